@@ -11,6 +11,12 @@ var useNodeJSDockerImage = bb.NewDocker().
 	Pull(bb.DockerPullIfNotExists).
 	Shell("/bin/bash")
 
+// goBuilderVersion is the version of the go-builder toolchain the backend jobs run in. Bump it whenever
+// build/docker/go-builder/Dockerfile changes, since a registered toolchain version can't be changed.
+const goBuilderVersion = "1.4"
+
+var goBuilderToolchain = fmt.Sprintf("go-builder@%s", goBuilderVersion)
+
 var goJobFingerprint = []string{
 	`find build/scripts -type f | sort | xargs sha1sum`,
 	`find backend/ -name '*.go' -not -path "*/vendor/*" -type f | sort | xargs sha1sum`,
@@ -25,7 +31,7 @@ var frontendJobFingerprint = []string{
 
 func main() {
 	bb.Workflows(
-		bb.NewWorkflow().Name("base").Handler(submitBaseJobs),
+		bb.NewWorkflow().Name("base").Handler(submitBaseJobs).Output("go-docker-config", bb.NewDocker()),
 		bb.NewWorkflow().Name("generate").Handler(submitGenerateJobs),
 		bb.NewWorkflow().Name("unit-test").Handler(submitUnitTestJobs),
 		bb.NewWorkflow().Name("integration-test").Handler(submitIntegrationTestJobs),
@@ -56,23 +62,26 @@ func submitBaseJobs(w *bb.Workflow) error {
 				"apk add bash git aws-cli",
 				"git config --global --add safe.directory $(pwd)",
 				// Use -p option to push docker image to registry, when using multiple runners
-				//"./build/scripts/build-docker.sh -t $BB_JOB_FINGERPRINT -p go-builder")).
-				"./build/scripts/build-docker.sh -t $BB_JOB_FINGERPRINT go-builder")).
+				//fmt.Sprintf("./build/scripts/build-docker.sh -t %s -p go-builder", goBuilderVersion))).
+				fmt.Sprintf("./build/scripts/build-docker.sh -t %s go-builder", goBuilderVersion))).
 		OnSuccess(func(event *bb.JobStatusChangedEvent) {
-			// Calculate the docker image name from this job's fingerprint
-			jGraph := w.GetBuild().MustGetJobGraph(event.JobID)
+			// Register the image just built as a toolchain, so later jobs can refer to it by name and version.
+			// This assumes the build is running using a single runner for all jobs (e.g. when running
+			// using the bb command line tool), so the docker image can just be local
+			w.GetBuild().MustRegisterToolchain("go-builder", goBuilderVersion, fmt.Sprintf("go-builder:%s", goBuilderVersion), "")
 
-			// Make a Docker Config for later steps to use to pull the docker image just built
-			// This config assumes the build is running using a single runner for all jobs (e.g. when running
-			// using the= bb command line tool), so the docker image can just be local
+			// If pushing image to ECR registry then register the pushed image instead, and make a docker config
+			// that authenticates to AWS to pull it
+			//w.GetBuild().MustRegisterToolchain("go-builder", goBuilderVersion,
+			//	fmt.Sprintf("fill-this-out.dkr.ecr.us-west-2.amazonaws.com/go-builder:%s", goBuilderVersion), "")
+
+			// Make a Docker Config for later jobs to run in the toolchain
 			goDockerConfig := bb.NewDocker().
-				Image(fmt.Sprintf("go-builder:%s", *jGraph.Job.Fingerprint)).
+				Toolchain(goBuilderToolchain).
 				Pull(bb.DockerPullNever).
 				Shell("/bin/bash")
-
-			// If pushing image to ECR registry then make a docker config that authenticates to AWS and pulls from ECR
 			//goDockerConfig := bb.NewDocker().
-			//	Image(fmt.Sprintf("fill-this-out.dkr.ecr.us-west-2.amazonaws.com/go-builder:%s", *jGraph.Job.Fingerprint)).
+			//	Toolchain(goBuilderToolchain).
 			//	Pull(bb.DockerPullIfNotExists).
 			//	Shell("/bin/bash").
 			//	AWSAuth(bb.NewAWSAuth().
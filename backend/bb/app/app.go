@@ -15,4 +15,8 @@ type App struct {
 	APIServer       *bb_server.BBAPIServer
 	LogFactory      logger.LogFactory
 	LogService      services.LogService
+	BuildService    services.BuildService
+	JobService      services.JobService
+	StepService     services.StepService
+	QueueService    services.QueueService
 }
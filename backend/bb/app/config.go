@@ -11,11 +11,15 @@ import (
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/runner"
 	"github.com/buildbeaver/buildbeaver/runner/logging"
+	"github.com/buildbeaver/buildbeaver/runner/plugins"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
+	"github.com/buildbeaver/buildbeaver/server/services/artifact"
+	"github.com/buildbeaver/buildbeaver/server/services/authentication"
 	"github.com/buildbeaver/buildbeaver/server/services/blob"
 	"github.com/buildbeaver/buildbeaver/server/services/credential"
 	"github.com/buildbeaver/buildbeaver/server/services/encryption"
 	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/services/oidc"
 	"github.com/buildbeaver/buildbeaver/server/services/queue"
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
@@ -30,6 +34,7 @@ type BBConfig struct {
 	LogFilePath              logger.LogFilePath
 	LogLevels                logger.LogLevelConfig
 	DatabaseConfig           store.DatabaseConfig
+	NotifierConfig           store.NotifierConfig
 	DatabaseFilePath         string
 	LocalBlobStoreDir        blob.LocalBlobStoreDirectory
 	RunnerLogTempDir         logging.RunnerLogTempDirectory
@@ -37,9 +42,22 @@ type BBConfig struct {
 	SchedulerConfig          runner.SchedulerConfig
 	ExecutorConfig           runner.ExecutorConfig
 	JWTConfig                credential.JWTConfig
-	LimitsConfig             queue.LimitsConfig
-	JSON                     local_backend.JSONOutput
-	Verbose                  local_backend.VerboseOutput
+	// ArtifactSigningConfig is left empty since artifacts from local builds don't need to be signed
+	ArtifactSigningConfig artifact.ArtifactSigningConfig
+	// OIDCConfig is left empty since local builds can't be issued OIDC tokens
+	OIDCConfig oidc.OIDCConfig
+	// SAMLConfig is left empty since users don't log in to local builds
+	SAMLConfig   authentication.SAMLConfig
+	LimitsConfig queue.LimitsConfig
+	// ImageConfig is left empty since local builds pull images directly, or via the runner's registry mirror
+	ImageConfig    queue.ImageConfig
+	JSON           local_backend.JSONOutput
+	Verbose        local_backend.VerboseOutput
+	StatusPagesURL local_backend.StatusPagesURL
+	// EnvOverrides, SecretOverrides and SecretsFile are set from the command line when running a local build
+	EnvOverrides    local_backend.EnvOverrides
+	SecretOverrides local_backend.SecretOverrides
+	SecretsFile     local_backend.SecretsFilePath
 }
 
 func NewBBConfig(workDir string, verbose bool, jsonOutput bool) *BBConfig {
@@ -57,6 +75,8 @@ func NewBBConfig(workDir string, verbose bool, jsonOutput bool) *BBConfig {
 	localServerAddress := fmt.Sprintf("localhost:%d", DefaultLocalServerPort)
 	dynamicAPIEndpoint := dynamic_api.Endpoint(fmt.Sprintf("http://%s", localServerAddress))
 
+	jobTypePlugins := plugins.Registered()
+
 	return &BBConfig{
 		BBAPIConfig: bb_server.BBAPIServerConfig{
 			HTTPServerConfig: server.HTTPServerConfig{
@@ -79,13 +99,16 @@ func NewBBConfig(workDir string, verbose bool, jsonOutput bool) *BBConfig {
 		LogServiceConfig:         log.LogServiceConfig{WriterConfig: log.DefaultWriterConfig},
 		JSON:                     local_backend.JSONOutput(jsonOutput),
 		Verbose:                  local_backend.VerboseOutput(verbose),
+		StatusPagesURL:           local_backend.StatusPagesURL(fmt.Sprintf("http://%s/status", localServerAddress)),
 		SchedulerConfig: runner.SchedulerConfig{
-			PollInterval: runner.DefaultPollInterval,
-			ParallelJobs: runner.DefaultParallelBuilds,
+			PollInterval:      runner.DefaultPollInterval,
+			ParallelJobs:      runner.DefaultParallelBuilds,
+			SupportedJobTypes: jobTypePlugins.SupportedJobTypes(),
 		},
 		ExecutorConfig: runner.ExecutorConfig{
 			IsLocal:            true,
 			DynamicAPIEndpoint: dynamicAPIEndpoint,
+			JobTypePlugins:     jobTypePlugins,
 		},
 		JWTConfig: credential.JWTConfig{
 			CertificateFile:   certificates.CertificateFile(jwtCertFilePath),
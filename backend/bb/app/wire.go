@@ -23,14 +23,20 @@ import (
 	"github.com/buildbeaver/buildbeaver/server/services/authorization"
 	"github.com/buildbeaver/buildbeaver/server/services/blob"
 	"github.com/buildbeaver/buildbeaver/server/services/build"
+	"github.com/buildbeaver/buildbeaver/server/services/build_comparison"
+	"github.com/buildbeaver/buildbeaver/server/services/build_rule_set"
 	"github.com/buildbeaver/buildbeaver/server/services/credential"
+	"github.com/buildbeaver/buildbeaver/server/services/custom_status"
 	"github.com/buildbeaver/buildbeaver/server/services/encryption"
 	"github.com/buildbeaver/buildbeaver/server/services/event"
 	"github.com/buildbeaver/buildbeaver/server/services/group"
 	"github.com/buildbeaver/buildbeaver/server/services/job"
+	"github.com/buildbeaver/buildbeaver/server/services/job_analytics"
 	"github.com/buildbeaver/buildbeaver/server/services/keypair"
+	"github.com/buildbeaver/buildbeaver/server/services/leader_election"
 	"github.com/buildbeaver/buildbeaver/server/services/legal_entity"
 	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/services/oidc"
 	"github.com/buildbeaver/buildbeaver/server/services/queue"
 	"github.com/buildbeaver/buildbeaver/server/services/repo"
 	"github.com/buildbeaver/buildbeaver/server/services/runner"
@@ -38,28 +44,41 @@ import (
 	"github.com/buildbeaver/buildbeaver/server/services/secret"
 	"github.com/buildbeaver/buildbeaver/server/services/step"
 	"github.com/buildbeaver/buildbeaver/server/services/sync"
+	"github.com/buildbeaver/buildbeaver/server/services/toolchain"
+	"github.com/buildbeaver/buildbeaver/server/services/usage"
 	"github.com/buildbeaver/buildbeaver/server/store"
 	"github.com/buildbeaver/buildbeaver/server/store/artifacts"
 	"github.com/buildbeaver/buildbeaver/server/store/authorizations"
+	"github.com/buildbeaver/buildbeaver/server/store/build_rule_sets"
 	"github.com/buildbeaver/buildbeaver/server/store/builds"
 	"github.com/buildbeaver/buildbeaver/server/store/commits"
 	"github.com/buildbeaver/buildbeaver/server/store/credentials"
+	"github.com/buildbeaver/buildbeaver/server/store/custom_statuses"
 	"github.com/buildbeaver/buildbeaver/server/store/events"
 	"github.com/buildbeaver/buildbeaver/server/store/grants"
 	"github.com/buildbeaver/buildbeaver/server/store/group_memberships"
 	"github.com/buildbeaver/buildbeaver/server/store/groups"
 	"github.com/buildbeaver/buildbeaver/server/store/identities"
 	"github.com/buildbeaver/buildbeaver/server/store/jobs"
+	"github.com/buildbeaver/buildbeaver/server/store/leader_leases"
 	"github.com/buildbeaver/buildbeaver/server/store/legal_entities"
 	"github.com/buildbeaver/buildbeaver/server/store/legal_entity_memberships"
 	"github.com/buildbeaver/buildbeaver/server/store/logs"
 	"github.com/buildbeaver/buildbeaver/server/store/migrations"
 	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+	"github.com/buildbeaver/buildbeaver/server/store/pull_requests"
 	"github.com/buildbeaver/buildbeaver/server/store/repos"
 	"github.com/buildbeaver/buildbeaver/server/store/resource_links"
+	"github.com/buildbeaver/buildbeaver/server/store/runner_pools"
 	"github.com/buildbeaver/buildbeaver/server/store/runners"
+	"github.com/buildbeaver/buildbeaver/server/store/saml_identity_providers"
+	"github.com/buildbeaver/buildbeaver/server/store/secret_versions"
 	"github.com/buildbeaver/buildbeaver/server/store/secrets"
 	"github.com/buildbeaver/buildbeaver/server/store/steps"
+	"github.com/buildbeaver/buildbeaver/server/store/test_cases"
+	"github.com/buildbeaver/buildbeaver/server/store/toolchains"
+	"github.com/buildbeaver/buildbeaver/server/store/usage_quotas"
+	"github.com/buildbeaver/buildbeaver/server/store/usage_records"
 )
 
 func MakeLogPipelineFactory(
@@ -77,8 +96,9 @@ func New(ctx context.Context, config *BBConfig) (*App, func(), error) {
 		wire.Struct(new(App), "*"),
 		wire.Struct(new(local_backend.LocalBackendConfig), "*"),
 		local_backend.NewLocalBackend,
-		wire.FieldsOf(new(*BBConfig), "BBAPIConfig", "LocalBlobStoreDir", "LogFilePath", "LocalKeyManagerMasterKey", "DatabaseConfig", "RunnerLogTempDir", "SchedulerConfig", "ExecutorConfig", "LogLevels", "LogServiceConfig", "JWTConfig", "LimitsConfig", "JSON", "Verbose"),
+		wire.FieldsOf(new(*BBConfig), "BBAPIConfig", "LocalBlobStoreDir", "LogFilePath", "LocalKeyManagerMasterKey", "DatabaseConfig", "NotifierConfig", "RunnerLogTempDir", "SchedulerConfig", "ExecutorConfig", "LogLevels", "LogServiceConfig", "JWTConfig", "ArtifactSigningConfig", "OIDCConfig", "SAMLConfig", "LimitsConfig", "ImageConfig", "JSON", "Verbose", "StatusPagesURL", "EnvOverrides", "SecretOverrides", "SecretsFile"),
 		store.NewDatabase,
+		store.NewNotifier,
 		migrations.NewBBGolangMigrateRunner,
 		wire.Bind(new(store.MigrationRunner), new(*migrations.GolangMigrateRunner)),
 		scm.NewSCMRegistry, // can stay empty but required for some components
@@ -96,6 +116,8 @@ func New(ctx context.Context, config *BBConfig) (*App, func(), error) {
 		wire.Bind(new(store.StepStore), new(*steps.StepStore)),
 		secrets.NewStore,
 		wire.Bind(new(store.SecretStore), new(*secrets.SecretStore)),
+		secret_versions.NewStore,
+		wire.Bind(new(store.SecretVersionStore), new(*secret_versions.SecretVersionStore)),
 		ownerships.NewStore,
 		wire.Bind(new(store.OwnershipStore), new(*ownerships.OwnershipStore)),
 		legal_entities.NewStore,
@@ -108,8 +130,24 @@ func New(ctx context.Context, config *BBConfig) (*App, func(), error) {
 		wire.Bind(new(store.AuthorizationStore), new(*authorizations.AuthorizationStore)),
 		artifacts.NewStore,
 		wire.Bind(new(store.ArtifactStore), new(*artifacts.ArtifactStore)),
+		test_cases.NewStore,
+		wire.Bind(new(store.TestCaseStore), new(*test_cases.TestCaseStore)),
+		custom_statuses.NewStore,
+		wire.Bind(new(store.CustomStatusStore), new(*custom_statuses.CustomStatusStore)),
+		toolchains.NewStore,
+		wire.Bind(new(store.ToolchainStore), new(*toolchains.ToolchainStore)),
+		usage_records.NewStore,
+		wire.Bind(new(store.UsageRecordStore), new(*usage_records.UsageRecordStore)),
+		usage_quotas.NewStore,
+		wire.Bind(new(store.UsageQuotaStore), new(*usage_quotas.UsageQuotaStore)),
+		leader_leases.NewStore,
+		wire.Bind(new(store.LeaderLeaseStore), new(*leader_leases.LeaderLeaseStore)),
+		build_rule_sets.NewStore,
+		wire.Bind(new(store.BuildRuleSetStore), new(*build_rule_sets.BuildRuleSetStore)),
 		runners.NewStore,
 		wire.Bind(new(store.RunnerStore), new(*runners.RunnerStore)),
+		runner_pools.NewStore,
+		wire.Bind(new(store.RunnerPoolStore), new(*runner_pools.RunnerPoolStore)),
 		credentials.NewStore,
 		wire.Bind(new(store.CredentialStore), new(*credentials.CredentialStore)),
 		groups.NewStore,
@@ -120,10 +158,14 @@ func New(ctx context.Context, config *BBConfig) (*App, func(), error) {
 		wire.Bind(new(store.GrantStore), new(*grants.GrantStore)),
 		resource_links.NewStore,
 		wire.Bind(new(store.ResourceLinkStore), new(*resource_links.ResourceLinkStore)),
+		pull_requests.NewStore,
+		wire.Bind(new(store.PullRequestStore), new(*pull_requests.PullRequestStore)),
 		logs.NewStore,
 		wire.Bind(new(store.LogStore), new(*logs.LogStore)),
 		events.NewStore,
 		wire.Bind(new(store.EventStore), new(*events.EventStore)),
+		saml_identity_providers.NewStore,
+		wire.Bind(new(store.SAMLIdentityProviderStore), new(*saml_identity_providers.SAMLIdentityProviderStore)),
 
 		// Services
 		queue.NewQueueService,
@@ -148,6 +190,22 @@ func New(ctx context.Context, config *BBConfig) (*App, func(), error) {
 		runner2.NewJobScheduler,
 		build.NewBuildService,
 		wire.Bind(new(services.BuildService), new(*build.BuildService)),
+		build_comparison.NewBuildComparisonService,
+		wire.Bind(new(services.BuildComparisonService), new(*build_comparison.BuildComparisonService)),
+		job_analytics.NewJobAnalyticsService,
+		wire.Bind(new(services.JobAnalyticsService), new(*job_analytics.JobAnalyticsService)),
+		custom_status.NewCustomStatusService,
+		wire.Bind(new(services.CustomStatusService), new(*custom_status.CustomStatusService)),
+		toolchain.NewToolchainService,
+		wire.Bind(new(services.ToolchainService), new(*toolchain.ToolchainService)),
+		usage.NewUsageService,
+		wire.Bind(new(services.UsageService), new(*usage.UsageService)),
+		leader_election.NewLeaderElectionService,
+		wire.Bind(new(services.LeaderElectionService), new(*leader_election.LeaderElectionService)),
+		oidc.NewOIDCService,
+		wire.Bind(new(services.OIDCService), new(*oidc.OIDCService)),
+		build_rule_set.NewBuildRuleSetService,
+		wire.Bind(new(services.BuildRuleSetService), new(*build_rule_set.BuildRuleSetService)),
 		job.NewJobService,
 		wire.Bind(new(services.JobService), new(*job.JobService)),
 		step.NewStepService,
@@ -156,6 +214,9 @@ func New(ctx context.Context, config *BBConfig) (*App, func(), error) {
 		wire.Bind(new(services.GroupService), new(*group.GroupService)),
 		authorization.NewAuthorizationService,
 		wire.Bind(new(services.AuthorizationService), new(*authorization.AuthorizationService)),
+		// The built-in runner doesn't report its health, so health checks are left disabled
+		wire.Value(runner.RunnerHealthConfig{}),
+		wire.Value(leader_election.LeaderElectionConfig{}),
 		runner.NewRunnerService,
 		wire.Bind(new(services.RunnerService), new(*runner.RunnerService)),
 		event.NewEventService,
@@ -176,6 +237,10 @@ func New(ctx context.Context, config *BBConfig) (*App, func(), error) {
 		server.NewLogAPI,
 		wire.Bind(new(server.ArtifactAPIDynamic), new(*bb_server.ArtifactAPIProxy)),
 		server.NewArtifactAPI,
+		server.NewCustomStatusAPI,
+		server.NewToolchainAPI,
+		server.NewOIDCAPI,
+		server.NewStatusPagesAPI,
 		bb_server.NewArtifactAPIProxy,
 		server.NewRootAPI,
 		server.NewBuildAPI,
@@ -1,6 +1,8 @@
 package bb_server
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/local_backend"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
 	"github.com/buildbeaver/buildbeaver/server/services"
@@ -75,6 +78,40 @@ func (a *ArtifactAPIProxy) GetData(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetVerification processes an artifact GetVerification API request by hashing the artifact data on the local
+// filesystem, since local builds don't store artifact data on the server. Artifacts from local builds are not signed.
+func (a *ArtifactAPIProxy) GetVerification(w http.ResponseWriter, r *http.Request) {
+	artifactID, err := a.AuthorizedArtifactID(r, models.ArtifactReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	artifact, err := a.artifactService.Read(r.Context(), nil, artifactID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	reader, err := a.localBackend.GetArtifactLocalData(artifact)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, reader)
+	if err != nil {
+		a.Error(w, r, fmt.Errorf("error hashing artifact data: %w", err))
+		return
+	}
+	verification := &models.ArtifactVerification{
+		ArtifactID:       artifact.ID,
+		SHA256:           artifact.SHA256,
+		CalculatedSHA256: hex.EncodeToString(hash.Sum(nil)),
+	}
+	verification.DataValid = verification.CalculatedSHA256 == artifact.SHA256
+	a.JSON(w, r, documents.MakeArtifactVerification(routes.RequestCtx(r), verification))
+}
+
 func (a *ArtifactAPIProxy) List(w http.ResponseWriter, r *http.Request) {
 	a.realAPI.List(w, r)
 }
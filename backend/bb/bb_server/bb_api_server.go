@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/local_backend"
 	"github.com/buildbeaver/buildbeaver/common/logger"
+	bbmiddleware "github.com/buildbeaver/buildbeaver/server/api/rest/middleware"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
 	"github.com/buildbeaver/buildbeaver/server/services"
 	"github.com/go-chi/chi/v5"
@@ -46,7 +48,12 @@ func NewBBAPIRouter(
 	build *server.BuildAPI,
 	job *server.JobAPI,
 	dynamicJobAPI server.DynamicJobAPIDynamic,
+	customStatus *server.CustomStatusAPI,
+	toolchain *server.ToolchainAPI,
+	oidc *server.OIDCAPI,
+	statusPages *server.StatusPagesAPI,
 	root *server.RootAPI,
+	localBackend *local_backend.LocalBackend,
 	authenticationService services.AuthenticationService,
 	logFactory logger.LogFactory,
 ) *BBAPIRouter {
@@ -69,9 +76,17 @@ func NewBBAPIRouter(
 				r.Get("/", root.GetRootDocument)
 			})
 
-			// Routes for Dynamic API clients to interact with; note this includes some read-only API functions
-			r.Group(server.DynamicJobAPIRouterFactory(dynamicJobAPI, build, job, artifact, log, authenticationService, logFactory))
+			// Routes for Dynamic API clients to interact with; note this includes some read-only API functions.
+			// Local builds are only run by a single user, so requests are not rate limited.
+			noRateLimit := bbmiddleware.MakeRateLimiter(logger, nil, "event_fetch", "build event fetches", bbmiddleware.RateLimitConfig{})
+			r.Group(server.DynamicJobAPIRouterFactory(dynamicJobAPI, build, job, artifact, log, customStatus, toolchain, oidc, authenticationService, noRateLimit, logFactory))
 		})
 	})
+
+	// Server-rendered status pages for the local build, viewed in a browser as the local user
+	r.Group(func(r chi.Router) {
+		r.Use(bbmiddleware.MakeLocalAuthenticator(logger, localBackend.GetIdentityID))
+		r.Group(server.StatusPagesRouterFactory(statusPages))
+	})
 	return &BBAPIRouter{Router: r}
 }
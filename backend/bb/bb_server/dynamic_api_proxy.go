@@ -39,3 +39,14 @@ func (a *DynamicJobAPIProxy) CreateJobs(w http.ResponseWriter, r *http.Request)
 		a.localBackend.NewJobsCreated(r.Context(), newJobs)
 	}
 }
+
+// UpdateJobs updates a set of jobs that were previously added to the build dynamically.
+func (a *DynamicJobAPIProxy) UpdateJobs(w http.ResponseWriter, r *http.Request) {
+	updatedJobs := a.realAPI.UpdateAndReturnJobs(w, r)
+
+	// updatedJobs will be nil if an error occurred
+	if len(updatedJobs) > 0 {
+		// Re-read the build so the local backend sees the updated jobs and any new steps
+		a.localBackend.NewJobsCreated(r.Context(), updatedJobs)
+	}
+}
@@ -0,0 +1,194 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/bb/app"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// latestBuild can be specified instead of a build ID to download artifacts from the most recent local build.
+const latestBuild = "latest"
+
+func init() {
+	artifactsRootCmd.PersistentFlags().StringVar(
+		&artifactsCmdConfig.workDir,
+		"workdir",
+		"~/.bb/local",
+		"The scratch space used for local builds")
+	artifactsRootCmd.PersistentFlags().StringVar(
+		&artifactsCmdConfig.server,
+		"server",
+		"",
+		"The URL of the BuildBeaver server to download artifacts from, or empty to download artifacts from local builds")
+	artifactsRootCmd.PersistentFlags().StringVar(
+		&artifactsCmdConfig.token,
+		"token",
+		os.Getenv("BB_TOKEN"),
+		"A personal access token with permission to read artifacts on the server (defaults to $BB_TOKEN)")
+	artifactsDownloadCmd.Flags().StringVar(
+		&artifactsCmdConfig.group,
+		"group",
+		"",
+		"Only download artifacts in the group with this name")
+	artifactsDownloadCmd.Flags().StringVar(
+		&artifactsCmdConfig.dest,
+		"dest",
+		".",
+		"The directory to download artifacts into")
+	commands.RootCmd.AddCommand(artifactsRootCmd)
+	artifactsRootCmd.AddCommand(artifactsDownloadCmd)
+}
+
+var artifactsCmdConfig = struct {
+	workDir string
+	server  string
+	token   string
+	group   string
+	dest    string
+}{}
+
+var artifactsRootCmd = &cobra.Command{
+	Use:   "artifacts download",
+	Short: "Work with the artifacts produced by local or remote builds",
+}
+
+var artifactsDownloadCmd = &cobra.Command{
+	Use:           "download <build>",
+	Short:         "Download and verify the artifacts produced by a build, or by the latest local build if <build> is \"latest\"",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			err error
+			ctx = context.Background()
+		)
+
+		var groupName *models.ResourceName
+		if artifactsCmdConfig.group != "" {
+			name := models.ResourceName(artifactsCmdConfig.group)
+			err = name.Validate()
+			if err != nil {
+				return fmt.Errorf("error invalid --group: %w", err)
+			}
+			groupName = &name
+		}
+		dest, err := utils.HomeifyPath(artifactsCmdConfig.dest)
+		if err != nil {
+			return err
+		}
+
+		var (
+			source  utils.ArtifactSource
+			buildID models.BuildID
+		)
+		if artifactsCmdConfig.server != "" {
+			if args[0] == latestBuild {
+				return fmt.Errorf("error %q can only be used with local builds; specify a build ID", latestBuild)
+			}
+			buildID, err = parseBuildID(args[0])
+			if err != nil {
+				return err
+			}
+			source, err = makeRemoteArtifactSource()
+			if err != nil {
+				return err
+			}
+		} else {
+			var cleanup func()
+			source, buildID, cleanup, err = makeLocalArtifactSource(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+		}
+
+		var progress io.Writer
+		if !commands.Global.JSON {
+			progress = os.Stdout
+		}
+		report, err := utils.DownloadArtifacts(ctx, source, buildID, groupName, dest, progress)
+		if err != nil {
+			return err
+		}
+		return report.Write(os.Stdout, commands.Global.JSON)
+	},
+}
+
+func parseBuildID(str string) (models.BuildID, error) {
+	id, err := models.ParseResourceID(str)
+	if err != nil || id.Kind() != models.BuildResourceKind {
+		return models.BuildID{}, fmt.Errorf("error %q is not a build ID", str)
+	}
+	return models.BuildIDFromResourceID(id), nil
+}
+
+// makeRemoteArtifactSource makes a source for artifacts produced by builds on the configured server.
+func makeRemoteArtifactSource() (utils.ArtifactSource, error) {
+	apiClient, err := utils.NewServerAPIClient(artifactsCmdConfig.server, artifactsCmdConfig.token)
+	if err != nil {
+		return nil, err
+	}
+	return utils.NewRemoteArtifactSource(apiClient), nil
+}
+
+// makeLocalArtifactSource makes a source for artifacts produced by local builds, and resolves buildArg to the ID
+// of a local build. The returned cleanup function must be called once the artifacts have been downloaded.
+func makeLocalArtifactSource(ctx context.Context, buildArg string) (utils.ArtifactSource, models.BuildID, func(), error) {
+	var (
+		buildID models.BuildID
+		err     error
+	)
+	if buildArg != latestBuild {
+		buildID, err = parseBuildID(buildArg)
+		if err != nil {
+			return nil, models.BuildID{}, nil, err
+		}
+	}
+
+	lockFile, err := utils.GetBBFileLock()
+	if err != nil {
+		return nil, models.BuildID{}, nil, errors.Wrap(err, "Error: Another instance of BB is currently running")
+	}
+
+	workDir, err := utils.HomeifyPath(artifactsCmdConfig.workDir)
+	if err != nil {
+		lockFile.Close()
+		return nil, models.BuildID{}, nil, err
+	}
+	config := app.NewBBConfig(workDir, false, commands.Global.JSON)
+	bb, appCleanup, err := app.New(ctx, config)
+	if err != nil {
+		lockFile.Close()
+		return nil, models.BuildID{}, nil, errors.Wrap(err, "error initializing app")
+	}
+	cleanup := func() {
+		appCleanup()
+		lockFile.Close()
+	}
+
+	if buildArg == latestBuild {
+		search := models.NewBuildSearch()
+		search.Limit = 1
+		builds, _, err := bb.BuildService.Search(ctx, nil, models.NoIdentity, search)
+		if err != nil {
+			cleanup()
+			return nil, models.BuildID{}, nil, fmt.Errorf("error finding latest local build: %w", err)
+		}
+		if len(builds) == 0 {
+			cleanup()
+			return nil, models.BuildID{}, nil, fmt.Errorf("error no local builds found; use 'bb run' to run a build")
+		}
+		buildID = builds[0].ID
+	}
+	return utils.NewLocalArtifactSource(bb.Backend), buildID, cleanup, nil
+}
@@ -1,7 +1,9 @@
 package cleanup
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -23,18 +25,42 @@ func init() {
 		"verbose",
 		"v",
 		false,
-		"Enable verbose log output")
+		"Enable verbose log output, including each resource using disk space")
+	cleanupRootCmd.PersistentFlags().BoolVar(
+		&cleanupCmdConfig.report,
+		"report",
+		false,
+		"Report disk usage and what would be reclaimed, without removing anything")
+	cleanupRootCmd.PersistentFlags().StringSliceVar(
+		&cleanupCmdConfig.categories,
+		"category",
+		nil,
+		fmt.Sprintf("Only reclaim disk space from these categories (default all): %v", utils.DiskUsageCategories))
+	cleanupRootCmd.PersistentFlags().IntVar(
+		&cleanupCmdConfig.keepLast,
+		"keep-last",
+		0,
+		"Keep the N most recently created resources in each category")
+	cleanupRootCmd.PersistentFlags().DurationVar(
+		&cleanupCmdConfig.olderThan,
+		"older-than",
+		0,
+		"Only reclaim resources created longer ago than this duration (e.g. 72h)")
 	commands.RootCmd.AddCommand(cleanupRootCmd)
 }
 
 var cleanupCmdConfig = struct {
-	workDir string
-	verbose bool
+	workDir    string
+	verbose    bool
+	report     bool
+	categories []string
+	keepLast   int
+	olderThan  time.Duration
 }{}
 
 var cleanupRootCmd = &cobra.Command{
 	Use:           "cleanup",
-	Short:         "Clean up resources (including docker containers and networks) left over from previous runs",
+	Short:         "Clean up resources left over from previous runs and report or reclaim the disk space they use",
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -43,6 +69,19 @@ var cleanupRootCmd = &cobra.Command{
 			ctx = context.Background()
 		)
 
+		categories, err := utils.ParseDiskUsageCategories(cleanupCmdConfig.categories)
+		if err != nil {
+			return err
+		}
+		if cleanupCmdConfig.keepLast < 0 {
+			return fmt.Errorf("error --keep-last must not be negative")
+		}
+		filter := utils.DiskUsageFilter{
+			Categories: categories,
+			KeepLast:   cleanupCmdConfig.keepLast,
+			OlderThan:  cleanupCmdConfig.olderThan,
+		}
+
 		lockFile, err := utils.GetBBFileLock()
 		if err != nil {
 			return errors.Wrap(err, "Error: Another instance of BB is currently running")
@@ -56,9 +95,6 @@ var cleanupRootCmd = &cobra.Command{
 
 		config := app.NewBBConfig(cleanupCmdConfig.workDir, cleanupCmdConfig.verbose, false)
 
-		// Clear out all old blobs
-		os.Remove(config.LocalBlobStoreDir.String())
-
 		bb, cleanup, err := app.New(ctx, config)
 		if err != nil {
 			// The local sqlite database is effectively a cache. Blow it away at the first
@@ -71,8 +107,27 @@ var cleanupRootCmd = &cobra.Command{
 		}
 		defer cleanup()
 
-		utils.CleanUpOldResources(bb, true)
+		// Remove left over containers and networks first so they don't hold on to images or volumes
+		if !cleanupCmdConfig.report {
+			utils.CleanUpOldResources(bb, true)
+		}
 
+		report, err := utils.GetDiskUsage(ctx, bb, config)
+		if err != nil {
+			return errors.Wrap(err, "error reporting disk usage")
+		}
+		report.Select(filter)
+		var reclaimErr error
+		if !cleanupCmdConfig.report {
+			reclaimErr = report.Reclaim(ctx)
+		}
+		err = report.Write(os.Stdout, commands.Global.JSON, cleanupCmdConfig.verbose)
+		if err != nil {
+			return err
+		}
+		if reclaimErr != nil {
+			return errors.New("error: unable to remove some resources")
+		}
 		return nil
 	},
 }
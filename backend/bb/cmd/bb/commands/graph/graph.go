@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/bb/app"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// latestBuild can be specified instead of a build ID to show the jobs in the most recent local build.
+const latestBuild = "latest"
+
+func init() {
+	graphRootCmd.PersistentFlags().StringVar(
+		&graphCmdConfig.workDir,
+		"workdir",
+		"~/.bb/local",
+		"The scratch space used for local builds")
+	graphRootCmd.PersistentFlags().StringVar(
+		&graphCmdConfig.format,
+		"format",
+		string(utils.GraphFormatDot),
+		"The format to print the graph in: dot or mermaid")
+	graphRootCmd.PersistentFlags().BoolVar(
+		&graphCmdConfig.dynamic,
+		"dynamic",
+		false,
+		"Read the file as jobs submitted by a dynamic build, allowing dependencies on jobs that aren't in the file")
+	graphRootCmd.PersistentFlags().StringVar(
+		&graphCmdConfig.build,
+		"build",
+		"",
+		"Show the jobs in a local build, including any jobs added by a dynamic build, instead of reading a build config; "+
+			"specify a build ID or \"latest\"")
+	commands.RootCmd.AddCommand(graphRootCmd)
+}
+
+var graphCmdConfig = struct {
+	workDir string
+	format  string
+	dynamic bool
+	build   string
+}{}
+
+var graphRootCmd = &cobra.Command{
+	Use:           "graph [file]",
+	Short:         "Print the job dependency graph for the build config in the root of the repo, or the specified file",
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		format := utils.GraphFormat(graphCmdConfig.format)
+		err := format.Validate()
+		if err != nil {
+			return err
+		}
+		if graphCmdConfig.build != "" && len(args) > 0 {
+			return fmt.Errorf("error specify either a file or --build, not both")
+		}
+
+		// Nothing is written, so don't take the BB lock file
+		workDir, err := utils.HomeifyPath(graphCmdConfig.workDir)
+		if err != nil {
+			return err
+		}
+		// This can be run before any local builds, so make sure the scratch space exists
+		err = os.MkdirAll(workDir, 0770)
+		if err != nil {
+			return fmt.Errorf("error making work directory %q: %w", workDir, err)
+		}
+		config := app.NewBBConfig(workDir, false, commands.Global.JSON)
+		bb, cleanup, err := app.New(ctx, config)
+		if err != nil {
+			return errors.Wrap(err, "error initializing app")
+		}
+		defer cleanup()
+
+		var graph *dto.BuildGraph
+		if graphCmdConfig.build != "" {
+			graph, err = readBuildGraph(ctx, bb, graphCmdConfig.build)
+			if err != nil {
+				return err
+			}
+		} else {
+			var configPath string
+			if len(args) > 0 {
+				configPath = args[0]
+			}
+			configPath, root, err := utils.FindBuildConfig(configPath)
+			if err != nil {
+				return err
+			}
+			report, err := utils.CheckBuildConfig(bb.QueueService, configPath, root, graphCmdConfig.dynamic)
+			if err != nil {
+				return err
+			}
+			if !report.Valid {
+				// Report the problems on stderr, so the graph output can be piped elsewhere
+				report.Write(os.Stderr, false)
+				return fmt.Errorf("error found %d problem(s) in %s; use 'bb validate' for details", len(report.Errors), report.File)
+			}
+			graph = report.Graph
+		}
+		return utils.WriteBuildGraph(os.Stdout, graph, format)
+	},
+}
+
+// readBuildGraph reads the graph of jobs in the local build identified by buildArg.
+func readBuildGraph(ctx context.Context, bb *app.App, buildArg string) (*dto.BuildGraph, error) {
+	var buildID models.BuildID
+	if buildArg == latestBuild {
+		search := models.NewBuildSearch()
+		search.Limit = 1
+		builds, _, err := bb.BuildService.Search(ctx, nil, models.NoIdentity, search)
+		if err != nil {
+			return nil, fmt.Errorf("error finding latest local build: %w", err)
+		}
+		if len(builds) == 0 {
+			return nil, fmt.Errorf("error no local builds found; use 'bb run' to run a build")
+		}
+		buildID = builds[0].ID
+	} else {
+		id, err := models.ParseResourceID(buildArg)
+		if err != nil || id.Kind() != models.BuildResourceKind {
+			return nil, fmt.Errorf("error %q is not a build ID", buildArg)
+		}
+		buildID = models.BuildIDFromResourceID(id)
+	}
+	build, err := bb.QueueService.ReadQueuedBuild(ctx, nil, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading build: %w", err)
+	}
+	return build.BuildGraph, nil
+}
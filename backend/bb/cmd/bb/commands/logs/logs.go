@@ -0,0 +1,244 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/bb/app"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// latestBuild can be specified instead of an ID to show the log for the most recent local build.
+const latestBuild = "latest"
+
+func init() {
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.workDir,
+		"workdir",
+		"~/.bb/local",
+		"The scratch space used for local builds")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.server,
+		"server",
+		"",
+		"The URL of the BuildBeaver server to read logs from, or empty to read logs from local builds")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.token,
+		"token",
+		os.Getenv("BB_TOKEN"),
+		"A personal access token with permission to read builds on the server (defaults to $BB_TOKEN)")
+	logsRootCmd.PersistentFlags().BoolVarP(
+		&logsCmdConfig.follow,
+		"follow",
+		"f",
+		false,
+		"Keep streaming new log entries as they are written, until the build, job or step finishes")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.since,
+		"since",
+		"",
+		"Only show entries written since this time, as a duration before now (e.g. 10m) or an RFC 3339 timestamp")
+	logsRootCmd.PersistentFlags().BoolVarP(
+		&logsCmdConfig.timestamps,
+		"timestamps",
+		"t",
+		false,
+		"Show the time each entry was written")
+	logsRootCmd.PersistentFlags().BoolVar(
+		&logsCmdConfig.noColor,
+		"no-color",
+		false,
+		"Remove ANSI colors and other escape sequences from log entries instead of passing them through")
+	commands.RootCmd.AddCommand(logsRootCmd)
+}
+
+var logsCmdConfig = struct {
+	workDir    string
+	server     string
+	token      string
+	follow     bool
+	since      string
+	timestamps bool
+	noColor    bool
+}{}
+
+var logsRootCmd = &cobra.Command{
+	Use:           "logs <build|job|step>",
+	Short:         "Show the log for a build, job or step, or for the latest local build if the argument is \"latest\"",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			err error
+			ctx = context.Background()
+		)
+
+		opts := utils.LogTailOptions{
+			Follow:     logsCmdConfig.follow,
+			Timestamps: logsCmdConfig.timestamps,
+			NoColor:    logsCmdConfig.noColor,
+		}
+		if logsCmdConfig.since != "" {
+			opts.Since, err = utils.ParseLogSince(logsCmdConfig.since, time.Now())
+			if err != nil {
+				return fmt.Errorf("error invalid --since: %w", err)
+			}
+		}
+
+		var (
+			source utils.LogSource
+			logID  models.LogDescriptorID
+		)
+		if logsCmdConfig.server != "" {
+			source, logID, opts.Expand, err = makeRemoteLogSource(ctx, args[0])
+			if err != nil {
+				return err
+			}
+		} else {
+			var cleanup func()
+			source, logID, opts.Expand, cleanup, err = makeLocalLogSource(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+		}
+
+		// Stop following the log on Ctrl-C, rather than exiting mid-entry
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		return utils.TailLog(ctx, source, logID, opts, os.Stdout)
+	},
+}
+
+// parseTarget parses the ID of the build, job, step or log to show, and returns whether the log's child logs
+// (e.g. the logs for each step of a job) should be included. Steps don't have child logs, so their logs are
+// not expanded; this allows them to be streamed directly when following them.
+func parseTarget(arg string) (models.ResourceID, bool, error) {
+	id, err := models.ParseResourceID(arg)
+	if err != nil {
+		return models.ResourceID{}, false, fmt.Errorf("error %q is not a build, job or step ID", arg)
+	}
+	switch id.Kind() {
+	case models.BuildResourceKind, models.JobResourceKind, models.LogDescriptorResourceKind:
+		return id, true, nil
+	case models.StepResourceKind:
+		return id, false, nil
+	default:
+		return models.ResourceID{}, false, fmt.Errorf("error %q is not a build, job or step ID", arg)
+	}
+}
+
+// makeRemoteLogSource makes a source for logs written by builds on the configured server, and finds the ID of
+// the log to show for arg.
+func makeRemoteLogSource(ctx context.Context, arg string) (utils.LogSource, models.LogDescriptorID, bool, error) {
+	if arg == latestBuild {
+		return nil, models.LogDescriptorID{}, false, fmt.Errorf("error %q can only be used with local builds; specify a build, job or step ID", latestBuild)
+	}
+	id, expand, err := parseTarget(arg)
+	if err != nil {
+		return nil, models.LogDescriptorID{}, false, err
+	}
+	apiClient, err := utils.NewServerAPIClient(logsCmdConfig.server, logsCmdConfig.token)
+	if err != nil {
+		return nil, models.LogDescriptorID{}, false, err
+	}
+	var logID models.LogDescriptorID
+	switch id.Kind() {
+	case models.BuildResourceKind:
+		build, err := apiClient.GetBuild(ctx, models.BuildIDFromResourceID(id))
+		if err != nil {
+			return nil, models.LogDescriptorID{}, false, fmt.Errorf("error reading build: %w", err)
+		}
+		logID = build.Build.LogDescriptorID
+	case models.JobResourceKind:
+		job, err := apiClient.GetJob(ctx, models.JobIDFromResourceID(id))
+		if err != nil {
+			return nil, models.LogDescriptorID{}, false, fmt.Errorf("error reading job: %w", err)
+		}
+		logID = job.LogDescriptorID
+	case models.StepResourceKind:
+		// The API doesn't serve individual steps; the step's log ID is included in its job's graph
+		return nil, models.LogDescriptorID{}, false, fmt.Errorf("error steps can't be looked up on a server; specify the step's log ID instead")
+	default:
+		logID = models.LogDescriptorIDFromResourceID(id)
+	}
+	return utils.NewRemoteLogSource(apiClient), logID, expand, nil
+}
+
+// makeLocalLogSource makes a source for logs written by local builds, and finds the ID of the log to show for arg.
+// The returned cleanup function must be called once the log has been read.
+func makeLocalLogSource(ctx context.Context, arg string) (utils.LogSource, models.LogDescriptorID, bool, func(), error) {
+	var (
+		id     models.ResourceID
+		expand = true
+		err    error
+	)
+	if arg != latestBuild {
+		id, expand, err = parseTarget(arg)
+		if err != nil {
+			return nil, models.LogDescriptorID{}, false, nil, err
+		}
+	}
+
+	// Logs are only read, so don't take the BB lock file; this allows logs to be followed while 'bb run' is
+	// running a build in another terminal
+	workDir, err := utils.HomeifyPath(logsCmdConfig.workDir)
+	if err != nil {
+		return nil, models.LogDescriptorID{}, false, nil, err
+	}
+	config := app.NewBBConfig(workDir, false, commands.Global.JSON)
+	bb, cleanup, err := app.New(ctx, config)
+	if err != nil {
+		return nil, models.LogDescriptorID{}, false, nil, errors.Wrap(err, "error initializing app")
+	}
+
+	var logID models.LogDescriptorID
+	switch {
+	case arg == latestBuild:
+		search := models.NewBuildSearch()
+		search.Limit = 1
+		builds, _, err := bb.BuildService.Search(ctx, nil, models.NoIdentity, search)
+		if err != nil {
+			cleanup()
+			return nil, models.LogDescriptorID{}, false, nil, fmt.Errorf("error finding latest local build: %w", err)
+		}
+		if len(builds) == 0 {
+			cleanup()
+			return nil, models.LogDescriptorID{}, false, nil, fmt.Errorf("error no local builds found; use 'bb run' to run a build")
+		}
+		logID = builds[0].LogDescriptorID
+	case id.Kind() == models.BuildResourceKind:
+		build, err := bb.BuildService.Read(ctx, nil, models.BuildIDFromResourceID(id))
+		if err != nil {
+			cleanup()
+			return nil, models.LogDescriptorID{}, false, nil, fmt.Errorf("error reading build: %w", err)
+		}
+		logID = build.LogDescriptorID
+	case id.Kind() == models.JobResourceKind:
+		job, err := bb.JobService.Read(ctx, nil, models.JobIDFromResourceID(id))
+		if err != nil {
+			cleanup()
+			return nil, models.LogDescriptorID{}, false, nil, fmt.Errorf("error reading job: %w", err)
+		}
+		logID = job.LogDescriptorID
+	case id.Kind() == models.StepResourceKind:
+		step, err := bb.StepService.Read(ctx, nil, models.StepIDFromResourceID(id))
+		if err != nil {
+			cleanup()
+			return nil, models.LogDescriptorID{}, false, nil, fmt.Errorf("error reading step: %w", err)
+		}
+		logID = step.LogDescriptorID
+	default:
+		logID = models.LogDescriptorIDFromResourceID(id)
+	}
+	return utils.NewLocalLogSource(bb.LogService), logID, expand, cleanup, nil
+}
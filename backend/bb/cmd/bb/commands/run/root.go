@@ -12,8 +12,11 @@ import (
 
 	"github.com/buildbeaver/buildbeaver/bb/app"
 	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/local_backend"
 	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/runner"
+	"github.com/buildbeaver/buildbeaver/server/dto"
 )
 
 func init() {
@@ -39,14 +42,80 @@ func init() {
 		"skip-cleanup",
 		false,
 		"Do not attempt to clean up resources (including docker containers and networks) left over from previous runs")
+	runRootCmd.PersistentFlags().StringVar(
+		&runCmdConfig.servicesOverride,
+		"services-override",
+		"",
+		"A docker-compose style YAML file replacing the image, ports or environment of services used by jobs")
+	runRootCmd.PersistentFlags().StringArrayVar(
+		&runCmdConfig.stubOutputs,
+		"stub-output",
+		nil,
+		"Stub an output of a workflow with the JSON value in a file, in the form workflow.output=file. Stubbed workflows are not run")
+	runRootCmd.PersistentFlags().StringArrayVar(
+		&runCmdConfig.jobs,
+		"job",
+		nil,
+		"Run only the specified job and the jobs it depends on, in the form workflow.job (or job for the default workflow)")
+	runRootCmd.PersistentFlags().StringArrayVar(
+		&runCmdConfig.workflows,
+		"workflow",
+		nil,
+		"Run only the specified workflow and the jobs it depends on")
+	runRootCmd.PersistentFlags().BoolVar(
+		&runCmdConfig.noDeps,
+		"no-deps",
+		false,
+		"Don't run the jobs that the specified jobs depend on; use the artifacts they produced in a previous local build instead")
+	runRootCmd.PersistentFlags().StringArrayVar(
+		&runCmdConfig.env,
+		"env",
+		nil,
+		"Set an environment variable for every job in the form NAME=VALUE, replacing any value from the build config")
+	runRootCmd.PersistentFlags().StringArrayVar(
+		&runCmdConfig.params,
+		"param",
+		nil,
+		"Supply the value of a parameter declared in the build config in the form NAME=VALUE")
+	runRootCmd.PersistentFlags().StringArrayVar(
+		&runCmdConfig.secrets,
+		"secret",
+		nil,
+		"Provide the value of a secret in the form NAME=VALUE, in preference to $BB_SECRET_NAME, the secrets file or $NAME")
+	runRootCmd.PersistentFlags().StringVar(
+		&runCmdConfig.secretsFile,
+		"secrets-file",
+		"~/.bb/secrets.json",
+		"The encrypted file that secrets set with 'bb secrets set' are read from")
+	runRootCmd.PersistentFlags().BoolVar(
+		&runCmdConfig.watch,
+		"watch",
+		false,
+		"Keep watching the working copy for changes to files not ignored by git, and re-run the jobs affected by each change")
+	runRootCmd.PersistentFlags().DurationVar(
+		&runCmdConfig.debounce,
+		"debounce",
+		500*time.Millisecond,
+		"With --watch, how long to wait for changes to stop before re-running jobs")
 	commands.RootCmd.AddCommand(runRootCmd)
 }
 
 var runCmdConfig = struct {
-	workDir     string
-	verbose     bool
-	force       bool
-	skipCleanup bool
+	workDir          string
+	verbose          bool
+	force            bool
+	skipCleanup      bool
+	servicesOverride string
+	stubOutputs      []string
+	jobs             []string
+	workflows        []string
+	noDeps           bool
+	env              []string
+	params           []string
+	secrets          []string
+	secretsFile      string
+	watch            bool
+	debounce         time.Duration
 }{}
 
 var runRootCmd = &cobra.Command{
@@ -77,6 +146,27 @@ var runRootCmd = &cobra.Command{
 		}
 
 		config := app.NewBBConfig(runCmdConfig.workDir, runCmdConfig.verbose, commands.Global.JSON)
+		envOverrides, err := utils.ParseNameValuePairs("env", runCmdConfig.env)
+		if err != nil {
+			return err
+		}
+		config.EnvOverrides = envOverrides
+		secretOverrides, err := utils.ParseNameValuePairs("secret", runCmdConfig.secrets)
+		if err != nil {
+			return err
+		}
+		config.SecretOverrides = secretOverrides
+		secretsFile, err := utils.HomeifyPath(runCmdConfig.secretsFile)
+		if err != nil {
+			return err
+		}
+		config.SecretsFile = local_backend.SecretsFilePath(secretsFile)
+		if runCmdConfig.servicesOverride != "" {
+			config.ExecutorConfig.ServiceOverrides, err = runner.LoadServiceOverrides(runCmdConfig.servicesOverride)
+			if err != nil {
+				return err
+			}
+		}
 
 		// Clear out all old blobs - they don't need to persist between runs
 		os.Remove(config.LocalBlobStoreDir.String())
@@ -105,41 +195,35 @@ var runRootCmd = &cobra.Command{
 
 		bb.APIServer.Start()
 
-		fqns, err := utils.ParseNodeFQNS(args)
+		fqns, err := selectNodesToRun(ctx, bb, args)
 		if err != nil {
-			return fmt.Errorf("error parsing steps: %v", err)
+			return err
 		}
-		opts := &models.BuildOptions{NodesToRun: fqns, Force: runCmdConfig.force}
-
-		build, err := bb.Backend.Enqueue(ctx, opts)
+		stubs, err := utils.ParseWorkflowOutputStubs(runCmdConfig.stubOutputs)
 		if err != nil {
-			return fmt.Errorf("error queuing local build: %v", err)
+			return err
 		}
-
-		bb.JobScheduler.Start()
-		// HACK wait some time to allow the scheduler to try pick up a job
-		// before we call StopWhenQuiet
-		for i := 0; i < 10; i++ {
-			stats := bb.JobScheduler.GetStats()
-			if stats.FailedPollCount == 0 && stats.SuccessfulPollCount == 0 {
-				time.Sleep(time.Millisecond * 100)
-			}
+		params, err := utils.ParseNameValuePairs("param", runCmdConfig.params)
+		if err != nil {
+			return err
+		}
+		// Local builds are always explicitly requested, so ignore any [skip ci] marker in the commit message
+		opts := &models.BuildOptions{
+			NodesToRun:          fqns,
+			SkipDependencies:    runCmdConfig.noDeps,
+			Force:               runCmdConfig.force,
+			IgnoreSkipMarkers:   true,
+			WorkflowOutputStubs: stubs,
+			Parameters:          params,
 		}
-		bb.JobScheduler.StopWhenQuiet()
 
-		failedJobs := bb.Backend.Results()
+		if runCmdConfig.watch {
+			return watch(ctx, bb, opts)
+		}
 
-		if !config.Verbose {
-			if len(failedJobs) > 0 {
-				fmt.Fprint(os.Stdout, "\r\n")
-				fmt.Fprintf(os.Stdout, "%d job(s) failed. See logs for details.\r\n\r\n", len(failedJobs))
-				t := true
-				reader, _ := bb.LogService.ReadData(ctx, build.LogDescriptorID, &models.LogSearch{
-					Plaintext: &t,
-					Expand:    &t,
-				})
-				io.Copy(os.Stdout, reader)
-			}
+		_, failedJobs, err := runBuild(ctx, bb, opts)
+		if err != nil {
+			return err
 		}
 		if len(failedJobs) > 0 {
 			os.Exit(1)
@@ -149,3 +233,91 @@ var runRootCmd = &cobra.Command{
 		return nil
 	},
 }
+
+// runBuild runs a local build with the specified options, returning the build and any jobs that failed.
+// If any jobs failed, the log of the build is written out.
+func runBuild(ctx context.Context, bb *app.App, opts *models.BuildOptions) (*dto.BuildGraph, []*models.Job, error) {
+	build, err := bb.Backend.Enqueue(ctx, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error queuing local build: %v", err)
+	}
+
+	bb.JobScheduler.Start()
+	// HACK wait some time to allow the scheduler to try pick up a job
+	// before we call StopWhenQuiet
+	for i := 0; i < 10; i++ {
+		stats := bb.JobScheduler.GetStats()
+		if stats.FailedPollCount == 0 && stats.SuccessfulPollCount == 0 {
+			time.Sleep(time.Millisecond * 100)
+		}
+	}
+	bb.JobScheduler.StopWhenQuiet()
+
+	failedJobs := bb.Backend.Results()
+
+	if !runCmdConfig.verbose {
+		if len(failedJobs) > 0 {
+			fmt.Fprint(os.Stdout, "\r\n")
+			fmt.Fprintf(os.Stdout, "%d job(s) failed. See logs for details.\r\n\r\n", len(failedJobs))
+			t := true
+			reader, _ := bb.LogService.ReadData(ctx, build.LogDescriptorID, &models.LogSearch{
+				Plaintext: &t,
+				Expand:    &t,
+			})
+			io.Copy(os.Stdout, reader)
+		}
+	}
+	return build, failedJobs, nil
+}
+
+// selectNodesToRun returns the nodes to run, based on the workflows in args and the --job, --workflow and
+// --no-deps flags. Jobs and workflows are looked up in the build config so that only the selected jobs, and
+// the jobs they depend on, are run. If --no-deps was specified then checks that the artifacts the selected jobs
+// need from the jobs they depend on are available from a previous local build.
+func selectNodesToRun(ctx context.Context, bb *app.App, args []string) ([]models.NodeFQN, error) {
+	workflowArgs := append(append([]string{}, args...), runCmdConfig.workflows...)
+	workflows, err := utils.ParseNodeFQNS(workflowArgs)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing workflows: %v", err)
+	}
+	jobs, err := utils.ParseJobFQNs(runCmdConfig.jobs)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 && len(workflows) == 0 {
+		if runCmdConfig.noDeps {
+			return nil, fmt.Errorf("error specify the jobs or workflows to run without their dependencies using --job or --workflow")
+		}
+		return nil, nil
+	}
+
+	configPath, root, err := utils.FindBuildConfig("")
+	if err != nil {
+		return nil, err
+	}
+	report, err := utils.CheckBuildConfig(bb.QueueService, configPath, root, false)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Valid {
+		report.Write(os.Stderr, false)
+		return nil, fmt.Errorf("error found %d problem(s) in %s; use 'bb validate' for details", len(report.Errors), report.File)
+	}
+	nodes, err := utils.SelectNodesToRun(report.Graph, jobs, workflows)
+	if err != nil {
+		return nil, err
+	}
+
+	if runCmdConfig.noDeps {
+		for _, node := range nodes {
+			if node.JobName == "" {
+				return nil, fmt.Errorf("error --no-deps can't be used with workflow %q as its jobs are added by a dynamic build", node.WorkflowName)
+			}
+		}
+		err = bb.Backend.VerifyCachedArtifacts(ctx, utils.SkippedArtifactDependencies(report.Graph, nodes))
+		if err != nil {
+			return nil, fmt.Errorf("%w; run without --no-deps to run the jobs that produce it", err)
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,250 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/bb/app"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/local_backend"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// maxChangedFilesToList is the number of changed files named when reporting a change to the working copy.
+const maxChangedFilesToList = 3
+
+// watch runs a local build with the specified options, then keeps watching the working copy for changes and
+// re-runs the jobs affected by each change until interrupted. Changes made while a build is running (including
+// any made by the build itself) are ignored.
+func watch(ctx context.Context, bb *app.App, opts *models.BuildOptions) error {
+	root, err := local_backend.LocateGitRoot()
+	if err != nil {
+		return err
+	}
+	watcher, err := utils.NewFileWatcher(root, runCmdConfig.debounce)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	lastStatus := make(map[models.NodeFQN]models.WorkflowStatus)
+	build, _, err := runBuild(ctx, bb, opts)
+	if err != nil {
+		return err
+	}
+	err = recordJobStatuses(ctx, bb, build.ID, lastStatus)
+	if err != nil {
+		return err
+	}
+	watcher.Discard()
+
+	for {
+		fmt.Fprintf(os.Stdout, "\r\nWatching for changes in %s (press Ctrl+C to stop)...\r\n", root)
+		changedFiles, err := waitForChanges(ctx, watcher)
+		if err != nil {
+			fmt.Fprint(os.Stdout, "Stopped watching\r\n")
+			return nil
+		}
+		fmt.Fprintf(os.Stdout, "Changed: %s\r\n", describeChangedFiles(changedFiles))
+
+		runOpts, changes, err := planRerun(bb, root, opts, changedFiles, lastStatus)
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "%s\r\n", err)
+			continue
+		}
+		if runOpts == nil {
+			fmt.Fprint(os.Stdout, "No jobs are affected by the changes\r\n")
+			writeWatchSummary(os.Stdout, changes, nil)
+			continue
+		}
+		build, _, err := runBuild(ctx, bb, runOpts)
+		watcher.Discard()
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "%s\r\n", err)
+			continue
+		}
+		queuedBuild, err := bb.QueueService.ReadQueuedBuild(ctx, nil, build.ID)
+		if err != nil {
+			return fmt.Errorf("error reading build: %w", err)
+		}
+		writeWatchSummary(os.Stdout, changes, queuedBuild.BuildGraph)
+		for _, job := range queuedBuild.Jobs {
+			lastStatus[job.GetFQN()] = job.Status
+		}
+	}
+}
+
+// waitForChanges waits for files in the working copy to change, returning an error if interrupted first.
+// Interrupts are only caught while waiting, so that a build can still be interrupted as usual.
+func waitForChanges(ctx context.Context, watcher *utils.FileWatcher) ([]string, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	return watcher.Next(ctx)
+}
+
+// recordJobStatuses records the status of each job in a build in statuses, keyed by job FQN.
+func recordJobStatuses(ctx context.Context, bb *app.App, buildID models.BuildID, statuses map[models.NodeFQN]models.WorkflowStatus) error {
+	queuedBuild, err := bb.QueueService.ReadQueuedBuild(ctx, nil, buildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	for _, job := range queuedBuild.Jobs {
+		statuses[job.GetFQN()] = job.Status
+	}
+	return nil
+}
+
+// planRerun works out which jobs to run again after changedFiles have changed, returning the options for a build
+// to run them along with the reason each job will or won't run. Returns nil options if no jobs need to run.
+// If the last build added jobs dynamically then there's no way to tell which of those jobs are affected,
+// so the options the first build ran with are returned instead.
+func planRerun(
+	bb *app.App,
+	root string,
+	opts *models.BuildOptions,
+	changedFiles []string,
+	lastStatus map[models.NodeFQN]models.WorkflowStatus,
+) (*models.BuildOptions, []*utils.JobChange, error) {
+	configPath, _, err := utils.FindBuildConfig("")
+	if err != nil {
+		return nil, nil, err
+	}
+	report, err := utils.CheckBuildConfig(bb.QueueService, configPath, root, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !report.Valid {
+		report.Write(os.Stdout, false)
+		return nil, nil, fmt.Errorf("error found %d problem(s) in %s; waiting for it to be fixed", len(report.Errors), report.File)
+	}
+	graph := report.Graph
+
+	for fqn := range lastStatus {
+		found := false
+		for _, job := range graph.Jobs {
+			if job.GetFQN() == fqn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprint(os.Stdout, "The build adds jobs dynamically, so all of it will run again\r\n")
+			return opts, nil, nil
+		}
+	}
+
+	// Only consider the jobs that were selected to run in the first place
+	if len(opts.NodesToRun) > 0 {
+		err = graph.Trim(opts.NodesToRun)
+		if err != nil {
+			return nil, nil, err
+		}
+		if opts.SkipDependencies {
+			graph.TrimDependencies(opts.NodesToRun)
+		}
+	}
+
+	configFile := configPath
+	if absPath, err := filepath.Abs(configPath); err == nil {
+		if rel, err := filepath.Rel(root, absPath); err == nil {
+			configFile = filepath.ToSlash(rel)
+		}
+	}
+	changes, err := utils.AffectedJobs(graph, configFile, changedFiles, lastStatus)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nodes []models.NodeFQN
+	for _, change := range changes {
+		if change.Affected {
+			nodes = append(nodes, change.FQN)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, changes, nil
+	}
+	// Jobs that aren't affected already produced their artifacts in the working copy, so don't run them again
+	runOpts := *opts
+	runOpts.NodesToRun = nodes
+	runOpts.SkipDependencies = true
+	return &runOpts, changes, nil
+}
+
+// describeChangedFiles returns a short description of the files that changed.
+func describeChangedFiles(changedFiles []string) string {
+	if len(changedFiles) <= maxChangedFilesToList {
+		return strings.Join(changedFiles, ", ")
+	}
+	return fmt.Sprintf("%s and %d more file(s)",
+		strings.Join(changedFiles[:maxChangedFilesToList], ", "), len(changedFiles)-maxChangedFilesToList)
+}
+
+// writeWatchSummary writes a line for each job saying what happened to it after a change to the working copy
+// and why. changes records why each job was or wasn't run again, and build is the build that ran the affected
+// jobs, or nil if no jobs were run.
+func writeWatchSummary(w io.Writer, changes []*utils.JobChange, build *dto.BuildGraph) {
+	type summaryLine struct {
+		name   string
+		result string
+		reason string
+	}
+	var (
+		lines     []*summaryLine
+		nameWidth int
+	)
+	jobsByFQN := make(map[models.NodeFQN]*dto.JobGraph)
+	if build != nil {
+		for _, job := range build.Jobs {
+			jobsByFQN[job.GetFQN()] = job
+		}
+	}
+	addLine := func(fqn models.NodeFQN, result string, reason string) {
+		line := &summaryLine{name: fqn.String(), result: result, reason: reason}
+		if len(line.name) > nameWidth {
+			nameWidth = len(line.name)
+		}
+		lines = append(lines, line)
+	}
+	// Jobs whose fingerprint matches a previous run are checked but don't actually run again
+	jobResult := func(job *dto.JobGraph) (string, string) {
+		if job.IndirectToJobID.Valid() {
+			return "skipped, fingerprint unchanged", "checked because"
+		}
+		return job.Status.String(), "ran because"
+	}
+	if changes == nil && build != nil {
+		for _, job := range build.Jobs {
+			result, _ := jobResult(job)
+			addLine(job.GetFQN(), result, "")
+		}
+	}
+	for _, change := range changes {
+		job, ran := jobsByFQN[change.FQN]
+		switch {
+		case ran:
+			result, because := jobResult(job)
+			addLine(change.FQN, result, fmt.Sprintf("%s %s", because, change.Reason))
+		case change.Affected:
+			addLine(change.FQN, "not run", change.Reason)
+		default:
+			addLine(change.FQN, "not re-run", change.Reason)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprint(w, "\r\nSummary:\r\n")
+	for _, line := range lines {
+		if line.reason == "" {
+			fmt.Fprintf(w, "  %-*s  %s\r\n", nameWidth, line.name, line.result)
+		} else {
+			fmt.Fprintf(w, "  %-*s  %s (%s)\r\n", nameWidth, line.name, line.result, line.reason)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package secrets
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/local_backend"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func init() {
+	secretsRootCmd.PersistentFlags().StringVar(
+		&secretsCmdConfig.secretsFile,
+		"secrets-file",
+		"~/.bb/secrets.json",
+		"The encrypted file to store secrets for local builds in")
+	commands.RootCmd.AddCommand(secretsRootCmd)
+	secretsRootCmd.AddCommand(secretsSetCmd)
+	secretsRootCmd.AddCommand(secretsListCmd)
+	secretsRootCmd.AddCommand(secretsDeleteCmd)
+}
+
+var secretsCmdConfig = struct {
+	secretsFile string
+}{}
+
+var secretsRootCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets available to local builds",
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:           "set <name>",
+	Short:         "Set the value of a secret for local builds, reading the value from stdin",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := models.ResourceName(args[0]).Validate()
+		if err != nil {
+			return fmt.Errorf("error invalid secret name: %w", err)
+		}
+		store, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		value, err := readSecretValue(args[0], os.Stdin)
+		if err != nil {
+			return err
+		}
+		err = store.Set(context.Background(), args[0], value)
+		if err != nil {
+			return err
+		}
+		if !commands.Global.JSON {
+			fmt.Fprintf(os.Stdout, "Secret %s set\r\n", args[0])
+		}
+		return nil
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List the names of the secrets set for local builds",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		names, err := store.List()
+		if err != nil {
+			return err
+		}
+		if commands.Global.JSON {
+			return json.NewEncoder(os.Stdout).Encode(names)
+		}
+		for _, name := range names {
+			fmt.Fprintf(os.Stdout, "%s\r\n", name)
+		}
+		return nil
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:           "delete <name>",
+	Short:         "Delete a secret set for local builds",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		found, err := store.Delete(args[0])
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("error secret %s is not set", args[0])
+		}
+		if !commands.Global.JSON {
+			fmt.Fprintf(os.Stdout, "Secret %s deleted\r\n", args[0])
+		}
+		return nil
+	},
+}
+
+func openSecretStore() (*local_backend.SecretStore, error) {
+	path, err := utils.HomeifyPath(secretsCmdConfig.secretsFile)
+	if err != nil {
+		return nil, err
+	}
+	return local_backend.NewSecretStore(local_backend.SecretsFilePath(path)), nil
+}
+
+// readSecretValue reads the value of the named secret from the first line of r, prompting for it if r is a
+// terminal. Piping the value in keeps it out of the shell history.
+func readSecretValue(name string, r *os.File) (string, error) {
+	if info, err := r.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stderr, "Value for secret %s: ", name)
+	}
+	value, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading secret value: %w", err)
+	}
+	value = strings.TrimRight(value, "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("error no value provided for secret %s", name)
+	}
+	return value, nil
+}
@@ -0,0 +1,128 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+func init() {
+	triggerCmd.Flags().StringVar(
+		&triggerCmdConfig.server,
+		"server",
+		"",
+		"The URL of the BuildBeaver server to trigger the build on")
+	triggerCmd.Flags().StringVar(
+		&triggerCmdConfig.token,
+		"token",
+		os.Getenv("BB_TOKEN"),
+		"A personal access token with permission to create builds in the repo (defaults to $BB_TOKEN)")
+	triggerCmd.Flags().StringVar(
+		&triggerCmdConfig.ref,
+		"ref",
+		"",
+		"The git ref or branch name to build (defaults to the repo's default branch)")
+	triggerCmd.Flags().StringVar(
+		&triggerCmdConfig.sha,
+		"sha",
+		"",
+		"The SHA of the commit to build (defaults to the commit at the head of --ref)")
+	triggerCmd.Flags().BoolVar(
+		&triggerCmdConfig.force,
+		"force",
+		false,
+		"Force all jobs to run by ignoring fingerprints")
+	triggerCmd.Flags().StringArrayVar(
+		&triggerCmdConfig.params,
+		"param",
+		nil,
+		"Supply the value of a parameter declared in the build config in the form NAME=VALUE")
+	triggerCmd.Flags().StringArrayVar(
+		&triggerCmdConfig.jobs,
+		"job",
+		nil,
+		"Run only the specified job and the jobs it depends on, in the form workflow.job (or job for the default workflow)")
+	triggerCmd.Flags().StringArrayVar(
+		&triggerCmdConfig.workflows,
+		"workflow",
+		nil,
+		"Run only the specified workflow and the jobs it depends on")
+	commands.RootCmd.AddCommand(triggerCmd)
+}
+
+var triggerCmdConfig = struct {
+	server    string
+	token     string
+	ref       string
+	sha       string
+	force     bool
+	params    []string
+	jobs      []string
+	workflows []string
+}{}
+
+var triggerCmd = &cobra.Command{
+	Use:           "trigger <repo>",
+	Short:         "Trigger a build of a ref or commit in a repo on a BuildBeaver server",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if triggerCmdConfig.server == "" {
+			return fmt.Errorf("error the server to trigger the build on must be specified using --server")
+		}
+		id, err := models.ParseResourceID(args[0])
+		if err != nil || id.Kind() != models.RepoResourceKind {
+			return fmt.Errorf("error %q is not a repo ID", args[0])
+		}
+		params, err := utils.ParseNameValuePairs("param", triggerCmdConfig.params)
+		if err != nil {
+			return err
+		}
+		workflows, err := utils.ParseNodeFQNS(triggerCmdConfig.workflows)
+		if err != nil {
+			return fmt.Errorf("error parsing workflows: %v", err)
+		}
+		jobs, err := utils.ParseJobFQNs(triggerCmdConfig.jobs)
+		if err != nil {
+			return err
+		}
+
+		apiClient, err := utils.NewServerAPIClient(triggerCmdConfig.server, triggerCmdConfig.token)
+		if err != nil {
+			return err
+		}
+		build, err := apiClient.CreateBuild(ctx, models.RepoIDFromResourceID(id), &documents.CreateBuildRequest{
+			Ref: triggerCmdConfig.ref,
+			SHA: triggerCmdConfig.sha,
+			Opts: &models.BuildOptions{
+				Force:      triggerCmdConfig.force,
+				NodesToRun: append(jobs, workflows...),
+				Parameters: params,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error triggering build: %w", err)
+		}
+
+		if commands.Global.JSON {
+			return json.NewEncoder(os.Stdout).Encode(build.Build)
+		}
+		fmt.Fprintf(os.Stdout, "Triggered build %s (%s) of %s at commit %s, status %s\n",
+			build.Build.Name, build.Build.ID, build.Build.Ref, build.Commit.SHA, build.Build.Status)
+		if build.Build.Error != nil {
+			fmt.Fprintf(os.Stdout, "Build failed: %s\n", build.Build.Error)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,85 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/bb/app"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/utils"
+)
+
+func init() {
+	validateRootCmd.PersistentFlags().StringVar(
+		&validateCmdConfig.workDir,
+		"workdir",
+		"~/.bb/local",
+		"The scratch space used for local builds")
+	validateRootCmd.PersistentFlags().BoolVar(
+		&validateCmdConfig.dynamic,
+		"dynamic",
+		false,
+		"Check the file as jobs submitted by a dynamic build, allowing dependencies on jobs that aren't in the file")
+	commands.RootCmd.AddCommand(validateRootCmd)
+}
+
+var validateCmdConfig = struct {
+	workDir string
+	dynamic bool
+}{}
+
+var validateRootCmd = &cobra.Command{
+	Use:           "validate [file]",
+	Short:         "Check the build config in the root of the repo, or the specified file, for problems without running a build",
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		var configPath string
+		if len(args) > 0 {
+			configPath = args[0]
+		} else if validateCmdConfig.dynamic {
+			return fmt.Errorf("error specify the file containing the jobs to check with --dynamic")
+		}
+		configPath, root, err := utils.FindBuildConfig(configPath)
+		if err != nil {
+			return err
+		}
+
+		// The build config is only read, so don't take the BB lock file
+		workDir, err := utils.HomeifyPath(validateCmdConfig.workDir)
+		if err != nil {
+			return err
+		}
+		// This can be run before any local builds, so make sure the scratch space exists
+		err = os.MkdirAll(workDir, 0770)
+		if err != nil {
+			return fmt.Errorf("error making work directory %q: %w", workDir, err)
+		}
+		config := app.NewBBConfig(workDir, false, commands.Global.JSON)
+		bb, cleanup, err := app.New(ctx, config)
+		if err != nil {
+			return errors.Wrap(err, "error initializing app")
+		}
+		defer cleanup()
+
+		report, err := utils.CheckBuildConfig(bb.QueueService, configPath, root, validateCmdConfig.dynamic)
+		if err != nil {
+			return err
+		}
+		err = report.Write(os.Stdout, commands.Global.JSON)
+		if err != nil {
+			return err
+		}
+		if !report.Valid {
+			return fmt.Errorf("error found %d problem(s) in %s", len(report.Errors), report.File)
+		}
+		return nil
+	},
+}
@@ -12,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -38,9 +39,25 @@ type VerboseOutput bool
 
 type JSONOutput bool
 
+// StatusPagesURL is the base URL of the status pages served by the local API server, or empty if
+// the status pages are not available.
+type StatusPagesURL string
+
+// EnvOverrides maps environment variable names to values that replace (or are added to) the environment of
+// every job in a local build.
+type EnvOverrides map[string]string
+
+// SecretOverrides maps secret names to the values used for those secrets in a local build, in preference to
+// environment variables with the same name.
+type SecretOverrides map[string]string
+
 type LocalBackendConfig struct {
-	JSON    JSONOutput
-	Verbose VerboseOutput
+	JSON            JSONOutput
+	Verbose         VerboseOutput
+	StatusPagesURL  StatusPagesURL
+	EnvOverrides    EnvOverrides
+	SecretOverrides SecretOverrides
+	SecretsFile     SecretsFilePath
 }
 
 // LocalBackendRequestContext provides a BaseURL() function returning a fake URL that can be used for document
@@ -74,7 +91,7 @@ type LocalBackend struct {
 	// State
 	buildID      models.BuildID
 	build        *dto.BuildGraph
-	buildMu      sync.RWMutex // protects build only
+	buildMu      sync.RWMutex // protects build and legalEntity only
 	failedJobs   []*models.Job
 	failedJobsMu sync.Mutex // protects failedJobs only
 	legalEntity  *models.LegalEntity
@@ -127,6 +144,7 @@ func (s *LocalBackend) Results() []*models.Job {
 	defer s.failedJobsMu.Unlock()
 	if s.spinners != nil {
 		s.spinners.Stop()
+		s.spinners = nil
 	}
 	return s.failedJobs
 }
@@ -134,7 +152,11 @@ func (s *LocalBackend) Results() []*models.Job {
 // Enqueue queues all jobs/steps found in the build configuration file in the current working directory.
 func (s *LocalBackend) Enqueue(ctx context.Context, opts *models.BuildOptions) (*dto.BuildGraph, error) {
 	now := models.NewTime(time.Now())
-	root, err := s.locateGitRoot()
+	// Builds can be queued one after another (e.g. by bb run --watch), so forget the results of any previous build
+	s.failedJobsMu.Lock()
+	s.failedJobs = nil
+	s.failedJobsMu.Unlock()
+	root, err := LocateGitRoot()
 	if err != nil {
 		return nil, err
 	}
@@ -208,52 +230,9 @@ func (s *LocalBackend) Enqueue(ctx context.Context, opts *models.BuildOptions) (
 		return nil, errors.Wrap(err, "error upserting repo")
 	}
 
-	files, err := ioutil.ReadDir(".")
+	configFilePath, configType, err := parser.FindBuildConfigFile(".")
 	if err != nil {
-		return nil, errors.Wrap(err, "error listing files")
-	}
-
-	var (
-		configType     models.ConfigType
-		configFilePath string
-	)
-
-loop:
-	for _, file := range files {
-
-		if file.IsDir() {
-			continue
-		}
-
-		path := file.Name()
-
-		for _, p := range parser.YAMLBuildConfigFileNames {
-			if path == p {
-				configType = models.ConfigTypeYAML
-				configFilePath = path
-				break loop
-			}
-		}
-
-		for _, p := range parser.JSONBuildConfigFileNames {
-			if path == p {
-				configType = models.ConfigTypeJSON
-				configFilePath = path
-				break loop
-			}
-		}
-
-		for _, p := range parser.JSONNETBuildConfigFileNames {
-			if path == p {
-				configType = models.ConfigTypeJSONNET
-				configFilePath = path
-				break loop
-			}
-		}
-	}
-
-	if configFilePath == "" {
-		return nil, errors.New("Unable to locate buildbeaver config file in root of repo")
+		return nil, err
 	}
 
 	config, err := ioutil.ReadFile(configFilePath)
@@ -311,12 +290,16 @@ loop:
 
 	s.buildMu.Lock()
 	s.build = build
+	s.legalEntity = legalEntity
 	s.buildMu.Unlock()
 
 	s.buildID = build.ID
-	s.legalEntity = legalEntity
 	s.runner = runner
 
+	if !s.config.JSON && s.config.StatusPagesURL != "" {
+		fmt.Fprintf(os.Stdout, "View build status at %s/builds/%s\r\n", s.config.StatusPagesURL, build.ID)
+	}
+
 	if !s.config.Verbose {
 		// Set up spinners for the initial jobs
 		s.spinners = NewBBSpinnerManager()
@@ -330,6 +313,50 @@ loop:
 	return build, nil
 }
 
+// GetIdentityID returns the ID of the identity that local builds are run as.
+// Returns a not found error if no build has been queued yet.
+func (s *LocalBackend) GetIdentityID(ctx context.Context) (models.IdentityID, error) {
+	s.buildMu.RLock()
+	legalEntity := s.legalEntity
+	s.buildMu.RUnlock()
+	if legalEntity == nil {
+		return models.IdentityID{}, gerror.NewErrNotFound("No local build has been queued")
+	}
+	identity, err := s.legalEntityService.ReadIdentity(ctx, nil, legalEntity.ID)
+	if err != nil {
+		return models.IdentityID{}, fmt.Errorf("error reading identity for local legal entity: %w", err)
+	}
+	return identity.ID, nil
+}
+
+// ListFinishedJobs returns all jobs from previous local builds that have finished, across all builds
+// recorded in the local database.
+func (s *LocalBackend) ListFinishedJobs(ctx context.Context) ([]*models.Job, error) {
+	var jobs []*models.Job
+	statuses := []models.WorkflowStatus{
+		models.WorkflowStatusSucceeded,
+		models.WorkflowStatusFailed,
+		models.WorkflowStatusCanceled,
+		models.WorkflowStatusSkipped,
+	}
+	for _, status := range statuses {
+		pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+		for moreResults := true; moreResults; {
+			results, cursor, err := s.jobStore.ListByStatus(ctx, nil, status, pagination)
+			if err != nil {
+				return nil, fmt.Errorf("error listing %s jobs: %w", status, err)
+			}
+			jobs = append(jobs, results...)
+			if cursor != nil && cursor.Next != nil {
+				pagination.Cursor = cursor.Next // move on to next page of results
+			} else {
+				moreResults = false
+			}
+		}
+	}
+	return jobs, nil
+}
+
 func (s *LocalBackend) NewJobsCreated(ctx context.Context, newJobs []*documents.JobGraph) {
 	// Re-read and store the entire build
 	queuedBuild, err := s.queueService.ReadQueuedBuild(ctx, nil, s.buildID)
@@ -350,13 +377,13 @@ func (s *LocalBackend) NewJobsCreated(ctx context.Context, newJobs []*documents.
 }
 
 // Dequeue returns the next build job that is ready to be executed, or nil if there are currently no queued builds.
-func (s *LocalBackend) Dequeue(ctx context.Context) (*documents.RunnableJob, error) {
+func (s *LocalBackend) Dequeue(ctx context.Context, wait time.Duration) (*documents.RunnableJob, error) {
 	var (
 		dequeued *dto.RunnableJob
 		err      error
 	)
 	for {
-		dequeued, err = s.queueService.Dequeue(ctx, s.runner.ID)
+		dequeued, err = s.queueService.DequeueWithWait(ctx, s.runner.ID, wait)
 		if err != nil {
 			return nil, err
 		}
@@ -368,9 +395,37 @@ func (s *LocalBackend) Dequeue(ctx context.Context) (*documents.RunnableJob, err
 	if !s.config.Verbose && s.spinners != nil {
 		s.spinners.UpdateSpinnerStatus(dequeued.ID, dequeued.Status)
 	}
+	dequeued.Job.Environment = s.applyEnvOverrides(dequeued.Job.Environment)
 	return documents.MakeRunnableJob(NewLocalBackendRequestContext(), dequeued), nil
 }
 
+// applyEnvOverrides returns env with the configured environment variable overrides applied. Overrides replace
+// any variable with the same name, including variables set from secrets. The runner replaces its copy of a job
+// each time the job is updated, so this must be applied to every job returned to the runner.
+func (s *LocalBackend) applyEnvOverrides(env models.JobEnvVars) models.JobEnvVars {
+	if len(s.config.EnvOverrides) == 0 {
+		return env
+	}
+	names := make([]string, 0, len(s.config.EnvOverrides))
+	for name := range s.config.EnvOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make(models.JobEnvVars, 0, len(env)+len(names))
+	for _, envVar := range env {
+		if _, ok := s.config.EnvOverrides[envVar.Name]; !ok {
+			result = append(result, envVar)
+		}
+	}
+	for _, name := range names {
+		result = append(result, &models.EnvVar{
+			Name:         name,
+			SecretString: models.SecretString{Value: s.config.EnvOverrides[name]},
+		})
+	}
+	return result
+}
+
 // Ping acts as a pre-flight check for a runner, contacting the server and checking that authentication
 // and registration are in place ready to dequeue build jobs.
 func (s *LocalBackend) Ping(ctx context.Context) error {
@@ -396,6 +451,9 @@ func (s *LocalBackend) SendRuntimeInfo(ctx context.Context, info *documents.Patc
 	if info.SupportedJobTypes != nil {
 		runner.SupportedJobTypes = *info.SupportedJobTypes
 	}
+	if info.Capacity != nil {
+		runner.SetCapacity(*info.Capacity)
+	}
 	runner.ETag = models.ETagAny
 	runner, err = s.runnerService.Update(ctx, nil, runner)
 	if err != nil {
@@ -404,6 +462,13 @@ func (s *LocalBackend) SendRuntimeInfo(ctx context.Context, info *documents.Patc
 	return nil
 }
 
+// SendHealthReport sends a report on the health of this runner's host to the server.
+func (s *LocalBackend) SendHealthReport(ctx context.Context, report *models.RunnerHealthReport) error {
+	// bb runs builds on the user's own machine, so there is no other runner to send jobs to if this one is
+	// unhealthy; ignore health reports rather than blocking the build
+	return nil
+}
+
 // UpdateJobStatus updates the status of the specified job.
 // If the status is finished, err can be supplied to signal the job failed with an error
 // or nil to signify the job succeeded.
@@ -433,6 +498,7 @@ func (s *LocalBackend) UpdateJobStatus(
 		s.spinners.UpdateSpinnerStatus(job.ID, job.Status)
 	}
 
+	job.Environment = s.applyEnvOverrides(job.Environment)
 	return documents.MakeJob(NewLocalBackendRequestContext(), job), nil
 }
 
@@ -506,6 +572,7 @@ func (s *LocalBackend) UpdateJobFingerprint(
 			}
 		}
 	}
+	job.Environment = s.applyEnvOverrides(job.Environment)
 	return documents.MakeJob(NewLocalBackendRequestContext(), job), nil
 }
 
@@ -515,11 +582,16 @@ func (s *LocalBackend) verifyArtifact(artifact *models.Artifact) error {
 	if err != nil {
 		return errors.Wrap(err, "error determining current working directory")
 	}
-	absolutePath := filepath.Join(cwd, artifact.Path)
+	return verifyArtifactFile(filepath.Join(cwd, artifact.Path), artifact)
+}
+
+// verifyArtifactFile verifies that the file at absolutePath has the contents recorded for artifact.
+func verifyArtifactFile(absolutePath string, artifact *models.Artifact) error {
 	file, err := os.Open(absolutePath)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 	stat, err := file.Stat()
 	if err != nil {
 		return err
@@ -550,59 +622,101 @@ func (s *LocalBackend) verifyArtifact(artifact *models.Artifact) error {
 	return nil
 }
 
-// GetSecretsPlaintext gets all secrets for the specified repo in plaintext.
-func (s *LocalBackend) GetSecretsPlaintext(ctx context.Context, repoID models.RepoID) ([]*models.SecretPlaintext, error) {
-	// We don't have any secret storage when running local builds so instead source them from environment variables.
-	// We need to know the resource_links of the secrets that steps are interested in first though.
-	// We need to know the resource_links of the secrets that steps are interested in first though.
+// GetSecretsPlaintext gets all secrets for the specified repo in plaintext. Secrets for local builds have no
+// versions so pinToBuildID is ignored.
+func (s *LocalBackend) GetSecretsPlaintext(ctx context.Context, repoID models.RepoID, pinToBuildID *models.BuildID) ([]*models.SecretPlaintext, error) {
+	// We don't have any server-side secret storage when running local builds so instead source each secret from
+	// (in order of preference) the command line, a $BB_SECRET_<name> environment variable, the local secrets file,
+	// or failing that an environment variable with the same name as the secret.
+	// We need to know the names of the secrets that jobs are interested in first though.
 	secretNames := make(map[string]bool)
-
 	s.buildMu.RLock()
 	for _, job := range s.build.Jobs {
-		for _, env := range job.Environment {
-			if env.ValueFromSecret != "" {
-				secretNames[strings.ToUpper(env.ValueFromSecret)] = true
-			}
+		for _, name := range job.ReferencedSecrets() {
+			secretNames[name] = true
 		}
 	}
 	s.buildMu.RUnlock()
+	if len(secretNames) == 0 {
+		return nil, nil
+	}
 
+	// Secret names are matched case-insensitively, so key each source of secrets by upper-case name
+	type secretSource struct {
+		description string
+		values      map[string]string
+	}
+	makeSource := func(description string, values map[string]string) *secretSource {
+		source := &secretSource{description: description, values: make(map[string]string, len(values))}
+		for name, value := range values {
+			source.values[strings.ToUpper(name)] = value
+		}
+		return source
+	}
 	var (
-		now     = time.Now().UTC()
-		secrets []*models.SecretPlaintext
+		prefixedEnv = make(map[string]string)
+		env         = make(map[string]string)
 	)
 	for _, pair := range os.Environ() {
-		split := strings.SplitN(pair, "=", 2)
-		if len(split) != 2 {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
 			s.log.Warnf("Ignoring malformed env var when generating secrets: %s", pair)
 			continue
 		}
-		key := split[0]
-		value := split[1]
-
-		// Does this environment variable match a secret?
-		_, ok := secretNames[strings.ToUpper(key)]
-		if !ok {
-			continue
+		if strings.HasPrefix(key, SecretEnvVarPrefix) && len(key) > len(SecretEnvVarPrefix) {
+			prefixedEnv[strings.TrimPrefix(key, SecretEnvVarPrefix)] = value
 		}
-		secret := &models.SecretPlaintext{
-			Secret: &models.Secret{
-				ID:               models.NewSecretID(),
-				Name:             models.ResourceName(key),
-				RepoID:           repoID,
-				CreatedAt:        models.NewTime(now),
-				UpdatedAt:        models.NewTime(now),
-				ETag:             "",
-				KeyEncrypted:     nil,
-				ValueEncrypted:   nil,
-				DataKeyEncrypted: nil,
-				IsInternal:       false,
-			},
-			Key:   key,
-			Value: value,
+		env[key] = value
+	}
+	var fileSecrets map[string]string
+	if s.config.SecretsFile != "" {
+		var err error
+		fileSecrets, err = NewSecretStore(s.config.SecretsFile).GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sources := []*secretSource{
+		makeSource("command line", s.config.SecretOverrides),
+		makeSource(fmt.Sprintf("%s env var", SecretEnvVarPrefix), prefixedEnv),
+		makeSource("secrets file", fileSecrets),
+		makeSource("env var", env),
+	}
+
+	names := make([]string, 0, len(secretNames))
+	for name := range secretNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var (
+		now     = time.Now().UTC()
+		secrets []*models.SecretPlaintext
+	)
+	for _, name := range names {
+		for _, source := range sources {
+			value, ok := source.values[strings.ToUpper(name)]
+			if !ok {
+				continue
+			}
+			secrets = append(secrets, &models.SecretPlaintext{
+				Secret: &models.Secret{
+					ID:               models.NewSecretID(),
+					Name:             models.ResourceName(name),
+					RepoID:           repoID,
+					CreatedAt:        models.NewTime(now),
+					UpdatedAt:        models.NewTime(now),
+					ETag:             "",
+					KeyEncrypted:     nil,
+					ValueEncrypted:   nil,
+					DataKeyEncrypted: nil,
+					IsInternal:       false,
+				},
+				Key:   name,
+				Value: value,
+			})
+			s.log.Infof("Generated secret %s from %s", name, source.description)
+			break
 		}
-		secrets = append(secrets, secret)
-		s.log.Infof("Generated secret from env var %s", key)
 	}
 
 	return secrets, nil
@@ -642,16 +756,34 @@ func (s *LocalBackend) GetArtifactData(ctx context.Context, artifactID models.Ar
 	return nil, errors.New("error not implemented")
 }
 
+// FindCache finds the cache entry to restore for the named cache.
+func (s *LocalBackend) FindCache(ctx context.Context, jobID models.JobID, name models.ResourceName, key string) (*documents.CacheEntry, error) {
+	// Local builds run directly in the working copy so there is no
+	// build cache to restore; the runner skips caches when running locally.
+	return nil, errors.New("error not implemented")
+}
+
+// GetCacheEntryData returns a reader to the tarball for a cache entry.
+// It is the caller's responsibility to close the reader.
+func (s *LocalBackend) GetCacheEntryData(ctx context.Context, jobID models.JobID, cacheEntryID models.CacheEntryID) (io.ReadCloser, error) {
+	return nil, errors.New("error not implemented")
+}
+
+// SaveCache saves a new entry for the named cache.
+func (s *LocalBackend) SaveCache(ctx context.Context, jobID models.JobID, name models.ResourceName, key string, reader io.ReadSeeker) (*documents.CacheEntry, error) {
+	return nil, errors.New("error not implemented")
+}
+
 // GetArtifactLocalData returns a reader to the data of an artifact, reading the file from the local filesystem.
 // It is the caller's responsibility to close the reader.
 func (s *LocalBackend) GetArtifactLocalData(artifact *models.Artifact) (io.ReadCloser, error) {
 	// artifact file should already exist at the filesystem path specified in the artifact resource,
-	// relative to the current working directory
-	currentWorkingDir, err := os.Getwd()
+	// relative to the root of the repo the build ran in
+	root, err := LocateGitRoot()
 	if err != nil {
-		return nil, errors.Wrap(err, "error determining current working directory")
+		return nil, err
 	}
-	absolutePath := path.Join(currentWorkingDir, artifact.Path)
+	absolutePath := path.Join(root, artifact.Path)
 
 	file, err := os.Open(absolutePath)
 	if err != nil {
@@ -660,6 +792,59 @@ func (s *LocalBackend) GetArtifactLocalData(artifact *models.Artifact) (io.ReadC
 	return file, nil
 }
 
+// VerifyCachedArtifacts checks that the artifacts a job depends on are still in the working copy, as produced
+// by the most recent local build that ran the job producing them. This allows a job to be run without first
+// running the jobs it depends on. Returns an error naming the first dependency that can't be satisfied.
+func (s *LocalBackend) VerifyCachedArtifacts(ctx context.Context, dependencies []*models.ArtifactDependency) error {
+	root, err := LocateGitRoot()
+	if err != nil {
+		return err
+	}
+	for _, dependency := range dependencies {
+		name := models.NewNodeFQNForJob(dependency.Workflow, dependency.JobName)
+		search := models.NewArtifactSearch()
+		search.Workflow = &dependency.Workflow
+		search.JobName = &dependency.JobName
+		if dependency.GroupName != "" {
+			search.GroupName = &dependency.GroupName
+		}
+		// Artifacts are recorded against each build, so only use those from the most recent job that produced any
+		var (
+			latest    []*models.Artifact
+			paginator = models.NewArtifactPager(search.Pagination, func(ctx context.Context, pagination models.Pagination) ([]*models.Artifact, *models.Cursor, error) {
+				search.Pagination = pagination
+				return s.artifactService.Search(ctx, nil, models.NoIdentity, *search)
+			})
+		)
+		for paginator.HasNext() {
+			artifacts, err := paginator.Next(ctx)
+			if err != nil {
+				return fmt.Errorf("error searching artifacts: %w", err)
+			}
+			for _, artifact := range artifacts {
+				if len(latest) > 0 && latest[0].JobID != artifact.JobID {
+					if !artifact.CreatedAt.After(latest[0].CreatedAt.Time) {
+						continue
+					}
+					latest = nil
+				}
+				latest = append(latest, artifact)
+			}
+		}
+		if len(latest) == 0 {
+			return fmt.Errorf("error no artifacts from a previous local build of %s found", name.String())
+		}
+		for _, artifact := range latest {
+			err := verifyArtifactFile(filepath.Join(root, artifact.Path), artifact)
+			if err != nil {
+				return fmt.Errorf("error artifact %q from a previous local build of %s is missing or has changed (%v)",
+					artifact.Path, name.String(), err)
+			}
+		}
+	}
+	return nil
+}
+
 // SearchArtifacts searches all artifacts for a build. Use cursor to page through failedJobs, if any.
 func (s *LocalBackend) SearchArtifacts(ctx context.Context, buildID models.BuildID, search *models.ArtifactSearch) (models.ArtifactSearchPaginator, error) {
 	return models.NewArtifactPager(search.Pagination, func(ctx context.Context, pagination models.Pagination) ([]*models.Artifact, *models.Cursor, error) {
@@ -787,10 +972,10 @@ func (s *LocalBackend) makeLogLinePrefix(workflowName models.ResourceName, jobNa
 	return fmt.Sprintf("%s%s%s: ", workflowPrefix, jobName, stepNameSuffix)
 }
 
-// locateGitRoot walks up the directory tree starting at the current working directory looking for a .git
+// LocateGitRoot walks up the directory tree starting at the current working directory looking for a .git
 // directory representing a git repo. Returns the path to the first directory found that contains a .git subdir,
 // or an error if we reached the root without finding one.
-func (s *LocalBackend) locateGitRoot() (string, error) {
+func LocateGitRoot() (string, error) {
 	path, err := filepath.Abs(".")
 	if err != nil {
 		return "", err
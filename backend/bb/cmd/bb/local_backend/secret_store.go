@@ -0,0 +1,226 @@
+package local_backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services/encryption"
+)
+
+// SecretEnvVarPrefix is the prefix of environment variables that provide the values of secrets for local builds,
+// e.g. $BB_SECRET_DOCKER_PASSWORD provides the value of the DOCKER_PASSWORD secret.
+const SecretEnvVarPrefix = "BB_SECRET_"
+
+// SecretsFilePath is the path of the encrypted file that secrets for local builds are stored in. The key used to
+// encrypt the file is stored alongside it, in a file with the same name plus a ".key" extension.
+type SecretsFilePath string
+
+func (p SecretsFilePath) String() string {
+	return string(p)
+}
+
+// secretsFile is the format of a secrets file.
+type secretsFile struct {
+	Secrets map[string]*encryptedSecret `json:"secrets"`
+}
+
+type encryptedSecret struct {
+	ValueEncrypted   []byte `json:"value_encrypted"`
+	DataKeyEncrypted []byte `json:"data_key_encrypted"`
+}
+
+// SecretStore stores secrets for local builds in an encrypted file, in the same way the server stores secrets
+// in its database. The file is only readable by the current user, and the key it is encrypted with is kept in
+// a separate file so that the secrets file itself can't be accidentally disclosed (e.g. by committing it).
+type SecretStore struct {
+	path    string
+	keyPath string
+}
+
+// NewSecretStore creates a store for secrets in the encrypted file at path. The file is created the first time
+// a secret is set.
+func NewSecretStore(path SecretsFilePath) *SecretStore {
+	return &SecretStore{
+		path:    path.String(),
+		keyPath: path.String() + ".key",
+	}
+}
+
+// List returns the names of all secrets in the store, in sorted order.
+func (s *SecretStore) List() ([]string, error) {
+	file, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(file.Secrets))
+	for name := range file.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetAll returns the plaintext values of all secrets in the store, keyed by secret name.
+func (s *SecretStore) GetAll(ctx context.Context) (map[string]string, error) {
+	file, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Secrets) == 0 {
+		return nil, nil
+	}
+	encryptionService, err := s.makeEncryptionService(false)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(file.Secrets))
+	for name, secret := range file.Secrets {
+		value, err := encryptionService.Decrypt(ctx, secret.ValueEncrypted, secret.DataKeyEncrypted)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting secret %q in %s: %w", name, s.path, err)
+		}
+		values[name] = string(value)
+	}
+	return values, nil
+}
+
+// Set encrypts value and stores it as the value of the named secret, replacing any existing value.
+func (s *SecretStore) Set(ctx context.Context, name string, value string) error {
+	err := models.ResourceName(name).Validate()
+	if err != nil {
+		return fmt.Errorf("error invalid secret name: %w", err)
+	}
+	file, err := s.read()
+	if err != nil {
+		return err
+	}
+	// Only make a new key if there are no secrets encrypted with an existing key
+	encryptionService, err := s.makeEncryptionService(len(file.Secrets) == 0)
+	if err != nil {
+		return err
+	}
+	valueEncrypted, dataKeyEncrypted, err := encryptionService.Encrypt(ctx, []byte(value))
+	if err != nil {
+		return fmt.Errorf("error encrypting secret: %w", err)
+	}
+	// Secret names are matched case-insensitively, so replace any existing secret that differs only in case
+	s.remove(file, name)
+	file.Secrets[name] = &encryptedSecret{
+		ValueEncrypted:   valueEncrypted,
+		DataKeyEncrypted: dataKeyEncrypted,
+	}
+	return s.write(file)
+}
+
+// Delete removes the named secret from the store. Returns false if there was no such secret.
+func (s *SecretStore) Delete(name string) (bool, error) {
+	file, err := s.read()
+	if err != nil {
+		return false, err
+	}
+	if !s.remove(file, name) {
+		return false, nil
+	}
+	return true, s.write(file)
+}
+
+// remove removes the named secret from file, matching the name case-insensitively.
+// Returns true if the secret was found.
+func (s *SecretStore) remove(file *secretsFile, name string) bool {
+	found := false
+	for existing := range file.Secrets {
+		if strings.EqualFold(existing, name) {
+			delete(file.Secrets, existing)
+			found = true
+		}
+	}
+	return found
+}
+
+// read reads the secrets file, returning an empty file if it doesn't exist yet.
+func (s *SecretStore) read() (*secretsFile, error) {
+	file := &secretsFile{Secrets: make(map[string]*encryptedSecret)}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return nil, fmt.Errorf("error reading secrets file: %w", err)
+	}
+	err = json.Unmarshal(data, file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing secrets file %s: %w", s.path, err)
+	}
+	if file.Secrets == nil {
+		file.Secrets = make(map[string]*encryptedSecret)
+	}
+	return file, nil
+}
+
+// write replaces the secrets file with file, creating it if it doesn't exist.
+func (s *SecretStore) write(file *secretsFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding secrets file: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(s.path), 0700)
+	if err != nil {
+		return fmt.Errorf("error creating secrets file directory: %w", err)
+	}
+	// Write to a temporary file and rename it into place so a failed write can't lose existing secrets
+	tempPath := s.path + ".tmp"
+	err = os.WriteFile(tempPath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing secrets file: %w", err)
+	}
+	err = os.Rename(tempPath, s.path)
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("error writing secrets file: %w", err)
+	}
+	return nil
+}
+
+// makeEncryptionService makes a service to encrypt and decrypt secrets using the key in the key file.
+// If create is true then a new random key is generated and saved if the key file doesn't exist yet.
+// Secrets encrypted with a previous key can't be decrypted with a new one, so only create a key for an empty file.
+func (s *SecretStore) makeEncryptionService(create bool) (*encryption.EncryptionService, error) {
+	var key [32]byte
+	data, err := os.ReadFile(s.keyPath)
+	switch {
+	case err == nil:
+		decoded, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(decoded) != len(key) {
+			return nil, fmt.Errorf("error key file %s is not a valid key", s.keyPath)
+		}
+		copy(key[:], decoded)
+	case os.IsNotExist(err) && create:
+		_, err = io.ReadFull(rand.Reader, key[:])
+		if err != nil {
+			return nil, fmt.Errorf("error generating key: %w", err)
+		}
+		err = os.MkdirAll(filepath.Dir(s.keyPath), 0700)
+		if err != nil {
+			return nil, fmt.Errorf("error creating key file directory: %w", err)
+		}
+		err = os.WriteFile(s.keyPath, []byte(hex.EncodeToString(key[:])), 0600)
+		if err != nil {
+			return nil, fmt.Errorf("error writing key file: %w", err)
+		}
+	case os.IsNotExist(err):
+		return nil, fmt.Errorf("error key file %s for secrets file %s is missing; delete the secrets file and set its secrets again", s.keyPath, s.path)
+	default:
+		return nil, fmt.Errorf("error reading key file: %w", err)
+	}
+	return encryption.NewEncryptionService(encryption.NewLocalKeyManager(&key)), nil
+}
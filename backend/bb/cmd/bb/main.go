@@ -2,8 +2,14 @@ package main
 
 import (
 	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands"
+	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/artifacts"
 	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/cleanup"
+	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/graph"
+	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/logs"
 	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/run"
+	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/secrets"
+	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/trigger"
+	_ "github.com/buildbeaver/buildbeaver/bb/cmd/bb/commands/validate"
 )
 
 func main() {
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
+)
+
+// JobChange records whether a job in a build needs to run again after files in the working copy have changed.
+type JobChange struct {
+	FQN models.NodeFQN
+	// Affected is true if the job needs to run again.
+	Affected bool
+	// Reason explains why the job does or doesn't need to run again.
+	Reason string
+}
+
+// AffectedJobs works out which of the jobs in graph need to run again after the files in changedFiles have
+// changed, given the status of each job the last time it ran (keyed by job FQN). configFile is the path of the
+// build config; all paths are relative to the root of the repo and use forward slashes.
+//
+// A job needs to run again if any of the changed files pass its path filters, if it has no path filters (in which
+// case its fingerprint commands decide whether it actually runs), if it didn't succeed last time, or if it depends
+// on a job that needs to run again. Every job needs to run again if the build config itself changed. The returned
+// changes are in the same order as the jobs in graph.
+func AffectedJobs(graph *dto.BuildGraph, configFile string, changedFiles []string, lastStatus map[models.NodeFQN]models.WorkflowStatus) ([]*JobChange, error) {
+	var (
+		changes       []*JobChange
+		changesByFQN  = make(map[models.NodeFQN]*JobChange, len(graph.Jobs))
+		configChanged = false
+	)
+	for _, file := range changedFiles {
+		if file == configFile {
+			configChanged = true
+		}
+	}
+	for _, job := range graph.Jobs {
+		change := &JobChange{FQN: job.GetFQN()}
+		status, ranBefore := lastStatus[change.FQN]
+		switch {
+		case configChanged:
+			change.Affected = true
+			change.Reason = "the build config changed"
+		case !ranBefore:
+			change.Affected = true
+			change.Reason = "it didn't run last time"
+		case status != models.WorkflowStatusSucceeded && status != models.WorkflowStatusSkipped:
+			change.Affected = true
+			change.Reason = fmt.Sprintf("it %s last time", status)
+		case len(job.OnlyPaths) == 0 && len(job.IgnorePaths) == 0:
+			change.Affected = true
+			change.Reason = "it has no path filters"
+		default:
+			change.Reason = "no changed files match its path filters"
+			for _, file := range changedFiles {
+				matched, err := parser.PathFiltersMatch(job.OnlyPaths, job.IgnorePaths, []string{file})
+				if err != nil {
+					return nil, fmt.Errorf("error matching path filters for job %q: %w", change.FQN.String(), err)
+				}
+				if matched {
+					change.Affected = true
+					change.Reason = fmt.Sprintf("%s matches its path filters", file)
+					break
+				}
+			}
+		}
+		changes = append(changes, change)
+		changesByFQN[change.FQN] = change
+	}
+
+	// Jobs that depend on a job that needs to run again need to run again too; repeat until nothing changes,
+	// since jobs aren't necessarily listed in dependency order
+	for propagated := true; propagated; {
+		propagated = false
+		for _, job := range graph.Jobs {
+			change := changesByFQN[job.GetFQN()]
+			if change.Affected {
+				continue
+			}
+			for _, dependency := range job.Depends {
+				dependencyChange, ok := changesByFQN[dependency.GetFQN()]
+				if ok && dependencyChange.Affected {
+					change.Affected = true
+					change.Reason = fmt.Sprintf("it depends on %s", dependencyChange.FQN.String())
+					propagated = true
+					break
+				}
+			}
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-units"
+
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/local_backend"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
+)
+
+// ArtifactSource finds the artifacts produced by a build and reads their data.
+type ArtifactSource interface {
+	SearchArtifacts(ctx context.Context, buildID models.BuildID, search *models.ArtifactSearch) (models.ArtifactSearchPaginator, error)
+	// GetArtifactData returns a reader to the data of an artifact. It is the caller's responsibility to close the reader.
+	GetArtifactData(ctx context.Context, artifact *models.Artifact) (io.ReadCloser, error)
+}
+
+// localArtifactSource reads artifacts produced by local builds from the working copy they were produced in.
+type localArtifactSource struct {
+	backend *local_backend.LocalBackend
+}
+
+// NewLocalArtifactSource creates an ArtifactSource for artifacts produced by local builds.
+func NewLocalArtifactSource(backend *local_backend.LocalBackend) ArtifactSource {
+	return &localArtifactSource{backend: backend}
+}
+
+func (s *localArtifactSource) SearchArtifacts(ctx context.Context, buildID models.BuildID, search *models.ArtifactSearch) (models.ArtifactSearchPaginator, error) {
+	return s.backend.SearchArtifacts(ctx, buildID, search)
+}
+
+func (s *localArtifactSource) GetArtifactData(ctx context.Context, artifact *models.Artifact) (io.ReadCloser, error) {
+	return s.backend.GetArtifactLocalData(artifact)
+}
+
+// remoteArtifactSource downloads artifacts produced by builds on a BuildBeaver server.
+type remoteArtifactSource struct {
+	apiClient *client.APIClient
+}
+
+// NewRemoteArtifactSource creates an ArtifactSource for artifacts produced by builds on the server apiClient connects to.
+func NewRemoteArtifactSource(apiClient *client.APIClient) ArtifactSource {
+	return &remoteArtifactSource{apiClient: apiClient}
+}
+
+func (s *remoteArtifactSource) SearchArtifacts(ctx context.Context, buildID models.BuildID, search *models.ArtifactSearch) (models.ArtifactSearchPaginator, error) {
+	return s.apiClient.SearchBuildArtifacts(ctx, buildID, search)
+}
+
+func (s *remoteArtifactSource) GetArtifactData(ctx context.Context, artifact *models.Artifact) (io.ReadCloser, error) {
+	return s.apiClient.GetBuildArtifactData(ctx, artifact.ID)
+}
+
+// DownloadedArtifact records the outcome of downloading a single artifact.
+type DownloadedArtifact struct {
+	ArtifactID models.ArtifactID   `json:"artifact_id"`
+	GroupName  models.ResourceName `json:"group_name"`
+	// Path is the path the artifact was written to, relative to the destination directory.
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Verified is true if the downloaded data was checked against the hash recorded for the artifact.
+	Verified bool `json:"verified"`
+	// Skipped explains why the artifact was not downloaded, or is empty if it was downloaded.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// ArtifactDownloadReport records the outcome of downloading the artifacts for a build.
+type ArtifactDownloadReport struct {
+	BuildID     models.BuildID        `json:"build_id"`
+	Destination string                `json:"destination"`
+	Artifacts   []*DownloadedArtifact `json:"artifacts"`
+	// Downloaded is the total size of all artifacts downloaded, in bytes.
+	Downloaded int64 `json:"downloaded"`
+}
+
+// DownloadArtifacts downloads every artifact produced by a build into destDir, optionally only those in the
+// named group. Each artifact is written to its path relative to the job workspace, and is checked against the
+// hash recorded for it before being moved into place. If progress is not nil, progress is written to it as
+// each artifact is downloaded.
+func DownloadArtifacts(
+	ctx context.Context,
+	source ArtifactSource,
+	buildID models.BuildID,
+	groupName *models.ResourceName,
+	destDir string,
+	progress io.Writer,
+) (*ArtifactDownloadReport, error) {
+	search := models.NewArtifactSearch()
+	search.GroupName = groupName
+	paginator, err := source.SearchArtifacts(ctx, buildID, search)
+	if err != nil {
+		return nil, fmt.Errorf("error searching artifacts: %w", err)
+	}
+	var artifacts []*models.Artifact
+	for paginator.HasNext() {
+		results, err := paginator.Next(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error searching artifacts: %w", err)
+		}
+		artifacts = append(artifacts, results...)
+	}
+	if len(artifacts) == 0 {
+		if groupName != nil {
+			return nil, fmt.Errorf("error build %s has no artifacts in group %q", buildID, *groupName)
+		}
+		return nil, fmt.Errorf("error build %s has no artifacts", buildID)
+	}
+
+	err = os.MkdirAll(destDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error making destination directory %q: %w", destDir, err)
+	}
+	report := &ArtifactDownloadReport{BuildID: buildID, Destination: destDir}
+	pathToArtifactID := make(map[string]models.ArtifactID, len(artifacts))
+	for i, artifact := range artifacts {
+		// Artifact paths are relative to the job workspace; clean them so they can't escape the destination
+		relativePath := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(artifact.Path, "\\", "/")), "/")
+		if other, ok := pathToArtifactID[relativePath]; ok {
+			return report, fmt.Errorf("error artifacts %s and %s both have path %q; use --group to download one group at a time",
+				other, artifact.ID, relativePath)
+		}
+		pathToArtifactID[relativePath] = artifact.ID
+
+		downloaded := &DownloadedArtifact{
+			ArtifactID: artifact.ID,
+			GroupName:  artifact.GroupName,
+			Path:       relativePath,
+		}
+		report.Artifacts = append(report.Artifacts, downloaded)
+		if !artifact.Sealed {
+			downloaded.Skipped = "upload did not finish"
+		} else if artifact.ScanStatus == models.ArtifactScanStatusInfected {
+			downloaded.Skipped = fmt.Sprintf("quarantined (%s)", artifact.ScanResult)
+		}
+		if downloaded.Skipped != "" {
+			if progress != nil {
+				fmt.Fprintf(progress, "[%d/%d] %s: skipped, %s\r\n", i+1, len(artifacts), relativePath, downloaded.Skipped)
+			}
+			continue
+		}
+
+		var counter *progressCounter
+		if progress != nil {
+			counter = &progressCounter{
+				w:      progress,
+				prefix: fmt.Sprintf("[%d/%d] %s", i+1, len(artifacts), relativePath),
+				total:  int64(artifact.Size),
+			}
+		}
+		err = downloadArtifact(ctx, source, artifact, filepath.Join(destDir, filepath.FromSlash(relativePath)), downloaded, counter)
+		if counter != nil {
+			counter.finish(err)
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Downloaded += downloaded.Size
+	}
+	return report, nil
+}
+
+// downloadArtifact downloads the data for a single artifact to a temporary file next to target, verifies it
+// and then moves it into place, so that target is never left holding partial or corrupt data.
+func downloadArtifact(
+	ctx context.Context,
+	source ArtifactSource,
+	artifact *models.Artifact,
+	target string,
+	downloaded *DownloadedArtifact,
+	counterOrNil *progressCounter,
+) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return fmt.Errorf("error making directory for artifact %q: %w", artifact.Path, err)
+	}
+	reader, err := source.GetArtifactData(ctx, artifact)
+	if err != nil {
+		return fmt.Errorf("error reading data for artifact %q: %w", artifact.Path, err)
+	}
+	defer reader.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".bb-download-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file for artifact %q: %w", artifact.Path, err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once the file has been moved into place
+	defer tmp.Close()
+
+	hasher, expected := makeArtifactHasher(artifact)
+	writers := []io.Writer{tmp}
+	if hasher != nil {
+		writers = append(writers, hasher)
+	}
+	if counterOrNil != nil {
+		writers = append(writers, counterOrNil)
+	}
+	n, err := io.Copy(io.MultiWriter(writers...), reader)
+	if err != nil {
+		return fmt.Errorf("error downloading artifact %q: %w", artifact.Path, err)
+	}
+	downloaded.Size = n
+	if n != int64(artifact.Size) {
+		return fmt.Errorf("error downloading artifact %q: expected %d bytes, got %d", artifact.Path, artifact.Size, n)
+	}
+	if hasher != nil {
+		actual := hex.EncodeToString(hasher.Sum(nil))
+		if !strings.EqualFold(actual, expected) {
+			return fmt.Errorf("error verifying artifact %q: checksum mismatch (expected %s, got %s)", artifact.Path, expected, actual)
+		}
+		downloaded.Verified = true
+	}
+
+	err = tmp.Chmod(0644)
+	if err != nil {
+		return fmt.Errorf("error setting permissions for artifact %q: %w", artifact.Path, err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("error writing artifact %q: %w", artifact.Path, err)
+	}
+	err = os.Rename(tmp.Name(), target)
+	if err != nil {
+		return fmt.Errorf("error moving artifact %q into place: %w", artifact.Path, err)
+	}
+	return nil
+}
+
+// makeArtifactHasher returns a hasher to verify the data for artifact along with the hex-encoded hash it is
+// expected to produce, or a nil hasher if no hash was recorded for the artifact using a supported algorithm.
+func makeArtifactHasher(artifact *models.Artifact) (hash.Hash, string) {
+	if artifact.SHA256 != "" {
+		return sha256.New(), artifact.SHA256
+	}
+	if artifact.Hash == "" {
+		return nil, ""
+	}
+	switch artifact.HashType {
+	case models.HashTypeSHA256:
+		return sha256.New(), artifact.Hash
+	case models.HashTypeSHA1:
+		return sha1.New(), artifact.Hash
+	case models.HashTypeMD5:
+		return md5.New(), artifact.Hash
+	default:
+		return nil, ""
+	}
+}
+
+// progressCounter writes the progress of a download to w each time another percent of the data has been written.
+type progressCounter struct {
+	w           io.Writer
+	prefix      string
+	total       int64
+	written     int64
+	lastPercent int64
+}
+
+func (c *progressCounter) Write(p []byte) (int, error) {
+	c.written += int64(len(p))
+	if c.total > 0 {
+		percent := c.written * 100 / c.total
+		if percent != c.lastPercent {
+			c.lastPercent = percent
+			fmt.Fprintf(c.w, "\r%s %3d%% of %s", c.prefix, percent, units.HumanSize(float64(c.total)))
+		}
+	}
+	return len(p), nil
+}
+
+// finish writes the final outcome of the download, where err is the error downloading the data, if any.
+func (c *progressCounter) finish(err error) {
+	if err != nil {
+		fmt.Fprintf(c.w, "\r%s: failed\r\n", c.prefix)
+		return
+	}
+	fmt.Fprintf(c.w, "\r%s %s done\r\n", c.prefix, units.HumanSize(float64(c.written)))
+}
+
+// Write writes the report to w, as JSON if jsonOutput is true.
+func (r *ArtifactDownloadReport) Write(w io.Writer, jsonOutput bool) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+	var downloaded, unverified, skipped int
+	for _, artifact := range r.Artifacts {
+		switch {
+		case artifact.Skipped != "":
+			skipped++
+		case !artifact.Verified:
+			downloaded++
+			unverified++
+		default:
+			downloaded++
+		}
+	}
+	fmt.Fprintf(w, "\r\nDownloaded %d artifact(s) (%s) to %s\r\n", downloaded, units.HumanSize(float64(r.Downloaded)), r.Destination)
+	if unverified > 0 {
+		fmt.Fprintf(w, "Warning: %d artifact(s) had no checksum recorded and could not be verified\r\n", unverified)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(w, "Warning: %d artifact(s) were skipped\r\n", skipped)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/buildbeaver/buildbeaver/bb/cmd/bb/local_backend"
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
+)
+
+// localBuildRef is the git ref recorded against the build graph made when checking a build config locally.
+// Build configs don't depend on the ref, so any non-empty value will do.
+const localBuildRef = "HEAD"
+
+// BuildConfigReport records the outcome of checking a build config.
+type BuildConfigReport struct {
+	// File is the path of the build config that was checked.
+	File string `json:"file"`
+	// Dynamic is true if the build config was checked as jobs submitted by a dynamic build.
+	Dynamic bool `json:"dynamic"`
+	// Valid is true if no problems were found with the build config.
+	Valid bool `json:"valid"`
+	// Version is the build definition version the config was parsed with, or empty if it could not be parsed.
+	Version string `json:"version,omitempty"`
+	// Errors lists the problems found with the build config, or is empty if Valid is true.
+	Errors []*documents.BuildConfigError `json:"errors,omitempty"`
+	// Warnings lists problems that would not prevent a build from running, such as deprecated fields.
+	Warnings []string `json:"warnings,omitempty"`
+	// Graph contains the jobs the build config defines, or is nil if the build config is not valid.
+	Graph *dto.BuildGraph `json:"-"`
+}
+
+// FindBuildConfig returns the path of the build config to check: configPath if it is not empty, or otherwise
+// the build config in the root of the git repo containing the current working directory. Also returns the
+// directory that templates referenced from the build config are loaded relative to.
+func FindBuildConfig(configPath string) (string, string, error) {
+	root, err := local_backend.LocateGitRoot()
+	if configPath != "" {
+		if err != nil {
+			// Templates are loaded relative to the config itself when it's not in a git repo
+			return configPath, filepath.Dir(configPath), nil
+		}
+		return configPath, root, nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	name, _, err := parser.FindBuildConfigFile(root)
+	if err != nil {
+		return "", "", err
+	}
+	path := filepath.Join(root, name)
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			path = rel
+		}
+	}
+	return path, root, nil
+}
+
+// CheckBuildConfig parses the build config at configPath and checks that the jobs it defines form a valid build,
+// loading any templates it references from the working copy of the repo rooted at root. If dynamic is true then
+// the build config is checked as a set of jobs submitted by a dynamic build: templates are not supported, and
+// dependencies on jobs that aren't in the build config are not checked since they can refer to jobs that are
+// already part of the build. Problems with the build config are reported in the returned report; an error is
+// returned only if the build config could not be checked.
+func CheckBuildConfig(queueService services.QueueService, configPath string, root string, dynamic bool) (*BuildConfigReport, error) {
+	report := &BuildConfigReport{File: configPath, Dynamic: dynamic}
+	configType := parser.ConfigTypeForFileName(configPath)
+	if configType == models.ConfigTypeInvalid {
+		return nil, fmt.Errorf("error %q is not a YAML, JSON or Jsonnet file", configPath)
+	}
+	config, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading build configuration file %q: %w", configPath, err)
+	}
+	err = queueService.CheckBuildConfigLength(len(config))
+	if err != nil {
+		report.Errors = append(report.Errors, makeBuildConfigError(err))
+		return report, nil
+	}
+
+	var (
+		buildDef *models.BuildDefinition
+		loader   *workingCopyTemplateLoader
+	)
+	if dynamic {
+		// Jobs are submitted by dynamic builds without templates
+		buildDef, err = queueService.ParseBuildConfig(config, configType, nil)
+	} else {
+		loader = &workingCopyTemplateLoader{root: root, queueService: queueService}
+		buildDef, err = queueService.ParseBuildConfig(config, configType, loader)
+	}
+	if err != nil {
+		if loader != nil && loader.readErr != nil {
+			return nil, fmt.Errorf("error loading build definition templates: %w", loader.readErr)
+		}
+		report.Errors = append(report.Errors, makeBuildConfigError(err))
+		return report, nil
+	}
+	report.Version = buildDef.Version
+	report.Warnings = buildDef.Warnings
+
+	if dynamic {
+		removeExternalJobDependencies(buildDef)
+	}
+	graph, err := queueService.MakeBuildGraph(models.NewRepoID(), localBuildRef, buildDef)
+	if err != nil {
+		report.Errors = append(report.Errors, makeBuildConfigErrors(err)...)
+		return report, nil
+	}
+	report.Valid = true
+	report.Graph = graph
+	return report, nil
+}
+
+// removeExternalJobDependencies removes any dependencies on jobs that aren't defined in buildDef.
+func removeExternalJobDependencies(buildDef *models.BuildDefinition) {
+	jobs := make(map[models.NodeFQN]bool, len(buildDef.Jobs))
+	for _, job := range buildDef.Jobs {
+		jobs[models.NewNodeFQNForJob(job.Workflow, job.Name)] = true
+	}
+	for i := range buildDef.Jobs {
+		var depends models.JobDependencies
+		for _, dependency := range buildDef.Jobs[i].Depends {
+			if jobs[dependency.GetFQN()] {
+				depends = append(depends, dependency)
+			}
+		}
+		buildDef.Jobs[i].Depends = depends
+	}
+}
+
+// makeBuildConfigErrors returns a BuildConfigError for each of the problems reported by err. Lists of problems
+// (which can be nested, e.g. problems with a job's steps) are flattened into a single list, with each problem's
+// message prefixed by the context it was reported in.
+func makeBuildConfigErrors(err error) []*documents.BuildConfigError {
+	var buildConfigErrors []*documents.BuildConfigError
+	for _, message := range flattenErrorMessages(err) {
+		buildConfigErrors = append(buildConfigErrors, &documents.BuildConfigError{Message: message})
+	}
+	return buildConfigErrors
+}
+
+// flattenErrorMessages returns a single-line message for each of the errors within err.
+func flattenErrorMessages(err error) []string {
+	var multiErr *multierror.Error
+	if !errors.As(err, &multiErr) {
+		return []string{err.Error()}
+	}
+	// Keep any context that errors wrapping the list added to the start of its message. The list's own message
+	// spans several lines, so a prefix containing a newline means a wrapper repeated the list rather than
+	// adding context.
+	prefix := strings.TrimSuffix(err.Error(), multiErr.Error())
+	if !strings.HasSuffix(err.Error(), multiErr.Error()) || strings.Contains(prefix, "\n") {
+		prefix = ""
+	}
+	var messages []string
+	for _, err := range multiErr.Errors {
+		for _, message := range flattenErrorMessages(err) {
+			messages = append(messages, prefix+message)
+		}
+	}
+	return messages
+}
+
+// makeBuildConfigError returns a BuildConfigError describing err, including the location of the problem if known.
+func makeBuildConfigError(err error) *documents.BuildConfigError {
+	buildConfigErr := &documents.BuildConfigError{Message: err.Error()}
+	if parseErr := parser.AsParseError(err); parseErr != nil {
+		buildConfigErr.Message = parseErr.Err.Error()
+		buildConfigErr.File = parseErr.File
+		buildConfigErr.Line = parseErr.Line
+		buildConfigErr.Column = parseErr.Column
+		buildConfigErr.Path = parseErr.Path
+	}
+	return buildConfigErr
+}
+
+// Write writes the report to w, either as JSON or as text with one line per problem. Problems are prefixed with
+// the file, line and column they were found at, where known.
+func (r *BuildConfigReport) Write(w io.Writer, jsonOutput bool) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+	for _, buildConfigErr := range r.Errors {
+		location := buildConfigErr.File
+		if location == "" {
+			location = r.File
+		}
+		if buildConfigErr.Line > 0 {
+			location = fmt.Sprintf("%s:%d:%d", location, buildConfigErr.Line, buildConfigErr.Column)
+		}
+		if buildConfigErr.Path != "" {
+			location = fmt.Sprintf("%s: %s", location, buildConfigErr.Path)
+		}
+		fmt.Fprintf(w, "%s: %s\r\n", location, buildConfigErr.Message)
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "Warning: %s: %s\r\n", r.File, warning)
+	}
+	if r.Valid {
+		fmt.Fprintf(w, "%s is valid (version %s, %d job(s))\r\n", r.File, r.Version, len(r.Graph.Jobs))
+	}
+	return nil
+}
+
+// workingCopyTemplateLoader loads the templates referenced from a build config from the working copy of the repo
+// being built. Templates in other repos, or at other git refs, can't be loaded locally.
+type workingCopyTemplateLoader struct {
+	root         string
+	queueService services.QueueService
+	// readErr is the first error encountered while reading a template that is not caused by a problem with
+	// the build config.
+	readErr error
+}
+
+func (l *workingCopyTemplateLoader) LoadTemplate(ref parser.TemplateReference) ([]byte, error) {
+	if ref.Repo != "" || ref.Ref != "" {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf(
+			"Template %q is in another repo or at another git ref, so can't be loaded from the working copy", ref))
+	}
+	path := filepath.Join(l.root, filepath.FromSlash(ref.Path))
+	if rel, err := filepath.Rel(l.root, path); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Template %q is outside the repo", ref))
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, gerror.NewErrNotFound(fmt.Sprintf("Template %q not found", ref))
+		}
+		if l.readErr == nil {
+			l.readErr = err
+		}
+		return nil, err
+	}
+	err = l.queueService.CheckBuildConfigLength(len(contents))
+	if err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// GraphFormat is a text format that a job dependency graph can be written in.
+type GraphFormat string
+
+const (
+	// GraphFormatDot is the Graphviz DOT format, e.g. for rendering using 'dot -Tsvg'.
+	GraphFormatDot GraphFormat = "dot"
+	// GraphFormatMermaid is the Mermaid flowchart format, e.g. for embedding in Markdown.
+	GraphFormatMermaid GraphFormat = "mermaid"
+)
+
+func (f GraphFormat) Validate() error {
+	switch f {
+	case GraphFormatDot, GraphFormatMermaid:
+		return nil
+	default:
+		return fmt.Errorf("error unsupported graph format %q; expected %q or %q", f, GraphFormatDot, GraphFormatMermaid)
+	}
+}
+
+// graphNode is a job in a job dependency graph.
+type graphNode struct {
+	// id identifies the node within the graph output.
+	id  string
+	fqn models.NodeFQN
+	// external is true if the job is depended on but is not part of the graph, e.g. because it is in
+	// a workflow that will be added to the build later.
+	external bool
+}
+
+// graphEdge is a dependency of one job on another in a job dependency graph.
+type graphEdge struct {
+	from *graphNode
+	to   *graphNode
+	// label lists the artifacts the dependency consumes, if the dependency is limited to specific artifacts.
+	label string
+}
+
+// graphWorkflow is the set of jobs in a workflow in a job dependency graph.
+type graphWorkflow struct {
+	id    string
+	name  models.ResourceName
+	nodes []*graphNode
+}
+
+// jobDependencyGraph is the graph of dependencies between the jobs in a build, ready to be written out.
+type jobDependencyGraph struct {
+	// workflows contains the jobs in each workflow, in the order the workflows first appear in the build.
+	// The jobs in the default workflow are recorded against a workflow with an empty name.
+	workflows []*graphWorkflow
+	edges     []*graphEdge
+}
+
+// makeJobDependencyGraph makes the graph of dependencies between the jobs in graph. Jobs are ordered as they
+// appear in the build, so the output is the same each time the graph is written.
+func makeJobDependencyGraph(graph *dto.BuildGraph) *jobDependencyGraph {
+	var (
+		depGraph      = &jobDependencyGraph{}
+		nodesByFQN    = make(map[models.NodeFQN]*graphNode)
+		workflowsByID = make(map[models.ResourceName]*graphWorkflow)
+	)
+	findOrAddNode := func(fqn models.NodeFQN, external bool) *graphNode {
+		node, ok := nodesByFQN[fqn]
+		if ok {
+			return node
+		}
+		node = &graphNode{id: fmt.Sprintf("job%d", len(nodesByFQN)), fqn: fqn, external: external}
+		nodesByFQN[fqn] = node
+		workflow, ok := workflowsByID[fqn.WorkflowName]
+		if !ok {
+			workflow = &graphWorkflow{id: fmt.Sprintf("workflow%d", len(workflowsByID)), name: fqn.WorkflowName}
+			workflowsByID[fqn.WorkflowName] = workflow
+			depGraph.workflows = append(depGraph.workflows, workflow)
+		}
+		workflow.nodes = append(workflow.nodes, node)
+		return node
+	}
+	for _, job := range graph.Jobs {
+		findOrAddNode(job.GetFQN(), false)
+	}
+	for _, job := range graph.Jobs {
+		to := nodesByFQN[job.GetFQN()]
+		for _, dependency := range job.Depends {
+			from := findOrAddNode(dependency.GetFQN(), true)
+			var artifacts []string
+			for _, artifactDependency := range dependency.ArtifactDependencies {
+				if artifactDependency.GroupName != "" {
+					artifacts = append(artifacts, artifactDependency.GroupName.String())
+				}
+			}
+			depGraph.edges = append(depGraph.edges, &graphEdge{from: from, to: to, label: strings.Join(artifacts, ", ")})
+		}
+	}
+	return depGraph
+}
+
+// WriteBuildGraph writes the graph of dependencies between the jobs in a build to w in the specified format.
+// Jobs are grouped by workflow, and each edge points from a job to the jobs that depend on it. Jobs that are
+// depended on but aren't part of the build yet (e.g. jobs in workflows that a dynamic build will add later)
+// are drawn with dashed outlines.
+func WriteBuildGraph(w io.Writer, graph *dto.BuildGraph, format GraphFormat) error {
+	depGraph := makeJobDependencyGraph(graph)
+	var sb strings.Builder
+	switch format {
+	case GraphFormatDot:
+		depGraph.writeDot(&sb)
+	case GraphFormatMermaid:
+		depGraph.writeMermaid(&sb)
+	default:
+		return format.Validate()
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (g *jobDependencyGraph) writeDot(sb *strings.Builder) {
+	sb.WriteString("digraph build {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	writeNode := func(indent string, node *graphNode) {
+		attrs := fmt.Sprintf("label=%q", node.fqn.JobName.String())
+		if node.external {
+			attrs += ", style=dashed"
+		}
+		sb.WriteString(fmt.Sprintf("%s%q [%s];\n", indent, node.id, attrs))
+	}
+	for _, workflow := range g.workflows {
+		if workflow.name == "" {
+			for _, node := range workflow.nodes {
+				writeNode("  ", node)
+			}
+			continue
+		}
+		// Subgraphs must be named 'cluster...' for Graphviz to draw a box around them
+		sb.WriteString(fmt.Sprintf("  subgraph %q {\n", "cluster_"+workflow.id))
+		sb.WriteString(fmt.Sprintf("    label=%q;\n", workflow.name.String()))
+		for _, node := range workflow.nodes {
+			writeNode("    ", node)
+		}
+		sb.WriteString("  }\n")
+	}
+	for _, edge := range g.edges {
+		var attrs []string
+		if edge.label != "" {
+			attrs = append(attrs, fmt.Sprintf("label=%q", edge.label))
+		}
+		if edge.from.external {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) > 0 {
+			sb.WriteString(fmt.Sprintf("  %q -> %q [%s];\n", edge.from.id, edge.to.id, strings.Join(attrs, ", ")))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %q -> %q;\n", edge.from.id, edge.to.id))
+		}
+	}
+	sb.WriteString("}\n")
+}
+
+func (g *jobDependencyGraph) writeMermaid(sb *strings.Builder) {
+	sb.WriteString("flowchart LR\n")
+	hasExternal := false
+	writeNode := func(indent string, node *graphNode) {
+		sb.WriteString(fmt.Sprintf("%s%s[\"%s\"]", indent, node.id, node.fqn.JobName))
+		if node.external {
+			sb.WriteString(":::external")
+			hasExternal = true
+		}
+		sb.WriteString("\n")
+	}
+	for _, workflow := range g.workflows {
+		if workflow.name == "" {
+			for _, node := range workflow.nodes {
+				writeNode("  ", node)
+			}
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  subgraph %s [\"%s\"]\n", workflow.id, workflow.name))
+		for _, node := range workflow.nodes {
+			writeNode("    ", node)
+		}
+		sb.WriteString("  end\n")
+	}
+	for _, edge := range g.edges {
+		arrow := "-->"
+		if edge.from.external {
+			arrow = "-.->"
+		}
+		if edge.label != "" {
+			arrow = fmt.Sprintf("%s|\"%s\"|", arrow, edge.label)
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s %s\n", edge.from.id, arrow, edge.to.id))
+	}
+	if hasExternal {
+		sb.WriteString("  classDef external stroke-dasharray: 5 5\n")
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,9 +12,11 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/buildbeaver/buildbeaver/bb/app"
+	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/common/util/proc_lock"
 	"github.com/buildbeaver/buildbeaver/runner/runtime/docker"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
 )
 
 // ParseNodeFQNS parses each of the supplied arguments as a Fully Qualified Name identifying a node in the build graph.
@@ -31,6 +34,39 @@ func ParseNodeFQNS(args []string) ([]models.NodeFQN, error) {
 	return fqns, nil
 }
 
+// ParseWorkflowOutputStubs parses each of the supplied arguments as a stubbed workflow output, in the
+// form "workflow.output=file". The file must contain the JSON encoding of the output value.
+func ParseWorkflowOutputStubs(args []string) (models.WorkflowOutputStubs, error) {
+	stubs := make(models.WorkflowOutputStubs)
+	for _, arg := range args {
+		name, filePath, ok := strings.Cut(arg, "=")
+		if !ok || filePath == "" {
+			return nil, fmt.Errorf("error parsing stubbed output %q: expected workflow.output=file", arg)
+		}
+		workflowName, outputName, ok := strings.Cut(name, ".")
+		if !ok || outputName == "" {
+			return nil, fmt.Errorf("error parsing stubbed output %q: expected workflow.output=file", arg)
+		}
+		filePath, err := HomeifyPath(filePath)
+		if err != nil {
+			return nil, err
+		}
+		value, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading stubbed output %q: %w", name, err)
+		}
+		if stubs[models.ResourceName(workflowName)] == nil {
+			stubs[models.ResourceName(workflowName)] = make(map[string]json.RawMessage)
+		}
+		stubs[models.ResourceName(workflowName)][outputName] = value
+	}
+	err := stubs.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return stubs, nil
+}
+
 func HomeifyPath(path string) (string, error) {
 	if strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "$HOME") {
 		home, err := os.UserHomeDir()
@@ -77,3 +113,25 @@ func CleanUpOldResources(bb *app.App, verbose bool) {
 func GetBBFileLock() (*os.File, error) {
 	return proc_lock.CreateLockFile(proc_lock.BBLockFile)
 }
+
+// NewServerAPIClient creates a client for the API of the BuildBeaver server at serverURL, authenticating
+// using a personal access token.
+func NewServerAPIClient(serverURL string, token string) (*client.APIClient, error) {
+	if token == "" {
+		return nil, fmt.Errorf("error a personal access token must be specified using --token or $BB_TOKEN to use a server")
+	}
+	logRegistry, err := logger.NewLogRegistry("")
+	if err != nil {
+		return nil, err
+	}
+	logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+	apiClient, err := client.NewAPIClient(
+		[]string{serverURL},
+		client.NewSharedSecretAuthenticator(client.SharedSecretToken(token), logFactory),
+		logFactory,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating API client: %w", err)
+	}
+	return apiClient, nil
+}
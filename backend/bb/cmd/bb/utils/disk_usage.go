@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/buildbeaver/buildbeaver/bb/app"
+	"github.com/buildbeaver/buildbeaver/runner"
+	"github.com/buildbeaver/buildbeaver/runner/runtime/docker"
+)
+
+// DiskUsageCategory is a kind of resource left behind by local builds that uses disk space.
+type DiskUsageCategory string
+
+const (
+	// DiskUsageCategoryDockerImages is the Docker images used by jobs and services in local builds.
+	DiskUsageCategoryDockerImages DiskUsageCategory = "docker-images"
+	// DiskUsageCategoryDockerVolumes is the anonymous Docker volumes that are no longer used by any container.
+	DiskUsageCategoryDockerVolumes DiskUsageCategory = "docker-volumes"
+	// DiskUsageCategoryWorkspaces is the per-job staging directories left behind by local builds.
+	DiskUsageCategoryWorkspaces DiskUsageCategory = "workspaces"
+	// DiskUsageCategoryArtifactCache is the local blob store holding artifacts and logs from local builds.
+	DiskUsageCategoryArtifactCache DiskUsageCategory = "artifact-cache"
+)
+
+// DiskUsageCategories is the list of all disk usage categories, in the order they are reported.
+var DiskUsageCategories = []DiskUsageCategory{
+	DiskUsageCategoryDockerImages,
+	DiskUsageCategoryDockerVolumes,
+	DiskUsageCategoryWorkspaces,
+	DiskUsageCategoryArtifactCache,
+}
+
+// ParseDiskUsageCategories parses each of the supplied arguments as a disk usage category.
+func ParseDiskUsageCategories(args []string) (map[DiskUsageCategory]bool, error) {
+	categories := make(map[DiskUsageCategory]bool)
+	for _, arg := range args {
+		found := false
+		for _, category := range DiskUsageCategories {
+			if arg == string(category) {
+				categories[category] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("error unknown disk usage category %q; expected one of %v", arg, DiskUsageCategories)
+		}
+	}
+	return categories, nil
+}
+
+// DiskUsageItem is a single resource that uses disk space, and that can be removed to reclaim the space.
+type DiskUsageItem struct {
+	Name string `json:"name"`
+	// Size is the disk space used in bytes, or -1 if it could not be calculated.
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	// Reclaim is true if the item has been selected for removal.
+	Reclaim bool `json:"reclaim"`
+	// Error is set if the item was selected for removal but could not be removed.
+	Error  string `json:"error,omitempty"`
+	remove func(ctx context.Context) error
+}
+
+// DiskUsageCategoryReport reports the disk space used by all resources in a single category.
+type DiskUsageCategoryReport struct {
+	Category DiskUsageCategory `json:"category"`
+	// Size is the total disk space used by all items in the category, in bytes.
+	Size int64 `json:"size"`
+	// ReclaimableSize is the disk space used by the items selected for removal, in bytes.
+	ReclaimableSize int64            `json:"reclaimable_size"`
+	Items           []*DiskUsageItem `json:"items"`
+	// Error is set if the resources in the category could not be listed.
+	Error string `json:"error,omitempty"`
+}
+
+// DiskUsageReport reports the disk space used by resources left behind by local builds, by category.
+type DiskUsageReport struct {
+	Categories []*DiskUsageCategoryReport `json:"categories"`
+	// Reclaimed is the disk space reclaimed by removing the selected items, in bytes.
+	Reclaimed int64 `json:"reclaimed"`
+}
+
+// DiskUsageFilter selects the items in a disk usage report to remove.
+type DiskUsageFilter struct {
+	// Categories is the set of categories to remove items from, or empty to remove items from all categories.
+	Categories map[DiskUsageCategory]bool
+	// KeepLast is the number of most recently created items to keep in each category.
+	KeepLast int
+	// OlderThan keeps items created more recently than this long ago, if non-zero.
+	OlderThan time.Duration
+}
+
+// GetDiskUsage reports the disk space used by each category of resource left behind by local builds.
+// Categories that can't be listed (e.g. Docker categories when Docker isn't running) are reported with an error.
+func GetDiskUsage(ctx context.Context, bb *app.App, config *app.BBConfig) (*DiskUsageReport, error) {
+	jobs, err := bb.Backend.ListFinishedJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var containerManager *docker.ContainerManager
+	dClient, dockerErr := client.NewClientWithOpts(client.FromEnv)
+	if dockerErr == nil {
+		containerManager = docker.NewContainerManager(docker.EngineConfig{}, dClient, bb.LogFactory)
+	}
+	dockerCategory := func(category DiskUsageCategory, list func() ([]*docker.DiskUsage, error), remove func(ctx context.Context, id string) error) *DiskUsageCategoryReport {
+		report := &DiskUsageCategoryReport{Category: category}
+		if dockerErr != nil {
+			report.Error = fmt.Sprintf("error making Docker API client: %s", dockerErr)
+			return report
+		}
+		usages, err := list()
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+		for _, usage := range usages {
+			id := usage.ID
+			report.Items = append(report.Items, &DiskUsageItem{
+				Name:      usage.Name,
+				Size:      usage.Size,
+				CreatedAt: usage.CreatedAt,
+				remove:    func(ctx context.Context) error { return remove(ctx, id) },
+			})
+		}
+		return report
+	}
+
+	// Docker images are those used by any job or service in a local build
+	var images []string
+	for _, job := range jobs {
+		if job.DockerImage != "" {
+			images = append(images, job.DockerImage)
+		}
+		for _, service := range job.Services {
+			images = append(images, service.DockerImage)
+		}
+	}
+	imagesReport := dockerCategory(DiskUsageCategoryDockerImages,
+		func() ([]*docker.DiskUsage, error) { return containerManager.ListImageDiskUsage(ctx, images) },
+		func(ctx context.Context, id string) error { return containerManager.RemoveImage(ctx, id) })
+	volumesReport := dockerCategory(DiskUsageCategoryDockerVolumes,
+		func() ([]*docker.DiskUsage, error) { return containerManager.ListUnusedVolumeDiskUsage(ctx) },
+		func(ctx context.Context, name string) error { return containerManager.RemoveVolume(ctx, name) })
+
+	// Workspaces are the directories created on the host for each job
+	workspacesReport := &DiskUsageCategoryReport{Category: DiskUsageCategoryWorkspaces}
+	for _, job := range jobs {
+		item, err := makeDirDiskUsageItem(runner.JobRootDir(job.ID.ResourceID))
+		if err != nil {
+			workspacesReport.Error = err.Error()
+			break
+		}
+		if item != nil {
+			workspacesReport.Items = append(workspacesReport.Items, item)
+		}
+	}
+
+	// The artifact cache is the local blob store; report each top-level directory separately
+	artifactCacheReport := &DiskUsageCategoryReport{Category: DiskUsageCategoryArtifactCache}
+	entries, err := os.ReadDir(config.LocalBlobStoreDir.String())
+	if err != nil && !os.IsNotExist(err) {
+		artifactCacheReport.Error = fmt.Sprintf("error reading artifact cache directory: %s", err)
+	}
+	for _, entry := range entries {
+		item, err := makeDirDiskUsageItem(filepath.Join(config.LocalBlobStoreDir.String(), entry.Name()))
+		if err != nil {
+			artifactCacheReport.Error = err.Error()
+			break
+		}
+		if item != nil {
+			artifactCacheReport.Items = append(artifactCacheReport.Items, item)
+		}
+	}
+
+	report := &DiskUsageReport{
+		Categories: []*DiskUsageCategoryReport{imagesReport, volumesReport, workspacesReport, artifactCacheReport},
+	}
+	for _, category := range report.Categories {
+		// Report the most recently created items first
+		sort.SliceStable(category.Items, func(i, j int) bool {
+			return category.Items[i].CreatedAt.After(category.Items[j].CreatedAt)
+		})
+		for _, item := range category.Items {
+			if item.Size > 0 {
+				category.Size += item.Size
+			}
+		}
+	}
+	return report, nil
+}
+
+// makeDirDiskUsageItem returns a disk usage item for the file or directory at the specified path, including
+// all files within the directory. Returns nil if nothing exists at the path.
+func makeDirDiskUsageItem(path string) (*DiskUsageItem, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading %q: %w", path, err)
+	}
+	var size int64
+	err = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error calculating size of %q: %w", path, err)
+	}
+	return &DiskUsageItem{
+		Name:      path,
+		Size:      size,
+		CreatedAt: info.ModTime(),
+		remove:    func(ctx context.Context) error { return os.RemoveAll(path) },
+	}, nil
+}
+
+// Select marks the items in the report that match the filter for removal.
+func (r *DiskUsageReport) Select(filter DiskUsageFilter) {
+	for _, category := range r.Categories {
+		if len(filter.Categories) > 0 && !filter.Categories[category.Category] {
+			continue
+		}
+		// Items are sorted with the most recently created first
+		for i, item := range category.Items {
+			if i < filter.KeepLast {
+				continue
+			}
+			if filter.OlderThan > 0 && time.Since(item.CreatedAt) < filter.OlderThan {
+				continue
+			}
+			item.Reclaim = true
+			if item.Size > 0 {
+				category.ReclaimableSize += item.Size
+			}
+		}
+	}
+}
+
+// Reclaim removes each item selected for removal. Items that can't be removed are recorded in the report
+// and the remaining items are still removed; an error is returned listing all items that failed.
+func (r *DiskUsageReport) Reclaim(ctx context.Context) error {
+	var results *multierror.Error
+	for _, category := range r.Categories {
+		for _, item := range category.Items {
+			if !item.Reclaim {
+				continue
+			}
+			err := item.remove(ctx)
+			if err != nil {
+				item.Error = err.Error()
+				results = multierror.Append(results, err)
+				continue
+			}
+			if item.Size > 0 {
+				r.Reclaimed += item.Size
+			}
+		}
+	}
+	return results.ErrorOrNil()
+}
+
+// Write writes the report to the supplied writer, either as a table of categories or as JSON.
+// If verbose is true the table also lists each item, marking those selected for removal with '*'.
+func (r *DiskUsageReport) Write(w io.Writer, jsonOutput bool, verbose bool) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "CATEGORY\tITEMS\tSIZE\tRECLAIMABLE\r\n")
+	for _, category := range r.Categories {
+		if category.Error != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\r\n", category.Category)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\r\n", category.Category, len(category.Items),
+			units.HumanSize(float64(category.Size)), units.HumanSize(float64(category.ReclaimableSize)))
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	for _, category := range r.Categories {
+		if category.Error != "" {
+			fmt.Fprintf(w, "Warning: unable to report %s: %s\r\n", category.Category, category.Error)
+		}
+		for _, item := range category.Items {
+			if item.Error != "" {
+				fmt.Fprintf(w, "Warning: unable to remove %s: %s\r\n", item.Name, item.Error)
+			}
+		}
+	}
+
+	if verbose {
+		for _, category := range r.Categories {
+			if len(category.Items) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "\r\n%s:\r\n", category.Category)
+			for _, item := range category.Items {
+				marker := " "
+				if item.Reclaim {
+					marker = "*"
+				}
+				fmt.Fprintf(w, "%s %s (%s, created %s)\r\n", marker, item.Name,
+					units.HumanSize(float64(item.Size)), item.CreatedAt.Format(time.RFC3339))
+			}
+		}
+	}
+
+	if r.Reclaimed > 0 {
+		fmt.Fprintf(w, "\r\nReclaimed %s\r\n", units.HumanSize(float64(r.Reclaimed)))
+	}
+	return nil
+}
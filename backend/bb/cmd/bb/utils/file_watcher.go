@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// FileWatcher watches the working copy of a git repo for changes to files that aren't ignored by git.
+type FileWatcher struct {
+	root     string
+	debounce time.Duration
+	watcher  *fsnotify.Watcher
+	matcher  gitignore.Matcher
+	mu       sync.Mutex // protects changed only
+	// changed is the set of files that have changed since the last call to Next or Discard, relative to root.
+	// The value is true if the file didn't exist before it changed.
+	changed map[string]bool
+	// notify receives a value whenever a file changes.
+	notify chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewFileWatcher starts watching all directories in the working copy rooted at root, other than those ignored by
+// .gitignore files. Directories created later are watched too. Call Close to stop watching.
+func NewFileWatcher(root string, debounce time.Duration) (*FileWatcher, error) {
+	patterns, err := gitignore.ReadPatterns(osfs.New(root), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading .gitignore files: %w", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error creating file watcher: %w", err)
+	}
+	w := &FileWatcher{
+		root:     root,
+		debounce: debounce,
+		watcher:  watcher,
+		matcher:  gitignore.NewMatcher(patterns),
+		changed:  make(map[string]bool),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	err = w.addDirs(root)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.loop()
+	}()
+	return w, nil
+}
+
+// Close stops watching for changes.
+func (w *FileWatcher) Close() error {
+	close(w.done)
+	err := w.watcher.Close()
+	w.wg.Wait()
+	return err
+}
+
+// Next waits until at least one file has changed, and then until no more files have changed for the debounce
+// period, before returning the paths of the files that changed relative to the root of the working copy.
+// Paths use forward slashes and are sorted. Returns an error if ctx is cancelled first.
+func (w *FileWatcher) Next(ctx context.Context) ([]string, error) {
+	for {
+		err := w.waitUntilQuiet(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Changes can cancel each other out, e.g. a temporary file that was created and then removed
+		paths := w.takeChanged()
+		if len(paths) > 0 {
+			return paths, nil
+		}
+	}
+}
+
+// waitUntilQuiet waits until at least one file has changed, and then until no more files have changed for the
+// debounce period.
+func (w *FileWatcher) waitUntilQuiet(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		pending := len(w.changed) > 0
+		w.mu.Unlock()
+		if pending {
+			break
+		}
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	timer := time.NewTimer(w.debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.notify:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(w.debounce)
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Discard forgets about any files that have changed since the last call to Next or Discard.
+func (w *FileWatcher) Discard() {
+	w.takeChanged()
+}
+
+func (w *FileWatcher) takeChanged() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	paths := make([]string, 0, len(w.changed))
+	for path, created := range w.changed {
+		if created {
+			// Ignore files that were created and then removed again, such as temporary files written by editors
+			if _, err := os.Lstat(filepath.Join(w.root, filepath.FromSlash(path))); os.IsNotExist(err) {
+				continue
+			}
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	w.changed = make(map[string]bool)
+	return paths
+}
+
+func (w *FileWatcher) loop() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Errors (e.g. the kernel's event queue overflowing) can only mean changes were missed, and there's
+			// nothing useful to do about that other than carry on watching
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *FileWatcher) handleEvent(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return
+	}
+	isDir := false
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			isDir = true
+		}
+	}
+	if w.isIgnored(rel, isDir) {
+		return
+	}
+	if isDir {
+		// Watch new directories too; any files already created in them before the watch was added are missed
+		w.addDirs(event.Name)
+		return
+	}
+	w.mu.Lock()
+	path := filepath.ToSlash(rel)
+	if _, ok := w.changed[path]; !ok {
+		w.changed[path] = event.Op&fsnotify.Create != 0
+	}
+	w.mu.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// addDirs adds a watch for dir and each directory below it that isn't ignored.
+func (w *FileWatcher) addDirs(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed while walking
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && w.isIgnored(rel, true) {
+			return filepath.SkipDir
+		}
+		err = w.watcher.Add(path)
+		if err != nil {
+			return fmt.Errorf("error watching directory %q: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// isIgnored returns true if changes to the file or directory at rel (relative to the root of the working copy)
+// should be ignored.
+func (w *FileWatcher) isIgnored(rel string, isDir bool) bool {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if parts[0] == ".git" {
+		return true
+	}
+	return w.matcher.Match(parts, isDir)
+}
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// DefaultLogPollInterval is how often logs that can't be streamed are read again when following them.
+const DefaultLogPollInterval = time.Second
+
+// ansiEscapeRegex matches ANSI escape sequences, including color codes and terminal titles.
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)`)
+
+// LogSource reads the data for logs.
+type LogSource interface {
+	// ReadLogData opens a stream of JSON log entries for a log. It is the caller's responsibility to close the reader.
+	ReadLogData(ctx context.Context, logID models.LogDescriptorID, search *models.LogSearch) (io.ReadCloser, error)
+}
+
+// localLogSource reads logs for local builds from the local log service.
+type localLogSource struct {
+	logService services.LogService
+}
+
+// NewLocalLogSource creates a LogSource for logs written by local builds.
+func NewLocalLogSource(logService services.LogService) LogSource {
+	return &localLogSource{logService: logService}
+}
+
+func (s *localLogSource) ReadLogData(ctx context.Context, logID models.LogDescriptorID, search *models.LogSearch) (io.ReadCloser, error) {
+	return s.logService.ReadData(ctx, logID, search)
+}
+
+// remoteLogSource reads logs for builds on a BuildBeaver server.
+type remoteLogSource struct {
+	apiClient *client.APIClient
+}
+
+// NewRemoteLogSource creates a LogSource for logs written by builds on the server apiClient connects to.
+func NewRemoteLogSource(apiClient *client.APIClient) LogSource {
+	return &remoteLogSource{apiClient: apiClient}
+}
+
+func (s *remoteLogSource) ReadLogData(ctx context.Context, logID models.LogDescriptorID, search *models.LogSearch) (io.ReadCloser, error) {
+	return s.apiClient.ReadLogData(ctx, logID, &documents.LogSearchRequest{LogSearch: search})
+}
+
+// LogTailOptions controls which log entries TailLog writes and how they are formatted.
+type LogTailOptions struct {
+	// Follow keeps writing new entries as they are written to the log, until the log is finished or ctx is done.
+	Follow bool
+	// Expand includes the entries of the log's child logs (e.g. the logs for each step of a job).
+	Expand bool
+	// Since skips entries written before this time, if set.
+	Since time.Time
+	// Timestamps prefixes each entry with the time it was written.
+	Timestamps bool
+	// NoColor removes ANSI escape sequences (e.g. colors) from entries, rather than passing them through.
+	NoColor bool
+	// PollInterval is how often an expanded log is read again when following it, since only single logs
+	// can be streamed. Defaults to DefaultLogPollInterval.
+	PollInterval time.Duration
+}
+
+// ParseLogSince parses the value of a --since flag, which can be either a duration before now (e.g. "10m")
+// or an RFC 3339 timestamp.
+func ParseLogSince(since string, now time.Time) (time.Time, error) {
+	duration, err := time.ParseDuration(since)
+	if err == nil {
+		return now.Add(-duration), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing %q: expected a duration (e.g. 10m) or an RFC 3339 timestamp", since)
+	}
+	return t, nil
+}
+
+// TailLog writes the entries of a log to w as text, one line per entry. If opts.Follow is set then TailLog
+// keeps writing entries as they are written to the log, and returns once the log is finished or ctx is done.
+func TailLog(ctx context.Context, source LogSource, logID models.LogDescriptorID, opts LogTailOptions, w io.Writer) error {
+	printer := &logEntryPrinter{w: w, opts: opts}
+	if !opts.Follow {
+		_, _, err := readLogEntries(ctx, source, logID, &models.LogSearch{Expand: &opts.Expand}, 0, printer.print)
+		return err
+	}
+	if !opts.Expand {
+		follow := true
+		_, _, err := readLogEntries(ctx, source, logID, &models.LogSearch{Follow: &follow}, 0, printer.print)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	// Only single logs can be streamed, so read the expanded log again each time the poll interval passes
+	// and write only the entries that weren't seen last time. Entries from all child logs are ordered by the
+	// time they were received, so new entries are always added at the end.
+	pollInterval := opts.PollInterval
+	if pollInterval == 0 {
+		pollInterval = DefaultLogPollInterval
+	}
+	seen := 0
+	for {
+		n, ended, err := readLogEntries(ctx, source, logID, &models.LogSearch{Expand: &opts.Expand}, seen, printer.print)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ended {
+			return nil
+		}
+		seen = n
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// readLogEntries reads the entries of a log, skipping the first skip entries and calling fn for the rest.
+// Returns the number of entries read (including those skipped), and whether the entry marking the end
+// of the log was read.
+func readLogEntries(
+	ctx context.Context,
+	source LogSource,
+	logID models.LogDescriptorID,
+	search *models.LogSearch,
+	skip int,
+	fn func(entry *models.LogEntry) error,
+) (int, bool, error) {
+	reader, err := source.ReadLogData(ctx, logID, search)
+	if err != nil {
+		return 0, false, fmt.Errorf("error reading log: %w", err)
+	}
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+	_, err = decoder.Token() // opening '['
+	if err != nil {
+		return 0, false, fmt.Errorf("error reading log: %w", err)
+	}
+	var (
+		n     int
+		ended bool
+	)
+	for decoder.More() {
+		entry := &models.LogEntry{}
+		err = decoder.Decode(entry)
+		if err != nil {
+			return n, ended, fmt.Errorf("error reading log entry: %w", err)
+		}
+		n++
+		if entry.Kind == models.LogEntryKindEnd {
+			ended = true
+		}
+		if n <= skip {
+			continue
+		}
+		err = fn(entry)
+		if err != nil {
+			return n, ended, err
+		}
+	}
+	_, err = decoder.Token() // closing ']'
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, ended, fmt.Errorf("error reading log: %w", err)
+	}
+	return n, ended, nil
+}
+
+// logEntryPrinter writes log entries as lines of text.
+type logEntryPrinter struct {
+	w    io.Writer
+	opts LogTailOptions
+}
+
+func (p *logEntryPrinter) print(entry *models.LogEntry) error {
+	text, ok := entry.Derived().(models.PlainTextLogEntry)
+	if !ok {
+		return nil // entries with no text (e.g. the end of the log) are not shown
+	}
+	// Entries written by bb don't have a server timestamp
+	timestamp := text.GetServerTimestamp().Time
+	if timestamp.IsZero() {
+		timestamp = text.GetClientTimestamp().Time
+	}
+	if !p.opts.Since.IsZero() && !timestamp.IsZero() && timestamp.Before(p.opts.Since) {
+		return nil
+	}
+	line := text.GetText()
+	if p.opts.NoColor {
+		line = ansiEscapeRegex.ReplaceAllString(line, "")
+	}
+	var err error
+	if p.opts.Timestamps {
+		_, err = fmt.Fprintf(p.w, "%s %s\r\n", timestamp.Local().Format("2006-01-02T15:04:05.000Z07:00"), line)
+	} else {
+		_, err = fmt.Fprintf(p.w, "%s\r\n", line)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// ParseJobFQNs parses each of the supplied arguments as the Fully Qualified Name of a job, in the form
+// "workflow.job", or "job" for a job in the default workflow.
+func ParseJobFQNs(args []string) ([]models.NodeFQN, error) {
+	var fqns []models.NodeFQN
+	for _, arg := range args {
+		workflowName, jobName, ok := strings.Cut(arg, ".")
+		if !ok {
+			workflowName, jobName = "", arg
+		}
+		fqn := models.NewNodeFQNForJob(models.ResourceName(workflowName), models.ResourceName(jobName))
+		if workflowName != "" {
+			err := fqn.WorkflowName.Validate()
+			if err != nil {
+				return nil, fmt.Errorf("error parsing job %q: invalid workflow name: %w", arg, err)
+			}
+		}
+		err := fqn.JobName.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing job %q: invalid job name: %w", arg, err)
+		}
+		fqns = append(fqns, fqn)
+	}
+	return fqns, nil
+}
+
+// ParseNameValuePairs parses each of the supplied arguments in the form "NAME=VALUE", as passed to the
+// specified command-line flag. The value can be empty. If a name is specified more than once the last value wins.
+func ParseNameValuePairs(flag string, args []string) (map[string]string, error) {
+	pairs := make(map[string]string, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("error parsing --%s %q: expected NAME=VALUE", flag, arg)
+		}
+		pairs[name] = value
+	}
+	return pairs, nil
+}
+
+// SelectNodesToRun returns the nodes to request in the build options in order to run the specified jobs and
+// workflows from the build graph defined by the build config. Each workflow is expanded to the jobs the build
+// config defines for it, so that only those jobs (and the jobs they depend on) are run. Workflows with no jobs
+// in the build config are left as they are, to be added to the build by a dynamic build.
+func SelectNodesToRun(graph *dto.BuildGraph, jobs []models.NodeFQN, workflows []models.NodeFQN) ([]models.NodeFQN, error) {
+	var (
+		nodes    []models.NodeFQN
+		selected = make(map[models.NodeFQN]bool)
+	)
+	add := func(fqn models.NodeFQN) {
+		if !selected[fqn] {
+			selected[fqn] = true
+			nodes = append(nodes, fqn)
+		}
+	}
+	for _, fqn := range jobs {
+		found := false
+		for _, job := range graph.Jobs {
+			if job.GetFQN() == fqn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("error job %q is not defined in the build config; "+
+				"use --workflow to run jobs that are added by a dynamic build", fqn.String())
+		}
+		add(fqn)
+	}
+	for _, fqn := range workflows {
+		found := false
+		for _, job := range graph.Jobs {
+			if job.Workflow == fqn.WorkflowName {
+				found = true
+				add(job.GetFQN())
+			}
+		}
+		if !found {
+			add(fqn)
+		}
+	}
+	return nodes, nil
+}
+
+// SkippedArtifactDependencies returns the artifacts that the jobs in nodes need from jobs in the build graph that
+// are not in nodes, i.e. the artifacts that must already be available if the jobs are run without the jobs they
+// depend on.
+func SkippedArtifactDependencies(graph *dto.BuildGraph, nodes []models.NodeFQN) []*models.ArtifactDependency {
+	var (
+		dependencies []*models.ArtifactDependency
+		selected     = make(map[models.NodeFQN]bool)
+		seen         = make(map[models.ArtifactDependency]bool)
+	)
+	for _, fqn := range nodes {
+		selected[models.NewNodeFQNForJob(fqn.WorkflowName, fqn.JobName)] = true
+	}
+	for _, job := range graph.Jobs {
+		if !selected[job.GetFQN()] {
+			continue
+		}
+		for _, dependency := range job.Depends {
+			if selected[dependency.GetFQN()] {
+				continue
+			}
+			for _, artifactDependency := range dependency.ArtifactDependencies {
+				if !seen[*artifactDependency] {
+					seen[*artifactDependency] = true
+					dependencies = append(dependencies, artifactDependency)
+				}
+			}
+		}
+	}
+	return dependencies
+}
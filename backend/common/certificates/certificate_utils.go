@@ -147,6 +147,26 @@ func GenerateEd25519SigningKeyAndCertificate(
 	)
 }
 
+// GenerateECDSASigningKeyAndCertificate checks whether a certificate and corresponding private key exist, and
+// if not then a new public/private key pair and self-signed certificate are created, in two separate .pem files.
+// certFilename and privateKeyFilename are the file names (with full path) of the files.
+// The entire path to the directory the certificate file is in will be created if it doesn't exist.
+// Any generated key pair will be an ecdsa key using the "P256" curve, suitable for signing JWT tokens using the
+// ES256 algorithm; unlike ed25519 this algorithm is supported by third parties that verify OpenID Connect tokens.
+func GenerateECDSASigningKeyAndCertificate(
+	certFilename CertificateFile,
+	privateKeyFilename PrivateKeyFile,
+	organization string,
+) (bool, error) {
+	return GenerateCertificateIfNotExists(
+		certFilename,
+		privateKeyFilename,
+		"", // no host required in certificate
+		organization,
+		false,
+	)
+}
+
 // GenerateCertificateIfNotExists checks whether a certificate and corresponding private key exist, and if not
 // then a new public/private key pair and self-signed certificate are created, in two separate .pem files.
 // certFilename and privateKeyFilename are the file names (with full path) of the files.
@@ -475,3 +495,31 @@ func GetEd25519PrivateKeyFromPEM(pemData string) (crypto.PrivateKey, error) {
 
 	return privateKey, nil
 }
+
+// GetECDSAPrivateKeyFromPEM extracts the private key from the provided PEM-encoded data,
+// and checks that it is an ecdsa private key.
+// The key is returned as an object, ready to be used for signing.
+func GetECDSAPrivateKeyFromPEM(pemData string) (*ecdsa.PrivateKey, error) {
+	// Find the first block of data in the PEM
+	const privateKeyPEMBlockType = "PRIVATE KEY"
+	privateKeyBlock, _ := pem.Decode([]byte(pemData))
+	if privateKeyBlock == nil {
+		return nil, gerror.NewErrValidationFailed("private key PEM data does not contain a valid PEM block")
+	}
+	if privateKeyBlock.Type != privateKeyPEMBlockType {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Private key PEM data contains unknown block type: %s (should be %s)",
+			privateKeyBlock.Type, privateKeyPEMBlockType))
+	}
+
+	// Parse the private key
+	privateKey, err := x509.ParsePKCS8PrivateKey(privateKeyBlock.Bytes)
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed("error parsing private key").Wrap(err)
+	}
+	ecdsaPrivateKey, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not a valid ECDSA private key")
+	}
+
+	return ecdsaPrivateKey, nil
+}
@@ -63,6 +63,26 @@ func GetDockerDynamicEndpoint(nonDockerEndpoint Endpoint) (Endpoint, error) {
 	return Endpoint(dockerEndpoint), nil
 }
 
+// GetPodmanDynamicEndpoint returns the endpoint URL that dynamic jobs running in Podman containers should use
+// to contact the dynamic API, based on the endpoint that non-docker based jobs should use.
+// Podman has no docker0 bridge interface (and rootless Podman has no bridge on the host at all), but
+// provides a special name for getting to the real host on all operating systems.
+func GetPodmanDynamicEndpoint(nonDockerEndpoint Endpoint) (Endpoint, error) {
+	nonDockerURL, err := url.Parse(nonDockerEndpoint.String())
+	if err != nil {
+		return "", err
+	}
+	if !IsLocalhost(nonDockerURL.Hostname()) {
+		// If not local server then podman jobs should use the same endpoint as non-docker jobs
+		return nonDockerEndpoint, nil
+	}
+	podmanEndpoint := "http://host.containers.internal"
+	if nonDockerURL.Port() != "" {
+		podmanEndpoint += ":" + nonDockerURL.Port()
+	}
+	return Endpoint(podmanEndpoint), nil
+}
+
 // IsLocalhost returns true if the specified host name or IP address refers to the local network interface,
 // i.e. "localhost", "127.0.0.1" or equivalent IPv6 address.
 func IsLocalhost(host string) bool {
@@ -2,6 +2,7 @@ package gerror
 
 import (
 	"fmt"
+	"sort"
 )
 
 const (
@@ -128,12 +129,18 @@ func (e *Error) withDetail(audience Audience, key DetailKey, value interface{})
 func makeErrorText(message string, details Details, inner error) string {
 	var detailsStr string
 	if len(details) > 0 {
+		// Sort the details so the error text is the same each time
+		keys := make([]string, 0, len(details))
+		for k := range details {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
 		detailsStr = " ["
-		for k, v := range details {
+		for _, k := range keys {
 			if detailsStr == " [" {
-				detailsStr += fmt.Sprintf("%s=%s", k, v.value)
+				detailsStr += fmt.Sprintf("%s=%v", k, details[DetailKey(k)].value)
 			} else {
-				detailsStr += fmt.Sprintf(", %s=%s", k, v.value)
+				detailsStr += fmt.Sprintf(", %s=%v", k, details[DetailKey(k)].value)
 			}
 		}
 		detailsStr += "]"
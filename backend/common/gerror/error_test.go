@@ -25,6 +25,16 @@ func TestError(t *testing.T) {
 	require.Equal(t, "foo already exists", err.Message())
 }
 
+func TestLimitExceeded(t *testing.T) {
+	err := NewErrLimitExceeded("too many steps in job 'build'", "max_steps_per_job", 20, 25).EDetail(JobDetailKey, "build")
+	require.Equal(t, "too many steps in job 'build' [count=25, job=build, limit=max_steps_per_job, max=20]", err.Error())
+	require.True(t, IsValidationFailed(err))
+	require.True(t, IsLimitExceeded(fmt.Errorf("error parsing: %w", err)))
+	require.Equal(t, 20, ToLimitExceeded(err).Details()[MaxDetailKey].Value())
+	require.False(t, IsLimitExceeded(NewErrValidationFailed("invalid")))
+	require.False(t, IsLimitExceeded(errors.New("too many")))
+}
+
 func TestMultiError(t *testing.T) {
 	// Compose a multierror with our tested error in the middle
 	var results *multierror.Error
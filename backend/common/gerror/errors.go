@@ -2,7 +2,9 @@ package gerror
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 )
 
 const (
@@ -15,10 +17,15 @@ const (
 	ErrCodeOptimisticLockFailed  Code = "OptimisticLockFailed"
 	ErrCodeAccountDisabled       Code = "AccountDisabled"
 	ErrCodeRunnerDisabled        Code = "RunnerDisabled"
+	ErrCodeRunnerUnhealthy       Code = "RunnerUnhealthy"
 	ErrCodeTimeout               Code = "Timeout"
 	ErrCodeLogClosed             Code = "LogClosed"
 	ErrHttpOperationFailed       Code = "HttpOperationFailed"
 	ErrArtifactUploadFailed      Code = "ArtifactUploadFailed"
+	ErrCodeArtifactQuarantined   Code = "ArtifactQuarantined"
+	ErrCodeStepTimedOut          Code = "StepTimedOut"
+	ErrCodeLogExpired            Code = "LogExpired"
+	ErrCodeQuotaExceeded         Code = "QuotaExceeded"
 )
 
 // ToError locates an Error in the provided error chain and returns it if it
@@ -64,6 +71,18 @@ func IsArtifactUploadFailed(err error) bool {
 	return ToArtifactUploadFailed(err) != nil
 }
 
+func NewErrArtifactQuarantined(message string) Error {
+	return NewError(message, AudienceExternal, ErrCodeArtifactQuarantined, http.StatusForbidden, nil)
+}
+
+func ToArtifactQuarantined(err error) *Error {
+	return ToError(err, ErrCodeArtifactQuarantined)
+}
+
+func IsArtifactQuarantined(err error) bool {
+	return ToArtifactQuarantined(err) != nil
+}
+
 func NewErrValidationFailed(message string) Error {
 	return NewError(message, AudienceExternal, ErrCodeValidationFailed, http.StatusBadRequest, nil)
 }
@@ -76,6 +95,45 @@ func IsValidationFailed(err error) bool {
 	return ToValidationFailed(err) != nil
 }
 
+const (
+	// LimitDetailKey is the detail naming the limit a limit exceeded error reports, e.g. 'max_steps_per_job'.
+	LimitDetailKey DetailKey = "limit"
+	// MaxDetailKey is the detail holding the maximum allowed by the limit.
+	MaxDetailKey DetailKey = "max"
+	// CountDetailKey is the detail holding the count found, which exceeds the maximum.
+	CountDetailKey DetailKey = "count"
+	// WorkflowDetailKey is the detail naming the workflow at fault, if any.
+	WorkflowDetailKey DetailKey = "workflow"
+	// JobDetailKey is the detail naming the job at fault, if any.
+	JobDetailKey DetailKey = "job"
+)
+
+// NewErrLimitExceeded returns a validation failed error reporting that a build exceeds one of the server's
+// limits, with the name of the limit, the maximum allowed and the count found as details so that clients
+// can tell what needs to be split up. Add the workflow and job at fault as details if the limit applies to them.
+func NewErrLimitExceeded(message string, limit string, max int, count int) Error {
+	return NewErrValidationFailed(message).
+		EDetail(LimitDetailKey, limit).
+		EDetail(MaxDetailKey, max).
+		EDetail(CountDetailKey, count)
+}
+
+// ToLimitExceeded returns the limit exceeded error in the provided error chain, or nil if there isn't one.
+func ToLimitExceeded(err error) *Error {
+	gErr := ToValidationFailed(err)
+	if gErr == nil {
+		return nil
+	}
+	if _, ok := gErr.Details()[LimitDetailKey]; !ok {
+		return nil
+	}
+	return gErr
+}
+
+func IsLimitExceeded(err error) bool {
+	return ToLimitExceeded(err) != nil
+}
+
 func NewErrInvalidQueryParameter(message string) Error {
 	return NewError(message, AudienceExternal, ErrCodeInvalidQueryParameter, http.StatusBadRequest, nil)
 }
@@ -118,6 +176,24 @@ func IsRunnerDisabled(err error) bool {
 	return ToRunnerDisabled(err) != nil
 }
 
+func NewErrCodeRunnerUnhealthy() Error {
+	return NewError(
+		"Runner unhealthy; Jobs will not be run until the runner reports that it is healthy again",
+		AudienceExternal,
+		ErrCodeRunnerUnhealthy,
+		http.StatusNotFound,
+		nil,
+	)
+}
+
+func ToRunnerUnhealthy(err error) *Error {
+	return ToError(err, ErrCodeRunnerUnhealthy)
+}
+
+func IsRunnerUnhealthy(err error) bool {
+	return ToRunnerUnhealthy(err) != nil
+}
+
 func NewErrUnauthorized(message string) Error {
 	return NewError(message, AudienceExternal, ErrCodeUnauthorized, http.StatusUnauthorized, nil)
 }
@@ -181,6 +257,18 @@ func IsTimeout(err error) bool {
 	return ToTimeout(err) != nil
 }
 
+func NewErrStepTimedOut(timeout time.Duration) Error {
+	return NewError(fmt.Sprintf("Step timed out after %s", timeout), AudienceExternal, ErrCodeStepTimedOut, http.StatusRequestTimeout, nil)
+}
+
+func ToStepTimedOut(err error) *Error {
+	return ToError(err, ErrCodeStepTimedOut)
+}
+
+func IsStepTimedOut(err error) bool {
+	return ToStepTimedOut(err) != nil
+}
+
 func NewErrLogClosed() Error {
 	// http.StatusGone "Indicates that the resource requested was previously in use but is no longer available
 	// and will not be available again". This seems appropriate when trying to write to a closed log.
@@ -194,3 +282,28 @@ func ToLogClosed(err error) *Error {
 func IsLogClosed(err error) bool {
 	return ToLogClosed(err) != nil
 }
+
+func NewErrLogExpired() Error {
+	// http.StatusGone since the log's data was deliberately deleted and will not be available again
+	return NewError("Log data has been deleted by the log retention policy", AudienceExternal, ErrCodeLogExpired, http.StatusGone, nil)
+}
+
+func ToLogExpired(err error) *Error {
+	return ToError(err, ErrCodeLogExpired)
+}
+
+func IsLogExpired(err error) bool {
+	return ToLogExpired(err) != nil
+}
+
+func NewErrQuotaExceeded(message string) Error {
+	return NewError(message, AudienceExternal, ErrCodeQuotaExceeded, http.StatusForbidden, nil)
+}
+
+func ToQuotaExceeded(err error) *Error {
+	return ToError(err, ErrCodeQuotaExceeded)
+}
+
+func IsQuotaExceeded(err error) bool {
+	return ToQuotaExceeded(err) != nil
+}
@@ -28,6 +28,11 @@ type Artifact struct {
 	HashType HashType `json:"hash_type" db:"artifact_hash_type"`
 	// Hash is the hex-encoded hash of the artifact data. This may be set later if the hash is not known yet.
 	Hash string `json:"hash" db:"artifact_hash"`
+	// SHA256 is the hex-encoded SHA-256 hash of the artifact data, or empty if not known yet.
+	SHA256 string `json:"sha256" db:"artifact_sha256"`
+	// Signature is the base64-encoded signature over the artifact's SHA-256 hash made with the server's artifact
+	// signing key, or empty if the artifact was not signed.
+	Signature string `json:"signature" db:"artifact_signature"`
 	// Size of the artifact file in bytes.
 	Size uint64 `json:"size" db:"artifact_size"`
 	// Mime type of the artifact, or empty if not known.
@@ -37,6 +42,10 @@ type Artifact struct {
 	// NOTE: If sealed is false it doesn't necessarily mean no data has been uploaded to the blob store yet, and so
 	// we must still verify that the backing data is deleted before garbage collecting unsealed artifact files.
 	Sealed bool `json:"sealed" db:"artifact_sealed"`
+	// ScanStatus records the outcome of scanning the artifact data for viruses and other malware.
+	ScanStatus ArtifactScanStatus `json:"scan_status" db:"artifact_scan_status"`
+	// ScanResult is the name of the threat found by the scanner if the artifact is infected, otherwise empty.
+	ScanResult string `json:"scan_result" db:"artifact_scan_result"`
 	ArtifactData
 }
 
@@ -124,5 +133,8 @@ func (m *Artifact) Validate() error {
 	if filepath.IsAbs(m.Path) {
 		result = multierror.Append(result, errors.New("error path must be a relative path"))
 	}
+	if !m.ScanStatus.Valid() {
+		result = multierror.Append(result, errors.New("error scan status is invalid"))
+	}
 	return result.ErrorOrNil()
 }
@@ -0,0 +1,33 @@
+package models
+
+const (
+	// ArtifactBundleFormatZip bundles artifacts into a zip archive.
+	ArtifactBundleFormatZip ArtifactBundleFormat = "zip"
+	// ArtifactBundleFormatTarGz bundles artifacts into a gzip compressed tar archive.
+	ArtifactBundleFormatTarGz ArtifactBundleFormat = "tar.gz"
+)
+
+// ArtifactBundleFormat is the archive format used when downloading several artifacts as a single file.
+type ArtifactBundleFormat string
+
+func (f ArtifactBundleFormat) Valid() bool {
+	return f == ArtifactBundleFormatZip ||
+		f == ArtifactBundleFormatTarGz
+}
+
+func (f ArtifactBundleFormat) String() string {
+	return string(f)
+}
+
+// FileExtension returns the file extension (without a leading dot) for archives in this format.
+func (f ArtifactBundleFormat) FileExtension() string {
+	return string(f)
+}
+
+// ContentType returns the MIME type for archives in this format.
+func (f ArtifactBundleFormat) ContentType() string {
+	if f == ArtifactBundleFormatTarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
@@ -0,0 +1,32 @@
+package models
+
+const (
+	// ArtifactScanStatusNotScanned means the artifact has not been (and will not be) scanned, because artifact
+	// scanning was not enabled when the artifact was uploaded.
+	ArtifactScanStatusNotScanned ArtifactScanStatus = "not-scanned"
+	// ArtifactScanStatusPending means the artifact is waiting to be scanned.
+	ArtifactScanStatusPending ArtifactScanStatus = "pending"
+	// ArtifactScanStatusClean means the artifact was scanned and no threats were found.
+	ArtifactScanStatusClean ArtifactScanStatus = "clean"
+	// ArtifactScanStatusInfected means the artifact was scanned and a threat was found. The artifact is
+	// quarantined and its data can no longer be downloaded.
+	ArtifactScanStatusInfected ArtifactScanStatus = "infected"
+)
+
+type ArtifactScanStatus string
+
+func (s ArtifactScanStatus) Valid() bool {
+	return s == ArtifactScanStatusNotScanned ||
+		s == ArtifactScanStatusPending ||
+		s == ArtifactScanStatusClean ||
+		s == ArtifactScanStatusInfected
+}
+
+func (s ArtifactScanStatus) String() string {
+	return string(s)
+}
+
+// IsQuarantined returns true if an artifact with this scan status must not be distributed.
+func (s ArtifactScanStatus) IsQuarantined() bool {
+	return s == ArtifactScanStatusInfected
+}
@@ -3,10 +3,15 @@ package models
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 )
 
+// ArtifactPathWildcard can be given as the artifact name in an artifact path to select all artifacts
+// produced by a job.
+const ArtifactPathWildcard = "*"
+
 type ArtifactSearchPaginator interface {
 	HasNext() bool
 	Next(ctx context.Context) ([]*Artifact, error)
@@ -31,9 +36,55 @@ func NewArtifactSearch() *ArtifactSearch {
 	return &ArtifactSearch{Pagination: NewPagination(DefaultPaginationLimit, nil)}
 }
 
+// SetPath sets the workflow, job name and group name to search for from an artifact path, in the
+// format 'workflow.job.artifact', or 'job.artifact' for a job in the default workflow. The artifact
+// name can be '*' to search for all artifacts produced by the job.
+func (m *ArtifactSearch) SetPath(path string) error {
+	parts := strings.Split(path, ".")
+	if len(parts) == 2 {
+		parts = append([]string{""}, parts...)
+	}
+	if len(parts) != 3 {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Artifact path %q must be in the format 'workflow.job.artifact' or 'job.artifact'", path))
+	}
+	workflow, jobName, groupName := ResourceName(parts[0]), ResourceName(parts[1]), ResourceName(parts[2])
+	if workflow != "" {
+		if err := workflow.Validate(); err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Artifact path %q has an invalid workflow: %s", path, err))
+		}
+	}
+	if err := jobName.Validate(); err != nil {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Artifact path %q has an invalid job name: %s", path, err))
+	}
+	m.Workflow = &workflow
+	m.JobName = &jobName
+	m.GroupName = nil
+	if groupName != ArtifactPathWildcard {
+		if err := groupName.Validate(); err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Artifact path %q has an invalid artifact name: %s", path, err))
+		}
+		m.GroupName = &groupName
+	}
+	return nil
+}
+
+// Validate checks the names being searched for, if any. A job name is not required since searches are always
+// limited to a single build, so leaving out the job searches all artifacts produced by the build.
 func (m *ArtifactSearch) Validate() error {
-	if m.JobName == nil {
-		return gerror.NewErrValidationFailed("Job name must be specified")
+	if m.Workflow != nil && *m.Workflow != "" {
+		if err := m.Workflow.Validate(); err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Invalid workflow: %s", err))
+		}
+	}
+	if m.JobName != nil {
+		if err := m.JobName.Validate(); err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Invalid job name: %s", err))
+		}
+	}
+	if m.GroupName != nil {
+		if err := m.GroupName.Validate(); err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Invalid group name: %s", err))
+		}
 	}
 	return nil
 }
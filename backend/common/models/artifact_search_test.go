@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactSearchSetPath(t *testing.T) {
+	search := NewArtifactSearch()
+	require.NoError(t, search.SetPath("deploy.build.binaries"))
+	require.Equal(t, ResourceName("deploy"), *search.Workflow)
+	require.Equal(t, ResourceName("build"), *search.JobName)
+	require.Equal(t, ResourceName("binaries"), *search.GroupName)
+
+	// Two parts address a job in the default workflow
+	require.NoError(t, search.SetPath("build.binaries"))
+	require.Equal(t, ResourceName(""), *search.Workflow)
+	require.Equal(t, ResourceName("build"), *search.JobName)
+	require.Equal(t, ResourceName("binaries"), *search.GroupName)
+
+	require.NoError(t, search.SetPath("deploy.build.*"))
+	require.Equal(t, ResourceName("deploy"), *search.Workflow)
+	require.Nil(t, search.GroupName)
+
+	for _, path := range []string{"", "build", "a.b.c.d", "deploy..binaries", "deploy.build.", "de ploy.build.x"} {
+		require.Error(t, NewArtifactSearch().SetPath(path), "path %q", path)
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import "fmt"
+
+// ArtifactSignatureAlgorithmEd25519 is the algorithm used to sign artifact hashes.
+const ArtifactSignatureAlgorithmEd25519 = "Ed25519"
+
+// ArtifactVerification is the result of checking that an artifact's data has not been modified since it was uploaded.
+type ArtifactVerification struct {
+	ArtifactID ArtifactID `json:"artifact_id"`
+	// SHA256 is the hex-encoded SHA-256 hash recorded for the artifact when it was uploaded.
+	SHA256 string `json:"sha256"`
+	// CalculatedSHA256 is the hex-encoded SHA-256 hash of the artifact data currently held by the server.
+	CalculatedSHA256 string `json:"calculated_sha256"`
+	// DataValid is true if the artifact data currently held by the server matches the hash recorded on upload.
+	DataValid bool `json:"data_valid"`
+	// Signed is true if the artifact's hash was signed with the server's artifact signing key on upload.
+	Signed bool `json:"signed"`
+	// SignatureValid is true if the artifact is signed and the signature matches the artifact's recorded hash.
+	SignatureValid bool `json:"signature_valid"`
+	// SignatureAlgorithm is the algorithm used to sign the artifact's hash, or empty if the artifact is not signed.
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty"`
+	// Signature is the base64-encoded signature over ArtifactSignaturePayload, or empty if the artifact is not signed.
+	Signature string `json:"signature,omitempty"`
+	// SigningCertificate is the PEM-encoded certificate holding the public key that can be used to check
+	// the signature, or empty if artifact signing is not enabled on the server.
+	SigningCertificate string `json:"signing_certificate,omitempty"`
+}
+
+// Verified returns true if the artifact data is intact and, if the artifact was signed, its signature is valid.
+func (m *ArtifactVerification) Verified() bool {
+	return m.DataValid && (!m.Signed || m.SignatureValid)
+}
+
+// ArtifactSignaturePayload returns the message that is signed for an artifact. The artifact ID is included so
+// that a signature can't be copied from one artifact to another.
+func ArtifactSignaturePayload(artifactID ArtifactID, sha256 string) []byte {
+	return []byte(fmt.Sprintf("buildbeaver-artifact:%s:sha256:%s", artifactID, sha256))
+}
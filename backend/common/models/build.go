@@ -51,6 +51,15 @@ type Build struct {
 	Error *Error `json:"error" db:"build_error"`
 	// Opts that are applied to this build.
 	Opts BuildOptions `json:"opts" db:"build_opts"`
+	// ClonedFromBuildID is the ID of the build whose recorded jobs were cloned to create this build,
+	// or empty if this build was not cloned from another build.
+	ClonedFromBuildID BuildID `json:"cloned_from_build_id" db:"build_cloned_from_build_id"`
+	// Warnings lists problems found with the build definition that didn't prevent the build from running,
+	// such as use of a deprecated version or deprecated fields.
+	Warnings BuildWarnings `json:"warnings" db:"build_warnings"`
+	// Priority determines the order in which jobs from different builds are handed to runners. Jobs from
+	// builds with a higher priority run first; jobs from builds with the same priority run oldest first.
+	Priority int `json:"priority" db:"build_priority"`
 }
 
 func (m *Build) GetKind() ResourceKind {
@@ -135,5 +144,8 @@ func (m *Build) Validate() error {
 	if !m.Status.Valid() {
 		result = multierror.Append(result, errors.New("error status is invalid"))
 	}
+	if err := ValidateBuildPriority(m.Priority); err != nil {
+		result = multierror.Append(result, err)
+	}
 	return result.ErrorOrNil()
 }
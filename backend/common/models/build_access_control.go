@@ -15,7 +15,15 @@ var BuildUpdateOperation = &Operation{
 	ResourceKind: BuildResourceKind,
 }
 
+// BuildPrioritizeOperation allows the priority of a build that has not finished to be changed, so its
+// jobs run ahead of (or behind) jobs from other builds.
+var BuildPrioritizeOperation = &Operation{
+	Name:         "prioritize",
+	ResourceKind: BuildResourceKind,
+}
+
 var BuildAccessControlOperations = []*Operation{
 	BuildReadOperation,
 	BuildUpdateOperation,
+	BuildPrioritizeOperation,
 }
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const BuildCoverageResourceKind ResourceKind = "build-coverage"
+
+type BuildCoverageID struct {
+	ResourceID
+}
+
+func NewBuildCoverageID() BuildCoverageID {
+	return BuildCoverageID{ResourceID: NewResourceID(BuildCoverageResourceKind)}
+}
+
+// BuildCoverage combines the code coverage from every coverage report uploaded by a build, and compares it
+// against the coverage of the latest build of the target branch (the base branch of the pull request being
+// built, otherwise the repo's default branch). It is recalculated each time a coverage report is added.
+type BuildCoverage struct {
+	ID        BuildCoverageID `json:"id" goqu:"skipupdate" db:"build_coverage_id"`
+	CreatedAt Time            `json:"created_at" goqu:"skipupdate" db:"build_coverage_created_at"`
+	UpdatedAt Time            `json:"updated_at" db:"build_coverage_updated_at"`
+	BuildID   BuildID         `json:"build_id" db:"build_coverage_build_id"`
+	RepoID    RepoID          `json:"repo_id" db:"build_coverage_repo_id"`
+	// Ref is the git ref the build is for, used to find coverage for the target branch of later builds.
+	Ref string `json:"ref" db:"build_coverage_ref"`
+	// Reports is the number of coverage reports in the build.
+	Reports int `json:"reports" db:"build_coverage_reports"`
+	// LinesCovered is the number of lines executed at least once, across all reports.
+	LinesCovered int `json:"lines_covered" db:"build_coverage_lines_covered"`
+	// LinesValid is the number of executable lines, across all reports.
+	LinesValid int `json:"lines_valid" db:"build_coverage_lines_valid"`
+	// Files is the coverage of each source file, combined across all reports.
+	Files FileCoverages `json:"files" db:"build_coverage_files"`
+	// TargetRef is the ref of the branch coverage is compared against.
+	TargetRef string `json:"target_ref" db:"build_coverage_target_ref"`
+	// TargetBuildID is the ID of the build on the target branch that coverage is compared against, or
+	// nil if no build on the target branch has reported coverage.
+	TargetBuildID *BuildID `json:"target_build_id" db:"build_coverage_target_build_id"`
+	// TargetLinesCovered is the number of lines covered by the target build.
+	TargetLinesCovered int `json:"target_lines_covered" db:"build_coverage_target_lines_covered"`
+	// TargetLinesValid is the number of executable lines in the target build.
+	TargetLinesValid int `json:"target_lines_valid" db:"build_coverage_target_lines_valid"`
+}
+
+func NewBuildCoverage(now Time, build *Build) *BuildCoverage {
+	return &BuildCoverage{
+		ID:        NewBuildCoverageID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		BuildID:   build.ID,
+		RepoID:    build.RepoID,
+		Ref:       build.Ref,
+	}
+}
+
+// Percent returns the percentage of lines covered by the build.
+func (m *BuildCoverage) Percent() float64 {
+	return CoveragePercent(m.LinesCovered, m.LinesValid)
+}
+
+// TargetPercent returns the percentage of lines covered by the target build, or nil if there is no target build.
+func (m *BuildCoverage) TargetPercent() *float64 {
+	if m.TargetBuildID == nil {
+		return nil
+	}
+	percent := CoveragePercent(m.TargetLinesCovered, m.TargetLinesValid)
+	return &percent
+}
+
+// Delta returns the change in coverage compared to the target build, in percentage points, or nil if there
+// is no target build.
+func (m *BuildCoverage) Delta() *float64 {
+	targetPercent := m.TargetPercent()
+	if targetPercent == nil {
+		return nil
+	}
+	delta := m.Percent() - *targetPercent
+	return &delta
+}
+
+// SetTarget records the coverage of the target build to compare against, or clears it if target is nil.
+func (m *BuildCoverage) SetTarget(targetRef string, target *BuildCoverage) {
+	m.TargetRef = targetRef
+	if target == nil {
+		m.TargetBuildID = nil
+		m.TargetLinesCovered, m.TargetLinesValid = 0, 0
+		return
+	}
+	targetBuildID := target.BuildID
+	m.TargetBuildID = &targetBuildID
+	m.TargetLinesCovered, m.TargetLinesValid = target.LinesCovered, target.LinesValid
+}
+
+func (m *BuildCoverage) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *BuildCoverage) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *BuildCoverage) GetKind() ResourceKind {
+	return BuildCoverageResourceKind
+}
+
+func (m *BuildCoverage) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if !m.BuildID.Valid() {
+		result = multierror.Append(result, errors.New("error build id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	return result.ErrorOrNil()
+}
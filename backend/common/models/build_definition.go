@@ -3,6 +3,13 @@ package models
 type BuildDefinition struct {
 	// Jobs is the set of jobs within the build.
 	Jobs []JobDefinition
+	// Parameters declares the parameters that can be supplied when triggering the build.
+	Parameters []*BuildParameterDefinition
+	// Version is the version of the build definition schema the build definition was parsed with.
+	Version string
+	// Warnings lists problems with the build definition that didn't prevent it from being parsed,
+	// such as use of a deprecated version or deprecated fields.
+	Warnings BuildWarnings
 }
 
 type JobDefinition struct {
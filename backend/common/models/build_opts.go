@@ -13,6 +13,45 @@ type BuildOptions struct {
 	// NodesToRun contains zero or more jobs and steps to run. If no nodes are specified
 	// then all jobs and steps will be run.
 	NodesToRun []NodeFQN `json:"nodes_to_run"`
+	// SkipDependencies is true if only the jobs named in NodesToRun should be run, without the jobs they depend on.
+	// Dependencies on jobs that are not run are removed from the build, so anything those jobs would have produced
+	// (such as artifacts) must already be available. Only applies if every node in NodesToRun names a job.
+	SkipDependencies bool `json:"skip_dependencies,omitempty"`
+	// IgnoreSkipMarkers is true if the build should run even if the commit message contains a marker such as
+	// [skip ci], or matches the repo's skip pattern. Builds explicitly requested by a user should set this.
+	IgnoreSkipMarkers bool `json:"ignore_skip_markers"`
+	// WorkflowOutputStubs provides values for the outputs of workflows that should not be run. Stubbed
+	// workflows are never started; workflows waiting on their outputs receive the stubbed values instead.
+	WorkflowOutputStubs WorkflowOutputStubs `json:"workflow_output_stubs,omitempty"`
+	// Priority overrides the priority the repo's settings would give the build, if set.
+	Priority *int `json:"priority,omitempty"`
+	// Parameters contains the value of each parameter declared in the build definition. When triggering a build
+	// this holds the values supplied for the build; once the build is queued it also holds default values for
+	// parameters that weren't supplied.
+	Parameters BuildParameters `json:"parameters,omitempty"`
+}
+
+// WorkflowOutputStubs maps workflow names to a set of stubbed output values for that workflow, keyed by
+// output name. Each value is the JSON encoding of the output.
+type WorkflowOutputStubs map[ResourceName]map[string]json.RawMessage
+
+// Validate checks each stubbed workflow name is valid and each stubbed value is valid JSON.
+func (m WorkflowOutputStubs) Validate() error {
+	for workflowName, outputs := range m {
+		err := workflowName.Validate()
+		if err != nil {
+			return fmt.Errorf("error invalid stubbed workflow name: %w", err)
+		}
+		for outputName, value := range outputs {
+			if outputName == "" {
+				return fmt.Errorf("error stubbed output for workflow %q has no name", workflowName)
+			}
+			if !json.Valid(value) {
+				return fmt.Errorf("error stubbed output %q for workflow %q is not valid JSON", outputName, workflowName)
+			}
+		}
+	}
+	return nil
 }
 
 func (m *BuildOptions) Scan(src interface{}) error {
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BuildParametersEnvVarName is the environment variable that jobs receive all of their build's parameter values
+// in, as a JSON object mapping parameter names to values.
+const BuildParametersEnvVarName = "BB_BUILD_PARAMETERS"
+
+// BuildParameterEnvVarPrefix is prepended to the upper-cased name of each build parameter to make the name of the
+// environment variable jobs receive the parameter's value in, e.g. 'BB_PARAM_TARGET' for parameter 'target'.
+const BuildParameterEnvVarPrefix = "BB_PARAM_"
+
+// buildParameterNameRegex allows parameter names that can also be used as part of an environment variable name.
+var buildParameterNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// MaxBuildParameterValueLength is the maximum length of a build parameter value, in bytes.
+const MaxBuildParameterValueLength = 4096
+
+type BuildParameterType string
+
+func (t BuildParameterType) String() string {
+	return string(t)
+}
+
+const (
+	BuildParameterTypeString  BuildParameterType = "string"
+	BuildParameterTypeNumber  BuildParameterType = "number"
+	BuildParameterTypeBoolean BuildParameterType = "boolean"
+	// BuildParameterTypeChoice parameters must have one of the values listed in the parameter's Choices.
+	BuildParameterTypeChoice BuildParameterType = "choice"
+)
+
+func (t BuildParameterType) Validate() error {
+	switch t {
+	case BuildParameterTypeString, BuildParameterTypeNumber, BuildParameterTypeBoolean, BuildParameterTypeChoice:
+		return nil
+	default:
+		return fmt.Errorf("error unknown parameter type %q; expected string, number, boolean or choice", t)
+	}
+}
+
+// BuildParameterDefinition declares a parameter that can be supplied when a build is triggered.
+type BuildParameterDefinition struct {
+	// Name of the parameter. Names can contain letters, numbers and underscores, and are case-sensitive.
+	Name string `json:"name"`
+	// Type of values the parameter accepts.
+	Type BuildParameterType `json:"type"`
+	// Description of the parameter, for people triggering builds.
+	Description string `json:"description,omitempty"`
+	// Default value for the parameter if no value is supplied, or nil if a value must always be supplied.
+	Default *string `json:"default,omitempty"`
+	// Choices lists the values allowed for a choice parameter.
+	Choices []string `json:"choices,omitempty"`
+}
+
+// Validate checks the parameter's name and type, and that any default value is valid for the parameter.
+func (m *BuildParameterDefinition) Validate() error {
+	if !buildParameterNameRegex.MatchString(m.Name) {
+		return fmt.Errorf("error invalid parameter name %q: must contain only letters, numbers and underscores, and not start with a number", m.Name)
+	}
+	err := m.Type.Validate()
+	if err != nil {
+		return err
+	}
+	if m.Type == BuildParameterTypeChoice && len(m.Choices) == 0 {
+		return fmt.Errorf("error choice parameter %q must list its choices", m.Name)
+	}
+	if m.Type != BuildParameterTypeChoice && len(m.Choices) > 0 {
+		return fmt.Errorf("error only choice parameters can list choices, but parameter %q has type %s", m.Name, m.Type)
+	}
+	if m.Default != nil {
+		_, err := m.ParseValue(*m.Default)
+		if err != nil {
+			return fmt.Errorf("error invalid default value: %w", err)
+		}
+	}
+	return nil
+}
+
+// ParseValue checks that value is valid for the parameter, and returns it in canonical form: booleans are
+// returned as 'true' or 'false', and all other values are returned unchanged.
+func (m *BuildParameterDefinition) ParseValue(value string) (string, error) {
+	if len(value) > MaxBuildParameterValueLength {
+		return "", fmt.Errorf("error value for parameter %q is too long (%d bytes, maximum is %d)", m.Name, len(value), MaxBuildParameterValueLength)
+	}
+	switch m.Type {
+	case BuildParameterTypeNumber:
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("error parameter %q must be a number but found %q", m.Name, value)
+		}
+	case BuildParameterTypeBoolean:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("error parameter %q must be true or false but found %q", m.Name, value)
+		}
+		value = strconv.FormatBool(parsed)
+	case BuildParameterTypeChoice:
+		found := false
+		for _, choice := range m.Choices {
+			if value == choice {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("error parameter %q must be one of %s but found %q", m.Name, strings.Join(m.Choices, ", "), value)
+		}
+	}
+	return value, nil
+}
+
+// BuildParameters maps build parameter names to the values supplied for them.
+type BuildParameters map[string]string
+
+// Names returns the names of the parameters in sorted order.
+func (m BuildParameters) Names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks each parameter name is valid and each value is not too long.
+func (m BuildParameters) Validate() error {
+	for name, value := range m {
+		if !buildParameterNameRegex.MatchString(name) {
+			return fmt.Errorf("error invalid parameter name %q: must contain only letters, numbers and underscores, and not start with a number", name)
+		}
+		if len(value) > MaxBuildParameterValueLength {
+			return fmt.Errorf("error value for parameter %q is too long (%d bytes, maximum is %d)", name, len(value), MaxBuildParameterValueLength)
+		}
+	}
+	return nil
+}
+
+// EnvVarName returns the name of the environment variable jobs receive the value of the named parameter in.
+func (m BuildParameters) EnvVarName(name string) string {
+	return BuildParameterEnvVarPrefix + strings.ToUpper(name)
+}
+
+// ResolveBuildParameters checks the parameter values supplied when triggering a build against the parameters
+// declared in the build definition, and returns the value of every declared parameter. Parameters that were
+// not supplied take their default value. Returns an error if a value is supplied for a parameter that is not
+// declared, if a value is invalid for its parameter, or if no value is supplied for a parameter without a default.
+// Returns nil if no parameters are declared or supplied.
+func ResolveBuildParameters(definitions []*BuildParameterDefinition, supplied BuildParameters) (BuildParameters, error) {
+	if len(definitions) == 0 && len(supplied) == 0 {
+		return nil, nil
+	}
+	definitionsByName := make(map[string]*BuildParameterDefinition, len(definitions))
+	for _, definition := range definitions {
+		definitionsByName[definition.Name] = definition
+	}
+	for _, name := range supplied.Names() {
+		if _, ok := definitionsByName[name]; !ok {
+			return nil, fmt.Errorf("error value supplied for parameter %q, but the build definition does not declare it", name)
+		}
+	}
+	resolved := make(BuildParameters, len(definitions))
+	for _, definition := range definitions {
+		value, ok := supplied[definition.Name]
+		if !ok {
+			if definition.Default == nil {
+				return nil, fmt.Errorf("error no value supplied for parameter %q, which has no default", definition.Name)
+			}
+			value = *definition.Default
+		}
+		parsed, err := definition.ParseValue(value)
+		if err != nil {
+			return nil, err
+		}
+		resolved[definition.Name] = parsed
+	}
+	return resolved, nil
+}
+
+// ParseBuildParameter parses a parameter supplied in the form 'name=value', as used on the command line and
+// in SCM comment commands.
+func ParseBuildParameter(str string) (name string, value string, err error) {
+	name, value, found := strings.Cut(str, "=")
+	if !found || name == "" {
+		return "", "", fmt.Errorf("error parameter must be in the format NAME=VALUE but found %q", str)
+	}
+	if !buildParameterNameRegex.MatchString(name) {
+		return "", "", fmt.Errorf("error invalid parameter name %q: must contain only letters, numbers and underscores, and not start with a number", name)
+	}
+	return name, value, nil
+}
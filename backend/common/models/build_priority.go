@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MinBuildPriority is the lowest priority that can be given to a build.
+	MinBuildPriority = -100
+	// MaxBuildPriority is the highest priority that can be given to a build.
+	MaxBuildPriority = 100
+)
+
+// ValidateBuildPriority returns an error if priority is outside the range of priorities that can be given to a build.
+func ValidateBuildPriority(priority int) error {
+	if priority < MinBuildPriority || priority > MaxBuildPriority {
+		return fmt.Errorf("error priority must be between %d and %d", MinBuildPriority, MaxBuildPriority)
+	}
+	return nil
+}
+
+// BuildPrioritySettings configures the priority given to new builds for a repo. Jobs from builds with a higher
+// priority are handed to runners before jobs from builds with a lower priority, regardless of how long the
+// lower priority jobs have been queued. These settings are configured per repo.
+type BuildPrioritySettings struct {
+	// Default is the priority given to builds for refs that don't match any of the Refs rules.
+	Default int `json:"default"`
+	// Refs gives builds for specific refs (e.g. release branches or tags) a different priority.
+	// The first rule whose pattern matches the build's ref is used.
+	Refs []*RefBuildPriority `json:"refs"`
+}
+
+// RefBuildPriority gives builds for refs matching a pattern a priority.
+type RefBuildPriority struct {
+	// Pattern is a regular expression matched against the full git ref, e.g. '^refs/tags/v.*$'.
+	Pattern string `json:"pattern"`
+	// Priority is given to builds for refs matching the pattern.
+	Priority int `json:"priority"`
+}
+
+func (m *BuildPrioritySettings) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m BuildPrioritySettings) Value() (driver.Value, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+func (m *BuildPrioritySettings) Validate() error {
+	var result *multierror.Error
+	err := ValidateBuildPriority(m.Default)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	for _, rule := range m.Refs {
+		if rule == nil {
+			result = multierror.Append(result, errors.New("error ref priority rule must not be null"))
+			continue
+		}
+		err := RefPatterns{rule.Pattern}.Validate()
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+		err = ValidateBuildPriority(rule.Priority)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("error invalid priority for ref pattern %q: %w", rule.Pattern, err))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// IsEmpty returns true if the settings give every build the normal priority.
+func (m *BuildPrioritySettings) IsEmpty() bool {
+	return m.Default == 0 && len(m.Refs) == 0
+}
+
+// PriorityForRef returns the priority to give a new build for the specified ref.
+func (m *BuildPrioritySettings) PriorityForRef(ref string) int {
+	for _, rule := range m.Refs {
+		if _, ok := (RefPatterns{rule.Pattern}).Match(ref); ok {
+			return rule.Priority
+		}
+	}
+	return m.Default
+}
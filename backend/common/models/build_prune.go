@@ -0,0 +1,13 @@
+package models
+
+// BuildPruneCriteria selects the builds to be removed by a data retention policy. Only builds that have
+// finished are ever selected.
+type BuildPruneCriteria struct {
+	// CreatedBefore selects builds created before this time.
+	CreatedBefore Time
+	// KeepLast is the number of most recent builds to keep for each repo regardless of age, counting only
+	// builds that have not been soft-deleted. Zero keeps no builds.
+	KeepLast int
+	// IncludeDeleted selects builds that have already been soft-deleted as well as builds that have not.
+	IncludeDeleted bool
+}
@@ -0,0 +1,251 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const BuildRuleSetResourceKind ResourceKind = "build-rule-set"
+
+// SkipMarkers are the markers that prevent a commit from being built when they appear anywhere in its
+// message. Markers are matched case-insensitively.
+var SkipMarkers = []string{"[skip ci]", "[ci skip]"}
+
+type BuildRuleSetID struct {
+	ResourceID
+}
+
+func NewBuildRuleSetID() BuildRuleSetID {
+	return BuildRuleSetID{ResourceID: NewResourceID(BuildRuleSetResourceKind)}
+}
+
+func BuildRuleSetIDFromResourceID(id ResourceID) BuildRuleSetID {
+	return BuildRuleSetID{ResourceID: id}
+}
+
+// RefPatterns is a list of regular expressions matched against full git refs, e.g. '^refs/heads/release/.*$'.
+type RefPatterns []string
+
+func (m *RefPatterns) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m RefPatterns) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+func (m RefPatterns) Validate() error {
+	var result *multierror.Error
+	for _, pattern := range m {
+		_, err := regexp.Compile(pattern)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("error parsing ref pattern %q: %w", pattern, err))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// Match returns the first pattern that matches ref, or false if no pattern matches.
+func (m RefPatterns) Match(ref string) (string, bool) {
+	for _, pattern := range m {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue // patterns are validated before being stored
+		}
+		if re.MatchString(ref) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// BuildRuleSet controls which pushes and pull requests for a repo will trigger a build. Each repo has at most
+// one rule set; repos without a rule set build every branch and tag that is pushed.
+type BuildRuleSet struct {
+	ID        BuildRuleSetID `json:"id" goqu:"skipupdate" db:"build_rule_set_id"`
+	RepoID    RepoID         `json:"repo_id" goqu:"skipupdate" db:"build_rule_set_repo_id"`
+	CreatedAt Time           `json:"created_at" goqu:"skipupdate" db:"build_rule_set_created_at"`
+	UpdatedAt Time           `json:"updated_at" db:"build_rule_set_updated_at"`
+	ETag      ETag           `json:"etag" db:"build_rule_set_etag" hash:"ignore"`
+	// AllowRefs is an optional list of regular expressions; if set, only refs matching at least one of them are built.
+	AllowRefs RefPatterns `json:"allow_refs" db:"build_rule_set_allow_refs"`
+	// DenyRefs is an optional list of regular expressions; refs matching any of them are never built.
+	// Deny rules take precedence over allow rules.
+	DenyRefs RefPatterns `json:"deny_refs" db:"build_rule_set_deny_refs"`
+	// BuildTags is true if pushing a tag should trigger a build.
+	BuildTags bool `json:"build_tags" db:"build_rule_set_build_tags"`
+	// PullRequestsOnly is true if pushes to branches other than the repo's default branch should only be
+	// built when they are part of a pull request.
+	PullRequestsOnly bool `json:"pull_requests_only" db:"build_rule_set_pull_requests_only"`
+	// SkipPattern is an optional regular expression; commits whose message matches it are not built,
+	// in addition to commits whose message contains one of the standard SkipMarkers.
+	SkipPattern string `json:"skip_pattern" db:"build_rule_set_skip_pattern"`
+	// CommentCommands lists the commands that can be posted as comments on pull requests to queue builds.
+	// If nil then DefaultCommentCommands are available; if empty then comment commands are disabled.
+	CommentCommands CommentCommands `json:"comment_commands" db:"build_rule_set_comment_commands"`
+}
+
+func NewBuildRuleSet(
+	now Time,
+	repoID RepoID,
+	allowRefs RefPatterns,
+	denyRefs RefPatterns,
+	buildTags bool,
+	pullRequestsOnly bool,
+	skipPattern string,
+	commentCommands CommentCommands,
+) *BuildRuleSet {
+	return &BuildRuleSet{
+		ID:               NewBuildRuleSetID(),
+		RepoID:           repoID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		AllowRefs:        allowRefs,
+		DenyRefs:         denyRefs,
+		BuildTags:        buildTags,
+		PullRequestsOnly: pullRequestsOnly,
+		SkipPattern:      skipPattern,
+		CommentCommands:  commentCommands,
+	}
+}
+
+func (m *BuildRuleSet) GetKind() ResourceKind {
+	return BuildRuleSetResourceKind
+}
+
+func (m *BuildRuleSet) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *BuildRuleSet) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *BuildRuleSet) GetParentID() ResourceID {
+	return m.RepoID.ResourceID
+}
+
+func (m *BuildRuleSet) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *BuildRuleSet) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *BuildRuleSet) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *BuildRuleSet) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+// ShouldBuild returns true if a push of the specified ref should trigger a build. pullRequest is true if the
+// push is part of a pull request. If the ref should not be built then a description of the reason is returned.
+func (m *BuildRuleSet) ShouldBuild(ref string, defaultBranch string, pullRequest bool) (bool, string) {
+	if pattern, ok := m.DenyRefs.Match(ref); ok {
+		return false, fmt.Sprintf("ref matches deny rule %q", pattern)
+	}
+	if len(m.AllowRefs) > 0 {
+		if _, ok := m.AllowRefs.Match(ref); !ok {
+			return false, "ref does not match any allow rule"
+		}
+	}
+	if strings.HasPrefix(ref, "refs/tags/") && !m.BuildTags {
+		return false, "tags are not built"
+	}
+	if strings.HasPrefix(ref, "refs/heads/") && m.PullRequestsOnly && !pullRequest {
+		branch := strings.TrimPrefix(ref, "refs/heads/")
+		if branch != strings.TrimPrefix(defaultBranch, "refs/heads/") {
+			return false, "branches other than the default branch are only built for pull requests"
+		}
+	}
+	return true, ""
+}
+
+// SkipCommit returns true if a commit with the specified message should not be built, because the message
+// contains one of the standard SkipMarkers or matches the rule set's SkipPattern. If the commit should be
+// skipped then a description of the reason is returned.
+func (m *BuildRuleSet) SkipCommit(message string) (bool, string) {
+	lowerMessage := strings.ToLower(message)
+	for _, marker := range SkipMarkers {
+		if strings.Contains(lowerMessage, marker) {
+			return true, fmt.Sprintf("commit message contains %q", marker)
+		}
+	}
+	if m.SkipPattern != "" {
+		re, err := regexp.Compile(m.SkipPattern)
+		if err == nil && re.MatchString(message) { // the pattern is validated before being stored
+			return true, fmt.Sprintf("commit message matches skip pattern %q", m.SkipPattern)
+		}
+	}
+	return false, ""
+}
+
+// GetCommentCommands returns the commands that can be posted as comments on the repo's pull requests.
+func (m *BuildRuleSet) GetCommentCommands() CommentCommands {
+	if m.CommentCommands == nil {
+		return DefaultCommentCommands
+	}
+	return m.CommentCommands
+}
+
+func (m *BuildRuleSet) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	err := m.AllowRefs.Validate()
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("error validating allow refs: %w", err))
+	}
+	err = m.DenyRefs.Validate()
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("error validating deny refs: %w", err))
+	}
+	if m.SkipPattern != "" {
+		_, err = regexp.Compile(m.SkipPattern)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("error parsing skip pattern %q: %w", m.SkipPattern, err))
+		}
+	}
+	err = m.CommentCommands.Validate()
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("error validating comment commands: %w", err))
+	}
+	return result.ErrorOrNil()
+}
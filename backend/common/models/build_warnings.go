@@ -0,0 +1,37 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// BuildWarnings is a list of human-readable warnings about a build definition.
+type BuildWarnings []string
+
+func (m *BuildWarnings) Scan(src interface{}) error {
+	if src == nil {
+		*m = nil
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m BuildWarnings) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
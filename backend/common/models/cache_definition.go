@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// CacheDefinition is generated from jobs in the build config.
+// It declares that one or more directories in the job's workspace should be saved to the build cache at the end
+// of the job, and restored at the start of later runs of the job with the same cache key (e.g. a Go module cache
+// keyed by the contents of go.sum).
+type CacheDefinition struct {
+	// Name uniquely identifies the cache within the job, and is shared by all jobs in the repo that declare
+	// a cache with the same name.
+	Name ResourceName `json:"name"`
+	// Paths contains one or more paths relative to the checkout directory to save and restore.
+	// These paths will be globbed, so that each path may identify one or more actual files or directories.
+	Paths []string `json:"paths"`
+	// KeyCommands contains zero or more shell commands whose output is hashed to produce the cache key.
+	// If no commands are specified the job's fingerprint is used as the cache key.
+	KeyCommands Commands `json:"key_commands"`
+	// Preset is the preset this cache was created from, or empty if the cache was declared in full.
+	// The runner sets the environment variables the preset requires to point tools at the cache's paths.
+	Preset CachePreset `json:"preset"`
+}
+
+// ApplyPreset fills in the cache's name, paths and key commands from the cache's preset, for each of
+// these that has not already been set. Does nothing if the cache has no preset.
+func (m *CacheDefinition) ApplyPreset() error {
+	if m.Preset == "" {
+		return nil
+	}
+	preset, ok := cachePresets[m.Preset]
+	if !ok {
+		return fmt.Errorf("error unknown cache preset %q", m.Preset)
+	}
+	if m.Name == "" {
+		m.Name = ResourceName(m.Preset)
+	}
+	if len(m.Paths) == 0 {
+		m.Paths = append([]string{}, preset.paths...)
+	}
+	if len(m.KeyCommands) == 0 {
+		m.KeyCommands = append(Commands{}, preset.keyCommands...)
+	}
+	return nil
+}
+
+func (m *CacheDefinition) Validate() error {
+	var result *multierror.Error
+	if err := m.Name.Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if len(m.Paths) == 0 {
+		result = multierror.Append(result, errors.New("Cache must specify at least one path"))
+	}
+	if m.Preset != "" {
+		if _, ok := cachePresets[m.Preset]; !ok {
+			result = multierror.Append(result, fmt.Errorf("Unknown cache preset %q", m.Preset))
+		}
+	}
+	for _, path := range m.Paths {
+		if filepath.IsAbs(path) {
+			result = multierror.Append(result, fmt.Errorf("Cache path %q must be relative to the checkout directory", path))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+type CacheDefinitions []*CacheDefinition
+
+func (m *CacheDefinitions) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m CacheDefinitions) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
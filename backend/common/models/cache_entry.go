@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const CacheEntryResourceKind ResourceKind = "cache-entry"
+
+type CacheEntryID struct {
+	ResourceID
+}
+
+func NewCacheEntryID() CacheEntryID {
+	return CacheEntryID{ResourceID: NewResourceID(CacheEntryResourceKind)}
+}
+
+func CacheEntryIDFromResourceID(id ResourceID) CacheEntryID {
+	return CacheEntryID{ResourceID: id}
+}
+
+func ParseCacheEntryID(str string) (CacheEntryID, error) {
+	resourceID, err := ParseResourceID(str)
+	if err != nil {
+		return CacheEntryID{}, fmt.Errorf("error parsing Cache Entry ID: %w", err)
+	}
+	return CacheEntryIDFromResourceID(resourceID), nil
+}
+
+// CacheEntry records a tarball of cached files saved by a job, stored in the blob store. Entries are shared by
+// all jobs in a repo that declare a cache with the same name, and are looked up by cache key.
+type CacheEntry struct {
+	ID        CacheEntryID `json:"id" goqu:"skipupdate" db:"cache_entry_id"`
+	CreatedAt Time         `json:"created_at" goqu:"skipupdate" db:"cache_entry_created_at"`
+	// LastUsedAt is the time the entry was last saved or restored, used to evict the least recently used
+	// entries once the caches for a repo grow too large.
+	LastUsedAt Time `json:"last_used_at" db:"cache_entry_last_used_at"`
+	// RepoID is the ID of the repo the cache belongs to.
+	RepoID RepoID `json:"repo_id" db:"cache_entry_repo_id"`
+	// JobID is the ID of the job that saved the entry.
+	JobID JobID `json:"job_id" db:"cache_entry_job_id"`
+	// Name is the name of the cache, from the job's cache definition.
+	Name ResourceName `json:"name" db:"cache_entry_name"`
+	// Key is the cache key calculated by the runner when the entry was saved.
+	Key string `json:"key" db:"cache_entry_key"`
+	// Size of the tarball in bytes.
+	Size uint64 `json:"size" db:"cache_entry_size"`
+	// HashType is the type of hashing algorithm used to hash the tarball.
+	HashType HashType `json:"hash_type" db:"cache_entry_hash_type"`
+	// Hash is the hex-encoded hash of the tarball.
+	Hash string `json:"hash" db:"cache_entry_hash"`
+}
+
+func NewCacheEntry(now Time, job *Job, name ResourceName, key string) *CacheEntry {
+	return &CacheEntry{
+		ID:         NewCacheEntryID(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		RepoID:     job.RepoID,
+		JobID:      job.ID,
+		Name:       name,
+		Key:        key,
+	}
+}
+
+func (m *CacheEntry) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *CacheEntry) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *CacheEntry) GetKind() ResourceKind {
+	return CacheEntryResourceKind
+}
+
+func (m *CacheEntry) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.LastUsedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error last used at must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if !m.JobID.Valid() {
+		result = multierror.Append(result, errors.New("error job id must be set"))
+	}
+	if err := m.Name.Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if m.Key == "" {
+		result = multierror.Append(result, errors.New("error key must be set"))
+	}
+	if !m.HashType.Valid() {
+		result = multierror.Append(result, errors.New("error hash type must be set"))
+	}
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,70 @@
+package models
+
+import "path/filepath"
+
+// CachePreset identifies a predefined cache for a common kind of dependency directory. Presets save every
+// pipeline from having to declare the same paths and key commands, and from having to point its tools at
+// a directory inside the workspace so the directory can be cached.
+type CachePreset string
+
+const (
+	// CachePresetGoModules caches the Go module cache, keyed by the contents of every go.sum file in the repo.
+	CachePresetGoModules CachePreset = "go-modules"
+	// CachePresetNodeModules caches the node_modules directory at the root of the repo, keyed by the
+	// contents of the repo's lock files.
+	CachePresetNodeModules CachePreset = "node-modules"
+)
+
+func (p CachePreset) String() string {
+	return string(p)
+}
+
+// Environment returns the environment variables that must be set in the job's steps for tools to use the
+// preset's paths, given the absolute path of the workspace.
+func (p CachePreset) Environment(workspaceDir string) map[string]string {
+	preset, ok := cachePresets[p]
+	if !ok {
+		return nil
+	}
+	env := make(map[string]string, len(preset.pathVars)+len(preset.vars))
+	for name, path := range preset.pathVars {
+		env[name] = filepath.Join(workspaceDir, filepath.FromSlash(path))
+	}
+	for name, value := range preset.vars {
+		env[name] = value
+	}
+	return env
+}
+
+type cachePreset struct {
+	paths       []string
+	keyCommands Commands
+	// pathVars are environment variables whose values are paths relative to the workspace.
+	pathVars map[string]string
+	vars     map[string]string
+}
+
+// goModCachePath is the path relative to the workspace that Go is told to use for its module cache.
+const goModCachePath = ".cache/go-mod"
+
+var cachePresets = map[CachePreset]cachePreset{
+	CachePresetGoModules: {
+		paths: []string{goModCachePath},
+		keyCommands: Commands{
+			Command("find . -name go.sum -not -path './" + goModCachePath + "/*' | sort | while read -r f; do cat \"$f\"; done"),
+		},
+		pathVars: map[string]string{
+			"GOMODCACHE": goModCachePath,
+		},
+		vars: map[string]string{
+			// Go makes its module cache read-only by default, which stops the workspace from being cleaned up
+			"GOFLAGS": "-modcacherw",
+		},
+	},
+	CachePresetNodeModules: {
+		paths: []string{"node_modules"},
+		keyCommands: Commands{
+			Command("cat yarn.lock package-lock.json pnpm-lock.yaml 2>/dev/null || true"),
+		},
+	},
+}
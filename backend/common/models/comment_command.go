@@ -0,0 +1,189 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// DefaultCommentCommands are the comment commands available for repos whose build rule set doesn't configure any.
+// Both queue a new build of the pull request that was commented on.
+var DefaultCommentCommands = CommentCommands{
+	{Name: "build"},
+	{Name: "rebuild"},
+}
+
+// commentCommandNameRegex allows command names that are easy to type in a comment.
+var commentCommandNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// CommentCommand is a slash-command that can be posted as a comment on a pull request to queue a build of it,
+// e.g. '/deploy target=production'.
+type CommentCommand struct {
+	// Name of the command, which is invoked by commenting '/<name>' or '/bb <name>'.
+	Name string `json:"name"`
+	// Workflows lists the workflows to run, along with any workflows they depend on. If empty then the whole
+	// build is run.
+	Workflows []string `json:"workflows,omitempty"`
+	// Parameters supplies values for parameters declared in the build definition. Values for other parameters
+	// can be supplied as name=value arguments to the command, but the values set here can't be overridden.
+	Parameters BuildParameters `json:"parameters,omitempty"`
+	// Operation is the operation the commenter must be authorized to perform on the repo in order to run the
+	// command, in the form 'name:resource-kind'. Defaults to 'create:build'.
+	Operation string `json:"operation,omitempty"`
+}
+
+// GetOperation returns the operation the commenter must be authorized to perform on the repo.
+func (m *CommentCommand) GetOperation() (*Operation, error) {
+	if m.Operation == "" {
+		return BuildCreateOperation, nil
+	}
+	for _, operation := range RepoOverridableOperations {
+		if operation.String() == m.Operation {
+			return operation, nil
+		}
+	}
+	return nil, fmt.Errorf("error unknown repo operation %q", m.Operation)
+}
+
+// GetNodesToRun returns the FQNs of the workflows the command runs, or nil if the whole build is run.
+func (m *CommentCommand) GetNodesToRun() ([]NodeFQN, error) {
+	var fqns []NodeFQN
+	for _, workflow := range m.Workflows {
+		fqn := NodeFQN{}
+		err := fqn.ScanWorkflowOnly(workflow)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing workflow %q: %w", workflow, err)
+		}
+		fqns = append(fqns, fqn)
+	}
+	return fqns, nil
+}
+
+// MakeBuildOptions returns the options for a build queued by the command, given the arguments that followed the
+// command in the comment. Each argument must supply the value of a parameter in the form name=value.
+func (m *CommentCommand) MakeBuildOptions(args []string) (*BuildOptions, error) {
+	nodesToRun, err := m.GetNodesToRun()
+	if err != nil {
+		return nil, err
+	}
+	params := make(BuildParameters, len(args)+len(m.Parameters))
+	for _, arg := range args {
+		name, value, err := ParseBuildParameter(arg)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m.Parameters[name]; ok {
+			return nil, fmt.Errorf("error parameter %q is set by the %s command and can't be overridden", name, m.Name)
+		}
+		params[name] = value
+	}
+	for name, value := range m.Parameters {
+		params[name] = value
+	}
+	// Builds requested by a user should run regardless of any [skip ci] marker
+	return &BuildOptions{
+		IgnoreSkipMarkers: true,
+		NodesToRun:        nodesToRun,
+		Parameters:        params,
+	}, nil
+}
+
+func (m *CommentCommand) Validate() error {
+	var result *multierror.Error
+	if !commentCommandNameRegex.MatchString(m.Name) {
+		result = multierror.Append(result, fmt.Errorf("error invalid command name %q: must contain only lower-case letters, numbers, underscores and hyphens, and start with a letter", m.Name))
+	}
+	_, err := m.GetNodesToRun()
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	err = m.Parameters.Validate()
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	_, err = m.GetOperation()
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
+
+// CommentCommands is the list of commands that can be posted as comments on a repo's pull requests.
+type CommentCommands []*CommentCommand
+
+func (m *CommentCommands) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m CommentCommands) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+func (m CommentCommands) Validate() error {
+	var result *multierror.Error
+	names := make(map[string]bool, len(m))
+	for i, command := range m {
+		if command == nil {
+			result = multierror.Append(result, fmt.Errorf("error command %d is empty", i))
+			continue
+		}
+		err := command.Validate()
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("error validating command %q: %w", command.Name, err))
+		}
+		if names[command.Name] {
+			result = multierror.Append(result, fmt.Errorf("error duplicate command name %q", command.Name))
+		}
+		names[command.Name] = true
+	}
+	return result.ErrorOrNil()
+}
+
+// Find returns the command with the specified name, or false if there is no such command.
+func (m CommentCommands) Find(name string) (*CommentCommand, bool) {
+	for _, command := range m {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return nil, false
+}
+
+// ParseCommentCommand checks whether the first line of a comment invokes a command, in the form
+// '/<name> [args...]' or '/bb <name> [args...]', and if so returns the command name and its arguments.
+func ParseCommentCommand(comment string) (name string, args []string, isCommand bool) {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(comment), "\n")
+	fields := strings.Fields(firstLine)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil, false
+	}
+	if fields[0] == "/bb" {
+		if len(fields) < 2 {
+			return "", nil, false
+		}
+		return fields[1], fields[2:], true
+	}
+	return strings.TrimPrefix(fields[0], "/"), fields[1:], true
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommentCommand(t *testing.T) {
+	tests := []struct {
+		comment string
+		name    string
+		args    []string
+	}{
+		{"/rebuild", "rebuild", []string{}},
+		{"  /deploy target=production  \nPlease deploy this", "deploy", []string{"target=production"}},
+		{"/bb build a=1 b=2", "build", []string{"a=1", "b=2"}},
+	}
+	for _, test := range tests {
+		name, args, isCommand := ParseCommentCommand(test.comment)
+		require.True(t, isCommand, test.comment)
+		require.Equal(t, test.name, name, test.comment)
+		require.Equal(t, test.args, args, test.comment)
+	}
+	for _, comment := range []string{"", "LGTM", "/bb", "Please\n/rebuild"} {
+		_, _, isCommand := ParseCommentCommand(comment)
+		require.False(t, isCommand, comment)
+	}
+}
+
+func TestCommentCommandBuildOptions(t *testing.T) {
+	command := &CommentCommand{
+		Name:       "deploy",
+		Workflows:  []string{"deploy"},
+		Parameters: BuildParameters{"environment": "production"},
+		Operation:  RepoUpdateOperation.String(),
+	}
+	require.NoError(t, command.Validate())
+	operation, err := command.GetOperation()
+	require.NoError(t, err)
+	require.Equal(t, RepoUpdateOperation, operation)
+
+	opts, err := command.MakeBuildOptions([]string{"version=1.2"})
+	require.NoError(t, err)
+	require.True(t, opts.IgnoreSkipMarkers)
+	require.Equal(t, []NodeFQN{NewNodeFQNForWorkflow("deploy")}, opts.NodesToRun)
+	require.Equal(t, BuildParameters{"environment": "production", "version": "1.2"}, opts.Parameters)
+
+	// Parameters set by the command can't be overridden
+	_, err = command.MakeBuildOptions([]string{"environment=staging"})
+	require.Error(t, err)
+	_, err = command.MakeBuildOptions([]string{"not-a-parameter"})
+	require.Error(t, err)
+
+	invalid := CommentCommands{
+		{Name: "Deploy"},
+		{Name: "rebuild", Operation: "launch:missiles"},
+		{Name: "test"},
+		{Name: "test"},
+	}
+	require.Error(t, invalid.Validate())
+	require.NoError(t, DefaultCommentCommands.Validate())
+}
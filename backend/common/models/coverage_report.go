@@ -0,0 +1,213 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const CoverageReportResourceKind ResourceKind = "coverage-report"
+
+// CoverageArtifactGroupName is the name of the artifact group that coverage reports must be uploaded to in
+// order to be ingested. Any artifact in a group with this name is parsed as a Cobertura XML, LCOV or Go
+// cover profile once it has been uploaded.
+const CoverageArtifactGroupName ResourceName = "coverage"
+
+type CoverageReportID struct {
+	ResourceID
+}
+
+func NewCoverageReportID() CoverageReportID {
+	return CoverageReportID{ResourceID: NewResourceID(CoverageReportResourceKind)}
+}
+
+func CoverageReportIDFromResourceID(id ResourceID) CoverageReportID {
+	return CoverageReportID{ResourceID: id}
+}
+
+type CoverageFormat string
+
+const (
+	// CoverageFormatCobertura is the Cobertura XML format, as produced by coverage.py, JaCoCo converters etc.
+	CoverageFormatCobertura CoverageFormat = "cobertura"
+	// CoverageFormatLCOV is the LCOV tracefile format, as produced by lcov, c8, nyc etc.
+	CoverageFormatLCOV CoverageFormat = "lcov"
+	// CoverageFormatGoCoverProfile is the output of 'go test -coverprofile'.
+	CoverageFormatGoCoverProfile CoverageFormat = "go-cover-profile"
+)
+
+func (f CoverageFormat) Valid() bool {
+	return f == CoverageFormatCobertura ||
+		f == CoverageFormatLCOV ||
+		f == CoverageFormatGoCoverProfile
+}
+
+func (f CoverageFormat) String() string {
+	return string(f)
+}
+
+// CoveragePercent returns the percentage of lines that were covered, or zero if there were no lines.
+func CoveragePercent(linesCovered int, linesValid int) float64 {
+	if linesValid <= 0 {
+		return 0
+	}
+	return float64(linesCovered) * 100 / float64(linesValid)
+}
+
+// FileCoverage records the coverage of a single source file. For Go cover profiles statements are
+// counted rather than lines.
+type FileCoverage struct {
+	Path         string `json:"path"`
+	LinesCovered int    `json:"lines_covered"`
+	LinesValid   int    `json:"lines_valid"`
+}
+
+// FileCoverages is a list of file coverages, sorted by path.
+type FileCoverages []*FileCoverage
+
+func (m *FileCoverages) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m FileCoverages) Value() (driver.Value, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+// Totals returns the total number of lines covered and valid across all files.
+func (m FileCoverages) Totals() (linesCovered int, linesValid int) {
+	for _, file := range m {
+		linesCovered += file.LinesCovered
+		linesValid += file.LinesValid
+	}
+	return linesCovered, linesValid
+}
+
+// Merge combines the coverage of files in other with the coverage in m, returning a new list sorted by
+// path. Line-level data isn't kept, so a file reported more than once is counted with its best coverage.
+func (m FileCoverages) Merge(other FileCoverages) FileCoverages {
+	byPath := make(map[string]*FileCoverage, len(m)+len(other))
+	for _, files := range []FileCoverages{m, other} {
+		for _, file := range files {
+			existing, ok := byPath[file.Path]
+			if !ok {
+				byPath[file.Path] = &FileCoverage{Path: file.Path, LinesCovered: file.LinesCovered, LinesValid: file.LinesValid}
+				continue
+			}
+			if file.LinesCovered > existing.LinesCovered {
+				existing.LinesCovered = file.LinesCovered
+			}
+			if file.LinesValid > existing.LinesValid {
+				existing.LinesValid = file.LinesValid
+			}
+		}
+	}
+	merged := make(FileCoverages, 0, len(byPath))
+	for _, file := range byPath {
+		merged = append(merged, file)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Path < merged[j].Path })
+	return merged
+}
+
+// CoverageReport records the totals parsed from a single coverage report artifact.
+type CoverageReport struct {
+	ID        CoverageReportID `json:"id" goqu:"skipupdate" db:"coverage_report_id"`
+	CreatedAt Time             `json:"created_at" goqu:"skipupdate" db:"coverage_report_created_at"`
+	// BuildID is the ID of the build the report was uploaded from.
+	BuildID BuildID `json:"build_id" db:"coverage_report_build_id"`
+	// JobID is the ID of the job the report was uploaded from.
+	JobID JobID `json:"job_id" db:"coverage_report_job_id"`
+	// RepoID is the ID of the repo the build ran for.
+	RepoID RepoID `json:"repo_id" db:"coverage_report_repo_id"`
+	// ArtifactID is the ID of the artifact containing the report.
+	ArtifactID ArtifactID `json:"artifact_id" db:"coverage_report_artifact_id"`
+	// Format is the format the report was parsed as.
+	Format CoverageFormat `json:"format" db:"coverage_report_format"`
+	// Files is the number of source files in the report.
+	Files int `json:"files" db:"coverage_report_files"`
+	// LinesCovered is the number of lines executed at least once.
+	LinesCovered int `json:"lines_covered" db:"coverage_report_lines_covered"`
+	// LinesValid is the number of executable lines.
+	LinesValid int `json:"lines_valid" db:"coverage_report_lines_valid"`
+}
+
+func NewCoverageReport(now Time, job *Job, artifactID ArtifactID, format CoverageFormat, files FileCoverages) *CoverageReport {
+	linesCovered, linesValid := files.Totals()
+	return &CoverageReport{
+		ID:           NewCoverageReportID(),
+		CreatedAt:    now,
+		BuildID:      job.BuildID,
+		JobID:        job.ID,
+		RepoID:       job.RepoID,
+		ArtifactID:   artifactID,
+		Format:       format,
+		Files:        len(files),
+		LinesCovered: linesCovered,
+		LinesValid:   linesValid,
+	}
+}
+
+// Percent returns the percentage of lines covered by the report.
+func (m *CoverageReport) Percent() float64 {
+	return CoveragePercent(m.LinesCovered, m.LinesValid)
+}
+
+func (m *CoverageReport) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *CoverageReport) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *CoverageReport) GetKind() ResourceKind {
+	return CoverageReportResourceKind
+}
+
+func (m *CoverageReport) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if !m.BuildID.Valid() {
+		result = multierror.Append(result, errors.New("error build id must be set"))
+	}
+	if !m.JobID.Valid() {
+		result = multierror.Append(result, errors.New("error job id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if !m.ArtifactID.Valid() {
+		result = multierror.Append(result, errors.New("error artifact id must be set"))
+	}
+	if !m.Format.Valid() {
+		result = multierror.Append(result, errors.New("error format must be cobertura, lcov or go-cover-profile"))
+	}
+	if m.LinesCovered < 0 || m.LinesValid < 0 || m.LinesCovered > m.LinesValid {
+		result = multierror.Append(result, errors.New("error lines covered must be between zero and lines valid"))
+	}
+	return result.ErrorOrNil()
+}
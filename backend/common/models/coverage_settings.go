@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// CoverageStatusName is the name of the custom status published to builds to report whether the build's
+// code coverage met the repo's coverage threshold.
+const CoverageStatusName ResourceName = "coverage"
+
+// CoverageSettings configures how code coverage reported by builds for a repo is checked once each build
+// finishes. These settings are configured per repo.
+type CoverageSettings struct {
+	// Threshold is the minimum acceptable coverage for a build, as a percentage between 0 and 100.
+	Threshold float64 `json:"threshold"`
+	// FailBuild is true if a build that otherwise succeeded should be failed when its coverage is below
+	// the threshold.
+	FailBuild bool `json:"fail_build"`
+	// PublishStatus is true if a separate 'coverage' status should be published to the build (and relayed
+	// to the SCM) reporting whether coverage met the threshold.
+	PublishStatus bool `json:"publish_status"`
+}
+
+func (m *CoverageSettings) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m CoverageSettings) Value() (driver.Value, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+func (m *CoverageSettings) Validate() error {
+	var result *multierror.Error
+	if m.Threshold < 0 || m.Threshold > 100 {
+		result = multierror.Append(result, errors.New("error threshold must be between 0 and 100"))
+	}
+	return result.ErrorOrNil()
+}
+
+// IsEmpty returns true if the settings don't ask for coverage to be checked.
+func (m *CoverageSettings) IsEmpty() bool {
+	return m.Threshold == 0 && !m.FailBuild && !m.PublishStatus
+}
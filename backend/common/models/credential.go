@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
@@ -9,6 +12,13 @@ import (
 
 const CredentialResourceKind ResourceKind = "credential"
 
+const (
+	// PersonalAccessTokenPrefix is the prefix of the string representation of every personal access token.
+	PersonalAccessTokenPrefix = "bbpat_"
+	// MaxPersonalAccessTokenNameLength is the maximum length of the name of a personal access token.
+	MaxPersonalAccessTokenNameLength = 100
+)
+
 type CredentialID struct {
 	ResourceID
 }
@@ -58,6 +68,17 @@ type Credential struct {
 	// GitHubUserID is the unique id of the GitHub user this credential
 	// belongs to, if the credential type is GitHub OAuth.
 	GitHubUserID *int64 `json:"github_user_id" db:"credential_github_user_id"`
+	// Name describes what a personal access token is for, if the credential type is personal access token.
+	Name string `json:"name" db:"credential_name"`
+	// Scopes limits the operations a personal access token can perform, if the credential type is
+	// personal access token.
+	Scopes TokenScopes `json:"scopes" db:"credential_scopes"`
+	// ExpiresAt is the time after which a personal access token can no longer be used, if the credential
+	// type is personal access token.
+	ExpiresAt *Time `json:"expires_at" db:"credential_expires_at"`
+	// LastUsedAt is approximately when a personal access token was last used to authenticate, or nil if it
+	// has never been used. Only maintained if the credential type is personal access token.
+	LastUsedAt *Time `json:"last_used_at" db:"credential_last_used_at"`
 }
 
 func NewSharedSecretCredential(now Time, identityID IdentityID, enabled bool, sharedSecret *SharedSecretToken) *Credential {
@@ -75,6 +96,31 @@ func NewSharedSecretCredential(now Time, identityID IdentityID, enabled bool, sh
 	}
 }
 
+func NewPersonalAccessTokenCredential(
+	now Time,
+	identityID IdentityID,
+	name string,
+	scopes TokenScopes,
+	expiresAt Time,
+	sharedSecret *SharedSecretToken,
+) *Credential {
+	salt, hash := sharedSecret.PrivateParts()
+	return &Credential{
+		ID:                     NewCredentialID(),
+		CreatedAt:              now,
+		UpdatedAt:              now,
+		IdentityID:             identityID,
+		Type:                   CredentialTypePersonalAccessToken,
+		IsEnabled:              true,
+		SharedSecretID:         sharedSecret.ID(),
+		SharedSecretSalt:       salt,
+		SharedSecretDataHashed: hash,
+		Name:                   name,
+		Scopes:                 scopes,
+		ExpiresAt:              &expiresAt,
+	}
+}
+
 func NewClientCertificateCredential(
 	now Time,
 	identityID IdentityID,
@@ -113,6 +159,11 @@ func NewGitHubCredential(now Time, identityID IdentityID, enabled bool, gitHubUs
 	}
 }
 
+// IsExpired returns true if the credential has an expiry date that is before now.
+func (m *Credential) IsExpired(now Time) bool {
+	return m.ExpiresAt != nil && !now.Before(m.ExpiresAt.Time)
+}
+
 func (m *Credential) GetKind() ResourceKind {
 	return CredentialResourceKind
 }
@@ -173,6 +224,28 @@ func (m *Credential) Validate() error {
 		if m.SharedSecretDataHashed == nil {
 			result = multierror.Append(result, gerror.NewErrValidationFailed("error shared secret hash must be set"))
 		}
+	case CredentialTypePersonalAccessToken:
+		if m.SharedSecretID == "" {
+			result = multierror.Append(result, gerror.NewErrValidationFailed("error shared secret id must be set"))
+		}
+		if m.SharedSecretSalt == nil {
+			result = multierror.Append(result, gerror.NewErrValidationFailed("error shared secret salt must be set"))
+		}
+		if m.SharedSecretDataHashed == nil {
+			result = multierror.Append(result, gerror.NewErrValidationFailed("error shared secret hash must be set"))
+		}
+		if strings.TrimSpace(m.Name) == "" {
+			result = multierror.Append(result, gerror.NewErrValidationFailed("error name must be set"))
+		}
+		if len(m.Name) > MaxPersonalAccessTokenNameLength {
+			result = multierror.Append(result, gerror.NewErrValidationFailed(fmt.Sprintf("error name must not exceed %d characters", MaxPersonalAccessTokenNameLength)))
+		}
+		if err := m.Scopes.Validate(); err != nil {
+			result = multierror.Append(result, gerror.NewErrValidationFailed(err.Error()))
+		}
+		if m.ExpiresAt == nil {
+			result = multierror.Append(result, gerror.NewErrValidationFailed("error expires at must be set"))
+		}
 	case CredentialTypeClientCertificate:
 		if m.ClientPublicKeyASN1Hash == "" {
 			result = multierror.Append(result, gerror.NewErrValidationFailed("error client public key ASN1 hash must be set"))
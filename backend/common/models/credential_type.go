@@ -12,12 +12,28 @@ const (
 	CredentialTypeGitHubOAuth       CredentialType = "github_oauth"
 	CredentialTypeClientCertificate CredentialType = "client_certificate"
 	CredentialTypeJWT               CredentialType = "jwt"
+	// CredentialTypePersonalAccessToken is a shared secret created by a user for API access, limited to a set
+	// of scopes and valid until an expiry date.
+	CredentialTypePersonalAccessToken CredentialType = "personal_access_token"
+	// CredentialTypeAnonymous is used for requests that supplied no credentials, and were assigned the
+	// anonymous identity. No credentials of this type are stored.
+	CredentialTypeAnonymous CredentialType = "anonymous"
+	// CredentialTypeSSO is used for requests authenticated with a session that was issued after logging in
+	// via a single sign-on identity provider. No credentials of this type are stored.
+	CredentialTypeSSO CredentialType = "sso"
+	// CredentialTypeSAML is used for requests authenticated with a session that was issued after logging in
+	// via a company's SAML identity provider. No credentials of this type are stored.
+	CredentialTypeSAML CredentialType = "saml"
+	// CredentialTypeLocal is used for requests to the server run by bb on the local machine, which are
+	// made as the identity that local builds run as. No credentials of this type are stored.
+	CredentialTypeLocal CredentialType = "local"
 )
 
 type CredentialType string
 
 func (s CredentialType) Valid() bool {
-	return s == CredentialTypeSharedSecret || s == CredentialTypeGitHubOAuth || s == CredentialTypeClientCertificate
+	return s == CredentialTypeSharedSecret || s == CredentialTypeGitHubOAuth || s == CredentialTypeClientCertificate ||
+		s == CredentialTypePersonalAccessToken
 }
 
 func (s CredentialType) String() string {
@@ -38,6 +54,8 @@ func (s *CredentialType) Scan(src interface{}) error {
 		*s = CredentialTypeClientCertificate
 	case string(CredentialTypeJWT):
 		*s = CredentialTypeJWT
+	case string(CredentialTypePersonalAccessToken):
+		*s = CredentialTypePersonalAccessToken
 	default:
 		return errors.Errorf("Unsupported credential type: %s", t)
 	}
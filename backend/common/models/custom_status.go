@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const CustomStatusResourceKind ResourceKind = "custom-status"
+
+type CustomStatusID struct {
+	ResourceID
+}
+
+func NewCustomStatusID() CustomStatusID {
+	return CustomStatusID{ResourceID: NewResourceID(CustomStatusResourceKind)}
+}
+
+func CustomStatusIDFromResourceID(id ResourceID) CustomStatusID {
+	return CustomStatusID{ResourceID: id}
+}
+
+type CustomStatusState string
+
+const (
+	// CustomStatusStatePending means the check the status describes has not yet reached a conclusion.
+	CustomStatusStatePending CustomStatusState = "pending"
+	// CustomStatusStateSuccess means the check the status describes passed.
+	CustomStatusStateSuccess CustomStatusState = "success"
+	// CustomStatusStateFailure means the check the status describes did not pass.
+	CustomStatusStateFailure CustomStatusState = "failure"
+	// CustomStatusStateError means the check the status describes could not be performed.
+	CustomStatusStateError CustomStatusState = "error"
+)
+
+func (s CustomStatusState) Valid() bool {
+	return s == CustomStatusStatePending ||
+		s == CustomStatusStateSuccess ||
+		s == CustomStatusStateFailure ||
+		s == CustomStatusStateError
+}
+
+func (s CustomStatusState) String() string {
+	return string(s)
+}
+
+// ToGitHubState returns the GitHub commit status state corresponding to the custom status state.
+func (s CustomStatusState) ToGitHubState() string {
+	switch s {
+	case CustomStatusStatePending:
+		return "pending"
+	case CustomStatusStateSuccess:
+		return "success"
+	case CustomStatusStateFailure:
+		return "failure"
+	default:
+		return "error"
+	}
+}
+
+// CustomStatus is an additional named status published to a build (typically by a dynamic job), such as the
+// outcome of a performance regression check. Each custom status is relayed to the SCM separately from the
+// overall build status, so branch protection rules can be based on it.
+// A build has at most one custom status with any given name; publishing a status with the same name again
+// replaces it.
+type CustomStatus struct {
+	ID        CustomStatusID `json:"id" goqu:"skipupdate" db:"custom_status_id"`
+	BuildID   BuildID        `json:"build_id" goqu:"skipupdate" db:"custom_status_build_id"`
+	CreatedAt Time           `json:"created_at" goqu:"skipupdate" db:"custom_status_created_at"`
+	UpdatedAt Time           `json:"updated_at" db:"custom_status_updated_at"`
+	ETag      ETag           `json:"etag" db:"custom_status_etag" hash:"ignore"`
+	// Name uniquely identifies the status within the build.
+	Name ResourceName `json:"name" goqu:"skipupdate" db:"custom_status_name"`
+	// State is the outcome reported by the status.
+	State CustomStatusState `json:"state" db:"custom_status_state"`
+	// Description is an optional human-readable summary of the status.
+	Description string `json:"description" db:"custom_status_description"`
+	// TargetURL is an optional link to more information about the status. If not set then the SCM will
+	// link to the build instead.
+	TargetURL string `json:"target_url" db:"custom_status_target_url"`
+}
+
+func NewCustomStatus(
+	now Time,
+	buildID BuildID,
+	name ResourceName,
+	state CustomStatusState,
+	description string,
+	targetURL string,
+) *CustomStatus {
+	return &CustomStatus{
+		ID:          NewCustomStatusID(),
+		BuildID:     buildID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Name:        name,
+		State:       state,
+		Description: description,
+		TargetURL:   targetURL,
+	}
+}
+
+func (m *CustomStatus) GetKind() ResourceKind {
+	return CustomStatusResourceKind
+}
+
+func (m *CustomStatus) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *CustomStatus) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *CustomStatus) GetParentID() ResourceID {
+	return m.BuildID.ResourceID
+}
+
+func (m *CustomStatus) GetName() ResourceName {
+	return m.Name
+}
+
+func (m *CustomStatus) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *CustomStatus) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *CustomStatus) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *CustomStatus) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+func (m *CustomStatus) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.BuildID.Valid() {
+		result = multierror.Append(result, errors.New("error build id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if err := m.Name.Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if !m.State.Valid() {
+		result = multierror.Append(result, fmt.Errorf("error state must be one of %q, %q, %q or %q",
+			CustomStatusStatePending, CustomStatusStateSuccess, CustomStatusStateFailure, CustomStatusStateError))
+	}
+	if m.TargetURL != "" {
+		u, err := url.Parse(m.TargetURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			result = multierror.Append(result, errors.New("error target url must be an absolute http or https URL"))
+		}
+	}
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,10 @@
+package models
+
+var CustomStatusCreateOperation = &Operation{
+	Name:         "create",
+	ResourceKind: CustomStatusResourceKind,
+}
+
+var CustomStatusAccessControlOperations = []*Operation{
+	CustomStatusCreateOperation,
+}
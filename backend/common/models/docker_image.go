@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDockerRegistry is the registry an image is pulled from when its reference doesn't name one.
+const DefaultDockerRegistry = "docker.io"
+
+// SplitDockerImageRegistry splits a Docker image reference (e.g. golang:1.19 or ghcr.io/org/image:tag) into
+// the registry the image is pulled from and the rest of the reference. As with Docker, the first component of
+// the reference names a registry only if it contains a '.' or ':', or is 'localhost'; otherwise the image is
+// pulled from DefaultDockerRegistry, and official images without a namespace are given the 'library' namespace.
+func SplitDockerImageRegistry(image string) (registry string, remainder string) {
+	n := strings.Index(image, "/")
+	if n >= 0 {
+		first := image[:n]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			registry, remainder = first, image[n+1:]
+			if registry == "index.docker.io" || registry == "registry-1.docker.io" {
+				registry = DefaultDockerRegistry
+			}
+			if registry == DefaultDockerRegistry && !strings.Contains(remainder, "/") {
+				remainder = "library/" + remainder
+			}
+			return registry, remainder
+		}
+		return DefaultDockerRegistry, image
+	}
+	return DefaultDockerRegistry, "library/" + image
+}
+
+// RewriteDockerImageRegistry returns the image reference with its registry replaced according to rewrites,
+// which maps the host of a registry (e.g. docker.io) to the host of a registry to use instead, optionally
+// followed by a path prefix (e.g. mirror.example.com:5000/dockerhub). Returns the image unchanged if there
+// is no rewrite for its registry.
+func RewriteDockerImageRegistry(image string, rewrites map[string]string) string {
+	if len(rewrites) == 0 || image == "" {
+		return image
+	}
+	registry, remainder := SplitDockerImageRegistry(image)
+	replacement, ok := rewrites[registry]
+	if !ok || replacement == "" {
+		return image
+	}
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(replacement, "/"), remainder)
+}
+
+// ParseDockerRegistryRewrites parses a comma separated list of registry=replacement pairs, as taken by
+// RewriteDockerImageRegistry.
+func ParseDockerRegistryRewrites(str string) (map[string]string, error) {
+	rewrites := make(map[string]string)
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("error invalid registry rewrite %q; expected registry=replacement", pair)
+		}
+		rewrites[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return rewrites, nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDockerImageRegistry(t *testing.T) {
+	tests := map[string][2]string{
+		"golang:1.19":                        {"docker.io", "library/golang:1.19"},
+		"bitnami/redis":                      {"docker.io", "bitnami/redis"},
+		"docker.io/golang":                   {"docker.io", "library/golang"},
+		"index.docker.io/bitnami/redis:7":    {"docker.io", "bitnami/redis:7"},
+		"ghcr.io/org/image:tag":              {"ghcr.io", "org/image:tag"},
+		"localhost/image":                    {"localhost", "image"},
+		"registry.local:5000/team/image@sha": {"registry.local:5000", "team/image@sha"},
+	}
+	for image, expected := range tests {
+		registry, remainder := SplitDockerImageRegistry(image)
+		require.Equal(t, expected[0], registry, image)
+		require.Equal(t, expected[1], remainder, image)
+	}
+}
+
+func TestRewriteDockerImageRegistry(t *testing.T) {
+	rewrites, err := ParseDockerRegistryRewrites("docker.io=mirror.example.com:5000/dockerhub/, ghcr.io=ghcr-mirror.example.com")
+	require.NoError(t, err)
+	require.Equal(t, "mirror.example.com:5000/dockerhub/library/golang:1.19", RewriteDockerImageRegistry("golang:1.19", rewrites))
+	require.Equal(t, "mirror.example.com:5000/dockerhub/bitnami/redis", RewriteDockerImageRegistry("bitnami/redis", rewrites))
+	require.Equal(t, "ghcr-mirror.example.com/org/image:tag", RewriteDockerImageRegistry("ghcr.io/org/image:tag", rewrites))
+	require.Equal(t, "quay.io/org/image", RewriteDockerImageRegistry("quay.io/org/image", rewrites))
+	require.Equal(t, "golang", RewriteDockerImageRegistry("golang", nil))
+
+	for _, invalid := range []string{"docker.io", "=mirror", "docker.io="} {
+		_, err := ParseDockerRegistryRewrites(invalid)
+		require.Error(t, err, invalid)
+	}
+}
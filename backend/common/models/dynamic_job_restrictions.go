@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// DynamicJobRestrictions limits what jobs can be added to a running build via the Dynamic API, using
+// the build-scoped identity issued to each job. These restrictions are configured per repo and limit the
+// damage that can be done by a compromised dynamic build job.
+type DynamicJobRestrictions struct {
+	// MaxJobs is the maximum total number of jobs a build may contain after dynamic jobs have been added.
+	// Zero means no repo-specific limit (the server-wide limit still applies).
+	MaxJobs int `json:"max_jobs"`
+	// AllowedWorkflows is the list of workflows that dynamic jobs may be added to. If empty then
+	// dynamic jobs may be added to any workflow.
+	AllowedWorkflows []ResourceName `json:"allowed_workflows"`
+	// ForbiddenLabels is a list of runner labels that dynamic jobs must not request via runs-on.
+	ForbiddenLabels Labels `json:"forbidden_labels"`
+	// ForbiddenSecrets is a list of secret names that dynamic jobs must not reference.
+	ForbiddenSecrets []string `json:"forbidden_secrets"`
+}
+
+func (m *DynamicJobRestrictions) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m DynamicJobRestrictions) Value() (driver.Value, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+func (m *DynamicJobRestrictions) Validate() error {
+	var result *multierror.Error
+	if m.MaxJobs < 0 {
+		result = multierror.Append(result, errors.New("error max jobs must not be negative"))
+	}
+	for _, workflow := range m.AllowedWorkflows {
+		// The default workflow is represented by an empty name, so allow that
+		if workflow != "" {
+			err := workflow.Validate()
+			if err != nil {
+				result = multierror.Append(result, fmt.Errorf("error invalid allowed workflow: %w", err))
+			}
+		}
+	}
+	for _, label := range m.ForbiddenLabels {
+		err := label.Validate()
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("error invalid forbidden label: %w", err))
+		}
+	}
+	for _, secret := range m.ForbiddenSecrets {
+		if secret == "" {
+			result = multierror.Append(result, errors.New("error forbidden secret name must be set"))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// IsEmpty returns true if no restrictions are specified.
+func (m *DynamicJobRestrictions) IsEmpty() bool {
+	return m.MaxJobs == 0 && len(m.AllowedWorkflows) == 0 && len(m.ForbiddenLabels) == 0 && len(m.ForbiddenSecrets) == 0
+}
+
+// CheckJobs returns an error describing every way in which the supplied job definitions violate the
+// workflow, label and secret restrictions. The MaxJobs restriction is checked separately via CheckJobCount
+// since it depends on the number of jobs already in the build.
+func (m *DynamicJobRestrictions) CheckJobs(jobs []JobDefinition) error {
+	var result *multierror.Error
+	for _, job := range jobs {
+		fqn := NewNodeFQNForJob(job.Workflow, job.Name)
+		if len(m.AllowedWorkflows) > 0 && !m.isWorkflowAllowed(job.Workflow) {
+			result = multierror.Append(result, fmt.Errorf("error job %s: workflow '%s' is not in the list of workflows dynamic jobs are allowed to use", fqn.String(), job.Workflow))
+		}
+		for _, label := range job.RunsOn {
+			if m.isLabelForbidden(label) {
+				result = multierror.Append(result, fmt.Errorf("error job %s: runs-on label '%s' is forbidden for dynamic jobs", fqn.String(), label))
+			}
+		}
+		for _, secret := range job.ReferencedSecrets() {
+			if m.isSecretForbidden(secret) {
+				result = multierror.Append(result, fmt.Errorf("error job %s: secret '%s' is forbidden for dynamic jobs", fqn.String(), secret))
+			}
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// CheckJobCount returns an error if a build would contain more jobs than allowed after adding new jobs.
+func (m *DynamicJobRestrictions) CheckJobCount(totalJobs int) error {
+	if m.MaxJobs > 0 && totalJobs > m.MaxJobs {
+		return fmt.Errorf("error build would contain %d jobs; the maximum allowed for builds in this repo is %d", totalJobs, m.MaxJobs)
+	}
+	return nil
+}
+
+func (m *DynamicJobRestrictions) isWorkflowAllowed(workflow ResourceName) bool {
+	for _, allowed := range m.AllowedWorkflows {
+		if allowed == workflow {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *DynamicJobRestrictions) isLabelForbidden(label Label) bool {
+	for _, forbidden := range m.ForbiddenLabels {
+		if forbidden == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *DynamicJobRestrictions) isSecretForbidden(secret string) bool {
+	for _, forbidden := range m.ForbiddenSecrets {
+		if forbidden == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferencedSecrets returns the names of all secrets referenced anywhere in the job definition.
+func (m *JobDefinitionData) ReferencedSecrets() []string {
+	var secrets []string
+	addSecretString := func(str SecretString) {
+		if str.ValueFromSecret != "" {
+			secrets = append(secrets, str.ValueFromSecret)
+		}
+	}
+	addDockerAuth := func(auth *DockerAuth) {
+		if auth == nil {
+			return
+		}
+		if auth.Basic != nil {
+			addSecretString(auth.Basic.Username)
+			addSecretString(auth.Basic.Password)
+		}
+		if auth.AWS != nil {
+			addSecretString(auth.AWS.AWSAccessKeyID)
+			addSecretString(auth.AWS.AWSSecretAccessKey)
+		}
+	}
+	for _, env := range m.Environment {
+		addSecretString(env.SecretString)
+	}
+	addDockerAuth(m.DockerAuth)
+	for _, service := range m.Services {
+		for _, env := range service.Environment {
+			addSecretString(env.SecretString)
+		}
+		addDockerAuth(service.DockerRegistryAuthentication)
+	}
+	return secrets
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const EmailDigestEntryResourceKind ResourceKind = "email-digest-entry"
+
+type EmailDigestEntryID struct {
+	ResourceID
+}
+
+func NewEmailDigestEntryID() EmailDigestEntryID {
+	return EmailDigestEntryID{ResourceID: NewResourceID(EmailDigestEntryResourceKind)}
+}
+
+// EmailDigestEntry records a finished build that is waiting to be included in a user's next digest email.
+type EmailDigestEntry struct {
+	ID            EmailDigestEntryID `json:"id" goqu:"skipupdate" db:"email_digest_entry_id"`
+	CreatedAt     Time               `json:"created_at" goqu:"skipupdate" db:"email_digest_entry_created_at"`
+	LegalEntityID LegalEntityID      `json:"legal_entity_id" db:"email_digest_entry_legal_entity_id"`
+	BuildID       BuildID            `json:"build_id" db:"email_digest_entry_build_id"`
+}
+
+func NewEmailDigestEntry(now Time, legalEntityID LegalEntityID, buildID BuildID) *EmailDigestEntry {
+	return &EmailDigestEntry{
+		ID:            NewEmailDigestEntryID(),
+		CreatedAt:     now,
+		LegalEntityID: legalEntityID,
+		BuildID:       buildID,
+	}
+}
+
+func (m *EmailDigestEntry) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *EmailDigestEntry) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *EmailDigestEntry) GetKind() ResourceKind {
+	return EmailDigestEntryResourceKind
+}
+
+func (m *EmailDigestEntry) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if !m.BuildID.Valid() {
+		result = multierror.Append(result, errors.New("error build id must be set"))
+	}
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const EmailPreferenceResourceKind ResourceKind = "email-preference"
+
+type EmailPreferenceID struct {
+	ResourceID
+}
+
+func NewEmailPreferenceID() EmailPreferenceID {
+	return EmailPreferenceID{ResourceID: NewResourceID(EmailPreferenceResourceKind)}
+}
+
+func EmailPreferenceIDFromResourceID(id ResourceID) EmailPreferenceID {
+	return EmailPreferenceID{ResourceID: id}
+}
+
+// EmailPreference specifies which build notification emails a user wants to receive. Users are sent emails
+// about builds for repos owned by themselves or by any organization they are a member of, subject to the
+// filters in their preference. Users without an email preference don't receive any emails.
+type EmailPreference struct {
+	ID            EmailPreferenceID `json:"id" goqu:"skipupdate" db:"email_preference_id"`
+	LegalEntityID LegalEntityID     `json:"legal_entity_id" goqu:"skipupdate" db:"email_preference_legal_entity_id"`
+	CreatedAt     Time              `json:"created_at" goqu:"skipupdate" db:"email_preference_created_at"`
+	UpdatedAt     Time              `json:"updated_at" db:"email_preference_updated_at"`
+	ETag          ETag              `json:"etag" db:"email_preference_etag" hash:"ignore"`
+	// Enabled is true if the user wants to receive build notification emails at all.
+	Enabled bool `json:"enabled" db:"email_preference_enabled"`
+	// OnlyMyBuilds is true if emails should only be sent for builds of commits authored or committed by the user.
+	OnlyMyBuilds bool `json:"only_my_builds" db:"email_preference_only_my_builds"`
+	// OnlyFailures is true if emails should only be sent for failed builds.
+	OnlyFailures bool `json:"only_failures" db:"email_preference_only_failures"`
+	// Digest is true if notifications should be batched up into a periodic digest email, rather than
+	// being sent as each build finishes.
+	Digest bool `json:"digest" db:"email_preference_digest"`
+}
+
+func NewEmailPreference(
+	now Time,
+	legalEntityID LegalEntityID,
+	enabled bool,
+	onlyMyBuilds bool,
+	onlyFailures bool,
+	digest bool,
+) *EmailPreference {
+	return &EmailPreference{
+		ID:            NewEmailPreferenceID(),
+		LegalEntityID: legalEntityID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Enabled:       enabled,
+		OnlyMyBuilds:  onlyMyBuilds,
+		OnlyFailures:  onlyFailures,
+		Digest:        digest,
+	}
+}
+
+func (m *EmailPreference) GetKind() ResourceKind {
+	return EmailPreferenceResourceKind
+}
+
+func (m *EmailPreference) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *EmailPreference) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *EmailPreference) GetParentID() ResourceID {
+	return m.LegalEntityID.ResourceID
+}
+
+func (m *EmailPreference) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *EmailPreference) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *EmailPreference) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *EmailPreference) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+// ShouldNotify returns true if the user should be emailed about a finished build. isMyBuild is true if
+// the build's commit was authored or committed by the user.
+func (m *EmailPreference) ShouldNotify(status WorkflowStatus, isMyBuild bool) bool {
+	if !m.Enabled {
+		return false
+	}
+	if m.OnlyMyBuilds && !isMyBuild {
+		return false
+	}
+	if m.OnlyFailures && status != WorkflowStatusFailed {
+		return false
+	}
+	return true
+}
+
+func (m *EmailPreference) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	return result.ErrorOrNil()
+}
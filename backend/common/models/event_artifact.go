@@ -0,0 +1,20 @@
+package models
+
+const (
+	// ArtifactQuarantinedEvent is an event to notify subscribers that an artifact was found to be infected by the
+	// artifact scanner, and has been quarantined. The event resource ID should be the ID of the artifact.
+	// The data should be the name of the threat found by the scanner.
+	ArtifactQuarantinedEvent EventType = "ArtifactQuarantined"
+)
+
+func NewArtifactQuarantinedEventData(job *Job, artifact *Artifact) *EventData {
+	return &EventData{
+		BuildID:      job.BuildID,
+		Type:         ArtifactQuarantinedEvent,
+		ResourceID:   artifact.ID.ResourceID,
+		Workflow:     job.Workflow,
+		JobName:      job.Name,
+		ResourceName: artifact.Name,
+		Payload:      artifact.ScanResult,
+	}
+}
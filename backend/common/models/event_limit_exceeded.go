@@ -0,0 +1,22 @@
+package models
+
+const (
+	// LimitExceededEvent is an event to record against a build that its build definition, or jobs added to it
+	// dynamically, exceeded one of the server's limits (e.g. on the number of steps in a job), so the build's
+	// author can see what needs to be split up. The event resource ID should be the ID of the build, and the
+	// workflow and job name should identify the workflow or job at fault, if any.
+	// The data should be a message describing the problem.
+	LimitExceededEvent EventType = "LimitExceeded"
+)
+
+func NewLimitExceededEventData(buildID BuildID, workflow ResourceName, jobName ResourceName, message string) *EventData {
+	return &EventData{
+		BuildID:      buildID,
+		Type:         LimitExceededEvent,
+		ResourceID:   buildID.ResourceID,
+		Workflow:     workflow,
+		JobName:      jobName,
+		ResourceName: jobName,
+		Payload:      message,
+	}
+}
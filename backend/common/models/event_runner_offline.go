@@ -0,0 +1,20 @@
+package models
+
+const (
+	// RunnerOfflineEvent is an event to notify subscribers that the runner a job was handed to has stopped sending
+	// heartbeats while the job was still running. The job may never finish if the runner does not come back online.
+	// The event resource ID should be the ID of the job. The data should be the ID of the runner.
+	RunnerOfflineEvent EventType = "RunnerOffline"
+)
+
+func NewRunnerOfflineEventData(job *Job, runner *Runner) *EventData {
+	return &EventData{
+		BuildID:      job.BuildID,
+		Type:         RunnerOfflineEvent,
+		ResourceID:   job.ID.ResourceID,
+		Workflow:     job.Workflow,
+		JobName:      job.Name,
+		ResourceName: job.Name,
+		Payload:      runner.ID.String(),
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+const (
+	// ToolchainVersionAvailableEvent is an event to notify subscribers that a new version of a toolchain used by
+	// a repo has been registered, so the repo's jobs can be moved to it. The event is published against the repo's
+	// latest build that used the toolchain, and the event resource ID should be the ID of the new toolchain version.
+	// The data should be the reference of the new version, in the format "name@version".
+	ToolchainVersionAvailableEvent EventType = "ToolchainVersionAvailable"
+)
+
+func NewToolchainVersionAvailableEventData(job *Job, toolchain *Toolchain) *EventData {
+	return &EventData{
+		BuildID:      job.BuildID,
+		Type:         ToolchainVersionAvailableEvent,
+		ResourceID:   toolchain.ID.ResourceID,
+		Workflow:     job.Workflow,
+		JobName:      job.Name,
+		ResourceName: toolchain.Name,
+		Payload:      toolchain.Reference().String(),
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ExecJobAllowAll is the exec job allowlist entry that allows exec jobs from any repo.
+const ExecJobAllowAll = "*"
+
+// ExecJobAllowlist lists the repos whose jobs may use the exec job type on a runner, which runs commands directly
+// on the runner's host rather than in a container and so gives the job full access to the host. Each entry is one of:
+//
+//   - "*" to allow any repo
+//   - "owner" to allow every repo owned by a user or organization
+//   - "owner/repo" to allow a single repo
+//
+// Entries are stored in lower case and names are matched case-insensitively.
+type ExecJobAllowlist []string
+
+// ParseExecJobAllowlist parses and validates a set of allowlist entries. Entries in the form "owner/*" are
+// treated as "owner". Empty entries are ignored, so an allowlist with only empty entries allows no repos.
+func ParseExecJobAllowlist(entries []string) (ExecJobAllowlist, error) {
+	allowlist := ExecJobAllowlist{}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry != ExecJobAllowAll {
+			parts := strings.Split(entry, "/")
+			if len(parts) > 2 || parts[0] == "" || parts[0] == ExecJobAllowAll || (len(parts) == 2 && parts[1] == "") {
+				return nil, fmt.Errorf("error expected exec job allowlist entry in the form *, owner, owner/* or owner/repo but found: %q", entry)
+			}
+			if len(parts) == 2 && parts[1] == ExecJobAllowAll {
+				entry = parts[0]
+			}
+		}
+		allowlist = append(allowlist, entry)
+	}
+	return allowlist, nil
+}
+
+// IsEmpty returns true if the allowlist allows no repos.
+func (m ExecJobAllowlist) IsEmpty() bool {
+	return len(m) == 0
+}
+
+// AllowsAll returns true if the allowlist allows any repo.
+func (m ExecJobAllowlist) AllowsAll() bool {
+	for _, entry := range m {
+		if entry == ExecJobAllowAll {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows returns true if jobs from the repo with the specified owner and name may use the exec job type.
+func (m ExecJobAllowlist) Allows(ownerName ResourceName, repoName ResourceName) bool {
+	owner := strings.ToLower(ownerName.String())
+	repo := owner + "/" + strings.ToLower(repoName.String())
+	for _, entry := range m {
+		if entry == ExecJobAllowAll || entry == owner || entry == repo {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ExecJobAllowlist) Scan(src interface{}) error {
+	if src == nil {
+		*m = nil
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m ExecJobAllowlist) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
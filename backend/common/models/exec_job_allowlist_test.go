@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecJobAllowlist(t *testing.T) {
+	allowlist, err := ParseExecJobAllowlist([]string{"gpu-team", "Acme/macOS-App", "tools/*", ""})
+	require.NoError(t, err)
+	require.False(t, allowlist.IsEmpty())
+	require.True(t, allowlist.Allows("gpu-team", "training"))
+	require.True(t, allowlist.Allows("acme", "macos-app"))
+	require.False(t, allowlist.Allows("acme", "website"))
+	require.True(t, allowlist.Allows("tools", "linter"))
+	require.False(t, allowlist.Allows("other", "macos-app"))
+
+	allowlist, err = ParseExecJobAllowlist([]string{"*"})
+	require.NoError(t, err)
+	require.True(t, allowlist.Allows("anyone", "anything"))
+	require.True(t, allowlist.AllowsAll())
+
+	// An allowlist with no entries allows nothing
+	allowlist, err = ParseExecJobAllowlist([]string{""})
+	require.NoError(t, err)
+	require.True(t, allowlist.IsEmpty())
+	require.False(t, allowlist.Allows("acme", "macos-app"))
+
+	for _, entry := range []string{"acme/", "/repo", "acme/repo/extra", "*/repo"} {
+		_, err = ParseExecJobAllowlist([]string{entry})
+		require.Error(t, err, entry)
+	}
+}
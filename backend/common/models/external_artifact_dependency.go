@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ExternalArtifactDependency declares that a job depends on artifact(s) produced by a previous build, which may
+// belong to a different repo owned by the same legal entity. The artifact(s) are resolved when the job is
+// dequeued, and will be downloaded and made available when the dependent job executes.
+// If repo name is empty then it refers to a build of the same repo as the dependent job.
+// If group name is empty then it refers to all artifacts produced by the build.
+type ExternalArtifactDependency struct {
+	RepoName  ResourceName `json:"repo_name"`
+	BuildName ResourceName `json:"build_name"`
+	GroupName ResourceName `json:"group_name"`
+}
+
+func NewExternalArtifactDependency(repoName ResourceName, buildName ResourceName, groupName ResourceName) *ExternalArtifactDependency {
+	return &ExternalArtifactDependency{
+		RepoName:  repoName,
+		BuildName: buildName,
+		GroupName: groupName,
+	}
+}
+
+// String returns the dependency in the same format used in build definitions, e.g. 'repo@build-123:group'.
+func (m *ExternalArtifactDependency) String() string {
+	str := fmt.Sprintf("build-%s", m.BuildName)
+	if m.RepoName != "" {
+		str = fmt.Sprintf("%s@%s", m.RepoName, str)
+	}
+	if m.GroupName != "" {
+		str = fmt.Sprintf("%s:%s", str, m.GroupName)
+	}
+	return str
+}
+
+func (m *ExternalArtifactDependency) Validate() error {
+	var result *multierror.Error
+	if m.RepoName != "" {
+		if err := m.RepoName.Validate(); err != nil {
+			result = multierror.Append(result, fmt.Errorf("error validating repo name: %w", err))
+		}
+	}
+	if err := m.BuildName.Validate(); err != nil {
+		result = multierror.Append(result, fmt.Errorf("error validating build name: %w", err))
+	}
+	if m.GroupName != "" {
+		if err := m.GroupName.Validate(); err != nil {
+			result = multierror.Append(result, fmt.Errorf("error validating group name: %w", err))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+type ExternalArtifactDependencies []*ExternalArtifactDependency
+
+func (m *ExternalArtifactDependencies) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m ExternalArtifactDependencies) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
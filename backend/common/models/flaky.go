@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const FlakyTestResourceKind ResourceKind = "flaky-test"
+
+// FailedDueToFlakyTestsMessage is the annotation for a failed job whose failing tests are all known to be flaky.
+const FailedDueToFlakyTestsMessage = "failed due to known flaky tests"
+
+type FlakyTestID struct {
+	ResourceID
+}
+
+func NewFlakyTestID() FlakyTestID {
+	return FlakyTestID{ResourceID: NewResourceID(FlakyTestResourceKind)}
+}
+
+func FlakyTestIDFromResourceID(id ResourceID) FlakyTestID {
+	return FlakyTestID{ResourceID: id}
+}
+
+// FlakyTest is a test in a repo that has been seen to fail intermittently, either by failing on some attempts
+// and passing on others within a single test run, or by passing in some test runs and failing in others that
+// ran against the same commit.
+type FlakyTest struct {
+	ID        FlakyTestID `json:"id" goqu:"skipupdate" db:"flaky_test_id"`
+	CreatedAt Time        `json:"created_at" goqu:"skipupdate" db:"flaky_test_created_at"`
+	UpdatedAt Time        `json:"updated_at" db:"flaky_test_updated_at"`
+	RepoID    RepoID      `json:"repo_id" goqu:"skipupdate" db:"flaky_test_repo_id"`
+	// Suite is the name of the suite, class or package containing the test.
+	Suite string `json:"suite" goqu:"skipupdate" db:"flaky_test_suite"`
+	// Name is the name of the test within the suite.
+	Name string `json:"name" goqu:"skipupdate" db:"flaky_test_name"`
+	// Detections is the number of test runs in which the test was seen to give inconsistent results.
+	Detections int `json:"detections" db:"flaky_test_detections"`
+	// LastBuildID is the ID of the build in which the test was most recently seen to be flaky.
+	LastBuildID BuildID `json:"last_build_id" db:"flaky_test_last_build_id"`
+	// LastCommitID is the ID of the commit the test was most recently seen to be flaky against.
+	LastCommitID CommitID `json:"last_commit_id" db:"flaky_test_last_commit_id"`
+	// Message is the most recent failure message reported for the test, if any.
+	Message string `json:"message" db:"flaky_test_message"`
+}
+
+func NewFlakyTest(now Time, repoID RepoID, suite string, name string) *FlakyTest {
+	return &FlakyTest{
+		ID:        NewFlakyTestID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		RepoID:    repoID,
+		Suite:     suite,
+		Name:      name,
+	}
+}
+
+// Detected records that the test was seen to be flaky in a test run against the specified commit.
+func (m *FlakyTest) Detected(now Time, testCase *TestCase, commitID CommitID) {
+	m.UpdatedAt = now
+	m.Detections++
+	m.LastBuildID = testCase.BuildID
+	m.LastCommitID = commitID
+	if testCase.Message != "" {
+		m.Message = testCase.Message
+	}
+}
+
+func (m *FlakyTest) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *FlakyTest) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *FlakyTest) GetKind() ResourceKind {
+	return FlakyTestResourceKind
+}
+
+func (m *FlakyTest) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if m.Name == "" {
+		result = multierror.Append(result, errors.New("error name must be set"))
+	}
+	if !m.LastBuildID.Valid() {
+		result = multierror.Append(result, errors.New("error last build id must be set"))
+	}
+	return result.ErrorOrNil()
+}
@@ -45,6 +45,10 @@ type Grant struct {
 	OperationName string `json:"operation_name" db:"access_control_grant_operation_name"`
 	// TargetResourceID is the id of the of resource the grant applies to.
 	TargetResourceID ResourceID `json:"target_resource_id" db:"access_control_grant_target_resource_id"`
+	// Deny is true if the grant denies the operation rather than allowing it. A grant on a resource takes
+	// precedence over grants on the resources that own it, and a deny takes precedence over an allow granted
+	// on the same resource.
+	Deny bool `json:"deny" db:"access_control_grant_deny"`
 }
 
 func NewIdentityGrant(now Time, grantedByLegalEntityID LegalEntityID, authorizedIdentityID IdentityID, operation Operation, targetResourcedID ResourceID) *Grant {
@@ -113,10 +117,11 @@ func (m *Grant) GetAuthorizedResourceID() ResourceID {
 // ToUniqueString returns a string that uniquely identifies a grant based on the data in the grant, without
 // including the grant ID. This can be used to check whether two grants are functionally equivalent.
 func (m *Grant) ToUniqueString() string {
-	return fmt.Sprintf("%s-%s-%s",
+	return fmt.Sprintf("%s-%s-%s-%t",
 		m.GetAuthorizedResourceID().String(),
 		m.GetOperation().String(),
 		m.TargetResourceID.String(),
+		m.Deny,
 	)
 }
 
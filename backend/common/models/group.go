@@ -155,6 +155,12 @@ func IsStandardGroupName(name ResourceName) bool {
 	return found
 }
 
+// LookupStandardGroupDefinition returns the definition of the standard group with the specified name, or nil
+// if there is no such standard group.
+func LookupStandardGroupDefinition(name ResourceName) *StandardGroupDefinition {
+	return allStandardGroupDefinitions[name]
+}
+
 // BaseStandardGroup is an access control Group for users that are members of an organization but have no specific
 // permissions for repos. Every member of a company legal entity should be a member of this group.
 // Provides read-only access to top-level organization resources, especially the organization's legal entity.
@@ -225,6 +231,7 @@ var AdminStandardGroup = &StandardGroupDefinition{
 		RunnerDeleteOperation,
 		// Other permissions
 		ArtifactDeleteOperation,
+		BuildPrioritizeOperation,
 		// Some operations can not be performed by admins for legal entities:
 		// - create new legal entities is done only by the server
 		// - create or update artifacts is done only by build agents
@@ -12,6 +12,10 @@ const IdentityResourceKind ResourceKind = "identity"
 // an optional identity (like our standard searches).
 var NoIdentity = IdentityID{}
 
+// AnonymousIdentityID is the well-known identity used for requests that were not authenticated. The anonymous
+// identity is only ever granted PublicReadOperations, on resources that have been made public.
+var AnonymousIdentityID = IdentityID{ResourceID: ResourceID{kind: IdentityResourceKind, id: "00000000-0000-0000-0000-000000000000"}}
+
 type IdentityID struct {
 	ResourceID
 }
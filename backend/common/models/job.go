@@ -59,7 +59,9 @@ type JobData struct {
 	RunnerID RunnerID `json:"runner_id" db:"job_runner_id"`
 	// IndirectToJobID records the ID of a job that previously ran successfully as part of another build
 	// and which is functionally identical to this job. If this is set it means this job did not actually
-	// run to avoid redundantly running the same thing more than once.
+	// run to avoid redundantly running the same thing more than once. For repos that deduplicate jobs this
+	// is also set while the job is queued, to an identical job that is running in another build; once that
+	// job finishes this job finishes with the same result.
 	IndirectToJobID JobID `json:"indirect_to_job_id" db:"job_indirect_to_job_id"`
 	// Ref is the git ref from the build that the job was generated from (e.g. branch or tag)
 	Ref string `json:"ref" db:"job_ref"`
@@ -79,6 +81,9 @@ type JobData struct {
 	// DefinitionDataHash is the hex-encoded hash of the job's definition data.
 	// NOTE: This hash captures the hash of the job's step definition data too.
 	DefinitionDataHash string `json:"definition_data_hash" db:"job_definition_data_hash"`
+	// RunnerLossRetries is the number of times the job has been queued to run again because the runner it was
+	// handed to stopped sending heartbeats.
+	RunnerLossRetries int `json:"runner_loss_retries" db:"job_runner_loss_retries"`
 }
 
 type JobDefinitionData struct {
@@ -88,8 +93,13 @@ type JobDefinitionData struct {
 	Workflow ResourceName `json:"workflow" db:"job_workflow"`
 	// Description is an optional human-readable description of the job.
 	Description string `json:"description" db:"job_description"`
+	// Stage is the optional name of the stage the job belongs to within its workflow (e.g. build, test, deploy).
+	// Jobs in a stage depend on all jobs in the previous stage of the same workflow.
+	Stage ResourceName `json:"stage" db:"job_stage"`
 	// Depends describes the dependencies this job has on other jobs.
 	Depends JobDependencies `json:"depends" db:"job_depends"`
+	// ArtifactFrom describes the dependencies this job has on artifacts produced by previous builds.
+	ArtifactFrom ExternalArtifactDependencies `json:"artifact_from" db:"job_artifact_from"`
 	// Services are a list of services to run in the background for the duration of the job.
 	// Services are started before the first step is run, and stopped after the last step completes.
 	Services JobServices `json:"services" db:"job_services"`
@@ -100,22 +110,78 @@ type JobDefinitionData struct {
 	// DockerImage is the default Docker image to run the job's steps in, if the job is of type Docker.
 	// In the future, steps may override this property by setting their own DockerImage.
 	DockerImage string `json:"docker_image" db:"job_docker_image"`
+	// DockerToolchain is an optional reference to a registered toolchain to run the job's steps in, in the format
+	// "name@version", if the job is of type Docker. When the job is run DockerImage is set to the toolchain's image.
+	DockerToolchain string `json:"docker_toolchain" db:"job_docker_toolchain"`
 	// DockerImagePullStrategy determines if/when the Docker image is pulled during job execution, if the job is of type Docker.
 	DockerImagePullStrategy DockerPullStrategy `json:"docker_pull" db:"job_docker_image_pull_strategy"`
 	// DockerAuth contains the optional authentication for pulling a docker image, if the job is of type Docker.
 	DockerAuth *DockerAuth `json:"docker_auth" db:"job_docker_auth"`
 	// DockerShell is the path to the shell to use to run build scripts with inside the container.
 	DockerShell *string `json:"docker_shell" db:"job_docker_shell"`
+	// Shell is the shell to run build scripts with: either one of the well-known shells (sh, bash, cmd,
+	// powershell or pwsh) or the path to a shell. Applies to exec jobs, and to docker jobs that don't set
+	// DockerShell. Defaults to cmd on Windows and sh everywhere else.
+	Shell *string `json:"shell" db:"job_shell"`
+	// OperatingSystem is the operating system a runner must be running on to run this job, or empty if the job
+	// can run on any operating system.
+	OperatingSystem OperatingSystem `json:"operating_system" db:"job_operating_system"`
 	// StepExecution determines how the runner will execute steps within this job.
 	StepExecution StepExecution `json:"step_execution" db:"job_step_execution"`
+	// SkipCheckout is true if the job doesn't need the repo's source code, in which case the runner won't
+	// clone the repo or set up an SSH agent with the repo's SSH key.
+	SkipCheckout bool `json:"skip_checkout" db:"job_skip_checkout"`
+	// RetryOnRunnerLoss is true if the job should be queued to run again, rather than failed, when the runner
+	// it was handed to stops sending heartbeats before the job finishes.
+	RetryOnRunnerLoss bool `json:"retry_on_runner_loss" db:"job_retry_on_runner_loss"`
+	// PinSecrets is true if the job should be given the versions of the repo's secrets that were current
+	// when the build started, rather than the latest versions when the job runs. This ensures every job in
+	// a build sees the same secrets even if a secret is rotated while the build is running.
+	PinSecrets bool `json:"pin_secrets" db:"job_pin_secrets"`
+	// Condition is an optional expression that must be true for the job to run. If the condition is false
+	// the job (and any jobs that depend on it) will be skipped.
+	Condition string `json:"condition" db:"job_condition"`
+	// OnlyPaths is an optional list of path patterns; if set, the job will be skipped unless at least one of the
+	// files changed by the commit being built matches one of the patterns.
+	OnlyPaths PathPatterns `json:"only_paths" db:"job_only_paths"`
+	// IgnorePaths is an optional list of path patterns; if set, the job will be skipped if every file changed
+	// by the commit being built matches one of the patterns.
+	IgnorePaths PathPatterns `json:"ignore_paths" db:"job_ignore_paths"`
 	// FingerprintCommands contains zero or more shell commands to execute to generate a unique fingerprint for the job.
 	// Two jobs in the same repo with the same name and fingerprint are considered identical.
 	FingerprintCommands Commands `json:"fingerprint_commands" db:"job_fingerprint_commands"`
 	// ArtifactDefinitions contains a list of artifacts the job is expected to produce that
 	// will be saved to the artifact store at the end of the job's execution.
 	ArtifactDefinitions ArtifactDefinitions `json:"artifact_definitions" db:"job_artifact_definitions"`
+	// CacheDefinitions contains a list of caches to restore before the job's steps run, and to save to
+	// the build cache once the job has succeeded.
+	CacheDefinitions CacheDefinitions `json:"cache_definitions" db:"job_cache_definitions"`
 	// Environment contains a list of environment variables to export prior to executing the job.
 	Environment JobEnvVars `json:"environment" db:"job_environment"`
+	// CPUMillis is the CPU the job requires, in thousandths of a core, or zero if the job has no CPU requirement.
+	CPUMillis int64 `json:"cpu_millis" db:"job_cpu_millis"`
+	// MemoryBytes is the memory the job requires, or zero if the job has no memory requirement.
+	MemoryBytes int64 `json:"memory_bytes" db:"job_memory_bytes"`
+	// DiskBytes is the disk space the job requires, or zero if the job has no disk requirement.
+	DiskBytes int64 `json:"disk_bytes" db:"job_disk_bytes"`
+	// GPUs is the number of GPUs the job requires, or zero if the job doesn't use GPUs.
+	GPUs int64 `json:"gpus" db:"job_gpus"`
+	// TimeoutSeconds is the maximum time the job may take, from when it is queued until it finishes, before
+	// it is failed. Zero means the server's default job timeout applies.
+	TimeoutSeconds int64 `json:"timeout_seconds" db:"job_timeout_seconds"`
+}
+
+// GetResources returns the resources the job requires from the runner it runs on.
+func (m *JobDefinitionData) GetResources() Resources {
+	return Resources{CPUMillis: m.CPUMillis, MemoryBytes: m.MemoryBytes, DiskBytes: m.DiskBytes, GPUs: m.GPUs}
+}
+
+// SetResources sets the resources the job requires from the runner it runs on.
+func (m *JobDefinitionData) SetResources(resources Resources) {
+	m.CPUMillis = resources.CPUMillis
+	m.MemoryBytes = resources.MemoryBytes
+	m.DiskBytes = resources.DiskBytes
+	m.GPUs = resources.GPUs
 }
 
 func (m *Job) GetKind() ResourceKind {
@@ -216,39 +282,65 @@ func (m *Job) Validate() error {
 			result = multierror.Append(result, err)
 		}
 	}
+	if m.Stage != "" { // stage is optional
+		if err := m.Stage.Validate(); err != nil {
+			result = multierror.Append(result, fmt.Errorf("error validating stage: %w", err))
+		}
+	}
 	if !m.Type.Valid() {
 		result = multierror.Append(result, errors.New("error builder type is invalid"))
 	} else if m.Type == JobTypeDocker {
-		if m.DockerImage == "" {
-			result = multierror.Append(result, errors.New("error docker image must be set"))
+		if m.DockerImage == "" && m.DockerToolchain == "" {
+			result = multierror.Append(result, errors.New("error docker image or toolchain must be set"))
+		}
+		if m.DockerToolchain != "" {
+			if _, err := ParseToolchainReference(m.DockerToolchain); err != nil {
+				result = multierror.Append(result, err)
+			}
 		}
 		if !m.DockerImagePullStrategy.Valid() {
 			result = multierror.Append(result, errors.New("error docker image pull strategy must be set"))
 		}
 	}
+	if err := m.GetResources().Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if m.TimeoutSeconds < 0 {
+		result = multierror.Append(result, errors.New("error timeout must not be negative"))
+	}
 	if !m.Status.Valid() {
 		result = multierror.Append(result, errors.New("error status is invalid"))
 	}
 	if m.Status == WorkflowStatusSubmitted && !m.RunnerID.Valid() {
 		result = multierror.Append(result, errors.New("error runner id must be set when job is submitted"))
 	}
+	if m.OperatingSystem != "" && !m.OperatingSystem.Valid() {
+		result = multierror.Append(result, fmt.Errorf("error operating system %q is invalid", m.OperatingSystem))
+	}
 	for _, label := range m.RunsOn {
 		err := label.Validate()
 		if err != nil {
 			result = multierror.Append(result, fmt.Errorf("error validating label %q: %w", label, err))
 		}
 	}
-	dependenciesByName := make(map[ResourceName]*JobDependency, len(m.Depends))
+	dependenciesByFQN := make(map[NodeFQN]*JobDependency, len(m.Depends))
 	for i, dependency := range m.Depends {
 		err := dependency.Validate()
 		if err != nil {
 			result = multierror.Append(result, errors.Wrapf(err, "error validating depedency %q (index %d)", dependency.JobName, i))
 		}
-		_, ok := dependenciesByName[dependency.JobName]
+		fqn := dependency.GetFQN()
+		_, ok := dependenciesByFQN[fqn]
 		if ok {
-			return errors.Errorf("Found duplicate dependency %q; Dependencies must have unique resource_links", dependency.JobName)
+			return errors.Errorf("Found duplicate dependency %q; Dependencies must have unique resource_links", fqn.String())
+		}
+		dependenciesByFQN[fqn] = dependency
+	}
+	for i, dependency := range m.ArtifactFrom {
+		err := dependency.Validate()
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "error validating artifact_from dependency %q (index %d)", dependency, i))
 		}
-		dependenciesByName[dependency.JobName] = dependency
 	}
 	servicesByName := make(map[string]*Service, len(m.Services))
 	for i, service := range m.Services {
@@ -274,6 +366,21 @@ func (m *Job) Validate() error {
 		}
 		artifactsByName[artifact.GroupName] = artifact
 	}
+	cachesByName := make(map[ResourceName]*CacheDefinition, len(m.CacheDefinitions))
+	for i, cache := range m.CacheDefinitions {
+		err := cache.Validate()
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "error validating cache %q (index %d)", cache.Name, i))
+		}
+		_, ok := cachesByName[cache.Name]
+		if ok {
+			return errors.Errorf("error duplicate cache definition %q; Caches must have unique names", cache.Name)
+		}
+		cachesByName[cache.Name] = cache
+		if len(cache.KeyCommands) == 0 && len(m.FingerprintCommands) == 0 {
+			result = multierror.Append(result, errors.Errorf("error cache %q must specify key commands since the job has no fingerprint commands to use as the cache key", cache.Name))
+		}
+	}
 	for i, env := range m.Environment {
 		err := env.Validate()
 		if err != nil {
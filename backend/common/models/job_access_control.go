@@ -15,8 +15,16 @@ var JobUpdateOperation = &Operation{
 	ResourceKind: JobResourceKind,
 }
 
+// JobIssueOIDCTokenOperation allows OpenID Connect ID tokens to be issued for a running job, which identify
+// the job to third parties such as cloud providers.
+var JobIssueOIDCTokenOperation = &Operation{
+	Name:         "issue_oidc_token",
+	ResourceKind: JobResourceKind,
+}
+
 var JobAccessControlOperations = []*Operation{
 	JobCreateOperation,
 	JobReadOperation,
 	JobUpdateOperation,
+	JobIssueOIDCTokenOperation,
 }
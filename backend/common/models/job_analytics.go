@@ -0,0 +1,98 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+)
+
+const (
+	// DefaultJobAnalyticsRange is how far back job analytics look if no start time is specified.
+	DefaultJobAnalyticsRange = 7 * 24 * time.Hour
+	// DefaultJobAnalyticsInterval is the length of each time window if no interval is specified.
+	DefaultJobAnalyticsInterval = 24 * time.Hour
+	// MinJobAnalyticsInterval is the shortest time window that job analytics can be broken down into.
+	MinJobAnalyticsInterval = time.Minute
+	// MaxJobAnalyticsWindows is the maximum number of time windows a single query can be broken down into.
+	MaxJobAnalyticsWindows = 500
+)
+
+// JobAnalyticsGroupBy controls how jobs are grouped when computing job analytics.
+type JobAnalyticsGroupBy string
+
+const (
+	// JobAnalyticsGroupByJob groups jobs by workflow and job name.
+	JobAnalyticsGroupByJob JobAnalyticsGroupBy = "job"
+	// JobAnalyticsGroupByWorkflow groups jobs by workflow only.
+	JobAnalyticsGroupByWorkflow JobAnalyticsGroupBy = "workflow"
+)
+
+func (g JobAnalyticsGroupBy) Valid() bool {
+	return g == JobAnalyticsGroupByJob || g == JobAnalyticsGroupByWorkflow
+}
+
+func (g JobAnalyticsGroupBy) String() string {
+	return string(g)
+}
+
+// JobAnalyticsWindow is a time window that job analytics are computed for. Jobs are counted in the window
+// they were created (queued) in.
+type JobAnalyticsWindow struct {
+	// Start is the start of the window, inclusive.
+	Start Time `json:"start"`
+	// End is the end of the window, exclusive.
+	End Time `json:"end"`
+}
+
+// JobAnalyticsQuery describes the jobs to compute analytics for, and how to break them down.
+type JobAnalyticsQuery struct {
+	// From is the start of the time range to include jobs from, inclusive.
+	From Time `json:"from"`
+	// To is the end of the time range to include jobs from, exclusive.
+	To Time `json:"to"`
+	// Interval is the length of each time window the range is broken down into. The last window is cut short
+	// if the range isn't a whole number of intervals.
+	Interval time.Duration       `json:"interval"`
+	GroupBy  JobAnalyticsGroupBy `json:"group_by"`
+}
+
+// NewJobAnalyticsQuery returns a query covering the default range of time up until now, broken down by the
+// default interval and grouped by job.
+func NewJobAnalyticsQuery(now Time) *JobAnalyticsQuery {
+	return &JobAnalyticsQuery{
+		From:     NewTime(now.Add(-DefaultJobAnalyticsRange)),
+		To:       now,
+		Interval: DefaultJobAnalyticsInterval,
+		GroupBy:  JobAnalyticsGroupByJob,
+	}
+}
+
+// Windows returns the time windows the query's range is broken down into, in order.
+func (m *JobAnalyticsQuery) Windows() []JobAnalyticsWindow {
+	var windows []JobAnalyticsWindow
+	for start := m.From.Time; start.Before(m.To.Time); start = start.Add(m.Interval) {
+		end := start.Add(m.Interval)
+		if end.After(m.To.Time) {
+			end = m.To.Time
+		}
+		windows = append(windows, JobAnalyticsWindow{Start: NewTime(start), End: NewTime(end)})
+	}
+	return windows
+}
+
+func (m *JobAnalyticsQuery) Validate() error {
+	if !m.From.Before(m.To.Time) {
+		return gerror.NewErrValidationFailed("From must be earlier than To")
+	}
+	if m.Interval < MinJobAnalyticsInterval {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Interval must be at least %s", MinJobAnalyticsInterval))
+	}
+	if m.To.Sub(m.From.Time) > time.Duration(MaxJobAnalyticsWindows)*m.Interval {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("At most %d intervals can be analyzed at once", MaxJobAnalyticsWindows))
+	}
+	if !m.GroupBy.Valid() {
+		return gerror.NewErrValidationFailed("Invalid group by: " + m.GroupBy.String())
+	}
+	return nil
+}
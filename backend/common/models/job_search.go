@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+)
+
+type JobSearch struct {
+	Pagination
+	// RunnerID is the runner that jobs being searched for were handed to, or nil to include jobs for any runner.
+	RunnerID *RunnerID `json:"runner_id"`
+	// After can be set to only include jobs that were still active (not yet finished) at or after this time.
+	After *Time `json:"after"`
+	// Before can be set to only include jobs that were created before this time.
+	Before *Time `json:"before"`
+	// Statuses can be set to only include jobs whose current status is in the list.
+	Statuses []WorkflowStatus `json:"statuses"`
+}
+
+func NewJobSearch() *JobSearch {
+	return &JobSearch{Pagination: NewPagination(DefaultPaginationLimit, nil)}
+}
+
+func (m *JobSearch) Validate() error {
+	if m.After != nil && m.Before != nil && !m.After.Before(m.Before.Time) {
+		return gerror.NewErrValidationFailed("After must be earlier than Before")
+	}
+	for _, status := range m.Statuses {
+		if !status.Valid() {
+			return gerror.NewErrValidationFailed("Invalid status: " + status.String())
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -14,6 +15,12 @@ const (
 	JobTypeExec   JobType = "exec"
 )
 
+// customJobTypeRegex matches the names of custom job types, which are implemented by runner plugins.
+var customJobTypeRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// JobType determines how a runner executes a job's steps. In addition to the built-in docker and exec types,
+// a job can use a custom type implemented by a runner plugin; the job is only run by runners that advertise
+// support for its type.
 type JobType string
 
 func (m *JobType) Scan(src interface{}) error {
@@ -25,18 +32,24 @@ func (m *JobType) Scan(src interface{}) error {
 	if !ok {
 		return errors.Errorf("error expected string but found: %T", src)
 	}
-	switch strings.ToLower(t) {
-	case "", string(JobTypeDocker):
+	t = strings.ToLower(t)
+	switch {
+	case t == "":
 		*m = JobTypeDocker
-	case string(JobTypeExec):
-		*m = JobTypeExec
+	case JobType(t).Valid():
+		*m = JobType(t)
 	default:
-		return errors.Errorf("error unknown job type: %s", t)
+		return errors.Errorf("error invalid job type: %s", t)
 	}
 	return nil
 }
 
 func (m JobType) Valid() bool {
+	return m.IsBuiltIn() || customJobTypeRegex.MatchString(string(m))
+}
+
+// IsBuiltIn returns true if the job type is supported by every runner, rather than being implemented by a plugin.
+func (m JobType) IsBuiltIn() bool {
 	return m == JobTypeDocker || m == JobTypeExec
 }
 
@@ -8,7 +8,14 @@ import (
 	"github.com/pkg/errors"
 )
 
-const LegalEntityResourceKind ResourceKind = "legal-entity"
+const (
+	LegalEntityResourceKind ResourceKind = "legal-entity"
+
+	// DefaultLegalEntitySchedulingWeight is the scheduling weight given to new legal entities.
+	DefaultLegalEntitySchedulingWeight = 1
+	// MaxLegalEntitySchedulingWeight is the highest scheduling weight that can be given to a legal entity.
+	MaxLegalEntitySchedulingWeight = 100
+)
 
 type LegalEntityID struct {
 	ResourceID
@@ -28,7 +35,10 @@ type LegalEntityMetadata struct {
 	UpdatedAt Time          `json:"updated_at" db:"legal_entity_updated_at"`
 	DeletedAt *Time         `json:"deleted_at,omitempty" db:"legal_entity_deleted_at"`
 	SyncedAt  *Time         `json:"synced_at,omitempty" db:"legal_entity_synced_at"`
-	ETag      ETag          `json:"etag" db:"legal_entity_etag" hash:"ignore"`
+	// DisabledAt is the time the legal entity was disabled by an administrator, or nil if it is enabled.
+	// A disabled legal entity can't authenticate, but its data is kept.
+	DisabledAt *Time `json:"disabled_at,omitempty" db:"legal_entity_disabled_at"`
+	ETag       ETag  `json:"etag" db:"legal_entity_etag" hash:"ignore"`
 }
 
 type LegalEntityData struct {
@@ -40,6 +50,10 @@ type LegalEntityData struct {
 	EmailAddress     string              `json:"email_address" db:"legal_entity_email_address"`
 	ExternalID       *ExternalResourceID `json:"external_id" db:"legal_entity_external_id"`
 	ExternalMetadata string              `json:"external_metadata" db:"legal_entity_external_metadata"`
+	// SchedulingWeight determines this legal entity's share of a runner that is shared with other legal entities,
+	// when jobs from several legal entities are queued. A legal entity with a weight of 2 is given twice as many
+	// concurrently running jobs as a legal entity with a weight of 1, however many repos each has.
+	SchedulingWeight int `json:"scheduling_weight" db:"legal_entity_scheduling_weight"`
 }
 
 type LegalEntity struct {
@@ -55,6 +69,7 @@ func NewCompanyLegalEntityData(name ResourceName, legalName string, emailAddress
 		EmailAddress:     emailAddress,
 		ExternalID:       externalID,
 		ExternalMetadata: externalMetadata,
+		SchedulingWeight: DefaultLegalEntitySchedulingWeight,
 	}
 }
 
@@ -66,6 +81,7 @@ func NewPersonLegalEntityData(name ResourceName, legalName string, emailAddress
 		EmailAddress:     emailAddress,
 		ExternalID:       externalID,
 		ExternalMetadata: externalMetadata,
+		SchedulingWeight: DefaultLegalEntitySchedulingWeight,
 	}
 }
 
@@ -118,6 +134,11 @@ func (m *LegalEntity) IsUnreachable() bool {
 	return false
 }
 
+// IsDisabled returns true if the legal entity has been disabled.
+func (m *LegalEntity) IsDisabled() bool {
+	return m.DisabledAt != nil
+}
+
 func (m *LegalEntity) Validate() error {
 	var result *multierror.Error
 	if !m.ID.Valid() {
@@ -132,6 +153,9 @@ func (m *LegalEntity) Validate() error {
 	if m.DeletedAt != nil && m.DeletedAt.IsZero() {
 		result = multierror.Append(result, errors.New("error deleted at must be non-zero when set"))
 	}
+	if m.DisabledAt != nil && m.DisabledAt.IsZero() {
+		result = multierror.Append(result, errors.New("error disabled at must be non-zero when set"))
+	}
 	err := m.LegalEntityData.Validate()
 	if err != nil {
 		result = multierror.Append(result, fmt.Errorf("data is invalid: %s", err))
@@ -147,6 +171,9 @@ func (m *LegalEntityData) Validate() error {
 	if !m.Type.Valid() {
 		result = multierror.Append(result, errors.New("error type is invalid"))
 	}
+	if err := ValidateLegalEntitySchedulingWeight(m.SchedulingWeight); err != nil {
+		result = multierror.Append(result, err)
+	}
 	if m.ExternalID != nil {
 		if !m.ExternalID.Valid() {
 			result = multierror.Append(result, errors.New("error external id is invalid"))
@@ -158,3 +185,11 @@ func (m *LegalEntityData) Validate() error {
 	}
 	return result.ErrorOrNil()
 }
+
+// ValidateLegalEntitySchedulingWeight returns an error if weight is not a valid scheduling weight for a legal entity.
+func ValidateLegalEntitySchedulingWeight(weight int) error {
+	if weight < 1 || weight > MaxLegalEntitySchedulingWeight {
+		return fmt.Errorf("error scheduling weight must be between 1 and %d", MaxLegalEntitySchedulingWeight)
+	}
+	return nil
+}
@@ -34,6 +34,7 @@ var LegalEntityAccessControlOperations = []*Operation{
 	BuildCreateOperation,
 	BuildReadOperation,
 	BuildUpdateOperation,
+	BuildPrioritizeOperation,
 	RunnerCreateOperation,
 	RunnerReadOperation,
 	RunnerUpdateOperation,
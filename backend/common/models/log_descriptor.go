@@ -46,6 +46,14 @@ type LogDescriptor struct {
 	Sealed bool `json:"sealed" db:"log_descriptor_sealed"`
 	// SizeBytes is calculated and set at the time the log is sealed
 	SizeBytes int64 `json:"size_bytes" db:"log_descriptor_size_bytes"`
+	// ArchivedAt is set when the log's data is compressed and moved to cold storage by the retention policy
+	ArchivedAt *Time `json:"archived_at" db:"log_descriptor_archived_at"`
+	// ArchiveBlobKey is the key of the blob holding the log's compressed data, once the log is archived
+	ArchiveBlobKey string `json:"archive_blob_key" db:"log_descriptor_archive_blob_key"`
+	// ArchivedSizeBytes is the compressed size of the log's data, once the log is archived
+	ArchivedSizeBytes int64 `json:"archived_size_bytes" db:"log_descriptor_archived_size_bytes"`
+	// ExpiredAt is set when the log's data is deleted by the retention policy. The descriptor is kept.
+	ExpiredAt *Time `json:"expired_at" db:"log_descriptor_expired_at"`
 	ETag      ETag  `json:"etag" db:"log_descriptor_etag" hash:"ignore"`
 }
 
@@ -101,5 +109,18 @@ func (m *LogDescriptor) Validate() error {
 	if !m.ResourceID.Valid() {
 		result = multierror.Append(result, errors.New("error resource id must be set"))
 	}
+	if m.ArchivedAt != nil && m.ArchiveBlobKey == "" {
+		result = multierror.Append(result, errors.New("error archive blob key must be set when archived"))
+	}
 	return result.ErrorOrNil()
 }
+
+// IsArchived returns true if the log's data has been moved to cold storage.
+func (m *LogDescriptor) IsArchived() bool {
+	return m.ArchivedAt != nil
+}
+
+// IsExpired returns true if the log's data has been deleted.
+func (m *LogDescriptor) IsExpired() bool {
+	return m.ExpiredAt != nil
+}
@@ -6,6 +6,9 @@ type LogSearch struct {
 	StartSeqNo *int
 	Plaintext  *bool
 	Expand     *bool
+	// Follow can be set to true to keep the stream open and receive new entries as they are written,
+	// until the log is sealed. Only supported when reading a single log.
+	Follow *bool
 }
 
 func NewLogSearch() *LogSearch {
@@ -16,5 +19,8 @@ func (m *LogSearch) Validate() error {
 	if (m.Expand != nil && *m.Expand) && (m.StartSeqNo != nil && *m.StartSeqNo != 0) {
 		return gerror.NewErrValidationFailed("expand and start seq no cannot be specified together")
 	}
+	if (m.Expand != nil && *m.Expand) && (m.Follow != nil && *m.Follow) {
+		return gerror.NewErrValidationFailed("expand and follow cannot be specified together")
+	}
 	return nil
 }
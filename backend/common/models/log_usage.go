@@ -0,0 +1,27 @@
+package models
+
+// LogUsage summarizes the storage used by the logs belonging to a repo's builds, jobs and steps.
+type LogUsage struct {
+	RepoID RepoID `json:"repo_id" db:"-"`
+	// LiveCount is the number of logs whose data is stored uncompressed.
+	LiveCount int64 `json:"live_count" db:"live_count"`
+	// LiveSizeBytes is the total size of the data for live logs. Logs that are still being written to
+	// are not included, since their size is only calculated when they are sealed.
+	LiveSizeBytes int64 `json:"live_size_bytes" db:"live_size_bytes"`
+	// ArchivedCount is the number of logs whose data has been compressed and moved to cold storage.
+	ArchivedCount int64 `json:"archived_count" db:"archived_count"`
+	// ArchivedSizeBytes is the total compressed size of the data for archived logs.
+	ArchivedSizeBytes int64 `json:"archived_size_bytes" db:"archived_size_bytes"`
+	// ExpiredCount is the number of logs whose data has been deleted.
+	ExpiredCount int64 `json:"expired_count" db:"expired_count"`
+}
+
+// LogRetentionResult records the work done by a single run of the log retention policy.
+type LogRetentionResult struct {
+	// Archived is the number of logs that were archived.
+	Archived int `json:"archived"`
+	// Expired is the number of logs whose data was deleted.
+	Expired int `json:"expired"`
+	// Failed is the number of logs that could not be archived or expired; these will be retried next run.
+	Failed int `json:"failed"`
+}
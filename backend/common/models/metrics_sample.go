@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const MetricsSampleResourceKind ResourceKind = "metrics-sample"
+
+type MetricsSampleID struct {
+	ResourceID
+}
+
+func NewMetricsSampleID() MetricsSampleID {
+	return MetricsSampleID{ResourceID: NewResourceID(MetricsSampleResourceKind)}
+}
+
+type MetricsSampleKind string
+
+const (
+	MetricsSampleKindBuild MetricsSampleKind = "build"
+	MetricsSampleKindJob   MetricsSampleKind = "job"
+)
+
+func (k MetricsSampleKind) Valid() bool {
+	return k == MetricsSampleKindBuild || k == MetricsSampleKindJob
+}
+
+func (k MetricsSampleKind) String() string {
+	return string(k)
+}
+
+// MetricsSample records the outcome and timings of a single finished build or job, waiting to be aggregated
+// and exported to an external metrics system. The repo and owner names are recorded at the time the
+// build or job finished so that samples can be exported even if the repo is later renamed or deleted.
+type MetricsSample struct {
+	ID        MetricsSampleID   `json:"id" goqu:"skipupdate" db:"metrics_sample_id"`
+	CreatedAt Time              `json:"created_at" goqu:"skipupdate" db:"metrics_sample_created_at"`
+	Kind      MetricsSampleKind `json:"kind" db:"metrics_sample_kind"`
+	RepoID    RepoID            `json:"repo_id" db:"metrics_sample_repo_id"`
+	// OwnerName is the name of the legal entity that owns the repo.
+	OwnerName ResourceName `json:"owner_name" db:"metrics_sample_owner_name"`
+	RepoName  ResourceName `json:"repo_name" db:"metrics_sample_repo_name"`
+	// ResourceID is the ID of the build or job the sample was taken from.
+	ResourceID ResourceID     `json:"resource_id" db:"metrics_sample_resource_id"`
+	Status     WorkflowStatus `json:"status" db:"metrics_sample_status"`
+	// QueueWaitMillis is the time spent waiting between being queued and starting to run, in milliseconds.
+	QueueWaitMillis int64 `json:"queue_wait_millis" db:"metrics_sample_queue_wait_millis"`
+	// DurationMillis is the time spent running, in milliseconds.
+	DurationMillis int64 `json:"duration_millis" db:"metrics_sample_duration_millis"`
+}
+
+func NewMetricsSample(
+	now Time,
+	kind MetricsSampleKind,
+	repoID RepoID,
+	ownerName ResourceName,
+	repoName ResourceName,
+	resourceID ResourceID,
+	status WorkflowStatus,
+	timings WorkflowTimings,
+) *MetricsSample {
+	sample := &MetricsSample{
+		ID:         NewMetricsSampleID(),
+		CreatedAt:  now,
+		Kind:       kind,
+		RepoID:     repoID,
+		OwnerName:  ownerName,
+		RepoName:   repoName,
+		ResourceID: resourceID,
+		Status:     status,
+	}
+	// Builds and jobs that never ran (e.g. canceled while queued) have no duration, and wait until they finished
+	startedAt := timings.RunningAt
+	if startedAt == nil {
+		startedAt = timings.FinishedAt
+	}
+	if timings.QueuedAt != nil && startedAt != nil {
+		sample.QueueWaitMillis = nonNegativeMillis(startedAt.Sub(timings.QueuedAt.Time))
+	}
+	if timings.RunningAt != nil && timings.FinishedAt != nil {
+		sample.DurationMillis = nonNegativeMillis(timings.FinishedAt.Sub(timings.RunningAt.Time))
+	}
+	return sample
+}
+
+func nonNegativeMillis(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+	return d.Milliseconds()
+}
+
+func (m *MetricsSample) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *MetricsSample) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *MetricsSample) GetKind() ResourceKind {
+	return MetricsSampleResourceKind
+}
+
+func (m *MetricsSample) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if !m.Kind.Valid() {
+		result = multierror.Append(result, errors.New("error kind must be build or job"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if !m.ResourceID.Valid() {
+		result = multierror.Append(result, errors.New("error resource id must be set"))
+	}
+	if !m.Status.HasFinished() {
+		result = multierror.Append(result, errors.New("error status must be a finished status"))
+	}
+	return result.ErrorOrNil()
+}
+
+// MetricsAggregate summarizes the metrics samples of one kind, for one repo and status, that were taken
+// within a single export window.
+type MetricsAggregate struct {
+	Kind      MetricsSampleKind
+	OwnerName ResourceName
+	RepoName  ResourceName
+	Status    WorkflowStatus
+	// Count is the number of builds or jobs that finished.
+	Count int64
+	// DurationSecondsSum is the total time spent running.
+	DurationSecondsSum float64
+	// DurationSecondsMax is the longest time any single build or job spent running.
+	DurationSecondsMax float64
+	// QueueWaitSecondsSum is the total time spent waiting to start running.
+	QueueWaitSecondsSum float64
+	// QueueWaitSecondsMax is the longest time any single build or job spent waiting to start running.
+	QueueWaitSecondsMax float64
+}
+
+// RateLimitKeyType is the kind of key a rate limiter throttled a request by.
+type RateLimitKeyType string
+
+const (
+	RateLimitKeyTypeIdentity RateLimitKeyType = "identity"
+	RateLimitKeyTypeIP       RateLimitKeyType = "ip"
+)
+
+func (k RateLimitKeyType) String() string {
+	return string(k)
+}
+
+// RateLimitAggregate counts the requests throttled by one of a server's rate limiters, for one type of key,
+// within a single export window.
+type RateLimitAggregate struct {
+	// Instance identifies the server that throttled the requests; each server counts its own requests.
+	Instance string
+	// Limiter is the name of the rate limiter (e.g. "dequeue").
+	Limiter string
+	KeyType RateLimitKeyType
+	// Throttled is the number of requests that were rejected.
+	Throttled int64
+}
+
+// MetricsBatch is a set of aggregated metrics for a single export window, to be sent to an external metrics system.
+type MetricsBatch struct {
+	// WindowStart is the (inclusive) start of the export window.
+	WindowStart Time
+	// WindowEnd is the (exclusive) end of the export window.
+	WindowEnd  Time
+	Aggregates []*MetricsAggregate
+	RateLimits []*RateLimitAggregate
+}
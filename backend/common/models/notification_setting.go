@@ -0,0 +1,206 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const NotificationSettingResourceKind ResourceKind = "notification-setting"
+
+type NotificationSettingID struct {
+	ResourceID
+}
+
+func NewNotificationSettingID() NotificationSettingID {
+	return NotificationSettingID{ResourceID: NewResourceID(NotificationSettingResourceKind)}
+}
+
+func NotificationSettingIDFromResourceID(id ResourceID) NotificationSettingID {
+	return NotificationSettingID{ResourceID: id}
+}
+
+// NotificationChannelType identifies the kind of external system notifications are sent to.
+type NotificationChannelType string
+
+const (
+	NotificationChannelTypeSlack   NotificationChannelType = "slack"
+	NotificationChannelTypeDiscord NotificationChannelType = "discord"
+)
+
+func (t NotificationChannelType) Valid() bool {
+	return t == NotificationChannelTypeSlack || t == NotificationChannelTypeDiscord
+}
+
+func (t NotificationChannelType) String() string {
+	return string(t)
+}
+
+// NotificationEvent is a build outcome that notifications can be sent for.
+type NotificationEvent string
+
+const (
+	// NotificationEventSuccess is sent when a build succeeds.
+	NotificationEventSuccess NotificationEvent = "success"
+	// NotificationEventFailure is sent when a build fails.
+	NotificationEventFailure NotificationEvent = "failure"
+	// NotificationEventRecovery is sent when a build succeeds after the previous build for the same ref failed.
+	NotificationEventRecovery NotificationEvent = "recovery"
+)
+
+func (e NotificationEvent) String() string {
+	return string(e)
+}
+
+// NotificationSetting configures a channel that notifications about builds for a repo are sent to,
+// and which build outcomes to send notifications for.
+type NotificationSetting struct {
+	ID        NotificationSettingID `json:"id" goqu:"skipupdate" db:"notification_setting_id"`
+	RepoID    RepoID                `json:"repo_id" db:"notification_setting_repo_id"`
+	CreatedAt Time                  `json:"created_at" goqu:"skipupdate" db:"notification_setting_created_at"`
+	UpdatedAt Time                  `json:"updated_at" db:"notification_setting_updated_at"`
+	ETag      ETag                  `json:"etag" db:"notification_setting_etag" hash:"ignore"`
+	// Channel is the type of system to send notifications to.
+	Channel NotificationChannelType `json:"channel" db:"notification_setting_channel"`
+	// WebhookURLEncrypted is the incoming webhook URL to send notifications to, encrypted using DataKeyEncrypted.
+	// Webhook URLs embed a credential, so they are treated as secrets.
+	WebhookURLEncrypted BinaryBlob `json:"-" db:"notification_setting_webhook_url_encrypted"`
+	// DataKeyEncrypted is the key that can be used to decrypt WebhookURLEncrypted.
+	// This key is itself encrypted and must be decrypted before being used.
+	DataKeyEncrypted BinaryBlob `json:"-" db:"notification_setting_data_key_encrypted"`
+	// OnSuccess is true if a notification should be sent every time a build succeeds.
+	OnSuccess bool `json:"on_success" db:"notification_setting_on_success"`
+	// OnFailure is true if a notification should be sent every time a build fails.
+	OnFailure bool `json:"on_failure" db:"notification_setting_on_failure"`
+	// OnRecovery is true if a notification should be sent when a build succeeds after the previous
+	// build for the same ref failed.
+	OnRecovery bool `json:"on_recovery" db:"notification_setting_on_recovery"`
+	// MessageTemplate is an optional Go text/template used to format the message, or empty to use the
+	// default message. See NotificationMessageData for the data available to the template.
+	MessageTemplate string `json:"message_template" db:"notification_setting_message_template"`
+}
+
+func NewNotificationSetting(
+	now Time,
+	repoID RepoID,
+	channel NotificationChannelType,
+	webhookURLEncrypted []byte,
+	dataKeyEncrypted []byte,
+	onSuccess bool,
+	onFailure bool,
+	onRecovery bool,
+	messageTemplate string) *NotificationSetting {
+
+	return &NotificationSetting{
+		ID:                  NewNotificationSettingID(),
+		RepoID:              repoID,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+		Channel:             channel,
+		WebhookURLEncrypted: webhookURLEncrypted,
+		DataKeyEncrypted:    dataKeyEncrypted,
+		OnSuccess:           onSuccess,
+		OnFailure:           onFailure,
+		OnRecovery:          onRecovery,
+		MessageTemplate:     messageTemplate,
+	}
+}
+
+func (m *NotificationSetting) GetKind() ResourceKind {
+	return NotificationSettingResourceKind
+}
+
+func (m *NotificationSetting) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *NotificationSetting) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *NotificationSetting) GetParentID() ResourceID {
+	return m.RepoID.ResourceID
+}
+
+func (m *NotificationSetting) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *NotificationSetting) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *NotificationSetting) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *NotificationSetting) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+// ShouldNotify returns true if a notification should be sent for the specified event.
+func (m *NotificationSetting) ShouldNotify(event NotificationEvent) bool {
+	switch event {
+	case NotificationEventSuccess:
+		return m.OnSuccess
+	case NotificationEventFailure:
+		return m.OnFailure
+	case NotificationEventRecovery:
+		// A recovery is also a success
+		return m.OnRecovery || m.OnSuccess
+	default:
+		return false
+	}
+}
+
+func (m *NotificationSetting) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if !m.Channel.Valid() {
+		result = multierror.Append(result, fmt.Errorf("error channel must be one of %q or %q", NotificationChannelTypeSlack, NotificationChannelTypeDiscord))
+	}
+	if m.WebhookURLEncrypted == nil {
+		result = multierror.Append(result, errors.New("error webhook url must be set"))
+	}
+	if m.DataKeyEncrypted == nil {
+		result = multierror.Append(result, errors.New("error data key must be set"))
+	}
+	if m.MessageTemplate != "" {
+		_, err := template.New("message").Parse(m.MessageTemplate)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("error parsing message template: %w", err))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// NotificationMessageData is the data available to a NotificationSetting's MessageTemplate.
+type NotificationMessageData struct {
+	// Event is the build outcome the notification is being sent for.
+	Event NotificationEvent
+	// RepoName is the name of the repo the build is for.
+	RepoName ResourceName
+	// BuildName is the name of the build, unique within the repo (e.g. "42").
+	BuildName ResourceName
+	// Ref is the git ref that was built.
+	Ref string
+	// Status is the final status of the build.
+	Status WorkflowStatus
+	// Error is the error the build failed with, or empty if the build succeeded.
+	Error string
+	// BuildURL is a link to the build in the web UI, or empty if no UI is configured.
+	BuildURL string
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"path"
+	"strings"
+)
+
+// OperatingSystem is the operating system a runner runs on, as reported by the runner, and which a job can require.
+// An empty OperatingSystem on a job means the job can run on any operating system.
+const (
+	OperatingSystemLinux   OperatingSystem = "linux"
+	OperatingSystemWindows OperatingSystem = "windows"
+	OperatingSystemMacOS   OperatingSystem = "macos"
+)
+
+type OperatingSystem string
+
+func (m OperatingSystem) Valid() bool {
+	return m == OperatingSystemLinux ||
+		m == OperatingSystemWindows ||
+		m == OperatingSystemMacOS
+}
+
+func (m OperatingSystem) String() string {
+	return string(m)
+}
+
+// windowsDockerImagePrefix is the prefix of the official Windows container base images.
+const windowsDockerImagePrefix = "mcr.microsoft.com/windows"
+
+// DeriveOperatingSystem returns the operating system a job needs in order to run its steps with the specified
+// shell or Docker image, or an empty OperatingSystem if they don't need a particular operating system.
+// The cmd and powershell shells and the official Windows container base images only work on Windows; pwsh
+// also runs elsewhere.
+func DeriveOperatingSystem(shell string, dockerImage string) OperatingSystem {
+	if shell != "" {
+		name := strings.ToLower(path.Base(strings.ReplaceAll(shell, `\`, "/")))
+		name = strings.TrimSuffix(name, ".exe")
+		if name == "cmd" || name == "powershell" {
+			return OperatingSystemWindows
+		}
+	}
+	if strings.HasPrefix(strings.ToLower(dockerImage), windowsDockerImagePrefix) {
+		return OperatingSystemWindows
+	}
+	return ""
+}
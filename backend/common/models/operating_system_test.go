@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveOperatingSystem(t *testing.T) {
+	tests := []struct {
+		shell    string
+		image    string
+		expected OperatingSystem
+	}{
+		{"", "", ""},
+		{"sh", "golang:1.19", ""},
+		{"pwsh", "", ""},
+		{"/bin/bash", "", ""},
+		{"cmd", "", OperatingSystemWindows},
+		{"PowerShell", "", OperatingSystemWindows},
+		{`C:\Windows\System32\cmd.exe`, "", OperatingSystemWindows},
+		{"", "mcr.microsoft.com/windows/servercore:ltsc2022", OperatingSystemWindows},
+		{"", "mcr.microsoft.com/dotnet/sdk:8.0", ""},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, DeriveOperatingSystem(test.shell, test.image), "%s %s", test.shell, test.image)
+	}
+}
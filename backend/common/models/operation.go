@@ -14,3 +14,13 @@ type Operation struct {
 func (m Operation) String() string {
 	return fmt.Sprintf("%s:%s", m.Name, m.ResourceKind)
 }
+
+// AllOperations lists every operation that is subject to access control.
+var AllOperations = append(LegalEntityAccessControlOperations, []*Operation{
+	JobCreateOperation,
+	JobReadOperation,
+	JobUpdateOperation,
+	JobIssueOIDCTokenOperation,
+	ToolchainCreateOperation,
+	CustomStatusCreateOperation,
+}...)
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const OutgoingWebhookResourceKind ResourceKind = "outgoing-webhook"
+
+type OutgoingWebhookID struct {
+	ResourceID
+}
+
+func NewOutgoingWebhookID() OutgoingWebhookID {
+	return OutgoingWebhookID{ResourceID: NewResourceID(OutgoingWebhookResourceKind)}
+}
+
+func OutgoingWebhookIDFromResourceID(id ResourceID) OutgoingWebhookID {
+	return OutgoingWebhookID{ResourceID: id}
+}
+
+// OutgoingWebhook is an HTTPS endpoint that JSON payloads are delivered to when build or job events occur.
+// A webhook registered against a legal entity receives events for all of the legal entity's repos, as well as
+// events for the legal entity's runners; a webhook registered against a repo receives events for that repo only.
+type OutgoingWebhook struct {
+	ID            OutgoingWebhookID `json:"id" goqu:"skipupdate" db:"outgoing_webhook_id"`
+	LegalEntityID LegalEntityID     `json:"legal_entity_id" goqu:"skipupdate" db:"outgoing_webhook_legal_entity_id"`
+	// RepoID is the repo the webhook receives events for, or invalid if the webhook receives events for
+	// all of the legal entity's repos.
+	RepoID    RepoID `json:"repo_id" goqu:"skipupdate" db:"outgoing_webhook_repo_id"`
+	CreatedAt Time   `json:"created_at" goqu:"skipupdate" db:"outgoing_webhook_created_at"`
+	UpdatedAt Time   `json:"updated_at" db:"outgoing_webhook_updated_at"`
+	ETag      ETag   `json:"etag" db:"outgoing_webhook_etag" hash:"ignore"`
+	// URL is the endpoint payloads are delivered to.
+	URL string `json:"url" db:"outgoing_webhook_url"`
+	// SecretEncrypted is the secret used to sign payloads, encrypted using DataKeyEncrypted.
+	SecretEncrypted BinaryBlob `json:"-" db:"outgoing_webhook_secret_encrypted"`
+	// DataKeyEncrypted is the key that can be used to decrypt SecretEncrypted.
+	// This key is itself encrypted and must be decrypted before being used.
+	DataKeyEncrypted BinaryBlob `json:"-" db:"outgoing_webhook_data_key_encrypted"`
+	// OnBuildStatusChanged is true if a payload should be delivered every time the status of a build changes.
+	OnBuildStatusChanged bool `json:"on_build_status_changed" db:"outgoing_webhook_on_build_status_changed"`
+	// OnJobStatusChanged is true if a payload should be delivered every time the status of a job changes.
+	OnJobStatusChanged bool `json:"on_job_status_changed" db:"outgoing_webhook_on_job_status_changed"`
+	// OnRunnerHealthChanged is true if a payload should be delivered every time one of the legal entity's
+	// runners becomes unhealthy or healthy again. Only applies to webhooks registered against a legal entity.
+	OnRunnerHealthChanged bool `json:"on_runner_health_changed" db:"outgoing_webhook_on_runner_health_changed"`
+	// ConsecutiveFailedDeliveries is the number of deliveries in a row that failed after every attempt to
+	// deliver them, since the last successful delivery.
+	ConsecutiveFailedDeliveries int `json:"consecutive_failed_deliveries" db:"outgoing_webhook_consecutive_failed_deliveries"`
+	// DisabledAt is the time the webhook was disabled, either manually or because deliveries to it kept failing,
+	// or nil if the webhook is enabled. No payloads are delivered to a disabled webhook until it is re-enabled.
+	DisabledAt *Time `json:"disabled_at" db:"outgoing_webhook_disabled_at"`
+	// DisabledReason describes why the webhook was disabled, or is empty if the webhook is enabled.
+	DisabledReason string `json:"disabled_reason" db:"outgoing_webhook_disabled_reason"`
+}
+
+func NewOutgoingWebhook(
+	now Time,
+	legalEntityID LegalEntityID,
+	repoID RepoID,
+	url string,
+	secretEncrypted []byte,
+	dataKeyEncrypted []byte,
+	onBuildStatusChanged bool,
+	onJobStatusChanged bool,
+	onRunnerHealthChanged bool) *OutgoingWebhook {
+
+	return &OutgoingWebhook{
+		ID:                    NewOutgoingWebhookID(),
+		LegalEntityID:         legalEntityID,
+		RepoID:                repoID,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+		URL:                   url,
+		SecretEncrypted:       secretEncrypted,
+		DataKeyEncrypted:      dataKeyEncrypted,
+		OnBuildStatusChanged:  onBuildStatusChanged,
+		OnJobStatusChanged:    onJobStatusChanged,
+		OnRunnerHealthChanged: onRunnerHealthChanged,
+	}
+}
+
+func (m *OutgoingWebhook) GetKind() ResourceKind {
+	return OutgoingWebhookResourceKind
+}
+
+func (m *OutgoingWebhook) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *OutgoingWebhook) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+// GetParentID returns the ID of the repo the webhook is registered against, or the legal entity if the
+// webhook is not specific to a repo.
+func (m *OutgoingWebhook) GetParentID() ResourceID {
+	if m.RepoID.Valid() {
+		return m.RepoID.ResourceID
+	}
+	return m.LegalEntityID.ResourceID
+}
+
+func (m *OutgoingWebhook) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *OutgoingWebhook) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *OutgoingWebhook) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *OutgoingWebhook) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+// IsDisabled returns true if the webhook has been disabled.
+func (m *OutgoingWebhook) IsDisabled() bool {
+	return m.DisabledAt != nil
+}
+
+// Disable stops payloads being delivered to the webhook until it is re-enabled.
+func (m *OutgoingWebhook) Disable(now Time, reason string) {
+	m.DisabledAt = &now
+	m.DisabledReason = reason
+}
+
+// Enable resumes delivery of payloads to the webhook, and resets its count of failed deliveries.
+func (m *OutgoingWebhook) Enable() {
+	m.DisabledAt = nil
+	m.DisabledReason = ""
+	m.ConsecutiveFailedDeliveries = 0
+}
+
+// IsSubscribed returns true if payloads should be delivered to the webhook for the specified event type.
+// Disabled webhooks are not subscribed to any events.
+func (m *OutgoingWebhook) IsSubscribed(eventType EventType) bool {
+	if m.IsDisabled() {
+		return false
+	}
+	switch eventType {
+	case BuildStatusChangedEvent:
+		return m.OnBuildStatusChanged
+	case JobStatusChangedEvent:
+		return m.OnJobStatusChanged
+	case RunnerHealthChangedEvent:
+		return m.OnRunnerHealthChanged
+	default:
+		return false
+	}
+}
+
+func (m *OutgoingWebhook) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if m.URL == "" {
+		result = multierror.Append(result, errors.New("error url must be set"))
+	}
+	if m.SecretEncrypted == nil {
+		result = multierror.Append(result, errors.New("error secret must be set"))
+	}
+	if m.DataKeyEncrypted == nil {
+		result = multierror.Append(result, errors.New("error data key must be set"))
+	}
+	if m.OnRunnerHealthChanged && m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error runner health events can only be delivered to webhooks registered against a legal entity"))
+	}
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// MaxOutgoingWebhookDeliveryAttemptLogLength is the maximum number of attempts recorded in a delivery's attempt
+// log. Once the log is full the oldest attempts are discarded.
+const MaxOutgoingWebhookDeliveryAttemptLogLength = 20
+
+const OutgoingWebhookDeliveryResourceKind ResourceKind = "outgoing-webhook-delivery"
+
+type OutgoingWebhookDeliveryID struct {
+	ResourceID
+}
+
+func NewOutgoingWebhookDeliveryID() OutgoingWebhookDeliveryID {
+	return OutgoingWebhookDeliveryID{ResourceID: NewResourceID(OutgoingWebhookDeliveryResourceKind)}
+}
+
+func OutgoingWebhookDeliveryIDFromResourceID(id ResourceID) OutgoingWebhookDeliveryID {
+	return OutgoingWebhookDeliveryID{ResourceID: id}
+}
+
+type OutgoingWebhookDeliveryStatus string
+
+const (
+	// OutgoingWebhookDeliveryStatusPending means the payload has not yet been delivered, but delivery
+	// will be attempted (or retried).
+	OutgoingWebhookDeliveryStatusPending OutgoingWebhookDeliveryStatus = "pending"
+	// OutgoingWebhookDeliveryStatusSucceeded means the endpoint accepted the payload with a 2xx response.
+	OutgoingWebhookDeliveryStatusSucceeded OutgoingWebhookDeliveryStatus = "succeeded"
+	// OutgoingWebhookDeliveryStatusFailed means every attempt to deliver the payload failed, and no
+	// further attempts will be made.
+	OutgoingWebhookDeliveryStatusFailed OutgoingWebhookDeliveryStatus = "failed"
+)
+
+func (s OutgoingWebhookDeliveryStatus) Valid() bool {
+	return s == OutgoingWebhookDeliveryStatusPending ||
+		s == OutgoingWebhookDeliveryStatusSucceeded ||
+		s == OutgoingWebhookDeliveryStatusFailed
+}
+
+func (s OutgoingWebhookDeliveryStatus) String() string {
+	return string(s)
+}
+
+// OutgoingWebhookDelivery records a payload to be delivered to an outgoing webhook, along with the outcome
+// of the most recent attempt to deliver it, for debugging purposes.
+type OutgoingWebhookDelivery struct {
+	ID        OutgoingWebhookDeliveryID `json:"id" goqu:"skipupdate" db:"outgoing_webhook_delivery_id"`
+	WebhookID OutgoingWebhookID         `json:"webhook_id" goqu:"skipupdate" db:"outgoing_webhook_delivery_webhook_id"`
+	CreatedAt Time                      `json:"created_at" goqu:"skipupdate" db:"outgoing_webhook_delivery_created_at"`
+	UpdatedAt Time                      `json:"updated_at" db:"outgoing_webhook_delivery_updated_at"`
+	ETag      ETag                      `json:"etag" db:"outgoing_webhook_delivery_etag" hash:"ignore"`
+	// EventType is the type of event the payload describes.
+	EventType EventType `json:"event_type" goqu:"skipupdate" db:"outgoing_webhook_delivery_event_type"`
+	// Payload is the JSON document delivered to the webhook.
+	Payload string `json:"payload" goqu:"skipupdate" db:"outgoing_webhook_delivery_payload"`
+	// Status is the current status of the delivery.
+	Status OutgoingWebhookDeliveryStatus `json:"status" db:"outgoing_webhook_delivery_status"`
+	// Attempts is the number of attempts made to deliver the payload so far.
+	Attempts int `json:"attempts" db:"outgoing_webhook_delivery_attempts"`
+	// ResponseStatusCode is the HTTP status code returned by the endpoint on the most recent attempt,
+	// or zero if no response was received.
+	ResponseStatusCode int `json:"response_status_code" db:"outgoing_webhook_delivery_response_status_code"`
+	// ResponseBody is the start of the body returned by the endpoint on the most recent attempt.
+	ResponseBody string `json:"response_body" db:"outgoing_webhook_delivery_response_body"`
+	// Error describes why the most recent attempt failed, or is empty if it succeeded.
+	Error string `json:"error" db:"outgoing_webhook_delivery_error"`
+	// AttemptLog records the outcome of each attempt to deliver the payload, oldest first, including attempts
+	// made before the payload was redelivered.
+	AttemptLog OutgoingWebhookDeliveryAttempts `json:"attempt_log" db:"outgoing_webhook_delivery_attempt_log"`
+}
+
+// NewOutgoingWebhookDelivery creates a new pending delivery. The Payload must be filled out before the
+// delivery is saved; it is not passed in because the payload includes the delivery's ID.
+func NewOutgoingWebhookDelivery(now Time, webhookID OutgoingWebhookID, eventType EventType) *OutgoingWebhookDelivery {
+	return &OutgoingWebhookDelivery{
+		ID:        NewOutgoingWebhookDeliveryID(),
+		WebhookID: webhookID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		EventType: eventType,
+		Status:    OutgoingWebhookDeliveryStatusPending,
+	}
+}
+
+func (m *OutgoingWebhookDelivery) GetKind() ResourceKind {
+	return OutgoingWebhookDeliveryResourceKind
+}
+
+func (m *OutgoingWebhookDelivery) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *OutgoingWebhookDelivery) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *OutgoingWebhookDelivery) GetParentID() ResourceID {
+	return m.WebhookID.ResourceID
+}
+
+func (m *OutgoingWebhookDelivery) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *OutgoingWebhookDelivery) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *OutgoingWebhookDelivery) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *OutgoingWebhookDelivery) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+func (m *OutgoingWebhookDelivery) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.WebhookID.Valid() {
+		result = multierror.Append(result, errors.New("error webhook id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if m.EventType == "" {
+		result = multierror.Append(result, errors.New("error event type must be set"))
+	}
+	if m.Payload == "" {
+		result = multierror.Append(result, errors.New("error payload must be set"))
+	}
+	if !m.Status.Valid() {
+		result = multierror.Append(result, errors.New("error status is invalid"))
+	}
+	return result.ErrorOrNil()
+}
+
+// LogAttempt appends an attempt to the delivery's attempt log, discarding the oldest attempts if the log is full.
+func (m *OutgoingWebhookDelivery) LogAttempt(attempt *OutgoingWebhookDeliveryAttempt) {
+	m.AttemptLog = append(m.AttemptLog, attempt)
+	if len(m.AttemptLog) > MaxOutgoingWebhookDeliveryAttemptLogLength {
+		m.AttemptLog = m.AttemptLog[len(m.AttemptLog)-MaxOutgoingWebhookDeliveryAttemptLogLength:]
+	}
+}
+
+// OutgoingWebhookDeliveryAttempt records the outcome of a single attempt to deliver a payload to an outgoing webhook.
+type OutgoingWebhookDeliveryAttempt struct {
+	AttemptedAt Time `json:"attempted_at"`
+	// DurationMs is the time taken for the endpoint to respond, or for the attempt to fail, in milliseconds.
+	DurationMs int64 `json:"duration_ms"`
+	// ResponseStatusCode is the HTTP status code returned by the endpoint, or zero if no response was received.
+	ResponseStatusCode int `json:"response_status_code"`
+	// Error describes why the attempt failed, or is empty if it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+type OutgoingWebhookDeliveryAttempts []*OutgoingWebhookDeliveryAttempt
+
+func (m *OutgoingWebhookDeliveryAttempts) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m OutgoingWebhookDeliveryAttempts) Value() (driver.Value, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+// OutgoingWebhookPayload is the JSON document delivered to outgoing webhooks.
+type OutgoingWebhookPayload struct {
+	// DeliveryID uniquely identifies the delivery, and is also sent in the X-BuildBeaver-Delivery header.
+	DeliveryID OutgoingWebhookDeliveryID `json:"delivery_id"`
+	// Event is the type of event, and is also sent in the X-BuildBeaver-Event header.
+	Event     EventType `json:"event"`
+	Timestamp Time      `json:"timestamp"`
+	// Repo and Build are set for build and job events only.
+	Repo  *OutgoingWebhookPayloadRepo `json:"repo,omitempty"`
+	Build *OutgoingWebhookPayloadItem `json:"build,omitempty"`
+	// Job is set for job events only.
+	Job *OutgoingWebhookPayloadItem `json:"job,omitempty"`
+	// Runner is set for runner events only.
+	Runner *OutgoingWebhookPayloadRunner `json:"runner,omitempty"`
+}
+
+// OutgoingWebhookPayloadRunner describes a runner in an outgoing webhook payload.
+type OutgoingWebhookPayloadRunner struct {
+	ID      RunnerID     `json:"id"`
+	Name    ResourceName `json:"name"`
+	Healthy bool         `json:"healthy"`
+	// Problems lists the reasons the runner is unhealthy, or is empty if the runner is healthy.
+	Problems []string `json:"problems,omitempty"`
+}
+
+type OutgoingWebhookPayloadRepo struct {
+	ID   RepoID       `json:"id"`
+	Name ResourceName `json:"name"`
+}
+
+// OutgoingWebhookPayloadItem describes a build or job in an outgoing webhook payload.
+type OutgoingWebhookPayloadItem struct {
+	ID       ResourceID     `json:"id"`
+	Name     ResourceName   `json:"name"`
+	Workflow ResourceName   `json:"workflow,omitempty"`
+	Ref      string         `json:"ref,omitempty"`
+	Status   WorkflowStatus `json:"status"`
+	Error    string         `json:"error,omitempty"`
+}
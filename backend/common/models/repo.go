@@ -48,6 +48,21 @@ type Repo struct {
 	SSHKeySecretID   *SecretID           `json:"ssh_key_secret_id" db:"repo_ssh_key_secret_id"`
 	ExternalID       *ExternalResourceID `json:"external_id" db:"repo_external_id"`
 	ExternalMetadata string              `json:"external_metadata" db:"repo_external_metadata"`
+	// DynamicJobRestrictions optionally limits the jobs that can be added to builds for this repo via the
+	// Dynamic API. If nil then no repo-specific restrictions apply.
+	DynamicJobRestrictions *DynamicJobRestrictions `json:"dynamic_job_restrictions" db:"repo_dynamic_job_restrictions"`
+	// CoverageSettings optionally configures a code coverage threshold that is checked when each build
+	// for this repo finishes. If nil then coverage is recorded but not checked.
+	CoverageSettings *CoverageSettings `json:"coverage_settings" db:"repo_coverage_settings"`
+	// PublicBuilds is true if anyone, including people who have not logged in, can read the repo's builds,
+	// logs and artifacts.
+	PublicBuilds bool `json:"public_builds" db:"repo_public_builds"`
+	// BuildPrioritySettings optionally configures the priority given to new builds for this repo.
+	// If nil then builds are given the normal priority unless requested otherwise.
+	BuildPrioritySettings *BuildPrioritySettings `json:"build_priority_settings" db:"repo_build_priority_settings"`
+	// DeduplicateJobs is true if a queued job that is identical to a job already running in another build
+	// for the same commit should finish with the running job's result, instead of being run a second time.
+	DeduplicateJobs bool `json:"deduplicate_jobs" db:"repo_deduplicate_jobs"`
 }
 
 func NewRepo(
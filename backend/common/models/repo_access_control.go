@@ -29,8 +29,47 @@ var RepoAccessControlOperations = []*Operation{
 	SecretReadPlaintextOperation,
 	BuildReadOperation,
 	BuildUpdateOperation,
+	BuildPrioritizeOperation,
 	ArtifactCreateOperation,
 	ArtifactReadOperation,
 	ArtifactUpdateOperation,
 	ArtifactDeleteOperation,
 }
+
+// PublicReadOperations are the operations that anyone, including the anonymous identity, can perform on
+// a repo that has public builds enabled.
+var PublicReadOperations = []*Operation{
+	RepoReadOperation,
+	BuildReadOperation,
+	ArtifactReadOperation,
+}
+
+// RepoOverridableOperations are the operations an organization can explicitly grant to or deny an access control
+// group on one of its repos, overriding the permissions the group holds on the organization as a whole.
+var RepoOverridableOperations = []*Operation{
+	RepoReadOperation,
+	RepoUpdateOperation,
+	RepoDeleteOperation,
+	BuildCreateOperation,
+	BuildReadOperation,
+	BuildUpdateOperation,
+	BuildPrioritizeOperation,
+	SecretCreateOperation,
+	SecretReadOperation,
+	SecretUpdateOperation,
+	SecretDeleteOperation,
+	ArtifactCreateOperation,
+	ArtifactReadOperation,
+	ArtifactUpdateOperation,
+	ArtifactDeleteOperation,
+}
+
+// IsRepoOverridableOperation returns true if operation is one of RepoOverridableOperations.
+func IsRepoOverridableOperation(operation Operation) bool {
+	for _, overridable := range RepoOverridableOperations {
+		if *overridable == operation {
+			return true
+		}
+	}
+	return false
+}
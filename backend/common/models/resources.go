@@ -0,0 +1,182 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Resources is an amount of CPU, memory, disk and GPUs; either the resources a job requires, or the capacity
+// of a runner. A zero value for CPU, memory or disk means no particular amount (for a job, no requirement;
+// for a runner, unknown or unlimited capacity). GPUs are different: a runner with zero GPUs has none to give.
+type Resources struct {
+	// CPUMillis is the amount of CPU, in thousandths of a CPU core.
+	CPUMillis int64 `json:"cpu_millis"`
+	// MemoryBytes is the amount of memory, in bytes.
+	MemoryBytes int64 `json:"memory_bytes"`
+	// DiskBytes is the amount of disk space, in bytes.
+	DiskBytes int64 `json:"disk_bytes"`
+	// GPUs is the number of GPUs.
+	GPUs int64 `json:"gpus"`
+}
+
+// IsZero returns true if no amount is set for any resource.
+func (m Resources) IsZero() bool {
+	return m == Resources{}
+}
+
+// FitsWithin returns true if these resources are no more than the specified capacity, for each resource
+// the capacity sets an amount for, and for GPUs.
+func (m Resources) FitsWithin(capacity Resources) bool {
+	fits := func(required int64, available int64) bool {
+		return available == 0 || required <= available
+	}
+	return fits(m.CPUMillis, capacity.CPUMillis) &&
+		fits(m.MemoryBytes, capacity.MemoryBytes) &&
+		fits(m.DiskBytes, capacity.DiskBytes) &&
+		m.GPUs <= capacity.GPUs
+}
+
+func (m Resources) Validate() error {
+	if m.CPUMillis < 0 || m.MemoryBytes < 0 || m.DiskBytes < 0 || m.GPUs < 0 {
+		return fmt.Errorf("error resources must not be negative")
+	}
+	return nil
+}
+
+func (m Resources) String() string {
+	return fmt.Sprintf("cpu=%dm memory=%d disk=%d gpus=%d", m.CPUMillis, m.MemoryBytes, m.DiskBytes, m.GPUs)
+}
+
+// ParseCPUMillis parses an amount of CPU, given either as a number of cores (e.g. 2 or 1.5) or as
+// thousandths of a core with an 'm' suffix (e.g. 500m), and returns it in thousandths of a core.
+func ParseCPUMillis(raw interface{}) (int64, error) {
+	switch v := raw.(type) {
+	case int:
+		return parseCPUCores(float64(v))
+	case int64:
+		return parseCPUCores(float64(v))
+	case float64:
+		return parseCPUCores(v)
+	case string:
+		str := strings.TrimSpace(v)
+		if strings.HasSuffix(str, "m") {
+			millis, err := strconv.ParseInt(strings.TrimSuffix(str, "m"), 10, 64)
+			if err != nil || millis < 0 {
+				return 0, fmt.Errorf("error invalid CPU amount %q", v)
+			}
+			return millis, nil
+		}
+		cores, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error invalid CPU amount %q", v)
+		}
+		return parseCPUCores(cores)
+	default:
+		return 0, fmt.Errorf("error expected CPU amount to be a number or string but found: %T", raw)
+	}
+}
+
+// ParseGPUs parses a number of GPUs, given as a whole number.
+func ParseGPUs(raw interface{}) (int64, error) {
+	switch v := raw.(type) {
+	case int:
+		return ParseGPUs(int64(v))
+	case int64:
+		if v < 0 {
+			return 0, fmt.Errorf("error invalid number of GPUs %d", v)
+		}
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("error invalid number of GPUs %v", v)
+		}
+		return ParseGPUs(int64(v))
+	case string:
+		gpus, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error invalid number of GPUs %q", v)
+		}
+		return ParseGPUs(gpus)
+	default:
+		return 0, fmt.Errorf("error expected number of GPUs to be a number or string but found: %T", raw)
+	}
+}
+
+func parseCPUCores(cores float64) (int64, error) {
+	if cores < 0 || math.IsNaN(cores) || math.IsInf(cores, 0) {
+		return 0, fmt.Errorf("error invalid CPU amount %v", cores)
+	}
+	return int64(math.Round(cores * 1000)), nil
+}
+
+// byteUnits maps the (lower case) units accepted by ParseBytes to their size in bytes. Decimal units
+// (KB, MB, GB, TB) are powers of 1000 and binary units (KiB, MiB, GiB, TiB) are powers of 1024; the
+// trailing 'B' is optional.
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// ParseBytes parses an amount of memory or disk space, given either as a number of bytes or as a number
+// with a unit suffix (e.g. 512Mi, 2GiB or 10GB), and returns it in bytes.
+func ParseBytes(raw interface{}) (int64, error) {
+	switch v := raw.(type) {
+	case int:
+		if v < 0 {
+			return 0, fmt.Errorf("error invalid size %d", v)
+		}
+		return int64(v), nil
+	case int64:
+		if v < 0 {
+			return 0, fmt.Errorf("error invalid size %d", v)
+		}
+		return v, nil
+	case float64:
+		if v < 0 || v != math.Trunc(v) || v > math.MaxInt64 {
+			return 0, fmt.Errorf("error invalid size %v", v)
+		}
+		return int64(v), nil
+	case string:
+		str := strings.TrimSpace(v)
+		split := strings.IndexFunc(str, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		number, unit := str, ""
+		if split >= 0 {
+			number, unit = str[:split], strings.TrimSpace(str[split:])
+		}
+		multiplier, ok := byteUnits[strings.ToLower(unit)]
+		if !ok {
+			return 0, fmt.Errorf("error invalid size %q: unknown unit %q", v, unit)
+		}
+		amount, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error invalid size %q", v)
+		}
+		bytes := math.Round(amount * multiplier)
+		if bytes > math.MaxInt64 {
+			return 0, fmt.Errorf("error invalid size %q: too large", v)
+		}
+		return int64(bytes), nil
+	default:
+		return 0, fmt.Errorf("error expected size to be a number or string but found: %T", raw)
+	}
+}
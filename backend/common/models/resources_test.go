@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUMillis(t *testing.T) {
+	valid := map[interface{}]int64{
+		2:       2000,
+		1.5:     1500,
+		"0.25":  250,
+		"500m":  500,
+		" 4 ":   4000,
+		"1000m": 1000,
+	}
+	for raw, expected := range valid {
+		millis, err := ParseCPUMillis(raw)
+		require.NoError(t, err, "parsing %v", raw)
+		require.Equal(t, expected, millis, "parsing %v", raw)
+	}
+	for _, raw := range []interface{}{"", "two", "-1", "1.5m", -1, true} {
+		_, err := ParseCPUMillis(raw)
+		require.Error(t, err, "parsing %v", raw)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	valid := map[interface{}]int64{
+		1024:          1024,
+		"1024":        1024,
+		"512Mi":       512 << 20,
+		"2GiB":        2 << 30,
+		"1.5Gi":       3 << 29,
+		"10GB":        10e9,
+		"10 gb":       10e9,
+		"100k":        100e3,
+		"64b":         64,
+		float64(2048): 2048,
+	}
+	for raw, expected := range valid {
+		bytes, err := ParseBytes(raw)
+		require.NoError(t, err, "parsing %v", raw)
+		require.Equal(t, expected, bytes, "parsing %v", raw)
+	}
+	for _, raw := range []interface{}{"", "lots", "10XB", "-5Gi", -1, 1.5, false} {
+		_, err := ParseBytes(raw)
+		require.Error(t, err, "parsing %v", raw)
+	}
+}
+
+func TestResourcesFitsWithin(t *testing.T) {
+	capacity := Resources{CPUMillis: 4000, MemoryBytes: 8 << 30}
+	require.True(t, Resources{}.FitsWithin(capacity))
+	require.True(t, Resources{CPUMillis: 4000, MemoryBytes: 8 << 30}.FitsWithin(capacity))
+	// The capacity doesn't limit disk, so any amount fits
+	require.True(t, Resources{DiskBytes: 1 << 40}.FitsWithin(capacity))
+	require.False(t, Resources{CPUMillis: 4001}.FitsWithin(capacity))
+	require.False(t, Resources{MemoryBytes: 9 << 30}.FitsWithin(capacity))
+	require.True(t, Resources{CPUMillis: 64000}.FitsWithin(Resources{}))
+	// Unlike other resources, a capacity without GPUs has none to give
+	require.False(t, Resources{GPUs: 1}.FitsWithin(capacity))
+	require.True(t, Resources{GPUs: 2}.FitsWithin(Resources{GPUs: 2}))
+	require.False(t, Resources{GPUs: 3}.FitsWithin(Resources{GPUs: 2}))
+}
+
+func TestParseGPUs(t *testing.T) {
+	valid := map[interface{}]int64{
+		0:          0,
+		2:          2,
+		"4":        4,
+		" 1 ":      1,
+		float64(8): 8,
+	}
+	for raw, expected := range valid {
+		gpus, err := ParseGPUs(raw)
+		require.NoError(t, err, "parsing %v", raw)
+		require.Equal(t, expected, gpus, "parsing %v", raw)
+	}
+	for _, raw := range []interface{}{"", "all", "1.5", -1, 0.5, true} {
+		_, err := ParseGPUs(raw)
+		require.Error(t, err, "parsing %v", raw)
+	}
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const RoleResourceKind ResourceKind = "role"
+
+type RoleID struct {
+	ResourceID
+}
+
+func NewRoleID() RoleID {
+	return RoleID{ResourceID: NewResourceID(RoleResourceKind)}
+}
+
+func RoleIDFromResourceID(id ResourceID) RoleID {
+	return RoleID{ResourceID: id}
+}
+
+// roleAssignableOperations are the operations that can be bundled into a custom role. Roles can't grant
+// anything an administrator of the organization couldn't already do; in particular reading secret
+// plaintext remains reserved for build agents.
+var roleAssignableOperations = func() map[Operation]bool {
+	operations := make(map[Operation]bool)
+	for _, operation := range AdminStandardGroup.Operations {
+		operations[*operation] = true
+	}
+	return operations
+}()
+
+// IsRoleAssignableOperation returns true if the operation can be included in a custom role.
+func IsRoleAssignableOperation(operation Operation) bool {
+	return roleAssignableOperations[operation]
+}
+
+// RoleOperations is the set of operations a role grants on the organization that owns it, and on any
+// resource the organization owns.
+type RoleOperations []Operation
+
+func (m *RoleOperations) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m RoleOperations) Value() (driver.Value, error) {
+	if m == nil {
+		m = RoleOperations{}
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+// Pointers returns the operations as a list of pointers, as expected by the authorization service.
+func (m RoleOperations) Pointers() []*Operation {
+	operations := make([]*Operation, len(m))
+	for i := range m {
+		operations[i] = &m[i]
+	}
+	return operations
+}
+
+func (m RoleOperations) Validate() error {
+	var result *multierror.Error
+	if len(m) == 0 {
+		result = multierror.Append(result, errors.New("error at least one operation must be set"))
+	}
+	seen := make(map[Operation]bool)
+	for _, operation := range m {
+		if seen[operation] {
+			result = multierror.Append(result, fmt.Errorf("error operation %s is listed more than once", operation))
+		}
+		seen[operation] = true
+		if !IsRoleAssignableOperation(operation) {
+			result = multierror.Append(result, fmt.Errorf("error operation %s can not be granted by a role", operation))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// Role is a named bundle of operations defined by an organization. Assigning a role to one of the
+// organization's groups or to one of its members grants them each of the role's operations on the organization.
+type Role struct {
+	ID            RoleID        `json:"id" goqu:"skipupdate" db:"access_control_role_id"`
+	LegalEntityID LegalEntityID `json:"legal_entity_id" goqu:"skipupdate" db:"access_control_role_legal_entity_id"`
+	CreatedAt     Time          `json:"created_at" goqu:"skipupdate" db:"access_control_role_created_at"`
+	UpdatedAt     Time          `json:"updated_at" db:"access_control_role_updated_at"`
+	ETag          ETag          `json:"etag" db:"access_control_role_etag" hash:"ignore"`
+	// Name of the role, unique within the organization.
+	Name ResourceName `json:"name" db:"access_control_role_name"`
+	// Description is a human-readable description of the role.
+	Description string `json:"description" db:"access_control_role_description"`
+	// Operations are the operations granted to the role's assignees.
+	Operations RoleOperations `json:"operations" db:"access_control_role_operations"`
+}
+
+func NewRole(now Time, legalEntityID LegalEntityID, name ResourceName, description string, operations RoleOperations) *Role {
+	return &Role{
+		ID:            NewRoleID(),
+		LegalEntityID: legalEntityID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Name:          name,
+		Description:   description,
+		Operations:    operations,
+	}
+}
+
+func (m *Role) GetKind() ResourceKind {
+	return RoleResourceKind
+}
+
+func (m *Role) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *Role) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *Role) GetParentID() ResourceID {
+	return m.LegalEntityID.ResourceID
+}
+
+func (m *Role) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *Role) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *Role) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *Role) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+func (m *Role) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if err := m.Name.Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := m.Operations.Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
+
+// RoleTemplate describes a commonly needed role that organizations can create without listing its operations.
+type RoleTemplate struct {
+	Name        ResourceName
+	Description string
+	Operations  []*Operation
+}
+
+// NewRoleFromTemplate creates a role for an organization based on a role template.
+func NewRoleFromTemplate(now Time, legalEntityID LegalEntityID, template *RoleTemplate) *Role {
+	operations := make(RoleOperations, 0, len(template.Operations))
+	for _, operation := range template.Operations {
+		operations = append(operations, *operation)
+	}
+	return NewRole(now, legalEntityID, template.Name, template.Description, operations)
+}
+
+// AllRoleTemplates lists the role templates available to every organization.
+var AllRoleTemplates = []*RoleTemplate{
+	ReleaseManagerRoleTemplate,
+	SecretsAdminRoleTemplate,
+}
+
+// LookupRoleTemplate returns the role template with the specified name, or nil if there is no such template.
+func LookupRoleTemplate(name ResourceName) *RoleTemplate {
+	for _, template := range AllRoleTemplates {
+		if template.Name == name {
+			return template
+		}
+	}
+	return nil
+}
+
+// ReleaseManagerRoleTemplate is for people who run and prioritize builds and manage their artifacts, without
+// being able to change secrets or access control.
+var ReleaseManagerRoleTemplate = &RoleTemplate{
+	Name:        ResourceName("release-manager"),
+	Description: "Release managers can run and prioritize builds and manage build artifacts for repos owned by the organization.",
+	Operations: []*Operation{
+		LegalEntityReadOperation,
+		RepoReadOperation,
+		RepoUpdateOperation,
+		BuildReadOperation,
+		BuildCreateOperation,
+		BuildPrioritizeOperation,
+		ArtifactReadOperation,
+		ArtifactDeleteOperation,
+	},
+}
+
+// SecretsAdminRoleTemplate is for people who manage the secrets of the organization's repos.
+var SecretsAdminRoleTemplate = &RoleTemplate{
+	Name:        ResourceName("secrets-admin"),
+	Description: "Secrets administrators can create, update and delete secrets for repos owned by the organization.",
+	Operations: []*Operation{
+		LegalEntityReadOperation,
+		RepoReadOperation,
+		SecretCreateOperation,
+		SecretReadOperation,
+		SecretUpdateOperation,
+		SecretDeleteOperation,
+	},
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const RoleAssignmentResourceKind ResourceKind = "role-assignment"
+
+type RoleAssignmentID struct {
+	ResourceID
+}
+
+func NewRoleAssignmentID() RoleAssignmentID {
+	return RoleAssignmentID{ResourceID: NewResourceID(RoleAssignmentResourceKind)}
+}
+
+func RoleAssignmentIDFromResourceID(id ResourceID) RoleAssignmentID {
+	return RoleAssignmentID{ResourceID: id}
+}
+
+// RoleAssignment assigns a role to either an access control group or an identity. While the assignment
+// exists the assignee holds a grant for each of the role's operations on the organization that owns the role.
+type RoleAssignment struct {
+	ID        RoleAssignmentID `json:"id" goqu:"skipupdate" db:"access_control_role_assignment_id"`
+	RoleID    RoleID           `json:"role_id" goqu:"skipupdate" db:"access_control_role_assignment_role_id"`
+	CreatedAt Time             `json:"created_at" goqu:"skipupdate" db:"access_control_role_assignment_created_at"`
+	// AuthorizedIdentityID is the id of the identity the role is assigned to, if the role is assigned
+	// directly to a specific identity.
+	AuthorizedIdentityID IdentityID `json:"authorized_identity_id" goqu:"skipupdate" db:"access_control_role_assignment_authorized_identity_id"`
+	// AuthorizedGroupID is the id of the access control Group the role is assigned to, if the role is
+	// assigned to a group.
+	AuthorizedGroupID GroupID `json:"authorized_group_id" goqu:"skipupdate" db:"access_control_role_assignment_authorized_group_id"`
+}
+
+func NewIdentityRoleAssignment(now Time, roleID RoleID, authorizedIdentityID IdentityID) *RoleAssignment {
+	return &RoleAssignment{
+		ID:                   NewRoleAssignmentID(),
+		RoleID:               roleID,
+		CreatedAt:            now,
+		AuthorizedIdentityID: authorizedIdentityID,
+	}
+}
+
+func NewGroupRoleAssignment(now Time, roleID RoleID, authorizedGroupID GroupID) *RoleAssignment {
+	return &RoleAssignment{
+		ID:                NewRoleAssignmentID(),
+		RoleID:            roleID,
+		CreatedAt:         now,
+		AuthorizedGroupID: authorizedGroupID,
+	}
+}
+
+func (m *RoleAssignment) GetKind() ResourceKind {
+	return RoleAssignmentResourceKind
+}
+
+func (m *RoleAssignment) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *RoleAssignment) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *RoleAssignment) GetParentID() ResourceID {
+	return m.RoleID.ResourceID
+}
+
+// GetAuthorizedResourceID returns the resource ID of the group or identity the role is assigned to.
+func (m *RoleAssignment) GetAuthorizedResourceID() ResourceID {
+	if m.AuthorizedIdentityID.Valid() {
+		return m.AuthorizedIdentityID.ResourceID
+	}
+	return m.AuthorizedGroupID.ResourceID
+}
+
+func (m *RoleAssignment) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.RoleID.Valid() {
+		result = multierror.Append(result, errors.New("error role id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.AuthorizedIdentityID.Valid() == m.AuthorizedGroupID.Valid() {
+		result = multierror.Append(result, errors.New("error exactly one of authorized identity id or group id must be set"))
+	}
+	return result.ErrorOrNil()
+}
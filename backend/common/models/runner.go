@@ -41,6 +41,30 @@ type Runner struct {
 	Labels Labels `json:"labels" db:"runner_labels"`
 	// Enabled specifies if this runner is available to process jobs.
 	Enabled bool `json:"enabled" db:"runner_enabled"`
+	// Health is the most recent health report received from the runner, or nil if the runner has never
+	// reported its health. Runners that are unhealthy are not given jobs to run, even if they are enabled.
+	Health *RunnerHealth `json:"health" db:"runner_health"`
+	// LastSeenAt is the time the most recent heartbeat was received from the runner, or nil if the runner has
+	// never sent a heartbeat.
+	LastSeenAt *Time `json:"last_seen_at" db:"runner_last_seen_at"`
+	// Online is true if the runner has sent a heartbeat recently. Runners are marked offline when no heartbeat
+	// has been received for a while, and online again when the next heartbeat arrives.
+	Online bool `json:"online" db:"runner_online"`
+	// CapacityCPUMillis is the CPU the runner makes available to jobs, in thousandths of a core, or zero
+	// if the runner doesn't limit the CPU its jobs may require.
+	CapacityCPUMillis int64 `json:"capacity_cpu_millis" db:"runner_capacity_cpu_millis"`
+	// CapacityMemoryBytes is the memory the runner makes available to jobs, or zero if the runner doesn't
+	// limit the memory its jobs may require.
+	CapacityMemoryBytes int64 `json:"capacity_memory_bytes" db:"runner_capacity_memory_bytes"`
+	// CapacityDiskBytes is the disk space the runner makes available to jobs, or zero if the runner doesn't
+	// limit the disk space its jobs may require.
+	CapacityDiskBytes int64 `json:"capacity_disk_bytes" db:"runner_capacity_disk_bytes"`
+	// CapacityGPUs is the number of GPUs the runner makes available to jobs. Jobs that require GPUs only
+	// run on runners with enough GPUs.
+	CapacityGPUs int64 `json:"capacity_gpus" db:"runner_capacity_gpus"`
+	// ExecJobAllowlist lists the repos whose jobs the runner will run if they use the exec job type, or nil
+	// if the runner hasn't reported an allowlist, in which case it may be given exec jobs from any repo.
+	ExecJobAllowlist ExecJobAllowlist `json:"exec_job_allowlist" db:"runner_exec_job_allowlist"`
 }
 
 func NewRunner(
@@ -69,6 +93,19 @@ func NewRunner(
 	}
 }
 
+// GetCapacity returns the resources the runner makes available to the jobs running on it at any one time.
+func (m *Runner) GetCapacity() Resources {
+	return Resources{CPUMillis: m.CapacityCPUMillis, MemoryBytes: m.CapacityMemoryBytes, DiskBytes: m.CapacityDiskBytes, GPUs: m.CapacityGPUs}
+}
+
+// SetCapacity sets the resources the runner makes available to the jobs running on it at any one time.
+func (m *Runner) SetCapacity(capacity Resources) {
+	m.CapacityCPUMillis = capacity.CPUMillis
+	m.CapacityMemoryBytes = capacity.MemoryBytes
+	m.CapacityDiskBytes = capacity.DiskBytes
+	m.CapacityGPUs = capacity.GPUs
+}
+
 func (m *Runner) GetKind() ResourceKind {
 	return RunnerResourceKind
 }
@@ -113,6 +150,11 @@ func (m *Runner) SetDeletedAt(deletedAt *Time) {
 	m.DeletedAt = deletedAt
 }
 
+// IsHealthy returns true unless the runner's most recent health report found problems with the runner.
+func (m *Runner) IsHealthy() bool {
+	return m.Health == nil || m.Health.Healthy()
+}
+
 func (m *Runner) IsUnreachable() bool {
 	// Runners should never be unreachable, even after being soft-deleted
 	return false
@@ -144,5 +186,8 @@ func (m *Runner) Validate() error {
 			result = multierror.Append(result, fmt.Errorf("error validating label %q: %w", label, err))
 		}
 	}
+	if err := m.GetCapacity().Validate(); err != nil {
+		result = multierror.Append(result, fmt.Errorf("error validating capacity: %w", err))
+	}
 	return result.ErrorOrNil()
 }
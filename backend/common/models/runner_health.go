@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// RunnerHealthChangedEvent is emitted when a runner becomes unhealthy, or becomes healthy again.
+const RunnerHealthChangedEvent EventType = "RunnerHealthChanged"
+
+// RunnerHealthReport is sent periodically by a runner as a heartbeat, describing the health of the host
+// it is running on.
+type RunnerHealthReport struct {
+	// Time is the time on the runner's clock when the report was made, used to detect clock skew.
+	Time Time `json:"time"`
+	// DiskPath is the path on the runner's host that disk space was measured for; this is where jobs
+	// are checked out and run.
+	DiskPath string `json:"disk_path"`
+	// DiskFreeBytes is the number of bytes available to the runner at DiskPath.
+	DiskFreeBytes uint64 `json:"disk_free_bytes"`
+	// DiskTotalBytes is the size of the filesystem containing DiskPath.
+	DiskTotalBytes uint64 `json:"disk_total_bytes"`
+	// DiskError is the error returned when measuring disk space, if it could not be measured.
+	DiskError string `json:"disk_error,omitempty"`
+	// DockerReachable is true if the runner was able to contact the docker daemon, or nil if the runner
+	// is configured not to check docker (e.g. because it is only used to run exec jobs).
+	DockerReachable *bool `json:"docker_reachable,omitempty"`
+	// DockerError is the error returned when contacting the docker daemon, if it was not reachable.
+	DockerError string `json:"docker_error,omitempty"`
+	// SoftwareVersion is the software version of the runner process, if known.
+	SoftwareVersion string `json:"software_version,omitempty"`
+	// CurrentJobIDs lists the jobs the runner is running at the time of the report.
+	CurrentJobIDs []JobID `json:"current_job_ids,omitempty"`
+	// Resources describes the CPU and memory usage of the runner's host, or nil if it could not be measured.
+	Resources *RunnerResourceStats `json:"resources,omitempty"`
+}
+
+// RunnerResourceStats describes the CPU and memory usage of a runner's host. Fields are zero if they could
+// not be measured on the runner's operating system.
+type RunnerResourceStats struct {
+	// CPUCount is the number of logical CPUs available to the runner.
+	CPUCount int `json:"cpu_count"`
+	// LoadAverage is the one-minute load average of the runner's host.
+	LoadAverage float64 `json:"load_average"`
+	// MemoryTotalBytes is the total physical memory of the runner's host.
+	MemoryTotalBytes uint64 `json:"memory_total_bytes"`
+	// MemoryAvailableBytes is the memory available for starting new processes without swapping.
+	MemoryAvailableBytes uint64 `json:"memory_available_bytes"`
+}
+
+func (m *RunnerHealthReport) Validate() error {
+	var result *multierror.Error
+	if m.Time.IsZero() {
+		result = multierror.Append(result, errors.New("error time must be set"))
+	}
+	if m.DiskFreeBytes > m.DiskTotalBytes {
+		result = multierror.Append(result, errors.New("error disk free bytes must not be greater than disk total bytes"))
+	}
+	for _, jobID := range m.CurrentJobIDs {
+		if !jobID.Valid() || jobID.Kind() != JobResourceKind {
+			result = multierror.Append(result, fmt.Errorf("error current job id %q is not a valid job id", jobID))
+		}
+	}
+	if m.Resources != nil && m.Resources.MemoryAvailableBytes > m.Resources.MemoryTotalBytes {
+		result = multierror.Append(result, errors.New("error memory available bytes must not be greater than memory total bytes"))
+	}
+	return result.ErrorOrNil()
+}
+
+// RunnerHealth is the most recent health report received from a runner, along with the server's assessment
+// of whether the runner is healthy enough to run jobs.
+type RunnerHealth struct {
+	RunnerHealthReport
+	// ReceivedAt is the time on the server's clock when the report was received.
+	ReceivedAt Time `json:"received_at"`
+	// ClockSkewSeconds is how far the runner's clock was ahead of the server's clock when the report was
+	// received, or negative if the runner's clock was behind.
+	ClockSkewSeconds float64 `json:"clock_skew_seconds"`
+	// Problems lists the reasons the runner is unhealthy, or is empty if the runner is healthy.
+	Problems []string `json:"problems"`
+}
+
+// Healthy returns true if no problems were found with the runner.
+func (m *RunnerHealth) Healthy() bool {
+	return len(m.Problems) == 0
+}
+
+func (m *RunnerHealth) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m *RunnerHealth) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,310 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const RunnerPoolResourceKind ResourceKind = "runner-pool"
+
+type RunnerPoolID struct {
+	ResourceID
+}
+
+func NewRunnerPoolID() RunnerPoolID {
+	return RunnerPoolID{ResourceID: NewResourceID(RunnerPoolResourceKind)}
+}
+
+func RunnerPoolIDFromResourceID(id ResourceID) RunnerPoolID {
+	return RunnerPoolID{ResourceID: id}
+}
+
+type RunnerProvisionerType string
+
+const (
+	// RunnerProvisionerTypeEC2 starts runners by launching EC2 instances from a launch template.
+	RunnerProvisionerTypeEC2 RunnerProvisionerType = "ec2"
+	// RunnerProvisionerTypeKubernetes starts runners by scaling a Kubernetes Deployment.
+	RunnerProvisionerTypeKubernetes RunnerProvisionerType = "kubernetes"
+	// RunnerProvisionerTypeWebhook asks an external system to start runners by posting to a URL.
+	RunnerProvisionerTypeWebhook RunnerProvisionerType = "webhook"
+)
+
+func (t RunnerProvisionerType) Valid() bool {
+	return t == RunnerProvisionerTypeEC2 || t == RunnerProvisionerTypeKubernetes || t == RunnerProvisionerTypeWebhook
+}
+
+func (t RunnerProvisionerType) String() string {
+	return string(t)
+}
+
+// RunnerProvisionerConfig contains the settings used to start and stop the runners in a pool. Only the
+// section matching the pool's provisioner type is used.
+type RunnerProvisionerConfig struct {
+	EC2        *EC2ProvisionerConfig        `json:"ec2,omitempty"`
+	Kubernetes *KubernetesProvisionerConfig `json:"kubernetes,omitempty"`
+	Webhook    *WebhookProvisionerConfig    `json:"webhook,omitempty"`
+}
+
+// EC2ProvisionerConfig launches runners as EC2 instances. Requests to EC2 are authenticated using the
+// server's AWS credentials.
+type EC2ProvisionerConfig struct {
+	// Region is the AWS region to launch instances in.
+	Region string `json:"region"`
+	// LaunchTemplateID is the ID of the launch template to launch instances from. The template should
+	// start a runner that registers itself with this server using the pool's labels.
+	LaunchTemplateID string `json:"launch_template_id"`
+	// LaunchTemplateVersion is the version of the launch template to use, or empty to use the default version.
+	LaunchTemplateVersion string `json:"launch_template_version,omitempty"`
+}
+
+// KubernetesProvisionerConfig runs runners as the pods of a Deployment, which is scaled to the number of
+// runners required. The pool's secret, if set, is used as a bearer token to authenticate to the API server.
+type KubernetesProvisionerConfig struct {
+	// APIServerURL is the base URL of the Kubernetes API server.
+	APIServerURL string `json:"api_server_url"`
+	// CACertificate is an optional PEM-encoded certificate used to verify the API server's certificate.
+	CACertificate string `json:"ca_certificate,omitempty"`
+	// Namespace is the namespace containing the Deployment.
+	Namespace string `json:"namespace"`
+	// Deployment is the name of the Deployment to scale.
+	Deployment string `json:"deployment"`
+}
+
+// WebhookProvisionerConfig asks an external system to start or stop runners by posting the desired number
+// of runners to a URL. The pool's secret, if set, is used to sign each request.
+type WebhookProvisionerConfig struct {
+	// URL is the endpoint to post scaling requests to.
+	URL string `json:"url"`
+}
+
+func (m *RunnerProvisionerConfig) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m RunnerProvisionerConfig) Value() (driver.Value, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+// RunnerPool is a group of identically configured runners belonging to a legal entity, which are started
+// and stopped automatically by a provisioner as jobs that need the pool's labels are queued and finished.
+type RunnerPool struct {
+	ID            RunnerPoolID  `json:"id" goqu:"skipupdate" db:"runner_pool_id"`
+	LegalEntityID LegalEntityID `json:"legal_entity_id" goqu:"skipupdate" db:"runner_pool_legal_entity_id"`
+	Name          ResourceName  `json:"name" db:"runner_pool_name"`
+	CreatedAt     Time          `json:"created_at" goqu:"skipupdate" db:"runner_pool_created_at"`
+	UpdatedAt     Time          `json:"updated_at" db:"runner_pool_updated_at"`
+	ETag          ETag          `json:"etag" db:"runner_pool_etag" hash:"ignore"`
+	// Labels is the set of labels the pool's runners are configured with. Jobs count towards the pool's
+	// demand if the pool's runners have every label the job requires.
+	Labels Labels `json:"labels" db:"runner_pool_labels"`
+	// MinRunners is the number of runners to keep running even when there are no jobs to run.
+	MinRunners int `json:"min_runners" db:"runner_pool_min_runners"`
+	// MaxRunners is the largest number of runners the pool is allowed to scale up to.
+	MaxRunners int `json:"max_runners" db:"runner_pool_max_runners"`
+	// ScaleDownIdleTimeoutSeconds is how long the pool must have had more runners than it needed before
+	// it is scaled down.
+	ScaleDownIdleTimeoutSeconds int `json:"scale_down_idle_timeout_seconds" db:"runner_pool_scale_down_idle_timeout_seconds"`
+	// ProvisionerType is the type of provisioner that starts and stops the pool's runners.
+	ProvisionerType RunnerProvisionerType `json:"provisioner_type" db:"runner_pool_provisioner_type"`
+	// ProvisionerConfig contains the settings for the provisioner.
+	ProvisionerConfig RunnerProvisionerConfig `json:"provisioner_config" db:"runner_pool_provisioner_config"`
+	// SecretEncrypted is an optional credential for the provisioner, encrypted using DataKeyEncrypted.
+	SecretEncrypted BinaryBlob `json:"-" db:"runner_pool_secret_encrypted"`
+	// DataKeyEncrypted is the key that can be used to decrypt SecretEncrypted.
+	// This key is itself encrypted and must be decrypted before being used.
+	DataKeyEncrypted BinaryBlob `json:"-" db:"runner_pool_data_key_encrypted"`
+	// DesiredRunners is the number of runners the provisioner was last successfully asked to run.
+	DesiredRunners int `json:"desired_runners" db:"runner_pool_desired_runners"`
+	// LastBusyAt is the last time the pool needed all of its desired runners, or nil if it never has.
+	LastBusyAt *Time `json:"last_busy_at" db:"runner_pool_last_busy_at"`
+	// LastScaledAt is the last time the provisioner was successfully asked to change the number of runners.
+	LastScaledAt *Time `json:"last_scaled_at" db:"runner_pool_last_scaled_at"`
+	// LastError describes why the most recent attempt to scale the pool failed, or is empty if it succeeded.
+	LastError string `json:"last_error" db:"runner_pool_last_error"`
+}
+
+func NewRunnerPool(
+	now Time,
+	legalEntityID LegalEntityID,
+	name ResourceName,
+	labels Labels,
+	minRunners int,
+	maxRunners int,
+	scaleDownIdleTimeout time.Duration,
+	provisionerType RunnerProvisionerType,
+	provisionerConfig RunnerProvisionerConfig,
+	secretEncrypted []byte,
+	dataKeyEncrypted []byte) *RunnerPool {
+
+	return &RunnerPool{
+		ID:                          NewRunnerPoolID(),
+		LegalEntityID:               legalEntityID,
+		Name:                        name,
+		CreatedAt:                   now,
+		UpdatedAt:                   now,
+		Labels:                      labels,
+		MinRunners:                  minRunners,
+		MaxRunners:                  maxRunners,
+		ScaleDownIdleTimeoutSeconds: int(scaleDownIdleTimeout.Seconds()),
+		ProvisionerType:             provisionerType,
+		ProvisionerConfig:           provisionerConfig,
+		SecretEncrypted:             secretEncrypted,
+		DataKeyEncrypted:            dataKeyEncrypted,
+	}
+}
+
+func (m *RunnerPool) GetKind() ResourceKind {
+	return RunnerPoolResourceKind
+}
+
+func (m *RunnerPool) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *RunnerPool) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *RunnerPool) GetParentID() ResourceID {
+	return m.LegalEntityID.ResourceID
+}
+
+func (m *RunnerPool) GetName() ResourceName {
+	return m.Name
+}
+
+func (m *RunnerPool) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *RunnerPool) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *RunnerPool) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *RunnerPool) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+// ScaleDownIdleTimeout returns how long the pool must have had more runners than it needed before it is scaled down.
+func (m *RunnerPool) ScaleDownIdleTimeout() time.Duration {
+	return time.Duration(m.ScaleDownIdleTimeoutSeconds) * time.Second
+}
+
+// TargetRunners returns the number of runners the pool should have to run the specified number of jobs,
+// within the pool's minimum and maximum size.
+func (m *RunnerPool) TargetRunners(demand int) int {
+	if demand < m.MinRunners {
+		return m.MinRunners
+	}
+	if demand > m.MaxRunners {
+		return m.MaxRunners
+	}
+	return demand
+}
+
+// CanRun returns true if a job that requires the specified labels counts towards the pool's demand.
+// Jobs that don't require any labels only count towards pools without labels, so that general purpose
+// jobs don't cause pools of specialised runners to be scaled up.
+func (m *RunnerPool) CanRun(runsOn Labels) bool {
+	if len(runsOn) == 0 {
+		return len(m.Labels) == 0
+	}
+	for _, required := range runsOn {
+		found := false
+		for _, label := range m.Labels {
+			if label == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *RunnerPool) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if err := m.Name.Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	for _, label := range m.Labels {
+		if err := label.Validate(); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	if m.MinRunners < 0 {
+		result = multierror.Append(result, errors.New("error min runners must not be negative"))
+	}
+	if m.MaxRunners < 1 {
+		result = multierror.Append(result, errors.New("error max runners must be at least 1"))
+	}
+	if m.MinRunners > m.MaxRunners {
+		result = multierror.Append(result, errors.New("error min runners must not be greater than max runners"))
+	}
+	if m.ScaleDownIdleTimeoutSeconds < 0 {
+		result = multierror.Append(result, errors.New("error scale down idle timeout must not be negative"))
+	}
+	if (m.SecretEncrypted == nil) != (m.DataKeyEncrypted == nil) {
+		result = multierror.Append(result, errors.New("error secret and data key must be set together"))
+	}
+	switch m.ProvisionerType {
+	case RunnerProvisionerTypeEC2:
+		config := m.ProvisionerConfig.EC2
+		if config == nil || config.Region == "" || config.LaunchTemplateID == "" {
+			result = multierror.Append(result, errors.New("error ec2 provisioner config must include region and launch template id"))
+		}
+	case RunnerProvisionerTypeKubernetes:
+		config := m.ProvisionerConfig.Kubernetes
+		if config == nil || config.APIServerURL == "" || config.Namespace == "" || config.Deployment == "" {
+			result = multierror.Append(result, errors.New("error kubernetes provisioner config must include api server url, namespace and deployment"))
+		}
+	case RunnerProvisionerTypeWebhook:
+		config := m.ProvisionerConfig.Webhook
+		if config == nil || config.URL == "" {
+			result = multierror.Append(result, errors.New("error webhook provisioner config must include url"))
+		}
+	default:
+		result = multierror.Append(result, fmt.Errorf("error provisioner type must be one of %q, %q or %q",
+			RunnerProvisionerTypeEC2, RunnerProvisionerTypeKubernetes, RunnerProvisionerTypeWebhook))
+	}
+	return result.ErrorOrNil()
+}
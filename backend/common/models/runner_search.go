@@ -4,6 +4,8 @@ type RunnerSearch struct {
 	Pagination
 	// LegalEntityID can be set to filter runners to those owned by a specific legal entity.
 	LegalEntityID *LegalEntityID `json:"legal_entity_id"`
+	// Online can be set to filter runners to those that are online (true) or offline (false).
+	Online *bool `json:"online"`
 }
 
 func NewRunnerSearch() *RunnerSearch {
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const SAMLIdentityProviderResourceKind ResourceKind = "saml-identity-provider"
+
+const (
+	// DefaultSAMLEmailAttribute is the assertion attribute containing a user's email address, unless configured otherwise.
+	DefaultSAMLEmailAttribute = "email"
+	// DefaultSAMLNameAttribute is the assertion attribute containing a user's display name, unless configured otherwise.
+	DefaultSAMLNameAttribute = "name"
+	// DefaultSAMLGroupsAttribute is the assertion attribute listing the groups a user is a member of, unless configured otherwise.
+	DefaultSAMLGroupsAttribute = "groups"
+)
+
+// samlMappableGroups are the standard groups that identity provider groups can be mapped to. The base group
+// is excluded since every company member is added to it anyway, as is the runner group since it is not
+// intended for people.
+var samlMappableGroups = map[ResourceName]bool{
+	ReadOnlyUserStandardGroup.Name: true,
+	UserStandardGroup.Name:         true,
+	AdminStandardGroup.Name:        true,
+}
+
+type SAMLIdentityProviderID struct {
+	ResourceID
+}
+
+func NewSAMLIdentityProviderID() SAMLIdentityProviderID {
+	return SAMLIdentityProviderID{ResourceID: NewResourceID(SAMLIdentityProviderResourceKind)}
+}
+
+func SAMLIdentityProviderIDFromResourceID(id ResourceID) SAMLIdentityProviderID {
+	return SAMLIdentityProviderID{ResourceID: id}
+}
+
+// SAMLGroupMapping makes users whose groups attribute contains AttributeValue members of one of the standard
+// access control groups of the company that owns the identity provider.
+type SAMLGroupMapping struct {
+	// AttributeValue is a value of the groups attribute in assertions, e.g. the name of a group in the identity provider.
+	AttributeValue string `json:"attribute_value"`
+	// GroupName is the name of the standard group to add users to; one of "readonly-user", "user" or "admin".
+	GroupName ResourceName `json:"group_name"`
+}
+
+type SAMLGroupMappings []SAMLGroupMapping
+
+func (m *SAMLGroupMappings) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m SAMLGroupMappings) Value() (driver.Value, error) {
+	if m == nil {
+		m = SAMLGroupMappings{}
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
+
+func (m SAMLGroupMappings) Validate() error {
+	var result *multierror.Error
+	for i, mapping := range m {
+		if mapping.AttributeValue == "" {
+			result = multierror.Append(result, fmt.Errorf("error group mapping %d: attribute value must be set", i))
+		}
+		if !samlMappableGroups[mapping.GroupName] {
+			result = multierror.Append(result, fmt.Errorf("error group mapping %d: group name must be one of %q, %q or %q",
+				i, ReadOnlyUserStandardGroup.Name, UserStandardGroup.Name, AdminStandardGroup.Name))
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// SAMLIdentityProvider configures a SAML 2.0 identity provider that members of a company can log in with.
+// Each company has at most one identity provider. Users who log in are provisioned as person legal entities
+// and made members of the company, and their group attribute values are mapped to the company's standard groups.
+type SAMLIdentityProvider struct {
+	ID            SAMLIdentityProviderID `json:"id" goqu:"skipupdate" db:"saml_identity_provider_id"`
+	LegalEntityID LegalEntityID          `json:"legal_entity_id" goqu:"skipupdate" db:"saml_identity_provider_legal_entity_id"`
+	CreatedAt     Time                   `json:"created_at" goqu:"skipupdate" db:"saml_identity_provider_created_at"`
+	UpdatedAt     Time                   `json:"updated_at" db:"saml_identity_provider_updated_at"`
+	ETag          ETag                   `json:"etag" db:"saml_identity_provider_etag" hash:"ignore"`
+	// Enabled is true if users can log in via the identity provider.
+	Enabled bool `json:"enabled" db:"saml_identity_provider_enabled"`
+	// MetadataXML is the identity provider's SAML metadata document, containing its entity ID, single sign-on
+	// URL and signing certificates.
+	MetadataXML string `json:"metadata_xml" db:"saml_identity_provider_metadata_xml"`
+	// EmailAttribute is the name of the assertion attribute containing the user's email address.
+	EmailAttribute string `json:"email_attribute" db:"saml_identity_provider_email_attribute"`
+	// NameAttribute is the name of the assertion attribute containing the user's display name.
+	NameAttribute string `json:"name_attribute" db:"saml_identity_provider_name_attribute"`
+	// UsernameAttribute is the name of the assertion attribute containing the user's preferred username.
+	// If empty, usernames are derived from email addresses.
+	UsernameAttribute string `json:"username_attribute" db:"saml_identity_provider_username_attribute"`
+	// GroupsAttribute is the name of the assertion attribute listing the groups the user is a member of.
+	GroupsAttribute string `json:"groups_attribute" db:"saml_identity_provider_groups_attribute"`
+	// GroupMappings map values of the groups attribute to the company's standard groups.
+	GroupMappings SAMLGroupMappings `json:"group_mappings" db:"saml_identity_provider_group_mappings"`
+}
+
+func NewSAMLIdentityProvider(now Time, legalEntityID LegalEntityID) *SAMLIdentityProvider {
+	return &SAMLIdentityProvider{
+		ID:              NewSAMLIdentityProviderID(),
+		LegalEntityID:   legalEntityID,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		EmailAttribute:  DefaultSAMLEmailAttribute,
+		NameAttribute:   DefaultSAMLNameAttribute,
+		GroupsAttribute: DefaultSAMLGroupsAttribute,
+	}
+}
+
+func (m *SAMLIdentityProvider) GetKind() ResourceKind {
+	return SAMLIdentityProviderResourceKind
+}
+
+func (m *SAMLIdentityProvider) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *SAMLIdentityProvider) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *SAMLIdentityProvider) GetParentID() ResourceID {
+	return m.LegalEntityID.ResourceID
+}
+
+func (m *SAMLIdentityProvider) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *SAMLIdentityProvider) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *SAMLIdentityProvider) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *SAMLIdentityProvider) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+func (m *SAMLIdentityProvider) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if m.Enabled && m.MetadataXML == "" {
+		result = multierror.Append(result, errors.New("error metadata xml must be set to enable the identity provider"))
+	}
+	if m.EmailAttribute == "" {
+		result = multierror.Append(result, errors.New("error email attribute must be set"))
+	}
+	if err := m.GroupMappings.Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
@@ -45,6 +45,11 @@ type Secret struct {
 	// IsInternal is true if this secret is an internal secret generated
 	// by the system (as opposed to being supplied by a user).
 	IsInternal bool `json:"is_internal" db:"secret_is_internal"`
+	// Version is the number of the current version of the secret, incremented each time the secret's
+	// key or value is changed. Prior versions are kept as SecretVersion records.
+	Version int `json:"version" db:"secret_version"`
+	// VersionCreatedAt is the time the current version of the secret was created.
+	VersionCreatedAt Time `json:"version_created_at" db:"secret_version_created_at"`
 }
 
 func NewSecret(now Time, name ResourceName, repoID RepoID, keyEncrypted []byte, valueEncrypted []byte, dataKeyEncrypted []byte, internal bool) *Secret {
@@ -58,6 +63,8 @@ func NewSecret(now Time, name ResourceName, repoID RepoID, keyEncrypted []byte,
 		ValueEncrypted:   valueEncrypted,
 		DataKeyEncrypted: dataKeyEncrypted,
 		IsInternal:       internal,
+		Version:          1,
+		VersionCreatedAt: now,
 	}
 }
 
@@ -115,6 +122,12 @@ func (m *Secret) Validate() error {
 	if m.UpdatedAt.IsZero() {
 		result = multierror.Append(result, errors.New("error updated at must be set"))
 	}
+	if m.Version < 1 {
+		result = multierror.Append(result, errors.New("error version must be at least 1"))
+	}
+	if m.VersionCreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error version created at must be set"))
+	}
 	if m.KeyEncrypted == nil {
 		result = multierror.Append(result, errors.New("error name must be set"))
 	}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const SecretVersionResourceKind ResourceKind = "secret-version"
+
+type SecretVersionID struct {
+	ResourceID
+}
+
+func NewSecretVersionID() SecretVersionID {
+	return SecretVersionID{ResourceID: NewResourceID(SecretVersionResourceKind)}
+}
+
+func SecretVersionIDFromResourceID(id ResourceID) SecretVersionID {
+	return SecretVersionID{ResourceID: id}
+}
+
+// SecretVersion is a prior version of a secret, recorded when the secret's key or value is changed so that
+// builds can keep using the secret as it was when they started, and so there is a history of when the secret
+// was rotated. The version's key and value remain encrypted and are never exposed via the API.
+type SecretVersion struct {
+	ID       SecretVersionID `json:"id" goqu:"skipupdate" db:"secret_version_id"`
+	SecretID SecretID        `json:"secret_id" goqu:"skipupdate" db:"secret_version_secret_id"`
+	RepoID   RepoID          `json:"repo_id" goqu:"skipupdate" db:"secret_version_repo_id"`
+	// Version is the number of this version of the secret; the first version of a secret is 1.
+	Version int `json:"version" goqu:"skipupdate" db:"secret_version_number"`
+	// CreatedAt is the time this version became the current version of the secret.
+	CreatedAt Time `json:"created_at" goqu:"skipupdate" db:"secret_version_created_at"`
+	// SupersededAt is the time this version was replaced by the next version of the secret.
+	SupersededAt Time `json:"superseded_at" goqu:"skipupdate" db:"secret_version_superseded_at"`
+	// KeyEncrypted is the key of the secret as of this version, encrypted using DataKeyEncrypted.
+	KeyEncrypted BinaryBlob `json:"-" goqu:"skipupdate" db:"secret_version_key_encrypted"`
+	// ValueEncrypted is the value of the secret as of this version, encrypted using DataKeyEncrypted.
+	ValueEncrypted BinaryBlob `json:"-" goqu:"skipupdate" db:"secret_version_value_encrypted"`
+	// DataKeyEncrypted is the (encrypted) key that can be used to decrypt KeyEncrypted and ValueEncrypted.
+	DataKeyEncrypted BinaryBlob `json:"-" goqu:"skipupdate" db:"secret_version_data_key_encrypted"`
+}
+
+// NewSecretVersion records the current version of a secret, which is being superseded at the specified time.
+func NewSecretVersion(supersededAt Time, secret *Secret) *SecretVersion {
+	return &SecretVersion{
+		ID:               NewSecretVersionID(),
+		SecretID:         secret.ID,
+		RepoID:           secret.RepoID,
+		Version:          secret.Version,
+		CreatedAt:        secret.VersionCreatedAt,
+		SupersededAt:     supersededAt,
+		KeyEncrypted:     secret.KeyEncrypted,
+		ValueEncrypted:   secret.ValueEncrypted,
+		DataKeyEncrypted: secret.DataKeyEncrypted,
+	}
+}
+
+func (m *SecretVersion) GetKind() ResourceKind {
+	return SecretVersionResourceKind
+}
+
+func (m *SecretVersion) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *SecretVersion) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *SecretVersion) GetParentID() ResourceID {
+	return m.SecretID.ResourceID
+}
+
+// ApplyTo returns a copy of the secret with its key and value replaced by those from this version.
+func (m *SecretVersion) ApplyTo(secret *Secret) *Secret {
+	versioned := *secret
+	versioned.Version = m.Version
+	versioned.VersionCreatedAt = m.CreatedAt
+	versioned.KeyEncrypted = m.KeyEncrypted
+	versioned.ValueEncrypted = m.ValueEncrypted
+	versioned.DataKeyEncrypted = m.DataKeyEncrypted
+	return &versioned
+}
+
+func (m *SecretVersion) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.SecretID.Valid() {
+		result = multierror.Append(result, errors.New("error secret id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if m.Version < 1 {
+		result = multierror.Append(result, errors.New("error version must be at least 1"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.SupersededAt.IsZero() {
+		result = multierror.Append(result, errors.New("error superseded at must be set"))
+	}
+	if m.KeyEncrypted == nil {
+		result = multierror.Append(result, errors.New("error name must be set"))
+	}
+	if m.ValueEncrypted == nil {
+		result = multierror.Append(result, errors.New("error value must be set"))
+	}
+	if m.DataKeyEncrypted == nil {
+		result = multierror.Append(result, errors.New("error data key must be set"))
+	}
+	return result.ErrorOrNil()
+}
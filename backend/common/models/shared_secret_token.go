@@ -81,6 +81,27 @@ func (m PublicSharedSecretToken) String() string {
 	return base64.StdEncoding.EncodeToString([]byte(str))
 }
 
+// PersonalAccessTokenString returns the string representation of the token for use as a personal access
+// token. The prefix makes personal access tokens easy to tell apart from other tokens, including by secret
+// scanners looking for leaked tokens.
+func (m PublicSharedSecretToken) PersonalAccessTokenString() string {
+	return PersonalAccessTokenPrefix + m.String()
+}
+
+// IsPersonalAccessToken returns true if str is in the format returned by PersonalAccessTokenString().
+func IsPersonalAccessToken(str string) bool {
+	return strings.HasPrefix(str, PersonalAccessTokenPrefix)
+}
+
+// NewPublicSharedSecretTokenFromPersonalAccessToken initializes a public shared secret token from the string
+// previously returned by the token's PersonalAccessTokenString() function.
+func NewPublicSharedSecretTokenFromPersonalAccessToken(str string) (PublicSharedSecretToken, error) {
+	if !IsPersonalAccessToken(str) {
+		return PublicSharedSecretToken{}, errors.New("Invalid personal access token format")
+	}
+	return NewPublicSharedSecretTokenFromString(strings.TrimPrefix(str, PersonalAccessTokenPrefix))
+}
+
 func (m PublicSharedSecretToken) IsValid(salt []byte, hash []byte) (bool, error) {
 	computedHash := sha256.Sum256(append(m.data, salt...))
 	return bytes.Equal(computedHash[:], hash), nil
@@ -61,6 +61,20 @@ type StepDefinitionData struct {
 	Commands Commands `json:"commands" db:"step_commands"`
 	// Depends describes the dependencies this step has on other steps within the parent job.
 	Depends StepDependencies `json:"depends" db:"step_depends"`
+	// ArtifactDefinitions contains a list of artifacts the step is expected to produce. These are uploaded
+	// as soon as the step finishes (even if it fails) and belong to the parent job, alongside any artifacts
+	// declared at the job level.
+	ArtifactDefinitions ArtifactDefinitions `json:"artifact_definitions" db:"step_artifact_definitions"`
+	// TimeoutSeconds is the maximum time the step's commands may run for before they are killed and the
+	// step fails, or zero if the step has no timeout.
+	TimeoutSeconds int64 `json:"timeout_seconds" db:"step_timeout_seconds"`
+	// EchoCommands is true to print each of the step's commands to the step's log as it runs (like sh's
+	// 'set -x'), false to not print commands, or nil to use the shell's default (cmd prints commands, other
+	// shells don't).
+	EchoCommands *bool `json:"echo_commands" db:"step_echo_commands"`
+	// Condition is an optional expression that must be true for the step to run. If the condition is false
+	// the step (and any steps that depend on it) will be skipped.
+	Condition string `json:"condition" db:"step_condition"`
 }
 
 func (m *Step) GetKind() ResourceKind {
@@ -163,6 +177,21 @@ func (m *Step) Validate() error {
 			result = multierror.Append(result, errors.Errorf("error commands cannot be empty (index %d)", i))
 		}
 	}
+	if m.TimeoutSeconds < 0 {
+		result = multierror.Append(result, errors.New("error timeout must not be negative"))
+	}
+	artifactsByName := make(map[ResourceName]*ArtifactDefinition, len(m.ArtifactDefinitions))
+	for i, artifact := range m.ArtifactDefinitions {
+		err := artifact.Validate()
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "error validating artifact %q (index %d)", artifact.GroupName, i))
+		}
+		_, ok := artifactsByName[artifact.GroupName]
+		if ok {
+			return errors.Errorf("error duplicate artifact definition %q; Artifacts must have unique names", artifact.GroupName)
+		}
+		artifactsByName[artifact.GroupName] = artifact
+	}
 	return result.ErrorOrNil()
 }
 
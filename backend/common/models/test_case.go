@@ -0,0 +1,192 @@
+package models
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+)
+
+const TestCaseResourceKind ResourceKind = "test-case"
+
+// MaxTestCaseMessageLength is the maximum length of the failure message recorded against a test case;
+// longer messages are truncated.
+const MaxTestCaseMessageLength = 4096
+
+type TestCaseID struct {
+	ResourceID
+}
+
+func NewTestCaseID() TestCaseID {
+	return TestCaseID{ResourceID: NewResourceID(TestCaseResourceKind)}
+}
+
+func TestCaseIDFromResourceID(id ResourceID) TestCaseID {
+	return TestCaseID{ResourceID: id}
+}
+
+type TestCaseStatus string
+
+const (
+	// TestCaseStatusPassed means the test passed on every attempt.
+	TestCaseStatusPassed TestCaseStatus = "passed"
+	// TestCaseStatusFailed means the test failed (or errored) on every attempt.
+	TestCaseStatusFailed TestCaseStatus = "failed"
+	// TestCaseStatusSkipped means the test was skipped.
+	TestCaseStatusSkipped TestCaseStatus = "skipped"
+	// TestCaseStatusFlaky means the test failed on some attempts and passed on others.
+	TestCaseStatusFlaky TestCaseStatus = "flaky"
+)
+
+func (s TestCaseStatus) Valid() bool {
+	return s == TestCaseStatusPassed ||
+		s == TestCaseStatusFailed ||
+		s == TestCaseStatusSkipped ||
+		s == TestCaseStatusFlaky
+}
+
+func (s TestCaseStatus) String() string {
+	return string(s)
+}
+
+// CombineTestCaseStatuses returns the overall status of a test given the number of attempts at the test
+// that passed, failed and were skipped.
+func CombineTestCaseStatuses(passed int, failed int, skipped int) TestCaseStatus {
+	switch {
+	case passed > 0 && failed > 0:
+		return TestCaseStatusFlaky
+	case failed > 0:
+		return TestCaseStatusFailed
+	case passed > 0:
+		return TestCaseStatusPassed
+	default:
+		return TestCaseStatusSkipped
+	}
+}
+
+// TestCase records the result of a single test within a test run. If a test was attempted more than once
+// in the same run (e.g. because it was retried) then the attempts are combined into a single test case.
+type TestCase struct {
+	ID        TestCaseID `json:"id" goqu:"skipupdate" db:"test_case_id"`
+	CreatedAt Time       `json:"created_at" goqu:"skipupdate" db:"test_case_created_at"`
+	// TestRunID is the ID of the test run the test case was reported in.
+	TestRunID TestRunID `json:"test_run_id" db:"test_case_test_run_id"`
+	// BuildID is the ID of the build the test case was reported in.
+	BuildID BuildID `json:"build_id" db:"test_case_build_id"`
+	// RepoID is the ID of the repo the build ran for.
+	RepoID RepoID `json:"repo_id" db:"test_case_repo_id"`
+	// Suite is the name of the suite, class or package containing the test.
+	Suite string `json:"suite" db:"test_case_suite"`
+	// Name is the name of the test within the suite.
+	Name   string         `json:"name" db:"test_case_name"`
+	Status TestCaseStatus `json:"status" db:"test_case_status"`
+	// Attempts is the number of times the test was run.
+	Attempts int `json:"attempts" db:"test_case_attempts"`
+	// DurationMillis is the total time spent running the test across all attempts, in milliseconds.
+	DurationMillis int64 `json:"duration_millis" db:"test_case_duration_millis"`
+	// Message is the failure message from the most recent failed attempt, if any.
+	Message string `json:"message" db:"test_case_message"`
+}
+
+func NewTestCase(now Time, testRun *TestRun, suite string, name string, status TestCaseStatus, attempts int, durationMillis int64, message string) *TestCase {
+	if len(message) > MaxTestCaseMessageLength {
+		// Truncate on a rune boundary so the message remains valid UTF-8
+		end := MaxTestCaseMessageLength
+		for end > 0 && !utf8.RuneStart(message[end]) {
+			end--
+		}
+		message = message[:end]
+	}
+	return &TestCase{
+		ID:             NewTestCaseID(),
+		CreatedAt:      now,
+		TestRunID:      testRun.ID,
+		BuildID:        testRun.BuildID,
+		RepoID:         testRun.RepoID,
+		Suite:          suite,
+		Name:           name,
+		Status:         status,
+		Attempts:       attempts,
+		DurationMillis: durationMillis,
+		Message:        message,
+	}
+}
+
+func (m *TestCase) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *TestCase) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *TestCase) GetKind() ResourceKind {
+	return TestCaseResourceKind
+}
+
+func (m *TestCase) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if !m.TestRunID.Valid() {
+		result = multierror.Append(result, errors.New("error test run id must be set"))
+	}
+	if !m.BuildID.Valid() {
+		result = multierror.Append(result, errors.New("error build id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if m.Name == "" {
+		result = multierror.Append(result, errors.New("error name must be set"))
+	}
+	if !m.Status.Valid() {
+		result = multierror.Append(result, errors.New("error status must be passed, failed, skipped or flaky"))
+	}
+	return result.ErrorOrNil()
+}
+
+// TestCaseSearch filters the test cases returned from a search. At least one of BuildID and RepoID must be set.
+type TestCaseSearch struct {
+	Pagination
+	// BuildID is the ID of the build to filter test cases to, or nil.
+	BuildID *BuildID `json:"build_id"`
+	// RepoID is the ID of the repo to filter test cases to, or nil.
+	RepoID *RepoID `json:"repo_id"`
+	// TestRunID is the ID of the test run to filter test cases to, or nil.
+	TestRunID *TestRunID `json:"test_run_id"`
+	// Suite is the name of the suite to filter test cases to, or nil.
+	Suite *string `json:"suite"`
+	// Name is the name of the test to filter test cases to, or nil.
+	Name *string `json:"name"`
+	// Status is the status to filter test cases to, or nil.
+	Status *TestCaseStatus `json:"status"`
+}
+
+func NewTestCaseSearch() *TestCaseSearch {
+	return &TestCaseSearch{Pagination: NewPagination(DefaultPaginationLimit, nil)}
+}
+
+func (m *TestCaseSearch) Validate() error {
+	if m.BuildID == nil && m.RepoID == nil {
+		return gerror.NewErrValidationFailed("Build ID or repo ID must be specified")
+	}
+	if m.Status != nil && !m.Status.Valid() {
+		return gerror.NewErrValidationFailed("Status must be passed, failed, skipped or flaky")
+	}
+	return nil
+}
+
+// TestCaseStatusCount is the number of test cases with a particular suite, name and status.
+type TestCaseStatusCount struct {
+	Suite  string         `json:"suite" db:"test_case_suite"`
+	Name   string         `json:"name" db:"test_case_name"`
+	Status TestCaseStatus `json:"status" db:"test_case_status"`
+	Count  int            `json:"count" db:"count"`
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const TestRunResourceKind ResourceKind = "test-run"
+
+// TestResultsArtifactGroupName is the name of the artifact group that test reports must be uploaded to in
+// order to be ingested. Any artifact in a group with this name is parsed as a JUnit/xUnit XML or Go test
+// JSON report once it has been uploaded.
+const TestResultsArtifactGroupName ResourceName = "test-results"
+
+type TestRunID struct {
+	ResourceID
+}
+
+func NewTestRunID() TestRunID {
+	return TestRunID{ResourceID: NewResourceID(TestRunResourceKind)}
+}
+
+func TestRunIDFromResourceID(id ResourceID) TestRunID {
+	return TestRunID{ResourceID: id}
+}
+
+type TestReportFormat string
+
+const (
+	// TestReportFormatJUnit is the JUnit XML format, as produced by most test frameworks.
+	TestReportFormatJUnit TestReportFormat = "junit"
+	// TestReportFormatXUnit is the xUnit.net v2 XML format.
+	TestReportFormatXUnit TestReportFormat = "xunit"
+	// TestReportFormatGoTestJSON is the output of 'go test -json'.
+	TestReportFormatGoTestJSON TestReportFormat = "go-test-json"
+)
+
+func (f TestReportFormat) Valid() bool {
+	return f == TestReportFormatJUnit ||
+		f == TestReportFormatXUnit ||
+		f == TestReportFormatGoTestJSON
+}
+
+func (f TestReportFormat) String() string {
+	return string(f)
+}
+
+// TestRun records the results parsed from a single test report artifact.
+type TestRun struct {
+	ID        TestRunID `json:"id" goqu:"skipupdate" db:"test_run_id"`
+	CreatedAt Time      `json:"created_at" goqu:"skipupdate" db:"test_run_created_at"`
+	// BuildID is the ID of the build the report was uploaded from.
+	BuildID BuildID `json:"build_id" db:"test_run_build_id"`
+	// JobID is the ID of the job the report was uploaded from.
+	JobID JobID `json:"job_id" db:"test_run_job_id"`
+	// RepoID is the ID of the repo the build ran for.
+	RepoID RepoID `json:"repo_id" db:"test_run_repo_id"`
+	// ArtifactID is the ID of the artifact containing the report.
+	ArtifactID ArtifactID `json:"artifact_id" db:"test_run_artifact_id"`
+	// Format is the format the report was parsed as.
+	Format TestReportFormat `json:"format" db:"test_run_format"`
+	// Total is the number of distinct tests in the report.
+	Total int `json:"total" db:"test_run_total"`
+	// Passed is the number of tests that passed on every attempt.
+	Passed int `json:"passed" db:"test_run_passed"`
+	// Failed is the number of tests that failed on every attempt.
+	Failed int `json:"failed" db:"test_run_failed"`
+	// Skipped is the number of tests that were skipped.
+	Skipped int `json:"skipped" db:"test_run_skipped"`
+	// Flaky is the number of tests that failed on some attempts and passed on others.
+	Flaky int `json:"flaky" db:"test_run_flaky"`
+	// DurationMillis is the total time spent running the tests in the report, in milliseconds.
+	DurationMillis int64 `json:"duration_millis" db:"test_run_duration_millis"`
+}
+
+func NewTestRun(now Time, job *Job, artifactID ArtifactID, format TestReportFormat) *TestRun {
+	return &TestRun{
+		ID:         NewTestRunID(),
+		CreatedAt:  now,
+		BuildID:    job.BuildID,
+		JobID:      job.ID,
+		RepoID:     job.RepoID,
+		ArtifactID: artifactID,
+		Format:     format,
+	}
+}
+
+// Add counts one more distinct test with the specified status and duration.
+func (m *TestRun) Add(status TestCaseStatus, durationMillis int64) {
+	m.Total++
+	switch status {
+	case TestCaseStatusPassed:
+		m.Passed++
+	case TestCaseStatusFailed:
+		m.Failed++
+	case TestCaseStatusSkipped:
+		m.Skipped++
+	case TestCaseStatusFlaky:
+		m.Flaky++
+	}
+	m.DurationMillis += durationMillis
+}
+
+func (m *TestRun) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *TestRun) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *TestRun) GetKind() ResourceKind {
+	return TestRunResourceKind
+}
+
+func (m *TestRun) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if !m.BuildID.Valid() {
+		result = multierror.Append(result, errors.New("error build id must be set"))
+	}
+	if !m.JobID.Valid() {
+		result = multierror.Append(result, errors.New("error job id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if !m.ArtifactID.Valid() {
+		result = multierror.Append(result, errors.New("error artifact id must be set"))
+	}
+	if !m.Format.Valid() {
+		result = multierror.Append(result, errors.New("error format must be junit, xunit or go-test-json"))
+	}
+	return result.ErrorOrNil()
+}
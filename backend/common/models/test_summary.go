@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const TestSummaryResourceKind ResourceKind = "test-summary"
+
+type TestSummaryID struct {
+	ResourceID
+}
+
+func NewTestSummaryID() TestSummaryID {
+	return TestSummaryID{ResourceID: NewResourceID(TestSummaryResourceKind)}
+}
+
+// TestSummary summarizes the results of all tests reported by a build, across every test run. A test that
+// was reported in more than one run (e.g. by several jobs) is counted once, as flaky if it passed in some
+// runs and failed in others. The summary is recalculated each time a test run is added to the build.
+type TestSummary struct {
+	ID        TestSummaryID `json:"id" goqu:"skipupdate" db:"test_summary_id"`
+	CreatedAt Time          `json:"created_at" goqu:"skipupdate" db:"test_summary_created_at"`
+	UpdatedAt Time          `json:"updated_at" db:"test_summary_updated_at"`
+	BuildID   BuildID       `json:"build_id" db:"test_summary_build_id"`
+	RepoID    RepoID        `json:"repo_id" db:"test_summary_repo_id"`
+	// Runs is the number of test runs (i.e. test reports) in the build.
+	Runs int `json:"runs" db:"test_summary_runs"`
+	// Total is the number of distinct tests.
+	Total int `json:"total" db:"test_summary_total"`
+	// Passed is the number of tests that passed on every attempt.
+	Passed int `json:"passed" db:"test_summary_passed"`
+	// Failed is the number of tests that failed on every attempt.
+	Failed int `json:"failed" db:"test_summary_failed"`
+	// Skipped is the number of tests that were skipped.
+	Skipped int `json:"skipped" db:"test_summary_skipped"`
+	// Flaky is the number of tests that failed on some attempts and passed on others.
+	Flaky int `json:"flaky" db:"test_summary_flaky"`
+	// DurationMillis is the total time spent running tests across all test runs, in milliseconds.
+	DurationMillis int64 `json:"duration_millis" db:"test_summary_duration_millis"`
+}
+
+func NewTestSummary(now Time, buildID BuildID, repoID RepoID) *TestSummary {
+	return &TestSummary{
+		ID:        NewTestSummaryID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		BuildID:   buildID,
+		RepoID:    repoID,
+	}
+}
+
+// Add counts one more distinct test with the specified status.
+func (m *TestSummary) Add(status TestCaseStatus) {
+	m.Total++
+	switch status {
+	case TestCaseStatusPassed:
+		m.Passed++
+	case TestCaseStatusFailed:
+		m.Failed++
+	case TestCaseStatusSkipped:
+		m.Skipped++
+	case TestCaseStatusFlaky:
+		m.Flaky++
+	}
+}
+
+func (m *TestSummary) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *TestSummary) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *TestSummary) GetKind() ResourceKind {
+	return TestSummaryResourceKind
+}
+
+func (m *TestSummary) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if !m.BuildID.Valid() {
+		result = multierror.Append(result, errors.New("error build id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	return result.ErrorOrNil()
+}
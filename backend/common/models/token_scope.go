@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// TokenScope limits what a personal access token can be used for. Each scope allows a fixed set of operations;
+// a token can only perform an operation if one of its scopes allows it AND the token's identity has been
+// granted the operation on the resource.
+type TokenScope string
+
+const (
+	// TokenScopeReadBuild allows reading builds, jobs, logs and artifacts, and the repos they belong to.
+	TokenScopeReadBuild TokenScope = "read:build"
+	// TokenScopeWriteSecret allows reading and changing repo secrets (but never reading their plaintext).
+	TokenScopeWriteSecret TokenScope = "write:secret"
+	// TokenScopeAdminRunner allows registering, reading, updating and deleting runners.
+	TokenScopeAdminRunner TokenScope = "admin:runner"
+)
+
+// tokenScopeOperations maps each scope to the operations it allows.
+var tokenScopeOperations = map[TokenScope][]*Operation{
+	TokenScopeReadBuild: {
+		LegalEntityReadOperation,
+		RepoReadOperation,
+		BuildReadOperation,
+		JobReadOperation,
+		ArtifactReadOperation,
+	},
+	TokenScopeWriteSecret: {
+		LegalEntityReadOperation,
+		RepoReadOperation,
+		SecretCreateOperation,
+		SecretReadOperation,
+		SecretUpdateOperation,
+		SecretDeleteOperation,
+	},
+	TokenScopeAdminRunner: {
+		LegalEntityReadOperation,
+		RunnerCreateOperation,
+		RunnerReadOperation,
+		RunnerUpdateOperation,
+		RunnerDeleteOperation,
+	},
+}
+
+// ListTokenScopes returns all the scopes a personal access token can have, in name order.
+func ListTokenScopes() []TokenScope {
+	scopes := make([]TokenScope, 0, len(tokenScopeOperations))
+	for scope := range tokenScopeOperations {
+		scopes = append(scopes, scope)
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i] < scopes[j] })
+	return scopes
+}
+
+func (s TokenScope) String() string {
+	return string(s)
+}
+
+func (s TokenScope) Valid() bool {
+	_, ok := tokenScopeOperations[s]
+	return ok
+}
+
+// Allows returns true if the scope allows the specified operation.
+func (s TokenScope) Allows(operation *Operation) bool {
+	for _, allowed := range tokenScopeOperations[s] {
+		if allowed.Name == operation.Name && allowed.ResourceKind == operation.ResourceKind {
+			return true
+		}
+	}
+	return false
+}
+
+type TokenScopes []TokenScope
+
+// Allows returns true if any of the scopes allows the specified operation.
+func (m TokenScopes) Allows(operation *Operation) bool {
+	for _, scope := range m {
+		if scope.Allows(operation) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m TokenScopes) Validate() error {
+	if len(m) == 0 {
+		return fmt.Errorf("error at least one scope must be specified")
+	}
+	seen := make(map[TokenScope]bool, len(m))
+	for _, scope := range m {
+		if !scope.Valid() {
+			return fmt.Errorf("error unknown scope %q; must be one of %v", scope, ListTokenScopes())
+		}
+		if seen[scope] {
+			return fmt.Errorf("error duplicate scope %q", scope)
+		}
+		seen[scope] = true
+	}
+	return nil
+}
+
+func (m *TokenScopes) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m TokenScopes) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
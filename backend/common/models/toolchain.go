@@ -0,0 +1,230 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const ToolchainResourceKind ResourceKind = "toolchain"
+
+const toolchainVersionMaxLength = 128
+
+var (
+	toolchainVersionRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+	imageDigestRegex      = regexp.MustCompile(`^[a-z0-9]+:[a-f0-9]{32,}$`)
+)
+
+type ToolchainID struct {
+	ResourceID
+}
+
+func NewToolchainID() ToolchainID {
+	return ToolchainID{ResourceID: NewResourceID(ToolchainResourceKind)}
+}
+
+func ToolchainIDFromResourceID(id ResourceID) ToolchainID {
+	return ToolchainID{ResourceID: id}
+}
+
+// ToolchainReference identifies a version of a toolchain, in the format "name@version" (e.g. "go-builder@1.4").
+type ToolchainReference struct {
+	Name    ResourceName
+	Version string
+}
+
+// ParseToolchainReference parses a toolchain reference in the format "name@version".
+func ParseToolchainReference(str string) (ToolchainReference, error) {
+	name, version, found := strings.Cut(str, "@")
+	if !found {
+		return ToolchainReference{}, fmt.Errorf("error toolchain reference %q must be in the format 'name@version'", str)
+	}
+	ref := ToolchainReference{Name: ResourceName(name), Version: version}
+	if err := ref.Validate(); err != nil {
+		return ToolchainReference{}, fmt.Errorf("error invalid toolchain reference %q: %w", str, err)
+	}
+	return ref, nil
+}
+
+func (r ToolchainReference) Validate() error {
+	if err := r.Name.Validate(); err != nil {
+		return err
+	}
+	if len(r.Version) > toolchainVersionMaxLength {
+		return fmt.Errorf("error version must not exceed %d characters", toolchainVersionMaxLength)
+	}
+	if !toolchainVersionRegex.MatchString(r.Version) {
+		return fmt.Errorf("error version must start with an alphanumeric character and only contain alphanumeric, dot, dash or underscore characters: '%s'", r.Version)
+	}
+	return nil
+}
+
+func (r ToolchainReference) String() string {
+	return fmt.Sprintf("%s@%s", r.Name, r.Version)
+}
+
+// Toolchain is a named, versioned Docker image registered by a legal entity for its builds to run jobs in,
+// such as an image containing the compilers and tools a repo is built with. Jobs refer to a toolchain by
+// name and version, and the server resolves the reference to the registered image when the job is run.
+// A version of a toolchain can't be changed once registered; a new version must be registered instead.
+type Toolchain struct {
+	ID            ToolchainID   `json:"id" goqu:"skipupdate" db:"toolchain_id"`
+	LegalEntityID LegalEntityID `json:"legal_entity_id" goqu:"skipupdate" db:"toolchain_legal_entity_id"`
+	CreatedAt     Time          `json:"created_at" goqu:"skipupdate" db:"toolchain_created_at"`
+	UpdatedAt     Time          `json:"updated_at" db:"toolchain_updated_at"`
+	ETag          ETag          `json:"etag" db:"toolchain_etag" hash:"ignore"`
+	// Name identifies the toolchain within the legal entity; there can be many versions with the same name.
+	Name ResourceName `json:"name" goqu:"skipupdate" db:"toolchain_name"`
+	// Version identifies this version of the toolchain, e.g. "1.4".
+	Version string `json:"version" goqu:"skipupdate" db:"toolchain_version"`
+	// Image is the Docker image for the toolchain, e.g. "registry.example.com/go-builder:1.4".
+	Image string `json:"image" db:"toolchain_image"`
+	// Digest is the optional content digest of the image, e.g. "sha256:...". If set, jobs using the
+	// toolchain run in exactly this image even if the image's tag is later moved.
+	Digest string `json:"digest" db:"toolchain_digest"`
+	// CreatedByBuildID is the build that registered the toolchain, or nil if it was registered via the API.
+	CreatedByBuildID *BuildID `json:"created_by_build_id" goqu:"skipupdate" db:"toolchain_created_by_build_id"`
+	// SourceRepoID is the repo containing the Dockerfile the toolchain's image is built from, or nil if the
+	// toolchain was registered via the API. Set to the registering build's repo.
+	SourceRepoID *RepoID `json:"source_repo_id" goqu:"skipupdate" db:"toolchain_source_repo_id"`
+	// Dockerfile is the optional path within the source repo of the Dockerfile the toolchain's image is built
+	// from. If set, a new version of the toolchain is automatically built whenever the Dockerfile changes.
+	Dockerfile string `json:"dockerfile" goqu:"skipupdate" db:"toolchain_dockerfile"`
+	// RebuildWorkflow is the workflow in the source repo's build definition that builds and registers a new
+	// version of the toolchain. Required if Dockerfile is set.
+	RebuildWorkflow ResourceName `json:"rebuild_workflow" goqu:"skipupdate" db:"toolchain_rebuild_workflow"`
+}
+
+func NewToolchain(
+	now Time,
+	legalEntityID LegalEntityID,
+	name ResourceName,
+	version string,
+	image string,
+	digest string,
+	createdByBuildID *BuildID,
+	sourceRepoID *RepoID,
+	dockerfile string,
+	rebuildWorkflow ResourceName) *Toolchain {
+	return &Toolchain{
+		ID:               NewToolchainID(),
+		LegalEntityID:    legalEntityID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Name:             name,
+		Version:          version,
+		Image:            image,
+		Digest:           digest,
+		CreatedByBuildID: createdByBuildID,
+		SourceRepoID:     sourceRepoID,
+		Dockerfile:       dockerfile,
+		RebuildWorkflow:  rebuildWorkflow,
+	}
+}
+
+func (m *Toolchain) GetKind() ResourceKind {
+	return ToolchainResourceKind
+}
+
+func (m *Toolchain) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *Toolchain) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *Toolchain) GetParentID() ResourceID {
+	return m.LegalEntityID.ResourceID
+}
+
+func (m *Toolchain) GetName() ResourceName {
+	return m.Name
+}
+
+func (m *Toolchain) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *Toolchain) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *Toolchain) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *Toolchain) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+// Reference returns the reference jobs use to refer to this version of the toolchain.
+func (m *Toolchain) Reference() ToolchainReference {
+	return ToolchainReference{Name: m.Name, Version: m.Version}
+}
+
+// ImageReference returns the image jobs using the toolchain should run in. If the toolchain has a digest
+// the image is pinned to it (e.g. "registry.example.com/go-builder@sha256:..."), otherwise the image is
+// returned as registered.
+func (m *Toolchain) ImageReference() string {
+	if m.Digest == "" {
+		return m.Image
+	}
+	image := m.Image
+	if at := strings.Index(image, "@"); at >= 0 {
+		image = image[:at]
+	}
+	// Strip the tag, taking care not to mistake a registry port for one
+	if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") {
+		image = image[:colon]
+	}
+	return fmt.Sprintf("%s@%s", image, m.Digest)
+}
+
+// IsRebuiltOnChange returns true if new versions of the toolchain should be built automatically when its
+// Dockerfile changes.
+func (m *Toolchain) IsRebuiltOnChange() bool {
+	return m.Dockerfile != ""
+}
+
+func (m *Toolchain) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if err := m.Reference().Validate(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if m.Image == "" || strings.ContainsAny(m.Image, " \t\n") {
+		result = multierror.Append(result, errors.New("error image must be set and must not contain whitespace"))
+	}
+	if m.Digest != "" && !imageDigestRegex.MatchString(m.Digest) {
+		result = multierror.Append(result, fmt.Errorf("error digest must be in the format 'algorithm:hex' (e.g. 'sha256:...'): '%s'", m.Digest))
+	}
+	if m.Dockerfile != "" {
+		if m.SourceRepoID == nil || !m.SourceRepoID.Valid() {
+			result = multierror.Append(result, errors.New("error dockerfile can only be set when the toolchain is registered by a build"))
+		}
+		if strings.HasPrefix(m.Dockerfile, "/") || strings.ContainsAny(m.Dockerfile, "\t\n") {
+			result = multierror.Append(result, fmt.Errorf("error dockerfile must be a path relative to the root of the repo: '%s'", m.Dockerfile))
+		}
+		if err := m.RebuildWorkflow.Validate(); err != nil {
+			result = multierror.Append(result, fmt.Errorf("error rebuild workflow must be set when dockerfile is set: %w", err))
+		}
+	} else if m.RebuildWorkflow != "" {
+		result = multierror.Append(result, errors.New("error rebuild workflow can only be set when dockerfile is set"))
+	}
+	return result.ErrorOrNil()
+}
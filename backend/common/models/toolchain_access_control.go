@@ -0,0 +1,10 @@
+package models
+
+var ToolchainCreateOperation = &Operation{
+	Name:         "create",
+	ResourceKind: ToolchainResourceKind,
+}
+
+var ToolchainAccessControlOperations = []*Operation{
+	ToolchainCreateOperation,
+}
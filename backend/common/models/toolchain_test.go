@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToolchainReference(t *testing.T) {
+	ref, err := ParseToolchainReference("go-builder@1.4")
+	require.NoError(t, err)
+	require.Equal(t, ToolchainReference{Name: "go-builder", Version: "1.4"}, ref)
+	require.Equal(t, "go-builder@1.4", ref.String())
+
+	for _, str := range []string{"", "go-builder", "go-builder@", "@1.4", "go builder@1.4", "go-builder@1.4@2", "go-builder@.4"} {
+		_, err := ParseToolchainReference(str)
+		require.Error(t, err, "parsing %q", str)
+	}
+}
+
+func TestToolchainImageReference(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := map[string]string{
+		"go-builder:1.4": "go-builder@" + digest,
+		"go-builder":     "go-builder@" + digest,
+		"registry.example.com:5000/go-builder:1.4": "registry.example.com:5000/go-builder@" + digest,
+		"registry.example.com:5000/go-builder":     "registry.example.com:5000/go-builder@" + digest,
+		"go-builder:1.4@sha256:abc":                "go-builder@" + digest,
+	}
+	for image, expected := range tests {
+		toolchain := &Toolchain{Image: image, Digest: digest}
+		require.Equal(t, expected, toolchain.ImageReference(), "image %q", image)
+	}
+	toolchain := &Toolchain{Image: "go-builder:1.4"}
+	require.Equal(t, "go-builder:1.4", toolchain.ImageReference(), "images without a digest are used as registered")
+}
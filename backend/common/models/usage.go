@@ -0,0 +1,274 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	UsageRecordResourceKind ResourceKind = "usage-record"
+	UsageQuotaResourceKind  ResourceKind = "usage-quota"
+)
+
+// UsageMetric identifies a kind of resource consumption that is accounted for per legal entity.
+type UsageMetric string
+
+const (
+	// UsageMetricBuildSeconds is the time jobs spent running on runners, in seconds.
+	UsageMetricBuildSeconds UsageMetric = "build-seconds"
+	// UsageMetricArtifactBytes is the size of the artifacts uploaded by jobs, in bytes.
+	UsageMetricArtifactBytes UsageMetric = "artifact-bytes"
+	// UsageMetricLogBytes is the size of the logs written by builds, jobs and steps, in bytes.
+	UsageMetricLogBytes UsageMetric = "log-bytes"
+)
+
+// AllUsageMetrics lists every metric usage is recorded for.
+var AllUsageMetrics = []UsageMetric{
+	UsageMetricBuildSeconds,
+	UsageMetricArtifactBytes,
+	UsageMetricLogBytes,
+}
+
+func (m UsageMetric) Valid() bool {
+	for _, metric := range AllUsageMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+func (m UsageMetric) String() string {
+	return string(m)
+}
+
+// UsagePeriod identifies the calendar month (in UTC) that usage is accounted to, in the form '2006-01'.
+type UsagePeriod string
+
+const usagePeriodFormat = "2006-01"
+
+// UsagePeriodOf returns the usage period that includes the specified time.
+func UsagePeriodOf(t time.Time) UsagePeriod {
+	return UsagePeriod(t.UTC().Format(usagePeriodFormat))
+}
+
+// ParseUsagePeriod parses a usage period in the form '2006-01'.
+func ParseUsagePeriod(str string) (UsagePeriod, error) {
+	t, err := time.Parse(usagePeriodFormat, str)
+	if err != nil {
+		return "", fmt.Errorf("error usage period must be a month in the form YYYY-MM: %w", err)
+	}
+	return UsagePeriodOf(t), nil
+}
+
+func (m UsagePeriod) String() string {
+	return string(m)
+}
+
+type UsageRecordID struct {
+	ResourceID
+}
+
+func NewUsageRecordID() UsageRecordID {
+	return UsageRecordID{ResourceID: NewResourceID(UsageRecordResourceKind)}
+}
+
+func UsageRecordIDFromResourceID(id ResourceID) UsageRecordID {
+	return UsageRecordID{ResourceID: id}
+}
+
+// UsageRecord records a quantity of resources consumed on behalf of a legal entity's repo. Each source resource
+// (e.g. a job, artifact or log) is recorded at most once per metric, so recording usage is idempotent.
+type UsageRecord struct {
+	ID            UsageRecordID `json:"id" goqu:"skipupdate" db:"usage_record_id"`
+	CreatedAt     Time          `json:"created_at" goqu:"skipupdate" db:"usage_record_created_at"`
+	LegalEntityID LegalEntityID `json:"legal_entity_id" goqu:"skipupdate" db:"usage_record_legal_entity_id"`
+	RepoID        RepoID        `json:"repo_id" goqu:"skipupdate" db:"usage_record_repo_id"`
+	// SourceResourceID is the ID of the resource the usage was measured from.
+	SourceResourceID ResourceID  `json:"source_resource_id" goqu:"skipupdate" db:"usage_record_source_resource_id"`
+	Metric           UsageMetric `json:"metric" goqu:"skipupdate" db:"usage_record_metric"`
+	// Period is the month the usage is accounted to.
+	Period UsagePeriod `json:"period" goqu:"skipupdate" db:"usage_record_period"`
+	// Quantity is the amount of resources consumed, in the units of the metric.
+	Quantity int64 `json:"quantity" goqu:"skipupdate" db:"usage_record_quantity"`
+}
+
+func NewUsageRecord(now Time, legalEntityID LegalEntityID, repoID RepoID, sourceResourceID ResourceID, metric UsageMetric, quantity int64) *UsageRecord {
+	return &UsageRecord{
+		ID:               NewUsageRecordID(),
+		CreatedAt:        now,
+		LegalEntityID:    legalEntityID,
+		RepoID:           repoID,
+		SourceResourceID: sourceResourceID,
+		Metric:           metric,
+		Period:           UsagePeriodOf(now.Time),
+		Quantity:         quantity,
+	}
+}
+
+func (m *UsageRecord) GetKind() ResourceKind {
+	return UsageRecordResourceKind
+}
+
+func (m *UsageRecord) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *UsageRecord) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *UsageRecord) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if !m.RepoID.Valid() {
+		result = multierror.Append(result, errors.New("error repo id must be set"))
+	}
+	if !m.SourceResourceID.Valid() {
+		result = multierror.Append(result, errors.New("error source resource id must be set"))
+	}
+	if !m.Metric.Valid() {
+		result = multierror.Append(result, fmt.Errorf("error unknown metric: %s", m.Metric))
+	}
+	if m.Period == "" {
+		result = multierror.Append(result, errors.New("error period must be set"))
+	}
+	if m.Quantity < 0 {
+		result = multierror.Append(result, errors.New("error quantity must not be negative"))
+	}
+	return result.ErrorOrNil()
+}
+
+type UsageQuotaID struct {
+	ResourceID
+}
+
+func NewUsageQuotaID() UsageQuotaID {
+	return UsageQuotaID{ResourceID: NewResourceID(UsageQuotaResourceKind)}
+}
+
+func UsageQuotaIDFromResourceID(id ResourceID) UsageQuotaID {
+	return UsageQuotaID{ResourceID: id}
+}
+
+// UsageQuota limits the usage of a metric by a legal entity in each calendar month. Once the quota is reached
+// no new builds can be queued for the legal entity, and queued jobs are failed rather than run, until the next
+// month starts or the quota is raised.
+type UsageQuota struct {
+	ID            UsageQuotaID  `json:"id" goqu:"skipupdate" db:"usage_quota_id"`
+	LegalEntityID LegalEntityID `json:"legal_entity_id" goqu:"skipupdate" db:"usage_quota_legal_entity_id"`
+	CreatedAt     Time          `json:"created_at" goqu:"skipupdate" db:"usage_quota_created_at"`
+	UpdatedAt     Time          `json:"updated_at" db:"usage_quota_updated_at"`
+	ETag          ETag          `json:"etag" db:"usage_quota_etag" hash:"ignore"`
+	Metric        UsageMetric   `json:"metric" goqu:"skipupdate" db:"usage_quota_metric"`
+	// MonthlyLimit is the maximum quantity of the metric that can be used in a month, in the units of the metric.
+	MonthlyLimit int64 `json:"monthly_limit" db:"usage_quota_monthly_limit"`
+}
+
+func NewUsageQuota(now Time, legalEntityID LegalEntityID, metric UsageMetric, monthlyLimit int64) *UsageQuota {
+	return &UsageQuota{
+		ID:            NewUsageQuotaID(),
+		LegalEntityID: legalEntityID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Metric:        metric,
+		MonthlyLimit:  monthlyLimit,
+	}
+}
+
+func (m *UsageQuota) GetKind() ResourceKind {
+	return UsageQuotaResourceKind
+}
+
+func (m *UsageQuota) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *UsageQuota) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *UsageQuota) GetParentID() ResourceID {
+	return m.LegalEntityID.ResourceID
+}
+
+func (m *UsageQuota) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *UsageQuota) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *UsageQuota) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *UsageQuota) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+func (m *UsageQuota) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if !m.LegalEntityID.Valid() {
+		result = multierror.Append(result, errors.New("error legal entity id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if !m.Metric.Valid() {
+		result = multierror.Append(result, fmt.Errorf("error unknown metric: %s", m.Metric))
+	}
+	if m.MonthlyLimit < 0 {
+		result = multierror.Append(result, errors.New("error monthly limit must not be negative"))
+	}
+	return result.ErrorOrNil()
+}
+
+// UsageRollup is the total usage of a metric by one of a legal entity's repos during a usage period.
+type UsageRollup struct {
+	Period   UsagePeriod `json:"period" db:"usage_record_period"`
+	RepoID   RepoID      `json:"repo_id" db:"usage_record_repo_id"`
+	Metric   UsageMetric `json:"metric" db:"usage_record_metric"`
+	Quantity int64       `json:"quantity" db:"quantity"`
+}
+
+// UsageTotals maps each metric to the total quantity used.
+type UsageTotals map[UsageMetric]int64
+
+// RepoUsage is the usage of one of a legal entity's repos during a usage period.
+type RepoUsage struct {
+	RepoID RepoID      `json:"repo_id"`
+	Totals UsageTotals `json:"totals"`
+}
+
+// MonthlyUsage is the usage of a legal entity during a usage period, in total and broken down by repo.
+type MonthlyUsage struct {
+	Period UsagePeriod  `json:"period"`
+	Totals UsageTotals  `json:"totals"`
+	Repos  []*RepoUsage `json:"repos"`
+}
+
+// UsageSummary summarizes the usage of a legal entity over a range of usage periods, along with its quotas.
+type UsageSummary struct {
+	LegalEntityID LegalEntityID   `json:"legal_entity_id"`
+	Months        []*MonthlyUsage `json:"months"`
+	Quotas        []*UsageQuota   `json:"quotas"`
+}
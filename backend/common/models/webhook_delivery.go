@@ -0,0 +1,192 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const WebhookDeliveryResourceKind ResourceKind = "webhook-delivery"
+
+type WebhookDeliveryID struct {
+	ResourceID
+}
+
+func NewWebhookDeliveryID() WebhookDeliveryID {
+	return WebhookDeliveryID{ResourceID: NewResourceID(WebhookDeliveryResourceKind)}
+}
+
+func WebhookDeliveryIDFromResourceID(id ResourceID) WebhookDeliveryID {
+	return WebhookDeliveryID{ResourceID: id}
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryStatusPending means the delivery has been received (or queued to be replayed) but has not
+	// finished processing yet.
+	WebhookDeliveryStatusPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliveryStatusSucceeded means the delivery was processed by the SCM without error.
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryStatusFailed means processing the delivery failed. Failed deliveries can be replayed.
+	WebhookDeliveryStatusFailed WebhookDeliveryStatus = "failed"
+)
+
+func (s WebhookDeliveryStatus) Valid() bool {
+	return s == WebhookDeliveryStatusPending ||
+		s == WebhookDeliveryStatusSucceeded ||
+		s == WebhookDeliveryStatusFailed
+}
+
+func (s WebhookDeliveryStatus) String() string {
+	return string(s)
+}
+
+type WebhookSignatureStatus string
+
+const (
+	// WebhookSignatureStatusUnverified means the signature of the delivery was not checked, either because it
+	// has not been processed yet or because the SCM has no webhook secret configured.
+	WebhookSignatureStatusUnverified WebhookSignatureStatus = "unverified"
+	// WebhookSignatureStatusValid means the delivery was signed using the configured webhook secret.
+	WebhookSignatureStatusValid WebhookSignatureStatus = "valid"
+	// WebhookSignatureStatusInvalid means the delivery's signature was missing or did not match the configured
+	// webhook secret, so the delivery was not processed.
+	WebhookSignatureStatusInvalid WebhookSignatureStatus = "invalid"
+)
+
+func (s WebhookSignatureStatus) Valid() bool {
+	return s == WebhookSignatureStatusUnverified ||
+		s == WebhookSignatureStatusValid ||
+		s == WebhookSignatureStatusInvalid
+}
+
+func (s WebhookSignatureStatus) String() string {
+	return string(s)
+}
+
+// WebhookDelivery records a webhook received from an SCM, along with the outcome of processing it, so that
+// missed builds can be debugged and failed deliveries replayed.
+type WebhookDelivery struct {
+	ID        WebhookDeliveryID `json:"id" goqu:"skipupdate" db:"webhook_delivery_id"`
+	CreatedAt Time              `json:"created_at" goqu:"skipupdate" db:"webhook_delivery_created_at"`
+	UpdatedAt Time              `json:"updated_at" db:"webhook_delivery_updated_at"`
+	ETag      ETag              `json:"etag" db:"webhook_delivery_etag" hash:"ignore"`
+	// SCMName is the name of the SCM the webhook was received from.
+	SCMName SystemName `json:"scm_name" goqu:"skipupdate" db:"webhook_delivery_scm_name"`
+	// EventType is the type of event the payload describes, as determined by the SCM when processing the
+	// delivery, or empty if it could not be determined.
+	EventType string `json:"event_type" db:"webhook_delivery_event_type"`
+	// Headers are the HTTP headers the webhook was delivered with.
+	Headers WebhookDeliveryHeaders `json:"headers" goqu:"skipupdate" db:"webhook_delivery_headers"`
+	// Payload is the body of the webhook request.
+	Payload string `json:"payload" goqu:"skipupdate" db:"webhook_delivery_payload"`
+	// SignatureStatus is the result of checking the signature of the payload when it was last processed.
+	SignatureStatus WebhookSignatureStatus `json:"signature_status" db:"webhook_delivery_signature_status"`
+	// Status is the outcome of processing the delivery.
+	Status WebhookDeliveryStatus `json:"status" db:"webhook_delivery_status"`
+	// Error describes why processing failed, or is empty if it succeeded or is still pending.
+	Error string `json:"error" db:"webhook_delivery_error"`
+	// Attempts is the number of times the delivery has been processed, including replays.
+	Attempts int `json:"attempts" db:"webhook_delivery_attempts"`
+	// ProcessedAt is the time the delivery was last processed, or nil if it has not been processed yet.
+	ProcessedAt *Time `json:"processed_at" db:"webhook_delivery_processed_at"`
+}
+
+func NewWebhookDelivery(now Time, scmName SystemName, headers http.Header, payload []byte) *WebhookDelivery {
+	return &WebhookDelivery{
+		ID:              NewWebhookDeliveryID(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		SCMName:         scmName,
+		Headers:         WebhookDeliveryHeaders(headers.Clone()),
+		Payload:         string(payload),
+		SignatureStatus: WebhookSignatureStatusUnverified,
+		Status:          WebhookDeliveryStatusPending,
+	}
+}
+
+func (m *WebhookDelivery) GetKind() ResourceKind {
+	return WebhookDeliveryResourceKind
+}
+
+func (m *WebhookDelivery) GetCreatedAt() Time {
+	return m.CreatedAt
+}
+
+func (m *WebhookDelivery) GetID() ResourceID {
+	return m.ID.ResourceID
+}
+
+func (m *WebhookDelivery) GetUpdatedAt() Time {
+	return m.UpdatedAt
+}
+
+func (m *WebhookDelivery) SetUpdatedAt(t Time) {
+	m.UpdatedAt = t
+}
+
+func (m *WebhookDelivery) GetETag() ETag {
+	return m.ETag
+}
+
+func (m *WebhookDelivery) SetETag(eTag ETag) {
+	m.ETag = eTag
+}
+
+func (m *WebhookDelivery) Validate() error {
+	var result *multierror.Error
+	if !m.ID.Valid() {
+		result = multierror.Append(result, errors.New("error id must be set"))
+	}
+	if m.CreatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error created at must be set"))
+	}
+	if m.UpdatedAt.IsZero() {
+		result = multierror.Append(result, errors.New("error updated at must be set"))
+	}
+	if m.SCMName == "" {
+		result = multierror.Append(result, errors.New("error scm name must be set"))
+	}
+	if !m.SignatureStatus.Valid() {
+		result = multierror.Append(result, errors.New("error signature status is invalid"))
+	}
+	if !m.Status.Valid() {
+		result = multierror.Append(result, errors.New("error status is invalid"))
+	}
+	return result.ErrorOrNil()
+}
+
+// WebhookDeliveryHeaders are the HTTP headers a webhook was delivered with.
+type WebhookDeliveryHeaders http.Header
+
+func (m WebhookDeliveryHeaders) Get(key string) string {
+	return http.Header(m).Get(key)
+}
+
+func (m *WebhookDeliveryHeaders) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type: %[1]T (%[1]v)", src)
+	}
+	err := json.Unmarshal([]byte(str), &m)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling from JSON: %w", err)
+	}
+	return nil
+}
+
+func (m WebhookDeliveryHeaders) Value() (driver.Value, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+)
+
+type WebhookDeliverySearch struct {
+	Pagination
+	// SCMName can be set to filter deliveries to those received from a specific SCM.
+	SCMName *SystemName `json:"scm_name"`
+	// Status can be set to filter deliveries to those with a specific status, e.g. to find failed deliveries.
+	Status *WebhookDeliveryStatus `json:"status"`
+}
+
+func NewWebhookDeliverySearch() *WebhookDeliverySearch {
+	return &WebhookDeliverySearch{Pagination: NewPagination(DefaultPaginationLimit, nil)}
+}
+
+func (m *WebhookDeliverySearch) Validate() error {
+	if m.Status != nil && !m.Status.Valid() {
+		return gerror.NewErrValidationFailed("Invalid status: " + m.Status.String())
+	}
+	return nil
+}
@@ -19,6 +19,9 @@ const (
 	WorkflowStatusSucceeded WorkflowStatus = "succeeded"
 	// WorkflowStatusCanceled indicates the item was canceled before it was ever processed.
 	WorkflowStatusCanceled WorkflowStatus = "canceled"
+	// WorkflowStatusSkipped indicates the item was not processed because its condition was not met,
+	// or because it depends on an item that was skipped.
+	WorkflowStatusSkipped WorkflowStatus = "skipped"
 	// WorkflowStatusUnknown indicates the item is in an unknown state.
 	WorkflowStatusUnknown WorkflowStatus = "unknown"
 )
@@ -30,6 +33,7 @@ var workflowStatuses = map[string]WorkflowStatus{
 	string(WorkflowStatusFailed):    WorkflowStatusFailed,
 	string(WorkflowStatusSucceeded): WorkflowStatusSucceeded,
 	string(WorkflowStatusCanceled):  WorkflowStatusCanceled,
+	string(WorkflowStatusSkipped):   WorkflowStatusSkipped,
 	string(WorkflowStatusUnknown):   WorkflowStatusUnknown,
 }
 
@@ -41,9 +45,9 @@ func (s WorkflowStatus) Valid() bool {
 }
 
 // HasFinished returns true if the workflow has finished either in a
-// successful, failure, or canceled state
+// successful, failure, canceled or skipped state
 func (s WorkflowStatus) HasFinished() bool {
-	return s == WorkflowStatusFailed || s == WorkflowStatusSucceeded || s == WorkflowStatusCanceled
+	return s == WorkflowStatusFailed || s == WorkflowStatusSucceeded || s == WorkflowStatusCanceled || s == WorkflowStatusSkipped
 }
 
 func (s WorkflowStatus) String() string {
@@ -66,6 +70,8 @@ func (s WorkflowStatus) ToGitHubState() string {
 		return "success"
 	case WorkflowStatusCanceled:
 		return "failure"
+	case WorkflowStatusSkipped:
+		return "success"
 	case WorkflowStatusUnknown:
 		return "error"
 	default:
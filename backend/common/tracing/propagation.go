@@ -0,0 +1,23 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// propagator propagates trace context between processes using the W3C traceparent and tracestate headers.
+var propagator = propagation.TraceContext{}
+
+// Inject sets the trace context headers for the span carried by ctx into the supplied HTTP headers.
+// Headers are left unchanged if ctx is not part of a trace.
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract reads the trace context headers (if any) from the supplied HTTP headers and returns a copy of
+// ctx carrying the remote span context. If no valid trace context header is present then ctx is returned.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
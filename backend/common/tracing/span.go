@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies BuildBeaver as the source of the spans it records.
+const instrumentationName = "github.com/buildbeaver/buildbeaver"
+
+// StartSpan starts a new span as a child of the span carried by ctx (if any) using the global tracer
+// provider, and returns a copy of ctx carrying the new span. The caller must call End() on the returned span.
+// Spans are internal unless a different kind is specified via trace.WithSpanKind.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// RecordError records err against the span and marks the span as failed. Nothing is recorded if err is nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+)
+
+const (
+	DefaultSampleRatio    = 1.0
+	DefaultExportInterval = 5 * time.Second
+	// otlpTracesPath is the path of the traces endpoint under an OTLP/HTTP collector's base URL.
+	otlpTracesPath = "/v1/traces"
+)
+
+type Config struct {
+	// ServiceName is reported as the service.name resource attribute on all exported spans.
+	ServiceName string
+	// OTLPEndpoint is the base URL of an OTLP/HTTP collector (e.g. http://localhost:4318).
+	// Tracing is disabled if no endpoint is configured.
+	OTLPEndpoint string
+	// OTLPHeaders are additional headers to send with each export request (e.g. for authentication).
+	OTLPHeaders map[string]string
+	// SampleRatio is the fraction of new traces to record, between 0 and 1. Traces continued from
+	// a remote parent follow the parent's sampling decision.
+	SampleRatio float64
+	// ExportInterval is the maximum time spans are buffered for before being exported.
+	ExportInterval time.Duration
+}
+
+// Enabled returns true if the config specifies an exporter endpoint.
+func (c Config) Enabled() bool {
+	return c.OTLPEndpoint != ""
+}
+
+// ParseHeaders parses a comma separated list of key=value pairs, suitable for use as OTLPHeaders.
+func ParseHeaders(str string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("error invalid header %q; expected key=value", pair)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers, nil
+}
+
+// Tracer owns the OpenTelemetry tracer provider that records and exports spans.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	log      logger.Log
+}
+
+// NewTracer creates an OpenTelemetry tracer provider that batches spans and exports them to the OTLP/HTTP
+// collector in config, and installs it as the global tracer provider used by StartSpan. If tracing is not
+// enabled in config then no provider is installed and spans are not recorded, but trace context is still
+// propagated. Call Shutdown to flush any buffered spans before exiting.
+func NewTracer(config Config, logFactory logger.LogFactory) (*Tracer, error) {
+	log := logFactory("Tracer")
+	if !config.Enabled() {
+		log.Info("Tracing disabled; no OTLP endpoint configured")
+		return &Tracer{log: log}, nil
+	}
+	endpoint, err := url.Parse(config.OTLPEndpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("error invalid OTLP endpoint %q; expected an http or https URL", config.OTLPEndpoint)
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Host),
+		otlptracehttp.WithURLPath(path.Join(endpoint.Path, otlpTracesPath)),
+		otlptracehttp.WithHeaders(config.OTLPHeaders),
+	}
+	if endpoint.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP exporter: %w", err)
+	}
+	exportInterval := config.ExportInterval
+	if exportInterval <= 0 {
+		exportInterval = DefaultExportInterval
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(exportInterval)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warnf("Error exporting traces: %v", err)
+	}))
+	log.Infof("Exporting traces to %s", config.OTLPEndpoint)
+	return &Tracer{provider: provider, log: log}, nil
+}
+
+// Shutdown exports any buffered spans and stops the tracer provider; no further spans will be recorded.
+func (t *Tracer) Shutdown(ctx context.Context) {
+	if t.provider == nil {
+		return
+	}
+	err := t.provider.Shutdown(ctx)
+	if err != nil {
+		t.log.Warnf("Error shutting down tracer: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSpansPropagateAcrossHeaders(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	// Client side
+	clientCtx, clientSpan := StartSpan(context.Background(), "client", trace.WithSpanKind(trace.SpanKindClient))
+	header := http.Header{}
+	Inject(clientCtx, header)
+	require.NotEmpty(t, header.Get("traceparent"))
+
+	// Server side
+	serverCtx := Extract(context.Background(), header)
+	serverCtx, serverSpan := StartSpan(serverCtx, "server", trace.WithSpanKind(trace.SpanKindServer))
+	_, childSpan := StartSpan(serverCtx, "child")
+	childSpan.End()
+	serverSpan.End()
+	clientSpan.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	traceID := clientSpan.SpanContext().TraceID()
+	for _, span := range spans {
+		require.Equal(t, traceID, span.SpanContext.TraceID())
+	}
+	parents := make(map[string]trace.SpanID)
+	for _, span := range spans {
+		parents[span.Name] = span.Parent.SpanID()
+	}
+	require.Equal(t, clientSpan.SpanContext().SpanID(), parents["server"])
+	require.Equal(t, serverSpan.SpanContext().SpanID(), parents["child"])
+}
+
+func TestTraceContextPropagatesWhenDisabled(t *testing.T) {
+	// Without a recording tracer provider spans aren't recorded, but a remote trace is still passed on
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := StartSpan(Extract(context.Background(), header), "disabled")
+	RecordError(span, context.Canceled)
+	span.End()
+	require.False(t, span.IsRecording())
+
+	out := http.Header{}
+	Inject(ctx, out)
+	require.Equal(t, header.Get("traceparent"), out.Get("traceparent"))
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders(" api-key = secret ,x-tenant=bb,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api-key": "secret", "x-tenant": "bb"}, headers)
+
+	_, err = ParseHeaders("api-key")
+	require.Error(t, err)
+}
@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
@@ -14,9 +15,13 @@ type APIClient interface {
 	Ping(ctx context.Context) error
 	// SendRuntimeInfo sends information about the runtime environment and version for this runner to the server.
 	SendRuntimeInfo(ctx context.Context, info *documents.PatchRuntimeInfoRequest) error
+	// SendHealthReport sends a report on the health of this runner's host to the server. The server will not
+	// give the runner jobs to run while the most recent report shows problems with the host.
+	SendHealthReport(ctx context.Context, report *models.RunnerHealthReport) error
 	// Dequeue returns the next build job that is ready to be executed, or
-	// nil if there are currently no queued builds.
-	Dequeue(ctx context.Context) (*documents.RunnableJob, error)
+	// nil if there are currently no queued builds. If wait is non-zero and no job is ready, the server
+	// may wait for up to wait for a job to become ready before returning.
+	Dequeue(ctx context.Context, wait time.Duration) (*documents.RunnableJob, error)
 	// UpdateJobStatus updates the status of the specified job.
 	// If the status is finished, err can be supplied to signal the job failed with an error
 	// or nil to signify the job succeeded.
@@ -44,8 +49,9 @@ type APIClient interface {
 		status models.WorkflowStatus,
 		stepError *models.Error,
 		eTag models.ETag) (*documents.Step, error)
-	// GetSecretsPlaintext gets all secrets for the specified repo in plaintext.
-	GetSecretsPlaintext(ctx context.Context, repoID models.RepoID) ([]*models.SecretPlaintext, error)
+	// GetSecretsPlaintext gets all secrets for the specified repo in plaintext. If pinToBuildID is set the secrets
+	// are returned as they were when that build started, otherwise the latest versions are returned.
+	GetSecretsPlaintext(ctx context.Context, repoID models.RepoID, pinToBuildID *models.BuildID) ([]*models.SecretPlaintext, error)
 	// CreateArtifact a new artifact with its contents provided by reader. It is the caller's responsibility to close reader.
 	// Returns store.ErrAlreadyExists if an artifact with matching unique properties already exists.
 	CreateArtifact(
@@ -59,6 +65,15 @@ type APIClient interface {
 	GetArtifactData(ctx context.Context, artifactID models.ArtifactID) (io.ReadCloser, error)
 	// SearchArtifacts searches all artifacts for a build. Use cursor to page through results, if any.
 	SearchArtifacts(ctx context.Context, buildID models.BuildID, search *models.ArtifactSearch) (models.ArtifactSearchPaginator, error)
+	// FindCache finds the cache entry to restore for the named cache, preferring an entry with the specified key.
+	// Returns a not found error if there are no entries for the cache.
+	FindCache(ctx context.Context, jobID models.JobID, name models.ResourceName, key string) (*documents.CacheEntry, error)
+	// GetCacheEntryData returns a reader to the tarball for a cache entry.
+	// It is the caller's responsibility to close the reader.
+	GetCacheEntryData(ctx context.Context, jobID models.JobID, cacheEntryID models.CacheEntryID) (io.ReadCloser, error)
+	// SaveCache saves a new entry for the named cache with the specified key, with the tarball provided by reader.
+	// It is the caller's responsibility to close reader.
+	SaveCache(ctx context.Context, jobID models.JobID, name models.ResourceName, key string, reader io.ReadSeeker) (*documents.CacheEntry, error)
 	// OpenLogWriteStream opens a writable stream to the specified log. Close the writer to finish writing.
 	OpenLogWriteStream(ctx context.Context, logID models.LogDescriptorID) (io.WriteCloser, error)
 }
@@ -2,29 +2,46 @@ package app
 
 import (
 	"context"
+	"io"
 	"time"
 
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 	"github.com/buildbeaver/buildbeaver/runner"
 )
 
 type Runner struct {
 	config          *RunnerConfig
 	registrar       *runner.Registrar
+	spoolingClient  *runner.SpoolingAPIClient
 	jobScheduler    *runner.Scheduler
+	healthMonitor   *runner.HealthMonitor
+	jobWatcher      *runner.JobWatcher
+	transportClient TransportAPIClient
 	executorFactory runner.ExecutorFactory
+	tracer          *tracing.Tracer
 }
 
 func NewRunner(
 	config *RunnerConfig,
 	registrar *runner.Registrar,
+	spoolingClient *runner.SpoolingAPIClient,
 	jobScheduler *runner.Scheduler,
+	healthMonitor *runner.HealthMonitor,
+	jobWatcher *runner.JobWatcher,
+	transportClient TransportAPIClient,
 	executorFactory runner.ExecutorFactory,
+	tracer *tracing.Tracer,
 ) *Runner {
 	return &Runner{
 		config:          config,
 		registrar:       registrar,
+		spoolingClient:  spoolingClient,
 		jobScheduler:    jobScheduler,
+		healthMonitor:   healthMonitor,
+		jobWatcher:      jobWatcher,
+		transportClient: transportClient,
 		executorFactory: executorFactory,
+		tracer:          tracer,
 	}
 }
 
@@ -33,12 +50,26 @@ func (r *Runner) Start(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	// Replay anything left in the spool by a previous run before starting new jobs
+	r.spoolingClient.Start()
+	// Report health before starting to dequeue jobs, so an unhealthy runner isn't given any
+	r.healthMonitor.Start()
 	r.jobScheduler.Start()
+	r.jobWatcher.Start()
 	return nil
 }
 
 func (r *Runner) Stop() {
+	r.jobWatcher.Stop()
 	r.jobScheduler.Stop()
+	r.healthMonitor.Stop()
+	r.spoolingClient.Stop()
+	if closer, ok := r.transportClient.(io.Closer); ok {
+		closer.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r.tracer.Shutdown(ctx)
 }
 
 // CleanUpOldResources cleans up containers and other resources left over from previous instances of the runner.
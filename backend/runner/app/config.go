@@ -2,14 +2,19 @@ package app
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	flag "github.com/spf13/pflag"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
 	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 	"github.com/buildbeaver/buildbeaver/runner"
 	"github.com/buildbeaver/buildbeaver/runner/logging"
+	"github.com/buildbeaver/buildbeaver/runner/plugins"
+	"github.com/buildbeaver/buildbeaver/runner/runtime/docker"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
 )
 
@@ -18,20 +23,54 @@ const (
 	DefaultRunnerPrivateKeyFile    = "runner-private-key.pem"
 	DefaultRunnerCACertificateFile = "ca-cert.pem"
 	DefaultRunnerLogTempDirName    = "log-temp"
+	DefaultRunnerSpoolDirName      = "spool"
 )
 
+// RunnerAPITransport is the protocol the runner uses to call the server's runner API.
+type RunnerAPITransport string
+
+const (
+	RunnerAPITransportREST RunnerAPITransport = "rest"
+	RunnerAPITransportGRPC RunnerAPITransport = "grpc"
+)
+
+func (t RunnerAPITransport) Valid() bool {
+	return t == RunnerAPITransportREST || t == RunnerAPITransportGRPC
+}
+
+func (t RunnerAPITransport) String() string {
+	return string(t)
+}
+
 // LogSafeFlags is a list of flags by name whose values are safe to log.
 var LogSafeFlags = []string{
 	"runner_api_endpoints",
+	"runner_api_transport",
+	"runner_grpc_api_endpoint",
 	"runner_config_directory",
 	"runner_log_temp_directory",
+	"runner_spool_directory",
+	"health_check_interval",
+	"health_check_disk_path",
+	"health_check_docker",
 	"dev_insecure_skip_verify",
 	"log_levels",
+	"tracing_otlp_endpoint",
+	"tracing_sample_ratio",
+	"job_type_plugins",
+	"exec_job_allowlist",
+	"container_engine",
+	"container_engine_host",
+	"container_engine_rootless",
+	"container_registry_mirror",
 }
 
 type RunnerConfig struct {
 	RunnerAPIEndpoints    []string
+	RunnerAPITransport    RunnerAPITransport
+	RunnerGRPCAPIEndpoint string
 	RunnerLogTempDir      logging.RunnerLogTempDirectory
+	RunnerSpoolDir        runner.RunnerSpoolDirectory
 	RunnerCertificateFile certificates.CertificateFile
 	RunnerPrivateKeyFile  certificates.PrivateKeyFile
 	AutoCreateCertificate client.AutoCreateCertificate
@@ -40,13 +79,22 @@ type RunnerConfig struct {
 	LogUnregisteredCert   bool
 	LogLevels             logger.LogLevelConfig
 	SchedulerConfig       runner.SchedulerConfig
+	HealthMonitorConfig   runner.HealthMonitorConfig
 	ExecutorConfig        runner.ExecutorConfig
+	TracingConfig         tracing.Config
 }
 
 func ConfigFromFlags() (*RunnerConfig, error) {
 	var (
 		runnerConfigDir     string
 		runnerLogTempDirStr string
+		runnerSpoolDirStr   string
+		tracingOTLPHeaders  string
+		jobTypePlugins      []string
+		execJobAllowlist    []string
+		containerEngine     docker.EngineConfig
+		containerEngineType string
+		capacityConfig      runner.CapacityConfig
 	)
 	config := &RunnerConfig{
 		ExecutorConfig: runner.ExecutorConfig{
@@ -56,12 +104,18 @@ func ConfigFromFlags() (*RunnerConfig, error) {
 
 	flag.StringArrayVar(&config.RunnerAPIEndpoints, "runner_api_endpoints", []string{"https://runner.changeme.com"},
 		"One or more endpoints to connect to the BuildBeaver server's Runner API")
+	flag.StringVar((*string)(&config.RunnerAPITransport), "runner_api_transport", RunnerAPITransportREST.String(),
+		fmt.Sprintf("The protocol to call the Runner API with; one of %s or %s. With %s, dequeuing jobs, status updates, logs and artifacts use --runner_grpc_api_endpoint and everything else uses --runner_api_endpoints.", RunnerAPITransportREST, RunnerAPITransportGRPC, RunnerAPITransportGRPC))
+	flag.StringVar(&config.RunnerGRPCAPIEndpoint, "runner_grpc_api_endpoint", "",
+		"The host:port address of the BuildBeaver server's gRPC Runner API. Required if --runner_api_transport is grpc.")
 	flag.StringVar((*string)(&config.ExecutorConfig.DynamicAPIEndpoint), "dynamic_api_endpoint", "https://app.changeme.com",
 		"The endpoint for build jobs to connect to the Dynamic API.")
 	flag.StringVar(&runnerConfigDir, "runner_config_directory",
 		defaultRunnerConfigDir, "The path on the local host containing configuration and certificates for the runner.")
 	flag.StringVar(&runnerLogTempDirStr, "runner_log_temp_directory",
 		defaultRunnerLogTempDir, "The path on the local host where the runner can buffer build logs in temporary files.")
+	flag.StringVar(&runnerSpoolDirStr, "runner_spool_directory",
+		defaultRunnerSpoolDir, "The path on the local host where the runner spools status updates, logs and artifacts while the server is unreachable.")
 	flag.BoolVar((*bool)(&config.AutoCreateCertificate), "auto_create_certificate",
 		true, "True to automatically create a key pair and client certificate for the runner to use when authenticating to the server, if not already configured.")
 	flag.BoolVar((*bool)(&config.InsecureSkipVerify), "dev_insecure_skip_verify",
@@ -70,15 +124,111 @@ func ConfigFromFlags() (*RunnerConfig, error) {
 		"", fmt.Sprintf("A comma separated list of name=level pairs where name is the name of the logger and level is one of: %s", logger.ListLogLevels()))
 	flag.DurationVar(&config.SchedulerConfig.PollInterval, "poll_interval",
 		runner.DefaultPollInterval, "The interval to check for new jobs to run.")
+	flag.DurationVar(&config.SchedulerConfig.DequeueWait, "dequeue_wait",
+		runner.DefaultDequeueWait, "How long to ask the server to wait for a new job when checking for jobs to run, so jobs are picked up as soon as they are queued. Set to zero to only check every poll interval.")
 	flag.IntVar(&config.SchedulerConfig.ParallelJobs, "parallel_jobs",
 		runner.DefaultParallelBuilds, "The number of jobs to run in parallel.")
+	flag.DurationVar(&config.HealthMonitorConfig.Interval, "health_check_interval",
+		runner.DefaultHealthCheckInterval, "The interval to check the health of the runner's host and report it to the server. Set to zero to disable health reporting.")
+	flag.StringVar(&config.HealthMonitorConfig.DiskPath, "health_check_disk_path",
+		os.TempDir(), "The path on the local host to check for free disk space; this should be where jobs are run.")
+	flag.BoolVar(&config.HealthMonitorConfig.CheckDocker, "health_check_docker",
+		true, "True to report the runner as unhealthy if the docker daemon is not reachable.")
+	flag.StringVar(&config.TracingConfig.OTLPEndpoint, "tracing_otlp_endpoint",
+		"", "The base URL of an OpenTelemetry collector to export traces to using OTLP/HTTP (e.g. http://localhost:4318). Tracing is disabled if not set.")
+	flag.StringVar(&tracingOTLPHeaders, "tracing_otlp_headers",
+		"", "A comma separated list of key=value pairs to send as headers when exporting traces.")
+	flag.Float64Var(&config.TracingConfig.SampleRatio, "tracing_sample_ratio",
+		tracing.DefaultSampleRatio, "The fraction of new traces to record, between 0 and 1.")
+	flag.StringArrayVar(&jobTypePlugins, "job_type_plugins",
+		nil, "One or more custom job types to support, each in the form type=path where path is the executable implementing the job type.")
+	flag.StringArrayVar(&execJobAllowlist, "exec_job_allowlist",
+		[]string{"*"}, "One or more repos whose jobs may use the exec job type to run commands directly on the runner's host, each in the form owner/repo, or owner to allow all of an owner's repos, or * to allow any repo. Set to an empty string to disable exec jobs. Defaults to * so runners keep running exec jobs from any repo as they did before the allowlist existed; since exec jobs have full access to the runner's host, set this on any runner that runs jobs for repos you don't fully trust.")
+	flag.StringVar(&containerEngineType, "container_engine",
+		docker.EngineTypeDocker.String(), fmt.Sprintf("The engine to run docker jobs and their services with; one of %s or %s.", docker.EngineTypeDocker, docker.EngineTypePodman))
+	flag.StringVar(&containerEngine.Host, "container_engine_host",
+		"", "The address of the container engine's API (e.g. unix:///run/podman/podman.sock). Defaults to DOCKER_HOST if set, otherwise the engine's default socket.")
+	flag.BoolVar(&containerEngine.Rootless, "container_engine_rootless",
+		false, "True if the container engine runs rootless, as the same unprivileged user as the runner.")
+	flag.BoolVar(&containerEngine.DiskLimits, "container_engine_disk_limits",
+		false, "True to limit the size of each docker job's container to the disk space the job requires. Only supported by some storage drivers (e.g. overlay2 on xfs with project quotas).")
+	flag.StringVar(&containerEngine.RegistryMirror, "container_registry_mirror",
+		"", "The address of a pull-through registry mirror (e.g. mirror.example.com:5000, optionally with a path prefix) to pull Docker Hub images from. Images are pulled from Docker Hub directly if the mirror can't provide them.")
+	flag.StringVar(&capacityConfig.CPU, "capacity_cpu",
+		runner.CapacityAuto, "The CPU available to jobs, as a number of cores or thousandths of a core (e.g. 500m). Jobs are only taken while their CPU requirements fit in what is left. Set to 'auto' to use the number of CPUs on the host, or an empty string for no limit.")
+	flag.StringVar(&capacityConfig.Memory, "capacity_memory",
+		runner.CapacityAuto, "The memory available to jobs, in bytes or with a unit suffix (e.g. 16GiB). Jobs are only taken while their memory requirements fit in what is left. Set to 'auto' to use the total memory of the host, or an empty string for no limit.")
+	flag.StringVar(&capacityConfig.Disk, "capacity_disk",
+		"", "The disk space available to jobs, in bytes or with a unit suffix (e.g. 100GB). Jobs are only taken while their disk requirements fit in what is left. Set to 'auto' to use the size of the filesystem containing --health_check_disk_path, or an empty string for no limit.")
+	flag.StringVar(&capacityConfig.GPUs, "capacity_gpus",
+		runner.CapacityAuto, "The number of GPUs available to jobs. Jobs requiring GPUs are only taken while enough GPUs are free, and each docker job is given its own GPUs via the NVIDIA container runtime. Set to 'auto' to count the GPUs listed by nvidia-smi, or an empty string for no GPUs.")
 	flag.Parse()
 
+	if !config.RunnerAPITransport.Valid() {
+		return nil, fmt.Errorf("error invalid --runner_api_transport %q; expected %s or %s", config.RunnerAPITransport, RunnerAPITransportREST, RunnerAPITransportGRPC)
+	}
+	if config.RunnerAPITransport == RunnerAPITransportGRPC && config.RunnerGRPCAPIEndpoint == "" {
+		return nil, fmt.Errorf("error --runner_grpc_api_endpoint must be set when --runner_api_transport is %s", RunnerAPITransportGRPC)
+	}
+
 	config.RunnerLogTempDir = logging.RunnerLogTempDirectory(runnerLogTempDirStr)
+	config.RunnerSpoolDir = runner.RunnerSpoolDirectory(runnerSpoolDirStr)
 	config.RunnerCertificateFile = certificates.CertificateFile(filepath.Join(runnerConfigDir, DefaultRunnerCertFile))
 	config.RunnerPrivateKeyFile = certificates.PrivateKeyFile(filepath.Join(runnerConfigDir, DefaultRunnerPrivateKeyFile))
 	config.CACertFile = certificates.CACertificateFile(filepath.Join(runnerConfigDir, DefaultRunnerCACertificateFile))
 	config.LogUnregisteredCert = true // log the certificate if not registered
 
+	config.TracingConfig.ServiceName = "bb-runner"
+	tracingHeaders, err := tracing.ParseHeaders(tracingOTLPHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing --tracing_otlp_headers: %w", err)
+	}
+	config.TracingConfig.OTLPHeaders = tracingHeaders
+
+	var execPlugins []plugins.ExecPluginConfig
+	for _, str := range jobTypePlugins {
+		execPlugin, err := plugins.ParseExecPluginConfig(str)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing --job_type_plugins: %w", err)
+		}
+		execPlugins = append(execPlugins, execPlugin)
+	}
+	config.ExecutorConfig.JobTypePlugins, err = plugins.NewJobTypePlugins(execPlugins)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring job type plugins: %w", err)
+	}
+	config.ExecutorConfig.ExecJobAllowlist, err = models.ParseExecJobAllowlist(execJobAllowlist)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing --exec_job_allowlist: %w", err)
+	}
+	config.SchedulerConfig.SupportedJobTypes = config.ExecutorConfig.JobTypePlugins.SupportedJobTypes()
+	config.SchedulerConfig.ExecJobAllowlist = config.ExecutorConfig.ExecJobAllowlist
+	if config.ExecutorConfig.ExecJobAllowlist.IsEmpty() {
+		// Don't take exec jobs from the server if no repo is allowed to run them
+		var supported models.JobTypes
+		for _, jobType := range config.SchedulerConfig.SupportedJobTypes {
+			if jobType != models.JobTypeExec {
+				supported = append(supported, jobType)
+			}
+		}
+		config.SchedulerConfig.SupportedJobTypes = supported
+	}
+
+	containerEngine.Type = docker.EngineType(containerEngineType)
+	if !containerEngine.Type.Valid() {
+		return nil, fmt.Errorf("error invalid --container_engine %q; expected %s or %s", containerEngineType, docker.EngineTypeDocker, docker.EngineTypePodman)
+	}
+	config.ExecutorConfig.ContainerEngine = containerEngine
+	config.HealthMonitorConfig.ContainerEngine = containerEngine
+
+	capacityConfig.DiskPath = config.HealthMonitorConfig.DiskPath
+	config.SchedulerConfig.Capacity, err = runner.ParseCapacity(capacityConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring runner capacity: %w", err)
+	}
+	if config.SchedulerConfig.Capacity.GPUs > 0 {
+		config.ExecutorConfig.GPUs = runner.NewGPUAllocator(config.SchedulerConfig.Capacity.GPUs)
+	}
+
 	return config, nil
 }
@@ -6,4 +6,5 @@ package app
 const (
 	defaultRunnerConfigDir  = "/var/lib/buildbeaver/runners/default"
 	defaultRunnerLogTempDir = "/var/lib/buildbeaver/runners/default/" + DefaultRunnerLogTempDirName
+	defaultRunnerSpoolDir   = "/var/lib/buildbeaver/runners/default/" + DefaultRunnerSpoolDirName
 )
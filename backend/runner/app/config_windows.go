@@ -6,4 +6,5 @@ package app
 const (
 	defaultRunnerConfigDir  = "C:\\ProgramData\\buildbeaver\\runners\\default"
 	defaultRunnerLogTempDir = "C:\\ProgramData\\buildbeaver\\runners\\default\\" + DefaultRunnerLogTempDirName
+	defaultRunnerSpoolDir   = "C:\\ProgramData\\buildbeaver\\runners\\default\\" + DefaultRunnerSpoolDirName
 )
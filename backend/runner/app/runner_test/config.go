@@ -17,6 +17,7 @@ func TestConfig(t *testing.T) *app.RunnerConfig {
 	return &app.RunnerConfig{
 		RunnerAPIEndpoints:    []string{"https://localhost:3002"}, // best guess at a default, local runner API server
 		RunnerLogTempDir:      logging.RunnerLogTempDirectory(filepath.Join(configDir, app.DefaultRunnerLogTempDirName)),
+		RunnerSpoolDir:        runner.RunnerSpoolDirectory(filepath.Join(configDir, app.DefaultRunnerSpoolDirName)),
 		RunnerCertificateFile: certificates.CertificateFile(filepath.Join(configDir, app.DefaultRunnerCertFile)),
 		RunnerPrivateKeyFile:  certificates.PrivateKeyFile(filepath.Join(configDir, app.DefaultRunnerPrivateKeyFile)),
 		AutoCreateCertificate: true,
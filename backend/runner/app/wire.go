@@ -11,8 +11,10 @@ import (
 
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 	"github.com/buildbeaver/buildbeaver/runner"
 	"github.com/buildbeaver/buildbeaver/runner/logging"
+	grpcclient "github.com/buildbeaver/buildbeaver/server/api/grpc/client"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
 )
 
@@ -26,17 +28,58 @@ func MakeLogPipelineFactory(
 	}
 }
 
+// TransportAPIClient is the client for the runner API using the configured transport, before spooling.
+type TransportAPIClient interface {
+	runner.APIClient
+}
+
+func MakeTransportAPIClient(
+	config *RunnerConfig,
+	restClient *client.APIClient,
+	authenticator *client.ClientCertificateAuthenticator,
+	logFactory logger.LogFactory,
+) (TransportAPIClient, error) {
+	if config.RunnerAPITransport == RunnerAPITransportGRPC {
+		return grpcclient.NewAPIClient(config.RunnerGRPCAPIEndpoint, restClient, authenticator, logFactory)
+	}
+	return restClient, nil
+}
+
+// MakeJobEventSource returns the transport client as a source of job events, or nil if the transport
+// does not support watching jobs.
+func MakeJobEventSource(apiClient TransportAPIClient) runner.JobEventSource {
+	if source, ok := apiClient.(runner.JobEventSource); ok {
+		return source
+	}
+	return nil
+}
+
+func MakeSpoolingAPIClient(
+	apiClient TransportAPIClient,
+	spool *runner.Spool,
+	logFactory logger.LogFactory,
+) *runner.SpoolingAPIClient {
+	return runner.NewSpoolingAPIClient(apiClient, spool, logFactory)
+}
+
 func New(config *RunnerConfig) (*Runner, error) {
 	panic(wire.Build(
 		NewRunner,
-		wire.FieldsOf(new(*RunnerConfig), "RunnerAPIEndpoints", "RunnerLogTempDir", "RunnerCertificateFile", "RunnerPrivateKeyFile", "AutoCreateCertificate", "CACertFile", "InsecureSkipVerify", "SchedulerConfig", "ExecutorConfig", "LogLevels"),
+		wire.FieldsOf(new(*RunnerConfig), "RunnerAPIEndpoints", "RunnerLogTempDir", "RunnerSpoolDir", "RunnerCertificateFile", "RunnerPrivateKeyFile", "AutoCreateCertificate", "CACertFile", "InsecureSkipVerify", "SchedulerConfig", "HealthMonitorConfig", "ExecutorConfig", "LogLevels", "TracingConfig"),
+		tracing.NewTracer,
 		client.NewClientCertificateAuthenticator,
 		wire.Bind(new(client.Authenticator), new(*client.ClientCertificateAuthenticator)),
 		client.NewAPIClient,
-		wire.Bind(new(runner.APIClient), new(*client.APIClient)),
+		MakeTransportAPIClient,
+		MakeJobEventSource,
+		runner.NewSpool,
+		MakeSpoolingAPIClient,
+		wire.Bind(new(runner.APIClient), new(*runner.SpoolingAPIClient)),
 		runner.MakeExecutorFactory,
 		runner.MakeOrchestratorFactory,
 		runner.NewJobScheduler,
+		runner.NewHealthMonitor,
+		runner.NewJobWatcher,
 		runner.NewRegistrar,
 		logger.NewLogRegistry,
 		logger.MakeLogrusLogFactoryStdOut,
@@ -2,6 +2,7 @@ package runner
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -17,6 +18,7 @@ import (
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/runner/logging"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
 )
 
 type ArtifactManager struct {
@@ -35,18 +37,38 @@ func NewArtifactManager(local bool,
 	}
 }
 
-// UploadArtifacts uploads all artifacts produced by the job.
+// UploadArtifacts uploads all artifacts declared by the job.
 // Any errors encountered are wrapped in ErrArtifactUploadFailed error codes
 func (b *ArtifactManager) UploadArtifacts(ctx *JobBuildContext, globalEnvVarsByName map[string]string) error {
 	if ctx.IsJobIndirected() {
 		return nil
 	}
-	if len(ctx.Job().Job.ArtifactDefinitions) == 0 {
+	return b.uploadArtifacts(ctx, ctx.LogPipeline(), ctx.Job().Job.ArtifactDefinitions, globalEnvVarsByName)
+}
+
+// UploadStepArtifacts uploads all artifacts declared by the step. Step artifacts belong to the job, and
+// are uploaded as soon as the step finishes so they are captured even if a later step fails.
+// Any errors encountered are wrapped in ErrArtifactUploadFailed error codes
+func (b *ArtifactManager) UploadStepArtifacts(ctx *StepBuildContext, globalEnvVarsByName map[string]string) error {
+	if ctx.IsJobIndirected() {
 		return nil
 	}
-	uploadLogger := ctx.LogPipeline().StructuredLogger().Wrap("artifact_upload", "Uploading artifacts...")
+	return b.uploadArtifacts(ctx.JobBuildContext, ctx.LogPipeline(), ctx.Step().ArtifactDefinitions, globalEnvVarsByName)
+}
+
+// uploadArtifacts uploads all artifacts matching the specified artifact definitions, logging to the
+// specified log pipeline.
+func (b *ArtifactManager) uploadArtifacts(
+	ctx *JobBuildContext,
+	logPipeline logging.LogPipeline,
+	artifactDefinitions []*documents.ArtifactDefinition,
+	globalEnvVarsByName map[string]string) error {
+	if len(artifactDefinitions) == 0 {
+		return nil
+	}
+	uploadLogger := logPipeline.StructuredLogger().Wrap("artifact_upload", "Uploading artifacts...")
 	var results *multierror.Error
-	for _, artifactDefinition := range ctx.Job().Job.ArtifactDefinitions {
+	for _, artifactDefinition := range artifactDefinitions {
 		for _, rawPath := range artifactDefinition.Paths {
 			absolutePath := filepath.Join(
 				b.hostWorkspaceDir,
@@ -109,9 +131,44 @@ func (b *ArtifactManager) DownloadArtifacts(ctx *JobBuildContext) error {
 			}
 		}
 	}
+	// Artifacts from previous builds have already been resolved by the server when the job was dequeued
+	for _, doc := range ctx.Job().ExternalArtifacts {
+		if downloadLogger == nil {
+			downloadLogger = ctx.LogPipeline().StructuredLogger().Wrap("artifact_download", "Downloading artifacts...")
+		}
+		err := b.downloadArtifact(ctx, downloadLogger, artifactFromDocument(doc))
+		if err != nil {
+			return errors.Wrap(err, "error downloading artifact from previous build")
+		}
+	}
 	return nil
 }
 
+// artifactFromDocument converts an artifact document received from the server into a model.
+func artifactFromDocument(doc *documents.Artifact) *models.Artifact {
+	return &models.Artifact{
+		ID:         doc.ID,
+		ETag:       doc.ETag,
+		HashType:   doc.HashType,
+		Hash:       doc.Hash,
+		SHA256:     doc.SHA256,
+		Signature:  doc.Signature,
+		Size:       doc.Size,
+		Mime:       doc.Mime,
+		Sealed:     doc.Sealed,
+		ScanStatus: doc.ScanStatus,
+		ScanResult: doc.ScanResult,
+		ArtifactData: models.ArtifactData{
+			Name:      doc.Name,
+			JobID:     doc.JobID,
+			CreatedAt: doc.CreatedAt,
+			UpdatedAt: doc.UpdatedAt,
+			GroupName: doc.GroupName,
+			Path:      doc.Path,
+		},
+	}
+}
+
 // downloadArtifact downloads a single artifact to the workspace.
 func (b *ArtifactManager) downloadArtifact(ctx *JobBuildContext, downloadLogger *logging.StructuredLogger, artifact *models.Artifact) error {
 	absolutePath := filepath.Join(b.hostWorkspaceDir, artifact.Path)
@@ -140,11 +197,20 @@ func (b *ArtifactManager) downloadArtifact(ctx *JobBuildContext, downloadLogger
 		if err != nil {
 			return errors.Wrap(err, "error opening artifact file for writing")
 		}
-		// TODO verify md5 sum
-		_, err = io.Copy(file, reader)
+		defer file.Close()
+		// Check the data against the SHA-256 hash recorded when the artifact was uploaded, so a tampered
+		// artifact fails the job rather than being used by its steps
+		sha256Hash := sha256.New()
+		_, err = io.Copy(io.MultiWriter(file, sha256Hash), reader)
 		if err != nil {
 			return errors.Wrap(err, "error writing artifact file")
 		}
+		if artifact.SHA256 != "" {
+			calculated := hex.EncodeToString(sha256Hash.Sum(nil))
+			if calculated != artifact.SHA256 {
+				return fmt.Errorf("error artifact %q SHA-256 mismatch; expected %q, downloaded data has %q", artifact.Path, artifact.SHA256, calculated)
+			}
+		}
 	}
 	return nil
 }
@@ -219,6 +285,11 @@ func (b *ArtifactManager) uploadArtifact(ctx *JobBuildContext, uploadLogger *log
 		relativePath,
 		file)
 	if err != nil {
+		if gerror.IsAlreadyExists(err) {
+			// Paths are unique within a job; this file was already uploaded by one of the job's steps
+			uploadLogger.WriteLinef("Artifact already uploaded from path %s", relativePath)
+			return nil
+		}
 		return errors.Wrap(err, "error creating artifact")
 	}
 	return nil
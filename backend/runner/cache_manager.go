@@ -0,0 +1,306 @@
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/runner/logging"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// CacheManager restores the build caches declared by a job into the workspace before the job's steps run,
+// and saves them back to the server once the job has succeeded. Caches are transferred as gzipped tarballs
+// of the cache's paths, relative to the workspace.
+type CacheManager struct {
+	local            bool
+	hostWorkspaceDir string
+	stagingDir       string
+	apiClient        APIClient
+}
+
+func NewCacheManager(
+	local bool,
+	hostWorkspaceDir string,
+	stagingDir string,
+	apiClient APIClient) *CacheManager {
+	return &CacheManager{
+		local:            local,
+		hostWorkspaceDir: hostWorkspaceDir,
+		stagingDir:       stagingDir,
+		apiClient:        apiClient,
+	}
+}
+
+// RestoreCaches restores each cache the job declares, using the calculated cache keys. If a cache has no entry
+// matching its key, the most recently used entry for the cache is restored instead. Returns the set of caches
+// that were restored from an entry with an exact key match; these don't need to be saved again after the job.
+// Errors restoring an individual cache are logged to the job log and the cache is skipped, since a missing
+// cache should only slow a job down and never cause it to fail.
+func (b *CacheManager) RestoreCaches(ctx *JobBuildContext, keys map[models.ResourceName]string) map[models.ResourceName]bool {
+	exactHits := make(map[models.ResourceName]bool)
+	if b.local || ctx.IsJobIndirected() || len(keys) == 0 {
+		return exactHits
+	}
+	restoreLogger := ctx.LogPipeline().StructuredLogger().Wrap("cache_restore", "Restoring caches...")
+	for _, definition := range ctx.Job().Job.CacheDefinitions {
+		key, ok := keys[definition.Name]
+		if !ok {
+			continue
+		}
+		cacheEntry, err := b.apiClient.FindCache(ctx.Ctx(), ctx.Job().Job.ID, definition.Name, key)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				restoreLogger.WriteLinef("Cache %s: no entries found", definition.Name)
+			} else {
+				restoreLogger.WriteLinef("Cache %s: warning: error finding cache entry: %s", definition.Name, err)
+			}
+			continue
+		}
+		err = b.restoreCacheEntry(ctx, cacheEntry)
+		if err != nil {
+			restoreLogger.WriteLinef("Cache %s: warning: error restoring cache entry: %s", definition.Name, err)
+			continue
+		}
+		if cacheEntry.Key == key {
+			exactHits[definition.Name] = true
+			restoreLogger.WriteLinef("Cache %s: restored %d bytes (key matched)", definition.Name, cacheEntry.Size)
+		} else {
+			restoreLogger.WriteLinef("Cache %s: restored %d bytes from most recent entry (key did not match)", definition.Name, cacheEntry.Size)
+		}
+	}
+	return exactHits
+}
+
+// SaveCaches saves each cache the job declares that was not restored from an entry with an exact key match.
+// Errors saving an individual cache are logged to the job log and the cache is skipped.
+func (b *CacheManager) SaveCaches(ctx *JobBuildContext, keys map[models.ResourceName]string, exactHits map[models.ResourceName]bool) {
+	if b.local || ctx.IsJobIndirected() || len(keys) == 0 {
+		return
+	}
+	var saveLogger *logging.StructuredLogger
+	for _, definition := range ctx.Job().Job.CacheDefinitions {
+		key, ok := keys[definition.Name]
+		if !ok || exactHits[definition.Name] {
+			continue
+		}
+		if saveLogger == nil {
+			// Only log when we have at least one cache to save...
+			saveLogger = ctx.LogPipeline().StructuredLogger().Wrap("cache_save", "Saving caches...")
+		}
+		size, err := b.saveCache(ctx, definition, key)
+		if err != nil {
+			saveLogger.WriteLinef("Cache %s: warning: error saving cache: %s", definition.Name, err)
+			continue
+		}
+		saveLogger.WriteLinef("Cache %s: saved %d bytes", definition.Name, size)
+	}
+}
+
+// saveCache writes a tarball of the paths for a cache to the staging directory and uploads it to the server.
+// Returns the size of the tarball.
+func (b *CacheManager) saveCache(ctx *JobBuildContext, definition *documents.CacheDefinition, key string) (int64, error) {
+	file, err := ioutil.TempFile(b.stagingDir, "cache-*.tar.gz")
+	if err != nil {
+		return 0, errors.Wrap(err, "error creating temporary file for cache")
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	err = b.writeTarball(file, definition.Paths)
+	if err != nil {
+		return 0, err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, errors.Wrap(err, "error determining cache size")
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, errors.Wrap(err, "error rewinding cache file")
+	}
+	_, err = b.apiClient.SaveCache(ctx.Ctx(), ctx.Job().Job.ID, definition.Name, key, file)
+	if err != nil {
+		return 0, errors.Wrap(err, "error uploading cache")
+	}
+	return size, nil
+}
+
+// writeTarball writes a gzipped tarball of all files, directories and symlinks matching the specified paths
+// (relative to the workspace) to writer.
+func (b *CacheManager) writeTarball(writer io.Writer, paths []string) error {
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	seen := make(map[string]bool)
+	for _, rawPath := range paths {
+		matches, err := doublestar.Glob(filepath.Join(b.hostWorkspaceDir, rawPath))
+		if err != nil {
+			return fmt.Errorf("error executing glob %q: %w", rawPath, err)
+		}
+		for _, match := range matches {
+			err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if seen[path] {
+					return nil
+				}
+				seen[path] = true
+				return b.addToTarball(tarWriter, path, info)
+			})
+			if err != nil {
+				return fmt.Errorf("error adding %q to cache: %w", match, err)
+			}
+		}
+	}
+	err := tarWriter.Close()
+	if err != nil {
+		return errors.Wrap(err, "error closing tar writer")
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return errors.Wrap(err, "error closing gzip writer")
+	}
+	return nil
+}
+
+func (b *CacheManager) addToTarball(tarWriter *tar.Writer, path string, info os.FileInfo) error {
+	mode := info.Mode()
+	if !mode.IsRegular() && !mode.IsDir() && mode&os.ModeSymlink == 0 {
+		return nil // skip sockets, devices etc.
+	}
+	relativePath, err := filepath.Rel(b.hostWorkspaceDir, path)
+	if err != nil {
+		return errors.Wrap(err, "error making relative path")
+	}
+	var link string
+	if mode&os.ModeSymlink != 0 {
+		link, err = os.Readlink(path)
+		if err != nil {
+			return errors.Wrap(err, "error reading symlink")
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return errors.Wrap(err, "error making tar header")
+	}
+	header.Name = filepath.ToSlash(relativePath)
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		return errors.Wrap(err, "error writing tar header")
+	}
+	if !mode.IsRegular() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "error opening file for reading")
+	}
+	defer file.Close()
+	_, err = io.Copy(tarWriter, file)
+	if err != nil {
+		return errors.Wrap(err, "error writing file to tarball")
+	}
+	return nil
+}
+
+// restoreCacheEntry downloads the tarball for a cache entry and extracts it into the workspace,
+// overwriting any existing files.
+func (b *CacheManager) restoreCacheEntry(ctx *JobBuildContext, cacheEntry *documents.CacheEntry) error {
+	reader, err := b.apiClient.GetCacheEntryData(ctx.Ctx(), ctx.Job().Job.ID, cacheEntry.ID)
+	if err != nil {
+		return errors.Wrap(err, "error getting data")
+	}
+	defer reader.Close()
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return errors.Wrap(err, "error opening gzip reader")
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error reading tarball")
+		}
+		err = b.extractFromTarball(tarReader, header)
+		if err != nil {
+			return fmt.Errorf("error extracting %q: %w", header.Name, err)
+		}
+	}
+}
+
+func (b *CacheManager) extractFromTarball(tarReader *tar.Reader, header *tar.Header) error {
+	absolutePath := filepath.Join(b.hostWorkspaceDir, filepath.FromSlash(header.Name))
+	if !b.isInWorkspace(absolutePath) {
+		return errors.New("error path is outside the workspace")
+	}
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(absolutePath, os.FileMode(header.Mode)|0700)
+	case tar.TypeReg:
+		err := b.prepareParentDir(absolutePath)
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(absolutePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)|0600)
+		if err != nil {
+			return errors.Wrap(err, "error opening file for writing")
+		}
+		defer file.Close()
+		_, err = io.Copy(file, tarReader)
+		if err != nil {
+			return errors.Wrap(err, "error writing file")
+		}
+		return nil
+	case tar.TypeSymlink:
+		target := header.Linkname
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(absolutePath), target)
+		}
+		if !b.isInWorkspace(target) {
+			return errors.New("error symlink target is outside the workspace")
+		}
+		err := b.prepareParentDir(absolutePath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(header.Linkname, absolutePath)
+	default:
+		return nil // skip anything else
+	}
+}
+
+// prepareParentDir creates the parent directory for a file being extracted, and removes anything already
+// at the file's path so it can be replaced.
+func (b *CacheManager) prepareParentDir(absolutePath string) error {
+	err := os.MkdirAll(filepath.Dir(absolutePath), 0777)
+	if err != nil {
+		return errors.Wrap(err, "error creating parent directory")
+	}
+	err = os.Remove(absolutePath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "error removing existing file")
+	}
+	return nil
+}
+
+func (b *CacheManager) isInWorkspace(absolutePath string) bool {
+	relativePath, err := filepath.Rel(b.hostWorkspaceDir, absolutePath)
+	if err != nil {
+		return false
+	}
+	return relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// CapacityAuto can be given for any resource in a capacity to detect the amount available on the runner's host.
+const CapacityAuto = "auto"
+
+// CapacityConfig is the runner's configured capacity, as strings given on the command line. Each resource
+// is an amount (see models.ParseCPUMillis, models.ParseBytes and models.ParseGPUs), CapacityAuto, or empty
+// for no limit (or, for GPUs, no GPUs).
+type CapacityConfig struct {
+	CPU    string
+	Memory string
+	Disk   string
+	GPUs   string
+	// DiskPath is the path on the runner's host whose filesystem jobs run on, used to detect the disk
+	// capacity automatically.
+	DiskPath string
+}
+
+// ParseCapacity converts the configured capacity to the resources to advertise to the server.
+func ParseCapacity(config CapacityConfig) (models.Resources, error) {
+	var capacity models.Resources
+	switch str := strings.TrimSpace(config.CPU); {
+	case str == "":
+	case strings.EqualFold(str, CapacityAuto):
+		capacity.CPUMillis = int64(runtime.NumCPU()) * 1000
+	default:
+		millis, err := models.ParseCPUMillis(str)
+		if err != nil {
+			return capacity, fmt.Errorf("error parsing CPU capacity: %w", err)
+		}
+		capacity.CPUMillis = millis
+	}
+
+	switch str := strings.TrimSpace(config.Memory); {
+	case str == "":
+	case strings.EqualFold(str, CapacityAuto):
+		stats, err := getResourceStats()
+		if err != nil {
+			return capacity, fmt.Errorf("error detecting memory capacity: %w", err)
+		}
+		// Left as zero (unlimited) where the total memory can't be measured
+		capacity.MemoryBytes = int64(stats.MemoryTotalBytes)
+	default:
+		bytes, err := models.ParseBytes(str)
+		if err != nil {
+			return capacity, fmt.Errorf("error parsing memory capacity: %w", err)
+		}
+		capacity.MemoryBytes = bytes
+	}
+
+	switch str := strings.TrimSpace(config.Disk); {
+	case str == "":
+	case strings.EqualFold(str, CapacityAuto):
+		_, total, err := getDiskSpace(config.DiskPath)
+		if err != nil {
+			return capacity, fmt.Errorf("error detecting disk capacity of %s: %w", config.DiskPath, err)
+		}
+		capacity.DiskBytes = int64(total)
+	default:
+		bytes, err := models.ParseBytes(str)
+		if err != nil {
+			return capacity, fmt.Errorf("error parsing disk capacity: %w", err)
+		}
+		capacity.DiskBytes = bytes
+	}
+
+	switch str := strings.TrimSpace(config.GPUs); {
+	case str == "":
+	case strings.EqualFold(str, CapacityAuto):
+		gpus, err := detectGPUs()
+		if err != nil {
+			return capacity, fmt.Errorf("error detecting GPU capacity: %w", err)
+		}
+		capacity.GPUs = gpus
+	default:
+		gpus, err := models.ParseGPUs(str)
+		if err != nil {
+			return capacity, fmt.Errorf("error parsing GPU capacity: %w", err)
+		}
+		capacity.GPUs = gpus
+	}
+	return capacity, nil
+}
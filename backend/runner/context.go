@@ -11,6 +11,7 @@ type JobBuildContext struct {
 	ctx         context.Context
 	job         *documents.RunnableJob
 	logPipeline logging.LogPipeline
+	jobErr      error
 }
 
 func NewJobBuildContext(ctx context.Context, job *documents.RunnableJob) *JobBuildContext {
@@ -56,6 +57,17 @@ func (c *JobBuildContext) LogPipeline() logging.LogPipeline {
 	return c.logPipeline
 }
 
+// SetJobError records the error the job failed with, or nil if the job succeeded, so that the job can
+// be torn down appropriately.
+func (c *JobBuildContext) SetJobError(err error) {
+	c.jobErr = err
+}
+
+// JobError returns the error the job failed with, or nil if the job succeeded or is still running.
+func (c *JobBuildContext) JobError() error {
+	return c.jobErr
+}
+
 // IsJobIndirected gets the indirect status for the job. If true this job should not execute but should be marked as successful.
 func (c *JobBuildContext) IsJobIndirected() bool {
 	return c.job.Job.IndirectToJobID.Valid()
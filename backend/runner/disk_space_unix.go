@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// getDiskSpace returns the number of bytes available to the runner, and the total size in bytes, of the
+// filesystem containing path.
+func getDiskSpace(path string) (free uint64, total uint64, err error) {
+	var stat unix.Statfs_t
+	err = unix.Statfs(path, &stat)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
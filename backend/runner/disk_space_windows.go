@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package runner
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// getDiskSpace returns the number of bytes available to the runner, and the total size in bytes, of the
+// filesystem containing path.
+func getDiskSpace(path string) (free uint64, total uint64, err error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = windows.GetDiskFreeSpaceEx(pathPtr, &free, &total, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return free, total, nil
+}
@@ -2,6 +2,9 @@ package runner
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -13,14 +16,15 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
-	"github.com/docker/docker/client"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 
 	"github.com/buildbeaver/buildbeaver/common/dynamic_api"
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/runner/logging"
+	"github.com/buildbeaver/buildbeaver/runner/plugins"
 	"github.com/buildbeaver/buildbeaver/runner/runtime"
 	"github.com/buildbeaver/buildbeaver/runner/runtime/docker"
 	"github.com/buildbeaver/buildbeaver/runner/runtime/exec"
@@ -48,6 +52,18 @@ type ExecutorConfig struct {
 	// Any 'localhost'-style endpoint will automatically be converted to an endpoint suitable for use
 	// within docker containers as required.
 	DynamicAPIEndpoint dynamic_api.Endpoint
+	// ServiceOverrides replaces parts of the configuration of the services declared by jobs, keyed by
+	// service name. Used by bb to run services on a developer's machine.
+	ServiceOverrides ServiceOverrides
+	// JobTypePlugins provides the runtimes for custom job types, keyed by job type.
+	JobTypePlugins plugins.JobTypePlugins
+	// ExecJobAllowlist lists the repos whose jobs may use the exec job type, which runs commands directly on
+	// the host. If nil then jobs from any repo may use it.
+	ExecJobAllowlist models.ExecJobAllowlist
+	// ContainerEngine is the engine (e.g. Docker or Podman) used to run docker jobs and their services.
+	ContainerEngine docker.EngineConfig
+	// GPUs hands out the runner's GPUs to jobs that require them, or is nil if the runner has no GPUs.
+	GPUs *GPUAllocator
 }
 
 // Executor executes the various lifecycle phases of a job and is driven by the orchestrator.
@@ -66,6 +82,10 @@ type Executor struct {
 		sshAgentPID         string
 		globalEnvVars       []string
 		globalEnvVarsByName map[string]string
+		stepEnv             *stepEnv
+		cacheKeys           map[models.ResourceName]string
+		exactCacheHits      map[models.ResourceName]bool
+		gpuDeviceIDs        []string
 	}
 }
 
@@ -93,7 +113,11 @@ func (b *Executor) Close() {}
 func (b *Executor) PreExecuteJob(ctx *JobBuildContext) error {
 	log := b.withJobLogFields(b.log, ctx.job)
 	log.Info("PreExecuteJob")
-	b.secretStore = NewSecretStore(b.apiClient, ctx.Job().Job.RepoID)
+	var pinSecretsToBuildID *models.BuildID
+	if ctx.Job().Job.PinSecrets {
+		pinSecretsToBuildID = &ctx.Job().Job.BuildID
+	}
+	b.secretStore = NewSecretStore(b.apiClient, ctx.Job().Job.RepoID, pinSecretsToBuildID)
 	err := b.initFileSystem(ctx)
 	if err != nil {
 		return fmt.Errorf("error preparing job directories: %w", err)
@@ -135,6 +159,7 @@ func (b *Executor) PreExecuteJob(ctx *JobBuildContext) error {
 	if err != nil {
 		return fmt.Errorf("error downloading artifacts: %w", err)
 	}
+	b.restoreCaches(ctx)
 	err = b.prepareServices(ctx)
 	if err != nil {
 		return fmt.Errorf("error preparing services: %w", err)
@@ -165,16 +190,48 @@ func (b *Executor) ExecuteStep(ctx *StepBuildContext) error {
 	if err != nil {
 		return fmt.Errorf("error making env vars for step: %w", err)
 	}
+	// Expose variables exported by earlier steps, and the file this step can export its own variables to
+	err = b.state.stepEnv.Reset()
+	if err != nil {
+		return err
+	}
+	env = append(env, b.state.stepEnv.Mappings()...)
+	env = append(env, fmt.Sprintf("%s=%s", StepEnvFileEnvVar, b.state.stepEnv.filePath))
 
 	converter := ctx.LogPipeline().Converter()
 	config := runtime.ExecConfig{
-		Name:     ctx.Step().Name.String(),
-		Commands: models.CommandsToStrings(ctx.Step().Commands),
-		Env:      env,
-		Stdout:   converter,
-		Stderr:   converter,
+		Name:         ctx.Step().Name.String(),
+		Commands:     models.CommandsToStrings(ctx.Step().Commands),
+		Env:          env,
+		EchoCommands: ctx.Step().EchoCommands,
+		Stdout:       converter,
+		Stderr:       converter,
+	}
+	err = b.execStep(ctx, config)
+	if err != nil {
+		return err
+	}
+	err = b.state.stepEnv.Load()
+	if err != nil {
+		return fmt.Errorf("error loading env vars exported by step: %w", err)
+	}
+	return nil
+}
+
+// execStep runs the step's commands in the job's runtime, enforcing the step's timeout if it has one.
+func (b *Executor) execStep(ctx *StepBuildContext, config runtime.ExecConfig) error {
+	if ctx.Step().TimeoutSeconds <= 0 {
+		return b.state.runtime.Exec(ctx.Ctx(), config)
+	}
+	// The runtime kills the step's processes when the context is done, so a hung command can't stall the job
+	timeout := time.Duration(ctx.Step().TimeoutSeconds) * time.Second
+	execCtx, cancel := context.WithTimeout(ctx.Ctx(), timeout)
+	defer cancel()
+	err := b.state.runtime.Exec(execCtx, config)
+	if err != nil && execCtx.Err() == context.DeadlineExceeded && ctx.Ctx().Err() == nil {
+		return gerror.NewErrStepTimedOut(timeout)
 	}
-	return b.state.runtime.Exec(ctx.Ctx(), config)
+	return err
 }
 
 // LogStepError writes an error to the step's log pipeline.
@@ -197,6 +254,13 @@ func (b *Executor) PostExecuteStep(ctx *StepBuildContext) error {
 
 	var results *multierror.Error
 
+	// Upload any artifacts declared by the step as soon as it finishes, even if it failed, so they
+	// are captured regardless of what happens in later steps
+	err := NewArtifactManager(b.config.IsLocal, b.state.workspaceDir, b.apiClient).UploadStepArtifacts(ctx, b.state.globalEnvVarsByName)
+	if err != nil {
+		results = multierror.Append(results, fmt.Errorf("error uploading step artifacts: %w", err))
+	}
+
 	// Always flush and close any open log pipeline
 	ctx.LogPipeline().Flush()
 	ctx.LogPipeline().Close()
@@ -233,6 +297,13 @@ func (b *Executor) PostExecuteJob(ctx *JobBuildContext) error {
 		results = multierror.Append(results, fmt.Errorf("error uploading artifacts: %w", err))
 	}
 
+	// Save caches before removing the workspace; caches are only saved for successful jobs so a
+	// failed job can't poison the cache for later jobs
+	if ctx.JobError() == nil {
+		NewCacheManager(b.config.IsLocal, b.state.workspaceDir, b.state.stagingDir, b.apiClient).
+			SaveCaches(ctx, b.state.cacheKeys, b.state.exactCacheHits)
+	}
+
 	if b.state.runtime != nil {
 		// Use cleanup context, not job context, so we still clean up even if job has timed out
 		err := b.state.runtime.Stop(cleanupCtx)
@@ -240,6 +311,8 @@ func (b *Executor) PostExecuteJob(ctx *JobBuildContext) error {
 			results = multierror.Append(results, fmt.Errorf("error stopping runtime: %w", err))
 		}
 	}
+	b.config.GPUs.Release(b.state.gpuDeviceIDs)
+	b.state.gpuDeviceIDs = nil
 
 	err = b.cleanupFileSystem(ctx)
 	if err != nil {
@@ -255,7 +328,7 @@ func (b *Executor) PostExecuteJob(ctx *JobBuildContext) error {
 }
 
 // CleanUp removes any resources left over by previous instances of each of the available runtimes,
-// including Docker.
+// including Docker and the runtimes provided by job type plugins.
 func (b *Executor) CleanUp(timeout time.Duration) error {
 	ctx, cancelFunc := context.WithDeadline(context.Background(), time.Now().Add(timeout))
 	defer cancelFunc()
@@ -277,18 +350,26 @@ func (b *Executor) CleanUp(timeout time.Duration) error {
 		results = multierror.Append(results, err)
 	}
 
+	for _, plugin := range b.config.JobTypePlugins {
+		err = b.cleanUpPlugin(ctx, baseConfig, plugin)
+		if err != nil {
+			results = multierror.Append(results, err)
+		}
+	}
+
 	return results.ErrorOrNil()
 }
 
 // cleanUpDocker cleans up any resources left over by the docker runtime.
 func (b *Executor) cleanUpDocker(ctx context.Context, baseConfig *runtime.Config) error {
 	// Create a docker client to use during the cleanup
-	dClient, err := client.NewClientWithOpts(client.FromEnv)
+	dClient, err := docker.NewClient(b.config.ContainerEngine)
 	if err != nil {
-		return errors.Wrap(err, "error making Docker API client")
+		return err
 	}
 	config := docker.Config{
 		Config: *baseConfig,
+		Engine: b.config.ContainerEngine,
 	}
 	dockerRuntime := docker.NewRuntime(config, dClient, b.logFactory)
 
@@ -315,9 +396,30 @@ func (b *Executor) cleanUpExec(ctx context.Context, baseConfig *runtime.Config)
 	return nil
 }
 
+// cleanUpPlugin cleans up any resources left over by the runtime for a custom job type.
+func (b *Executor) cleanUpPlugin(ctx context.Context, baseConfig *runtime.Config, plugin plugins.JobTypePlugin) error {
+	pluginRuntime, err := plugin.NewRuntime(*baseConfig, nil)
+	if err != nil {
+		return fmt.Errorf("error making runtime for %s jobs: %w", plugin.JobType(), err)
+	}
+
+	err = pluginRuntime.CleanUp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// JobRootDir returns the directory on the host containing the workspace (for non-local builds) and staging
+// directories for a job.
+func JobRootDir(jobID models.ResourceID) string {
+	return filepath.Join(os.TempDir(), "buildbeaver", models.SanitizeFilePathID(jobID))
+}
+
 func (b *Executor) initFileSystem(ctx *JobBuildContext) error {
 	log := b.withJobLogFields(b.log, ctx.job)
-	jobRootDir := filepath.Join(os.TempDir(), "buildbeaver", models.SanitizeFilePathID(ctx.Job().GetID()))
+	jobRootDir := JobRootDir(ctx.Job().GetID())
 	// Local builds are expected to be operating out of a git checkout
 	// directory, so we set the workspace to the current working directory.
 	if b.config.IsLocal {
@@ -338,6 +440,7 @@ func (b *Executor) initFileSystem(ctx *JobBuildContext) error {
 	if err != nil {
 		return errors.Wrap(err, "error creating job staging directory")
 	}
+	b.state.stepEnv = newStepEnv(b.state.stagingDir)
 	b.addGlobalEnvVar("CI_WORKSPACE", b.state.workspaceDir, false)
 	log.WithFields(logger.Fields{"workspace": b.state.workspaceDir, "staging": b.state.stagingDir}).
 		Info("Created filesystem directories")
@@ -368,6 +471,10 @@ func (b *Executor) initGitCheckout(ctx *JobBuildContext) error {
 		return nil
 	}
 	log := b.withJobLogFields(b.log, ctx.job)
+	if ctx.Job().Job.SkipCheckout {
+		log.Info("Job does not require a checkout; skipping git clone")
+		return nil
+	}
 	repoSSHKey, err := b.secretStore.GetSecret(models.RepoSSHKeySecretName, true)
 	if err != nil {
 		return fmt.Errorf("error finding repo SSH key: %w", err)
@@ -395,6 +502,10 @@ func (b *Executor) prepareSSHAgent(ctx *JobBuildContext) error {
 	if b.config.IsLocal {
 		return nil
 	}
+	// Jobs without a checkout have no use for the repo's SSH key, so don't expose it to them
+	if ctx.Job().Job.SkipCheckout {
+		return nil
+	}
 
 	// TODO can we avoid writing the key to the filesystem?
 	log := b.withJobLogFields(b.log, ctx.job)
@@ -413,7 +524,11 @@ func (b *Executor) prepareSSHAgent(ctx *JobBuildContext) error {
 		sshAgentPIDPath    = filepath.Join(b.state.stagingDir, "ssh_agent_pid")
 	)
 
-	shell := runtime.ShellOrDefault(runtime.OSLinux, ctx.Job().Job.DockerConfig.Shell)
+	// The agent runs on the host rather than in the job's container, so always use the host's shell
+	shell, err := runtime.ShellPath(runtime.ShellSH)
+	if err != nil {
+		return err
+	}
 
 	cmd := hExec.Command(shell, "-c", `
 		eval $(ssh-agent -s -a `+sshAgentSocketPath+`)
@@ -468,14 +583,22 @@ func (b *Executor) prepareRuntime(ctx *JobBuildContext) error {
 		LogPipeline:  ctx.LogPipeline(),
 	}
 
+	if job.Resources != nil && job.Resources.GPUs > 0 {
+		gpuDeviceIDs, err := b.config.GPUs.Allocate(job.Resources.GPUs)
+		if err != nil {
+			return err
+		}
+		b.state.gpuDeviceIDs = gpuDeviceIDs
+	}
+
 	switch job.Type {
 	case models.JobTypeDocker:
 		if job.DockerConfig == nil {
 			return fmt.Errorf("error no docker config provided for job of type '%s'", models.JobTypeDocker)
 		}
-		dClient, err := client.NewClientWithOpts(client.FromEnv)
+		dClient, err := docker.NewClient(b.config.ContainerEngine)
 		if err != nil {
-			return errors.Wrap(err, "error making Docker API client")
+			return err
 		}
 		jobDockerAuth, err := b.getDockerAuth(job.DockerConfig)
 		if err != nil {
@@ -483,10 +606,18 @@ func (b *Executor) prepareRuntime(ctx *JobBuildContext) error {
 		}
 		config := docker.Config{
 			Config:       baseConfig,
+			Engine:       b.config.ContainerEngine,
 			ImageURI:     job.DockerConfig.Image,
 			AuthOrNil:    jobDockerAuth,
 			PullStrategy: job.DockerConfig.Pull,
-			ShellOrNil:   job.DockerConfig.Shell,
+			ShellOrNil:   job.Shell,
+			GPUDeviceIDs: b.state.gpuDeviceIDs,
+		}
+		if job.Resources != nil {
+			config.Resources = *job.Resources
+		}
+		if job.DockerConfig.Shell != nil {
+			config.ShellOrNil = job.DockerConfig.Shell
 		}
 		for _, service := range job.Services {
 			serviceDockerAuth, err := b.getDockerAuth(service.DockerConfig)
@@ -499,17 +630,39 @@ func (b *Executor) prepareRuntime(ctx *JobBuildContext) error {
 				AuthOrNil:    serviceDockerAuth,
 				PullStrategy: service.DockerConfig.Pull,
 			}
+			if override := b.config.ServiceOverrides.Get(service.Name); override != nil {
+				if override.Image != "" {
+					sConfig.ImageURI = override.Image
+				}
+				sConfig.Ports = override.Ports
+			}
 			config.Services = append(config.Services, sConfig)
 		}
 		b.state.runtime = docker.NewRuntime(config, dClient, b.logFactory)
 	case models.JobTypeExec:
+		if b.config.ExecJobAllowlist != nil && !b.config.ExecJobAllowlist.Allows(ctx.Job().RepoOwnerName, ctx.Job().Repo.Name) {
+			return fmt.Errorf("error %s jobs from repo %s/%s are not allowed on this runner",
+				models.JobTypeExec, ctx.Job().RepoOwnerName, ctx.Job().Repo.Name)
+		}
 		config := exec.Config{
 			Config:     baseConfig,
-			ShellOrNil: nil, // TODO
+			ShellOrNil: job.Shell,
+		}
+		if len(b.state.gpuDeviceIDs) > 0 {
+			// Steps run directly on the host, so restrict CUDA to the GPUs the job was given
+			b.addGlobalEnvVar("CUDA_VISIBLE_DEVICES", strings.Join(b.state.gpuDeviceIDs, ","), false)
 		}
 		b.state.runtime = exec.NewRuntime(config)
 	default:
-		return fmt.Errorf("error unsupported job kind: %v", job.Type)
+		plugin := b.config.JobTypePlugins.Get(job.Type)
+		if plugin == nil {
+			return fmt.Errorf("error unsupported job kind: %v", job.Type)
+		}
+		pluginRuntime, err := plugin.NewRuntime(baseConfig, job)
+		if err != nil {
+			return fmt.Errorf("error making runtime for %s job: %w", job.Type, err)
+		}
+		b.state.runtime = pluginRuntime
 	}
 	return b.state.runtime.Start(ctx.Ctx())
 }
@@ -520,6 +673,9 @@ func (b *Executor) prepareServices(ctx *JobBuildContext) error {
 		if err != nil {
 			return fmt.Errorf("error making env for service %q: %w", service.Name, err)
 		}
+		if override := b.config.ServiceOverrides.Get(service.Name); override != nil {
+			env = append(env, override.EnvMappings()...) // later mappings replace earlier ones
+		}
 		sConfig := runtime.ServiceConfig{
 			Name: service.Name,
 			Env:  env,
@@ -682,6 +838,72 @@ func (b *Executor) fingerprintJob(ctx *JobBuildContext) error {
 	return nil
 }
 
+// restoreCaches calculates the key for each cache the job declares and restores the caches into the workspace,
+// setting any environment variables the caches' presets need for tools to use the cached paths.
+// Caches are an optimization only, so errors are written to the job log rather than failing the job.
+func (b *Executor) restoreCaches(ctx *JobBuildContext) {
+	job := ctx.Job().Job
+	if b.config.IsLocal || len(job.CacheDefinitions) == 0 {
+		return
+	}
+	for _, definition := range job.CacheDefinitions {
+		env := definition.Preset.Environment(b.state.workspaceDir)
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b.addGlobalEnvVar(name, env[name], false)
+		}
+	}
+	b.state.cacheKeys = make(map[models.ResourceName]string)
+	for _, definition := range job.CacheDefinitions {
+		key, err := b.calculateCacheKey(ctx, definition)
+		if err != nil {
+			ctx.LogPipeline().StructuredLogger().WriteLinef("Cache %s: warning: error calculating cache key; cache will be skipped: %s", definition.Name, err)
+			continue
+		}
+		b.state.cacheKeys[definition.Name] = key
+	}
+	b.state.exactCacheHits = NewCacheManager(b.config.IsLocal, b.state.workspaceDir, b.state.stagingDir, b.apiClient).
+		RestoreCaches(ctx, b.state.cacheKeys)
+}
+
+// calculateCacheKey calculates the key for a cache by hashing the cache's name and paths together with the
+// output of its key commands. If the cache has no key commands the job's fingerprint is used instead.
+func (b *Executor) calculateCacheKey(ctx *JobBuildContext, definition *documents.CacheDefinition) (string, error) {
+	job := ctx.Job().Job
+	hash := sha256.New()
+	hash.Write([]byte(definition.Name.String()))
+	for _, path := range definition.Paths {
+		hash.Write([]byte(path))
+	}
+	if len(definition.KeyCommands) == 0 {
+		if job.Fingerprint == "" {
+			return "", errors.New("error job has no fingerprint to use as the cache key")
+		}
+		hash.Write([]byte(job.Fingerprint))
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	env, err := b.makeEnvMappings(job.Environment)
+	if err != nil {
+		return "", fmt.Errorf("error making env vars for cache key commands: %w", err)
+	}
+	config := runtime.ExecConfig{
+		Name:     fmt.Sprintf("cache-key-%s", definition.Name),
+		Commands: models.CommandsToStrings(definition.KeyCommands),
+		Env:      env,
+		Stdout:   hash,
+		Stderr:   ctx.LogPipeline().Converter(),
+	}
+	err = b.state.runtime.Exec(ctx.Ctx(), config)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func (b *Executor) initJobLogPipeline(ctx *JobBuildContext) error {
 	jobLogPipeline, err := b.logPipelineFactory(ctx.Ctx(), clock.New(), b.secretStore.GetAllSecrets(), ctx.Job().Job.LogDescriptorID)
 	if err != nil {
@@ -708,7 +930,11 @@ func (b *Executor) prepareStandardGlobalEnv(ctx *JobBuildContext) error { // Cal
 	var dynamicAPIEndpoint = b.config.DynamicAPIEndpoint
 	if ctx.job.Job.Type == models.JobTypeDocker {
 		var err error
-		dynamicAPIEndpoint, err = dynamic_api.GetDockerDynamicEndpoint(b.config.DynamicAPIEndpoint)
+		if b.config.ContainerEngine.IsPodman() {
+			dynamicAPIEndpoint, err = dynamic_api.GetPodmanDynamicEndpoint(b.config.DynamicAPIEndpoint)
+		} else {
+			dynamicAPIEndpoint, err = dynamic_api.GetDockerDynamicEndpoint(b.config.DynamicAPIEndpoint)
+		}
 		if err != nil {
 			b.log.Warnf("unable to find suitable address to allow docker containers to connect to endpoint %s: %s",
 				b.config.DynamicAPIEndpoint, err.Error())
@@ -741,6 +967,12 @@ func AddStandardGlobalEnvVars(
 	setter("BB_BUILD_OWNER_NAME", "", false)
 	setter("BB_BUILD_REF", runnable.Job.Ref, false)
 	setter("BB_WORKFLOWS_TO_RUN", makeWorkflowList(runnable.WorkflowsToRun), false)
+	setter("BB_WORKFLOW_OUTPUT_STUBS", makeWorkflowOutputStubs(runnable.WorkflowOutputStubs), false)
+	// Build parameters, both individually and as JSON for dynamic builds
+	setter(models.BuildParametersEnvVarName, makeBuildParameters(runnable.BuildParameters), false)
+	for _, name := range runnable.BuildParameters.Names() {
+		setter(runnable.BuildParameters.EnvVarName(name), runnable.BuildParameters[name], false)
+	}
 	// Commit info
 	setter("BB_COMMIT_SHA", runnable.Commit.SHA, false)
 	setter("BB_COMMIT_AUTHOR_NAME", runnable.Commit.AuthorName, false)
@@ -770,6 +1002,33 @@ func makeWorkflowList(workflows []models.ResourceName) string {
 	return list
 }
 
+// makeWorkflowOutputStubs converts a set of stubbed workflow outputs to JSON, or to an empty string if there
+// are no stubbed outputs.
+func makeWorkflowOutputStubs(stubs models.WorkflowOutputStubs) string {
+	if len(stubs) == 0 {
+		return ""
+	}
+	buf, err := json.Marshal(stubs)
+	if err != nil {
+		// Stubs are validated when the build is queued so this should never happen
+		return ""
+	}
+	return string(buf)
+}
+
+// makeBuildParameters converts a set of build parameters to JSON, or to an empty string if there are no parameters.
+func makeBuildParameters(parameters models.BuildParameters) string {
+	if len(parameters) == 0 {
+		return ""
+	}
+	buf, err := json.Marshal(parameters)
+	if err != nil {
+		// A map of strings can always be marshalled so this should never happen
+		return ""
+	}
+	return string(buf)
+}
+
 func (b *Executor) addGlobalEnvVar(name string, value string, isSecret bool) {
 	b.state.globalEnvVarsByName[name] = value
 	b.state.globalEnvVars = append(b.state.globalEnvVars, fmt.Sprintf("%s=%s", name, value))
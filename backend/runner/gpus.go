@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GPUAllocator hands out the runner's GPUs to the jobs it runs, so that no two jobs are given the same GPU.
+// GPUs are identified by their index on the host, as understood by the NVIDIA container runtime and by
+// CUDA_VISIBLE_DEVICES.
+type GPUAllocator struct {
+	mu   sync.Mutex
+	free map[string]bool
+}
+
+// NewGPUAllocator returns an allocator for a host with the specified number of GPUs.
+func NewGPUAllocator(count int64) *GPUAllocator {
+	free := make(map[string]bool, count)
+	for i := int64(0); i < count; i++ {
+		free[strconv.FormatInt(i, 10)] = true
+	}
+	return &GPUAllocator{free: free}
+}
+
+// Allocate reserves the specified number of GPUs and returns their device IDs. The GPUs must be returned
+// with Release when the job using them has finished.
+func (a *GPUAllocator) Allocate(count int64) ([]string, error) {
+	if a == nil {
+		return nil, fmt.Errorf("error %d GPU(s) required but this runner has no GPUs", count)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if int64(len(a.free)) < count {
+		return nil, fmt.Errorf("error %d GPU(s) required but only %d free", count, len(a.free))
+	}
+	var ids []string
+	for id := range a.free {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		left, _ := strconv.Atoi(ids[i])
+		right, _ := strconv.Atoi(ids[j])
+		return left < right
+	})
+	ids = ids[:count]
+	for _, id := range ids {
+		delete(a.free, id)
+	}
+	return ids, nil
+}
+
+// Release returns GPUs previously reserved with Allocate.
+func (a *GPUAllocator) Release(ids []string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range ids {
+		a.free[id] = true
+	}
+}
+
+// detectGPUs returns the number of NVIDIA GPUs on the runner's host, or zero if the NVIDIA driver
+// (and its nvidia-smi tool) isn't installed.
+func detectGPUs() (int64, error) {
+	path, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		return 0, nil
+	}
+	out, err := exec.Command(path, "-L").Output()
+	if err != nil {
+		return 0, fmt.Errorf("error listing GPUs with nvidia-smi: %w", err)
+	}
+	var count int64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "GPU ") {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGPUAllocator(t *testing.T) {
+	gpus := NewGPUAllocator(3)
+
+	first, err := gpus.Allocate(2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "1"}, first)
+
+	_, err = gpus.Allocate(2)
+	require.Error(t, err, "only one GPU is free")
+
+	second, err := gpus.Allocate(1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2"}, second)
+
+	gpus.Release(first)
+	third, err := gpus.Allocate(2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "1"}, third)
+
+	// A runner without GPUs has no allocator
+	var none *GPUAllocator
+	_, err = none.Allocate(1)
+	require.Error(t, err)
+	none.Release(nil)
+}
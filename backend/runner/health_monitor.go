@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/version"
+	"github.com/buildbeaver/buildbeaver/runner/runtime/docker"
+)
+
+const (
+	DefaultHealthCheckInterval = time.Minute
+	// healthCheckTimeout is the maximum time to spend checking health and sending the report to the server.
+	healthCheckTimeout = time.Second * 30
+)
+
+type HealthMonitorConfig struct {
+	// Interval is how often to check the health of the runner's host and report it to the server.
+	// Zero disables health reporting.
+	Interval time.Duration
+	// DiskPath is the path to measure free disk space for; this should be where jobs are checked out and run.
+	DiskPath string
+	// CheckDocker is true if the docker daemon must be reachable for the runner to be healthy.
+	CheckDocker bool
+	// ContainerEngine is the engine (e.g. Docker or Podman) that is checked when CheckDocker is true.
+	ContainerEngine docker.EngineConfig
+}
+
+// HealthMonitor periodically checks the health of the runner's host (free disk space and whether the docker
+// daemon is reachable) and sends a report to the server as a heartbeat, along with the jobs currently being
+// run and the host's CPU and memory usage. The server compares the time in the report against its own clock
+// to detect clock skew, stops giving jobs to the runner while it is unhealthy, and marks the runner offline
+// if heartbeats stop arriving.
+type HealthMonitor struct {
+	client    APIClient
+	scheduler *Scheduler
+	config    HealthMonitorConfig
+	log       logger.Log
+	mu        sync.Mutex // protects state
+	state     struct {
+		exitChan chan bool
+		wg       sync.WaitGroup
+	}
+}
+
+func NewHealthMonitor(client APIClient, scheduler *Scheduler, config HealthMonitorConfig, logFactory logger.LogFactory) *HealthMonitor {
+	return &HealthMonitor{
+		client:    client,
+		scheduler: scheduler,
+		config:    config,
+		log:       logFactory("HealthMonitor"),
+	}
+}
+
+// Start checking and reporting health in the background. The first report is sent before Start returns,
+// so the server knows whether the runner is healthy before it tries to dequeue any jobs.
+func (m *HealthMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state.exitChan != nil {
+		return
+	}
+	if m.config.Interval <= 0 {
+		m.log.Info("Health reporting is disabled")
+		return
+	}
+	m.report()
+	m.state.exitChan = make(chan bool)
+	m.state.wg.Add(1)
+	go func(exitChan chan bool) {
+		defer m.state.wg.Done()
+		m.loop(exitChan)
+	}(m.state.exitChan)
+}
+
+// Stop checking and reporting health.
+func (m *HealthMonitor) Stop() {
+	m.mu.Lock()
+	if m.state.exitChan == nil {
+		m.mu.Unlock()
+		return
+	}
+	close(m.state.exitChan)
+	m.state.exitChan = nil
+	m.mu.Unlock()
+	m.state.wg.Wait()
+}
+
+func (m *HealthMonitor) loop(exitChan chan bool) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exitChan:
+			return
+		case <-ticker.C:
+			m.report()
+		}
+	}
+}
+
+// report checks health and sends the report to the server. Errors are logged rather than returned since
+// the next report will be sent at the next interval regardless.
+func (m *HealthMonitor) report() {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	report := m.Check(ctx)
+	err := m.client.SendHealthReport(ctx, report)
+	if err != nil {
+		m.log.Warnf("Error sending health report to server: %s", err)
+		return
+	}
+	m.log.Tracef("Sent health report to server: %d MiB disk space free at %q", report.DiskFreeBytes/(1024*1024), report.DiskPath)
+}
+
+// Check the health of the runner's host.
+func (m *HealthMonitor) Check(ctx context.Context) *models.RunnerHealthReport {
+	report := &models.RunnerHealthReport{
+		DiskPath:        m.config.DiskPath,
+		SoftwareVersion: version.VERSION,
+		CurrentJobIDs:   m.scheduler.RunningJobIDs(),
+	}
+	free, total, err := getDiskSpace(m.config.DiskPath)
+	if err != nil {
+		m.log.Warnf("Error measuring disk space at %q: %s", m.config.DiskPath, err)
+		report.DiskError = err.Error()
+	} else {
+		report.DiskFreeBytes, report.DiskTotalBytes = free, total
+	}
+	if m.config.CheckDocker {
+		err := m.pingDocker(ctx)
+		reachable := err == nil
+		report.DockerReachable = &reachable
+		if err != nil {
+			m.log.Warnf("Docker daemon is not reachable: %s", err)
+			report.DockerError = err.Error()
+		}
+	}
+	resources, err := getResourceStats()
+	if err != nil {
+		m.log.Warnf("Error measuring resource usage: %s", err)
+	} else {
+		report.Resources = resources
+	}
+	// Record the time last, so that it is as close as possible to when the server receives the report
+	report.Time = models.NewTime(time.Now())
+	return report
+}
+
+// pingDocker checks that the docker daemon (or other container engine) is reachable.
+func (m *HealthMonitor) pingDocker(ctx context.Context) error {
+	dClient, err := docker.NewClient(m.config.ContainerEngine)
+	if err != nil {
+		return err
+	}
+	defer dClient.Close()
+	_, err = dClient.Ping(ctx)
+	return err
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// jobWatcherRetryDelay is how long to wait before watching jobs again after an error.
+const jobWatcherRetryDelay = 5 * time.Second
+
+// JobEventSource notifies the runner when jobs it is running are finished by the server. Only some API
+// transports support this; with other transports the runner finds out when it next updates the job.
+type JobEventSource interface {
+	// WatchJobs calls handler each time the server finishes a job the runner is running (e.g. because the
+	// job was canceled), until ctx is done or the call fails.
+	WatchJobs(ctx context.Context, handler func(jobID models.JobID, status models.WorkflowStatus)) error
+}
+
+// JobWatcher watches for jobs being finished by the server while the runner is running them, and stops
+// running those jobs so that canceled builds don't hold on to the runner.
+type JobWatcher struct {
+	source    JobEventSource
+	scheduler *Scheduler
+	log       logger.Log
+	mu        sync.Mutex // protects state
+	state     struct {
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	}
+}
+
+// NewJobWatcher creates a JobWatcher that receives events from source. source can be nil if the API
+// transport does not support watching jobs, in which case the JobWatcher does nothing.
+func NewJobWatcher(source JobEventSource, scheduler *Scheduler, logFactory logger.LogFactory) *JobWatcher {
+	return &JobWatcher{
+		source:    source,
+		scheduler: scheduler,
+		log:       logFactory("JobWatcher"),
+	}
+}
+
+// Start watching jobs in the background.
+func (w *JobWatcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state.cancel != nil {
+		return
+	}
+	if w.source == nil {
+		w.log.Info("API transport does not support watching jobs; canceled jobs will be stopped on their next update")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.state.cancel = cancel
+	w.state.wg.Add(1)
+	go func() {
+		defer w.state.wg.Done()
+		w.loop(ctx)
+	}()
+}
+
+// Stop watching jobs.
+func (w *JobWatcher) Stop() {
+	w.mu.Lock()
+	if w.state.cancel == nil {
+		w.mu.Unlock()
+		return
+	}
+	w.state.cancel()
+	w.state.cancel = nil
+	w.mu.Unlock()
+	w.state.wg.Wait()
+}
+
+func (w *JobWatcher) loop(ctx context.Context) {
+	for {
+		err := w.source.WatchJobs(ctx, w.handleEvent)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.log.Warnf("Will retry error watching jobs: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jobWatcherRetryDelay):
+		}
+	}
+}
+
+func (w *JobWatcher) handleEvent(jobID models.JobID, status models.WorkflowStatus) {
+	if !status.HasFinished() {
+		return
+	}
+	if w.scheduler.CancelJob(jobID) {
+		w.log.Infof("Job %s was finished by the server (status %s); Stopping job", jobID, status)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"sync/atomic"
 
 	"github.com/benbjohnson/clock"
 
@@ -14,7 +16,20 @@ import (
 	"github.com/buildbeaver/buildbeaver/common/util"
 )
 
-// LogConverter converts a plaintext log stream to a structured log stream.
+const (
+	// groupStartMarker starts a collapsible group of log lines when printed at the start of a line, with the
+	// rest of the line giving the group's title. Groups can be nested.
+	groupStartMarker = "::group::"
+	// groupEndMarker ends the most recently started group that is still open when printed on its own line.
+	groupEndMarker = "::endgroup::"
+)
+
+// groupCounter is used to give each group a unique block name.
+var groupCounter int64
+
+// LogConverter converts a plaintext log stream to a structured log stream. Lines are converted to line log
+// entries, except for group markers (see groupStartMarker and groupEndMarker) which are converted to blocks
+// that contain the lines written until the group is ended.
 type LogConverter struct {
 	*util.StatefulService
 	clk    clock.Clock
@@ -41,12 +56,34 @@ func (l *LogConverter) Close() error {
 }
 
 func (l *LogConverter) loop() {
+	var groups []models.ResourceName // the names of the currently open groups, innermost last
+	parentBlock := func() *models.ResourceName {
+		if len(groups) == 0 {
+			return nil
+		}
+		name := groups[len(groups)-1]
+		return &name
+	}
 	scanner := bufio.NewScanner(l.reader)
 	for l.Ctx().Err() == nil && scanner.Scan() {
-		// TODO We could look for special syntax in the plaintext stream and convert to other structures (like blocks) here
 		// TODO: Consider a special syntax for marking error messages as well, to be translated to 'error' log entries
-		l.log.Tracef("Writing line: %w", scanner.Text())
-		l.next.Write(models.NewLogEntryLine(-1, models.NewTime(l.clk.Now()), scanner.Text(), -1, nil))
+		text := scanner.Text()
+		marker := strings.TrimRight(text, "\r")
+		if strings.HasPrefix(marker, groupStartMarker) {
+			name := models.ResourceName(fmt.Sprintf("group-%d", atomic.AddInt64(&groupCounter, 1)))
+			title := strings.TrimSpace(strings.TrimPrefix(marker, groupStartMarker))
+			l.next.Write(models.NewLogEntryBlock(-1, models.NewTime(l.clk.Now()), title, name, parentBlock()))
+			groups = append(groups, name)
+			continue
+		}
+		if strings.TrimSpace(marker) == groupEndMarker {
+			if len(groups) > 0 {
+				groups = groups[:len(groups)-1]
+			}
+			continue
+		}
+		l.log.Tracef("Writing line: %s", text)
+		l.next.Write(models.NewLogEntryLine(-1, models.NewTime(l.clk.Now()), text, -1, parentBlock()))
 	}
 	err := scanner.Err()
 	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) {
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// captureLogWriter records the entries written to it.
+type captureLogWriter struct {
+	mu      sync.Mutex
+	entries []*models.LogEntry
+}
+
+func (w *captureLogWriter) Write(entry *models.LogEntry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries = append(w.entries, entry)
+}
+
+func (w *captureLogWriter) Flush() {}
+
+func (w *captureLogWriter) Close() {}
+
+func (w *captureLogWriter) Entries() []*models.LogEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*models.LogEntry{}, w.entries...)
+}
+
+func TestLogConverterGroups(t *testing.T) {
+	writer := &captureLogWriter{}
+	converter := NewLogConverter(clock.NewMock(), logger.NoOpLogFactory, writer)
+	converter.Start()
+	defer converter.Close()
+
+	_, err := converter.Write([]byte("before\n::group::Build\ncompiling\n::group:: Tests \r\ntesting\n::endgroup::\nlinking\n::endgroup::\n::endgroup::\nafter\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(writer.Entries()) == 7 }, time.Second, time.Millisecond)
+
+	type expected struct {
+		kind   models.LogEntryKind
+		text   string
+		parent int // index of the parent block's entry, or -1 for no parent
+	}
+	expectations := []expected{
+		{models.LogEntryKindLine, "before", -1},
+		{models.LogEntryKindBlock, "Build", -1},
+		{models.LogEntryKindLine, "compiling", 1},
+		{models.LogEntryKindBlock, "Tests", 1},
+		{models.LogEntryKindLine, "testing", 3},
+		{models.LogEntryKindLine, "linking", 1},
+		// The extra end marker is ignored
+		{models.LogEntryKindLine, "after", -1},
+	}
+	entries := writer.Entries()
+	for i, expectation := range expectations {
+		entry := entries[i].Derived().(models.PlainTextLogEntry)
+		require.Equal(t, expectation.kind, entries[i].Kind, "entry %d", i)
+		require.Equal(t, expectation.text, entry.GetText(), "entry %d", i)
+		if expectation.parent < 0 {
+			require.Nil(t, entry.GetParentBlockName(), "entry %d", i)
+		} else {
+			parent := entries[expectation.parent].Derived().(*models.LogEntryBlock)
+			require.Equal(t, parent.Name, *entry.GetParentBlockName(), "entry %d", i)
+		}
+	}
+}
@@ -2,27 +2,36 @@ package logging
 
 import (
 	"bytes"
+	"encoding/base64"
+	"strings"
 	"sync"
 
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 )
 
-const filler = "*"
+const (
+	// mask replaces each run of secret text in the log.
+	mask = "***"
+	// minDerivedSecretLen is the shortest variant of a secret (a line of a multi-line secret, or an encoding
+	// of a secret) that will be scrubbed; shorter variants are too likely to match ordinary log text.
+	minDerivedSecretLen = 4
+)
 
-// LogScrubber scrubs plaintext user-supplied secrets from the log stream.
-// NOTE: This introduces buffering into the stream - the buffer will be sized to match the largest secret.
+// LogScrubber scrubs plaintext user-supplied secrets from the log stream, replacing them with '***'.
+// Each line of a multi-line secret, and the base64 encodings of each secret, are also scrubbed.
+// NOTE: This introduces buffering into the stream - the buffer will be sized to match the longest secret.
 type LogScrubber struct {
-	mu               sync.Mutex
-	log              logger.Log
-	closePipeline    closeRequester
-	entries          []*models.LogEntry
-	buf              []byte
-	secrets          []*models.SecretPlaintext
-	fillerMap        map[models.SecretID][]byte
-	longestSecretLen int
-	logClosed        bool
-	next             LogWriter
+	mu            sync.Mutex
+	log           logger.Log
+	closePipeline closeRequester
+	entries       []*models.LogEntry
+	buf           []byte
+	masked        []bool // masked[i] is true if buf[i] is part of a secret
+	patterns      [][]byte
+	longestLen    int
+	logClosed     bool
+	next          LogWriter
 }
 
 func NewLogScrubber(
@@ -32,25 +41,31 @@ func NewLogScrubber(
 	secrets []*models.SecretPlaintext,
 ) *LogScrubber {
 	var (
-		longestSecretLen int
-		fillerMap        = make(map[models.SecretID][]byte)
+		longestLen int
+		patterns   [][]byte
+		seen       = make(map[string]bool)
 	)
 	for _, secret := range secrets {
 		if secret.IsInternal {
 			continue
 		}
-		if len(secret.Value) > longestSecretLen {
-			longestSecretLen = len(secret.Value)
+		for _, pattern := range secretPatterns(secret.Value) {
+			if pattern == "" || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			patterns = append(patterns, []byte(pattern))
+			if len(pattern) > longestLen {
+				longestLen = len(pattern)
+			}
 		}
-		fillerMap[secret.ID] = makeFiller(filler, len(secret.Value))
 	}
 	return &LogScrubber{
-		log:              logFactory("LogScrubber"),
-		closePipeline:    closePipeline,
-		secrets:          secrets,
-		fillerMap:        fillerMap,
-		longestSecretLen: longestSecretLen,
-		next:             next,
+		log:           logFactory("LogScrubber"),
+		closePipeline: closePipeline,
+		patterns:      patterns,
+		longestLen:    longestLen,
+		next:          next,
 	}
 }
 
@@ -65,19 +80,28 @@ func (l *LogScrubber) Write(entry *models.LogEntry) {
 	}
 
 	plaintext, ok := entry.Derived().(models.PlainTextLogEntry)
-	if !ok || len(l.secrets) == 0 {
+	if !ok || len(l.patterns) == 0 {
 		l.next.Write(entry)
 		return
 	}
+	oldLen := len(l.buf)
 	l.entries = append(l.entries, entry)
 	l.buf = append(l.buf, plaintext.GetText()...)
-	for _, secret := range l.secrets {
-		if secret.IsInternal {
-			continue
+	l.masked = append(l.masked, make([]bool, len(l.buf)-oldLen)...)
+	for _, pattern := range l.patterns {
+		// Only look for secrets that overlap the new text; earlier text has already been searched
+		for offset := max(oldLen-len(pattern)+1, 0); offset < len(l.buf); {
+			i := bytes.Index(l.buf[offset:], pattern)
+			if i < 0 {
+				break
+			}
+			for j := offset + i; j < offset+i+len(pattern); j++ {
+				l.masked[j] = true
+			}
+			offset += i + 1
 		}
-		l.buf = bytes.ReplaceAll(l.buf, []byte(secret.Value), l.fillerMap[secret.ID])
 	}
-	l.flush(max(len(l.buf)-l.longestSecretLen, 0))
+	l.flush(max(len(l.buf)-l.longestLen, 0))
 }
 
 func (l *LogScrubber) Flush() {
@@ -119,13 +143,14 @@ func (l *LogScrubber) flush(n int) {
 		if entryLen > n {
 			break
 		}
-		plaintext.SetText(string(l.buf[bufOffset : bufOffset+entryLen]))
+		plaintext.SetText(maskText(l.buf[bufOffset:bufOffset+entryLen], l.masked[bufOffset:bufOffset+entryLen]))
 		l.next.Write(entry)
 		n = n - entryLen
 		bufOffset += entryLen
 		entryOffset++
 	}
 	l.buf = l.buf[bufOffset:]
+	l.masked = l.masked[bufOffset:]
 	l.entries = l.entries[entryOffset:]
 }
 
@@ -136,10 +161,40 @@ func max(a, b int) int {
 	return b
 }
 
-func makeFiller(filer string, n int) []byte {
-	buf := make([]byte, n)
-	for i := 0; i < len(buf); i++ {
-		buf[i] = filer[i%len(filer)]
+// secretPatterns returns the variants of a secret value to scrub from logs: the value itself, each line
+// of a multi-line value (since logs are scrubbed line by line), and the value's base64 encodings.
+func secretPatterns(value string) []string {
+	patterns := []string{value}
+	if strings.ContainsAny(value, "\r\n") {
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) >= minDerivedSecretLen {
+				patterns = append(patterns, line)
+			}
+		}
+	}
+	if len(value) < minDerivedSecretLen {
+		return patterns
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		// The last few characters of an encoded value depend on what follows it, so also scrub the encoding
+		// of the whole 3-byte groups the value starts with to catch the value embedded in longer encoded data
+		patterns = append(patterns,
+			strings.TrimRight(encoding.EncodeToString([]byte(value)), "="),
+			encoding.EncodeToString([]byte(value[:len(value)/3*3])))
+	}
+	return patterns
+}
+
+// maskText returns text with each run of masked bytes replaced by the mask.
+func maskText(text []byte, masked []bool) string {
+	var sb strings.Builder
+	for i, b := range text {
+		if !masked[i] {
+			sb.WriteByte(b)
+		} else if i == 0 || !masked[i-1] {
+			sb.WriteString(mask)
+		}
 	}
-	return buf
+	return sb.String()
 }
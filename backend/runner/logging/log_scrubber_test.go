@@ -10,24 +10,11 @@ import (
 	"github.com/buildbeaver/buildbeaver/common/models"
 )
 
-func TestMakeFiller(t *testing.T) {
-	filler := makeFiller("secret", 0)
-	assert.Equal(t, []byte(""), filler)
-
-	filler = makeFiller("secret", 1)
-	assert.Equal(t, []byte("s"), filler)
-
-	filler = makeFiller("secret", 6)
-	assert.Equal(t, []byte("secret"), filler)
-
-	filler = makeFiller("secret", 9)
-	assert.Equal(t, []byte("secretsec"), filler)
-
-	filler = makeFiller("secret", 11)
-	assert.Equal(t, []byte("secretsecre"), filler)
-
-	filler = makeFiller("secret", 12)
-	assert.Equal(t, []byte("secretsecret"), filler)
+func TestMaskText(t *testing.T) {
+	assert.Equal(t, "", maskText([]byte(""), nil))
+	assert.Equal(t, "abc", maskText([]byte("abc"), []bool{false, false, false}))
+	assert.Equal(t, "***", maskText([]byte("abc"), []bool{true, true, true}))
+	assert.Equal(t, "a***d***", maskText([]byte("abcdef"), []bool{false, true, true, false, true, true}))
 }
 
 func TestLogScrubber_Write(t *testing.T) {
@@ -39,7 +26,22 @@ func TestLogScrubber_Write(t *testing.T) {
 	}{{
 		secretValues:    []string{"world"},
 		inputs:          []string{"Hello world", "Hello World", "wor", "ld", "helloworld", "hello\nworld"},
-		expectedOutputs: []string{"Hello *****", "Hello World", "***", "**", "hello*****", "hello\n*****"},
+		expectedOutputs: []string{"Hello ***", "Hello World", "***", "***", "hello***", "hello\n***"},
+	}, {
+		// Each line of a multi-line secret is scrubbed, since logs are scrubbed line by line
+		secretValues:    []string{"-----BEGIN KEY-----\r\nMIIEabc\r\n-----END KEY-----\r\n"},
+		inputs:          []string{"-----BEGIN KEY-----", "MIIEabc", "-----END KEY-----", "done"},
+		expectedOutputs: []string{"***", "***", "***", "done"},
+	}, {
+		// Base64 encodings of secrets are scrubbed, including when embedded in longer encoded data
+		secretValues:    []string{"s3cr3t-token"},
+		inputs:          []string{"token czNjcjN0LXRva2Vu", "auth dXNlcjp4czNjcjN0LXRva2VuIQ==", "s3cr3t-token"},
+		expectedOutputs: []string{"token ***", "auth dXNlcjp4***IQ==", "***"},
+	}, {
+		// Overlapping secrets are scrubbed together, and short secrets aren't encoded
+		secretValues:    []string{"abcdef", "defghi", "ab"},
+		inputs:          []string{"xxabcdefghixx", "YWI="},
+		expectedOutputs: []string{"xx***xx", "YWI="},
 	}}
 
 	logRegistry, err := logger.NewLogRegistry("")
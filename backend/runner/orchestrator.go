@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
 	"github.com/buildbeaver/buildbeaver/server/dto"
 )
@@ -35,6 +38,9 @@ type Orchestrator struct {
 	attemptedStepsByName   map[models.ResourceName]*documents.Step
 	attemptedStepsByNameMu sync.RWMutex // protects attemptedStepsByName
 	executor               *Executor
+	cancelMu               sync.Mutex // protects cancelJob and canceled
+	cancelJob              context.CancelFunc
+	canceled               bool
 	logger.Log
 }
 
@@ -53,8 +59,17 @@ func NewOrchestrator(client APIClient, executorFactory ExecutorFactory, logFacto
 // server as the steps are executed (on both success and failure).
 func (s *Orchestrator) Run(runnable *documents.RunnableJob) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), buildTimeout)
+	timeout := buildTimeout
+	if runnable.Job.TimeoutSeconds > 0 {
+		timeout = time.Duration(runnable.Job.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	s.setCancelJob(cancel)
+	// All requests made to the server using the job context will be recorded as part of this job's trace
+	ctx, span := tracing.StartSpan(ctx, "Orchestrator.Run")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("job_id", runnable.Job.ID), attribute.Stringer("build_id", runnable.Job.BuildID))
 
 	jobCtx := NewJobBuildContext(ctx, runnable)
 	s.executor = s.executorFactory(ctx)
@@ -91,6 +106,10 @@ func (s *Orchestrator) Run(runnable *documents.RunnableJob) {
 	// send an appropriate status back to the server. We intentionally do not bubble
 	// errors up to the walk (by always returning nil) as this would cause it to abort.
 	err = s.walkSteps(runnable.Job, runnable.Steps, true, func(step *documents.Step) error {
+		if step.Status == models.WorkflowStatusSkipped {
+			// The server skipped this step when the job was enqueued; it has already finished
+			return nil
+		}
 		// TODO reserve token and defer release
 
 		// Use a new context for the step status update, so we can send an update even if the main context times out
@@ -168,6 +187,7 @@ func (s *Orchestrator) Run(runnable *documents.RunnableJob) {
 		if jobErr != nil {
 			s.executor.LogJobError(jobCtx, jobErr)
 		}
+		jobCtx.SetJobError(jobErr)
 		err := s.tearDownJob(jobCtx)
 		// If we encounter an error we can continue unless it's an artifact upload error where we need to fail the build
 		if err != nil {
@@ -207,6 +227,26 @@ func (s *Orchestrator) Run(runnable *documents.RunnableJob) {
 	runnable.Job = jobDoc
 }
 
+// Cancel stops the job being run as soon as possible, by canceling the job's context. The job's remaining
+// steps fail and the final status updates are still sent to the server. Cancel can be called before Run.
+func (s *Orchestrator) Cancel() {
+	s.cancelMu.Lock()
+	defer s.cancelMu.Unlock()
+	s.canceled = true
+	if s.cancelJob != nil {
+		s.cancelJob()
+	}
+}
+
+func (s *Orchestrator) setCancelJob(cancel context.CancelFunc) {
+	s.cancelMu.Lock()
+	defer s.cancelMu.Unlock()
+	s.cancelJob = cancel
+	if s.canceled {
+		cancel()
+	}
+}
+
 // stepDAGNode wraps a Step document, allowing it to be used as a node in a DAG by implementing
 // the dto.GraphNode interface.
 type stepDAGNode struct {
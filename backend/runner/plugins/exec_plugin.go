@@ -0,0 +1,163 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/runner/runtime"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// Environment variables passed to exec plugins.
+const (
+	ExecPluginJobTypeEnvVar      = "BB_PLUGIN_JOB_TYPE"
+	ExecPluginJobNameEnvVar      = "BB_PLUGIN_JOB_NAME"
+	ExecPluginRuntimeIDEnvVar    = "BB_PLUGIN_RUNTIME_ID"
+	ExecPluginStagingDirEnvVar   = "BB_PLUGIN_STAGING_DIR"
+	ExecPluginWorkspaceDirEnvVar = "BB_PLUGIN_WORKSPACE_DIR"
+	ExecPluginStepNameEnvVar     = "BB_PLUGIN_STEP_NAME"
+	ExecPluginScriptEnvVar       = "BB_PLUGIN_SCRIPT"
+)
+
+// Commands passed to exec plugins as their only argument.
+const (
+	execPluginStartCommand   = "start"
+	execPluginExecCommand    = "exec"
+	execPluginStopCommand    = "stop"
+	execPluginCleanUpCommand = "cleanup"
+)
+
+// ExecPluginConfig configures a job type that is implemented by an external executable.
+type ExecPluginConfig struct {
+	// JobType is the custom job type the executable implements.
+	JobType models.JobType
+	// Path is the path to the executable.
+	Path string
+}
+
+// ParseExecPluginConfig parses an exec plugin config in the form type=path.
+func ParseExecPluginConfig(str string) (ExecPluginConfig, error) {
+	parts := strings.SplitN(str, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ExecPluginConfig{}, fmt.Errorf("error expected job type plugin in the form type=path but found: %q", str)
+	}
+	return ExecPluginConfig{JobType: models.JobType(strings.ToLower(parts[0])), Path: parts[1]}, nil
+}
+
+// ExecPlugin implements a custom job type by running an external executable, which lets teams add job types
+// without rebuilding the runner. The executable is run with a single argument naming the runtime operation:
+//
+//   - "start" before the job's first step
+//   - "exec" once for each step; the step's commands are in the script named by BB_PLUGIN_SCRIPT, and the step's
+//     environment is passed to the executable. The executable's output becomes the step's log.
+//   - "stop" after the job's last step, even if the job failed
+//   - "cleanup" when the runner starts, to remove anything left over by jobs that did not stop cleanly
+//
+// The executable runs in the job's workspace directory, and is told about the job via the BB_PLUGIN_*
+// environment variables. A non-zero exit code fails the operation.
+type ExecPlugin struct {
+	config ExecPluginConfig
+}
+
+func NewExecPlugin(config ExecPluginConfig) *ExecPlugin {
+	return &ExecPlugin{config: config}
+}
+
+func (p *ExecPlugin) JobType() models.JobType {
+	return p.config.JobType
+}
+
+func (p *ExecPlugin) NewRuntime(config runtime.Config, job *documents.Job) (runtime.Runtime, error) {
+	env := []string{
+		ExecPluginJobTypeEnvVar + "=" + p.config.JobType.String(),
+		ExecPluginRuntimeIDEnvVar + "=" + config.RuntimeID,
+		ExecPluginStagingDirEnvVar + "=" + config.StagingDir,
+		ExecPluginWorkspaceDirEnvVar + "=" + config.WorkspaceDir,
+	}
+	if job != nil {
+		env = append(env, ExecPluginJobNameEnvVar+"="+job.Name.String())
+	}
+	return &execPluginRuntime{plugin: p.config, config: config, env: env}, nil
+}
+
+// execPluginRuntime is the runtime for a single job of an exec plugin's type.
+type execPluginRuntime struct {
+	plugin ExecPluginConfig
+	config runtime.Config
+	env    []string
+}
+
+// Start initializes the runtime and prepares it to have commands Exec'd inside it.
+func (r *execPluginRuntime) Start(ctx context.Context) error {
+	return r.run(ctx, execPluginStartCommand)
+}
+
+// StartService starts a service inside the runtime.
+func (r *execPluginRuntime) StartService(ctx context.Context, config runtime.ServiceConfig) error {
+	return fmt.Errorf("services are not supported with %s jobs", r.plugin.JobType)
+}
+
+// Exec executes a command inside the runtime.
+// Start must have been called before calling Exec.
+func (r *execPluginRuntime) Exec(ctx context.Context, config runtime.ExecConfig) error {
+	scriptPath, err := runtime.WriteScript(r.config.StagingDir, config.Name, config.Commands)
+	if err != nil {
+		return err
+	}
+	cmd := r.command(ctx, execPluginExecCommand)
+	cmd.Env = append(cmd.Env, config.Env...)
+	cmd.Env = append(cmd.Env,
+		ExecPluginStepNameEnvVar+"="+config.Name,
+		ExecPluginScriptEnvVar+"="+scriptPath)
+	cmd.Stdout = config.Stdout
+	cmd.Stderr = config.Stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("error running command: %w", ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("error running command: %w", err)
+	}
+	return nil
+}
+
+// Stop tears down the runtime.
+func (r *execPluginRuntime) Stop(ctx context.Context) error {
+	return r.run(ctx, execPluginStopCommand)
+}
+
+// CleanUp removes any resources left over from previous commands that may not have finished cleanly.
+func (r *execPluginRuntime) CleanUp(ctx context.Context) error {
+	return r.run(ctx, execPluginCleanUpCommand)
+}
+
+// run runs the plugin executable to perform a runtime operation, including the executable's output in
+// the returned error if it fails.
+func (r *execPluginRuntime) run(ctx context.Context, command string) error {
+	var output bytes.Buffer
+	cmd := r.command(ctx, command)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("error running %s plugin %q command: %w: %s",
+			r.plugin.JobType, command, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+func (r *execPluginRuntime) command(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, r.plugin.Path, command)
+	if _, err := os.Stat(r.config.WorkspaceDir); err == nil {
+		cmd.Dir = r.config.WorkspaceDir
+	}
+	// Keep the existing PATH env variable so that the plugin can find the tools it runs, but don't pass
+	// through any other env variables since secrets are supplied in env vars when using the command-line tool.
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH")}, r.env...)
+	return cmd
+}
@@ -0,0 +1,94 @@
+//go:build !windows
+// +build !windows
+
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/runner/runtime"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+func TestJobTypePlugins(t *testing.T) {
+	_, err := ParseExecPluginConfig("terraform")
+	require.Error(t, err)
+	config, err := ParseExecPluginConfig("Terraform=/usr/local/bin/bb-terraform")
+	require.NoError(t, err)
+	require.Equal(t, ExecPluginConfig{JobType: "terraform", Path: "/usr/local/bin/bb-terraform"}, config)
+
+	jobTypePlugins, err := NewJobTypePlugins([]ExecPluginConfig{
+		config,
+		{JobType: "ansible", Path: "/usr/local/bin/bb-ansible"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.JobTypes{"docker", "exec", "ansible", "terraform"}, jobTypePlugins.SupportedJobTypes())
+	require.NotNil(t, jobTypePlugins.Get("terraform"))
+	require.Nil(t, jobTypePlugins.Get("docker"))
+
+	// Plugins can't replace built-in job types or each other
+	_, err = NewJobTypePlugins([]ExecPluginConfig{{JobType: "docker", Path: "/usr/local/bin/bb-docker"}})
+	require.Error(t, err)
+	_, err = NewJobTypePlugins([]ExecPluginConfig{config, config})
+	require.Error(t, err)
+	_, err = NewJobTypePlugins([]ExecPluginConfig{{JobType: "not a type", Path: "/usr/local/bin/bb-other"}})
+	require.Error(t, err)
+}
+
+func TestExecPluginRuntime(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	stagingDir := filepath.Join(dir, "staging")
+	workspaceDir := filepath.Join(dir, "workspace")
+	require.NoError(t, os.Mkdir(stagingDir, 0755))
+	require.NoError(t, os.Mkdir(workspaceDir, 0755))
+
+	// The plugin records each operation, and runs step scripts with a prefix on each line of output
+	pluginPath := filepath.Join(dir, "bb-terraform")
+	script := `#!/bin/sh
+echo "$1 $BB_PLUGIN_JOB_TYPE $BB_PLUGIN_JOB_NAME $BB_PLUGIN_STEP_NAME" >> "$BB_PLUGIN_STAGING_DIR/operations"
+case "$1" in
+exec) sh "$BB_PLUGIN_SCRIPT" | sed "s/^/[$GREETING] /" ;;
+stop) exit 3 ;;
+esac
+`
+	require.NoError(t, os.WriteFile(pluginPath, []byte(script), 0755))
+
+	plugin := NewExecPlugin(ExecPluginConfig{JobType: "terraform", Path: pluginPath})
+	pluginRuntime, err := plugin.NewRuntime(runtime.Config{
+		RuntimeID:    "test",
+		StagingDir:   stagingDir,
+		WorkspaceDir: workspaceDir,
+	}, &documents.Job{Name: "plan"})
+	require.NoError(t, err)
+
+	err = pluginRuntime.Start(ctx)
+	require.NoError(t, err)
+	err = pluginRuntime.StartService(ctx, runtime.ServiceConfig{Name: "postgres"})
+	require.Error(t, err)
+	var stdout bytes.Buffer
+	err = pluginRuntime.Exec(ctx, runtime.ExecConfig{
+		Name:     "init",
+		Commands: []string{"echo hello", "pwd"},
+		Env:      []string{"GREETING=tf"},
+		Stdout:   &stdout,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "[tf] hello\n[tf] "+workspaceDir+"\n", stdout.String())
+
+	// Failures include the plugin's exit status
+	err = pluginRuntime.Stop(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exit status 3")
+
+	operations, err := os.ReadFile(filepath.Join(stagingDir, "operations"))
+	require.NoError(t, err)
+	require.Equal(t, "start terraform plan \nexec terraform plan init\nstop terraform plan \n", string(operations))
+}
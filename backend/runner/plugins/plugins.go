@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/runner/runtime"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// JobTypePlugin implements a custom job type (e.g. "terraform" or "ansible") by providing the runtime
+// the job's steps are executed in. Runners advertise the job types of their plugins to the server, so
+// jobs of a custom type are only given to runners with a plugin for that type.
+type JobTypePlugin interface {
+	// JobType returns the job type the plugin implements.
+	JobType() models.JobType
+	// NewRuntime returns a runtime to execute the steps of a job of the plugin's type.
+	// job is nil if the runtime will only be used to clean up resources left over from previous jobs.
+	NewRuntime(config runtime.Config, job *documents.Job) (runtime.Runtime, error)
+}
+
+var (
+	registeredMu sync.Mutex
+	registered   = make(JobTypePlugins)
+)
+
+// Register makes a plugin available to runners built into the same binary. It is intended to be called
+// from the init function of the package that implements the plugin, in the same way as database drivers
+// are registered. Panics if the plugin's job type is invalid, is a built-in type or is already registered.
+func Register(plugin JobTypePlugin) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	err := registered.add(plugin)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Registered returns the plugins that have been registered with Register.
+func Registered() JobTypePlugins {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	plugins := make(JobTypePlugins, len(registered))
+	for jobType, plugin := range registered {
+		plugins[jobType] = plugin
+	}
+	return plugins
+}
+
+// JobTypePlugins is a set of plugins, keyed by the job type each plugin implements.
+type JobTypePlugins map[models.JobType]JobTypePlugin
+
+// NewJobTypePlugins returns the registered plugins along with an exec plugin for each of the supplied configs.
+func NewJobTypePlugins(execPlugins []ExecPluginConfig) (JobTypePlugins, error) {
+	plugins := Registered()
+	for _, config := range execPlugins {
+		err := plugins.add(NewExecPlugin(config))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plugins, nil
+}
+
+// Get returns the plugin implementing jobType, or nil if there is no such plugin.
+func (p JobTypePlugins) Get(jobType models.JobType) JobTypePlugin {
+	return p[jobType]
+}
+
+// SupportedJobTypes returns the built-in job types followed by the job types implemented by the plugins.
+func (p JobTypePlugins) SupportedJobTypes() models.JobTypes {
+	jobTypes := models.JobTypes{models.JobTypeDocker, models.JobTypeExec}
+	var custom models.JobTypes
+	for jobType := range p {
+		custom = append(custom, jobType)
+	}
+	sort.Slice(custom, func(i, j int) bool {
+		return custom[i] < custom[j]
+	})
+	return append(jobTypes, custom...)
+}
+
+func (p JobTypePlugins) add(plugin JobTypePlugin) error {
+	jobType := plugin.JobType()
+	if !jobType.Valid() {
+		return fmt.Errorf("error invalid job type for plugin: %q", jobType)
+	}
+	if jobType.IsBuiltIn() {
+		return fmt.Errorf("error plugin cannot replace built-in job type %q", jobType)
+	}
+	if _, exists := p[jobType]; exists {
+		return fmt.Errorf("error more than one plugin for job type %q", jobType)
+	}
+	p[jobType] = plugin
+	return nil
+}
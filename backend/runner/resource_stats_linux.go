@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// getResourceStats returns the CPU and memory usage of the runner's host, read from /proc.
+func getResourceStats() (*models.RunnerResourceStats, error) {
+	stats := &models.RunnerResourceStats{CPUCount: runtime.NumCPU()}
+
+	loadAvg, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(loadAvg))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("error unexpected format in /proc/loadavg: %q", loadAvg)
+	}
+	stats.LoadAverage, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing load average: %w", err)
+	}
+
+	memInfo, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer memInfo.Close()
+	scanner := bufio.NewScanner(memInfo)
+	for scanner.Scan() {
+		// Lines are of the form "MemTotal:       16318408 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var dest *uint64
+		switch fields[0] {
+		case "MemTotal:":
+			dest = &stats.MemoryTotalBytes
+		case "MemAvailable:":
+			dest = &stats.MemoryAvailableBytes
+		default:
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s in /proc/meminfo: %w", fields[0], err)
+		}
+		*dest = kb * 1024
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading /proc/meminfo: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+import (
+	"runtime"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// getResourceStats returns the CPU and memory usage of the runner's host. Only the number of CPUs is
+// measured on operating systems other than Linux.
+func getResourceStats() (*models.RunnerResourceStats, error) {
+	return &models.RunnerResourceStats{CPUCount: runtime.NumCPU()}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 
@@ -66,10 +68,20 @@ type ContainerConfig struct {
 	// Aliases is a list of names this container will be resolvable on from
 	// each of the configured networks (if any).
 	Aliases []string
-	Stdout  io.Writer
-	Stderr  io.Writer
+	// Ports is a list of container ports to publish on the host, in Docker's [ip:]hostPort:containerPort format.
+	Ports []string
+	// Resources limits the CPU, memory and (if the engine supports it) disk space the container may use.
+	// Resources left as zero are not limited.
+	Resources models.Resources
+	// GPUs are the IDs of the host GPUs to make available in the container via the NVIDIA container runtime.
+	GPUs   []string
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
+// killExecTimeout is the maximum time to wait for a script exec to be killed inside a container.
+const killExecTimeout = 30 * time.Second
+
 type ExecConfig struct {
 	ContainerID string
 	Command     []string
@@ -77,15 +89,19 @@ type ExecConfig struct {
 	Env         []string
 	Stdout      io.Writer
 	Stderr      io.Writer
+	// KillCommand is an optional command to execute in the container to kill Command and any processes it
+	// started, if the context is done before Command finishes.
+	KillCommand []string
 }
 
 type ContainerManager struct {
+	engine EngineConfig
 	client *client.Client
 	log    logger.Log
 }
 
-func NewContainerManager(client *client.Client, logFactory logger.LogFactory) *ContainerManager {
-	return &ContainerManager{client: client, log: logFactory("DockerContainerManager")}
+func NewContainerManager(engine EngineConfig, client *client.Client, logFactory logger.LogFactory) *ContainerManager {
+	return &ContainerManager{engine: engine, client: client, log: logFactory("DockerContainerManager")}
 }
 
 // PullDockerImage pulls a Docker Image from a remote registry.
@@ -170,8 +186,43 @@ func (r *ContainerManager) PullDockerImage(ctx context.Context, log *logging.Str
 		log.WriteLinef("Using Docker registry auth: None")
 	}
 
+	if r.engine.RegistryMirror != "" {
+		mirrored, err := r.pullFromMirror(ctx, config.ImageURI)
+		if err == nil {
+			log.WriteLinef("Pulled image from registry mirror: %s", mirrored)
+			return nil
+		}
+		if mirrored != "" {
+			log.WriteLinef("Unable to pull image from registry mirror; pulling from Docker Hub instead: %s", err)
+		}
+	}
+
 	// TODO this error needs to go to the job log
-	stream, err := r.client.ImagePull(ctx, image.FQN(), imagePullOptions)
+	return r.pullImage(ctx, image.FQN(), imagePullOptions)
+}
+
+// pullFromMirror pulls a Docker Hub image via the engine's registry mirror, and tags it with its original
+// reference so that it can be used exactly as if it had been pulled from Docker Hub. Returns the mirrored
+// reference, or an empty string if the image isn't from Docker Hub and so can't be pulled via the mirror.
+func (r *ContainerManager) pullFromMirror(ctx context.Context, imageURI string) (string, error) {
+	mirrored := models.RewriteDockerImageRegistry(imageURI, map[string]string{models.DefaultDockerRegistry: r.engine.RegistryMirror})
+	if mirrored == imageURI {
+		return "", fmt.Errorf("error image is not from %s", models.DefaultDockerRegistry)
+	}
+	// No auth is sent to the mirror; the mirror uses its own credentials to pull from Docker Hub
+	err := r.pullImage(ctx, mirrored, types.ImagePullOptions{})
+	if err != nil {
+		return mirrored, err
+	}
+	err = r.client.ImageTag(ctx, mirrored, imageURI)
+	if err != nil {
+		return mirrored, errors.Wrap(err, "error tagging image pulled from registry mirror")
+	}
+	return mirrored, nil
+}
+
+func (r *ContainerManager) pullImage(ctx context.Context, ref string, imagePullOptions types.ImagePullOptions) error {
+	stream, err := r.client.ImagePull(ctx, ref, imagePullOptions)
 	if err != nil {
 		return errors.Wrap(err, "error pulling image")
 	}
@@ -216,12 +267,44 @@ func (r *ContainerManager) StartContainer(ctx context.Context, config ContainerC
 		AutoRemove: false,
 		Binds:      config.Binds,
 	}
+	if config.Resources.CPUMillis > 0 {
+		hConfig.NanoCPUs = config.Resources.CPUMillis * 1e6
+	}
+	if config.Resources.MemoryBytes > 0 {
+		hConfig.Memory = config.Resources.MemoryBytes
+	}
+	if config.Resources.DiskBytes > 0 && r.engine.DiskLimits {
+		hConfig.StorageOpt = map[string]string{"size": strconv.FormatInt(config.Resources.DiskBytes, 10)}
+	}
+	if len(config.GPUs) > 0 {
+		// Equivalent to 'docker run --gpus "device=..."'
+		hConfig.DeviceRequests = []container.DeviceRequest{{
+			Driver:       "nvidia",
+			DeviceIDs:    config.GPUs,
+			Capabilities: [][]string{{"gpu"}},
+		}}
+	}
+	if len(config.Ports) > 0 {
+		exposedPorts, portBindings, err := nat.ParsePortSpecs(config.Ports)
+		if err != nil {
+			return "", errors.Wrap(err, "error parsing ports")
+		}
+		cConfig.ExposedPorts = exposedPorts
+		hConfig.PortBindings = portBindings
+	}
 	nConfig := &network.NetworkingConfig{}
+	networks := config.Networks
+	if r.engine.IsPodman() && len(networks) > 0 {
+		// Podman only registers a container's aliases with a network's DNS reliably when the container is
+		// attached to the network as it is created; Docker allows at most one network to be given here.
+		nConfig.EndpointsConfig = map[string]*network.EndpointSettings{networks[0]: {Aliases: config.Aliases}}
+		networks = networks[1:]
+	}
 	res, err := r.client.ContainerCreate(ctx, cConfig, hConfig, nConfig, nil, config.Name) // platform is optional
 	if err != nil {
 		return "", errors.Wrap(err, "error creating container")
 	}
-	for _, networkID := range config.Networks {
+	for _, networkID := range networks {
 		nConfig := &network.EndpointSettings{Aliases: config.Aliases}
 		err = r.client.NetworkConnect(ctx, networkID, res.ID, nConfig)
 		if err != nil {
@@ -303,14 +386,31 @@ func (r *ContainerManager) Execute(ctx context.Context, config ExecConfig) error
 		return fmt.Errorf("error attaching script exec: %w", err)
 	}
 	defer resp.Close()
+
+	// Docker doesn't stop an exec when the client goes away, so if the context is done before the command
+	// finishes then kill it explicitly, and close the attached stream so we stop waiting for its output
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.killExec(config)
+			resp.Close()
+		case <-done:
+		}
+	}()
+
 	if config.Stdout != nil || config.Stderr != nil {
 		err = r.pipeContainerLog(ctx, resp.Reader, config.Stdout, config.Stderr)
-		if err != nil {
+		if err != nil && ctx.Err() == nil {
 			return fmt.Errorf("error piping container log: %w", err)
 		}
 	}
 	var exitCode int
 	for {
+		if ctx.Err() != nil {
+			return fmt.Errorf("error running script exec: %w", ctx.Err())
+		}
 		res, err := r.client.ContainerExecInspect(ctx, createRes.ID)
 		if err != nil {
 			return fmt.Errorf("error inspecting script exec: %w", err)
@@ -328,9 +428,46 @@ func (r *ContainerManager) Execute(ctx context.Context, config ExecConfig) error
 	return nil
 }
 
+// killExec runs the kill command for an exec, if it has one. Errors are logged rather than returned since
+// the exec has already failed by the time it needs killing.
+func (r *ContainerManager) killExec(config ExecConfig) {
+	if len(config.KillCommand) == 0 {
+		return
+	}
+	// The exec's own context is already done, so use a new context to kill it
+	ctx, cancel := context.WithTimeout(context.Background(), killExecTimeout)
+	defer cancel()
+	createRes, err := r.client.ContainerExecCreate(ctx, config.ContainerID, types.ExecConfig{
+		Cmd:    config.KillCommand,
+		Detach: true,
+	})
+	if err != nil {
+		r.log.Warnf("Error creating exec to kill script in container %s: %v", config.ContainerID, err)
+		return
+	}
+	err = r.client.ContainerExecStart(ctx, createRes.ID, types.ExecStartCheck{Detach: true})
+	if err != nil {
+		r.log.Warnf("Error starting exec to kill script in container %s: %v", config.ContainerID, err)
+		return
+	}
+	for ctx.Err() == nil {
+		res, err := r.client.ContainerExecInspect(ctx, createRes.ID)
+		if err != nil || !res.Running {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 // CreateNetwork creates a new private network and returns its ID.
 func (r *ContainerManager) CreateNetwork(ctx context.Context, name string) (string, error) {
-	res, err := r.client.NetworkCreate(ctx, name, types.NetworkCreate{})
+	options := types.NetworkCreate{}
+	if r.engine.IsPodman() {
+		// Podman only provides DNS (and so service name resolution) on bridge networks, and doesn't
+		// default to a bridge network when running rootless.
+		options.Driver = "bridge"
+	}
+	res, err := r.client.NetworkCreate(ctx, name, options)
 	if err != nil {
 		return "", fmt.Errorf("error creating network: %w", err)
 	}
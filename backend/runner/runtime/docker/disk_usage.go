@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+)
+
+// anonymousVolumeNameRegex matches the names Docker generates for anonymous volumes.
+var anonymousVolumeNameRegex = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// DiskUsage describes a Docker image or volume and the disk space it uses.
+type DiskUsage struct {
+	// ID is the ID of the image, or the name of the volume.
+	ID string
+	// Name is a human-readable name for the image or volume.
+	Name string
+	// Size is the disk space used in bytes, or -1 if Docker was unable to calculate it.
+	Size int64
+	// CreatedAt is the time the image or volume was created.
+	CreatedAt time.Time
+}
+
+// ListImageDiskUsage returns the disk usage of each locally stored image matching one of the supplied references.
+// References that don't match any locally stored image are ignored.
+func (r *ContainerManager) ListImageDiskUsage(ctx context.Context, references []string) ([]*DiskUsage, error) {
+	var (
+		usages []*DiskUsage
+		seen   = make(map[string]bool)
+	)
+	for _, reference := range references {
+		fil := filters.NewArgs()
+		fil.Add("reference", parseDockerImageURI(reference).Reference())
+		list, err := r.client.ImageList(ctx, types.ImageListOptions{Filters: fil})
+		if err != nil {
+			return nil, fmt.Errorf("error listing images: %w", err)
+		}
+		for _, image := range list {
+			if seen[image.ID] {
+				continue
+			}
+			seen[image.ID] = true
+			name := reference
+			if len(image.RepoTags) > 0 {
+				name = image.RepoTags[0]
+			}
+			usages = append(usages, &DiskUsage{
+				ID:        image.ID,
+				Name:      name,
+				Size:      image.Size,
+				CreatedAt: time.Unix(image.Created, 0),
+			})
+		}
+	}
+	return usages, nil
+}
+
+// ListUnusedVolumeDiskUsage returns the disk usage of each anonymous volume that is not in use by any container.
+// These are left behind when containers that declare volumes are removed without their volumes.
+func (r *ContainerManager) ListUnusedVolumeDiskUsage(ctx context.Context) ([]*DiskUsage, error) {
+	usage, err := r.client.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, fmt.Errorf("error reading docker disk usage: %w", err)
+	}
+	var usages []*DiskUsage
+	for _, volume := range usage.Volumes {
+		if !anonymousVolumeNameRegex.MatchString(volume.Name) || volume.UsageData == nil || volume.UsageData.RefCount != 0 {
+			continue
+		}
+		// Volumes created by older versions of Docker don't report a creation time
+		createdAt, _ := time.Parse(time.RFC3339, volume.CreatedAt)
+		usages = append(usages, &DiskUsage{
+			ID:        volume.Name,
+			Name:      volume.Name,
+			Size:      volume.UsageData.Size,
+			CreatedAt: createdAt,
+		})
+	}
+	return usages, nil
+}
+
+// RemoveImage removes a locally stored image, including all of its tags.
+// Returns an error if the image is in use by a running container.
+func (r *ContainerManager) RemoveImage(ctx context.Context, imageID string) error {
+	_, err := r.client.ImageRemove(ctx, imageID, types.ImageRemoveOptions{Force: true, PruneChildren: true})
+	if err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("error removing image %q: %w", imageID, err)
+	}
+	return nil
+}
+
+// RemoveVolume removes a volume. Returns an error if the volume is in use by a container.
+func (r *ContainerManager) RemoveVolume(ctx context.Context, volumeName string) error {
+	err := r.client.VolumeRemove(ctx, volumeName, false)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("error removing volume %q: %w", volumeName, err)
+	}
+	return nil
+}
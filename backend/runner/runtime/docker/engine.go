@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/docker/docker/client"
+)
+
+type EngineType string
+
+const (
+	// EngineTypeDocker is the Docker engine, or any other engine that behaves exactly like it.
+	EngineTypeDocker EngineType = "docker"
+	// EngineTypePodman is Podman, serving the Docker-compatible API via 'podman system service'.
+	EngineTypePodman EngineType = "podman"
+)
+
+func (t EngineType) Valid() bool {
+	return t == EngineTypeDocker || t == EngineTypePodman
+}
+
+func (t EngineType) String() string {
+	return string(t)
+}
+
+// EngineConfig selects the container engine used to run docker jobs and their services.
+// Any engine that serves the Docker API can be used.
+type EngineConfig struct {
+	// Type is the type of engine. Defaults to Docker if empty.
+	Type EngineType
+	// Host is the address of the engine's API (e.g. unix:///run/podman/podman.sock). If empty then the
+	// engine's default socket is used, unless the DOCKER_HOST environment variable is set.
+	Host string
+	// Rootless is true if the engine runs as an unprivileged user rather than as root, in which case its
+	// default socket is in the user's runtime directory.
+	Rootless bool
+	// DiskLimits is true if the engine's storage driver supports limiting the size of a container's
+	// writable layer (e.g. overlay2 on xfs with project quotas), in which case containers for jobs that
+	// declare a disk requirement are limited to that size.
+	DiskLimits bool
+	// RegistryMirror is the address of a pull-through registry mirror for Docker Hub (e.g. mirror.example.com:5000,
+	// optionally followed by a path prefix). If set, Docker Hub images are pulled via the mirror, falling back
+	// to Docker Hub if the mirror can't provide the image.
+	RegistryMirror string
+}
+
+// IsPodman returns true if the engine is Podman.
+func (c EngineConfig) IsPodman() bool {
+	return c.Type == EngineTypePodman
+}
+
+// GetHost returns the address of the engine's API.
+func (c EngineConfig) GetHost() string {
+	if c.Host != "" {
+		return c.Host
+	}
+	if host := os.Getenv(client.EnvOverrideHost); host != "" {
+		return host
+	}
+	switch {
+	case c.IsPodman() && c.Rootless:
+		return "unix://" + filepath.Join(userRuntimeDir(), "podman", "podman.sock")
+	case c.IsPodman():
+		return "unix:///run/podman/podman.sock"
+	case c.Rootless:
+		return "unix://" + filepath.Join(userRuntimeDir(), "docker.sock")
+	default:
+		return client.DefaultDockerHost
+	}
+}
+
+// SocketPath returns the path on the host of the engine's unix socket, or an empty string if the
+// engine's API is not served on a unix socket.
+func (c EngineConfig) SocketPath() string {
+	hostURL, err := url.Parse(c.GetHost())
+	if err != nil || hostURL.Scheme != "unix" {
+		return ""
+	}
+	return hostURL.Path
+}
+
+// BindOptions returns the options to add to each bind mount of a host directory into a container.
+func (c EngineConfig) BindOptions() string {
+	if c.IsPodman() {
+		// Podman is commonly used on SELinux hosts, where bind mounted directories must be relabelled
+		// before containers can use them. The option is ignored on hosts without SELinux.
+		return "rw,z"
+	}
+	return "rw"
+}
+
+// NewClient makes a client for the engine's API.
+func NewClient(config EngineConfig) (*client.Client, error) {
+	dClient, err := client.NewClientWithOpts(client.FromEnv, client.WithHost(config.GetHost()), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("error making %s API client: %w", config.engineName(), err)
+	}
+	return dClient, nil
+}
+
+func (c EngineConfig) engineName() string {
+	if c.IsPodman() {
+		return "Podman"
+	}
+	return "Docker"
+}
+
+// userRuntimeDir returns the directory containing the current user's sockets when running rootless.
+func userRuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("/run/user", strconv.Itoa(os.Getuid()))
+}
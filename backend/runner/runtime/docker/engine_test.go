@@ -0,0 +1,35 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineConfig(t *testing.T) {
+	t.Setenv(client.EnvOverrideHost, "")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	// Each engine has a default socket, which depends on whether it runs rootless
+	engine := EngineConfig{}
+	require.Equal(t, client.DefaultDockerHost, engine.GetHost())
+	require.Equal(t, "rw", engine.BindOptions())
+	engine = EngineConfig{Rootless: true}
+	require.Equal(t, "/run/user/1000/docker.sock", engine.SocketPath())
+	engine = EngineConfig{Type: EngineTypePodman}
+	require.Equal(t, "/run/podman/podman.sock", engine.SocketPath())
+	require.Equal(t, "rw,z", engine.BindOptions())
+	engine = EngineConfig{Type: EngineTypePodman, Rootless: true}
+	require.Equal(t, "unix:///run/user/1000/podman/podman.sock", engine.GetHost())
+
+	// An explicit host takes precedence over DOCKER_HOST, which takes precedence over the default
+	t.Setenv(client.EnvOverrideHost, "unix:///var/run/custom.sock")
+	require.Equal(t, "/var/run/custom.sock", engine.SocketPath())
+	engine.Host = "tcp://127.0.0.1:2375"
+	require.Equal(t, "tcp://127.0.0.1:2375", engine.GetHost())
+	require.Equal(t, "", engine.SocketPath())
+
+	require.True(t, EngineTypePodman.Valid())
+	require.False(t, EngineType("containerd").Valid())
+}
@@ -3,7 +3,6 @@ package docker
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 	"strings"
 
 	"github.com/docker/docker/client"
@@ -17,10 +16,16 @@ import (
 
 type Config struct {
 	runtime.Config
+	// Engine is the container engine the runtime's containers are run by.
+	Engine       EngineConfig
 	ImageURI     string
 	AuthOrNil    *Auth
 	PullStrategy models.DockerPullStrategy
 	ShellOrNil   *string
+	// Resources limits the CPU, memory and disk space the job's container may use.
+	Resources models.Resources
+	// GPUDeviceIDs are the host GPUs to give the job's container, if any.
+	GPUDeviceIDs []string
 	Services     []RuntimeServiceConfig
 }
 
@@ -30,6 +35,8 @@ type RuntimeServiceConfig struct {
 	ImageURI     string
 	AuthOrNil    *Auth
 	PullStrategy models.DockerPullStrategy
+	// Ports is a list of the service's ports to publish on the host, in Docker's [ip:]hostPort:containerPort format.
+	Ports []string
 }
 
 type runtimeImageConfig struct {
@@ -66,7 +73,7 @@ func NewRuntime(config Config, client *client.Client, logFactory logger.LogFacto
 		NetworkType:        ServiceNetworkTypePrivate,
 		PrivateNetworkName: makeNetworkName(&config),
 	}
-	cManager := NewContainerManager(client, logFactory)
+	cManager := NewContainerManager(config.Engine, client, logFactory)
 	return &Runtime{
 		config:           config,
 		containerManager: cManager,
@@ -120,6 +127,8 @@ func (r *Runtime) Start(ctx context.Context) error {
 		WorkingDir: config.GuestWorkspaceDir,
 		Binds:      config.Binds,
 		Networks:   []string{network.NetworkID},
+		Resources:  r.config.Resources,
+		GPUs:       r.config.GPUDeviceIDs,
 		Stdout:     converter,
 		Stderr:     converter,
 	}
@@ -154,26 +163,58 @@ func (r *Runtime) Stop(ctx context.Context) error {
 // Exec executes a command inside the runtime.
 // Start must have been called before calling Exec.
 func (r *Runtime) Exec(ctx context.Context, config runtime.ExecConfig) error {
-	_, err := runtime.WriteScript(r.config.StagingDir, config.Name, config.Commands)
+	shell := runtime.ResolveShell(r.state.imageConfig.OS, r.config.ShellOrNil).WithEcho(config.EchoCommands)
+	hostScriptPath, err := shell.WriteScript(r.config.StagingDir, config.Name, config.Commands)
 	if err != nil {
 		return err
 	}
-	shell := runtime.ShellOrDefault(r.state.imageConfig.OS, r.config.ShellOrNil)
-	containerScriptPath, _, err := r.mapHostPath(runtime.GetHostOS(), filepath.Join(r.config.StagingDir, config.Name))
+	containerScriptPath, _, err := r.mapHostPath(runtime.GetHostOS(), hostScriptPath)
 	if err != nil {
 		return err
 	}
 	execConfig := ExecConfig{
 		ContainerID: r.state.containerID,
-		Command:     []string{shell, containerScriptPath},
+		Command:     shell.Command(containerScriptPath),
 		WorkingDir:  r.state.containerConfig.GuestWorkspaceDir,
 		Env:         r.fixEnv(config.Env),
 		Stdout:      config.Stdout,
 		Stderr:      config.Stderr,
 	}
+	if r.state.imageConfig.OS == runtime.OSLinux {
+		// Record the script's PID so the script and everything it started can be killed inside the container
+		// if the context is done before the script finishes. sh execs the script's shell so the PIDs match.
+		shPath, err := runtime.ShellPath(runtime.ShellSH)
+		if err != nil {
+			return err
+		}
+		pidPath := containerScriptPath + ".pid"
+		execConfig.Command = []string{shPath, "-c", fmt.Sprintf(`echo $$ > '%s' && exec %s`, pidPath, shellQuote(execConfig.Command))}
+		execConfig.KillCommand = []string{shPath, "-c", fmt.Sprintf(linuxKillProcessTreeScript, pidPath)}
+	}
 	return r.containerManager.Execute(ctx, execConfig)
 }
 
+// shellQuote quotes each of the words in a command so that it can be run by sh.
+func shellQuote(command []string) string {
+	quoted := make([]string, len(command))
+	for i, word := range command {
+		quoted[i] = "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// linuxKillProcessTreeScript is a shell script that kills the process whose PID is in the file at the path
+// substituted for %s, along with all of its descendants. Each process is stopped before its children are
+// found, so it can't start new children while the tree is being killed.
+const linuxKillProcessTreeScript = `kill_tree() {
+  kill -STOP "$1" 2>/dev/null
+  for child in $(cat /proc/"$1"/task/*/children 2>/dev/null); do
+    kill_tree "$child"
+  done
+  kill -KILL "$1" 2>/dev/null
+}
+kill_tree "$(cat '%s')"`
+
 // StartService starts a service inside the runtime.
 // The service must be resolvable by name to commands run with Exec.
 // Service names are unique within the runtime - it is an error to try start service with the same name twice.
@@ -188,6 +229,7 @@ func (r *Runtime) StartService(ctx context.Context, config runtime.ServiceConfig
 			sConfig.AuthOrNil = service.AuthOrNil
 			sConfig.ImageURI = service.ImageURI
 			sConfig.PullStrategy = service.PullStrategy
+			sConfig.Ports = service.Ports
 			found = true
 			break
 		}
@@ -231,13 +273,11 @@ func (r *Runtime) prepareJobContainerConfig(ctx context.Context) (*runtimeContai
 }
 
 func (r *Runtime) prepareWindowsContainerConfig(ctx context.Context) (*runtimeContainerConfig, error) {
-	// TODO work with the configured shell
-	scriptName := fmt.Sprintf("pid0")
-	_, err := runtime.WriteScript(r.config.StagingDir, scriptName, []string{"timeout /t -1"})
-	if err != nil {
-		return nil, err
-	}
-	shellPath, err := runtime.ShellPath(runtime.ShellCMD)
+	// Keep the container alive with cmd.exe, which is present in every Windows image. Steps are run with the
+	// configured shell. Windows containers have no console, so "timeout" can't be used to wait here.
+	shell := runtime.ResolveShell(runtime.OSWindows, nil)
+	scriptName := shell.ScriptName("pid0")
+	_, err := runtime.WriteScript(r.config.StagingDir, scriptName, []string{"ping -t localhost > NUL"})
 	if err != nil {
 		return nil, err
 	}
@@ -246,14 +286,15 @@ func (r *Runtime) prepareWindowsContainerConfig(ctx context.Context) (*runtimeCo
 	guestKeepAliveScriptPath := fmt.Sprintf("C:\\buildbeaver\\staging\\%s", scriptName)
 	binds := []string{
 		fmt.Sprintf("%s:%s:rw", r.config.WorkspaceDir, guestWorkingDir),
-		fmt.Sprintf("%s:%s:ro", r.config.StagingDir, guestStagingDir),
+		// The staging dir is writable so that steps can write to the step env file
+		fmt.Sprintf("%s:%s:rw", r.config.StagingDir, guestStagingDir),
 		// Windows containers only run on Windows, so use the Windows pipe syntax
 		"\\\\.\\pipe\\docker_engine:\\\\.\\pipe\\docker_engine",
 	}
 	return &runtimeContainerConfig{
 		Name:                util.EscapeFileName(r.config.RuntimeID),
 		Binds:               binds,
-		GuestShellPath:      []string{shellPath},
+		GuestShellPath:      append([]string{shell.Path}, shell.Args...),
 		GuestWorkspaceDir:   guestWorkingDir,
 		GuestStagingDir:     guestStagingDir,
 		GuestPID0ScriptPath: guestKeepAliveScriptPath,
@@ -274,12 +315,21 @@ func (r *Runtime) prepareLinuxContainerConfig(ctx context.Context) (*runtimeCont
 	guestWorkingDir := "/tmp/buildbeaver/workspace"
 	guestStagingDir := "/tmp/buildbeaver/staging"
 	guestKeepAliveScriptPath := fmt.Sprintf("/tmp/buildbeaver/staging/%s", scriptName)
+	bindOptions := r.config.Engine.BindOptions()
 	binds := []string{
-		fmt.Sprintf("%s:%s:rw", r.config.WorkspaceDir, guestWorkingDir),
-		fmt.Sprintf("%s:%s:ro", r.config.StagingDir, guestStagingDir),
-		// Linux containers run natively on Linux, and in a Linux VM on Windows and macOS,
-		// so we can always refer to the Linux socket path here
-		"/var/run/docker.sock:/var/run/docker.sock",
+		fmt.Sprintf("%s:%s:%s", r.config.WorkspaceDir, guestWorkingDir, bindOptions),
+		// The staging dir is writable so that steps can write to the step env file
+		fmt.Sprintf("%s:%s:%s", r.config.StagingDir, guestStagingDir, bindOptions),
+	}
+	// Make the engine's socket available to steps at the standard Docker path, so they can run containers.
+	// Docker's Linux containers run natively on Linux, and in a Linux VM on Windows and macOS, so Docker's
+	// default socket can always be referred to by its Linux path. Other engines are found via their config.
+	socketPath := "/var/run/docker.sock"
+	if r.config.Engine.IsPodman() || r.config.Engine.Rootless || r.config.Engine.Host != "" {
+		socketPath = r.config.Engine.SocketPath()
+	}
+	if socketPath != "" {
+		binds = append(binds, fmt.Sprintf("%s:/var/run/docker.sock", socketPath))
 	}
 	return &runtimeContainerConfig{
 		Name:                r.config.RuntimeID,
@@ -30,6 +30,7 @@ type ServiceConfig struct {
 	AuthOrNil    *Auth
 	PullStrategy models.DockerPullStrategy
 	Env          []string
+	Ports        []string
 }
 
 type ServiceManagerConfig struct {
@@ -93,6 +94,7 @@ func (s *ServiceManager) StartService(ctx context.Context, config ServiceConfig)
 		Name:     makeContainerNameForService(&s.config, &config),
 		ImageURI: config.ImageURI,
 		Env:      config.Env,
+		Ports:    config.Ports,
 		Aliases:  []string{config.Name},
 		// TODO Web UI needs some work to support concurrent writing to multiple blocks.
 		//  Right now everything shows up under the most recently declared block.
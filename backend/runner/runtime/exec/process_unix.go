@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package exec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup configures the command to start in a new process group, so that the command and any
+// processes it starts can be killed together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessTree kills the started command along with any processes it started.
+func killProcessTree(cmd *exec.Cmd) error {
+	// A negative PID signals every process in the process group
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package exec
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows; child processes are found via the process tree when killing.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessTree kills the started command along with any processes it started.
+func killProcessTree(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
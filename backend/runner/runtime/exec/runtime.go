@@ -36,28 +36,14 @@ func (r *Runtime) Stop(ctx context.Context) error {
 // Exec executes a command inside the runtime.
 // Start must have been called before calling Exec.
 func (r *Runtime) Exec(ctx context.Context, config runtime.ExecConfig) error {
-	hostOS := runtime.GetHostOS()
-
-	scriptName := config.Name
-	if hostOS == runtime.OSWindows {
-		// Windows cmd.exe requires scripts to end in ".bat", or they won't be executed
-		scriptName += ".bat"
-	}
-
-	scriptPath, err := runtime.WriteScript(r.config.StagingDir, scriptName, config.Commands)
+	shell := runtime.ResolveShell(runtime.GetHostOS(), r.config.ShellOrNil).WithEcho(config.EchoCommands)
+	scriptPath, err := shell.WriteScript(r.config.StagingDir, config.Name, config.Commands)
 	if err != nil {
 		return err
 	}
-	shell := runtime.ShellOrDefault(hostOS, r.config.ShellOrNil)
-
-	var cmd *exec.Cmd
-	if hostOS == runtime.OSWindows {
-		// Windows cmd.exe requires the /C option to run commands, as well as some other recommended options.
-		// NOTE that "/C" must be the last option, immediately before the actual command.
-		cmd = exec.CommandContext(ctx, shell, "/D", "/E:ON", "/V:OFF", "/S", "/C", scriptPath)
-	} else {
-		cmd = exec.CommandContext(ctx, shell, scriptPath)
-	}
+	command := shell.Command(scriptPath)
+	cmd := exec.Command(command[0], command[1:]...)
+	setProcessGroup(cmd)
 
 	cmd.Dir = r.config.WorkspaceDir
 	cmd.Stdout = config.Stdout
@@ -68,7 +54,26 @@ func (r *Runtime) Exec(ctx context.Context, config runtime.ExecConfig) error {
 	pathEnv := os.Getenv("PATH")
 	cmd.Env = append(config.Env, "PATH="+pathEnv)
 
-	err = cmd.Run()
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("error starting command: %w", err)
+	}
+
+	// Kill the whole process tree if the context is done before the command finishes, rather than just the
+	// shell, so that background processes can't keep the command's output open and stall the job
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = killProcessTree(cmd)
+		case <-done:
+		}
+	}()
+	err = cmd.Wait()
+	close(done)
+	if ctx.Err() != nil {
+		return fmt.Errorf("error running command: %w", ctx.Err())
+	}
 	if err != nil {
 		return fmt.Errorf("error running command: %w", err)
 	}
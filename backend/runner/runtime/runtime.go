@@ -38,6 +38,9 @@ type ExecConfig struct {
 	Commands []string
 	// Env is the environment in the form name=value to expose to the commands.
 	Env []string
+	// EchoCommands is true to print each command before it runs, false to not print commands, or nil to
+	// use the shell's default.
+	EchoCommands *bool
 	// Stdout is optional. If supplied the command(s) stdout will be written to it.
 	Stdout io.Writer
 	// Stdout is optional. If supplied the command(s) stderr will be written to it.
@@ -3,7 +3,6 @@ package runtime
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -21,8 +20,11 @@ const (
 type Shell string
 
 const (
-	ShellCMD Shell = "cmd"
-	ShellSH  Shell = "sh"
+	ShellCMD        Shell = "cmd"
+	ShellSH         Shell = "sh"
+	ShellBash       Shell = "bash"
+	ShellPowerShell Shell = "powershell"
+	ShellPwsh       Shell = "pwsh"
 )
 
 func ShellPath(shell Shell) (string, error) {
@@ -31,26 +33,130 @@ func ShellPath(shell Shell) (string, error) {
 		return "C:\\Windows\\System32\\cmd.exe", nil
 	case ShellSH:
 		return "/bin/sh", nil
+	case ShellBash:
+		return "/bin/bash", nil
+	case ShellPowerShell:
+		return "powershell.exe", nil
+	case ShellPwsh:
+		return "pwsh", nil
 	default:
 		return "", fmt.Errorf("error unknown shell: %v", shell)
 	}
 }
 
-func ShellOrDefault(platform OS, shell *string) string {
-	if shell != nil {
-		return *shell
+// ShellConfig describes how to run a script with a shell.
+type ShellConfig struct {
+	// Path is the path to the shell executable.
+	Path string
+	// Args are the arguments to pass to the shell before the path to the script.
+	Args []string
+	// ScriptExtension is the file extension the shell requires scripts to have, if any.
+	ScriptExtension string
+	// ScriptPrefix and ScriptSuffix are lines added before and after the commands in each script, to make
+	// the script fail when its commands do.
+	ScriptPrefix []string
+	ScriptSuffix []string
+	// EchoOn and EchoOff are lines added at the start of each script to turn on or off printing each command
+	// before it runs, if the shell supports this and doesn't already behave that way by default.
+	EchoOn  []string
+	EchoOff []string
+}
+
+// powerShellArgs runs a script with PowerShell without loading the user's profile or prompting for input.
+var powerShellArgs = []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}
+
+// powerShellScriptPrefix and powerShellScriptSuffix make a PowerShell script fail if a cmdlet fails, or if
+// the last native command it ran exited with a non-zero exit code.
+var (
+	powerShellScriptPrefix = []string{"$ErrorActionPreference = 'Stop'"}
+	powerShellScriptSuffix = []string{"if ((Test-Path -LiteralPath variable:\\LASTEXITCODE)) { exit $LASTEXITCODE }"}
+)
+
+// ResolveShell returns the config for running scripts with a shell on the specified platform. shell may be
+// the name of a well-known shell (see Shell), or the path to a shell executable. Shells at paths whose file
+// names match a well-known shell are run in the same way as the well-known shell. If no shell is specified
+// then cmd is used on Windows and sh is used everywhere else.
+func ResolveShell(platform OS, shellOrNil *string) *ShellConfig {
+	var shell, path string
+	if shellOrNil != nil {
+		path = *shellOrNil
+		// Windows paths use backslashes, which filepath only treats as separators on Windows hosts
+		name := path[strings.LastIndexAny(path, "/\\")+1:]
+		shell = strings.TrimSuffix(strings.ToLower(name), ".exe")
+	} else if platform == OSWindows {
+		shell = string(ShellCMD)
+	} else {
+		shell = string(ShellSH)
 	}
-	switch platform {
-	case OSWindows:
-		return "C:\\Windows\\System32\\cmd.exe"
-	case OSLinux, OSMacOS:
-		return "/bin/sh"
+	if path == "" || path == shell {
+		// Find the well-known shell; any other shell given by name is looked up on the PATH when it's run
+		wellKnownPath, err := ShellPath(Shell(shell))
+		if err == nil {
+			path = wellKnownPath
+		}
+	}
+	switch Shell(shell) {
+	case ShellCMD:
+		// cmd.exe requires scripts to end in ".bat" and the /C option to run them, as well as some other
+		// recommended options. NOTE that "/C" must be the last option, immediately before the script.
+		return &ShellConfig{
+			Path:            path,
+			Args:            []string{"/D", "/E:ON", "/V:OFF", "/S", "/C"},
+			ScriptExtension: ".bat",
+			EchoOff:         []string{"@echo off"},
+		}
+	case ShellPowerShell, ShellPwsh:
+		// PowerShell will only run scripts that end in ".ps1"
+		return &ShellConfig{
+			Path:            path,
+			Args:            powerShellArgs,
+			ScriptExtension: ".ps1",
+			ScriptPrefix:    powerShellScriptPrefix,
+			ScriptSuffix:    powerShellScriptSuffix,
+			EchoOn:          []string{"Set-PSDebug -Trace 1"},
+		}
+	case ShellSH, ShellBash:
+		return &ShellConfig{Path: path, EchoOn: []string{"set -x"}}
 	default:
-		log.Panicf("Unsupported OS: %s", platform)
-		return "" // Keep compiler happy
+		return &ShellConfig{Path: path}
 	}
 }
 
+// WithEcho returns a copy of the shell config whose scripts turn printing each command before it runs on or
+// off, or the shell config unchanged if echo is nil (to use the shell's default).
+func (c *ShellConfig) WithEcho(echo *bool) *ShellConfig {
+	if echo == nil {
+		return c
+	}
+	lines := c.EchoOff
+	if *echo {
+		lines = c.EchoOn
+	}
+	withEcho := *c
+	withEcho.ScriptPrefix = append(append([]string{}, lines...), c.ScriptPrefix...)
+	return &withEcho
+}
+
+// ScriptName returns the file name to use for a script with the specified name.
+func (c *ShellConfig) ScriptName(name string) string {
+	return name + c.ScriptExtension
+}
+
+// Command returns the command line that runs the script at scriptPath.
+func (c *ShellConfig) Command(scriptPath string) []string {
+	command := append([]string{c.Path}, c.Args...)
+	return append(command, scriptPath)
+}
+
+// WriteScript writes a script to run the specified commands with the shell, returning the path to the script.
+func (c *ShellConfig) WriteScript(dir string, name string, commands []string) (string, error) {
+	var lines []string
+	lines = append(lines, c.ScriptPrefix...)
+	lines = append(lines, commands...)
+	lines = append(lines, c.ScriptSuffix...)
+	return WriteScript(dir, c.ScriptName(name), lines)
+}
+
 func GetHostOS() OS {
 	os := runtime.GOOS
 	switch os {
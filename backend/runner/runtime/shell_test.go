@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveShell(t *testing.T) {
+	shellPtr := func(shell string) *string { return &shell }
+
+	// Each platform has a default shell
+	shell := ResolveShell(OSLinux, nil)
+	require.Equal(t, []string{"/bin/sh", "/tmp/test"}, shell.Command("/tmp/test"))
+	require.Equal(t, "test", shell.ScriptName("test"))
+	shell = ResolveShell(OSWindows, nil)
+	require.Equal(t, []string{"C:\\Windows\\System32\\cmd.exe", "/D", "/E:ON", "/V:OFF", "/S", "/C", "C:\\test.bat"}, shell.Command("C:\\test.bat"))
+	require.Equal(t, "test.bat", shell.ScriptName("test"))
+
+	// Well-known shells can be given by name or path
+	shell = ResolveShell(OSWindows, shellPtr("powershell"))
+	require.Equal(t, "powershell.exe", shell.Path)
+	require.Equal(t, "test.ps1", shell.ScriptName("test"))
+	require.Equal(t, "-File", shell.Args[len(shell.Args)-1])
+	shell = ResolveShell(OSWindows, shellPtr("C:\\Program Files\\PowerShell\\7\\pwsh.exe"))
+	require.Equal(t, "C:\\Program Files\\PowerShell\\7\\pwsh.exe", shell.Path)
+	require.Equal(t, "test.ps1", shell.ScriptName("test"))
+	shell = ResolveShell(OSWindows, shellPtr("cmd"))
+	require.Equal(t, "C:\\Windows\\System32\\cmd.exe", shell.Path)
+	shell = ResolveShell(OSLinux, shellPtr("bash"))
+	require.Equal(t, []string{"/bin/bash", "/tmp/test"}, shell.Command("/tmp/test"))
+
+	// Other shells are run with the script as their only argument
+	shell = ResolveShell(OSLinux, shellPtr("/usr/bin/zsh"))
+	require.Equal(t, []string{"/usr/bin/zsh", "/tmp/test"}, shell.Command("/tmp/test"))
+	shell = ResolveShell(OSMacOS, shellPtr("fish"))
+	require.Equal(t, []string{"fish", "/tmp/test"}, shell.Command("/tmp/test"))
+
+	// PowerShell scripts fail when a command fails
+	shell = ResolveShell(OSLinux, shellPtr("pwsh"))
+	path, err := shell.WriteScript(t.TempDir(), "test", []string{"dotnet build"})
+	require.NoError(t, err)
+	require.Regexp(t, `test\.ps1$`, path)
+}
+
+func TestShellWithEcho(t *testing.T) {
+	shellPtr := func(shell string) *string { return &shell }
+	on, off := true, false
+
+	shell := ResolveShell(OSLinux, nil)
+	require.Same(t, shell, shell.WithEcho(nil))
+	require.Equal(t, []string{"set -x"}, shell.WithEcho(&on).ScriptPrefix)
+	require.Empty(t, shell.WithEcho(&off).ScriptPrefix)
+	require.Empty(t, shell.ScriptPrefix, "the original config should not be changed")
+
+	// cmd prints commands by default, so can only be told not to
+	shell = ResolveShell(OSWindows, nil)
+	require.Empty(t, shell.WithEcho(&on).ScriptPrefix)
+	require.Equal(t, []string{"@echo off"}, shell.WithEcho(&off).ScriptPrefix)
+
+	// Echo lines go before the shell's own script prefix
+	shell = ResolveShell(OSWindows, shellPtr("pwsh"))
+	prefix := shell.WithEcho(&on).ScriptPrefix
+	require.Equal(t, "Set-PSDebug -Trace 1", prefix[0])
+	require.Equal(t, shell.ScriptPrefix, prefix[1:])
+
+	// Shells that aren't known to support printing commands are left alone
+	shell = ResolveShell(OSLinux, shellPtr("fish"))
+	require.Empty(t, shell.WithEcho(&on).ScriptPrefix)
+}
@@ -16,6 +16,7 @@ import (
 
 const (
 	DefaultPollInterval   = time.Second * 5
+	DefaultDequeueWait    = time.Second * 20
 	DefaultParallelBuilds = 0
 	pollTimeout           = time.Second * 30
 	buildTimeout          = time.Hour * 2
@@ -39,6 +40,19 @@ func getCleanupContext() (context.Context, context.CancelFunc) {
 type SchedulerConfig struct {
 	ParallelJobs int
 	PollInterval time.Duration
+	// DequeueWait is how long to ask the server to wait for a job to become ready when polling, so that new
+	// jobs are picked up as soon as they are queued rather than at the next poll. Zero to not wait.
+	DequeueWait time.Duration
+	// SupportedJobTypes is the set of job types to advertise to the server. Defaults to the built-in job
+	// types if empty.
+	SupportedJobTypes models.JobTypes
+	// Capacity is the CPU, memory, disk space and GPUs to advertise to the server as available for jobs. The
+	// server only gives the runner jobs whose resource requirements fit within the capacity its other jobs
+	// aren't using. Resources left as zero are not limited, except for GPUs.
+	Capacity models.Resources
+	// ExecJobAllowlist lists the repos whose jobs may use the exec job type, to advertise to the server so that
+	// it only gives the runner exec jobs it will run. If nil then jobs from any repo may use it.
+	ExecJobAllowlist models.ExecJobAllowlist
 }
 
 type pollResult struct {
@@ -67,7 +81,9 @@ type Scheduler struct {
 	config     SchedulerConfig
 	stats      models.RunnerStats
 	statsMutex sync.RWMutex
-	log        logger.Log
+	// runningJobs is the orchestrator running each job currently being run, protected by statsMutex
+	runningJobs map[models.JobID]*Orchestrator
+	log         logger.Log
 }
 
 func NewJobScheduler(
@@ -94,6 +110,7 @@ func NewJobScheduler(
 		mu:                  sync.Mutex{},
 		wg:                  sync.WaitGroup{},
 		config:              config,
+		runningJobs:         make(map[models.JobID]*Orchestrator),
 		log:                 log,
 	}
 }
@@ -165,6 +182,18 @@ func (s *Scheduler) GetStats() *models.RunnerStats {
 	return &statsCopy
 }
 
+// RunningJobIDs returns the IDs of the jobs currently being run.
+func (s *Scheduler) RunningJobIDs() []models.JobID {
+	s.statsMutex.RLock()
+	defer s.statsMutex.RUnlock()
+
+	jobIDs := make([]models.JobID, 0, len(s.runningJobs))
+	for jobID := range s.runningJobs {
+		jobIDs = append(jobIDs, jobID)
+	}
+	return jobIDs
+}
+
 func (s *Scheduler) loop() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -196,7 +225,7 @@ func (s *Scheduler) loop() {
 		// TODO proper timer
 		var pollTimer <-chan time.Time
 		if !s.state.exiting && !s.state.polling && s.state.runningJobs < s.config.ParallelJobs {
-			pollTimer = time.After(s.config.PollInterval)
+			pollTimer = time.After(s.nextPollDelay())
 		}
 
 		select {
@@ -228,17 +257,31 @@ func (s *Scheduler) loop() {
 	}
 }
 
+// nextPollDelay returns how long to wait before polling the server again. When the server is asked to wait for
+// jobs, a poll that took longer than the poll interval means the server waited, so the next poll starts straight
+// away; otherwise (e.g. the server doesn't support waiting, or returned an error) polls are spaced by the poll interval.
+func (s *Scheduler) nextPollDelay() time.Duration {
+	if s.config.DequeueWait <= 0 {
+		return s.config.PollInterval
+	}
+	delay := s.config.PollInterval - time.Since(s.state.lastPollStarted)
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
 func (s *Scheduler) poll(ctx context.Context) {
 	if s.state.polling {
 		s.log.Panic("Expected polling to be false")
 	}
 	s.state.lastPollStarted = time.Now()
 	s.state.polling = true
-	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout+s.config.DequeueWait)
 	go func() {
 		defer cancel()
 		if s.state.runtimeInfoSent {
-			job, err := s.client.Dequeue(ctx)
+			job, err := s.client.Dequeue(ctx, s.config.DequeueWait)
 			s.pollResultChan <- &pollResult{
 				err: err,
 				job: job,
@@ -259,7 +302,7 @@ func (s *Scheduler) handlePollResult(ctx context.Context, res *pollResult) {
 	s.state.polling = false
 	if res.err != nil {
 		s.recordFailedPoll()
-		if !gerror.IsNotFound(res.err) && !gerror.IsRunnerDisabled(res.err) {
+		if !gerror.IsNotFound(res.err) && !gerror.IsRunnerDisabled(res.err) && !gerror.IsRunnerUnhealthy(res.err) {
 			s.log.Errorf("Will retry error during poll: %s", res.err)
 		}
 		return
@@ -275,9 +318,12 @@ func (s *Scheduler) handlePollResult(ctx context.Context, res *pollResult) {
 			s.log.Panicf("s.state.runningJobs > %d", s.config.ParallelJobs)
 		}
 		s.log.Infof("Running job %s; %d jobs(s) now in progress", res.job.Job.ID, s.state.runningJobs)
+		jobID := res.job.Job.ID
+		runner := s.orchestratorFactory()
+		s.setJobRunning(jobID, runner)
 		go func() {
-			runner := s.orchestratorFactory()
 			runner.Run(res.job)
+			s.setJobRunning(jobID, nil)
 			s.jobCompleteC <- true
 		}()
 		if s.state.runningJobs < s.config.ParallelJobs {
@@ -287,6 +333,30 @@ func (s *Scheduler) handlePollResult(ctx context.Context, res *pollResult) {
 	}
 }
 
+// CancelJob stops running the specified job as soon as possible. Returns false if the job is not being run.
+func (s *Scheduler) CancelJob(jobID models.JobID) bool {
+	s.statsMutex.RLock()
+	defer s.statsMutex.RUnlock()
+	runner, ok := s.runningJobs[jobID]
+	if !ok {
+		return false
+	}
+	runner.Cancel()
+	return true
+}
+
+// setJobRunning records the orchestrator running the specified job, or that the job is no longer
+// being run if orchestrator is nil.
+func (s *Scheduler) setJobRunning(jobID models.JobID, orchestrator *Orchestrator) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	if orchestrator != nil {
+		s.runningJobs[jobID] = orchestrator
+	} else {
+		delete(s.runningJobs, jobID)
+	}
+}
+
 func (s *Scheduler) recordSuccessfulPoll() {
 	s.statsMutex.Lock()
 	defer s.statsMutex.Unlock()
@@ -304,19 +374,24 @@ func (s *Scheduler) sendRuntimeInfo(ctx context.Context) error {
 		os                = string(runtime2.GetHostOS())
 		arch              = runtime.GOARCH
 		softwareVersion   = version.VERSION
-		supportedJobKinds = models.JobTypes{models.JobTypeDocker, models.JobTypeExec}
+		supportedJobKinds = s.config.SupportedJobTypes
 	)
+	if len(supportedJobKinds) == 0 {
+		supportedJobKinds = models.JobTypes{models.JobTypeDocker, models.JobTypeExec}
+	}
 	info := &documents.PatchRuntimeInfoRequest{
 		SoftwareVersion:   &softwareVersion,
 		OperatingSystem:   &os,
 		Architecture:      &arch,
 		SupportedJobTypes: &supportedJobKinds,
+		Capacity:          &s.config.Capacity,
+		ExecJobAllowlist:  s.config.ExecJobAllowlist,
 	}
 	err := s.client.SendRuntimeInfo(ctx, info)
 	if err != nil {
 		return err
 	}
-	s.log.Infof("Sent runtime info to server: Software version: %s, Operating System: %s, Architecture: %s, Supported Job Types: %v\n",
-		softwareVersion, os, arch, supportedJobKinds)
+	s.log.Infof("Sent runtime info to server: Software version: %s, Operating System: %s, Architecture: %s, Supported Job Types: %v, Capacity: %s\n",
+		softwareVersion, os, arch, supportedJobKinds, s.config.Capacity)
 	return nil
 }
@@ -11,14 +11,18 @@ import (
 
 type SecretStore struct {
 	repoID                models.RepoID
+	pinToBuildID          *models.BuildID
 	apiClient             APIClient
 	secretsPlaintext      []*models.SecretPlaintext
 	secretsPlaintextByKey map[string]*models.SecretPlaintext
 }
 
-func NewSecretStore(apiClient APIClient, repoID models.RepoID) *SecretStore {
+// NewSecretStore makes a store for the secrets of the specified repo. If pinToBuildID is set the store will
+// hold the secrets as they were when that build started, rather than the latest versions.
+func NewSecretStore(apiClient APIClient, repoID models.RepoID, pinToBuildID *models.BuildID) *SecretStore {
 	return &SecretStore{
 		repoID:                repoID,
+		pinToBuildID:          pinToBuildID,
 		apiClient:             apiClient,
 		secretsPlaintextByKey: map[string]*models.SecretPlaintext{},
 	}
@@ -30,7 +34,7 @@ func NewSecretStore(apiClient APIClient, repoID models.RepoID) *SecretStore {
 //
 //	encrypted with the runner's public key (which we will need to subsequently decrypt)
 func (b *SecretStore) Init(ctx context.Context) error {
-	secrets, err := b.apiClient.GetSecretsPlaintext(ctx, b.repoID)
+	secrets, err := b.apiClient.GetSecretsPlaintext(ctx, b.repoID, b.pinToBuildID)
 	if err != nil {
 		return errors.Wrap(err, "error getting secrets")
 	}
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// ServiceOverride replaces parts of the configuration of a service declared in a build definition, so that
+// jobs can be run unchanged on machines where the declared configuration doesn't suit (e.g. to use a locally
+// built image, or to make the service reachable from the host).
+type ServiceOverride struct {
+	// Image replaces the Docker image the service is run from, if not empty.
+	Image string `yaml:"image"`
+	// Ports is a list of the service's ports to publish on the host, each in the
+	// form [ip:]hostPort:containerPort[/protocol] used by Docker.
+	Ports []string `yaml:"ports"`
+	// Environment contains environment variables to add to the service's environment, replacing
+	// any variables of the same name declared in the build definition.
+	Environment map[string]string `yaml:"environment"`
+}
+
+// ServiceOverrides maps service names to the override to apply to each service with that name.
+type ServiceOverrides map[string]*ServiceOverride
+
+// serviceOverridesFile is the format of a service overrides file, which follows the layout of the services
+// section of a docker-compose file.
+type serviceOverridesFile struct {
+	Services ServiceOverrides `yaml:"services"`
+}
+
+// LoadServiceOverrides reads and validates a set of service overrides from a YAML file in the format
+// of the services section of a docker-compose file, e.g.
+//
+//	services:
+//	  postgres:
+//	    image: postgres:14-alpine
+//	    ports:
+//	      - "5432:5432"
+//	    environment:
+//	      POSTGRES_PASSWORD: password
+func LoadServiceOverrides(filePath string) (ServiceOverrides, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service overrides file %q: %w", filePath, err)
+	}
+	return ParseServiceOverrides(data)
+}
+
+// ParseServiceOverrides parses and validates a set of service overrides in the format read by LoadServiceOverrides.
+func ParseServiceOverrides(data []byte) (ServiceOverrides, error) {
+	file := &serviceOverridesFile{}
+	err := yaml.Unmarshal(data, file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing service overrides: %w", err)
+	}
+	for name, override := range file.Services {
+		if override == nil {
+			return nil, fmt.Errorf("error override for service %q is empty", name)
+		}
+		_, _, err = nat.ParsePortSpecs(override.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing ports for service %q: %w", name, err)
+		}
+		for envName := range override.Environment {
+			if !envVarNameRegex.MatchString(envName) {
+				return nil, fmt.Errorf("error invalid environment variable name %q for service %q", envName, name)
+			}
+		}
+	}
+	return file.Services, nil
+}
+
+// Get returns the override for the named service, or nil if the service is not overridden.
+func (o ServiceOverrides) Get(serviceName string) *ServiceOverride {
+	if o == nil {
+		return nil
+	}
+	return o[serviceName]
+}
+
+// EnvMappings returns the override's environment variables as NAME=value mappings, sorted by name.
+func (o *ServiceOverride) EnvMappings() []string {
+	names := make([]string, 0, len(o.Environment))
+	for name := range o.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mappings := make([]string, 0, len(names))
+	for _, name := range names {
+		mappings = append(mappings, fmt.Sprintf("%s=%s", name, o.Environment[name]))
+	}
+	return mappings
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServiceOverrides(t *testing.T) {
+	data := `
+services:
+  postgres:
+    image: postgres:14-alpine
+    ports:
+      - "5433:5432"
+    environment:
+      POSTGRES_USER: buildbeaver
+      POSTGRES_PASSWORD: password
+  redis:
+    ports: ["127.0.0.1:6379:6379/tcp"]
+`
+	overrides, err := ParseServiceOverrides([]byte(data))
+	require.Nil(t, err)
+	require.Len(t, overrides, 2)
+
+	postgres := overrides.Get("postgres")
+	require.NotNil(t, postgres)
+	require.Equal(t, "postgres:14-alpine", postgres.Image)
+	require.Equal(t, []string{"5433:5432"}, postgres.Ports)
+	require.Equal(t, []string{"POSTGRES_PASSWORD=password", "POSTGRES_USER=buildbeaver"}, postgres.EnvMappings())
+
+	redis := overrides.Get("redis")
+	require.NotNil(t, redis)
+	require.Empty(t, redis.Image)
+	require.Empty(t, redis.EnvMappings())
+
+	require.Nil(t, overrides.Get("mysql"))
+	require.Nil(t, ServiceOverrides(nil).Get("postgres"))
+
+	for _, invalid := range []string{
+		"services: [postgres]",
+		"services:\n  postgres:\n",
+		"services:\n  postgres:\n    ports: [\"not-a-port\"]\n",
+		"services:\n  postgres:\n    environment:\n      1BAD: value\n",
+	} {
+		_, err = ParseServiceOverrides([]byte(invalid))
+		require.NotNil(t, err, "expected error parsing %q", invalid)
+	}
+}
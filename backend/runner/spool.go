@@ -0,0 +1,249 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// RunnerSpoolDirectory is a value specifying the local directory in which the runner spools status updates,
+// logs and artifacts that could not be sent to the server, until they can be replayed.
+type RunnerSpoolDirectory string
+
+const (
+	spoolRecordFileExt = ".json"
+	spoolDataFileExt   = ".data"
+	spoolTempFileExt   = ".tmp"
+	// spoolSeqNoDigits is the number of digits in spool file names, so that names sort in sequence number order
+	spoolSeqNoDigits = 20
+)
+
+type SpoolRecordKind string
+
+const (
+	SpoolRecordKindJobStatus  SpoolRecordKind = "job_status"
+	SpoolRecordKindStepStatus SpoolRecordKind = "step_status"
+	SpoolRecordKindLogChunk   SpoolRecordKind = "log_chunk"
+	SpoolRecordKindArtifact   SpoolRecordKind = "artifact"
+)
+
+// SpoolRecord is a single operation waiting in the spool to be replayed against the server.
+// Log chunks and artifacts have their data stored in a separate file alongside the record.
+type SpoolRecord struct {
+	SeqNo     int64           `json:"seq_no"`
+	Kind      SpoolRecordKind `json:"kind"`
+	CreatedAt time.Time       `json:"created_at"`
+	// JobID is set for job status updates and artifacts
+	JobID models.JobID `json:"job_id"`
+	// StepID is set for step status updates
+	StepID models.StepID `json:"step_id"`
+	// Status, Error and ETag are set for job and step status updates
+	Status models.WorkflowStatus `json:"status,omitempty"`
+	Error  *models.Error         `json:"error,omitempty"`
+	ETag   models.ETag           `json:"etag,omitempty"`
+	// LogDescriptorID is set for log chunks
+	LogDescriptorID models.LogDescriptorID `json:"log_descriptor_id"`
+	// GroupName and Path are set for artifacts
+	GroupName models.ResourceName `json:"group_name,omitempty"`
+	Path      string              `json:"path,omitempty"`
+}
+
+// Spool is an ordered, persistent queue of operations that could not be sent to the server. Records are
+// stored as one file per record in the spool directory, named by sequence number, so the spool survives
+// a restart of the runner and records are always replayed in the order they were added.
+type Spool struct {
+	dir   string
+	mu    sync.Mutex
+	log   logger.Log
+	state struct {
+		nextSeqNo int64
+		pending   []int64 // sequence numbers of records in the spool, oldest first
+	}
+}
+
+// NewSpool creates a spool in the specified directory, loading any records left over from a previous
+// instance of the runner.
+func NewSpool(dir RunnerSpoolDirectory, logFactory logger.LogFactory) (*Spool, error) {
+	s := &Spool{
+		dir: string(dir),
+		log: logFactory("Spool"),
+	}
+	s.state.nextSeqNo = 1
+	err := os.MkdirAll(s.dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating spool directory: %w", err)
+	}
+	err = s.load()
+	if err != nil {
+		return nil, fmt.Errorf("error loading spool: %w", err)
+	}
+	if len(s.state.pending) > 0 {
+		s.log.Infof("Loaded %d spooled record(s) waiting to be replayed", len(s.state.pending))
+	}
+	return s, nil
+}
+
+// load finds the records already in the spool directory, and removes data and temporary files that
+// are not part of a complete record (e.g. left behind if the runner stopped part way through adding a record).
+func (s *Spool) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("error reading spool directory: %w", err)
+	}
+	records := make(map[int64]bool)
+	var orphans []string
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		seqNo, err := strconv.ParseInt(strings.TrimSuffix(name, ext), 10, 64)
+		if err != nil || entry.IsDir() {
+			s.log.Warnf("Ignoring unexpected file in spool directory: %s", name)
+			continue
+		}
+		if seqNo >= s.state.nextSeqNo {
+			s.state.nextSeqNo = seqNo + 1
+		}
+		switch ext {
+		case spoolRecordFileExt:
+			records[seqNo] = true
+		case spoolDataFileExt, spoolTempFileExt:
+			orphans = append(orphans, name)
+		}
+	}
+	for _, name := range orphans {
+		ext := filepath.Ext(name)
+		seqNo, _ := strconv.ParseInt(strings.TrimSuffix(name, ext), 10, 64)
+		if ext == spoolDataFileExt && records[seqNo] {
+			continue
+		}
+		s.log.Warnf("Removing incomplete spool file: %s", name)
+		err = os.Remove(filepath.Join(s.dir, name))
+		if err != nil {
+			return fmt.Errorf("error removing incomplete spool file: %w", err)
+		}
+	}
+	for seqNo := range records {
+		s.state.pending = append(s.state.pending, seqNo)
+	}
+	sort.Slice(s.state.pending, func(i, j int) bool { return s.state.pending[i] < s.state.pending[j] })
+	return nil
+}
+
+// Len returns the number of records waiting in the spool.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.state.pending)
+}
+
+// Append adds a record to the end of the spool. If data is not nil it is stored alongside the record
+// and can be read back using OpenData. The record's sequence number is assigned by the spool.
+func (s *Spool) Append(record *SpoolRecord, data io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.SeqNo = s.state.nextSeqNo
+	s.state.nextSeqNo++
+	if data != nil {
+		err := s.writeFile(s.path(record.SeqNo, spoolDataFileExt), data)
+		if err != nil {
+			return fmt.Errorf("error writing spool data: %w", err)
+		}
+	}
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error marshalling spool record: %w", err)
+	}
+	// The record file is written last, so a record is only loaded after a restart if it is complete
+	err = s.writeFile(s.path(record.SeqNo, spoolRecordFileExt), bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("error writing spool record: %w", err)
+	}
+	s.state.pending = append(s.state.pending, record.SeqNo)
+	s.log.Infof("Spooled %s record %d", record.Kind, record.SeqNo)
+	return nil
+}
+
+// Peek returns the oldest record in the spool, or nil if the spool is empty.
+func (s *Spool) Peek() (*SpoolRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.state.pending) == 0 {
+		return nil, nil
+	}
+	buf, err := os.ReadFile(s.path(s.state.pending[0], spoolRecordFileExt))
+	if err != nil {
+		return nil, fmt.Errorf("error reading spool record: %w", err)
+	}
+	record := &SpoolRecord{}
+	err = json.Unmarshal(buf, record)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling spool record: %w", err)
+	}
+	return record, nil
+}
+
+// OpenData opens the data stored alongside a record. The caller is responsible for closing the returned file.
+func (s *Spool) OpenData(record *SpoolRecord) (*os.File, error) {
+	return os.Open(s.path(record.SeqNo, spoolDataFileExt))
+}
+
+// Remove removes the oldest record from the spool, along with its data. The record must be the record
+// most recently returned by Peek.
+func (s *Spool) Remove(record *SpoolRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.state.pending) == 0 || s.state.pending[0] != record.SeqNo {
+		return fmt.Errorf("error spool record %d is not the oldest record in the spool", record.SeqNo)
+	}
+	err := os.Remove(s.path(record.SeqNo, spoolRecordFileExt))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing spool record: %w", err)
+	}
+	err = os.Remove(s.path(record.SeqNo, spoolDataFileExt))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing spool data: %w", err)
+	}
+	s.state.pending = s.state.pending[1:]
+	return nil
+}
+
+// writeFile writes data to a temporary file then renames it into place, so that a partially written
+// file is never mistaken for a complete one.
+func (s *Spool) writeFile(path string, data io.Reader) error {
+	tempPath := strings.TrimSuffix(path, filepath.Ext(path)) + spoolTempFileExt
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func (s *Spool) path(seqNo int64, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%0*d%s", spoolSeqNoDigits, seqNo, ext))
+}
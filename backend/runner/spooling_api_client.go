@@ -0,0 +1,542 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+const (
+	// spoolReplayMinBackoff and spoolReplayMaxBackoff bound the delay between attempts to replay the spool
+	// while the server is unreachable.
+	spoolReplayMinBackoff = time.Second * 5
+	spoolReplayMaxBackoff = time.Minute * 2
+	// spoolReplayTimeout is the maximum time to spend replaying a single spooled record.
+	spoolReplayTimeout = time.Minute * 5
+)
+
+// SpoolingAPIClient wraps an APIClient so that job and step status updates, log chunks and artifacts are
+// written to a local spool if the server is unreachable, rather than being lost. Spooled records are replayed
+// in order in the background once the server can be contacted again. While any records are waiting in the
+// spool, later updates are spooled behind them so the server always sees updates in the order they were made.
+//
+// Spooled status updates return a document built from the last known state of the job or step, so the
+// orchestrator can carry on running the job while the server is unreachable. ETags handed out in these
+// documents are translated to the server's current ETag when the update is eventually sent.
+type SpoolingAPIClient struct {
+	APIClient
+	spool      *Spool
+	log        logger.Log
+	replayWake chan bool
+	replayMu   sync.Mutex // held while replaying, so records are sent to the server exactly in order
+	mu         sync.Mutex // protects state
+	state      struct {
+		jobs  map[models.JobID]*documents.Job
+		steps map[models.StepID]*documents.Step
+		// eTags maps ETags handed out in documents returned for spooled updates to the ETag the server
+		// returned when the update was replayed
+		eTags    map[models.ETag]models.ETag
+		exitChan chan bool
+		wg       sync.WaitGroup
+	}
+}
+
+func NewSpoolingAPIClient(client APIClient, spool *Spool, logFactory logger.LogFactory) *SpoolingAPIClient {
+	c := &SpoolingAPIClient{
+		APIClient:  client,
+		spool:      spool,
+		log:        logFactory("SpoolingAPIClient"),
+		replayWake: make(chan bool, 1),
+	}
+	c.state.jobs = make(map[models.JobID]*documents.Job)
+	c.state.steps = make(map[models.StepID]*documents.Step)
+	c.state.eTags = make(map[models.ETag]models.ETag)
+	return c
+}
+
+// Start replaying spooled records in the background.
+func (c *SpoolingAPIClient) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state.exitChan != nil {
+		return
+	}
+	c.state.exitChan = make(chan bool)
+	c.state.wg.Add(1)
+	go func(exitChan chan bool) {
+		defer c.state.wg.Done()
+		c.replayLoop(exitChan)
+	}(c.state.exitChan)
+}
+
+// Stop replaying spooled records. Any records still in the spool will be replayed next time the runner starts.
+func (c *SpoolingAPIClient) Stop() {
+	c.mu.Lock()
+	if c.state.exitChan == nil {
+		c.mu.Unlock()
+		return
+	}
+	close(c.state.exitChan)
+	c.state.exitChan = nil
+	c.mu.Unlock()
+	c.state.wg.Wait()
+}
+
+// Dequeue returns the next build job that is ready to be executed, or nil if there are currently no queued builds.
+func (c *SpoolingAPIClient) Dequeue(ctx context.Context, wait time.Duration) (*documents.RunnableJob, error) {
+	runnable, err := c.APIClient.Dequeue(ctx, wait)
+	if err != nil || runnable == nil {
+		return runnable, err
+	}
+	c.rememberJob(runnable.Job)
+	for _, step := range runnable.Steps {
+		c.rememberStep(step)
+	}
+	return runnable, nil
+}
+
+// UpdateJobStatus updates the status of the specified job, spooling the update if the server can't be reached.
+func (c *SpoolingAPIClient) UpdateJobStatus(
+	ctx context.Context,
+	jobID models.JobID,
+	status models.WorkflowStatus,
+	jobError *models.Error,
+	eTag models.ETag) (*documents.Job, error) {
+
+	if c.spool.Len() == 0 {
+		jobDoc, err := c.APIClient.UpdateJobStatus(ctx, jobID, status, jobError, c.translateETag(eTag))
+		if err == nil {
+			c.rememberJob(jobDoc)
+			return jobDoc, nil
+		}
+		if !isServerUnreachable(err) {
+			return nil, err
+		}
+		c.log.Warnf("Server unreachable updating status for job %q; spooling update: %s", jobID, err)
+	}
+	jobDoc := c.lastKnownJob(jobID)
+	if jobDoc == nil {
+		return nil, fmt.Errorf("error unable to spool status update for unknown job %q", jobID)
+	}
+	err := c.append(&SpoolRecord{
+		Kind:   SpoolRecordKindJobStatus,
+		JobID:  jobID,
+		Status: status,
+		Error:  jobError,
+		ETag:   eTag,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	jobDoc.Status = status
+	jobDoc.Error = jobError
+	jobDoc.ETag = eTag
+	c.rememberJob(jobDoc)
+	return jobDoc, nil
+}
+
+// UpdateJobFingerprint sets the fingerprint that has been calculated for a job. Any spooled updates are
+// replayed first, since the server's response determines whether the job should run.
+func (c *SpoolingAPIClient) UpdateJobFingerprint(
+	ctx context.Context,
+	jobID models.JobID,
+	jobFingerprint string,
+	jobFingerprintHashType *models.HashType,
+	eTag models.ETag) (*documents.Job, error) {
+
+	err := c.replayAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error replaying spooled updates: %w", err)
+	}
+	jobDoc, err := c.APIClient.UpdateJobFingerprint(ctx, jobID, jobFingerprint, jobFingerprintHashType, c.translateETag(eTag))
+	if err != nil {
+		return nil, err
+	}
+	c.rememberJob(jobDoc)
+	return jobDoc, nil
+}
+
+// UpdateStepStatus updates the status of the specified step, spooling the update if the server can't be reached.
+func (c *SpoolingAPIClient) UpdateStepStatus(
+	ctx context.Context,
+	stepID models.StepID,
+	status models.WorkflowStatus,
+	stepError *models.Error,
+	eTag models.ETag) (*documents.Step, error) {
+
+	if c.spool.Len() == 0 {
+		stepDoc, err := c.APIClient.UpdateStepStatus(ctx, stepID, status, stepError, c.translateETag(eTag))
+		if err == nil {
+			c.rememberStep(stepDoc)
+			return stepDoc, nil
+		}
+		if !isServerUnreachable(err) {
+			return nil, err
+		}
+		c.log.Warnf("Server unreachable updating status for step %q; spooling update: %s", stepID, err)
+	}
+	stepDoc := c.lastKnownStep(stepID)
+	if stepDoc == nil {
+		return nil, fmt.Errorf("error unable to spool status update for unknown step %q", stepID)
+	}
+	err := c.append(&SpoolRecord{
+		Kind:   SpoolRecordKindStepStatus,
+		StepID: stepID,
+		Status: status,
+		Error:  stepError,
+		ETag:   eTag,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	stepDoc.Status = status
+	stepDoc.Error = stepError
+	stepDoc.ETag = eTag
+	c.rememberStep(stepDoc)
+	return stepDoc, nil
+}
+
+// CreateArtifact creates a new artifact with its contents provided by reader, spooling a copy of the artifact
+// if the server can't be reached. It is the caller's responsibility to close reader.
+func (c *SpoolingAPIClient) CreateArtifact(
+	ctx context.Context,
+	jobID models.JobID,
+	groupName models.ResourceName,
+	relativePath string,
+	reader io.ReadSeeker) (*documents.Artifact, error) {
+
+	if c.spool.Len() == 0 {
+		artifact, err := c.APIClient.CreateArtifact(ctx, jobID, groupName, relativePath, reader)
+		if err == nil || !isServerUnreachable(err) {
+			return artifact, err
+		}
+		c.log.Warnf("Server unreachable creating artifact %q; spooling artifact: %s", relativePath, err)
+		_, err = reader.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, fmt.Errorf("error rewinding artifact data: %w", err)
+		}
+	}
+	err := c.append(&SpoolRecord{
+		Kind:      SpoolRecordKindArtifact,
+		JobID:     jobID,
+		GroupName: groupName,
+		Path:      relativePath,
+	}, reader)
+	if err != nil {
+		return nil, err
+	}
+	// The artifact has not been created on the server yet, so return what we know about it
+	return &documents.Artifact{
+		JobID:     jobID,
+		GroupName: groupName,
+		Path:      relativePath,
+	}, nil
+}
+
+// OpenLogWriteStream opens a writable stream to the specified log. Close the writer to finish writing.
+// If the server can't be reached when the stream is closed, the data written to the stream is spooled
+// and Close reports success.
+func (c *SpoolingAPIClient) OpenLogWriteStream(ctx context.Context, logID models.LogDescriptorID) (io.WriteCloser, error) {
+	writer := &spoolingLogWriter{client: c, logID: logID}
+	if c.spool.Len() == 0 {
+		stream, err := c.APIClient.OpenLogWriteStream(ctx, logID)
+		if err != nil && !isServerUnreachable(err) {
+			return nil, err
+		}
+		writer.stream = stream
+	}
+	return writer, nil
+}
+
+// spoolingLogWriter writes log data to a stream to the server, keeping a copy of the data so that it can be
+// spooled if the stream fails because the server is unreachable. If stream is nil then all data is spooled.
+// The log streamer limits the amount of data written to each stream, so keeping a copy in memory is fine.
+type spoolingLogWriter struct {
+	client    *SpoolingAPIClient
+	logID     models.LogDescriptorID
+	stream    io.WriteCloser
+	streamErr error
+	buf       bytes.Buffer
+}
+
+func (w *spoolingLogWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if w.stream != nil && w.streamErr == nil {
+		_, err := w.stream.Write(p)
+		if err != nil {
+			// Carry on collecting data; the error returned from the stream's Close decides whether to spool it
+			w.streamErr = err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush flushes the stream to the server, if the stream supports flushing.
+func (w *spoolingLogWriter) Flush() {
+	if flusher, ok := w.stream.(http.Flusher); ok && w.streamErr == nil {
+		flusher.Flush()
+	}
+}
+
+func (w *spoolingLogWriter) Close() error {
+	if w.stream != nil {
+		err := w.stream.Close()
+		if err == nil {
+			err = w.streamErr
+		}
+		if err == nil || !isServerUnreachable(err) {
+			return err
+		}
+		w.client.log.Warnf("Server unreachable writing to log %q; spooling log data: %s", w.logID, err)
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	return w.client.append(&SpoolRecord{
+		Kind:            SpoolRecordKindLogChunk,
+		LogDescriptorID: w.logID,
+	}, &w.buf)
+}
+
+// append adds a record to the spool and wakes up the replay loop.
+func (c *SpoolingAPIClient) append(record *SpoolRecord, data io.Reader) error {
+	record.CreatedAt = time.Now().UTC()
+	err := c.spool.Append(record, data)
+	if err != nil {
+		return fmt.Errorf("error spooling %s: %w", record.Kind, err)
+	}
+	c.wakeReplay()
+	return nil
+}
+
+func (c *SpoolingAPIClient) wakeReplay() {
+	select {
+	case c.replayWake <- true:
+	default:
+	}
+}
+
+// replayLoop replays spooled records until exitChan is closed, backing off while the server is unreachable.
+func (c *SpoolingAPIClient) replayLoop(exitChan chan bool) {
+	backoff := spoolReplayMinBackoff
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-exitChan:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := c.replayAll(ctx)
+		cancel()
+
+		wait := backoff
+		if err != nil {
+			c.log.Warnf("Unable to replay spooled records (%d waiting), will retry in %s: %s", c.spool.Len(), backoff, err)
+			backoff *= 2
+			if backoff > spoolReplayMaxBackoff {
+				backoff = spoolReplayMaxBackoff
+			}
+		} else {
+			backoff = spoolReplayMinBackoff
+			wait = spoolReplayMaxBackoff // nothing to do until woken
+		}
+		select {
+		case <-exitChan:
+			return
+		case <-c.replayWake:
+			if err != nil {
+				// Don't hammer an unreachable server each time another record is spooled
+				select {
+				case <-exitChan:
+					return
+				case <-time.After(wait):
+				}
+			}
+		case <-time.After(wait):
+		}
+	}
+}
+
+// replayAll replays spooled records in order until the spool is empty, or returns an error if the server
+// is unreachable. Records the server rejects are logged and discarded, since they will never succeed.
+func (c *SpoolingAPIClient) replayAll(ctx context.Context) error {
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+
+	for ctx.Err() == nil {
+		record, err := c.spool.Peek()
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return nil
+		}
+		err = c.replay(ctx, record)
+		if err != nil {
+			if ctx.Err() != nil || isServerUnreachable(err) {
+				return err
+			}
+			c.log.Errorf("Discarding spooled %s record %d after it was rejected by the server: %s", record.Kind, record.SeqNo, err)
+		} else {
+			c.log.Infof("Replayed spooled %s record %d", record.Kind, record.SeqNo)
+		}
+		err = c.spool.Remove(record)
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// replay sends a single spooled record to the server. The caller must hold c.replayMu.
+func (c *SpoolingAPIClient) replay(ctx context.Context, record *SpoolRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, spoolReplayTimeout)
+	defer cancel()
+
+	switch record.Kind {
+	case SpoolRecordKindJobStatus:
+		jobDoc, err := c.APIClient.UpdateJobStatus(ctx, record.JobID, record.Status, record.Error, c.translateETag(record.ETag))
+		if err != nil {
+			return err
+		}
+		c.setETagTranslation(record.ETag, jobDoc.ETag)
+		return nil
+
+	case SpoolRecordKindStepStatus:
+		stepDoc, err := c.APIClient.UpdateStepStatus(ctx, record.StepID, record.Status, record.Error, c.translateETag(record.ETag))
+		if err != nil {
+			return err
+		}
+		c.setETagTranslation(record.ETag, stepDoc.ETag)
+		return nil
+
+	case SpoolRecordKindLogChunk:
+		data, err := c.spool.OpenData(record)
+		if err != nil {
+			return fmt.Errorf("error opening spooled log data: %w", err)
+		}
+		defer data.Close()
+		stream, err := c.APIClient.OpenLogWriteStream(ctx, record.LogDescriptorID)
+		if err != nil {
+			return err
+		}
+		_, copyErr := io.Copy(stream, data)
+		err = stream.Close()
+		if err == nil {
+			err = copyErr
+		}
+		return err
+
+	case SpoolRecordKindArtifact:
+		data, err := c.spool.OpenData(record)
+		if err != nil {
+			return fmt.Errorf("error opening spooled artifact data: %w", err)
+		}
+		defer data.Close()
+		_, err = c.APIClient.CreateArtifact(ctx, record.JobID, record.GroupName, record.Path, data)
+		if err != nil && gerror.IsAlreadyExists(err) {
+			return nil // the artifact was created before the connection to the server was lost
+		}
+		return err
+
+	default:
+		return fmt.Errorf("error unknown spool record kind: %q", record.Kind)
+	}
+}
+
+// translateETag returns the server's current ETag for a resource, given an ETag that may have been handed
+// out in a document returned for a spooled update.
+func (c *SpoolingAPIClient) translateETag(eTag models.ETag) models.ETag {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if translated, ok := c.state.eTags[eTag]; ok {
+		return translated
+	}
+	return eTag
+}
+
+// setETagTranslation records that a spooled update sent with handedOutETag resulted in the server's ETag
+// for the resource changing to serverETag.
+func (c *SpoolingAPIClient) setETagTranslation(handedOutETag models.ETag, serverETag models.ETag) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.eTags[handedOutETag] = serverETag
+}
+
+func (c *SpoolingAPIClient) rememberJob(job *documents.Job) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if job.Status.HasFinished() {
+		// Nothing further will be reported for the job or its steps
+		delete(c.state.jobs, job.ID)
+		for id, step := range c.state.steps {
+			if step.JobID == job.ID {
+				delete(c.state.steps, id)
+			}
+		}
+		return
+	}
+	c.state.jobs[job.ID] = job
+}
+
+func (c *SpoolingAPIClient) rememberStep(step *documents.Step) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.steps[step.ID] = step
+}
+
+// lastKnownJob returns a copy of the most recent document for a job, or nil if the job is not known.
+func (c *SpoolingAPIClient) lastKnownJob(jobID models.JobID) *documents.Job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job, ok := c.state.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	jobCopy := *job
+	return &jobCopy
+}
+
+// lastKnownStep returns a copy of the most recent document for a step, or nil if the step is not known.
+func (c *SpoolingAPIClient) lastKnownStep(stepID models.StepID) *documents.Step {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	step, ok := c.state.steps[stepID]
+	if !ok {
+		return nil
+	}
+	stepCopy := *step
+	return &stepCopy
+}
+
+// isServerUnreachable returns true if err indicates the request may not have reached the server, so it is
+// worth spooling and trying again later. Errors returned by the server itself are final, except for
+// gateway errors from a proxy in front of a server that is down.
+func isServerUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var gErr gerror.Error
+	if errors.As(err, &gErr) {
+		switch gErr.HTTPStatusCode() {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+	return !errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,244 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+var errTestConnectionRefused = errors.New("dial tcp 127.0.0.1:3002: connect: connection refused")
+
+// fakeServerAPIClient records the calls it receives, and fails every call while offline.
+type fakeServerAPIClient struct {
+	APIClient
+	mu      sync.Mutex
+	offline bool
+	calls   []string
+	eTags   map[models.ResourceID]models.ETag
+	logs    map[models.LogDescriptorID]string
+}
+
+func newFakeServerAPIClient() *fakeServerAPIClient {
+	return &fakeServerAPIClient{
+		eTags: make(map[models.ResourceID]models.ETag),
+		logs:  make(map[models.LogDescriptorID]string),
+	}
+}
+
+func (f *fakeServerAPIClient) setOffline(offline bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.offline = offline
+}
+
+func (f *fakeServerAPIClient) getCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// update checks the ETag for a resource in the same way as the server, and returns the new ETag.
+func (f *fakeServerAPIClient) update(id models.ResourceID, eTag models.ETag, call string) (models.ETag, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.offline {
+		return "", errTestConnectionRefused
+	}
+	if current, ok := f.eTags[id]; ok && current != eTag {
+		return "", gerror.NewErrOptimisticLockFailed("Optimistic lock failed")
+	}
+	f.calls = append(f.calls, call)
+	newETag := models.ETag(fmt.Sprintf("etag-%d", len(f.calls)))
+	f.eTags[id] = newETag
+	return newETag, nil
+}
+
+func (f *fakeServerAPIClient) UpdateJobStatus(ctx context.Context, jobID models.JobID, status models.WorkflowStatus, jobError *models.Error, eTag models.ETag) (*documents.Job, error) {
+	newETag, err := f.update(jobID.ResourceID, eTag, "job:"+status.String())
+	if err != nil {
+		return nil, err
+	}
+	return &documents.Job{ID: jobID, Status: status, Error: jobError, ETag: newETag}, nil
+}
+
+func (f *fakeServerAPIClient) UpdateStepStatus(ctx context.Context, stepID models.StepID, status models.WorkflowStatus, stepError *models.Error, eTag models.ETag) (*documents.Step, error) {
+	newETag, err := f.update(stepID.ResourceID, eTag, "step:"+status.String())
+	if err != nil {
+		return nil, err
+	}
+	return &documents.Step{ID: stepID, Status: status, Error: stepError, ETag: newETag}, nil
+}
+
+func (f *fakeServerAPIClient) CreateArtifact(ctx context.Context, jobID models.JobID, groupName models.ResourceName, relativePath string, reader io.ReadSeeker) (*documents.Artifact, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.offline {
+		return nil, errTestConnectionRefused
+	}
+	f.calls = append(f.calls, fmt.Sprintf("artifact:%s:%s", relativePath, data))
+	return &documents.Artifact{JobID: jobID, GroupName: groupName, Path: relativePath}, nil
+}
+
+func (f *fakeServerAPIClient) OpenLogWriteStream(ctx context.Context, logID models.LogDescriptorID) (io.WriteCloser, error) {
+	return &fakeLogStream{server: f, logID: logID}, nil
+}
+
+type fakeLogStream struct {
+	server *fakeServerAPIClient
+	logID  models.LogDescriptorID
+	buf    bytes.Buffer
+}
+
+func (s *fakeLogStream) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+func (s *fakeLogStream) Close() error {
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	if s.server.offline {
+		return errTestConnectionRefused
+	}
+	s.server.calls = append(s.server.calls, "log:"+s.buf.String())
+	s.server.logs[s.logID] += s.buf.String()
+	return nil
+}
+
+func writeTestLog(t *testing.T, client APIClient, logID models.LogDescriptorID, data string) {
+	stream, err := client.OpenLogWriteStream(context.Background(), logID)
+	require.NoError(t, err)
+	_, err = stream.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+}
+
+func TestSpoolingAPIClient(t *testing.T) {
+	ctx := context.Background()
+	spoolDir := t.TempDir()
+	server := newFakeServerAPIClient()
+	spool, err := NewSpool(RunnerSpoolDirectory(spoolDir), logger.NoOpLogFactory)
+	require.NoError(t, err)
+	client := NewSpoolingAPIClient(server, spool, logger.NoOpLogFactory)
+
+	job := &documents.Job{ID: models.NewJobID(), Status: models.WorkflowStatusQueued, ETag: "job-etag"}
+	step := &documents.Step{ID: models.NewStepID(), JobID: job.ID, Status: models.WorkflowStatusQueued, ETag: "step-etag"}
+	server.eTags[job.ID.ResourceID] = job.ETag
+	server.eTags[step.ID.ResourceID] = step.ETag
+	client.rememberJob(job)
+	client.rememberStep(step)
+	logID := models.NewLogDescriptorID()
+
+	// Updates go straight to the server while it is reachable
+	jobDoc, err := client.UpdateJobStatus(ctx, job.ID, models.WorkflowStatusRunning, nil, job.ETag)
+	require.NoError(t, err)
+	writeTestLog(t, client, logID, "[1]")
+	require.Equal(t, 0, spool.Len())
+
+	// While the server is unreachable, updates are spooled and documents are returned from the last known state
+	server.setOffline(true)
+	stepDoc, err := client.UpdateStepStatus(ctx, step.ID, models.WorkflowStatusRunning, nil, step.ETag)
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusRunning, stepDoc.Status)
+	require.Equal(t, step.JobID, stepDoc.JobID)
+	writeTestLog(t, client, logID, "[2]")
+
+	// Once something is spooled, later updates are spooled behind it even if the server is reachable again
+	server.setOffline(false)
+	writeTestLog(t, client, logID, "[3]")
+	_, err = client.CreateArtifact(ctx, job.ID, "reports", "report.txt", strings.NewReader("all good"))
+	require.NoError(t, err)
+	stepDoc, err = client.UpdateStepStatus(ctx, step.ID, models.WorkflowStatusSucceeded, nil, stepDoc.ETag)
+	require.NoError(t, err)
+	jobDoc, err = client.UpdateJobStatus(ctx, job.ID, models.WorkflowStatusSucceeded, nil, jobDoc.ETag)
+	require.NoError(t, err)
+	require.Equal(t, 6, spool.Len())
+	require.Equal(t, []string{"job:running", "log:[1]"}, server.getCalls())
+
+	// Replaying fails without losing anything while the server is unreachable
+	server.setOffline(true)
+	err = client.replayAll(ctx)
+	require.Error(t, err)
+	require.True(t, isServerUnreachable(err))
+	require.Equal(t, 6, spool.Len())
+
+	// The spool survives the runner restarting, and is replayed in order with ETags chained between updates
+	server.setOffline(false)
+	spool, err = NewSpool(RunnerSpoolDirectory(spoolDir), logger.NoOpLogFactory)
+	require.NoError(t, err)
+	require.Equal(t, 6, spool.Len())
+	client = NewSpoolingAPIClient(server, spool, logger.NoOpLogFactory)
+	client.Start()
+	defer client.Stop()
+	client.wakeReplay()
+	require.Eventually(t, func() bool { return spool.Len() == 0 }, spoolReplayMinBackoff*2, spoolReplayMinBackoff/50)
+	require.Equal(t, []string{
+		"job:running",
+		"log:[1]",
+		"step:running",
+		"log:[2]",
+		"log:[3]",
+		"artifact:report.txt:all good",
+		"step:succeeded",
+		"job:succeeded",
+	}, server.getCalls())
+	require.Equal(t, "[1][2][3]", server.logs[logID])
+}
+
+func TestSpoolingAPIClientDiscardsRejectedRecords(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServerAPIClient()
+	spool, err := NewSpool(RunnerSpoolDirectory(t.TempDir()), logger.NoOpLogFactory)
+	require.NoError(t, err)
+	client := NewSpoolingAPIClient(server, spool, logger.NoOpLogFactory)
+
+	job := &documents.Job{ID: models.NewJobID(), Status: models.WorkflowStatusRunning, ETag: "job-etag"}
+	server.eTags[job.ID.ResourceID] = job.ETag
+	client.rememberJob(job)
+
+	server.setOffline(true)
+	_, err = client.UpdateJobStatus(ctx, job.ID, models.WorkflowStatusSucceeded, nil, job.ETag)
+	require.NoError(t, err)
+	_, err = client.CreateArtifact(ctx, job.ID, "reports", "report.txt", strings.NewReader("all good"))
+	require.NoError(t, err)
+	require.Equal(t, 2, spool.Len())
+
+	// The job was changed on the server while the runner was offline, so the spooled update is rejected
+	server.setOffline(false)
+	server.eTags[job.ID.ResourceID] = "changed-on-server"
+	err = client.replayAll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, spool.Len())
+	require.Equal(t, []string{"artifact:report.txt:all good"}, server.getCalls())
+
+	// Errors returned by the server are not spooled
+	_, err = client.UpdateJobStatus(ctx, job.ID, models.WorkflowStatusFailed, nil, job.ETag)
+	require.Error(t, err)
+	require.True(t, gerror.IsOptimisticLockFailed(err))
+	require.Equal(t, 0, spool.Len())
+}
+
+func TestIsServerUnreachable(t *testing.T) {
+	require.False(t, isServerUnreachable(nil))
+	require.True(t, isServerUnreachable(fmt.Errorf("error during request: %w", errTestConnectionRefused)))
+	require.True(t, isServerUnreachable(context.DeadlineExceeded))
+	require.False(t, isServerUnreachable(context.Canceled))
+	require.True(t, isServerUnreachable(gerror.NewError("Bad gateway", gerror.AudienceExternal, gerror.ErrHttpOperationFailed, http.StatusBadGateway, nil)))
+	require.False(t, isServerUnreachable(gerror.NewErrOptimisticLockFailed("Optimistic lock failed")))
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// StepEnvFileEnvVar is the name of the environment variable holding the path to the file a step can
+	// write to in order to set environment variables for all subsequent steps in the same job.
+	StepEnvFileEnvVar = "BB_ENV"
+	// stepEnvFileName is the name of the step env file within the job's staging directory.
+	stepEnvFileName = "bb_env"
+)
+
+var envVarNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// stepEnv holds the environment variables exported by the steps in a job via the step env file.
+// Variables exported by a step are visible to all subsequent steps in the job, and a variable exported
+// by a later step replaces any value exported by an earlier step.
+type stepEnv struct {
+	// filePath is the path to the step env file on the local filesystem.
+	filePath string
+	names    []string
+	values   map[string]string
+}
+
+func newStepEnv(stagingDir string) *stepEnv {
+	return &stepEnv{
+		filePath: filepath.Join(stagingDir, stepEnvFileName),
+		values:   make(map[string]string),
+	}
+}
+
+// Reset creates an empty step env file, ready for the next step to write to.
+func (e *stepEnv) Reset() error {
+	err := ioutil.WriteFile(e.filePath, nil, 0666)
+	if err != nil {
+		return fmt.Errorf("error creating step env file: %w", err)
+	}
+	// Ensure the file remains writable by steps running as a different user inside a container
+	return os.Chmod(e.filePath, 0666)
+}
+
+// Load reads the variables written to the step env file by the step that just ran, and adds them to the
+// set of variables exported to subsequent steps.
+func (e *stepEnv) Load() error {
+	data, err := ioutil.ReadFile(e.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading step env file: %w", err)
+	}
+	names, values, err := parseStepEnvFile(data)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, ok := e.values[name]; !ok {
+			e.names = append(e.names, name)
+		}
+		e.values[name] = values[name]
+	}
+	return nil
+}
+
+// Mappings returns the exported variables as `key=value` strings, in the order they were first exported.
+func (e *stepEnv) Mappings() []string {
+	mappings := make([]string, 0, len(e.names))
+	for _, name := range e.names {
+		mappings = append(mappings, fmt.Sprintf("%s=%s", name, e.values[name]))
+	}
+	return mappings
+}
+
+// parseStepEnvFile parses the contents of a step env file. Each line is either of the form `NAME=value`,
+// or `NAME<<DELIMITER` to start a multi-line value that ends at the next line consisting only of DELIMITER.
+// Blank lines are ignored. Returns the variable names in the order they were first set, and the last value
+// set for each.
+func parseStepEnvFile(data []byte) ([]string, map[string]string, error) {
+	var (
+		names   []string
+		values  = make(map[string]string)
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+		lineNo  = 0
+	)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	set := func(name string, value string, nameLineNo int) error {
+		if !envVarNameRegex.MatchString(name) {
+			return fmt.Errorf("error invalid environment variable name %q on line %d of step env file", name, nameLineNo)
+		}
+		if name == StepEnvFileEnvVar {
+			return fmt.Errorf("error environment variable %q can not be set from the step env file", name)
+		}
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = value
+		return nil
+	}
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		equalsIndex := strings.Index(line, "=")
+		heredocIndex := strings.Index(line, "<<")
+		if heredocIndex >= 0 && (equalsIndex < 0 || heredocIndex < equalsIndex) {
+			name := line[:heredocIndex]
+			delimiter := line[heredocIndex+2:]
+			if delimiter == "" {
+				return nil, nil, fmt.Errorf("error missing delimiter for multi-line value on line %d of step env file", lineNo)
+			}
+			startLineNo := lineNo
+			var valueLines []string
+			terminated := false
+			for scanner.Scan() {
+				lineNo++
+				valueLine := strings.TrimRight(scanner.Text(), "\r")
+				if valueLine == delimiter {
+					terminated = true
+					break
+				}
+				valueLines = append(valueLines, valueLine)
+			}
+			if !terminated {
+				return nil, nil, fmt.Errorf("error multi-line value started on line %d of step env file is missing its closing delimiter %q", startLineNo, delimiter)
+			}
+			err := set(name, strings.Join(valueLines, "\n"), startLineNo)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if equalsIndex < 0 {
+			return nil, nil, fmt.Errorf("error expected NAME=value or NAME<<DELIMITER on line %d of step env file", lineNo)
+		}
+		err := set(line[:equalsIndex], line[equalsIndex+1:], lineNo)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading step env file: %w", err)
+	}
+	return names, values, nil
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStepEnvFile(t *testing.T) {
+	data := "FOO=bar\r\n\nEMPTY=\nWITH_EQUALS=a=b\nMULTI<<EOF\nline 1\nline 2\nEOF\nFOO=baz\n"
+	names, values, err := parseStepEnvFile([]byte(data))
+	require.Nil(t, err)
+	require.Equal(t, []string{"FOO", "EMPTY", "WITH_EQUALS", "MULTI"}, names)
+	require.Equal(t, map[string]string{
+		"FOO":         "baz",
+		"EMPTY":       "",
+		"WITH_EQUALS": "a=b",
+		"MULTI":       "line 1\nline 2",
+	}, values)
+
+	for _, invalid := range []string{
+		"no equals sign",
+		"1FOO=bar",
+		"=bar",
+		"MULTI<<\nfoo\n",
+		"MULTI<<EOF\nfoo\n",
+		"BB_ENV=/tmp/foo",
+	} {
+		_, _, err = parseStepEnvFile([]byte(invalid))
+		require.NotNil(t, err, "expected error parsing %q", invalid)
+	}
+}
+
+func TestStepEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-env-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	env := newStepEnv(dir)
+	require.Nil(t, env.Load())
+	require.Empty(t, env.Mappings())
+
+	// First step exports two variables
+	require.Nil(t, env.Reset())
+	require.Nil(t, ioutil.WriteFile(env.filePath, []byte("GOBIN=/go/bin\nGOCACHE=/go/cache\n"), 0666))
+	require.Nil(t, env.Load())
+	require.Equal(t, []string{"GOBIN=/go/bin", "GOCACHE=/go/cache"}, env.Mappings())
+
+	// Second step exports nothing; earlier variables are kept
+	require.Nil(t, env.Reset())
+	require.Nil(t, env.Load())
+	require.Equal(t, []string{"GOBIN=/go/bin", "GOCACHE=/go/cache"}, env.Mappings())
+
+	// Third step overrides one variable and adds another
+	require.Nil(t, env.Reset())
+	require.Nil(t, ioutil.WriteFile(env.filePath, []byte("GOBIN=/usr/local/bin\nCGO_ENABLED=0\n"), 0666))
+	require.Nil(t, env.Load())
+	require.Equal(t, []string{"GOBIN=/usr/local/bin", "GOCACHE=/go/cache", "CGO_ENABLED=0"}, env.Mappings())
+}
@@ -0,0 +1,110 @@
+package graphql
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxBatchSize is the maximum number of keys a Loader passes to its fetch function at once, if no
+	// other maximum is specified.
+	DefaultMaxBatchSize = 100
+	// DefaultWait is how long a Loader waits for more keys before fetching a batch, if no other wait is specified.
+	DefaultWait = 2 * time.Millisecond
+)
+
+// FetchFunc fetches the values for a batch of keys. Keys with no value should be omitted from the
+// returned map; a nil value will be returned for them. An error fails the load of every key in the batch.
+type FetchFunc[K comparable, V any] func(keys []K) (map[K]V, error)
+
+type loaderResult[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type loaderBatch[K comparable] struct {
+	keys []K
+	once sync.Once
+}
+
+// Loader batches and caches loads of values by key, so that resolving the same field on many objects
+// (e.g. the jobs for each build in a list) makes one fetch rather than one per object.
+// GraphQL resolvers for the objects in a list run concurrently; the first key loaded starts a batch, and
+// the batch is fetched once it is full or once the loader has waited a short time for more keys.
+// Values are cached for the lifetime of the Loader, so a Loader should be created for each request.
+type Loader[K comparable, V any] struct {
+	fetch        FetchFunc[K, V]
+	maxBatchSize int
+	wait         time.Duration
+	mu           sync.Mutex
+	batch        *loaderBatch[K]
+	results      map[K]*loaderResult[V]
+}
+
+// NewLoader creates a Loader that fetches at most maxBatchSize keys at a time, waiting up to wait for
+// each batch to fill. If maxBatchSize or wait are zero then DefaultMaxBatchSize or DefaultWait are used.
+func NewLoader[K comparable, V any](fetch FetchFunc[K, V], maxBatchSize int, wait time.Duration) *Loader[K, V] {
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+	if wait <= 0 {
+		wait = DefaultWait
+	}
+	return &Loader[K, V]{
+		fetch:        fetch,
+		maxBatchSize: maxBatchSize,
+		wait:         wait,
+		results:      make(map[K]*loaderResult[V]),
+	}
+}
+
+// Load returns the value for a key, waiting for it to be fetched along with any other keys loaded at
+// around the same time if it isn't already cached.
+func (l *Loader[K, V]) Load(key K) (V, error) {
+	l.mu.Lock()
+	result, ok := l.results[key]
+	if !ok {
+		result = &loaderResult[V]{done: make(chan struct{})}
+		l.results[key] = result
+		batch := l.batch
+		if batch == nil {
+			batch = &loaderBatch[K]{}
+			l.batch = batch
+			time.AfterFunc(l.wait, func() { l.dispatch(batch) })
+		}
+		batch.keys = append(batch.keys, key)
+		if len(batch.keys) >= l.maxBatchSize {
+			l.batch = nil
+			go l.dispatch(batch)
+		}
+	}
+	l.mu.Unlock()
+	<-result.done
+	return result.value, result.err
+}
+
+// dispatch fetches the keys in a batch, if it hasn't already been fetched, and wakes up the callers
+// waiting for them.
+func (l *Loader[K, V]) dispatch(batch *loaderBatch[K]) {
+	batch.once.Do(func() {
+		l.mu.Lock()
+		if l.batch == batch {
+			l.batch = nil
+		}
+		keys := batch.keys
+		l.mu.Unlock()
+		values, err := l.fetch(keys)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, key := range keys {
+			result := l.results[key]
+			if err != nil {
+				result.err = err
+			} else {
+				result.value = values[key]
+			}
+			close(result.done)
+		}
+	})
+}
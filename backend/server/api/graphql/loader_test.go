@@ -0,0 +1,103 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// loadConcurrently loads each key from its own goroutine, as GraphQL resolvers for the objects in a list do,
+// and returns the values in key order.
+func loadConcurrently[K comparable, V any](loader *Loader[K, V], keys []K) ([]V, []error) {
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key K) {
+			defer wg.Done()
+			values[i], errs[i] = loader.Load(key)
+		}(i, key)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+func TestLoader(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]int
+	)
+	loader := NewLoader(func(keys []int) (map[int]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sorted := append([]int(nil), keys...)
+		sort.Ints(sorted)
+		batches = append(batches, sorted)
+		results := make(map[int]string)
+		for _, key := range keys {
+			if key != 3 {
+				results[key] = fmt.Sprint(key)
+			}
+		}
+		return results, nil
+	}, 10, 100*time.Millisecond)
+
+	// Keys loaded at the same time are fetched together, once each
+	values, errs := loadConcurrently(loader, []int{1, 2, 2, 3, 4})
+	require.Equal(t, []error{nil, nil, nil, nil, nil}, errs)
+	require.Equal(t, []string{"1", "2", "2", "", "4"}, values, "Keys with no value should load the zero value")
+	require.Equal(t, [][]int{{1, 2, 3, 4}}, batches)
+
+	// Loaded values are cached
+	value, err := loader.Load(4)
+	require.NoError(t, err)
+	require.Equal(t, "4", value)
+	require.Len(t, batches, 1)
+}
+
+func TestLoaderBatchSize(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]int
+	)
+	// Wait much longer than the test should take, so that batches are only fetched when they are full
+	loader := NewLoader(func(keys []int) (map[int]int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, keys)
+		results := make(map[int]int)
+		for _, key := range keys {
+			results[key] = key * 10
+		}
+		return results, nil
+	}, 2, time.Minute)
+
+	values, errs := loadConcurrently(loader, []int{1, 2, 3, 4})
+	require.Equal(t, []error{nil, nil, nil, nil}, errs)
+	require.Equal(t, []int{10, 20, 30, 40}, values)
+	require.Len(t, batches, 2)
+	for _, batch := range batches {
+		require.Len(t, batch, 2)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	fetches := 0
+	loader := NewLoader(func(keys []string) (map[string]string, error) {
+		fetches++
+		return nil, errors.New("error fetching")
+	}, 0, 0)
+
+	_, err := loader.Load("a")
+	require.EqualError(t, err, "error fetching")
+	// Errors are cached too, so that a failing fetch isn't repeated for every object in a query
+	_, err = loader.Load("a")
+	require.EqualError(t, err, "error fetching")
+	require.Equal(t, 1, fetches)
+}
@@ -0,0 +1,315 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	bbgrpc "github.com/buildbeaver/buildbeaver/server/api/grpc"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// maxChunkSize is the largest amount of log or artifact data sent in a single message, comfortably below
+// the default 4MiB gRPC message size limit.
+const maxChunkSize = 64 * 1024
+
+// APIClient is a client for the gRPC runner API. Calls that are not part of the gRPC API (e.g. reading
+// secrets and caches) are made using the embedded REST API client, so APIClient can be used anywhere a
+// runner needs an API client.
+type APIClient struct {
+	*client.APIClient
+	conn      *grpc.ClientConn
+	runnerAPI bbgrpc.RunnerAPIClient
+	log       logger.Log
+}
+
+// NewAPIClient creates a client for the gRPC runner API at endpoint (a host:port address). The client
+// authenticates using the same client certificate and verifies the server using the same CA certificates
+// as authenticator does for the REST API.
+func NewAPIClient(
+	endpoint string,
+	restClient *client.APIClient,
+	authenticator *client.ClientCertificateAuthenticator,
+	logFactory logger.LogFactory,
+) (*APIClient, error) {
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates:       []tls.Certificate{*authenticator.ClientCert},
+		RootCAs:            authenticator.ServerCACertPool,
+		InsecureSkipVerify: authenticator.InsecureSkipVerify.Bool(),
+	})
+	// Dial does not block; the connection is made (and remade if lost) in the background
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("error dialing gRPC runner API: %w", err)
+	}
+	return &APIClient{
+		APIClient: restClient,
+		conn:      conn,
+		runnerAPI: bbgrpc.NewRunnerAPIClient(conn),
+		log:       logFactory("GRPCAPIClient"),
+	}, nil
+}
+
+// Close closes the connection to the server.
+func (a *APIClient) Close() error {
+	return a.conn.Close()
+}
+
+// Dequeue returns the next build job that is ready to be executed, or nil if there are currently no queued builds.
+// If wait is non-zero and no job is ready, the server waits for up to wait for a job to become ready before
+// returning.
+func (a *APIClient) Dequeue(ctx context.Context, wait time.Duration) (*documents.RunnableJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, wait+bbgrpc.DequeueResponseMargin)
+	defer cancel()
+	res, err := a.runnerAPI.Dequeue(ctx, &bbgrpc.DequeueRequest{})
+	if err != nil {
+		err = bbgrpc.FromStatusError(err)
+		if gerror.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	doc := &documents.RunnableJob{}
+	err = json.Unmarshal(res.RunnableJob, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing runnable job: %s", string(res.RunnableJob))
+	}
+	// RunnableJob document must contain a job
+	if doc.Job == nil {
+		return nil, fmt.Errorf("error parsing runnable job: no 'job' element specified in RunnableJob")
+	}
+	return doc, nil
+}
+
+// UpdateJobStatus updates the status of the specified job.
+// If the status is finished, err can be supplied to signal the job failed with an error
+// or nil to signify the job succeeded.
+func (a *APIClient) UpdateJobStatus(
+	ctx context.Context,
+	jobID models.JobID,
+	status models.WorkflowStatus,
+	jobError *models.Error,
+	eTag models.ETag) (*documents.Job, error) {
+
+	return a.updateJob(ctx, &bbgrpc.UpdateJobRequest{
+		JobId:  jobID.String(),
+		Status: status.String(),
+		Error:  toError(jobError),
+		Etag:   eTag.String(),
+	})
+}
+
+// UpdateJobFingerprint sets the fingerprint that has been calculated for a job. If the build is not configured
+// with the force option (e.g. force=false), the server will attempt to locate a previously successful job with a
+// matching fingerprint and indirect this job to it. If an indirection has been set, the agent must skip the job.
+func (a *APIClient) UpdateJobFingerprint(
+	ctx context.Context,
+	jobID models.JobID,
+	jobFingerprint string,
+	jobFingerprintHashType *models.HashType,
+	eTag models.ETag) (*documents.Job, error) {
+
+	req := &bbgrpc.UpdateJobRequest{
+		JobId:       jobID.String(),
+		Fingerprint: jobFingerprint,
+		Etag:        eTag.String(),
+	}
+	if jobFingerprintHashType != nil {
+		req.FingerprintHashType = jobFingerprintHashType.String()
+	}
+	return a.updateJob(ctx, req)
+}
+
+func (a *APIClient) updateJob(ctx context.Context, req *bbgrpc.UpdateJobRequest) (*documents.Job, error) {
+	res, err := a.runnerAPI.UpdateJob(ctx, req)
+	if err != nil {
+		return nil, bbgrpc.FromStatusError(err)
+	}
+	doc := &documents.Job{}
+	err = json.Unmarshal(res.Job, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing job: %s", string(res.Job))
+	}
+	return doc, nil
+}
+
+// UpdateStepStatus updates the status of the specified step.
+// If the status is finished, err can be supplied to signal the step failed with an error
+// or nil to signify the step succeeded.
+func (a *APIClient) UpdateStepStatus(
+	ctx context.Context,
+	stepID models.StepID,
+	status models.WorkflowStatus,
+	stepError *models.Error,
+	eTag models.ETag) (*documents.Step, error) {
+
+	res, err := a.runnerAPI.UpdateStep(ctx, &bbgrpc.UpdateStepRequest{
+		StepId: stepID.String(),
+		Status: status.String(),
+		Error:  toError(stepError),
+		Etag:   eTag.String(),
+	})
+	if err != nil {
+		return nil, bbgrpc.FromStatusError(err)
+	}
+	doc := &documents.Step{}
+	err = json.Unmarshal(res.Step, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing step: %s", string(res.Step))
+	}
+	return doc, nil
+}
+
+// CreateArtifact registers a new artifact against the specified job, with its contents provided by reader.
+// It is the caller's responsibility to close reader.
+func (a *APIClient) CreateArtifact(
+	ctx context.Context,
+	jobID models.JobID,
+	groupName models.ResourceName,
+	relativePath string,
+	reader io.ReadSeeker) (*documents.Artifact, error) {
+
+	stream, err := a.runnerAPI.UploadArtifact(ctx)
+	if err != nil {
+		return nil, bbgrpc.FromStatusError(err)
+	}
+	err = stream.Send(&bbgrpc.UploadArtifactRequest{
+		Part: &bbgrpc.UploadArtifactRequest_Metadata{Metadata: &bbgrpc.ArtifactMetadata{
+			JobId:     jobID.String(),
+			GroupName: groupName.String(),
+			Path:      relativePath,
+			Md5:       "", // TODO calculate this
+		}},
+	})
+	buf := make([]byte, maxChunkSize)
+	for err == nil {
+		var n int
+		n, err = reader.Read(buf)
+		if n > 0 {
+			sendErr := stream.Send(&bbgrpc.UploadArtifactRequest{
+				Part: &bbgrpc.UploadArtifactRequest_Data{Data: buf[:n]},
+			})
+			if sendErr != nil {
+				err = sendErr
+			}
+		}
+	}
+	// Send returns io.EOF if the server ended the call; the server's error is returned by CloseAndRecv
+	if err != io.EOF {
+		stream.CloseSend()
+		return nil, fmt.Errorf("error uploading artifact: %w", err)
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, bbgrpc.FromStatusError(err)
+	}
+	doc := &documents.Artifact{}
+	err = json.Unmarshal(res.Artifact, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing artifact: %s", string(res.Artifact))
+	}
+	return doc, nil
+}
+
+// OpenLogWriteStream opens a writable stream to the specified log. Close the writer to finish writing.
+func (a *APIClient) OpenLogWriteStream(ctx context.Context, logID models.LogDescriptorID) (io.WriteCloser, error) {
+	stream, err := a.runnerAPI.WriteLog(ctx)
+	if err != nil {
+		return nil, bbgrpc.FromStatusError(err)
+	}
+	return &logStreamWriter{stream: stream, logID: logID}, nil
+}
+
+// WatchJobs calls handler each time the server finishes a job the runner is running (e.g. because the job
+// was canceled), until ctx is done or the call fails.
+func (a *APIClient) WatchJobs(ctx context.Context, handler func(jobID models.JobID, status models.WorkflowStatus)) error {
+	stream, err := a.runnerAPI.WatchJobs(ctx, &bbgrpc.WatchJobsRequest{})
+	if err != nil {
+		return bbgrpc.FromStatusError(err)
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return bbgrpc.FromStatusError(err)
+		}
+		id, err := models.ParseResourceID(event.JobId)
+		if err != nil || id.Kind() != models.JobResourceKind {
+			a.log.Warnf("Ignoring event for invalid job ID %q", event.JobId)
+			continue
+		}
+		handler(models.JobIDFromResourceID(id), models.WorkflowStatus(event.Status))
+	}
+}
+
+// logStreamWriter is an io.WriteCloser that writes log entries to a WriteLog stream.
+type logStreamWriter struct {
+	stream  bbgrpc.RunnerAPI_WriteLogClient
+	logID   models.LogDescriptorID
+	started bool
+}
+
+func (w *logStreamWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > maxChunkSize {
+			n = maxChunkSize
+		}
+		err := w.send(p[:n])
+		if err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close finishes writing and returns the error (if any) the server returned from the call.
+// This is more accurate than the error returned from Write() since it reflects the overall status of the call.
+func (w *logStreamWriter) Close() error {
+	if !w.started {
+		// The server needs the log ID even if no entries were written
+		err := w.send(nil)
+		if err != nil && err != io.EOF {
+			return bbgrpc.FromStatusError(err)
+		}
+	}
+	_, err := w.stream.CloseAndRecv()
+	if err != nil {
+		return bbgrpc.FromStatusError(err)
+	}
+	return nil
+}
+
+// send sends entries to the server, including the log ID in the first message.
+func (w *logStreamWriter) send(entries []byte) error {
+	req := &bbgrpc.WriteLogRequest{Entries: entries}
+	if !w.started {
+		req.LogDescriptorId = w.logID.String()
+		w.started = true
+	}
+	return w.stream.Send(req)
+}
+
+// toError converts err to the form sent to the server, or nil if err is not set.
+func toError(err *models.Error) *bbgrpc.Error {
+	if !err.Valid() {
+		return nil
+	}
+	return &bbgrpc.Error{Message: err.Error()}
+}
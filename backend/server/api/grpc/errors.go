@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"errors"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+)
+
+const (
+	// errorInfoDomain is the domain of the ErrorInfo detail attached to errors returned by the gRPC API.
+	errorInfoDomain = "buildbeaver.com"
+	// httpStatusCodeMetadataKey is the ErrorInfo metadata key holding the HTTP status code the REST API
+	// would have returned for the error.
+	httpStatusCodeMetadataKey = "http_status_code"
+)
+
+// grpcCodes maps the code of each public error to the closest gRPC status code. Codes not in the map are
+// returned as codes.Internal.
+var grpcCodes = map[gerror.Code]codes.Code{
+	gerror.ErrCodeValidationFailed:      codes.InvalidArgument,
+	gerror.ErrCodeInvalidQueryParameter: codes.InvalidArgument,
+	gerror.ErrCodeNotFound:              codes.NotFound,
+	gerror.ErrCodeUnauthorized:          codes.Unauthenticated,
+	gerror.ErrCodeAlreadyExists:         codes.AlreadyExists,
+	gerror.ErrCodeOptimisticLockFailed:  codes.Aborted,
+	gerror.ErrCodeAccountDisabled:       codes.FailedPrecondition,
+	gerror.ErrCodeRunnerDisabled:        codes.FailedPrecondition,
+	gerror.ErrCodeRunnerUnhealthy:       codes.FailedPrecondition,
+	gerror.ErrCodeTimeout:               codes.DeadlineExceeded,
+	gerror.ErrCodeLogClosed:             codes.FailedPrecondition,
+	gerror.ErrCodeArtifactQuarantined:   codes.FailedPrecondition,
+	gerror.ErrCodeStepTimedOut:          codes.DeadlineExceeded,
+	gerror.ErrCodeLogExpired:            codes.FailedPrecondition,
+	gerror.ErrCodeQuotaExceeded:         codes.ResourceExhausted,
+}
+
+// ToStatusError converts an error returned by a service into a gRPC status error, sanitized for public
+// display in the same way as errors returned by the REST API. The public error's code and HTTP status
+// code are attached as an ErrorInfo detail so that FromStatusError can recreate the error on the client.
+// Errors that are already gRPC status errors (e.g. from a canceled stream) are returned unchanged.
+func ToStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	// Look down through the chain of wrapped errors and find the first error which is a gerror.Error
+	var gErr gerror.Error
+	if !errors.As(err, &gErr) || gErr.Audience() != gerror.AudienceExternal {
+		gErr = gerror.NewErrInternal()
+	}
+	code, ok := grpcCodes[gErr.Code()]
+	if !ok {
+		code = codes.Internal
+	}
+	st := status.New(code, gErr.Message())
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   string(gErr.Code()),
+		Domain:   errorInfoDomain,
+		Metadata: map[string]string{httpStatusCodeMetadataKey: strconv.Itoa(gErr.HTTPStatusCode())},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// FromStatusError converts a gRPC status error returned by the server back into the public error the
+// server returned, so that callers can inspect it using the gerror package just as for errors returned
+// by the REST API client. Errors without error info from the server are returned unchanged.
+func FromStatusError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st == nil {
+		return err
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != errorInfoDomain {
+			continue
+		}
+		httpStatusCode, _ := strconv.Atoi(info.Metadata[httpStatusCodeMetadataKey])
+		return gerror.NewError(st.Message(), gerror.AudienceExternal, gerror.Code(info.Reason), httpStatusCode, err)
+	}
+	return err
+}
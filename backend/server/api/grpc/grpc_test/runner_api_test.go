@@ -0,0 +1,342 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/runner/app/runner_test"
+	grpcclient "github.com/buildbeaver/buildbeaver/server/api/grpc/client"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestRunnerGRPCAPI(t *testing.T) {
+	ctx := context.Background()
+
+	// Create a test server
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.RunnerAPIServer.Start()
+	defer app.RunnerAPIServer.Stop(ctx)
+	app.RunnerGRPCAPIServer.Start()
+	defer app.RunnerGRPCAPIServer.Stop(ctx)
+
+	// Create a gRPC API client to talk to the server via client certificate authentication
+	apiClient, clientCert := makeGRPCAPIClient(t, app)
+	defer apiClient.Close()
+
+	testCompany := server_test.CreateCompanyLegalEntity(t, ctx, app, "", "", "")
+
+	// Create a runner in order to register the client certificate as a credential
+	_ = server_test.CreateRunner(t, ctx, app, "", testCompany.ID, clientCert)
+
+	repo := server_test.CreateRepo(t, ctx, app, testCompany.ID)
+
+	t.Run("Dequeue", testGRPCDequeue(app, apiClient, repo.ID, testCompany.ID))
+	t.Run("DequeueWait", testGRPCDequeueWait(app, apiClient, repo.ID, testCompany.ID))
+	t.Run("UpdateStatus", testGRPCUpdateStatus(app, apiClient, repo.ID, testCompany.ID))
+	t.Run("WriteLog", testGRPCWriteLog(app, apiClient, repo.ID, testCompany.ID))
+	t.Run("UploadArtifact", testGRPCUploadArtifact(app, apiClient, repo.ID, testCompany.ID))
+	t.Run("WatchJobs", testGRPCWatchJobs(app, apiClient, repo.ID, testCompany.ID))
+	t.Run("Unregistered", testGRPCUnregisteredRunner(app))
+}
+
+func testGRPCDequeue(app *server_test.TestServer, apiClient *grpcclient.APIClient, repoID models.RepoID, legalEntityID models.LegalEntityID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		job, err := apiClient.Dequeue(ctx, 0)
+		require.NoError(t, err)
+		require.Nil(t, job, "No job should be returned when queue is empty")
+
+		build := server_test.CreateAndQueueBuild(t, ctx, app, repoID, legalEntityID, "")
+		jobIDs := make(map[models.JobID]bool)
+		for _, job := range build.Jobs {
+			jobIDs[job.ID] = true
+		}
+		dequeued := 0
+		for {
+			job, err := apiClient.Dequeue(ctx, 0)
+			require.NoError(t, err)
+			if job == nil {
+				break
+			}
+			require.True(t, jobIDs[job.Job.ID], "Unexpected job dequeued")
+			require.Equal(t, models.WorkflowStatusSubmitted, job.Job.Status)
+			require.NotEmpty(t, job.Steps)
+			delete(jobIDs, job.Job.ID)
+			dequeued++
+		}
+		require.NotZero(t, dequeued, "Expected to dequeue jobs")
+	}
+}
+
+func testGRPCDequeueWait(app *server_test.TestServer, apiClient *grpcclient.APIClient, repoID models.RepoID, legalEntityID models.LegalEntityID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		// With nothing to dequeue the server waits before saying so
+		start := time.Now()
+		job, err := apiClient.Dequeue(ctx, 1*time.Second)
+		require.NoError(t, err)
+		require.Nil(t, job)
+		require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+
+		// A job queued while waiting is returned straight away
+		type result struct {
+			job *documents.RunnableJob
+			err error
+		}
+		resultChan := make(chan result)
+		start = time.Now()
+		go func() {
+			job, err := apiClient.Dequeue(ctx, 30*time.Second)
+			resultChan <- result{job: job, err: err}
+		}()
+		time.Sleep(200 * time.Millisecond)
+		build := server_test.CreateAndQueueBuild(t, ctx, app, repoID, legalEntityID, "")
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.NotNil(t, res.job)
+		require.Equal(t, build.ID, res.job.Job.BuildID)
+		require.Less(t, time.Since(start), 10*time.Second)
+
+		drainQueue(t, apiClient)
+	}
+}
+
+func testGRPCUpdateStatus(app *server_test.TestServer, apiClient *grpcclient.APIClient, repoID models.RepoID, legalEntityID models.LegalEntityID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		runnable := dequeueNewJob(t, app, apiClient, repoID, legalEntityID)
+
+		job, err := apiClient.UpdateJobStatus(ctx, runnable.Job.ID, models.WorkflowStatusRunning, nil, runnable.Job.ETag)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusRunning, job.Status)
+
+		// A stale ETag fails optimistic locking, as for the REST API
+		_, err = apiClient.UpdateJobStatus(ctx, runnable.Job.ID, models.WorkflowStatusRunning, nil, runnable.Job.ETag)
+		require.Error(t, err)
+		require.True(t, gerror.IsOptimisticLockFailed(err), "expected optimistic lock failure, got %v", err)
+
+		// Invalid requests are rejected
+		_, err = apiClient.UpdateJobStatus(ctx, runnable.Job.ID, "bogus", nil, job.ETag)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "expected validation failure, got %v", err)
+
+		step := runnable.Steps[0]
+		stepDoc, err := apiClient.UpdateStepStatus(ctx, step.ID, models.WorkflowStatusRunning, nil, step.ETag)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusRunning, stepDoc.Status)
+		stepDoc, err = apiClient.UpdateStepStatus(ctx, step.ID, models.WorkflowStatusFailed, models.NewError(errors.New("step went wrong")), stepDoc.ETag)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusFailed, stepDoc.Status)
+
+		read, err := app.StepService.Read(ctx, nil, step.ID)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusFailed, read.Status)
+		require.Equal(t, "step went wrong", read.Error.Error())
+
+		drainQueue(t, apiClient)
+	}
+}
+
+func testGRPCWriteLog(app *server_test.TestServer, apiClient *grpcclient.APIClient, repoID models.RepoID, legalEntityID models.LegalEntityID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		build := server_test.CreateAndQueueBuild(t, ctx, app, repoID, legalEntityID, "")
+		logDescriptor, err := app.LogService.Create(ctx, nil, models.NewLogDescriptor(
+			models.NewTime(time.Now()),
+			models.LogDescriptorID{},
+			build.ID.ResourceID))
+		require.NoError(t, err)
+
+		// Write enough entries that they are sent in several messages
+		var (
+			entries  []*models.LogEntry
+			expected strings.Builder
+		)
+		for i := 1; i <= 2000; i++ {
+			text := fmt.Sprintf("line %d %s", i, strings.Repeat("x", 100))
+			entries = append(entries, models.NewLogEntryLine(i, models.NewTime(time.Now()), text, i, nil))
+			expected.WriteString(text + "\n")
+		}
+		writeData, err := json.Marshal(entries)
+		require.NoError(t, err)
+		require.Greater(t, len(writeData), 128*1024)
+
+		writer, err := apiClient.OpenLogWriteStream(ctx, logDescriptor.ID)
+		require.NoError(t, err)
+		_, err = writer.Write(writeData)
+		require.NoError(t, err)
+		err = writer.Close()
+		require.NoError(t, err)
+
+		// Read the log back using the REST API
+		plaintext := true
+		reader, err := apiClient.OpenLogReadStream(ctx, logDescriptor.ID, &documents.LogSearchRequest{LogSearch: &models.LogSearch{Plaintext: &plaintext}})
+		require.NoError(t, err)
+		readData, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		reader.Close()
+		require.Equal(t, expected.String(), string(readData))
+
+		// Closing a stream with nothing written succeeds
+		writer, err = apiClient.OpenLogWriteStream(ctx, logDescriptor.ID)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		// Logs can't be written once sealed
+		err = app.LogService.Seal(ctx, nil, logDescriptor.ID)
+		require.NoError(t, err)
+		writer, err = apiClient.OpenLogWriteStream(ctx, logDescriptor.ID)
+		require.NoError(t, err)
+		_, _ = writer.Write(writeData)
+		err = writer.Close()
+		require.Error(t, err)
+
+		drainQueue(t, apiClient)
+	}
+}
+
+func testGRPCUploadArtifact(app *server_test.TestServer, apiClient *grpcclient.APIClient, repoID models.RepoID, legalEntityID models.LegalEntityID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		runnable := dequeueNewJob(t, app, apiClient, repoID, legalEntityID)
+
+		data := bytes.Repeat([]byte("artifact data "), 20000)
+		artifact, err := apiClient.CreateArtifact(ctx, runnable.Job.ID, "reports", "out/report.txt", bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, "out/report.txt", artifact.Path)
+		require.Equal(t, runnable.Job.ID, artifact.JobID)
+
+		reader, err := apiClient.GetArtifactData(ctx, artifact.ID)
+		require.NoError(t, err)
+		defer reader.Close()
+		read, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, data, read)
+
+		drainQueue(t, apiClient)
+	}
+}
+
+func testGRPCWatchJobs(app *server_test.TestServer, apiClient *grpcclient.APIClient, repoID models.RepoID, legalEntityID models.LegalEntityID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		runnable := dequeueNewJob(t, app, apiClient, repoID, legalEntityID)
+		job, err := apiClient.UpdateJobStatus(ctx, runnable.Job.ID, models.WorkflowStatusRunning, nil, runnable.Job.ETag)
+		require.NoError(t, err)
+
+		type event struct {
+			jobID  models.JobID
+			status models.WorkflowStatus
+		}
+		events := make(chan event, 10)
+		watchErr := make(chan error, 1)
+		go func() {
+			watchErr <- apiClient.WatchJobs(ctx, func(jobID models.JobID, status models.WorkflowStatus) {
+				events <- event{jobID: jobID, status: status}
+			})
+		}()
+		// Let the server see the job running before finishing it
+		time.Sleep(500 * time.Millisecond)
+
+		// Finish the job on the server, as happens when it times out
+		_, err = app.QueueService.UpdateJobStatus(ctx, nil, runnable.Job.ID, dto.UpdateJobStatus{
+			Status: models.WorkflowStatusFailed,
+			Error:  models.NewError(errors.New("job timed out")),
+			ETag:   job.ETag,
+		})
+		require.NoError(t, err)
+
+		select {
+		case e := <-events:
+			require.Equal(t, runnable.Job.ID, e.jobID)
+			require.Equal(t, models.WorkflowStatusFailed, e.status)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for job event")
+		}
+
+		cancel()
+		require.NoError(t, <-watchErr)
+
+		drainQueue(t, apiClient)
+	}
+}
+
+func testGRPCUnregisteredRunner(app *server_test.TestServer) func(t *testing.T) {
+	return func(t *testing.T) {
+		// A client whose certificate isn't registered as a runner's credential can't call the API
+		apiClient, _ := makeGRPCAPIClient(t, app)
+		defer apiClient.Close()
+		_, err := apiClient.Dequeue(context.Background(), 0)
+		require.Error(t, err)
+		require.True(t, gerror.IsUnauthorized(err), "expected unauthorized, got %v", err)
+	}
+}
+
+// makeGRPCAPIClient creates a gRPC API client that can be used to communicate with the given test server,
+// generating a client certificate. Returns the client, and the certificate to register for the runner.
+func makeGRPCAPIClient(t *testing.T, app *server_test.TestServer) (*grpcclient.APIClient, certificates.CertificateData) {
+	config := runner_test.TestConfig(t)
+
+	authenticator, err := client.NewClientCertificateAuthenticator(
+		config.RunnerCertificateFile,
+		config.RunnerPrivateKeyFile,
+		true,
+		config.CACertFile,
+		true,
+		app.LogFactory,
+	)
+	require.NoError(t, err)
+	restClient, err := client.NewAPIClient([]string{app.RunnerAPIServer.GetServerURL()}, authenticator, app.LogFactory)
+	require.NoError(t, err)
+	apiClient, err := grpcclient.NewAPIClient(app.RunnerGRPCAPIServer.GetAddress(), restClient, authenticator, app.LogFactory)
+	require.NoError(t, err)
+
+	clientCert, err := certificates.LoadCertificateFromPemFile(config.RunnerCertificateFile)
+	require.NoError(t, err)
+
+	return apiClient, clientCert
+}
+
+// dequeueNewJob queues a new build and dequeues one of its jobs.
+func dequeueNewJob(t *testing.T, app *server_test.TestServer, apiClient *grpcclient.APIClient, repoID models.RepoID, legalEntityID models.LegalEntityID) *documents.RunnableJob {
+	server_test.CreateAndQueueBuild(t, context.Background(), app, repoID, legalEntityID, "")
+	runnable, err := apiClient.Dequeue(context.Background(), 0)
+	require.NoError(t, err)
+	require.NotNil(t, runnable)
+	return runnable
+}
+
+// drainQueue dequeues any jobs left in the queue, so they aren't dequeued by the next test.
+func drainQueue(t *testing.T, apiClient *grpcclient.APIClient) {
+	for {
+		job, err := apiClient.Dequeue(context.Background(), 0)
+		require.NoError(t, err)
+		if job == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+const (
+	// WatchJobsPollInterval is how often WatchJobs checks whether the jobs a runner is running have finished.
+	WatchJobsPollInterval = 2 * time.Second
+	// DequeueResponseMargin is the time Dequeue leaves before the call's deadline to send its response.
+	// Clients should set a deadline this much later than the time they are prepared to wait for a job.
+	DequeueResponseMargin = time.Second
+)
+
+// RunnerAPI implements the gRPC runner API, calling the same services as the REST runner API.
+// Every call must have been authenticated as a runner by Server's interceptors.
+type RunnerAPI struct {
+	UnimplementedRunnerAPIServer
+	runnerService        services.RunnerService
+	queueService         services.QueueService
+	jobService           services.JobService
+	logService           services.LogService
+	artifactService      services.ArtifactService
+	authorizationService services.AuthorizationService
+	logger.Log
+}
+
+func NewRunnerAPI(
+	runnerService services.RunnerService,
+	queueService services.QueueService,
+	jobService services.JobService,
+	logService services.LogService,
+	artifactService services.ArtifactService,
+	authorizationService services.AuthorizationService,
+	logFactory logger.LogFactory,
+) *RunnerAPI {
+	return &RunnerAPI{
+		runnerService:        runnerService,
+		queueService:         queueService,
+		jobService:           jobService,
+		logService:           logService,
+		artifactService:      artifactService,
+		authorizationService: authorizationService,
+		Log:                  logFactory("RunnerGRPCAPI"),
+	}
+}
+
+// Dequeue waits for a job the runner can run until the call's deadline, or for up to server.MaxDequeueWait
+// if the deadline is later or not set.
+func (a *RunnerAPI) Dequeue(ctx context.Context, req *DequeueRequest) (*DequeueResponse, error) {
+	runner, err := a.runnerService.ReadByIdentityID(ctx, nil, mustIdentityID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	wait := server.MaxDequeueWait
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		// Leave time to send the response before the client gives up
+		wait = time.Until(deadline) - DequeueResponseMargin
+		if wait < 0 {
+			wait = 0
+		}
+	}
+	job, err := a.queueService.DequeueWithWait(ctx, runner.ID, wait)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(documents.MakeRunnableJob(requestCtx(ctx), job))
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling runnable job: %w", err)
+	}
+	return &DequeueResponse{RunnableJob: buf}, nil
+}
+
+func (a *RunnerAPI) UpdateJob(ctx context.Context, req *UpdateJobRequest) (*UpdateJobResponse, error) {
+	jobID, err := a.authorizedJobID(ctx, req.JobId, models.BuildUpdateOperation)
+	if err != nil {
+		return nil, err
+	}
+	patch := &documents.PatchJobRequest{Error: toModelError(req.Error)}
+	if req.Status != "" {
+		status := models.WorkflowStatus(req.Status)
+		patch.Status = &status
+	}
+	if req.Fingerprint != "" {
+		hashType := models.HashType(req.FingerprintHashType)
+		patch.Fingerprint = &req.Fingerprint
+		patch.FingerprintHashType = &hashType
+	}
+	err = patch.Bind(nil)
+	if err != nil {
+		return nil, err
+	}
+	var job *models.Job
+	if patch.Status != nil {
+		job, err = a.queueService.UpdateJobStatus(ctx, nil, jobID, dto.UpdateJobStatus{
+			Status: *patch.Status,
+			Error:  patch.Error,
+			ETag:   models.ETag(req.Etag),
+		})
+	} else {
+		job, err = a.queueService.UpdateJobFingerprint(ctx, jobID, dto.UpdateJobFingerprint{
+			Fingerprint:         *patch.Fingerprint,
+			FingerprintHashType: *patch.FingerprintHashType,
+			ETag:                models.ETag(req.Etag),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(documents.MakeJob(requestCtx(ctx), job))
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling job: %w", err)
+	}
+	return &UpdateJobResponse{Job: buf, Etag: job.ETag.String()}, nil
+}
+
+func (a *RunnerAPI) UpdateStep(ctx context.Context, req *UpdateStepRequest) (*UpdateStepResponse, error) {
+	stepID, err := a.authorizedStepID(ctx, req.StepId, models.BuildUpdateOperation)
+	if err != nil {
+		return nil, err
+	}
+	status := models.WorkflowStatus(req.Status)
+	patch := &documents.PatchStepRequest{Status: &status, Error: toModelError(req.Error)}
+	err = patch.Bind(nil)
+	if err != nil {
+		return nil, err
+	}
+	step, err := a.queueService.UpdateStepStatus(ctx, nil, stepID, dto.UpdateStepStatus{
+		Status: *patch.Status,
+		Error:  patch.Error,
+		ETag:   models.ETag(req.Etag),
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(documents.MakeStep(requestCtx(ctx), step))
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling step: %w", err)
+	}
+	return &UpdateStepResponse{Step: buf, Etag: step.ETag.String()}, nil
+}
+
+func (a *RunnerAPI) WriteLog(stream RunnerAPI_WriteLogServer) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	resourceID, err := a.authorizedResourceID(ctx, first.LogDescriptorId, models.LogDescriptorResourceKind, models.BuildUpdateOperation)
+	if err != nil {
+		return err
+	}
+	reader := &logStreamReader{stream: stream, buf: first.Entries}
+	err = reader.fill()
+	if err == io.EOF {
+		// The runner closed the stream without writing any entries
+		return stream.SendAndClose(&WriteLogResponse{})
+	}
+	if err != nil {
+		return err
+	}
+	err = a.logService.WriteData(ctx, models.LogDescriptorIDFromResourceID(resourceID), reader)
+	if err != nil {
+		return fmt.Errorf("error writing log: %w", err)
+	}
+	return stream.SendAndClose(&WriteLogResponse{})
+}
+
+func (a *RunnerAPI) UploadArtifact(stream RunnerAPI_UploadArtifactServer) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	meta := first.GetMetadata()
+	if meta == nil {
+		return gerror.NewErrValidationFailed("The first message must contain the artifact's metadata")
+	}
+	jobID, err := a.authorizedJobID(ctx, meta.JobId, models.ArtifactCreateOperation)
+	if err != nil {
+		return err
+	}
+	reader := &artifactStreamReader{stream: stream}
+	artifact, err := a.artifactService.Create(ctx, jobID, models.ResourceName(meta.GroupName), meta.Path, meta.Md5, reader, true)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(documents.MakeArtifact(requestCtx(ctx), artifact))
+	if err != nil {
+		return fmt.Errorf("error marshaling artifact: %w", err)
+	}
+	return stream.SendAndClose(&UploadArtifactResponse{Artifact: buf})
+}
+
+// WatchJobs sends an event each time a job the runner is running finishes, until the runner closes the
+// stream. Jobs are found by polling, since the server may be one of several and job status changes are
+// not broadcast between servers.
+func (a *RunnerAPI) WatchJobs(req *WatchJobsRequest, stream RunnerAPI_WatchJobsServer) error {
+	ctx := stream.Context()
+	runner, err := a.runnerService.ReadByIdentityID(ctx, nil, mustIdentityID(ctx))
+	if err != nil {
+		return err
+	}
+	running := make(map[models.JobID]bool)
+	ticker := time.NewTicker(WatchJobsPollInterval)
+	defer ticker.Stop()
+	for {
+		jobs, err := a.jobService.ListRunningByRunnerID(ctx, nil, runner.ID)
+		if err != nil {
+			return fmt.Errorf("error listing running jobs: %w", err)
+		}
+		stillRunning := make(map[models.JobID]bool, len(jobs))
+		for _, job := range jobs {
+			stillRunning[job.ID] = true
+		}
+		for jobID := range running {
+			if stillRunning[jobID] {
+				continue
+			}
+			job, err := a.jobService.Read(ctx, nil, jobID)
+			if err != nil {
+				return fmt.Errorf("error reading job: %w", err)
+			}
+			err = stream.Send(&JobEvent{JobId: jobID.String(), Status: job.Status.String()})
+			if err != nil {
+				return err
+			}
+		}
+		running = stillRunning
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// authorizedResourceID parses a resource ID sent by the runner, checks it is of the expected kind and
+// performs an access control check on it using the currently authenticated runner as the principal.
+func (a *RunnerAPI) authorizedResourceID(
+	ctx context.Context,
+	str string,
+	kind models.ResourceKind,
+	operation *models.Operation,
+) (models.ResourceID, error) {
+	id, err := models.ParseResourceID(str)
+	if err != nil || id.Kind() != kind {
+		return models.ResourceID{}, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid %s ID: %q", kind, str))
+	}
+	authorized, err := a.authorizationService.IsAuthorized(ctx, mustIdentityID(ctx), operation, id)
+	if err != nil {
+		return models.ResourceID{}, err
+	}
+	if !authorized {
+		return models.ResourceID{}, gerror.NewErrUnauthorized("Unauthorized")
+	}
+	return id, nil
+}
+
+func (a *RunnerAPI) authorizedJobID(ctx context.Context, str string, operation *models.Operation) (models.JobID, error) {
+	id, err := a.authorizedResourceID(ctx, str, models.JobResourceKind, operation)
+	if err != nil {
+		return models.JobID{}, err
+	}
+	return models.JobIDFromResourceID(id), nil
+}
+
+func (a *RunnerAPI) authorizedStepID(ctx context.Context, str string, operation *models.Operation) (models.StepID, error) {
+	id, err := a.authorizedResourceID(ctx, str, models.StepResourceKind, operation)
+	if err != nil {
+		return models.StepID{}, err
+	}
+	return models.StepIDFromResourceID(id), nil
+}
+
+// toModelError converts an error sent by the runner into a models.Error, or nil if no error was sent.
+func toModelError(e *Error) *models.Error {
+	if e == nil || e.Message == "" {
+		return nil
+	}
+	return models.NewError(errors.New(e.Message))
+}
+
+// requestCtx returns the context used to make links in documents sent to the runner, based on the
+// host the runner connected to.
+func requestCtx(ctx context.Context) *grpcRequestCtx {
+	host := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if authority := md.Get(":authority"); len(authority) > 0 {
+			host = authority[0]
+		}
+	}
+	return &grpcRequestCtx{host: host}
+}
+
+type grpcRequestCtx struct {
+	host string
+}
+
+func (r *grpcRequestCtx) BaseURL() string {
+	return fmt.Sprintf("https://%s", r.host)
+}
+
+// logStreamReader reads the log entries sent on a WriteLog stream as a single stream of JSON lines.
+type logStreamReader struct {
+	stream RunnerAPI_WriteLogServer
+	buf    []byte
+}
+
+func (r *logStreamReader) Read(p []byte) (int, error) {
+	err := r.fill()
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fill receives messages until there are entries to read. Returns io.EOF once the runner closes the stream.
+func (r *logStreamReader) fill() error {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return err
+		}
+		r.buf = req.Entries
+	}
+	return nil
+}
+
+// artifactStreamReader reads the data sent on an UploadArtifact stream after the metadata.
+type artifactStreamReader struct {
+	stream RunnerAPI_UploadArtifactServer
+	buf    []byte
+}
+
+func (r *artifactStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err // io.EOF once the runner closes the stream
+		}
+		if req.GetMetadata() != nil {
+			return 0, gerror.NewErrValidationFailed("Artifact metadata can only be sent in the first message")
+		}
+		r.buf = req.GetData()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,403 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: runner_api.proto
+
+package grpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// RunnerAPIClient is the client API for RunnerAPI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RunnerAPIClient interface {
+	// Dequeue waits for a job the runner can run and assigns it to the runner. Unlike the REST API the
+	// call blocks until a job is available or the deadline passes, so runners do not need to poll.
+	// Returns NOT_FOUND if no job became available before the deadline.
+	Dequeue(ctx context.Context, in *DequeueRequest, opts ...grpc.CallOption) (*DequeueResponse, error)
+	// UpdateJob sets the status or fingerprint of a job the runner is running.
+	UpdateJob(ctx context.Context, in *UpdateJobRequest, opts ...grpc.CallOption) (*UpdateJobResponse, error)
+	// UpdateStep sets the status of a step the runner is running.
+	UpdateStep(ctx context.Context, in *UpdateStepRequest, opts ...grpc.CallOption) (*UpdateStepResponse, error)
+	// WriteLog appends log entries to a log. The first message must set log_descriptor_id; entries are
+	// written as they arrive and the log is not sealed when the stream closes.
+	WriteLog(ctx context.Context, opts ...grpc.CallOption) (RunnerAPI_WriteLogClient, error)
+	// UploadArtifact creates an artifact for a job. The first message must set the artifact's metadata
+	// and later messages carry its data, in order.
+	UploadArtifact(ctx context.Context, opts ...grpc.CallOption) (RunnerAPI_UploadArtifactClient, error)
+	// WatchJobs notifies the runner when a job it is running is canceled (or otherwise finished by the
+	// server), so it can stop work immediately instead of discovering this on its next status update.
+	WatchJobs(ctx context.Context, in *WatchJobsRequest, opts ...grpc.CallOption) (RunnerAPI_WatchJobsClient, error)
+}
+
+type runnerAPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRunnerAPIClient(cc grpc.ClientConnInterface) RunnerAPIClient {
+	return &runnerAPIClient{cc}
+}
+
+func (c *runnerAPIClient) Dequeue(ctx context.Context, in *DequeueRequest, opts ...grpc.CallOption) (*DequeueResponse, error) {
+	out := new(DequeueResponse)
+	err := c.cc.Invoke(ctx, "/grpc.RunnerAPI/Dequeue", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runnerAPIClient) UpdateJob(ctx context.Context, in *UpdateJobRequest, opts ...grpc.CallOption) (*UpdateJobResponse, error) {
+	out := new(UpdateJobResponse)
+	err := c.cc.Invoke(ctx, "/grpc.RunnerAPI/UpdateJob", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runnerAPIClient) UpdateStep(ctx context.Context, in *UpdateStepRequest, opts ...grpc.CallOption) (*UpdateStepResponse, error) {
+	out := new(UpdateStepResponse)
+	err := c.cc.Invoke(ctx, "/grpc.RunnerAPI/UpdateStep", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runnerAPIClient) WriteLog(ctx context.Context, opts ...grpc.CallOption) (RunnerAPI_WriteLogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RunnerAPI_ServiceDesc.Streams[0], "/grpc.RunnerAPI/WriteLog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &runnerAPIWriteLogClient{stream}
+	return x, nil
+}
+
+type RunnerAPI_WriteLogClient interface {
+	Send(*WriteLogRequest) error
+	CloseAndRecv() (*WriteLogResponse, error)
+	grpc.ClientStream
+}
+
+type runnerAPIWriteLogClient struct {
+	grpc.ClientStream
+}
+
+func (x *runnerAPIWriteLogClient) Send(m *WriteLogRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *runnerAPIWriteLogClient) CloseAndRecv() (*WriteLogResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(WriteLogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *runnerAPIClient) UploadArtifact(ctx context.Context, opts ...grpc.CallOption) (RunnerAPI_UploadArtifactClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RunnerAPI_ServiceDesc.Streams[1], "/grpc.RunnerAPI/UploadArtifact", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &runnerAPIUploadArtifactClient{stream}
+	return x, nil
+}
+
+type RunnerAPI_UploadArtifactClient interface {
+	Send(*UploadArtifactRequest) error
+	CloseAndRecv() (*UploadArtifactResponse, error)
+	grpc.ClientStream
+}
+
+type runnerAPIUploadArtifactClient struct {
+	grpc.ClientStream
+}
+
+func (x *runnerAPIUploadArtifactClient) Send(m *UploadArtifactRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *runnerAPIUploadArtifactClient) CloseAndRecv() (*UploadArtifactResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadArtifactResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *runnerAPIClient) WatchJobs(ctx context.Context, in *WatchJobsRequest, opts ...grpc.CallOption) (RunnerAPI_WatchJobsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RunnerAPI_ServiceDesc.Streams[2], "/grpc.RunnerAPI/WatchJobs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &runnerAPIWatchJobsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RunnerAPI_WatchJobsClient interface {
+	Recv() (*JobEvent, error)
+	grpc.ClientStream
+}
+
+type runnerAPIWatchJobsClient struct {
+	grpc.ClientStream
+}
+
+func (x *runnerAPIWatchJobsClient) Recv() (*JobEvent, error) {
+	m := new(JobEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RunnerAPIServer is the server API for RunnerAPI service.
+// All implementations must embed UnimplementedRunnerAPIServer
+// for forward compatibility
+type RunnerAPIServer interface {
+	// Dequeue waits for a job the runner can run and assigns it to the runner. Unlike the REST API the
+	// call blocks until a job is available or the deadline passes, so runners do not need to poll.
+	// Returns NOT_FOUND if no job became available before the deadline.
+	Dequeue(context.Context, *DequeueRequest) (*DequeueResponse, error)
+	// UpdateJob sets the status or fingerprint of a job the runner is running.
+	UpdateJob(context.Context, *UpdateJobRequest) (*UpdateJobResponse, error)
+	// UpdateStep sets the status of a step the runner is running.
+	UpdateStep(context.Context, *UpdateStepRequest) (*UpdateStepResponse, error)
+	// WriteLog appends log entries to a log. The first message must set log_descriptor_id; entries are
+	// written as they arrive and the log is not sealed when the stream closes.
+	WriteLog(RunnerAPI_WriteLogServer) error
+	// UploadArtifact creates an artifact for a job. The first message must set the artifact's metadata
+	// and later messages carry its data, in order.
+	UploadArtifact(RunnerAPI_UploadArtifactServer) error
+	// WatchJobs notifies the runner when a job it is running is canceled (or otherwise finished by the
+	// server), so it can stop work immediately instead of discovering this on its next status update.
+	WatchJobs(*WatchJobsRequest, RunnerAPI_WatchJobsServer) error
+	mustEmbedUnimplementedRunnerAPIServer()
+}
+
+// UnimplementedRunnerAPIServer must be embedded to have forward compatible implementations.
+type UnimplementedRunnerAPIServer struct {
+}
+
+func (UnimplementedRunnerAPIServer) Dequeue(context.Context, *DequeueRequest) (*DequeueResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Dequeue not implemented")
+}
+func (UnimplementedRunnerAPIServer) UpdateJob(context.Context, *UpdateJobRequest) (*UpdateJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateJob not implemented")
+}
+func (UnimplementedRunnerAPIServer) UpdateStep(context.Context, *UpdateStepRequest) (*UpdateStepResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateStep not implemented")
+}
+func (UnimplementedRunnerAPIServer) WriteLog(RunnerAPI_WriteLogServer) error {
+	return status.Errorf(codes.Unimplemented, "method WriteLog not implemented")
+}
+func (UnimplementedRunnerAPIServer) UploadArtifact(RunnerAPI_UploadArtifactServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadArtifact not implemented")
+}
+func (UnimplementedRunnerAPIServer) WatchJobs(*WatchJobsRequest, RunnerAPI_WatchJobsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchJobs not implemented")
+}
+func (UnimplementedRunnerAPIServer) mustEmbedUnimplementedRunnerAPIServer() {}
+
+// UnsafeRunnerAPIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RunnerAPIServer will
+// result in compilation errors.
+type UnsafeRunnerAPIServer interface {
+	mustEmbedUnimplementedRunnerAPIServer()
+}
+
+func RegisterRunnerAPIServer(s grpc.ServiceRegistrar, srv RunnerAPIServer) {
+	s.RegisterService(&RunnerAPI_ServiceDesc, srv)
+}
+
+func _RunnerAPI_Dequeue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DequeueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerAPIServer).Dequeue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc.RunnerAPI/Dequeue",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerAPIServer).Dequeue(ctx, req.(*DequeueRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunnerAPI_UpdateJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerAPIServer).UpdateJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc.RunnerAPI/UpdateJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerAPIServer).UpdateJob(ctx, req.(*UpdateJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunnerAPI_UpdateStep_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateStepRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerAPIServer).UpdateStep(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc.RunnerAPI/UpdateStep",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerAPIServer).UpdateStep(ctx, req.(*UpdateStepRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunnerAPI_WriteLog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RunnerAPIServer).WriteLog(&runnerAPIWriteLogServer{stream})
+}
+
+type RunnerAPI_WriteLogServer interface {
+	SendAndClose(*WriteLogResponse) error
+	Recv() (*WriteLogRequest, error)
+	grpc.ServerStream
+}
+
+type runnerAPIWriteLogServer struct {
+	grpc.ServerStream
+}
+
+func (x *runnerAPIWriteLogServer) SendAndClose(m *WriteLogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *runnerAPIWriteLogServer) Recv() (*WriteLogRequest, error) {
+	m := new(WriteLogRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RunnerAPI_UploadArtifact_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RunnerAPIServer).UploadArtifact(&runnerAPIUploadArtifactServer{stream})
+}
+
+type RunnerAPI_UploadArtifactServer interface {
+	SendAndClose(*UploadArtifactResponse) error
+	Recv() (*UploadArtifactRequest, error)
+	grpc.ServerStream
+}
+
+type runnerAPIUploadArtifactServer struct {
+	grpc.ServerStream
+}
+
+func (x *runnerAPIUploadArtifactServer) SendAndClose(m *UploadArtifactResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *runnerAPIUploadArtifactServer) Recv() (*UploadArtifactRequest, error) {
+	m := new(UploadArtifactRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RunnerAPI_WatchJobs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchJobsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RunnerAPIServer).WatchJobs(m, &runnerAPIWatchJobsServer{stream})
+}
+
+type RunnerAPI_WatchJobsServer interface {
+	Send(*JobEvent) error
+	grpc.ServerStream
+}
+
+type runnerAPIWatchJobsServer struct {
+	grpc.ServerStream
+}
+
+func (x *runnerAPIWatchJobsServer) Send(m *JobEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RunnerAPI_ServiceDesc is the grpc.ServiceDesc for RunnerAPI service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RunnerAPI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc.RunnerAPI",
+	HandlerType: (*RunnerAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Dequeue",
+			Handler:    _RunnerAPI_Dequeue_Handler,
+		},
+		{
+			MethodName: "UpdateJob",
+			Handler:    _RunnerAPI_UpdateJob_Handler,
+		},
+		{
+			MethodName: "UpdateStep",
+			Handler:    _RunnerAPI_UpdateStep_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WriteLog",
+			Handler:       _RunnerAPI_WriteLog_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "UploadArtifact",
+			Handler:       _RunnerAPI_UploadArtifact_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchJobs",
+			Handler:       _RunnerAPI_WatchJobs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "runner_api.proto",
+}
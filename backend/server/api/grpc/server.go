@@ -0,0 +1,216 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+type ServerConfig struct {
+	// Address to listen on for the gRPC runner API, or empty to not serve it.
+	Address string
+	// TLSConfig for the server certificate. Normally the same as the REST runner API's TLS config, so that
+	// runners can verify both servers with the same CA certificate.
+	TLSConfig *server.TLSConfig
+}
+
+// Server serves the gRPC runner API. Runners must authenticate using a registered client certificate
+// via TLS mutual auth, exactly as for the REST runner API.
+type Server struct {
+	grpcServer            *grpc.Server
+	listener              net.Listener
+	authenticationService services.AuthenticationService
+	config                ServerConfig
+	log                   logger.Log
+}
+
+func NewServer(
+	runnerAPI *RunnerAPI,
+	config ServerConfig,
+	authenticationService services.AuthenticationService,
+	logFactory logger.LogFactory,
+) (*Server, error) {
+	s := &Server{
+		authenticationService: authenticationService,
+		config:                config,
+		log:                   logFactory("RunnerGRPCAPIServer"),
+	}
+	if config.Address == "" {
+		return s, nil
+	}
+	if config.TLSConfig == nil {
+		return nil, fmt.Errorf("error TLS must be configured for the gRPC runner API")
+	}
+	if config.TLSConfig.AutoCreateCertificate.Bool() {
+		// Create a self-signed server certificate if we don't have a certificate already configured
+		created, err := certificates.GenerateServerSelfSignedCertificate(
+			config.TLSConfig.CertificateFile,
+			config.TLSConfig.PrivateKeyFile,
+			config.TLSConfig.AutoCreatedCertificateHost,
+			config.TLSConfig.AutoCreatedCertificateOrganization,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error ensuring server certificate exists: %w", err)
+		}
+		if created {
+			s.log.Infof("Created private key file and certificate for server")
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(config.TLSConfig.CertificateFile.String(), config.TLSConfig.PrivateKeyFile.String())
+	if err != nil {
+		return nil, fmt.Errorf("error loading server certificate: %w", err)
+	}
+	// Allow any client cert to be used to establish a TLS connection, then check in the interceptors that
+	// the specific certificate is registered.
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	s.grpcServer = grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	)
+	RegisterRunnerAPIServer(s.grpcServer, runnerAPI)
+	return s, nil
+}
+
+// Start starts listening on the configured address and serving requests on a goroutine, so this function
+// returns immediately. Does nothing if no address is configured.
+func (s *Server) Start() {
+	if s.grpcServer == nil {
+		s.log.Infof("No address configured; gRPC runner API will not be served")
+		return
+	}
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		// If we can't start the server then log an error and terminate the process, as for the HTTP servers
+		s.log.Fatalf("Error starting gRPC server: %s", err)
+	}
+	s.listener = listener
+	s.log.Infof("gRPC listening on %s", listener.Addr())
+	go func() {
+		err := s.grpcServer.Serve(listener)
+		if err != nil && err != grpc.ErrServerStopped {
+			s.log.Errorf("Error serving gRPC requests: %s", err)
+		}
+	}()
+}
+
+// Stop shuts down the server gracefully, waiting for in-flight calls to finish until ctx is done and then
+// canceling any calls that remain. Stop should only be called once.
+func (s *Server) Stop(ctx context.Context) error {
+	if s.grpcServer == nil {
+		return nil
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+	}
+	return nil
+}
+
+// GetAddress returns the address the server is listening on, or an empty string if it hasn't been started.
+func (s *Server) GetAddress() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+type identityIDContextKey struct{}
+
+// mustIdentityID returns the ID of the runner identity that made the call. Panics if the call was not
+// authenticated, which would be a bug since every call passes through the interceptors.
+func mustIdentityID(ctx context.Context) models.IdentityID {
+	return ctx.Value(identityIDContextKey{}).(models.IdentityID)
+}
+
+// authenticate checks the client certificate used to make the call is registered as a runner's credential.
+// Returns a context recording the runner's identity.
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, gerror.NewErrUnauthorized("Client certificate required")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil, gerror.NewErrUnauthorized("Client certificate required")
+	}
+	// The first certificate in the list is the 'leaf' certificate whose public key the connection was verified against
+	leafClientCert := tlsInfo.State.PeerCertificates[0]
+	// Only self-signed certificates are supported, as for the REST runner API
+	err := leafClientCert.CheckSignatureFrom(leafClientCert)
+	if err != nil {
+		return nil, gerror.NewErrUnauthorized("Invalid client certificate").Wrap(
+			fmt.Errorf("error verifying that client certificate is self-signed: %w", err))
+	}
+	identity, err := s.authenticationService.AuthenticateClientCertificate(ctx, leafClientCert.Raw)
+	if err != nil {
+		return nil, gerror.NewErrUnauthorized("Invalid client certificate").Wrap(
+			fmt.Errorf("error authenticating client: %w", err))
+	}
+	return context.WithValue(ctx, identityIDContextKey{}, identity.ID), nil
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, s.toStatusError(info.FullMethod, err)
+	}
+	res, err := handler(ctx, req)
+	if err != nil {
+		return nil, s.toStatusError(info.FullMethod, err)
+	}
+	return res, nil
+}
+
+func (s *Server) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return s.toStatusError(info.FullMethod, err)
+	}
+	err = handler(srv, &authenticatedServerStream{ServerStream: ss, ctx: ctx})
+	if err != nil {
+		return s.toStatusError(info.FullMethod, err)
+	}
+	return nil
+}
+
+// toStatusError logs an error returned by a call and converts it to a status error to return to the runner.
+// Do not log 'not found', 'Runner Disabled' or 'Runner Unhealthy' errors as warnings - these are normal
+// states when there's nothing in the queue or the runner can't currently be given jobs.
+func (s *Server) toStatusError(method string, err error) error {
+	if !gerror.IsNotFound(err) && !gerror.IsRunnerDisabled(err) && !gerror.IsRunnerUnhealthy(err) {
+		s.log.Warnf("Error in gRPC call %s: %v", method, err)
+	}
+	return ToStatusError(err)
+}
+
+// authenticatedServerStream is a grpc.ServerStream whose context records the authenticated runner identity.
+type authenticatedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -98,3 +98,29 @@ func (a *APIClient) SearchArtifacts(ctx context.Context, buildID models.BuildID,
 	paginator := newArtifactSearchPaginator(a, url, doc)
 	return paginator, nil
 }
+
+// SearchBuildArtifacts searches all artifacts for a build via the core API, for clients authenticated as a user
+// (e.g. using a personal access token) rather than as a runner. Use pager to page through results, if any.
+func (a *APIClient) SearchBuildArtifacts(ctx context.Context, buildID models.BuildID, search *models.ArtifactSearch) (models.ArtifactSearchPaginator, error) {
+	doc := &documents.ArtifactSearchRequest{
+		ArtifactSearch: search,
+	}
+	url := fmt.Sprintf("/api/v1/builds/%s/artifacts/search", buildID)
+	paginator := newArtifactSearchPaginator(a, url, doc)
+	return paginator, nil
+}
+
+// GetBuildArtifactData returns a reader to the data of an artifact via the core API, for clients authenticated
+// as a user rather than as a runner. It is the callers responsibility to close the reader.
+func (a *APIClient) GetBuildArtifactData(ctx context.Context, artifactID models.ArtifactID) (io.ReadCloser, error) {
+	url := fmt.Sprintf("/api/v1/artifacts/%s/data", artifactID)
+	code, _, body, err := a.getStream(ctx, nil, url)
+	if err != nil {
+		return nil, err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		body.Close()
+		return nil, a.makeHTTPError(code, nil)
+	}
+	return body, nil
+}
@@ -29,3 +29,39 @@ func (a *APIClient) GetBuildSummary(ctx context.Context, legalEntityID models.Le
 	}
 	return doc, nil
 }
+
+// GetBuild gets a build by ID, along with its jobs and steps.
+func (a *APIClient) GetBuild(ctx context.Context, buildID models.BuildID) (*documents.BuildGraph, error) {
+	url := fmt.Sprintf("/api/v1/builds/%s", buildID)
+	code, _, body, err := a.get(ctx, nil, url)
+	if err != nil {
+		return nil, err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		return nil, a.makeHTTPError(code, body)
+	}
+	doc := &documents.BuildGraph{}
+	err = json.Unmarshal(body, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response body: %s", string(body[:]))
+	}
+	return doc, nil
+}
+
+// CreateBuild queues a new build in a repo, as described by req.
+func (a *APIClient) CreateBuild(ctx context.Context, repoID models.RepoID, req *documents.CreateBuildRequest) (*documents.BuildGraph, error) {
+	url := fmt.Sprintf("/api/v1/repos/%s/builds", repoID)
+	code, _, body, err := a.post(ctx, nil, url, req)
+	if err != nil {
+		return nil, fmt.Errorf("error in request: %w", err)
+	}
+	if !a.isOneOf(code, []int{http.StatusOK, http.StatusCreated}) {
+		return nil, a.makeHTTPError(code, body)
+	}
+	doc := &documents.BuildGraph{}
+	err = json.Unmarshal(body, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response body: %s", string(body[:]))
+	}
+	return doc, nil
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// FindCache finds the cache entry to restore for the named cache, preferring an entry with the specified key.
+// Returns a not found error if there are no entries for the cache.
+func (a *APIClient) FindCache(ctx context.Context, jobID models.JobID, name models.ResourceName, key string) (*documents.CacheEntry, error) {
+	url := fmt.Sprintf("/api/v1/runner/jobs/%s/caches/%s?key=%s", jobID, url.PathEscape(name.String()), url.QueryEscape(key))
+	code, _, body, err := a.get(ctx, nil, url)
+	if err != nil {
+		return nil, err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		return nil, a.makeHTTPError(code, body)
+	}
+	resDoc := &documents.CacheEntry{}
+	err = json.Unmarshal(body, resDoc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response body: %s", string(body[:]))
+	}
+	return resDoc, nil
+}
+
+// GetCacheEntryData returns a reader to the tarball for a cache entry.
+// It is the callers responsibility to close the reader.
+func (a *APIClient) GetCacheEntryData(ctx context.Context, jobID models.JobID, cacheEntryID models.CacheEntryID) (io.ReadCloser, error) {
+	url := fmt.Sprintf("/api/v1/runner/jobs/%s/cache-entries/%s/data", jobID, cacheEntryID)
+	code, _, body, err := a.getStream(ctx, nil, url)
+	if err != nil {
+		return nil, err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		body.Close()
+		return nil, a.makeHTTPError(code, nil)
+	}
+	return body, nil
+}
+
+// SaveCache saves a new entry for the named cache with the specified key, reading the tarball from reader.
+// If an entry with the same key already exists the existing entry is returned.
+func (a *APIClient) SaveCache(ctx context.Context, jobID models.JobID, name models.ResourceName, key string, reader io.ReadSeeker) (*documents.CacheEntry, error) {
+	url := fmt.Sprintf("/api/v1/runner/jobs/%s/caches/%s?key=%s", jobID, url.PathEscape(name.String()), url.QueryEscape(key))
+	code, _, body, err := a.putStream(ctx, nil, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("error in request: %w", err)
+	}
+	defer body.Close()
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+	if !a.isOneOf(code, []int{http.StatusOK, http.StatusCreated}) {
+		return nil, a.makeHTTPError(code, buf)
+	}
+	resDoc := &documents.CacheEntry{}
+	err = json.Unmarshal(buf, resDoc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response body: %s", string(buf[:]))
+	}
+	return resDoc, nil
+}
@@ -13,10 +13,13 @@ import (
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
 )
 
@@ -107,6 +110,14 @@ func (a *APIClient) postStream(ctx context.Context, headers http.Header, pathOrU
 	return a.doRequestStream(ctx, headers, "POST", pathOrURL, data)
 }
 
+// putStream performs a basic HTTP PUT request. If a path is specified then a url will be made using the currently
+// configured endpoints. If a full url is specified it will be used directly. Returns the HTTP status code,
+// headers and response body. Returns an error if there was a problem making the request. No status code
+// inspection is made.
+func (a *APIClient) putStream(ctx context.Context, headers http.Header, pathOrURL string, data io.ReadSeeker) (int, http.Header, io.ReadCloser, error) {
+	return a.doRequestStream(ctx, headers, "PUT", pathOrURL, data)
+}
+
 // delete performs a basic HTTP DELETE request. If a path is specified then a url will be made using the currently
 // configured endpoints. If a full url is specified it will be used directly. Returns the HTTP status code,
 // headers and buffered response body. Returns an error if there was a problem making the request. No status code
@@ -152,6 +163,9 @@ func (a *APIClient) doRequestStream(ctx context.Context, headers http.Header, ve
 	if err != nil {
 		return -1, nil, nil, errors.Wrap(err, "error making request")
 	}
+	ctx, span := tracing.StartSpan(ctx, fmt.Sprintf("HTTP %s", verb), trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(attribute.String("http.method", verb), attribute.String("http.url", endpoint))
 	req = req.WithContext(ctx)
 	if a.authenticator != nil {
 		req.Header, err = a.authenticator.AuthenticateRequest(req.Header)
@@ -167,10 +181,14 @@ func (a *APIClient) doRequestStream(ctx context.Context, headers http.Header, ve
 		}
 	}
 	req.Header.Set("Content-Type", "application/json")
+	// Propagate the trace so server-side spans are recorded as children of the caller's span
+	tracing.Inject(ctx, req.Header)
 	res, err := a.retryableClient.Do(req)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return -1, nil, nil, errors.Wrap(err, "error during request")
 	}
+	span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
 	return res.StatusCode, res.Header, res.Body, nil
 }
 
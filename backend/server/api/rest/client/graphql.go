@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// GraphQL runs a GraphQL query against the server. Errors resolving individual fields are returned in
+// the response rather than as an error.
+func (a *APIClient) GraphQL(ctx context.Context, query string, variables map[string]interface{}) (*documents.GraphQLResponse, error) {
+	req := &documents.GraphQLRequest{Query: query, Variables: variables}
+	code, _, body, err := a.post(ctx, nil, "/api/v1/graphql", req)
+	if err != nil {
+		return nil, err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		return nil, a.makeHTTPError(code, body)
+	}
+	doc := &documents.GraphQLResponse{}
+	err = json.Unmarshal(body, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response body: %s", string(body[:]))
+	}
+	return doc, nil
+}
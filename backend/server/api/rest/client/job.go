@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// GetJob gets a job by ID.
+func (a *APIClient) GetJob(ctx context.Context, jobID models.JobID) (*documents.Job, error) {
+	url := fmt.Sprintf("/api/v1/jobs/%s", jobID)
+	code, _, body, err := a.get(ctx, nil, url)
+	if err != nil {
+		return nil, err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		return nil, a.makeHTTPError(code, body)
+	}
+	doc := &documents.Job{}
+	err = json.Unmarshal(body, doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response body: %s", string(body[:]))
+	}
+	return doc, nil
+}
@@ -114,3 +114,26 @@ func (a *APIClient) OpenLogReadStream(ctx context.Context, logID models.LogDescr
 	}
 	return body, nil
 }
+
+// ReadLogData opens a read stream to a log's data via the core API, for clients authenticated as a user
+// (e.g. using a personal access token) rather than as a runner. It is the callers responsibility to close the reader.
+func (a *APIClient) ReadLogData(ctx context.Context, logID models.LogDescriptorID, search *documents.LogSearchRequest) (io.ReadCloser, error) {
+	endpoint, err := a.getRequestEndpoint(fmt.Sprintf("/api/v1/logs/%s/data", logID))
+	if err != nil {
+		return nil, fmt.Errorf("error getting request endpoint: %w", err)
+	}
+	url, err := url2.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request endpoint: %w", err)
+	}
+	url.RawQuery = search.GetQuery().Encode()
+	code, _, body, err := a.getStream(ctx, nil, url.String())
+	if err != nil {
+		return nil, err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		body.Close()
+		return nil, a.makeHTTPError(code, nil)
+	}
+	return body, nil
+}
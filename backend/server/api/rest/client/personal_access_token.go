@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+)
+
+// CreatePersonalAccessToken creates a new personal access token for the authenticated user.
+// The token is only included in the returned document; it can't be read again later.
+func (a *APIClient) CreatePersonalAccessToken(
+	ctx context.Context,
+	name string,
+	scopes models.TokenScopes,
+	expiresAt models.Time,
+) (*documents.PersonalAccessToken, error) {
+	url := "/api/v1/user/access-tokens"
+	doc := &documents.CreatePersonalAccessTokenRequest{
+		Name:      name,
+		Scopes:    scopes,
+		ExpiresAt: &expiresAt,
+	}
+	code, _, body, err := a.post(ctx, nil, url, doc)
+	if err != nil {
+		return nil, fmt.Errorf("error in request: %w", err)
+	}
+	if !a.isOneOf(code, []int{http.StatusOK, http.StatusCreated}) {
+		return nil, a.makeHTTPError(code, body)
+	}
+	resDoc := &documents.PersonalAccessToken{}
+	err = json.Unmarshal(body, resDoc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response body: %s", string(body[:]))
+	}
+	return resDoc, nil
+}
+
+// RevokePersonalAccessToken permanently deletes one of the authenticated user's personal access tokens.
+func (a *APIClient) RevokePersonalAccessToken(ctx context.Context, credentialID models.CredentialID) error {
+	url := fmt.Sprintf("/api/v1/user/access-tokens/%s", credentialID)
+	code, _, body, err := a.delete(ctx, nil, url)
+	if err != nil {
+		return fmt.Errorf("error in request: %w", err)
+	}
+	if !a.isOneOf(code, []int{http.StatusOK, http.StatusNoContent}) {
+		return a.makeHTTPError(code, body)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -13,8 +14,13 @@ import (
 )
 
 // Dequeue returns the next build job that is ready to be executed, or nil if there are currently no queued builds.
-func (a *APIClient) Dequeue(ctx context.Context) (*documents.RunnableJob, error) {
+// If wait is non-zero and no job is ready, the server waits for up to wait for a job to become ready before
+// returning; servers that don't support waiting return straight away.
+func (a *APIClient) Dequeue(ctx context.Context, wait time.Duration) (*documents.RunnableJob, error) {
 	url := "/api/v1/runner/queue"
+	if wait > 0 {
+		url = fmt.Sprintf("%s?wait=%d", url, int(wait.Seconds()))
+	}
 	code, _, body, err := a.get(ctx, nil, url)
 
 	if err != nil {
@@ -69,6 +75,21 @@ func (a *APIClient) SendRuntimeInfo(ctx context.Context, info *documents.PatchRu
 	return nil
 }
 
+// SendHealthReport sends a report on the health of this runner's host to the server. The server will not
+// give the runner jobs to run while the most recent report shows problems with the host.
+func (a *APIClient) SendHealthReport(ctx context.Context, report *models.RunnerHealthReport) error {
+	url := "/api/v1/runner/health"
+	code, _, body, err := a.put(ctx, nil, url, &documents.PutRunnerHealthRequest{RunnerHealthReport: report})
+	if err != nil {
+		return err
+	}
+	if !a.isOneOf(code, []int{http.StatusOK}) {
+		return a.makeHTTPError(code, body)
+	}
+
+	return nil
+}
+
 // UpdateJobStatus updates the status of the specified job.
 // If the status is finished, err can be supplied to signal the job failed with an error
 // or nil to signify the job succeeded.
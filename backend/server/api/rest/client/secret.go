@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 
 	"github.com/pkg/errors"
 
@@ -17,9 +18,13 @@ type paginatedSecretResponse struct {
 	Results []*models.SecretPlaintext `json:"results"` // TODO these should be documents
 }
 
-// GetSecretsPlaintext gets all secrets for the specified repo in plaintext.
-func (a *APIClient) GetSecretsPlaintext(ctx context.Context, repoID models.RepoID) ([]*models.SecretPlaintext, error) {
+// GetSecretsPlaintext gets all secrets for the specified repo in plaintext. If pinToBuildID is set the secrets
+// are returned as they were when that build started, otherwise the latest versions are returned.
+func (a *APIClient) GetSecretsPlaintext(ctx context.Context, repoID models.RepoID, pinToBuildID *models.BuildID) ([]*models.SecretPlaintext, error) {
 	url := fmt.Sprintf("/api/v1/runner/repos/%s/secrets", repoID)
+	if pinToBuildID != nil {
+		url = fmt.Sprintf("%s?build_id=%s", url, neturl.QueryEscape(pinToBuildID.String()))
+	}
 	code, _, body, err := a.get(ctx, nil, url)
 	if err != nil {
 		return nil, err
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
 )
@@ -29,6 +30,11 @@ type Artifact struct {
 	HashType models.HashType `json:"hash_type"`
 	// Hash is the hex-encoded hash of the artifact data. This This may be set later if the hash is not known yet.
 	Hash string `json:"hash"`
+	// SHA256 is the hex-encoded SHA-256 hash of the artifact data, or empty if not known yet.
+	SHA256 string `json:"sha256"`
+	// Signature is the base64-encoded signature over the artifact's SHA-256 hash made with the server's artifact
+	// signing key, or empty if the artifact was not signed.
+	Signature string `json:"signature,omitempty"`
 	// Size of the artifact file in bytes.
 	Size uint64 `json:"size"`
 	// Mime type of the artifact, or empty if not known.
@@ -38,8 +44,14 @@ type Artifact struct {
 	// NOTE: If sealed is false it doesn't necessarily mean no data has been uploaded to the blob store yet, and so
 	// we must still verify that the backing data is deleted before garbage collecting unsealed artifact files.
 	Sealed bool `json:"sealed"`
-
-	DataURL string `json:"data_url"`
+	// ScanStatus records the outcome of scanning the artifact data for viruses and other malware.
+	// The data for artifacts with a scan status of "infected" can not be downloaded.
+	ScanStatus models.ArtifactScanStatus `json:"scan_status"`
+	// ScanResult is the name of the threat found by the scanner if the artifact is infected, otherwise empty.
+	ScanResult string `json:"scan_result,omitempty"`
+
+	DataURL         string `json:"data_url"`
+	VerificationURL string `json:"verification_url"`
 }
 
 func MakeArtifact(rctx routes.RequestContext, artifact *models.Artifact) *Artifact {
@@ -53,17 +65,22 @@ func MakeArtifact(rctx routes.RequestContext, artifact *models.Artifact) *Artifa
 		UpdatedAt: artifact.UpdatedAt,
 		ETag:      artifact.ETag,
 
-		Name:      artifact.Name,
-		JobID:     artifact.JobID,
-		GroupName: artifact.GroupName,
-		Path:      artifact.Path,
-		HashType:  artifact.HashType,
-		Hash:      artifact.Hash,
-		Size:      artifact.Size,
-		Mime:      artifact.Mime,
-		Sealed:    artifact.Sealed,
-
-		DataURL: routes.MakeArtifactsDataLink(rctx, artifact.ID),
+		Name:       artifact.Name,
+		JobID:      artifact.JobID,
+		GroupName:  artifact.GroupName,
+		Path:       artifact.Path,
+		HashType:   artifact.HashType,
+		Hash:       artifact.Hash,
+		SHA256:     artifact.SHA256,
+		Signature:  artifact.Signature,
+		Size:       artifact.Size,
+		Mime:       artifact.Mime,
+		Sealed:     artifact.Sealed,
+		ScanStatus: artifact.ScanStatus,
+		ScanResult: artifact.ScanResult,
+
+		DataURL:         routes.MakeArtifactsDataLink(rctx, artifact.ID),
+		VerificationURL: routes.MakeArtifactVerificationLink(rctx, artifact.ID),
 	}
 }
 
@@ -87,6 +104,18 @@ func (m *Artifact) GetCreatedAt() models.Time {
 	return m.CreatedAt
 }
 
+type ArtifactVerification struct {
+	*models.ArtifactVerification
+	URL string `json:"url"`
+}
+
+func MakeArtifactVerification(rctx routes.RequestContext, verification *models.ArtifactVerification) *ArtifactVerification {
+	return &ArtifactVerification{
+		ArtifactVerification: verification,
+		URL:                  routes.MakeArtifactVerificationLink(rctx, verification.ArtifactID),
+	}
+}
+
 type ArtifactSearchRequest struct {
 	*models.ArtifactSearch
 }
@@ -101,6 +130,15 @@ func (d *ArtifactSearchRequest) Bind(r *http.Request) error {
 
 func (d *ArtifactSearchRequest) GetQuery() url.Values {
 	values := makePaginationQueryParams(d.Pagination)
+	if d.Workflow != nil && *d.Workflow == "" && d.JobName != nil && *d.JobName != "" {
+		// An empty workflow query parameter means any workflow, so the default workflow is selected with a path
+		groupName := models.ArtifactPathWildcard
+		if d.GroupName != nil && *d.GroupName != "" {
+			groupName = d.GroupName.String()
+		}
+		values.Set("path", url.QueryEscape(fmt.Sprintf("%s.%s", d.JobName, groupName)))
+		return values
+	}
 	if d.Workflow != nil && *d.Workflow != "" {
 		values.Set("workflow", url.QueryEscape(d.Workflow.String()))
 	}
@@ -120,14 +158,72 @@ func (d *ArtifactSearchRequest) FromQuery(values url.Values) error {
 	}
 	d.Pagination = pagination
 
-	vals, ok := values["workflow"]
+	err = parseArtifactSearchFilters(values, d.ArtifactSearch)
+	if err != nil {
+		return err
+	}
+	return d.Validate()
+}
+
+func (d *ArtifactSearchRequest) Next(cursor *models.DirectionalCursor) PaginatedRequest {
+	d.Cursor = cursor
+	return d
+}
+
+// ArtifactBundleRequest selects the artifacts to download together as a single archive, and the archive format.
+type ArtifactBundleRequest struct {
+	*models.ArtifactSearch
+	Format models.ArtifactBundleFormat `json:"format"`
+}
+
+func NewArtifactBundleRequest() *ArtifactBundleRequest {
+	return &ArtifactBundleRequest{
+		ArtifactSearch: models.NewArtifactSearch(),
+		Format:         models.ArtifactBundleFormatZip,
+	}
+}
+
+func (d *ArtifactBundleRequest) FromQuery(values url.Values) error {
+	vals, ok := values["format"]
+	if ok && len(vals) > 0 {
+		val, err := url.QueryUnescape(vals[0])
+		if err != nil {
+			return fmt.Errorf("error unescaping format: %w", err)
+		}
+		d.Format = models.ArtifactBundleFormat(val)
+	}
+	if !d.Format.Valid() {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Unsupported artifact bundle format %q; expected %q or %q",
+			d.Format, models.ArtifactBundleFormatZip, models.ArtifactBundleFormatTarGz))
+	}
+	return parseArtifactSearchFilters(values, d.ArtifactSearch)
+}
+
+// parseArtifactSearchFilters sets the workflow, job name and group name filters on search from query values,
+// either given separately or together as an artifact path (see models.ArtifactSearch.SetPath).
+func parseArtifactSearchFilters(values url.Values, search *models.ArtifactSearch) error {
+	vals, ok := values["path"]
+	if ok && len(vals) > 0 {
+		if values.Has("workflow") || values.Has("job_name") || values.Has("group_name") {
+			return gerror.NewErrValidationFailed("Artifact path can't be combined with workflow, job_name or group_name")
+		}
+		val, err := url.QueryUnescape(vals[0])
+		if err != nil {
+			return fmt.Errorf("error unescaping path: %w", err)
+		}
+		return search.SetPath(val)
+	}
+	vals, ok = values["workflow"]
 	if ok && len(vals) > 0 {
 		val, err := url.QueryUnescape(vals[0])
 		if err != nil {
 			return fmt.Errorf("error unescaping workflow: %w", err)
 		}
-		workflow := models.ResourceName(val)
-		d.Workflow = &workflow
+		// Clients send an empty workflow to search all workflows; the default workflow is selected with a path
+		if val != "" {
+			workflow := models.ResourceName(val)
+			search.Workflow = &workflow
+		}
 	}
 	vals, ok = values["job_name"]
 	if ok && len(vals) > 0 {
@@ -136,7 +232,7 @@ func (d *ArtifactSearchRequest) FromQuery(values url.Values) error {
 			return fmt.Errorf("error unescaping job name: %w", err)
 		}
 		name := models.ResourceName(val)
-		d.JobName = &name
+		search.JobName = &name
 	}
 	vals, ok = values["group_name"]
 	if ok && len(vals) > 0 {
@@ -145,12 +241,7 @@ func (d *ArtifactSearchRequest) FromQuery(values url.Values) error {
 			return fmt.Errorf("error unescaping group name: %w", err)
 		}
 		name := models.ResourceName(val)
-		d.GroupName = &name
+		search.GroupName = &name
 	}
-	return d.Validate()
-}
-
-func (d *ArtifactSearchRequest) Next(cursor *models.DirectionalCursor) PaginatedRequest {
-	d.Cursor = cursor
-	return d
+	return nil
 }
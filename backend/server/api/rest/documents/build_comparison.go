@@ -0,0 +1,210 @@
+package documents
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// ComparisonChange describes how something differs between the base build and the head build of a comparison.
+type ComparisonChange string
+
+const (
+	// ComparisonChangeAdded means it is only in the head build.
+	ComparisonChangeAdded ComparisonChange = "added"
+	// ComparisonChangeRemoved means it is only in the base build.
+	ComparisonChangeRemoved ComparisonChange = "removed"
+	// ComparisonChangeChanged means it is in both builds, but has changed: for jobs the fingerprint changed,
+	// and for artifacts the size changed.
+	ComparisonChangeChanged ComparisonChange = "changed"
+	// ComparisonChangeUnchanged means it is in both builds, and has not changed.
+	ComparisonChangeUnchanged ComparisonChange = "unchanged"
+)
+
+// BuildComparison describes what changed between two builds of the same repo, from the base build to the head build.
+type BuildComparison struct {
+	baseResourceDocument
+
+	Base *Build `json:"base"`
+	Head *Build `json:"head"`
+	// DurationDeltaMillis is how much longer the head build ran for than the base build, in milliseconds,
+	// or null if either build hasn't finished.
+	DurationDeltaMillis *int64 `json:"duration_delta_millis"`
+	// Jobs compares each job that appears in either build, matched by workflow and job name.
+	Jobs []*JobComparison `json:"jobs"`
+	// Artifacts lists the artifacts that were added, removed or changed size, matched by job, group and path.
+	Artifacts []*ArtifactComparison `json:"artifacts"`
+	// NewTestFailures lists the tests that failed in the head build but not in the base build.
+	NewTestFailures []*TestCase `json:"new_test_failures"`
+	// ResolvedTestFailures lists the tests that failed in the base build but not in the head build.
+	ResolvedTestFailures []*TestCase `json:"resolved_test_failures"`
+}
+
+// JobComparison compares a job in the base build with the job with the same workflow and name in the head build.
+type JobComparison struct {
+	// ID is the fully qualified name of the job.
+	ID       string              `json:"id"`
+	Workflow models.ResourceName `json:"workflow"`
+	Name     models.ResourceName `json:"name"`
+	Change   ComparisonChange    `json:"change"`
+
+	BaseJobID  models.JobID          `json:"base_job_id"`
+	HeadJobID  models.JobID          `json:"head_job_id"`
+	BaseStatus models.WorkflowStatus `json:"base_status,omitempty"`
+	HeadStatus models.WorkflowStatus `json:"head_status,omitempty"`
+	// BaseDurationMillis and HeadDurationMillis are how long the job ran for in each build, or null if it didn't
+	// finish running in that build.
+	BaseDurationMillis *int64 `json:"base_duration_millis"`
+	HeadDurationMillis *int64 `json:"head_duration_millis"`
+	// DurationDeltaMillis is how much longer the job ran for in the head build, or null if it didn't finish
+	// running in both builds.
+	DurationDeltaMillis *int64 `json:"duration_delta_millis"`
+	BaseFingerprint     string `json:"base_fingerprint,omitempty"`
+	HeadFingerprint     string `json:"head_fingerprint,omitempty"`
+	// FingerprintChanged is true if the job has a fingerprint in both builds, and it changed.
+	FingerprintChanged bool `json:"fingerprint_changed"`
+}
+
+// ArtifactComparison compares an artifact in the base build with the artifact produced by the same job, in
+// the same group and with the same path in the head build.
+type ArtifactComparison struct {
+	// Job is the fully qualified name of the job that produced the artifact.
+	Job       string              `json:"job"`
+	GroupName models.ResourceName `json:"group_name"`
+	Path      string              `json:"path"`
+	Change    ComparisonChange    `json:"change"`
+
+	BaseArtifactID models.ArtifactID `json:"base_artifact_id"`
+	HeadArtifactID models.ArtifactID `json:"head_artifact_id"`
+	BaseSize       *uint64           `json:"base_size"`
+	HeadSize       *uint64           `json:"head_size"`
+	// SizeDeltaBytes is how much larger the artifact is in the head build, treating a missing artifact as empty.
+	SizeDeltaBytes int64 `json:"size_delta_bytes"`
+}
+
+func MakeBuildComparison(rctx routes.RequestContext, comparison *dto.BuildComparison) *BuildComparison {
+	jobs := make([]*JobComparison, len(comparison.Jobs))
+	for i, job := range comparison.Jobs {
+		jobs[i] = makeJobComparison(job)
+	}
+	artifacts := make([]*ArtifactComparison, len(comparison.Artifacts))
+	for i, artifact := range comparison.Artifacts {
+		artifacts[i] = makeArtifactComparison(artifact)
+	}
+	newTestFailures := MakeTestCases(rctx, comparison.NewTestFailures)
+	if newTestFailures == nil {
+		newTestFailures = []*TestCase{}
+	}
+	resolvedTestFailures := MakeTestCases(rctx, comparison.ResolvedTestFailures)
+	if resolvedTestFailures == nil {
+		resolvedTestFailures = []*TestCase{}
+	}
+	return &BuildComparison{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeBuildComparisonLink(rctx, comparison.Head.ID, comparison.Base.ID),
+		},
+		Base:                 MakeBuild(rctx, comparison.Base),
+		Head:                 MakeBuild(rctx, comparison.Head),
+		DurationDeltaMillis:  makeDurationDeltaMillis(&comparison.Base.Timings, &comparison.Head.Timings),
+		Jobs:                 jobs,
+		Artifacts:            artifacts,
+		NewTestFailures:      newTestFailures,
+		ResolvedTestFailures: resolvedTestFailures,
+	}
+}
+
+func makeJobComparison(comparison *dto.JobComparison) *JobComparison {
+	doc := &JobComparison{
+		ID:                 comparison.FQN.String(),
+		Workflow:           comparison.FQN.WorkflowName,
+		Name:               comparison.FQN.JobName,
+		FingerprintChanged: comparison.FingerprintChanged(),
+	}
+	switch {
+	case comparison.Base == nil:
+		doc.Change = ComparisonChangeAdded
+	case comparison.Head == nil:
+		doc.Change = ComparisonChangeRemoved
+	case doc.FingerprintChanged:
+		doc.Change = ComparisonChangeChanged
+	default:
+		doc.Change = ComparisonChangeUnchanged
+	}
+	if comparison.Base != nil {
+		doc.BaseJobID = comparison.Base.ID
+		doc.BaseStatus = comparison.Base.Status
+		doc.BaseDurationMillis = makeDurationMillis(&comparison.Base.Timings)
+		doc.BaseFingerprint = comparison.Base.Fingerprint
+	}
+	if comparison.Head != nil {
+		doc.HeadJobID = comparison.Head.ID
+		doc.HeadStatus = comparison.Head.Status
+		doc.HeadDurationMillis = makeDurationMillis(&comparison.Head.Timings)
+		doc.HeadFingerprint = comparison.Head.Fingerprint
+	}
+	if comparison.Base != nil && comparison.Head != nil {
+		doc.DurationDeltaMillis = makeDurationDeltaMillis(&comparison.Base.Timings, &comparison.Head.Timings)
+	}
+	return doc
+}
+
+func makeArtifactComparison(comparison *dto.ArtifactComparison) *ArtifactComparison {
+	doc := &ArtifactComparison{
+		Job:       comparison.JobFQN.String(),
+		GroupName: comparison.GroupName,
+		Path:      comparison.Path,
+	}
+	switch {
+	case comparison.Base == nil:
+		doc.Change = ComparisonChangeAdded
+	case comparison.Head == nil:
+		doc.Change = ComparisonChangeRemoved
+	case comparison.Base.Size != comparison.Head.Size:
+		doc.Change = ComparisonChangeChanged
+	default:
+		doc.Change = ComparisonChangeUnchanged
+	}
+	if comparison.Base != nil {
+		doc.BaseArtifactID = comparison.Base.ID
+		doc.BaseSize = &comparison.Base.Size
+		doc.SizeDeltaBytes -= int64(comparison.Base.Size)
+	}
+	if comparison.Head != nil {
+		doc.HeadArtifactID = comparison.Head.ID
+		doc.HeadSize = &comparison.Head.Size
+		doc.SizeDeltaBytes += int64(comparison.Head.Size)
+	}
+	return doc
+}
+
+// makeDurationMillis returns how long something ran for, or nil if it hasn't finished running.
+func makeDurationMillis(timings *models.WorkflowTimings) *int64 {
+	if timings.RunningAt == nil || timings.FinishedAt == nil {
+		return nil
+	}
+	millis := timings.FinishedAt.Sub(timings.RunningAt.Time).Milliseconds()
+	return &millis
+}
+
+// makeDurationDeltaMillis returns how much longer head ran for than base, or nil if either hasn't finished running.
+func makeDurationDeltaMillis(base *models.WorkflowTimings, head *models.WorkflowTimings) *int64 {
+	baseMillis := makeDurationMillis(base)
+	headMillis := makeDurationMillis(head)
+	if baseMillis == nil || headMillis == nil {
+		return nil
+	}
+	delta := *headMillis - *baseMillis
+	return &delta
+}
+
+func (d *BuildComparison) GetID() models.ResourceID {
+	return d.Head.GetID()
+}
+
+func (d *BuildComparison) GetKind() models.ResourceKind {
+	return d.Head.GetKind()
+}
+
+func (d *BuildComparison) GetCreatedAt() models.Time {
+	return d.Head.GetCreatedAt()
+}
@@ -0,0 +1,198 @@
+package documents
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// BuildDAG describes the complete graph of jobs in a build, with everything needed to draw it in a single document.
+type BuildDAG struct {
+	baseResourceDocument
+	Build *Build `json:"build"`
+	// Workflows lists the workflows in the build in the order they first appear, with the nodes in each.
+	Workflows []*BuildDAGWorkflow `json:"workflows"`
+	// Nodes contains a node for each job in the build, followed by a node for each job in another workflow that
+	// is depended on but has not been added to the build yet.
+	Nodes []*BuildDAGNode `json:"nodes"`
+	// Edges contains an edge for each dependency between jobs, pointing from the job that is depended on.
+	Edges []*BuildDAGEdge `json:"edges"`
+	// Stages groups the build's jobs by stage within each workflow, in the order the stages run.
+	Stages []*BuildStage `json:"stages"`
+}
+
+// BuildDAGWorkflow is a workflow within a build.
+type BuildDAGWorkflow struct {
+	// Name of the workflow, or empty for the default workflow.
+	Name models.ResourceName `json:"name"`
+	// Nodes contains the IDs of the workflow's nodes.
+	Nodes []string `json:"nodes"`
+}
+
+// BuildDAGNode is a job within a build.
+type BuildDAGNode struct {
+	// ID uniquely identifies the node within the graph, and is the fully qualified name of the job.
+	ID string `json:"id"`
+	// JobID is the ID of the job, or empty if the node is deferred.
+	JobID       models.JobID          `json:"job_id,omitempty"`
+	JobURL      string                `json:"job_url,omitempty"`
+	Workflow    models.ResourceName   `json:"workflow"`
+	Name        models.ResourceName   `json:"name"`
+	Description string                `json:"description,omitempty"`
+	Stage       models.ResourceName   `json:"stage,omitempty"`
+	Status      models.WorkflowStatus `json:"status"`
+	Error       *models.Error         `json:"error,omitempty"`
+	Timings     WorkflowTimings       `json:"timings"`
+	// Deferred is true if the node is a job in another workflow that is depended on but has not been added to
+	// the build yet. Deferred nodes only have a workflow and name, and an unknown status.
+	Deferred bool `json:"deferred"`
+	// IndirectTo is the job this job's results were taken from, if the job was skipped because a job with the same
+	// fingerprint had already succeeded.
+	IndirectTo *BuildDAGIndirectJob `json:"indirect_to,omitempty"`
+	// Steps contains the job's steps, in the order they were declared.
+	Steps []*BuildDAGStep `json:"steps"`
+}
+
+// BuildDAGIndirectJob is a job that another job's results were taken from.
+type BuildDAGIndirectJob struct {
+	JobID   models.JobID          `json:"job_id"`
+	JobURL  string                `json:"job_url"`
+	BuildID models.BuildID        `json:"build_id"`
+	Status  models.WorkflowStatus `json:"status"`
+	Timings WorkflowTimings       `json:"timings"`
+	// Steps contains the steps of the job, whose logs are the ones to show for the job that was indirected.
+	Steps []*BuildDAGStep `json:"steps"`
+}
+
+// BuildDAGStep is a step within a job.
+type BuildDAGStep struct {
+	StepID          models.StepID          `json:"step_id"`
+	Name            models.ResourceName    `json:"name"`
+	Status          models.WorkflowStatus  `json:"status"`
+	Error           *models.Error          `json:"error,omitempty"`
+	Timings         WorkflowTimings        `json:"timings"`
+	LogDescriptorID models.LogDescriptorID `json:"log_descriptor_id"`
+}
+
+// BuildDAGEdge is a dependency of one job on another.
+type BuildDAGEdge struct {
+	// From is the ID of the node that is depended on.
+	From string `json:"from"`
+	// To is the ID of the node that depends on From.
+	To string `json:"to"`
+	// Artifacts contains the names of the artifact groups produced by From that are consumed by To.
+	Artifacts []models.ResourceName `json:"artifacts,omitempty"`
+	// Deferred is true if From is a deferred node.
+	Deferred bool `json:"deferred"`
+}
+
+// MakeBuildDAG makes the graph for a build. indirectJobs contains the jobs that jobs in the build were
+// indirected to, by ID; jobs that are missing from it are returned without IndirectTo being set.
+func MakeBuildDAG(rctx routes.RequestContext, build *dto.QueuedBuild, indirectJobs map[models.JobID]*dto.JobGraph) *BuildDAG {
+	var (
+		workflows       []*BuildDAGWorkflow
+		workflowsByName = make(map[models.ResourceName]*BuildDAGWorkflow)
+		nodes           = make([]*BuildDAGNode, 0, len(build.Jobs))
+		deferredNodes   = make(map[models.NodeFQN]bool)
+		edges           []*BuildDAGEdge
+	)
+	addToWorkflow := func(node *BuildDAGNode) {
+		workflow, ok := workflowsByName[node.Workflow]
+		if !ok {
+			workflow = &BuildDAGWorkflow{Name: node.Workflow}
+			workflowsByName[node.Workflow] = workflow
+			workflows = append(workflows, workflow)
+		}
+		workflow.Nodes = append(workflow.Nodes, node.ID)
+	}
+	for _, job := range build.Jobs {
+		fqn := job.GetFQN()
+		node := &BuildDAGNode{
+			ID:          fqn.String(),
+			JobID:       job.ID,
+			JobURL:      routes.MakeJobLink(rctx, job.ID),
+			Workflow:    job.Workflow,
+			Name:        job.Name,
+			Description: job.Description,
+			Stage:       job.Stage,
+			Status:      job.Status,
+			Error:       job.Error,
+			Timings:     *MakeWorkflowTimings(&job.Timings),
+			Steps:       makeBuildDAGSteps(job.Steps),
+		}
+		if indirectJob, ok := indirectJobs[job.IndirectToJobID]; ok {
+			node.IndirectTo = &BuildDAGIndirectJob{
+				JobID:   indirectJob.ID,
+				JobURL:  routes.MakeJobLink(rctx, indirectJob.ID),
+				BuildID: indirectJob.BuildID,
+				Status:  indirectJob.Status,
+				Timings: *MakeWorkflowTimings(&indirectJob.Timings),
+				Steps:   makeBuildDAGSteps(indirectJob.Steps),
+			}
+		}
+		nodes = append(nodes, node)
+		addToWorkflow(node)
+	}
+	for _, edge := range build.BuildGraph.Edges() {
+		if edge.Deferred && !deferredNodes[edge.From] {
+			deferredNodes[edge.From] = true
+			node := &BuildDAGNode{
+				ID:       edge.From.String(),
+				Workflow: edge.From.WorkflowName,
+				Name:     edge.From.JobName,
+				Status:   models.WorkflowStatusUnknown,
+				Deferred: true,
+				Steps:    []*BuildDAGStep{},
+			}
+			nodes = append(nodes, node)
+			addToWorkflow(node)
+		}
+		var artifacts []models.ResourceName
+		for _, artifact := range edge.ArtifactDependencies {
+			artifacts = append(artifacts, artifact.GroupName)
+		}
+		edges = append(edges, &BuildDAGEdge{
+			From:      edge.From.String(),
+			To:        edge.To.String(),
+			Artifacts: artifacts,
+			Deferred:  edge.Deferred,
+		})
+	}
+	return &BuildDAG{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeBuildDAGLink(rctx, build.ID),
+		},
+		Build:     MakeBuild(rctx, build.BuildGraph.Build),
+		Workflows: workflows,
+		Nodes:     nodes,
+		Edges:     edges,
+		Stages:    MakeBuildStages(build.BuildGraph.Stages()),
+	}
+}
+
+func makeBuildDAGSteps(steps []*models.Step) []*BuildDAGStep {
+	docs := make([]*BuildDAGStep, len(steps))
+	for i, step := range steps {
+		docs[i] = &BuildDAGStep{
+			StepID:          step.ID,
+			Name:            step.Name,
+			Status:          step.Status,
+			Error:           step.Error,
+			Timings:         *MakeWorkflowTimings(&step.Timings),
+			LogDescriptorID: step.LogDescriptorID,
+		}
+	}
+	return docs
+}
+
+func (d *BuildDAG) GetID() models.ResourceID {
+	return d.Build.GetID()
+}
+
+func (d *BuildDAG) GetKind() models.ResourceKind {
+	return d.Build.GetKind()
+}
+
+func (d *BuildDAG) GetCreatedAt() models.Time {
+	return d.Build.GetCreatedAt()
+}
@@ -0,0 +1,107 @@
+package documents
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// BuildRuleSet controls which pushes and pull requests for a repo will trigger a build.
+type BuildRuleSet struct {
+	baseResourceDocument
+
+	ID        models.BuildRuleSetID `json:"id"`
+	CreatedAt models.Time           `json:"created_at"`
+	UpdatedAt models.Time           `json:"updated_at"`
+	ETag      models.ETag           `json:"etag" hash:"ignore"`
+
+	// RepoID is the ID of the repo the rules apply to.
+	RepoID models.RepoID `json:"repo_id"`
+	// AllowRefs contains regular expressions; if not empty, only refs matching one of them are built.
+	AllowRefs []string `json:"allow_refs"`
+	// DenyRefs contains regular expressions; refs matching any of them are never built.
+	DenyRefs []string `json:"deny_refs"`
+	// BuildTags is true if pushing a tag triggers a build.
+	BuildTags bool `json:"build_tags"`
+	// PullRequestsOnly is true if branches other than the default branch are only built for pull requests.
+	PullRequestsOnly bool `json:"pull_requests_only"`
+	// SkipPattern is a regular expression; commits whose message matches it are not built, in addition to
+	// commits whose message contains [skip ci] or [ci skip].
+	SkipPattern string `json:"skip_pattern"`
+	// CommentCommands lists the commands that can be posted as comments on pull requests to queue builds.
+	CommentCommands models.CommentCommands `json:"comment_commands"`
+}
+
+func MakeBuildRuleSet(rctx routes.RequestContext, ruleSet *models.BuildRuleSet) *BuildRuleSet {
+	return &BuildRuleSet{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeBuildRuleSetLink(rctx, ruleSet.RepoID),
+		},
+
+		ID:        ruleSet.ID,
+		CreatedAt: ruleSet.CreatedAt,
+		UpdatedAt: ruleSet.UpdatedAt,
+		ETag:      ruleSet.ETag,
+
+		RepoID:           ruleSet.RepoID,
+		AllowRefs:        ruleSet.AllowRefs,
+		DenyRefs:         ruleSet.DenyRefs,
+		BuildTags:        ruleSet.BuildTags,
+		PullRequestsOnly: ruleSet.PullRequestsOnly,
+		SkipPattern:      ruleSet.SkipPattern,
+		CommentCommands:  ruleSet.GetCommentCommands(),
+	}
+}
+
+func (d *BuildRuleSet) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *BuildRuleSet) GetKind() models.ResourceKind {
+	return models.BuildRuleSetResourceKind
+}
+
+func (d *BuildRuleSet) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// PatchBuildRuleSetRequest is used when updating a repo's build rules
+type PatchBuildRuleSetRequest struct {
+	AllowRefs        *models.RefPatterns     `json:"allow_refs"`
+	DenyRefs         *models.RefPatterns     `json:"deny_refs"`
+	BuildTags        *bool                   `json:"build_tags"`
+	PullRequestsOnly *bool                   `json:"pull_requests_only"`
+	SkipPattern      *string                 `json:"skip_pattern"`
+	CommentCommands  *models.CommentCommands `json:"comment_commands"`
+}
+
+func (d *PatchBuildRuleSetRequest) Bind(r *http.Request) error {
+	if d.AllowRefs != nil {
+		err := d.AllowRefs.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed("Invalid allow_refs").Wrap(err)
+		}
+	}
+	if d.DenyRefs != nil {
+		err := d.DenyRefs.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed("Invalid deny_refs").Wrap(err)
+		}
+	}
+	if d.SkipPattern != nil {
+		_, err := regexp.Compile(*d.SkipPattern)
+		if err != nil {
+			return gerror.NewErrValidationFailed("Invalid skip_pattern").Wrap(err)
+		}
+	}
+	if d.CommentCommands != nil {
+		err := d.CommentCommands.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed("Invalid comment_commands").Wrap(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package documents
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// Names of the server-sent events sent on a build's timeline stream.
+const (
+	// BuildTimelineEventEvent carries an Event published by the build. The SSE event ID is the event's
+	// sequence number, so a client that reconnects resumes from the last event it received.
+	BuildTimelineEventEvent = "event"
+	// BuildTimelineJobAddedEvent carries a BuildTimelineJobAdded, sent the first time the stream sees an
+	// event for a job, including jobs added to the build dynamically while it runs.
+	BuildTimelineJobAddedEvent = "job_added"
+	// BuildTimelineLogSizeEvent carries a BuildTimelineLogSize, sent when the log of a running step grows.
+	BuildTimelineLogSizeEvent = "log_size"
+	// BuildTimelineEndEvent is sent once the build has finished and all its events have been sent,
+	// after which the server closes the stream.
+	BuildTimelineEndEvent = "end"
+)
+
+// BuildTimelineJobAdded is sent on a build's timeline stream when a job is first seen.
+type BuildTimelineJobAdded struct {
+	Job *Job `json:"job"`
+}
+
+// BuildTimelineLogSize is sent on a build's timeline stream when the size of a step's log changes.
+type BuildTimelineLogSize struct {
+	// ResourceID is the ID of the step the log belongs to.
+	ResourceID models.ResourceID `json:"resource_id"`
+	// LogDescriptorID is the ID of the step's log.
+	LogDescriptorID models.LogDescriptorID `json:"log_descriptor_id"`
+	// SizeBytes is the size of the log's data written so far.
+	SizeBytes int64 `json:"size_bytes"`
+}
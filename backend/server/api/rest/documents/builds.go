@@ -38,12 +38,25 @@ type Build struct {
 	Error *models.Error `json:"error"`
 	// Opts that are applied to this build.
 	Opts BuildOptions `json:"opts"`
+	// ClonedFromBuildID is the ID of the build this build was cloned from, if any.
+	ClonedFromBuildID *models.BuildID `json:"cloned_from_build_id,omitempty"`
+	// Warnings lists problems found with the build definition that didn't prevent the build from running,
+	// such as use of a deprecated version or deprecated fields.
+	Warnings []string `json:"warnings,omitempty"`
+	// Priority determines the order in which jobs from different builds are handed to runners; jobs from
+	// builds with a higher priority run first.
+	Priority int `json:"priority"`
 
 	LogDescriptorURL  string `json:"log_descriptor_url"`
 	ArtifactSearchURL string `json:"artifact_search_url"`
+	DAGURL            string `json:"dag_url"`
 }
 
 func MakeBuild(rctx routes.RequestContext, build *models.Build) *Build {
+	var clonedFromBuildID *models.BuildID
+	if build.ClonedFromBuildID.Valid() {
+		clonedFromBuildID = &build.ClonedFromBuildID
+	}
 	return &Build{
 		baseResourceDocument: baseResourceDocument{
 			URL: routes.MakeBuildLink(rctx, build.ID),
@@ -55,18 +68,22 @@ func MakeBuild(rctx routes.RequestContext, build *models.Build) *Build {
 		DeletedAt: build.DeletedAt,
 		ETag:      build.ETag,
 
-		Name:            build.Name,
-		RepoID:          build.RepoID,
-		CommitID:        build.CommitID,
-		LogDescriptorID: build.LogDescriptorID,
-		Ref:             build.Ref,
-		Status:          build.Status,
-		Timings:         *MakeWorkflowTimings(&build.Timings),
-		Error:           build.Error,
-		Opts:            *MakeBuildOptions(&build.Opts),
+		Name:              build.Name,
+		RepoID:            build.RepoID,
+		CommitID:          build.CommitID,
+		LogDescriptorID:   build.LogDescriptorID,
+		Ref:               build.Ref,
+		Status:            build.Status,
+		Timings:           *MakeWorkflowTimings(&build.Timings),
+		Error:             build.Error,
+		Opts:              *MakeBuildOptions(&build.Opts),
+		ClonedFromBuildID: clonedFromBuildID,
+		Warnings:          build.Warnings,
+		Priority:          build.Priority,
 
 		LogDescriptorURL:  routes.MakeLogLink(rctx, build.LogDescriptorID),
 		ArtifactSearchURL: routes.MakeArtifactSearchLink(rctx, build.ID),
+		DAGURL:            routes.MakeBuildDAGLink(rctx, build.ID),
 	}
 }
 
@@ -97,12 +114,15 @@ type BuildOptions struct {
 	// NodesToRun contains zero or more workflows, jobs and steps to run. If no nodes are specified
 	// then all workflows, jobs and steps will be run.
 	NodesToRun []NodeFQN `json:"nodes_to_run"`
+	// Parameters contains the value of each parameter declared in the build definition.
+	Parameters models.BuildParameters `json:"parameters,omitempty"`
 }
 
 func MakeBuildOptions(opts *models.BuildOptions) *BuildOptions {
 	return &BuildOptions{
 		Force:      opts.Force,
 		NodesToRun: MakeNodeFQNs(opts.NodesToRun),
+		Parameters: opts.Parameters,
 	}
 }
 
@@ -146,6 +166,9 @@ type BuildGraph struct {
 	Build *Build `json:"build"`
 	// Jobs that make up the build.
 	Jobs []*JobGraph `json:"jobs"`
+	// Stages groups the build's jobs by stage within each workflow, in the order the stages run.
+	// Jobs that don't belong to a stage are not included.
+	Stages []*BuildStage `json:"stages"`
 	// Repo that was committed to.
 	Repo *Repo `json:"repo"`
 	// Commit that the build was generated from.
@@ -160,10 +183,41 @@ func MakeBuildGraph(rctx routes.RequestContext, build *dto.QueuedBuild) *BuildGr
 		Repo:   MakeRepo(rctx, build.Repo),
 		Commit: MakeCommit(rctx, build.Commit),
 		Jobs:   MakeJobGraphs(rctx, build.Jobs),
+		Stages: MakeBuildStages(build.BuildGraph.Stages()),
 		Build:  MakeBuild(rctx, build.BuildGraph.Build),
 	}
 }
 
+// BuildStage is a named group of jobs within a workflow. Each stage's jobs run after the jobs in the stage before it.
+type BuildStage struct {
+	// Workflow the stage is a part of, or empty if the stage is part of the default workflow
+	Workflow models.ResourceName `json:"workflow"`
+	// Name of the stage.
+	Name models.ResourceName `json:"name"`
+	// Jobs contains the IDs of the jobs in the stage.
+	Jobs []models.JobID `json:"jobs"`
+}
+
+func MakeBuildStage(stage *dto.BuildStage) *BuildStage {
+	jobIDs := make([]models.JobID, len(stage.Jobs))
+	for i, job := range stage.Jobs {
+		jobIDs[i] = job.ID
+	}
+	return &BuildStage{
+		Workflow: stage.Workflow,
+		Name:     stage.Name,
+		Jobs:     jobIDs,
+	}
+}
+
+func MakeBuildStages(stages []*dto.BuildStage) []*BuildStage {
+	docs := make([]*BuildStage, len(stages))
+	for i, stage := range stages {
+		docs[i] = MakeBuildStage(stage)
+	}
+	return docs
+}
+
 func (d *BuildGraph) GetID() models.ResourceID {
 	return d.Build.GetID()
 }
@@ -177,20 +231,121 @@ func (d *BuildGraph) GetCreatedAt() models.Time {
 }
 
 type CreateBuildRequest struct {
-	// FromBuildID nominates a previous build to clone to create the new build.
-	// In the future, we may support specifying a commit/ref instead of a previous
-	// build but for now this gives us "re-run" functionality.
-	FromBuildID *models.BuildID      `json:"from_build_id"`
-	Opts        *models.BuildOptions `json:"opts"`
+	// FromBuildID nominates a previous build whose commit and ref the new build should build again.
+	// If not set then the build is created for Ref and SHA instead.
+	FromBuildID *models.BuildID `json:"from_build_id"`
+	// Ref to build, such as refs/heads/main or a branch name. Defaults to the repo's default branch.
+	Ref string `json:"ref"`
+	// SHA of the commit to build. Defaults to the commit at the head of Ref. The commit is read from the
+	// repo's SCM if it hasn't been seen before.
+	SHA  string               `json:"sha"`
+	Opts *models.BuildOptions `json:"opts"`
 }
 
 func (d *CreateBuildRequest) Bind(r *http.Request) error {
-	if d.FromBuildID == nil || !d.FromBuildID.Valid() {
-		return gerror.NewErrValidationFailed("The build to base the new build on must be set")
+	if d.FromBuildID != nil {
+		if !d.FromBuildID.Valid() {
+			return gerror.NewErrValidationFailed("The build to base the new build on is not a valid build ID")
+		}
+		if d.Ref != "" || d.SHA != "" {
+			return gerror.NewErrValidationFailed("A ref or SHA can't be set when basing the new build on another build")
+		}
+	}
+	return nil
+}
+
+// CloneBuildRequest is used when cloning a finished build into a new build
+type CloneBuildRequest struct {
+	// Environment contains environment variables to set on every job in the new build, replacing any
+	// existing variables with the same name.
+	Environment []*models.EnvVar `json:"environment"`
+	// Opts to apply to the new build.
+	Opts *models.BuildOptions `json:"opts"`
+}
+
+func (d *CloneBuildRequest) Bind(r *http.Request) error {
+	for _, envVar := range d.Environment {
+		if envVar == nil {
+			return gerror.NewErrValidationFailed("Environment variables must not be null")
+		}
+		err := envVar.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Invalid environment variable: %s", err))
+		}
 	}
 	return nil
 }
 
+// PatchBuildRequest is used to change a build that has not finished
+type PatchBuildRequest struct {
+	// Priority to give the build's remaining queued jobs.
+	Priority *int `json:"priority"`
+}
+
+func (d *PatchBuildRequest) Bind(r *http.Request) error {
+	if d.Priority == nil {
+		return gerror.NewErrValidationFailed("Priority must be specified")
+	}
+	err := models.ValidateBuildPriority(*d.Priority)
+	if err != nil {
+		return gerror.NewErrValidationFailed(err.Error())
+	}
+	return nil
+}
+
+// ValidateBuildConfigRequest is used to check a build configuration for problems without running a build
+type ValidateBuildConfigRequest struct {
+	// Config is the contents of the build configuration file to validate.
+	Config string `json:"config"`
+	// ConfigType is the format of the build configuration ('yaml', 'json' or 'jsonnet'). Defaults to 'yaml'.
+	ConfigType models.ConfigType `json:"config_type"`
+	// Ref is the git ref to load any templates in the repo itself from. Defaults to the repo's default branch.
+	Ref string `json:"ref"`
+}
+
+func (d *ValidateBuildConfigRequest) Bind(r *http.Request) error {
+	if d.Config == "" {
+		return gerror.NewErrValidationFailed("The build configuration to validate must be set")
+	}
+	if d.ConfigType == models.ConfigTypeNoConfig {
+		d.ConfigType = models.ConfigTypeYAML
+	}
+	switch d.ConfigType {
+	case models.ConfigTypeYAML, models.ConfigTypeJSON, models.ConfigTypeJSONNET:
+		return nil
+	default:
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Unsupported build configuration type: %s", d.ConfigType))
+	}
+}
+
+// BuildConfigValidation is the result of validating a build configuration
+type BuildConfigValidation struct {
+	// Valid is true if no problems were found with the build configuration.
+	Valid bool `json:"valid"`
+	// Error describes the problem with the build configuration, or is nil if Valid is true.
+	Error *BuildConfigError `json:"error"`
+	// Version is the build definition version the configuration was parsed with, or empty if it is not valid.
+	Version string `json:"version,omitempty"`
+	// Warnings lists problems with the build configuration that would not prevent a build from running,
+	// such as use of a deprecated version or deprecated fields.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// BuildConfigError describes a problem with a build configuration, and where in the configuration it was found
+type BuildConfigError struct {
+	// Message describes the problem.
+	Message string `json:"message"`
+	// File is the path of the template containing the problem, or empty if the problem is in the build
+	// configuration itself.
+	File string `json:"file,omitempty"`
+	// Line is the 1-based line number the problem was found at, or 0 if unknown.
+	Line int `json:"line,omitempty"`
+	// Column is the 1-based column number the problem was found at, or 0 if unknown.
+	Column int `json:"column,omitempty"`
+	// Path is the path to the element containing the problem, e.g. 'jobs[1].steps[0].commands'.
+	Path string `json:"path,omitempty"`
+}
+
 // BuildSearchResult is the API layer representation of a BuildSearchResult that can be sent to the UI
 type BuildSearchResult struct {
 	// Build resource containing details of the build
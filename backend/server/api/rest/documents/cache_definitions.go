@@ -0,0 +1,35 @@
+package documents
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// CacheDefinition is generated from jobs in the build config.
+// It declares that one or more paths in the job's workspace should be restored from the build cache before
+// the job's steps run, and saved to the build cache once the job has succeeded.
+type CacheDefinition struct {
+	// Name identifies the cache; caches with the same name are shared by all jobs in the repo.
+	Name models.ResourceName `json:"name"`
+	// Paths contains one or more paths relative to the checkout directory to save and restore.
+	Paths []string `json:"paths"`
+	// KeyCommands contains zero or more shell commands whose output is hashed to produce the cache key.
+	// If no commands are specified the job's fingerprint is used as the cache key.
+	KeyCommands []models.Command `json:"key_commands"`
+	// Preset is the preset the cache was created from, or empty if the cache was declared in full.
+	Preset models.CachePreset `json:"preset,omitempty"`
+}
+
+func MakeCacheDefinition(definition *models.CacheDefinition) *CacheDefinition {
+	return &CacheDefinition{
+		Name:        definition.Name,
+		Paths:       definition.Paths,
+		KeyCommands: definition.KeyCommands,
+		Preset:      definition.Preset,
+	}
+}
+
+func MakeCacheDefinitions(definitions models.CacheDefinitions) []*CacheDefinition {
+	var docs []*CacheDefinition
+	for _, definition := range definitions {
+		docs = append(docs, MakeCacheDefinition(definition))
+	}
+	return docs
+}
@@ -0,0 +1,64 @@
+package documents
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// CacheEntry is a tarball of cached files saved by a job, which can be restored by later jobs in the same repo.
+type CacheEntry struct {
+	baseResourceDocument
+
+	ID         models.CacheEntryID `json:"id"`
+	CreatedAt  models.Time         `json:"created_at"`
+	LastUsedAt models.Time         `json:"last_used_at"`
+
+	RepoID models.RepoID `json:"repo_id"`
+	// JobID is the ID of the job that saved the entry.
+	JobID models.JobID `json:"job_id"`
+	// Name is the name of the cache, from the job's cache definition.
+	Name models.ResourceName `json:"name"`
+	// Key is the cache key calculated by the runner when the entry was saved.
+	Key string `json:"key"`
+	// Size of the tarball in bytes.
+	Size     uint64          `json:"size"`
+	HashType models.HashType `json:"hash_type"`
+	Hash     string          `json:"hash"`
+
+	DataURL string `json:"data_url"`
+}
+
+// MakeCacheEntry makes a document for a cache entry being accessed on behalf of the specified job.
+func MakeCacheEntry(rctx routes.RequestContext, jobID models.JobID, cacheEntry *models.CacheEntry) *CacheEntry {
+	return &CacheEntry{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeCacheEntryLinkForRunner(rctx, jobID, cacheEntry.ID),
+		},
+
+		ID:         cacheEntry.ID,
+		CreatedAt:  cacheEntry.CreatedAt,
+		LastUsedAt: cacheEntry.LastUsedAt,
+
+		RepoID:   cacheEntry.RepoID,
+		JobID:    cacheEntry.JobID,
+		Name:     cacheEntry.Name,
+		Key:      cacheEntry.Key,
+		Size:     cacheEntry.Size,
+		HashType: cacheEntry.HashType,
+		Hash:     cacheEntry.Hash,
+
+		DataURL: routes.MakeCacheEntryDataLinkForRunner(rctx, jobID, cacheEntry.ID),
+	}
+}
+
+func (d *CacheEntry) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *CacheEntry) GetKind() models.ResourceKind {
+	return models.CacheEntryResourceKind
+}
+
+func (d *CacheEntry) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
@@ -0,0 +1,135 @@
+package documents
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// BuildCoverage combines the code coverage from every coverage report uploaded by a build, and compares it
+// against the coverage of the latest build of the target branch.
+type BuildCoverage struct {
+	baseResourceDocument
+
+	ID        models.BuildCoverageID `json:"id"`
+	CreatedAt models.Time            `json:"created_at"`
+	UpdatedAt models.Time            `json:"updated_at"`
+
+	BuildID models.BuildID `json:"build_id"`
+	RepoID  models.RepoID  `json:"repo_id"`
+	Ref     string         `json:"ref"`
+	// Reports is the number of coverage reports in the build.
+	Reports int `json:"reports"`
+	// LinesCovered is the number of lines executed at least once, across all reports.
+	LinesCovered int `json:"lines_covered"`
+	// LinesValid is the number of executable lines, across all reports.
+	LinesValid int `json:"lines_valid"`
+	// Percent is the percentage of lines covered.
+	Percent float64 `json:"percent"`
+	// Files is the coverage of each source file, combined across all reports.
+	Files models.FileCoverages `json:"files"`
+	// TargetRef is the ref of the branch coverage is compared against.
+	TargetRef string `json:"target_ref"`
+	// TargetBuildID is the ID of the build on the target branch that coverage is compared against, or
+	// nil if no build on the target branch has reported coverage.
+	TargetBuildID *models.BuildID `json:"target_build_id"`
+	// TargetPercent is the percentage of lines covered by the target build, or nil if there is no target build.
+	TargetPercent *float64 `json:"target_percent"`
+	// Delta is the change in coverage compared to the target build, in percentage points, or nil if
+	// there is no target build.
+	Delta *float64 `json:"delta"`
+
+	CoverageReportsURL string `json:"coverage_reports_url"`
+}
+
+func MakeBuildCoverage(rctx routes.RequestContext, buildCoverage *models.BuildCoverage) *BuildCoverage {
+	return &BuildCoverage{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeBuildCoverageLink(rctx, buildCoverage.BuildID),
+		},
+
+		ID:        buildCoverage.ID,
+		CreatedAt: buildCoverage.CreatedAt,
+		UpdatedAt: buildCoverage.UpdatedAt,
+
+		BuildID:       buildCoverage.BuildID,
+		RepoID:        buildCoverage.RepoID,
+		Ref:           buildCoverage.Ref,
+		Reports:       buildCoverage.Reports,
+		LinesCovered:  buildCoverage.LinesCovered,
+		LinesValid:    buildCoverage.LinesValid,
+		Percent:       buildCoverage.Percent(),
+		Files:         buildCoverage.Files,
+		TargetRef:     buildCoverage.TargetRef,
+		TargetBuildID: buildCoverage.TargetBuildID,
+		TargetPercent: buildCoverage.TargetPercent(),
+		Delta:         buildCoverage.Delta(),
+
+		CoverageReportsURL: routes.MakeCoverageReportsLink(rctx, buildCoverage.BuildID),
+	}
+}
+
+func MakeBuildCoverages(rctx routes.RequestContext, buildCoverages []*models.BuildCoverage) []*BuildCoverage {
+	var docs []*BuildCoverage
+	for _, model := range buildCoverages {
+		docs = append(docs, MakeBuildCoverage(rctx, model))
+	}
+	return docs
+}
+
+func (d *BuildCoverage) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *BuildCoverage) GetKind() models.ResourceKind {
+	return models.BuildCoverageResourceKind
+}
+
+func (d *BuildCoverage) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// CoverageReport records the totals parsed from a single coverage report artifact.
+type CoverageReport struct {
+	ID        models.CoverageReportID `json:"id"`
+	CreatedAt models.Time             `json:"created_at"`
+
+	BuildID    models.BuildID        `json:"build_id"`
+	JobID      models.JobID          `json:"job_id"`
+	RepoID     models.RepoID         `json:"repo_id"`
+	ArtifactID models.ArtifactID     `json:"artifact_id"`
+	Format     models.CoverageFormat `json:"format"`
+	// Files is the number of source files in the report.
+	Files        int     `json:"files"`
+	LinesCovered int     `json:"lines_covered"`
+	LinesValid   int     `json:"lines_valid"`
+	Percent      float64 `json:"percent"`
+
+	ArtifactURL string `json:"artifact_url"`
+}
+
+func MakeCoverageReport(rctx routes.RequestContext, report *models.CoverageReport) *CoverageReport {
+	return &CoverageReport{
+		ID:        report.ID,
+		CreatedAt: report.CreatedAt,
+
+		BuildID:      report.BuildID,
+		JobID:        report.JobID,
+		RepoID:       report.RepoID,
+		ArtifactID:   report.ArtifactID,
+		Format:       report.Format,
+		Files:        report.Files,
+		LinesCovered: report.LinesCovered,
+		LinesValid:   report.LinesValid,
+		Percent:      report.Percent(),
+
+		ArtifactURL: routes.MakeArtifactLink(rctx, report.ArtifactID),
+	}
+}
+
+func MakeCoverageReports(rctx routes.RequestContext, reports []*models.CoverageReport) []*CoverageReport {
+	var docs []*CoverageReport
+	for _, model := range reports {
+		docs = append(docs, MakeCoverageReport(rctx, model))
+	}
+	return docs
+}
@@ -0,0 +1,93 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// CustomStatus is an additional named status published to a build, relayed to the SCM separately from
+// the overall status of the build.
+type CustomStatus struct {
+	baseResourceDocument
+
+	ID        models.CustomStatusID `json:"id"`
+	CreatedAt models.Time           `json:"created_at"`
+	UpdatedAt models.Time           `json:"updated_at"`
+	ETag      models.ETag           `json:"etag" hash:"ignore"`
+
+	// BuildID is the ID of the build the status was published to.
+	BuildID models.BuildID `json:"build_id"`
+	// Name uniquely identifies the status within the build.
+	Name models.ResourceName `json:"name"`
+	// State is one of "pending", "success", "failure" or "error".
+	State models.CustomStatusState `json:"state"`
+	// Description is an optional human-readable summary of the status.
+	Description string `json:"description"`
+	// TargetURL is an optional link to more information about the status.
+	TargetURL string `json:"target_url"`
+}
+
+func MakeCustomStatus(rctx routes.RequestContext, customStatus *models.CustomStatus) *CustomStatus {
+	return &CustomStatus{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeCustomStatusLink(rctx, customStatus.BuildID, customStatus.ID),
+		},
+
+		ID:        customStatus.ID,
+		CreatedAt: customStatus.CreatedAt,
+		UpdatedAt: customStatus.UpdatedAt,
+		ETag:      customStatus.ETag,
+
+		BuildID:     customStatus.BuildID,
+		Name:        customStatus.Name,
+		State:       customStatus.State,
+		Description: customStatus.Description,
+		TargetURL:   customStatus.TargetURL,
+	}
+}
+
+func MakeCustomStatuses(rctx routes.RequestContext, customStatuses []*models.CustomStatus) []*CustomStatus {
+	var docs []*CustomStatus
+	for _, model := range customStatuses {
+		docs = append(docs, MakeCustomStatus(rctx, model))
+	}
+	return docs
+}
+
+func (d *CustomStatus) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *CustomStatus) GetKind() models.ResourceKind {
+	return models.CustomStatusResourceKind
+}
+
+func (d *CustomStatus) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// PublishCustomStatusRequest is used when publishing a custom status to a build
+type PublishCustomStatusRequest struct {
+	// Name uniquely identifies the status within the build; publishing a status with the same name again
+	// replaces the previous status.
+	Name models.ResourceName `json:"name"`
+	// State is one of "pending", "success", "failure" or "error".
+	State models.CustomStatusState `json:"state"`
+	// Description is an optional human-readable summary of the status.
+	Description string `json:"description"`
+	// TargetURL is an optional link to more information about the status.
+	TargetURL string `json:"target_url"`
+}
+
+func (d *PublishCustomStatusRequest) Bind(r *http.Request) error {
+	if d.Name == "" {
+		return gerror.NewErrValidationFailed("Name must not be empty")
+	}
+	if d.State == "" {
+		return gerror.NewErrValidationFailed("State must not be empty")
+	}
+	return nil
+}
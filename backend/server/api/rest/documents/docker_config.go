@@ -17,6 +17,9 @@ type DockerConfig struct {
 	// Image is the default Docker image to run the job's steps in.
 	// In the future, steps may override this property by setting their own DockerImage.
 	Image string `json:"image,omitempty"`
+	// Toolchain is an optional reference to a registered toolchain to run the job's steps in, in the format
+	// "name@version". Once the job has been run Image is set to the toolchain's image.
+	Toolchain string `json:"toolchain,omitempty"`
 	// ImagePullStrategy determines if/when the Docker image is pulled during job execution.
 	Pull models.DockerPullStrategy `json:"pull,omitempty"`
 	// BasicAuth specifies the basic auth credentials to use when pulling the Docker image from the registry.
@@ -27,7 +30,7 @@ type DockerConfig struct {
 	Shell *string `json:"shell,omitempty"`
 }
 
-func MakeDockerConfig(image string, pull models.DockerPullStrategy, auth *models.DockerAuth, shell *string) *DockerConfig {
+func MakeDockerConfig(image string, toolchain string, pull models.DockerPullStrategy, auth *models.DockerAuth, shell *string) *DockerConfig {
 	var basicAuth *DockerBasicAuth
 	if auth != nil && auth.Basic != nil {
 		basicAuth = &DockerBasicAuth{}
@@ -57,6 +60,7 @@ func MakeDockerConfig(image string, pull models.DockerPullStrategy, auth *models
 	}
 	return &DockerConfig{
 		Image:     image,
+		Toolchain: toolchain,
 		Pull:      pull,
 		BasicAuth: basicAuth,
 		AWSAuth:   awsAuth,
@@ -0,0 +1,72 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// EmailPreference specifies which build notification emails a user wants to receive.
+type EmailPreference struct {
+	baseResourceDocument
+
+	ID        models.EmailPreferenceID `json:"id"`
+	CreatedAt models.Time              `json:"created_at"`
+	UpdatedAt models.Time              `json:"updated_at"`
+	ETag      models.ETag              `json:"etag" hash:"ignore"`
+
+	// LegalEntityID is the ID of the user the preference belongs to.
+	LegalEntityID models.LegalEntityID `json:"legal_entity_id"`
+	// Enabled is true if the user receives build notification emails at all.
+	Enabled bool `json:"enabled"`
+	// OnlyMyBuilds is true if emails are only sent for builds of commits authored or committed by the user.
+	OnlyMyBuilds bool `json:"only_my_builds"`
+	// OnlyFailures is true if emails are only sent for failed builds.
+	OnlyFailures bool `json:"only_failures"`
+	// Digest is true if notifications are batched up into a periodic digest email.
+	Digest bool `json:"digest"`
+}
+
+func MakeEmailPreference(rctx routes.RequestContext, preference *models.EmailPreference) *EmailPreference {
+	return &EmailPreference{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeEmailPreferenceLink(rctx, preference.LegalEntityID),
+		},
+
+		ID:        preference.ID,
+		CreatedAt: preference.CreatedAt,
+		UpdatedAt: preference.UpdatedAt,
+		ETag:      preference.ETag,
+
+		LegalEntityID: preference.LegalEntityID,
+		Enabled:       preference.Enabled,
+		OnlyMyBuilds:  preference.OnlyMyBuilds,
+		OnlyFailures:  preference.OnlyFailures,
+		Digest:        preference.Digest,
+	}
+}
+
+func (d *EmailPreference) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *EmailPreference) GetKind() models.ResourceKind {
+	return models.EmailPreferenceResourceKind
+}
+
+func (d *EmailPreference) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// PatchEmailPreferenceRequest is used when updating an email preference
+type PatchEmailPreferenceRequest struct {
+	Enabled      *bool `json:"enabled"`
+	OnlyMyBuilds *bool `json:"only_my_builds"`
+	OnlyFailures *bool `json:"only_failures"`
+	Digest       *bool `json:"digest"`
+}
+
+func (d *PatchEmailPreferenceRequest) Bind(r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package documents
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+)
+
+// GraphQLRequest is a GraphQL query, in the standard format for GraphQL over HTTP.
+type GraphQLRequest struct {
+	// Query is the GraphQL query document.
+	Query string `json:"query"`
+	// OperationName selects the operation to run if the document contains more than one.
+	OperationName string `json:"operationName"`
+	// Variables are the values of any variables used in the query.
+	Variables map[string]interface{} `json:"variables"`
+}
+
+func (d *GraphQLRequest) Bind(r *http.Request) error {
+	if d.Query == "" {
+		return gerror.NewErrValidationFailed("Query must be set")
+	}
+	return nil
+}
+
+// GraphQLResponse is the result of a GraphQL query.
+type GraphQLResponse struct {
+	// Data is the result of the query, shaped like the query itself. Fields that could not be resolved are null.
+	// Data is omitted if the query was invalid and could not be run.
+	Data json.RawMessage `json:"data"`
+	// Errors describes any problems running the query or resolving fields.
+	Errors []*GraphQLError `json:"errors,omitempty"`
+}
+
+// GraphQLError describes a problem running a GraphQL query.
+type GraphQLError struct {
+	Message string `json:"message"`
+	// Path is the path of response keys and list indexes to the field that failed, if any.
+	Path []interface{} `json:"path,omitempty"`
+}
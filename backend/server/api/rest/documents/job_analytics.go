@@ -0,0 +1,176 @@
+package documents
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// JobAnalyticsRequest selects the jobs to compute analytics for, and how to break them down.
+type JobAnalyticsRequest struct {
+	*models.JobAnalyticsQuery
+}
+
+// NewJobAnalyticsRequest returns a request covering the default range of time up until now.
+func NewJobAnalyticsRequest(now models.Time) *JobAnalyticsRequest {
+	return &JobAnalyticsRequest{JobAnalyticsQuery: models.NewJobAnalyticsQuery(now)}
+}
+
+// FromQuery parses the request from the query parameters 'from' and 'to' (RFC3339 times, where 'to' defaults
+// to now and 'from' defaults to 7 days before 'to'), 'interval' (a duration such as '1h' or '24h', defaulting
+// to 24h) and 'group_by' (either 'job' or 'workflow', defaulting to 'job').
+func (d *JobAnalyticsRequest) FromQuery(values url.Values) error {
+	if values.Has("to") {
+		to, err := time.Parse(time.RFC3339Nano, values.Get("to"))
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("error parsing to: %s", err))
+		}
+		d.To = models.NewTime(to)
+		d.From = models.NewTime(to.Add(-models.DefaultJobAnalyticsRange))
+	}
+	if values.Has("from") {
+		from, err := time.Parse(time.RFC3339Nano, values.Get("from"))
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("error parsing from: %s", err))
+		}
+		d.From = models.NewTime(from)
+	}
+	if values.Has("interval") {
+		interval, err := time.ParseDuration(values.Get("interval"))
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("error parsing interval: %s", err))
+		}
+		d.Interval = interval
+	}
+	if values.Has("group_by") {
+		d.GroupBy = models.JobAnalyticsGroupBy(values.Get("group_by"))
+	}
+	return d.Validate()
+}
+
+// JobAnalytics contains statistics about the durations, queue wait times and success rates of the finished jobs
+// in a repo, for the repo as a whole and for each workflow or job.
+type JobAnalytics struct {
+	URL            string                     `json:"url"`
+	RepoID         models.RepoID              `json:"repo_id"`
+	From           models.Time                `json:"from"`
+	To             models.Time                `json:"to"`
+	IntervalMillis int64                      `json:"interval_millis"`
+	GroupBy        models.JobAnalyticsGroupBy `json:"group_by"`
+	// Totals covers every finished job in the repo.
+	Totals *JobAnalyticsSeries `json:"totals"`
+	// Groups contains a series for each workflow or job, ordered by workflow and then by job name.
+	Groups []*JobAnalyticsGroup `json:"groups"`
+}
+
+// JobAnalyticsGroup is the series of statistics for a single workflow, or for a single job within a workflow.
+type JobAnalyticsGroup struct {
+	// Workflow is the name of the workflow, or empty for the default workflow.
+	Workflow models.ResourceName `json:"workflow"`
+	// Job is the name of the job, or empty if jobs were grouped by workflow.
+	Job models.ResourceName `json:"job,omitempty"`
+	*JobAnalyticsSeries
+}
+
+// JobAnalyticsSeries contains statistics for a set of jobs over the whole time range and in each time window.
+type JobAnalyticsSeries struct {
+	Overall *JobStats `json:"overall"`
+	// Windows contains the statistics for each time window in the range, in order. Jobs are counted in the
+	// window they were queued in.
+	Windows []*JobAnalyticsWindow `json:"windows"`
+}
+
+// JobAnalyticsWindow contains statistics for the jobs queued in a single time window.
+type JobAnalyticsWindow struct {
+	// Start is the start of the window, inclusive.
+	Start models.Time `json:"start"`
+	// End is the end of the window, exclusive.
+	End models.Time `json:"end"`
+	*JobStats
+}
+
+// JobStats are statistics about a set of finished jobs.
+type JobStats struct {
+	Jobs      int `json:"jobs"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Canceled  int `json:"canceled"`
+	Skipped   int `json:"skipped"`
+	// SuccessRate is the fraction of jobs that either succeeded or failed that succeeded, from 0 to 1,
+	// or null if no jobs succeeded or failed.
+	SuccessRate *float64 `json:"success_rate"`
+	// DurationP50Millis and DurationP95Millis are percentiles of the time jobs spent running, in milliseconds,
+	// or null if no jobs ran.
+	DurationP50Millis *int64 `json:"duration_p50_millis"`
+	DurationP95Millis *int64 `json:"duration_p95_millis"`
+	// QueueWaitP50Millis and QueueWaitP95Millis are percentiles of the time jobs spent between being queued and
+	// starting to run, in milliseconds, or null if no jobs ran. This includes time spent waiting for the jobs'
+	// dependencies to finish as well as time spent waiting for a runner.
+	QueueWaitP50Millis *int64 `json:"queue_wait_p50_millis"`
+	QueueWaitP95Millis *int64 `json:"queue_wait_p95_millis"`
+}
+
+func MakeJobAnalytics(rctx routes.RequestContext, analytics *dto.JobAnalytics) *JobAnalytics {
+	groups := make([]*JobAnalyticsGroup, len(analytics.Groups))
+	for i, group := range analytics.Groups {
+		groups[i] = &JobAnalyticsGroup{
+			Workflow:           group.Workflow,
+			Job:                group.JobName,
+			JobAnalyticsSeries: makeJobAnalyticsSeries(analytics.Windows, group.JobAnalyticsSeries),
+		}
+	}
+	return &JobAnalytics{
+		URL:            routes.MakeRepoJobAnalyticsLink(rctx, analytics.RepoID),
+		RepoID:         analytics.RepoID,
+		From:           analytics.Query.From,
+		To:             analytics.Query.To,
+		IntervalMillis: analytics.Query.Interval.Milliseconds(),
+		GroupBy:        analytics.Query.GroupBy,
+		Totals:         makeJobAnalyticsSeries(analytics.Windows, analytics.Totals),
+		Groups:         groups,
+	}
+}
+
+func makeJobAnalyticsSeries(windows []models.JobAnalyticsWindow, series *dto.JobAnalyticsSeries) *JobAnalyticsSeries {
+	docs := make([]*JobAnalyticsWindow, len(series.Windows))
+	for i, stats := range series.Windows {
+		docs[i] = &JobAnalyticsWindow{
+			Start:    windows[i].Start,
+			End:      windows[i].End,
+			JobStats: makeJobStats(stats),
+		}
+	}
+	return &JobAnalyticsSeries{
+		Overall: makeJobStats(series.Overall),
+		Windows: docs,
+	}
+}
+
+func makeJobStats(stats *dto.JobStats) *JobStats {
+	return &JobStats{
+		Jobs:               stats.Jobs,
+		Succeeded:          stats.Succeeded,
+		Failed:             stats.Failed,
+		Canceled:           stats.Canceled,
+		Skipped:            stats.Skipped,
+		SuccessRate:        stats.SuccessRate,
+		DurationP50Millis:  makeMillisOrNil(stats.DurationP50),
+		DurationP95Millis:  makeMillisOrNil(stats.DurationP95),
+		QueueWaitP50Millis: makeMillisOrNil(stats.QueueWaitP50),
+		QueueWaitP95Millis: makeMillisOrNil(stats.QueueWaitP95),
+	}
+}
+
+// makeMillisOrNil returns the number of milliseconds in duration, or nil if duration is nil.
+func makeMillisOrNil(duration *time.Duration) *int64 {
+	if duration == nil {
+		return nil
+	}
+	millis := duration.Milliseconds()
+	return &millis
+}
@@ -3,6 +3,8 @@ package documents
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
@@ -25,8 +27,12 @@ type Job struct {
 	Workflow models.ResourceName `json:"workflow"`
 	// Description is an optional human-readable description of the job.
 	Description string `json:"description" db:"job_description"`
+	// Stage is the optional name of the stage the job belongs to within its workflow.
+	Stage models.ResourceName `json:"stage"`
 	// Depends describes the dependencies this job has on other jobs.
 	Depends []*JobDependency `json:"depends"`
+	// ArtifactFrom describes the dependencies this job has on artifacts produced by previous builds.
+	ArtifactFrom []*ExternalArtifactDependency `json:"artifact_from"`
 	// Services is a list of services to run in the background for the duration of the job.
 	// Services are started before the first step is run, and stopped after the last step completes.
 	Services []*Service `json:"services"`
@@ -36,14 +42,43 @@ type Job struct {
 	RunsOn []models.Label `json:"runs_on"`
 	// DockerConfig provides information about how to configure Docker to run this job, if Type is 'docker'.
 	DockerConfig *DockerConfig `json:"docker"`
+	// Shell is the shell to run build scripts with, for exec jobs and for docker jobs that don't configure
+	// a shell in DockerConfig.
+	Shell *string `json:"shell"`
+	// OperatingSystem is the operating system a runner must be running on to run the job, or empty if the job
+	// can run on any operating system.
+	OperatingSystem models.OperatingSystem `json:"operating_system"`
+	// Resources is the CPU, memory, disk space and GPUs the job requires from the runner it runs on, or nil if
+	// the job has no particular requirements.
+	Resources *models.Resources `json:"resources"`
+	// TimeoutSeconds is the maximum time the job may take, from when it is queued until it finishes, or zero
+	// if the server's default job timeout applies.
+	TimeoutSeconds int64 `json:"timeout_seconds"`
 	// StepExecution determines how the runner will execute steps within this job.
 	StepExecution models.StepExecution `json:"step_execution"`
+	// SkipCheckout is true if the job doesn't need the repo's source code and the repo will not be cloned.
+	SkipCheckout bool `json:"skip_checkout"`
+	// RetryOnRunnerLoss is true if the job is queued to run again, rather than failed, if its runner stops
+	// sending heartbeats before the job finishes.
+	RetryOnRunnerLoss bool `json:"retry_on_runner_loss"`
+	// PinSecrets is true if the job is given the versions of the repo's secrets that were current when the
+	// build started, rather than the latest versions.
+	PinSecrets bool `json:"pin_secrets"`
+	// Condition is an optional expression that must be true for the job to run, or else the job is skipped.
+	Condition string `json:"condition,omitempty"`
+	// OnlyPaths contains path patterns; the job is skipped unless a file changed by the commit matches one of them.
+	OnlyPaths []string `json:"only_paths,omitempty"`
+	// IgnorePaths contains path patterns; the job is skipped if every file changed by the commit matches one of them.
+	IgnorePaths []string `json:"ignore_paths,omitempty"`
 	// FingerprintCommands contains zero or more shell commands to execute to generate a unique fingerprint for the job.
 	// Two jobs in the same repo with the same name and fingerprint are considered identical.
 	FingerprintCommands []models.Command `json:"fingerprint_commands"`
 	// ArtifactDefinitions contains a list of artifacts the job is expected to produce that
 	// will be saved to the artifact store at the end of the job's execution.
 	ArtifactDefinitions []*ArtifactDefinition `json:"artifact_definitions"`
+	// CacheDefinitions contains a list of caches to restore before the job's steps run, and to save to
+	// the build cache once the job has succeeded.
+	CacheDefinitions []*CacheDefinition `json:"cache_definitions"`
 	// Environment contains a list of environment variables to export prior to executing the job.
 	Environment []*EnvVar `json:"environment"`
 
@@ -59,7 +94,9 @@ type Job struct {
 	RunnerID models.RunnerID `json:"runner_id"`
 	// IndirectToJobID records the ID of a job that previously ran successfully as part of another build
 	// and which is functionally identical to this job. If this is set it means this job did not actually
-	// run to avoid redundantly running the same thing more than once.
+	// run to avoid redundantly running the same thing more than once. For repos that deduplicate jobs this
+	// is also set while the job is queued, to an identical job that is running in another build; once that
+	// job finishes this job finishes with the same result.
 	IndirectToJobID models.JobID `json:"indirect_to_job_id"`
 	// Ref is the git ref from the build that the job was generated from (e.g. branch or tag)
 	Ref string `json:"ref"`
@@ -69,6 +106,8 @@ type Job struct {
 	Error *models.Error `json:"error"`
 	// Timings records the times at which the job transitioned between statuses.
 	Timings WorkflowTimings `json:"timings"`
+	// DurationMillis is the time the job spent running on its runner, or nil if the job has not finished running.
+	DurationMillis *int64 `json:"duration_millis,omitempty"`
 	// Fingerprint contains the hashed output of FingerprintCommands, as well as any other inputs the agent added (such
 	// as artifact hashes). This is only available after the job has run successfully.
 	Fingerprint string `json:"fingerprint"`
@@ -79,6 +118,9 @@ type Job struct {
 	// DefinitionDataHash is the hex-encoded hash of the job's definition data.
 	// NOTE: This hash captures the hash of the job's step definition data too.
 	DefinitionDataHash string `json:"definition_data_hash"`
+	// RunnerLossRetries is the number of times the job has been queued to run again because its runner
+	// stopped sending heartbeats.
+	RunnerLossRetries int `json:"runner_loss_retries"`
 
 	LogDescriptorURL string  `json:"log_descriptor_url"`
 	IndirectJobURL   *string `json:"indirect_job_url"`
@@ -90,6 +132,11 @@ func MakeJob(rctx routes.RequestContext, job *models.Job) *Job {
 		link := routes.MakeJobLink(rctx, job.IndirectToJobID)
 		indirectJobURL = &link
 	}
+	var durationMillis *int64
+	if job.Timings.RunningAt != nil && job.Timings.FinishedAt != nil {
+		millis := job.Timings.FinishedAt.Sub(job.Timings.RunningAt.Time).Milliseconds()
+		durationMillis = &millis
+	}
 	return &Job{
 		baseResourceDocument: baseResourceDocument{
 			URL: routes.MakeJobLink(rctx, job.ID),
@@ -104,14 +151,27 @@ func MakeJob(rctx routes.RequestContext, job *models.Job) *Job {
 		Name:                job.Name,
 		Workflow:            job.Workflow,
 		Description:         job.Description,
+		Stage:               job.Stage,
+		SkipCheckout:        job.SkipCheckout,
+		RetryOnRunnerLoss:   job.RetryOnRunnerLoss,
+		PinSecrets:          job.PinSecrets,
+		Condition:           job.Condition,
+		OnlyPaths:           job.OnlyPaths,
+		IgnorePaths:         job.IgnorePaths,
 		Depends:             MakeJobDependencies(job.Depends),
+		ArtifactFrom:        MakeExternalArtifactDependencies(job.ArtifactFrom),
 		Services:            MakeServices(job.Services),
 		Type:                job.Type,
 		RunsOn:              job.RunsOn,
-		DockerConfig:        MakeDockerConfig(job.DockerImage, job.DockerImagePullStrategy, job.DockerAuth, job.DockerShell),
+		DockerConfig:        MakeDockerConfig(job.DockerImage, job.DockerToolchain, job.DockerImagePullStrategy, job.DockerAuth, job.DockerShell),
+		Shell:               job.Shell,
+		OperatingSystem:     job.OperatingSystem,
+		Resources:           makeResourcesOrNil(job.GetResources()),
+		TimeoutSeconds:      job.TimeoutSeconds,
 		StepExecution:       job.StepExecution,
 		FingerprintCommands: job.FingerprintCommands,
 		ArtifactDefinitions: MakeArtifactDefinitions(job.ArtifactDefinitions),
+		CacheDefinitions:    MakeCacheDefinitions(job.CacheDefinitions),
 		Environment:         MakeEnvVars(job.Environment),
 
 		BuildID:                job.BuildID,
@@ -124,10 +184,12 @@ func MakeJob(rctx routes.RequestContext, job *models.Job) *Job {
 		Status:                 job.Status,
 		Error:                  job.Error,
 		Timings:                *MakeWorkflowTimings(&job.Timings),
+		DurationMillis:         durationMillis,
 		Fingerprint:            job.Fingerprint,
 		FingerprintHashType:    job.FingerprintHashType,
 		DefinitionDataHashType: job.DefinitionDataHashType,
 		DefinitionDataHash:     job.DefinitionDataHash,
+		RunnerLossRetries:      job.RunnerLossRetries,
 
 		LogDescriptorURL: routes.MakeLogLink(rctx, job.LogDescriptorID),
 		IndirectJobURL:   indirectJobURL,
@@ -203,15 +265,25 @@ type RunnableJob struct {
 	Steps []*Step `json:"steps"`
 	// Repo that was committed to.
 	Repo *Repo `json:"repo"`
+	// RepoOwnerName is the name of the legal entity (user or organization) that owns the repo.
+	RepoOwnerName models.ResourceName `json:"repo_owner_name"`
 	// Commit that the job was generated from.
 	Commit *Commit `json:"commit"`
 	// Jobs is the set of jobs that this job depends on.
 	Jobs []*Job `json:"jobs"`
+	// ExternalArtifacts is the set of artifacts from previous builds that this job depends on.
+	ExternalArtifacts []*Artifact `json:"external_artifacts"`
 	// JWT (JSON Web Token) that dynamic build jobs can use to access the dynamic API for this build
 	JWT string `json:"jwt"`
 	// WorkflowsToRun is a list of workflows that have been requested to run as part of the build options.
 	// This does not include workflows that become required as new dependencies when new jobs are submitted.
 	WorkflowsToRun []models.ResourceName `json:"workflows_to_run"`
+	// WorkflowOutputStubs provides values for the outputs of workflows that should not be run,
+	// as specified in the build options.
+	WorkflowOutputStubs models.WorkflowOutputStubs `json:"workflow_output_stubs"`
+	// BuildParameters contains the value of each parameter supplied for the build, or defaulted from the
+	// build definition.
+	BuildParameters models.BuildParameters `json:"build_parameters"`
 	// Log descriptor for the log to write to for this job.
 	LogDescriptorURL string `json:"log_descriptor_url"`
 }
@@ -221,14 +293,18 @@ func MakeRunnableJob(rctx routes.RequestContext, job *dto.RunnableJob) *Runnable
 		baseResourceDocument: baseResourceDocument{
 			URL: routes.MakeJobLink(rctx, job.ID),
 		},
-		Job:              MakeJob(rctx, job.Job),
-		Steps:            MakeSteps(rctx, job.Steps),
-		Repo:             MakeRepo(rctx, job.Repo),
-		Commit:           MakeCommit(rctx, job.Commit),
-		Jobs:             MakeJobs(rctx, job.Jobs),
-		JWT:              job.JWT,
-		WorkflowsToRun:   job.WorkflowsToRun,
-		LogDescriptorURL: routes.MakeLogLink(rctx, job.LogDescriptorID),
+		Job:                 MakeJob(rctx, job.Job),
+		Steps:               MakeSteps(rctx, job.Steps),
+		Repo:                MakeRepo(rctx, job.Repo),
+		RepoOwnerName:       job.RepoOwnerName,
+		Commit:              MakeCommit(rctx, job.Commit),
+		Jobs:                MakeJobs(rctx, job.Jobs),
+		ExternalArtifacts:   MakeArtifacts(rctx, job.ExternalArtifacts),
+		JWT:                 job.JWT,
+		WorkflowsToRun:      job.WorkflowsToRun,
+		WorkflowOutputStubs: job.WorkflowOutputStubs,
+		BuildParameters:     job.BuildParameters,
+		LogDescriptorURL:    routes.MakeLogLink(rctx, job.LogDescriptorID),
 	}
 }
 
@@ -325,3 +401,94 @@ func MakeArtifactDependencies(dependencies []*models.ArtifactDependency) []*Arti
 	}
 	return docs
 }
+
+type ExternalArtifactDependency struct {
+	RepoName  models.ResourceName `json:"repo_name"`
+	BuildName models.ResourceName `json:"build_name"`
+	GroupName models.ResourceName `json:"group_name"`
+}
+
+func MakeExternalArtifactDependency(dependency *models.ExternalArtifactDependency) *ExternalArtifactDependency {
+	return &ExternalArtifactDependency{
+		RepoName:  dependency.RepoName,
+		BuildName: dependency.BuildName,
+		GroupName: dependency.GroupName,
+	}
+}
+
+func MakeExternalArtifactDependencies(dependencies models.ExternalArtifactDependencies) []*ExternalArtifactDependency {
+	var docs []*ExternalArtifactDependency
+	for _, dependency := range dependencies {
+		docs = append(docs, MakeExternalArtifactDependency(dependency))
+	}
+	return docs
+}
+
+type JobSearchRequest struct {
+	*models.JobSearch
+}
+
+func NewJobSearchRequest() *JobSearchRequest {
+	return &JobSearchRequest{JobSearch: models.NewJobSearch()}
+}
+
+func (d *JobSearchRequest) Bind(r *http.Request) error {
+	return d.Validate()
+}
+
+func (d *JobSearchRequest) GetQuery() url.Values {
+	values := makePaginationQueryParams(d.Pagination)
+	if d.After != nil {
+		values.Set("after", d.After.Format(time.RFC3339Nano))
+	}
+	if d.Before != nil {
+		values.Set("before", d.Before.Format(time.RFC3339Nano))
+	}
+	for _, status := range d.Statuses {
+		values.Add("status", status.String())
+	}
+	return values
+}
+
+// FromQuery parses the search from query parameters. Times must be in RFC 3339 format. The runner to search
+// for is not read from the query, and must be set from the URL path by the caller.
+func (d *JobSearchRequest) FromQuery(values url.Values) error {
+	pagination, err := getPaginationFromQueryParams(values)
+	if err != nil {
+		return fmt.Errorf("error parsing pagination: %w", err)
+	}
+	d.Pagination = pagination
+
+	if values.Has("after") {
+		after, err := time.Parse(time.RFC3339Nano, values.Get("after"))
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("error parsing after: %s", err))
+		}
+		d.After = models.NewTimePtr(after)
+	}
+	if values.Has("before") {
+		before, err := time.Parse(time.RFC3339Nano, values.Get("before"))
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("error parsing before: %s", err))
+		}
+		d.Before = models.NewTimePtr(before)
+	}
+	for _, status := range values["status"] {
+		d.Statuses = append(d.Statuses, models.WorkflowStatus(status))
+	}
+	return d.Validate()
+}
+
+func (d *JobSearchRequest) Next(cursor *models.DirectionalCursor) PaginatedRequest {
+	d.Cursor = cursor
+	return d
+}
+
+// makeResourcesOrNil returns nil if no amount is set for any resource, so that documents only include
+// resources that have been declared.
+func makeResourcesOrNil(resources models.Resources) *models.Resources {
+	if resources.IsZero() {
+		return nil
+	}
+	return &resources
+}
@@ -1,6 +1,9 @@
 package documents
 
 import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
 )
@@ -34,6 +37,23 @@ func MakeLegalEntities(rctx routes.RequestContext, list []*models.LegalEntity) [
 	return docs
 }
 
+type PatchLegalEntityRequest struct {
+	// SchedulingWeight sets the legal entity's share of the runners it shares with other legal entities,
+	// when jobs from several legal entities are queued.
+	SchedulingWeight *int `json:"scheduling_weight"`
+}
+
+func (d *PatchLegalEntityRequest) Bind(r *http.Request) error {
+	if d.SchedulingWeight == nil {
+		return gerror.NewErrValidationFailed("SchedulingWeight must be specified")
+	}
+	err := models.ValidateLegalEntitySchedulingWeight(*d.SchedulingWeight)
+	if err != nil {
+		return gerror.NewErrValidationFailed(err.Error())
+	}
+	return nil
+}
+
 type LegalEntitySetupStatus struct {
 	baseResourceDocument
 	ID        models.LegalEntityID `json:"id"`
@@ -31,6 +31,12 @@ type LogDescriptor struct {
 	Sealed bool `json:"sealed"`
 	// SizeBytes is calculated and set at the time the log is sealed
 	SizeBytes int64 `json:"size_bytes"`
+	// ArchivedAt is set when the log's data was compressed and moved to cold storage by the retention policy.
+	ArchivedAt *models.Time `json:"archived_at"`
+	// ArchivedSizeBytes is the compressed size of the log's data, if archived.
+	ArchivedSizeBytes int64 `json:"archived_size_bytes"`
+	// ExpiredAt is set when the log's data was deleted by the retention policy.
+	ExpiredAt *models.Time `json:"expired_at"`
 
 	DataURL string `json:"data_url"`
 }
@@ -51,6 +57,10 @@ func MakeLog(rctx routes.RequestContext, log *models.LogDescriptor) *LogDescript
 		Sealed:     log.Sealed,
 		SizeBytes:  log.SizeBytes,
 
+		ArchivedAt:        log.ArchivedAt,
+		ArchivedSizeBytes: log.ArchivedSizeBytes,
+		ExpiredAt:         log.ExpiredAt,
+
 		DataURL: routes.MakeLogDataLink(rctx, log.ID),
 	}
 }
@@ -75,6 +85,19 @@ func (m *LogDescriptor) GetCreatedAt() models.Time {
 	return m.CreatedAt
 }
 
+// LogUsage summarizes the storage used by the logs belonging to a repo's builds, jobs and steps.
+type LogUsage struct {
+	*models.LogUsage
+	URL string `json:"url"`
+}
+
+func MakeLogUsage(rctx routes.RequestContext, usage *models.LogUsage) *LogUsage {
+	return &LogUsage{
+		LogUsage: usage,
+		URL:      routes.MakeRepoLogUsageLink(rctx, usage.RepoID),
+	}
+}
+
 type LogSearchRequest struct {
 	// TODO: Include all model fields directly in this object
 	*models.LogSearch
@@ -99,6 +122,9 @@ func (d *LogSearchRequest) GetQuery() url.Values {
 	if d.Expand != nil {
 		values.Set("expand", url.QueryEscape(strconv.FormatBool(*d.Expand)))
 	}
+	if d.Follow != nil {
+		values.Set("follow", url.QueryEscape(strconv.FormatBool(*d.Follow)))
+	}
 	return values
 }
 
@@ -140,5 +166,17 @@ func (d *LogSearchRequest) FromQuery(values url.Values) error {
 		}
 		d.Expand = &expand
 	}
+	vals, ok = values["follow"]
+	if ok && len(vals) > 0 {
+		val, err := url.QueryUnescape(vals[0])
+		if err != nil {
+			return fmt.Errorf("error unescaping follow: %w", err)
+		}
+		follow, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("error parsing follow: %w", err)
+		}
+		d.Follow = &follow
+	}
 	return d.Validate()
 }
@@ -0,0 +1,134 @@
+package documents
+
+import (
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// NotificationSetting is used to represent a notification setting without its webhook URL, which is a secret.
+type NotificationSetting struct {
+	baseResourceDocument
+
+	ID        models.NotificationSettingID `json:"id"`
+	CreatedAt models.Time                  `json:"created_at"`
+	UpdatedAt models.Time                  `json:"updated_at"`
+	ETag      models.ETag                  `json:"etag" hash:"ignore"`
+
+	// RepoID is the ID of the repo whose builds notifications are sent for.
+	RepoID models.RepoID `json:"repo_id"`
+	// Channel is the type of system notifications are sent to, e.g. "slack" or "discord".
+	Channel models.NotificationChannelType `json:"channel"`
+	// OnSuccess is true if a notification is sent every time a build succeeds.
+	OnSuccess bool `json:"on_success"`
+	// OnFailure is true if a notification is sent every time a build fails.
+	OnFailure bool `json:"on_failure"`
+	// OnRecovery is true if a notification is sent when a build succeeds after the previous build for the same ref failed.
+	OnRecovery bool `json:"on_recovery"`
+	// MessageTemplate is the Go text/template used to format messages, or empty if the default message is used.
+	MessageTemplate string `json:"message_template"`
+}
+
+func MakeNotificationSetting(rctx routes.RequestContext, setting *models.NotificationSetting) *NotificationSetting {
+	return &NotificationSetting{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeNotificationSettingLink(rctx, setting.RepoID, setting.ID),
+		},
+
+		ID:        setting.ID,
+		CreatedAt: setting.CreatedAt,
+		UpdatedAt: setting.UpdatedAt,
+		ETag:      setting.ETag,
+
+		RepoID:          setting.RepoID,
+		Channel:         setting.Channel,
+		OnSuccess:       setting.OnSuccess,
+		OnFailure:       setting.OnFailure,
+		OnRecovery:      setting.OnRecovery,
+		MessageTemplate: setting.MessageTemplate,
+	}
+}
+
+func MakeNotificationSettings(rctx routes.RequestContext, settings []*models.NotificationSetting) []*NotificationSetting {
+	var docs []*NotificationSetting
+	for _, model := range settings {
+		docs = append(docs, MakeNotificationSetting(rctx, model))
+	}
+	return docs
+}
+
+func (d *NotificationSetting) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *NotificationSetting) GetKind() models.ResourceKind {
+	return models.NotificationSettingResourceKind
+}
+
+func (d *NotificationSetting) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// CreateNotificationSettingRequest is used when creating a notification setting
+type CreateNotificationSettingRequest struct {
+	// Channel is the type of system to send notifications to, e.g. "slack" or "discord".
+	Channel models.NotificationChannelType `json:"channel"`
+	// WebhookURL is the incoming webhook URL to send notifications to.
+	WebhookURL string `json:"webhook_url"`
+	// OnSuccess is true if a notification should be sent every time a build succeeds.
+	OnSuccess bool `json:"on_success"`
+	// OnFailure is true if a notification should be sent every time a build fails.
+	OnFailure bool `json:"on_failure"`
+	// OnRecovery is true if a notification should be sent when a build succeeds after the previous build for the same ref failed.
+	OnRecovery bool `json:"on_recovery"`
+	// MessageTemplate is an optional Go text/template used to format messages.
+	MessageTemplate string `json:"message_template"`
+}
+
+func (d *CreateNotificationSettingRequest) Bind(r *http.Request) error {
+	if !d.Channel.Valid() {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Channel must be one of %q or %q", models.NotificationChannelTypeSlack, models.NotificationChannelTypeDiscord))
+	}
+	if d.WebhookURL == "" {
+		return gerror.NewErrValidationFailed("Webhook URL must not be empty")
+	}
+	return validateMessageTemplate(d.MessageTemplate)
+}
+
+// PatchNotificationSettingRequest is used when updating a notification setting
+type PatchNotificationSettingRequest struct {
+	Channel         *models.NotificationChannelType `json:"channel"`
+	WebhookURL      *string                         `json:"webhook_url"`
+	OnSuccess       *bool                           `json:"on_success"`
+	OnFailure       *bool                           `json:"on_failure"`
+	OnRecovery      *bool                           `json:"on_recovery"`
+	MessageTemplate *string                         `json:"message_template"`
+}
+
+func (d *PatchNotificationSettingRequest) Bind(r *http.Request) error {
+	if d.Channel != nil && !d.Channel.Valid() {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Channel must be one of %q or %q", models.NotificationChannelTypeSlack, models.NotificationChannelTypeDiscord))
+	}
+	if d.WebhookURL != nil && *d.WebhookURL == "" {
+		return gerror.NewErrValidationFailed("Webhook URL must not be empty")
+	}
+	if d.MessageTemplate != nil {
+		return validateMessageTemplate(*d.MessageTemplate)
+	}
+	return nil
+}
+
+func validateMessageTemplate(messageTemplate string) error {
+	if messageTemplate == "" {
+		return nil
+	}
+	_, err := template.New("message").Parse(messageTemplate)
+	if err != nil {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Message template is invalid: %s", err))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+type CreateOIDCTokenRequest struct {
+	// The audience the token is intended for (e.g. "sts.amazonaws.com"). Defaults to the issuer URL if empty.
+	Audience string `json:"audience"`
+}
+
+func (d *CreateOIDCTokenRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+// OIDCToken is a document containing a signed OpenID Connect ID token identifying a job.
+type OIDCToken struct {
+	Token     string      `json:"token"`
+	ExpiresAt models.Time `json:"expires_at"`
+}
+
+func MakeOIDCToken(token *dto.OIDCToken) *OIDCToken {
+	return &OIDCToken{
+		Token:     token.Token,
+		ExpiresAt: token.ExpiresAt,
+	}
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document describing the server as an issuer of tokens.
+type OpenIDConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+func MakeOpenIDConfiguration(issuerURL string, jwksURL string) *OpenIDConfiguration {
+	return &OpenIDConfiguration{
+		Issuer:                           issuerURL,
+		JWKSURI:                          jwksURL,
+		ResponseTypesSupported:           []string{"id_token"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{"ES256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "nbf", "jti",
+			"legal_entity", "repo", "repo_id", "build_id", "build_name", "workflow", "job", "job_id", "ref", "commit_sha",
+		},
+	}
+}
+
+// JSONWebKeySet is the set of public keys tokens issued by the server can be verified with.
+type JSONWebKeySet struct {
+	Keys []*dto.JSONWebKey `json:"keys"`
+}
+
+func MakeJSONWebKeySet(keys []*dto.JSONWebKey) *JSONWebKeySet {
+	return &JSONWebKeySet{Keys: keys}
+}
@@ -0,0 +1,218 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// OutgoingWebhook is used to represent an outgoing webhook without its secret.
+type OutgoingWebhook struct {
+	baseResourceDocument
+
+	ID        models.OutgoingWebhookID `json:"id"`
+	CreatedAt models.Time              `json:"created_at"`
+	UpdatedAt models.Time              `json:"updated_at"`
+	ETag      models.ETag              `json:"etag" hash:"ignore"`
+
+	// LegalEntityID is the ID of the legal entity the webhook belongs to.
+	LegalEntityID models.LegalEntityID `json:"legal_entity_id"`
+	// RepoID is the ID of the repo the webhook receives events for, or nil if the webhook receives
+	// events for all of the legal entity's repos.
+	RepoID *models.RepoID `json:"repo_id,omitempty"`
+	// URL is the endpoint payloads are delivered to.
+	URL string `json:"url"`
+	// OnBuildStatusChanged is true if a payload is delivered every time the status of a build changes.
+	OnBuildStatusChanged bool `json:"on_build_status_changed"`
+	// OnJobStatusChanged is true if a payload is delivered every time the status of a job changes.
+	OnJobStatusChanged bool `json:"on_job_status_changed"`
+	// OnRunnerHealthChanged is true if a payload is delivered every time one of the legal entity's runners
+	// becomes unhealthy or healthy again.
+	OnRunnerHealthChanged bool `json:"on_runner_health_changed"`
+	// Enabled is false if no payloads are delivered to the webhook, because it was disabled manually or
+	// because deliveries to it kept failing.
+	Enabled bool `json:"enabled"`
+	// DisabledAt is the time the webhook was disabled, or nil if the webhook is enabled.
+	DisabledAt *models.Time `json:"disabled_at,omitempty"`
+	// DisabledReason describes why the webhook was disabled.
+	DisabledReason string `json:"disabled_reason,omitempty"`
+	// ConsecutiveFailedDeliveries is the number of deliveries in a row that failed after every attempt to
+	// deliver them, since the last successful delivery.
+	ConsecutiveFailedDeliveries int `json:"consecutive_failed_deliveries"`
+
+	DeliveriesURL string `json:"deliveries_url"`
+}
+
+func MakeOutgoingWebhook(rctx routes.RequestContext, webhook *models.OutgoingWebhook) *OutgoingWebhook {
+	doc := &OutgoingWebhook{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeOutgoingWebhookLink(rctx, webhook.GetParentID(), webhook.ID),
+		},
+
+		ID:        webhook.ID,
+		CreatedAt: webhook.CreatedAt,
+		UpdatedAt: webhook.UpdatedAt,
+		ETag:      webhook.ETag,
+
+		LegalEntityID:               webhook.LegalEntityID,
+		URL:                         webhook.URL,
+		OnBuildStatusChanged:        webhook.OnBuildStatusChanged,
+		OnJobStatusChanged:          webhook.OnJobStatusChanged,
+		OnRunnerHealthChanged:       webhook.OnRunnerHealthChanged,
+		Enabled:                     !webhook.IsDisabled(),
+		DisabledAt:                  webhook.DisabledAt,
+		DisabledReason:              webhook.DisabledReason,
+		ConsecutiveFailedDeliveries: webhook.ConsecutiveFailedDeliveries,
+
+		DeliveriesURL: routes.MakeOutgoingWebhookDeliveriesLink(rctx, webhook.GetParentID(), webhook.ID),
+	}
+	if webhook.RepoID.Valid() {
+		doc.RepoID = &webhook.RepoID
+	}
+	return doc
+}
+
+func MakeOutgoingWebhooks(rctx routes.RequestContext, webhooks []*models.OutgoingWebhook) []*OutgoingWebhook {
+	var docs []*OutgoingWebhook
+	for _, model := range webhooks {
+		docs = append(docs, MakeOutgoingWebhook(rctx, model))
+	}
+	return docs
+}
+
+func (d *OutgoingWebhook) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *OutgoingWebhook) GetKind() models.ResourceKind {
+	return models.OutgoingWebhookResourceKind
+}
+
+func (d *OutgoingWebhook) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// CreateOutgoingWebhookRequest is used when creating an outgoing webhook
+type CreateOutgoingWebhookRequest struct {
+	// URL is the https endpoint to deliver payloads to.
+	URL string `json:"url"`
+	// Secret is used to sign payloads; the signature is sent in the X-BuildBeaver-Signature-256 header.
+	Secret string `json:"secret"`
+	// OnBuildStatusChanged is true if a payload should be delivered every time the status of a build changes.
+	OnBuildStatusChanged bool `json:"on_build_status_changed"`
+	// OnJobStatusChanged is true if a payload should be delivered every time the status of a job changes.
+	OnJobStatusChanged bool `json:"on_job_status_changed"`
+	// OnRunnerHealthChanged is true if a payload should be delivered every time one of the legal entity's
+	// runners becomes unhealthy or healthy again. Can only be set for webhooks registered against a legal entity.
+	OnRunnerHealthChanged bool `json:"on_runner_health_changed"`
+}
+
+func (d *CreateOutgoingWebhookRequest) Bind(r *http.Request) error {
+	if d.URL == "" {
+		return gerror.NewErrValidationFailed("URL must not be empty")
+	}
+	if d.Secret == "" {
+		return gerror.NewErrValidationFailed("Secret must not be empty")
+	}
+	return nil
+}
+
+// PatchOutgoingWebhookRequest is used when updating an outgoing webhook
+type PatchOutgoingWebhookRequest struct {
+	URL                   *string `json:"url"`
+	Secret                *string `json:"secret"`
+	OnBuildStatusChanged  *bool   `json:"on_build_status_changed"`
+	OnJobStatusChanged    *bool   `json:"on_job_status_changed"`
+	OnRunnerHealthChanged *bool   `json:"on_runner_health_changed"`
+	// Enabled disables the webhook if false, or re-enables a disabled webhook if true.
+	Enabled *bool `json:"enabled"`
+}
+
+func (d *PatchOutgoingWebhookRequest) Bind(r *http.Request) error {
+	if d.URL != nil && *d.URL == "" {
+		return gerror.NewErrValidationFailed("URL must not be empty")
+	}
+	if d.Secret != nil && *d.Secret == "" {
+		return gerror.NewErrValidationFailed("Secret must not be empty")
+	}
+	return nil
+}
+
+// OutgoingWebhookDelivery records a payload delivered (or to be delivered) to an outgoing webhook.
+type OutgoingWebhookDelivery struct {
+	baseResourceDocument
+
+	ID        models.OutgoingWebhookDeliveryID `json:"id"`
+	CreatedAt models.Time                      `json:"created_at"`
+	UpdatedAt models.Time                      `json:"updated_at"`
+	ETag      models.ETag                      `json:"etag" hash:"ignore"`
+
+	// WebhookID is the ID of the outgoing webhook the payload is delivered to.
+	WebhookID models.OutgoingWebhookID `json:"webhook_id"`
+	// EventType is the type of event the payload describes.
+	EventType models.EventType `json:"event_type"`
+	// Payload is the JSON document delivered to the webhook.
+	Payload string `json:"payload"`
+	// Status is "pending" until the payload is delivered ("succeeded") or all attempts have failed ("failed").
+	Status models.OutgoingWebhookDeliveryStatus `json:"status"`
+	// Attempts is the number of attempts made to deliver the payload so far.
+	Attempts int `json:"attempts"`
+	// ResponseStatusCode is the HTTP status code returned by the endpoint on the most recent attempt,
+	// or zero if no response was received.
+	ResponseStatusCode int `json:"response_status_code"`
+	// ResponseBody is the start of the body returned by the endpoint on the most recent attempt.
+	ResponseBody string `json:"response_body"`
+	// Error describes why the most recent attempt failed, or is empty if it succeeded.
+	Error string `json:"error,omitempty"`
+	// AttemptLog records the outcome of each attempt to deliver the payload, oldest first.
+	AttemptLog models.OutgoingWebhookDeliveryAttempts `json:"attempt_log"`
+
+	RedeliverURL string `json:"redeliver_url"`
+}
+
+func MakeOutgoingWebhookDelivery(rctx routes.RequestContext, webhook *models.OutgoingWebhook, delivery *models.OutgoingWebhookDelivery) *OutgoingWebhookDelivery {
+	return &OutgoingWebhookDelivery{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeOutgoingWebhookDeliveryLink(rctx, webhook.GetParentID(), webhook.ID, delivery.ID),
+		},
+
+		ID:        delivery.ID,
+		CreatedAt: delivery.CreatedAt,
+		UpdatedAt: delivery.UpdatedAt,
+		ETag:      delivery.ETag,
+
+		WebhookID:          delivery.WebhookID,
+		EventType:          delivery.EventType,
+		Payload:            delivery.Payload,
+		Status:             delivery.Status,
+		Attempts:           delivery.Attempts,
+		ResponseStatusCode: delivery.ResponseStatusCode,
+		ResponseBody:       delivery.ResponseBody,
+		Error:              delivery.Error,
+		AttemptLog:         delivery.AttemptLog,
+
+		RedeliverURL: routes.MakeOutgoingWebhookRedeliverLink(rctx, webhook.GetParentID(), webhook.ID, delivery.ID),
+	}
+}
+
+func MakeOutgoingWebhookDeliveries(rctx routes.RequestContext, webhook *models.OutgoingWebhook, deliveries []*models.OutgoingWebhookDelivery) []*OutgoingWebhookDelivery {
+	var docs []*OutgoingWebhookDelivery
+	for _, model := range deliveries {
+		docs = append(docs, MakeOutgoingWebhookDelivery(rctx, webhook, model))
+	}
+	return docs
+}
+
+func (d *OutgoingWebhookDelivery) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *OutgoingWebhookDelivery) GetKind() models.ResourceKind {
+	return models.OutgoingWebhookDeliveryResourceKind
+}
+
+func (d *OutgoingWebhookDelivery) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
@@ -0,0 +1,101 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// PermissionOverride grants or denies an access control group an operation on a single repo, taking precedence
+// over the permissions the group holds on the repo's owner.
+type PermissionOverride struct {
+	baseResourceDocument
+
+	ID        models.GrantID `json:"id"`
+	CreatedAt models.Time    `json:"created_at"`
+	UpdatedAt models.Time    `json:"updated_at"`
+
+	// RepoID is the ID of the repo the override applies to.
+	RepoID models.RepoID `json:"repo_id"`
+	// GroupID is the ID of the group the operation is granted to or denied for.
+	GroupID models.GroupID `json:"group_id"`
+	// Operation is the operation being granted or denied.
+	Operation models.Operation `json:"operation"`
+	// Deny is true if the operation is denied rather than granted.
+	Deny bool `json:"deny"`
+}
+
+func MakePermissionOverride(rctx routes.RequestContext, repoID models.RepoID, grant *models.Grant) *PermissionOverride {
+	return &PermissionOverride{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakePermissionOverrideLink(rctx, repoID, grant.ID),
+		},
+
+		ID:        grant.ID,
+		CreatedAt: grant.CreatedAt,
+		UpdatedAt: grant.UpdatedAt,
+
+		RepoID:    repoID,
+		GroupID:   grant.AuthorizedGroupID,
+		Operation: grant.GetOperation(),
+		Deny:      grant.Deny,
+	}
+}
+
+func MakePermissionOverrides(rctx routes.RequestContext, repoID models.RepoID, grants []*models.Grant) []*PermissionOverride {
+	var docs []*PermissionOverride
+	for _, model := range grants {
+		docs = append(docs, MakePermissionOverride(rctx, repoID, model))
+	}
+	return docs
+}
+
+func (d *PermissionOverride) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *PermissionOverride) GetKind() models.ResourceKind {
+	return models.GrantResourceKind
+}
+
+func (d *PermissionOverride) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// SetPermissionOverrideRequest is used when granting or denying a group an operation on a repo
+type SetPermissionOverrideRequest struct {
+	GroupID   *models.GroupID   `json:"group_id"`
+	Operation *models.Operation `json:"operation"`
+	Deny      bool              `json:"deny"`
+}
+
+func (d *SetPermissionOverrideRequest) Bind(r *http.Request) error {
+	if d.GroupID == nil {
+		return gerror.NewErrValidationFailed("group_id must be set")
+	}
+	if d.Operation == nil {
+		return gerror.NewErrValidationFailed("operation must be set")
+	}
+	return nil
+}
+
+// EffectivePermissions lists the operations an identity is authorized to perform on a resource, after taking
+// into account all of the identity's grants, group memberships and permission overrides.
+type EffectivePermissions struct {
+	IdentityID models.IdentityID   `json:"identity_id"`
+	ResourceID models.ResourceID   `json:"resource_id"`
+	Operations []*models.Operation `json:"operations"`
+}
+
+func MakeEffectivePermissions(identityID models.IdentityID, resourceID models.ResourceID, operations []*models.Operation) *EffectivePermissions {
+	if operations == nil {
+		operations = []*models.Operation{}
+	}
+	return &EffectivePermissions{
+		IdentityID: identityID,
+		ResourceID: resourceID,
+		Operations: operations,
+	}
+}
@@ -0,0 +1,89 @@
+package documents
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// PersonalAccessToken is a token a user has created to access the API as themselves, limited to a set of
+// scopes. The token itself is only included in the document returned when the token is created.
+type PersonalAccessToken struct {
+	baseResourceDocument
+
+	ID        models.CredentialID `json:"id"`
+	CreatedAt models.Time         `json:"created_at"`
+
+	// Name describes what the token is for.
+	Name string `json:"name"`
+	// Scopes limits the operations the token can perform.
+	Scopes models.TokenScopes `json:"scopes"`
+	// ExpiresAt is the time after which the token can no longer be used.
+	ExpiresAt *models.Time `json:"expires_at"`
+	// LastUsedAt is approximately when the token was last used, or null if it has never been used.
+	LastUsedAt *models.Time `json:"last_used_at"`
+	// Token is the secret token to authenticate with. Only set when the token is created; it can't be read again.
+	Token string `json:"token,omitempty"`
+}
+
+func MakePersonalAccessToken(rctx routes.RequestContext, credential *models.Credential) *PersonalAccessToken {
+	return &PersonalAccessToken{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakePersonalAccessTokenLink(rctx, credential.ID),
+		},
+
+		ID:        credential.ID,
+		CreatedAt: credential.CreatedAt,
+
+		Name:       credential.Name,
+		Scopes:     credential.Scopes,
+		ExpiresAt:  credential.ExpiresAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
+
+func MakePersonalAccessTokens(rctx routes.RequestContext, credentials []*models.Credential) []*PersonalAccessToken {
+	var docs []*PersonalAccessToken
+	for _, model := range credentials {
+		docs = append(docs, MakePersonalAccessToken(rctx, model))
+	}
+	return docs
+}
+
+func (d *PersonalAccessToken) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *PersonalAccessToken) GetKind() models.ResourceKind {
+	return models.CredentialResourceKind
+}
+
+func (d *PersonalAccessToken) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// CreatePersonalAccessTokenRequest is used when creating a new personal access token.
+type CreatePersonalAccessTokenRequest struct {
+	// Name describes what the token is for, e.g. "release script".
+	Name string `json:"name"`
+	// Scopes limits the operations the token can perform, e.g. ["read:build"].
+	Scopes models.TokenScopes `json:"scopes"`
+	// ExpiresAt is the time after which the token can no longer be used.
+	ExpiresAt *models.Time `json:"expires_at"`
+}
+
+func (d *CreatePersonalAccessTokenRequest) Bind(r *http.Request) error {
+	if strings.TrimSpace(d.Name) == "" {
+		return gerror.NewErrValidationFailed("Name must not be empty")
+	}
+	if err := d.Scopes.Validate(); err != nil {
+		return gerror.NewErrValidationFailed(err.Error())
+	}
+	if d.ExpiresAt == nil {
+		return gerror.NewErrValidationFailed("Expires at must be set")
+	}
+	return nil
+}
@@ -30,6 +30,12 @@ type Repo struct {
 	ExternalID       *models.ExternalResourceID `json:"external_id"`
 	ExternalMetadata string                     `json:"external_metadata"`
 
+	DynamicJobRestrictions *models.DynamicJobRestrictions `json:"dynamic_job_restrictions"`
+	CoverageSettings       *models.CoverageSettings       `json:"coverage_settings"`
+	PublicBuilds           bool                           `json:"public_builds"`
+	BuildPrioritySettings  *models.BuildPrioritySettings  `json:"build_priority_settings"`
+	DeduplicateJobs        bool                           `json:"deduplicate_jobs"`
+
 	BuildsURL      string `json:"builds_url"`
 	BuildSearchURL string `json:"build_search_url"`
 	SecretsURL     string `json:"secrets_url"`
@@ -60,6 +66,12 @@ func MakeRepo(rctx routes.RequestContext, repo *models.Repo) *Repo {
 		ExternalID:       repo.ExternalID,
 		ExternalMetadata: repo.ExternalMetadata,
 
+		DynamicJobRestrictions: repo.DynamicJobRestrictions,
+		CoverageSettings:       repo.CoverageSettings,
+		PublicBuilds:           repo.PublicBuilds,
+		BuildPrioritySettings:  repo.BuildPrioritySettings,
+		DeduplicateJobs:        repo.DeduplicateJobs,
+
 		BuildsURL:      routes.MakeBuildsLink(rctx, repo.ID),
 		BuildSearchURL: routes.MakeBuildSearchLink(rctx, repo.ID),
 		SecretsURL:     routes.MakeSecretsLink(rctx, repo.ID),
@@ -88,11 +100,45 @@ func (d *Repo) GetCreatedAt() models.Time {
 
 type PatchRepoRequest struct {
 	Enabled *bool `json:"enabled"`
+	// DynamicJobRestrictions replaces the repo's restrictions on dynamic jobs. Supply an empty object
+	// to remove all restrictions.
+	DynamicJobRestrictions *models.DynamicJobRestrictions `json:"dynamic_job_restrictions"`
+	// CoverageSettings replaces the repo's code coverage settings. Supply an empty object to stop checking
+	// coverage for the repo.
+	CoverageSettings *models.CoverageSettings `json:"coverage_settings"`
+	// PublicBuilds sets whether anyone, including people who have not logged in, can read the repo's
+	// builds, logs and artifacts.
+	PublicBuilds *bool `json:"public_builds"`
+	// BuildPrioritySettings replaces the repo's build priority settings. Supply an empty object to give
+	// new builds the normal priority.
+	BuildPrioritySettings *models.BuildPrioritySettings `json:"build_priority_settings"`
+	// DeduplicateJobs sets whether queued jobs that are identical to a job already running in another build
+	// finish with the running job's result instead of being run again.
+	DeduplicateJobs *bool `json:"deduplicate_jobs"`
 }
 
 func (d *PatchRepoRequest) Bind(r *http.Request) error {
-	if d.Enabled == nil {
-		return gerror.NewErrValidationFailed("Enabled must be specified")
+	if d.Enabled == nil && d.DynamicJobRestrictions == nil && d.CoverageSettings == nil && d.PublicBuilds == nil &&
+		d.BuildPrioritySettings == nil && d.DeduplicateJobs == nil {
+		return gerror.NewErrValidationFailed("At least one of Enabled, DynamicJobRestrictions, CoverageSettings, PublicBuilds, BuildPrioritySettings or DeduplicateJobs must be specified")
+	}
+	if d.DynamicJobRestrictions != nil {
+		err := d.DynamicJobRestrictions.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+	}
+	if d.CoverageSettings != nil {
+		err := d.CoverageSettings.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+	}
+	if d.BuildPrioritySettings != nil {
+		err := d.BuildPrioritySettings.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
 	}
 	return nil
 }
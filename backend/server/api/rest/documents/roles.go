@@ -0,0 +1,193 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// Role is a named bundle of operations defined by a company, that can be assigned to the company's groups
+// and members.
+type Role struct {
+	baseResourceDocument
+
+	ID        models.RoleID `json:"id"`
+	CreatedAt models.Time   `json:"created_at"`
+	UpdatedAt models.Time   `json:"updated_at"`
+	ETag      models.ETag   `json:"etag" hash:"ignore"`
+
+	// LegalEntityID is the ID of the company the role belongs to.
+	LegalEntityID models.LegalEntityID `json:"legal_entity_id"`
+	// Name of the role, unique within the company.
+	Name models.ResourceName `json:"name"`
+	// Description is a human-readable description of the role.
+	Description string `json:"description"`
+	// Operations are the operations granted to the role's assignees.
+	Operations models.RoleOperations `json:"operations"`
+
+	// AssignmentsURL is the URL to list and create the role's assignments.
+	AssignmentsURL string `json:"assignments_url"`
+}
+
+func MakeRole(rctx routes.RequestContext, role *models.Role) *Role {
+	return &Role{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeRoleLink(rctx, role.LegalEntityID, role.ID),
+		},
+
+		ID:        role.ID,
+		CreatedAt: role.CreatedAt,
+		UpdatedAt: role.UpdatedAt,
+		ETag:      role.ETag,
+
+		LegalEntityID: role.LegalEntityID,
+		Name:          role.Name,
+		Description:   role.Description,
+		Operations:    role.Operations,
+
+		AssignmentsURL: routes.MakeRoleAssignmentsLink(rctx, role.LegalEntityID, role.ID),
+	}
+}
+
+func MakeRoles(rctx routes.RequestContext, roles []*models.Role) []*Role {
+	var docs []*Role
+	for _, model := range roles {
+		docs = append(docs, MakeRole(rctx, model))
+	}
+	return docs
+}
+
+func (d *Role) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *Role) GetKind() models.ResourceKind {
+	return models.RoleResourceKind
+}
+
+func (d *Role) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// RoleAssignment assigns a role to either a group or an identity.
+type RoleAssignment struct {
+	baseResourceDocument
+
+	ID        models.RoleAssignmentID `json:"id"`
+	CreatedAt models.Time             `json:"created_at"`
+
+	// RoleID is the ID of the role being assigned.
+	RoleID models.RoleID `json:"role_id"`
+	// AuthorizedIdentityID is the ID of the identity the role is assigned to, if assigned to an identity.
+	AuthorizedIdentityID *models.IdentityID `json:"authorized_identity_id,omitempty"`
+	// AuthorizedGroupID is the ID of the group the role is assigned to, if assigned to a group.
+	AuthorizedGroupID *models.GroupID `json:"authorized_group_id,omitempty"`
+}
+
+func MakeRoleAssignment(rctx routes.RequestContext, role *models.Role, assignment *models.RoleAssignment) *RoleAssignment {
+	doc := &RoleAssignment{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeRoleAssignmentLink(rctx, role.LegalEntityID, role.ID, assignment.ID),
+		},
+
+		ID:        assignment.ID,
+		CreatedAt: assignment.CreatedAt,
+
+		RoleID: assignment.RoleID,
+	}
+	if assignment.AuthorizedIdentityID.Valid() {
+		doc.AuthorizedIdentityID = &assignment.AuthorizedIdentityID
+	}
+	if assignment.AuthorizedGroupID.Valid() {
+		doc.AuthorizedGroupID = &assignment.AuthorizedGroupID
+	}
+	return doc
+}
+
+func MakeRoleAssignments(rctx routes.RequestContext, role *models.Role, assignments []*models.RoleAssignment) []*RoleAssignment {
+	var docs []*RoleAssignment
+	for _, model := range assignments {
+		docs = append(docs, MakeRoleAssignment(rctx, role, model))
+	}
+	return docs
+}
+
+func (d *RoleAssignment) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *RoleAssignment) GetKind() models.ResourceKind {
+	return models.RoleAssignmentResourceKind
+}
+
+func (d *RoleAssignment) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// RoleTemplate describes a commonly needed role that can be created without listing its operations.
+type RoleTemplate struct {
+	Name        models.ResourceName `json:"name"`
+	Description string              `json:"description"`
+	Operations  []*models.Operation `json:"operations"`
+}
+
+func MakeRoleTemplates(templates []*models.RoleTemplate) []*RoleTemplate {
+	var docs []*RoleTemplate
+	for _, template := range templates {
+		docs = append(docs, &RoleTemplate{
+			Name:        template.Name,
+			Description: template.Description,
+			Operations:  template.Operations,
+		})
+	}
+	return docs
+}
+
+// CreateRoleRequest is used when creating a role
+type CreateRoleRequest struct {
+	// Template is the name of a role template to create the role from. When set, the template provides the
+	// role's operations, and its name and description unless they are specified.
+	Template    models.ResourceName   `json:"template"`
+	Name        models.ResourceName   `json:"name"`
+	Description string                `json:"description"`
+	Operations  models.RoleOperations `json:"operations"`
+}
+
+func (d *CreateRoleRequest) Bind(r *http.Request) error {
+	if d.Template == "" && d.Name == "" {
+		return gerror.NewErrValidationFailed("Name must not be empty")
+	}
+	if d.Template != "" && len(d.Operations) > 0 {
+		return gerror.NewErrValidationFailed("Operations can not be specified when creating a role from a template")
+	}
+	return nil
+}
+
+// PatchRoleRequest is used when updating a role
+type PatchRoleRequest struct {
+	Name        *models.ResourceName   `json:"name"`
+	Description *string                `json:"description"`
+	Operations  *models.RoleOperations `json:"operations"`
+}
+
+func (d *PatchRoleRequest) Bind(r *http.Request) error {
+	if d.Name != nil && *d.Name == "" {
+		return gerror.NewErrValidationFailed("Name must not be empty")
+	}
+	return nil
+}
+
+// CreateRoleAssignmentRequest is used when assigning a role; exactly one of IdentityID or GroupID must be set.
+type CreateRoleAssignmentRequest struct {
+	IdentityID *models.IdentityID `json:"identity_id"`
+	GroupID    *models.GroupID    `json:"group_id"`
+}
+
+func (d *CreateRoleAssignmentRequest) Bind(r *http.Request) error {
+	if (d.IdentityID == nil) == (d.GroupID == nil) {
+		return gerror.NewErrValidationFailed("Exactly one of identity_id or group_id must be set")
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// RunnerPool is used to represent a runner pool without its provisioner secret.
+type RunnerPool struct {
+	baseResourceDocument
+
+	ID        models.RunnerPoolID `json:"id"`
+	CreatedAt models.Time         `json:"created_at"`
+	UpdatedAt models.Time         `json:"updated_at"`
+	ETag      models.ETag         `json:"etag" hash:"ignore"`
+
+	// LegalEntityID is the ID of the legal entity the pool belongs to.
+	LegalEntityID models.LegalEntityID `json:"legal_entity_id"`
+	// Name of the pool, unique within the legal entity.
+	Name models.ResourceName `json:"name"`
+	// Labels is the set of labels the pool's runners are configured with.
+	Labels models.Labels `json:"labels"`
+	// MinRunners is the number of runners kept running even when there are no jobs to run.
+	MinRunners int `json:"min_runners"`
+	// MaxRunners is the largest number of runners the pool can scale up to.
+	MaxRunners int `json:"max_runners"`
+	// ScaleDownIdleTimeoutSeconds is how long the pool must have had more runners than it needed before
+	// it is scaled down.
+	ScaleDownIdleTimeoutSeconds int `json:"scale_down_idle_timeout_seconds"`
+	// ProvisionerType is the type of provisioner that starts and stops the pool's runners.
+	ProvisionerType models.RunnerProvisionerType `json:"provisioner_type"`
+	// ProvisionerConfig contains the settings for the provisioner.
+	ProvisionerConfig models.RunnerProvisionerConfig `json:"provisioner_config"`
+	// HasSecret is true if a secret has been set for the provisioner.
+	HasSecret bool `json:"has_secret"`
+	// DesiredRunners is the number of runners the provisioner was last successfully asked to run.
+	DesiredRunners int `json:"desired_runners"`
+	// LastScaledAt is the last time the provisioner was asked to change the number of runners.
+	LastScaledAt *models.Time `json:"last_scaled_at,omitempty"`
+	// LastError describes why the most recent attempt to scale the pool failed.
+	LastError string `json:"last_error,omitempty"`
+}
+
+func MakeRunnerPool(rctx routes.RequestContext, pool *models.RunnerPool) *RunnerPool {
+	return &RunnerPool{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeRunnerPoolLink(rctx, pool.LegalEntityID, pool.ID),
+		},
+
+		ID:        pool.ID,
+		CreatedAt: pool.CreatedAt,
+		UpdatedAt: pool.UpdatedAt,
+		ETag:      pool.ETag,
+
+		LegalEntityID:               pool.LegalEntityID,
+		Name:                        pool.Name,
+		Labels:                      pool.Labels,
+		MinRunners:                  pool.MinRunners,
+		MaxRunners:                  pool.MaxRunners,
+		ScaleDownIdleTimeoutSeconds: pool.ScaleDownIdleTimeoutSeconds,
+		ProvisionerType:             pool.ProvisionerType,
+		ProvisionerConfig:           pool.ProvisionerConfig,
+		HasSecret:                   pool.SecretEncrypted != nil,
+		DesiredRunners:              pool.DesiredRunners,
+		LastScaledAt:                pool.LastScaledAt,
+		LastError:                   pool.LastError,
+	}
+}
+
+func MakeRunnerPools(rctx routes.RequestContext, pools []*models.RunnerPool) []*RunnerPool {
+	var docs []*RunnerPool
+	for _, model := range pools {
+		docs = append(docs, MakeRunnerPool(rctx, model))
+	}
+	return docs
+}
+
+func (d *RunnerPool) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *RunnerPool) GetKind() models.ResourceKind {
+	return models.RunnerPoolResourceKind
+}
+
+func (d *RunnerPool) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// CreateRunnerPoolRequest is used when creating a runner pool
+type CreateRunnerPoolRequest struct {
+	Name                        models.ResourceName            `json:"name"`
+	Labels                      models.Labels                  `json:"labels"`
+	MinRunners                  int                            `json:"min_runners"`
+	MaxRunners                  int                            `json:"max_runners"`
+	ScaleDownIdleTimeoutSeconds int                            `json:"scale_down_idle_timeout_seconds"`
+	ProvisionerType             models.RunnerProvisionerType   `json:"provisioner_type"`
+	ProvisionerConfig           models.RunnerProvisionerConfig `json:"provisioner_config"`
+	// Secret is an optional credential for the provisioner: the bearer token for Kubernetes, or the key used
+	// to sign requests for webhooks. It is never returned by the API.
+	Secret string `json:"secret"`
+}
+
+func (d *CreateRunnerPoolRequest) Bind(r *http.Request) error {
+	if d.Name == "" {
+		return gerror.NewErrValidationFailed("Name must not be empty")
+	}
+	if !d.ProvisionerType.Valid() {
+		return gerror.NewErrValidationFailed("Invalid provisioner type: " + d.ProvisionerType.String())
+	}
+	return nil
+}
+
+// PatchRunnerPoolRequest is used when updating a runner pool
+type PatchRunnerPoolRequest struct {
+	Name                        *models.ResourceName            `json:"name"`
+	Labels                      *models.Labels                  `json:"labels"`
+	MinRunners                  *int                            `json:"min_runners"`
+	MaxRunners                  *int                            `json:"max_runners"`
+	ScaleDownIdleTimeoutSeconds *int                            `json:"scale_down_idle_timeout_seconds"`
+	ProvisionerType             *models.RunnerProvisionerType   `json:"provisioner_type"`
+	ProvisionerConfig           *models.RunnerProvisionerConfig `json:"provisioner_config"`
+	// Secret replaces the provisioner's credential; an empty string removes it.
+	Secret *string `json:"secret"`
+}
+
+func (d *PatchRunnerPoolRequest) Bind(r *http.Request) error {
+	if d.Name != nil && *d.Name == "" {
+		return gerror.NewErrValidationFailed("Name must not be empty")
+	}
+	if d.ProvisionerType != nil && !d.ProvisionerType.Valid() {
+		return gerror.NewErrValidationFailed("Invalid provisioner type: " + d.ProvisionerType.String())
+	}
+	return nil
+}
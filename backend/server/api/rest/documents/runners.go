@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
 	"github.com/buildbeaver/buildbeaver/common/gerror"
@@ -36,6 +37,24 @@ type Runner struct {
 	Labels []models.Label `json:"labels"`
 	// Enabled specifies if this runner is available to process jobs.
 	Enabled bool `json:"enabled" db:"runner_enabled"`
+	// Healthy is false if the runner's most recent health report found problems with the runner's host.
+	// Unhealthy runners are not given jobs to run, even if they are enabled.
+	Healthy bool `json:"healthy"`
+	// Health is the most recent health report received from the runner, or nil if the runner has never
+	// reported its health.
+	Health *models.RunnerHealth `json:"health"`
+	// LastSeenAt is the time the most recent heartbeat was received from the runner, or nil if the runner has
+	// never sent a heartbeat.
+	LastSeenAt *models.Time `json:"last_seen_at"`
+	// Online is true if the runner has sent a heartbeat recently.
+	Online bool `json:"online"`
+	// Capacity is the CPU, memory and disk space the runner makes available to jobs, or nil if the runner
+	// hasn't advertised its capacity. Jobs are only given to the runner if their requirements fit within
+	// the capacity not used by the runner's other jobs.
+	Capacity *models.Resources `json:"capacity"`
+	// ExecJobAllowlist lists the repos whose exec jobs the runner will run, or nil if the runner hasn't reported
+	// an allowlist.
+	ExecJobAllowlist models.ExecJobAllowlist `json:"exec_job_allowlist"`
 }
 
 func MakeRunner(rctx routes.RequestContext, runner *models.Runner) *Runner {
@@ -58,6 +77,12 @@ func MakeRunner(rctx routes.RequestContext, runner *models.Runner) *Runner {
 		SupportedJobTypes: runner.SupportedJobTypes,
 		Labels:            runner.Labels,
 		Enabled:           runner.Enabled,
+		Healthy:           runner.IsHealthy(),
+		Health:            runner.Health,
+		LastSeenAt:        runner.LastSeenAt,
+		Online:            runner.Online,
+		Capacity:          makeResourcesOrNil(runner.GetCapacity()),
+		ExecJobAllowlist:  runner.ExecJobAllowlist,
 	}
 }
 
@@ -118,9 +143,43 @@ type PatchRuntimeInfoRequest struct {
 	OperatingSystem   *string          `json:"operating_system"`
 	Architecture      *string          `json:"architecture"`
 	SupportedJobTypes *models.JobTypes `json:"supported_job_types"`
+	// Capacity is the CPU, memory and disk space the runner makes available to jobs.
+	Capacity *models.Resources `json:"capacity"`
+	// ExecJobAllowlist lists the repos whose jobs the runner will run if they use the exec job type.
+	// The server only gives the runner exec jobs from these repos.
+	ExecJobAllowlist models.ExecJobAllowlist `json:"exec_job_allowlist"`
 }
 
 func (d *PatchRuntimeInfoRequest) Bind(r *http.Request) error {
+	if d.ExecJobAllowlist != nil {
+		var err error
+		d.ExecJobAllowlist, err = models.ParseExecJobAllowlist(d.ExecJobAllowlist)
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+	}
+	if d.Capacity != nil {
+		err := d.Capacity.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+	}
+	return nil
+}
+
+// PutRunnerHealthRequest is sent periodically by a runner as a heartbeat, reporting the health of its host.
+type PutRunnerHealthRequest struct {
+	*models.RunnerHealthReport
+}
+
+func (d *PutRunnerHealthRequest) Bind(r *http.Request) error {
+	if d.RunnerHealthReport == nil {
+		return gerror.NewErrValidationFailed("Health report must be specified")
+	}
+	err := d.RunnerHealthReport.Validate()
+	if err != nil {
+		return gerror.NewErrValidationFailed(err.Error())
+	}
 	return nil
 }
 
@@ -140,6 +199,9 @@ func (d *RunnerSearchRequest) Bind(r *http.Request) error {
 
 func (d *RunnerSearchRequest) GetQuery() url.Values {
 	values := makePaginationQueryParams(d.Pagination)
+	if d.Online != nil {
+		values.Set("online", url.QueryEscape(strconv.FormatBool(*d.Online)))
+	}
 	return values
 }
 
@@ -149,6 +211,18 @@ func (d *RunnerSearchRequest) FromQuery(values url.Values) error {
 		return fmt.Errorf("error parsing pagination: %w", err)
 	}
 	d.Pagination = pagination
+	vals, ok := values["online"]
+	if ok && len(vals) > 0 {
+		val, err := url.QueryUnescape(vals[0])
+		if err != nil {
+			return fmt.Errorf("error unescaping online: %w", err)
+		}
+		online, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("error parsing online: %w", err)
+		}
+		d.Online = &online
+	}
 	return d.Validate()
 }
 
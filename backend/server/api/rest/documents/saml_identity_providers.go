@@ -0,0 +1,92 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// SAMLIdentityProvider configures a SAML 2.0 identity provider that members of a company can log in with.
+type SAMLIdentityProvider struct {
+	baseResourceDocument
+
+	ID        models.SAMLIdentityProviderID `json:"id"`
+	CreatedAt models.Time                   `json:"created_at"`
+	UpdatedAt models.Time                   `json:"updated_at"`
+	ETag      models.ETag                   `json:"etag" hash:"ignore"`
+
+	// LegalEntityID is the ID of the company the identity provider belongs to.
+	LegalEntityID models.LegalEntityID `json:"legal_entity_id"`
+	// Enabled is true if users can log in via the identity provider.
+	Enabled bool `json:"enabled"`
+	// MetadataXML is the identity provider's SAML metadata document.
+	MetadataXML string `json:"metadata_xml"`
+	// EmailAttribute is the name of the assertion attribute containing the user's email address.
+	EmailAttribute string `json:"email_attribute"`
+	// NameAttribute is the name of the assertion attribute containing the user's display name.
+	NameAttribute string `json:"name_attribute"`
+	// UsernameAttribute is the name of the assertion attribute containing the user's preferred username.
+	UsernameAttribute string `json:"username_attribute"`
+	// GroupsAttribute is the name of the assertion attribute listing the groups the user is a member of.
+	GroupsAttribute string `json:"groups_attribute"`
+	// GroupMappings map values of the groups attribute to the company's standard groups.
+	GroupMappings models.SAMLGroupMappings `json:"group_mappings"`
+
+	// ServiceProviderMetadataURL is the URL of BuildBeaver's service provider metadata, to upload to the identity provider.
+	ServiceProviderMetadataURL string `json:"service_provider_metadata_url"`
+	// LoginURL is the URL to send users to in order to log in via the identity provider.
+	LoginURL string `json:"login_url"`
+}
+
+func MakeSAMLIdentityProvider(rctx routes.RequestContext, provider *models.SAMLIdentityProvider) *SAMLIdentityProvider {
+	return &SAMLIdentityProvider{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeSAMLIdentityProviderLink(rctx, provider.LegalEntityID),
+		},
+
+		ID:        provider.ID,
+		CreatedAt: provider.CreatedAt,
+		UpdatedAt: provider.UpdatedAt,
+		ETag:      provider.ETag,
+
+		LegalEntityID:     provider.LegalEntityID,
+		Enabled:           provider.Enabled,
+		MetadataXML:       provider.MetadataXML,
+		EmailAttribute:    provider.EmailAttribute,
+		NameAttribute:     provider.NameAttribute,
+		UsernameAttribute: provider.UsernameAttribute,
+		GroupsAttribute:   provider.GroupsAttribute,
+		GroupMappings:     provider.GroupMappings,
+
+		ServiceProviderMetadataURL: routes.MakeSAMLMetadataURL(rctx, provider.LegalEntityID),
+		LoginURL:                   routes.MakeSAMLAuthenticationURL(rctx, provider.LegalEntityID),
+	}
+}
+
+func (d *SAMLIdentityProvider) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *SAMLIdentityProvider) GetKind() models.ResourceKind {
+	return models.SAMLIdentityProviderResourceKind
+}
+
+func (d *SAMLIdentityProvider) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// PatchSAMLIdentityProviderRequest is used when creating or updating a SAML identity provider
+type PatchSAMLIdentityProviderRequest struct {
+	Enabled           *bool                     `json:"enabled"`
+	MetadataXML       *string                   `json:"metadata_xml"`
+	EmailAttribute    *string                   `json:"email_attribute"`
+	NameAttribute     *string                   `json:"name_attribute"`
+	UsernameAttribute *string                   `json:"username_attribute"`
+	GroupsAttribute   *string                   `json:"groups_attribute"`
+	GroupMappings     *models.SAMLGroupMappings `json:"group_mappings"`
+}
+
+func (d *PatchSAMLIdentityProviderRequest) Bind(r *http.Request) error {
+	return nil
+}
@@ -44,6 +44,59 @@ func MakeSecrets(rctx routes.RequestContext, secrets []*models.SecretPlaintext)
 	return docs
 }
 
+// SecretVersion is a prior version of a secret. Only metadata about the version is included; the key and
+// value of the secret as of the version are never returned.
+type SecretVersion struct {
+	baseResourceDocument
+
+	ID        models.SecretVersionID `json:"id"`
+	CreatedAt models.Time            `json:"created_at"`
+
+	// SecretID is the ID of the secret this is a version of.
+	SecretID models.SecretID `json:"secret_id"`
+	// Version is the number of the version; the first version of a secret is 1.
+	Version int `json:"version"`
+	// SupersededAt is the time the version was replaced by the next version of the secret.
+	SupersededAt models.Time `json:"superseded_at"`
+}
+
+// MakeSecretVersion converts a models.SecretVersion into a SecretVersion
+func MakeSecretVersion(rctx routes.RequestContext, version *models.SecretVersion) *SecretVersion {
+	return &SecretVersion{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeSecretVersionsLink(rctx, version.SecretID),
+		},
+
+		ID:        version.ID,
+		CreatedAt: version.CreatedAt,
+
+		SecretID:     version.SecretID,
+		Version:      version.Version,
+		SupersededAt: version.SupersededAt,
+	}
+}
+
+// MakeSecretVersions converts an array of models.SecretVersion into an array of SecretVersions
+func MakeSecretVersions(rctx routes.RequestContext, versions []*models.SecretVersion) []*SecretVersion {
+	var docs []*SecretVersion
+	for _, version := range versions {
+		docs = append(docs, MakeSecretVersion(rctx, version))
+	}
+	return docs
+}
+
+func (d *SecretVersion) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *SecretVersion) GetKind() models.ResourceKind {
+	return models.SecretVersionResourceKind
+}
+
+func (d *SecretVersion) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
 // CreateSecretRequest is used when creating a secret
 type CreateSecretRequest struct {
 	// Name is the name of the secret
@@ -26,6 +26,17 @@ type Step struct {
 	Commands []models.Command `json:"commands"`
 	// Depends describes the dependencies this step has on other steps within the parent job.
 	Depends []*StepDependency `json:"depends"`
+	// ArtifactDefinitions contains a list of artifacts the step is expected to produce, that will be saved
+	// to the artifact store as soon as the step finishes.
+	ArtifactDefinitions []*ArtifactDefinition `json:"artifact_definitions"`
+	// TimeoutSeconds is the maximum time the step's commands may run for before they are killed and the
+	// step fails, or zero if the step has no timeout.
+	TimeoutSeconds int64 `json:"timeout_seconds"`
+	// EchoCommands is true to print each of the step's commands to the step's log as it runs, false to not
+	// print commands, or nil to use the shell's default.
+	EchoCommands *bool `json:"echo_commands"`
+	// Condition is an optional expression that must be true for the step to run, or else the step is skipped.
+	Condition string `json:"condition,omitempty"`
 
 	JobID models.JobID `json:"job_id"`
 	// RepoID that the step is building from.
@@ -67,6 +78,11 @@ func MakeStep(rctx routes.RequestContext, step *models.Step) *Step {
 		Commands:    step.Commands,
 		Depends:     MakeStepDependencies(step.Depends),
 
+		ArtifactDefinitions: MakeArtifactDefinitions(step.ArtifactDefinitions),
+		TimeoutSeconds:      step.TimeoutSeconds,
+		EchoCommands:        step.EchoCommands,
+		Condition:           step.Condition,
+
 		JobID:           step.JobID,
 		RepoID:          step.RepoID,
 		RunnerID:        step.RunnerID,
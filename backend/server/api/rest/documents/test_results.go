@@ -0,0 +1,361 @@
+package documents
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// TestSummary summarizes the results of all tests reported by a build. A test reported by more than one
+// test run is counted once, as flaky if it passed in some runs and failed in others.
+type TestSummary struct {
+	baseResourceDocument
+
+	ID        models.TestSummaryID `json:"id"`
+	CreatedAt models.Time          `json:"created_at"`
+	UpdatedAt models.Time          `json:"updated_at"`
+
+	BuildID models.BuildID `json:"build_id"`
+	RepoID  models.RepoID  `json:"repo_id"`
+	// Runs is the number of test runs (i.e. test reports) in the build.
+	Runs int `json:"runs"`
+	// Total is the number of distinct tests.
+	Total int `json:"total"`
+	// Passed is the number of tests that passed on every attempt.
+	Passed int `json:"passed"`
+	// Failed is the number of tests that failed on every attempt.
+	Failed int `json:"failed"`
+	// Skipped is the number of tests that were skipped.
+	Skipped int `json:"skipped"`
+	// Flaky is the number of tests that failed on some attempts and passed on others.
+	Flaky int `json:"flaky"`
+	// DurationMillis is the total time spent running tests across all test runs, in milliseconds.
+	DurationMillis int64 `json:"duration_millis"`
+
+	TestRunsURL  string `json:"test_runs_url"`
+	TestCasesURL string `json:"test_cases_url"`
+}
+
+func MakeTestSummary(rctx routes.RequestContext, summary *models.TestSummary) *TestSummary {
+	return &TestSummary{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeTestSummaryLink(rctx, summary.BuildID),
+		},
+
+		ID:        summary.ID,
+		CreatedAt: summary.CreatedAt,
+		UpdatedAt: summary.UpdatedAt,
+
+		BuildID:        summary.BuildID,
+		RepoID:         summary.RepoID,
+		Runs:           summary.Runs,
+		Total:          summary.Total,
+		Passed:         summary.Passed,
+		Failed:         summary.Failed,
+		Skipped:        summary.Skipped,
+		Flaky:          summary.Flaky,
+		DurationMillis: summary.DurationMillis,
+
+		TestRunsURL:  routes.MakeTestRunsLink(rctx, summary.BuildID),
+		TestCasesURL: routes.MakeTestCasesLink(rctx, summary.BuildID),
+	}
+}
+
+func MakeTestSummaries(rctx routes.RequestContext, summaries []*models.TestSummary) []*TestSummary {
+	var docs []*TestSummary
+	for _, model := range summaries {
+		docs = append(docs, MakeTestSummary(rctx, model))
+	}
+	return docs
+}
+
+func (d *TestSummary) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *TestSummary) GetKind() models.ResourceKind {
+	return models.TestSummaryResourceKind
+}
+
+func (d *TestSummary) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// TestRun records the results parsed from a single test report artifact.
+type TestRun struct {
+	ID        models.TestRunID `json:"id"`
+	CreatedAt models.Time      `json:"created_at"`
+
+	BuildID    models.BuildID          `json:"build_id"`
+	JobID      models.JobID            `json:"job_id"`
+	RepoID     models.RepoID           `json:"repo_id"`
+	ArtifactID models.ArtifactID       `json:"artifact_id"`
+	Format     models.TestReportFormat `json:"format"`
+	Total      int                     `json:"total"`
+	Passed     int                     `json:"passed"`
+	Failed     int                     `json:"failed"`
+	Skipped    int                     `json:"skipped"`
+	Flaky      int                     `json:"flaky"`
+	// DurationMillis is the total time spent running the tests in the report, in milliseconds.
+	DurationMillis int64 `json:"duration_millis"`
+
+	ArtifactURL  string `json:"artifact_url"`
+	TestCasesURL string `json:"test_cases_url"`
+}
+
+func MakeTestRun(rctx routes.RequestContext, testRun *models.TestRun) *TestRun {
+	return &TestRun{
+		ID:        testRun.ID,
+		CreatedAt: testRun.CreatedAt,
+
+		BuildID:        testRun.BuildID,
+		JobID:          testRun.JobID,
+		RepoID:         testRun.RepoID,
+		ArtifactID:     testRun.ArtifactID,
+		Format:         testRun.Format,
+		Total:          testRun.Total,
+		Passed:         testRun.Passed,
+		Failed:         testRun.Failed,
+		Skipped:        testRun.Skipped,
+		Flaky:          testRun.Flaky,
+		DurationMillis: testRun.DurationMillis,
+
+		ArtifactURL:  routes.MakeArtifactLink(rctx, testRun.ArtifactID),
+		TestCasesURL: routes.MakeTestRunCasesLink(rctx, testRun.BuildID, testRun.ID),
+	}
+}
+
+func MakeTestRuns(rctx routes.RequestContext, testRuns []*models.TestRun) []*TestRun {
+	var docs []*TestRun
+	for _, model := range testRuns {
+		docs = append(docs, MakeTestRun(rctx, model))
+	}
+	return docs
+}
+
+// TestCase records the result of a single test within a test run, combining all attempts at the test.
+type TestCase struct {
+	ID        models.TestCaseID `json:"id"`
+	CreatedAt models.Time       `json:"created_at"`
+
+	TestRunID models.TestRunID `json:"test_run_id"`
+	BuildID   models.BuildID   `json:"build_id"`
+	RepoID    models.RepoID    `json:"repo_id"`
+	// Suite is the name of the suite, class or package containing the test.
+	Suite string `json:"suite"`
+	// Name is the name of the test within the suite.
+	Name string `json:"name"`
+	// Status is one of "passed", "failed", "skipped" or "flaky".
+	Status models.TestCaseStatus `json:"status"`
+	// Attempts is the number of times the test was run.
+	Attempts int `json:"attempts"`
+	// DurationMillis is the total time spent running the test across all attempts, in milliseconds.
+	DurationMillis int64 `json:"duration_millis"`
+	// Message is the failure message from the most recent failed attempt, if any.
+	Message string `json:"message"`
+
+	BuildURL string `json:"build_url"`
+}
+
+func MakeTestCase(rctx routes.RequestContext, testCase *models.TestCase) *TestCase {
+	return &TestCase{
+		ID:        testCase.ID,
+		CreatedAt: testCase.CreatedAt,
+
+		TestRunID:      testCase.TestRunID,
+		BuildID:        testCase.BuildID,
+		RepoID:         testCase.RepoID,
+		Suite:          testCase.Suite,
+		Name:           testCase.Name,
+		Status:         testCase.Status,
+		Attempts:       testCase.Attempts,
+		DurationMillis: testCase.DurationMillis,
+		Message:        testCase.Message,
+
+		BuildURL: routes.MakeBuildLink(rctx, testCase.BuildID),
+	}
+}
+
+func MakeTestCases(rctx routes.RequestContext, testCases []*models.TestCase) []*TestCase {
+	var docs []*TestCase
+	for _, model := range testCases {
+		docs = append(docs, MakeTestCase(rctx, model))
+	}
+	return docs
+}
+
+type TestCaseSearchRequest struct {
+	*models.TestCaseSearch
+}
+
+func NewTestCaseSearchRequest() *TestCaseSearchRequest {
+	return &TestCaseSearchRequest{TestCaseSearch: models.NewTestCaseSearch()}
+}
+
+func (d *TestCaseSearchRequest) Bind(r *http.Request) error {
+	return d.Validate()
+}
+
+func (d *TestCaseSearchRequest) GetQuery() url.Values {
+	values := makePaginationQueryParams(d.Pagination)
+	if d.TestRunID != nil {
+		values.Set("test_run_id", d.TestRunID.String())
+	}
+	if d.Suite != nil {
+		values.Set("suite", *d.Suite)
+	}
+	if d.Name != nil {
+		values.Set("name", *d.Name)
+	}
+	if d.Status != nil {
+		values.Set("status", d.Status.String())
+	}
+	return values
+}
+
+// FromQuery parses the search from query parameters. The build or repo to search within is not read
+// from the query, and must be set from the URL path by the caller.
+func (d *TestCaseSearchRequest) FromQuery(values url.Values) error {
+	pagination, err := getPaginationFromQueryParams(values)
+	if err != nil {
+		return fmt.Errorf("error parsing pagination: %w", err)
+	}
+	d.Pagination = pagination
+
+	if values.Has("test_run_id") {
+		id, err := models.ParseResourceID(values.Get("test_run_id"))
+		if err != nil {
+			return fmt.Errorf("error parsing test run id: %w", err)
+		}
+		testRunID := models.TestRunIDFromResourceID(id)
+		d.TestRunID = &testRunID
+	}
+	if values.Has("suite") {
+		suite := values.Get("suite")
+		d.Suite = &suite
+	}
+	if values.Has("name") {
+		name := values.Get("name")
+		d.Name = &name
+	}
+	if values.Has("status") {
+		status := models.TestCaseStatus(values.Get("status"))
+		d.Status = &status
+	}
+	return nil
+}
+
+func (d *TestCaseSearchRequest) Next(cursor *models.DirectionalCursor) PaginatedRequest {
+	d.Cursor = cursor
+	return d
+}
+
+// FlakyTest is a test in a repo that has been seen to fail intermittently.
+type FlakyTest struct {
+	ID        models.FlakyTestID `json:"id"`
+	CreatedAt models.Time        `json:"created_at"`
+	UpdatedAt models.Time        `json:"updated_at"`
+
+	RepoID models.RepoID `json:"repo_id"`
+	// Suite is the name of the suite, class or package containing the test.
+	Suite string `json:"suite"`
+	// Name is the name of the test within the suite.
+	Name string `json:"name"`
+	// Detections is the number of test runs in which the test was seen to give inconsistent results.
+	Detections int `json:"detections"`
+	// LastBuildID is the ID of the build in which the test was most recently seen to be flaky.
+	LastBuildID models.BuildID `json:"last_build_id"`
+	// LastCommitID is the ID of the commit the test was most recently seen to be flaky against.
+	LastCommitID models.CommitID `json:"last_commit_id"`
+	// Message is the most recent failure message reported for the test, if any.
+	Message string `json:"message"`
+
+	LastBuildURL   string `json:"last_build_url"`
+	TestHistoryURL string `json:"test_history_url"`
+}
+
+func MakeFlakyTest(rctx routes.RequestContext, flakyTest *models.FlakyTest) *FlakyTest {
+	return &FlakyTest{
+		ID:        flakyTest.ID,
+		CreatedAt: flakyTest.CreatedAt,
+		UpdatedAt: flakyTest.UpdatedAt,
+
+		RepoID:       flakyTest.RepoID,
+		Suite:        flakyTest.Suite,
+		Name:         flakyTest.Name,
+		Detections:   flakyTest.Detections,
+		LastBuildID:  flakyTest.LastBuildID,
+		LastCommitID: flakyTest.LastCommitID,
+		Message:      flakyTest.Message,
+
+		LastBuildURL:   routes.MakeBuildLink(rctx, flakyTest.LastBuildID),
+		TestHistoryURL: routes.MakeRepoTestHistoryLink(rctx, flakyTest.RepoID, flakyTest.Suite, flakyTest.Name),
+	}
+}
+
+func MakeFlakyTests(rctx routes.RequestContext, flakyTests []*models.FlakyTest) []*FlakyTest {
+	var docs []*FlakyTest
+	for _, model := range flakyTests {
+		docs = append(docs, MakeFlakyTest(rctx, model))
+	}
+	return docs
+}
+
+// JobFlakyTests describes the failed tests reported by a job that are known to be flaky.
+type JobFlakyTests struct {
+	baseResourceDocument
+
+	JobID     models.JobID `json:"job_id"`
+	CreatedAt models.Time  `json:"created_at"`
+	// Failed is the number of test cases reported by the job that failed on every attempt.
+	Failed int `json:"failed"`
+	// KnownFlaky lists the tests that failed and are known to be flaky.
+	KnownFlaky []*FlakyTest `json:"known_flaky"`
+	// FailedDueToFlakyTests is true if the job failed and every test that failed is known to be flaky.
+	FailedDueToFlakyTests bool `json:"failed_due_to_flaky_tests"`
+	// Annotation is a description of the job's result to show alongside it, or empty if there is nothing to add.
+	Annotation string `json:"annotation"`
+
+	JobURL string `json:"job_url"`
+}
+
+func MakeJobFlakyTests(rctx routes.RequestContext, jobFlakyTests *dto.JobFlakyTests) *JobFlakyTests {
+	annotation := ""
+	if jobFlakyTests.FailedDueToFlakyTests {
+		annotation = models.FailedDueToFlakyTestsMessage
+	}
+	knownFlaky := MakeFlakyTests(rctx, jobFlakyTests.KnownFlaky)
+	if knownFlaky == nil {
+		knownFlaky = []*FlakyTest{}
+	}
+	return &JobFlakyTests{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeJobFlakyTestsLink(rctx, jobFlakyTests.Job.ID),
+		},
+
+		JobID:                 jobFlakyTests.Job.ID,
+		CreatedAt:             jobFlakyTests.Job.CreatedAt,
+		Failed:                jobFlakyTests.Failed,
+		KnownFlaky:            knownFlaky,
+		FailedDueToFlakyTests: jobFlakyTests.FailedDueToFlakyTests,
+		Annotation:            annotation,
+
+		JobURL: routes.MakeJobLink(rctx, jobFlakyTests.Job.ID),
+	}
+}
+
+func (d *JobFlakyTests) GetID() models.ResourceID {
+	return d.JobID.ResourceID
+}
+
+func (d *JobFlakyTests) GetKind() models.ResourceKind {
+	return models.JobResourceKind
+}
+
+func (d *JobFlakyTests) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
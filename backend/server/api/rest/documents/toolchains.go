@@ -0,0 +1,123 @@
+package documents
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// Toolchain is a named, versioned Docker image registered by a legal entity for its builds to run jobs in.
+type Toolchain struct {
+	baseResourceDocument
+
+	ID        models.ToolchainID `json:"id"`
+	CreatedAt models.Time        `json:"created_at"`
+	UpdatedAt models.Time        `json:"updated_at"`
+	ETag      models.ETag        `json:"etag" hash:"ignore"`
+
+	// LegalEntityID is the ID of the legal entity the toolchain belongs to.
+	LegalEntityID models.LegalEntityID `json:"legal_entity_id"`
+	// Name of the toolchain; there can be many versions with the same name.
+	Name models.ResourceName `json:"name"`
+	// Version of the toolchain, unique amongst the toolchains with the same name.
+	Version string `json:"version"`
+	// Reference is the reference jobs use to run in the toolchain, in the format "name@version".
+	Reference string `json:"reference"`
+	// Image is the Docker image registered for the toolchain.
+	Image string `json:"image"`
+	// Digest is the optional content digest of the image.
+	Digest string `json:"digest,omitempty"`
+	// ResolvedImage is the image jobs using the toolchain run in; if the toolchain has a digest this is
+	// the image pinned to the digest.
+	ResolvedImage string `json:"resolved_image"`
+	// CreatedByBuildID is the ID of the build that registered the toolchain, if it was registered by a build.
+	CreatedByBuildID *models.BuildID `json:"created_by_build_id,omitempty"`
+	// SourceRepoID is the ID of the repo the toolchain's image is built from, if it was registered by a build.
+	SourceRepoID *models.RepoID `json:"source_repo_id,omitempty"`
+	// Dockerfile is the path of the Dockerfile within the source repo the toolchain's image is built from,
+	// if a new version should be built automatically when it changes.
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// RebuildWorkflow is the workflow run to build a new version of the toolchain when the Dockerfile changes.
+	RebuildWorkflow models.ResourceName `json:"rebuild_workflow,omitempty"`
+}
+
+func MakeToolchain(rctx routes.RequestContext, toolchain *models.Toolchain) *Toolchain {
+	return &Toolchain{
+		baseResourceDocument: baseResourceDocument{
+			URL: routes.MakeToolchainLink(rctx, toolchain.LegalEntityID, toolchain.ID),
+		},
+
+		ID:        toolchain.ID,
+		CreatedAt: toolchain.CreatedAt,
+		UpdatedAt: toolchain.UpdatedAt,
+		ETag:      toolchain.ETag,
+
+		LegalEntityID:    toolchain.LegalEntityID,
+		Name:             toolchain.Name,
+		Version:          toolchain.Version,
+		Reference:        toolchain.Reference().String(),
+		Image:            toolchain.Image,
+		Digest:           toolchain.Digest,
+		ResolvedImage:    toolchain.ImageReference(),
+		CreatedByBuildID: toolchain.CreatedByBuildID,
+		SourceRepoID:     toolchain.SourceRepoID,
+		Dockerfile:       toolchain.Dockerfile,
+		RebuildWorkflow:  toolchain.RebuildWorkflow,
+	}
+}
+
+func MakeToolchains(rctx routes.RequestContext, toolchains []*models.Toolchain) []*Toolchain {
+	var docs []*Toolchain
+	for _, model := range toolchains {
+		docs = append(docs, MakeToolchain(rctx, model))
+	}
+	return docs
+}
+
+func (d *Toolchain) GetID() models.ResourceID {
+	return d.ID.ResourceID
+}
+
+func (d *Toolchain) GetKind() models.ResourceKind {
+	return models.ToolchainResourceKind
+}
+
+func (d *Toolchain) GetCreatedAt() models.Time {
+	return d.CreatedAt
+}
+
+// RegisterToolchainRequest is used when registering a new version of a toolchain
+type RegisterToolchainRequest struct {
+	// Name of the toolchain, e.g. "go-builder".
+	Name models.ResourceName `json:"name"`
+	// Version of the toolchain, e.g. "1.4". Once registered a version can't be changed.
+	Version string `json:"version"`
+	// Image is the Docker image for the toolchain, e.g. "registry.example.com/go-builder:1.4".
+	Image string `json:"image"`
+	// Digest is the optional content digest of the image, e.g. "sha256:...". If set, jobs using the
+	// toolchain run in exactly this image even if the image's tag is later moved.
+	Digest string `json:"digest"`
+	// Dockerfile is the optional path of the Dockerfile the toolchain's image is built from, within the repo
+	// of the build registering the toolchain. If set, RebuildWorkflow is run whenever the Dockerfile changes
+	// on the repo's default branch, to build a new version of the toolchain. Only valid when registering
+	// a toolchain from a build.
+	Dockerfile string `json:"dockerfile"`
+	// RebuildWorkflow is the workflow that builds and registers a new version of the toolchain. Required
+	// if Dockerfile is set.
+	RebuildWorkflow models.ResourceName `json:"rebuild_workflow"`
+}
+
+func (d *RegisterToolchainRequest) Bind(r *http.Request) error {
+	if d.Name == "" {
+		return gerror.NewErrValidationFailed("Name must not be empty")
+	}
+	if d.Version == "" {
+		return gerror.NewErrValidationFailed("Version must not be empty")
+	}
+	if d.Image == "" {
+		return gerror.NewErrValidationFailed("Image must not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package documents
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+// UsageSummary summarizes the resources used by a legal entity each month, in total and by repo, for
+// billing and chargeback, along with the legal entity's monthly quotas.
+type UsageSummary struct {
+	*models.UsageSummary
+	URL string `json:"url"`
+}
+
+func MakeUsageSummary(rctx routes.RequestContext, summary *models.UsageSummary) *UsageSummary {
+	return &UsageSummary{
+		UsageSummary: summary,
+		URL:          routes.MakeLegalEntityUsageLink(rctx, summary.LegalEntityID),
+	}
+}
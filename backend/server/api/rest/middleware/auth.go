@@ -21,6 +21,18 @@ type AuthenticationMeta struct {
 	CredentialType models.CredentialType
 	OAuthToken     *oauth2.Token     // TODO Remove me (easy once we don't need this for sync)
 	Claims         map[string]string // claim data from the authentication method (especially JWT)
+	// Scopes limits the operations the request can perform, if the credential type is personal access token.
+	Scopes models.TokenScopes
+}
+
+// AllowsOperation returns true if the credential the request was authenticated with can be used to perform
+// the specified operation. Only personal access tokens are limited in this way; whether the identity is
+// allowed to perform the operation on a particular resource must still be checked with the authorization service.
+func (m *AuthenticationMeta) AllowsOperation(operation *models.Operation) bool {
+	if m.CredentialType != models.CredentialTypePersonalAccessToken {
+		return true
+	}
+	return m.Scopes.Allows(operation)
 }
 
 // MakeMustAuthenticate makes a middleware that enforces that the request must be authenticated.
@@ -40,14 +52,77 @@ func MakeMustAuthenticate(log logger.Log) func(next http.Handler) http.Handler {
 	}
 }
 
+// MakeAnonymousAuthenticator makes a middleware that authenticates requests that have not already been
+// authenticated as the anonymous identity. The anonymous identity can only read resources that have been
+// made public, so this must only be used for routes that serve public data, after any other authenticators.
+func MakeAnonymousAuthenticator(log logger.Log) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Context().Value(authenticationMetaContextKeyName) == nil {
+				meta := &AuthenticationMeta{
+					IdentityID:     models.AnonymousIdentityID,
+					CredentialType: models.CredentialTypeAnonymous,
+				}
+				ctx := context.WithValue(r.Context(), authenticationMetaContextKeyName, meta)
+				r = r.WithContext(ctx)
+				log.Tracef("Authenticated request as anonymous")
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// MakeLocalAuthenticator makes a middleware that authenticates requests that have not already been
+// authenticated as the identity returned by getIdentityID. This trusts every request, so must only be used
+// by servers that are run for a single user on their local machine.
+func MakeLocalAuthenticator(log logger.Log, getIdentityID func(ctx context.Context) (models.IdentityID, error)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Context().Value(authenticationMetaContextKeyName) == nil {
+				identityID, err := getIdentityID(r.Context())
+				if err != nil {
+					log.Error(w, r, gerror.NewErrUnauthorized("Unauthorized").Wrap(err))
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				meta := &AuthenticationMeta{
+					IdentityID:     identityID,
+					CredentialType: models.CredentialTypeLocal,
+				}
+				ctx := context.WithValue(r.Context(), authenticationMetaContextKeyName, meta)
+				r = r.WithContext(ctx)
+				log.Tracef("Authenticated request as local identity %q", identityID)
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
 // MakeSharedSecretAuthenticator makes a middleware that authenticates requests using
-// a shared secret token from the request headers. If the request headers do not contain
-// a token then this a no-op.
+// a shared secret token or personal access token from the request headers. If the request
+// headers do not contain a token then this a no-op.
 func MakeSharedSecretAuthenticator(log logger.Log, authenticationService services.AuthenticationService) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			token := r.Header.Get("buildbeaver-token")
-			if token != "" {
+			if models.IsPersonalAccessToken(token) {
+				identity, credential, err := authenticationService.AuthenticatePersonalAccessToken(r.Context(), token)
+				if err != nil {
+					log.Error(w, r, gerror.NewErrUnauthorized("Invalid personal access token").Wrap(err))
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				meta := &AuthenticationMeta{
+					IdentityID:     identity.ID,
+					CredentialType: models.CredentialTypePersonalAccessToken,
+					Scopes:         credential.Scopes,
+				}
+				ctx := context.WithValue(r.Context(), authenticationMetaContextKeyName, meta)
+				r = r.WithContext(ctx)
+				log.Infof("Authenticated identity %q using personal access token %q", identity.ID, credential.ID)
+			} else if token != "" {
 				identity, err := authenticationService.AuthenticateSharedSecret(r.Context(), token)
 				if err != nil {
 					log.Error(w, r, gerror.NewErrUnauthorized("Invalid shared secret"))
@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+const (
+	// rateLimitPruneInterval is how often buckets that have refilled are discarded, to bound memory use.
+	rateLimitPruneInterval = 1 * time.Minute
+	// rateLimitReportInterval is the shortest interval between log messages summarizing throttled requests.
+	rateLimitReportInterval = 1 * time.Minute
+	// rateLimitReportMaxKeys is the maximum number of identities or IP addresses named in each summary.
+	rateLimitReportMaxKeys = 5
+)
+
+// RateLimit configures a token bucket. Requests are allowed at an average of RequestsPerSecond, with
+// bursts of up to Burst requests at once. A zero RequestsPerSecond disables the limit.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.RequestsPerSecond > 0
+}
+
+// RateLimitConfig configures the limits applied to each authenticated identity and to each client IP address.
+// A request must be within both limits to be allowed.
+type RateLimitConfig struct {
+	PerIdentity RateLimit
+	PerIP       RateLimit
+}
+
+func (c RateLimitConfig) Enabled() bool {
+	return c.PerIdentity.Enabled() || c.PerIP.Enabled()
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter keeps a token bucket for each key (e.g. an identity or IP address) it sees.
+type RateLimiter struct {
+	limit      RateLimit
+	mu         sync.Mutex
+	buckets    map[string]*tokenBucket
+	lastPruned time.Time
+}
+
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &RateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the key's bucket and returns true if one was available. If not, returns false
+// along with how long the caller should wait before a token will be available.
+func (l *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPruned) >= rateLimitPruneInterval {
+		l.prune(now)
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = bucket
+	} else {
+		bucket.tokens = l.refill(bucket, now)
+		bucket.updated = now
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.limit.RequestsPerSecond * float64(time.Second))
+	return false, wait
+}
+
+// refill returns the number of tokens in the bucket at the specified time.
+func (l *RateLimiter) refill(bucket *tokenBucket, now time.Time) float64 {
+	elapsed := now.Sub(bucket.updated).Seconds()
+	if elapsed <= 0 {
+		return bucket.tokens
+	}
+	return math.Min(float64(l.limit.Burst), bucket.tokens+elapsed*l.limit.RequestsPerSecond)
+}
+
+// prune discards buckets that have refilled, since they are indistinguishable from new buckets.
+func (l *RateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if l.refill(bucket, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPruned = now
+}
+
+// RateLimitMetrics counts throttled requests so they can be exported to an external metrics system.
+type RateLimitMetrics interface {
+	// RecordRateLimited counts a request throttled by the named rate limiter, by the specified type of key.
+	RecordRateLimited(limiter string, keyType models.RateLimitKeyType)
+}
+
+// rateLimitReporter counts throttled requests by key and periodically logs a summary, so that runaway
+// clients can be identified without logging every throttled request. Each throttled request is also
+// counted in metrics by key type, since the keys themselves are too numerous to use as metric labels.
+type rateLimitReporter struct {
+	name        string
+	description string
+	log         logger.Log
+	metrics     RateLimitMetrics
+	mu          sync.Mutex
+	throttled   map[string]int
+	since       time.Time
+	reported    time.Time
+}
+
+func (r *rateLimitReporter) record(key string, keyType models.RateLimitKeyType, now time.Time) {
+	if r.metrics != nil {
+		r.metrics.RecordRateLimited(r.name, keyType)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.throttled) == 0 {
+		r.throttled = make(map[string]int)
+		r.since = now
+	}
+	r.throttled[key]++
+	// Report the first throttled request straight away, then at most once per interval
+	if now.Sub(r.reported) < rateLimitReportInterval {
+		return
+	}
+	keys := make([]string, 0, len(r.throttled))
+	total := 0
+	for key, count := range r.throttled {
+		keys = append(keys, key)
+		total += count
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if r.throttled[keys[i]] != r.throttled[keys[j]] {
+			return r.throttled[keys[i]] > r.throttled[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > rateLimitReportMaxKeys {
+		keys = keys[:rateLimitReportMaxKeys]
+	}
+	top := make([]string, len(keys))
+	for i, key := range keys {
+		top[i] = fmt.Sprintf("%s (%d)", key, r.throttled[key])
+	}
+	r.log.Warnf("Rate limit for %s throttled %d request(s) since %s; top clients: %s",
+		r.description, total, r.since.Format(time.RFC3339), strings.Join(top, ", "))
+	r.throttled = nil
+	r.reported = now
+}
+
+// MakeRateLimiter makes a middleware that limits the rate of requests from each authenticated identity and
+// from each client IP address, according to config. Requests over either limit are rejected with a 429 error
+// and a Retry-After header telling the client when to try again. Throttled requests are counted in metrics
+// (if not nil) labelled with name, and summarized in log messages using description. To limit by identity
+// this must be used after any authenticators; requests made as the anonymous identity are only limited by
+// IP address.
+// The returned middleware can be used on several routes, in which case they share the same limits.
+func MakeRateLimiter(
+	log logger.Log,
+	metrics RateLimitMetrics,
+	name string,
+	description string,
+	config RateLimitConfig,
+) func(next http.Handler) http.Handler {
+	if !config.Enabled() {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	var identityLimiter, ipLimiter *RateLimiter
+	if config.PerIdentity.Enabled() {
+		identityLimiter = NewRateLimiter(config.PerIdentity)
+	}
+	if config.PerIP.Enabled() {
+		ipLimiter = NewRateLimiter(config.PerIP)
+	}
+	reporter := &rateLimitReporter{name: name, description: description, log: log, metrics: metrics}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+			if ipLimiter != nil {
+				key := "ip:" + clientIP(r)
+				if allowed, retryAfter := ipLimiter.Allow(key, now); !allowed {
+					reporter.record(key, models.RateLimitKeyTypeIP, now)
+					tooManyRequests(w, retryAfter)
+					return
+				}
+			}
+			if identityLimiter != nil {
+				meta, ok := r.Context().Value(authenticationMetaContextKeyName).(*AuthenticationMeta)
+				if ok && !meta.IdentityID.Equal(models.AnonymousIdentityID.ResourceID) {
+					key := meta.IdentityID.String()
+					if allowed, retryAfter := identityLimiter.Allow(key, now); !allowed {
+						reporter.record(key, models.RateLimitKeyTypeIdentity, now)
+						tooManyRequests(w, retryAfter)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// clientIP returns the IP address the request was received from.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(RateLimit{RequestsPerSecond: 2, Burst: 3})
+
+	// The full burst is available straight away
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("a", now)
+		require.True(t, allowed)
+	}
+	allowed, retryAfter := limiter.Allow("a", now)
+	require.False(t, allowed)
+	require.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// Other keys have their own bucket
+	allowed, _ = limiter.Allow("b", now)
+	require.True(t, allowed)
+
+	// Tokens refill at the configured rate, up to the burst
+	allowed, _ = limiter.Allow("a", now.Add(500*time.Millisecond))
+	require.True(t, allowed)
+	allowed, _ = limiter.Allow("a", now.Add(500*time.Millisecond))
+	require.False(t, allowed)
+
+	// Buckets that have refilled are pruned
+	later := now.Add(rateLimitPruneInterval)
+	allowed, _ = limiter.Allow("c", later)
+	require.True(t, allowed)
+	require.Len(t, limiter.buckets, 1)
+}
+
+// testRateLimitMetrics counts throttled requests by limiter and key type.
+type testRateLimitMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (m *testRateLimitMetrics) RecordRateLimited(limiter string, keyType models.RateLimitKeyType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[limiter+"/"+keyType.String()]++
+}
+
+func TestMakeRateLimiter(t *testing.T) {
+	log := logger.NoOpLogFactory("RateLimiterTest")
+	metrics := &testRateLimitMetrics{counts: make(map[string]int)}
+	handler := MakeRateLimiter(log, metrics, "test", "test requests", RateLimitConfig{
+		PerIdentity: RateLimit{RequestsPerSecond: 0.1, Burst: 1},
+		PerIP:       RateLimit{RequestsPerSecond: 0.1, Burst: 4},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(identityID models.IdentityID, remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if identityID.Valid() {
+			meta := &AuthenticationMeta{IdentityID: identityID}
+			r = r.WithContext(context.WithValue(r.Context(), authenticationMetaContextKeyName, meta))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	identity1 := models.NewIdentityID()
+	identity2 := models.NewIdentityID()
+	require.Equal(t, http.StatusOK, request(identity1, "10.0.0.1:1234").Code)
+	w := request(identity1, "10.0.0.1:1235")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	// The IP address still has capacity for other identities, and anonymous requests are only limited by IP
+	require.Equal(t, http.StatusOK, request(identity2, "10.0.0.1:1236").Code)
+	require.Equal(t, http.StatusOK, request(models.AnonymousIdentityID, "10.0.0.1:1237").Code)
+	require.Equal(t, http.StatusTooManyRequests, request(models.AnonymousIdentityID, "10.0.0.1:1238").Code)
+	require.Equal(t, http.StatusOK, request(models.AnonymousIdentityID, "10.0.0.2:1234").Code)
+
+	// Throttled requests are counted by the type of key that throttled them
+	require.Equal(t, map[string]int{"test/identity": 1, "test/ip": 1}, metrics.counts)
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/buildbeaver/buildbeaver/common/tracing"
+)
+
+// MakeTracing makes a middleware that records a server span for each request, continuing any trace
+// propagated by the client via the W3C traceparent header. The serverName is recorded against each span
+// to distinguish between the different API servers.
+func MakeTracing(serverName string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracing.StartSpan(ctx, fmt.Sprintf("HTTP %s", r.Method), trace.WithSpanKind(trace.SpanKindServer))
+			defer span.End()
+			span.SetAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.Path),
+				attribute.String("server.name", serverName))
+			if requestID := middleware.GetReqID(r.Context()); requestID != "" {
+				span.SetAttributes(attribute.String("http.request_id", requestID))
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+			// The route pattern is only known once routing has completed
+			if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil && routeCtx.RoutePattern() != "" {
+				span.SetAttributes(attribute.String("http.route", routeCtx.RoutePattern()))
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.status_code", status))
+			if status >= http.StatusInternalServerError {
+				tracing.RecordError(span, fmt.Errorf("%d %s", status, http.StatusText(status)))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -14,6 +14,10 @@ func MakeArtifactsDataLink(rctx RequestContext, artifactID models.ArtifactID) st
 	return fmt.Sprintf("%s/data", MakeArtifactLink(rctx, artifactID))
 }
 
+func MakeArtifactVerificationLink(rctx RequestContext, artifactID models.ArtifactID) string {
+	return fmt.Sprintf("%s/verification", MakeArtifactLink(rctx, artifactID))
+}
+
 func MakeArtifactsLink(rctx RequestContext, buildID models.BuildID) string {
 	return fmt.Sprintf("%s/api/v1/builds/%s/artifacts", rctx, buildID)
 }
@@ -5,3 +5,7 @@ import "fmt"
 func MakeGitHubAuthenticationURL(rctx RequestContext) string {
 	return fmt.Sprintf("%s/api/v1/authentication/github", rctx)
 }
+
+func MakeSSOAuthenticationURL(rctx RequestContext) string {
+	return fmt.Sprintf("%s/api/v1/authentication/sso", rctx)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeBuildRuleSetLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/build-rules", MakeRepoLink(rctx, repoID))
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/buildbeaver/buildbeaver/common/models"
 )
@@ -21,3 +22,11 @@ func MakeBuildSearchLink(rctx RequestContext, repoID models.RepoID) string {
 func MakeBuildSummaryLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
 	return fmt.Sprintf("%s/builds/summary", MakeLegalEntityLink(rctx, legalEntityID))
 }
+
+func MakeBuildDAGLink(rctx RequestContext, buildID models.BuildID) string {
+	return fmt.Sprintf("%s/dag", MakeBuildLink(rctx, buildID))
+}
+
+func MakeBuildComparisonLink(rctx RequestContext, buildID models.BuildID, baseBuildID models.BuildID) string {
+	return fmt.Sprintf("%s/compare?base=%s", MakeBuildLink(rctx, buildID), url.QueryEscape(baseBuildID.String()))
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// Caches are only available to runners, and are always accessed on behalf of a job so that the runner's
+// access to the job's repo can be checked.
+
+func MakeCacheEntryLinkForRunner(rctx RequestContext, jobID models.JobID, cacheEntryID models.CacheEntryID) string {
+	return fmt.Sprintf("%s/api/v1/runner/jobs/%s/cache-entries/%s", rctx, jobID, cacheEntryID)
+}
+
+func MakeCacheEntryDataLinkForRunner(rctx RequestContext, jobID models.JobID, cacheEntryID models.CacheEntryID) string {
+	return fmt.Sprintf("%s/data", MakeCacheEntryLinkForRunner(rctx, jobID, cacheEntryID))
+}
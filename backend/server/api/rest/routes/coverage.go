@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeBuildCoverageLink(rctx RequestContext, buildID models.BuildID) string {
+	return fmt.Sprintf("%s/coverage", MakeBuildLink(rctx, buildID))
+}
+
+func MakeCoverageReportsLink(rctx RequestContext, buildID models.BuildID) string {
+	return fmt.Sprintf("%s/coverage-reports", MakeBuildLink(rctx, buildID))
+}
+
+func MakeRepoBuildCoveragesLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/coverage", MakeRepoLink(rctx, repoID))
+}
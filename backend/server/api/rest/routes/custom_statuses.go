@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeCustomStatusesLink(rctx RequestContext, buildID models.BuildID) string {
+	return fmt.Sprintf("%s/custom-statuses", MakeBuildLink(rctx, buildID))
+}
+
+func MakeCustomStatusLink(rctx RequestContext, buildID models.BuildID, customStatusID models.CustomStatusID) string {
+	return fmt.Sprintf("%s/%s", MakeCustomStatusesLink(rctx, buildID), customStatusID)
+}
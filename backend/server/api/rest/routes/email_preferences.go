@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeEmailPreferenceLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/email-preference", MakeLegalEntityLink(rctx, legalEntityID))
+}
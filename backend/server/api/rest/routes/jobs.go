@@ -9,3 +9,7 @@ import (
 func MakeJobLink(rctx RequestContext, jobID models.JobID) string {
 	return fmt.Sprintf("%s/api/v1/jobs/%s", rctx, jobID)
 }
+
+func MakeRepoJobAnalyticsLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/job-analytics", MakeRepoLink(rctx, repoID))
+}
@@ -13,3 +13,7 @@ func MakeLogLink(rctx RequestContext, logDescriptorID models.LogDescriptorID) st
 func MakeLogDataLink(rctx RequestContext, logID models.LogDescriptorID) string {
 	return fmt.Sprintf("%s/data", MakeLogLink(rctx, logID))
 }
+
+func MakeRepoLogUsageLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/api/v1/repos/%s/log-usage", rctx, repoID)
+}
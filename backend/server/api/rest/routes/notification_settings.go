@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeNotificationSettingLink(rctx RequestContext, repoID models.RepoID, settingID models.NotificationSettingID) string {
+	return fmt.Sprintf("%s/%s", MakeNotificationSettingsLink(rctx, repoID), settingID)
+}
+
+func MakeNotificationSettingsLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/notification-settings", MakeRepoLink(rctx, repoID))
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// MakeOutgoingWebhooksLink returns the link to the outgoing webhooks registered against a repo or legal entity.
+func MakeOutgoingWebhooksLink(rctx RequestContext, parentID models.ResourceID) string {
+	if parentID.Kind() == models.RepoResourceKind {
+		return fmt.Sprintf("%s/outgoing-webhooks", MakeRepoLink(rctx, models.RepoIDFromResourceID(parentID)))
+	}
+	return fmt.Sprintf("%s/outgoing-webhooks", MakeLegalEntityLink(rctx, models.LegalEntityIDFromResourceID(parentID)))
+}
+
+func MakeOutgoingWebhookLink(rctx RequestContext, parentID models.ResourceID, webhookID models.OutgoingWebhookID) string {
+	return fmt.Sprintf("%s/%s", MakeOutgoingWebhooksLink(rctx, parentID), webhookID)
+}
+
+func MakeOutgoingWebhookDeliveriesLink(rctx RequestContext, parentID models.ResourceID, webhookID models.OutgoingWebhookID) string {
+	return fmt.Sprintf("%s/deliveries", MakeOutgoingWebhookLink(rctx, parentID, webhookID))
+}
+
+func MakeOutgoingWebhookDeliveryLink(rctx RequestContext, parentID models.ResourceID, webhookID models.OutgoingWebhookID, deliveryID models.OutgoingWebhookDeliveryID) string {
+	return fmt.Sprintf("%s/%s", MakeOutgoingWebhookDeliveriesLink(rctx, parentID, webhookID), deliveryID)
+}
+
+func MakeOutgoingWebhookRedeliverLink(rctx RequestContext, parentID models.ResourceID, webhookID models.OutgoingWebhookID, deliveryID models.OutgoingWebhookDeliveryID) string {
+	return fmt.Sprintf("%s/redeliver", MakeOutgoingWebhookDeliveryLink(rctx, parentID, webhookID, deliveryID))
+}
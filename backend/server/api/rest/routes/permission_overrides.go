@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakePermissionOverridesLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/permission-overrides", MakeRepoLink(rctx, repoID))
+}
+
+func MakePermissionOverrideLink(rctx RequestContext, repoID models.RepoID, grantID models.GrantID) string {
+	return fmt.Sprintf("%s/%s", MakePermissionOverridesLink(rctx, repoID), grantID)
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakePersonalAccessTokensLink(rctx RequestContext) string {
+	return fmt.Sprintf("%s/access-tokens", MakeCurrentLegalEntityLink(rctx))
+}
+
+func MakePersonalAccessTokenLink(rctx RequestContext, credentialID models.CredentialID) string {
+	return fmt.Sprintf("%s/%s", MakePersonalAccessTokensLink(rctx), credentialID)
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeRolesLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/roles", MakeLegalEntityLink(rctx, legalEntityID))
+}
+
+func MakeRoleLink(rctx RequestContext, legalEntityID models.LegalEntityID, roleID models.RoleID) string {
+	return fmt.Sprintf("%s/%s", MakeRolesLink(rctx, legalEntityID), roleID)
+}
+
+func MakeRoleAssignmentsLink(rctx RequestContext, legalEntityID models.LegalEntityID, roleID models.RoleID) string {
+	return fmt.Sprintf("%s/assignments", MakeRoleLink(rctx, legalEntityID, roleID))
+}
+
+func MakeRoleAssignmentLink(rctx RequestContext, legalEntityID models.LegalEntityID, roleID models.RoleID, assignmentID models.RoleAssignmentID) string {
+	return fmt.Sprintf("%s/%s", MakeRoleAssignmentsLink(rctx, legalEntityID, roleID), assignmentID)
+}
+
+func MakeRoleTemplatesLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/role-templates", MakeLegalEntityLink(rctx, legalEntityID))
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeRunnerPoolsLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/runner-pools", MakeLegalEntityLink(rctx, legalEntityID))
+}
+
+func MakeRunnerPoolLink(rctx RequestContext, legalEntityID models.LegalEntityID, poolID models.RunnerPoolID) string {
+	return fmt.Sprintf("%s/%s", MakeRunnerPoolsLink(rctx, legalEntityID), poolID)
+}
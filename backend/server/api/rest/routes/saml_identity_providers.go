@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeSAMLIdentityProviderLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/saml-identity-provider", MakeLegalEntityLink(rctx, legalEntityID))
+}
+
+func MakeSAMLAuthenticationURL(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/api/v1/authentication/saml/%s/login", rctx, legalEntityID)
+}
+
+func MakeSAMLMetadataURL(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/api/v1/authentication/saml/%s/metadata", rctx, legalEntityID)
+}
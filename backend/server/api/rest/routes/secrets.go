@@ -10,6 +10,10 @@ func MakeSecretLink(rctx RequestContext, secretID models.SecretID) string {
 	return fmt.Sprintf("%s/api/v1/secrets/%s", rctx, secretID)
 }
 
+func MakeSecretVersionsLink(rctx RequestContext, secretID models.SecretID) string {
+	return fmt.Sprintf("%s/versions", MakeSecretLink(rctx, secretID))
+}
+
 func MakeSecretsLink(rctx RequestContext, repoID models.RepoID) string {
 	return fmt.Sprintf("%s/secrets", MakeRepoLink(rctx, repoID))
 }
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeTestSummaryLink(rctx RequestContext, buildID models.BuildID) string {
+	return fmt.Sprintf("%s/test-summary", MakeBuildLink(rctx, buildID))
+}
+
+func MakeTestRunsLink(rctx RequestContext, buildID models.BuildID) string {
+	return fmt.Sprintf("%s/test-runs", MakeBuildLink(rctx, buildID))
+}
+
+func MakeTestCasesLink(rctx RequestContext, buildID models.BuildID) string {
+	return fmt.Sprintf("%s/test-cases", MakeBuildLink(rctx, buildID))
+}
+
+func MakeTestRunCasesLink(rctx RequestContext, buildID models.BuildID, testRunID models.TestRunID) string {
+	return fmt.Sprintf("%s?test_run_id=%s", MakeTestCasesLink(rctx, buildID), url.QueryEscape(testRunID.String()))
+}
+
+func MakeRepoTestSummariesLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/test-summaries", MakeRepoLink(rctx, repoID))
+}
+
+func MakeRepoTestCasesLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/test-cases", MakeRepoLink(rctx, repoID))
+}
+
+func MakeRepoFlakyTestsLink(rctx RequestContext, repoID models.RepoID) string {
+	return fmt.Sprintf("%s/flaky-tests", MakeRepoLink(rctx, repoID))
+}
+
+func MakeJobFlakyTestsLink(rctx RequestContext, jobID models.JobID) string {
+	return fmt.Sprintf("%s/flaky-tests", MakeJobLink(rctx, jobID))
+}
+
+func MakeRepoTestHistoryLink(rctx RequestContext, repoID models.RepoID, suite string, name string) string {
+	return fmt.Sprintf("%s?suite=%s&name=%s", MakeRepoTestCasesLink(rctx, repoID), url.QueryEscape(suite), url.QueryEscape(name))
+}
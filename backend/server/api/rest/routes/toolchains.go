@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeToolchainsLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/toolchains", MakeLegalEntityLink(rctx, legalEntityID))
+}
+
+func MakeToolchainLink(rctx RequestContext, legalEntityID models.LegalEntityID, toolchainID models.ToolchainID) string {
+	return fmt.Sprintf("%s/%s", MakeToolchainsLink(rctx, legalEntityID), toolchainID)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func MakeLegalEntityUsageLink(rctx RequestContext, legalEntityID models.LegalEntityID) string {
+	return fmt.Sprintf("%s/api/v1/legal-entities/%s/usage", rctx, legalEntityID)
+}
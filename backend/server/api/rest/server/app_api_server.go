@@ -40,6 +40,20 @@ func NewAppAPIRouter(
 	log *LogAPI,
 	authentication *CoreAuthenticationAPI,
 	secret *SecretAPI,
+	notificationSetting *NotificationSettingAPI,
+	emailPreference *EmailPreferenceAPI,
+	samlIdentityProvider *SAMLIdentityProviderAPI,
+	role *RoleAPI,
+	permission *PermissionAPI,
+	usage *UsageAPI,
+	buildRuleSet *BuildRuleSetAPI,
+	outgoingWebhook *OutgoingWebhookAPI,
+	runnerPool *RunnerPoolAPI,
+	toolchain *ToolchainAPI,
+	oidc *OIDCAPI,
+	customStatus *CustomStatusAPI,
+	testResult *TestResultAPI,
+	coverage *CoverageAPI,
 	artifact *ArtifactAPI,
 	webhook *WebhookAPI,
 	legalEntity *LegalEntityAPI,
@@ -49,10 +63,15 @@ func NewAppAPIRouter(
 	step *StepAPI,
 	runner *RunnerAPI,
 	search *SearchAPI,
+	statusPages *StatusPagesAPI,
 	dynamicJobAPI *DynamicJobAPI,
 	tokenExchange *TokenExchangeAPI,
+	personalAccessToken *PersonalAccessTokenAPI,
+	graphQL *GraphQLAPI,
 	root *RootAPI,
 	authenticationService services.AuthenticationService,
+	metricsExportService services.MetricsExportService,
+	rateLimits RateLimitsConfig,
 	logFactory logger.LogFactory) *AppAPIRouter {
 
 	logger := logFactory("AppAPIRouter").
@@ -62,10 +81,14 @@ func NewAppAPIRouter(
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
+	r.Use(bbmiddleware.MakeTracing("AppAPI"))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Compress(6))
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	// Build events are fetched via several routes; they share the same limits
+	eventFetchRateLimiter := bbmiddleware.MakeRateLimiter(logger, metricsExportService, "event_fetch", "build event fetches", rateLimits.EventFetch)
+
 	r.Route("/api", func(r chi.Router) {
 
 		// TODO should only be enabled on debug builds
@@ -84,15 +107,59 @@ func NewAppAPIRouter(
 				r.Get("/", root.GetRootDocument)
 				r.Get("/authentication/github", authentication.AuthenticateGitHub)
 				r.Get("/authentication/github/callback", authentication.AuthenticateGitHubCallback)
+				r.Get("/authentication/sso", authentication.AuthenticateSSO)
+				r.Get("/authentication/sso/callback", authentication.AuthenticateSSOCallback)
+				r.Route("/authentication/saml/{legal_entity_id}", func(r chi.Router) {
+					r.Get("/login", authentication.AuthenticateSAML)
+					r.Post("/acs", authentication.AuthenticateSAMLCallback)
+					r.Get("/metadata", authentication.GetSAMLMetadata)
+				})
 				// Public routes for webhooks to go to - each SCM provides its own authentication
 				r.Route("/webhooks", func(r chi.Router) {
 					r.Post("/{scm}", webhook.HandleWebhook)
 				})
 				r.Post("/token-exchange", tokenExchange.Exchange)
+				// OpenID Connect discovery for verifiers of the tokens issued to jobs
+				r.Route("/oidc", func(r chi.Router) {
+					r.Get("/.well-known/openid-configuration", oidc.GetConfiguration)
+					r.Get("/jwks", oidc.GetJWKS)
+				})
 			})
 
 			// Routes for Dynamic API clients to interact with; note this includes some read-only API functions
-			r.Group(DynamicJobAPIRouterFactory(dynamicJobAPI, build, job, artifact, log, authenticationService, logFactory))
+			r.Group(DynamicJobAPIRouterFactory(dynamicJobAPI, build, job, artifact, log, customStatus, toolchain, oidc, authenticationService, eventFetchRateLimiter, logFactory))
+
+			// Read-only routes for repos with public builds. Requests that aren't authenticated are made
+			// as the anonymous identity, which can only read repos that have public builds enabled.
+			r.Route("/public", func(r chi.Router) {
+				r.Use(authentication.SessionAuthenticator)
+				r.Use(bbmiddleware.MakeSharedSecretAuthenticator(logger, authenticationService))
+				r.Use(bbmiddleware.MakeAnonymousAuthenticator(logger))
+
+				r.Route("/repos/{repo_id}", func(r chi.Router) {
+					r.Get("/", repo.Get)
+					r.Get("/builds", build.List)
+				})
+				r.Route("/builds/{build_id}", func(r chi.Router) {
+					r.Get("/", build.Get)
+					r.Get("/artifacts", artifact.List)
+					r.With(eventFetchRateLimiter).Get("/events", build.GetEvents)
+					r.Get("/timeline", build.GetTimeline)
+					r.Get("/dag", build.GetDAG)
+				})
+				r.Route("/jobs/{job_id}", func(r chi.Router) {
+					r.Get("/", job.Get)
+					r.Get("/graph", job.GetGraph)
+				})
+				r.Route("/artifacts/{artifact_id}", func(r chi.Router) {
+					r.Get("/", artifact.Get)
+					r.Get("/data", artifact.GetData)
+				})
+				r.Route("/logs/{log_descriptor_id}", func(r chi.Router) {
+					r.Get("/", log.Get)
+					r.Get("/data", log.GetData)
+				})
+			})
 
 			// Routes for API clients to interact with are authenticated using sessions
 			r.Group(func(r chi.Router) {
@@ -104,7 +171,35 @@ func NewAppAPIRouter(
 					r.Get("/", legalEntity.List)
 					r.Route("/{legal_entity_id}", func(r chi.Router) {
 						r.Get("/", legalEntity.Get)
+						r.Patch("/", legalEntity.Patch)
 						r.Get("/setup-status", legalEntity.GetSetupStatus)
+						r.Route("/email-preference", func(r chi.Router) {
+							r.Get("/", emailPreference.Get)
+							r.Patch("/", emailPreference.Patch)
+						})
+						r.Route("/saml-identity-provider", func(r chi.Router) {
+							r.Get("/", samlIdentityProvider.Get)
+							r.Patch("/", samlIdentityProvider.Patch)
+							r.Delete("/", samlIdentityProvider.Delete)
+						})
+						r.Get("/role-templates", role.ListTemplates)
+						r.Route("/roles", func(r chi.Router) {
+							r.Get("/", role.List)
+							r.Post("/", role.Create)
+							r.Route("/{role_id}", func(r chi.Router) {
+								r.Get("/", role.Get)
+								r.Patch("/", role.Patch)
+								r.Delete("/", role.Delete)
+								r.Route("/assignments", func(r chi.Router) {
+									r.Get("/", role.ListAssignments)
+									r.Post("/", role.Assign)
+									r.Get("/{role_assignment_id}", role.GetAssignment)
+									r.Delete("/{role_assignment_id}", role.Unassign)
+								})
+							})
+						})
+						r.Get("/effective-permissions", permission.GetEffectivePermissions)
+						r.Get("/usage", usage.GetSummary)
 						r.Route("/repos", func(r chi.Router) {
 							r.Get("/", repo.List)
 							r.Post("/search", repo.Search)
@@ -117,6 +212,37 @@ func NewAppAPIRouter(
 							r.Post("/", runner.Create)
 							r.Post("/search", runner.Search)
 						})
+						r.Route("/runner-pools", func(r chi.Router) {
+							r.Get("/", runnerPool.List)
+							r.Post("/", runnerPool.Create)
+							r.Route("/{runner_pool_id}", func(r chi.Router) {
+								r.Get("/", runnerPool.Get)
+								r.Patch("/", runnerPool.Patch)
+								r.Delete("/", runnerPool.Delete)
+							})
+						})
+						r.Route("/toolchains", func(r chi.Router) {
+							r.Get("/", toolchain.List)
+							r.Post("/", toolchain.Create)
+							r.Route("/{toolchain_id}", func(r chi.Router) {
+								r.Get("/", toolchain.Get)
+								r.Delete("/", toolchain.Delete)
+							})
+						})
+						r.Route("/outgoing-webhooks", func(r chi.Router) {
+							r.Get("/", outgoingWebhook.List)
+							r.Post("/", outgoingWebhook.Create)
+							r.Route("/{outgoing_webhook_id}", func(r chi.Router) {
+								r.Get("/", outgoingWebhook.Get)
+								r.Patch("/", outgoingWebhook.Patch)
+								r.Delete("/", outgoingWebhook.Delete)
+								r.Route("/deliveries", func(r chi.Router) {
+									r.Get("/", outgoingWebhook.ListDeliveries)
+									r.Get("/{outgoing_webhook_delivery_id}", outgoingWebhook.GetDelivery)
+									r.Post("/{outgoing_webhook_delivery_id}/redeliver", outgoingWebhook.Redeliver)
+								})
+							})
+						})
 					})
 				})
 				r.Route("/repos/{repo_id}", func(r chi.Router) {
@@ -126,28 +252,84 @@ func NewAppAPIRouter(
 						r.Get("/", build.List)
 						r.Post("/", build.Create)
 						r.Post("/search", build.Search)
+						r.Post("/validate", build.ValidateConfig)
 					})
 					r.Route("/secrets", func(r chi.Router) {
 						r.Get("/", secret.List)
 						r.Post("/", secret.Create)
 					})
+					r.Route("/build-rules", func(r chi.Router) {
+						r.Get("/", buildRuleSet.Get)
+						r.Patch("/", buildRuleSet.Patch)
+						r.Delete("/", buildRuleSet.Delete)
+					})
+					r.Route("/notification-settings", func(r chi.Router) {
+						r.Get("/", notificationSetting.List)
+						r.Post("/", notificationSetting.Create)
+						r.Route("/{notification_setting_id}", func(r chi.Router) {
+							r.Get("/", notificationSetting.Get)
+							r.Patch("/", notificationSetting.Patch)
+							r.Delete("/", notificationSetting.Delete)
+						})
+					})
+					r.Route("/outgoing-webhooks", func(r chi.Router) {
+						r.Get("/", outgoingWebhook.List)
+						r.Post("/", outgoingWebhook.Create)
+						r.Route("/{outgoing_webhook_id}", func(r chi.Router) {
+							r.Get("/", outgoingWebhook.Get)
+							r.Patch("/", outgoingWebhook.Patch)
+							r.Delete("/", outgoingWebhook.Delete)
+							r.Route("/deliveries", func(r chi.Router) {
+								r.Get("/", outgoingWebhook.ListDeliveries)
+								r.Get("/{outgoing_webhook_delivery_id}", outgoingWebhook.GetDelivery)
+								r.Post("/{outgoing_webhook_delivery_id}/redeliver", outgoingWebhook.Redeliver)
+							})
+						})
+					})
+					r.Get("/test-summaries", testResult.ListRepoSummaries)
+					r.Get("/test-cases", testResult.ListRepoCases)
+					r.Get("/flaky-tests", testResult.ListRepoFlakyTests)
+					r.Get("/job-analytics", job.GetRepoAnalytics)
+					r.Get("/coverage", coverage.ListRepoBuildCoverages)
+					r.Get("/log-usage", log.GetRepoUsage)
+					r.Route("/permission-overrides", func(r chi.Router) {
+						r.Get("/", permission.ListOverrides)
+						r.Post("/", permission.SetOverride)
+						r.Get("/{permission_override_id}", permission.GetOverride)
+						r.Delete("/{permission_override_id}", permission.DeleteOverride)
+					})
+					r.Get("/effective-permissions", permission.GetEffectivePermissions)
 				})
 				r.Route("/runners/{runner_id}", func(r chi.Router) {
 					r.Get("/", runner.Get)
 					r.Patch("/", runner.Patch)
 					r.Delete("/", runner.Delete)
+					r.Get("/jobs", job.ListForRunner)
 				})
 				r.Route("/builds/{build_id}", func(r chi.Router) {
 					r.Get("/", build.Get)
+					r.Patch("/", build.Patch)
 					r.Route("/artifacts", func(r chi.Router) {
 						r.Get("/", artifact.List)
 						r.Post("/search", artifact.Search)
+						r.Get("/bundle", artifact.GetBundle)
 					})
-					r.Get("/events", build.GetEvents)
+					r.With(eventFetchRateLimiter).Get("/events", build.GetEvents)
+					r.Get("/timeline", build.GetTimeline)
+					r.Get("/dag", build.GetDAG)
+					r.Get("/compare", build.Compare)
+					r.Post("/clone", build.Clone)
+					r.Get("/custom-statuses", customStatus.List)
+					r.Get("/test-summary", testResult.GetSummary)
+					r.Get("/test-runs", testResult.ListRuns)
+					r.Get("/test-cases", testResult.ListCases)
+					r.Get("/coverage", coverage.GetBuildCoverage)
+					r.Get("/coverage-reports", coverage.ListReports)
 				})
 				r.Route("/artifacts/{artifact_id}", func(r chi.Router) {
 					r.Get("/", artifact.Get)
 					r.Get("/data", artifact.GetData)
+					r.Get("/verification", artifact.GetVerification)
 				})
 				r.Route("/logs/{log_descriptor_id}", func(r chi.Router) {
 					r.Get("/", log.Get)
@@ -157,17 +339,24 @@ func NewAppAPIRouter(
 					r.Get("/", secret.Get)
 					r.Patch("/", secret.Patch)
 					r.Delete("/", secret.Delete)
+					r.Get("/versions", secret.ListVersions)
 				})
 				r.Route("/jobs/{job_id}", func(r chi.Router) {
 					r.Get("/", job.Get)
 					r.Get("/graph", job.GetGraph)
 					r.Patch("/", job.Patch)
+					r.Get("/flaky-tests", testResult.GetJobFlakyTests)
 				})
 				r.Route("/steps/{step_id}", func(r chi.Router) {
 					r.Patch("/", step.Patch)
 				})
 				r.Route("/user", func(r chi.Router) {
 					r.Get("/", legalEntity.GetCurrent)
+					r.Route("/access-tokens", func(r chi.Router) {
+						r.Get("/", personalAccessToken.List)
+						r.Post("/", personalAccessToken.Create)
+						r.Delete("/{credential_id}", personalAccessToken.Revoke)
+					})
 				})
 				r.Route("/users", func(r chi.Router) {
 					r.Route("/{legal_entity_name:"+models.ResourceNameRegexStr+"}", func(r chi.Router) {
@@ -222,6 +411,7 @@ func NewAppAPIRouter(
 								r.Get("/", secret.Get)
 								r.Patch("/", secret.Patch)
 								r.Delete("/", secret.Delete)
+								r.Get("/versions", secret.ListVersions)
 							})
 						})
 					})
@@ -230,8 +420,20 @@ func NewAppAPIRouter(
 					r.Get("/", search.List)
 					r.Post("/", search.Search)
 				})
+				r.Route("/graphql", func(r chi.Router) {
+					r.Post("/", graphQL.Query)
+					r.Get("/schema", graphQL.GetSchema)
+				})
 			})
 		})
 	})
+
+	// Server-rendered status pages for installs that don't deploy the frontend. Requests that aren't
+	// authenticated are made as the anonymous identity, so repos with public builds can be browsed.
+	r.Group(func(r chi.Router) {
+		r.Use(authentication.SessionAuthenticator)
+		r.Use(bbmiddleware.MakeAnonymousAuthenticator(logger))
+		r.Group(StatusPagesRouterFactory(statusPages))
+	})
 	return &AppAPIRouter{Router: r}
 }
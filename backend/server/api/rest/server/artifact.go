@@ -94,12 +94,77 @@ func (a *ArtifactAPI) GetData(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetVerification re-hashes the artifact's stored data and checks it against the hash and signature recorded
+// when the artifact was uploaded, so that consumers can confirm the artifact has not been tampered with.
+func (a *ArtifactAPI) GetVerification(w http.ResponseWriter, r *http.Request) {
+	artifactID, err := a.AuthorizedArtifactID(r, models.ArtifactReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	verification, err := a.artifactService.VerifyArtifact(r.Context(), artifactID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeArtifactVerification(routes.RequestCtx(r), verification))
+}
+
+// GetBundle streams a single archive containing the data for all artifacts in a build, optionally filtered to
+// a workflow, job or artifact group.
+func (a *ArtifactAPI) GetBundle(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.BuildID(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	bundle := documents.NewArtifactBundleRequest()
+	err = bundle.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	bundle.BuildID = buildID
+
+	// Headers are only written once the bundle produces its first bytes, so that errors found before
+	// then (e.g. no matching artifacts) can still be reported with an appropriate status code
+	writer := &bundleResponseWriter{
+		w: w,
+		writeHeaders: func() {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-artifacts.%s", buildID, bundle.Format.FileExtension()))
+			w.Header().Set("Content-Type", bundle.Format.ContentType())
+			w.WriteHeader(http.StatusOK)
+		},
+	}
+	identityID, err := a.ScopedIdentityID(r, models.ArtifactReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.artifactService.WriteArtifactBundle(r.Context(), identityID, *bundle.ArtifactSearch, bundle.Format, writer)
+	if err != nil {
+		if !writer.started {
+			a.Error(w, r, err)
+			return
+		}
+		a.Errorf("error writing artifact bundle to response body: %v", err)
+	}
+}
+
+// List returns the artifacts produced by a build. If the optional 'wait' query parameter is supplied and none of
+// the matching artifacts have been sealed, the request waits for up to that many seconds for a matching artifact
+// to be sealed, so clients waiting for an artifact don't have to keep polling.
 func (a *ArtifactAPI) List(w http.ResponseWriter, r *http.Request) {
 	buildID, err := a.BuildID(r)
 	if err != nil {
 		a.Error(w, r, err)
 		return
 	}
+	wait, err := a.GetWait(r, MaxResourceWait)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
 	search := documents.NewArtifactSearchRequest()
 	err = search.FromQuery(r.URL.Query())
 	if err != nil {
@@ -109,7 +174,12 @@ func (a *ArtifactAPI) List(w http.ResponseWriter, r *http.Request) {
 	// The artifact list is embedded under a build in the API, so this search
 	// is always filtered to artifacts for that build.
 	search.BuildID = buildID
-	artifacts, cursor, err := a.artifactService.Search(r.Context(), nil, a.MustAuthenticatedIdentityID(r), *search.ArtifactSearch)
+	identityID, err := a.ScopedIdentityID(r, models.ArtifactReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	artifacts, cursor, err := a.artifactService.SearchWithWait(r.Context(), identityID, *search.ArtifactSearch, wait)
 	if err != nil {
 		a.Error(w, r, err)
 		return
@@ -152,3 +222,18 @@ func (a *ArtifactAPI) Search(w http.ResponseWriter, r *http.Request) {
 	next := documents.AddQueryParams(link, search)
 	http.Redirect(w, r, next.String(), http.StatusSeeOther)
 }
+
+// bundleResponseWriter writes response headers just before the first write to the response body.
+type bundleResponseWriter struct {
+	w            io.Writer
+	writeHeaders func()
+	started      bool
+}
+
+func (b *bundleResponseWriter) Write(p []byte) (int, error) {
+	if !b.started {
+		b.started = true
+		b.writeHeaders()
+	}
+	return b.w.Write(p)
+}
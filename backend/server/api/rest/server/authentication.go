@@ -26,6 +26,17 @@ const (
 	sessionIdentityIDKeyName      = "identity_id"
 	sessionOAuthTokenKeyName      = "oauth_token"
 	sessionGitHubAuthStateKeyName = "github_state"
+	sessionSSOAuthStateKeyName    = "sso_state"
+	sessionSSONonceKeyName        = "sso_nonce"
+	sessionCredentialTypeKeyName  = "credential_type"
+
+	// The SAML session tracks an authentication request while the user is at the identity provider. It is
+	// separate from the main session since it must be sent on the cross-site POST back from the identity
+	// provider, which requires SameSite=None.
+	samlSessionName                 = "buildbeaver_saml"
+	samlSessionRequestIDKeyName     = "request_id"
+	samlSessionLegalEntityIDKeyName = "legal_entity_id"
+	samlSessionExpirySeconds        = 60 * 10 // 10 minutes
 
 	sessionOAuthRedirectSuccessKeyName = "redirect_success_url"
 	sessionOAuthRedirectErrorKeyName   = "redirect_error_url"
@@ -54,13 +65,16 @@ type AuthenticationConfig struct {
 
 type CoreAuthenticationAPI struct {
 	authenticationService services.AuthenticationService
+	ssoService            services.SSOService
 	sessionStore          sessions.Store
+	samlSessionStore      sessions.Store
 	config                AuthenticationConfig
 	*APIBase
 }
 
 func NewCoreAuthenticationAPI(
 	authenticationService services.AuthenticationService,
+	ssoService services.SSOService,
 	authorizationService services.AuthorizationService,
 	resourceLinker *routes.ResourceLinker,
 	logFactory logger.LogFactory,
@@ -85,9 +99,19 @@ func NewCoreAuthenticationAPI(
 		sessionStore.Options.SameSite = http.SameSiteLaxMode
 	}
 
+	samlSessionStore := sessions.NewCookieStore(
+		config.SessionAuthenticationKey[:],
+		config.SessionEncryptionKey[:])
+	samlSessionStore.Options.Secure = true
+	samlSessionStore.Options.HttpOnly = true
+	samlSessionStore.Options.SameSite = http.SameSiteNoneMode
+	samlSessionStore.MaxAge(samlSessionExpirySeconds)
+
 	return &CoreAuthenticationAPI{
 		authenticationService: authenticationService,
+		ssoService:            ssoService,
 		sessionStore:          sessionStore,
+		samlSessionStore:      samlSessionStore,
 		config:                config,
 		APIBase:               NewAPIBase(authorizationService, resourceLinker, logFactory("CoreAuthenticationAPI")),
 	}
@@ -106,16 +130,12 @@ func (a *CoreAuthenticationAPI) AuthenticateGitHub(w http.ResponseWriter, r *htt
 		return
 	}
 
-	stateBytes := make([]byte, 16)
-
-	_, err := rand.Read(stateBytes)
+	state, err := makeRandomSessionValue()
 	if err != nil {
 		a.Error(w, r, errors.Wrap(err, "error generating oauth state"))
 		return
 	}
 
-	state := base64.URLEncoding.EncodeToString(stateBytes)
-
 	pairs := map[string]string{
 		sessionGitHubAuthStateKeyName:      state,
 		sessionOAuthRedirectSuccessKeyName: successRedirectURLStr,
@@ -204,8 +224,9 @@ func (a *CoreAuthenticationAPI) AuthenticateGitHubCallback(w http.ResponseWriter
 	}
 
 	pairs := map[string]string{
-		sessionIdentityIDKeyName: identity.ID.String(),
-		sessionOAuthTokenKeyName: string(oAuthTokenJson),
+		sessionIdentityIDKeyName:     identity.ID.String(),
+		sessionOAuthTokenKeyName:     string(oAuthTokenJson),
+		sessionCredentialTypeKeyName: models.CredentialTypeGitHubOAuth.String(),
 	}
 
 	err = a.setSessionValues(w, r, pairs)
@@ -220,6 +241,258 @@ func (a *CoreAuthenticationAPI) AuthenticateGitHubCallback(w http.ResponseWriter
 	http.Redirect(w, r, successRedirectURL.String(), 302)
 }
 
+// AuthenticateSSO uses OpenID Connect to authenticate the user using the configured single sign-on identity
+// provider. This endpoint will redirect the browser to the provider, which will then redirect back to
+// the AuthenticateSSOCallback handler.
+func (a *CoreAuthenticationAPI) AuthenticateSSO(w http.ResponseWriter, r *http.Request) {
+	if !a.ssoService.Enabled() {
+		a.Error(w, r, gerror.NewErrNotFound("Single sign-on is not enabled on this server"))
+		return
+	}
+
+	successRedirectURLStr := r.URL.Query().Get("success_url")
+	errorRedirectURLStr := r.URL.Query().Get("error_url")
+
+	if successRedirectURLStr == "" || errorRedirectURLStr == "" {
+		a.Error(w, r, gerror.NewErrValidationFailed("success_url and error_url must be set"))
+		return
+	}
+
+	state, err := makeRandomSessionValue()
+	if err != nil {
+		a.Error(w, r, errors.Wrap(err, "error generating oauth state"))
+		return
+	}
+	nonce, err := makeRandomSessionValue()
+	if err != nil {
+		a.Error(w, r, errors.Wrap(err, "error generating oidc nonce"))
+		return
+	}
+
+	authURL, err := a.ssoService.AuthCodeURL(r.Context(), state, nonce)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+
+	pairs := map[string]string{
+		sessionSSOAuthStateKeyName:         state,
+		sessionSSONonceKeyName:             nonce,
+		sessionOAuthRedirectSuccessKeyName: successRedirectURLStr,
+		sessionOAuthRedirectErrorKeyName:   errorRedirectURLStr,
+	}
+
+	err = a.setSessionValues(w, r, pairs)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, 302)
+}
+
+// AuthenticateSSOCallback is the second step in the OpenID Connect flow for single sign-on. It exchanges the
+// authorization code for an ID token, which is verified and matched up to a BuildBeaver user. On success a
+// session cookie is issued and the browser is redirected to the success url, on error the browser is
+// redirected to the error url.
+func (a *CoreAuthenticationAPI) AuthenticateSSOCallback(w http.ResponseWriter, r *http.Request) {
+
+	session := a.getSession(r)
+	expectedState := a.getSessionValue(session, sessionSSOAuthStateKeyName)
+	nonce := a.getSessionValue(session, sessionSSONonceKeyName)
+	successRedirectURLStr := a.getSessionValue(session, sessionOAuthRedirectSuccessKeyName)
+	errorRedirectURLStr := a.getSessionValue(session, sessionOAuthRedirectErrorKeyName)
+
+	if expectedState == "" || nonce == "" {
+		a.Error(w, r, gerror.NewErrValidationFailed("sso_state or sso_nonce not set on oidc callback"))
+		return
+	}
+	if successRedirectURLStr == "" || errorRedirectURLStr == "" {
+		a.Error(w, r, gerror.NewErrValidationFailed("redirect urls not set on oidc callback"))
+		return
+	}
+
+	successRedirectURL, err := url.Parse(successRedirectURLStr)
+	if err != nil {
+		a.Error(w, r, gerror.NewErrValidationFailed("error parsing success redirect url").Wrap(err))
+		return
+	}
+
+	errorRedirectURL, err := url.Parse(errorRedirectURLStr)
+	if err != nil {
+		a.Error(w, r, gerror.NewErrValidationFailed("error parsing error redirect url").Wrap(err))
+		return
+	}
+
+	if r.URL.Query().Get("state") != expectedState {
+		a.Errorf("Mismatched state")
+		http.Redirect(w, r, errorRedirectURL.String(), 302)
+		return
+	}
+
+	if providerError := r.URL.Query().Get("error"); providerError != "" {
+		a.Errorf("Identity provider returned error: %s: %s", providerError, r.URL.Query().Get("error_description"))
+		http.Redirect(w, r, errorRedirectURL.String(), 302)
+		return
+	}
+
+	identity, err := a.ssoService.Authenticate(r.Context(), r.URL.Query().Get("code"), nonce)
+	if err != nil {
+		a.Errorf("Error authenticating: %s", err)
+		http.Redirect(w, r, errorRedirectURL.String(), 302)
+		return
+	}
+
+	// The state and nonce must only be used once
+	pairs := map[string]string{
+		sessionIdentityIDKeyName:     identity.ID.String(),
+		sessionOAuthTokenKeyName:     "",
+		sessionCredentialTypeKeyName: models.CredentialTypeSSO.String(),
+		sessionSSOAuthStateKeyName:   "",
+		sessionSSONonceKeyName:       "",
+	}
+
+	err = a.setSessionValues(w, r, pairs)
+	if err != nil {
+		a.Errorf("Error issuing session: %s", err)
+		http.Redirect(w, r, errorRedirectURL.String(), 302)
+		return
+	}
+
+	a.Infof("Identity %s authenticated using single sign-on", identity.ID.String())
+
+	http.Redirect(w, r, successRedirectURL.String(), 302)
+}
+
+// AuthenticateSAML uses SAML to authenticate the user using the identity provider configured for the legal entity
+// in the URL. This endpoint will redirect the browser to the identity provider, which will then post the user's
+// assertion back to the AuthenticateSAMLCallback handler.
+func (a *CoreAuthenticationAPI) AuthenticateSAML(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := parseURLParamResourceID(r, "legal_entity_id", models.LegalEntityResourceKind)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+
+	successRedirectURLStr := r.URL.Query().Get("success_url")
+	errorRedirectURLStr := r.URL.Query().Get("error_url")
+
+	if successRedirectURLStr == "" || errorRedirectURLStr == "" {
+		a.Error(w, r, gerror.NewErrValidationFailed("success_url and error_url must be set"))
+		return
+	}
+
+	req, err := a.authenticationService.MakeSAMLAuthenticationRequest(r.Context(), models.LegalEntityIDFromResourceID(legalEntityID))
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+
+	session := a.getSAMLSession(r)
+	session.Values[samlSessionRequestIDKeyName] = req.RequestID
+	session.Values[samlSessionLegalEntityIDKeyName] = legalEntityID.String()
+	session.Values[sessionOAuthRedirectSuccessKeyName] = successRedirectURLStr
+	session.Values[sessionOAuthRedirectErrorKeyName] = errorRedirectURLStr
+	err = session.Save(r, w)
+	if err != nil {
+		a.Error(w, r, errors.Wrap(err, "error saving saml session"))
+		return
+	}
+
+	http.Redirect(w, r, req.RedirectURL, 302)
+}
+
+// AuthenticateSAMLCallback is the assertion consumer service for SAML authentication. It validates the response
+// posted by the identity provider and matches the assertion up to a BuildBeaver user. On success a session
+// cookie is issued and the browser is redirected to the success url, on error the browser is redirected
+// to the error url.
+func (a *CoreAuthenticationAPI) AuthenticateSAMLCallback(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := parseURLParamResourceID(r, "legal_entity_id", models.LegalEntityResourceKind)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+
+	samlSession := a.getSAMLSession(r)
+	requestID := a.getSessionValue(samlSession, samlSessionRequestIDKeyName)
+	sessionLegalEntityID := a.getSessionValue(samlSession, samlSessionLegalEntityIDKeyName)
+	successRedirectURLStr := a.getSessionValue(samlSession, sessionOAuthRedirectSuccessKeyName)
+	errorRedirectURLStr := a.getSessionValue(samlSession, sessionOAuthRedirectErrorKeyName)
+
+	if requestID == "" || sessionLegalEntityID != legalEntityID.String() {
+		a.Error(w, r, gerror.NewErrValidationFailed("no saml authentication request in progress for this legal entity"))
+		return
+	}
+	if successRedirectURLStr == "" || errorRedirectURLStr == "" {
+		a.Error(w, r, gerror.NewErrValidationFailed("redirect urls not set on saml callback"))
+		return
+	}
+
+	successRedirectURL, err := url.Parse(successRedirectURLStr)
+	if err != nil {
+		a.Error(w, r, gerror.NewErrValidationFailed("error parsing success redirect url").Wrap(err))
+		return
+	}
+
+	errorRedirectURL, err := url.Parse(errorRedirectURLStr)
+	if err != nil {
+		a.Error(w, r, gerror.NewErrValidationFailed("error parsing error redirect url").Wrap(err))
+		return
+	}
+
+	// The request ID must only be used once
+	samlSession.Options.MaxAge = -1
+	err = samlSession.Save(r, w)
+	if err != nil {
+		a.Errorf("Error clearing saml session: %s", err)
+		http.Redirect(w, r, errorRedirectURL.String(), 302)
+		return
+	}
+
+	identity, err := a.authenticationService.AuthenticateSAMLResponse(
+		r.Context(), models.LegalEntityIDFromResourceID(legalEntityID), r.PostFormValue("SAMLResponse"), requestID)
+	if err != nil {
+		a.Errorf("Error authenticating: %s", err)
+		http.Redirect(w, r, errorRedirectURL.String(), 302)
+		return
+	}
+
+	pairs := map[string]string{
+		sessionIdentityIDKeyName:     identity.ID.String(),
+		sessionOAuthTokenKeyName:     "",
+		sessionCredentialTypeKeyName: models.CredentialTypeSAML.String(),
+	}
+
+	err = a.setSessionValues(w, r, pairs)
+	if err != nil {
+		a.Errorf("Error issuing session: %s", err)
+		http.Redirect(w, r, errorRedirectURL.String(), 302)
+		return
+	}
+
+	a.Infof("Identity %s authenticated using SAML identity provider for legal entity %s", identity.ID.String(), legalEntityID)
+
+	// Redirect with a 303 so the browser follows up the POST with a GET
+	http.Redirect(w, r, successRedirectURL.String(), http.StatusSeeOther)
+}
+
+// GetSAMLMetadata returns the SAML service provider metadata for the legal entity in the URL, which
+// must be uploaded to the legal entity's identity provider.
+func (a *CoreAuthenticationAPI) GetSAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := parseURLParamResourceID(r, "legal_entity_id", models.LegalEntityResourceKind)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	metadata, err := a.authenticationService.GetSAMLServiceProviderMetadata(r.Context(), models.LegalEntityIDFromResourceID(legalEntityID))
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(metadata)
+}
+
 // SessionAuthenticator makes a middleware that authenticates requests using a session cookie
 // from the request headers. If the request headers do not contain a session cookie then this is a no-op.
 func (a *CoreAuthenticationAPI) SessionAuthenticator(next http.Handler) http.Handler {
@@ -247,10 +520,19 @@ func (a *CoreAuthenticationAPI) SessionAuthenticator(next http.Handler) http.Han
 				}
 			}
 
+			// Sessions issued before the credential type was recorded were always issued via GitHub
+			credentialType := models.CredentialTypeGitHubOAuth
+			if credentialTypeStr := a.getSessionValue(session, sessionCredentialTypeKeyName); credentialTypeStr != "" {
+				credentialType = models.CredentialType(credentialTypeStr)
+			}
+			if credentialType != models.CredentialTypeGitHubOAuth {
+				oauthToken = nil
+			}
+
 			if identityID.Valid() {
 				meta := &middleware.AuthenticationMeta{
 					IdentityID:     identityID,
-					CredentialType: models.CredentialTypeGitHubOAuth, // TODO stash/restore credential type in/from session
+					CredentialType: credentialType,
 					OAuthToken:     oauthToken,
 				}
 				ctx := context.WithValue(r.Context(), AuthenticationMetaContextKeyName, meta)
@@ -263,6 +545,16 @@ func (a *CoreAuthenticationAPI) SessionAuthenticator(next http.Handler) http.Han
 	return http.HandlerFunc(fn)
 }
 
+// makeRandomSessionValue returns a random value suitable for use as an OAuth state or OpenID Connect nonce.
+func makeRandomSessionValue() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
 func (a *CoreAuthenticationAPI) getSession(r *http.Request) *sessions.Session {
 	session, err := a.sessionStore.Get(r, sessionName)
 	if err != nil {
@@ -271,6 +563,14 @@ func (a *CoreAuthenticationAPI) getSession(r *http.Request) *sessions.Session {
 	return session
 }
 
+func (a *CoreAuthenticationAPI) getSAMLSession(r *http.Request) *sessions.Session {
+	session, err := a.samlSessionStore.Get(r, samlSessionName)
+	if err != nil {
+		session, _ = a.samlSessionStore.New(r, samlSessionName)
+	}
+	return session
+}
+
 func (a *CoreAuthenticationAPI) setSessionValues(w http.ResponseWriter, r *http.Request, pairs map[string]string) error {
 	session := a.getSession(r)
 	for k, v := range pairs {
@@ -5,7 +5,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/render"
 	"github.com/lib/pq"
@@ -22,6 +25,10 @@ import (
 
 const AuthenticationMetaContextKeyName = "authentication"
 
+// MaxResourceWait is the longest a client can ask to wait for a resource to reach a desired state, such as
+// an artifact or log being sealed. This is kept below the 30 second timeout applied to dynamic API requests.
+const MaxResourceWait = 25 * time.Second
+
 type APIBase struct {
 	logger.Log
 	resourceLinker       *routes.ResourceLinker
@@ -176,6 +183,9 @@ func (a *APIBase) UpdatedResource(w http.ResponseWriter, r *http.Request, resour
 // legal entity from the request as the principal. Returns an error if the user is not authorized.
 func (a *APIBase) Authorize(r *http.Request, operation *models.Operation, resourceID models.ResourceID) error {
 	meta := a.MustAuthenticationMeta(r)
+	if !meta.AllowsOperation(operation) {
+		return gerror.NewErrUnauthorized(fmt.Sprintf("Personal access token does not have a scope allowing %s", operation))
+	}
 	authorized, err := a.authorizationService.IsAuthorized(
 		r.Context(),
 		meta.IdentityID,
@@ -334,6 +344,18 @@ func (a *APIBase) MustAuthenticationMeta(r *http.Request) *middleware.Authentica
 	return meta
 }
 
+// ScopedIdentityID returns the id of the currently authenticated identity from the request, after checking the
+// credential the request was authenticated with can be used for the specified operation. Use this instead of
+// MustAuthenticatedIdentityID when searching for resources the identity has been granted the operation on,
+// since a search does not check each result with Authorize.
+func (a *APIBase) ScopedIdentityID(r *http.Request, operation *models.Operation) (models.IdentityID, error) {
+	meta := a.MustAuthenticationMeta(r)
+	if !meta.AllowsOperation(operation) {
+		return models.IdentityID{}, gerror.NewErrUnauthorized(fmt.Sprintf("Personal access token does not have a scope allowing %s", operation))
+	}
+	return meta.IdentityID, nil
+}
+
 // MustAuthenticatedIdentityID returns the id of the currently authenticated identity from the request.
 // If the request is not authenticated then this panics.
 func (a *APIBase) MustAuthenticatedIdentityID(r *http.Request) models.IdentityID {
@@ -343,3 +365,22 @@ func (a *APIBase) MustAuthenticatedIdentityID(r *http.Request) models.IdentityID
 func (a *APIBase) GetIfMatch(r *http.Request) models.ETag {
 	return models.ETag(r.Header.Get("If-Match"))
 }
+
+// GetWait returns the duration specified by the optional 'wait' query parameter, which is a number of seconds
+// to wait before responding. Waits longer than maxWait are reduced to maxWait. Returns zero if the parameter
+// is not supplied.
+func (a *APIBase) GetWait(r *http.Request, maxWait time.Duration) (time.Duration, error) {
+	waitStr := r.URL.Query().Get("wait")
+	if waitStr == "" {
+		return 0, nil
+	}
+	waitSeconds, err := strconv.Atoi(waitStr)
+	if err != nil || waitSeconds < 0 {
+		return 0, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid 'wait' query parameter %q: must be a number of seconds", waitStr))
+	}
+	wait := time.Duration(waitSeconds) * time.Second
+	if wait > maxWait {
+		wait = maxWait
+	}
+	return wait, nil
+}
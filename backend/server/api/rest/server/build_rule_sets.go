@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// BuildRuleSetAPI manages the build rules for a repo, which decide which pushes and pull requests trigger
+// builds. Each repo has exactly one set of rules, addressed via the repo, and access is controlled by the
+// operations granted on the repo.
+type BuildRuleSetAPI struct {
+	buildRuleSetService services.BuildRuleSetService
+	*APIBase
+}
+
+func NewBuildRuleSetAPI(
+	buildRuleSetService services.BuildRuleSetService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *BuildRuleSetAPI {
+	return &BuildRuleSetAPI{
+		buildRuleSetService: buildRuleSetService,
+		APIBase:             NewAPIBase(authorizationService, resourceLinker, logFactory("BuildRuleSetAPI")),
+	}
+}
+
+func (a *BuildRuleSetAPI) Get(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.RepoReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	ruleSet, err := a.buildRuleSetService.Read(r.Context(), nil, repoID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeBuildRuleSet(routes.RequestCtx(r), ruleSet)
+	a.GotResource(w, r, res)
+}
+
+func (a *BuildRuleSetAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.RepoUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchBuildRuleSetRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	ruleSet, err := a.buildRuleSetService.Update(r.Context(), nil, repoID, dto.UpdateBuildRuleSet{
+		AllowRefs:        req.AllowRefs,
+		DenyRefs:         req.DenyRefs,
+		BuildTags:        req.BuildTags,
+		PullRequestsOnly: req.PullRequestsOnly,
+		SkipPattern:      req.SkipPattern,
+		CommentCommands:  req.CommentCommands,
+		ETag:             a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeBuildRuleSet(routes.RequestCtx(r), ruleSet)
+	a.UpdatedResource(w, r, res, nil)
+}
+
+// Delete removes the repo's build rules, so that every branch and tag is built again.
+func (a *BuildRuleSetAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.RepoUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.buildRuleSetService.Delete(r.Context(), nil, repoID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+)
+
+var (
+	// BuildTimelinePollInterval is how often a build's timeline stream checks for new events and log data.
+	// Events are read from the database rather than from this server's memory, so that the stream includes
+	// events published via every server.
+	BuildTimelinePollInterval = time.Second
+	// buildTimelineKeepAliveInterval is how often a comment is sent on an idle timeline stream, so that
+	// proxies don't close the connection.
+	buildTimelineKeepAliveInterval = 15 * time.Second
+	// buildTimelineEventBatchSize is the maximum number of events to read from the database at once.
+	buildTimelineEventBatchSize = 1000
+)
+
+// GetTimeline streams a build's progress as server-sent events (see documents.BuildTimelineEventEvent and
+// friends), so that the build page can update live without polling the build's events and jobs.
+// All the build's events are sent first, then new events as they are published, until the build finishes.
+// Clients can resume from a specific event using the 'last' query parameter or the Last-Event-ID header.
+func (a *BuildAPI) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	lastStr := r.Header.Get("Last-Event-ID")
+	if lastStr == "" {
+		lastStr = r.URL.Query().Get("last")
+	}
+	lastEventNumber := models.EventNumber(0)
+	if lastStr != "" {
+		lastInt, err := strconv.ParseUint(lastStr, 10, 64)
+		if err != nil {
+			a.Error(w, r, fmt.Errorf("error parsing last event number: %w", err))
+			return
+		}
+		lastEventNumber = models.EventNumber(lastInt)
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		a.Error(w, r, fmt.Errorf("error response body does not support http.Flusher"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	timeline := &buildTimeline{
+		api:             a,
+		rctx:            routes.RequestCtx(r),
+		w:               w,
+		buildID:         buildID,
+		lastEventNumber: lastEventNumber,
+		seenJobs:        make(map[models.JobID]bool),
+		stepLogs:        make(map[models.StepID]*timelineStepLog),
+	}
+	err = timeline.run(r.Context(), flusher)
+	if err != nil && r.Context().Err() == nil {
+		a.Warnf("Closing timeline stream for build %s: %v", buildID, err)
+	}
+}
+
+// timelineStepLog tracks the log of a running step, to send updates when the log grows.
+type timelineStepLog struct {
+	stepID   models.StepID
+	logID    models.LogDescriptorID
+	size     int64
+	finished bool
+}
+
+// buildTimeline holds the state of a single client's timeline stream.
+type buildTimeline struct {
+	api             *BuildAPI
+	rctx            routes.RequestContext
+	w               http.ResponseWriter
+	buildID         models.BuildID
+	lastEventNumber models.EventNumber
+	seenJobs        map[models.JobID]bool
+	stepLogs        map[models.StepID]*timelineStepLog
+	buildFinished   bool
+	lastWriteAt     time.Time
+}
+
+func (t *buildTimeline) run(ctx context.Context, flusher http.Flusher) error {
+	ticker := time.NewTicker(BuildTimelinePollInterval)
+	defer ticker.Stop()
+	t.lastWriteAt = time.Now()
+	for {
+		err := t.sendNewEvents(ctx)
+		if err != nil {
+			return err
+		}
+		err = t.sendLogSizes(ctx)
+		if err != nil {
+			return err
+		}
+		if t.buildFinished {
+			err = t.write(documents.BuildTimelineEndEvent, "", struct{}{})
+			flusher.Flush()
+			return err
+		}
+		if time.Since(t.lastWriteAt) >= buildTimelineKeepAliveInterval {
+			_, err = fmt.Fprint(t.w, ": keep-alive\n\n")
+			if err != nil {
+				return err
+			}
+			t.lastWriteAt = time.Now()
+		}
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendNewEvents sends all events published since the last event sent, preceded by a job_added message
+// for each job not seen before.
+func (t *buildTimeline) sendNewEvents(ctx context.Context) error {
+	for {
+		events, err := t.api.eventService.FetchEvents(ctx, nil, t.buildID, t.lastEventNumber, buildTimelineEventBatchSize)
+		if err != nil {
+			return fmt.Errorf("error fetching events: %w", err)
+		}
+		for _, event := range events {
+			err = t.sendEvent(ctx, event)
+			if err != nil {
+				return err
+			}
+			t.lastEventNumber = event.SequenceNumber
+		}
+		if len(events) < buildTimelineEventBatchSize {
+			return nil
+		}
+	}
+}
+
+func (t *buildTimeline) sendEvent(ctx context.Context, event *models.Event) error {
+	status := models.WorkflowStatus(event.Payload)
+	switch event.Type {
+	case models.JobStatusChangedEvent:
+		jobID := models.JobIDFromResourceID(event.ResourceID)
+		if !t.seenJobs[jobID] {
+			job, err := t.api.jobService.Read(ctx, nil, jobID)
+			if err != nil {
+				return fmt.Errorf("error reading job: %w", err)
+			}
+			err = t.write(documents.BuildTimelineJobAddedEvent, "", &documents.BuildTimelineJobAdded{Job: documents.MakeJob(t.rctx, job)})
+			if err != nil {
+				return err
+			}
+			t.seenJobs[jobID] = true
+		}
+	case models.StepStatusChangedEvent:
+		stepID := models.StepIDFromResourceID(event.ResourceID)
+		stepLog, ok := t.stepLogs[stepID]
+		if status == models.WorkflowStatusRunning && !ok {
+			step, err := t.api.stepService.Read(ctx, nil, stepID)
+			if err != nil {
+				return fmt.Errorf("error reading step: %w", err)
+			}
+			t.stepLogs[stepID] = &timelineStepLog{stepID: stepID, logID: step.LogDescriptorID}
+		} else if status.HasFinished() && ok {
+			// Send the final size of the log before forgetting about it
+			stepLog.finished = true
+		}
+	case models.BuildStatusChangedEvent:
+		t.buildFinished = status.HasFinished()
+	}
+	return t.write(documents.BuildTimelineEventEvent, event.SequenceNumber.String(), documents.MakeEvent(t.rctx, event))
+}
+
+// sendLogSizes sends the size of the log of each running step whose log has grown.
+func (t *buildTimeline) sendLogSizes(ctx context.Context) error {
+	for stepID, stepLog := range t.stepLogs {
+		size, err := t.api.logService.ReadSize(ctx, nil, stepLog.logID)
+		if err != nil {
+			return fmt.Errorf("error reading log size: %w", err)
+		}
+		if size != stepLog.size {
+			stepLog.size = size
+			err = t.write(documents.BuildTimelineLogSizeEvent, "", &documents.BuildTimelineLogSize{
+				ResourceID:      stepID.ResourceID,
+				LogDescriptorID: stepLog.logID,
+				SizeBytes:       size,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if stepLog.finished {
+			delete(t.stepLogs, stepID)
+		}
+	}
+	return nil
+}
+
+// write sends a single server-sent event with the JSON encoding of data.
+func (t *buildTimeline) write(name string, id string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding %s event: %w", name, err)
+	}
+	var message strings.Builder
+	if id != "" {
+		message.WriteString("id: " + id + "\n")
+	}
+	message.WriteString("event: " + name + "\n")
+	message.WriteString("data: " + string(encoded) + "\n\n")
+	_, err = fmt.Fprint(t.w, message.String())
+	if err != nil {
+		return err
+	}
+	t.lastWriteAt = time.Now()
+	return nil
+}
@@ -13,15 +13,21 @@ import (
 	"github.com/buildbeaver/buildbeaver/common/models/search"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
 	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
 type BuildAPI struct {
-	buildService services.BuildService
-	queueService services.QueueService
-	eventService services.EventService
-	commitStore  store.CommitStore
+	buildService           services.BuildService
+	queueService           services.QueueService
+	eventService           services.EventService
+	jobService             services.JobService
+	stepService            services.StepService
+	logService             services.LogService
+	buildComparisonService services.BuildComparisonService
+	commitStore            store.CommitStore
 	*APIBase
 }
 
@@ -30,15 +36,23 @@ func NewBuildAPI(
 	buildService services.BuildService,
 	queueService services.QueueService,
 	eventService services.EventService,
+	jobService services.JobService,
+	stepService services.StepService,
+	logService services.LogService,
+	buildComparisonService services.BuildComparisonService,
 	commitStore store.CommitStore,
 	resourceLinker *routes.ResourceLinker,
 	logFactory logger.LogFactory) *BuildAPI {
 	return &BuildAPI{
-		buildService: buildService,
-		queueService: queueService,
-		eventService: eventService,
-		commitStore:  commitStore,
-		APIBase:      NewAPIBase(authorizationService, resourceLinker, logFactory("BuildAPI")),
+		buildService:           buildService,
+		queueService:           queueService,
+		eventService:           eventService,
+		jobService:             jobService,
+		stepService:            stepService,
+		logService:             logService,
+		buildComparisonService: buildComparisonService,
+		commitStore:            commitStore,
+		APIBase:                NewAPIBase(authorizationService, resourceLinker, logFactory("BuildAPI")),
 	}
 }
 
@@ -57,6 +71,68 @@ func (a *BuildAPI) Get(w http.ResponseWriter, r *http.Request) {
 	a.GotResource(w, r, res)
 }
 
+// GetDAG returns the complete graph of jobs in a build, including the jobs any of them were indirected to and
+// placeholders for jobs in other workflows that have been depended on but not added yet, so the build can be
+// drawn from a single request. Jobs can only be indirected to jobs in the same repo, so can be read by anyone
+// who can read the build.
+func (a *BuildAPI) GetDAG(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	queuedBuild, err := a.queueService.ReadQueuedBuild(r.Context(), nil, buildID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	indirectJobs := make(map[models.JobID]*dto.JobGraph)
+	for _, job := range queuedBuild.Jobs {
+		if job.IndirectToJobID.IsZero() {
+			continue
+		}
+		if _, ok := indirectJobs[job.IndirectToJobID]; ok {
+			continue
+		}
+		indirectJob, err := a.queueService.ReadJobGraph(r.Context(), nil, job.IndirectToJobID)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				continue // the job's build has been deleted
+			}
+			a.Error(w, r, err)
+			return
+		}
+		indirectJobs[job.IndirectToJobID] = indirectJob
+	}
+	res := documents.MakeBuildDAG(routes.RequestCtx(r), queuedBuild, indirectJobs)
+	a.GotResource(w, r, res)
+}
+
+// Compare returns what changed between another build of the same repo, specified by the 'base' query
+// parameter, and the build.
+func (a *BuildAPI) Compare(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	id, err := models.ParseResourceID(r.URL.Query().Get("base"))
+	if err != nil || id.Kind() != models.BuildResourceKind {
+		a.Error(w, r, gerror.NewErrValidationFailed("The 'base' query parameter must be set to the ID of the build to compare with"))
+		return
+	}
+	// The base build must be in the same repo, so anyone who can read the build can read the base build
+	comparison, err := a.buildComparisonService.Compare(r.Context(), nil, models.BuildIDFromResourceID(id), buildID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeBuildComparison(routes.RequestCtx(r), comparison)
+	a.GotResource(w, r, res)
+}
+
+// Create queues a new build in a repo, either of the same commit and ref as a previous build or of a ref or commit
+// SHA that need not have been built before.
 func (a *BuildAPI) Create(w http.ResponseWriter, r *http.Request) {
 	repoID, err := a.AuthorizedRepoID(r, models.BuildCreateOperation)
 	if err != nil {
@@ -69,27 +145,80 @@ func (a *BuildAPI) Create(w http.ResponseWriter, r *http.Request) {
 		a.Error(w, r, fmt.Errorf("error parsing request: %w", err))
 		return
 	}
-	// Make sure the user is actually allowed to read the build they nominated
-	err = a.Authorize(r, models.BuildReadOperation, req.FromBuildID.ResourceID)
+	// Builds requested via the API always run, even if the commit message would normally cause them to be skipped
+	opts := &models.BuildOptions{}
+	if req.Opts != nil {
+		opts = req.Opts
+	}
+	opts.IgnoreSkipMarkers = true
+	var newBuild *dto.BuildGraph
+	if req.FromBuildID != nil {
+		newBuild, err = a.rebuild(r, repoID, *req.FromBuildID, opts)
+	} else {
+		newBuild, err = a.queueService.EnqueueBuildFromRef(r.Context(), repoID, req.Ref, req.SHA, opts)
+	}
 	if err != nil {
 		a.Error(w, r, err)
 		return
 	}
-	build, err := a.buildService.Read(r.Context(), nil, *req.FromBuildID)
+	queuedBuild, err := a.queueService.ReadQueuedBuild(r.Context(), nil, newBuild.ID)
 	if err != nil {
 		a.Error(w, r, err)
 		return
 	}
+	res := documents.MakeBuildGraph(routes.RequestCtx(r), queuedBuild)
+	a.CreatedResource(w, r, res, nil)
+}
+
+// rebuild enqueues a new build of the same commit and ref as the specified build, which must be in the repo.
+func (a *BuildAPI) rebuild(r *http.Request, repoID models.RepoID, fromBuildID models.BuildID, opts *models.BuildOptions) (*dto.BuildGraph, error) {
+	// Make sure the user is actually allowed to read the build they nominated
+	err := a.Authorize(r, models.BuildReadOperation, fromBuildID.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	build, err := a.buildService.Read(r.Context(), nil, fromBuildID)
+	if err != nil {
+		return nil, err
+	}
 	if !repoID.Equal(build.RepoID.ResourceID) {
-		a.Error(w, r, gerror.NewErrValidationFailed("Cannot create a build from a different repo"))
-		return
+		return nil, gerror.NewErrValidationFailed("Cannot create a build from a different repo")
 	}
 	commit, err := a.commitStore.Read(r.Context(), nil, build.CommitID)
+	if err != nil {
+		return nil, err
+	}
+	return a.queueService.EnqueueBuildFromCommit(r.Context(), nil, commit, build.Ref, opts)
+}
+
+// Clone creates a new build from the jobs recorded for a finished build, with modified environment and options.
+func (a *BuildAPI) Clone(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CloneBuildRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, fmt.Errorf("error parsing request: %w", err))
+		return
+	}
+	build, err := a.buildService.Read(r.Context(), nil, buildID)
 	if err != nil {
 		a.Error(w, r, err)
 		return
 	}
-	newBuild, err := a.queueService.EnqueueBuildFromCommit(r.Context(), nil, commit, build.Ref, req.Opts)
+	// Make sure the user is actually allowed to create builds in the repo
+	err = a.Authorize(r, models.BuildCreateOperation, build.RepoID.ResourceID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	newBuild, err := a.queueService.CloneBuild(r.Context(), nil, buildID, dto.CloneBuild{
+		Environment: req.Environment,
+		Opts:        req.Opts,
+	})
 	if err != nil {
 		a.Error(w, r, err)
 		return
@@ -103,6 +232,68 @@ func (a *BuildAPI) Create(w http.ResponseWriter, r *http.Request) {
 	a.CreatedResource(w, r, res, nil)
 }
 
+// Patch changes the priority of a build that has not finished, so its remaining queued jobs are handed to
+// runners ahead of (or behind) jobs from other builds.
+func (a *BuildAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildPrioritizeOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchBuildRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, fmt.Errorf("error parsing request: %w", err))
+		return
+	}
+	build, err := a.queueService.UpdateBuildPriority(r.Context(), nil, buildID, dto.UpdateBuildPriority{
+		Priority: *req.Priority,
+		ETag:     a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeBuild(routes.RequestCtx(r), build)
+	a.UpdatedResource(w, r, res, nil)
+}
+
+// ValidateConfig checks a build configuration for problems as though it had been committed to the repo,
+// without running a build. Problems with the configuration are reported in the response rather than as an error.
+func (a *BuildAPI) ValidateConfig(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.RepoReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.ValidateBuildConfigRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, fmt.Errorf("error parsing request: %w", err))
+		return
+	}
+	buildDef, err := a.queueService.ValidateBuildConfig(r.Context(), nil, repoID, []byte(req.Config), req.ConfigType, req.Ref)
+	if err != nil && !gerror.IsValidationFailed(err) {
+		a.Error(w, r, err)
+		return
+	}
+	res := &documents.BuildConfigValidation{Valid: err == nil}
+	if err != nil {
+		res.Error = &documents.BuildConfigError{Message: err.Error()}
+		if parseErr := parser.AsParseError(err); parseErr != nil {
+			res.Error.Message = parseErr.Err.Error()
+			res.Error.File = parseErr.File
+			res.Error.Line = parseErr.Line
+			res.Error.Column = parseErr.Column
+			res.Error.Path = parseErr.Path
+		}
+	} else {
+		res.Version = buildDef.Version
+		res.Warnings = buildDef.Warnings
+	}
+	a.JSON(w, r, res)
+}
+
 func (a *BuildAPI) List(w http.ResponseWriter, r *http.Request) {
 	repoID, err := a.RepoID(r)
 	if err != nil {
@@ -118,7 +309,12 @@ func (a *BuildAPI) List(w http.ResponseWriter, r *http.Request) {
 	// The build list is embedded under a repo in the API, so this search
 	// must always be limited to repos for that legal entity.
 	req.Query = search.NewBuildQueryBuilder(req.Query).WhereRepoID(search.Equal, repoID).Compile()
-	builds, cursor, err := a.buildService.UniversalSearch(r.Context(), nil, a.MustAuthenticatedIdentityID(r), req.Query)
+	identityID, err := a.ScopedIdentityID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	builds, cursor, err := a.buildService.UniversalSearch(r.Context(), nil, identityID, req.Query)
 	if err != nil {
 		a.Error(w, r, err)
 		return
@@ -151,7 +347,12 @@ func (a *BuildAPI) Summary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	builds, err := a.buildService.Summary(r.Context(), nil, legalEntityID, a.MustAuthenticatedIdentityID(r))
+	identityID, err := a.ScopedIdentityID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	builds, err := a.buildService.Summary(r.Context(), nil, legalEntityID, identityID)
 	if err != nil {
 		a.Error(w, r, err)
 		return
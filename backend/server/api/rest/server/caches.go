@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+type CacheAPI struct {
+	cacheService services.CacheService
+	*APIBase
+}
+
+func NewCacheAPI(
+	cacheService services.CacheService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *CacheAPI {
+	return &CacheAPI{
+		cacheService: cacheService,
+		APIBase:      NewAPIBase(authorizationService, resourceLinker, logFactory("CacheAPI")),
+	}
+}
+
+// Find returns the cache entry a job should restore for the named cache, given the key in the query string.
+func (a *CacheAPI) Find(w http.ResponseWriter, r *http.Request) {
+	jobID, err := a.AuthorizedJobID(r, models.ArtifactReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	name, err := a.cacheName(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	cacheEntry, err := a.cacheService.Find(r.Context(), nil, jobID, name, r.URL.Query().Get("key"))
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			// A cache miss is expected the first time a job runs, so don't fill the log with it
+			a.ErrorNotLogged(w, r, err)
+		} else {
+			a.Error(w, r, err)
+		}
+		return
+	}
+	a.GotResource(w, r, documents.MakeCacheEntry(routes.RequestCtx(r), jobID, cacheEntry))
+}
+
+// Save saves a new entry for the named cache, with the key in the query string and the tarball in the request body.
+func (a *CacheAPI) Save(w http.ResponseWriter, r *http.Request) {
+	jobID, err := a.AuthorizedJobID(r, models.ArtifactCreateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	name, err := a.cacheName(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	cacheEntry, err := a.cacheService.Save(r.Context(), jobID, name, r.URL.Query().Get("key"), r.Body)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.CreatedResource(w, r, documents.MakeCacheEntry(routes.RequestCtx(r), jobID, cacheEntry), nil)
+}
+
+func (a *CacheAPI) GetEntry(w http.ResponseWriter, r *http.Request) {
+	jobID, err := a.AuthorizedJobID(r, models.ArtifactReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	cacheEntryID, err := a.cacheEntryID(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	cacheEntry, err := a.cacheService.ReadForJob(r.Context(), nil, jobID, cacheEntryID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.GotResource(w, r, documents.MakeCacheEntry(routes.RequestCtx(r), jobID, cacheEntry))
+}
+
+func (a *CacheAPI) GetData(w http.ResponseWriter, r *http.Request) {
+	jobID, err := a.AuthorizedJobID(r, models.ArtifactReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	cacheEntryID, err := a.cacheEntryID(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	reader, err := a.cacheService.GetCacheEntryData(r.Context(), jobID, cacheEntryID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.tar.gz", cacheEntryID))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, reader)
+	if err != nil {
+		a.Errorf("error writing cache entry data to response body: %v", err)
+	}
+}
+
+func (a *CacheAPI) cacheName(r *http.Request) (models.ResourceName, error) {
+	name := models.ResourceName(chi.URLParam(r, "cache_name"))
+	err := name.Validate()
+	if err != nil {
+		return "", gerror.NewErrNotFound("Not Found").Wrap(err)
+	}
+	return name, nil
+}
+
+func (a *CacheAPI) cacheEntryID(r *http.Request) (models.CacheEntryID, error) {
+	id, err := parseURLParamResourceID(r, "cache_entry_id", models.CacheEntryResourceKind)
+	if err != nil {
+		return models.CacheEntryID{}, err
+	}
+	return models.CacheEntryIDFromResourceID(id), nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+type CoverageAPI struct {
+	coverageService services.CoverageService
+	*APIBase
+}
+
+func NewCoverageAPI(
+	coverageService services.CoverageService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *CoverageAPI {
+	return &CoverageAPI{
+		coverageService: coverageService,
+		APIBase:         NewAPIBase(authorizationService, resourceLinker, logFactory("CoverageAPI")),
+	}
+}
+
+// GetBuildCoverage returns the combined code coverage for a build, including the change compared to the target branch.
+func (a *CoverageAPI) GetBuildCoverage(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	buildCoverage, err := a.coverageService.ReadBuildCoverage(r.Context(), nil, buildID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.GotResource(w, r, documents.MakeBuildCoverage(routes.RequestCtx(r), buildCoverage))
+}
+
+// ListReports returns the coverage reports uploaded by a build.
+func (a *CoverageAPI) ListReports(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	reports, cursor, err := a.coverageService.ListReports(r.Context(), nil, buildID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeCoverageReports(routes.RequestCtx(r), reports)
+	res := documents.NewPaginatedResponse(models.CoverageReportResourceKind, routes.MakeCoverageReportsLink(routes.RequestCtx(r), buildID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListRepoBuildCoverages returns the coverage for builds in a repo, newest first, to show coverage trends over time.
+func (a *CoverageAPI) ListRepoBuildCoverages(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	buildCoverages, cursor, err := a.coverageService.ListBuildCoverages(r.Context(), nil, repoID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeBuildCoverages(routes.RequestCtx(r), buildCoverages)
+	res := documents.NewPaginatedResponse(models.BuildCoverageResourceKind, routes.MakeRepoBuildCoveragesLink(routes.RequestCtx(r), repoID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+type CustomStatusAPI struct {
+	customStatusService services.CustomStatusService
+	*APIBase
+}
+
+func NewCustomStatusAPI(
+	customStatusService services.CustomStatusService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *CustomStatusAPI {
+	return &CustomStatusAPI{
+		customStatusService: customStatusService,
+		APIBase:             NewAPIBase(authorizationService, resourceLinker, logFactory("CustomStatusAPI")),
+	}
+}
+
+// Publish creates or replaces a named custom status for a build, and relays it to the SCM.
+func (a *CustomStatusAPI) Publish(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.CustomStatusCreateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PublishCustomStatusRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	customStatus, err := a.customStatusService.Publish(r.Context(), nil, dto.PublishCustomStatus{
+		BuildID:     buildID,
+		Name:        req.Name,
+		State:       req.State,
+		Description: req.Description,
+		TargetURL:   req.TargetURL,
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeCustomStatus(routes.RequestCtx(r), customStatus)
+	a.JSON(w, r, res)
+}
+
+// List returns the custom statuses published to a build.
+func (a *CustomStatusAPI) List(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	customStatuses, cursor, err := a.customStatusService.ListByBuildID(r.Context(), nil, buildID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeCustomStatuses(routes.RequestCtx(r), customStatuses)
+	res := documents.NewPaginatedResponse(models.CustomStatusResourceKind, routes.MakeCustomStatusesLink(routes.RequestCtx(r), buildID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
@@ -15,12 +15,14 @@ import (
 type DynamicJobAPIDynamic interface {
 	Ping(w http.ResponseWriter, r *http.Request)
 	CreateJobs(w http.ResponseWriter, r *http.Request)
+	UpdateJobs(w http.ResponseWriter, r *http.Request)
 }
 
 // ArtifactAPIDynamic is the subset of methods of ArtifactAPI that are referenced by the dynamic job API router.
 type ArtifactAPIDynamic interface {
 	Get(w http.ResponseWriter, r *http.Request)
 	GetData(w http.ResponseWriter, r *http.Request)
+	GetVerification(w http.ResponseWriter, r *http.Request)
 	List(w http.ResponseWriter, r *http.Request)
 }
 
@@ -34,7 +36,11 @@ func DynamicJobAPIRouterFactory(
 	job *JobAPI,
 	artifact ArtifactAPIDynamic,
 	log *LogAPI,
+	customStatus *CustomStatusAPI,
+	toolchain *ToolchainAPI,
+	oidc *OIDCAPI,
 	authenticationService services.AuthenticationService,
+	eventFetchRateLimiter func(next http.Handler) http.Handler,
 	logFactory logger.LogFactory,
 ) func(r chi.Router) {
 	// Assume that middleware.DefaultLogger has already been set by the caller
@@ -56,16 +62,24 @@ func DynamicJobAPIRouterFactory(
 				r.Route("/artifacts", func(r chi.Router) {
 					r.Get("/", artifact.List)
 				})
-				r.Post("/jobs", dynamicJobAPI.CreateJobs) // only available to dynamic builds
-				r.Get("/events", build.GetEvents)
+				r.Post("/jobs", dynamicJobAPI.CreateJobs)  // only available to dynamic builds
+				r.Patch("/jobs", dynamicJobAPI.UpdateJobs) // only available to dynamic builds
+				r.Route("/custom-statuses", func(r chi.Router) {
+					r.Get("/", customStatus.List)
+					r.Post("/", customStatus.Publish)
+				})
+				r.Post("/toolchains", toolchain.Register)
+				r.With(eventFetchRateLimiter).Get("/events", build.GetEvents)
 			})
 			r.Route("/jobs/{job_id}", func(r chi.Router) {
 				r.Get("/", job.Get)
 				r.Get("/graph", job.GetGraph)
+				r.Post("/oidc-token", oidc.IssueToken)
 			})
 			r.Route("/artifacts/{artifact_id}", func(r chi.Router) {
 				r.Get("/", artifact.Get)
 				r.Get("/data", artifact.GetData)
+				r.Get("/verification", artifact.GetVerification)
 			})
 			r.Route("/logs/{log_descriptor_id}", func(r chi.Router) {
 				r.Get("/", log.Get)
@@ -55,18 +55,7 @@ func (a *DynamicJobAPI) CreateAndReturnJobs(w http.ResponseWriter, r *http.Reque
 		return nil
 	}
 
-	// Determine the content type, which must be one of the types supported by our custom parser.
-	// Note that render.ContentTypeForm (i.e. HTML form data) is not supported by our parser.
-	var configType models.ConfigType
-	switch render.GetRequestContentType(r) {
-	case render.ContentTypeJSON:
-		configType = models.ConfigTypeJSON
-	default:
-		a.Error(w, r, gerror.NewErrValidationFailed(fmt.Sprintf("error: unable to decode request with content type %s", r.Header.Get("Content-Type"))))
-		return nil
-	}
-
-	configBytes, err := io.ReadAll(r.Body)
+	configBytes, configType, err := a.readJobConfig(r)
 	if err != nil {
 		a.Error(w, r, err)
 		return nil
@@ -85,3 +74,60 @@ func (a *DynamicJobAPI) CreateAndReturnJobs(w http.ResponseWriter, r *http.Reque
 
 	return newJobList
 }
+
+// UpdateJobs updates a set of jobs that were previously added to the build and have not yet been picked up
+// by a runner.
+func (a *DynamicJobAPI) UpdateJobs(w http.ResponseWriter, r *http.Request) {
+	a.UpdateAndReturnJobs(w, r)
+}
+
+// UpdateAndReturnJobs updates a set of jobs that were previously added to the build and have not yet been
+// picked up by a runner. Each job must be supplied with its full definition; only the job's environment
+// variables and dependencies can be changed, and new steps can be added.
+// It returns the job graphs for the updated jobs both in the HTTP response and as an object.
+func (a *DynamicJobAPI) UpdateAndReturnJobs(w http.ResponseWriter, r *http.Request) []*documents.JobGraph {
+	a.Tracef("UpdateJobs called (dynamic build)")
+	buildID, err := a.AuthorizedBuildID(r, models.JobUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return nil
+	}
+
+	configBytes, configType, err := a.readJobConfig(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return nil
+	}
+
+	_, updatedJobs, err := a.queueService.UpdateConfigInBuild(r.Context(), nil, buildID, configBytes, configType)
+	if err != nil {
+		a.Error(w, r, err)
+		return nil
+	}
+	a.Infof("Updated %d dynamic jobs from new configuration for build '%s'", len(updatedJobs), buildID)
+
+	// Return a list of the updated jobs
+	updatedJobList := documents.MakeJobGraphs(routes.RequestCtx(r), updatedJobs)
+	a.JSON(w, r, updatedJobList)
+
+	return updatedJobList
+}
+
+// readJobConfig reads the job definitions submitted in the body of a request, and determines their config type.
+func (a *DynamicJobAPI) readJobConfig(r *http.Request) ([]byte, models.ConfigType, error) {
+	// Determine the content type, which must be one of the types supported by our custom parser.
+	// Note that render.ContentTypeForm (i.e. HTML form data) is not supported by our parser.
+	var configType models.ConfigType
+	switch render.GetRequestContentType(r) {
+	case render.ContentTypeJSON:
+		configType = models.ConfigTypeJSON
+	default:
+		return nil, "", gerror.NewErrValidationFailed(fmt.Sprintf("error: unable to decode request with content type %s", r.Header.Get("Content-Type")))
+	}
+
+	configBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return configBytes, configType, nil
+}
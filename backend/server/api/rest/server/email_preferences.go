@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// EmailPreferenceAPI manages the email notification preference for a user. Each user has exactly one
+// preference, addressed via their legal entity, and access is controlled by the operations granted on
+// the legal entity.
+type EmailPreferenceAPI struct {
+	emailService services.EmailService
+	*APIBase
+}
+
+func NewEmailPreferenceAPI(
+	emailService services.EmailService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *EmailPreferenceAPI {
+	return &EmailPreferenceAPI{
+		emailService: emailService,
+		APIBase:      NewAPIBase(authorizationService, resourceLinker, logFactory("EmailPreferenceAPI")),
+	}
+}
+
+func (a *EmailPreferenceAPI) Get(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.LegalEntityReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	preference, err := a.emailService.ReadPreference(r.Context(), nil, legalEntityID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeEmailPreference(routes.RequestCtx(r), preference)
+	a.GotResource(w, r, res)
+}
+
+func (a *EmailPreferenceAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.LegalEntityUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchEmailPreferenceRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	preference, err := a.emailService.UpdatePreference(r.Context(), nil, legalEntityID, dto.UpdateEmailPreference{
+		Enabled:      req.Enabled,
+		OnlyMyBuilds: req.OnlyMyBuilds,
+		OnlyFailures: req.OnlyFailures,
+		Digest:       req.Digest,
+		ETag:         a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeEmailPreference(routes.RequestCtx(r), preference)
+	a.UpdatedResource(w, r, res, nil)
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/render"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	graphqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/graphql"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// graphQLMaxRequestBytes is the maximum size of a GraphQL request body.
+	graphQLMaxRequestBytes = 1024 * 1024
+	// graphQLMaxDepth is the maximum depth that fields can be nested in a GraphQL query, to stop a single
+	// query from walking arbitrarily far through the build graph (e.g. job -> build -> jobs -> build...).
+	// Standard introspection queries nest type references around 12 deep, so they must fit within this.
+	graphQLMaxDepth = 15
+	// graphQLMaxBatchSize is the maximum number of resources read from the database in one query when
+	// resolving a level of a GraphQL query. Resolvers can only be batched together if they run at the
+	// same time, so this is also the maximum number of resolvers run in parallel for a query.
+	graphQLMaxBatchSize = 500
+)
+
+// GraphQLAPI serves a read-only GraphQL API over builds, jobs, steps, logs, artifacts, runners and repos.
+// It lets a client such as the frontend fetch a whole build graph in one request, and supports
+// introspection so that standard GraphQL tooling can explore the schema. Resources at each level of a
+// query are read in batches, so the number of database queries depends on the depth of the query rather
+// than the number of resources returned.
+type GraphQLAPI struct {
+	buildService    services.BuildService
+	jobService      services.JobService
+	stepService     services.StepService
+	logService      services.LogService
+	artifactService services.ArtifactService
+	runnerService   services.RunnerService
+	repoService     services.RepoService
+	schema          *graphqlgo.Schema
+	*APIBase
+}
+
+func NewGraphQLAPI(
+	buildService services.BuildService,
+	jobService services.JobService,
+	stepService services.StepService,
+	logService services.LogService,
+	artifactService services.ArtifactService,
+	runnerService services.RunnerService,
+	repoService services.RepoService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *GraphQLAPI {
+	a := &GraphQLAPI{
+		buildService:    buildService,
+		jobService:      jobService,
+		stepService:     stepService,
+		logService:      logService,
+		artifactService: artifactService,
+		runnerService:   runnerService,
+		repoService:     repoService,
+		APIBase:         NewAPIBase(authorizationService, resourceLinker, logFactory("GraphQLAPI")),
+	}
+	schema, err := graphqlgo.ParseSchema(graphQLSchema, &graphQLRootResolver{},
+		graphqlgo.MaxDepth(graphQLMaxDepth),
+		graphqlgo.MaxParallelism(graphQLMaxBatchSize),
+		graphqlgo.Logger(graphQLPanicHandler{a.Log}),
+		graphqlgo.PanicHandler(graphQLPanicHandler{a.Log}))
+	if err != nil {
+		a.Panicf("error creating GraphQL schema: %v", err)
+	}
+	a.schema = schema
+	return a
+}
+
+// Query executes a GraphQL query. As is usual for GraphQL the response status is 200 OK even if the
+// query fails, with the errors described in the response document, unless the request itself is invalid.
+func (a *GraphQLAPI) Query(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, graphQLMaxRequestBytes)
+	req := &documents.GraphQLRequest{}
+	err := render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, fmt.Errorf("error parsing request: %w", err))
+		return
+	}
+	ctx := context.WithValue(r.Context(), graphQLRequestContextKey{}, newGraphQLRequest(a, r))
+	response := a.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, queryErr := range response.Errors {
+		if queryErr.ResolverError != nil {
+			queryErr.Message = a.formatError(queryErr.ResolverError)
+		}
+	}
+	a.JSON(w, r, response)
+}
+
+// GetSchema returns the GraphQL schema in schema definition language, for use by client tooling.
+func (a *GraphQLAPI) GetSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(graphQLSchema))
+}
+
+// formatError sanitizes an error returned while resolving a field for display to the client, in the same
+// way as errors are sanitized by the REST API.
+func (a *GraphQLAPI) formatError(err error) string {
+	var gErr gerror.Error
+	if !errors.As(err, &gErr) || gErr.Audience() != gerror.AudienceExternal {
+		a.Warnf("Error resolving GraphQL field: %v", err)
+		gErr = gerror.NewErrInternal()
+	}
+	return gErr.Message()
+}
+
+// graphQLPanicHandler logs panics while resolving GraphQL fields, and reports them to the client without
+// revealing any details.
+type graphQLPanicHandler struct {
+	logger.Log
+}
+
+func (h graphQLPanicHandler) LogPanic(ctx context.Context, value interface{}) {
+	h.Errorf("Panic resolving GraphQL field: %v", value)
+}
+
+func (h graphQLPanicHandler) MakePanicError(ctx context.Context, value interface{}) *graphqlerrors.QueryError {
+	return graphqlerrors.Errorf("%s", gerror.NewErrInternal().Message())
+}
+
+type graphQLRequestContextKey struct{}
+
+// graphQLRequest holds the state for executing a single GraphQL query: the loaders that batch and cache
+// reads for the query, and the results of the authorization checks made so far.
+type graphQLRequest struct {
+	api              *GraphQLAPI
+	r                *http.Request
+	authorizationsMu sync.Mutex
+	authorizations   map[string]error
+	builds           *graphql.Loader[models.BuildID, *models.Build]
+	repos            *graphql.Loader[models.RepoID, *models.Repo]
+	runners          *graphql.Loader[models.RunnerID, *models.Runner]
+	logs             *graphql.Loader[models.LogDescriptorID, *models.LogDescriptor]
+	jobsByBuildID    *graphql.Loader[models.BuildID, []*models.Job]
+	stepsByJobID     *graphql.Loader[models.JobID, []*models.Step]
+	artifactsByJobID *graphql.Loader[models.JobID, []*models.Artifact]
+}
+
+func newGraphQLRequest(a *GraphQLAPI, r *http.Request) *graphQLRequest {
+	ctx := r.Context()
+	return &graphQLRequest{
+		api:            a,
+		r:              r,
+		authorizations: make(map[string]error),
+		builds: newGraphQLLoader(ctx, a.buildService.ListByIDs, func(build *models.Build) models.BuildID {
+			return build.ID
+		}),
+		repos: newGraphQLLoader(ctx, a.repoService.ListByIDs, func(repo *models.Repo) models.RepoID {
+			return repo.ID
+		}),
+		runners: newGraphQLLoader(ctx, a.runnerService.ListByIDs, func(runner *models.Runner) models.RunnerID {
+			return runner.ID
+		}),
+		logs: newGraphQLLoader(ctx, a.logService.ListByIDs, func(log *models.LogDescriptor) models.LogDescriptorID {
+			return log.ID
+		}),
+		jobsByBuildID: newGraphQLListLoader(ctx, a.jobService.ListByBuildIDs, func(job *models.Job) models.BuildID {
+			return job.BuildID
+		}),
+		stepsByJobID: newGraphQLListLoader(ctx, a.stepService.ListByJobIDs, func(step *models.Step) models.JobID {
+			return step.JobID
+		}),
+		artifactsByJobID: newGraphQLListLoader(ctx, a.artifactService.ListByJobIDs, func(artifact *models.Artifact) models.JobID {
+			return artifact.JobID
+		}),
+	}
+}
+
+// graphQLRequestFrom returns the state for the GraphQL query a field is being resolved for.
+func graphQLRequestFrom(ctx context.Context) *graphQLRequest {
+	return ctx.Value(graphQLRequestContextKey{}).(*graphQLRequest)
+}
+
+// authorize checks the authenticated identity is allowed to perform operation on a resource, remembering
+// the result so that each resource is only checked once per query.
+func (q *graphQLRequest) authorize(operation *models.Operation, resourceID models.ResourceID) error {
+	q.authorizationsMu.Lock()
+	defer q.authorizationsMu.Unlock()
+	key := operation.String() + "/" + resourceID.String()
+	err, checked := q.authorizations[key]
+	if !checked {
+		err = q.api.Authorize(q.r, operation, resourceID)
+		q.authorizations[key] = err
+	}
+	return err
+}
+
+// newGraphQLLoader creates a loader that reads resources by ID using list, which must return the resources
+// with the specified IDs. key returns the ID of a resource.
+func newGraphQLLoader[K comparable, V any](
+	ctx context.Context,
+	list func(ctx context.Context, txOrNil *store.Tx, ids []K) ([]V, error),
+	key func(V) K,
+) *graphql.Loader[K, V] {
+	return graphql.NewLoader(func(ids []K) (map[K]V, error) {
+		resources, err := list(ctx, nil, ids)
+		if err != nil {
+			return nil, err
+		}
+		results := make(map[K]V, len(resources))
+		for _, resource := range resources {
+			results[key(resource)] = resource
+		}
+		return results, nil
+	}, graphQLMaxBatchSize, 0)
+}
+
+// newGraphQLListLoader creates a loader that reads the resources belonging to parent resources using list,
+// which must return the resources belonging to all of the specified parents. key returns the ID of the
+// parent a resource belongs to.
+func newGraphQLListLoader[K comparable, V any](
+	ctx context.Context,
+	list func(ctx context.Context, txOrNil *store.Tx, parentIDs []K) ([]V, error),
+	key func(V) K,
+) *graphql.Loader[K, []V] {
+	return graphql.NewLoader(func(parentIDs []K) (map[K][]V, error) {
+		resources, err := list(ctx, nil, parentIDs)
+		if err != nil {
+			return nil, err
+		}
+		results := make(map[K][]V, len(parentIDs))
+		for _, resource := range resources {
+			parentID := key(resource)
+			results[parentID] = append(results[parentID], resource)
+		}
+		return results, nil
+	}, graphQLMaxBatchSize, 0)
+}
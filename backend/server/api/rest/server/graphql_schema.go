@@ -0,0 +1,725 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// graphQLSchema is the schema for the GraphQL API, in schema definition language. Each type is resolved by
+// the graphQL<Type>Resolver below, which find the state for the query being executed using graphQLRequestFrom.
+//
+// Authorization follows the REST API: each root field checks the same operation as the corresponding
+// REST endpoint. Fields that lead from a build to the jobs, steps and logs within it are covered by the
+// check on the build (or job), while fields leading to repos, runners and artifacts make their own
+// checks, which are cached for the duration of the query.
+const graphQLSchema = `
+schema {
+  query: Query
+}
+
+"A timestamp in RFC 3339 format."
+scalar Time
+
+type Query {
+  "Looks up a build by ID."
+  build(id: ID!): Build
+  "Looks up a job by ID."
+  job(id: ID!): Job
+  "Looks up a step by ID."
+  step(id: ID!): Step
+  "Looks up a log by ID."
+  log(id: ID!): Log
+  "Looks up an artifact by ID."
+  artifact(id: ID!): Artifact
+  "Looks up a runner by ID."
+  runner(id: ID!): Runner
+  "Looks up a repo by ID."
+  repo(id: ID!): Repo
+}
+
+"A build of a commit in a repo, made up of jobs."
+type Build {
+  id: ID!
+  name: String!
+  ref: String!
+  status: String!
+  "The reason the build failed, if it did."
+  error: String
+  timings: WorkflowTimings!
+  createdAt: Time!
+  repo: Repo
+  jobs: [Job!]
+  log: Log
+}
+
+"A job within a build, made up of steps."
+type Job {
+  id: ID!
+  name: String!
+  workflow: String!
+  description: String!
+  stage: String!
+  status: String!
+  "The reason the job failed, if it did."
+  error: String
+  timings: WorkflowTimings!
+  createdAt: Time!
+  "The jobs that must finish before this job can run."
+  dependsOn: [JobDependency!]!
+  build: Build
+  steps: [Step!]
+  "The runner the job was handed to, if it has been."
+  runner: Runner
+  artifacts: [Artifact!]
+  log: Log
+}
+
+"A job that must finish before another job can run."
+type JobDependency {
+  workflow: String!
+  jobName: String!
+}
+
+"A step within a job."
+type Step {
+  id: ID!
+  name: String!
+  description: String!
+  status: String!
+  "The reason the step failed, if it did."
+  error: String
+  timings: WorkflowTimings!
+  createdAt: Time!
+  "The names of the steps in the same job that must finish before this step can run."
+  dependsOn: [String!]!
+  "The runner the step ran on, if it has run."
+  runner: Runner
+  log: Log
+}
+
+"A log belonging to a build, job or step."
+type Log {
+  id: ID!
+  "True if the log is complete."
+  sealed: Boolean!
+  sizeBytes: Float!
+  createdAt: Time!
+  "The time the log's data was moved to archive storage, if it has been."
+  archivedAt: Time
+  "The time the log's data was deleted by the retention policy, if it has been."
+  expiredAt: Time
+}
+
+"A file produced by a job."
+type Artifact {
+  id: ID!
+  name: String!
+  groupName: String!
+  path: String!
+  "The size of the artifact in bytes."
+  size: Float!
+  mime: String!
+  sha256: String!
+  "True if the artifact's data is complete."
+  sealed: Boolean!
+  scanStatus: String!
+  createdAt: Time!
+}
+
+"A runner that runs jobs."
+type Runner {
+  id: ID!
+  name: String!
+  softwareVersion: String!
+  operatingSystem: String!
+  architecture: String!
+  labels: [String!]!
+  enabled: Boolean!
+  online: Boolean!
+  lastSeenAt: Time
+}
+
+"A repo containing code to build."
+type Repo {
+  id: ID!
+  name: String!
+  description: String!
+  link: String!
+  httpUrl: String!
+  defaultBranch: String!
+  private: Boolean!
+  enabled: Boolean!
+}
+
+"The times a build, job or step changed status."
+type WorkflowTimings {
+  queuedAt: Time
+  submittedAt: Time
+  runningAt: Time
+  finishedAt: Time
+  canceledAt: Time
+}
+`
+
+type graphQLIDArgs struct {
+	ID graphqlgo.ID
+}
+
+type graphQLRootResolver struct{}
+
+func (r *graphQLRootResolver) Build(ctx context.Context, args graphQLIDArgs) (*graphQLBuildResolver, error) {
+	q := graphQLRequestFrom(ctx)
+	id, err := graphQLAuthorizedID(q, args.ID, models.BuildResourceKind, models.BuildReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	return graphQLBuild(q, models.BuildIDFromResourceID(id))
+}
+
+func (r *graphQLRootResolver) Job(ctx context.Context, args graphQLIDArgs) (*graphQLJobResolver, error) {
+	q := graphQLRequestFrom(ctx)
+	id, err := graphQLAuthorizedID(q, args.ID, models.JobResourceKind, models.BuildReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.api.jobService.Read(ctx, nil, models.JobIDFromResourceID(id))
+	if err != nil {
+		return nil, graphQLNotFoundAsNull(err)
+	}
+	return &graphQLJobResolver{q: q, job: job}, nil
+}
+
+func (r *graphQLRootResolver) Step(ctx context.Context, args graphQLIDArgs) (*graphQLStepResolver, error) {
+	q := graphQLRequestFrom(ctx)
+	id, err := graphQLAuthorizedID(q, args.ID, models.StepResourceKind, models.BuildReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	step, err := q.api.stepService.Read(ctx, nil, models.StepIDFromResourceID(id))
+	if err != nil {
+		return nil, graphQLNotFoundAsNull(err)
+	}
+	return &graphQLStepResolver{q: q, step: step}, nil
+}
+
+func (r *graphQLRootResolver) Log(ctx context.Context, args graphQLIDArgs) (*graphQLLogResolver, error) {
+	q := graphQLRequestFrom(ctx)
+	id, err := graphQLAuthorizedID(q, args.ID, models.LogDescriptorResourceKind, models.BuildReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	return graphQLLog(q, models.LogDescriptorIDFromResourceID(id))
+}
+
+func (r *graphQLRootResolver) Artifact(ctx context.Context, args graphQLIDArgs) (*graphQLArtifactResolver, error) {
+	q := graphQLRequestFrom(ctx)
+	id, err := graphQLAuthorizedID(q, args.ID, models.ArtifactResourceKind, models.ArtifactReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := q.api.artifactService.Read(ctx, nil, models.ArtifactIDFromResourceID(id))
+	if err != nil {
+		return nil, graphQLNotFoundAsNull(err)
+	}
+	return &graphQLArtifactResolver{artifact: artifact}, nil
+}
+
+func (r *graphQLRootResolver) Runner(ctx context.Context, args graphQLIDArgs) (*graphQLRunnerResolver, error) {
+	q := graphQLRequestFrom(ctx)
+	id, err := graphQLAuthorizedID(q, args.ID, models.RunnerResourceKind, models.RunnerReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	runner, err := q.runners.Load(models.RunnerIDFromResourceID(id))
+	if err != nil || runner == nil {
+		return nil, err
+	}
+	return &graphQLRunnerResolver{runner: runner}, nil
+}
+
+func (r *graphQLRootResolver) Repo(ctx context.Context, args graphQLIDArgs) (*graphQLRepoResolver, error) {
+	q := graphQLRequestFrom(ctx)
+	id, err := graphQLAuthorizedID(q, args.ID, models.RepoResourceKind, models.RepoReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := q.repos.Load(models.RepoIDFromResourceID(id))
+	if err != nil || repo == nil {
+		return nil, err
+	}
+	return &graphQLRepoResolver{repo: repo}, nil
+}
+
+type graphQLBuildResolver struct {
+	q     *graphQLRequest
+	build *models.Build
+}
+
+func (r *graphQLBuildResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(r.build.ID.String())
+}
+
+func (r *graphQLBuildResolver) Name() string {
+	return r.build.Name.String()
+}
+
+func (r *graphQLBuildResolver) Ref() string {
+	return r.build.Ref
+}
+
+func (r *graphQLBuildResolver) Status() string {
+	return r.build.Status.String()
+}
+
+func (r *graphQLBuildResolver) Error() *string {
+	return graphQLErrorMessage(r.build.Error)
+}
+
+func (r *graphQLBuildResolver) Timings() *graphQLTimingsResolver {
+	return &graphQLTimingsResolver{timings: r.build.Timings}
+}
+
+func (r *graphQLBuildResolver) CreatedAt() graphqlgo.Time {
+	return graphQLTime(r.build.CreatedAt)
+}
+
+func (r *graphQLBuildResolver) Repo() (*graphQLRepoResolver, error) {
+	return graphQLRepo(r.q, r.build.RepoID)
+}
+
+func (r *graphQLBuildResolver) Jobs() (*[]*graphQLJobResolver, error) {
+	jobs, err := r.q.jobsByBuildID.Load(r.build.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*graphQLJobResolver, len(jobs))
+	for i, job := range jobs {
+		results[i] = &graphQLJobResolver{q: r.q, job: job}
+	}
+	return &results, nil
+}
+
+func (r *graphQLBuildResolver) Log() (*graphQLLogResolver, error) {
+	return graphQLLog(r.q, r.build.LogDescriptorID)
+}
+
+type graphQLJobResolver struct {
+	q   *graphQLRequest
+	job *models.Job
+}
+
+func (r *graphQLJobResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(r.job.ID.String())
+}
+
+func (r *graphQLJobResolver) Name() string {
+	return r.job.Name.String()
+}
+
+func (r *graphQLJobResolver) Workflow() string {
+	return r.job.Workflow.String()
+}
+
+func (r *graphQLJobResolver) Description() string {
+	return r.job.Description
+}
+
+func (r *graphQLJobResolver) Stage() string {
+	return r.job.Stage.String()
+}
+
+func (r *graphQLJobResolver) Status() string {
+	return r.job.Status.String()
+}
+
+func (r *graphQLJobResolver) Error() *string {
+	return graphQLErrorMessage(r.job.Error)
+}
+
+func (r *graphQLJobResolver) Timings() *graphQLTimingsResolver {
+	return &graphQLTimingsResolver{timings: r.job.Timings}
+}
+
+func (r *graphQLJobResolver) CreatedAt() graphqlgo.Time {
+	return graphQLTime(r.job.CreatedAt)
+}
+
+func (r *graphQLJobResolver) DependsOn() []*graphQLJobDependencyResolver {
+	results := make([]*graphQLJobDependencyResolver, len(r.job.Depends))
+	for i, dependency := range r.job.Depends {
+		results[i] = &graphQLJobDependencyResolver{dependency: dependency}
+	}
+	return results
+}
+
+func (r *graphQLJobResolver) Build() (*graphQLBuildResolver, error) {
+	return graphQLBuild(r.q, r.job.BuildID)
+}
+
+func (r *graphQLJobResolver) Steps() (*[]*graphQLStepResolver, error) {
+	steps, err := r.q.stepsByJobID.Load(r.job.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*graphQLStepResolver, len(steps))
+	for i, step := range steps {
+		results[i] = &graphQLStepResolver{q: r.q, step: step}
+	}
+	return &results, nil
+}
+
+func (r *graphQLJobResolver) Runner() (*graphQLRunnerResolver, error) {
+	return graphQLRunner(r.q, r.job.RunnerID)
+}
+
+func (r *graphQLJobResolver) Artifacts() (*[]*graphQLArtifactResolver, error) {
+	// Check against the build so there's one check per build rather than one per job
+	err := r.q.authorize(models.ArtifactReadOperation, r.job.BuildID.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := r.q.artifactsByJobID.Load(r.job.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*graphQLArtifactResolver, len(artifacts))
+	for i, artifact := range artifacts {
+		results[i] = &graphQLArtifactResolver{artifact: artifact}
+	}
+	return &results, nil
+}
+
+func (r *graphQLJobResolver) Log() (*graphQLLogResolver, error) {
+	return graphQLLog(r.q, r.job.LogDescriptorID)
+}
+
+type graphQLJobDependencyResolver struct {
+	dependency *models.JobDependency
+}
+
+func (r *graphQLJobDependencyResolver) Workflow() string {
+	return r.dependency.Workflow.String()
+}
+
+func (r *graphQLJobDependencyResolver) JobName() string {
+	return r.dependency.JobName.String()
+}
+
+type graphQLStepResolver struct {
+	q    *graphQLRequest
+	step *models.Step
+}
+
+func (r *graphQLStepResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(r.step.ID.String())
+}
+
+func (r *graphQLStepResolver) Name() string {
+	return r.step.Name.String()
+}
+
+func (r *graphQLStepResolver) Description() string {
+	return r.step.Description
+}
+
+func (r *graphQLStepResolver) Status() string {
+	return r.step.Status.String()
+}
+
+func (r *graphQLStepResolver) Error() *string {
+	return graphQLErrorMessage(r.step.Error)
+}
+
+func (r *graphQLStepResolver) Timings() *graphQLTimingsResolver {
+	return &graphQLTimingsResolver{timings: r.step.Timings}
+}
+
+func (r *graphQLStepResolver) CreatedAt() graphqlgo.Time {
+	return graphQLTime(r.step.CreatedAt)
+}
+
+func (r *graphQLStepResolver) DependsOn() []string {
+	names := make([]string, len(r.step.Depends))
+	for i, dependency := range r.step.Depends {
+		names[i] = dependency.StepName.String()
+	}
+	return names
+}
+
+func (r *graphQLStepResolver) Runner() (*graphQLRunnerResolver, error) {
+	return graphQLRunner(r.q, r.step.RunnerID)
+}
+
+func (r *graphQLStepResolver) Log() (*graphQLLogResolver, error) {
+	return graphQLLog(r.q, r.step.LogDescriptorID)
+}
+
+type graphQLLogResolver struct {
+	log *models.LogDescriptor
+}
+
+func (r *graphQLLogResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(r.log.ID.String())
+}
+
+func (r *graphQLLogResolver) Sealed() bool {
+	return r.log.Sealed
+}
+
+func (r *graphQLLogResolver) SizeBytes() float64 {
+	return float64(r.log.SizeBytes)
+}
+
+func (r *graphQLLogResolver) CreatedAt() graphqlgo.Time {
+	return graphQLTime(r.log.CreatedAt)
+}
+
+func (r *graphQLLogResolver) ArchivedAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.log.ArchivedAt)
+}
+
+func (r *graphQLLogResolver) ExpiredAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.log.ExpiredAt)
+}
+
+type graphQLArtifactResolver struct {
+	artifact *models.Artifact
+}
+
+func (r *graphQLArtifactResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(r.artifact.ID.String())
+}
+
+func (r *graphQLArtifactResolver) Name() string {
+	return r.artifact.Name.String()
+}
+
+func (r *graphQLArtifactResolver) GroupName() string {
+	return r.artifact.GroupName.String()
+}
+
+func (r *graphQLArtifactResolver) Path() string {
+	return r.artifact.Path
+}
+
+func (r *graphQLArtifactResolver) Size() float64 {
+	return float64(r.artifact.Size)
+}
+
+func (r *graphQLArtifactResolver) Mime() string {
+	return r.artifact.Mime
+}
+
+func (r *graphQLArtifactResolver) SHA256() string {
+	return r.artifact.SHA256
+}
+
+func (r *graphQLArtifactResolver) Sealed() bool {
+	return r.artifact.Sealed
+}
+
+func (r *graphQLArtifactResolver) ScanStatus() string {
+	return r.artifact.ScanStatus.String()
+}
+
+func (r *graphQLArtifactResolver) CreatedAt() graphqlgo.Time {
+	return graphQLTime(r.artifact.CreatedAt)
+}
+
+type graphQLRunnerResolver struct {
+	runner *models.Runner
+}
+
+func (r *graphQLRunnerResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(r.runner.ID.String())
+}
+
+func (r *graphQLRunnerResolver) Name() string {
+	return r.runner.Name.String()
+}
+
+func (r *graphQLRunnerResolver) SoftwareVersion() string {
+	return r.runner.SoftwareVersion
+}
+
+func (r *graphQLRunnerResolver) OperatingSystem() string {
+	return r.runner.OperatingSystem
+}
+
+func (r *graphQLRunnerResolver) Architecture() string {
+	return r.runner.Architecture
+}
+
+func (r *graphQLRunnerResolver) Labels() []string {
+	labels := make([]string, len(r.runner.Labels))
+	for i, label := range r.runner.Labels {
+		labels[i] = label.String()
+	}
+	return labels
+}
+
+func (r *graphQLRunnerResolver) Enabled() bool {
+	return r.runner.Enabled
+}
+
+func (r *graphQLRunnerResolver) Online() bool {
+	return r.runner.Online
+}
+
+func (r *graphQLRunnerResolver) LastSeenAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.runner.LastSeenAt)
+}
+
+type graphQLRepoResolver struct {
+	repo *models.Repo
+}
+
+func (r *graphQLRepoResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(r.repo.ID.String())
+}
+
+func (r *graphQLRepoResolver) Name() string {
+	return r.repo.Name.String()
+}
+
+func (r *graphQLRepoResolver) Description() string {
+	return r.repo.Description
+}
+
+func (r *graphQLRepoResolver) Link() string {
+	return r.repo.Link
+}
+
+func (r *graphQLRepoResolver) HTTPURL() string {
+	return r.repo.HTTPURL
+}
+
+func (r *graphQLRepoResolver) DefaultBranch() string {
+	return r.repo.DefaultBranch
+}
+
+func (r *graphQLRepoResolver) Private() bool {
+	return r.repo.Private
+}
+
+func (r *graphQLRepoResolver) Enabled() bool {
+	return r.repo.Enabled
+}
+
+type graphQLTimingsResolver struct {
+	timings models.WorkflowTimings
+}
+
+func (r *graphQLTimingsResolver) QueuedAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.timings.QueuedAt)
+}
+
+func (r *graphQLTimingsResolver) SubmittedAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.timings.SubmittedAt)
+}
+
+func (r *graphQLTimingsResolver) RunningAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.timings.RunningAt)
+}
+
+func (r *graphQLTimingsResolver) FinishedAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.timings.FinishedAt)
+}
+
+func (r *graphQLTimingsResolver) CanceledAt() *graphqlgo.Time {
+	return graphQLOptionalTime(r.timings.CanceledAt)
+}
+
+// graphQLAuthorizedID parses the id argument of a root field as an ID of the specified kind, and checks
+// the authenticated identity is allowed to perform operation on it.
+func graphQLAuthorizedID(q *graphQLRequest, graphQLID graphqlgo.ID, kind models.ResourceKind, operation *models.Operation) (models.ResourceID, error) {
+	id, err := models.ParseResourceID(string(graphQLID))
+	if err != nil || id.Kind() != kind {
+		return models.ResourceID{}, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid %s id", kind))
+	}
+	err = q.authorize(operation, id)
+	if err != nil {
+		return models.ResourceID{}, err
+	}
+	return id, nil
+}
+
+// graphQLNotFoundAsNull returns no error for a resource that doesn't exist, so that the field's value is
+// null rather than an error, to match resources read using a loader.
+func graphQLNotFoundAsNull(err error) error {
+	if gerror.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func graphQLBuild(q *graphQLRequest, buildID models.BuildID) (*graphQLBuildResolver, error) {
+	build, err := q.builds.Load(buildID)
+	if err != nil || build == nil {
+		return nil, err
+	}
+	return &graphQLBuildResolver{q: q, build: build}, nil
+}
+
+func graphQLRepo(q *graphQLRequest, repoID models.RepoID) (*graphQLRepoResolver, error) {
+	err := q.authorize(models.RepoReadOperation, repoID.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := q.repos.Load(repoID)
+	if err != nil || repo == nil {
+		return nil, err
+	}
+	return &graphQLRepoResolver{repo: repo}, nil
+}
+
+func graphQLRunner(q *graphQLRequest, runnerID models.RunnerID) (*graphQLRunnerResolver, error) {
+	if !runnerID.Valid() {
+		return nil, nil
+	}
+	err := q.authorize(models.RunnerReadOperation, runnerID.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	runner, err := q.runners.Load(runnerID)
+	if err != nil || runner == nil {
+		return nil, err
+	}
+	return &graphQLRunnerResolver{runner: runner}, nil
+}
+
+func graphQLLog(q *graphQLRequest, logID models.LogDescriptorID) (*graphQLLogResolver, error) {
+	if !logID.Valid() {
+		return nil, nil
+	}
+	log, err := q.logs.Load(logID)
+	if err != nil || log == nil {
+		return nil, err
+	}
+	return &graphQLLogResolver{log: log}, nil
+}
+
+func graphQLErrorMessage(err *models.Error) *string {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	return &message
+}
+
+func graphQLTime(t models.Time) graphqlgo.Time {
+	return graphqlgo.Time{Time: t.UTC()}
+}
+
+func graphQLOptionalTime(t *models.Time) *graphqlgo.Time {
+	if t == nil {
+		return nil
+	}
+	result := graphQLTime(*t)
+	return &result
+}
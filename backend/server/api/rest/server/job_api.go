@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/render"
 
@@ -14,21 +15,24 @@ import (
 )
 
 type JobAPI struct {
-	jobService   services.JobService
-	queueService services.QueueService
+	jobService          services.JobService
+	queueService        services.QueueService
+	jobAnalyticsService services.JobAnalyticsService
 	*APIBase
 }
 
 func NewJobAPI(
 	jobService services.JobService,
 	queueService services.QueueService,
+	jobAnalyticsService services.JobAnalyticsService,
 	authorizationService services.AuthorizationService,
 	resourceLinker *routes.ResourceLinker,
 	logFactory logger.LogFactory) *JobAPI {
 	return &JobAPI{
-		jobService:   jobService,
-		queueService: queueService,
-		APIBase:      NewAPIBase(authorizationService, resourceLinker, logFactory("JobAPI")),
+		jobService:          jobService,
+		queueService:        queueService,
+		jobAnalyticsService: jobAnalyticsService,
+		APIBase:             NewAPIBase(authorizationService, resourceLinker, logFactory("JobAPI")),
 	}
 }
 
@@ -47,6 +51,56 @@ func (a *JobAPI) Get(w http.ResponseWriter, r *http.Request) {
 	a.GotResource(w, r, res)
 }
 
+// ListForRunner lists the jobs that were handed to a runner, optionally filtered to a time window and
+// set of statuses, so the impact of a problematic runner can be investigated.
+func (a *JobAPI) ListForRunner(w http.ResponseWriter, r *http.Request) {
+	runnerID, err := a.AuthorizedRunnerID(r, models.RunnerReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewJobSearchRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	// The jobs list is embedded under a runner in the API, so this search
+	// is always filtered to jobs for that runner.
+	search.RunnerID = &runnerID
+	jobs, cursor, err := a.jobService.Search(r.Context(), nil, *search.JobSearch)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeJobs(routes.RequestCtx(r), jobs)
+	res := documents.NewPaginatedResponse(models.JobResourceKind, routes.MakeRunnerJobsLink(routes.RequestCtx(r), runnerID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// GetRepoAnalytics returns statistics about the durations, queue wait times and success rates of the finished
+// jobs in a repo, grouped by job or workflow and broken down into time windows, for dashboards and capacity
+// planning. See documents.JobAnalyticsRequest for the query parameters.
+func (a *JobAPI) GetRepoAnalytics(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := documents.NewJobAnalyticsRequest(models.NewTime(time.Now()))
+	err = req.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	analytics, err := a.jobAnalyticsService.Analyze(r.Context(), nil, repoID, req.JobAnalyticsQuery)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeJobAnalytics(routes.RequestCtx(r), analytics))
+}
+
 func (a *JobAPI) GetGraph(w http.ResponseWriter, r *http.Request) {
 	jobID, err := a.AuthorizedJobID(r, models.BuildReadOperation)
 	if err != nil {
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/go-chi/render"
+
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/common/models/search"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
 	"github.com/buildbeaver/buildbeaver/server/services"
 	"github.com/buildbeaver/buildbeaver/server/services/scm"
 )
@@ -56,6 +59,30 @@ func (a *LegalEntityAPI) Get(w http.ResponseWriter, r *http.Request) {
 	a.GotResource(w, r, res)
 }
 
+func (a *LegalEntityAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.LegalEntityUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchLegalEntityRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	legalEntity, err := a.legalEntityService.UpdateSchedulingWeight(r.Context(), legalEntityID, dto.UpdateLegalEntitySchedulingWeight{
+		SchedulingWeight: *req.SchedulingWeight,
+		ETag:             a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeLegalEntity(routes.RequestCtx(r), legalEntity)
+	a.UpdatedResource(w, r, res, nil)
+}
+
 func (a *LegalEntityAPI) GetCurrent(w http.ResponseWriter, r *http.Request) {
 	meta := a.MustAuthenticationMeta(r)
 	userLegalEntity, err := a.legalEntityService.ReadByIdentityID(r.Context(), nil, meta.IdentityID)
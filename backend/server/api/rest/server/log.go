@@ -34,13 +34,21 @@ func NewLogAPI(
 	}
 }
 
+// Get returns a log descriptor. If the optional 'wait' query parameter is supplied and the log has not been
+// sealed, the request waits for up to that many seconds for the log to be sealed, so clients waiting for a
+// log to finish don't have to keep polling.
 func (a *LogAPI) Get(w http.ResponseWriter, r *http.Request) {
 	logID, err := a.AuthorizedLogDescriptorID(r, models.BuildReadOperation)
 	if err != nil {
 		a.Error(w, r, err)
 		return
 	}
-	log, err := a.logService.Read(r.Context(), nil, logID)
+	wait, err := a.GetWait(r, MaxResourceWait)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	log, err := a.logService.ReadWithWait(r.Context(), logID, wait)
 	if err != nil {
 		a.Error(w, r, err)
 		return
@@ -49,6 +57,21 @@ func (a *LogAPI) Get(w http.ResponseWriter, r *http.Request) {
 	a.GotResource(w, r, res)
 }
 
+// GetRepoUsage returns a summary of the storage used by the logs belonging to a repo's builds, jobs and steps.
+func (a *LogAPI) GetRepoUsage(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	usage, err := a.logService.UsageForRepo(r.Context(), nil, repoID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeLogUsage(routes.RequestCtx(r), usage))
+}
+
 func (a *LogAPI) WriteData(w http.ResponseWriter, r *http.Request) {
 	meta := a.MustAuthenticationMeta(r)
 	if meta.CredentialType != models.CredentialTypeClientCertificate {
@@ -100,9 +123,17 @@ func (a *LogAPI) GetData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Open the stream before writing headers so errors (e.g. the log has expired) can still be reported
+	stream, err := a.logService.ReadData(r.Context(), logID, search.LogSearch)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+
 	// Write and flush headers before we write the data
 	flusher, ok := w.(http.Flusher)
 	if !ok {
+		stream.Close()
 		a.Error(w, r, fmt.Errorf("error response body does not support http.Flusher"))
 		return
 	}
@@ -132,11 +163,6 @@ func (a *LogAPI) GetData(w http.ResponseWriter, r *http.Request) {
 	}
 	flusher.Flush() // Flush the headers before writing data
 
-	stream, err := a.logService.ReadData(r.Context(), logID, search.LogSearch)
-	if err != nil {
-		a.Error(w, r, err)
-		return
-	}
 	defer stream.Close()
 	_, err = io.Copy(util.NewFlushingWriter(w, flusher), stream)
 	if err != nil {
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// NotificationSettingAPI manages the notification settings for a repo. Notification settings are
+// always addressed via their repo, and access is controlled by the operations granted on the repo.
+type NotificationSettingAPI struct {
+	notificationService services.NotificationService
+	*APIBase
+}
+
+func NewNotificationSettingAPI(
+	notificationService services.NotificationService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *NotificationSettingAPI {
+	return &NotificationSettingAPI{
+		notificationService: notificationService,
+		APIBase:             NewAPIBase(authorizationService, resourceLinker, logFactory("NotificationSettingAPI")),
+	}
+}
+
+func (a *NotificationSettingAPI) Get(w http.ResponseWriter, r *http.Request) {
+	setting, err := a.authorizedNotificationSetting(r, models.RepoReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeNotificationSetting(routes.RequestCtx(r), setting)
+	a.GotResource(w, r, res)
+}
+
+func (a *NotificationSettingAPI) Create(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.RepoUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CreateNotificationSettingRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	setting, err := a.notificationService.Create(r.Context(), nil, dto.CreateNotificationSetting{
+		RepoID:              repoID,
+		Channel:             req.Channel,
+		WebhookURLPlaintext: req.WebhookURL,
+		OnSuccess:           req.OnSuccess,
+		OnFailure:           req.OnFailure,
+		OnRecovery:          req.OnRecovery,
+		MessageTemplate:     req.MessageTemplate,
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeNotificationSetting(routes.RequestCtx(r), setting)
+	a.CreatedResource(w, r, res, nil)
+}
+
+func (a *NotificationSettingAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	setting, err := a.authorizedNotificationSetting(r, models.RepoUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchNotificationSettingRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	setting, err = a.notificationService.Update(r.Context(), nil, setting.ID, dto.UpdateNotificationSetting{
+		Channel:             req.Channel,
+		WebhookURLPlaintext: req.WebhookURL,
+		OnSuccess:           req.OnSuccess,
+		OnFailure:           req.OnFailure,
+		OnRecovery:          req.OnRecovery,
+		MessageTemplate:     req.MessageTemplate,
+		ETag:                a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeNotificationSetting(routes.RequestCtx(r), setting)
+	a.UpdatedResource(w, r, res, nil)
+}
+
+func (a *NotificationSettingAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	setting, err := a.authorizedNotificationSetting(r, models.RepoUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.notificationService.Delete(r.Context(), nil, setting.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List returns the notification settings for a repo.
+func (a *NotificationSettingAPI) List(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.RepoReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	// TODO support search/pagination
+	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+	settings, cursor, err := a.notificationService.ListByRepoID(r.Context(), nil, repoID, pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeNotificationSettings(routes.RequestCtx(r), settings)
+	res := documents.NewPaginatedResponse(models.NotificationSettingResourceKind, routes.MakeNotificationSettingsLink(routes.RequestCtx(r), repoID), nil, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// authorizedNotificationSetting authorizes the operation against the repo in the request URL, and then
+// reads the notification setting in the request URL, checking that it belongs to the repo.
+func (a *NotificationSettingAPI) authorizedNotificationSetting(r *http.Request, operation *models.Operation) (*models.NotificationSetting, error) {
+	repoID, err := a.AuthorizedRepoID(r, operation)
+	if err != nil {
+		return nil, err
+	}
+	// This is required to support clients that escape colon's in IDs
+	escaped, err := url.PathUnescape(chi.URLParam(r, "notification_setting_id"))
+	if err != nil {
+		return nil, gerror.NewErrNotFound("Not Found").Wrap(err)
+	}
+	id, err := models.ParseResourceID(escaped)
+	if err != nil || id.Kind() != models.NotificationSettingResourceKind {
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	setting, err := a.notificationService.Read(r.Context(), nil, models.NotificationSettingIDFromResourceID(id))
+	if err != nil {
+		return nil, err
+	}
+	if setting.RepoID != repoID {
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	return setting, nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/oidc"
+)
+
+// OIDCAPI issues OpenID Connect ID tokens to running jobs via the Dynamic API, and publishes the discovery
+// document and signing keys that cloud providers and other services need to verify those tokens. The discovery
+// routes are public, since verifiers fetch them without authenticating.
+type OIDCAPI struct {
+	oidcService services.OIDCService
+	*APIBase
+}
+
+func NewOIDCAPI(
+	oidcService services.OIDCService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *OIDCAPI {
+	return &OIDCAPI{
+		oidcService: oidcService,
+		APIBase:     NewAPIBase(authorizationService, resourceLinker, logFactory("OIDCAPI")),
+	}
+}
+
+// GetConfiguration returns the OpenID Connect discovery document.
+func (a *OIDCAPI) GetConfiguration(w http.ResponseWriter, r *http.Request) {
+	issuerURL, err := a.oidcService.IssuerURL()
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeOpenIDConfiguration(issuerURL, issuerURL+oidc.JWKSPath))
+}
+
+// GetJWKS returns the set of public keys that tokens issued by the server can be verified with.
+func (a *OIDCAPI) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	keys, err := a.oidcService.GetSigningKeys()
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeJSONWebKeySet(keys))
+}
+
+// IssueToken issues an OpenID Connect ID token to the job in the request URL.
+func (a *OIDCAPI) IssueToken(w http.ResponseWriter, r *http.Request) {
+	jobID, err := a.AuthorizedJobID(r, models.JobIssueOIDCTokenOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CreateOIDCTokenRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	token, err := a.oidcService.IssueJobToken(r.Context(), nil, jobID, req.Audience)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeOIDCToken(token))
+}
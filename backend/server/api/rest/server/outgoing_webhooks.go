@@ -0,0 +1,291 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// OutgoingWebhookAPI manages the outgoing webhooks for a repo or legal entity. Outgoing webhooks are
+// always addressed via the repo or legal entity they are registered against, and access is controlled
+// by the operations granted on that repo or legal entity.
+type OutgoingWebhookAPI struct {
+	outgoingWebhookService services.OutgoingWebhookService
+	*APIBase
+}
+
+func NewOutgoingWebhookAPI(
+	outgoingWebhookService services.OutgoingWebhookService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *OutgoingWebhookAPI {
+	return &OutgoingWebhookAPI{
+		outgoingWebhookService: outgoingWebhookService,
+		APIBase:                NewAPIBase(authorizationService, resourceLinker, logFactory("OutgoingWebhookAPI")),
+	}
+}
+
+func (a *OutgoingWebhookAPI) Get(w http.ResponseWriter, r *http.Request) {
+	webhook, err := a.authorizedOutgoingWebhook(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeOutgoingWebhook(routes.RequestCtx(r), webhook)
+	a.GotResource(w, r, res)
+}
+
+func (a *OutgoingWebhookAPI) Create(w http.ResponseWriter, r *http.Request) {
+	parentID, err := a.authorizedParentID(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CreateOutgoingWebhookRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	create := dto.CreateOutgoingWebhook{
+		URL:                   req.URL,
+		SecretPlaintext:       req.Secret,
+		OnBuildStatusChanged:  req.OnBuildStatusChanged,
+		OnJobStatusChanged:    req.OnJobStatusChanged,
+		OnRunnerHealthChanged: req.OnRunnerHealthChanged,
+	}
+	if parentID.Kind() == models.RepoResourceKind {
+		create.RepoID = models.RepoIDFromResourceID(parentID)
+	} else {
+		create.LegalEntityID = models.LegalEntityIDFromResourceID(parentID)
+	}
+	webhook, err := a.outgoingWebhookService.Create(r.Context(), nil, create)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeOutgoingWebhook(routes.RequestCtx(r), webhook)
+	a.CreatedResource(w, r, res, nil)
+}
+
+func (a *OutgoingWebhookAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	webhook, err := a.authorizedOutgoingWebhook(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchOutgoingWebhookRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	webhook, err = a.outgoingWebhookService.Update(r.Context(), nil, webhook.ID, dto.UpdateOutgoingWebhook{
+		URL:                   req.URL,
+		SecretPlaintext:       req.Secret,
+		OnBuildStatusChanged:  req.OnBuildStatusChanged,
+		OnJobStatusChanged:    req.OnJobStatusChanged,
+		OnRunnerHealthChanged: req.OnRunnerHealthChanged,
+		Enabled:               req.Enabled,
+		ETag:                  a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeOutgoingWebhook(routes.RequestCtx(r), webhook)
+	a.UpdatedResource(w, r, res, nil)
+}
+
+func (a *OutgoingWebhookAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	webhook, err := a.authorizedOutgoingWebhook(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.outgoingWebhookService.Delete(r.Context(), nil, webhook.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List returns the outgoing webhooks registered against a repo or legal entity.
+func (a *OutgoingWebhookAPI) List(w http.ResponseWriter, r *http.Request) {
+	parentID, err := a.authorizedParentID(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	var (
+		webhooks []*models.OutgoingWebhook
+		cursor   *models.Cursor
+	)
+	if parentID.Kind() == models.RepoResourceKind {
+		webhooks, cursor, err = a.outgoingWebhookService.ListByRepoID(r.Context(), nil, models.RepoIDFromResourceID(parentID), search.Pagination)
+	} else {
+		webhooks, cursor, err = a.outgoingWebhookService.ListByLegalEntityID(r.Context(), nil, models.LegalEntityIDFromResourceID(parentID), search.Pagination)
+	}
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeOutgoingWebhooks(routes.RequestCtx(r), webhooks)
+	res := documents.NewPaginatedResponse(models.OutgoingWebhookResourceKind, routes.MakeOutgoingWebhooksLink(routes.RequestCtx(r), parentID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListDeliveries returns the delivery history for an outgoing webhook, most recent first.
+func (a *OutgoingWebhookAPI) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, err := a.authorizedOutgoingWebhook(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	deliveries, cursor, err := a.outgoingWebhookService.ListDeliveries(r.Context(), nil, webhook.ID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeOutgoingWebhookDeliveries(routes.RequestCtx(r), webhook, deliveries)
+	link := routes.MakeOutgoingWebhookDeliveriesLink(routes.RequestCtx(r), webhook.GetParentID(), webhook.ID)
+	res := documents.NewPaginatedResponse(models.OutgoingWebhookDeliveryResourceKind, link, search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// GetDelivery returns a single delivery for an outgoing webhook.
+func (a *OutgoingWebhookAPI) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	webhook, delivery, err := a.authorizedDelivery(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeOutgoingWebhookDelivery(routes.RequestCtx(r), webhook, delivery)
+	a.GotResource(w, r, res)
+}
+
+// Redeliver queues a delivery for an outgoing webhook to be delivered again.
+func (a *OutgoingWebhookAPI) Redeliver(w http.ResponseWriter, r *http.Request) {
+	webhook, delivery, err := a.authorizedDelivery(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	delivery, err = a.outgoingWebhookService.Redeliver(r.Context(), nil, delivery.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeOutgoingWebhookDelivery(routes.RequestCtx(r), webhook, delivery)
+	a.JSON(w, r, res)
+}
+
+// authorizedParentID returns the ID of the repo or legal entity in the request URL, after checking the
+// authenticated user has permission to read (or if update is true, update) it.
+func (a *OutgoingWebhookAPI) authorizedParentID(r *http.Request, update bool) (models.ResourceID, error) {
+	id, err := a.resourceLinker.GetLeafResourceID(r)
+	if err != nil {
+		return models.ResourceID{}, gerror.NewErrNotFound("Not Found").Wrap(err)
+	}
+	var operation *models.Operation
+	switch id.Kind() {
+	case models.RepoResourceKind:
+		operation = models.RepoReadOperation
+		if update {
+			operation = models.RepoUpdateOperation
+		}
+	case models.LegalEntityResourceKind:
+		operation = models.LegalEntityReadOperation
+		if update {
+			operation = models.LegalEntityUpdateOperation
+		}
+	default:
+		return models.ResourceID{}, gerror.NewErrNotFound("Not Found")
+	}
+	err = a.Authorize(r, operation, id)
+	if err != nil {
+		return models.ResourceID{}, err
+	}
+	return id, nil
+}
+
+// authorizedOutgoingWebhook authorizes the request against the repo or legal entity in the request URL,
+// and then reads the outgoing webhook in the request URL, checking that it is registered against that
+// repo or legal entity.
+func (a *OutgoingWebhookAPI) authorizedOutgoingWebhook(r *http.Request, update bool) (*models.OutgoingWebhook, error) {
+	parentID, err := a.authorizedParentID(r, update)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseURLParamResourceID(r, "outgoing_webhook_id", models.OutgoingWebhookResourceKind)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := a.outgoingWebhookService.Read(r.Context(), nil, models.OutgoingWebhookIDFromResourceID(id))
+	if err != nil {
+		return nil, err
+	}
+	if webhook.GetParentID() != parentID {
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	return webhook, nil
+}
+
+// authorizedDelivery authorizes the request and reads the outgoing webhook in the request URL as for
+// authorizedOutgoingWebhook, and then reads the delivery in the request URL, checking that it belongs to the webhook.
+func (a *OutgoingWebhookAPI) authorizedDelivery(r *http.Request, update bool) (*models.OutgoingWebhook, *models.OutgoingWebhookDelivery, error) {
+	webhook, err := a.authorizedOutgoingWebhook(r, update)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := parseURLParamResourceID(r, "outgoing_webhook_delivery_id", models.OutgoingWebhookDeliveryResourceKind)
+	if err != nil {
+		return nil, nil, err
+	}
+	delivery, err := a.outgoingWebhookService.ReadDelivery(r.Context(), nil, models.OutgoingWebhookDeliveryIDFromResourceID(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	if delivery.WebhookID != webhook.ID {
+		return nil, nil, gerror.NewErrNotFound("Not Found")
+	}
+	return webhook, delivery, nil
+}
+
+// parseURLParamResourceID parses the resource ID in the named URL parameter, returning a not found error
+// if it is not a valid ID for a resource of the expected kind.
+func parseURLParamResourceID(r *http.Request, param string, kind models.ResourceKind) (models.ResourceID, error) {
+	// This is required to support clients that escape colon's in IDs
+	escaped, err := url.PathUnescape(chi.URLParam(r, param))
+	if err != nil {
+		return models.ResourceID{}, gerror.NewErrNotFound("Not Found").Wrap(err)
+	}
+	id, err := models.ParseResourceID(escaped)
+	if err != nil || id.Kind() != kind {
+		return models.ResourceID{}, gerror.NewErrNotFound("Not Found")
+	}
+	return id, nil
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// PermissionAPI manages the permission overrides for a repo, and reports the effective permissions of an
+// identity on a resource. Access is controlled by the grant operations granted on the resource.
+type PermissionAPI struct {
+	permissionOverrideService services.PermissionOverrideService
+	*APIBase
+}
+
+func NewPermissionAPI(
+	permissionOverrideService services.PermissionOverrideService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *PermissionAPI {
+	return &PermissionAPI{
+		permissionOverrideService: permissionOverrideService,
+		APIBase:                   NewAPIBase(authorizationService, resourceLinker, logFactory("PermissionAPI")),
+	}
+}
+
+// ListOverrides returns the permission overrides for a repo.
+func (a *PermissionAPI) ListOverrides(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.GrantReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	grants, cursor, err := a.permissionOverrideService.ListByRepoID(r.Context(), nil, repoID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakePermissionOverrides(routes.RequestCtx(r), repoID, grants)
+	link := routes.MakePermissionOverridesLink(routes.RequestCtx(r), repoID)
+	res := documents.NewPaginatedResponse(models.GrantResourceKind, link, search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// SetOverride grants or denies a group an operation on a repo, replacing any existing override for the same
+// group and operation.
+func (a *PermissionAPI) SetOverride(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.GrantCreateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.SetPermissionOverrideRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	grant, err := a.permissionOverrideService.Set(r.Context(), nil, repoID, *req.GroupID, *req.Operation, req.Deny)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakePermissionOverride(routes.RequestCtx(r), repoID, grant)
+	a.CreatedResource(w, r, res, nil)
+}
+
+// GetOverride returns a single permission override for a repo.
+func (a *PermissionAPI) GetOverride(w http.ResponseWriter, r *http.Request) {
+	repoID, grant, err := a.authorizedOverride(r, models.GrantReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakePermissionOverride(routes.RequestCtx(r), repoID, grant)
+	a.GotResource(w, r, res)
+}
+
+// DeleteOverride removes a permission override from a repo.
+func (a *PermissionAPI) DeleteOverride(w http.ResponseWriter, r *http.Request) {
+	_, grant, err := a.authorizedOverride(r, models.GrantDeleteOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.permissionOverrideService.Delete(r.Context(), nil, grant.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetEffectivePermissions returns the operations an identity can perform on the resource in the request URL.
+// The identity defaults to the authenticated identity; the permissions of any other identity can only be
+// listed by identities that can read the resource's grants.
+func (a *PermissionAPI) GetEffectivePermissions(w http.ResponseWriter, r *http.Request) {
+	resourceID, err := a.resourceLinker.GetLeafResourceID(r)
+	if err != nil {
+		a.Error(w, r, gerror.NewErrNotFound("Not Found").Wrap(err))
+		return
+	}
+	identityID := a.MustAuthenticatedIdentityID(r)
+	if str := r.URL.Query().Get("identity_id"); str != "" {
+		id, err := models.ParseResourceID(str)
+		if err != nil || id.Kind() != models.IdentityResourceKind {
+			a.Error(w, r, gerror.NewErrValidationFailed("identity_id must be the ID of an identity"))
+			return
+		}
+		identityID = models.IdentityIDFromResourceID(id)
+	}
+	if !identityID.Equal(a.MustAuthenticatedIdentityID(r).ResourceID) {
+		err = a.Authorize(r, models.GrantReadOperation, resourceID)
+		if err != nil {
+			a.Error(w, r, err)
+			return
+		}
+	}
+	operations, err := a.authorizationService.ListEffectivePermissions(r.Context(), nil, identityID, resourceID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeEffectivePermissions(identityID, resourceID, operations))
+}
+
+// authorizedOverride authorizes the operation against the repo in the request URL, and then reads the
+// permission override in the request URL, checking that it belongs to that repo.
+func (a *PermissionAPI) authorizedOverride(r *http.Request, operation *models.Operation) (models.RepoID, *models.Grant, error) {
+	repoID, err := a.AuthorizedRepoID(r, operation)
+	if err != nil {
+		return models.RepoID{}, nil, err
+	}
+	id, err := parseURLParamResourceID(r, "permission_override_id", models.GrantResourceKind)
+	if err != nil {
+		return models.RepoID{}, nil, err
+	}
+	grant, err := a.permissionOverrideService.Read(r.Context(), nil, models.GrantIDFromResourceID(id))
+	if err != nil {
+		return models.RepoID{}, nil, err
+	}
+	if !grant.TargetResourceID.Equal(repoID.ResourceID) || !grant.AuthorizedGroupID.Valid() {
+		return models.RepoID{}, nil, gerror.NewErrNotFound("Not Found")
+	}
+	return repoID, grant, nil
+}
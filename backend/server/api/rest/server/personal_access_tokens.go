@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// PersonalAccessTokenAPI lets users create, list and revoke their own personal access tokens. Tokens always
+// belong to the authenticated identity, so no further access control is needed; however a personal access
+// token can't be used to manage tokens, so that a leaked token can't be used to create others.
+type PersonalAccessTokenAPI struct {
+	credentialService services.CredentialService
+	*APIBase
+}
+
+func NewPersonalAccessTokenAPI(
+	credentialService services.CredentialService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *PersonalAccessTokenAPI {
+	return &PersonalAccessTokenAPI{
+		credentialService: credentialService,
+		APIBase:           NewAPIBase(authorizationService, resourceLinker, logFactory("PersonalAccessTokenAPI")),
+	}
+}
+
+// List returns the authenticated user's personal access tokens.
+func (a *PersonalAccessTokenAPI) List(w http.ResponseWriter, r *http.Request) {
+	identityID, err := a.tokenOwnerID(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	credentials, cursor, err := a.credentialService.ListPersonalAccessTokens(r.Context(), nil, identityID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakePersonalAccessTokens(routes.RequestCtx(r), credentials)
+	res := documents.NewPaginatedResponse(models.CredentialResourceKind, routes.MakePersonalAccessTokensLink(routes.RequestCtx(r)), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// Create creates a new personal access token for the authenticated user. The response is the only time
+// the token is returned.
+func (a *PersonalAccessTokenAPI) Create(w http.ResponseWriter, r *http.Request) {
+	identityID, err := a.tokenOwnerID(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CreatePersonalAccessTokenRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	token, credential, err := a.credentialService.CreatePersonalAccessToken(r.Context(), nil, dto.CreatePersonalAccessToken{
+		IdentityID: identityID,
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		ExpiresAt:  *req.ExpiresAt,
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakePersonalAccessToken(routes.RequestCtx(r), credential)
+	res.Token = token
+	a.CreatedResource(w, r, res, nil)
+}
+
+// Revoke permanently deletes one of the authenticated user's personal access tokens.
+func (a *PersonalAccessTokenAPI) Revoke(w http.ResponseWriter, r *http.Request) {
+	identityID, err := a.tokenOwnerID(r)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	id, err := parseURLParamResourceID(r, "credential_id", models.CredentialResourceKind)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.credentialService.RevokePersonalAccessToken(r.Context(), nil, identityID, models.CredentialIDFromResourceID(id))
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tokenOwnerID returns the ID of the identity whose tokens are being managed, checking the request was
+// not authenticated with a personal access token.
+func (a *PersonalAccessTokenAPI) tokenOwnerID(r *http.Request) (models.IdentityID, error) {
+	meta := a.MustAuthenticationMeta(r)
+	if meta.CredentialType == models.CredentialTypePersonalAccessToken {
+		return models.IdentityID{}, gerror.NewErrUnauthorized("Personal access tokens can't be used to manage personal access tokens")
+	}
+	return meta.IdentityID, nil
+}
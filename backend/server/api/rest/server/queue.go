@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
@@ -10,6 +11,9 @@ import (
 	"github.com/buildbeaver/buildbeaver/server/services"
 )
 
+// MaxDequeueWait is the longest a runner can ask to wait for a job to become ready when dequeuing.
+const MaxDequeueWait = 60 * time.Second
+
 type QueueAPI struct {
 	queueService  services.QueueService
 	runnerService services.RunnerService
@@ -29,8 +33,16 @@ func NewQueueAPI(
 	}
 }
 
+// Dequeue returns the next job that is ready for the authenticated runner to run. If the optional 'wait' query
+// parameter is supplied and no job is ready, the request waits for up to that many seconds for a job to become
+// ready before returning a 404, so the runner doesn't have to keep polling.
 func (a *QueueAPI) Dequeue(w http.ResponseWriter, r *http.Request) {
 	meta := a.MustAuthenticationMeta(r)
+	wait, err := a.GetWait(r, MaxDequeueWait)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
 	// Read the currently authenticated runner
 	runner, err := a.runnerService.ReadByIdentityID(r.Context(), nil, meta.IdentityID)
 	if err != nil {
@@ -38,11 +50,12 @@ func (a *QueueAPI) Dequeue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job, err := a.queueService.Dequeue(r.Context(), runner.ID)
+	job, err := a.queueService.DequeueWithWait(r.Context(), runner.ID, wait)
 	if err != nil {
-		if gerror.IsNotFound(err) || gerror.IsRunnerDisabled(err) {
-			// Do not log 'not found' or 'Runner Disabled' errors as warnings - these are normal states when there's
-			// either nothing in the queue or the runner has been disabled by the user.
+		if gerror.IsNotFound(err) || gerror.IsRunnerDisabled(err) || gerror.IsRunnerUnhealthy(err) {
+			// Do not log 'not found', 'Runner Disabled' or 'Runner Unhealthy' errors as warnings - these are normal
+			// states when there's either nothing in the queue, the runner has been disabled by the user, or the
+			// runner has reported that its host is unhealthy.
 			a.ErrorNotLogged(w, r, err)
 		} else {
 			a.Error(w, r, err)
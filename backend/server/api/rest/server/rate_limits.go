@@ -0,0 +1,33 @@
+package server
+
+import (
+	bbmiddleware "github.com/buildbeaver/buildbeaver/server/api/rest/middleware"
+)
+
+// RateLimitsConfig configures the rate limits applied to API requests that clients make repeatedly, to
+// protect the server from runaway runners or scripts.
+type RateLimitsConfig struct {
+	// Dequeue limits how often runners can poll for jobs to run.
+	Dequeue bbmiddleware.RateLimitConfig
+	// LogWrite limits how often runners can write log data.
+	LogWrite bbmiddleware.RateLimitConfig
+	// EventFetch limits how often clients can fetch the events for a build.
+	EventFetch bbmiddleware.RateLimitConfig
+}
+
+// DefaultRateLimits allow well-behaved runners and clients plenty of headroom. Limits per IP address are
+// higher than per identity, since many runners or users may share an address behind NAT.
+var DefaultRateLimits = RateLimitsConfig{
+	Dequeue: bbmiddleware.RateLimitConfig{
+		PerIdentity: bbmiddleware.RateLimit{RequestsPerSecond: 2, Burst: 20},
+		PerIP:       bbmiddleware.RateLimit{RequestsPerSecond: 50, Burst: 200},
+	},
+	LogWrite: bbmiddleware.RateLimitConfig{
+		PerIdentity: bbmiddleware.RateLimit{RequestsPerSecond: 20, Burst: 100},
+		PerIP:       bbmiddleware.RateLimit{RequestsPerSecond: 200, Burst: 1000},
+	},
+	EventFetch: bbmiddleware.RateLimitConfig{
+		PerIdentity: bbmiddleware.RateLimit{RequestsPerSecond: 10, Burst: 50},
+		PerIP:       bbmiddleware.RateLimit{RequestsPerSecond: 50, Burst: 200},
+	},
+}
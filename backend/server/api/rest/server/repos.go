@@ -61,10 +61,78 @@ func (a *RepoAPI) Patch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var repo *models.Repo
+	eTag := a.GetIfMatch(r)
 	if req.Enabled != nil {
 		repo, err = a.repoService.UpdateRepoEnabled(r.Context(), repoID, dto.UpdateRepoEnabled{
 			Enabled: *req.Enabled,
-			ETag:    a.GetIfMatch(r),
+			ETag:    eTag,
+		})
+		if err != nil {
+			a.Error(w, r, err)
+			return
+		}
+		eTag = repo.ETag // the repo has changed, so only the new ETag will match for the next update
+	}
+	if req.DynamicJobRestrictions != nil {
+		restrictions := req.DynamicJobRestrictions
+		if restrictions.IsEmpty() {
+			restrictions = nil
+		}
+		repo, err = a.repoService.UpdateDynamicJobRestrictions(r.Context(), repoID, dto.UpdateDynamicJobRestrictions{
+			Restrictions: restrictions,
+			ETag:         eTag,
+		})
+		if err != nil {
+			a.Error(w, r, err)
+			return
+		}
+		eTag = repo.ETag
+	}
+	if req.CoverageSettings != nil {
+		settings := req.CoverageSettings
+		if settings.IsEmpty() {
+			settings = nil
+		}
+		repo, err = a.repoService.UpdateCoverageSettings(r.Context(), repoID, dto.UpdateCoverageSettings{
+			Settings: settings,
+			ETag:     eTag,
+		})
+		if err != nil {
+			a.Error(w, r, err)
+			return
+		}
+		eTag = repo.ETag
+	}
+	if req.PublicBuilds != nil {
+		repo, err = a.repoService.UpdatePublicBuilds(r.Context(), repoID, dto.UpdateRepoPublicBuilds{
+			PublicBuilds: *req.PublicBuilds,
+			ETag:         eTag,
+		})
+		if err != nil {
+			a.Error(w, r, err)
+			return
+		}
+		eTag = repo.ETag
+	}
+	if req.BuildPrioritySettings != nil {
+		settings := req.BuildPrioritySettings
+		if settings.IsEmpty() {
+			settings = nil
+		}
+		repo, err = a.repoService.UpdateBuildPrioritySettings(r.Context(), repoID, dto.UpdateBuildPrioritySettings{
+			Settings: settings,
+			ETag:     eTag,
+		})
+		if err != nil {
+			a.Error(w, r, err)
+			return
+		}
+		eTag = repo.ETag
+	}
+	if req.DeduplicateJobs != nil {
+		repo, err = a.repoService.UpdateDeduplicateJobs(r.Context(), repoID, dto.UpdateRepoDeduplicateJobs{
+			DeduplicateJobs: *req.DeduplicateJobs,
+			ETag:            eTag,
 		})
 		if err != nil {
 			a.Error(w, r, err)
@@ -90,7 +158,12 @@ func (a *RepoAPI) List(w http.ResponseWriter, r *http.Request) {
 	// The repo list is embedded under a legal entity in the API, so this search
 	// must always be limited to repos for that legal entity.
 	req.Query = search.NewRepoQueryBuilder(req.Query).WhereLegalEntityID(search.Equal, legalEntityID).Compile()
-	repos, cursor, err := a.repoService.Search(r.Context(), nil, a.MustAuthenticatedIdentityID(r), req.Query)
+	identityID, err := a.ScopedIdentityID(r, models.RepoReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	repos, cursor, err := a.repoService.Search(r.Context(), nil, identityID, req.Query)
 	if err != nil {
 		a.Error(w, r, err)
 		return
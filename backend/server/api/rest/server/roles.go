@@ -0,0 +1,264 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// RoleAPI manages the custom roles defined by a company, and their assignments to the company's groups and
+// members. Roles are always addressed via the company they belong to, and access is controlled by the
+// grant operations granted on that company.
+type RoleAPI struct {
+	roleService services.RoleService
+	*APIBase
+}
+
+func NewRoleAPI(
+	roleService services.RoleService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *RoleAPI {
+	return &RoleAPI{
+		roleService: roleService,
+		APIBase:     NewAPIBase(authorizationService, resourceLinker, logFactory("RoleAPI")),
+	}
+}
+
+func (a *RoleAPI) Get(w http.ResponseWriter, r *http.Request) {
+	role, err := a.authorizedRole(r, models.GrantReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRole(routes.RequestCtx(r), role)
+	a.GotResource(w, r, res)
+}
+
+func (a *RoleAPI) Create(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.GrantCreateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CreateRoleRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	role, err := a.roleService.Create(r.Context(), nil, dto.CreateRole{
+		LegalEntityID: legalEntityID,
+		TemplateName:  req.Template,
+		Name:          req.Name,
+		Description:   req.Description,
+		Operations:    req.Operations,
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRole(routes.RequestCtx(r), role)
+	a.CreatedResource(w, r, res, nil)
+}
+
+func (a *RoleAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	role, err := a.authorizedRole(r, models.GrantUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchRoleRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	role, err = a.roleService.Update(r.Context(), nil, role.ID, dto.UpdateRole{
+		Name:        req.Name,
+		Description: req.Description,
+		Operations:  req.Operations,
+		ETag:        a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRole(routes.RequestCtx(r), role)
+	a.UpdatedResource(w, r, res, nil)
+}
+
+func (a *RoleAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	role, err := a.authorizedRole(r, models.GrantDeleteOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.roleService.Delete(r.Context(), nil, role.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List returns the roles defined by a company.
+func (a *RoleAPI) List(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.GrantReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	roles, cursor, err := a.roleService.ListByLegalEntityID(r.Context(), nil, legalEntityID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeRoles(routes.RequestCtx(r), roles)
+	res := documents.NewPaginatedResponse(models.RoleResourceKind, routes.MakeRolesLink(routes.RequestCtx(r), legalEntityID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListTemplates returns the templates roles can be created from.
+func (a *RoleAPI) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	_, err := a.AuthorizedLegalEntityID(r, models.GrantReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRoleTemplates(a.roleService.ListTemplates())
+	a.JSON(w, r, res)
+}
+
+// Assign assigns a role to one of the company's groups or members.
+func (a *RoleAPI) Assign(w http.ResponseWriter, r *http.Request) {
+	role, err := a.authorizedRole(r, models.GrantCreateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CreateRoleAssignmentRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	var assignment *models.RoleAssignment
+	if req.GroupID != nil {
+		assignment, err = a.roleService.AssignToGroup(r.Context(), nil, role.ID, *req.GroupID)
+	} else {
+		assignment, err = a.roleService.AssignToIdentity(r.Context(), nil, role.ID, *req.IdentityID)
+	}
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRoleAssignment(routes.RequestCtx(r), role, assignment)
+	a.CreatedResource(w, r, res, nil)
+}
+
+// ListAssignments returns the assignments of a role.
+func (a *RoleAPI) ListAssignments(w http.ResponseWriter, r *http.Request) {
+	role, err := a.authorizedRole(r, models.GrantReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	assignments, cursor, err := a.roleService.ListAssignments(r.Context(), nil, role.ID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeRoleAssignments(routes.RequestCtx(r), role, assignments)
+	link := routes.MakeRoleAssignmentsLink(routes.RequestCtx(r), role.LegalEntityID, role.ID)
+	res := documents.NewPaginatedResponse(models.RoleAssignmentResourceKind, link, search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// GetAssignment returns a single assignment of a role.
+func (a *RoleAPI) GetAssignment(w http.ResponseWriter, r *http.Request) {
+	role, assignment, err := a.authorizedRoleAssignment(r, models.GrantReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRoleAssignment(routes.RequestCtx(r), role, assignment)
+	a.GotResource(w, r, res)
+}
+
+// Unassign removes an assignment of a role.
+func (a *RoleAPI) Unassign(w http.ResponseWriter, r *http.Request) {
+	_, assignment, err := a.authorizedRoleAssignment(r, models.GrantDeleteOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.roleService.Unassign(r.Context(), nil, assignment.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizedRole authorizes the operation against the company in the request URL, and then reads the role
+// in the request URL, checking that it belongs to that company.
+func (a *RoleAPI) authorizedRole(r *http.Request, operation *models.Operation) (*models.Role, error) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, operation)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseURLParamResourceID(r, "role_id", models.RoleResourceKind)
+	if err != nil {
+		return nil, err
+	}
+	role, err := a.roleService.Read(r.Context(), nil, models.RoleIDFromResourceID(id))
+	if err != nil {
+		return nil, err
+	}
+	if role.LegalEntityID != legalEntityID {
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	return role, nil
+}
+
+// authorizedRoleAssignment authorizes the request and reads the role in the request URL as for authorizedRole,
+// and then reads the role assignment in the request URL, checking that it belongs to the role.
+func (a *RoleAPI) authorizedRoleAssignment(r *http.Request, operation *models.Operation) (*models.Role, *models.RoleAssignment, error) {
+	role, err := a.authorizedRole(r, operation)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := parseURLParamResourceID(r, "role_assignment_id", models.RoleAssignmentResourceKind)
+	if err != nil {
+		return nil, nil, err
+	}
+	assignment, err := a.roleService.ReadAssignment(r.Context(), nil, models.RoleAssignmentIDFromResourceID(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	if assignment.RoleID != role.ID {
+		return nil, nil, gerror.NewErrNotFound("Not Found")
+	}
+	return role, assignment, nil
+}
@@ -13,6 +13,7 @@ var rootDocumentPaths = map[string]func(ctx routes.RequestContext) string{
 	"current_legal_entity_url":  routes.MakeCurrentLegalEntityLink,
 	"legal_entities_url":        routes.MakeLegalEntitiesLink,
 	"github_authentication_url": routes.MakeGitHubAuthenticationURL,
+	"sso_authentication_url":    routes.MakeSSOAuthenticationURL,
 }
 
 type RootAPI struct {
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// RunnerPoolAPI manages the runner pools for a legal entity. Runner pools are always addressed via the
+// legal entity they belong to, and access is controlled by the operations granted on that legal entity.
+type RunnerPoolAPI struct {
+	runnerPoolService services.RunnerPoolService
+	*APIBase
+}
+
+func NewRunnerPoolAPI(
+	runnerPoolService services.RunnerPoolService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *RunnerPoolAPI {
+	return &RunnerPoolAPI{
+		runnerPoolService: runnerPoolService,
+		APIBase:           NewAPIBase(authorizationService, resourceLinker, logFactory("RunnerPoolAPI")),
+	}
+}
+
+func (a *RunnerPoolAPI) Get(w http.ResponseWriter, r *http.Request) {
+	pool, err := a.authorizedRunnerPool(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRunnerPool(routes.RequestCtx(r), pool)
+	a.GotResource(w, r, res)
+}
+
+func (a *RunnerPoolAPI) Create(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.authorizedLegalEntityID(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.CreateRunnerPoolRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	pool, err := a.runnerPoolService.Create(r.Context(), nil, dto.CreateRunnerPool{
+		LegalEntityID:        legalEntityID,
+		Name:                 req.Name,
+		Labels:               req.Labels,
+		MinRunners:           req.MinRunners,
+		MaxRunners:           req.MaxRunners,
+		ScaleDownIdleTimeout: time.Duration(req.ScaleDownIdleTimeoutSeconds) * time.Second,
+		ProvisionerType:      req.ProvisionerType,
+		ProvisionerConfig:    req.ProvisionerConfig,
+		SecretPlaintext:      req.Secret,
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRunnerPool(routes.RequestCtx(r), pool)
+	a.CreatedResource(w, r, res, nil)
+}
+
+func (a *RunnerPoolAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	pool, err := a.authorizedRunnerPool(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchRunnerPoolRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	update := dto.UpdateRunnerPool{
+		Name:              req.Name,
+		Labels:            req.Labels,
+		MinRunners:        req.MinRunners,
+		MaxRunners:        req.MaxRunners,
+		ProvisionerType:   req.ProvisionerType,
+		ProvisionerConfig: req.ProvisionerConfig,
+		SecretPlaintext:   req.Secret,
+		ETag:              a.GetIfMatch(r),
+	}
+	if req.ScaleDownIdleTimeoutSeconds != nil {
+		timeout := time.Duration(*req.ScaleDownIdleTimeoutSeconds) * time.Second
+		update.ScaleDownIdleTimeout = &timeout
+	}
+	pool, err = a.runnerPoolService.Update(r.Context(), nil, pool.ID, update)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRunnerPool(routes.RequestCtx(r), pool)
+	a.UpdatedResource(w, r, res, nil)
+}
+
+func (a *RunnerPoolAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	pool, err := a.authorizedRunnerPool(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.runnerPoolService.Delete(r.Context(), nil, pool.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List returns the runner pools belonging to a legal entity.
+func (a *RunnerPoolAPI) List(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.authorizedLegalEntityID(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	pools, cursor, err := a.runnerPoolService.ListByLegalEntityID(r.Context(), nil, legalEntityID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeRunnerPools(routes.RequestCtx(r), pools)
+	res := documents.NewPaginatedResponse(models.RunnerPoolResourceKind, routes.MakeRunnerPoolsLink(routes.RequestCtx(r), legalEntityID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// authorizedLegalEntityID returns the ID of the legal entity in the request URL, after checking the
+// authenticated user has permission to read (or if update is true, update) it.
+func (a *RunnerPoolAPI) authorizedLegalEntityID(r *http.Request, update bool) (models.LegalEntityID, error) {
+	id, err := a.resourceLinker.GetLeafResourceID(r)
+	if err != nil {
+		return models.LegalEntityID{}, gerror.NewErrNotFound("Not Found").Wrap(err)
+	}
+	if id.Kind() != models.LegalEntityResourceKind {
+		return models.LegalEntityID{}, gerror.NewErrNotFound("Not Found")
+	}
+	operation := models.LegalEntityReadOperation
+	if update {
+		operation = models.LegalEntityUpdateOperation
+	}
+	err = a.Authorize(r, operation, id)
+	if err != nil {
+		return models.LegalEntityID{}, err
+	}
+	return models.LegalEntityIDFromResourceID(id), nil
+}
+
+// authorizedRunnerPool authorizes the request against the legal entity in the request URL, and then reads
+// the runner pool in the request URL, checking that it belongs to that legal entity.
+func (a *RunnerPoolAPI) authorizedRunnerPool(r *http.Request, update bool) (*models.RunnerPool, error) {
+	legalEntityID, err := a.authorizedLegalEntityID(r, update)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseURLParamResourceID(r, "runner_pool_id", models.RunnerPoolResourceKind)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := a.runnerPoolService.Read(r.Context(), nil, models.RunnerPoolIDFromResourceID(id))
+	if err != nil {
+		return nil, err
+	}
+	if pool.LegalEntityID != legalEntityID {
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	return pool, nil
+}
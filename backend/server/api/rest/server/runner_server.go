@@ -42,10 +42,13 @@ func NewRunnerAPIRouter(
 	log *LogAPI,
 	secret *SecretAPI,
 	artifact *ArtifactAPI,
+	cache *CacheAPI,
 	job *JobAPI,
 	step *StepAPI,
 	runner *RunnerAPI,
 	authenticationService services.AuthenticationService,
+	metricsExportService services.MetricsExportService,
+	rateLimits RateLimitsConfig,
 	logFactory logger.LogFactory) *RunnerAPIRouter {
 
 	logger := logFactory("RunnerAPI").
@@ -55,9 +58,13 @@ func NewRunnerAPIRouter(
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
+	r.Use(bbmiddleware.MakeTracing("RunnerAPI"))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Compress(6))
 
+	dequeueRateLimiter := bbmiddleware.MakeRateLimiter(logger, metricsExportService, "dequeue", "job dequeues", rateLimits.Dequeue)
+	logWriteRateLimiter := bbmiddleware.MakeRateLimiter(logger, metricsExportService, "log_write", "log writes", rateLimits.LogWrite)
+
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/v1", func(r chi.Router) {
 			// Routes for runners to interact with are authenticated using client certificates via TLS mutual auth;
@@ -72,7 +79,8 @@ func NewRunnerAPIRouter(
 
 					r.Get("/ping", queue.Ping)
 					r.Patch("/runtime", runner.PatchRuntimeInfo)
-					r.Get("/queue", queue.Dequeue)
+					r.Put("/health", runner.PutHealth)
+					r.With(dequeueRateLimiter).Get("/queue", queue.Dequeue)
 					r.Route("/repos/{repo_id}", func(r chi.Router) {
 						r.Route("/secrets", func(r chi.Router) {
 							r.Get("/", secret.ListPlainText)
@@ -97,12 +105,29 @@ func NewRunnerAPIRouter(
 					r.Group(func(r chi.Router) {
 						r.Use(middleware.Timeout(routerDefaultTimeout))
 						r.Patch("/", job.Patch)
+						r.Get("/cache-entries/{cache_entry_id}", cache.GetEntry)
 					})
 
 					r.Route("/artifacts", func(r chi.Router) {
 						r.Use(middleware.Timeout(5 * time.Minute)) // extra long timeout for posting artifacts
 						r.Post("/", artifact.Create)
 					})
+
+					r.Route("/caches/{cache_name}", func(r chi.Router) {
+						r.Group(func(r chi.Router) {
+							r.Use(middleware.Timeout(routerDefaultTimeout))
+							r.Get("/", cache.Find)
+						})
+						r.Group(func(r chi.Router) {
+							r.Use(middleware.Timeout(5 * time.Minute)) // extra long timeout for saving caches
+							r.Put("/", cache.Save)
+						})
+					})
+
+					r.Route("/cache-entries/{cache_entry_id}/data", func(r chi.Router) {
+						r.Use(middleware.Timeout(5 * time.Minute)) // extra long timeout for downloading caches
+						r.Get("/", cache.GetData)
+					})
 				})
 
 				r.Route("/logs/{log_descriptor_id}", func(r chi.Router) {
@@ -116,7 +141,7 @@ func NewRunnerAPIRouter(
 						// allow clients to stream log data for a longer-than-standard time to allow clients the
 						// option to hold the connection open
 						r.Use(middleware.Timeout(5 * time.Minute))
-						r.Post("/data", log.WriteData)
+						r.With(logWriteRateLimiter).Post("/data", log.WriteData)
 					})
 				})
 			})
@@ -151,6 +151,12 @@ func (a *RunnerAPI) PatchRuntimeInfo(w http.ResponseWriter, r *http.Request) {
 	if req.SupportedJobTypes != nil {
 		runner.SupportedJobTypes = *req.SupportedJobTypes
 	}
+	if req.Capacity != nil {
+		runner.SetCapacity(*req.Capacity)
+	}
+	if req.ExecJobAllowlist != nil {
+		runner.ExecJobAllowlist = req.ExecJobAllowlist
+	}
 	etag := a.GetIfMatch(r)
 	if etag != "" {
 		runner.ETag = etag
@@ -164,6 +170,32 @@ func (a *RunnerAPI) PatchRuntimeInfo(w http.ResponseWriter, r *http.Request) {
 	a.UpdatedResource(w, r, res, nil)
 }
 
+// PutHealth records a health report sent by a runner as a heartbeat. The runner is not given jobs to run
+// while its most recent report shows problems with its host.
+func (a *RunnerAPI) PutHealth(w http.ResponseWriter, r *http.Request) {
+	// This API function must be called by a runner. Read the runner associated with currently authenticated identity.
+	meta := a.MustAuthenticationMeta(r)
+	runner, err := a.runnerService.ReadByIdentityID(r.Context(), nil, meta.IdentityID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+
+	req := &documents.PutRunnerHealthRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	runner, err = a.runnerService.UpdateHealth(r.Context(), nil, runner.ID, *req.RunnerHealthReport)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeRunner(routes.RequestCtx(r), runner)
+	a.UpdatedResource(w, r, res, nil)
+}
+
 func (a *RunnerAPI) Delete(w http.ResponseWriter, r *http.Request) {
 	runnerID, err := a.AuthorizedRunnerID(r, models.RunnerDeleteOperation)
 	if err != nil {
@@ -194,7 +226,12 @@ func (a *RunnerAPI) List(w http.ResponseWriter, r *http.Request) {
 	// The runners list is embedded under a legal entity in the API, so this search
 	// is always filtered to runners for that legal entity.
 	search.LegalEntityID = &legalEntityID
-	runners, cursor, err := a.runnerService.Search(r.Context(), nil, a.MustAuthenticatedIdentityID(r), *search.RunnerSearch)
+	identityID, err := a.ScopedIdentityID(r, models.RunnerReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	runners, cursor, err := a.runnerService.Search(r.Context(), nil, identityID, *search.RunnerSearch)
 	if err != nil {
 		a.Error(w, r, err)
 		return
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// SAMLIdentityProviderAPI manages the SAML identity provider for a company. Each company has at most one
+// identity provider, addressed via its legal entity, and access is controlled by the operations granted on
+// the legal entity.
+type SAMLIdentityProviderAPI struct {
+	authenticationService services.AuthenticationService
+	*APIBase
+}
+
+func NewSAMLIdentityProviderAPI(
+	authenticationService services.AuthenticationService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *SAMLIdentityProviderAPI {
+	return &SAMLIdentityProviderAPI{
+		authenticationService: authenticationService,
+		APIBase:               NewAPIBase(authorizationService, resourceLinker, logFactory("SAMLIdentityProviderAPI")),
+	}
+}
+
+func (a *SAMLIdentityProviderAPI) Get(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.LegalEntityReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	provider, err := a.authenticationService.ReadSAMLIdentityProvider(r.Context(), nil, legalEntityID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeSAMLIdentityProvider(routes.RequestCtx(r), provider)
+	a.GotResource(w, r, res)
+}
+
+func (a *SAMLIdentityProviderAPI) Patch(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.LegalEntityUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.PatchSAMLIdentityProviderRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	provider, err := a.authenticationService.UpdateSAMLIdentityProvider(r.Context(), nil, legalEntityID, dto.UpdateSAMLIdentityProvider{
+		Enabled:           req.Enabled,
+		MetadataXML:       req.MetadataXML,
+		EmailAttribute:    req.EmailAttribute,
+		NameAttribute:     req.NameAttribute,
+		UsernameAttribute: req.UsernameAttribute,
+		GroupsAttribute:   req.GroupsAttribute,
+		GroupMappings:     req.GroupMappings,
+		ETag:              a.GetIfMatch(r),
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeSAMLIdentityProvider(routes.RequestCtx(r), provider)
+	a.UpdatedResource(w, r, res, nil)
+}
+
+func (a *SAMLIdentityProviderAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.LegalEntityUpdateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.authenticationService.DeleteSAMLIdentityProvider(r.Context(), nil, legalEntityID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
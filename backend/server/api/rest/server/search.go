@@ -102,7 +102,11 @@ func (a *SearchAPI) Search(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *SearchAPI) searchRepos(r *http.Request, req *documents.SearchRequest) (*documents.PaginatedResponse, error) {
-	repos, _, err := a.repoService.Search(r.Context(), nil, a.MustAuthenticatedIdentityID(r), req.Query)
+	identityID, err := a.ScopedIdentityID(r, models.RepoReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	repos, _, err := a.repoService.Search(r.Context(), nil, identityID, req.Query)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +116,11 @@ func (a *SearchAPI) searchRepos(r *http.Request, req *documents.SearchRequest) (
 }
 
 func (a *SearchAPI) searchBuilds(r *http.Request, req *documents.SearchRequest) (*documents.PaginatedResponse, error) {
-	builds, _, err := a.buildService.UniversalSearch(r.Context(), nil, a.MustAuthenticatedIdentityID(r), req.Query)
+	identityID, err := a.ScopedIdentityID(r, models.BuildReadOperation)
+	if err != nil {
+		return nil, err
+	}
+	builds, _, err := a.buildService.UniversalSearch(r.Context(), nil, identityID, req.Query)
 	if err != nil {
 		return nil, err
 	}
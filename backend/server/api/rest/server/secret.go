@@ -1,10 +1,12 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/render"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
@@ -147,7 +149,41 @@ func (a *SecretAPI) List(w http.ResponseWriter, r *http.Request) {
 	a.JSON(w, r, res)
 }
 
-// ListPlainText returns a list of secrets in plaintext for a repo.
+// ListVersions returns a list of the prior versions of a secret, newest first. Only metadata about each version
+// is returned, never the key or value of the secret as of that version.
+func (a *SecretAPI) ListVersions(w http.ResponseWriter, r *http.Request) {
+	secretID, err := a.AuthorizedSecretID(r, models.SecretReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	secret, err := a.secretService.Read(r.Context(), nil, secretID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	if secret.IsInternal {
+		a.Error(w, r, gerror.NewErrNotFound("Not Found"))
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	versions, cursor, err := a.secretService.ListVersions(r.Context(), nil, secretID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeSecretVersions(routes.RequestCtx(r), versions)
+	res := documents.NewPaginatedResponse(models.SecretVersionResourceKind, routes.MakeSecretVersionsLink(routes.RequestCtx(r), secretID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListPlainText returns a list of secrets in plaintext for a repo. If the build_id query parameter is set, the
+// secrets are returned as they were when that build started (for jobs that pin their secrets).
 func (a *SecretAPI) ListPlainText(w http.ResponseWriter, r *http.Request) {
 	meta := a.MustAuthenticationMeta(r)
 	if meta.CredentialType != models.CredentialTypeClientCertificate {
@@ -160,7 +196,20 @@ func (a *SecretAPI) ListPlainText(w http.ResponseWriter, r *http.Request) {
 	}
 	// TODO support search/pagination
 	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
-	secrets, cursor, err := a.secretService.ListPlaintextByRepoID(r.Context(), nil, repoID, pagination)
+	var (
+		secrets []*models.SecretPlaintext
+		cursor  *models.Cursor
+	)
+	if buildIDStr := r.URL.Query().Get("build_id"); buildIDStr != "" {
+		id, err := models.ParseResourceID(buildIDStr)
+		if err != nil || id.Kind() != models.BuildResourceKind {
+			a.Error(w, r, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid build id: %q", buildIDStr)))
+			return
+		}
+		secrets, cursor, err = a.secretService.ListPlaintextByRepoIDAtBuildStart(r.Context(), nil, repoID, models.BuildIDFromResourceID(id), pagination)
+	} else {
+		secrets, cursor, err = a.secretService.ListPlaintextByRepoID(r.Context(), nil, repoID, pagination)
+	}
 	if err != nil {
 		a.Error(w, r, err)
 		return
@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestDownloadBuildArtifacts(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	legalEntity, identity := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	require.NotEmpty(t, graph.Jobs)
+	job := graph.Jobs[0]
+
+	_, err = app.ArtifactService.Create(ctx, job.ID, "reports", "out/report.txt", "", bytes.NewReader([]byte("report")), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, job.ID, "binaries", "out/app.bin", "", bytes.NewReader([]byte("binary")), true)
+	require.NoError(t, err)
+
+	sharedSecret, _, err := app.CredentialService.CreateSharedSecretCredential(ctx, nil, identity.ID, true)
+	require.NoError(t, err)
+	userClient, err := client.NewAPIClient(
+		[]string{app.CoreAPIServer.GetServerURL()},
+		client.NewSharedSecretAuthenticator(client.SharedSecretToken(sharedSecret.String()), app.LogFactory),
+		app.LogFactory)
+	require.Nil(t, err)
+
+	search := models.NewArtifactSearch()
+	groupName := models.ResourceName("reports")
+	search.GroupName = &groupName
+	paginator, err := userClient.SearchBuildArtifacts(ctx, graph.ID, search)
+	require.NoError(t, err)
+	var artifacts []*models.Artifact
+	for paginator.HasNext() {
+		results, err := paginator.Next(ctx)
+		require.NoError(t, err)
+		artifacts = append(artifacts, results...)
+	}
+	require.Len(t, artifacts, 1)
+	require.Equal(t, "out/report.txt", artifacts[0].Path)
+
+	reader, err := userClient.GetBuildArtifactData(ctx, artifacts[0].ID)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "report", string(data))
+	sum := sha256.Sum256(data)
+	require.Equal(t, hex.EncodeToString(sum[:]), artifacts[0].SHA256)
+
+	// Artifacts can't be read by anonymous clients
+	anonClient, err := client.NewAPIClient(
+		[]string{app.CoreAPIServer.GetServerURL()},
+		client.NewSharedSecretAuthenticator("", app.LogFactory),
+		app.LogFactory)
+	require.Nil(t, err)
+	_, err = anonClient.GetBuildArtifactData(ctx, artifacts[0].ID)
+	require.Error(t, err)
+}
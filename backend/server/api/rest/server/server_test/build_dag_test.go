@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+const buildDAGDynamicJobsYAML = `
+version: 0.3
+jobs:
+  - name: publish
+    workflow: deploy
+    type: exec
+    depends: [ release.package ]
+    steps:
+      - name: publish
+        commands:
+          - echo publish
+  - name: notify
+    workflow: deploy
+    type: exec
+    depends: [ deploy.publish ]
+    steps:
+      - name: notify
+        commands:
+          - echo notify
+`
+
+func TestBuildDAG(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateNamedRepo(t, ctx, app, "dag", legalEntity.ID)
+	_, err = app.RepoService.UpdatePublicBuilds(ctx, repo.ID, dto.UpdateRepoPublicBuilds{PublicBuilds: true})
+	require.NoError(t, err)
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	const fingerprint = "build-dag-fingerprint"
+
+	// Run a build to completion so that the job in the next build is indirected to it
+	firstBuild := enqueuePublicBuildsTestBuild(t, ctx, app, repo.ID, legalEntity.ID)
+	job, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateJobFingerprint(ctx, job.ID, dto.UpdateJobFingerprint{Fingerprint: fingerprint, FingerprintHashType: models.HashTypeBlake2b})
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateStepStatus(ctx, nil, job.Steps[0].ID, dto.UpdateStepStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+
+	build := enqueuePublicBuildsTestBuild(t, ctx, app, repo.ID, legalEntity.ID)
+	job, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, build.ID, job.BuildID)
+	indirected, err := app.QueueService.UpdateJobFingerprint(ctx, job.ID, dto.UpdateJobFingerprint{Fingerprint: fingerprint, FingerprintHashType: models.HashTypeBlake2b})
+	require.NoError(t, err)
+	require.Equal(t, firstBuild.Jobs[0].ID, indirected.IndirectToJobID)
+	_, _, err = app.QueueService.AddConfigToBuild(ctx, nil, build.ID, []byte(buildDAGDynamicJobsYAML), models.ConfigTypeYAML)
+	require.NoError(t, err)
+
+	res, err := http.Get(app.CoreAPIServer.GetServerURL() + "/api/v1/public/builds/" + build.ID.String() + "/dag")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	dag := &documents.BuildDAG{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(dag))
+	require.Equal(t, build.ID, dag.Build.ID)
+
+	// Jobs in the build come first, followed by the deferred job that hasn't been added yet
+	require.Len(t, dag.Nodes, 4)
+	nodesByID := make(map[string]*documents.BuildDAGNode, len(dag.Nodes))
+	for _, node := range dag.Nodes {
+		nodesByID[node.ID] = node
+	}
+	require.Equal(t, "release.package", dag.Nodes[3].ID)
+	workflowsByName := make(map[models.ResourceName]*documents.BuildDAGWorkflow, len(dag.Workflows))
+	for _, workflow := range dag.Workflows {
+		workflowsByName[workflow.Name] = workflow
+	}
+	require.Len(t, workflowsByName, 3)
+	require.ElementsMatch(t, []string{"deploy.publish", "deploy.notify"}, workflowsByName["deploy"].Nodes)
+	require.Equal(t, []string{"release.package"}, workflowsByName["release"].Nodes)
+
+	// The indirected job includes the job it was indirected to, with its steps
+	test := nodesByID[".test"]
+	require.NotNil(t, test)
+	require.Equal(t, build.Jobs[0].ID, test.JobID)
+	require.NotNil(t, test.IndirectTo)
+	require.Equal(t, firstBuild.Jobs[0].ID, test.IndirectTo.JobID)
+	require.Equal(t, firstBuild.ID, test.IndirectTo.BuildID)
+	require.Equal(t, models.WorkflowStatusSucceeded, test.IndirectTo.Status)
+	require.Len(t, test.IndirectTo.Steps, 1)
+	require.Equal(t, models.WorkflowStatusSucceeded, test.IndirectTo.Steps[0].Status)
+
+	deferred := nodesByID["release.package"]
+	require.True(t, deferred.Deferred)
+	require.True(t, deferred.JobID.IsZero())
+	require.Equal(t, models.WorkflowStatusUnknown, deferred.Status)
+	require.ElementsMatch(t, []*documents.BuildDAGEdge{
+		{From: "release.package", To: "deploy.publish", Deferred: true},
+		{From: "deploy.publish", To: "deploy.notify"},
+	}, dag.Edges)
+}
@@ -0,0 +1,136 @@
+package api_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+// timelineEvent is a single server-sent event read from a build timeline stream.
+type timelineEvent struct {
+	id   string
+	name string
+	data string
+}
+
+func TestBuildTimeline(t *testing.T) {
+	ctx := context.Background()
+	server.BuildTimelinePollInterval = 100 * time.Millisecond
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateNamedRepo(t, ctx, app, "timeline", legalEntity.ID)
+	_, err = app.RepoService.UpdatePublicBuilds(ctx, repo.ID, dto.UpdateRepoPublicBuilds{PublicBuilds: true})
+	require.NoError(t, err)
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	build := enqueuePublicBuildsTestBuild(t, ctx, app, repo.ID, legalEntity.ID)
+
+	// Read the stream in the background until the server closes it
+	res, err := http.Get(app.CoreAPIServer.GetServerURL() + "/api/v1/public/builds/" + build.ID.String() + "/timeline")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	received := make(chan timelineEvent, 100)
+	go func() {
+		defer close(received)
+		var event timelineEvent
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if event.name != "" {
+					received <- event
+				}
+				event = timelineEvent{}
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	next := func() timelineEvent {
+		select {
+		case event, ok := <-received:
+			require.True(t, ok, "timeline stream closed unexpectedly")
+			return event
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "timed out waiting for timeline event")
+			return timelineEvent{}
+		}
+	}
+
+	// Existing jobs are announced before their first event
+	event := next()
+	for event.name != documents.BuildTimelineJobAddedEvent {
+		require.Equal(t, documents.BuildTimelineEventEvent, event.name)
+		require.NotEmpty(t, event.id)
+		event = next()
+	}
+	jobAdded := &documents.BuildTimelineJobAdded{}
+	require.NoError(t, json.Unmarshal([]byte(event.data), jobAdded))
+	require.Equal(t, build.Jobs[0].ID, jobAdded.Job.ID)
+	event = next()
+	require.Equal(t, documents.BuildTimelineEventEvent, event.name)
+	jobEvent := &documents.Event{}
+	require.NoError(t, json.Unmarshal([]byte(event.data), jobEvent))
+	require.Equal(t, models.JobStatusChangedEvent, jobEvent.Type)
+
+	// Run the job, writing to the step's log while it runs
+	job, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusRunning})
+	require.NoError(t, err)
+	step := job.Steps[0]
+	_, err = app.QueueService.UpdateStepStatus(ctx, nil, step.ID, dto.UpdateStepStatus{Status: models.WorkflowStatusRunning})
+	require.NoError(t, err)
+	entries, err := json.Marshal([]*models.LogEntry{models.NewLogEntryLine(1, models.NewTime(time.Now()), "hello world", 1, nil)})
+	require.NoError(t, err)
+	err = app.LogService.WriteData(ctx, step.LogDescriptorID, bytes.NewReader(entries))
+	require.NoError(t, err)
+
+	var logSize *documents.BuildTimelineLogSize
+	for logSize == nil {
+		event = next()
+		if event.name == documents.BuildTimelineLogSizeEvent {
+			logSize = &documents.BuildTimelineLogSize{}
+			require.NoError(t, json.Unmarshal([]byte(event.data), logSize))
+		}
+	}
+	require.Equal(t, step.ID.ResourceID, logSize.ResourceID)
+	require.Equal(t, step.LogDescriptorID, logSize.LogDescriptorID)
+	require.Greater(t, logSize.SizeBytes, int64(0))
+
+	// The stream ends once the build has finished
+	_, err = app.QueueService.UpdateStepStatus(ctx, nil, step.ID, dto.UpdateStepStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+	for event.name != documents.BuildTimelineEndEvent {
+		event = next()
+	}
+	_, ok := <-received
+	require.False(t, ok, "expected timeline stream to be closed after the end event")
+}
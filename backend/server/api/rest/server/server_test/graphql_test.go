@@ -0,0 +1,193 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client/clienttest"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+const graphQLTestBuildQuery = `
+query Build($id: ID!) {
+  build(id: $id) {
+    id
+    status
+    repo { id name }
+    log { id }
+    jobs {
+      id
+      name
+      runner { id name }
+      log { id }
+      steps { id name log { id } }
+      artifacts { id }
+    }
+  }
+}`
+
+type graphQLTestBuild struct {
+	Build *struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Repo   struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"repo"`
+		Log struct {
+			ID string `json:"id"`
+		} `json:"log"`
+		Jobs []struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			Runner *struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"runner"`
+			Log struct {
+				ID string `json:"id"`
+			} `json:"log"`
+			Steps []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+				Log  struct {
+					ID string `json:"id"`
+				} `json:"log"`
+			} `json:"steps"`
+			Artifacts []struct {
+				ID string `json:"id"`
+			} `json:"artifacts"`
+		} `json:"jobs"`
+	} `json:"build"`
+}
+
+func TestGraphQL(t *testing.T) {
+	ctx := context.Background()
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	legalEntityA, identityA := server_test.CreatePersonLegalEntity(t, ctx, app, "test", "Jim Bob", "jim@bob.com")
+	tokenA, _, err := app.CredentialService.CreateSharedSecretCredential(ctx, nil, identityA.ID, true)
+	require.Nil(t, err)
+	apiClientA, err := client.NewAPIClient(
+		[]string{app.CoreAPIServer.GetServerURL()},
+		client.NewSharedSecretAuthenticator(client.SharedSecretToken(tokenA.String()), app.LogFactory),
+		app.LogFactory)
+	require.Nil(t, err)
+
+	_, identityB := server_test.CreatePersonLegalEntity(t, ctx, app, "tester", "Jimmy Bibby", "jimmy@bibby.com")
+	tokenB, _, err := app.CredentialService.CreateSharedSecretCredential(ctx, nil, identityB.ID, true)
+	require.Nil(t, err)
+	apiClientB, err := client.NewAPIClient(
+		[]string{app.CoreAPIServer.GetServerURL()},
+		client.NewSharedSecretAuthenticator(client.SharedSecretToken(tokenB.String()), app.LogFactory),
+		app.LogFactory)
+	require.Nil(t, err)
+
+	_, clientCert := clienttest.MakeClientCertificateAPIClient(t, app)
+	runner := server_test.CreateRunner(t, ctx, app, "test", legalEntityA.ID, clientCert)
+	repo := server_test.CreateNamedRepo(t, ctx, app, "graphql", legalEntityA.ID)
+	build := enqueuePublicBuildsTestBuild(t, ctx, app, repo.ID, legalEntityA.ID)
+	_, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+
+	t.Run("BuildGraph", func(t *testing.T) {
+		res, err := apiClientA.GraphQL(ctx, graphQLTestBuildQuery, map[string]interface{}{"id": build.ID.String()})
+		require.NoError(t, err)
+		require.Empty(t, res.Errors)
+		data := &graphQLTestBuild{}
+		require.NoError(t, json.Unmarshal(res.Data, data))
+		require.NotNil(t, data.Build)
+		require.Equal(t, build.ID.String(), data.Build.ID)
+		require.Equal(t, repo.ID.String(), data.Build.Repo.ID)
+		require.Equal(t, build.LogDescriptorID.String(), data.Build.Log.ID)
+		require.Len(t, data.Build.Jobs, 1)
+		job := data.Build.Jobs[0]
+		require.Equal(t, build.Jobs[0].ID.String(), job.ID)
+		require.Equal(t, build.Jobs[0].LogDescriptorID.String(), job.Log.ID)
+		require.NotNil(t, job.Runner)
+		require.Equal(t, runner.ID.String(), job.Runner.ID)
+		require.Len(t, job.Steps, 1)
+		require.Equal(t, build.Jobs[0].Steps[0].ID.String(), job.Steps[0].ID)
+		require.Equal(t, build.Jobs[0].Steps[0].LogDescriptorID.String(), job.Steps[0].Log.ID)
+		require.NotNil(t, job.Artifacts)
+		require.Empty(t, job.Artifacts)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		res, err := apiClientB.GraphQL(ctx, graphQLTestBuildQuery, map[string]interface{}{"id": build.ID.String()})
+		require.NoError(t, err)
+		require.Len(t, res.Errors, 1)
+		require.Equal(t, []interface{}{"build"}, res.Errors[0].Path)
+		data := &graphQLTestBuild{}
+		require.NoError(t, json.Unmarshal(res.Data, data))
+		require.Nil(t, data.Build)
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		res, err := apiClientA.GraphQL(ctx, graphQLTestBuildQuery, map[string]interface{}{"id": repo.ID.String()})
+		require.NoError(t, err)
+		require.Len(t, res.Errors, 1)
+		require.Equal(t, "Invalid build id", res.Errors[0].Message)
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		res, err := apiClientA.GraphQL(ctx, `{ build(id: "x") { secret } }`, nil)
+		require.NoError(t, err)
+		require.Len(t, res.Errors, 1)
+		// Queries that fail validation aren't executed, so there is no data
+		require.Empty(t, res.Data)
+	})
+
+	t.Run("Introspection", func(t *testing.T) {
+		res, err := apiClientA.GraphQL(ctx, `{ __type(name: "Build") { name fields { name } } }`, nil)
+		require.NoError(t, err)
+		require.Empty(t, res.Errors)
+		data := &struct {
+			Type struct {
+				Name   string `json:"name"`
+				Fields []struct {
+					Name string `json:"name"`
+				} `json:"fields"`
+			} `json:"__type"`
+		}{}
+		require.NoError(t, json.Unmarshal(res.Data, data))
+		require.Equal(t, "Build", data.Type.Name)
+		var fields []string
+		for _, field := range data.Type.Fields {
+			fields = append(fields, field.Name)
+		}
+		require.Contains(t, fields, "jobs")
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, app.CoreAPIServer.GetServerURL()+"/api/v1/graphql/schema", nil)
+		require.NoError(t, err)
+		req.Header.Set("buildbeaver-token", tokenA.String())
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.True(t, strings.Contains(string(body), "type Query {"))
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		res, err := http.Post(app.CoreAPIServer.GetServerURL()+"/api/v1/graphql", "application/json",
+			strings.NewReader(`{"query": "{ build(id: \"x\") { id } }"}`))
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
@@ -46,6 +46,42 @@ func TestLogAPI(t *testing.T) {
 
 	t.Run("Single", testSingleLog(app, apiClient, build.ID))
 	t.Run("Merged", testMergedLogs(app, apiClient, build.ID))
+	t.Run("Retention", testLogRetention(app, apiClient, repo.ID, build.ID))
+	t.Run("WaitForSeal", testWaitForLogSeal(app, build.ID))
+}
+
+func testWaitForLogSeal(app *server_test.TestServer, buildID models.BuildID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		logDescriptor, err := app.LogService.Create(ctx, nil, models.NewLogDescriptor(
+			models.NewTime(time.Now()),
+			models.LogDescriptorID{},
+			buildID.ResourceID))
+		require.Nil(t, err)
+
+		// The wait expires if the log isn't sealed
+		read, err := app.LogService.ReadWithWait(ctx, logDescriptor.ID, 100*time.Millisecond)
+		require.Nil(t, err)
+		require.False(t, read.Sealed)
+
+		var waited *models.LogDescriptor
+		errChan := make(chan error, 1)
+		go func() {
+			var err error
+			waited, err = app.LogService.ReadWithWait(ctx, logDescriptor.ID, time.Minute)
+			errChan <- err
+		}()
+		err = app.LogService.Seal(ctx, nil, logDescriptor.ID)
+		require.Nil(t, err)
+		select {
+		case err := <-errChan:
+			require.Nil(t, err)
+			require.True(t, waited.Sealed)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for log to be sealed")
+		}
+	}
 }
 
 func testSingleLog(app *server_test.TestServer, client *client.APIClient, buildID models.BuildID) func(t *testing.T) {
@@ -239,6 +275,79 @@ func testMergedLogs(app *server_test.TestServer, client *client.APIClient, build
 	}
 }
 
+func testLogRetention(app *server_test.TestServer, client *client.APIClient, repoID models.RepoID, buildID models.BuildID) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		logDescriptor, err := app.LogService.Create(ctx, nil, models.NewLogDescriptor(
+			models.NewTime(time.Now()),
+			models.LogDescriptorID{},
+			buildID.ResourceID))
+		require.Nil(t, err)
+		entries, err := fillLogDescriptor(ctx, client, logDescriptor)
+		require.Nil(t, err)
+		err = app.LogService.Seal(ctx, nil, logDescriptor.ID)
+		require.Nil(t, err)
+
+		before, err := app.LogService.UsageForRepo(ctx, nil, repoID)
+		require.Nil(t, err)
+		require.Equal(t, repoID, before.RepoID)
+		require.GreaterOrEqual(t, before.LiveCount, int64(1))
+
+		// The new log is due for archival as soon as it's older than the cutoff
+		archivable, err := app.LogStore.ListArchivable(ctx, nil, models.NewTime(time.Now().Add(time.Second)), 100)
+		require.Nil(t, err)
+		require.Contains(t, logDescriptorIDs(archivable), logDescriptor.ID)
+
+		// Archive the log; it should remain readable and be accounted for as archived
+		err = app.LogService.Archive(ctx, logDescriptor.ID)
+		require.Nil(t, err)
+		reader, err := client.OpenLogReadStream(ctx, logDescriptor.ID, &documents.LogSearchRequest{LogSearch: &models.LogSearch{}})
+		require.Nil(t, err)
+		readData, err := ioutil.ReadAll(reader)
+		require.Nil(t, err)
+		reader.Close()
+		var read []*models.LogEntry
+		err = json.Unmarshal(readData, &read)
+		require.Nil(t, err)
+		require.Equal(t, read[len(read)-1].Kind, models.LogEntryKindEnd)
+		var written []*models.LogEntry
+		for _, entry := range entries {
+			written = append(written, entry.LogEntry)
+		}
+		require.True(t, structuredLogsEqual(written, read[:len(read)-1]))
+
+		archived, err := app.LogService.UsageForRepo(ctx, nil, repoID)
+		require.Nil(t, err)
+		require.Equal(t, before.LiveCount-1, archived.LiveCount)
+		require.Equal(t, before.ArchivedCount+1, archived.ArchivedCount)
+		require.Greater(t, archived.ArchivedSizeBytes, before.ArchivedSizeBytes)
+
+		archivable, err = app.LogStore.ListArchivable(ctx, nil, models.NewTime(time.Now().Add(time.Second)), 100)
+		require.Nil(t, err)
+		require.NotContains(t, logDescriptorIDs(archivable), logDescriptor.ID)
+
+		// Expire the log; its data should no longer be readable
+		err = app.LogService.Expire(ctx, logDescriptor.ID)
+		require.Nil(t, err)
+		_, err = client.OpenLogReadStream(ctx, logDescriptor.ID, &documents.LogSearchRequest{LogSearch: &models.LogSearch{}})
+		require.NotNil(t, err)
+
+		expired, err := app.LogService.UsageForRepo(ctx, nil, repoID)
+		require.Nil(t, err)
+		require.Equal(t, before.ArchivedCount, expired.ArchivedCount)
+		require.Equal(t, before.ExpiredCount+1, expired.ExpiredCount)
+	}
+}
+
+func logDescriptorIDs(descriptors []*models.LogDescriptor) []models.LogDescriptorID {
+	var ids []models.LogDescriptorID
+	for _, descriptor := range descriptors {
+		ids = append(ids, descriptor.ID)
+	}
+	return ids
+}
+
 type entryWithDescriptorID struct {
 	*models.LogEntry
 	LogDescriptorID models.LogDescriptorID
@@ -381,3 +490,55 @@ type basicReader struct {
 func (r *basicReader) Read(p []byte) (n int, err error) {
 	return r.r.Read(p)
 }
+
+func TestReadLogDataAsUser(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	legalEntity, identity := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	require.NotEmpty(t, graph.Jobs)
+	job := graph.Jobs[0]
+
+	sharedSecret, _, err := app.CredentialService.CreateSharedSecretCredential(ctx, nil, identity.ID, true)
+	require.NoError(t, err)
+	userClient, err := client.NewAPIClient(
+		[]string{app.CoreAPIServer.GetServerURL()},
+		client.NewSharedSecretAuthenticator(client.SharedSecretToken(sharedSecret.String()), app.LogFactory),
+		app.LogFactory)
+	require.Nil(t, err)
+
+	// Builds and jobs can be looked up to find their logs
+	buildDoc, err := userClient.GetBuild(ctx, graph.ID)
+	require.NoError(t, err)
+	require.Equal(t, graph.LogDescriptorID, buildDoc.Build.LogDescriptorID)
+	jobDoc, err := userClient.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, job.LogDescriptorID, jobDoc.LogDescriptorID)
+
+	entries := []*models.LogEntry{
+		models.NewLogEntryLine(1, models.NewTime(time.Now()), "hello", 1, nil),
+		models.NewLogEntryLine(2, models.NewTime(time.Now()), "world", 2, nil),
+	}
+	data, err := json.Marshal(entries)
+	require.NoError(t, err)
+	err = app.LogService.WriteData(ctx, job.LogDescriptorID, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	plaintext := true
+	search := documents.NewLogSearchRequest()
+	search.Plaintext = &plaintext
+	reader, err := userClient.ReadLogData(ctx, jobDoc.LogDescriptorID, search)
+	require.NoError(t, err)
+	defer reader.Close()
+	text, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "hello\nworld\n", string(text))
+}
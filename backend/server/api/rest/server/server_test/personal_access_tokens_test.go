@@ -0,0 +1,114 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	entity, identity := server_test.CreatePersonLegalEntity(t, ctx, app, "test", "Jim Bob", "jim@bob.com")
+	runner := server_test.CreateRunner(t, ctx, app, "", entity.ID, nil)
+
+	sharedSecret, _, err := app.CredentialService.CreateSharedSecretCredential(ctx, nil, identity.ID, true)
+	require.NoError(t, err)
+	userClient, err := client.NewAPIClient(
+		[]string{app.CoreAPIServer.GetServerURL()},
+		client.NewSharedSecretAuthenticator(client.SharedSecretToken(sharedSecret.String()), app.LogFactory),
+		app.LogFactory)
+	require.Nil(t, err)
+
+	expiresAt := models.NewTime(time.Now().Add(24 * time.Hour))
+
+	t.Run("CreateValidation", func(t *testing.T) {
+		_, err := userClient.CreatePersonalAccessToken(ctx, "bad scope", models.TokenScopes{"write:everything"}, expiresAt)
+		require.Error(t, err)
+		_, err = userClient.CreatePersonalAccessToken(ctx, "no scopes", models.TokenScopes{}, expiresAt)
+		require.Error(t, err)
+		_, err = userClient.CreatePersonalAccessToken(ctx, "expired", models.TokenScopes{models.TokenScopeReadBuild}, models.NewTime(time.Now().Add(-time.Hour)))
+		require.Error(t, err)
+		_, err = userClient.CreatePersonalAccessToken(ctx, "too long", models.TokenScopes{models.TokenScopeReadBuild}, models.NewTime(time.Now().Add(400*24*time.Hour)))
+		require.Error(t, err)
+	})
+
+	tokenDoc, err := userClient.CreatePersonalAccessToken(ctx, "build reader", models.TokenScopes{models.TokenScopeReadBuild}, expiresAt)
+	require.NoError(t, err)
+	require.NotEmpty(t, tokenDoc.Token)
+	require.Equal(t, "build reader", tokenDoc.Name)
+	require.Nil(t, tokenDoc.LastUsedAt)
+
+	tokenClient, err := client.NewAPIClient(
+		[]string{app.CoreAPIServer.GetServerURL()},
+		client.NewSharedSecretAuthenticator(client.SharedSecretToken(tokenDoc.Token), app.LogFactory),
+		app.LogFactory)
+	require.Nil(t, err)
+
+	t.Run("Scopes", func(t *testing.T) {
+		// read:build allows reading the legal entity, but not runners
+		_, err := tokenClient.GetLegalEntity(ctx, entity.ID)
+		require.NoError(t, err)
+		_, err = tokenClient.GetRunner(ctx, runner.ID)
+		require.Error(t, err)
+
+		// The same identity can read the runner when not limited by a token
+		_, err = userClient.GetRunner(ctx, runner.ID)
+		require.NoError(t, err)
+
+		// Personal access tokens can't be used to create more tokens
+		_, err = tokenClient.CreatePersonalAccessToken(ctx, "escalate", models.TokenScopes{models.TokenScopeAdminRunner}, expiresAt)
+		require.Error(t, err)
+	})
+
+	t.Run("LastUsed", func(t *testing.T) {
+		credential, err := app.CredentialStore.Read(ctx, nil, tokenDoc.ID)
+		require.NoError(t, err)
+		require.NotNil(t, credential.LastUsedAt)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		credential, err := app.CredentialStore.Read(ctx, nil, tokenDoc.ID)
+		require.NoError(t, err)
+		past := models.NewTime(time.Now().Add(-time.Minute))
+		credential.ExpiresAt = &past
+		err = app.CredentialStore.Update(ctx, nil, credential)
+		require.NoError(t, err)
+
+		_, err = tokenClient.GetLegalEntity(ctx, entity.ID)
+		require.Error(t, err)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		revokeDoc, err := userClient.CreatePersonalAccessToken(ctx, "to revoke", models.TokenScopes{models.TokenScopeAdminRunner}, expiresAt)
+		require.NoError(t, err)
+		revokeClient, err := client.NewAPIClient(
+			[]string{app.CoreAPIServer.GetServerURL()},
+			client.NewSharedSecretAuthenticator(client.SharedSecretToken(revokeDoc.Token), app.LogFactory),
+			app.LogFactory)
+		require.Nil(t, err)
+		_, err = revokeClient.GetRunner(ctx, runner.ID)
+		require.NoError(t, err)
+
+		err = userClient.RevokePersonalAccessToken(ctx, revokeDoc.ID)
+		require.NoError(t, err)
+		_, err = revokeClient.GetRunner(ctx, runner.ID)
+		require.Error(t, err)
+
+		// Revoking again should report the token no longer exists
+		err = userClient.RevokePersonalAccessToken(ctx, revokeDoc.ID)
+		require.Error(t, err)
+	})
+}
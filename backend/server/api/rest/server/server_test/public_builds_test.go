@@ -0,0 +1,99 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestPublicBuilds(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateNamedRepo(t, ctx, app, "public", legalEntity.ID)
+	privateRepo := server_test.CreateNamedRepo(t, ctx, app, "private", legalEntity.ID)
+	publicBuild := enqueuePublicBuildsTestBuild(t, ctx, app, repo.ID, legalEntity.ID)
+	privateBuild := enqueuePublicBuildsTestBuild(t, ctx, app, privateRepo.ID, legalEntity.ID)
+
+	publicURL := app.CoreAPIServer.GetServerURL() + "/api/v1/public"
+	get := func(path string) int {
+		res, err := http.Get(publicURL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		return res.StatusCode
+	}
+	publicPaths := []string{
+		"/repos/" + repo.ID.String(),
+		"/repos/" + repo.ID.String() + "/builds",
+		"/builds/" + publicBuild.ID.String(),
+		"/builds/" + publicBuild.ID.String() + "/dag",
+		"/jobs/" + publicBuild.Jobs[0].ID.String(),
+		"/logs/" + publicBuild.LogDescriptorID.String(),
+	}
+
+	// Builds can't be read anonymously until public builds are enabled for the repo
+	for _, path := range publicPaths[2:] {
+		require.Equal(t, http.StatusUnauthorized, get(path), path)
+	}
+	_, err = app.RepoService.UpdatePublicBuilds(ctx, repo.ID, dto.UpdateRepoPublicBuilds{PublicBuilds: true})
+	require.NoError(t, err)
+	for _, path := range publicPaths {
+		require.Equal(t, http.StatusOK, get(path), path)
+	}
+
+	// Other repos are still private
+	require.Equal(t, http.StatusUnauthorized, get("/repos/"+privateRepo.ID.String()))
+	require.Equal(t, http.StatusUnauthorized, get("/builds/"+privateBuild.ID.String()))
+
+	// The anonymous identity can't write, even to a public repo
+	authorized, err := app.AuthorizationService.IsAuthorized(ctx, models.AnonymousIdentityID, models.BuildUpdateOperation, publicBuild.ID.ResourceID)
+	require.NoError(t, err)
+	require.False(t, authorized)
+	res, err := http.Post(app.CoreAPIServer.GetServerURL()+"/api/v1/builds/"+publicBuild.ID.String()+"/clone", "application/json", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// Disabling public builds removes anonymous access again
+	_, err = app.RepoService.UpdatePublicBuilds(ctx, repo.ID, dto.UpdateRepoPublicBuilds{PublicBuilds: false})
+	require.NoError(t, err)
+	for _, path := range publicPaths[2:] {
+		require.Equal(t, http.StatusUnauthorized, get(path), path)
+	}
+}
+
+func enqueuePublicBuildsTestBuild(t *testing.T, ctx context.Context, app *server_test.TestServer, repoID models.RepoID, legalEntityID models.LegalEntityID) *dto.BuildGraph {
+	commit := server_test.CreateCommit(t, ctx, app, repoID, legalEntityID)
+	buildDef := &models.BuildDefinition{
+		Jobs: []models.JobDefinition{{
+			JobDefinitionData: models.JobDefinitionData{
+				Name:                    "test",
+				Type:                    models.JobTypeDocker,
+				DockerImage:             "alpine",
+				DockerImagePullStrategy: models.DockerPullStrategyDefault,
+				StepExecution:           models.StepExecutionSequential,
+			},
+			Steps: []models.StepDefinition{{
+				StepDefinitionData: models.StepDefinitionData{
+					Name:     "test",
+					Commands: models.Commands{"echo 'hello world'"},
+				},
+			}},
+		}},
+	}
+	bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repoID, commit.ID, buildDef, "refs/heads/main", nil)
+	require.NoError(t, err)
+	return bGraph
+}
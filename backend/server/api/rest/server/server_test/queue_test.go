@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -51,13 +52,47 @@ func TestQueueAPI(t *testing.T) {
 
 	// Repeat the queue (and dequeue) tests now we have a queued build for another company
 	t.Run("Queue segregation", testQueueBuild(app.QueueService, apiClient, commit))
+
+	t.Run("Dequeue wait", testDequeueWait(app.QueueService, apiClient, commit))
+}
+
+func testDequeueWait(service services.QueueService, client *client.APIClient, commit *models.Commit) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		// With nothing to dequeue the server waits before saying so
+		start := time.Now()
+		job, err := client.Dequeue(ctx, 1*time.Second)
+		require.NoError(t, err)
+		require.Nil(t, job)
+		require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+
+		// A job queued while waiting is returned straight away
+		type result struct {
+			job *documents.RunnableJob
+			err error
+		}
+		resultChan := make(chan result)
+		start = time.Now()
+		go func() {
+			job, err := client.Dequeue(ctx, 30*time.Second)
+			resultChan <- result{job: job, err: err}
+		}()
+		time.Sleep(200 * time.Millisecond)
+		_, err = service.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+		require.NoError(t, err)
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.NotNil(t, res.job)
+		require.Less(t, time.Since(start), 10*time.Second)
+	}
 }
 
 func testQueueBuild(service services.QueueService, client *client.APIClient, commit *models.Commit) func(t *testing.T) {
 	return func(t *testing.T) {
 		ctx := context.Background()
 
-		job, err := client.Dequeue(ctx)
+		job, err := client.Dequeue(ctx, 0)
 		require.Nil(t, err)
 
 		if job != nil {
@@ -72,7 +107,7 @@ func testQueueBuild(service services.QueueService, client *client.APIClient, com
 		t.Run("Dequeue job 3", testDequeueBuild(client))
 		t.Run("Dequeue job 4", testDequeueBuild(client))
 
-		job, err = client.Dequeue(ctx)
+		job, err = client.Dequeue(ctx, 0)
 		require.Nil(t, err)
 
 		if job != nil {
@@ -85,7 +120,7 @@ func testDequeueBuild(client *client.APIClient) func(t *testing.T) {
 	return func(t *testing.T) {
 		ctx := context.Background()
 
-		job, err := client.Dequeue(ctx)
+		job, err := client.Dequeue(ctx, 0)
 		require.Nil(t, err)
 
 		if job == nil {
@@ -0,0 +1,60 @@
+package api_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestStatusPages(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	app.CoreAPIServer.Start()
+	defer app.CoreAPIServer.Stop(ctx)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateNamedRepo(t, ctx, app, "status-pages", legalEntity.ID)
+	build := enqueuePublicBuildsTestBuild(t, ctx, app, repo.ID, legalEntity.ID)
+
+	statusURL := app.CoreAPIServer.GetServerURL() + "/status"
+	get := func(path string) (int, string) {
+		res, err := http.Get(statusURL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(body)
+	}
+
+	// Status pages for private repos can't be viewed anonymously
+	status, _ := get("/repos/" + repo.ID.String())
+	require.Equal(t, http.StatusUnauthorized, status)
+	status, _ = get("/builds/" + build.ID.String())
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	_, err = app.RepoService.UpdatePublicBuilds(ctx, repo.ID, dto.UpdateRepoPublicBuilds{PublicBuilds: true})
+	require.NoError(t, err)
+
+	// The repo page lists the build and links to it
+	status, body := get("/repos/" + repo.ID.String())
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, "/status/builds/"+build.ID.String())
+
+	// The build page shows each job, and reloads itself while the build is still running
+	status, body = get("/builds/" + build.ID.String())
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, "Build "+build.Name.String())
+	require.Contains(t, body, build.Jobs[0].Name.String())
+	require.Contains(t, body, `http-equiv="refresh"`)
+}
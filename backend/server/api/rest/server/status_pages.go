@@ -0,0 +1,287 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+const (
+	// statusPageBuildLimit is the maximum number of builds shown on a repo's status page.
+	statusPageBuildLimit = 50
+	// statusPageMaxLogBytes is the maximum amount of each job's log that is shown on a build's status
+	// page; if a log is longer than this then only the end of the log is shown.
+	statusPageMaxLogBytes = 256 * 1024
+	// statusPageRefreshSeconds is how often the status page for an unfinished build reloads itself
+	// to show the latest logs.
+	statusPageRefreshSeconds = 3
+)
+
+// StatusPagesAPI serves minimal server-rendered HTML pages showing the builds for a repo and the
+// status and logs of a build. These allow builds to be browsed on installs that don't deploy the
+// full frontend, including the local server started by bb.
+type StatusPagesAPI struct {
+	repoService  services.RepoService
+	buildService services.BuildService
+	queueService services.QueueService
+	logService   services.LogService
+	templates    *template.Template
+	*APIBase
+}
+
+func NewStatusPagesAPI(
+	repoService services.RepoService,
+	buildService services.BuildService,
+	queueService services.QueueService,
+	logService services.LogService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory,
+) *StatusPagesAPI {
+	return &StatusPagesAPI{
+		repoService:  repoService,
+		buildService: buildService,
+		queueService: queueService,
+		logService:   logService,
+		templates:    template.Must(template.New("status").Funcs(statusPageFuncs).Parse(statusPageTemplates)),
+		APIBase:      NewAPIBase(authorizationService, resourceLinker, logFactory("StatusPagesAPI")),
+	}
+}
+
+// StatusPagesRouterFactory returns a function that adds the status pages to a router, under /status.
+func StatusPagesRouterFactory(statusPages *StatusPagesAPI) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Route("/status", func(r chi.Router) {
+			r.Get("/repos/{repo_id}", statusPages.ListBuilds)
+			r.Get("/builds/{build_id}", statusPages.GetBuild)
+		})
+	}
+}
+
+// statusPage contains the fields used by the header of every status page.
+type statusPage struct {
+	Title string
+	// RefreshSeconds is how often the page reloads itself, or 0 if the page doesn't reload.
+	RefreshSeconds int
+}
+
+type statusPageError struct {
+	statusPage
+	Error gerror.Error
+}
+
+type statusPageBuildList struct {
+	statusPage
+	Repo   *models.Repo
+	Builds []*models.BuildSearchResult
+}
+
+type statusPageBuild struct {
+	statusPage
+	Build *dto.QueuedBuild
+	Jobs  []*statusPageJob
+}
+
+type statusPageJob struct {
+	*dto.JobGraph
+	Log string
+}
+
+// ListBuilds renders a page showing the most recent builds for a repo.
+func (a *StatusPagesAPI) ListBuilds(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.RepoReadOperation)
+	if err != nil {
+		a.PageError(w, r, err)
+		return
+	}
+	repo, err := a.repoService.Read(r.Context(), nil, repoID)
+	if err != nil {
+		a.PageError(w, r, err)
+		return
+	}
+	search := models.NewBuildSearchForRepo(repoID, "", false, nil, statusPageBuildLimit)
+	builds, _, err := a.buildService.Search(r.Context(), nil, a.MustAuthenticatedIdentityID(r), search)
+	if err != nil {
+		a.PageError(w, r, err)
+		return
+	}
+	a.Page(w, r, "build-list", &statusPageBuildList{
+		statusPage: statusPage{Title: repo.Name.String()},
+		Repo:       repo,
+		Builds:     builds,
+	})
+}
+
+// GetBuild renders a page showing the status of each job and step in a build, along with the job logs.
+// The page reloads itself until the build has finished so that logs can be followed as they are written.
+func (a *StatusPagesAPI) GetBuild(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.PageError(w, r, err)
+		return
+	}
+	build, err := a.queueService.ReadQueuedBuild(r.Context(), nil, buildID)
+	if err != nil {
+		a.PageError(w, r, err)
+		return
+	}
+	page := &statusPageBuild{
+		statusPage: statusPage{Title: "Build " + build.Name.String()},
+		Build:      build,
+	}
+	if !build.Status.HasFinished() {
+		page.RefreshSeconds = statusPageRefreshSeconds
+	}
+	for _, job := range build.Jobs {
+		log, err := a.readJobLog(r, job)
+		if err != nil {
+			a.PageError(w, r, err)
+			return
+		}
+		page.Jobs = append(page.Jobs, &statusPageJob{JobGraph: job, Log: log})
+	}
+	a.Page(w, r, "build", page)
+}
+
+// readJobLog reads the plaintext log for a job, including the logs for each of its steps. Only the
+// end of the log is returned if it is longer than statusPageMaxLogBytes.
+func (a *StatusPagesAPI) readJobLog(r *http.Request, job *dto.JobGraph) (string, error) {
+	t := true
+	reader, err := a.logService.ReadData(r.Context(), job.LogDescriptorID, &models.LogSearch{
+		Plaintext: &t,
+		Expand:    &t,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > statusPageMaxLogBytes {
+		data = append([]byte("...\n"), data[len(data)-statusPageMaxLogBytes:]...)
+	}
+	return string(data), nil
+}
+
+// Page renders the named template to the http response as an HTML page.
+func (a *StatusPagesAPI) Page(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	a.page(w, r, http.StatusOK, name, data)
+}
+
+// PageError renders the specified error to the http response as an HTML page. Errors are sanitized
+// for public display in the same way as API errors, and are logged to the server log at a Warning level.
+func (a *StatusPagesAPI) PageError(w http.ResponseWriter, r *http.Request, err error) {
+	a.Warnf("Error in status page: %v", err)
+	var gErr gerror.Error
+	if !errors.As(err, &gErr) || gErr.Audience() != gerror.AudienceExternal {
+		gErr = gerror.NewErrInternal()
+	}
+	a.page(w, r, gErr.HTTPStatusCode(), "error", &statusPageError{
+		statusPage: statusPage{Title: "Error"},
+		Error:      gErr,
+	})
+}
+
+func (a *StatusPagesAPI) page(w http.ResponseWriter, r *http.Request, statusCode int, name string, data interface{}) {
+	buf := &bytes.Buffer{}
+	err := a.templates.ExecuteTemplate(buf, name, data)
+	if err != nil {
+		a.Errorf("Error rendering status page %q: %v", name, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	w.Write(buf.Bytes())
+}
+
+var statusPageFuncs = template.FuncMap{
+	"shortSHA": func(sha string) string {
+		if len(sha) > 7 {
+			return sha[:7]
+		}
+		return sha
+	},
+}
+
+const statusPageTemplates = `
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{if .RefreshSeconds}}<meta http-equiv="refresh" content="{{.RefreshSeconds}}">{{end}}
+<title>{{.Title}} - BuildBeaver</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+td, th { padding: 0.3em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+pre { background: #111; color: #eee; padding: 1em; overflow-x: auto; }
+.status { font-weight: bold; }
+.status-succeeded { color: #1a7f37; }
+.status-failed, .status-canceled { color: #cf222e; }
+.status-running { color: #0969da; }
+.status-skipped { color: #777; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "status"}}<span class="status status-{{.}}">{{.}}</span>{{end}}
+
+{{define "build-list"}}{{template "header" .}}
+<h1>{{.Repo.Name}}</h1>
+{{if .Builds}}
+<table>
+<tr><th>Build</th><th>Ref</th><th>Commit</th><th>Status</th><th>Created</th></tr>
+{{range .Builds}}
+<tr>
+<td><a href="/status/builds/{{.ID}}">{{.Name}}</a></td>
+<td>{{.Ref}}</td>
+<td>{{if .Commit}}{{shortSHA .Commit.SHA}}{{end}}</td>
+<td>{{template "status" .Status}}</td>
+<td>{{.CreatedAt.Format "2006-01-02 15:04:05 MST"}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No builds yet.</p>
+{{end}}
+{{template "footer"}}{{end}}
+
+{{define "build"}}{{template "header" .}}
+<p><a href="/status/repos/{{.Build.RepoID}}">&larr; {{if .Build.Repo}}{{.Build.Repo.Name}}{{else}}All builds{{end}}</a></p>
+<h1>Build {{.Build.Name}} {{template "status" .Build.Status}}</h1>
+<p>{{.Build.Ref}}{{if .Build.Commit}} &middot; {{shortSHA .Build.Commit.SHA}} {{.Build.Commit.Message}}{{end}}</p>
+{{if .Build.Error}}<p class="status status-failed">{{.Build.Error}}</p>{{end}}
+{{range .Jobs}}
+<h2>{{if .Workflow}}{{.Workflow}}.{{end}}{{.Name}} {{template "status" .Status}}</h2>
+{{if .Error}}<p class="status status-failed">{{.Error}}</p>{{end}}
+<table>
+{{range .Steps}}<tr><td>{{.Name}}</td><td>{{template "status" .Status}}</td></tr>
+{{end}}
+</table>
+{{if .Log}}<pre>{{.Log}}</pre>{{end}}
+{{end}}
+{{template "footer"}}{{end}}
+
+{{define "error"}}{{template "header" .}}
+<h1>{{.Error.HTTPStatusCode}} {{.Error.Message}}</h1>
+{{template "footer"}}{{end}}
+`
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+type TestResultAPI struct {
+	testResultService services.TestResultService
+	flakyTestService  services.FlakyTestService
+	*APIBase
+}
+
+func NewTestResultAPI(
+	testResultService services.TestResultService,
+	flakyTestService services.FlakyTestService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *TestResultAPI {
+	return &TestResultAPI{
+		testResultService: testResultService,
+		flakyTestService:  flakyTestService,
+		APIBase:           NewAPIBase(authorizationService, resourceLinker, logFactory("TestResultAPI")),
+	}
+}
+
+// GetSummary returns the summary of test results for a build.
+func (a *TestResultAPI) GetSummary(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	summary, err := a.testResultService.ReadSummary(r.Context(), nil, buildID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.GotResource(w, r, documents.MakeTestSummary(routes.RequestCtx(r), summary))
+}
+
+// ListRuns returns the test runs (one per test report) for a build.
+func (a *TestResultAPI) ListRuns(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	testRuns, cursor, err := a.testResultService.ListRuns(r.Context(), nil, buildID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeTestRuns(routes.RequestCtx(r), testRuns)
+	res := documents.NewPaginatedResponse(models.TestRunResourceKind, routes.MakeTestRunsLink(routes.RequestCtx(r), buildID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListCases returns the test cases for a build, optionally filtered by test run, suite, name or status.
+func (a *TestResultAPI) ListCases(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewTestCaseSearchRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	// The test case list is embedded under a build in the API, so this search
+	// is always filtered to test cases for that build.
+	search.BuildID = &buildID
+	testCases, cursor, err := a.testResultService.SearchCases(r.Context(), nil, *search.TestCaseSearch)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeTestCases(routes.RequestCtx(r), testCases)
+	res := documents.NewPaginatedResponse(models.TestCaseResourceKind, routes.MakeTestCasesLink(routes.RequestCtx(r), buildID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListRepoSummaries returns the test summaries for builds in a repo, newest first, to show test trends over time.
+func (a *TestResultAPI) ListRepoSummaries(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	summaries, cursor, err := a.testResultService.ListSummaries(r.Context(), nil, repoID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeTestSummaries(routes.RequestCtx(r), summaries)
+	res := documents.NewPaginatedResponse(models.TestSummaryResourceKind, routes.MakeRepoTestSummariesLink(routes.RequestCtx(r), repoID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListRepoCases returns test cases across all builds in a repo, newest first. Filter by suite and name to
+// get the history of a single test.
+func (a *TestResultAPI) ListRepoCases(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewTestCaseSearchRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	// The test case list is embedded under a repo in the API, so this search
+	// is always filtered to test cases for that repo.
+	search.RepoID = &repoID
+	testCases, cursor, err := a.testResultService.SearchCases(r.Context(), nil, *search.TestCaseSearch)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeTestCases(routes.RequestCtx(r), testCases)
+	res := documents.NewPaginatedResponse(models.TestCaseResourceKind, routes.MakeRepoTestCasesLink(routes.RequestCtx(r), repoID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// ListRepoFlakyTests returns the tests in a repo that are known to be flaky, newest first.
+func (a *TestResultAPI) ListRepoFlakyTests(w http.ResponseWriter, r *http.Request) {
+	repoID, err := a.AuthorizedRepoID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	flakyTests, cursor, err := a.flakyTestService.ListFlakyTests(r.Context(), nil, repoID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeFlakyTests(routes.RequestCtx(r), flakyTests)
+	res := documents.NewPaginatedResponse(models.FlakyTestResourceKind, routes.MakeRepoFlakyTestsLink(routes.RequestCtx(r), repoID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// GetJobFlakyTests returns the failed tests in a job that are known to be flaky, annotating the job as having
+// failed due to known flaky tests if none of the other tests failed.
+func (a *TestResultAPI) GetJobFlakyTests(w http.ResponseWriter, r *http.Request) {
+	jobID, err := a.AuthorizedJobID(r, models.BuildReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	jobFlakyTests, err := a.flakyTestService.ReadJobFlakyTests(r.Context(), nil, jobID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.GotResource(w, r, documents.MakeJobFlakyTests(routes.RequestCtx(r), jobFlakyTests))
+}
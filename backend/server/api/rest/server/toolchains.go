@@ -0,0 +1,182 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// ToolchainAPI manages the toolchains registered by a legal entity. Toolchains are addressed via the legal
+// entity they belong to, and access is controlled by the operations granted on that legal entity. Builds can
+// also register toolchains for the legal entity that owns their repo via the Dynamic API.
+type ToolchainAPI struct {
+	toolchainService services.ToolchainService
+	*APIBase
+}
+
+func NewToolchainAPI(
+	toolchainService services.ToolchainService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *ToolchainAPI {
+	return &ToolchainAPI{
+		toolchainService: toolchainService,
+		APIBase:          NewAPIBase(authorizationService, resourceLinker, logFactory("ToolchainAPI")),
+	}
+}
+
+func (a *ToolchainAPI) Get(w http.ResponseWriter, r *http.Request) {
+	toolchain, err := a.authorizedToolchain(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeToolchain(routes.RequestCtx(r), toolchain)
+	a.GotResource(w, r, res)
+}
+
+func (a *ToolchainAPI) Create(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.authorizedLegalEntityID(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.RegisterToolchainRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	toolchain, err := a.toolchainService.Register(r.Context(), nil, dto.RegisterToolchain{
+		LegalEntityID:   legalEntityID,
+		Name:            req.Name,
+		Version:         req.Version,
+		Image:           req.Image,
+		Digest:          req.Digest,
+		Dockerfile:      req.Dockerfile,
+		RebuildWorkflow: req.RebuildWorkflow,
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeToolchain(routes.RequestCtx(r), toolchain)
+	a.CreatedResource(w, r, res, nil)
+}
+
+// Register registers a toolchain for the legal entity that owns the repo of the build in the request URL.
+// This is used by builds that produce their own build images.
+func (a *ToolchainAPI) Register(w http.ResponseWriter, r *http.Request) {
+	buildID, err := a.AuthorizedBuildID(r, models.ToolchainCreateOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	req := &documents.RegisterToolchainRequest{}
+	err = render.Bind(r, req)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	toolchain, err := a.toolchainService.RegisterForBuild(r.Context(), nil, buildID, dto.RegisterToolchain{
+		Name:            req.Name,
+		Version:         req.Version,
+		Image:           req.Image,
+		Digest:          req.Digest,
+		Dockerfile:      req.Dockerfile,
+		RebuildWorkflow: req.RebuildWorkflow,
+	})
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	res := documents.MakeToolchain(routes.RequestCtx(r), toolchain)
+	a.JSON(w, r, res)
+}
+
+func (a *ToolchainAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	toolchain, err := a.authorizedToolchain(r, true)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	err = a.toolchainService.Delete(r.Context(), nil, toolchain.ID)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List returns the toolchains belonging to a legal entity.
+func (a *ToolchainAPI) List(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.authorizedLegalEntityID(r, false)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	search := documents.NewListRequest()
+	err = search.FromQuery(r.URL.Query())
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	toolchains, cursor, err := a.toolchainService.ListByLegalEntityID(r.Context(), nil, legalEntityID, search.Pagination)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	docs := documents.MakeToolchains(routes.RequestCtx(r), toolchains)
+	res := documents.NewPaginatedResponse(models.ToolchainResourceKind, routes.MakeToolchainsLink(routes.RequestCtx(r), legalEntityID), search, docs, cursor)
+	a.JSON(w, r, res)
+}
+
+// authorizedLegalEntityID returns the ID of the legal entity in the request URL, after checking the
+// authenticated user has permission to read (or if update is true, update) it.
+func (a *ToolchainAPI) authorizedLegalEntityID(r *http.Request, update bool) (models.LegalEntityID, error) {
+	id, err := a.resourceLinker.GetLeafResourceID(r)
+	if err != nil {
+		return models.LegalEntityID{}, gerror.NewErrNotFound("Not Found").Wrap(err)
+	}
+	if id.Kind() != models.LegalEntityResourceKind {
+		return models.LegalEntityID{}, gerror.NewErrNotFound("Not Found")
+	}
+	operation := models.LegalEntityReadOperation
+	if update {
+		operation = models.LegalEntityUpdateOperation
+	}
+	err = a.Authorize(r, operation, id)
+	if err != nil {
+		return models.LegalEntityID{}, err
+	}
+	return models.LegalEntityIDFromResourceID(id), nil
+}
+
+// authorizedToolchain authorizes the request against the legal entity in the request URL, and then reads
+// the toolchain in the request URL, checking that it belongs to that legal entity.
+func (a *ToolchainAPI) authorizedToolchain(r *http.Request, update bool) (*models.Toolchain, error) {
+	legalEntityID, err := a.authorizedLegalEntityID(r, update)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseURLParamResourceID(r, "toolchain_id", models.ToolchainResourceKind)
+	if err != nil {
+		return nil, err
+	}
+	toolchain, err := a.toolchainService.Read(r.Context(), nil, models.ToolchainIDFromResourceID(id))
+	if err != nil {
+		return nil, err
+	}
+	if toolchain.LegalEntityID != legalEntityID {
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	return toolchain, nil
+}
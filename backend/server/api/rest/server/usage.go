@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/documents"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+type UsageAPI struct {
+	usageService services.UsageService
+	*APIBase
+}
+
+func NewUsageAPI(
+	usageService services.UsageService,
+	authorizationService services.AuthorizationService,
+	resourceLinker *routes.ResourceLinker,
+	logFactory logger.LogFactory) *UsageAPI {
+	return &UsageAPI{
+		usageService: usageService,
+		APIBase:      NewAPIBase(authorizationService, resourceLinker, logFactory("UsageAPI")),
+	}
+}
+
+// GetSummary returns the monthly usage of a legal entity for each month from the 'from' query parameter to
+// the 'to' query parameter inclusive, both in the form YYYY-MM. 'to' defaults to the current month, and 'from'
+// defaults to 'to'.
+func (a *UsageAPI) GetSummary(w http.ResponseWriter, r *http.Request) {
+	legalEntityID, err := a.AuthorizedLegalEntityID(r, models.LegalEntityReadOperation)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	to := models.UsagePeriodOf(time.Now())
+	if str := r.URL.Query().Get("to"); str != "" {
+		to, err = models.ParseUsagePeriod(str)
+		if err != nil {
+			a.Error(w, r, gerror.NewErrInvalidQueryParameter(fmt.Sprintf("invalid value for 'to' query parameter: %s", err)))
+			return
+		}
+	}
+	from := to
+	if str := r.URL.Query().Get("from"); str != "" {
+		from, err = models.ParseUsagePeriod(str)
+		if err != nil {
+			a.Error(w, r, gerror.NewErrInvalidQueryParameter(fmt.Sprintf("invalid value for 'from' query parameter: %s", err)))
+			return
+		}
+	}
+	summary, err := a.usageService.Summary(r.Context(), nil, legalEntityID, from, to)
+	if err != nil {
+		a.Error(w, r, err)
+		return
+	}
+	a.JSON(w, r, documents.MakeUsageSummary(routes.RequestCtx(r), summary))
+}
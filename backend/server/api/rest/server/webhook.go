@@ -1,45 +1,53 @@
 package server
 
 import (
+	"fmt"
+	"io/ioutil"
 	"net/http"
 
-	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/go-chi/chi/v5"
 
 	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
 	"github.com/buildbeaver/buildbeaver/server/services"
-	"github.com/buildbeaver/buildbeaver/server/services/scm"
 )
 
+// webhookMaxPayloadBytes is the maximum size of an incoming webhook payload. GitHub caps payloads at 25MB.
+const webhookMaxPayloadBytes = 25 * 1024 * 1024
+
 type WebhookAPI struct {
-	scmRegistry *scm.SCMRegistry
+	webhookDeliveryService services.WebhookDeliveryService
 	*APIBase
 }
 
 func NewWebhooksAPI(
-	scmRegistry *scm.SCMRegistry,
+	webhookDeliveryService services.WebhookDeliveryService,
 	authorizationService services.AuthorizationService,
 	resourceLinker *routes.ResourceLinker,
 	logFactory logger.LogFactory) *WebhookAPI {
 
 	return &WebhookAPI{
-		scmRegistry: scmRegistry,
-		APIBase:     NewAPIBase(authorizationService, resourceLinker, logFactory("WebhooksAPI")),
+		webhookDeliveryService: webhookDeliveryService,
+		APIBase:                NewAPIBase(authorizationService, resourceLinker, logFactory("WebhooksAPI")),
 	}
 }
 
+// HandleWebhook records a webhook delivered by an SCM and passes it to the SCM to process.
 func (a *WebhookAPI) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	scmName := models.SystemName(chi.URLParam(r, "scm"))
-	scm, err := a.scmRegistry.Get(scmName)
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxPayloadBytes))
 	if err != nil {
-		a.Error(w, r, err)
+		a.Error(w, r, fmt.Errorf("error reading webhook payload: %w", err))
 		return
 	}
-	handler, err := scm.WebhookHandler()
+	delivery, err := a.webhookDeliveryService.Receive(r.Context(), scmName, r.Header, payload)
 	if err != nil {
+		if delivery != nil {
+			err = fmt.Errorf("error processing webhook delivery %q: %w", delivery.ID, err)
+		}
 		a.Error(w, r, err)
 		return
 	}
-	handler(w, r)
+	w.WriteHeader(http.StatusOK)
 }
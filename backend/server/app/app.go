@@ -1,35 +1,70 @@
 package app
 
 import (
+	"github.com/buildbeaver/buildbeaver/common/tracing"
+	"github.com/buildbeaver/buildbeaver/server/api/grpc"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
 	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/email"
+	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/services/metrics_export"
+	"github.com/buildbeaver/buildbeaver/server/services/runner"
+	"github.com/buildbeaver/buildbeaver/server/services/runner_pool"
 	"github.com/buildbeaver/buildbeaver/server/services/scm"
+	"github.com/buildbeaver/buildbeaver/server/services/toolchain"
 )
 
 type Server struct {
-	LegalEntityService    services.LegalEntityService
-	RunnerService         services.RunnerService
-	SyncService           services.SyncService
-	CoreAPIServer         *server.AppAPIServer
-	RunnerAPIServer       *server.RunnerAPIServer
-	InternalRunnerManager *InternalRunnerManager
+	LegalEntityService      services.LegalEntityService
+	RunnerService           *runner.RunnerService
+	SyncService             services.SyncService
+	ArtifactScanService     services.ArtifactScanService
+	EmailService            *email.EmailService
+	MetricsExportService    *metrics_export.MetricsExportService
+	RunnerPoolService       *runner_pool.RunnerPoolService
+	ToolchainRebuildService *toolchain.ToolchainRebuildService
+	LogService              *log.LogService
+	LeaderElectionService   services.LeaderElectionService
+	CoreAPIServer           *server.AppAPIServer
+	RunnerAPIServer         *server.RunnerAPIServer
+	RunnerGRPCAPIServer     *grpc.Server
+	InternalRunnerManager   *InternalRunnerManager
+	Tracer                  *tracing.Tracer
 }
 
 func NewServer(
 	legalEntityService services.LegalEntityService,
-	runnerService services.RunnerService,
+	runnerService *runner.RunnerService,
 	syncService services.SyncService,
+	artifactScanService services.ArtifactScanService,
+	emailService *email.EmailService,
+	metricsExportService *metrics_export.MetricsExportService,
+	runnerPoolService *runner_pool.RunnerPoolService,
+	toolchainRebuildService *toolchain.ToolchainRebuildService,
+	logService *log.LogService,
+	leaderElectionService services.LeaderElectionService,
 	coreAPIServer *server.AppAPIServer,
 	runnerAPIServer *server.RunnerAPIServer,
+	runnerGRPCAPIServer *grpc.Server,
 	internalRunnerManager *InternalRunnerManager,
 	allSCMs []scm.SCM, // tell Wire the app has a dependency on the SCMs, to ensure they're created
+	tracer *tracing.Tracer,
 ) *Server {
 	return &Server{
-		LegalEntityService:    legalEntityService,
-		RunnerService:         runnerService,
-		SyncService:           syncService,
-		CoreAPIServer:         coreAPIServer,
-		RunnerAPIServer:       runnerAPIServer,
-		InternalRunnerManager: internalRunnerManager,
+		LegalEntityService:      legalEntityService,
+		RunnerService:           runnerService,
+		SyncService:             syncService,
+		ArtifactScanService:     artifactScanService,
+		EmailService:            emailService,
+		MetricsExportService:    metricsExportService,
+		RunnerPoolService:       runnerPoolService,
+		ToolchainRebuildService: toolchainRebuildService,
+		LogService:              logService,
+		LeaderElectionService:   leaderElectionService,
+		CoreAPIServer:           coreAPIServer,
+		RunnerAPIServer:         runnerAPIServer,
+		RunnerGRPCAPIServer:     runnerGRPCAPIServer,
+		InternalRunnerManager:   internalRunnerManager,
+		Tracer:                  tracer,
 	}
 }
@@ -6,21 +6,39 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	ghub "golang.org/x/oauth2/github"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
 	"github.com/buildbeaver/buildbeaver/common/dynamic_api"
 	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
+	"github.com/buildbeaver/buildbeaver/server/api/grpc"
+	bbmiddleware "github.com/buildbeaver/buildbeaver/server/api/rest/middleware"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
 	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/artifact"
+	"github.com/buildbeaver/buildbeaver/server/services/artifact_scan"
+	"github.com/buildbeaver/buildbeaver/server/services/authentication"
 	"github.com/buildbeaver/buildbeaver/server/services/blob"
+	"github.com/buildbeaver/buildbeaver/server/services/cache"
 	"github.com/buildbeaver/buildbeaver/server/services/credential"
+	"github.com/buildbeaver/buildbeaver/server/services/email"
 	"github.com/buildbeaver/buildbeaver/server/services/encryption"
+	"github.com/buildbeaver/buildbeaver/server/services/leader_election"
 	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/services/metrics_export"
+	"github.com/buildbeaver/buildbeaver/server/services/notification"
+	"github.com/buildbeaver/buildbeaver/server/services/oidc"
+	"github.com/buildbeaver/buildbeaver/server/services/outgoing_webhook"
 	"github.com/buildbeaver/buildbeaver/server/services/queue"
 	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
+	"github.com/buildbeaver/buildbeaver/server/services/runner"
+	"github.com/buildbeaver/buildbeaver/server/services/runner_pool"
 	"github.com/buildbeaver/buildbeaver/server/services/scm/github"
+	"github.com/buildbeaver/buildbeaver/server/services/sso"
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
@@ -30,6 +48,12 @@ const (
 
 	DefaultJWTCertFile       = "jwt-cert.pem"
 	DefaultJWTPrivateKeyFile = "jwt-private-key.pem"
+
+	DefaultArtifactSigningCertFile       = "artifact-signing-cert.pem"
+	DefaultArtifactSigningPrivateKeyFile = "artifact-signing-private-key.pem"
+
+	DefaultOIDCCertFile       = "oidc-cert.pem"
+	DefaultOIDCPrivateKeyFile = "oidc-private-key.pem"
 )
 
 // LogSafeFlags is a list of flags by name whose values are safe to log.
@@ -50,13 +74,49 @@ var LogSafeFlags = []string{
 	"runner_api_auto_create_certificate",
 	"runner_api_auto_created_certificate_host",
 	"runner_api_auto_created_certificate_organization",
+	"runner_grpc_api_server_address",
 	"dynamic_job_api_server_address",
 	"dev_internal_runner_config_directory",
 	"dev_start_internal_runners",
+	"dev_outgoing_webhook_allow_http",
 	"github_app_id",
 	"github_app_private_key_file_path",
 	"github_client_id",
 	"github_app_commit_status_target_url",
+	"tracing_otlp_endpoint",
+	"tracing_sample_ratio",
+	"artifact_scan_clamav_address",
+	"artifact_scan_timeout",
+	"artifact_signing_certificate_directory",
+	"artifact_signing_auto_create_key_pair",
+	"oidc_issuer_url",
+	"oidc_certificate_directory",
+	"oidc_auto_create_key_pair",
+	"oidc_token_expiry",
+	"sso_issuer_url",
+	"sso_client_id",
+	"sso_redirect_url",
+	"sso_scopes",
+	"sso_groups_claim",
+	"sso_group_mappings",
+	"saml_base_url",
+	"email_smtp_host",
+	"email_smtp_port",
+	"email_smtp_username",
+	"email_from_address",
+	"email_web_ui_base_url",
+	"email_digest_interval",
+	"metrics_export_interval",
+	"metrics_export_bigquery_project_id",
+	"metrics_export_bigquery_dataset_id",
+	"metrics_export_bigquery_table_id",
+	"metrics_export_bigquery_endpoint",
+	"cache_max_repo_size_bytes",
+	"cache_max_entry_size_bytes",
+	"log_archive_after_days",
+	"log_delete_after_days",
+	"log_archive_prefix",
+	"log_retention_interval",
 	"github_app_deploy_key_name",
 	"database_driver",
 	"log_levels",
@@ -103,18 +163,48 @@ func KeyManagerFactory(config EncryptionConfig, logFactory logger.LogFactory) (e
 }
 
 type ServerConfig struct {
-	CoreAPIConfig        server.AppAPIServerConfig
-	RunnerAPIConfig      server.RunnerAPIServerConfig
-	InternalRunnerConfig InternalRunnerConfig
-	AuthenticationConfig server.AuthenticationConfig
-	DatabaseConfig       store.DatabaseConfig
-	GitHubAppConfig      github.AppConfig
-	LogLevels            logger.LogLevelConfig
-	LogServiceConfig     log.LogServiceConfig
-	BlobStoreConfig      BlobStoreConfig
-	EncryptionConfig     EncryptionConfig
-	JWTConfig            credential.JWTConfig
-	LimitsConfig         queue.LimitsConfig
+	CoreAPIConfig         server.AppAPIServerConfig
+	RunnerAPIConfig       server.RunnerAPIServerConfig
+	RunnerGRPCAPIConfig   grpc.ServerConfig
+	InternalRunnerConfig  InternalRunnerConfig
+	AuthenticationConfig  server.AuthenticationConfig
+	DatabaseConfig        store.DatabaseConfig
+	NotifierConfig        store.NotifierConfig
+	LeaderElectionConfig  leader_election.LeaderElectionConfig
+	GitHubAppConfig       github.AppConfig
+	LogLevels             logger.LogLevelConfig
+	LogServiceConfig      log.LogServiceConfig
+	BlobStoreConfig       BlobStoreConfig
+	EncryptionConfig      EncryptionConfig
+	JWTConfig             credential.JWTConfig
+	LimitsConfig          queue.LimitsConfig
+	ImageConfig           queue.ImageConfig
+	RunnerHealthConfig    runner.RunnerHealthConfig
+	TracingConfig         tracing.Config
+	RateLimitsConfig      server.RateLimitsConfig
+	NotificationConfig    notification.NotificationServiceConfig
+	ArtifactScanConfig    artifact_scan.ArtifactScanServiceConfig
+	ArtifactSigningConfig artifact.ArtifactSigningConfig
+	OIDCConfig            oidc.OIDCConfig
+	SSOConfig             sso.SSOConfig
+	SAMLConfig            authentication.SAMLConfig
+	OutgoingWebhookConfig outgoing_webhook.OutgoingWebhookServiceConfig
+	EmailConfig           email.EmailServiceConfig
+	MetricsExportConfig   metrics_export.MetricsExportServiceConfig
+	CacheConfig           cache.CacheServiceConfig
+	RunnerPoolConfig      runner_pool.RunnerPoolServiceConfig
+}
+
+// rateLimitFlags defines the flags that configure the rate limits for a kind of API request.
+func rateLimitFlags(config *bbmiddleware.RateLimitConfig, name string, description string, defaults bbmiddleware.RateLimitConfig) {
+	flag.Float64Var(&config.PerIdentity.RequestsPerSecond, fmt.Sprintf("rate_limit_%s_per_identity", name),
+		defaults.PerIdentity.RequestsPerSecond, fmt.Sprintf("The average number of %s allowed per second for each identity. Set to zero to disable the limit.", description))
+	flag.IntVar(&config.PerIdentity.Burst, fmt.Sprintf("rate_limit_%s_per_identity_burst", name),
+		defaults.PerIdentity.Burst, fmt.Sprintf("The number of %s allowed at once for each identity.", description))
+	flag.Float64Var(&config.PerIP.RequestsPerSecond, fmt.Sprintf("rate_limit_%s_per_ip", name),
+		defaults.PerIP.RequestsPerSecond, fmt.Sprintf("The average number of %s allowed per second from each IP address. Set to zero to disable the limit.", description))
+	flag.IntVar(&config.PerIP.Burst, fmt.Sprintf("rate_limit_%s_per_ip_burst", name),
+		defaults.PerIP.Burst, fmt.Sprintf("The number of %s allowed at once from each IP address.", description))
 }
 
 func ConfigFromFlags() (*ServerConfig, error) {
@@ -122,6 +212,7 @@ func ConfigFromFlags() (*ServerConfig, error) {
 		localKeyManagerMasterKey           string
 		databaseDriverStr                  string
 		databaseConnectionString           string
+		notifierBackendStr                 string
 		gitHubPrivateKeyFilePath           string
 		gitHubPrivateKey                   string
 		logLevels                          string
@@ -129,7 +220,15 @@ func ConfigFromFlags() (*ServerConfig, error) {
 		coreAPISessionEncryptionKeyStr     string
 		runnerAPICertDir                   string
 		jwtCertDir                         string
+		artifactSigningCertDir             string
+		oidcCertDir                        string
+		ssoScopes                          string
+		ssoGroupMappings                   string
 		alternateYAMLFilename              string
+		tracingOTLPHeaders                 string
+		dockerRegistryRewrites             string
+		logArchiveAfterDays                int
+		logDeleteAfterDays                 int
 	)
 
 	// Pre-configure values in the server config
@@ -202,6 +301,8 @@ func ConfigFromFlags() (*ServerConfig, error) {
 		"runner.changeme.com", "The host to configure in the auto created Runner API server certificate.")
 	flag.StringVar(&config.RunnerAPIConfig.TLSConfig.AutoCreatedCertificateOrganization, "runner_api_auto_created_certificate_organization",
 		"BuildBeaver Limited", "The organization to configure in the auto created Runner API server certificate.")
+	flag.StringVar(&config.RunnerGRPCAPIConfig.Address, "runner_grpc_api_server_address",
+		"", "The interface and port to bind the gRPC Runner API server to, or empty to only serve the REST Runner API. The gRPC server uses the Runner API server's certificate.")
 
 	// Internal Runners
 	flag.StringVar((*string)(&config.InternalRunnerConfig.ConfigDir), "dev_internal_runner_config_directory",
@@ -222,6 +323,8 @@ func ConfigFromFlags() (*ServerConfig, error) {
 		github.DefaultCommitStatusTargetURL, "The base URL to pass to the SCM in commit status updates as the target URL")
 	flag.StringVar(&config.GitHubAppConfig.DeployKeyName, "github_app_deploy_key_name",
 		"buildbeaver-autogenerated", "The name of the deploy key to install into GitHub repos that are enabled in BuildBeaver.")
+	flag.StringVar(&config.GitHubAppConfig.WebhookSecret, "github_app_webhook_secret",
+		"", "The webhook secret configured for the GitHub App, used to verify incoming webhooks. If not set then webhook signatures are not verified.")
 
 	// Database
 	flag.StringVar(&databaseConnectionString, "database_connection_string",
@@ -232,6 +335,10 @@ func ConfigFromFlags() (*ServerConfig, error) {
 		store.DefaultDatabaseMaxIdleConnections, "The maximum number of idle database connections to use")
 	flag.IntVar(&config.DatabaseConfig.MaxOpenConnections, "database_max_open_connections",
 		store.DefaultDatabaseMaxOpenConnections, "The maximum number of open database connections to use")
+	flag.StringVar(&notifierBackendStr, "notifier_backend",
+		string(store.NotifierBackendAuto), "How servers notify each other of new work items and queued jobs, so they aren't only found by polling the database (i.e auto|postgres|local|none). auto uses postgres when the database is Postgres, and local otherwise; local only suits a single server.")
+	flag.DurationVar(&config.LeaderElectionConfig.LeaseDuration, "leader_lease_duration",
+		leader_election.DefaultLeaseDuration, "How long the leader's lease lasts if not renewed. When several servers share a database only the leader runs periodic background work (e.g. syncs and job timeout checks); if the leader stops without releasing its lease another server takes over after at most this long.")
 
 	// Limits
 	flag.IntVar(&config.LimitsConfig.MaxBuildConfigLength, "max_build_config_length",
@@ -241,6 +348,141 @@ func ConfigFromFlags() (*ServerConfig, error) {
 	flag.IntVar(&config.LimitsConfig.MaxStepsPerJob, "max_steps_per_job",
 		queue.DefaultMaxStepsPerJob, "The maximum number of steps allowed in any single job.")
 
+	// Docker images
+	flag.StringVar(&dockerRegistryRewrites, "docker_registry_rewrites",
+		"", "A comma separated list of registry=replacement pairs, to make runners pull Docker images from a different registry such as a pull-through mirror (e.g. docker.io=mirror.example.com:5000). The replacement may include a path prefix.")
+
+	// Runner health
+	flag.Uint64Var(&config.RunnerHealthConfig.MinDiskFreeBytes, "runner_min_disk_free_bytes",
+		runner.DefaultMinDiskFreeBytes, "The minimum free disk space a runner must report to be given jobs to run. Set to zero to disable the check.")
+	flag.DurationVar(&config.RunnerHealthConfig.MaxClockSkew, "runner_max_clock_skew",
+		runner.DefaultMaxClockSkew, "The maximum difference allowed between a runner's clock and the server's clock for the runner to be given jobs to run. Set to zero to disable the check.")
+	flag.DurationVar(&config.RunnerHealthConfig.OfflineAfter, "runner_offline_after",
+		runner.DefaultOfflineAfter, "The time after the last heartbeat from a runner at which the runner is marked as offline. Set to zero to disable the check.")
+
+	// Rate limits
+	rateLimitFlags(&config.RateLimitsConfig.Dequeue, "dequeue", "polls for jobs by runners", server.DefaultRateLimits.Dequeue)
+	rateLimitFlags(&config.RateLimitsConfig.LogWrite, "log_write", "log writes by runners", server.DefaultRateLimits.LogWrite)
+	rateLimitFlags(&config.RateLimitsConfig.EventFetch, "event_fetch", "build event fetches", server.DefaultRateLimits.EventFetch)
+
+	// Tracing
+	flag.StringVar(&config.TracingConfig.OTLPEndpoint, "tracing_otlp_endpoint",
+		"", "The base URL of an OpenTelemetry collector to export traces to using OTLP/HTTP (e.g. http://localhost:4318). Tracing is disabled if not set.")
+	flag.StringVar(&tracingOTLPHeaders, "tracing_otlp_headers",
+		"", "A comma separated list of key=value pairs to send as headers when exporting traces.")
+	flag.Float64Var(&config.TracingConfig.SampleRatio, "tracing_sample_ratio",
+		tracing.DefaultSampleRatio, "The fraction of new traces to record, between 0 and 1.")
+
+	// Notifications
+	flag.StringVar(&config.NotificationConfig.WebUIBaseURL, "notification_web_ui_base_url",
+		"", "The base URL of the web UI, used to link to builds in Slack and Discord notifications. Links are omitted if not set.")
+
+	// Email notifications
+	flag.StringVar(&config.EmailConfig.SMTPHost, "email_smtp_host",
+		"", "The host name of the SMTP server to send build notification emails via. Email notifications are disabled if not set.")
+	flag.IntVar(&config.EmailConfig.SMTPPort, "email_smtp_port",
+		email.DefaultSMTPPort, "The port of the SMTP server to send build notification emails via.")
+	flag.StringVar(&config.EmailConfig.SMTPUsername, "email_smtp_username",
+		"", "The username to authenticate to the SMTP server with. No authentication is used if not set.")
+	flag.StringVar(&config.EmailConfig.SMTPPassword, "email_smtp_password",
+		"", "The password to authenticate to the SMTP server with.")
+	flag.StringVar(&config.EmailConfig.FromAddress, "email_from_address",
+		"buildbeaver@localhost", "The address to send build notification emails from.")
+	flag.StringVar(&config.EmailConfig.WebUIBaseURL, "email_web_ui_base_url",
+		"", "The base URL of the web UI, used to link to builds in notification emails. Links are omitted if not set.")
+	flag.DurationVar(&config.EmailConfig.DigestInterval, "email_digest_interval",
+		email.DefaultDigestInterval, "How long to batch up notifications for before sending a digest email, for users who prefer digests.")
+
+	// Metrics export
+	flag.DurationVar(&config.MetricsExportConfig.Interval, "metrics_export_interval",
+		metrics_export.DefaultExportInterval, "How often to aggregate build and job metrics and export them to external systems.")
+	flag.StringVar(&config.MetricsExportConfig.PrometheusRemoteWriteURL, "metrics_export_prometheus_remote_write_url",
+		"", "The URL of a Prometheus remote write endpoint to export build and job metrics to. Not exported to Prometheus if not set.")
+	flag.StringVar(&config.MetricsExportConfig.PrometheusRemoteWriteBearerToken, "metrics_export_prometheus_remote_write_bearer_token",
+		"", "A bearer token to authenticate to the Prometheus remote write endpoint with.")
+	flag.StringVar(&config.MetricsExportConfig.BigQueryProjectID, "metrics_export_bigquery_project_id",
+		"", "The Google Cloud project containing the BigQuery table to export build and job metrics to. Not exported to BigQuery if not set.")
+	flag.StringVar(&config.MetricsExportConfig.BigQueryDatasetID, "metrics_export_bigquery_dataset_id",
+		"", "The BigQuery dataset containing the table to export build and job metrics to.")
+	flag.StringVar(&config.MetricsExportConfig.BigQueryTableID, "metrics_export_bigquery_table_id",
+		"", "The BigQuery table to export build and job metrics to.")
+	flag.StringVar(&config.MetricsExportConfig.BigQueryAccessToken, "metrics_export_bigquery_access_token",
+		"", "An OAuth2 access token to authenticate to BigQuery with. A token for the default service account is obtained from the GCE metadata server if not set.")
+	flag.StringVar(&config.MetricsExportConfig.BigQueryEndpoint, "metrics_export_bigquery_endpoint",
+		metrics_export.DefaultBigQueryEndpoint, "The base URL of the BigQuery API.")
+
+	// Runner pools
+	flag.DurationVar(&config.RunnerPoolConfig.ReconcileInterval, "runner_pool_reconcile_interval",
+		runner_pool.DefaultReconcileInterval, "How often to compare the number of queued jobs with the size of each runner pool, and start or stop runners to match.")
+	flag.BoolVar(&config.RunnerPoolConfig.AllowHTTP, "dev_runner_pool_allow_http",
+		false, "Allow runner pool webhook provisioners and Kubernetes API servers to be configured with plain http URLs. Only use this for development.")
+
+	// Build caches
+	flag.Uint64Var(&config.CacheConfig.MaxRepoSizeBytes, "cache_max_repo_size_bytes",
+		cache.DefaultMaxRepoSizeBytes, "The maximum total size of the build caches for each repo, after which the least recently used cache entries are evicted. Set to zero for no limit.")
+	flag.Uint64Var(&config.CacheConfig.MaxEntrySizeBytes, "cache_max_entry_size_bytes",
+		cache.DefaultMaxEntrySizeBytes, "The maximum size of a single build cache entry. Set to zero for no limit.")
+
+	// Log retention
+	flag.IntVar(&logArchiveAfterDays, "log_archive_after_days",
+		0, "The number of days after which a log's data is compressed and moved to the archive prefix in the blob store. Set to zero to disable archival.")
+	flag.IntVar(&logDeleteAfterDays, "log_delete_after_days",
+		0, "The number of days after which a log's data is deleted. Set to zero to disable deletion.")
+	flag.StringVar(&config.LogServiceConfig.ArchivePrefix, "log_archive_prefix",
+		log.DefaultArchivePrefix, "The blob store key prefix to move archived logs to.")
+	flag.DurationVar(&config.LogServiceConfig.RetentionInterval, "log_retention_interval",
+		log.DefaultRetentionInterval, "How often to archive and delete logs according to the log retention policy.")
+
+	// Outgoing webhooks
+	flag.BoolVar(&config.OutgoingWebhookConfig.AllowHTTP, "dev_outgoing_webhook_allow_http",
+		false, "Allow outgoing webhooks to be registered with plain http URLs. Only use this for development.")
+	flag.IntVar(&config.OutgoingWebhookConfig.MaxConsecutiveFailedDeliveries, "outgoing_webhook_max_consecutive_failed_deliveries",
+		outgoing_webhook.DefaultMaxConsecutiveFailedDeliveries, "The number of deliveries in a row that can fail before an outgoing webhook is disabled. Set to zero to never disable webhooks.")
+
+	// Artifact scanning
+	flag.StringVar(&config.ArtifactScanConfig.ClamAVAddress, "artifact_scan_clamav_address",
+		"", "The address of a ClamAV daemon (host:port or Unix socket path) to scan uploaded artifacts with. Takes precedence over --artifact_scan_external_url.")
+	flag.StringVar(&config.ArtifactScanConfig.ExternalScannerURL, "artifact_scan_external_url",
+		"", "The URL of an external HTTP service to scan uploaded artifacts with. Artifact scanning is disabled if neither this nor --artifact_scan_clamav_address is set.")
+	flag.DurationVar(&config.ArtifactScanConfig.ScanTimeout, "artifact_scan_timeout",
+		artifact_scan.DefaultScanTimeout, "The maximum time allowed to scan a single artifact.")
+
+	// Artifact signing
+	flag.StringVar(&artifactSigningCertDir, "artifact_signing_certificate_directory",
+		"", "The path on the local host containing the private key and public key (certificate) used for signing and verifying the hashes of uploaded artifacts. Artifact signing is disabled if not set.")
+	flag.BoolVar(&config.ArtifactSigningConfig.AutoCreateKeyPair, "artifact_signing_auto_create_key_pair",
+		false, "True to automatically create a key pair for signing and verifying artifact hashes, if not already configured.")
+
+	// OIDC tokens
+	flag.StringVar(&config.OIDCConfig.IssuerURL, "oidc_issuer_url",
+		"", "The public URL of the API server's OIDC routes (e.g. https://buildbeaver.example.com/api/v1/oidc), used as the issuer of OIDC tokens issued to jobs. OIDC tokens are disabled if not set.")
+	flag.StringVar(&oidcCertDir, "oidc_certificate_directory",
+		"", "The path on the local host containing the private key and public key (certificate) used for signing and verifying OIDC tokens issued to jobs.")
+	flag.BoolVar(&config.OIDCConfig.AutoCreateKeyPair, "oidc_auto_create_key_pair",
+		false, "True to automatically create a key pair for signing and verifying OIDC tokens, if not already configured.")
+	flag.DurationVar(&config.OIDCConfig.TokenExpiry, "oidc_token_expiry",
+		oidc.DefaultTokenExpiry, fmt.Sprintf("How long OIDC tokens issued to jobs are valid for, up to %s.", oidc.MaxTokenExpiry))
+
+	// Single sign-on
+	flag.StringVar(&config.SSOConfig.IssuerURL, "sso_issuer_url",
+		"", "The issuer URL of an OpenID Connect identity provider (e.g. Okta, Azure AD or Keycloak) to allow users to log in with. Single sign-on is disabled if not set.")
+	flag.StringVar(&config.SSOConfig.ClientID, "sso_client_id",
+		"", "The Client ID the server will present to the single sign-on identity provider.")
+	flag.StringVar(&config.SSOConfig.ClientSecret, "sso_client_secret",
+		"", "The Client Secret the server will present to the single sign-on identity provider.")
+	flag.StringVar(&config.SSOConfig.RedirectURL, "sso_redirect_url",
+		"", "The url the single sign-on identity provider will redirect to after authenticating users.")
+	flag.StringVar(&ssoScopes, "sso_scopes",
+		"profile,email", "A comma separated list of scopes to request from the single sign-on identity provider, in addition to openid.")
+	flag.StringVar(&config.SSOConfig.GroupsClaim, "sso_groups_claim",
+		sso.DefaultGroupsClaim, "The ID token claim that lists the groups a single sign-on user is a member of.")
+	flag.StringVar(&ssoGroupMappings, "sso_group_mappings",
+		"", "A comma separated list of provider-group=company/group mappings, making members of each identity provider group members of the access control group within the company.")
+
+	// SAML
+	flag.StringVar(&config.SAMLConfig.BaseURL, "saml_base_url",
+		"", "The public URL of the API server's SAML routes (e.g. https://buildbeaver.example.com/api/v1/authentication/saml), used to identify the server to each company's SAML identity provider. SAML authentication is disabled if not set.")
+
 	// Misc
 	flag.StringVar(&logLevels, "log_levels",
 		"", fmt.Sprintf("A comma separated list of name=level pairs where name is the name of the logger and level is one of: %s", logger.ListLogLevels()))
@@ -276,11 +518,44 @@ func ConfigFromFlags() (*ServerConfig, error) {
 	// Runner API
 	config.RunnerAPIConfig.TLSConfig.CertificateFile = certificates.CertificateFile(filepath.Join(runnerAPICertDir, DefaultServerCertFile))
 	config.RunnerAPIConfig.TLSConfig.PrivateKeyFile = certificates.PrivateKeyFile(filepath.Join(runnerAPICertDir, DefaultServerPrivateKeyFile))
+	config.RunnerGRPCAPIConfig.TLSConfig = config.RunnerAPIConfig.TLSConfig
 
 	// JWT tokens
 	config.JWTConfig.CertificateFile = certificates.CertificateFile(filepath.Join(jwtCertDir, DefaultJWTCertFile))
 	config.JWTConfig.PrivateKeyFile = certificates.PrivateKeyFile(filepath.Join(jwtCertDir, DefaultJWTPrivateKeyFile))
 
+	// Artifact signing
+	if artifactSigningCertDir != "" {
+		config.ArtifactSigningConfig.CertificateFile = certificates.CertificateFile(filepath.Join(artifactSigningCertDir, DefaultArtifactSigningCertFile))
+		config.ArtifactSigningConfig.PrivateKeyFile = certificates.PrivateKeyFile(filepath.Join(artifactSigningCertDir, DefaultArtifactSigningPrivateKeyFile))
+	}
+
+	// OIDC tokens
+	if config.OIDCConfig.Enabled() {
+		if oidcCertDir == "" {
+			return nil, errors.New("--oidc_certificate_directory must be set when --oidc_issuer_url is set")
+		}
+		config.OIDCConfig.CertificateFile = certificates.CertificateFile(filepath.Join(oidcCertDir, DefaultOIDCCertFile))
+		config.OIDCConfig.PrivateKeyFile = certificates.PrivateKeyFile(filepath.Join(oidcCertDir, DefaultOIDCPrivateKeyFile))
+	}
+
+	// Single sign-on
+	if config.SSOConfig.Enabled() {
+		if config.SSOConfig.ClientID == "" || config.SSOConfig.RedirectURL == "" {
+			return nil, errors.New("--sso_client_id and --sso_redirect_url must be set when --sso_issuer_url is set")
+		}
+		for _, scope := range strings.Split(ssoScopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				config.SSOConfig.Scopes = append(config.SSOConfig.Scopes, scope)
+			}
+		}
+		groupMappings, err := sso.ParseGroupMappings(ssoGroupMappings)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing --sso_group_mappings: %w", err)
+		}
+		config.SSOConfig.GroupMappings = groupMappings
+	}
+
 	// GitHub App
 	if gitHubPrivateKeyFilePath != "" {
 		config.GitHubAppConfig.PrivateKeyProvider = github.MakeFilePathPrivateKeyProvider(gitHubPrivateKeyFilePath)
@@ -293,10 +568,28 @@ func ConfigFromFlags() (*ServerConfig, error) {
 	// Database
 	config.DatabaseConfig.Driver = store.DBDriver(databaseDriverStr)
 	config.DatabaseConfig.ConnectionString = store.DatabaseConnectionString(databaseConnectionString)
+	config.NotifierConfig.Backend = store.NotifierBackend(notifierBackendStr)
+
+	// Tracing
+	config.TracingConfig.ServiceName = "bb-server"
+	tracingHeaders, err := tracing.ParseHeaders(tracingOTLPHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing --tracing_otlp_headers: %w", err)
+	}
+	config.TracingConfig.OTLPHeaders = tracingHeaders
+
+	// Docker images
+	registryRewrites, err := models.ParseDockerRegistryRewrites(dockerRegistryRewrites)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing --docker_registry_rewrites: %w", err)
+	}
+	config.ImageConfig.RegistryRewrites = registryRewrites
 
 	// Misc
 	config.LogLevels = logger.LogLevelConfig(logLevels)
-	config.LogServiceConfig = log.LogServiceConfig{WriterConfig: log.DefaultWriterConfig}
+	config.LogServiceConfig.WriterConfig = log.DefaultWriterConfig
+	config.LogServiceConfig.ArchiveAfter = time.Duration(logArchiveAfterDays) * 24 * time.Hour
+	config.LogServiceConfig.DeleteAfter = time.Duration(logDeleteAfterDays) * 24 * time.Hour
 	if alternateYAMLFilename != "" {
 		// Add alternate to start of the YAMLBuildConfigFileNames list not the end, to make it highest priority
 		parser.YAMLBuildConfigFileNames = append([]string{alternateYAMLFilename}, parser.YAMLBuildConfigFileNames...)
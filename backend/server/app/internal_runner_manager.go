@@ -199,10 +199,12 @@ func (m *InternalRunnerManager) createRunner(legalEntity *models.LegalEntity) (*
 	runnerCertFile := certificates.CertificateFile(filepath.Join(runnerConfigDir, app.DefaultRunnerCertFile))
 	runnerPrivateKeyFile := certificates.PrivateKeyFile(filepath.Join(runnerConfigDir, app.DefaultRunnerPrivateKeyFile))
 	runnerLogTempDir := logging.RunnerLogTempDirectory(filepath.Join(runnerConfigDir, app.DefaultRunnerLogTempDirName))
+	runnerSpoolDir := runner.RunnerSpoolDirectory(filepath.Join(runnerConfigDir, app.DefaultRunnerSpoolDirName))
 
 	runnerConfig := &app.RunnerConfig{
 		RunnerAPIEndpoints:    []string{m.runnerAPIServer.GetServerURL()},
 		RunnerLogTempDir:      runnerLogTempDir,
+		RunnerSpoolDir:        runnerSpoolDir,
 		RunnerCertificateFile: runnerCertFile,
 		RunnerPrivateKeyFile:  runnerPrivateKeyFile,
 		AutoCreateCertificate: true,
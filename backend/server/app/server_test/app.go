@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/server/api/grpc"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
 	"github.com/buildbeaver/buildbeaver/server/services"
 	"github.com/buildbeaver/buildbeaver/server/services/scm"
@@ -49,10 +50,36 @@ type TestServer struct {
 	WorkQueueService           services.WorkQueueService
 	EventService               services.EventService
 	ArtifactService            services.ArtifactService
+	NotificationService        services.NotificationService
+	ArtifactScanService        services.ArtifactScanService
+	OutgoingWebhookService     services.OutgoingWebhookService
+	WebhookDeliveryService     services.WebhookDeliveryService
+	CustomStatusService        services.CustomStatusService
+	EmailService               services.EmailService
+	BuildRuleSetService        services.BuildRuleSetService
+	MetricsExportService       services.MetricsExportService
+	RunnerPoolService          services.RunnerPoolService
+	ToolchainService           services.ToolchainService
+	ToolchainRebuildService    services.ToolchainRebuildService
+	OIDCService                services.OIDCService
+	SecretService              services.SecretService
+	TestResultService          services.TestResultService
+	FlakyTestService           services.FlakyTestService
+	BuildComparisonService     services.BuildComparisonService
+	JobAnalyticsService        services.JobAnalyticsService
+	CoverageService            services.CoverageService
+	CacheService               services.CacheService
+	AuthenticationService      services.AuthenticationService
+	RoleService                services.RoleService
+	PermissionOverrideService  services.PermissionOverrideService
+	UsageService               services.UsageService
+	LeaderLeaseStore           store.LeaderLeaseStore
+	LeaderElectionService      services.LeaderElectionService
 	LogFactory                 logger.LogFactory
 
-	CoreAPIServer   *server.AppAPIServer
-	RunnerAPIServer *server.RunnerAPIServer
+	CoreAPIServer       *server.AppAPIServer
+	RunnerAPIServer     *server.RunnerAPIServer
+	RunnerGRPCAPIServer *grpc.Server
 }
 
 func NewTestServer(
@@ -96,9 +123,35 @@ func NewTestServer(
 	workQueueService services.WorkQueueService,
 	eventService services.EventService,
 	artifactService services.ArtifactService,
+	notificationService services.NotificationService,
+	artifactScanService services.ArtifactScanService,
+	outgoingWebhookService services.OutgoingWebhookService,
+	webhookDeliveryService services.WebhookDeliveryService,
+	customStatusService services.CustomStatusService,
+	emailService services.EmailService,
+	buildRuleSetService services.BuildRuleSetService,
+	metricsExportService services.MetricsExportService,
+	runnerPoolService services.RunnerPoolService,
+	toolchainService services.ToolchainService,
+	toolchainRebuildService services.ToolchainRebuildService,
+	oidcService services.OIDCService,
+	secretService services.SecretService,
+	testResultService services.TestResultService,
+	flakyTestService services.FlakyTestService,
+	buildComparisonService services.BuildComparisonService,
+	jobAnalyticsService services.JobAnalyticsService,
+	coverageService services.CoverageService,
+	cacheService services.CacheService,
+	authenticationService services.AuthenticationService,
+	roleService services.RoleService,
+	permissionOverrideService services.PermissionOverrideService,
+	usageService services.UsageService,
+	leaderLeaseStore store.LeaderLeaseStore,
+	leaderElectionService services.LeaderElectionService,
 	logFactory logger.LogFactory,
 	coreAPIServer *server.AppAPIServer,
 	runnerAPIServer *server.RunnerAPIServer,
+	runnerGRPCAPIServer *grpc.Server,
 	allSCMs []scm.SCM, // tell Wire the app has a dependency on the SCMs, to ensure they're created
 ) *TestServer {
 	return &TestServer{
@@ -142,8 +195,34 @@ func NewTestServer(
 		WorkQueueService:           workQueueService,
 		EventService:               eventService,
 		ArtifactService:            artifactService,
+		NotificationService:        notificationService,
+		ArtifactScanService:        artifactScanService,
+		OutgoingWebhookService:     outgoingWebhookService,
+		WebhookDeliveryService:     webhookDeliveryService,
+		CustomStatusService:        customStatusService,
+		EmailService:               emailService,
+		BuildRuleSetService:        buildRuleSetService,
+		MetricsExportService:       metricsExportService,
+		RunnerPoolService:          runnerPoolService,
+		ToolchainService:           toolchainService,
+		ToolchainRebuildService:    toolchainRebuildService,
+		OIDCService:                oidcService,
+		SecretService:              secretService,
+		TestResultService:          testResultService,
+		FlakyTestService:           flakyTestService,
+		BuildComparisonService:     buildComparisonService,
+		JobAnalyticsService:        jobAnalyticsService,
+		CoverageService:            coverageService,
+		CacheService:               cacheService,
+		AuthenticationService:      authenticationService,
+		RoleService:                roleService,
+		PermissionOverrideService:  permissionOverrideService,
+		UsageService:               usageService,
+		LeaderLeaseStore:           leaderLeaseStore,
+		LeaderElectionService:      leaderElectionService,
 		LogFactory:                 logFactory,
 		CoreAPIServer:              coreAPIServer,
 		RunnerAPIServer:            runnerAPIServer,
+		RunnerGRPCAPIServer:        runnerGRPCAPIServer,
 	}
 }
@@ -5,13 +5,19 @@ import (
 	"testing"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/server/api/grpc"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
 	"github.com/buildbeaver/buildbeaver/server/app"
+	"github.com/buildbeaver/buildbeaver/server/services/artifact"
 	"github.com/buildbeaver/buildbeaver/server/services/blob"
+	"github.com/buildbeaver/buildbeaver/server/services/cache"
 	"github.com/buildbeaver/buildbeaver/server/services/credential"
 	"github.com/buildbeaver/buildbeaver/server/services/encryption"
 	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/services/oidc"
+	"github.com/buildbeaver/buildbeaver/server/services/outgoing_webhook"
 	"github.com/buildbeaver/buildbeaver/server/services/queue"
+	"github.com/buildbeaver/buildbeaver/server/services/runner"
 	"github.com/buildbeaver/buildbeaver/server/services/scm/github"
 	"github.com/buildbeaver/buildbeaver/server/services/scm/github/github_test_utils"
 )
@@ -26,6 +32,15 @@ func TestConfig(t *testing.T) *app.ServerConfig {
 	var test256bitKey [32]byte
 	copy(test256bitKey[:], test256bitKeyStr)
 
+	// The REST and gRPC runner API servers share a server certificate
+	runnerAPITLSConfig := &server.TLSConfig{
+		CertificateFile:       certificates.CertificateFile(filepath.Join(configDir, app.DefaultServerCertFile)),
+		PrivateKeyFile:        certificates.PrivateKeyFile(filepath.Join(configDir, app.DefaultServerPrivateKeyFile)),
+		AutoCreateCertificate: true,
+		// The REST test server makes its own certificate, but the gRPC server needs a host for the one it creates
+		AutoCreatedCertificateHost: "localhost",
+	}
+
 	return &app.ServerConfig{
 		EncryptionConfig: app.EncryptionConfig{
 			KeyManagerType:           encryption.LocalKeyManagerType.String(),
@@ -43,15 +58,15 @@ func TestConfig(t *testing.T) *app.ServerConfig {
 		},
 		RunnerAPIConfig: server.RunnerAPIServerConfig{
 			HTTPServerConfig: server.HTTPServerConfig{
-				Address: "", // Test is expected to use httptest server which picks its own address
-				TLSConfig: &server.TLSConfig{
-					CertificateFile:       certificates.CertificateFile(filepath.Join(configDir, app.DefaultServerCertFile)),
-					PrivateKeyFile:        certificates.PrivateKeyFile(filepath.Join(configDir, app.DefaultServerPrivateKeyFile)),
-					AutoCreateCertificate: true,
-				},
+				Address:      "", // Test is expected to use httptest server which picks its own address
+				TLSConfig:    runnerAPITLSConfig,
 				DockerBridge: false, // runner API does not need to be available to docker containers
 			},
 		},
+		RunnerGRPCAPIConfig: grpc.ServerConfig{
+			Address:   "127.0.0.1:0", // listen on a random port; tests find the address via GetAddress()
+			TLSConfig: runnerAPITLSConfig,
+		},
 		InternalRunnerConfig: app.InternalRunnerConfig{
 			StartInternalRunners: false,
 		},
@@ -72,10 +87,34 @@ func TestConfig(t *testing.T) *app.ServerConfig {
 			PrivateKeyFile:    certificates.PrivateKeyFile(filepath.Join(configDir, app.DefaultJWTPrivateKeyFile)),
 			AutoCreateKeyPair: true,
 		},
+		ArtifactSigningConfig: artifact.ArtifactSigningConfig{
+			CertificateFile:   certificates.CertificateFile(filepath.Join(configDir, app.DefaultArtifactSigningCertFile)),
+			PrivateKeyFile:    certificates.PrivateKeyFile(filepath.Join(configDir, app.DefaultArtifactSigningPrivateKeyFile)),
+			AutoCreateKeyPair: true,
+		},
+		OIDCConfig: oidc.OIDCConfig{
+			IssuerURL:         "https://buildbeaver.example.com/api/v1/oidc",
+			CertificateFile:   certificates.CertificateFile(filepath.Join(configDir, app.DefaultOIDCCertFile)),
+			PrivateKeyFile:    certificates.PrivateKeyFile(filepath.Join(configDir, app.DefaultOIDCPrivateKeyFile)),
+			AutoCreateKeyPair: true,
+			TokenExpiry:       oidc.DefaultTokenExpiry,
+		},
 		LimitsConfig: queue.LimitsConfig{
 			MaxBuildConfigLength: queue.DefaultMaxBuildConfigLength,
 			MaxJobsPerBuild:      queue.DefaultMaxJobsPerBuild,
 			MaxStepsPerJob:       queue.DefaultMaxStepsPerJob,
 		},
+		RunnerHealthConfig: runner.RunnerHealthConfig{
+			MinDiskFreeBytes: runner.DefaultMinDiskFreeBytes,
+			MaxClockSkew:     runner.DefaultMaxClockSkew,
+			OfflineAfter:     runner.DefaultOfflineAfter,
+		},
+		CacheConfig: cache.CacheServiceConfig{
+			MaxRepoSizeBytes:  cache.DefaultMaxRepoSizeBytes,
+			MaxEntrySizeBytes: cache.DefaultMaxEntrySizeBytes,
+		},
+		OutgoingWebhookConfig: outgoing_webhook.OutgoingWebhookServiceConfig{
+			MaxConsecutiveFailedDeliveries: outgoing_webhook.DefaultMaxConsecutiveFailedDeliveries,
+		},
 	}
 }
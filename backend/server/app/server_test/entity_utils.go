@@ -113,6 +113,8 @@ func CreateSecret(t *testing.T, ctx context.Context, app *TestServer, repoID mod
 		DataKeyEncrypted: []byte{152, 78, 223, 173, 64, 147, 46, 56, 5, 28, 178, 80, 75, 38, 5, 192, 153, 240, 212, 64, 252, 206, 201, 52, 240, 83, 160, 79, 218, 40, 144, 9, 3, 65, 183, 76, 17, 44, 9, 21, 6, 71, 118, 16, 206, 112, 40, 46, 210, 44, 217, 87, 237, 182, 155, 111, 54, 170, 10, 205},
 		IsInternal:       false,
 		RepoID:           repoID,
+		Version:          1,
+		VersionCreatedAt: models.NewTime(now),
 	}
 
 	err := app.SecretStore.Create(ctx, nil, randomSecret)
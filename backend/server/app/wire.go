@@ -10,54 +10,103 @@ import (
 	"github.com/google/wire"
 
 	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
+	"github.com/buildbeaver/buildbeaver/server/api/grpc"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/routes"
 	"github.com/buildbeaver/buildbeaver/server/api/rest/server"
 	"github.com/buildbeaver/buildbeaver/server/services"
 	"github.com/buildbeaver/buildbeaver/server/services/artifact"
+	"github.com/buildbeaver/buildbeaver/server/services/artifact_scan"
 	"github.com/buildbeaver/buildbeaver/server/services/authentication"
 	"github.com/buildbeaver/buildbeaver/server/services/authorization"
 	"github.com/buildbeaver/buildbeaver/server/services/build"
+	"github.com/buildbeaver/buildbeaver/server/services/build_comparison"
+	"github.com/buildbeaver/buildbeaver/server/services/build_rule_set"
+	"github.com/buildbeaver/buildbeaver/server/services/cache"
+	"github.com/buildbeaver/buildbeaver/server/services/coverage"
 	"github.com/buildbeaver/buildbeaver/server/services/credential"
+	"github.com/buildbeaver/buildbeaver/server/services/custom_status"
+	"github.com/buildbeaver/buildbeaver/server/services/email"
 	"github.com/buildbeaver/buildbeaver/server/services/encryption"
 	"github.com/buildbeaver/buildbeaver/server/services/event"
+	"github.com/buildbeaver/buildbeaver/server/services/flaky"
 	"github.com/buildbeaver/buildbeaver/server/services/group"
 	"github.com/buildbeaver/buildbeaver/server/services/job"
+	"github.com/buildbeaver/buildbeaver/server/services/job_analytics"
 	"github.com/buildbeaver/buildbeaver/server/services/keypair"
+	"github.com/buildbeaver/buildbeaver/server/services/leader_election"
 	"github.com/buildbeaver/buildbeaver/server/services/legal_entity"
 	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/services/metrics_export"
+	"github.com/buildbeaver/buildbeaver/server/services/notification"
+	"github.com/buildbeaver/buildbeaver/server/services/oidc"
+	"github.com/buildbeaver/buildbeaver/server/services/outgoing_webhook"
+	"github.com/buildbeaver/buildbeaver/server/services/permission_override"
 	"github.com/buildbeaver/buildbeaver/server/services/pull_request"
 	"github.com/buildbeaver/buildbeaver/server/services/queue"
 	"github.com/buildbeaver/buildbeaver/server/services/repo"
+	"github.com/buildbeaver/buildbeaver/server/services/role"
 	"github.com/buildbeaver/buildbeaver/server/services/runner"
+	"github.com/buildbeaver/buildbeaver/server/services/runner_pool"
 	"github.com/buildbeaver/buildbeaver/server/services/scm"
 	"github.com/buildbeaver/buildbeaver/server/services/scm/github"
 	"github.com/buildbeaver/buildbeaver/server/services/secret"
+	"github.com/buildbeaver/buildbeaver/server/services/sso"
 	"github.com/buildbeaver/buildbeaver/server/services/step"
 	"github.com/buildbeaver/buildbeaver/server/services/sync"
+	"github.com/buildbeaver/buildbeaver/server/services/test_result"
+	"github.com/buildbeaver/buildbeaver/server/services/toolchain"
+	"github.com/buildbeaver/buildbeaver/server/services/usage"
+	"github.com/buildbeaver/buildbeaver/server/services/webhook_delivery"
 	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
 	"github.com/buildbeaver/buildbeaver/server/store"
 	"github.com/buildbeaver/buildbeaver/server/store/artifacts"
 	"github.com/buildbeaver/buildbeaver/server/store/authorizations"
+	"github.com/buildbeaver/buildbeaver/server/store/build_coverages"
+	"github.com/buildbeaver/buildbeaver/server/store/build_rule_sets"
 	"github.com/buildbeaver/buildbeaver/server/store/builds"
+	"github.com/buildbeaver/buildbeaver/server/store/cache_entries"
 	"github.com/buildbeaver/buildbeaver/server/store/commits"
+	"github.com/buildbeaver/buildbeaver/server/store/coverage_reports"
 	"github.com/buildbeaver/buildbeaver/server/store/credentials"
+	"github.com/buildbeaver/buildbeaver/server/store/custom_statuses"
+	"github.com/buildbeaver/buildbeaver/server/store/email_digest_entries"
+	"github.com/buildbeaver/buildbeaver/server/store/email_preferences"
 	"github.com/buildbeaver/buildbeaver/server/store/events"
+	"github.com/buildbeaver/buildbeaver/server/store/flaky_tests"
 	"github.com/buildbeaver/buildbeaver/server/store/grants"
 	"github.com/buildbeaver/buildbeaver/server/store/group_memberships"
 	"github.com/buildbeaver/buildbeaver/server/store/groups"
 	"github.com/buildbeaver/buildbeaver/server/store/identities"
 	"github.com/buildbeaver/buildbeaver/server/store/jobs"
+	"github.com/buildbeaver/buildbeaver/server/store/leader_leases"
 	"github.com/buildbeaver/buildbeaver/server/store/legal_entities"
 	"github.com/buildbeaver/buildbeaver/server/store/legal_entity_memberships"
 	"github.com/buildbeaver/buildbeaver/server/store/logs"
+	"github.com/buildbeaver/buildbeaver/server/store/metrics_samples"
 	"github.com/buildbeaver/buildbeaver/server/store/migrations"
+	"github.com/buildbeaver/buildbeaver/server/store/notification_settings"
+	"github.com/buildbeaver/buildbeaver/server/store/outgoing_webhook_deliveries"
+	"github.com/buildbeaver/buildbeaver/server/store/outgoing_webhooks"
 	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
 	"github.com/buildbeaver/buildbeaver/server/store/pull_requests"
 	"github.com/buildbeaver/buildbeaver/server/store/repos"
 	"github.com/buildbeaver/buildbeaver/server/store/resource_links"
+	"github.com/buildbeaver/buildbeaver/server/store/role_assignments"
+	"github.com/buildbeaver/buildbeaver/server/store/roles"
+	"github.com/buildbeaver/buildbeaver/server/store/runner_pools"
 	"github.com/buildbeaver/buildbeaver/server/store/runners"
+	"github.com/buildbeaver/buildbeaver/server/store/saml_identity_providers"
+	"github.com/buildbeaver/buildbeaver/server/store/secret_versions"
 	"github.com/buildbeaver/buildbeaver/server/store/secrets"
 	"github.com/buildbeaver/buildbeaver/server/store/steps"
+	"github.com/buildbeaver/buildbeaver/server/store/test_cases"
+	"github.com/buildbeaver/buildbeaver/server/store/test_runs"
+	"github.com/buildbeaver/buildbeaver/server/store/test_summaries"
+	"github.com/buildbeaver/buildbeaver/server/store/toolchains"
+	"github.com/buildbeaver/buildbeaver/server/store/usage_quotas"
+	"github.com/buildbeaver/buildbeaver/server/store/usage_records"
+	"github.com/buildbeaver/buildbeaver/server/store/webhook_deliveries"
 	"github.com/buildbeaver/buildbeaver/server/store/work_item_states"
 	"github.com/buildbeaver/buildbeaver/server/store/work_items"
 )
@@ -69,7 +118,9 @@ func MakeSCMs(
 	commitStore store.CommitStore,
 	buildStore store.BuildStore,
 	pullRequestService services.PullRequestService,
+	buildRuleSetService services.BuildRuleSetService,
 	legalEntityService services.LegalEntityService,
+	authorizationService services.AuthorizationService,
 	queueService services.QueueService,
 	workQueueService services.WorkQueueService,
 	groupService services.GroupService,
@@ -83,7 +134,9 @@ func MakeSCMs(
 		commitStore,
 		buildStore,
 		pullRequestService,
+		buildRuleSetService,
 		legalEntityService,
+		authorizationService,
 		queueService,
 		workQueueService,
 		groupService,
@@ -103,9 +156,10 @@ func MakeWorkQueueService(
 	db *store.DB,
 	workItemStore store.WorkItemStore,
 	stateStore store.WorkItemStateStore,
+	notifier store.Notifier,
 	logFactory logger.LogFactory,
 ) *work_queue.WorkQueueService {
-	service := work_queue.NewWorkQueueService(db, workItemStore, stateStore, logFactory)
+	service := work_queue.NewWorkQueueService(db, workItemStore, stateStore, notifier, logFactory)
 	service.Start()
 	return service
 }
@@ -113,9 +167,11 @@ func MakeWorkQueueService(
 func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 	panic(wire.Build(
 		NewServer,
-		wire.FieldsOf(new(*ServerConfig), "BlobStoreConfig", "EncryptionConfig", "CoreAPIConfig", "RunnerAPIConfig", "InternalRunnerConfig", "AuthenticationConfig", "DatabaseConfig", "GitHubAppConfig", "LogLevels", "LogServiceConfig", "JWTConfig", "LimitsConfig"),
+		wire.FieldsOf(new(*ServerConfig), "BlobStoreConfig", "EncryptionConfig", "CoreAPIConfig", "RunnerAPIConfig", "RunnerGRPCAPIConfig", "InternalRunnerConfig", "AuthenticationConfig", "DatabaseConfig", "GitHubAppConfig", "LogLevels", "LogServiceConfig", "JWTConfig", "LimitsConfig", "ImageConfig", "RunnerHealthConfig", "NotificationConfig", "ArtifactScanConfig", "ArtifactSigningConfig", "OIDCConfig", "SSOConfig", "SAMLConfig", "OutgoingWebhookConfig", "EmailConfig", "MetricsExportConfig", "CacheConfig", "RunnerPoolConfig", "RateLimitsConfig", "NotifierConfig", "LeaderElectionConfig", "TracingConfig"),
+		tracing.NewTracer,
 		scm.NewSCMRegistry,
 		store.NewDatabase,
+		store.NewNotifier,
 		migrations.NewBBGolangMigrateRunner,
 		wire.Bind(new(store.MigrationRunner), new(*migrations.GolangMigrateRunner)),
 
@@ -132,6 +188,26 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		wire.Bind(new(store.StepStore), new(*steps.StepStore)),
 		secrets.NewStore,
 		wire.Bind(new(store.SecretStore), new(*secrets.SecretStore)),
+		secret_versions.NewStore,
+		wire.Bind(new(store.SecretVersionStore), new(*secret_versions.SecretVersionStore)),
+		notification_settings.NewStore,
+		wire.Bind(new(store.NotificationSettingStore), new(*notification_settings.NotificationSettingStore)),
+		build_rule_sets.NewStore,
+		wire.Bind(new(store.BuildRuleSetStore), new(*build_rule_sets.BuildRuleSetStore)),
+		email_preferences.NewStore,
+		wire.Bind(new(store.EmailPreferenceStore), new(*email_preferences.EmailPreferenceStore)),
+		email_digest_entries.NewStore,
+		wire.Bind(new(store.EmailDigestEntryStore), new(*email_digest_entries.EmailDigestEntryStore)),
+		metrics_samples.NewStore,
+		wire.Bind(new(store.MetricsSampleStore), new(*metrics_samples.MetricsSampleStore)),
+		outgoing_webhooks.NewStore,
+		wire.Bind(new(store.OutgoingWebhookStore), new(*outgoing_webhooks.OutgoingWebhookStore)),
+		runner_pools.NewStore,
+		wire.Bind(new(store.RunnerPoolStore), new(*runner_pools.RunnerPoolStore)),
+		outgoing_webhook_deliveries.NewStore,
+		wire.Bind(new(store.OutgoingWebhookDeliveryStore), new(*outgoing_webhook_deliveries.OutgoingWebhookDeliveryStore)),
+		webhook_deliveries.NewStore,
+		wire.Bind(new(store.WebhookDeliveryStore), new(*webhook_deliveries.WebhookDeliveryStore)),
 		ownerships.NewStore,
 		wire.Bind(new(store.OwnershipStore), new(*ownerships.OwnershipStore)),
 		legal_entities.NewStore,
@@ -152,6 +228,30 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		wire.Bind(new(store.GrantStore), new(*grants.GrantStore)),
 		artifacts.NewStore,
 		wire.Bind(new(store.ArtifactStore), new(*artifacts.ArtifactStore)),
+		custom_statuses.NewStore,
+		wire.Bind(new(store.CustomStatusStore), new(*custom_statuses.CustomStatusStore)),
+		toolchains.NewStore,
+		wire.Bind(new(store.ToolchainStore), new(*toolchains.ToolchainStore)),
+		usage_records.NewStore,
+		wire.Bind(new(store.UsageRecordStore), new(*usage_records.UsageRecordStore)),
+		usage_quotas.NewStore,
+		wire.Bind(new(store.UsageQuotaStore), new(*usage_quotas.UsageQuotaStore)),
+		leader_leases.NewStore,
+		wire.Bind(new(store.LeaderLeaseStore), new(*leader_leases.LeaderLeaseStore)),
+		test_runs.NewStore,
+		wire.Bind(new(store.TestRunStore), new(*test_runs.TestRunStore)),
+		test_cases.NewStore,
+		wire.Bind(new(store.TestCaseStore), new(*test_cases.TestCaseStore)),
+		test_summaries.NewStore,
+		wire.Bind(new(store.TestSummaryStore), new(*test_summaries.TestSummaryStore)),
+		flaky_tests.NewStore,
+		wire.Bind(new(store.FlakyTestStore), new(*flaky_tests.FlakyTestStore)),
+		coverage_reports.NewStore,
+		wire.Bind(new(store.CoverageReportStore), new(*coverage_reports.CoverageReportStore)),
+		build_coverages.NewStore,
+		wire.Bind(new(store.BuildCoverageStore), new(*build_coverages.BuildCoverageStore)),
+		cache_entries.NewStore,
+		wire.Bind(new(store.CacheEntryStore), new(*cache_entries.CacheEntryStore)),
 		runners.NewStore,
 		wire.Bind(new(store.RunnerStore), new(*runners.RunnerStore)),
 		resource_links.NewStore,
@@ -166,6 +266,12 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		wire.Bind(new(store.WorkItemStateStore), new(*work_item_states.WorkItemStateStore)),
 		events.NewStore,
 		wire.Bind(new(store.EventStore), new(*events.EventStore)),
+		saml_identity_providers.NewStore,
+		wire.Bind(new(store.SAMLIdentityProviderStore), new(*saml_identity_providers.SAMLIdentityProviderStore)),
+		roles.NewStore,
+		wire.Bind(new(store.RoleStore), new(*roles.RoleStore)),
+		role_assignments.NewStore,
+		wire.Bind(new(store.RoleAssignmentStore), new(*role_assignments.RoleAssignmentStore)),
 
 		// Services
 		queue.NewQueueService,
@@ -176,10 +282,30 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		wire.Bind(new(services.EncryptionService), new(*encryption.EncryptionService)),
 		secret.NewSecretService,
 		wire.Bind(new(services.SecretService), new(*secret.SecretService)),
+		build_rule_set.NewBuildRuleSetService,
+		wire.Bind(new(services.BuildRuleSetService), new(*build_rule_set.BuildRuleSetService)),
+		email.NewEmailService,
+		wire.Bind(new(services.EmailService), new(*email.EmailService)),
+		metrics_export.NewMetricsExportService,
+		wire.Bind(new(services.MetricsExportService), new(*metrics_export.MetricsExportService)),
+		notification.NewNotificationService,
+		wire.Bind(new(services.NotificationService), new(*notification.NotificationService)),
+		outgoing_webhook.NewOutgoingWebhookService,
+		wire.Bind(new(services.OutgoingWebhookService), new(*outgoing_webhook.OutgoingWebhookService)),
+		webhook_delivery.NewWebhookDeliveryService,
+		wire.Bind(new(services.WebhookDeliveryService), new(*webhook_delivery.WebhookDeliveryService)),
+		runner_pool.NewRunnerPoolService,
+		wire.Bind(new(services.RunnerPoolService), new(*runner_pool.RunnerPoolService)),
+		artifact_scan.NewArtifactScanService,
+		wire.Bind(new(services.ArtifactScanService), new(*artifact_scan.ArtifactScanService)),
 		authorization.NewAuthorizationService,
 		wire.Bind(new(services.AuthorizationService), new(*authorization.AuthorizationService)),
 		group.NewGroupService,
 		wire.Bind(new(services.GroupService), new(*group.GroupService)),
+		role.NewRoleService,
+		wire.Bind(new(services.RoleService), new(*role.RoleService)),
+		permission_override.NewPermissionOverrideService,
+		wire.Bind(new(services.PermissionOverrideService), new(*permission_override.PermissionOverrideService)),
 		authentication.NewAuthenticationService,
 		wire.Bind(new(services.AuthenticationService), new(*authentication.AuthenticationService)),
 		credential.NewCredentialService,
@@ -196,6 +322,32 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		wire.Bind(new(services.KeyPairService), new(*keypair.KeyPairService)),
 		build.NewBuildService,
 		wire.Bind(new(services.BuildService), new(*build.BuildService)),
+		custom_status.NewCustomStatusService,
+		wire.Bind(new(services.CustomStatusService), new(*custom_status.CustomStatusService)),
+		toolchain.NewToolchainService,
+		wire.Bind(new(services.ToolchainService), new(*toolchain.ToolchainService)),
+		usage.NewUsageService,
+		wire.Bind(new(services.UsageService), new(*usage.UsageService)),
+		leader_election.NewLeaderElectionService,
+		wire.Bind(new(services.LeaderElectionService), new(*leader_election.LeaderElectionService)),
+		oidc.NewOIDCService,
+		wire.Bind(new(services.OIDCService), new(*oidc.OIDCService)),
+		sso.NewSSOService,
+		wire.Bind(new(services.SSOService), new(*sso.SSOService)),
+		toolchain.NewToolchainRebuildService,
+		wire.Bind(new(services.ToolchainRebuildService), new(*toolchain.ToolchainRebuildService)),
+		test_result.NewTestResultService,
+		wire.Bind(new(services.TestResultService), new(*test_result.TestResultService)),
+		flaky.NewFlakyTestService,
+		wire.Bind(new(services.FlakyTestService), new(*flaky.FlakyTestService)),
+		build_comparison.NewBuildComparisonService,
+		wire.Bind(new(services.BuildComparisonService), new(*build_comparison.BuildComparisonService)),
+		job_analytics.NewJobAnalyticsService,
+		wire.Bind(new(services.JobAnalyticsService), new(*job_analytics.JobAnalyticsService)),
+		coverage.NewCoverageService,
+		wire.Bind(new(services.CoverageService), new(*coverage.CoverageService)),
+		cache.NewCacheService,
+		wire.Bind(new(services.CacheService), new(*cache.CacheService)),
 		job.NewJobService,
 		wire.Bind(new(services.JobService), new(*job.JobService)),
 		step.NewStepService,
@@ -218,8 +370,23 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		server.NewQueueAPI,
 		server.NewWebhooksAPI,
 		server.NewSecretAPI,
+		server.NewNotificationSettingAPI,
+		server.NewEmailPreferenceAPI,
+		server.NewSAMLIdentityProviderAPI,
+		server.NewRoleAPI,
+		server.NewPermissionAPI,
+		server.NewUsageAPI,
+		server.NewBuildRuleSetAPI,
+		server.NewOutgoingWebhookAPI,
+		server.NewRunnerPoolAPI,
 		server.NewCoreAuthenticationAPI,
 		server.NewArtifactAPI,
+		server.NewCustomStatusAPI,
+		server.NewToolchainAPI,
+		server.NewOIDCAPI,
+		server.NewTestResultAPI,
+		server.NewCoverageAPI,
+		server.NewCacheAPI,
 		server.NewRootAPI,
 		server.NewLegalEntityAPI,
 		server.NewRepoAPI,
@@ -229,7 +396,10 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		server.NewDynamicJobAPI,
 		server.NewStepAPI,
 		server.NewSearchAPI,
+		server.NewStatusPagesAPI,
 		server.NewTokenExchangeAPI,
+		server.NewPersonalAccessTokenAPI,
+		server.NewGraphQLAPI,
 
 		// HTTP Servers
 		server.NewAppAPIServer,
@@ -238,6 +408,10 @@ func New(ctx context.Context, config *ServerConfig) (*Server, func(), error) {
 		server.NewRunnerAPIRouter,
 		server.RealHTTPServerFactory,
 
+		// gRPC Servers
+		grpc.NewRunnerAPI,
+		grpc.NewServer,
+
 		MakeSCMs,
 		NewInternalRunnerManager,
 		logger.NewLogRegistry,
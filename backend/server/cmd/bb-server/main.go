@@ -30,6 +30,17 @@ func main() {
 	defer cleanup()
 	app.CoreAPIServer.Start()
 	app.RunnerAPIServer.Start()
+	app.RunnerGRPCAPIServer.Start()
+	app.EmailService.Start()
+	defer app.EmailService.Stop()
+	app.MetricsExportService.Start()
+	defer app.MetricsExportService.Stop()
+	app.RunnerPoolService.Start()
+	defer app.RunnerPoolService.Stop()
+	app.RunnerService.StartOfflineDetection()
+	defer app.RunnerService.StopOfflineDetection()
+	app.LogService.StartRetention(app.LeaderElectionService)
+	defer app.LogService.StopRetention()
 
 	if config.InternalRunnerConfig.StartInternalRunners {
 		err = app.InternalRunnerManager.Start()
@@ -54,5 +65,10 @@ func main() {
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	err = app.RunnerGRPCAPIServer.Stop(ctx)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	app.Tracer.Shutdown(ctx)
 	log.Print("Server shutdown complete")
 }
@@ -0,0 +1,660 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/version"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// backupFormatVersion is incremented whenever the layout of a backup changes incompatibly.
+	backupFormatVersion = 1
+	manifestFileName    = "manifest.json"
+	databaseDir         = "database"
+	blobsDir            = "blobs"
+	// blobListFileName lists the blobs in (or, with blobsManifest, referenced by) the backup.
+	blobListFileName = "blobs.jsonl"
+	// blobPageSize is the number of blobs listed from the blob store at a time.
+	blobPageSize = 1000
+)
+
+// blobsMode controls what a backup contains for the blobs in the blob store.
+type blobsMode string
+
+const (
+	// blobsFull copies the contents of every blob into the backup.
+	blobsFull blobsMode = "full"
+	// blobsManifest only lists the key and size of every blob, for use when the blob store is backed up
+	// separately (e.g. an S3 bucket with versioning or replication).
+	blobsManifest blobsMode = "manifest"
+	// blobsNone leaves blobs out of the backup entirely.
+	blobsNone blobsMode = "none"
+)
+
+var blobsModes = []blobsMode{blobsFull, blobsManifest, blobsNone}
+
+func parseBlobsMode(str string) (blobsMode, error) {
+	for _, mode := range blobsModes {
+		if string(mode) == strings.ToLower(str) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("error unsupported --blobs %q; options are: %v", str, blobsModes)
+}
+
+// backupManifest is the first file in a backup, describing what the rest of the backup contains.
+type backupManifest struct {
+	FormatVersion  int            `json:"format_version"`
+	CreatedAt      models.Time    `json:"created_at"`
+	ServerVersion  string         `json:"server_version"`
+	DatabaseDriver store.DBDriver `json:"database_driver"`
+	// SchemaVersion is the migration version of the database the backup was taken from.
+	SchemaVersion uint           `json:"schema_version"`
+	Tables        []*backupTable `json:"tables"`
+	Blobs         blobsMode      `json:"blobs"`
+}
+
+// backupTable describes a table in a backup. Tables are listed in the order they must be restored in.
+type backupTable struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+	// DeferredColumns are set after all rows in the backup have been restored, since they reference rows
+	// that may not have been restored yet.
+	DeferredColumns []string `json:"deferred_columns,omitempty"`
+	PrimaryKey      []string `json:"primary_key,omitempty"`
+}
+
+type blobEntry struct {
+	Key       string `json:"key"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// backupResult summarises what was backed up or restored.
+type backupResult struct {
+	Tables        int
+	Rows          int64
+	Blobs         int
+	BlobSizeBytes int64
+	// MissingBlobs counts blobs that were listed but no longer existed when they were read, or (when
+	// restoring a backup that only has a manifest of blobs) that are missing from the blob store.
+	MissingBlobs int
+}
+
+// backupWriter writes a backup of a database and blob store.
+type backupWriter struct {
+	db        *store.DB
+	blobStore services.BlobStore
+	blobs     blobsMode
+}
+
+// WriteBackup writes a gzipped tar archive to w containing a manifest, the rows of every table in the database
+// read inside a single transaction so that they are consistent, and (depending on the blobs mode) the blobs in
+// the blob store. Blobs are listed after the database has been read, so every blob the database refers to is
+// included unless it is deleted while the backup is running.
+func (b *backupWriter) WriteBackup(ctx context.Context, w io.Writer) (*backupResult, error) {
+	tempDir, err := os.MkdirTemp("", "bb-backup-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifest, err := b.dumpDatabase(ctx, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	var blobList []*blobEntry
+	if b.blobs != blobsNone {
+		blobList, err = b.listBlobs(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &backupResult{Tables: len(manifest.Tables)}
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	err = writeJSONFile(tarWriter, manifestFileName, manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range manifest.Tables {
+		err = copyFileToTar(tarWriter, path.Join(databaseDir, table.Name+".jsonl"), tableFilePath(tempDir, table.Name))
+		if err != nil {
+			return nil, err
+		}
+		result.Rows += table.Rows
+	}
+	var included []*blobEntry
+	for _, blob := range blobList {
+		if b.blobs == blobsFull {
+			found, err := b.copyBlobToTar(ctx, tarWriter, blob)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				result.MissingBlobs++
+				continue
+			}
+		}
+		included = append(included, blob)
+		result.Blobs++
+		result.BlobSizeBytes += blob.SizeBytes
+	}
+	if b.blobs != blobsNone {
+		err = writeJSONLinesFile(tarWriter, blobListFileName, included)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error writing backup: %w", err)
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error writing backup: %w", err)
+	}
+	return result, nil
+}
+
+// dumpDatabase writes the rows of each table to a file in dir, returning a manifest describing the tables.
+func (b *backupWriter) dumpDatabase(ctx context.Context, dir string) (*backupManifest, error) {
+	tx, err := b.db.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error beginning database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	schemaVersion, err := readSchemaVersion(ctx, tx, b.db.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if schemaVersion == 0 {
+		return nil, fmt.Errorf("error database has no schema to back up")
+	}
+	schemas, err := readTableSchemas(ctx, tx, b.db.Driver)
+	if err != nil {
+		return nil, err
+	}
+	deferred, err := deferredColumns(schemas)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &backupManifest{
+		FormatVersion:  backupFormatVersion,
+		CreatedAt:      models.NewTime(time.Now()),
+		ServerVersion:  version.VersionToString(),
+		DatabaseDriver: b.db.Driver,
+		SchemaVersion:  schemaVersion,
+		Blobs:          b.blobs,
+	}
+	for _, schema := range schemas {
+		table, err := dumpTable(ctx, tx, schema, tableFilePath(dir, schema.Name))
+		if err != nil {
+			return nil, err
+		}
+		if len(deferred[schema.Name]) > 0 {
+			table.DeferredColumns = deferred[schema.Name]
+			table.PrimaryKey = schema.PrimaryKey
+		}
+		manifest.Tables = append(manifest.Tables, table)
+	}
+	return manifest, nil
+}
+
+// dumpTable writes each row of a table to a new file at filePath as a JSON array of column values.
+func dumpTable(ctx context.Context, tx *sql.Tx, schema *tableSchema, filePath string) (*backupTable, error) {
+	query := fmt.Sprintf("SELECT * FROM %s", quoteIdentifier(schema.Name))
+	if len(schema.PrimaryKey) > 0 {
+		var orderBy []string
+		for _, column := range schema.PrimaryKey {
+			orderBy = append(orderBy, quoteIdentifier(column))
+		}
+		query += " ORDER BY " + strings.Join(orderBy, ", ")
+	}
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error reading table %s: %w", schema.Name, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("error reading columns of table %s: %w", schema.Name, err)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	table := &backupTable{Name: schema.Name, Columns: columns}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, fmt.Errorf("error reading row from table %s: %w", schema.Name, err)
+		}
+		encoded := make([]interface{}, len(values))
+		for i, value := range values {
+			encoded[i], err = encodeValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("error reading column %s.%s: %w", schema.Name, columns[i], err)
+			}
+		}
+		err = encoder.Encode(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("error writing temporary file: %w", err)
+		}
+		table.Rows++
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error reading table %s: %w", schema.Name, err)
+	}
+	err = writer.Flush()
+	if err != nil {
+		return nil, fmt.Errorf("error writing temporary file: %w", err)
+	}
+	return table, nil
+}
+
+// listBlobs lists every blob in the blob store.
+func (b *backupWriter) listBlobs(ctx context.Context) ([]*blobEntry, error) {
+	var blobs []*blobEntry
+	pagination := models.NewPagination(blobPageSize, nil)
+	for moreResults := true; moreResults; {
+		descriptors, cursor, err := b.blobStore.ListBlobs(ctx, "", "", pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing blobs: %w", err)
+		}
+		for _, descriptor := range descriptors {
+			blobs = append(blobs, &blobEntry{Key: descriptor.Key, SizeBytes: descriptor.SizeBytes})
+		}
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next // move on to next page of results
+		} else {
+			moreResults = false
+		}
+	}
+	return blobs, nil
+}
+
+// copyBlobToTar copies the contents of a blob into the tar archive. Returns false if the blob no longer exists.
+func (b *backupWriter) copyBlobToTar(ctx context.Context, tarWriter *tar.Writer, blob *blobEntry) (bool, error) {
+	reader, err := b.blobStore.GetBlob(ctx, blob.Key)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reading blob %q: %w", blob.Key, err)
+	}
+	defer reader.Close()
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    path.Join(blobsDir, blob.Key),
+		Mode:    0600,
+		Size:    blob.SizeBytes,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("error writing backup: %w", err)
+	}
+	_, err = io.CopyN(tarWriter, reader, blob.SizeBytes)
+	if err != nil {
+		return false, fmt.Errorf("error copying blob %q (it may have changed during the backup): %w", blob.Key, err)
+	}
+	return true, nil
+}
+
+// backupRestorer restores a backup written by backupWriter.
+type backupRestorer struct {
+	db              *store.DB
+	blobStore       services.BlobStore
+	migrationRunner store.MigrationRunner
+	// confirm is called once the backup has been checked, before any data is replaced, and returns
+	// false to cancel the restore.
+	confirm func(manifest *backupManifest) bool
+}
+
+// deferredUpdate records the value of a deferred column to set once all rows have been restored.
+type deferredUpdate struct {
+	column     string
+	value      interface{}
+	primaryKey []interface{}
+}
+
+// RestoreBackup replaces the contents of the database (and adds blobs to the blob store) from the backup in r.
+// The database must either be empty, in which case its schema is created by running migrations up to the
+// version the backup was taken at, or already be at that version. Returns nil if the restore was cancelled.
+func (b *backupRestorer) RestoreBackup(ctx context.Context, r io.Reader) (*backupResult, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup: %w", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	header, err := tarReader.Next()
+	if err != nil || header.Name != manifestFileName {
+		return nil, fmt.Errorf("error backup does not start with a %s file", manifestFileName)
+	}
+	manifest := &backupManifest{}
+	err = json.NewDecoder(tarReader).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup manifest: %w", err)
+	}
+	if manifest.FormatVersion != backupFormatVersion {
+		return nil, fmt.Errorf("error unsupported backup format version %d", manifest.FormatVersion)
+	}
+	if manifest.DatabaseDriver != b.db.Driver {
+		return nil, fmt.Errorf("error backup was taken from a %s database and can't be restored to a %s database",
+			manifest.DatabaseDriver, b.db.Driver)
+	}
+
+	schemaVersion, err := readSchemaVersion(ctx, b.db, b.db.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if schemaVersion != 0 && schemaVersion != manifest.SchemaVersion {
+		return nil, fmt.Errorf("error database schema is at version %d but the backup was taken at version %d; "+
+			"restore to an empty database instead", schemaVersion, manifest.SchemaVersion)
+	}
+	if !b.confirm(manifest) {
+		return nil, nil
+	}
+	if schemaVersion == 0 {
+		err = b.migrationRunner.Goto(ctx, b.db.Driver, b.db.ConnectionString, manifest.SchemaVersion)
+		if err != nil {
+			return nil, fmt.Errorf("error creating database schema: %w", err)
+		}
+	}
+
+	result := &backupResult{Tables: len(manifest.Tables)}
+	header, err = b.restoreDatabase(ctx, tarReader, manifest, result)
+	if err != nil {
+		return nil, err
+	}
+	for ; err == nil; header, err = tarReader.Next() {
+		switch {
+		case strings.HasPrefix(header.Name, blobsDir+"/"):
+			key := strings.TrimPrefix(header.Name, blobsDir+"/")
+			err = b.blobStore.PutBlob(ctx, key, tarReader)
+			if err != nil {
+				return nil, fmt.Errorf("error restoring blob %q: %w", key, err)
+			}
+			result.Blobs++
+			result.BlobSizeBytes += header.Size
+		case header.Name == blobListFileName && manifest.Blobs == blobsManifest:
+			err = b.checkBlobs(ctx, tarReader, result)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err != io.EOF {
+		return nil, fmt.Errorf("error reading backup: %w", err)
+	}
+	return result, nil
+}
+
+// restoreDatabase replaces all rows in the database with the rows in the backup, inside a single transaction.
+// Returns the header of the first entry in the backup after the database tables, or nil if there are none.
+func (b *backupRestorer) restoreDatabase(ctx context.Context, tarReader *tar.Reader, manifest *backupManifest, result *backupResult) (*tar.Header, error) {
+	tx, err := b.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Remove the existing data (including any rows added by migrations), clearing deferred columns first so
+	// that tables can be emptied in the reverse of the order they're restored in
+	for _, table := range manifest.Tables {
+		for _, column := range table.DeferredColumns {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s = NULL",
+				quoteIdentifier(table.Name), quoteIdentifier(column)))
+			if err != nil {
+				return nil, fmt.Errorf("error clearing column %s.%s: %w", table.Name, column, err)
+			}
+		}
+	}
+	for i := len(manifest.Tables) - 1; i >= 0; i-- {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", quoteIdentifier(manifest.Tables[i].Name)))
+		if err != nil {
+			return nil, fmt.Errorf("error removing existing rows from table %s: %w", manifest.Tables[i].Name, err)
+		}
+	}
+
+	deferredUpdates := make(map[string][]*deferredUpdate)
+	for _, table := range manifest.Tables {
+		header, err := tarReader.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading backup: %w", err)
+		}
+		if header.Name != path.Join(databaseDir, table.Name+".jsonl") {
+			return nil, fmt.Errorf("error expected rows for table %s in backup but found %s", table.Name, header.Name)
+		}
+		updates, err := b.restoreTable(ctx, tx, table, tarReader)
+		if err != nil {
+			return nil, err
+		}
+		deferredUpdates[table.Name] = updates
+		result.Rows += table.Rows
+	}
+	for _, table := range manifest.Tables {
+		for _, update := range deferredUpdates[table.Name] {
+			var where []string
+			for i, column := range table.PrimaryKey {
+				where = append(where, fmt.Sprintf("%s = %s", quoteIdentifier(column), placeholder(b.db.Driver, i+1)))
+			}
+			query := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s", quoteIdentifier(table.Name),
+				quoteIdentifier(update.column), placeholder(b.db.Driver, 0), strings.Join(where, " AND "))
+			_, err = tx.ExecContext(ctx, query, append([]interface{}{update.value}, update.primaryKey...)...)
+			if err != nil {
+				return nil, fmt.Errorf("error restoring column %s.%s: %w", table.Name, update.column, err)
+			}
+		}
+	}
+	if b.db.Driver == store.Postgres {
+		err = resetPostgresSequences(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing database transaction: %w", err)
+	}
+
+	header, err := tarReader.Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup: %w", err)
+	}
+	return header, nil
+}
+
+// restoreTable inserts the rows of a table read from r, returning the updates needed to set its deferred columns.
+func (b *backupRestorer) restoreTable(ctx context.Context, tx *sql.Tx, table *backupTable, r io.Reader) ([]*deferredUpdate, error) {
+	var (
+		columns      []string
+		placeholders []string
+		deferred     = make(map[string]bool)
+		keyIndexes   []int
+	)
+	for _, column := range table.DeferredColumns {
+		deferred[column] = true
+	}
+	for i, column := range table.Columns {
+		columns = append(columns, quoteIdentifier(column))
+		placeholders = append(placeholders, placeholder(b.db.Driver, i))
+	}
+	for _, keyColumn := range table.PrimaryKey {
+		for i, column := range table.Columns {
+			if column == keyColumn {
+				keyIndexes = append(keyIndexes, i)
+			}
+		}
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdentifier(table.Name), strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing to restore table %s: %w", table.Name, err)
+	}
+	defer stmt.Close()
+
+	var updates []*deferredUpdate
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for {
+		var encoded []interface{}
+		err = decoder.Decode(&encoded)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading rows for table %s from backup: %w", table.Name, err)
+		}
+		if len(encoded) != len(table.Columns) {
+			return nil, fmt.Errorf("error row for table %s in backup has %d columns; expected %d",
+				table.Name, len(encoded), len(table.Columns))
+		}
+		values := make([]interface{}, len(encoded))
+		for i := range encoded {
+			values[i], err = decodeValue(encoded[i])
+			if err != nil {
+				return nil, fmt.Errorf("error reading column %s.%s from backup: %w", table.Name, table.Columns[i], err)
+			}
+		}
+		var primaryKey []interface{}
+		for _, i := range keyIndexes {
+			primaryKey = append(primaryKey, values[i])
+		}
+		for i, column := range table.Columns {
+			if deferred[column] && values[i] != nil {
+				updates = append(updates, &deferredUpdate{column: column, value: values[i], primaryKey: primaryKey})
+				values[i] = nil
+			}
+		}
+		_, err = stmt.ExecContext(ctx, values...)
+		if err != nil {
+			return nil, fmt.Errorf("error restoring row to table %s: %w", table.Name, err)
+		}
+	}
+	return updates, nil
+}
+
+// checkBlobs checks that each blob listed in a backup that only has a manifest of blobs exists in the blob store.
+func (b *backupRestorer) checkBlobs(ctx context.Context, r io.Reader, result *backupResult) error {
+	decoder := json.NewDecoder(r)
+	for {
+		blob := &blobEntry{}
+		err := decoder.Decode(blob)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading blob list from backup: %w", err)
+		}
+		reader, err := b.blobStore.GetBlobRange(ctx, blob.Key, 0, 0)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				result.MissingBlobs++
+				continue
+			}
+			return fmt.Errorf("error checking blob %q: %w", blob.Key, err)
+		}
+		reader.Close()
+		result.Blobs++
+		result.BlobSizeBytes += blob.SizeBytes
+	}
+}
+
+func tableFilePath(dir string, table string) string {
+	return path.Join(dir, table+".jsonl")
+}
+
+func writeJSONFile(tarWriter *tar.Writer, name string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %w", name, err)
+	}
+	return writeTarFile(tarWriter, name, data)
+}
+
+func writeJSONLinesFile(tarWriter *tar.Writer, name string, values []*blobEntry) error {
+	var data []byte
+	for _, value := range values {
+		line, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("error encoding %s: %w", name, err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return writeTarFile(tarWriter, name, data)
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, data []byte) error {
+	err := tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error writing backup: %w", err)
+	}
+	_, err = tarWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("error writing backup: %w", err)
+	}
+	return nil
+}
+
+func copyFileToTar(tarWriter *tar.Writer, name string, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error opening temporary file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error opening temporary file: %w", err)
+	}
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    info.Size(),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error writing backup: %w", err)
+	}
+	_, err = io.Copy(tarWriter, file)
+	if err != nil {
+		return fmt.Errorf("error writing backup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/blob"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/migrations"
+)
+
+const (
+	defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+	defaultLocalBlobStoreDir      = "/var/lib/buildbeaver/blob"
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{backupCmd, restoreCmd} {
+		cmd.Flags().StringVar(
+			&backupCmdConfig.databaseDriver,
+			"driver",
+			string(store.Sqlite),
+			"The Database Driver to use (i.e sqlite3|postgres)")
+		cmd.Flags().StringVar(
+			&backupCmdConfig.databaseConnectionString,
+			"connection",
+			defaultSQLiteConnectionString,
+			"The connection string for the database to use")
+		cmd.Flags().StringVar(
+			&backupCmdConfig.blobStoreType,
+			"blob-store-type",
+			blob.LocalBlobStoreType.String(),
+			fmt.Sprintf("The type of blob store to use. Options: %s", strings.Join(blob.BlobStoreTypes(), ", ")))
+		cmd.Flags().StringVar(
+			&backupCmdConfig.localBlobStoreDir,
+			"blob-store-local-directory",
+			defaultLocalBlobStoreDir,
+			"The path on the local host blobs are stored in, if using the local blob store")
+		cmd.Flags().StringVar(
+			&backupCmdConfig.s3BlobStoreConfig.BucketName,
+			"blob-store-aws-s3-bucket-name",
+			"",
+			"The name of the S3 bucket blobs are stored in, if using the S3 blob store")
+		cmd.Flags().StringVar(
+			&backupCmdConfig.s3BlobStoreConfig.Region,
+			"blob-store-aws-s3-region",
+			"",
+			"The region of the S3 bucket blobs are stored in, if using the S3 blob store")
+		cmd.Flags().StringVar(
+			&backupCmdConfig.s3BlobStoreConfig.AccessKeyID,
+			"blob-store-aws-s3-access-key-id",
+			"",
+			"The AWS Access Key ID to use to authenticate to the S3 bucket, if using the S3 blob store")
+		cmd.Flags().StringVar(
+			&backupCmdConfig.s3BlobStoreConfig.SecretAccessKey,
+			"blob-store-aws-s3-secret-key",
+			"",
+			"The AWS Secret Key to use to authenticate to the S3 bucket, if using the S3 blob store")
+	}
+	backupCmd.Flags().StringVarP(
+		&backupCmdConfig.output,
+		"output",
+		"o",
+		"",
+		"The file to write the backup to (defaults to buildbeaver-backup-<timestamp>.tar.gz in the current directory)")
+	backupCmd.Flags().StringVar(
+		&backupCmdConfig.blobs,
+		"blobs",
+		string(blobsFull),
+		"What to include for blobs: 'full' copies every blob, 'manifest' lists blob keys and sizes only "+
+			"(for blob stores that are backed up separately), 'none' leaves blobs out")
+	restoreCmd.Flags().BoolVarP(
+		&backupCmdConfig.skipConfirmation,
+		"skip-confirmation",
+		"",
+		false,
+		"Skip interactive confirmation and automatically answer Yes to confirmation questions")
+
+	commands.RootCmd.AddCommand(backupCmd)
+	commands.RootCmd.AddCommand(restoreCmd)
+}
+
+var backupCmdConfig = struct {
+	databaseDriver           string
+	databaseConnectionString string
+	blobStoreType            string
+	localBlobStoreDir        string
+	s3BlobStoreConfig        blob.S3BlobStoreConfig
+	output                   string
+	blobs                    string
+	skipConfirmation         bool
+}{}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [-o file]",
+	Short: "Backs up the database and blob store to a file, for disaster recovery",
+	Long: "Backs up the database and blob store to a gzipped tar file, for disaster recovery.\n" +
+		"The database is read inside a single transaction so the backup is consistent while the server is\n" +
+		"running. Blobs are read after the database, so blobs written by builds that finish during the\n" +
+		"backup may be included without the rows that refer to them.\n" +
+		"Secrets in the database stay encrypted; keep a copy of the server's master key separately since\n" +
+		"secrets can't be decrypted after a restore without it.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		mode, err := parseBlobsMode(backupCmdConfig.blobs)
+		if err != nil {
+			return err
+		}
+		output := backupCmdConfig.output
+		if output == "" {
+			output = fmt.Sprintf("buildbeaver-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+		}
+
+		logFactory, err := makeLogFactory()
+		if err != nil {
+			return err
+		}
+		blobStore, err := makeBlobStore(logFactory)
+		if err != nil {
+			return err
+		}
+		db, cleanup, err := openDatabase(ctx)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		writer := &backupWriter{
+			db:        db,
+			blobStore: blobStore,
+			blobs:     mode,
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
+		}
+		result, err := writer.WriteBackup(ctx, file)
+		closeErr := file.Close()
+		if err != nil {
+			_ = os.Remove(output)
+			return err
+		}
+		if closeErr != nil {
+			return fmt.Errorf("error closing output file: %w", closeErr)
+		}
+
+		summary := fmt.Sprintf("%d rows from %d tables", result.Rows, result.Tables)
+		switch mode {
+		case blobsFull:
+			summary += fmt.Sprintf(" and %d blobs (%d bytes)", result.Blobs, result.BlobSizeBytes)
+		case blobsManifest:
+			summary += fmt.Sprintf(" and a list of %d blobs (%d bytes)", result.Blobs, result.BlobSizeBytes)
+		}
+		cli.Stdout.Printf("Backed up %s to %s\n", summary, output)
+		if result.MissingBlobs > 0 {
+			cli.Stdout.Printf("Warning: %d blobs were deleted while the backup was running and were not included\n", result.MissingBlobs)
+		}
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore file",
+	Short: "Restores the database and blob store from a file written by the backup command",
+	Long: "Restores the database and blob store from a file written by the backup command.\n" +
+		"The server must be stopped while restoring. All existing rows in the database are replaced by the\n" +
+		"rows in the backup, and blobs in the backup are added to the blob store. The database must use the\n" +
+		"same driver as the database that was backed up, and must either be empty (in which case its schema is\n" +
+		"created by running migrations) or be at the same schema version as the backup; run the server's\n" +
+		"migrations after restoring to upgrade the schema.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		logFactory, err := makeLogFactory()
+		if err != nil {
+			return err
+		}
+		blobStore, err := makeBlobStore(logFactory)
+		if err != nil {
+			return err
+		}
+		db, cleanup, err := openDatabase(ctx)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("error opening backup: %w", err)
+		}
+		defer file.Close()
+
+		restorer := &backupRestorer{
+			db:              db,
+			blobStore:       blobStore,
+			migrationRunner: migrations.NewBBGolangMigrateRunner(logFactory),
+			confirm: func(manifest *backupManifest) bool {
+				cli.Stdout.Printf("Backup taken at %s by server version %s (schema version %d)\n",
+					manifest.CreatedAt, manifest.ServerVersion, manifest.SchemaVersion)
+				return cli.AskForConfirmation("Restoring will REPLACE ALL data in this database. Are you sure?", backupCmdConfig.skipConfirmation)
+			},
+		}
+		result, err := restorer.RestoreBackup(ctx, file)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return nil
+		}
+
+		summary := fmt.Sprintf("%d rows to %d tables", result.Rows, result.Tables)
+		if result.Blobs > 0 {
+			summary += fmt.Sprintf(" and %d blobs (%d bytes)", result.Blobs, result.BlobSizeBytes)
+		}
+		cli.Stdout.Printf("Restored %s from %s\n", summary, args[0])
+		if result.MissingBlobs > 0 {
+			cli.Stdout.Printf("Warning: %d blobs listed in the backup are missing from the blob store\n", result.MissingBlobs)
+		}
+		return nil
+	},
+}
+
+func makeLogFactory() (logger.LogFactory, error) {
+	// stores need a log factory; use a very plain log format
+	logRegistry, err := logger.NewLogRegistry("")
+	if err != nil {
+		return nil, err
+	}
+	return logger.MakeLogrusLogFactoryStdOutPlain(logRegistry), nil
+}
+
+// openDatabase opens the database but does not perform migrations.
+func openDatabase(ctx context.Context) (*store.DB, func(), error) {
+	databaseConfig := store.DatabaseConfig{
+		ConnectionString:   store.DatabaseConnectionString(backupCmdConfig.databaseConnectionString),
+		Driver:             store.DBDriver(backupCmdConfig.databaseDriver),
+		MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+		MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+	}
+	db, cleanup, err := store.NewDatabase(ctx, databaseConfig, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening %s database: %w", databaseConfig.Driver, err)
+	}
+	return db, cleanup, nil
+}
+
+func makeBlobStore(logFactory logger.LogFactory) (services.BlobStore, error) {
+	switch strings.ToLower(backupCmdConfig.blobStoreType) {
+	case strings.ToLower(blob.AWSS3BlobStoreType.String()):
+		s3Store, err := blob.NewS3BlobStore(backupCmdConfig.s3BlobStoreConfig, logFactory)
+		if err != nil {
+			return nil, fmt.Errorf("error creating S3 blob store: %w", err)
+		}
+		return s3Store, nil
+	case strings.ToLower(blob.LocalBlobStoreType.String()):
+		return blob.NewLocalBlobStore(blob.LocalBlobStoreDirectory(backupCmdConfig.localBlobStoreDir)), nil
+	default:
+		return nil, fmt.Errorf("error unsupported blob store type: %v", backupCmdConfig.blobStoreType)
+	}
+}
@@ -0,0 +1,416 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// migrationsTable is the table golang-migrate records the schema version in. It isn't backed up; instead the
+// schema is created by running migrations up to the version recorded in the backup manifest.
+const migrationsTable = "schema_migrations"
+
+// querier can run queries against a database or inside a transaction.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// tableSchema describes the parts of a table's schema needed to back up and restore its rows.
+type tableSchema struct {
+	Name string
+	// PrimaryKey lists the columns making up the table's primary key.
+	PrimaryKey []string
+	// References maps each column that is a foreign key to the table it references.
+	References map[string]string
+	// NotNull is the set of columns that can't be null.
+	NotNull map[string]bool
+}
+
+// readSchemaVersion returns the migration version of the database, or zero if no migrations have been run.
+// Returns an error if the last migration failed part way through.
+func readSchemaVersion(ctx context.Context, q querier, driver store.DBDriver) (uint, error) {
+	tables, err := readTableNames(ctx, q, driver)
+	if err != nil {
+		return 0, err
+	}
+	found := false
+	for _, table := range tables {
+		if table == migrationsTable {
+			found = true
+		}
+	}
+	if !found {
+		return 0, nil
+	}
+	var (
+		version int64
+		dirty   bool
+	)
+	err = q.QueryRowContext(ctx, fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", migrationsTable)).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("error database schema version %d is dirty; fix the database using 'bb-tools migrate force' first", version)
+	}
+	return uint(version), nil
+}
+
+// readTableNames returns the names of all tables in the database, in sorted order.
+func readTableNames(ctx context.Context, q querier, driver store.DBDriver) ([]string, error) {
+	var query string
+	switch driver {
+	case store.Sqlite:
+		query = "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+	case store.Postgres:
+		query = "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'"
+	default:
+		return nil, fmt.Errorf("error unsupported database driver: %s", driver)
+	}
+	names, err := queryStrings(ctx, q, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing tables: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readTableSchemas reads the schema of each table in the database other than the migrations table, returning the
+// tables in the order their rows must be restored in so that rows are inserted after the rows they reference.
+// Nullable foreign keys that this order can't satisfy (e.g. because a table references itself) are listed by
+// deferredColumns; these columns must be set after all rows are inserted.
+func readTableSchemas(ctx context.Context, q querier, driver store.DBDriver) ([]*tableSchema, error) {
+	names, err := readTableNames(ctx, q, driver)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]*tableSchema, len(names))
+	for _, name := range names {
+		if name == migrationsTable {
+			continue
+		}
+		schemas[name] = &tableSchema{Name: name, References: make(map[string]string), NotNull: make(map[string]bool)}
+	}
+
+	switch driver {
+	case store.Sqlite:
+		for name, schema := range schemas {
+			err = readSqliteTableSchema(ctx, q, name, schema)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case store.Postgres:
+		err = readPostgresTableSchemas(ctx, q, schemas)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Order tables so that referenced tables come first, visiting tables and columns in sorted order so the result
+	// is deterministic. Non-null foreign keys are always followed; a nullable foreign key is only followed if doing
+	// so can't lead back through non-null foreign keys to a table that is still being ordered, since that table
+	// would then have to be restored both before and after the referenced table.
+	var (
+		ordered  []*tableSchema
+		visited  = make(map[string]bool)
+		visiting = make(map[string]bool)
+	)
+	reachesVisiting := func(name string) bool {
+		seen := make(map[string]bool)
+		var reaches func(name string) bool
+		reaches = func(name string) bool {
+			if visiting[name] {
+				return true
+			}
+			seen[name] = true
+			schema, ok := schemas[name]
+			if !ok {
+				return false
+			}
+			for column, referenced := range schema.References {
+				if schema.NotNull[column] && !seen[referenced] && reaches(referenced) {
+					return true
+				}
+			}
+			return false
+		}
+		return reaches(name)
+	}
+	var visit func(schema *tableSchema)
+	visit = func(schema *tableSchema) {
+		visiting[schema.Name] = true
+		for _, notNull := range []bool{true, false} {
+			for _, column := range sortedKeys(schema.References) {
+				if schema.NotNull[column] != notNull {
+					continue
+				}
+				referenced, ok := schemas[schema.References[column]]
+				if !ok || visited[referenced.Name] || visiting[referenced.Name] {
+					continue
+				}
+				if !notNull && reachesVisiting(referenced.Name) {
+					continue
+				}
+				visit(referenced)
+			}
+		}
+		delete(visiting, schema.Name)
+		visited[schema.Name] = true
+		ordered = append(ordered, schema)
+	}
+	for _, name := range names {
+		schema, ok := schemas[name]
+		if ok && !visited[name] {
+			visit(schema)
+		}
+	}
+	return ordered, nil
+}
+
+// deferredColumns returns the foreign key columns of each table that reference a table that isn't restored
+// before it (including the table itself), keyed by table name. Returns an error if any of these columns can't
+// be null, since there's then no order the rows can be restored in.
+func deferredColumns(schemas []*tableSchema) (map[string][]string, error) {
+	var (
+		deferred = make(map[string][]string)
+		restored = make(map[string]bool)
+	)
+	for _, schema := range schemas {
+		for _, column := range sortedKeys(schema.References) {
+			if restored[schema.References[column]] {
+				continue
+			}
+			if schema.NotNull[column] {
+				return nil, fmt.Errorf("error column %s.%s references table %s, which can't be restored first",
+					schema.Name, column, schema.References[column])
+			}
+			if len(schema.PrimaryKey) == 0 {
+				return nil, fmt.Errorf("error table %s has no primary key, so column %s can't be restored",
+					schema.Name, column)
+			}
+			deferred[schema.Name] = append(deferred[schema.Name], column)
+		}
+		restored[schema.Name] = true
+	}
+	return deferred, nil
+}
+
+func readSqliteTableSchema(ctx context.Context, q querier, name string, schema *tableSchema) error {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT name, "notnull", pk FROM pragma_table_info(%s)`, quoteString(name)))
+	if err != nil {
+		return fmt.Errorf("error reading columns of table %s: %w", name, err)
+	}
+	primaryKey := make(map[int]string)
+	for rows.Next() {
+		var (
+			column  string
+			notNull bool
+			pk      int
+		)
+		err = rows.Scan(&column, &notNull, &pk)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error reading columns of table %s: %w", name, err)
+		}
+		if notNull {
+			schema.NotNull[column] = true
+		}
+		if pk > 0 {
+			primaryKey[pk] = column
+		}
+	}
+	rows.Close()
+	for i := 1; i <= len(primaryKey); i++ {
+		schema.PrimaryKey = append(schema.PrimaryKey, primaryKey[i])
+	}
+
+	rows, err = q.QueryContext(ctx, fmt.Sprintf(`SELECT "from", "table" FROM pragma_foreign_key_list(%s)`, quoteString(name)))
+	if err != nil {
+		return fmt.Errorf("error reading foreign keys of table %s: %w", name, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column, referenced string
+		err = rows.Scan(&column, &referenced)
+		if err != nil {
+			return fmt.Errorf("error reading foreign keys of table %s: %w", name, err)
+		}
+		schema.References[column] = referenced
+	}
+	return rows.Err()
+}
+
+func readPostgresTableSchemas(ctx context.Context, q querier, schemas map[string]*tableSchema) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND is_nullable = 'NO'`)
+	if err != nil {
+		return fmt.Errorf("error reading columns: %w", err)
+	}
+	for rows.Next() {
+		var table, column string
+		err = rows.Scan(&table, &column)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error reading columns: %w", err)
+		}
+		if schema, ok := schemas[table]; ok {
+			schema.NotNull[column] = true
+		}
+	}
+	rows.Close()
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT kcu.table_name, kcu.column_name, tc.constraint_type, ccu.table_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+		JOIN information_schema.constraint_column_usage ccu
+			ON ccu.constraint_name = tc.constraint_name AND ccu.table_schema = tc.table_schema
+		WHERE tc.table_schema = current_schema() AND tc.constraint_type IN ('PRIMARY KEY', 'FOREIGN KEY')
+		ORDER BY kcu.table_name, kcu.ordinal_position`)
+	if err != nil {
+		return fmt.Errorf("error reading table constraints: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, column, constraintType, referenced string
+		err = rows.Scan(&table, &column, &constraintType, &referenced)
+		if err != nil {
+			return fmt.Errorf("error reading table constraints: %w", err)
+		}
+		schema, ok := schemas[table]
+		if !ok {
+			continue
+		}
+		if constraintType == "PRIMARY KEY" {
+			schema.PrimaryKey = append(schema.PrimaryKey, column)
+		} else {
+			schema.References[column] = referenced
+		}
+	}
+	return rows.Err()
+}
+
+// resetPostgresSequences sets the sequence behind each serial column to continue after the largest value
+// restored into the column.
+func resetPostgresSequences(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_default LIKE 'nextval(%'`)
+	if err != nil {
+		return fmt.Errorf("error listing serial columns: %w", err)
+	}
+	var columns [][2]string
+	for rows.Next() {
+		var table, column string
+		err = rows.Scan(&table, &column)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error listing serial columns: %w", err)
+		}
+		columns = append(columns, [2]string{table, column})
+	}
+	rows.Close()
+	for _, tc := range columns {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence(%s, %s), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+			quoteString(tc[0]), quoteString(tc[1]), quoteIdentifier(tc[1]), quoteIdentifier(tc[0])))
+		if err != nil {
+			return fmt.Errorf("error resetting sequence for %s.%s: %w", tc[0], tc[1], err)
+		}
+	}
+	return nil
+}
+
+// encodeValue converts a value scanned from the database into a value that can be encoded as JSON and decoded
+// again by decodeValue without losing its type.
+func encodeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, int64, float64, string:
+		return v, nil
+	case []byte:
+		return map[string]string{"bytes": base64.StdEncoding.EncodeToString(v)}, nil
+	case time.Time:
+		return map[string]string{"time": v.Format(time.RFC3339Nano)}, nil
+	default:
+		return nil, fmt.Errorf("error unsupported column type %T", value)
+	}
+}
+
+// decodeValue converts a value encoded by encodeValue, and decoded from JSON using json.Decoder.UseNumber, back
+// into a value to be written to the database.
+func decodeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		if encoded, ok := v["bytes"].(string); ok {
+			return base64.StdEncoding.DecodeString(encoded)
+		}
+		if encoded, ok := v["time"].(string); ok {
+			return time.Parse(time.RFC3339Nano, encoded)
+		}
+	}
+	return nil, fmt.Errorf("error unexpected value %v", value)
+}
+
+// placeholder returns the placeholder for the i'th (zero-based) parameter of a query for the database driver.
+func placeholder(driver store.DBDriver, i int) string {
+	if driver == store.Postgres {
+		return fmt.Sprintf("$%d", i+1)
+	}
+	return "?"
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteString(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
+
+func queryStrings(ctx context.Context, q querier, query string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []string
+	for rows.Next() {
+		var str string
+		err = rows.Scan(&str)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, str)
+	}
+	return results, rows.Err()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,315 @@
+package grant_admin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/authorization"
+	"github.com/buildbeaver/buildbeaver/server/services/group"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/authorizations"
+	"github.com/buildbeaver/buildbeaver/server/store/grants"
+	"github.com/buildbeaver/buildbeaver/server/store/group_memberships"
+	"github.com/buildbeaver/buildbeaver/server/store/groups"
+	"github.com/buildbeaver/buildbeaver/server/store/identities"
+	"github.com/buildbeaver/buildbeaver/server/store/legal_entities"
+	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+)
+
+const defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+
+func init() {
+	grantRootCmd.PersistentFlags().StringVar(
+		&grantCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use for fetching and writing data (i.e sqlite3|postgres)")
+	grantRootCmd.PersistentFlags().StringVar(
+		&grantCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use for fetching and writing data")
+	for _, cmd := range []*cobra.Command{grantListCmd, grantAddCmd} {
+		cmd.Flags().StringVar(
+			&grantCmdConfig.userName,
+			"user",
+			"",
+			"The name of the user the grants are for")
+		cmd.Flags().StringVar(
+			&grantCmdConfig.groupName,
+			"group",
+			"",
+			"The group the grants are for, as legal-entity-name/group-name")
+	}
+	grantAddCmd.Flags().BoolVar(
+		&grantCmdConfig.deny,
+		"deny",
+		false,
+		"Deny the operation rather than allowing it")
+
+	commands.RootCmd.AddCommand(grantRootCmd)
+	grantRootCmd.AddCommand(grantListCmd)
+	grantRootCmd.AddCommand(grantAddCmd)
+	grantRootCmd.AddCommand(grantRemoveCmd)
+}
+
+var grantCmdConfig = struct {
+	databaseConfig           store.DatabaseConfig
+	databaseDriver           string
+	databaseConnectionString string
+	userName                 string
+	groupName                string
+	deny                     bool
+	db                       *store.DB
+	dbCleanup                func()
+	legalEntityStore         store.LegalEntityStore
+	identityStore            store.IdentityStore
+	authorizationService     services.AuthorizationService
+	groupService             services.GroupService
+}{}
+
+var grantRootCmd = &cobra.Command{
+	Use:     "grant list|add|remove",
+	Aliases: []string{"grants"},
+	Short:   "Lists, adds and removes the permissions granted to users and groups.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		grantCmdConfig.databaseConfig = store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(grantCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(grantCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logRegistry, err := logger.NewLogRegistry("")
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(context.Background(), grantCmdConfig.databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", grantCmdConfig.databaseConfig.Driver, err)
+		}
+		grantCmdConfig.db = db
+		grantCmdConfig.dbCleanup = cleanup
+
+		grantCmdConfig.legalEntityStore = legal_entities.NewStore(db, logFactory)
+		grantCmdConfig.identityStore = identities.NewStore(db, logFactory)
+		grantCmdConfig.authorizationService = authorization.NewAuthorizationService(
+			db,
+			grants.NewStore(db, logFactory),
+			ownerships.NewStore(db, logFactory),
+			authorizations.NewStore(db),
+			logFactory,
+		)
+		grantCmdConfig.groupService = group.NewGroupService(
+			db,
+			ownerships.NewStore(db, logFactory),
+			groups.NewStore(db, logFactory),
+			group_memberships.NewStore(db, logFactory),
+			grants.NewStore(db, logFactory),
+			grantCmdConfig.authorizationService,
+			logFactory,
+		)
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if grantCmdConfig.dbCleanup != nil {
+			grantCmdConfig.dbCleanup()
+			grantCmdConfig.dbCleanup = nil
+		}
+	},
+}
+
+var grantListCmd = &cobra.Command{
+	Use:   "list --user user-name|--group legal-entity-name/group-name",
+	Short: "Lists the grants given directly to a user, or to a group.",
+	Long: "Lists the grants given directly to a user, or to a group. Grants a user has through the groups\n" +
+		"they are a member of are not listed; use 'grant list --group' to see those.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		return grantCmdConfig.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+			subject, err := readGrantSubject(ctx, tx)
+			if err != nil {
+				return err
+			}
+			count := 0
+			pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+			for moreResults := true; moreResults; {
+				var (
+					grantList []*models.Grant
+					cursor    *models.Cursor
+				)
+				if subject.group != nil {
+					grantList, cursor, err = grantCmdConfig.authorizationService.ListGrantsForGroup(ctx, tx, subject.group.ID, pagination)
+				} else {
+					grantList, cursor, err = grantCmdConfig.authorizationService.ListGrantsForIdentity(ctx, tx, subject.identity.ID, pagination)
+				}
+				if err != nil {
+					return fmt.Errorf("error listing grants for %s: %w", subject, err)
+				}
+				for _, grant := range grantList {
+					count++
+					effect := "allow"
+					if grant.Deny {
+						effect = "deny"
+					}
+					operation := models.Operation{Name: grant.OperationName, ResourceKind: grant.OperationResourceType}
+					cli.Stdout.Printf("    %s %s on %s, ID %s\n", effect, operation, grant.TargetResourceID, grant.ID)
+				}
+				if cursor != nil && cursor.Next != nil {
+					pagination.Cursor = cursor.Next // move on to next page of results
+				} else {
+					moreResults = false
+				}
+			}
+			if count == 0 {
+				cli.Stdout.Printf("No grants found for %s.\n", subject)
+			}
+			return nil
+		})
+	},
+}
+
+var grantAddCmd = &cobra.Command{
+	Use:   "add --user user-name|--group legal-entity-name/group-name [--deny] operation resource-id",
+	Short: "Grants a user or group permission to perform an operation on a resource.",
+	Long: "Grants a user or group permission to perform an operation on a resource, or denies it with --deny.\n" +
+		"The operation is specified as name:resource-kind, e.g. read:repo. A grant on a resource also applies\n" +
+		"to the resources it owns, so a grant on a legal entity covers all of its repos.",
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		operation, err := parseOperation(args[0])
+		if err != nil {
+			return err
+		}
+		targetResourceID, err := models.ParseResourceID(args[1])
+		if err != nil {
+			return fmt.Errorf("error: Unable to parse resource ID '%s': %w", args[1], err)
+		}
+		return grantCmdConfig.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+			subject, err := readGrantSubject(ctx, tx)
+			if err != nil {
+				return err
+			}
+			now := models.NewTime(time.Now())
+			var grantData *models.Grant
+			if subject.group != nil {
+				grantData = models.NewGroupGrant(now, subject.group.LegalEntityID, subject.group.ID, *operation, targetResourceID)
+			} else {
+				grantData = models.NewIdentityGrant(now, subject.user.ID, subject.identity.ID, *operation, targetResourceID)
+			}
+			grantData.Deny = grantCmdConfig.deny
+			grant, created, err := grantCmdConfig.authorizationService.FindOrCreateGrant(ctx, tx, grantData)
+			if err != nil {
+				return fmt.Errorf("error granting %s on %s to %s: %w", operation, targetResourceID, subject, err)
+			}
+			if !created {
+				cli.Stdout.Printf("Not granted: %s already has a matching grant, ID %s\n", subject, grant.ID)
+				return nil
+			}
+			cli.Stdout.Printf("Granted, ID %s\n", grant.ID)
+			return nil
+		})
+	},
+}
+
+var grantRemoveCmd = &cobra.Command{
+	Use:           "remove grant-id",
+	Short:         "Permanently removes a grant. Use 'grant list' to find grant IDs.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		id, err := models.ParseResourceID(args[0])
+		if err != nil || id.Kind() != models.GrantResourceKind {
+			return fmt.Errorf("error: '%s' is not a valid grant ID", args[0])
+		}
+		err = grantCmdConfig.authorizationService.DeleteGrant(ctx, nil, models.GrantIDFromResourceID(id))
+		if err != nil {
+			return fmt.Errorf("error removing grant '%s': %w", id, err)
+		}
+		cli.Stdout.Printf("Removed.\n")
+		return nil
+	},
+}
+
+// grantSubject is the user or group that grants are listed or added for.
+type grantSubject struct {
+	user     *models.LegalEntity
+	identity *models.Identity
+	group    *models.Group
+}
+
+func (s *grantSubject) String() string {
+	if s.group != nil {
+		return fmt.Sprintf("group '%s'", s.group.Name)
+	}
+	return fmt.Sprintf("user '%s'", s.user.Name)
+}
+
+// readGrantSubject reads the user or group specified by the --user or --group flag.
+func readGrantSubject(ctx context.Context, txOrNil *store.Tx) (*grantSubject, error) {
+	if (grantCmdConfig.userName == "") == (grantCmdConfig.groupName == "") {
+		return nil, fmt.Errorf("error: Exactly one of --user or --group must be specified")
+	}
+	if grantCmdConfig.groupName != "" {
+		legalEntityName, groupName, found := strings.Cut(grantCmdConfig.groupName, "/")
+		if !found {
+			return nil, fmt.Errorf("error: Group must be specified as legal-entity-name/group-name")
+		}
+		legalEntity, err := grantCmdConfig.legalEntityStore.ReadByName(ctx, txOrNil, models.ResourceName(legalEntityName))
+		if err != nil {
+			return nil, fmt.Errorf("error: Unable to find legal entity with name '%s': %w", legalEntityName, err)
+		}
+		group, err := grantCmdConfig.groupService.ReadByName(ctx, txOrNil, legalEntity.ID, models.ResourceName(groupName))
+		if err != nil {
+			return nil, fmt.Errorf("error: Unable to find group '%s' for legal entity '%s': %w", groupName, legalEntity.Name, err)
+		}
+		return &grantSubject{group: group}, nil
+	}
+	user, err := grantCmdConfig.legalEntityStore.ReadByName(ctx, txOrNil, models.ResourceName(grantCmdConfig.userName))
+	if err != nil {
+		return nil, fmt.Errorf("error: Unable to find user with name '%s': %w", grantCmdConfig.userName, err)
+	}
+	if user.Type != models.LegalEntityTypePerson {
+		return nil, fmt.Errorf("error: The specified user must be of type '%s' (found '%s')", models.LegalEntityTypePerson, user.Type)
+	}
+	identity, err := grantCmdConfig.identityStore.ReadByOwnerResource(ctx, txOrNil, user.ID.ResourceID)
+	if err != nil {
+		return nil, fmt.Errorf("error: Unable to find identity for user '%s': %w", user.Name, err)
+	}
+	return &grantSubject{user: user, identity: identity}, nil
+}
+
+// parseOperation finds the access control operation with the specified name, in the form name:resource-kind.
+func parseOperation(str string) (*models.Operation, error) {
+	for _, operation := range models.AllOperations {
+		if operation.String() == str {
+			return operation, nil
+		}
+	}
+	names := make([]string, 0, len(models.AllOperations))
+	for _, operation := range models.AllOperations {
+		names = append(names, operation.String())
+	}
+	return nil, fmt.Errorf("error: Unknown operation '%s'; must be one of: %s", str, strings.Join(names, ", "))
+}
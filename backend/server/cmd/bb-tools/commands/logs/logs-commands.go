@@ -0,0 +1,269 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/blob"
+	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/logs"
+	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+)
+
+const (
+	defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+	defaultLocalBlobStoreDir      = "/var/lib/buildbeaver/blob"
+)
+
+func init() {
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use (i.e sqlite3|postgres)")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.blobStoreType,
+		"blob-store-type",
+		blob.LocalBlobStoreType.String(),
+		fmt.Sprintf("The type of blob store logs are stored in. Options: %s", strings.Join(blob.BlobStoreTypes(), ", ")))
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.localBlobStoreDir,
+		"blob-store-local-directory",
+		defaultLocalBlobStoreDir,
+		"The path on the local host blobs are stored in, if using the local blob store")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.s3BlobStoreConfig.BucketName,
+		"blob-store-aws-s3-bucket-name",
+		"",
+		"The name of the S3 bucket blobs are stored in, if using the S3 blob store")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.s3BlobStoreConfig.Region,
+		"blob-store-aws-s3-region",
+		"",
+		"The region of the S3 bucket blobs are stored in, if using the S3 blob store")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.s3BlobStoreConfig.AccessKeyID,
+		"blob-store-aws-s3-access-key-id",
+		"",
+		"The AWS Access Key ID to use to authenticate to the S3 bucket, if using the S3 blob store")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.s3BlobStoreConfig.SecretAccessKey,
+		"blob-store-aws-s3-secret-key",
+		"",
+		"The AWS Secret Key to use to authenticate to the S3 bucket, if using the S3 blob store")
+	logsRootCmd.PersistentFlags().StringVar(
+		&logsCmdConfig.archivePrefix,
+		"archive-prefix",
+		log.DefaultArchivePrefix,
+		"The blob store key prefix archived logs are moved to")
+	logsRootCmd.PersistentFlags().BoolVarP(
+		&logsCmdConfig.verbose,
+		"verbose",
+		"v",
+		false,
+		"Enable verbose log output")
+
+	logsArchiveCmd.Flags().IntVar(
+		&logsCmdConfig.archiveAfterDays,
+		"archive-after-days",
+		0,
+		"Archive logs older than this many days. Set to zero to skip archival.")
+	logsArchiveCmd.Flags().IntVar(
+		&logsCmdConfig.deleteAfterDays,
+		"delete-after-days",
+		0,
+		"Delete the data for logs older than this many days. Set to zero to skip deletion.")
+
+	commands.RootCmd.AddCommand(logsRootCmd)
+	logsRootCmd.AddCommand(logsArchiveCmd)
+	logsRootCmd.AddCommand(logsInspectCmd)
+	logsRootCmd.AddCommand(logsUsageCmd)
+}
+
+var logsCmdConfig = struct {
+	databaseConfig           store.DatabaseConfig
+	databaseDriver           string
+	databaseConnectionString string
+	blobStoreType            string
+	localBlobStoreDir        string
+	s3BlobStoreConfig        blob.S3BlobStoreConfig
+	archivePrefix            string
+	archiveAfterDays         int
+	deleteAfterDays          int
+	verbose                  bool
+	logFactory               logger.LogFactory
+	db                       *store.DB
+	dbCleanup                func()
+	blobStore                services.BlobStore
+	logStore                 store.LogStore
+	ownershipStore           store.OwnershipStore
+}{}
+
+var logsRootCmd = &cobra.Command{
+	Use:   "logs (command)",
+	Short: "Runs and inspects log retention (archival and deletion of log data)",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		logsCmdConfig.databaseConfig = store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(logsCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(logsCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logLevels := logger.LogLevelConfig("")
+		if logsCmdConfig.verbose {
+			logLevels = "LogService=debug"
+		}
+		logRegistry, err := logger.NewLogRegistry(logLevels)
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+		logsCmdConfig.logFactory = logFactory
+
+		switch strings.ToLower(logsCmdConfig.blobStoreType) {
+		case strings.ToLower(blob.AWSS3BlobStoreType.String()):
+			logsCmdConfig.blobStore, err = blob.NewS3BlobStore(logsCmdConfig.s3BlobStoreConfig, logFactory)
+			if err != nil {
+				return fmt.Errorf("error creating S3 blob store: %w", err)
+			}
+		case strings.ToLower(blob.LocalBlobStoreType.String()):
+			logsCmdConfig.blobStore = blob.NewLocalBlobStore(blob.LocalBlobStoreDirectory(logsCmdConfig.localBlobStoreDir))
+		default:
+			return fmt.Errorf("error unsupported blob store type: %v", logsCmdConfig.blobStoreType)
+		}
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(context.Background(), logsCmdConfig.databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", logsCmdConfig.databaseConfig.Driver, err)
+		}
+		logsCmdConfig.db = db
+		logsCmdConfig.dbCleanup = cleanup
+		logsCmdConfig.logStore = logs.NewStore(db, logFactory)
+		logsCmdConfig.ownershipStore = ownerships.NewStore(db, logFactory)
+
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if logsCmdConfig.dbCleanup != nil {
+			logsCmdConfig.dbCleanup()
+			logsCmdConfig.dbCleanup = nil
+		}
+	},
+}
+
+var logsArchiveCmd = &cobra.Command{
+	Use:           "archive",
+	Short:         "Applies the log retention policy once, archiving and deleting the data for old logs",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if logsCmdConfig.archiveAfterDays <= 0 && logsCmdConfig.deleteAfterDays <= 0 {
+			return fmt.Errorf("error: at least one of --archive-after-days or --delete-after-days must be specified")
+		}
+		logService := makeLogService(log.LogServiceConfig{
+			ArchiveAfter:  time.Duration(logsCmdConfig.archiveAfterDays) * 24 * time.Hour,
+			DeleteAfter:   time.Duration(logsCmdConfig.deleteAfterDays) * 24 * time.Hour,
+			ArchivePrefix: logsCmdConfig.archivePrefix,
+		})
+		result, err := logService.ApplyRetention(context.Background(), models.NewTime(time.Now()))
+		if err != nil {
+			return err
+		}
+		cli.Stdout.Printf("Archived: %d\n", result.Archived)
+		cli.Stdout.Printf("Expired: %d\n", result.Expired)
+		cli.Stdout.Printf("Failed: %d\n", result.Failed)
+		if result.Failed > 0 {
+			return fmt.Errorf("error applying retention policy to %d log(s); see warnings above for details", result.Failed)
+		}
+		return nil
+	},
+}
+
+var logsInspectCmd = &cobra.Command{
+	Use:           "inspect log-id",
+	Short:         "Shows the retention state of the log with the specified ID",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := models.ParseResourceID(args[0])
+		if err != nil {
+			return fmt.Errorf("error parsing log ID: %w", err)
+		}
+		descriptor, err := logsCmdConfig.logStore.Read(context.Background(), nil, models.LogDescriptorIDFromResourceID(id))
+		if err != nil {
+			return fmt.Errorf("error reading log '%s': %w", id, err)
+		}
+
+		cli.Stdout.Printf("Log '%s':\n", descriptor.ID)
+		cli.Stdout.Printf("  Created At: %s\n", descriptor.CreatedAt.String())
+		cli.Stdout.Printf("  Resource ID: %s\n", descriptor.ResourceID)
+		cli.Stdout.Printf("  Sealed: %t\n", descriptor.Sealed)
+		cli.Stdout.Printf("  Size Bytes: %d\n", descriptor.SizeBytes)
+		if descriptor.IsArchived() {
+			cli.Stdout.Printf("  Archived At: %s\n", descriptor.ArchivedAt.String())
+			cli.Stdout.Printf("  Archive Blob Key: %s\n", descriptor.ArchiveBlobKey)
+			cli.Stdout.Printf("  Archived Size Bytes: %d\n", descriptor.ArchivedSizeBytes)
+		}
+		if descriptor.IsExpired() {
+			cli.Stdout.Printf("  Expired At: %s\n", descriptor.ExpiredAt.String())
+		}
+		return nil
+	},
+}
+
+var logsUsageCmd = &cobra.Command{
+	Use:           "usage repo-id",
+	Short:         "Shows the storage used by the logs for the repo with the specified ID",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := models.ParseResourceID(args[0])
+		if err != nil {
+			return fmt.Errorf("error parsing repo ID: %w", err)
+		}
+		usage, err := logsCmdConfig.logStore.UsageByRepoID(context.Background(), nil, models.RepoIDFromResourceID(id))
+		if err != nil {
+			return fmt.Errorf("error reading log usage for repo '%s': %w", id, err)
+		}
+
+		cli.Stdout.Printf("Log usage for repo '%s':\n", id)
+		cli.Stdout.Printf("  Live: %d log(s), %d bytes\n", usage.LiveCount, usage.LiveSizeBytes)
+		cli.Stdout.Printf("  Archived: %d log(s), %d bytes\n", usage.ArchivedCount, usage.ArchivedSizeBytes)
+		cli.Stdout.Printf("  Expired: %d log(s)\n", usage.ExpiredCount)
+		return nil
+	},
+}
+
+func makeLogService(config log.LogServiceConfig) *log.LogService {
+	config.WriterConfig = log.DefaultWriterConfig
+	return log.NewLogService(
+		logsCmdConfig.logFactory,
+		clock.New(),
+		logsCmdConfig.db,
+		config,
+		logsCmdConfig.blobStore,
+		logsCmdConfig.logStore,
+		logsCmdConfig.ownershipStore)
+}
@@ -0,0 +1,305 @@
+package prune
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/artifact"
+	"github.com/buildbeaver/buildbeaver/server/services/blob"
+	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/artifacts"
+	"github.com/buildbeaver/buildbeaver/server/store/builds"
+	"github.com/buildbeaver/buildbeaver/server/store/events"
+	"github.com/buildbeaver/buildbeaver/server/store/grants"
+	"github.com/buildbeaver/buildbeaver/server/store/identities"
+	"github.com/buildbeaver/buildbeaver/server/store/jobs"
+	"github.com/buildbeaver/buildbeaver/server/store/logs"
+	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+	"github.com/buildbeaver/buildbeaver/server/store/resource_links"
+	"github.com/buildbeaver/buildbeaver/server/store/steps"
+)
+
+const (
+	defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+	defaultLocalBlobStoreDir      = "/var/lib/buildbeaver/blob"
+)
+
+func init() {
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use (i.e sqlite3|postgres)")
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use")
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.blobStoreType,
+		"blob-store-type",
+		blob.LocalBlobStoreType.String(),
+		fmt.Sprintf("The type of blob store artifacts and logs are stored in. Options: %s", strings.Join(blob.BlobStoreTypes(), ", ")))
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.localBlobStoreDir,
+		"blob-store-local-directory",
+		defaultLocalBlobStoreDir,
+		"The path on the local host blobs are stored in, if using the local blob store")
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.s3BlobStoreConfig.BucketName,
+		"blob-store-aws-s3-bucket-name",
+		"",
+		"The name of the S3 bucket blobs are stored in, if using the S3 blob store")
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.s3BlobStoreConfig.Region,
+		"blob-store-aws-s3-region",
+		"",
+		"The region of the S3 bucket blobs are stored in, if using the S3 blob store")
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.s3BlobStoreConfig.AccessKeyID,
+		"blob-store-aws-s3-access-key-id",
+		"",
+		"The AWS Access Key ID to use to authenticate to the S3 bucket, if using the S3 blob store")
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.s3BlobStoreConfig.SecretAccessKey,
+		"blob-store-aws-s3-secret-key",
+		"",
+		"The AWS Secret Key to use to authenticate to the S3 bucket, if using the S3 blob store")
+	pruneCmd.Flags().StringVar(
+		&pruneCmdConfig.buildsOlderThan,
+		"builds-older-than",
+		"",
+		"Prune finished builds created longer ago than this, as a number of days (e.g. 90d) or a duration (e.g. 720h)")
+	pruneCmd.Flags().IntVar(
+		&pruneCmdConfig.keepLast,
+		"keep-last",
+		0,
+		"The number of most recent builds to keep for each repo, regardless of age")
+	pruneCmd.Flags().BoolVar(
+		&pruneCmdConfig.hard,
+		"hard",
+		false,
+		"Permanently delete builds from the database rather than soft-deleting them; builds that were "+
+			"previously soft-deleted are also deleted")
+	pruneCmd.Flags().BoolVar(
+		&pruneCmdConfig.dryRun,
+		"dry-run",
+		false,
+		"Report what would be pruned without changing anything")
+	pruneCmd.Flags().BoolVarP(
+		&pruneCmdConfig.skipConfirmation,
+		"skip-confirmation",
+		"",
+		false,
+		"Skip interactive confirmation and automatically answer Yes to confirmation questions")
+	pruneCmd.Flags().BoolVarP(
+		&pruneCmdConfig.verbose,
+		"verbose",
+		"v",
+		false,
+		"Enable verbose log output, listing each build as it is pruned")
+	_ = pruneCmd.MarkFlagRequired("builds-older-than")
+
+	commands.RootCmd.AddCommand(pruneCmd)
+}
+
+var pruneCmdConfig = struct {
+	databaseDriver           string
+	databaseConnectionString string
+	blobStoreType            string
+	localBlobStoreDir        string
+	s3BlobStoreConfig        blob.S3BlobStoreConfig
+	buildsOlderThan          string
+	keepLast                 int
+	hard                     bool
+	dryRun                   bool
+	skipConfirmation         bool
+	verbose                  bool
+}{}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune --builds-older-than age [--keep-last n]",
+	Short: "Deletes old builds along with their jobs, steps, events, logs and artifacts",
+	Long: "Deletes finished builds older than the specified age, along with their jobs, steps, events, logs and\n" +
+		"artifacts, and reports the blob store space reclaimed. Builds whose jobs are reused by other builds\n" +
+		"are kept. By default builds, jobs and steps are soft-deleted: they disappear from the API and UI, and\n" +
+		"their events, artifacts and log data are deleted, but their rows remain in the database. Use --hard to\n" +
+		"remove the rows as well.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		age, err := parseAge(pruneCmdConfig.buildsOlderThan)
+		if err != nil {
+			return fmt.Errorf("error parsing --builds-older-than: %w", err)
+		}
+		if pruneCmdConfig.keepLast < 0 {
+			return fmt.Errorf("error: --keep-last must not be negative")
+		}
+		criteria := models.BuildPruneCriteria{
+			CreatedBefore: models.NewTime(time.Now().Add(-age)),
+			KeepLast:      pruneCmdConfig.keepLast,
+		}
+
+		if !pruneCmdConfig.dryRun {
+			action := "soft-deleted"
+			if pruneCmdConfig.hard {
+				action = "permanently deleted"
+			}
+			confirmed := cli.AskForConfirmation(fmt.Sprintf(
+				"Finished builds created before %s will be %s along with their logs and artifacts, except for the newest %d builds of each repo. Are you sure?",
+				criteria.CreatedBefore.Format(time.RFC3339), action, criteria.KeepLast), pruneCmdConfig.skipConfirmation)
+			if !confirmed {
+				cli.Stdout.Printf("Prune cancelled.")
+				return nil
+			}
+		}
+
+		p, cleanup, err := makePruner(ctx)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		result, err := p.Prune(ctx, criteria)
+		if err != nil {
+			return err
+		}
+		if pruneCmdConfig.dryRun {
+			cli.Stdout.Printf("Dry run; nothing was deleted. Would prune:\n")
+		} else {
+			cli.Stdout.Printf("Pruned:\n")
+		}
+		cli.Stdout.Printf("  Builds: %d\n", result.Builds)
+		cli.Stdout.Printf("  Jobs: %d\n", result.Jobs)
+		cli.Stdout.Printf("  Steps: %d\n", result.Steps)
+		cli.Stdout.Printf("  Artifacts: %d\n", result.Artifacts)
+		cli.Stdout.Printf("  Logs: %d\n", result.Logs)
+		cli.Stdout.Printf("  Reclaimed Bytes: %d\n", result.ReclaimedBytes)
+		if result.Failed > 0 {
+			return fmt.Errorf("error pruning %d build(s); see warnings above for details", result.Failed)
+		}
+		return nil
+	},
+}
+
+// parseAge parses an age given either as a whole number of days (e.g. "90d") or as a Go duration (e.g. "720h").
+func parseAge(str string) (time.Duration, error) {
+	var (
+		age time.Duration
+		err error
+	)
+	if days := strings.TrimSuffix(str, "d"); days != str {
+		var n int
+		n, err = strconv.Atoi(days)
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		age, err = time.ParseDuration(str)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q; expected a number of days (e.g. 90d) or a duration (e.g. 720h)", str)
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("age must be positive, got %q", str)
+	}
+	return age, nil
+}
+
+func makePruner(ctx context.Context) (*pruner, func(), error) {
+	// stores need a log factory; use a very plain log format
+	logLevels := logger.LogLevelConfig("")
+	if pruneCmdConfig.verbose {
+		logLevels = "Pruner=debug"
+	}
+	logRegistry, err := logger.NewLogRegistry(logLevels)
+	if err != nil {
+		return nil, nil, err
+	}
+	logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+	var blobStore services.BlobStore
+	switch strings.ToLower(pruneCmdConfig.blobStoreType) {
+	case strings.ToLower(blob.AWSS3BlobStoreType.String()):
+		blobStore, err = blob.NewS3BlobStore(pruneCmdConfig.s3BlobStoreConfig, logFactory)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating S3 blob store: %w", err)
+		}
+	case strings.ToLower(blob.LocalBlobStoreType.String()):
+		blobStore = blob.NewLocalBlobStore(blob.LocalBlobStoreDirectory(pruneCmdConfig.localBlobStoreDir))
+	default:
+		return nil, nil, fmt.Errorf("error unsupported blob store type: %v", pruneCmdConfig.blobStoreType)
+	}
+
+	// open the database but do not perform migrations
+	databaseConfig := store.DatabaseConfig{
+		ConnectionString:   store.DatabaseConnectionString(pruneCmdConfig.databaseConnectionString),
+		Driver:             store.DBDriver(pruneCmdConfig.databaseDriver),
+		MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+		MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+	}
+	db, cleanup, err := store.NewDatabase(ctx, databaseConfig, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening %s database: %w", databaseConfig.Driver, err)
+	}
+
+	artifactStore := artifacts.NewStore(db, logFactory)
+	jobStore := jobs.NewStore(db, logFactory)
+	logStore := logs.NewStore(db, logFactory)
+	ownershipStore := ownerships.NewStore(db, logFactory)
+	resourceLinkStore := resource_links.NewStore(db, logFactory)
+	artifactService, err := artifact.NewArtifactService(
+		db,
+		artifactStore,
+		jobStore,
+		ownershipStore,
+		blobStore,
+		resourceLinkStore,
+		store.NewLocalNotifier(), // pruning never seals artifacts, so there's nobody to notify
+		artifact.ArtifactSigningConfig{},
+		logFactory)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	logService := log.NewLogService(
+		logFactory,
+		clock.New(),
+		db,
+		log.LogServiceConfig{WriterConfig: log.DefaultWriterConfig, ArchivePrefix: log.DefaultArchivePrefix},
+		blobStore,
+		logStore,
+		ownershipStore)
+
+	return &pruner{
+		db:                db,
+		buildStore:        builds.NewStore(db, logFactory),
+		jobStore:          jobStore,
+		stepStore:         steps.NewStore(db, logFactory),
+		artifactStore:     artifactStore,
+		eventStore:        events.NewStore(db, logFactory),
+		identityStore:     identities.NewStore(db, logFactory),
+		grantStore:        grants.NewStore(db, logFactory),
+		ownershipStore:    ownershipStore,
+		resourceLinkStore: resourceLinkStore,
+		logStore:          logStore,
+		logService:        logService,
+		artifactService:   artifactService,
+		hard:              pruneCmdConfig.hard,
+		dryRun:            pruneCmdConfig.dryRun,
+		Log:               logFactory("Pruner"),
+	}, cleanup, nil
+}
@@ -0,0 +1,347 @@
+package prune
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// pruneBatchSize is the number of builds read from the database at a time.
+const pruneBatchSize = 100
+
+// pruneResult counts the resources removed (or that would be removed, for a dry run) by a prune.
+type pruneResult struct {
+	Builds         int
+	Jobs           int
+	Steps          int
+	Artifacts      int
+	Logs           int
+	ReclaimedBytes int64
+	Failed         int
+}
+
+// pruner removes old builds along with everything that belongs to them. In soft mode the builds, jobs and steps
+// are soft-deleted and only their events, artifacts and log data are removed; log descriptors are kept so that the
+// logs can be reported as expired. In hard mode every row belonging to each build is deleted from the database.
+type pruner struct {
+	db                *store.DB
+	buildStore        store.BuildStore
+	jobStore          store.JobStore
+	stepStore         store.StepStore
+	artifactStore     store.ArtifactStore
+	eventStore        store.EventStore
+	identityStore     store.IdentityStore
+	grantStore        store.GrantStore
+	ownershipStore    store.OwnershipStore
+	resourceLinkStore store.ResourceLinkStore
+	logStore          store.LogStore
+	logService        services.LogService
+	artifactService   services.ArtifactService
+	hard              bool
+	dryRun            bool
+	logger.Log
+}
+
+// buildContents is everything belonging to a build that a prune removes.
+type buildContents struct {
+	build     *models.Build
+	jobs      []*models.Job
+	steps     []*models.Step
+	artifacts []*models.Artifact
+	logs      []*models.LogDescriptor
+}
+
+// Prune removes all builds matching criteria. Builds that can't be removed are logged and counted, and the
+// prune continues with the next build; running the prune again will retry them.
+func (p *pruner) Prune(ctx context.Context, criteria models.BuildPruneCriteria) (*pruneResult, error) {
+	criteria.IncludeDeleted = p.hard
+	result := &pruneResult{}
+	var after models.BuildID
+	for {
+		builds, err := p.buildStore.ListPrunable(ctx, nil, criteria, after, pruneBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("error listing builds to prune: %w", err)
+		}
+		for _, build := range builds {
+			err := p.pruneBuild(ctx, build, result)
+			if err != nil {
+				p.Warnf("Error pruning build %q: %v", build.ID, err)
+				result.Failed++
+			}
+		}
+		if len(builds) < pruneBatchSize {
+			break
+		}
+		after = builds[len(builds)-1].ID // move on to next page of results
+	}
+	return result, nil
+}
+
+func (p *pruner) pruneBuild(ctx context.Context, build *models.Build, result *pruneResult) error {
+	contents, err := p.readBuildContents(ctx, build)
+	if err != nil {
+		return err
+	}
+	reclaimed := contents.reclaimableBytes()
+	if !p.dryRun {
+		if p.hard {
+			err = p.hardDelete(ctx, contents)
+		} else {
+			err = p.softDelete(ctx, contents)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	p.Debugf("Pruned build %q (%d jobs, %d steps, %d artifacts, %d logs, %d bytes)",
+		build.ID, len(contents.jobs), len(contents.steps), len(contents.artifacts), len(contents.logs), reclaimed)
+	result.Builds++
+	result.Jobs += len(contents.jobs)
+	result.Steps += len(contents.steps)
+	result.Artifacts += len(contents.artifacts)
+	result.Logs += len(contents.logs)
+	result.ReclaimedBytes += reclaimed
+	return nil
+}
+
+func (p *pruner) readBuildContents(ctx context.Context, build *models.Build) (*buildContents, error) {
+	var (
+		jobs  []*models.Job
+		steps []*models.Step
+		err   error
+	)
+	if p.hard {
+		jobs, err = p.jobStore.ListByBuildIDIncludingDeleted(ctx, nil, build.ID)
+	} else {
+		jobs, err = p.jobStore.ListByBuildID(ctx, nil, build.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs: %w", err)
+	}
+	jobIDs := make([]models.JobID, 0, len(jobs))
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.ID)
+	}
+	if p.hard {
+		steps, err = p.stepStore.ListByJobIDsIncludingDeleted(ctx, nil, jobIDs)
+	} else {
+		steps, err = p.stepStore.ListByJobIDs(ctx, nil, jobIDs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing steps: %w", err)
+	}
+	artifacts, err := p.artifactStore.ListByJobIDs(ctx, nil, jobIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts: %w", err)
+	}
+
+	// Logs are found via their resource rather than the build's log tree, to include logs from previous attempts
+	// at jobs that are no longer referenced by the job itself
+	resourceIDs := []models.ResourceID{build.ID.ResourceID}
+	for _, job := range jobs {
+		resourceIDs = append(resourceIDs, job.ID.ResourceID)
+	}
+	for _, step := range steps {
+		resourceIDs = append(resourceIDs, step.ID.ResourceID)
+	}
+	logs, err := p.logStore.ListByResourceIDs(ctx, nil, resourceIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error listing logs: %w", err)
+	}
+
+	return &buildContents{
+		build:     build,
+		jobs:      jobs,
+		steps:     steps,
+		artifacts: artifacts,
+		logs:      childLogsFirst(logs),
+	}, nil
+}
+
+// softDelete removes the build's events, artifacts and log data, then soft-deletes its steps, jobs and the
+// build itself. Steps, jobs and builds are soft-deleted last so that a failed prune is retried on the next run.
+func (p *pruner) softDelete(ctx context.Context, contents *buildContents) error {
+	err := p.deleteArtifactsAndEvents(ctx, contents)
+	if err != nil {
+		return err
+	}
+	for _, descriptor := range contents.logs {
+		if descriptor.IsExpired() {
+			continue
+		}
+		if !descriptor.Sealed {
+			err := p.logService.Seal(ctx, nil, descriptor.ID)
+			if err != nil {
+				return fmt.Errorf("error sealing log %q: %w", descriptor.ID, err)
+			}
+		}
+		err := p.logService.Expire(ctx, descriptor.ID)
+		if err != nil {
+			return fmt.Errorf("error expiring log %q: %w", descriptor.ID, err)
+		}
+	}
+	return p.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		for _, step := range contents.steps {
+			err := p.stepStore.SoftDelete(ctx, tx, step)
+			if err != nil {
+				return fmt.Errorf("error soft-deleting step %q: %w", step.ID, err)
+			}
+		}
+		for _, job := range contents.jobs {
+			err := p.jobStore.SoftDelete(ctx, tx, job)
+			if err != nil {
+				return fmt.Errorf("error soft-deleting job %q: %w", job.ID, err)
+			}
+		}
+		err := p.buildStore.SoftDelete(ctx, tx, contents.build)
+		if err != nil {
+			return fmt.Errorf("error soft-deleting build: %w", err)
+		}
+		return nil
+	})
+}
+
+// hardDelete permanently deletes the build and everything belonging to it. All rows are deleted in a single
+// transaction, with logs deleted last since the build, jobs and steps refer to them. If the transaction fails
+// then the build is retried on the next run, even though some of its data may already be gone.
+func (p *pruner) hardDelete(ctx context.Context, contents *buildContents) error {
+	err := p.deleteArtifactsAndEvents(ctx, contents)
+	if err != nil {
+		return err
+	}
+	build := contents.build
+	return p.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		err := p.deleteBuildIdentity(ctx, tx, build.ID)
+		if err != nil {
+			return err
+		}
+		jobIDs := make([]models.JobID, 0, len(contents.jobs))
+		for _, job := range contents.jobs {
+			jobIDs = append(jobIDs, job.ID)
+		}
+		err = p.stepStore.DeleteByJobIDs(ctx, tx, jobIDs)
+		if err != nil {
+			return fmt.Errorf("error deleting steps: %w", err)
+		}
+		err = p.jobStore.DeleteByBuildID(ctx, tx, build.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting jobs: %w", err)
+		}
+		err = p.eventStore.DeleteEventCounter(ctx, tx, build.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting event counter: %w", err)
+		}
+		err = p.buildStore.Delete(ctx, tx, build.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting build: %w", err)
+		}
+		resourceIDs := []models.ResourceID{build.ID.ResourceID}
+		for _, job := range contents.jobs {
+			resourceIDs = append(resourceIDs, job.ID.ResourceID)
+		}
+		for _, step := range contents.steps {
+			resourceIDs = append(resourceIDs, step.ID.ResourceID)
+		}
+		for _, resourceID := range resourceIDs {
+			err = p.ownershipStore.Delete(ctx, tx, resourceID)
+			if err != nil {
+				return fmt.Errorf("error deleting ownership for %q: %w", resourceID, err)
+			}
+			err = p.resourceLinkStore.Delete(ctx, tx, resourceID)
+			if err != nil {
+				return fmt.Errorf("error deleting resource link for %q: %w", resourceID, err)
+			}
+		}
+		for _, descriptor := range contents.logs {
+			err := p.logService.Delete(ctx, tx, descriptor.ID)
+			if err != nil {
+				return fmt.Errorf("error deleting log %q: %w", descriptor.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (p *pruner) deleteArtifactsAndEvents(ctx context.Context, contents *buildContents) error {
+	for _, artifact := range contents.artifacts {
+		err := p.artifactService.Delete(ctx, nil, artifact.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting artifact %q: %w", artifact.ID, err)
+		}
+	}
+	err := p.eventStore.DeleteEventsForBuild(ctx, nil, contents.build.ID)
+	if err != nil {
+		return fmt.Errorf("error deleting events: %w", err)
+	}
+	return nil
+}
+
+// deleteBuildIdentity deletes the identity the build's jobs used to access the server, along with its grants.
+func (p *pruner) deleteBuildIdentity(ctx context.Context, tx *store.Tx, buildID models.BuildID) error {
+	identity, err := p.identityStore.ReadByOwnerResource(ctx, tx, buildID.ResourceID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return nil // not an error; there is no identity
+		}
+		return fmt.Errorf("error reading identity for build: %w", err)
+	}
+	err = p.grantStore.DeleteAllGrantsForIdentity(ctx, tx, identity.ID)
+	if err != nil {
+		return fmt.Errorf("error deleting grants for identity %q: %w", identity.ID, err)
+	}
+	err = p.identityStore.Delete(ctx, tx, identity.ID)
+	if err != nil {
+		return fmt.Errorf("error deleting identity %q: %w", identity.ID, err)
+	}
+	return nil
+}
+
+// reclaimableBytes returns the amount of storage used by the build's artifacts and log data.
+func (c *buildContents) reclaimableBytes() int64 {
+	var total int64
+	for _, artifact := range c.artifacts {
+		total += int64(artifact.Size)
+	}
+	for _, descriptor := range c.logs {
+		switch {
+		case descriptor.IsExpired():
+		case descriptor.IsArchived():
+			total += descriptor.ArchivedSizeBytes
+		default:
+			total += descriptor.SizeBytes
+		}
+	}
+	return total
+}
+
+// childLogsFirst orders logs so that every log comes before its parent, since a log can't be deleted while other
+// logs still refer to it.
+func childLogsFirst(logs []*models.LogDescriptor) []*models.LogDescriptor {
+	byID := make(map[models.LogDescriptorID]*models.LogDescriptor, len(logs))
+	for _, descriptor := range logs {
+		byID[descriptor.ID] = descriptor
+	}
+	depth := func(descriptor *models.LogDescriptor) int {
+		d := 0
+		for parent, ok := byID[descriptor.ParentLogID]; ok && d < len(logs); parent, ok = byID[parent.ParentLogID] {
+			d++
+		}
+		return d
+	}
+	depths := make(map[models.LogDescriptorID]int, len(logs))
+	for _, descriptor := range logs {
+		depths[descriptor.ID] = depth(descriptor)
+	}
+	ordered := make([]*models.LogDescriptor, len(logs))
+	copy(ordered, logs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return depths[ordered[i].ID] > depths[ordered[j].ID]
+	})
+	return ordered
+}
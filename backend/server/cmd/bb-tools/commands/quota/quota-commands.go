@@ -0,0 +1,213 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/legal_entities"
+	"github.com/buildbeaver/buildbeaver/server/store/usage_quotas"
+)
+
+const defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+
+func init() {
+	quotaRootCmd.PersistentFlags().StringVar(
+		&quotaCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use for fetching and writing data (i.e sqlite3|postgres)")
+	quotaRootCmd.PersistentFlags().StringVar(
+		&quotaCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use for fetching and writing data")
+
+	commands.RootCmd.AddCommand(quotaRootCmd)
+	quotaRootCmd.AddCommand(quotaSetCmd)
+	quotaRootCmd.AddCommand(quotaClearCmd)
+	quotaRootCmd.AddCommand(quotaListCmd)
+}
+
+var quotaCmdConfig = struct {
+	databaseConfig           store.DatabaseConfig
+	databaseDriver           string
+	databaseConnectionString string
+	db                       *store.DB
+	dbCleanup                func()
+	legalEntityStore         store.LegalEntityStore
+	usageQuotaStore          store.UsageQuotaStore
+}{}
+
+var quotaRootCmd = &cobra.Command{
+	Use:   "quota set|clear|list",
+	Short: "Manage the monthly usage quotas of a legal entity.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		quotaCmdConfig.databaseConfig = store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(quotaCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(quotaCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logRegistry, err := logger.NewLogRegistry("")
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(context.Background(), quotaCmdConfig.databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", quotaCmdConfig.databaseConfig.Driver, err)
+		}
+		quotaCmdConfig.db = db
+		quotaCmdConfig.dbCleanup = cleanup
+		quotaCmdConfig.legalEntityStore = legal_entities.NewStore(db, logFactory)
+		quotaCmdConfig.usageQuotaStore = usage_quotas.NewStore(db, logFactory)
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if quotaCmdConfig.dbCleanup != nil {
+			quotaCmdConfig.dbCleanup()
+			quotaCmdConfig.dbCleanup = nil
+		}
+	},
+}
+
+var quotaSetCmd = &cobra.Command{
+	Use:           "set legal-entity-name build-seconds|artifact-bytes|log-bytes monthly-limit",
+	Short:         "Limits the monthly usage of a metric by a legal entity. Once the limit is reached no new builds are queued and queued jobs are failed until the next month.",
+	Args:          cobra.ExactArgs(3),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		monthlyLimit, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("error: monthly limit must be a whole number: %w", err)
+		}
+		return quotaCmdConfig.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+			legalEntity, metric, err := parseArgsAndRead(ctx, tx, args)
+			if err != nil {
+				return err
+			}
+			now := models.NewTime(time.Now())
+			quota, err := quotaCmdConfig.usageQuotaStore.ReadByMetric(ctx, tx, legalEntity.ID, metric)
+			if gerror.IsNotFound(err) {
+				quota = models.NewUsageQuota(now, legalEntity.ID, metric, monthlyLimit)
+				if err = quota.Validate(); err != nil {
+					return fmt.Errorf("error: invalid quota: %w", err)
+				}
+				err = quotaCmdConfig.usageQuotaStore.Create(ctx, tx, quota)
+			} else if err == nil {
+				quota.MonthlyLimit = monthlyLimit
+				quota.UpdatedAt = now
+				if err = quota.Validate(); err != nil {
+					return fmt.Errorf("error: invalid quota: %w", err)
+				}
+				err = quotaCmdConfig.usageQuotaStore.Update(ctx, tx, quota)
+			}
+			if err != nil {
+				return fmt.Errorf("error setting %s quota for '%s': %w", metric, legalEntity.Name, err)
+			}
+			cli.Stdout.Printf("Set monthly %s quota for '%s' to %d.\n", metric, legalEntity.Name, monthlyLimit)
+			return nil
+		})
+	},
+}
+
+var quotaClearCmd = &cobra.Command{
+	Use:           "clear legal-entity-name build-seconds|artifact-bytes|log-bytes",
+	Short:         "Removes a legal entity's monthly quota for a metric, allowing unlimited usage.",
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		return quotaCmdConfig.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+			legalEntity, metric, err := parseArgsAndRead(ctx, tx, args)
+			if err != nil {
+				return err
+			}
+			quota, err := quotaCmdConfig.usageQuotaStore.ReadByMetric(ctx, tx, legalEntity.ID, metric)
+			if err != nil {
+				if gerror.IsNotFound(err) {
+					cli.Stdout.Printf("Not cleared: '%s' has no %s quota.\n", legalEntity.Name, metric)
+					return nil
+				}
+				return fmt.Errorf("error reading %s quota for '%s': %w", metric, legalEntity.Name, err)
+			}
+			err = quotaCmdConfig.usageQuotaStore.Delete(ctx, tx, quota.ID)
+			if err != nil {
+				return fmt.Errorf("error clearing %s quota for '%s': %w", metric, legalEntity.Name, err)
+			}
+			cli.Stdout.Printf("Cleared.\n")
+			return nil
+		})
+	},
+}
+
+var quotaListCmd = &cobra.Command{
+	Use:           "list legal-entity-name",
+	Short:         "Lists the monthly quotas of a legal entity.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		legalEntity, err := quotaCmdConfig.legalEntityStore.ReadByName(ctx, nil, models.ResourceName(args[0]))
+		if err != nil {
+			return fmt.Errorf("error: Unable to find legal entity with name '%s': %w", args[0], err)
+		}
+		count := 0
+		pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+		for moreResults := true; moreResults; {
+			quotas, cursor, err := quotaCmdConfig.usageQuotaStore.ListByLegalEntityID(ctx, nil, legalEntity.ID, pagination)
+			if err != nil {
+				return fmt.Errorf("error listing quotas for '%s': %w", legalEntity.Name, err)
+			}
+			for _, quota := range quotas {
+				count++
+				cli.Stdout.Printf("    %s: %d per month\n", quota.Metric, quota.MonthlyLimit)
+			}
+			if cursor != nil && cursor.Next != nil {
+				pagination.Cursor = cursor.Next // move on to next page of results
+			} else {
+				moreResults = false
+			}
+		}
+		if count == 0 {
+			cli.Stdout.Printf("'%s' has no quotas.\n", legalEntity.Name)
+		}
+		return nil
+	},
+}
+
+// parseArgsAndRead parses the supplied arguments expecting the name of a legal entity and a usage metric.
+// Reads and returns the legal entity from the database.
+func parseArgsAndRead(ctx context.Context, txOrNil *store.Tx, args []string) (*models.LegalEntity, models.UsageMetric, error) {
+	legalEntityName := args[0]
+	if len(legalEntityName) == 0 {
+		return nil, "", fmt.Errorf("error: Legal Entity name must be specified")
+	}
+	metric := models.UsageMetric(args[1])
+	if !metric.Valid() {
+		return nil, "", fmt.Errorf("error: Unknown metric '%s' (expected one of %v)", metric, models.AllUsageMetrics)
+	}
+	legalEntity, err := quotaCmdConfig.legalEntityStore.ReadByName(ctx, txOrNil, models.ResourceName(legalEntityName))
+	if err != nil {
+		return nil, "", fmt.Errorf("error: Unable to find legal entity with name '%s': %w", legalEntityName, err)
+	}
+	return legalEntity, metric, nil
+}
@@ -0,0 +1,395 @@
+package runner_admin
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/authorization"
+	"github.com/buildbeaver/buildbeaver/server/services/credential"
+	"github.com/buildbeaver/buildbeaver/server/services/event"
+	"github.com/buildbeaver/buildbeaver/server/services/group"
+	"github.com/buildbeaver/buildbeaver/server/services/runner"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/authorizations"
+	"github.com/buildbeaver/buildbeaver/server/store/credentials"
+	"github.com/buildbeaver/buildbeaver/server/store/events"
+	"github.com/buildbeaver/buildbeaver/server/store/grants"
+	"github.com/buildbeaver/buildbeaver/server/store/group_memberships"
+	"github.com/buildbeaver/buildbeaver/server/store/groups"
+	"github.com/buildbeaver/buildbeaver/server/store/identities"
+	"github.com/buildbeaver/buildbeaver/server/store/jobs"
+	"github.com/buildbeaver/buildbeaver/server/store/legal_entities"
+	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+	"github.com/buildbeaver/buildbeaver/server/store/resource_links"
+	"github.com/buildbeaver/buildbeaver/server/store/runner_pools"
+	"github.com/buildbeaver/buildbeaver/server/store/runners"
+)
+
+const (
+	defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+	defaultJWTCertificateDir      = "/var/lib/buildbeaver/jwt-certs"
+	jwtCertFile                   = "jwt-cert.pem"
+	jwtPrivateKeyFile             = "jwt-private-key.pem"
+)
+
+func init() {
+	runnerRootCmd.PersistentFlags().StringVar(
+		&runnerCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use for fetching and writing data (i.e sqlite3|postgres)")
+	runnerRootCmd.PersistentFlags().StringVar(
+		&runnerCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use for fetching and writing data")
+	runnerRootCmd.PersistentFlags().StringVar(
+		&runnerCmdConfig.jwtCertificateDir,
+		"jwt-certificate-directory",
+		defaultJWTCertificateDir,
+		"The directory containing the server's key pair for signing JWT tokens, needed to load the credential service")
+	runnerListCmd.Flags().StringVar(
+		&runnerCmdConfig.legalEntityName,
+		"legal-entity",
+		"",
+		"Only list runners registered by the legal entity with this name")
+	runnerDeleteCmd.Flags().BoolVar(
+		&runnerCmdConfig.skipConfirmation,
+		"skip-confirmation",
+		false,
+		"Skip interactive confirmation and automatically answer Yes to confirmation questions")
+
+	commands.RootCmd.AddCommand(runnerRootCmd)
+	runnerRootCmd.AddCommand(runnerListCmd)
+	runnerRootCmd.AddCommand(runnerDisableCmd)
+	runnerRootCmd.AddCommand(runnerEnableCmd)
+	runnerRootCmd.AddCommand(runnerDeleteCmd)
+	runnerRootCmd.AddCommand(runnerShareCmd)
+	runnerRootCmd.AddCommand(runnerUnshareCmd)
+}
+
+var runnerCmdConfig = struct {
+	databaseConfig           store.DatabaseConfig
+	databaseDriver           string
+	databaseConnectionString string
+	jwtCertificateDir        string
+	legalEntityName          string
+	skipConfirmation         bool
+	db                       *store.DB
+	dbCleanup                func()
+	legalEntityStore         store.LegalEntityStore
+	runnerService            services.RunnerService
+}{}
+
+var runnerRootCmd = &cobra.Command{
+	Use:     "runner list|disable|enable|delete|share|unshare",
+	Aliases: []string{"runners"},
+	Short:   "Lists, disables, re-enables, deletes and shares runners.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		runnerCmdConfig.databaseConfig = store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(runnerCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(runnerCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logRegistry, err := logger.NewLogRegistry("")
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(context.Background(), runnerCmdConfig.databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", runnerCmdConfig.databaseConfig.Driver, err)
+		}
+		runnerCmdConfig.db = db
+		runnerCmdConfig.dbCleanup = cleanup
+
+		// we need the runner service in order to ensure business logic is run when disabling or deleting a runner,
+		// e.g. removing a deleted runner's credentials and access rights
+		credentialService, err := credential.NewCredentialService(
+			db,
+			credential.JWTConfig{
+				CertificateFile: certificates.CertificateFile(filepath.Join(runnerCmdConfig.jwtCertificateDir, jwtCertFile)),
+				PrivateKeyFile:  certificates.PrivateKeyFile(filepath.Join(runnerCmdConfig.jwtCertificateDir, jwtPrivateKeyFile)),
+			},
+			ownerships.NewStore(db, logFactory),
+			credentials.NewStore(db, logFactory),
+			logFactory,
+		)
+		if err != nil {
+			return fmt.Errorf("error creating credential service: %w", err)
+		}
+		authorizationService := authorization.NewAuthorizationService(
+			db,
+			grants.NewStore(db, logFactory),
+			ownerships.NewStore(db, logFactory),
+			authorizations.NewStore(db),
+			logFactory,
+		)
+		runnerCmdConfig.legalEntityStore = legal_entities.NewStore(db, logFactory)
+		runnerCmdConfig.runnerService = runner.NewRunnerService(
+			db,
+			credentialService,
+			group.NewGroupService(
+				db,
+				ownerships.NewStore(db, logFactory),
+				groups.NewStore(db, logFactory),
+				group_memberships.NewStore(db, logFactory),
+				grants.NewStore(db, logFactory),
+				authorizationService,
+				logFactory,
+			),
+			runners.NewStore(db, logFactory),
+			runner_pools.NewStore(db, logFactory),
+			ownerships.NewStore(db, logFactory),
+			resource_links.NewStore(db, logFactory),
+			identities.NewStore(db, logFactory),
+			jobs.NewStore(db, logFactory),
+			event.NewEventService(db, events.NewStore(db, logFactory), logFactory),
+			nil, // leader election is only needed to detect offline runners, which is left to the server
+			runner.RunnerHealthConfig{},
+			logFactory,
+		)
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if runnerCmdConfig.dbCleanup != nil {
+			runnerCmdConfig.dbCleanup()
+			runnerCmdConfig.dbCleanup = nil
+		}
+	},
+}
+
+var runnerListCmd = &cobra.Command{
+	Use:           "list [--legal-entity legal-entity-name]",
+	Short:         "Lists registered runners, and whether each is enabled and online.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		search := models.NewRunnerSearch()
+		search.Pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+		if runnerCmdConfig.legalEntityName != "" {
+			legalEntity, err := runnerCmdConfig.legalEntityStore.ReadByName(ctx, nil, models.ResourceName(runnerCmdConfig.legalEntityName))
+			if err != nil {
+				return fmt.Errorf("error: Unable to find legal entity with name '%s': %w", runnerCmdConfig.legalEntityName, err)
+			}
+			search.LegalEntityID = &legalEntity.ID
+		}
+		legalEntityNames := make(map[models.LegalEntityID]models.ResourceName)
+		count := 0
+		for moreResults := true; moreResults; {
+			// Search all runners rather than those a particular identity can see
+			runnerList, cursor, err := runnerCmdConfig.runnerService.Search(ctx, nil, models.IdentityID{}, *search)
+			if err != nil {
+				return fmt.Errorf("error listing runners: %w", err)
+			}
+			for _, r := range runnerList {
+				count++
+				ownerName, ok := legalEntityNames[r.LegalEntityID]
+				if !ok {
+					owner, err := runnerCmdConfig.legalEntityStore.Read(ctx, nil, r.LegalEntityID)
+					if err != nil {
+						return fmt.Errorf("error reading legal entity for runner '%s': %w", r.Name, err)
+					}
+					ownerName = owner.Name
+					legalEntityNames[r.LegalEntityID] = ownerName
+				}
+				enabled := "enabled"
+				if !r.Enabled {
+					enabled = "disabled"
+				}
+				online := "offline"
+				if r.Online {
+					online = "online"
+				}
+				cli.Stdout.Printf("    %s/%s: %s, %s, version %s, ID %s\n", ownerName, r.Name, enabled, online, r.SoftwareVersion, r.ID)
+			}
+			if cursor != nil && cursor.Next != nil {
+				search.Pagination.Cursor = cursor.Next // move on to next page of results
+			} else {
+				moreResults = false
+			}
+		}
+		if count == 0 {
+			cli.Stdout.Printf("No runners found.\n")
+		}
+		return nil
+	},
+}
+
+var runnerDisableCmd = &cobra.Command{
+	Use:           "disable legal-entity-name/runner-name|runner-id",
+	Short:         "Disables a runner so that it is not given any more jobs to run. Jobs it is already running are unaffected.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setRunnerEnabled(args[0], false)
+	},
+}
+
+var runnerEnableCmd = &cobra.Command{
+	Use:           "enable legal-entity-name/runner-name|runner-id",
+	Short:         "Re-enables a runner that was disabled, so that it is given jobs to run again.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setRunnerEnabled(args[0], true)
+	},
+}
+
+var runnerDeleteCmd = &cobra.Command{
+	Use:   "delete legal-entity-name/runner-name|runner-id",
+	Short: "Deletes a runner, removing its credentials so that it can no longer connect to the server.",
+	Long: "Deletes a runner, removing its credentials and access rights so that it can no longer connect to the\n" +
+		"server. The runner must be registered again before it can be used.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		r, err := readRunner(ctx, nil, args[0])
+		if err != nil {
+			return err
+		}
+		confirmed := cli.AskForConfirmation(fmt.Sprintf("Runner '%s' (%s) will be deleted. Are you sure?", r.Name, r.ID), runnerCmdConfig.skipConfirmation)
+		if !confirmed {
+			cli.Stdout.Printf("Delete cancelled.\n")
+			return nil
+		}
+		err = runnerCmdConfig.runnerService.SoftDelete(ctx, nil, r.ID, dto.DeleteRunner{ETag: r.ETag})
+		if err != nil {
+			return fmt.Errorf("error deleting runner '%s': %w", r.Name, err)
+		}
+		cli.Stdout.Printf("Deleted.\n")
+		return nil
+	},
+}
+
+var runnerShareCmd = &cobra.Command{
+	Use:   "share legal-entity-name/runner-name|runner-id legal-entity-name",
+	Short: "Shares a runner with another legal entity, so that it also runs jobs for that legal entity's repos.",
+	Long: "Shares a runner with another legal entity, so that it also runs jobs for that legal entity's repos.\n" +
+		"The runner can read the other legal entity's builds and secrets, so only share a runner with a\n" +
+		"legal entity that trusts whoever controls the runner.\n" +
+		"When jobs from several legal entities are queued, the runner is shared according to each legal\n" +
+		"entity's scheduling weight.",
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setRunnerShared(args[0], args[1], true)
+	},
+}
+
+var runnerUnshareCmd = &cobra.Command{
+	Use:           "unshare legal-entity-name/runner-name|runner-id legal-entity-name",
+	Short:         "Stops sharing a runner with another legal entity, so that it no longer runs jobs for that legal entity's repos.",
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setRunnerShared(args[0], args[1], false)
+	},
+}
+
+func setRunnerShared(nameOrID string, legalEntityName string, shared bool) error {
+	ctx := context.Background()
+	return runnerCmdConfig.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		r, err := readRunner(ctx, tx, nameOrID)
+		if err != nil {
+			return err
+		}
+		legalEntity, err := runnerCmdConfig.legalEntityStore.ReadByName(ctx, tx, models.ResourceName(legalEntityName))
+		if err != nil {
+			return fmt.Errorf("error: Unable to find legal entity with name '%s': %w", legalEntityName, err)
+		}
+		if shared {
+			err = runnerCmdConfig.runnerService.ShareWithLegalEntity(ctx, tx, r.ID, legalEntity.ID)
+			if err != nil {
+				return fmt.Errorf("error sharing runner '%s' with legal entity '%s': %w", r.Name, legalEntity.Name, err)
+			}
+			cli.Stdout.Printf("Shared.\n")
+		} else {
+			err = runnerCmdConfig.runnerService.StopSharingWithLegalEntity(ctx, tx, r.ID, legalEntity.ID)
+			if err != nil {
+				return fmt.Errorf("error unsharing runner '%s' with legal entity '%s': %w", r.Name, legalEntity.Name, err)
+			}
+			cli.Stdout.Printf("Unshared.\n")
+		}
+		return nil
+	})
+}
+
+func setRunnerEnabled(nameOrID string, enabled bool) error {
+	ctx := context.Background()
+	return runnerCmdConfig.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		r, err := readRunner(ctx, tx, nameOrID)
+		if err != nil {
+			return err
+		}
+		if r.Enabled == enabled {
+			if enabled {
+				cli.Stdout.Printf("Not enabled: runner '%s' is already enabled.\n", r.Name)
+			} else {
+				cli.Stdout.Printf("Not disabled: runner '%s' is already disabled.\n", r.Name)
+			}
+			return nil
+		}
+		r.Enabled = enabled
+		_, err = runnerCmdConfig.runnerService.Update(ctx, tx, r)
+		if err != nil {
+			return fmt.Errorf("error updating runner '%s': %w", r.Name, err)
+		}
+		if enabled {
+			cli.Stdout.Printf("Enabled.\n")
+		} else {
+			cli.Stdout.Printf("Disabled.\n")
+		}
+		return nil
+	})
+}
+
+// readRunner reads a runner, identified either by its ID or by the name of the legal entity that registered it
+// and the runner's name, separated by a slash.
+func readRunner(ctx context.Context, txOrNil *store.Tx, nameOrID string) (*models.Runner, error) {
+	legalEntityName, runnerName, found := strings.Cut(nameOrID, "/")
+	if !found {
+		id, err := models.ParseResourceID(nameOrID)
+		if err != nil || id.Kind() != models.RunnerResourceKind {
+			return nil, fmt.Errorf("error: Runner must be specified as legal-entity-name/runner-name or by runner ID")
+		}
+		r, err := runnerCmdConfig.runnerService.Read(ctx, txOrNil, models.RunnerIDFromResourceID(id))
+		if err != nil {
+			return nil, fmt.Errorf("error: Unable to find runner with ID '%s': %w", id, err)
+		}
+		return r, nil
+	}
+	legalEntity, err := runnerCmdConfig.legalEntityStore.ReadByName(ctx, txOrNil, models.ResourceName(legalEntityName))
+	if err != nil {
+		return nil, fmt.Errorf("error: Unable to find legal entity with name '%s': %w", legalEntityName, err)
+	}
+	r, err := runnerCmdConfig.runnerService.ReadByName(ctx, txOrNil, legalEntity.ID, models.ResourceName(runnerName))
+	if err != nil {
+		return nil, fmt.Errorf("error: Unable to find runner '%s' for legal entity '%s': %w", runnerName, legalEntity.Name, err)
+	}
+	return r, nil
+}
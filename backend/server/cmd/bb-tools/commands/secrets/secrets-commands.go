@@ -0,0 +1,225 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services/secret"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/builds"
+	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+	"github.com/buildbeaver/buildbeaver/server/store/repos"
+	"github.com/buildbeaver/buildbeaver/server/store/resource_links"
+	"github.com/buildbeaver/buildbeaver/server/store/secret_versions"
+	"github.com/buildbeaver/buildbeaver/server/store/secrets"
+)
+
+const (
+	defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+	defaultKeepVersions           = 5
+	defaultOlderThan              = 30 * 24 * time.Hour
+)
+
+func init() {
+	secretsRootCmd.PersistentFlags().StringVar(
+		&secretsCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use (i.e sqlite3|postgres)")
+	secretsRootCmd.PersistentFlags().StringVar(
+		&secretsCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use")
+	secretsPurgeVersionsCmd.Flags().IntVar(
+		&secretsCmdConfig.keep,
+		"keep",
+		defaultKeepVersions,
+		"The number of prior versions of each secret to keep, regardless of age")
+	secretsPurgeVersionsCmd.Flags().DurationVar(
+		&secretsCmdConfig.olderThan,
+		"older-than",
+		defaultOlderThan,
+		"Only purge versions that were superseded at least this long ago")
+	for _, cmd := range []*cobra.Command{secretsPurgeVersionsCmd, secretsPurgeCmd} {
+		cmd.Flags().BoolVar(
+			&secretsCmdConfig.skipConfirmation,
+			"skip-confirmation",
+			false,
+			"Skip interactive confirmation and automatically answer Yes to confirmation questions")
+	}
+
+	commands.RootCmd.AddCommand(secretsRootCmd)
+	secretsRootCmd.AddCommand(secretsPurgeVersionsCmd)
+	secretsRootCmd.AddCommand(secretsPurgeCmd)
+}
+
+var secretsCmdConfig = struct {
+	databaseDriver           string
+	databaseConnectionString string
+	keep                     int
+	olderThan                time.Duration
+	skipConfirmation         bool
+}{}
+
+var secretsRootCmd = &cobra.Command{
+	Use:     "secrets purge-versions|purge",
+	Aliases: []string{"secret"},
+	Short:   "Perform maintenance operations on secrets.",
+}
+
+var secretsPurgeVersionsCmd = &cobra.Command{
+	Use:   "purge-versions [--keep N] [--older-than duration]",
+	Short: "Permanently deletes old prior versions of secrets",
+	Long: "Permanently deletes the prior versions of secrets that were superseded more than --older-than ago,\n" +
+		"keeping the newest --keep prior versions of each secret. The current version of a secret is never\n" +
+		"deleted. Jobs that pin their secrets to the start of a build will fail if a version they need is purged,\n" +
+		"so --older-than should be longer than any build takes to run.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if secretsCmdConfig.keep < 0 {
+			return fmt.Errorf("error: --keep must not be negative")
+		}
+		supersededBefore := models.NewTime(time.Now().Add(-secretsCmdConfig.olderThan))
+		confirmed := cli.AskForConfirmation(fmt.Sprintf(
+			"Prior versions of secrets superseded before %s will be permanently deleted, except for the newest %d versions of each secret. Are you sure?",
+			supersededBefore.Format(time.RFC3339), secretsCmdConfig.keep), secretsCmdConfig.skipConfirmation)
+		if !confirmed {
+			cli.Stdout.Printf("Purge cancelled.")
+			return nil
+		}
+
+		db, logFactory, cleanup, err := openDatabase(ctx)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		purged, err := secret_versions.NewStore(db, logFactory).DeleteSuperseded(ctx, nil, secretsCmdConfig.keep, supersededBefore)
+		if err != nil {
+			return fmt.Errorf("error purging secret versions: %w", err)
+		}
+		cli.Stdout.Printf("Purged %d secret versions\n", purged)
+		return nil
+	},
+}
+
+var secretsPurgeCmd = &cobra.Command{
+	Use:   "purge repo-id",
+	Short: "Permanently deletes all secrets for a repo",
+	Long: "Permanently deletes all secrets for a repo, including their prior versions. Internal secrets managed by\n" +
+		"the server (e.g. the repo's SSH key) are kept. Builds for the repo will fail if their jobs use a\n" +
+		"purged secret, so this is intended for repos whose secrets have been compromised or are no longer needed.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		id, err := models.ParseResourceID(args[0])
+		if err != nil || id.Kind() != models.RepoResourceKind {
+			return fmt.Errorf("error: '%s' is not a valid repo ID", args[0])
+		}
+		repoID := models.RepoIDFromResourceID(id)
+
+		db, logFactory, cleanup, err := openDatabase(ctx)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		repo, err := repos.NewStore(db, logFactory).Read(ctx, nil, repoID)
+		if err != nil {
+			return fmt.Errorf("error: Unable to find repo with ID '%s': %w", repoID, err)
+		}
+		// we need the secret service in order to ensure business logic is run when deleting a secret,
+		// e.g. removing its versions and ownership; encryption isn't needed to delete
+		secretService := secret.NewSecretService(
+			db,
+			secrets.NewStore(db, logFactory),
+			secret_versions.NewStore(db, logFactory),
+			builds.NewStore(db, logFactory),
+			ownerships.NewStore(db, logFactory),
+			resource_links.NewStore(db, logFactory),
+			nil,
+			logFactory,
+		)
+
+		var secretIDs []models.SecretID
+		pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+		for moreResults := true; moreResults; {
+			secretList, cursor, err := secretService.ListByRepoID(ctx, nil, repoID, pagination)
+			if err != nil {
+				return fmt.Errorf("error listing secrets: %w", err)
+			}
+			for _, s := range secretList {
+				if !s.IsInternal {
+					secretIDs = append(secretIDs, s.ID)
+				}
+			}
+			if cursor != nil && cursor.Next != nil {
+				pagination.Cursor = cursor.Next // move on to next page of results
+			} else {
+				moreResults = false
+			}
+		}
+		if len(secretIDs) == 0 {
+			cli.Stdout.Printf("No secrets found for repo '%s'.\n", repo.Name)
+			return nil
+		}
+
+		confirmed := cli.AskForConfirmation(fmt.Sprintf(
+			"%d secrets for repo '%s' will be permanently deleted. Are you sure?", len(secretIDs), repo.Name), secretsCmdConfig.skipConfirmation)
+		if !confirmed {
+			cli.Stdout.Printf("Purge cancelled.")
+			return nil
+		}
+		err = db.WithTx(ctx, nil, func(tx *store.Tx) error {
+			for _, secretID := range secretIDs {
+				err := secretService.Delete(ctx, tx, secretID)
+				if err != nil {
+					return fmt.Errorf("error deleting secret '%s': %w", secretID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		cli.Stdout.Printf("Purged %d secrets\n", len(secretIDs))
+		return nil
+	},
+}
+
+// openDatabase opens the database but does not perform migrations, and returns a log factory for stores to use.
+func openDatabase(ctx context.Context) (*store.DB, logger.LogFactory, func(), error) {
+	databaseConfig := store.DatabaseConfig{
+		ConnectionString:   store.DatabaseConnectionString(secretsCmdConfig.databaseConnectionString),
+		Driver:             store.DBDriver(secretsCmdConfig.databaseDriver),
+		MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+		MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+	}
+
+	// stores need a log factory; use a very plain log format
+	logRegistry, err := logger.NewLogRegistry("")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+	db, cleanup, err := store.NewDatabase(ctx, databaseConfig, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error opening %s database: %w", databaseConfig.Driver, err)
+	}
+	return db, logFactory, cleanup, nil
+}
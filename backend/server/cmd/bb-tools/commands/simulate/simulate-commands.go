@@ -0,0 +1,237 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/simulator"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/jobs"
+)
+
+const (
+	defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+	defaultHistory                = 7 * 24 * time.Hour
+	defaultTargetWait             = 2 * time.Minute
+	defaultTargetPercentile       = 95
+	defaultMaxRunners             = 100
+)
+
+func init() {
+	simulateQueueCmd.Flags().StringVar(
+		&simulateCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use (i.e sqlite3|postgres)")
+	simulateQueueCmd.Flags().StringVar(
+		&simulateCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use")
+	simulateQueueCmd.Flags().DurationVar(
+		&simulateCmdConfig.history,
+		"history",
+		defaultHistory,
+		"How far back to read job history to replay through the simulated queue")
+	simulateQueueCmd.Flags().StringArrayVar(
+		&simulateCmdConfig.runners,
+		"runner",
+		nil,
+		"A group of identical runners in the scenario to simulate, as labels:count[:parallel-jobs] where labels\n"+
+			"is a comma separated list (e.g. linux,amd64:4 or macos,arm64:2:2). Repeat for each group of runners.")
+	simulateQueueCmd.Flags().StringVar(
+		&simulateCmdConfig.grow,
+		"grow",
+		"",
+		"The labels of a runner group to find the number of runners needed for (e.g. linux,amd64).\n"+
+			"If not set the scenario is simulated as given.")
+	simulateQueueCmd.Flags().DurationVar(
+		&simulateCmdConfig.targetWait,
+		"target-wait",
+		defaultTargetWait,
+		"The longest wait for a runner that jobs run by the --grow group should see, at --target-percentile")
+	simulateQueueCmd.Flags().Float64Var(
+		&simulateCmdConfig.targetPercentile,
+		"target-percentile",
+		defaultTargetPercentile,
+		"The percentile (0-100) of wait times that must be within --target-wait")
+	simulateQueueCmd.Flags().IntVar(
+		&simulateCmdConfig.maxRunners,
+		"max-runners",
+		defaultMaxRunners,
+		"The largest number of runners to try in the --grow group before giving up")
+	_ = simulateQueueCmd.MarkFlagRequired("runner")
+
+	commands.RootCmd.AddCommand(simulateQueueCmd)
+}
+
+var simulateCmdConfig = struct {
+	databaseDriver           string
+	databaseConnectionString string
+	history                  time.Duration
+	runners                  []string
+	grow                     string
+	targetWait               time.Duration
+	targetPercentile         float64
+	maxRunners               int
+}{}
+
+var simulateQueueCmd = &cobra.Command{
+	Use:   "simulate-queue --runner labels:count [--runner labels:count...]",
+	Short: "Replays job history through the queue with a hypothetical set of runners to plan runner capacity",
+	Long: "Replays job history through the queue with a hypothetical set of runners and reports how long jobs\n" +
+		"would have waited for a runner. Each job is replayed from the time it was ready to run, for as long as it\n" +
+		"actually ran. With --grow, finds how many runners the specified group needs to keep waits for the jobs\n" +
+		"it can run within --target-wait, e.g. to answer 'how many linux,amd64 runners do we need for a p95 wait\n" +
+		"under 2 minutes?':\n\n" +
+		"  bb-tools simulate-queue --runner linux,amd64:4 --runner macos,arm64:2 --grow linux,amd64 --target-wait 2m",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		var runners []simulator.RunnerGroup
+		for _, raw := range simulateCmdConfig.runners {
+			group, err := parseRunnerGroup(raw)
+			if err != nil {
+				return err
+			}
+			runners = append(runners, group)
+		}
+		grow := -1
+		if simulateCmdConfig.grow != "" {
+			key := simulator.LabelsKey(parseLabels(simulateCmdConfig.grow))
+			for i, group := range runners {
+				if simulator.LabelsKey(group.Labels) == key {
+					grow = i
+					break
+				}
+			}
+			if grow < 0 {
+				return fmt.Errorf("error: --grow %q does not match the labels of any --runner group", simulateCmdConfig.grow)
+			}
+		}
+
+		databaseConfig := store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(simulateCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(simulateCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logRegistry, err := logger.NewLogRegistry("")
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(ctx, databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", databaseConfig.Driver, err)
+		}
+		defer cleanup()
+
+		history, err := simulator.LoadHistory(ctx, jobs.NewStore(db, logFactory), time.Now().Add(-simulateCmdConfig.history))
+		if err != nil {
+			return err
+		}
+		cli.Stdout.Printf("Replaying %d jobs from the last %s\n", len(history), simulateCmdConfig.history)
+
+		if grow < 0 {
+			printResult(simulator.Simulate(history, runners))
+			return nil
+		}
+		count, result, err := simulator.RunnersNeeded(
+			history,
+			runners,
+			grow,
+			simulateCmdConfig.targetPercentile,
+			simulateCmdConfig.targetWait,
+			simulateCmdConfig.maxRunners)
+		if err != nil {
+			return err
+		}
+		printResult(result)
+		cli.Stdout.Printf("\n%d runners with labels [%s] keep p%v wait times within %s (currently %d)\n",
+			count,
+			simulateCmdConfig.grow,
+			simulateCmdConfig.targetPercentile,
+			simulateCmdConfig.targetWait,
+			runners[grow].Count)
+		return nil
+	},
+}
+
+// parseRunnerGroup parses a runner group given as labels:count[:parallel-jobs].
+func parseRunnerGroup(raw string) (simulator.RunnerGroup, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return simulator.RunnerGroup{}, fmt.Errorf("error invalid runner group %q: expected labels:count[:parallel-jobs]", raw)
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil || count < 0 {
+		return simulator.RunnerGroup{}, fmt.Errorf("error invalid runner count in runner group %q", raw)
+	}
+	group := simulator.RunnerGroup{Labels: parseLabels(parts[0]), Count: count}
+	if len(parts) == 3 {
+		group.ParallelJobs, err = strconv.Atoi(parts[2])
+		if err != nil || group.ParallelJobs < 1 {
+			return simulator.RunnerGroup{}, fmt.Errorf("error invalid number of parallel jobs in runner group %q", raw)
+		}
+	}
+	return group, nil
+}
+
+func parseLabels(raw string) models.Labels {
+	var labels models.Labels
+	for _, label := range strings.Split(raw, ",") {
+		label = strings.TrimSpace(label)
+		if label != "" {
+			labels = append(labels, models.Label(label))
+		}
+	}
+	return labels
+}
+
+func printResult(result *simulator.Result) {
+	keys := make([]string, 0, len(result.ByRunsOn))
+	for key := range result.ByRunsOn {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cli.Stdout.Printf("\n%-30s %8s %10s %10s %10s %10s\n", "RUNS ON", "JOBS", "P50", "P95", "P99", "MAX")
+	printStats := func(name string, stats *simulator.Stats) {
+		cli.Stdout.Printf("%-30s %8d %10s %10s %10s %10s\n",
+			name,
+			stats.Jobs(),
+			stats.Percentile(50).Round(time.Second),
+			stats.Percentile(95).Round(time.Second),
+			stats.Percentile(99).Round(time.Second),
+			stats.Max().Round(time.Second))
+	}
+	for _, key := range keys {
+		name := key
+		if name == "" {
+			name = "(any runner)"
+		}
+		printStats(name, result.ByRunsOn[key])
+	}
+	printStats("(all jobs)", result.Overall)
+	if result.Unschedulable > 0 {
+		cli.Stdout.Printf("\n%d jobs could not run on any runner in the scenario\n", result.Unschedulable)
+	}
+}
@@ -0,0 +1,480 @@
+package support
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/version"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// eventPageSize is the number of events read from the store at a time.
+const eventPageSize = 500
+
+// bundleConfig records the (non-secret) configuration the bundle was gathered with.
+type bundleConfig struct {
+	DatabaseDriver string `json:"database_driver"`
+	BlobStoreType  string `json:"blob_store_type"`
+	MaxLogBytes    int    `json:"max_log_bytes"`
+}
+
+type bundleManifest struct {
+	BuildID       models.BuildID `json:"build_id"`
+	GeneratedAt   models.Time    `json:"generated_at"`
+	ServerVersion string         `json:"server_version"`
+	Config        bundleConfig   `json:"config"`
+	// Problems lists anything that could not be gathered; the rest of the bundle is still usable.
+	Problems []string `json:"problems"`
+}
+
+type bundleBuild struct {
+	ID                models.BuildID         `json:"id"`
+	Name              models.ResourceName    `json:"name"`
+	CreatedAt         models.Time            `json:"created_at"`
+	Ref               string                 `json:"ref"`
+	Status            models.WorkflowStatus  `json:"status"`
+	Timings           models.WorkflowTimings `json:"timings"`
+	Error             *models.Error          `json:"error"`
+	Opts              models.BuildOptions    `json:"opts"`
+	ClonedFromBuildID models.BuildID         `json:"cloned_from_build_id"`
+	Warnings          models.BuildWarnings   `json:"warnings"`
+	Priority          int                    `json:"priority"`
+	LogDescriptorID   models.LogDescriptorID `json:"log_descriptor_id"`
+	Repo              *bundleRepo            `json:"repo"`
+	Commit            *bundleCommit          `json:"commit"`
+}
+
+type bundleRepo struct {
+	ID              models.RepoID       `json:"id"`
+	Name            models.ResourceName `json:"name"`
+	DefaultBranch   string              `json:"default_branch"`
+	Private         bool                `json:"private"`
+	Enabled         bool                `json:"enabled"`
+	PublicBuilds    bool                `json:"public_builds"`
+	DeduplicateJobs bool                `json:"deduplicate_jobs"`
+}
+
+type bundleCommit struct {
+	ID         models.CommitID   `json:"id"`
+	SHA        string            `json:"sha"`
+	ConfigType models.ConfigType `json:"config_type"`
+}
+
+type bundleJob struct {
+	ID                      models.JobID              `json:"id"`
+	Workflow                models.ResourceName       `json:"workflow"`
+	Name                    models.ResourceName       `json:"name"`
+	Stage                   models.ResourceName       `json:"stage"`
+	Type                    models.JobType            `json:"type"`
+	RunsOn                  models.Labels             `json:"runs_on"`
+	Depends                 models.JobDependencies    `json:"depends"`
+	DockerImage             string                    `json:"docker_image"`
+	DockerImagePullStrategy models.DockerPullStrategy `json:"docker_pull"`
+	HasDockerAuth           bool                      `json:"has_docker_auth"`
+	StepExecution           models.StepExecution      `json:"step_execution"`
+	Services                []*bundleService          `json:"services"`
+	Environment             []*bundleEnvVar           `json:"environment"`
+	Status                  models.WorkflowStatus     `json:"status"`
+	Timings                 models.WorkflowTimings    `json:"timings"`
+	Error                   *models.Error             `json:"error"`
+	RunnerID                models.RunnerID           `json:"runner_id"`
+	IndirectToJobID         models.JobID              `json:"indirect_to_job_id"`
+	LogDescriptorID         models.LogDescriptorID    `json:"log_descriptor_id"`
+	Steps                   []*bundleStep             `json:"steps"`
+}
+
+type bundleService struct {
+	Name        string          `json:"name"`
+	DockerImage string          `json:"image"`
+	Environment []*bundleEnvVar `json:"environment"`
+}
+
+// bundleEnvVar records only the name of an environment variable and where its value comes from;
+// values are never included in a bundle.
+type bundleEnvVar struct {
+	Name       string `json:"name"`
+	FromSecret bool   `json:"from_secret"`
+}
+
+type bundleStep struct {
+	ID              models.StepID           `json:"id"`
+	Name            models.ResourceName     `json:"name"`
+	Depends         models.StepDependencies `json:"depends"`
+	TimeoutSeconds  int64                   `json:"timeout_seconds"`
+	Status          models.WorkflowStatus   `json:"status"`
+	Timings         models.WorkflowTimings  `json:"timings"`
+	Error           *models.Error           `json:"error"`
+	RunnerID        models.RunnerID         `json:"runner_id"`
+	LogDescriptorID models.LogDescriptorID  `json:"log_descriptor_id"`
+}
+
+type bundleRunner struct {
+	ID                models.RunnerID      `json:"id"`
+	Name              models.ResourceName  `json:"name"`
+	SoftwareVersion   string               `json:"software_version"`
+	OperatingSystem   string               `json:"operating_system"`
+	Architecture      string               `json:"architecture"`
+	SupportedJobTypes models.JobTypes      `json:"supported_job_types"`
+	Labels            models.Labels        `json:"labels"`
+	Enabled           bool                 `json:"enabled"`
+	Online            bool                 `json:"online"`
+	LastSeenAt        *models.Time         `json:"last_seen_at"`
+	Health            *models.RunnerHealth `json:"health"`
+}
+
+// bundleEvent omits the event payload, which can contain arbitrary data from the build.
+type bundleEvent struct {
+	SequenceNumber models.EventNumber  `json:"sequence_number"`
+	CreatedAt      models.Time         `json:"created_at"`
+	Type           models.EventType    `json:"type"`
+	ResourceID     models.ResourceID   `json:"resource_id"`
+	Workflow       models.ResourceName `json:"workflow"`
+	JobName        models.ResourceName `json:"job_name"`
+	ResourceName   models.ResourceName `json:"resource_name"`
+}
+
+// bundleGatherer reads everything about a build that is useful for diagnosing problems, and writes
+// it to a gzipped tar archive. Only an explicit list of fields is copied from each model, so that
+// newly added fields are not included in bundles until somebody decides they are safe to share.
+type bundleGatherer struct {
+	buildStore  store.BuildStore
+	repoStore   store.RepoStore
+	commitStore store.CommitStore
+	jobStore    store.JobStore
+	stepStore   store.StepStore
+	runnerStore store.RunnerStore
+	eventStore  store.EventStore
+	logService  services.LogService
+	maxLogBytes int
+	config      bundleConfig
+}
+
+// WriteBundle gathers the support bundle for the specified build and writes it to w.
+// Problems reading secondary information (repo, commit, runners, logs) are recorded in the bundle's
+// manifest rather than failing the whole bundle.
+func (g *bundleGatherer) WriteBundle(ctx context.Context, buildID models.BuildID, w io.Writer) error {
+	build, err := g.buildStore.Read(ctx, nil, buildID)
+	if err != nil {
+		return fmt.Errorf("error reading build '%s': %w", buildID, err)
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	manifest := &bundleManifest{
+		BuildID:       buildID,
+		GeneratedAt:   models.NewTime(time.Now()),
+		ServerVersion: version.VersionToString(),
+		Config:        g.config,
+		Problems:      []string{},
+	}
+	problem := func(format string, args ...interface{}) {
+		manifest.Problems = append(manifest.Problems, fmt.Sprintf(format, args...))
+	}
+
+	bBuild := &bundleBuild{
+		ID:                build.ID,
+		Name:              build.Name,
+		CreatedAt:         build.CreatedAt,
+		Ref:               build.Ref,
+		Status:            build.Status,
+		Timings:           build.Timings,
+		Error:             build.Error,
+		Opts:              build.Opts,
+		ClonedFromBuildID: build.ClonedFromBuildID,
+		Warnings:          build.Warnings,
+		Priority:          build.Priority,
+		LogDescriptorID:   build.LogDescriptorID,
+	}
+	repo, err := g.repoStore.Read(ctx, nil, build.RepoID)
+	if err != nil {
+		problem("error reading repo '%s': %s", build.RepoID, err)
+	} else {
+		bBuild.Repo = &bundleRepo{
+			ID:              repo.ID,
+			Name:            repo.Name,
+			DefaultBranch:   repo.DefaultBranch,
+			Private:         repo.Private,
+			Enabled:         repo.Enabled,
+			PublicBuilds:    repo.PublicBuilds,
+			DeduplicateJobs: repo.DeduplicateJobs,
+		}
+	}
+	commit, err := g.commitStore.Read(ctx, nil, build.CommitID)
+	if err != nil {
+		problem("error reading commit '%s': %s", build.CommitID, err)
+	} else {
+		bBuild.Commit = &bundleCommit{
+			ID:         commit.ID,
+			SHA:        commit.SHA,
+			ConfigType: commit.ConfigType,
+		}
+	}
+	err = writeJSON(tarWriter, "build.json", bBuild)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := g.jobStore.ListByBuildID(ctx, nil, buildID)
+	if err != nil {
+		return fmt.Errorf("error listing jobs for build '%s': %w", buildID, err)
+	}
+	bJobs := make([]*bundleJob, 0, len(jobs))
+	runnerIDs := make(map[models.RunnerID]bool)
+	for _, job := range jobs {
+		bJob, err := g.makeBundleJob(ctx, job)
+		if err != nil {
+			return err
+		}
+		bJobs = append(bJobs, bJob)
+		if job.RunnerID.Valid() {
+			runnerIDs[job.RunnerID] = true
+		}
+	}
+	err = writeJSON(tarWriter, "jobs.json", bJobs)
+	if err != nil {
+		return err
+	}
+
+	bRunners := make([]*bundleRunner, 0, len(runnerIDs))
+	for runnerID := range runnerIDs {
+		runner, err := g.runnerStore.Read(ctx, nil, runnerID)
+		if err != nil {
+			problem("error reading runner '%s': %s", runnerID, err)
+			continue
+		}
+		bRunners = append(bRunners, &bundleRunner{
+			ID:                runner.ID,
+			Name:              runner.Name,
+			SoftwareVersion:   runner.SoftwareVersion,
+			OperatingSystem:   runner.OperatingSystem,
+			Architecture:      runner.Architecture,
+			SupportedJobTypes: runner.SupportedJobTypes,
+			Labels:            runner.Labels,
+			Enabled:           runner.Enabled,
+			Online:            runner.Online,
+			LastSeenAt:        runner.LastSeenAt,
+			Health:            runner.Health,
+		})
+	}
+	err = writeJSON(tarWriter, "runners.json", bRunners)
+	if err != nil {
+		return err
+	}
+
+	bEvents, err := g.readEvents(ctx, buildID)
+	if err != nil {
+		problem("error reading events: %s", err)
+	}
+	err = writeJSON(tarWriter, "events.json", bEvents)
+	if err != nil {
+		return err
+	}
+
+	if g.maxLogBytes > 0 {
+		err = g.writeLog(ctx, tarWriter, "logs/build.log", build.LogDescriptorID)
+		if err != nil {
+			problem("error reading build log: %s", err)
+		}
+		for _, job := range bJobs {
+			jobDir := path.Join("logs", job.Workflow.String()+"."+job.Name.String())
+			err = g.writeLog(ctx, tarWriter, path.Join(jobDir, "job.log"), job.LogDescriptorID)
+			if err != nil {
+				problem("error reading log for job '%s': %s", job.ID, err)
+			}
+			for _, step := range job.Steps {
+				err = g.writeLog(ctx, tarWriter, path.Join(jobDir, step.Name.String()+".log"), step.LogDescriptorID)
+				if err != nil {
+					problem("error reading log for step '%s': %s", step.ID, err)
+				}
+			}
+		}
+	}
+
+	// The manifest is written last so it can record every problem encountered above
+	err = writeJSON(tarWriter, "manifest.json", manifest)
+	if err != nil {
+		return err
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		return fmt.Errorf("error closing archive: %w", err)
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return fmt.Errorf("error closing archive: %w", err)
+	}
+	return nil
+}
+
+func (g *bundleGatherer) makeBundleJob(ctx context.Context, job *models.Job) (*bundleJob, error) {
+	bJob := &bundleJob{
+		ID:                      job.ID,
+		Workflow:                job.Workflow,
+		Name:                    job.Name,
+		Stage:                   job.Stage,
+		Type:                    job.Type,
+		RunsOn:                  job.RunsOn,
+		Depends:                 job.Depends,
+		DockerImage:             job.DockerImage,
+		DockerImagePullStrategy: job.DockerImagePullStrategy,
+		HasDockerAuth:           job.DockerAuth != nil,
+		StepExecution:           job.StepExecution,
+		Services:                make([]*bundleService, 0, len(job.Services)),
+		Environment:             makeBundleEnvVars(job.Environment),
+		Status:                  job.Status,
+		Timings:                 job.Timings,
+		Error:                   job.Error,
+		RunnerID:                job.RunnerID,
+		IndirectToJobID:         job.IndirectToJobID,
+		LogDescriptorID:         job.LogDescriptorID,
+	}
+	for _, service := range job.Services {
+		bJob.Services = append(bJob.Services, &bundleService{
+			Name:        service.Name,
+			DockerImage: service.DockerImage,
+			Environment: makeBundleEnvVars(service.Environment),
+		})
+	}
+
+	steps, err := g.stepStore.ListByJobID(ctx, nil, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing steps for job '%s': %w", job.ID, err)
+	}
+	bJob.Steps = make([]*bundleStep, 0, len(steps))
+	for _, step := range steps {
+		bJob.Steps = append(bJob.Steps, &bundleStep{
+			ID:              step.ID,
+			Name:            step.Name,
+			Depends:         step.Depends,
+			TimeoutSeconds:  step.TimeoutSeconds,
+			Status:          step.Status,
+			Timings:         step.Timings,
+			Error:           step.Error,
+			RunnerID:        step.RunnerID,
+			LogDescriptorID: step.LogDescriptorID,
+		})
+	}
+	return bJob, nil
+}
+
+func makeBundleEnvVars(envVars []*models.EnvVar) []*bundleEnvVar {
+	result := make([]*bundleEnvVar, 0, len(envVars))
+	for _, env := range envVars {
+		result = append(result, &bundleEnvVar{
+			Name:       env.Name,
+			FromSecret: env.ValueFromSecret != "",
+		})
+	}
+	return result
+}
+
+func (g *bundleGatherer) readEvents(ctx context.Context, buildID models.BuildID) ([]*bundleEvent, error) {
+	bEvents := []*bundleEvent{}
+	var last models.EventNumber
+	for {
+		events, err := g.eventStore.FindEvents(ctx, nil, buildID, last, eventPageSize)
+		if err != nil {
+			return bEvents, err
+		}
+		for _, event := range events {
+			bEvents = append(bEvents, &bundleEvent{
+				SequenceNumber: event.SequenceNumber,
+				CreatedAt:      event.CreatedAt,
+				Type:           event.Type,
+				ResourceID:     event.ResourceID,
+				Workflow:       event.Workflow,
+				JobName:        event.JobName,
+				ResourceName:   event.ResourceName,
+			})
+			last = event.SequenceNumber
+		}
+		if len(events) < eventPageSize {
+			return bEvents, nil
+		}
+	}
+}
+
+// writeLog writes at most maxLogBytes from the end of the specified log to the archive as plain text.
+// Logs that were never created, or whose data has expired, are skipped.
+func (g *bundleGatherer) writeLog(ctx context.Context, tarWriter *tar.Writer, name string, logID models.LogDescriptorID) error {
+	if !logID.Valid() {
+		return nil
+	}
+	plaintext := true
+	reader, err := g.logService.ReadData(ctx, logID, &models.LogSearch{Plaintext: &plaintext})
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	defer reader.Close()
+
+	tail, truncated, err := readTail(reader, g.maxLogBytes)
+	if err != nil {
+		return err
+	}
+	if truncated {
+		tail = append([]byte(fmt.Sprintf("[log truncated to the last %d bytes]\n", g.maxLogBytes)), tail...)
+	}
+	return writeFile(tarWriter, name, tail)
+}
+
+// readTail reads r to the end and returns at most the last max bytes, and whether any data was discarded.
+func readTail(r io.Reader, max int) ([]byte, bool, error) {
+	var (
+		tail      []byte
+		truncated bool
+		buf       = make([]byte, 32*1024)
+	)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			tail = append(tail, buf[:n]...)
+			if len(tail) > max {
+				tail = append(tail[:0:0], tail[len(tail)-max:]...)
+				truncated = true
+			}
+		}
+		if err == io.EOF {
+			return tail, truncated, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+func writeJSON(tarWriter *tar.Writer, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling %s: %w", name, err)
+	}
+	return writeFile(tarWriter, name, data)
+}
+
+func writeFile(tarWriter *tar.Writer, name string, data []byte) error {
+	err := tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error writing %s to archive: %w", name, err)
+	}
+	_, err = tarWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("error writing %s to archive: %w", name, err)
+	}
+	return nil
+}
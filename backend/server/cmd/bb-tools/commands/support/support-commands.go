@@ -0,0 +1,220 @@
+package support
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/benbjohnson/clock"
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/blob"
+	"github.com/buildbeaver/buildbeaver/server/services/log"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/builds"
+	"github.com/buildbeaver/buildbeaver/server/store/commits"
+	"github.com/buildbeaver/buildbeaver/server/store/events"
+	"github.com/buildbeaver/buildbeaver/server/store/jobs"
+	"github.com/buildbeaver/buildbeaver/server/store/logs"
+	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+	"github.com/buildbeaver/buildbeaver/server/store/repos"
+	"github.com/buildbeaver/buildbeaver/server/store/runners"
+	"github.com/buildbeaver/buildbeaver/server/store/steps"
+)
+
+const (
+	defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+	defaultLocalBlobStoreDir      = "/var/lib/buildbeaver/blob"
+	defaultMaxLogBytes            = 64 * 1024
+)
+
+func init() {
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use (i.e sqlite3|postgres)")
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use")
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.blobStoreType,
+		"blob-store-type",
+		blob.LocalBlobStoreType.String(),
+		fmt.Sprintf("The type of blob store logs are stored in. Options: %s", strings.Join(blob.BlobStoreTypes(), ", ")))
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.localBlobStoreDir,
+		"blob-store-local-directory",
+		defaultLocalBlobStoreDir,
+		"The path on the local host blobs are stored in, if using the local blob store")
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.s3BlobStoreConfig.BucketName,
+		"blob-store-aws-s3-bucket-name",
+		"",
+		"The name of the S3 bucket blobs are stored in, if using the S3 blob store")
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.s3BlobStoreConfig.Region,
+		"blob-store-aws-s3-region",
+		"",
+		"The region of the S3 bucket blobs are stored in, if using the S3 blob store")
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.s3BlobStoreConfig.AccessKeyID,
+		"blob-store-aws-s3-access-key-id",
+		"",
+		"The AWS Access Key ID to use to authenticate to the S3 bucket, if using the S3 blob store")
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.s3BlobStoreConfig.SecretAccessKey,
+		"blob-store-aws-s3-secret-key",
+		"",
+		"The AWS Secret Key to use to authenticate to the S3 bucket, if using the S3 blob store")
+	supportBundleCmd.Flags().StringVar(
+		&supportCmdConfig.buildID,
+		"build",
+		"",
+		"The ID of the build to gather a support bundle for")
+	supportBundleCmd.Flags().StringVarP(
+		&supportCmdConfig.output,
+		"output",
+		"o",
+		"",
+		"The file to write the bundle to (defaults to support-bundle-<build-id>.tar.gz in the current directory)")
+	supportBundleCmd.Flags().IntVar(
+		&supportCmdConfig.maxLogBytes,
+		"max-log-bytes",
+		defaultMaxLogBytes,
+		"The maximum number of bytes to include from the end of each log. Set to zero to omit logs.")
+	_ = supportBundleCmd.MarkFlagRequired("build")
+
+	commands.RootCmd.AddCommand(supportBundleCmd)
+}
+
+var supportCmdConfig = struct {
+	databaseDriver           string
+	databaseConnectionString string
+	blobStoreType            string
+	localBlobStoreDir        string
+	s3BlobStoreConfig        blob.S3BlobStoreConfig
+	buildID                  string
+	output                   string
+	maxLogBytes              int
+}{}
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle --build build-id",
+	Short: "Gathers a sanitized diagnostics bundle for a build, suitable for attaching to a bug report",
+	Long: "Gathers a sanitized diagnostics bundle for a build, suitable for attaching to a bug report.\n" +
+		"The bundle contains the build graph, statuses and timings, the runners the jobs ran on, the build's\n" +
+		"events and the tail of each log. Secret values, environment variable values, registry credentials,\n" +
+		"step commands and commit author details are never included.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		id, err := models.ParseResourceID(supportCmdConfig.buildID)
+		if err != nil {
+			return fmt.Errorf("error parsing build ID: %w", err)
+		}
+		buildID := models.BuildIDFromResourceID(id)
+
+		if supportCmdConfig.maxLogBytes < 0 {
+			return fmt.Errorf("error: --max-log-bytes must not be negative")
+		}
+		output := supportCmdConfig.output
+		if output == "" {
+			output = fmt.Sprintf("support-bundle-%s.tar.gz", strings.ReplaceAll(buildID.String(), ":", "-"))
+		}
+
+		databaseConfig := store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(supportCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(supportCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logRegistry, err := logger.NewLogRegistry("")
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+		blobStore, err := makeBlobStore(logFactory)
+		if err != nil {
+			return err
+		}
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(ctx, databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", databaseConfig.Driver, err)
+		}
+		defer cleanup()
+
+		logConfig := log.LogServiceConfig{WriterConfig: log.DefaultWriterConfig}
+		gatherer := &bundleGatherer{
+			buildStore:  builds.NewStore(db, logFactory),
+			repoStore:   repos.NewStore(db, logFactory),
+			commitStore: commits.NewStore(db, logFactory),
+			jobStore:    jobs.NewStore(db, logFactory),
+			stepStore:   steps.NewStore(db, logFactory),
+			runnerStore: runners.NewStore(db, logFactory),
+			eventStore:  events.NewStore(db, logFactory),
+			logService: log.NewLogService(
+				logFactory,
+				clock.New(),
+				db,
+				logConfig,
+				blobStore,
+				logs.NewStore(db, logFactory),
+				ownerships.NewStore(db, logFactory)),
+			maxLogBytes: supportCmdConfig.maxLogBytes,
+			config: bundleConfig{
+				DatabaseDriver: string(databaseConfig.Driver),
+				BlobStoreType:  supportCmdConfig.blobStoreType,
+				MaxLogBytes:    supportCmdConfig.maxLogBytes,
+			},
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
+		}
+		err = gatherer.WriteBundle(ctx, buildID, file)
+		closeErr := file.Close()
+		if err != nil {
+			_ = os.Remove(output)
+			return err
+		}
+		if closeErr != nil {
+			return fmt.Errorf("error closing output file: %w", closeErr)
+		}
+
+		cli.Stdout.Printf("Wrote support bundle for build '%s' to %s\n", buildID, output)
+		return nil
+	},
+}
+
+func makeBlobStore(logFactory logger.LogFactory) (services.BlobStore, error) {
+	switch strings.ToLower(supportCmdConfig.blobStoreType) {
+	case strings.ToLower(blob.AWSS3BlobStoreType.String()):
+		s3Store, err := blob.NewS3BlobStore(supportCmdConfig.s3BlobStoreConfig, logFactory)
+		if err != nil {
+			return nil, fmt.Errorf("error creating S3 blob store: %w", err)
+		}
+		return s3Store, nil
+	case strings.ToLower(blob.LocalBlobStoreType.String()):
+		return blob.NewLocalBlobStore(blob.LocalBlobStoreDirectory(supportCmdConfig.localBlobStoreDir)), nil
+	default:
+		return nil, fmt.Errorf("error unsupported blob store type: %v", supportCmdConfig.blobStoreType)
+	}
+}
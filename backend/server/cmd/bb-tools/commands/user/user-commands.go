@@ -0,0 +1,240 @@
+package user_admin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/authorization"
+	"github.com/buildbeaver/buildbeaver/server/services/group"
+	"github.com/buildbeaver/buildbeaver/server/services/legal_entity"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/authorizations"
+	"github.com/buildbeaver/buildbeaver/server/store/grants"
+	"github.com/buildbeaver/buildbeaver/server/store/group_memberships"
+	"github.com/buildbeaver/buildbeaver/server/store/groups"
+	"github.com/buildbeaver/buildbeaver/server/store/identities"
+	"github.com/buildbeaver/buildbeaver/server/store/legal_entities"
+	"github.com/buildbeaver/buildbeaver/server/store/legal_entity_memberships"
+	"github.com/buildbeaver/buildbeaver/server/store/ownerships"
+	"github.com/buildbeaver/buildbeaver/server/store/resource_links"
+)
+
+const defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+
+func init() {
+	userRootCmd.PersistentFlags().StringVar(
+		&userCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use for fetching and writing data (i.e sqlite3|postgres)")
+	userRootCmd.PersistentFlags().StringVar(
+		&userCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use for fetching and writing data")
+	userListCmd.Flags().BoolVar(
+		&userCmdConfig.disabledOnly,
+		"disabled",
+		false,
+		"Only list users that have been disabled")
+
+	commands.RootCmd.AddCommand(userRootCmd)
+	userRootCmd.AddCommand(userListCmd)
+	userRootCmd.AddCommand(userDisableCmd)
+	userRootCmd.AddCommand(userEnableCmd)
+}
+
+var userCmdConfig = struct {
+	databaseConfig           store.DatabaseConfig
+	databaseDriver           string
+	databaseConnectionString string
+	disabledOnly             bool
+	db                       *store.DB
+	dbCleanup                func()
+	legalEntityStore         store.LegalEntityStore
+	legalEntityService       services.LegalEntityService
+}{}
+
+var userRootCmd = &cobra.Command{
+	Use:     "user list|disable|enable",
+	Aliases: []string{"users"},
+	Short:   "Lists users, and disables or re-enables their accounts.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		userCmdConfig.databaseConfig = store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(userCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(userCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logRegistry, err := logger.NewLogRegistry("")
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(context.Background(), userCmdConfig.databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", userCmdConfig.databaseConfig.Driver, err)
+		}
+		userCmdConfig.db = db
+		userCmdConfig.dbCleanup = cleanup
+
+		// we need the legal entity service in order to ensure business logic is run when disabling a user
+		userCmdConfig.legalEntityStore = legal_entities.NewStore(db, logFactory)
+		authorizationService := authorization.NewAuthorizationService(
+			db,
+			grants.NewStore(db, logFactory),
+			ownerships.NewStore(db, logFactory),
+			authorizations.NewStore(db),
+			logFactory,
+		)
+		userCmdConfig.legalEntityService = legal_entity.NewLegalEntityService(
+			db,
+			userCmdConfig.legalEntityStore,
+			legal_entity_memberships.NewStore(db, logFactory),
+			ownerships.NewStore(db, logFactory),
+			resource_links.NewStore(db, logFactory),
+			identities.NewStore(db, logFactory),
+			authorizationService,
+			group.NewGroupService(
+				db,
+				ownerships.NewStore(db, logFactory),
+				groups.NewStore(db, logFactory),
+				group_memberships.NewStore(db, logFactory),
+				grants.NewStore(db, logFactory),
+				authorizationService,
+				logFactory,
+			),
+			logFactory,
+		)
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if userCmdConfig.dbCleanup != nil {
+			userCmdConfig.dbCleanup()
+			userCmdConfig.dbCleanup = nil
+		}
+	},
+}
+
+var userListCmd = &cobra.Command{
+	Use:           "list [--disabled]",
+	Short:         "Lists the users known to the server, and whether each is disabled.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		count := 0
+		pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+		for moreResults := true; moreResults; {
+			legalEntities, cursor, err := userCmdConfig.legalEntityService.ListAllLegalEntities(ctx, nil, pagination)
+			if err != nil {
+				return fmt.Errorf("error listing users: %w", err)
+			}
+			for _, legalEntity := range legalEntities {
+				if legalEntity.Type != models.LegalEntityTypePerson || legalEntity.DeletedAt != nil {
+					continue
+				}
+				if userCmdConfig.disabledOnly && !legalEntity.IsDisabled() {
+					continue
+				}
+				count++
+				status := "enabled"
+				if legalEntity.IsDisabled() {
+					status = fmt.Sprintf("disabled at %s", legalEntity.DisabledAt.Format(time.RFC3339))
+				}
+				cli.Stdout.Printf("    %s (%s) <%s>: %s, ID %s\n",
+					legalEntity.Name, legalEntity.LegalName, legalEntity.EmailAddress, status, legalEntity.ID)
+			}
+			if cursor != nil && cursor.Next != nil {
+				pagination.Cursor = cursor.Next // move on to next page of results
+			} else {
+				moreResults = false
+			}
+		}
+		if count == 0 {
+			cli.Stdout.Printf("No users found.\n")
+		}
+		return nil
+	},
+}
+
+var userDisableCmd = &cobra.Command{
+	Use:   "disable user-name",
+	Short: "Disables a user's account so they can no longer log in or use the API.",
+	Long: "Disables a user's account so they can no longer log in or use the API with any credential, including\n" +
+		"sessions and personal access tokens issued before the account was disabled. The user's data, group\n" +
+		"memberships and grants are kept so that the account can be re-enabled with 'user enable'.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setUserDisabled(args[0], true)
+	},
+}
+
+var userEnableCmd = &cobra.Command{
+	Use:           "enable user-name",
+	Short:         "Re-enables a user's account that was disabled with 'user disable'.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setUserDisabled(args[0], false)
+	},
+}
+
+func setUserDisabled(userName string, disabled bool) error {
+	ctx := context.Background()
+	return userCmdConfig.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		user, err := readUser(ctx, tx, userName)
+		if err != nil {
+			return err
+		}
+		if user.IsDisabled() == disabled {
+			if disabled {
+				cli.Stdout.Printf("Not disabled: user '%s' is already disabled.\n", user.Name)
+			} else {
+				cli.Stdout.Printf("Not enabled: user '%s' is not disabled.\n", user.Name)
+			}
+			return nil
+		}
+		_, err = userCmdConfig.legalEntityService.SetDisabled(ctx, tx, user.ID, disabled)
+		if err != nil {
+			return fmt.Errorf("error updating user '%s': %w", user.Name, err)
+		}
+		if disabled {
+			cli.Stdout.Printf("Disabled.\n")
+		} else {
+			cli.Stdout.Printf("Enabled.\n")
+		}
+		return nil
+	})
+}
+
+// readUser reads the legal entity for the user with the specified name.
+func readUser(ctx context.Context, txOrNil *store.Tx, userName string) (*models.LegalEntity, error) {
+	if len(userName) == 0 {
+		return nil, fmt.Errorf("error: user's Legal Entity name must be specified")
+	}
+	user, err := userCmdConfig.legalEntityStore.ReadByName(ctx, txOrNil, models.ResourceName(userName))
+	if err != nil {
+		return nil, fmt.Errorf("error: Unable to find user with name '%s': %w", userName, err)
+	}
+	if user.Type != models.LegalEntityTypePerson {
+		return nil, fmt.Errorf("error: The specified user must be of type '%s' (found '%s')", models.LegalEntityTypePerson, user.Type)
+	}
+	return user, nil
+}
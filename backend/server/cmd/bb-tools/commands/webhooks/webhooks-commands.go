@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/cli"
+	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/scm"
+	"github.com/buildbeaver/buildbeaver/server/services/webhook_delivery"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+	"github.com/buildbeaver/buildbeaver/server/store/webhook_deliveries"
+	"github.com/buildbeaver/buildbeaver/server/store/work_item_states"
+	"github.com/buildbeaver/buildbeaver/server/store/work_items"
+)
+
+const defaultSQLiteConnectionString = "file:/var/lib/buildbeaver/db/sqlite.db?cache=shared"
+
+// maxListedErrorLength is the maximum length of the error shown for each delivery by the list command.
+const maxListedErrorLength = 60
+
+func init() {
+	webhooksRootCmd.PersistentFlags().StringVar(
+		&webhooksCmdConfig.databaseDriver,
+		"driver",
+		string(store.Sqlite),
+		"The Database Driver to use for fetching and writing data (i.e sqlite3|postgres)")
+	webhooksRootCmd.PersistentFlags().StringVar(
+		&webhooksCmdConfig.databaseConnectionString,
+		"connection",
+		defaultSQLiteConnectionString,
+		"The connection string for the database to use for fetching and writing data")
+	webhooksListCmd.Flags().StringVar(
+		&webhooksCmdConfig.status,
+		"status",
+		"",
+		"Only list deliveries with this status (i.e pending|succeeded|failed)")
+	webhooksListCmd.Flags().StringVar(
+		&webhooksCmdConfig.scmName,
+		"scm",
+		"",
+		"Only list deliveries received from the SCM with this name (e.g. github)")
+	webhooksListCmd.Flags().IntVar(
+		&webhooksCmdConfig.limit,
+		"limit",
+		models.DefaultPaginationLimit,
+		"The maximum number of deliveries to list, most recent first")
+
+	commands.RootCmd.AddCommand(webhooksRootCmd)
+	webhooksRootCmd.AddCommand(webhooksListCmd)
+	webhooksRootCmd.AddCommand(webhooksShowCmd)
+	webhooksRootCmd.AddCommand(webhooksReplayCmd)
+}
+
+var webhooksCmdConfig = struct {
+	databaseConfig           store.DatabaseConfig
+	databaseDriver           string
+	databaseConnectionString string
+	status                   string
+	scmName                  string
+	limit                    int
+	db                       *store.DB
+	dbCleanup                func()
+	webhookDeliveryService   services.WebhookDeliveryService
+}{}
+
+var webhooksRootCmd = &cobra.Command{
+	Use:   "webhooks list|show|replay",
+	Short: "Inspect and replay webhooks received from SCMs.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		webhooksCmdConfig.databaseConfig = store.DatabaseConfig{
+			ConnectionString:   store.DatabaseConnectionString(webhooksCmdConfig.databaseConnectionString),
+			Driver:             store.DBDriver(webhooksCmdConfig.databaseDriver),
+			MaxIdleConnections: store.DefaultDatabaseMaxIdleConnections,
+			MaxOpenConnections: store.DefaultDatabaseMaxOpenConnections,
+		}
+
+		// stores need a log factory; use a very plain log format
+		logRegistry, err := logger.NewLogRegistry("")
+		if err != nil {
+			return err
+		}
+		logFactory := logger.MakeLogrusLogFactoryStdOutPlain(logRegistry)
+
+		// open the database but do not perform migrations
+		db, cleanup, err := store.NewDatabase(context.Background(), webhooksCmdConfig.databaseConfig, nil)
+		if err != nil {
+			return fmt.Errorf("error opening %s database: %w", webhooksCmdConfig.databaseConfig.Driver, err)
+		}
+		webhooksCmdConfig.db = db
+		webhooksCmdConfig.dbCleanup = cleanup
+
+		// Replays are queued as work items for the server to process; the notifier wakes up the server's
+		// work queue if the database supports it. No work items are processed here, and no SCMs are needed.
+		notifier, notifierCleanup, err := store.NewNotifier(store.NotifierConfig{}, db, logFactory)
+		if err != nil {
+			return fmt.Errorf("error creating database notifier: %w", err)
+		}
+		webhooksCmdConfig.dbCleanup = func() {
+			notifierCleanup()
+			cleanup()
+		}
+		workQueueService := work_queue.NewWorkQueueService(
+			db,
+			work_items.NewStore(db, logFactory),
+			work_item_states.NewStore(db, logFactory),
+			notifier,
+			logFactory,
+		)
+		webhooksCmdConfig.webhookDeliveryService = webhook_delivery.NewWebhookDeliveryService(
+			db,
+			webhook_deliveries.NewStore(db, logFactory),
+			scm.NewSCMRegistry(),
+			workQueueService,
+			logFactory,
+		)
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if webhooksCmdConfig.dbCleanup != nil {
+			webhooksCmdConfig.dbCleanup()
+			webhooksCmdConfig.dbCleanup = nil
+		}
+	},
+}
+
+var webhooksListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "Lists the webhooks received from SCMs, most recent first.",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		search := models.NewWebhookDeliverySearch()
+		search.Limit = webhooksCmdConfig.limit
+		if webhooksCmdConfig.status != "" {
+			status := models.WebhookDeliveryStatus(webhooksCmdConfig.status)
+			search.Status = &status
+		}
+		if webhooksCmdConfig.scmName != "" {
+			scmName := models.SystemName(webhooksCmdConfig.scmName)
+			search.SCMName = &scmName
+		}
+		deliveries, _, err := webhooksCmdConfig.webhookDeliveryService.Search(ctx, nil, search)
+		if err != nil {
+			return fmt.Errorf("error listing webhook deliveries: %w", err)
+		}
+		if len(deliveries) == 0 {
+			cli.Stdout.Printf("No webhook deliveries found.\n")
+			return nil
+		}
+		for _, delivery := range deliveries {
+			deliveryError := delivery.Error
+			if len(deliveryError) > maxListedErrorLength {
+				deliveryError = deliveryError[:maxListedErrorLength] + "..."
+			}
+			cli.Stdout.Printf("%s  %s  %s/%s  signature %s  %s after %d attempt(s)  %s\n",
+				delivery.ID, delivery.CreatedAt.Format("2006-01-02 15:04:05"), delivery.SCMName, delivery.EventType,
+				delivery.SignatureStatus, delivery.Status, delivery.Attempts, deliveryError)
+		}
+		return nil
+	},
+}
+
+var webhooksShowCmd = &cobra.Command{
+	Use:           "show delivery-id",
+	Short:         "Shows the headers, payload and outcome of processing a webhook received from an SCM.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		id, err := parseDeliveryID(args[0])
+		if err != nil {
+			return err
+		}
+		delivery, err := webhooksCmdConfig.webhookDeliveryService.Read(ctx, nil, id)
+		if err != nil {
+			return fmt.Errorf("error reading webhook delivery '%s': %w", id, err)
+		}
+		cli.Stdout.Printf("ID:           %s\n", delivery.ID)
+		cli.Stdout.Printf("Received:     %s\n", delivery.CreatedAt.Format("2006-01-02 15:04:05"))
+		cli.Stdout.Printf("SCM:          %s\n", delivery.SCMName)
+		cli.Stdout.Printf("Event:        %s\n", delivery.EventType)
+		cli.Stdout.Printf("Signature:    %s\n", delivery.SignatureStatus)
+		cli.Stdout.Printf("Status:       %s\n", delivery.Status)
+		cli.Stdout.Printf("Attempts:     %d\n", delivery.Attempts)
+		if delivery.ProcessedAt != nil {
+			cli.Stdout.Printf("Processed:    %s\n", delivery.ProcessedAt.Format("2006-01-02 15:04:05"))
+		}
+		if delivery.Error != "" {
+			cli.Stdout.Printf("Error:        %s\n", delivery.Error)
+		}
+		cli.Stdout.Printf("Headers:\n")
+		var headerNames []string
+		for name := range delivery.Headers {
+			headerNames = append(headerNames, name)
+		}
+		sort.Strings(headerNames)
+		for _, name := range headerNames {
+			cli.Stdout.Printf("    %s: %s\n", name, strings.Join(delivery.Headers[name], ", "))
+		}
+		cli.Stdout.Printf("Payload:\n")
+		payload := &bytes.Buffer{}
+		if json.Indent(payload, []byte(delivery.Payload), "    ", "  ") == nil {
+			cli.Stdout.Printf("    %s\n", payload.String())
+		} else {
+			cli.Stdout.Printf("    %s\n", delivery.Payload)
+		}
+		return nil
+	},
+}
+
+var webhooksReplayCmd = &cobra.Command{
+	Use:           "replay delivery-id...",
+	Short:         "Queues webhooks received from SCMs to be processed again by the server, for example after fixing the problem that caused them to fail.",
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		for _, arg := range args {
+			id, err := parseDeliveryID(arg)
+			if err != nil {
+				return err
+			}
+			_, err = webhooksCmdConfig.webhookDeliveryService.Replay(ctx, nil, id)
+			if err != nil {
+				return fmt.Errorf("error replaying webhook delivery '%s': %w", id, err)
+			}
+			cli.Stdout.Printf("Queued replay of '%s'.\n", id)
+		}
+		return nil
+	},
+}
+
+func parseDeliveryID(str string) (models.WebhookDeliveryID, error) {
+	id, err := models.ParseResourceID(str)
+	if err != nil || id.Kind() != models.WebhookDeliveryResourceKind {
+		return models.WebhookDeliveryID{}, fmt.Errorf("error: '%s' is not a webhook delivery id", str)
+	}
+	return models.WebhookDeliveryIDFromResourceID(id), nil
+}
@@ -3,8 +3,19 @@ package main
 import (
 	"github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands"
 	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/admin"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/backup"
 	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/dump"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/grant"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/logs"
 	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/migrate"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/prune"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/quota"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/runner"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/secrets"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/simulate"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/support"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/user"
+	_ "github.com/buildbeaver/buildbeaver/server/cmd/bb-tools/commands/webhooks"
 )
 
 func main() {
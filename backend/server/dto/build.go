@@ -2,6 +2,7 @@ package dto
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -18,6 +19,20 @@ type UpdateBuildStatus struct {
 	ETag   models.ETag
 }
 
+type UpdateBuildPriority struct {
+	Priority int
+	ETag     models.ETag
+}
+
+// CloneBuild contains the changes to make when cloning a finished build into a new build.
+type CloneBuild struct {
+	// Environment contains environment variables to set on every job in the new build, replacing any
+	// existing variables with the same name.
+	Environment []*models.EnvVar
+	// Opts to apply to the new build, or nil to use the default options.
+	Opts *models.BuildOptions
+}
+
 type BuildGraph struct {
 	*models.Build
 	// Jobs that make up the build.
@@ -39,6 +54,8 @@ func (m *BuildGraph) Validate() error {
 
 	jobsByFQN := make(map[models.NodeFQN]*models.Job, len(m.Jobs))
 	stepsByFQN := make(map[models.NodeFQN]*models.Step)
+	// Names of the artifacts declared by each job or its steps, used to check artifact dependencies
+	artifactsByJobFQN := make(map[models.NodeFQN]map[models.ResourceName]bool, len(m.Jobs))
 
 	for i, job := range m.Jobs {
 
@@ -55,6 +72,17 @@ func (m *BuildGraph) Validate() error {
 			jobsByFQN[job.GetFQN()] = job.Job
 		}
 
+		artifactNames := make(map[models.ResourceName]bool)
+		for _, artifact := range job.ArtifactDefinitions {
+			artifactNames[artifact.GroupName] = true
+		}
+		for _, step := range job.Steps {
+			for _, artifact := range step.ArtifactDefinitions {
+				artifactNames[artifact.GroupName] = true
+			}
+		}
+		artifactsByJobFQN[job.GetFQN()] = artifactNames
+
 		for i, step := range job.Steps {
 
 			err := step.Validate()
@@ -82,9 +110,19 @@ func (m *BuildGraph) Validate() error {
 			// when the dependency job doesn't exist if it should be in the same workflow as the dependent job
 			if !ok && (dependency.Workflow == job.Workflow) {
 				result = multierror.Append(result, errors.Errorf("Job %q depends on job %q but it does not exist",
-					job.Name, dependencyFQN))
+					job.Name, dependencyFQN.String()))
+			}
+			// Artifacts can only be checked for jobs that already exist; deferred jobs are checked when added
+			artifactNames, ok := artifactsByJobFQN[dependency.GetFQN()]
+			if !ok {
+				continue
+			}
+			for _, artifactDependency := range dependency.ArtifactDependencies {
+				if artifactDependency.GroupName != "" && !artifactNames[artifactDependency.GroupName] {
+					result = multierror.Append(result, errors.Errorf("Job %q depends on artifact %q from job %q but the job does not define it",
+						job.Name, artifactDependency.GroupName, dependencyFQN.String()))
+				}
 			}
-			// TODO validate artifact dependencies
 		}
 	}
 
@@ -125,6 +163,28 @@ func (m *BuildGraph) Validate() error {
 		}
 	}
 
+	// Validate stubbed workflow outputs; a workflow can't be both stubbed and requested to run
+	err = m.Opts.WorkflowOutputStubs.Validate()
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	err = m.Opts.Parameters.Validate()
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	for _, nodeFQN := range m.Opts.NodesToRun {
+		if _, ok := m.Opts.WorkflowOutputStubs[nodeFQN.WorkflowName]; ok {
+			result = multierror.Append(result, errors.Errorf("Build options specified workflow %q to run but its outputs are stubbed",
+				nodeFQN.WorkflowName))
+		}
+	}
+	if m.Opts.Priority != nil {
+		err = models.ValidateBuildPriority(*m.Opts.Priority)
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
 	// Return a validation error so an HTTP status of 400 (bad request) is returned
 	err = result.ErrorOrNil()
 	if err != nil {
@@ -182,6 +242,113 @@ func (m *BuildGraph) dag() (*DAG, error) {
 	return dag, nil
 }
 
+// BuildStage is a named group of jobs within a workflow. Each stage's jobs depend on the jobs in the stage before it.
+type BuildStage struct {
+	// Workflow the stage is a part of, or empty if the stage is part of the default workflow
+	Workflow models.ResourceName
+	// Name of the stage
+	Name models.ResourceName
+	// Jobs that belong to the stage
+	Jobs []*JobGraph
+}
+
+// Stages returns the stages of each workflow in the build, ordered so that every stage comes after the stages
+// its jobs depend on. Stages are ordered by the shallowest position of any of their jobs in the job dependency
+// graph, with ties broken by the order in which the stages first appear in the build's jobs.
+// Jobs that don't belong to a stage are not included. If the job dependency graph can't be walked then stages
+// are returned in the order they first appear.
+func (m *BuildGraph) Stages() []*BuildStage {
+	type stageKey struct {
+		workflow models.ResourceName
+		name     models.ResourceName
+	}
+	var (
+		stages      []*BuildStage
+		stagesByKey = make(map[stageKey]*BuildStage)
+		depthByFQN  = make(map[models.NodeFQN]int, len(m.Jobs))
+	)
+	for _, job := range m.Jobs {
+		if job.Stage == "" {
+			continue
+		}
+		key := stageKey{workflow: job.Workflow, name: job.Stage}
+		stage, ok := stagesByKey[key]
+		if !ok {
+			stage = &BuildStage{Workflow: job.Workflow, Name: job.Stage}
+			stagesByKey[key] = stage
+			stages = append(stages, stage)
+		}
+		stage.Jobs = append(stage.Jobs, job)
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+	// Walking in series visits each job after its dependencies, so their depths are always known
+	err := m.Walk(false, func(job *JobGraph) error {
+		depth := 0
+		for _, dependency := range job.Depends {
+			dependencyDepth, ok := depthByFQN[dependency.GetFQN()]
+			if ok && dependencyDepth+1 > depth {
+				depth = dependencyDepth + 1
+			}
+		}
+		depthByFQN[job.GetFQN()] = depth
+		return nil
+	})
+	if err != nil {
+		return stages
+	}
+	minDepth := func(stage *BuildStage) int {
+		min := -1
+		for _, job := range stage.Jobs {
+			depth := depthByFQN[job.GetFQN()]
+			if min == -1 || depth < min {
+				min = depth
+			}
+		}
+		return min
+	}
+	sort.SliceStable(stages, func(i, j int) bool {
+		return minDepth(stages[i]) < minDepth(stages[j])
+	})
+	return stages
+}
+
+// BuildGraphEdge is a dependency of one job in a build on another.
+type BuildGraphEdge struct {
+	// From identifies the job that is depended on.
+	From models.NodeFQN
+	// To identifies the job that depends on From.
+	To models.NodeFQN
+	// ArtifactDependencies lists the artifacts produced by From that are consumed by To.
+	ArtifactDependencies []*models.ArtifactDependency
+	// Deferred is true if From is in another workflow and has not been added to the build yet.
+	Deferred bool
+}
+
+// Edges returns an edge for each dependency between the jobs in the build, in the order the jobs and their
+// dependencies are declared. Dependencies on jobs in other workflows that have not been added to the build yet
+// are included, and marked as deferred.
+func (m *BuildGraph) Edges() []*BuildGraphEdge {
+	jobsByFQN := make(map[models.NodeFQN]bool, len(m.Jobs))
+	for _, job := range m.Jobs {
+		jobsByFQN[job.GetFQN()] = true
+	}
+	var edges []*BuildGraphEdge
+	for _, job := range m.Jobs {
+		for _, dependency := range job.Depends {
+			from := dependency.GetFQN()
+			edges = append(edges, &BuildGraphEdge{
+				From:                 from,
+				To:                   job.GetFQN(),
+				ArtifactDependencies: dependency.ArtifactDependencies,
+				Deferred:             !jobsByFQN[from],
+			})
+		}
+	}
+	return edges
+}
+
 // Ancestors returns all ancestors (dependencies) of the specified job. Includes transitive dependencies.
 // Does not include dependencies on jobs in other workflows that don't exist yet.
 func (m *BuildGraph) Ancestors(jGraph *JobGraph) ([]*JobGraph, error) {
@@ -273,3 +440,28 @@ func (m *BuildGraph) Trim(keep []models.NodeFQN) error {
 
 	return nil
 }
+
+// TrimDependencies removes all jobs from the build graph except those referenced in keep, along with any
+// dependencies the remaining jobs have on the jobs that were removed. Unlike Trim, the jobs that the kept jobs
+// depend on are not kept; anything they would have produced (such as artifacts) must be provided some other way.
+func (m *BuildGraph) TrimDependencies(keep []models.NodeFQN) {
+	keeping := make(map[models.NodeFQN]bool, len(keep))
+	for _, keepFQN := range keep {
+		keeping[models.NewNodeFQNForJob(keepFQN.WorkflowName, keepFQN.JobName)] = true
+	}
+	var jobs []*JobGraph
+	for _, job := range m.Jobs {
+		if !keeping[job.GetFQN()] {
+			continue
+		}
+		var depends models.JobDependencies
+		for _, dependency := range job.Depends {
+			if keeping[dependency.GetFQN()] {
+				depends = append(depends, dependency)
+			}
+		}
+		job.Depends = depends
+		jobs = append(jobs, job)
+	}
+	m.Jobs = jobs
+}
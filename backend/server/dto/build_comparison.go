@@ -0,0 +1,47 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// BuildComparison describes what changed between two builds of the same repo, from the base build to the head build.
+type BuildComparison struct {
+	Base *models.Build
+	Head *models.Build
+	// Jobs compares each job that appears in either build, matched by workflow and job name.
+	Jobs []*JobComparison
+	// Artifacts lists the artifacts that were added, removed or changed size, matched by job, group and path.
+	Artifacts []*ArtifactComparison
+	// NewTestFailures lists the tests that failed in the head build but not in the base build.
+	NewTestFailures []*models.TestCase
+	// ResolvedTestFailures lists the tests that failed in the base build but not in the head build.
+	ResolvedTestFailures []*models.TestCase
+}
+
+// JobComparison compares a job in the base build with the job with the same workflow and name in the head build.
+type JobComparison struct {
+	FQN models.NodeFQN
+	// Base is the job in the base build, or nil if the job was added in the head build.
+	Base *models.Job
+	// Head is the job in the head build, or nil if the job was removed in the head build.
+	Head *models.Job
+}
+
+// FingerprintChanged returns true if the job is in both builds and its fingerprint changed, meaning that its
+// inputs changed. Jobs that have no fingerprint are never considered to have changed.
+func (m *JobComparison) FingerprintChanged() bool {
+	if m.Base == nil || m.Head == nil || m.Base.Fingerprint == "" || m.Head.Fingerprint == "" {
+		return false
+	}
+	return m.Base.Fingerprint != m.Head.Fingerprint
+}
+
+// ArtifactComparison compares an artifact in the base build with the artifact produced by the same job, in
+// the same group and with the same path in the head build.
+type ArtifactComparison struct {
+	JobFQN    models.NodeFQN
+	GroupName models.ResourceName
+	Path      string
+	// Base is the artifact in the base build, or nil if the artifact was added in the head build.
+	Base *models.Artifact
+	// Head is the artifact in the head build, or nil if the artifact was removed in the head build.
+	Head *models.Artifact
+}
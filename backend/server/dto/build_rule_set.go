@@ -0,0 +1,14 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// UpdateBuildRuleSet contains the fields to update on a repo's build rule set; nil fields are left unchanged.
+type UpdateBuildRuleSet struct {
+	AllowRefs        *models.RefPatterns
+	DenyRefs         *models.RefPatterns
+	BuildTags        *bool
+	PullRequestsOnly *bool
+	SkipPattern      *string
+	CommentCommands  *models.CommentCommands
+	ETag             models.ETag
+}
@@ -0,0 +1,16 @@
+package dto
+
+import (
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// CreatePersonalAccessToken contains the details for a new personal access token for an identity.
+type CreatePersonalAccessToken struct {
+	IdentityID models.IdentityID
+	// Name describes what the token is for.
+	Name string
+	// Scopes limits the operations the token can perform.
+	Scopes models.TokenScopes
+	// ExpiresAt is the time after which the token can no longer be used.
+	ExpiresAt models.Time
+}
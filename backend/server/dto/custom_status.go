@@ -0,0 +1,13 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// PublishCustomStatus contains the details of a custom status to publish to a build. If the build already
+// has a custom status with the same name then it is replaced.
+type PublishCustomStatus struct {
+	BuildID     models.BuildID
+	Name        models.ResourceName
+	State       models.CustomStatusState
+	Description string
+	TargetURL   string
+}
@@ -278,3 +278,90 @@ func TestBuild(t *testing.T) {
 		t.Fatalf("Expected successful trim: %s", err)
 	}
 }
+
+func TestBuildStages(t *testing.T) {
+	makeJob := func(name models.ResourceName, stage models.ResourceName, depends ...models.ResourceName) *dto.JobGraph {
+		job := &dto.JobGraph{
+			Job: &models.Job{
+				JobMetadata: models.JobMetadata{ID: models.NewJobID()},
+				JobData: models.JobData{
+					JobDefinitionData: models.JobDefinitionData{
+						Name:     name,
+						Workflow: "w",
+						Stage:    stage,
+					},
+				},
+			},
+		}
+		for _, dependency := range depends {
+			job.Depends = append(job.Depends, models.NewJobDependency("w", dependency))
+		}
+		return job
+	}
+
+	// Jobs are listed out of stage order to check stages are ordered by dependencies
+	build := &dto.BuildGraph{
+		Build: &models.Build{ID: models.NewBuildID()},
+		Jobs: []*dto.JobGraph{
+			makeJob("deploy", "deploy", "unit", "integration"),
+			makeJob("unit", "test", "compile"),
+			makeJob("compile", "build"),
+			makeJob("lint", ""),
+			makeJob("integration", "test", "compile"),
+		},
+	}
+
+	stages := build.Stages()
+	require.Len(t, stages, 3)
+	require.Equal(t, models.ResourceName("build"), stages[0].Name)
+	require.Equal(t, models.ResourceName("test"), stages[1].Name)
+	require.Equal(t, models.ResourceName("deploy"), stages[2].Name)
+	require.Len(t, stages[1].Jobs, 2)
+	require.Equal(t, models.ResourceName("unit"), stages[1].Jobs[0].Name)
+	require.Equal(t, models.ResourceName("integration"), stages[1].Jobs[1].Name)
+	for _, stage := range stages {
+		require.Equal(t, models.ResourceName("w"), stage.Workflow)
+	}
+}
+
+func TestBuildTrimDependencies(t *testing.T) {
+	makeJob := func(name models.ResourceName, depends ...models.ResourceName) *dto.JobGraph {
+		job := &dto.JobGraph{
+			Job: &models.Job{
+				JobMetadata: models.JobMetadata{ID: models.NewJobID()},
+				JobData: models.JobData{
+					JobDefinitionData: models.JobDefinitionData{
+						Name:     name,
+						Workflow: "w",
+					},
+				},
+			},
+		}
+		for _, dependency := range depends {
+			job.Depends = append(job.Depends, models.NewJobDependency("w", dependency,
+				models.NewArtifactDependency("w", dependency, "out")))
+		}
+		return job
+	}
+
+	build := &dto.BuildGraph{
+		Build: &models.Build{ID: models.NewBuildID()},
+		Jobs: []*dto.JobGraph{
+			makeJob("compile"),
+			makeJob("unit", "compile"),
+			makeJob("integration", "compile", "unit"),
+			makeJob("deploy", "integration"),
+		},
+	}
+
+	build.TrimDependencies([]models.NodeFQN{
+		models.NewNodeFQNForJob("w", "unit"),
+		models.NewNodeFQN("w", "integration", "test"),
+	})
+	require.Len(t, build.Jobs, 2)
+	require.Equal(t, models.ResourceName("unit"), build.Jobs[0].Name)
+	require.Empty(t, build.Jobs[0].Depends, "dependency on a removed job should be removed")
+	require.Equal(t, models.ResourceName("integration"), build.Jobs[1].Name)
+	require.Len(t, build.Jobs[1].Depends, 1, "dependency on a kept job should be kept")
+	require.Equal(t, models.ResourceName("unit"), build.Jobs[1].Depends[0].JobName)
+}
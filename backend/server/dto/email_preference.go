@@ -0,0 +1,12 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// UpdateEmailPreference contains the fields to update on a user's email preference; nil fields are left unchanged.
+type UpdateEmailPreference struct {
+	Enabled      *bool
+	OnlyMyBuilds *bool
+	OnlyFailures *bool
+	Digest       *bool
+	ETag         models.ETag
+}
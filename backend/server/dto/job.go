@@ -41,12 +41,29 @@ type UpdateJobFingerprintAndIndirect struct {
 }
 
 // JobGraph provides the details of a job, including the steps that the job contains.
+// JobFlakyTests describes the failed tests reported by a job that are known to be flaky.
+type JobFlakyTests struct {
+	Job *models.Job
+	// Failed is the number of test cases reported by the job that failed on every attempt.
+	Failed int
+	// KnownFlaky lists the tests that failed and are known to be flaky.
+	KnownFlaky []*models.FlakyTest
+	// FailedDueToFlakyTests is true if the job failed and every test that failed is known to be flaky.
+	FailedDueToFlakyTests bool
+}
+
 type JobGraph struct {
 	*models.Job
 	// Steps is the set of steps within the job.
 	Steps []*models.Step `json:"steps"`
 }
 
+// String returns the job's fully-qualified name, so jobs can be identified in dependency graph errors.
+func (m *JobGraph) String() string {
+	fqn := m.GetFQN()
+	return fqn.String()
+}
+
 // Validate the job including the step relationships/dependencies.
 func (m *JobGraph) Validate() error {
 	var result *multierror.Error
@@ -60,6 +77,22 @@ func (m *JobGraph) Validate() error {
 		}
 	}
 
+	// Artifacts declared by steps belong to the job, so their names must be unique across the job and all its steps
+	artifactOwnersByName := make(map[models.ResourceName]string)
+	for _, artifact := range m.ArtifactDefinitions {
+		artifactOwnersByName[artifact.GroupName] = "the job"
+	}
+	for _, step := range m.Steps {
+		for _, artifact := range step.ArtifactDefinitions {
+			owner, ok := artifactOwnersByName[artifact.GroupName]
+			if ok {
+				result = multierror.Append(result, fmt.Errorf("error artifact %q declared by step %q is also declared by %s; Artifacts must have unique names within a job", artifact.GroupName, step.Name, owner))
+				continue
+			}
+			artifactOwnersByName[artifact.GroupName] = fmt.Sprintf("step %q", step.Name)
+		}
+	}
+
 	// Form the graph of steps into a DAG, which will verify there are no cycles
 	_, err := m.dag()
 	if err != nil {
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// JobAnalytics summarizes the durations, queue wait times and outcomes of the finished jobs in a repo.
+type JobAnalytics struct {
+	RepoID models.RepoID
+	Query  *models.JobAnalyticsQuery
+	// Windows lists the time windows the query's range was broken down into, in order.
+	Windows []models.JobAnalyticsWindow
+	// Totals covers every finished job in the repo.
+	Totals *JobAnalyticsSeries
+	// Groups contains a series for each workflow or job, ordered by workflow and then by job name.
+	Groups []*JobAnalyticsGroup
+}
+
+// JobAnalyticsGroup is the series of statistics for a single workflow, or for a single job within a workflow.
+type JobAnalyticsGroup struct {
+	Workflow models.ResourceName
+	// JobName is the name of the job, or empty if jobs were grouped by workflow.
+	JobName models.ResourceName
+	*JobAnalyticsSeries
+}
+
+// JobAnalyticsSeries contains statistics for a set of jobs over the whole range of a query and in each of its
+// time windows.
+type JobAnalyticsSeries struct {
+	Overall *JobStats
+	// Windows contains the statistics for each of the query's time windows, in the same order as the windows.
+	Windows []*JobStats
+}
+
+// JobStats are statistics about a set of finished jobs.
+type JobStats struct {
+	Jobs      int
+	Succeeded int
+	Failed    int
+	Canceled  int
+	Skipped   int
+	// SuccessRate is the fraction of jobs that either succeeded or failed that succeeded, or nil if no jobs
+	// succeeded or failed.
+	SuccessRate *float64
+	// DurationP50 and DurationP95 are percentiles of the time jobs spent running, or nil if no jobs ran.
+	DurationP50 *time.Duration
+	DurationP95 *time.Duration
+	// QueueWaitP50 and QueueWaitP95 are percentiles of the time jobs spent between being queued and starting
+	// to run, including time spent waiting for their dependencies, or nil if no jobs ran.
+	QueueWaitP50 *time.Duration
+	QueueWaitP95 *time.Duration
+}
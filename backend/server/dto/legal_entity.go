@@ -0,0 +1,8 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+type UpdateLegalEntitySchedulingWeight struct {
+	SchedulingWeight int
+	ETag             models.ETag
+}
@@ -0,0 +1,24 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+type CreateNotificationSetting struct {
+	RepoID              models.RepoID
+	Channel             models.NotificationChannelType
+	WebhookURLPlaintext string
+	OnSuccess           bool
+	OnFailure           bool
+	OnRecovery          bool
+	MessageTemplate     string
+}
+
+// UpdateNotificationSetting contains the fields to update on a notification setting; nil fields are left unchanged.
+type UpdateNotificationSetting struct {
+	Channel             *models.NotificationChannelType
+	WebhookURLPlaintext *string
+	OnSuccess           *bool
+	OnFailure           *bool
+	OnRecovery          *bool
+	MessageTemplate     *string
+	ETag                models.ETag
+}
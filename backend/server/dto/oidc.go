@@ -0,0 +1,41 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// OIDCToken is an OpenID Connect ID token issued to a running job.
+type OIDCToken struct {
+	// Token is the signed JWT.
+	Token string
+	// ExpiresAt is the time after which the token is no longer valid.
+	ExpiresAt models.Time
+}
+
+// JSONWebKey is the public half of a key used to sign OpenID Connect ID tokens, in the format specified
+// by RFC 7517. Keys issued by the server are always EC keys; N and E are only set for RSA keys published
+// by external identity providers.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// SSOUser is a user who has logged in via a single sign-on identity provider, as described by the claims
+// in the ID token issued by the provider.
+type SSOUser struct {
+	// Subject uniquely and permanently identifies the user within the identity provider.
+	Subject string
+	// Username is the user's preferred username, if the provider supplied one.
+	Username string
+	// Name is the user's full name, if the provider supplied one.
+	Name string
+	// Email is the user's email address, if the provider supplied one.
+	Email string
+	// Groups are the names of the groups the user is a member of within the identity provider.
+	Groups []string
+}
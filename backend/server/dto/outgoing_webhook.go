@@ -0,0 +1,28 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// CreateOutgoingWebhook contains the details for a new outgoing webhook. If RepoID is set then the webhook
+// receives events for that repo only, otherwise it receives events for all repos owned by LegalEntityID.
+type CreateOutgoingWebhook struct {
+	LegalEntityID        models.LegalEntityID
+	RepoID               models.RepoID
+	URL                  string
+	SecretPlaintext      string
+	OnBuildStatusChanged bool
+	OnJobStatusChanged   bool
+	// OnRunnerHealthChanged can only be set for webhooks that are not specific to a repo.
+	OnRunnerHealthChanged bool
+}
+
+// UpdateOutgoingWebhook contains the fields to update on an outgoing webhook; nil fields are left unchanged.
+type UpdateOutgoingWebhook struct {
+	URL                   *string
+	SecretPlaintext       *string
+	OnBuildStatusChanged  *bool
+	OnJobStatusChanged    *bool
+	OnRunnerHealthChanged *bool
+	// Enabled disables the webhook if false, or re-enables a disabled webhook if true.
+	Enabled *bool
+	ETag    models.ETag
+}
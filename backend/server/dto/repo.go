@@ -6,3 +6,31 @@ type UpdateRepoEnabled struct {
 	Enabled bool
 	ETag    models.ETag
 }
+
+type UpdateDynamicJobRestrictions struct {
+	// Restrictions to apply to dynamic jobs, or nil to remove all repo-specific restrictions.
+	Restrictions *models.DynamicJobRestrictions
+	ETag         models.ETag
+}
+
+type UpdateCoverageSettings struct {
+	// Settings for checking code coverage, or nil to stop checking coverage for the repo.
+	Settings *models.CoverageSettings
+	ETag     models.ETag
+}
+
+type UpdateRepoPublicBuilds struct {
+	PublicBuilds bool
+	ETag         models.ETag
+}
+
+type UpdateBuildPrioritySettings struct {
+	// Settings for the priority of new builds, or nil to give new builds the normal priority.
+	Settings *models.BuildPrioritySettings
+	ETag     models.ETag
+}
+
+type UpdateRepoDeduplicateJobs struct {
+	DeduplicateJobs bool
+	ETag            models.ETag
+}
@@ -0,0 +1,23 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// CreateRole contains the details for a new custom role belonging to a legal entity.
+type CreateRole struct {
+	LegalEntityID models.LegalEntityID
+	// TemplateName is the name of a role template to create the role from, or empty to create the role from
+	// the other fields. When set, the template provides the role's operations and defaults for its name
+	// and description.
+	TemplateName models.ResourceName
+	Name         models.ResourceName
+	Description  string
+	Operations   models.RoleOperations
+}
+
+// UpdateRole contains the fields to update on a role; nil fields are left unchanged.
+type UpdateRole struct {
+	Name        *models.ResourceName
+	Description *string
+	Operations  *models.RoleOperations
+	ETag        models.ETag
+}
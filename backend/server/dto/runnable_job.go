@@ -7,14 +7,25 @@ import "github.com/buildbeaver/buildbeaver/common/models"
 type RunnableJob struct {
 	// Repo that was committed to.
 	Repo *models.Repo `json:"repo"`
+	// RepoOwnerName is the name of the legal entity (user or organization) that owns the repo.
+	RepoOwnerName models.ResourceName `json:"repo_owner_name"`
 	// Commit that the job was generated from.
 	Commit *models.Commit `json:"commit"`
 	// Jobs is the set of jobs that this job depends on.
 	Jobs []*models.Job `json:"jobs"`
+	// ExternalArtifacts is the set of artifacts from previous builds that this job depends on, resolved from the
+	// job's ArtifactFrom dependencies at the time the job was dequeued.
+	ExternalArtifacts []*models.Artifact `json:"external_artifacts"`
 	// JWT (JSON Web Token) that dynamic build jobs can use to access the dynamic API for this build
 	JWT string `json:"jwt"`
 	// WorkflowsToRun is a list of workflows that have been requested to run as part of the build options.
 	// This does not include workflows that become required as new dependencies when new jobs are submitted.
 	WorkflowsToRun []models.ResourceName `json:"workflows_to_run"`
+	// WorkflowOutputStubs provides values for the outputs of workflows that should not be run,
+	// as specified in the build options.
+	WorkflowOutputStubs models.WorkflowOutputStubs `json:"workflow_output_stubs"`
+	// BuildParameters contains the value of each parameter supplied for the build, or defaulted from the
+	// build definition.
+	BuildParameters models.BuildParameters `json:"build_parameters"`
 	*JobGraph
 }
@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// CreateRunnerPool contains the details for a new runner pool belonging to a legal entity.
+type CreateRunnerPool struct {
+	LegalEntityID        models.LegalEntityID
+	Name                 models.ResourceName
+	Labels               models.Labels
+	MinRunners           int
+	MaxRunners           int
+	ScaleDownIdleTimeout time.Duration
+	ProvisionerType      models.RunnerProvisionerType
+	ProvisionerConfig    models.RunnerProvisionerConfig
+	// SecretPlaintext is an optional credential for the provisioner.
+	SecretPlaintext string
+}
+
+// UpdateRunnerPool contains the fields to update on a runner pool; nil fields are left unchanged.
+type UpdateRunnerPool struct {
+	Name                 *models.ResourceName
+	Labels               *models.Labels
+	MinRunners           *int
+	MaxRunners           *int
+	ScaleDownIdleTimeout *time.Duration
+	ProvisionerType      *models.RunnerProvisionerType
+	ProvisionerConfig    *models.RunnerProvisionerConfig
+	// SecretPlaintext replaces the provisioner's credential; an empty string removes it.
+	SecretPlaintext *string
+	ETag            models.ETag
+}
@@ -0,0 +1,37 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// UpdateSAMLIdentityProvider contains the fields to update on a legal entity's SAML identity provider; nil
+// fields are left unchanged.
+type UpdateSAMLIdentityProvider struct {
+	Enabled           *bool
+	MetadataXML       *string
+	EmailAttribute    *string
+	NameAttribute     *string
+	UsernameAttribute *string
+	GroupsAttribute   *string
+	GroupMappings     *models.SAMLGroupMappings
+	ETag              models.ETag
+}
+
+// SAMLAuthenticationRequest is a request to authenticate a user with a legal entity's SAML identity provider.
+type SAMLAuthenticationRequest struct {
+	// RedirectURL is the identity provider's single sign-on URL to redirect the user's browser to, including
+	// the encoded request.
+	RedirectURL string
+	// RequestID is the ID of the request, which the identity provider's response must refer to. The ID must
+	// be kept by the caller and passed in when authenticating the response.
+	RequestID string
+}
+
+// SAMLUser is a user described by an assertion from a SAML identity provider.
+type SAMLUser struct {
+	// NameID identifies the user within the identity provider.
+	NameID   string
+	Username string
+	Name     string
+	Email    string
+	// Groups are the values of the groups attribute.
+	Groups []string
+}
@@ -0,0 +1,21 @@
+package dto
+
+import "github.com/buildbeaver/buildbeaver/common/models"
+
+// RegisterToolchain contains the details of a new version of a toolchain to register for a legal entity.
+type RegisterToolchain struct {
+	// LegalEntityID is the legal entity to register the toolchain for. Ignored when registering for a build,
+	// where the toolchain is registered for the legal entity that owns the build's repo.
+	LegalEntityID models.LegalEntityID
+	Name          models.ResourceName
+	Version       string
+	Image         string
+	// Digest is the optional content digest of the image, e.g. "sha256:...".
+	Digest string
+	// Dockerfile is the optional path of the Dockerfile the toolchain's image is built from, within the repo
+	// of the build registering the toolchain. Only valid when registering for a build.
+	Dockerfile string
+	// RebuildWorkflow is the workflow that builds and registers a new version of the toolchain when the
+	// Dockerfile changes. Required if Dockerfile is set.
+	RebuildWorkflow models.ResourceName
+}
@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/h2non/filetype"
 	"github.com/pkg/errors"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/common/util"
@@ -23,28 +25,44 @@ import (
 type ArtifactService struct {
 	db                *store.DB
 	artifactStore     store.ArtifactStore
+	jobStore          store.JobStore
 	ownershipStore    store.OwnershipStore
 	blobStore         services.BlobStore
 	resourceLinkStore store.ResourceLinkStore
+	notifier          store.Notifier
+	signer            *artifactSigner
+	uploadHandlersMu  sync.RWMutex
+	uploadHandlers    []services.ArtifactUploadHandler
 	logger.Log
 }
 
 func NewArtifactService(
 	db *store.DB,
 	artifactStore store.ArtifactStore,
+	jobStore store.JobStore,
 	ownershipStore store.OwnershipStore,
 	blobStore services.BlobStore,
 	resourceLinkStore store.ResourceLinkStore,
-	logFactory logger.LogFactory) *ArtifactService {
+	notifier store.Notifier,
+	signingConfig ArtifactSigningConfig,
+	logFactory logger.LogFactory) (*ArtifactService, error) {
 
-	return &ArtifactService{
+	s := &ArtifactService{
 		db:                db,
 		artifactStore:     artifactStore,
+		jobStore:          jobStore,
 		ownershipStore:    ownershipStore,
 		blobStore:         blobStore,
 		resourceLinkStore: resourceLinkStore,
+		notifier:          notifier,
 		Log:               logFactory("ArtifactService"),
 	}
+	signer, err := newArtifactSigner(signingConfig, s.Log)
+	if err != nil {
+		return nil, fmt.Errorf("error loading artifact signing key: %w", err)
+	}
+	s.signer = signer
+	return s, nil
 }
 
 // Read an existing artifact, looking it up by ID.
@@ -52,8 +70,14 @@ func (s *ArtifactService) Read(ctx context.Context, txOrNil *store.Tx, id models
 	return s.artifactStore.Read(ctx, txOrNil, id)
 }
 
+// ListByJobIDs gets all artifacts created by any of the specified jobs.
+func (s *ArtifactService) ListByJobIDs(ctx context.Context, txOrNil *store.Tx, jobIDs []models.JobID) ([]*models.Artifact, error) {
+	return s.artifactStore.ListByJobIDs(ctx, txOrNil, jobIDs)
+}
+
 // Create a new artifact with its contents provided by reader. It is the caller's responsibility to close reader.
 // Optionally specify expectedMD5 to verify the file contents matches the expected MD5.
+// The SHA-256 hash of the contents is recorded alongside the MD5, and signed if artifact signing is enabled.
 // If storeData is true then the artifact data obtained from the reader will be stored in the blob store.
 func (s *ArtifactService) Create(
 	ctx context.Context,
@@ -76,8 +100,9 @@ func (s *ArtifactService) Create(
 		return nil, fmt.Errorf("error creating artifact file: %w", err)
 	}
 	md5Hash := md5.New()
+	sha256Hash := sha256.New()
 	countingReader := util.NewCountingReader(reader)
-	hashingReader := newHashingReader(md5Hash, countingReader)
+	hashingReader := newHashingReader(io.MultiWriter(md5Hash, sha256Hash), countingReader)
 	key := s.makeArtifactKey(artifact.ID)
 
 	if storeData {
@@ -103,8 +128,57 @@ func (s *ArtifactService) Create(
 	artifact.Size = countingReader.Count()
 	artifact.Hash = calculatedMD5
 	artifact.HashType = models.HashTypeMD5
+	artifact.SHA256 = hex.EncodeToString(sha256Hash.Sum(nil))
+	if s.signer != nil {
+		artifact.Signature = s.signer.Sign(artifact.ID, artifact.SHA256)
+	}
 	// artifact.Mime = // TODO sniff sniff
-	return artifact, s.artifactStore.Update(ctx, nil, artifact)
+	err = s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		if storeData {
+			s.uploadHandlersMu.RLock()
+			handlers := s.uploadHandlers
+			s.uploadHandlersMu.RUnlock()
+			for _, handler := range handlers {
+				err := handler(ctx, tx, artifact)
+				if err != nil {
+					return fmt.Errorf("error calling artifact upload handler: %w", err)
+				}
+			}
+		}
+		err := s.artifactStore.Update(ctx, tx, artifact)
+		if err != nil {
+			return err
+		}
+		s.notifyArtifactSealed(ctx, tx, artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// notifyArtifactSealed notifies anyone waiting for artifacts from the artifact's build that it has been sealed.
+// Errors are logged rather than returned, since waiters will find the artifact when they next search.
+func (s *ArtifactService) notifyArtifactSealed(ctx context.Context, tx *store.Tx, artifact *models.Artifact) {
+	job, err := s.jobStore.Read(ctx, tx, artifact.JobID)
+	if err != nil {
+		s.Warnf("Ignoring error reading job to notify waiters of sealed artifact: %v", err)
+		return
+	}
+	err = s.notifier.Notify(ctx, tx, store.BuildArtifactsNotificationChannel(job.BuildID))
+	if err != nil {
+		s.Warnf("Ignoring error notifying waiters of sealed artifact: %v", err)
+	}
+}
+
+// RegisterUploadHandler registers a handler to be called each time the data for an artifact has been stored,
+// just before the artifact is sealed. Handlers are called inside the transaction that seals the artifact and
+// may modify the artifact before it is saved.
+func (s *ArtifactService) RegisterUploadHandler(handler services.ArtifactUploadHandler) {
+	s.uploadHandlersMu.Lock()
+	defer s.uploadHandlersMu.Unlock()
+	s.uploadHandlers = append(s.uploadHandlers, handler)
 }
 
 // Search all artifacts. If searcher is set, the results will be limited to artifacts the searcher is authorized to
@@ -117,6 +191,46 @@ func (s *ArtifactService) Search(ctx context.Context, txOrNil *store.Tx, searche
 	return s.artifactStore.Search(ctx, txOrNil, searcher, search)
 }
 
+// SearchWithWait searches artifacts in the same way as Search. If none of the matching artifacts have been
+// sealed then waits for up to wait for an artifact in the build being searched to be sealed, searching again
+// each time one is, and returns the results of the last search. The results may still contain no sealed
+// artifacts if none were sealed before the wait expired. Waiting requires the search to be limited to a
+// build, and relies on notifications that artifacts have been sealed; if the notifier doesn't deliver
+// notifications then this doesn't wait, and behaves the same as Search.
+func (s *ArtifactService) SearchWithWait(ctx context.Context, searcher models.IdentityID, search models.ArtifactSearch, wait time.Duration) ([]*models.Artifact, *models.Cursor, error) {
+	if wait <= 0 || !search.BuildID.Valid() {
+		return s.Search(ctx, nil, searcher, search)
+	}
+	// Start listening before the first search, so that artifacts sealed during it aren't missed
+	notifications, stopListening := s.notifier.Listen(store.BuildArtifactsNotificationChannel(search.BuildID))
+	defer stopListening()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		artifacts, cursor, err := s.Search(ctx, nil, searcher, search)
+		if err != nil || anySealed(artifacts) || notifications == nil {
+			return artifacts, cursor, err
+		}
+		select {
+		case <-notifications:
+		case <-timer.C:
+			return artifacts, cursor, nil
+		case <-ctx.Done():
+			return artifacts, cursor, nil
+		}
+	}
+}
+
+// anySealed returns true if any of the artifacts have been sealed.
+func anySealed(artifacts []*models.Artifact) bool {
+	for _, artifact := range artifacts {
+		if artifact.Sealed {
+			return true
+		}
+	}
+	return false
+}
+
 // findOrCreateArtifact creates an artifact if no artifact with the same unique values exist,
 // otherwise it reads and returns the existing artifact.
 func (s *ArtifactService) findOrCreateArtifact(ctx context.Context, txOrNil *store.Tx, artifactData *models.ArtifactData) (artifact *models.Artifact, created bool, err error) {
@@ -143,12 +257,85 @@ func (s *ArtifactService) findOrCreateArtifact(ctx context.Context, txOrNil *sto
 }
 
 // GetArtifactData returns a reader to the data of an artifact.
+// Returns an ArtifactQuarantined error if the artifact has been quarantined by the artifact scanner.
 // It is the callers responsibility to close reader.
 func (s *ArtifactService) GetArtifactData(ctx context.Context, artifactID models.ArtifactID) (io.ReadCloser, error) {
+	artifact, err := s.artifactStore.Read(ctx, nil, artifactID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifact: %w", err)
+	}
+	if artifact.ScanStatus.IsQuarantined() {
+		return nil, gerror.NewErrArtifactQuarantined(fmt.Sprintf("Artifact %q has been quarantined: %s", artifact.Path, artifact.ScanResult))
+	}
 	key := s.makeArtifactKey(artifactID)
 	return s.blobStore.GetBlob(ctx, key)
 }
 
+// VerifyArtifact checks that the data held for a sealed artifact still matches the SHA-256 hash recorded when
+// it was uploaded and, if the artifact was signed, that the signature over the hash is valid.
+func (s *ArtifactService) VerifyArtifact(ctx context.Context, artifactID models.ArtifactID) (*models.ArtifactVerification, error) {
+	artifact, err := s.artifactStore.Read(ctx, nil, artifactID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifact: %w", err)
+	}
+	if !artifact.Sealed {
+		return nil, gerror.NewErrValidationFailed("Artifact data has not been uploaded")
+	}
+	if artifact.SHA256 == "" {
+		return nil, gerror.NewErrValidationFailed("Artifact was uploaded before SHA-256 hashes were recorded and can not be verified")
+	}
+	reader, err := s.blobStore.GetBlob(ctx, s.makeArtifactKey(artifactID))
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifact data: %w", err)
+	}
+	defer reader.Close()
+	sha256Hash := sha256.New()
+	_, err = io.Copy(sha256Hash, reader)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing artifact data: %w", err)
+	}
+	verification := &models.ArtifactVerification{
+		ArtifactID:       artifact.ID,
+		SHA256:           artifact.SHA256,
+		CalculatedSHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+		Signed:           artifact.Signature != "",
+		Signature:        artifact.Signature,
+	}
+	verification.DataValid = verification.CalculatedSHA256 == artifact.SHA256
+	if verification.Signed {
+		verification.SignatureAlgorithm = models.ArtifactSignatureAlgorithmEd25519
+	}
+	if s.signer != nil {
+		verification.SigningCertificate = s.signer.certificatePEM
+		verification.SignatureValid = verification.Signed && s.signer.Verify(artifact.ID, artifact.SHA256, artifact.Signature)
+	}
+	return verification, nil
+}
+
+// Delete permanently and idempotently deletes an artifact and its data. The data is deleted from the blob store
+// first, so if anything fails the artifact is still recorded and the delete can be retried.
+func (s *ArtifactService) Delete(ctx context.Context, txOrNil *store.Tx, artifactID models.ArtifactID) error {
+	err := s.blobStore.DeleteBlob(ctx, s.makeArtifactKey(artifactID))
+	if err != nil {
+		return fmt.Errorf("error deleting artifact data: %w", err)
+	}
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.artifactStore.Delete(ctx, tx, artifactID)
+		if err != nil {
+			return fmt.Errorf("error deleting artifact: %w", err)
+		}
+		err = s.ownershipStore.Delete(ctx, tx, artifactID.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		err = s.resourceLinkStore.Delete(ctx, tx, artifactID.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting resource link: %w", err)
+		}
+		return nil
+	})
+}
+
 func (s *ArtifactService) makeArtifactKey(artifactID models.ArtifactID) string {
 	return fmt.Sprintf("artifacts/%s", artifactID)
 }
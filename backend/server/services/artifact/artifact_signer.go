@@ -0,0 +1,99 @@
+package artifact
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// ArtifactSigningConfig configures the key used to sign the hashes of uploaded artifacts.
+// Artifact signing is disabled if no private key file is configured.
+type ArtifactSigningConfig struct {
+	CertificateFile certificates.CertificateFile
+	PrivateKeyFile  certificates.PrivateKeyFile
+	// AutoCreateKeyPair will create a new key pair and certificate if no files exist at the configured locations.
+	AutoCreateKeyPair bool
+}
+
+// Enabled returns true if artifact signing is configured.
+func (c ArtifactSigningConfig) Enabled() bool {
+	return c.PrivateKeyFile != ""
+}
+
+// artifactSigner signs and verifies artifact hashes using an ed25519 key pair.
+type artifactSigner struct {
+	privateKey     ed25519.PrivateKey
+	publicKey      ed25519.PublicKey
+	certificatePEM string
+}
+
+// newArtifactSigner loads the key pair used to sign artifacts, creating it first if configured to do so.
+// Returns nil if artifact signing is not enabled.
+func newArtifactSigner(config ArtifactSigningConfig, log logger.Log) (*artifactSigner, error) {
+	if !config.Enabled() {
+		return nil, nil
+	}
+	if config.AutoCreateKeyPair {
+		created, err := certificates.GenerateEd25519SigningKeyAndCertificate(
+			config.CertificateFile,
+			config.PrivateKeyFile,
+			"BuildBeaver Limited",
+		)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			log.Infof("Created private/public key pair for artifact signing and verification")
+		}
+	}
+
+	privateKeyPEMBlock, err := os.ReadFile(config.PrivateKeyFile.String())
+	if err != nil {
+		return nil, fmt.Errorf("error loading artifact signing private key: %w", err)
+	}
+	privateKey, err := certificates.GetEd25519PrivateKeyFromPEM(string(privateKeyPEMBlock))
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifact signing private key from PEM file data: %w", err)
+	}
+	certPEMBlock, err := os.ReadFile(config.CertificateFile.String())
+	if err != nil {
+		return nil, fmt.Errorf("error loading artifact verification public key certificate: %w", err)
+	}
+	publicKey, err := certificates.GetEd25519PublicKeyFromCertificatePEM(string(certPEMBlock))
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifact verification public key from PEM file data: %w", err)
+	}
+	ed25519PrivateKey, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("error artifact signing private key is not an ed25519 key")
+	}
+	ed25519PublicKey, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("error artifact verification public key is not an ed25519 key")
+	}
+	return &artifactSigner{
+		privateKey:     ed25519PrivateKey,
+		publicKey:      ed25519PublicKey,
+		certificatePEM: string(certPEMBlock),
+	}, nil
+}
+
+// Sign returns the base64-encoded signature for an artifact with the specified SHA-256 hash.
+func (s *artifactSigner) Sign(artifactID models.ArtifactID, sha256 string) string {
+	signature := ed25519.Sign(s.privateKey, models.ArtifactSignaturePayload(artifactID, sha256))
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// Verify returns true if signature is a valid base64-encoded signature for an artifact with the specified SHA-256 hash.
+func (s *artifactSigner) Verify(artifactID models.ArtifactID, sha256 string, signature string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(s.publicKey, models.ArtifactSignaturePayload(artifactID, sha256), decoded)
+}
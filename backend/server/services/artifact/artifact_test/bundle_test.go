@@ -0,0 +1,121 @@
+package artifact_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestWriteArtifactBundle(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	require.GreaterOrEqual(t, len(graph.Jobs), 2)
+	job1 := graph.Jobs[0]
+	job2 := graph.Jobs[1]
+
+	// Both jobs produce an artifact at the same path, so the bundle must keep them apart
+	expected := map[string]string{
+		path.Join(job1.Name.String(), "out/report.txt"): "report from job 1",
+		path.Join(job1.Name.String(), "out/app.bin"):    "binary from job 1",
+		path.Join(job2.Name.String(), "out/report.txt"): "report from job 2",
+	}
+	_, err = app.ArtifactService.Create(ctx, job1.ID, "reports", "out/report.txt", "", bytes.NewReader([]byte("report from job 1")), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, job1.ID, "binaries", "out/app.bin", "", bytes.NewReader([]byte("binary from job 1")), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, job2.ID, "reports", "out/report.txt", "", bytes.NewReader([]byte("report from job 2")), true)
+	require.NoError(t, err)
+
+	search := models.ArtifactSearch{BuildID: graph.ID}
+
+	t.Run("Zip", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := app.ArtifactService.WriteArtifactBundle(ctx, models.NoIdentity, search, models.ArtifactBundleFormatZip, buf)
+		require.NoError(t, err)
+		require.Equal(t, expected, readZip(t, buf.Bytes()))
+	})
+
+	t.Run("TarGz", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := app.ArtifactService.WriteArtifactBundle(ctx, models.NoIdentity, search, models.ArtifactBundleFormatTarGz, buf)
+		require.NoError(t, err)
+		require.Equal(t, expected, readTarGz(t, buf.Bytes()))
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		filtered := search
+		filtered.JobName = &job1.Name
+		groupName := models.ResourceName("reports")
+		filtered.GroupName = &groupName
+		buf := &bytes.Buffer{}
+		err := app.ArtifactService.WriteArtifactBundle(ctx, models.NoIdentity, filtered, models.ArtifactBundleFormatZip, buf)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			path.Join(job1.Name.String(), "out/report.txt"): "report from job 1",
+		}, readZip(t, buf.Bytes()))
+	})
+
+	t.Run("NoMatches", func(t *testing.T) {
+		filtered := search
+		groupName := models.ResourceName("does-not-exist")
+		filtered.GroupName = &groupName
+		buf := &bytes.Buffer{}
+		err := app.ArtifactService.WriteArtifactBundle(ctx, models.NoIdentity, filtered, models.ArtifactBundleFormatZip, buf)
+		require.Error(t, err)
+		require.True(t, gerror.IsNotFound(err))
+		require.Zero(t, buf.Len())
+	})
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, file := range reader.File {
+		f, err := file.Open()
+		require.NoError(t, err)
+		contents, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		f.Close()
+		files[file.Name] = string(contents)
+	}
+	return files
+}
+
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	reader := tar.NewReader(gzipReader)
+	files := make(map[string]string)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		contents, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		files[header.Name] = string(contents)
+	}
+	return files
+}
@@ -0,0 +1,60 @@
+package artifact_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/services/blob"
+)
+
+func TestVerifyArtifact(t *testing.T) {
+	ctx := context.Background()
+
+	config := server_test.TestConfig(t)
+	app, cleanup, err := server_test.New(config)
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	job := graph.Jobs[0]
+
+	data := []byte("release binary")
+	expectedSHA256 := sha256.Sum256(data)
+	artifact, err := app.ArtifactService.Create(ctx, job.ID, "binaries", "out/app.bin", "", bytes.NewReader(data), true)
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(expectedSHA256[:]), artifact.SHA256)
+	require.NotEmpty(t, artifact.Signature, "expected artifact to be signed")
+
+	t.Run("Valid", func(t *testing.T) {
+		verification, err := app.ArtifactService.VerifyArtifact(ctx, artifact.ID)
+		require.NoError(t, err)
+		require.True(t, verification.DataValid)
+		require.True(t, verification.Signed)
+		require.True(t, verification.SignatureValid)
+		require.True(t, verification.Verified())
+		require.Equal(t, artifact.SHA256, verification.CalculatedSHA256)
+		require.NotEmpty(t, verification.SigningCertificate)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		blobStore := blob.NewLocalBlobStore(blob.LocalBlobStoreDirectory(config.BlobStoreConfig.LocalBlobStoreDir))
+		err := blobStore.PutBlob(ctx, fmt.Sprintf("artifacts/%s", artifact.ID), bytes.NewReader([]byte("malicious binary")))
+		require.NoError(t, err)
+
+		verification, err := app.ArtifactService.VerifyArtifact(ctx, artifact.ID)
+		require.NoError(t, err)
+		require.False(t, verification.DataValid)
+		require.True(t, verification.SignatureValid, "the signature covers the recorded hash, which is unchanged")
+		require.False(t, verification.Verified())
+	})
+}
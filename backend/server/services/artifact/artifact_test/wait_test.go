@@ -0,0 +1,56 @@
+package artifact_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestSearchWithWait(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	job := graph.Jobs[0]
+
+	groupName := models.ResourceName("reports")
+	search := models.NewArtifactSearch()
+	search.BuildID = graph.ID
+	search.GroupName = &groupName
+
+	// The wait expires if no matching artifact is sealed
+	artifacts, _, err := app.ArtifactService.SearchWithWait(ctx, models.NoIdentity, *search, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Empty(t, artifacts)
+
+	var waited []*models.Artifact
+	errChan := make(chan error, 1)
+	go func() {
+		var err error
+		waited, _, err = app.ArtifactService.SearchWithWait(ctx, models.NoIdentity, *search, time.Minute)
+		errChan <- err
+	}()
+	artifact, err := app.ArtifactService.Create(ctx, job.ID, groupName, "reports/report.txt", "", bytes.NewReader([]byte("report")), true)
+	require.NoError(t, err)
+	select {
+	case err := <-errChan:
+		require.NoError(t, err)
+		require.Len(t, waited, 1)
+		require.Equal(t, artifact.ID, waited[0].ID)
+		require.True(t, waited[0].Sealed)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for artifact to be sealed")
+	}
+}
@@ -0,0 +1,254 @@
+package artifact
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// bundleRangeSizeBytes is the amount of artifact data read from the blob store in each request while
+	// assembling a bundle.
+	bundleRangeSizeBytes = 8 * 1024 * 1024
+	// bundleSearchPageSize is the number of artifacts read from the database at a time while assembling a bundle.
+	bundleSearchPageSize = 100
+)
+
+// WriteArtifactBundle writes an archive in the specified format to writer, containing the data for every artifact
+// matching search. The archive is assembled on the fly from ranged blob store reads, so it is never held in
+// memory or on disk. Unsealed and quarantined artifacts are skipped. If searcher is set, only artifacts the searcher
+// is authorized to see are included. Returns a NotFound error, before anything is written, if no artifacts match.
+func (s *ArtifactService) WriteArtifactBundle(
+	ctx context.Context,
+	searcher models.IdentityID,
+	search models.ArtifactSearch,
+	format models.ArtifactBundleFormat,
+	writer io.Writer,
+) error {
+	if !format.Valid() {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Unsupported artifact bundle format %q", format))
+	}
+	if search.BuildID.IsZero() {
+		return gerror.NewErrValidationFailed("Build ID must be specified")
+	}
+	search.Pagination = models.NewPagination(bundleSearchPageSize, nil)
+	artifacts, cursor, err := s.artifactStore.Search(ctx, nil, searcher, search)
+	if err != nil {
+		return fmt.Errorf("error searching artifacts: %w", err)
+	}
+	if len(artifacts) == 0 {
+		return gerror.NewErrNotFound("No artifacts found")
+	}
+
+	var bundle bundleWriter
+	if format == models.ArtifactBundleFormatTarGz {
+		bundle = newTarGzBundleWriter(writer)
+	} else {
+		bundle = newZipBundleWriter(writer)
+	}
+	names := newBundleNamer(s.jobStore)
+	for {
+		for _, artifact := range artifacts {
+			if !artifact.Sealed || artifact.ScanStatus.IsQuarantined() {
+				s.Infof("Skipping artifact %q (sealed: %t, scan status: %s) in bundle", artifact.Path, artifact.Sealed, artifact.ScanStatus)
+				continue
+			}
+			name, err := names.name(ctx, artifact)
+			if err != nil {
+				return err
+			}
+			reader := newBlobRangeReader(ctx, s.blobStore, s.makeArtifactKey(artifact.ID), int64(artifact.Size), bundleRangeSizeBytes)
+			err = bundle.add(name, artifact, reader)
+			reader.Close()
+			if err != nil {
+				return fmt.Errorf("error adding artifact %q to bundle: %w", artifact.Path, err)
+			}
+		}
+		if cursor == nil || cursor.Next == nil {
+			break
+		}
+		search.Pagination.Cursor = cursor.Next
+		artifacts, cursor, err = s.artifactStore.Search(ctx, nil, searcher, search)
+		if err != nil {
+			return fmt.Errorf("error searching artifacts: %w", err)
+		}
+	}
+	return bundle.Close()
+}
+
+// bundleNamer chooses the path of each artifact within a bundle. Artifacts from different jobs can share the
+// same path, so each artifact is placed in a directory named after its workflow and job.
+type bundleNamer struct {
+	jobStore   store.JobStore
+	dirByJobID map[models.JobID]string
+}
+
+func newBundleNamer(jobStore store.JobStore) *bundleNamer {
+	return &bundleNamer{jobStore: jobStore, dirByJobID: make(map[models.JobID]string)}
+}
+
+func (n *bundleNamer) name(ctx context.Context, artifact *models.Artifact) (string, error) {
+	dir, ok := n.dirByJobID[artifact.JobID]
+	if !ok {
+		job, err := n.jobStore.Read(ctx, nil, artifact.JobID)
+		if err != nil {
+			return "", fmt.Errorf("error reading job for artifact %q: %w", artifact.Path, err)
+		}
+		dir = job.Name.String()
+		if job.Workflow != "" {
+			dir = path.Join(job.Workflow.String(), dir)
+		}
+		n.dirByJobID[artifact.JobID] = dir
+	}
+	// Artifact paths are relative to the job workspace; clean them so they can't escape the job's directory
+	clean := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(artifact.Path, "\\", "/")), "/")
+	return path.Join(dir, clean), nil
+}
+
+// bundleWriter adds artifacts to an archive.
+type bundleWriter interface {
+	add(name string, artifact *models.Artifact, reader io.Reader) error
+	// Close finishes writing the archive, without closing the underlying writer.
+	Close() error
+}
+
+type zipBundleWriter struct {
+	zip *zip.Writer
+}
+
+func newZipBundleWriter(writer io.Writer) *zipBundleWriter {
+	return &zipBundleWriter{zip: zip.NewWriter(writer)}
+}
+
+func (w *zipBundleWriter) add(name string, artifact *models.Artifact, reader io.Reader) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: artifact.CreatedAt.Time,
+	}
+	header.SetMode(0644)
+	entry, err := w.zip.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+func (w *zipBundleWriter) Close() error {
+	return w.zip.Close()
+}
+
+type tarGzBundleWriter struct {
+	gzip *gzip.Writer
+	tar  *tar.Writer
+}
+
+func newTarGzBundleWriter(writer io.Writer) *tarGzBundleWriter {
+	gzipWriter := gzip.NewWriter(writer)
+	return &tarGzBundleWriter{gzip: gzipWriter, tar: tar.NewWriter(gzipWriter)}
+}
+
+func (w *tarGzBundleWriter) add(name string, artifact *models.Artifact, reader io.Reader) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(artifact.Size),
+		Mode:     0644,
+		ModTime:  artifact.CreatedAt.Time,
+		Format:   tar.FormatPAX,
+	}
+	err := w.tar.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w.tar, reader)
+	return err
+}
+
+func (w *tarGzBundleWriter) Close() error {
+	err := w.tar.Close()
+	if err != nil {
+		return err
+	}
+	return w.gzip.Close()
+}
+
+// blobRangeReader reads a blob of known size sequentially, as a series of ranged reads from the blob store.
+// This keeps each blob store request short, even when the overall read is slow.
+type blobRangeReader struct {
+	ctx       context.Context
+	blobStore services.BlobStore
+	key       string
+	size      int64
+	rangeSize int64
+	offset    int64
+	current   io.ReadCloser
+	// readInRange is the number of bytes read from the current range
+	readInRange int64
+}
+
+func newBlobRangeReader(ctx context.Context, blobStore services.BlobStore, key string, size int64, rangeSize int64) *blobRangeReader {
+	return &blobRangeReader{
+		ctx:       ctx,
+		blobStore: blobStore,
+		key:       key,
+		size:      size,
+		rangeSize: rangeSize,
+	}
+}
+
+func (r *blobRangeReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.offset >= r.size {
+				return 0, io.EOF
+			}
+			length := r.rangeSize
+			if r.offset+length > r.size {
+				length = r.size - r.offset
+			}
+			reader, err := r.blobStore.GetBlobRange(r.ctx, r.key, r.offset, length)
+			if err != nil {
+				return 0, fmt.Errorf("error reading range %d-%d of %q: %w", r.offset, r.offset+length, r.key, err)
+			}
+			r.current = reader
+			r.readInRange = 0
+		}
+		n, err := r.current.Read(p)
+		r.offset += int64(n)
+		r.readInRange += int64(n)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if r.readInRange == 0 {
+				// The blob is shorter than expected; don't keep asking for the same range
+				return n, io.ErrUnexpectedEOF
+			}
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *blobRangeReader) Close() error {
+	if r.current != nil {
+		err := r.current.Close()
+		r.current = nil
+		return err
+	}
+	return nil
+}
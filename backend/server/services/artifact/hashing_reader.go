@@ -1,17 +1,17 @@
 package artifact
 
 import (
-	"hash"
 	"io"
 )
 
 // hashingReader sums the bytes read using a pluggable hasher implementation.
+// Use an io.MultiWriter to feed the bytes read to several hashers at once.
 type hashingReader struct {
-	hasher hash.Hash
+	hasher io.Writer
 	reader io.Reader
 }
 
-func newHashingReader(hasher hash.Hash, reader io.Reader) *hashingReader {
+func newHashingReader(hasher io.Writer, reader io.Reader) *hashingReader {
 	return &hashingReader{
 		hasher: hasher,
 		reader: reader,
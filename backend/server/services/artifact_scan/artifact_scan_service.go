@@ -0,0 +1,169 @@
+package artifact_scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const DefaultScanTimeout = 10 * time.Minute
+
+type ArtifactScanServiceConfig struct {
+	// ClamAVAddress is the address of a ClamAV daemon (clamd) to scan artifacts with, either host:port or
+	// the path to a Unix socket. Takes precedence over ExternalScannerURL if both are set.
+	ClamAVAddress string
+	// ExternalScannerURL is the URL of an external HTTP scanning service to scan artifacts with.
+	ExternalScannerURL string
+	// ScanTimeout is the maximum time allowed to scan a single artifact. Defaults to 10 minutes if zero.
+	ScanTimeout time.Duration
+}
+
+// ArtifactScanService scans newly uploaded artifacts for viruses and other malware, if a scanner is configured.
+// Artifacts are marked as pending when they are uploaded and scanned in the background via the work queue.
+// Infected artifacts are quarantined so their data can no longer be downloaded, and an ArtifactQuarantined
+// event is published. Artifacts that are pending are still available for download, so that scanning does not
+// hold up downstream jobs.
+type ArtifactScanService struct {
+	db              *store.DB
+	artifactStore   store.ArtifactStore
+	jobStore        store.JobStore
+	artifactService services.ArtifactService
+	eventService    services.EventService
+	scanner         services.ArtifactScanner
+	logger.Log
+}
+
+func NewArtifactScanService(
+	db *store.DB,
+	artifactStore store.ArtifactStore,
+	jobStore store.JobStore,
+	artifactService services.ArtifactService,
+	eventService services.EventService,
+	workQueueService services.WorkQueueService,
+	config ArtifactScanServiceConfig,
+	logFactory logger.LogFactory,
+) *ArtifactScanService {
+	s := &ArtifactScanService{
+		db:              db,
+		artifactStore:   artifactStore,
+		jobStore:        jobStore,
+		artifactService: artifactService,
+		eventService:    eventService,
+		Log:             logFactory("ArtifactScanService"),
+	}
+	switch {
+	case config.ClamAVAddress != "":
+		s.scanner = NewClamAVScanner(config.ClamAVAddress)
+		s.Infof("Artifact scanning enabled using ClamAV at %s", config.ClamAVAddress)
+	case config.ExternalScannerURL != "":
+		s.scanner = NewHTTPScanner(config.ExternalScannerURL)
+		s.Infof("Artifact scanning enabled using external scanner")
+	default:
+		return s
+	}
+
+	scanTimeout := config.ScanTimeout
+	if scanTimeout == 0 {
+		scanTimeout = DefaultScanTimeout
+	}
+	// Register the code to process work items for scanning artifacts
+	err := workQueueService.RegisterHandler(
+		ArtifactScanWorkItem,
+		s.ProcessArtifactScanWorkItem,
+		scanTimeout,
+		work_queue.ExponentialBackoff(10, 10*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	artifactService.RegisterUploadHandler(func(ctx context.Context, tx *store.Tx, artifact *models.Artifact) error {
+		artifact.ScanStatus = models.ArtifactScanStatusPending
+		return workQueueService.AddWorkItem(ctx, tx, NewArtifactScanWorkItem(artifact.ID))
+	})
+
+	return s
+}
+
+// Enabled returns true if a scanner is configured and newly uploaded artifacts will be scanned.
+func (s *ArtifactScanService) Enabled() bool {
+	return s.scanner != nil
+}
+
+// ProcessArtifactScanWorkItem scans the data for an artifact and records the result, quarantining the
+// artifact if it is infected.
+func (s *ArtifactScanService) ProcessArtifactScanWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &ArtifactScanWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling artifact scan work item data: %w", err)
+	}
+	artifact, err := s.artifactStore.Read(ctx, nil, workItemData.ArtifactID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping scan for deleted artifact %q", workItemData.ArtifactID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading artifact: %w", err)
+	}
+	if artifact.ScanStatus != models.ArtifactScanStatusPending {
+		s.Infof("Ignoring scan for artifact %q with scan status %q", artifact.ID, artifact.ScanStatus)
+		return false, nil
+	}
+
+	reader, err := s.artifactService.GetArtifactData(ctx, artifact.ID)
+	if err != nil {
+		return true, fmt.Errorf("error reading artifact data: %w", err)
+	}
+	defer reader.Close()
+	infected, threat, err := s.scanner.Scan(ctx, reader)
+	if err != nil {
+		return true, fmt.Errorf("error scanning artifact %q: %w", artifact.ID, err)
+	}
+
+	err = s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		artifact, err := s.artifactStore.Read(ctx, tx, workItemData.ArtifactID)
+		if err != nil {
+			return fmt.Errorf("error reading artifact: %w", err)
+		}
+		artifact.ScanStatus = models.ArtifactScanStatusClean
+		artifact.ScanResult = ""
+		if infected {
+			artifact.ScanStatus = models.ArtifactScanStatusInfected
+			artifact.ScanResult = threat
+		}
+		artifact.UpdatedAt = models.NewTime(time.Now())
+		err = s.artifactStore.Update(ctx, tx, artifact)
+		if err != nil {
+			return fmt.Errorf("error updating artifact scan status: %w", err)
+		}
+		if !infected {
+			return nil
+		}
+		s.Warnf("Quarantined artifact %q (%s): found %s", artifact.ID, artifact.Path, threat)
+		job, err := s.jobStore.Read(ctx, tx, artifact.JobID)
+		if err != nil {
+			return fmt.Errorf("error reading job for artifact: %w", err)
+		}
+		err = s.eventService.PublishEvent(ctx, tx, models.NewArtifactQuarantinedEventData(job, artifact))
+		if err != nil {
+			return fmt.Errorf("error publishing artifact quarantined event: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return true, err
+	}
+	s.Tracef("Scanned artifact %q: infected=%v", artifact.ID, infected)
+	return false, nil
+}
@@ -0,0 +1,139 @@
+package artifact_scan_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const (
+	testScanTimeout = 30 * time.Second
+	testThreatName  = "Test.Virus"
+)
+
+// newTestScanner returns an external scanner that reports any data containing the string "VIRUS" as infected.
+func newTestScanner(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		result := map[string]interface{}{"infected": false}
+		if strings.Contains(string(data), "VIRUS") {
+			result = map[string]interface{}{"infected": true, "threat": testThreatName}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(result)
+		require.NoError(t, err)
+	}))
+}
+
+func TestArtifactScanService(t *testing.T) {
+	ctx := context.Background()
+
+	scanner := newTestScanner(t)
+	defer scanner.Close()
+
+	config := server_test.TestConfig(t)
+	config.ArtifactScanConfig.ExternalScannerURL = scanner.URL
+	app, cleanup, err := server_test.New(config)
+	require.Nil(t, err)
+	defer cleanup()
+	require.True(t, app.ArtifactScanService.Enabled())
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	require.NotEmpty(t, graph.Jobs)
+	job := graph.Jobs[0]
+
+	clean, err := app.ArtifactService.Create(ctx, job.ID, "reports", "clean.txt", "", bytes.NewReader([]byte("all good")), true)
+	require.NoError(t, err)
+	require.Equal(t, models.ArtifactScanStatusPending, clean.ScanStatus)
+	infected, err := app.ArtifactService.Create(ctx, job.ID, "reports", "infected.txt", "", bytes.NewReader([]byte("a VIRUS")), true)
+	require.NoError(t, err)
+	require.Equal(t, models.ArtifactScanStatusPending, infected.ScanStatus)
+
+	// Pending artifacts can still be downloaded
+	reader, err := app.ArtifactService.GetArtifactData(ctx, infected.ID)
+	require.NoError(t, err)
+	reader.Close()
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	waitForScan := func(artifactID models.ArtifactID) *models.Artifact {
+		deadline := time.Now().Add(testScanTimeout)
+		for time.Now().Before(deadline) {
+			artifact, err := app.ArtifactService.Read(ctx, nil, artifactID)
+			require.NoError(t, err)
+			if artifact.ScanStatus != models.ArtifactScanStatusPending {
+				return artifact
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for artifact %q to be scanned", artifactID)
+		return nil
+	}
+
+	clean = waitForScan(clean.ID)
+	require.Equal(t, models.ArtifactScanStatusClean, clean.ScanStatus)
+	require.Empty(t, clean.ScanResult)
+	reader, err = app.ArtifactService.GetArtifactData(ctx, clean.ID)
+	require.NoError(t, err)
+	reader.Close()
+
+	infected = waitForScan(infected.ID)
+	require.Equal(t, models.ArtifactScanStatusInfected, infected.ScanStatus)
+	require.Equal(t, testThreatName, infected.ScanResult)
+	_, err = app.ArtifactService.GetArtifactData(ctx, infected.ID)
+	require.Error(t, err)
+	require.True(t, gerror.IsArtifactQuarantined(err))
+
+	events, err := app.EventService.FetchEvents(ctx, nil, graph.ID, 0, 1000)
+	require.NoError(t, err)
+	var quarantined []*models.Event
+	for _, event := range events {
+		if event.Type == models.ArtifactQuarantinedEvent {
+			quarantined = append(quarantined, event)
+		}
+	}
+	require.Len(t, quarantined, 1)
+	require.Equal(t, infected.ID.ResourceID, quarantined[0].ResourceID)
+	require.Equal(t, testThreatName, quarantined[0].Payload)
+}
+
+func TestArtifactScanServiceDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+	require.False(t, app.ArtifactScanService.Enabled())
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	require.NotEmpty(t, graph.Jobs)
+
+	artifact, err := app.ArtifactService.Create(ctx, graph.Jobs[0].ID, "reports", "file.txt", "", bytes.NewReader([]byte("a VIRUS")), true)
+	require.NoError(t, err)
+	require.Equal(t, models.ArtifactScanStatusNotScanned, artifact.ScanStatus)
+	reader, err := app.ArtifactService.GetArtifactData(ctx, artifact.ID)
+	require.NoError(t, err)
+	reader.Close()
+}
@@ -0,0 +1,33 @@
+package artifact_scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// ArtifactScanWorkItem is a work item that will scan the data for an artifact and quarantine it if infected.
+const ArtifactScanWorkItem models.WorkItemType = "ArtifactScan"
+
+// ArtifactScanWorkItemData is serialized to JSON and stored in the Data field of an ArtifactScanWorkItem.
+type ArtifactScanWorkItemData struct {
+	ArtifactID models.ArtifactID
+}
+
+func NewArtifactScanWorkItem(artifactID models.ArtifactID) *models.WorkItem {
+	data := &ArtifactScanWorkItemData{
+		ArtifactID: artifactID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in ArtifactScanWorkItemData definition
+		panic("Unable to marshal ArtifactScanWorkItemData object to JSON")
+	}
+
+	// Concurrency key is per artifact; different artifacts can be scanned in parallel
+	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("artifact-scan/%s", artifactID))
+
+	return models.NewWorkItem(ArtifactScanWorkItem, string(dataJson), concurrencyKey, models.NewTime(time.Now()))
+}
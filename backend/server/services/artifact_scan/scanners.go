@@ -0,0 +1,156 @@
+package artifact_scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	clamAVDialTimeout = 10 * time.Second
+	// clamAVChunkSize is the size of each chunk of data sent to clamd. This must be smaller than clamd's
+	// StreamMaxLength setting.
+	clamAVChunkSize = 64 * 1024
+	// maxErrorBodyBytes is the maximum amount of an external scanner's error response to include in an error message.
+	maxErrorBodyBytes = 512
+)
+
+// ClamAVScanner scans artifacts by streaming them to a ClamAV daemon (clamd) using the INSTREAM command.
+type ClamAVScanner struct {
+	address string
+}
+
+// NewClamAVScanner creates a scanner that connects to clamd at the specified address. The address is either
+// a host:port for a TCP socket, or a filesystem path for a Unix socket.
+func NewClamAVScanner(address string) *ClamAVScanner {
+	return &ClamAVScanner{address: address}
+}
+
+// Scan reads the data from reader and scans it. If a threat is found then infected is returned as true,
+// along with the name of the threat.
+func (s *ClamAVScanner) Scan(ctx context.Context, reader io.Reader) (infected bool, threat string, err error) {
+	network := "tcp"
+	if strings.HasPrefix(s.address, "/") {
+		network = "unix"
+	}
+	dialer := &net.Dialer{Timeout: clamAVDialTimeout}
+	conn, err := dialer.DialContext(ctx, network, s.address)
+	if err != nil {
+		return false, "", fmt.Errorf("error connecting to clamd at %s: %w", s.address, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		err = conn.SetDeadline(deadline)
+		if err != nil {
+			return false, "", fmt.Errorf("error setting clamd connection deadline: %w", err)
+		}
+	}
+
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return false, "", fmt.Errorf("error sending INSTREAM command to clamd: %w", err)
+	}
+	buf := make([]byte, clamAVChunkSize)
+	sizeBuf := make([]byte, 4)
+	for {
+		n, readErr := reader.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(sizeBuf, uint32(n))
+			_, err = conn.Write(sizeBuf)
+			if err == nil {
+				_, err = conn.Write(buf[:n])
+			}
+			if err != nil {
+				return false, "", fmt.Errorf("error sending data to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return false, "", fmt.Errorf("error reading artifact data: %w", readErr)
+		}
+	}
+	// A zero-length chunk marks the end of the stream
+	binary.BigEndian.PutUint32(sizeBuf, 0)
+	_, err = conn.Write(sizeBuf)
+	if err != nil {
+		return false, "", fmt.Errorf("error sending end of stream to clamd: %w", err)
+	}
+
+	response, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && err != io.EOF {
+		return false, "", fmt.Errorf("error reading response from clamd: %w", err)
+	}
+	return parseClamAVResponse(response)
+}
+
+// parseClamAVResponse parses the response to an INSTREAM command, which is of the form
+// "stream: OK", "stream: <threat> FOUND" or "<message> ERROR".
+func parseClamAVResponse(response string) (infected bool, threat string, err error) {
+	response = strings.TrimSpace(strings.TrimRight(response, "\x00"))
+	result := strings.TrimSpace(strings.TrimPrefix(response, "stream:"))
+	switch {
+	case result == "OK":
+		return false, "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return true, strings.TrimSpace(strings.TrimSuffix(result, " FOUND")), nil
+	default:
+		return false, "", fmt.Errorf("error clamd returned unexpected response: %q", response)
+	}
+}
+
+// HTTPScanner scans artifacts by posting them to an external scanning service over HTTP.
+// The data is sent as the request body, and the service must respond with a 200 status code and a JSON
+// body of the form {"infected": true, "threat": "<name of threat>"}.
+type HTTPScanner struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPScanner(url string) *HTTPScanner {
+	// No client timeout is set since large artifacts can take a long time to upload; the request is
+	// bounded by the context deadline instead.
+	return &HTTPScanner{url: url, client: &http.Client{}}
+}
+
+type httpScannerResponse struct {
+	Infected bool   `json:"infected"`
+	Threat   string `json:"threat"`
+}
+
+// Scan reads the data from reader and scans it. If a threat is found then infected is returned as true,
+// along with the name of the threat.
+func (s *HTTPScanner) Scan(ctx context.Context, reader io.Reader) (infected bool, threat string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, reader)
+	if err != nil {
+		return false, "", fmt.Errorf("error creating scan request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("error sending scan request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		errBody, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBodyBytes))
+		return false, "", fmt.Errorf("error scan request failed with status %d: %s", res.StatusCode, errBody)
+	}
+	result := &httpScannerResponse{}
+	err = json.NewDecoder(res.Body).Decode(result)
+	if err != nil {
+		return false, "", fmt.Errorf("error decoding scan response: %w", err)
+	}
+	if result.Infected && result.Threat == "" {
+		result.Threat = "unknown"
+	}
+	return result.Infected, result.Threat, nil
+}
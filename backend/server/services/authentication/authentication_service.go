@@ -3,6 +3,7 @@ package authentication
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -14,12 +15,20 @@ import (
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
+// lastUsedResolution is how accurately the last used time of personal access tokens is tracked.
+const lastUsedResolution = time.Minute
+
 type AuthenticationService struct {
-	db                *store.DB
-	credentialStore   store.CredentialStore
-	identityStore     store.IdentityStore
-	credentialService services.CredentialService
-	syncService       services.SyncService
+	db                        *store.DB
+	credentialStore           store.CredentialStore
+	identityStore             store.IdentityStore
+	legalEntityStore          store.LegalEntityStore
+	samlIdentityProviderStore store.SAMLIdentityProviderStore
+	credentialService         services.CredentialService
+	syncService               services.SyncService
+	legalEntityService        services.LegalEntityService
+	groupService              services.GroupService
+	samlConfig                SAMLConfig
 	logger.Log
 }
 
@@ -27,17 +36,27 @@ func NewAuthenticationService(
 	db *store.DB,
 	credentialStore store.CredentialStore,
 	identityStore store.IdentityStore,
+	legalEntityStore store.LegalEntityStore,
+	samlIdentityProviderStore store.SAMLIdentityProviderStore,
 	credentialService services.CredentialService,
 	syncService services.SyncService,
+	legalEntityService services.LegalEntityService,
+	groupService services.GroupService,
+	samlConfig SAMLConfig,
 	logFactory logger.LogFactory,
 ) *AuthenticationService {
 	return &AuthenticationService{
-		db:                db,
-		credentialStore:   credentialStore,
-		identityStore:     identityStore,
-		credentialService: credentialService,
-		syncService:       syncService,
-		Log:               logFactory("AuthenticationService"),
+		db:                        db,
+		credentialStore:           credentialStore,
+		identityStore:             identityStore,
+		legalEntityStore:          legalEntityStore,
+		samlIdentityProviderStore: samlIdentityProviderStore,
+		credentialService:         credentialService,
+		syncService:               syncService,
+		legalEntityService:        legalEntityService,
+		groupService:              groupService,
+		samlConfig:                samlConfig,
+		Log:                       logFactory("AuthenticationService"),
 	}
 }
 
@@ -70,10 +89,74 @@ func (s *AuthenticationService) AuthenticateSharedSecret(ctx context.Context, to
 	if err != nil {
 		return nil, errors.Wrap(err, "error reading identity for credential")
 	}
+	err = s.checkNotDisabled(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
 
 	return identity, nil
 }
 
+// AuthenticatePersonalAccessToken authenticates an identity using a personal access token. The token's
+// credential is returned too, so the caller can limit the request to the token's scopes.
+// Returns an unauthorized error if the token is invalid, disabled or expired.
+func (s *AuthenticationService) AuthenticatePersonalAccessToken(ctx context.Context, tokenStr string) (*models.Identity, *models.Credential, error) {
+
+	token, err := models.NewPublicSharedSecretTokenFromPersonalAccessToken(tokenStr)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing token")
+	}
+
+	cred, err := s.credentialStore.ReadByPersonalAccessTokenID(ctx, nil, token.ID())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error locating credential")
+	}
+
+	valid, err := token.IsValid(cred.SharedSecretSalt, cred.SharedSecretDataHashed)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error validating token")
+	}
+	if !valid {
+		return nil, nil, gerror.NewErrUnauthorized("Unauthorized")
+	}
+
+	if !cred.IsEnabled {
+		return nil, nil, gerror.NewErrAccountDisabled()
+	}
+
+	now := models.NewTime(time.Now().UTC())
+	if cred.IsExpired(now) {
+		return nil, nil, gerror.NewErrUnauthorized("Personal access token has expired")
+	}
+
+	identity, err := s.identityStore.Read(ctx, nil, cred.IdentityID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error reading identity for credential")
+	}
+	err = s.checkNotDisabled(ctx, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.recordPersonalAccessTokenUse(ctx, cred, now)
+
+	return identity, cred, nil
+}
+
+// recordPersonalAccessTokenUse updates the time a personal access token was last used, at most once per
+// lastUsedResolution to avoid writing to the database on every request. Failures are logged but otherwise
+// ignored since they shouldn't prevent the token from being used.
+func (s *AuthenticationService) recordPersonalAccessTokenUse(ctx context.Context, cred *models.Credential, now models.Time) {
+	if cred.LastUsedAt != nil && now.Sub(cred.LastUsedAt.Time) < lastUsedResolution {
+		return
+	}
+	cred.LastUsedAt = &now
+	err := s.credentialStore.Update(ctx, nil, cred)
+	if err != nil && !gerror.IsOptimisticLockFailed(err) {
+		s.Warnf("Error recording use of personal access token %q: %s", cred.ID, err)
+	}
+}
+
 // AuthenticateSCMAuth authenticates an identity using an SCM as the authentication provider (typically OAuth).
 // If the identity does not exist then a new legal entity and identity will automatically be created and authenticated.
 func (s *AuthenticationService) AuthenticateSCMAuth(ctx context.Context, auth models.SCMAuth) (*models.Identity, error) {
@@ -82,6 +165,10 @@ func (s *AuthenticationService) AuthenticateSCMAuth(ctx context.Context, auth mo
 	if err != nil {
 		return nil, err
 	}
+	err = s.checkNotDisabled(ctx, userIdentity)
+	if err != nil {
+		return nil, err
+	}
 
 	// Return just the bits we're interested in during authentication
 	return userIdentity, nil
@@ -110,6 +197,10 @@ func (s *AuthenticationService) AuthenticateClientCertificate(
 	if err != nil {
 		return nil, errors.Wrap(err, "error reading legal entity")
 	}
+	err = s.checkNotDisabled(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
 
 	return identity, nil
 }
@@ -127,6 +218,27 @@ func (s *AuthenticationService) AuthenticateJWT(ctx context.Context, jwt string)
 	if err != nil {
 		return nil, fmt.Errorf("error reading legal entity for identity ID specified in JWT: %w", err)
 	}
+	// JWTs aren't revoked when a legal entity is disabled, so check on every request
+	err = s.checkNotDisabled(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
 
 	return identity, nil
 }
+
+// checkNotDisabled returns an account disabled error if the identity belongs to a legal entity that has been
+// disabled. Identities belonging to other kinds of resource (e.g. runners) are never treated as disabled here.
+func (s *AuthenticationService) checkNotDisabled(ctx context.Context, identity *models.Identity) error {
+	if identity.OwnerResourceID.Kind() != models.LegalEntityResourceKind {
+		return nil
+	}
+	legalEntity, err := s.legalEntityStore.Read(ctx, nil, models.LegalEntityIDFromResourceID(identity.OwnerResourceID))
+	if err != nil {
+		return fmt.Errorf("error reading legal entity for identity: %w", err)
+	}
+	if legalEntity.IsDisabled() {
+		return gerror.NewErrAccountDisabled()
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package authentication_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestDisabledLegalEntityAuthentication(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	user, identity := server_test.CreatePersonLegalEntity(t, ctx, app, "alice", "Alice", "alice@example.com")
+	jwt, err := app.CredentialService.CreateIdentityJWT(identity.ID)
+	require.NoError(t, err)
+	token, _, err := app.CredentialService.CreatePersonalAccessToken(ctx, nil, dto.CreatePersonalAccessToken{
+		IdentityID: identity.ID,
+		Name:       "test",
+		Scopes:     models.TokenScopes{models.TokenScopeReadBuild},
+		ExpiresAt:  models.NewTime(time.Now().Add(time.Hour)),
+	})
+	require.NoError(t, err)
+
+	_, err = app.AuthenticationService.AuthenticateJWT(ctx, jwt)
+	require.NoError(t, err)
+	_, _, err = app.AuthenticationService.AuthenticatePersonalAccessToken(ctx, token)
+	require.NoError(t, err)
+
+	// Credentials issued before the user was disabled must stop working
+	user, err = app.LegalEntityService.SetDisabled(ctx, nil, user.ID, true)
+	require.NoError(t, err)
+	require.True(t, user.IsDisabled())
+	_, err = app.AuthenticationService.AuthenticateJWT(ctx, jwt)
+	require.True(t, gerror.IsAccountDisabled(err), "expected account disabled error, got: %v", err)
+	_, _, err = app.AuthenticationService.AuthenticatePersonalAccessToken(ctx, token)
+	require.True(t, gerror.IsAccountDisabled(err), "expected account disabled error, got: %v", err)
+
+	// Re-enabling the user restores access with the same credentials
+	user, err = app.LegalEntityService.SetDisabled(ctx, nil, user.ID, false)
+	require.NoError(t, err)
+	require.False(t, user.IsDisabled())
+	_, err = app.AuthenticationService.AuthenticateJWT(ctx, jwt)
+	require.NoError(t, err)
+	_, _, err = app.AuthenticationService.AuthenticatePersonalAccessToken(ctx, token)
+	require.NoError(t, err)
+}
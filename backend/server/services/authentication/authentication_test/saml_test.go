@@ -0,0 +1,248 @@
+package authentication_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/authentication"
+)
+
+const testSAMLBaseURL = "https://buildbeaver.example.com/api/v1/authentication/saml"
+
+// newTestIdentityProvider returns a SAML identity provider with a freshly generated signing key.
+func newTestIdentityProvider(t *testing.T) *saml.IdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certData, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certData)
+	require.NoError(t, err)
+	metadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.com/sso")
+	return &saml.IdentityProvider{
+		Key:         key,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+	}
+}
+
+func identityProviderMetadataXML(t *testing.T, idp *saml.IdentityProvider) string {
+	buf, err := xml.Marshal(idp.Metadata())
+	require.NoError(t, err)
+	return string(buf)
+}
+
+// makeSAMLResponse returns a base64 encoded response from idp, in response to the request with the specified ID,
+// asserting that the user described by session has logged in.
+func makeSAMLResponse(t *testing.T, ctx context.Context, app *server_test.TestServer, legalEntityID models.LegalEntityID, idp *saml.IdentityProvider, requestID string, session *saml.Session) string {
+	spMetadataXML, err := app.AuthenticationService.GetSAMLServiceProviderMetadata(ctx, legalEntityID)
+	require.NoError(t, err)
+	spMetadata := &saml.EntityDescriptor{}
+	require.NoError(t, xml.Unmarshal(spMetadataXML, spMetadata))
+
+	req := &saml.IdpAuthnRequest{
+		IDP:                     idp,
+		HTTPRequest:             httptest.NewRequest("POST", "/sso", nil),
+		Request:                 saml.AuthnRequest{ID: requestID, IssueInstant: time.Now()},
+		ServiceProviderMetadata: spMetadata,
+		SPSSODescriptor:         &spMetadata.SPSSODescriptors[0],
+		ACSEndpoint:             &spMetadata.SPSSODescriptors[0].AssertionConsumerServices[0],
+		Now:                     time.Now(),
+	}
+	require.NoError(t, saml.DefaultAssertionMaker{}.MakeAssertion(req, session))
+	form, err := req.PostBinding()
+	require.NoError(t, err)
+	return form.SAMLResponse
+}
+
+func samlSession(nameID string, email string, groups ...string) *saml.Session {
+	attribute := func(name string, values ...string) saml.Attribute {
+		attr := saml.Attribute{Name: name, NameFormat: "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"}
+		for _, value := range values {
+			attr.Values = append(attr.Values, saml.AttributeValue{Type: "xs:string", Value: value})
+		}
+		return attr
+	}
+	return &saml.Session{
+		CreateTime:   time.Now(),
+		NameID:       nameID,
+		NameIDFormat: string(saml.PersistentNameIDFormat),
+		CustomAttributes: []saml.Attribute{
+			attribute("email", email),
+			attribute("name", "Test User "+nameID),
+			attribute("groups", groups...),
+		},
+	}
+}
+
+func TestSAMLAuthentication(t *testing.T) {
+	ctx := context.Background()
+
+	config := server_test.TestConfig(t)
+	config.SAMLConfig.BaseURL = testSAMLBaseURL
+	app, cleanup, err := server_test.New(config)
+	require.NoError(t, err)
+	defer cleanup()
+
+	idp := newTestIdentityProvider(t)
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "acme", "Acme Corp", "acme@example.com")
+	adminGroup, err := app.GroupService.ReadByName(ctx, nil, company.ID, models.AdminStandardGroup.Name)
+	require.NoError(t, err)
+	userGroup, err := app.GroupService.ReadByName(ctx, nil, company.ID, models.UserStandardGroup.Name)
+	require.NoError(t, err)
+
+	enabled := true
+	metadataXML := identityProviderMetadataXML(t, idp)
+	provider, err := app.AuthenticationService.UpdateSAMLIdentityProvider(ctx, nil, company.ID, dto.UpdateSAMLIdentityProvider{
+		Enabled:     &enabled,
+		MetadataXML: &metadataXML,
+		GroupMappings: &models.SAMLGroupMappings{
+			{AttributeValue: "eng-admins", GroupName: models.AdminStandardGroup.Name},
+			{AttributeValue: "devs", GroupName: models.UserStandardGroup.Name},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.DefaultSAMLEmailAttribute, provider.EmailAttribute)
+	require.Equal(t, models.DefaultSAMLGroupsAttribute, provider.GroupsAttribute)
+
+	t.Run("AuthenticationRequest", func(t *testing.T) {
+		req, err := app.AuthenticationService.MakeSAMLAuthenticationRequest(ctx, company.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, req.RequestID)
+		redirectURL, err := url.Parse(req.RedirectURL)
+		require.NoError(t, err)
+		require.Equal(t, "https://idp.example.com/sso", redirectURL.Scheme+"://"+redirectURL.Host+redirectURL.Path)
+		require.NotEmpty(t, redirectURL.Query().Get("SAMLRequest"))
+	})
+
+	var aliceIdentity *models.Identity
+	systemName := authentication.SAMLSystemName
+
+	t.Run("JITProvisioning", func(t *testing.T) {
+		req, err := app.AuthenticationService.MakeSAMLAuthenticationRequest(ctx, company.ID)
+		require.NoError(t, err)
+		response := makeSAMLResponse(t, ctx, app, company.ID, idp, req.RequestID, samlSession("alice-id", "alice@example.com", "eng-admins", "unmapped"))
+		aliceIdentity, err = app.AuthenticationService.AuthenticateSAMLResponse(ctx, company.ID, response, req.RequestID)
+		require.NoError(t, err)
+
+		alice, err := app.LegalEntityService.ReadByIdentityID(ctx, nil, aliceIdentity.ID)
+		require.NoError(t, err)
+		require.Equal(t, models.ResourceName("alice"), alice.Name)
+		require.Equal(t, "Test User alice-id", alice.LegalName)
+		require.Equal(t, models.LegalEntityTypePerson, alice.Type)
+		require.Equal(t, "alice@example.com", alice.EmailAddress)
+
+		_, err = app.GroupService.ReadMembership(ctx, nil, adminGroup.ID, aliceIdentity.ID, authentication.SAMLSystemName)
+		require.NoError(t, err)
+		_, err = app.GroupService.ReadMembership(ctx, nil, userGroup.ID, aliceIdentity.ID, authentication.SAMLSystemName)
+		require.True(t, gerror.IsNotFound(err))
+	})
+
+	t.Run("GroupMembershipChanged", func(t *testing.T) {
+		req, err := app.AuthenticationService.MakeSAMLAuthenticationRequest(ctx, company.ID)
+		require.NoError(t, err)
+		response := makeSAMLResponse(t, ctx, app, company.ID, idp, req.RequestID, samlSession("alice-id", "alice@example.com", "devs"))
+		identity, err := app.AuthenticationService.AuthenticateSAMLResponse(ctx, company.ID, response, req.RequestID)
+		require.NoError(t, err)
+		require.Equal(t, aliceIdentity.ID, identity.ID)
+
+		_, err = app.GroupService.ReadMembership(ctx, nil, adminGroup.ID, aliceIdentity.ID, authentication.SAMLSystemName)
+		require.True(t, gerror.IsNotFound(err))
+		_, err = app.GroupService.ReadMembership(ctx, nil, userGroup.ID, aliceIdentity.ID, authentication.SAMLSystemName)
+		require.NoError(t, err)
+		memberships, _, err := app.GroupService.ListGroupMemberships(ctx, nil, nil, &aliceIdentity.ID, &systemName, models.NewPagination(models.DefaultPaginationLimit, nil))
+		require.NoError(t, err)
+		require.Len(t, memberships, 1)
+	})
+
+	t.Run("InvalidResponses", func(t *testing.T) {
+		req, err := app.AuthenticationService.MakeSAMLAuthenticationRequest(ctx, company.ID)
+		require.NoError(t, err)
+
+		// Response must be in response to our request
+		response := makeSAMLResponse(t, ctx, app, company.ID, idp, "some-other-request", samlSession("alice-id", "alice@example.com"))
+		_, err = app.AuthenticationService.AuthenticateSAMLResponse(ctx, company.ID, response, req.RequestID)
+		require.True(t, gerror.IsUnauthorized(err))
+
+		// Response must be signed by the configured identity provider
+		response = makeSAMLResponse(t, ctx, app, company.ID, newTestIdentityProvider(t), req.RequestID, samlSession("alice-id", "alice@example.com"))
+		_, err = app.AuthenticationService.AuthenticateSAMLResponse(ctx, company.ID, response, req.RequestID)
+		require.True(t, gerror.IsUnauthorized(err))
+
+		// Response must be valid base64 encoded XML
+		_, err = app.AuthenticationService.AuthenticateSAMLResponse(ctx, company.ID, "not a response", req.RequestID)
+		require.True(t, gerror.IsUnauthorized(err))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := false
+		_, err := app.AuthenticationService.UpdateSAMLIdentityProvider(ctx, nil, company.ID, dto.UpdateSAMLIdentityProvider{Enabled: &disabled})
+		require.NoError(t, err)
+		_, err = app.AuthenticationService.MakeSAMLAuthenticationRequest(ctx, company.ID)
+		require.True(t, gerror.IsNotFound(err))
+	})
+}
+
+func TestSAMLIdentityProviderValidation(t *testing.T) {
+	ctx := context.Background()
+
+	config := server_test.TestConfig(t)
+	config.SAMLConfig.BaseURL = testSAMLBaseURL
+	app, cleanup, err := server_test.New(config)
+	require.NoError(t, err)
+	defer cleanup()
+
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "acme", "Acme Corp", "acme@example.com")
+	person, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "bob", "Bob", "bob@example.com")
+	metadataXML := identityProviderMetadataXML(t, newTestIdentityProvider(t))
+
+	// Only companies can have identity providers
+	_, err = app.AuthenticationService.UpdateSAMLIdentityProvider(ctx, nil, person.ID, dto.UpdateSAMLIdentityProvider{MetadataXML: &metadataXML})
+	require.True(t, gerror.IsValidationFailed(err))
+
+	// Metadata must describe an identity provider
+	invalidMetadata := "<EntityDescriptor xmlns=\"urn:oasis:names:tc:SAML:2.0:metadata\" entityID=\"https://idp.example.com\"/>"
+	_, err = app.AuthenticationService.UpdateSAMLIdentityProvider(ctx, nil, company.ID, dto.UpdateSAMLIdentityProvider{MetadataXML: &invalidMetadata})
+	require.True(t, gerror.IsValidationFailed(err))
+
+	// Groups can only be mapped to standard groups for users
+	_, err = app.AuthenticationService.UpdateSAMLIdentityProvider(ctx, nil, company.ID, dto.UpdateSAMLIdentityProvider{
+		MetadataXML:   &metadataXML,
+		GroupMappings: &models.SAMLGroupMappings{{AttributeValue: "runners", GroupName: models.RunnerStandardGroup.Name}},
+	})
+	require.True(t, gerror.IsValidationFailed(err))
+
+	// Identity providers can't be enabled without metadata
+	enabled := true
+	_, err = app.AuthenticationService.UpdateSAMLIdentityProvider(ctx, nil, company.ID, dto.UpdateSAMLIdentityProvider{Enabled: &enabled})
+	require.True(t, gerror.IsValidationFailed(err))
+
+	_, err = app.AuthenticationService.UpdateSAMLIdentityProvider(ctx, nil, company.ID, dto.UpdateSAMLIdentityProvider{MetadataXML: &metadataXML, Enabled: &enabled})
+	require.NoError(t, err)
+	require.NoError(t, app.AuthenticationService.DeleteSAMLIdentityProvider(ctx, nil, company.ID))
+	_, err = app.AuthenticationService.ReadSAMLIdentityProvider(ctx, nil, company.ID)
+	require.True(t, gerror.IsNotFound(err))
+}
@@ -0,0 +1,464 @@
+package authentication
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// SAMLSystemName is the external system name for legal entities and group memberships that are sourced
+// from SAML identity providers.
+const SAMLSystemName = models.SystemName("saml")
+
+var invalidNameCharsRegex = regexp.MustCompile("[^a-zA-Z0-9_-]+")
+
+type SAMLConfig struct {
+	// BaseURL is the public URL of the API server's SAML routes (e.g. https://buildbeaver.example.com/api/v1/authentication/saml).
+	// Each legal entity's service provider metadata and assertion consumer service URLs are under this URL.
+	BaseURL string
+}
+
+// Enabled returns true if legal entities can configure SAML identity providers.
+func (c SAMLConfig) Enabled() bool {
+	return c.BaseURL != ""
+}
+
+// ReadSAMLIdentityProvider reads the SAML identity provider configured for a legal entity.
+// Returns a not found error if the legal entity has no identity provider.
+func (s *AuthenticationService) ReadSAMLIdentityProvider(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) (*models.SAMLIdentityProvider, error) {
+	return s.samlIdentityProviderStore.ReadByLegalEntityID(ctx, txOrNil, legalEntityID)
+}
+
+// UpdateSAMLIdentityProvider updates the SAML identity provider for a company with optimistic locking, changing
+// only the fields that are set in update. The identity provider is created if the company doesn't have one.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (s *AuthenticationService) UpdateSAMLIdentityProvider(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, update dto.UpdateSAMLIdentityProvider) (*models.SAMLIdentityProvider, error) {
+	if !s.samlConfig.Enabled() {
+		return nil, gerror.NewErrValidationFailed("SAML authentication is not enabled on this server")
+	}
+	var provider *models.SAMLIdentityProvider
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		legalEntity, err := s.legalEntityStore.Read(ctx, tx, legalEntityID)
+		if err != nil {
+			return fmt.Errorf("error reading legal entity: %w", err)
+		}
+		if legalEntity.Type != models.LegalEntityTypeCompany {
+			return gerror.NewErrValidationFailed("SAML identity providers can only be configured for companies")
+		}
+		create := false
+		provider, err = s.samlIdentityProviderStore.ReadByLegalEntityID(ctx, tx, legalEntityID)
+		if err != nil {
+			if !gerror.IsNotFound(err) {
+				return fmt.Errorf("error reading SAML identity provider: %w", err)
+			}
+			provider = models.NewSAMLIdentityProvider(models.NewTime(time.Now()), legalEntityID)
+			create = true
+		}
+		if update.Enabled != nil {
+			provider.Enabled = *update.Enabled
+		}
+		if update.MetadataXML != nil {
+			provider.MetadataXML = *update.MetadataXML
+		}
+		if update.EmailAttribute != nil {
+			provider.EmailAttribute = *update.EmailAttribute
+		}
+		if update.NameAttribute != nil {
+			provider.NameAttribute = *update.NameAttribute
+		}
+		if update.UsernameAttribute != nil {
+			provider.UsernameAttribute = *update.UsernameAttribute
+		}
+		if update.GroupsAttribute != nil {
+			provider.GroupsAttribute = *update.GroupsAttribute
+		}
+		if update.GroupMappings != nil {
+			provider.GroupMappings = *update.GroupMappings
+		}
+		err = provider.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+		if provider.MetadataXML != "" {
+			_, err = s.makeServiceProvider(provider)
+			if err != nil {
+				return gerror.NewErrValidationFailed("Invalid identity provider metadata").Wrap(err)
+			}
+		}
+		if create {
+			err = s.samlIdentityProviderStore.Create(ctx, tx, provider)
+			if err != nil {
+				return fmt.Errorf("error creating SAML identity provider: %w", err)
+			}
+			s.Infof("Created SAML identity provider %q for legal entity %q", provider.ID, legalEntityID)
+			return nil
+		}
+		provider.UpdatedAt = models.NewTime(time.Now())
+		provider.ETag = models.GetETag(provider, update.ETag)
+		err = s.samlIdentityProviderStore.Update(ctx, tx, provider)
+		if err != nil {
+			return fmt.Errorf("error updating SAML identity provider: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// DeleteSAMLIdentityProvider deletes the SAML identity provider configured for a legal entity, so that users
+// can no longer log in with it. Users and group memberships that were provisioned via the provider are kept.
+// Returns a not found error if the legal entity has no identity provider.
+func (s *AuthenticationService) DeleteSAMLIdentityProvider(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		provider, err := s.samlIdentityProviderStore.ReadByLegalEntityID(ctx, tx, legalEntityID)
+		if err != nil {
+			return err
+		}
+		err = s.samlIdentityProviderStore.Delete(ctx, tx, provider.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting SAML identity provider: %w", err)
+		}
+		s.Infof("Deleted SAML identity provider %q for legal entity %q", provider.ID, legalEntityID)
+		return nil
+	})
+}
+
+// GetSAMLServiceProviderMetadata returns the SAML metadata document describing BuildBeaver as a service provider
+// for a legal entity's identity provider, to be uploaded to the identity provider.
+// Returns a not found error if the legal entity has no identity provider.
+func (s *AuthenticationService) GetSAMLServiceProviderMetadata(ctx context.Context, legalEntityID models.LegalEntityID) ([]byte, error) {
+	_, sp, err := s.getServiceProvider(ctx, legalEntityID, false)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling service provider metadata: %w", err)
+	}
+	return buf, nil
+}
+
+// MakeSAMLAuthenticationRequest makes a request to authenticate a user with a legal entity's SAML identity provider,
+// using the HTTP-Redirect binding. Returns a not found error if the legal entity has no enabled identity provider.
+func (s *AuthenticationService) MakeSAMLAuthenticationRequest(ctx context.Context, legalEntityID models.LegalEntityID) (*dto.SAMLAuthenticationRequest, error) {
+	_, sp, err := s.getServiceProvider(ctx, legalEntityID, true)
+	if err != nil {
+		return nil, err
+	}
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, fmt.Errorf("error making SAML authentication request: %w", err)
+	}
+	redirectURL, err := req.Redirect("", sp)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding SAML authentication request: %w", err)
+	}
+	return &dto.SAMLAuthenticationRequest{
+		RedirectURL: redirectURL.String(),
+		RequestID:   req.ID,
+	}, nil
+}
+
+// AuthenticateSAMLResponse validates a response posted back from a legal entity's SAML identity provider
+// (base64 encoded, as received via the HTTP-POST binding) and ensures there is a person legal entity and
+// identity for the user the assertion describes. The user is made a member of the legal entity, and of any
+// standard groups their groups attribute values are mapped to. requestID must be the ID of the request
+// the response is in response to. Returns the user's identity.
+func (s *AuthenticationService) AuthenticateSAMLResponse(ctx context.Context, legalEntityID models.LegalEntityID, samlResponse string, requestID string) (*models.Identity, error) {
+	provider, sp, err := s.getServiceProvider(ctx, legalEntityID, true)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, gerror.NewErrUnauthorized("Invalid SAML response").Wrap(err)
+	}
+	assertion, err := sp.ParseXMLResponse(decoded, []string{requestID})
+	if err != nil {
+		var invalidErr *saml.InvalidResponseError
+		if errors.As(err, &invalidErr) {
+			err = invalidErr.PrivateErr
+		}
+		return nil, gerror.NewErrUnauthorized("Invalid SAML response").Wrap(err)
+	}
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, gerror.NewErrUnauthorized("SAML assertion has no subject")
+	}
+	user := &dto.SAMLUser{
+		NameID: assertion.Subject.NameID.Value,
+		Email:  firstAttributeValue(assertion, provider.EmailAttribute),
+		Name:   firstAttributeValue(assertion, provider.NameAttribute),
+		Groups: attributeValues(assertion, provider.GroupsAttribute),
+	}
+	if provider.UsernameAttribute != "" {
+		user.Username = firstAttributeValue(assertion, provider.UsernameAttribute)
+	}
+	identity, err := s.syncSAMLUser(ctx, provider, user)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkNotDisabled(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// syncSAMLUser ensures there is a legal entity and identity for a user who has logged in via a legal entity's
+// identity provider, that the user is a member of the legal entity, and that the user is a member of the standard
+// groups their groups are mapped to (and no other groups via the identity provider). Returns the user's identity.
+func (s *AuthenticationService) syncSAMLUser(ctx context.Context, provider *models.SAMLIdentityProvider, user *dto.SAMLUser) (*models.Identity, error) {
+	var identity *models.Identity
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		legalEntity, err := s.upsertSAMLUserLegalEntity(ctx, tx, provider, user)
+		if err != nil {
+			return err
+		}
+		identity, err = s.legalEntityService.ReadIdentity(ctx, tx, legalEntity.ID)
+		if err != nil {
+			return fmt.Errorf("error finding identity for legal entity: %w", err)
+		}
+		err = s.legalEntityService.AddCompanyMember(ctx, tx, provider.LegalEntityID, legalEntity.ID)
+		if err != nil {
+			return fmt.Errorf("error adding user %q to company: %w", legalEntity.Name, err)
+		}
+		return s.syncSAMLUserGroups(ctx, tx, provider, legalEntity, identity, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Infof("Synced SAML user %q (identity %s) for legal entity %q with %d groups", user.NameID, identity.ID, provider.LegalEntityID, len(user.Groups))
+	return identity, nil
+}
+
+// upsertSAMLUserLegalEntity creates or updates the person legal entity for a SAML user. Name IDs are only unique
+// within an identity provider, so each identity provider's users are given their own legal entities.
+// Existing legal entities keep their name so that links to them remain stable.
+func (s *AuthenticationService) upsertSAMLUserLegalEntity(ctx context.Context, tx *store.Tx, provider *models.SAMLIdentityProvider, user *dto.SAMLUser) (*models.LegalEntity, error) {
+	externalID := models.NewExternalResourceID(SAMLSystemName, fmt.Sprintf("%s/%s", provider.LegalEntityID, user.NameID))
+	var name models.ResourceName
+	existing, err := s.legalEntityStore.ReadByExternalID(ctx, tx, externalID)
+	if err == nil {
+		name = existing.Name
+	} else if gerror.IsNotFound(err) {
+		name, err = s.chooseSAMLUserName(ctx, tx, externalID, user)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("error reading legal entity for SAML user: %w", err)
+	}
+	legalName := user.Name
+	if legalName == "" {
+		legalName = name.String()
+	}
+	legalEntityData := models.NewPersonLegalEntityData(name, legalName, user.Email, &externalID, "")
+	legalEntity, _, _, err := s.legalEntityService.Upsert(ctx, tx, legalEntityData)
+	if err != nil {
+		return nil, fmt.Errorf("error upserting legal entity for SAML user: %w", err)
+	}
+	return legalEntity, nil
+}
+
+// chooseSAMLUserName returns a name for a new legal entity for a SAML user that is not already in use. Names are
+// based on the user's username, or on their email address if the identity provider doesn't supply usernames.
+func (s *AuthenticationService) chooseSAMLUserName(ctx context.Context, tx *store.Tx, externalID models.ExternalResourceID, user *dto.SAMLUser) (models.ResourceName, error) {
+	base := user.Username
+	if base == "" && user.Email != "" {
+		base = strings.SplitN(user.Email, "@", 2)[0]
+	}
+	base = strings.Trim(invalidNameCharsRegex.ReplaceAllString(base, "-"), "-")
+	hash := sha256.Sum256([]byte(externalID.String()))
+	suffix := hex.EncodeToString(hash[:])[:8]
+	if base == "" {
+		return models.ResourceName("user-" + suffix), nil
+	}
+	if len(base) > 90 {
+		base = base[:90]
+	}
+	_, err := s.legalEntityStore.ReadByName(ctx, tx, models.ResourceName(base))
+	if gerror.IsNotFound(err) {
+		return models.ResourceName(base), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error checking whether legal entity name is in use: %w", err)
+	}
+	return models.ResourceName(base + "-" + suffix), nil
+}
+
+// syncSAMLUserGroups adds the user to the standard groups their groups are mapped to, and removes any memberships
+// previously added via the identity provider for mapped groups they are no longer mapped to.
+func (s *AuthenticationService) syncSAMLUserGroups(
+	ctx context.Context,
+	tx *store.Tx,
+	provider *models.SAMLIdentityProvider,
+	user *models.LegalEntity,
+	identity *models.Identity,
+	samlUser *dto.SAMLUser,
+) error {
+	userGroups := make(map[string]bool, len(samlUser.Groups))
+	for _, group := range samlUser.Groups {
+		userGroups[group] = true
+	}
+	// Several attribute values can map to the same group
+	isMember := make(map[models.ResourceName]bool)
+	for _, mapping := range provider.GroupMappings {
+		isMember[mapping.GroupName] = isMember[mapping.GroupName] || userGroups[mapping.AttributeValue]
+	}
+
+	systemName := SAMLSystemName
+	for groupName, member := range isMember {
+		group, err := s.groupService.ReadByName(ctx, tx, provider.LegalEntityID, groupName)
+		if err != nil {
+			return fmt.Errorf("error reading group %q: %w", groupName, err)
+		}
+		if member {
+			_, created, err := s.groupService.FindOrCreateMembership(ctx, tx, models.NewGroupMembershipData(
+				group.ID, identity.ID, SAMLSystemName, provider.LegalEntityID))
+			if err != nil {
+				return fmt.Errorf("error adding user %q to group %q: %w", user.Name, group.Name, err)
+			}
+			if created {
+				s.Infof("Added SAML user %q to group %q for legal entity %q", user.Name, group.Name, provider.LegalEntityID)
+			}
+		} else {
+			err = s.groupService.RemoveMembership(ctx, tx, group.ID, identity.ID, &systemName)
+			if err != nil {
+				return fmt.Errorf("error removing user %q from group %q: %w", user.Name, group.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getServiceProvider reads a legal entity's identity provider and returns it along with the service provider
+// used to communicate with it. If mustBeEnabled is true then a not found error is returned if the identity
+// provider is disabled.
+func (s *AuthenticationService) getServiceProvider(ctx context.Context, legalEntityID models.LegalEntityID, mustBeEnabled bool) (*models.SAMLIdentityProvider, *saml.ServiceProvider, error) {
+	if !s.samlConfig.Enabled() {
+		return nil, nil, gerror.NewErrNotFound("SAML authentication is not enabled on this server")
+	}
+	provider, err := s.samlIdentityProviderStore.ReadByLegalEntityID(ctx, nil, legalEntityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mustBeEnabled && !provider.Enabled {
+		return nil, nil, gerror.NewErrNotFound("SAML identity provider is not enabled")
+	}
+	sp, err := s.makeServiceProvider(provider)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error configuring SAML service provider: %w", err)
+	}
+	return provider, sp, nil
+}
+
+// makeServiceProvider returns the service provider used to communicate with an identity provider. Authentication
+// requests are not signed and encrypted assertions are not supported, so no service provider key is required.
+func (s *AuthenticationService) makeServiceProvider(provider *models.SAMLIdentityProvider) (*saml.ServiceProvider, error) {
+	sp := &saml.ServiceProvider{
+		AuthnNameIDFormat: saml.PersistentNameIDFormat,
+	}
+	if provider.MetadataXML != "" {
+		idpMetadata, err := parseIdentityProviderMetadata([]byte(provider.MetadataXML))
+		if err != nil {
+			return nil, err
+		}
+		sp.IDPMetadata = idpMetadata
+		if sp.GetSSOBindingLocation(saml.HTTPRedirectBinding) == "" {
+			return nil, fmt.Errorf("error identity provider does not support the HTTP-Redirect binding")
+		}
+	}
+	baseURL := fmt.Sprintf("%s/%s", strings.TrimSuffix(s.samlConfig.BaseURL, "/"), provider.LegalEntityID)
+	metadataURL, err := url.Parse(baseURL + "/metadata")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing service provider metadata URL: %w", err)
+	}
+	acsURL, err := url.Parse(baseURL + "/acs")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing assertion consumer service URL: %w", err)
+	}
+	sp.EntityID = metadataURL.String()
+	sp.MetadataURL = *metadataURL
+	sp.AcsURL = *acsURL
+	return sp, nil
+}
+
+// parseIdentityProviderMetadata parses an identity provider's metadata, which may be a single entity
+// descriptor or a set of entity descriptors containing the identity provider.
+func parseIdentityProviderMetadata(metadataXML []byte) (*saml.EntityDescriptor, error) {
+	entity := &saml.EntityDescriptor{}
+	err := xml.Unmarshal(metadataXML, entity)
+	if err != nil {
+		entities := &saml.EntitiesDescriptor{}
+		if xml.Unmarshal(metadataXML, entities) != nil {
+			return nil, fmt.Errorf("error parsing metadata: %w", err)
+		}
+		entity = nil
+		for i := range entities.EntityDescriptors {
+			if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+				entity = &entities.EntityDescriptors[i]
+				break
+			}
+		}
+	}
+	if entity == nil || len(entity.IDPSSODescriptors) == 0 {
+		return nil, fmt.Errorf("error metadata does not describe an identity provider")
+	}
+	if entity.EntityID == "" {
+		return nil, fmt.Errorf("error metadata has no entity ID")
+	}
+	for _, descriptor := range entity.IDPSSODescriptors {
+		for _, key := range descriptor.KeyDescriptors {
+			if (key.Use == "" || key.Use == "signing") && len(key.KeyInfo.X509Data.X509Certificates) > 0 {
+				return entity, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("error metadata has no signing certificate")
+}
+
+// attributeValues returns the values of an assertion attribute, matching the attribute's name or friendly name.
+func attributeValues(assertion *saml.Assertion, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var values []string
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if attribute.Name != name && attribute.FriendlyName != name {
+				continue
+			}
+			for _, value := range attribute.Values {
+				if value.Value != "" {
+					values = append(values, value.Value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+func firstAttributeValue(assertion *saml.Assertion, name string) string {
+	values := attributeValues(assertion, name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
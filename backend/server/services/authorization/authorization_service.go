@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/store"
@@ -41,6 +42,12 @@ func (s *AuthorizationService) IsAuthorized(
 	operation *models.Operation,
 	resourceID models.ResourceID) (bool, error) {
 
+	// The anonymous identity can only ever read public resources, whatever grants exist for it
+	if identityID.Equal(models.AnonymousIdentityID.ResourceID) && !isPublicReadOperation(operation) {
+		s.Warnf("DENIED anonymous to '%s:%s' on '%s'", operation.ResourceKind, operation.Name, resourceID)
+		return false, nil
+	}
+
 	count, err := s.authorizationStore.CountGrantsForOperation(
 		ctx,
 		nil,
@@ -60,6 +67,30 @@ func (s *AuthorizationService) IsAuthorized(
 	return false, nil
 }
 
+// ListEffectivePermissions returns each of the operations the identity is authorized to perform on the resource,
+// taking into account every grant that applies to the identity, including denied permissions.
+func (s *AuthorizationService) ListEffectivePermissions(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	resourceID models.ResourceID,
+) ([]*models.Operation, error) {
+	var operations []*models.Operation
+	for _, operation := range models.AllOperations {
+		if identityID.Equal(models.AnonymousIdentityID.ResourceID) && !isPublicReadOperation(operation) {
+			continue
+		}
+		count, err := s.authorizationStore.CountGrantsForOperation(ctx, txOrNil, identityID, operation, resourceID)
+		if err != nil {
+			return nil, errors.Wrap(err, "error counting grants")
+		}
+		if count > 0 {
+			operations = append(operations, operation)
+		}
+	}
+	return operations, nil
+}
+
 // CreateGrantsForIdentity grants the specified identity a set of permissions.
 // For each operation in the supplied list, the identity will be allowed to perform the
 // specified operation on the specified resource or on any resource it owns (directly or indirectly),
@@ -141,6 +172,58 @@ func (s *AuthorizationService) createGrants(
 	})
 }
 
+// SetPublicReadAccess grants the anonymous identity permission to perform each of models.PublicReadOperations
+// on the specified resource or on any resource it owns if public is true, or removes those grants if public
+// is false. This allows requests that are not authenticated to read the resource.
+func (s *AuthorizationService) SetPublicReadAccess(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	grantedByLegalEntityID models.LegalEntityID,
+	resourceID models.ResourceID,
+	public bool,
+) error {
+	if public {
+		return s.CreateGrantsForIdentity(ctx, txOrNil, grantedByLegalEntityID, models.AnonymousIdentityID, models.PublicReadOperations, resourceID)
+	}
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		for _, operation := range models.PublicReadOperations {
+			grantData := models.NewIdentityGrant(
+				models.NewTime(time.Now().UTC()),
+				grantedByLegalEntityID,
+				models.AnonymousIdentityID,
+				*operation,
+				resourceID)
+			grant, err := s.grantStore.ReadByAuthorizedOperation(ctx, tx, grantData)
+			if err != nil {
+				if gerror.IsNotFound(err) {
+					continue
+				}
+				return errors.Wrap(err, "error reading grant")
+			}
+			err = s.ownershipStore.Delete(ctx, tx, grant.GetID())
+			if err != nil {
+				return errors.Wrap(err, "error deleting ownership")
+			}
+			err = s.grantStore.Delete(ctx, tx, grant.ID)
+			if err != nil {
+				return errors.Wrap(err, "error deleting grant")
+			}
+			s.Infof("Deleted grant for anonymous to perform %s on %s", grant.GetOperation(), resourceID)
+		}
+		return nil
+	})
+}
+
+// isPublicReadOperation returns true if operation is one of models.PublicReadOperations.
+func isPublicReadOperation(operation *models.Operation) bool {
+	for _, public := range models.PublicReadOperations {
+		if public.Name == operation.Name && public.ResourceKind == operation.ResourceKind {
+			return true
+		}
+	}
+	return false
+}
+
 // DeleteGrant permanently and idempotently deletes a grant, identifying it by id.
 func (s *AuthorizationService) DeleteGrant(ctx context.Context, txOrNil *store.Tx, id models.GrantID) error {
 	return s.grantStore.Delete(ctx, txOrNil, id)
@@ -171,3 +254,14 @@ func (s *AuthorizationService) ListGrantsForGroup(
 ) ([]*models.Grant, *models.Cursor, error) {
 	return s.grantStore.ListGrantsForGroup(ctx, txOrNil, groupID, pagination)
 }
+
+// ListGrantsForIdentity finds and returns all grants that give permissions directly to the specified identity.
+// Grants the identity has through the groups it is a member of are not included.
+func (s *AuthorizationService) ListGrantsForIdentity(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	pagination models.Pagination,
+) ([]*models.Grant, *models.Cursor, error) {
+	return s.grantStore.ListGrantsForIdentity(ctx, txOrNil, identityID, pagination)
+}
@@ -29,6 +29,15 @@ func (s *NoOpAuthorizationService) IsAuthorized(
 	return true, nil
 }
 
+func (s *NoOpAuthorizationService) ListEffectivePermissions(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	resourceID models.ResourceID,
+) ([]*models.Operation, error) {
+	return models.AllOperations, nil
+}
+
 func (s *NoOpAuthorizationService) CreateGrantsForIdentity(
 	ctx context.Context,
 	txOrNil *store.Tx,
@@ -51,6 +60,16 @@ func (s *NoOpAuthorizationService) CreateGrantsForGroup(
 	return nil
 }
 
+func (s *NoOpAuthorizationService) SetPublicReadAccess(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	grantedByLegalEntityID models.LegalEntityID,
+	resourceID models.ResourceID,
+	public bool,
+) error {
+	return nil
+}
+
 // DeleteGrant permanently and idempotently deletes a grant, identifying it by id.
 func (s *NoOpAuthorizationService) DeleteGrant(ctx context.Context, txOrNil *store.Tx, id models.GrantID) error {
 	return nil
@@ -81,3 +100,13 @@ func (s *NoOpAuthorizationService) ListGrantsForGroup(
 ) ([]*models.Grant, *models.Cursor, error) {
 	return []*models.Grant{}, nil, nil
 }
+
+// ListGrantsForIdentity finds and returns all grants that give permissions directly to the specified identity.
+func (s *NoOpAuthorizationService) ListGrantsForIdentity(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	pagination models.Pagination,
+) ([]*models.Grant, *models.Cursor, error) {
+	return []*models.Grant{}, nil, nil
+}
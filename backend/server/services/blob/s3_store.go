@@ -125,7 +125,7 @@ func (s *S3BlobStore) GetBlobRange(ctx context.Context, key string, offset, leng
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.config.BucketName),
 		Key:    aws.String(key),
-		Range:  aws.String(fmt.Sprintf("%d-%d", offset, offset+length-1)),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
 	}
 	output, err := s.s3.GetObjectWithContext(ctx, input)
 	if err != nil {
@@ -110,6 +110,12 @@ func (s *BuildService) Read(ctx context.Context, txOrNil *store.Tx, id models.Bu
 	return s.buildStore.Read(ctx, txOrNil, id)
 }
 
+// ListByIDs reads the builds with the specified IDs, in no particular order. IDs of builds that do not
+// exist are ignored.
+func (s *BuildService) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.BuildID) ([]*models.Build, error) {
+	return s.buildStore.ListByIDs(ctx, txOrNil, ids)
+}
+
 // ReadByIdentityID looks up the build that corresponds to the specified identity, or returns a not found error
 // if the identity doesn't correspond to a build.
 func (s *BuildService) ReadByIdentityID(ctx context.Context, txOrNil *store.Tx, identityID models.IdentityID) (*models.Build, error) {
@@ -157,6 +163,10 @@ func (s *BuildService) FindOrCreateIdentity(ctx context.Context, txOrNil *store.
 				models.JobReadOperation,
 				models.ArtifactReadOperation,
 				models.JobCreateOperation,
+				models.JobUpdateOperation,
+				models.CustomStatusCreateOperation,
+				models.ToolchainCreateOperation,
+				models.JobIssueOIDCTokenOperation,
 			},
 			buildID.ResourceID,
 		)
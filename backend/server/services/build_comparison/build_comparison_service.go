@@ -0,0 +1,202 @@
+package build_comparison
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// BuildComparisonService works out what changed between two builds of the same repo, to help investigate
+// regressions in build time, artifact sizes and test results.
+type BuildComparisonService struct {
+	buildStore    store.BuildStore
+	jobStore      store.JobStore
+	artifactStore store.ArtifactStore
+	testCaseStore store.TestCaseStore
+	logger.Log
+}
+
+func NewBuildComparisonService(
+	buildStore store.BuildStore,
+	jobStore store.JobStore,
+	artifactStore store.ArtifactStore,
+	testCaseStore store.TestCaseStore,
+	logFactory logger.LogFactory,
+) *BuildComparisonService {
+	return &BuildComparisonService{
+		buildStore:    buildStore,
+		jobStore:      jobStore,
+		artifactStore: artifactStore,
+		testCaseStore: testCaseStore,
+		Log:           logFactory("BuildComparisonService"),
+	}
+}
+
+// Compare works out what changed from the base build to the head build. Both builds must be in the same repo.
+func (s *BuildComparisonService) Compare(ctx context.Context, txOrNil *store.Tx, baseBuildID models.BuildID, headBuildID models.BuildID) (*dto.BuildComparison, error) {
+	base, err := s.buildStore.Read(ctx, txOrNil, baseBuildID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading base build: %w", err)
+	}
+	head, err := s.buildStore.Read(ctx, txOrNil, headBuildID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading head build: %w", err)
+	}
+	if base.RepoID != head.RepoID {
+		return nil, gerror.NewErrValidationFailed("Builds can only be compared with other builds of the same repo")
+	}
+	baseJobs, err := s.jobStore.ListByBuildID(ctx, txOrNil, base.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs in base build: %w", err)
+	}
+	headJobs, err := s.jobStore.ListByBuildID(ctx, txOrNil, head.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs in head build: %w", err)
+	}
+	baseArtifacts, err := s.listArtifacts(ctx, txOrNil, baseJobs)
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts in base build: %w", err)
+	}
+	headArtifacts, err := s.listArtifacts(ctx, txOrNil, headJobs)
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts in head build: %w", err)
+	}
+	baseFailures, err := s.testCaseStore.ListFailedByBuildID(ctx, txOrNil, base.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing test failures in base build: %w", err)
+	}
+	headFailures, err := s.testCaseStore.ListFailedByBuildID(ctx, txOrNil, head.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing test failures in head build: %w", err)
+	}
+	return &dto.BuildComparison{
+		Base:                 base,
+		Head:                 head,
+		Jobs:                 compareJobs(baseJobs, headJobs),
+		Artifacts:            compareArtifacts(baseJobs, baseArtifacts, headJobs, headArtifacts),
+		NewTestFailures:      subtractTestFailures(headFailures, baseFailures),
+		ResolvedTestFailures: subtractTestFailures(baseFailures, headFailures),
+	}, nil
+}
+
+func (s *BuildComparisonService) listArtifacts(ctx context.Context, txOrNil *store.Tx, jobs []*models.Job) ([]*models.Artifact, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	jobIDs := make([]models.JobID, len(jobs))
+	for i, job := range jobs {
+		jobIDs[i] = job.ID
+	}
+	return s.artifactStore.ListByJobIDs(ctx, txOrNil, jobIDs)
+}
+
+// compareJobs matches up the jobs in two builds by FQN, ordered by FQN.
+func compareJobs(baseJobs []*models.Job, headJobs []*models.Job) []*dto.JobComparison {
+	byFQN := make(map[models.NodeFQN]*dto.JobComparison)
+	get := func(fqn models.NodeFQN) *dto.JobComparison {
+		comparison, ok := byFQN[fqn]
+		if !ok {
+			comparison = &dto.JobComparison{FQN: fqn}
+			byFQN[fqn] = comparison
+		}
+		return comparison
+	}
+	for _, job := range baseJobs {
+		get(job.GetFQN()).Base = job
+	}
+	for _, job := range headJobs {
+		get(job.GetFQN()).Head = job
+	}
+	comparisons := make([]*dto.JobComparison, 0, len(byFQN))
+	for _, comparison := range byFQN {
+		comparisons = append(comparisons, comparison)
+	}
+	sort.Slice(comparisons, func(i, j int) bool {
+		return comparisons[i].FQN.String() < comparisons[j].FQN.String()
+	})
+	return comparisons
+}
+
+// compareArtifacts matches up the artifacts in two builds by job FQN, group and path, and returns the
+// artifacts that were added, removed or changed size, ordered by job FQN, group and path.
+func compareArtifacts(baseJobs []*models.Job, baseArtifacts []*models.Artifact, headJobs []*models.Job, headArtifacts []*models.Artifact) []*dto.ArtifactComparison {
+	type artifactKey struct {
+		jobFQN    string
+		groupName models.ResourceName
+		path      string
+	}
+	byKey := make(map[artifactKey]*dto.ArtifactComparison)
+	get := func(jobsByID map[models.JobID]*models.Job, artifact *models.Artifact) *dto.ArtifactComparison {
+		jobFQN := jobsByID[artifact.JobID].GetFQN()
+		key := artifactKey{jobFQN: jobFQN.String(), groupName: artifact.GroupName, path: artifact.Path}
+		comparison, ok := byKey[key]
+		if !ok {
+			comparison = &dto.ArtifactComparison{JobFQN: jobFQN, GroupName: artifact.GroupName, Path: artifact.Path}
+			byKey[key] = comparison
+		}
+		return comparison
+	}
+	baseJobsByID := makeJobsByID(baseJobs)
+	for _, artifact := range baseArtifacts {
+		get(baseJobsByID, artifact).Base = artifact
+	}
+	headJobsByID := makeJobsByID(headJobs)
+	for _, artifact := range headArtifacts {
+		get(headJobsByID, artifact).Head = artifact
+	}
+	var comparisons []*dto.ArtifactComparison
+	for _, comparison := range byKey {
+		if comparison.Base != nil && comparison.Head != nil && comparison.Base.Size == comparison.Head.Size {
+			continue
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	sort.Slice(comparisons, func(i, j int) bool {
+		a, b := comparisons[i], comparisons[j]
+		if a.JobFQN.String() != b.JobFQN.String() {
+			return a.JobFQN.String() < b.JobFQN.String()
+		}
+		if a.GroupName != b.GroupName {
+			return a.GroupName < b.GroupName
+		}
+		return a.Path < b.Path
+	})
+	return comparisons
+}
+
+func makeJobsByID(jobs []*models.Job) map[models.JobID]*models.Job {
+	jobsByID := make(map[models.JobID]*models.Job, len(jobs))
+	for _, job := range jobs {
+		jobsByID[job.ID] = job
+	}
+	return jobsByID
+}
+
+// subtractTestFailures returns the test failures in from for tests that didn't fail in subtract, with one
+// failure per test.
+func subtractTestFailures(from []*models.TestCase, subtract []*models.TestCase) []*models.TestCase {
+	type testKey struct {
+		suite string
+		name  string
+	}
+	seen := make(map[testKey]bool, len(subtract))
+	for _, testCase := range subtract {
+		seen[testKey{suite: testCase.Suite, name: testCase.Name}] = true
+	}
+	result := []*models.TestCase{}
+	for _, testCase := range from {
+		key := testKey{suite: testCase.Suite, name: testCase.Name}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, testCase)
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package build_comparison_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testIngestTimeout = 30 * time.Second
+
+const baseReport = `<testsuite name="math">
+  <testcase classname="math" name="TestAdd" time="0.1"/>
+  <testcase classname="math" name="TestDivide" time="0.2"><failure message="expected 2"/></testcase>
+</testsuite>`
+
+const headReport = `<testsuite name="math">
+  <testcase classname="math" name="TestAdd" time="0.1"><failure message="expected 3"/></testcase>
+  <testcase classname="math" name="TestDivide" time="0.2"/>
+</testsuite>`
+
+const extraJobYAML = `
+version: 0.3
+jobs:
+  - name: lint
+    workflow: extra
+    type: exec
+    steps:
+      - name: lint
+        commands:
+          - echo lint
+`
+
+func TestBuildComparisonService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	baseBuild, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	headBuild, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	_, _, err = app.QueueService.AddConfigToBuild(ctx, nil, headBuild.ID, []byte(extraJobYAML), models.ConfigTypeYAML)
+	require.NoError(t, err)
+
+	// The head build's copy of the first job ran for longer, and was built from different inputs
+	hashType := models.HashTypeBlake2b
+	started := models.NewTime(time.Now().UTC().Truncate(time.Second))
+	finish := func(jobID models.JobID, duration time.Duration, fingerprint string) {
+		job, err := app.JobStore.Read(ctx, nil, jobID)
+		require.NoError(t, err)
+		finished := models.NewTime(started.Add(duration))
+		job.Timings.RunningAt = &started
+		job.Timings.FinishedAt = &finished
+		job.Fingerprint = fingerprint
+		job.FingerprintHashType = &hashType
+		err = app.JobStore.Update(ctx, nil, job)
+		require.NoError(t, err)
+	}
+	baseJob := baseBuild.Jobs[0]
+	headJob := headBuild.Jobs[0]
+	for _, job := range headBuild.Jobs {
+		if job.GetFQN() == baseJob.GetFQN() {
+			headJob = job
+		}
+	}
+	finish(baseJob.ID, 2*time.Second, "base-fingerprint")
+	finish(headJob.ID, 5*time.Second, "head-fingerprint")
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+	waitForRun := func(buildID models.BuildID) {
+		deadline := time.Now().Add(testIngestTimeout)
+		for time.Now().Before(deadline) {
+			_, err := app.TestResultService.ReadSummary(ctx, nil, buildID)
+			if err == nil {
+				return
+			}
+			require.True(t, gerror.IsNotFound(err))
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for test results to be ingested for build %q", buildID)
+	}
+
+	// The binary grew, one artifact is only in the base build, and the test results swap which test fails
+	_, err = app.ArtifactService.Create(ctx, baseJob.ID, "binaries", "bin/app", "", bytes.NewReader([]byte("binary")), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, baseJob.ID, "binaries", "bin/old", "", bytes.NewReader([]byte("old")), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, headJob.ID, "binaries", "bin/app", "", bytes.NewReader([]byte("bigger binary")), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, baseJob.ID, models.TestResultsArtifactGroupName, "junit.xml", "", bytes.NewReader([]byte(baseReport)), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, headJob.ID, models.TestResultsArtifactGroupName, "junit.xml", "", bytes.NewReader([]byte(headReport)), true)
+	require.NoError(t, err)
+	waitForRun(baseBuild.ID)
+	waitForRun(headBuild.ID)
+
+	comparison, err := app.BuildComparisonService.Compare(ctx, nil, baseBuild.ID, headBuild.ID)
+	require.NoError(t, err)
+	require.Equal(t, baseBuild.ID, comparison.Base.ID)
+	require.Equal(t, headBuild.ID, comparison.Head.ID)
+
+	jobs := make(map[models.NodeFQN]bool)
+	for _, job := range comparison.Jobs {
+		jobs[job.FQN] = true
+		switch job.FQN {
+		case baseJob.GetFQN():
+			require.Equal(t, baseJob.ID, job.Base.ID)
+			require.Equal(t, headJob.ID, job.Head.ID)
+			require.True(t, job.FingerprintChanged())
+		case models.NewNodeFQNForJob("extra", "lint"):
+			require.Nil(t, job.Base)
+			require.NotNil(t, job.Head)
+			require.False(t, job.FingerprintChanged())
+		default:
+			require.NotNil(t, job.Base, job.FQN.String())
+			require.NotNil(t, job.Head, job.FQN.String())
+		}
+	}
+	require.True(t, jobs[baseJob.GetFQN()])
+	require.True(t, jobs[models.NewNodeFQNForJob("extra", "lint")])
+
+	// Only artifacts that were added, removed or changed size are included, so the test results are left out
+	require.Len(t, comparison.Artifacts, 2)
+	artifacts := make(map[string]bool)
+	for _, artifact := range comparison.Artifacts {
+		artifacts[artifact.Path] = true
+		require.Equal(t, baseJob.GetFQN(), artifact.JobFQN)
+		switch artifact.Path {
+		case "bin/app":
+			require.Equal(t, uint64(6), artifact.Base.Size)
+			require.Equal(t, uint64(13), artifact.Head.Size)
+		case "bin/old":
+			require.NotNil(t, artifact.Base)
+			require.Nil(t, artifact.Head)
+		}
+	}
+	require.True(t, artifacts["bin/app"])
+	require.True(t, artifacts["bin/old"])
+	require.False(t, artifacts["junit.xml"])
+
+	require.Len(t, comparison.NewTestFailures, 1)
+	require.Equal(t, "TestAdd", comparison.NewTestFailures[0].Name)
+	require.Len(t, comparison.ResolvedTestFailures, 1)
+	require.Equal(t, "TestDivide", comparison.ResolvedTestFailures[0].Name)
+
+	// Builds can only be compared with builds of the same repo
+	otherRepo := server_test.CreateNamedRepo(t, ctx, app, "other", legalEntity.ID)
+	otherCommit := server_test.CreateCommit(t, ctx, app, otherRepo.ID, legalEntity.ID)
+	otherBuild, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, otherCommit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	_, err = app.BuildComparisonService.Compare(ctx, nil, otherBuild.ID, headBuild.ID)
+	require.True(t, gerror.IsValidationFailed(err))
+}
@@ -0,0 +1,157 @@
+package build_rule_set
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// BuildRuleSetService manages the rules that decide which pushes and pull requests for a repo trigger builds.
+type BuildRuleSetService struct {
+	db                *store.DB
+	buildRuleSetStore store.BuildRuleSetStore
+	logger.Log
+}
+
+func NewBuildRuleSetService(
+	db *store.DB,
+	buildRuleSetStore store.BuildRuleSetStore,
+	logFactory logger.LogFactory,
+) *BuildRuleSetService {
+	return &BuildRuleSetService{
+		db:                db,
+		buildRuleSetStore: buildRuleSetStore,
+		Log:               logFactory("BuildRuleSetService"),
+	}
+}
+
+// Read reads the build rule set for a repo. If the repo has no rule set then a default rule set is
+// returned, which builds every branch and tag.
+func (s *BuildRuleSetService) Read(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) (*models.BuildRuleSet, error) {
+	ruleSet, err := s.buildRuleSetStore.ReadByRepoID(ctx, txOrNil, repoID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return s.makeDefaultRuleSet(repoID), nil
+		}
+		return nil, err
+	}
+	return ruleSet, nil
+}
+
+// Update updates a repo's build rule set with optimistic locking, changing only the fields that are set
+// in update. The rule set is created if the repo doesn't already have one.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (s *BuildRuleSetService) Update(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, update dto.UpdateBuildRuleSet) (*models.BuildRuleSet, error) {
+	var ruleSet *models.BuildRuleSet
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		var (
+			err    error
+			create bool
+		)
+		ruleSet, err = s.buildRuleSetStore.ReadByRepoID(ctx, tx, repoID)
+		if err != nil {
+			if !gerror.IsNotFound(err) {
+				return fmt.Errorf("error reading build rule set: %w", err)
+			}
+			ruleSet = s.makeDefaultRuleSet(repoID)
+			create = true
+		}
+		if update.AllowRefs != nil {
+			ruleSet.AllowRefs = *update.AllowRefs
+		}
+		if update.DenyRefs != nil {
+			ruleSet.DenyRefs = *update.DenyRefs
+		}
+		if update.BuildTags != nil {
+			ruleSet.BuildTags = *update.BuildTags
+		}
+		if update.PullRequestsOnly != nil {
+			ruleSet.PullRequestsOnly = *update.PullRequestsOnly
+		}
+		if update.SkipPattern != nil {
+			ruleSet.SkipPattern = *update.SkipPattern
+		}
+		if update.CommentCommands != nil {
+			ruleSet.CommentCommands = *update.CommentCommands
+		}
+		err = ruleSet.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+		if create {
+			err = s.buildRuleSetStore.Create(ctx, tx, ruleSet)
+			if err != nil {
+				return fmt.Errorf("error creating build rule set: %w", err)
+			}
+			s.Infof("Created build rule set %q for repo %q", ruleSet.ID, repoID)
+			return nil
+		}
+		ruleSet.UpdatedAt = models.NewTime(time.Now())
+		ruleSet.ETag = models.GetETag(ruleSet, update.ETag)
+		err = s.buildRuleSetStore.Update(ctx, tx, ruleSet)
+		if err != nil {
+			return fmt.Errorf("error updating build rule set: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ruleSet, nil
+}
+
+// Delete permanently and idempotently deletes a repo's build rule set, reverting to the default rules.
+func (s *BuildRuleSetService) Delete(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		ruleSet, err := s.buildRuleSetStore.ReadByRepoID(ctx, tx, repoID)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("error reading build rule set: %w", err)
+		}
+		err = s.buildRuleSetStore.Delete(ctx, tx, ruleSet.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting build rule set: %w", err)
+		}
+		return nil
+	})
+}
+
+// ShouldBuild returns true if the repo's build rules allow a push of the specified ref to be built.
+// pullRequest is true if the push is part of a pull request.
+func (s *BuildRuleSetService) ShouldBuild(ctx context.Context, txOrNil *store.Tx, repo *models.Repo, ref string, pullRequest bool) (bool, error) {
+	ruleSet, err := s.Read(ctx, txOrNil, repo.ID)
+	if err != nil {
+		return false, fmt.Errorf("error reading build rule set: %w", err)
+	}
+	build, reason := ruleSet.ShouldBuild(ref, repo.DefaultBranch, pullRequest)
+	if !build {
+		s.Infof("Build rules for repo %q do not allow ref %q to be built: %s", repo.ID, ref, reason)
+	}
+	return build, nil
+}
+
+// SkipCommit returns true if the repo's build rules say that the specified commit should not be built,
+// based on the commit message.
+func (s *BuildRuleSetService) SkipCommit(ctx context.Context, txOrNil *store.Tx, commit *models.Commit) (bool, error) {
+	ruleSet, err := s.Read(ctx, txOrNil, commit.RepoID)
+	if err != nil {
+		return false, fmt.Errorf("error reading build rule set: %w", err)
+	}
+	skip, reason := ruleSet.SkipCommit(commit.Message)
+	if skip {
+		s.Infof("Build rules for repo %q skip commit %q: %s", commit.RepoID, commit.SHA, reason)
+	}
+	return skip, nil
+}
+
+func (s *BuildRuleSetService) makeDefaultRuleSet(repoID models.RepoID) *models.BuildRuleSet {
+	return models.NewBuildRuleSet(models.NewTime(time.Now()), repoID, nil, nil, true, false, "", nil)
+}
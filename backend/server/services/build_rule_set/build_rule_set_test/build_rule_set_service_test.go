@@ -0,0 +1,116 @@
+package build_rule_set_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestBuildRuleSet(t *testing.T) {
+	ctx := context.Background()
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	// Repos without build rules build everything
+	ruleSet, err := app.BuildRuleSetService.Read(ctx, nil, repo.ID)
+	require.NoError(t, err)
+	require.True(t, ruleSet.BuildTags)
+	require.False(t, ruleSet.PullRequestsOnly)
+	for _, ref := range []string{"refs/heads/master", "refs/heads/feature", "refs/tags/v1.0.0"} {
+		shouldBuild, err := app.BuildRuleSetService.ShouldBuild(ctx, nil, repo, ref, false)
+		require.NoError(t, err)
+		require.True(t, shouldBuild, ref)
+	}
+
+	// Invalid regular expressions are rejected
+	_, err = app.BuildRuleSetService.Update(ctx, nil, repo.ID, dto.UpdateBuildRuleSet{
+		DenyRefs: &models.RefPatterns{"^refs/heads/(wip"},
+	})
+	require.True(t, gerror.IsValidationFailed(err))
+
+	denyRefs := models.RefPatterns{"^refs/heads/wip/"}
+	buildTags := false
+	pullRequestsOnly := true
+	ruleSet, err = app.BuildRuleSetService.Update(ctx, nil, repo.ID, dto.UpdateBuildRuleSet{
+		DenyRefs:         &denyRefs,
+		BuildTags:        &buildTags,
+		PullRequestsOnly: &pullRequestsOnly,
+	})
+	require.NoError(t, err)
+	tests := []struct {
+		ref         string
+		pullRequest bool
+		expected    bool
+	}{
+		{"refs/heads/master", false, true}, // the default branch is always built
+		{"refs/heads/feature", false, false},
+		{"refs/heads/feature", true, true},
+		{"refs/heads/wip/feature", true, false},
+		{"refs/pull/12/head", true, true},
+		{"refs/tags/v1.0.0", false, false},
+	}
+	for _, test := range tests {
+		shouldBuild, err := app.BuildRuleSetService.ShouldBuild(ctx, nil, repo, test.ref, test.pullRequest)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, shouldBuild, "ref %s (pull request: %v)", test.ref, test.pullRequest)
+	}
+
+	// Updates only change the fields that are set, and the rules can be narrowed to an allow list
+	allowRefs := models.RefPatterns{"^refs/heads/(master|release/.*)$", "^refs/tags/"}
+	buildTags = true
+	ruleSet, err = app.BuildRuleSetService.Update(ctx, nil, repo.ID, dto.UpdateBuildRuleSet{
+		AllowRefs: &allowRefs,
+		BuildTags: &buildTags,
+		ETag:      ruleSet.ETag,
+	})
+	require.NoError(t, err)
+	require.Equal(t, denyRefs, ruleSet.DenyRefs)
+	require.True(t, ruleSet.PullRequestsOnly)
+	for ref, expected := range map[string]bool{
+		"refs/heads/release/1.0": false, // not a pull request
+		"refs/heads/master":      true,
+		"refs/tags/v1.0.0":       true,
+		"refs/heads/other":       false,
+	} {
+		shouldBuild, err := app.BuildRuleSetService.ShouldBuild(ctx, nil, repo, ref, false)
+		require.NoError(t, err)
+		require.Equal(t, expected, shouldBuild, ref)
+	}
+
+	// The default comment commands are available until others are configured
+	require.Equal(t, models.DefaultCommentCommands, ruleSet.GetCommentCommands())
+	_, err = app.BuildRuleSetService.Update(ctx, nil, repo.ID, dto.UpdateBuildRuleSet{
+		CommentCommands: &models.CommentCommands{{Name: "deploy", Operation: "launch:missiles"}},
+	})
+	require.True(t, gerror.IsValidationFailed(err))
+	commentCommands := models.CommentCommands{
+		{Name: "rebuild"},
+		{Name: "deploy", Workflows: []string{"deploy"}, Parameters: models.BuildParameters{"target": "production"}, Operation: models.RepoUpdateOperation.String()},
+	}
+	_, err = app.BuildRuleSetService.Update(ctx, nil, repo.ID, dto.UpdateBuildRuleSet{
+		CommentCommands: &commentCommands,
+	})
+	require.NoError(t, err)
+	ruleSet, err = app.BuildRuleSetService.Read(ctx, nil, repo.ID)
+	require.NoError(t, err)
+	require.Equal(t, commentCommands, ruleSet.GetCommentCommands())
+	_, ok := ruleSet.GetCommentCommands().Find("build")
+	require.False(t, ok)
+
+	// Deleting the rules goes back to building everything
+	err = app.BuildRuleSetService.Delete(ctx, nil, repo.ID)
+	require.NoError(t, err)
+	shouldBuild, err := app.BuildRuleSetService.ShouldBuild(ctx, nil, repo, "refs/heads/wip/feature", false)
+	require.NoError(t, err)
+	require.True(t, shouldBuild)
+}
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/util"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	DefaultMaxRepoSizeBytes  = 10 * 1024 * 1024 * 1024
+	DefaultMaxEntrySizeBytes = 2 * 1024 * 1024 * 1024
+	// evictionBatchSize is the number of least recently used entries to read at a time while evicting.
+	evictionBatchSize = 20
+)
+
+type CacheServiceConfig struct {
+	// MaxRepoSizeBytes is the maximum total size of the cache entries for each repo. Once a repo's caches grow
+	// beyond this size, the least recently used entries are evicted. Zero means no limit.
+	MaxRepoSizeBytes uint64
+	// MaxEntrySizeBytes is the largest cache entry that can be saved; larger entries are rejected.
+	// Zero means no limit.
+	MaxEntrySizeBytes uint64
+}
+
+// CacheService stores build caches declared by jobs. Runners save a tarball of each cache at the end of a
+// successful job, and restore it at the start of later jobs in the same repo. Entries are looked up by cache
+// name and key; if there is no entry with a matching key, the most recently used entry for the cache is
+// restored instead so the job can start from a warm (if slightly stale) cache. Entries are kept in the blob
+// store and evicted least recently used first once the caches for a repo exceed the configured size.
+type CacheService struct {
+	db              *store.DB
+	cacheEntryStore store.CacheEntryStore
+	jobStore        store.JobStore
+	blobStore       services.BlobStore
+	config          CacheServiceConfig
+	logger.Log
+}
+
+func NewCacheService(
+	db *store.DB,
+	cacheEntryStore store.CacheEntryStore,
+	jobStore store.JobStore,
+	blobStore services.BlobStore,
+	config CacheServiceConfig,
+	logFactory logger.LogFactory,
+) *CacheService {
+	return &CacheService{
+		db:              db,
+		cacheEntryStore: cacheEntryStore,
+		jobStore:        jobStore,
+		blobStore:       blobStore,
+		config:          config,
+		Log:             logFactory("CacheService"),
+	}
+}
+
+// Find finds the cache entry to restore for a job. Returns the entry with the specified key if there is one,
+// otherwise the most recently used entry for the cache in the job's repo. The entry is marked as used, so
+// it is less likely to be evicted.
+// Returns models.ErrNotFound if the job's repo has no entries for the cache.
+func (s *CacheService) Find(ctx context.Context, txOrNil *store.Tx, jobID models.JobID, name models.ResourceName, key string) (*models.CacheEntry, error) {
+	var cacheEntry *models.CacheEntry
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		job, err := s.jobStore.Read(ctx, tx, jobID)
+		if err != nil {
+			return fmt.Errorf("error reading job: %w", err)
+		}
+		cacheEntry, err = s.cacheEntryStore.ReadByKey(ctx, tx, job.RepoID, name, key)
+		if gerror.IsNotFound(err) {
+			cacheEntry, err = s.cacheEntryStore.ReadMostRecentlyUsed(ctx, tx, job.RepoID, name)
+		}
+		if err != nil {
+			return err
+		}
+		cacheEntry.LastUsedAt = models.NewTime(time.Now())
+		return s.cacheEntryStore.Update(ctx, tx, cacheEntry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cacheEntry, nil
+}
+
+// ReadForJob reads a cache entry on behalf of a job.
+// Returns models.ErrNotFound if the cache entry does not exist or belongs to a different repo to the job.
+func (s *CacheService) ReadForJob(ctx context.Context, txOrNil *store.Tx, jobID models.JobID, cacheEntryID models.CacheEntryID) (*models.CacheEntry, error) {
+	job, err := s.jobStore.Read(ctx, txOrNil, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading job: %w", err)
+	}
+	cacheEntry, err := s.cacheEntryStore.Read(ctx, txOrNil, cacheEntryID)
+	if err != nil {
+		return nil, err
+	}
+	if cacheEntry.RepoID != job.RepoID {
+		// Don't reveal that the entry exists to jobs for other repos
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	return cacheEntry, nil
+}
+
+// GetCacheEntryData returns a reader to the tarball for a cache entry, read on behalf of a job.
+// Returns models.ErrNotFound if the cache entry does not exist or belongs to a different repo to the job.
+// It is the caller's responsibility to close the reader.
+func (s *CacheService) GetCacheEntryData(ctx context.Context, jobID models.JobID, cacheEntryID models.CacheEntryID) (io.ReadCloser, error) {
+	cacheEntry, err := s.ReadForJob(ctx, nil, jobID, cacheEntryID)
+	if err != nil {
+		return nil, err
+	}
+	return s.blobStore.GetBlob(ctx, s.makeCacheEntryKey(cacheEntry.ID))
+}
+
+// Save a cache entry for a job, with the tarball provided by reader. It is the caller's responsibility to close
+// reader. The job must declare a cache with the specified name. If an entry with the same name and key already
+// exists in the job's repo, the existing entry is returned and the new tarball is discarded.
+// Once the entry is saved, the least recently used entries in the repo are evicted if the repo's caches
+// have grown too large.
+func (s *CacheService) Save(ctx context.Context, jobID models.JobID, name models.ResourceName, key string, reader io.Reader) (*models.CacheEntry, error) {
+	if key == "" {
+		return nil, gerror.NewErrValidationFailed("Cache key must be set")
+	}
+	job, err := s.jobStore.Read(ctx, nil, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading job: %w", err)
+	}
+	if !s.jobDeclaresCache(job, name) {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Job does not declare a cache named %q", name))
+	}
+
+	cacheEntry := models.NewCacheEntry(models.NewTime(time.Now()), job, name, key)
+	blobKey := s.makeCacheEntryKey(cacheEntry.ID)
+
+	// Upload the data before creating the entry so we don't hold a transaction open during the upload, and
+	// so entries are never visible before their data is available
+	if s.config.MaxEntrySizeBytes > 0 {
+		// Read one byte past the limit so we can tell if the tarball was too large
+		reader = io.LimitReader(reader, int64(s.config.MaxEntrySizeBytes)+1)
+	}
+	md5Hash := md5.New()
+	countingReader := util.NewCountingReader(io.TeeReader(reader, md5Hash))
+	err = s.blobStore.PutBlob(ctx, blobKey, countingReader)
+	if err != nil {
+		s.deleteBlob(ctx, blobKey)
+		return nil, fmt.Errorf("error writing cache entry data to blob store: %w", err)
+	}
+	if s.config.MaxEntrySizeBytes > 0 && countingReader.Count() > s.config.MaxEntrySizeBytes {
+		s.deleteBlob(ctx, blobKey)
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Cache entry is too large; the maximum size is %d bytes", s.config.MaxEntrySizeBytes))
+	}
+	cacheEntry.Size = countingReader.Count()
+	cacheEntry.Hash = hex.EncodeToString(md5Hash.Sum(nil))
+	cacheEntry.HashType = models.HashTypeMD5
+
+	var existing *models.CacheEntry
+	err = s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		existing, err = s.cacheEntryStore.ReadByKey(ctx, tx, job.RepoID, name, key)
+		if err == nil {
+			return nil
+		}
+		if !gerror.IsNotFound(err) {
+			return err
+		}
+		existing = nil
+		err = cacheEntry.Validate()
+		if err != nil {
+			return fmt.Errorf("error validating cache entry: %w", err)
+		}
+		return s.cacheEntryStore.Create(ctx, tx, cacheEntry)
+	})
+	if err != nil {
+		s.deleteBlob(ctx, blobKey)
+		return nil, err
+	}
+	if existing != nil {
+		// Another job saved the same entry first; keep that one
+		s.deleteBlob(ctx, blobKey)
+		return existing, nil
+	}
+	s.Infof("Saved cache entry %q for cache %q (%d bytes)", cacheEntry.ID, name, cacheEntry.Size)
+
+	err = s.evict(ctx, job.RepoID, cacheEntry.ID)
+	if err != nil {
+		// The entry was saved successfully; eviction will be retried next time an entry is saved
+		s.Warnf("Error evicting cache entries for repo %q: %s", job.RepoID, err)
+	}
+	return cacheEntry, nil
+}
+
+// evict deletes the least recently used cache entries in a repo until the total size of the repo's caches
+// is within the configured limit. The entry identified by keepID is never evicted.
+func (s *CacheService) evict(ctx context.Context, repoID models.RepoID, keepID models.CacheEntryID) error {
+	if s.config.MaxRepoSizeBytes == 0 {
+		return nil
+	}
+	total, err := s.cacheEntryStore.TotalSizeByRepoID(ctx, nil, repoID)
+	if err != nil {
+		return fmt.Errorf("error calculating total cache size: %w", err)
+	}
+	for total > s.config.MaxRepoSizeBytes {
+		cacheEntries, err := s.cacheEntryStore.ListLeastRecentlyUsed(ctx, nil, repoID, evictionBatchSize)
+		if err != nil {
+			return fmt.Errorf("error listing least recently used cache entries: %w", err)
+		}
+		evicted := false
+		for _, cacheEntry := range cacheEntries {
+			if total <= s.config.MaxRepoSizeBytes {
+				break
+			}
+			if cacheEntry.ID == keepID {
+				continue
+			}
+			err = s.cacheEntryStore.Delete(ctx, nil, cacheEntry.ID)
+			if err != nil {
+				return fmt.Errorf("error deleting cache entry: %w", err)
+			}
+			s.deleteBlob(ctx, s.makeCacheEntryKey(cacheEntry.ID))
+			s.Infof("Evicted cache entry %q for cache %q (%d bytes)", cacheEntry.ID, cacheEntry.Name, cacheEntry.Size)
+			total -= cacheEntry.Size
+			evicted = true
+		}
+		if !evicted {
+			// Only the entry we must keep is left
+			break
+		}
+	}
+	return nil
+}
+
+// deleteBlob deletes the blob for a cache entry, logging rather than returning any error since an orphaned
+// blob is harmless.
+func (s *CacheService) deleteBlob(ctx context.Context, blobKey string) {
+	err := s.blobStore.DeleteBlob(ctx, blobKey)
+	if err != nil {
+		s.Warnf("Error deleting cache entry blob %q: %s", blobKey, err)
+	}
+}
+
+func (s *CacheService) jobDeclaresCache(job *models.Job, name models.ResourceName) bool {
+	for _, definition := range job.CacheDefinitions {
+		if definition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *CacheService) makeCacheEntryKey(cacheEntryID models.CacheEntryID) string {
+	return fmt.Sprintf("caches/%s", cacheEntryID)
+}
@@ -0,0 +1,92 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestCacheService(t *testing.T) {
+	ctx := context.Background()
+
+	config := server_test.TestConfig(t)
+	config.CacheConfig.MaxRepoSizeBytes = 10
+	config.CacheConfig.MaxEntrySizeBytes = 8
+	app, cleanup, err := server_test.New(config)
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	otherRepo := server_test.CreateNamedRepo(t, ctx, app, "other-repo", legalEntity.ID)
+
+	// makeJob returns a job in the specified repo that declares a cache called "deps"
+	makeJob := func(repoID models.RepoID) models.JobID {
+		build := server_test.CreateAndQueueBuild(t, ctx, app, repoID, legalEntity.ID, "")
+		job, err := app.JobStore.Read(ctx, nil, build.Jobs[0].ID)
+		require.NoError(t, err)
+		job.CacheDefinitions = models.CacheDefinitions{{Name: "deps", Paths: []string{"vendor"}, KeyCommands: models.Commands{"cat go.sum"}}}
+		err = app.JobStore.Update(ctx, nil, job)
+		require.NoError(t, err)
+		return job.ID
+	}
+	jobID := makeJob(repo.ID)
+
+	// Nothing to restore yet
+	_, err = app.CacheService.Find(ctx, nil, jobID, "deps", "key-1")
+	require.True(t, gerror.IsNotFound(err))
+
+	// Caches the job doesn't declare can't be saved, and neither can entries over the size limit
+	_, err = app.CacheService.Save(ctx, jobID, "undeclared", "key-1", bytes.NewReader([]byte("data")))
+	require.True(t, gerror.IsValidationFailed(err))
+	_, err = app.CacheService.Save(ctx, jobID, "deps", "key-1", bytes.NewReader([]byte("too much data")))
+	require.True(t, gerror.IsValidationFailed(err))
+
+	entry1, err := app.CacheService.Save(ctx, jobID, "deps", "key-1", bytes.NewReader([]byte("data-1")))
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), entry1.Size)
+
+	// Saving the same key again returns the existing entry
+	duplicate, err := app.CacheService.Save(ctx, jobID, "deps", "key-1", bytes.NewReader([]byte("other")))
+	require.NoError(t, err)
+	require.Equal(t, entry1.ID, duplicate.ID)
+
+	// An exact key match is found, and a miss falls back to the most recently used entry
+	found, err := app.CacheService.Find(ctx, nil, jobID, "deps", "key-1")
+	require.NoError(t, err)
+	require.Equal(t, entry1.ID, found.ID)
+	found, err = app.CacheService.Find(ctx, nil, jobID, "deps", "key-2")
+	require.NoError(t, err)
+	require.Equal(t, entry1.ID, found.ID)
+
+	reader, err := app.CacheService.GetCacheEntryData(ctx, jobID, entry1.ID)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	require.Equal(t, "data-1", string(data))
+
+	// Jobs in other repos can't see the entry
+	otherJobID := makeJob(otherRepo.ID)
+	_, err = app.CacheService.Find(ctx, nil, otherJobID, "deps", "key-1")
+	require.True(t, gerror.IsNotFound(err))
+	_, err = app.CacheService.ReadForJob(ctx, nil, otherJobID, entry1.ID)
+	require.True(t, gerror.IsNotFound(err))
+
+	// Saving a second entry takes the repo over its size limit, so the least recently used entry is evicted
+	entry2, err := app.CacheService.Save(ctx, jobID, "deps", "key-2", bytes.NewReader([]byte("data-2")))
+	require.NoError(t, err)
+	_, err = app.CacheService.ReadForJob(ctx, nil, jobID, entry1.ID)
+	require.True(t, gerror.IsNotFound(err))
+	found, err = app.CacheService.Find(ctx, nil, jobID, "deps", "key-1")
+	require.NoError(t, err)
+	require.Equal(t, entry2.ID, found.ID)
+}
@@ -0,0 +1,61 @@
+package coverage
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+type coberturaCoverage struct {
+	XMLName  xml.Name            `xml:"coverage"`
+	Packages []*coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Classes []*coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Filename string           `xml:"filename,attr"`
+	Lines    []*coberturaLine `xml:"lines>line"`
+	Methods  []*coberturaLine `xml:"methods>method>lines>line"`
+}
+
+type coberturaLine struct {
+	Number string `xml:"number,attr"`
+	Hits   string `xml:"hits,attr"`
+}
+
+// parseCobertura parses a Cobertura XML coverage report. Coverage is calculated from the individual lines
+// in the report rather than the report's own totals, so that files split across several classes (and lines
+// listed under both a class and its methods) are only counted once.
+func parseCobertura(data []byte) (models.FileCoverages, error) {
+	report := &coberturaCoverage{}
+	err := xml.Unmarshal(data, report)
+	if err != nil {
+		return nil, err
+	}
+	hits := make(lineHits)
+	for _, pkg := range report.Packages {
+		for _, class := range pkg.Classes {
+			if class.Filename == "" {
+				continue
+			}
+			for _, lines := range [][]*coberturaLine{class.Lines, class.Methods} {
+				for _, line := range lines {
+					count, err := strconv.ParseInt(line.Hits, 10, 64)
+					if err != nil {
+						continue
+					}
+					hits.add(class.Filename, line.Number, 1, count > 0)
+				}
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return nil, errors.New("no classes found")
+	}
+	return hits.files(), nil
+}
@@ -0,0 +1,374 @@
+package coverage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// maxCoverageReportSize is the largest coverage report that will be parsed; larger reports are rejected.
+	maxCoverageReportSize = 64 * 1024 * 1024
+	ingestTimeout         = 5 * time.Minute
+	checkTimeout          = 1 * time.Minute
+)
+
+// CoverageService ingests code coverage reports uploaded by jobs and checks coverage against each repo's
+// threshold. Any artifact uploaded to the models.CoverageArtifactGroupName artifact group is parsed in the
+// background via the work queue, as a Cobertura XML, LCOV or Go cover profile report. Each report is added to
+// the build's coverage, which is compared against the latest coverage for the build's target branch.
+// Once a build finishes its coverage is checked against the repo's models.CoverageSettings, if any.
+type CoverageService struct {
+	db                  *store.DB
+	coverageReportStore store.CoverageReportStore
+	buildCoverageStore  store.BuildCoverageStore
+	artifactStore       store.ArtifactStore
+	jobStore            store.JobStore
+	buildStore          store.BuildStore
+	repoStore           store.RepoStore
+	pullRequestStore    store.PullRequestStore
+	artifactService     services.ArtifactService
+	customStatusService services.CustomStatusService
+	queueService        services.QueueService
+	workQueueService    services.WorkQueueService
+	logger.Log
+}
+
+func NewCoverageService(
+	db *store.DB,
+	coverageReportStore store.CoverageReportStore,
+	buildCoverageStore store.BuildCoverageStore,
+	artifactStore store.ArtifactStore,
+	jobStore store.JobStore,
+	buildStore store.BuildStore,
+	repoStore store.RepoStore,
+	pullRequestStore store.PullRequestStore,
+	artifactService services.ArtifactService,
+	customStatusService services.CustomStatusService,
+	queueService services.QueueService,
+	eventService services.EventService,
+	workQueueService services.WorkQueueService,
+	logFactory logger.LogFactory,
+) *CoverageService {
+	s := &CoverageService{
+		db:                  db,
+		coverageReportStore: coverageReportStore,
+		buildCoverageStore:  buildCoverageStore,
+		artifactStore:       artifactStore,
+		jobStore:            jobStore,
+		buildStore:          buildStore,
+		repoStore:           repoStore,
+		pullRequestStore:    pullRequestStore,
+		artifactService:     artifactService,
+		customStatusService: customStatusService,
+		queueService:        queueService,
+		workQueueService:    workQueueService,
+		Log:                 logFactory("CoverageService"),
+	}
+
+	// Register the code to process work items for ingesting and checking coverage
+	err := workQueueService.RegisterHandler(
+		IngestCoverageWorkItem,
+		s.ProcessIngestCoverageWorkItem,
+		ingestTimeout,
+		work_queue.ExponentialBackoff(10, 5*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+	err = workQueueService.RegisterHandler(
+		CheckCoverageWorkItem,
+		s.ProcessCheckCoverageWorkItem,
+		checkTimeout,
+		work_queue.ExponentialBackoff(10, 5*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	artifactService.RegisterUploadHandler(func(ctx context.Context, tx *store.Tx, artifact *models.Artifact) error {
+		if artifact.GroupName != models.CoverageArtifactGroupName {
+			return nil
+		}
+		job, err := s.jobStore.Read(ctx, tx, artifact.JobID)
+		if err != nil {
+			return fmt.Errorf("error reading job for artifact: %w", err)
+		}
+		return workQueueService.AddWorkItem(ctx, tx, NewIngestCoverageWorkItem(job.BuildID, artifact.ID))
+	})
+
+	eventService.Subscribe(models.BuildStatusChangedEvent, s.onBuildStatusChanged)
+
+	return s
+}
+
+// onBuildStatusChanged queues a check of the build's coverage when a build finishes, if the build's repo
+// has coverage settings.
+func (s *CoverageService) onBuildStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	status := models.WorkflowStatus(event.Payload)
+	if status != models.WorkflowStatusSucceeded && status != models.WorkflowStatusFailed {
+		return nil
+	}
+	build, err := s.buildStore.Read(ctx, tx, event.BuildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	repo, err := s.repoStore.Read(ctx, tx, build.RepoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	if repo.CoverageSettings == nil {
+		return nil
+	}
+	return s.workQueueService.AddWorkItem(ctx, tx, NewCheckCoverageWorkItem(build.ID))
+}
+
+// ProcessIngestCoverageWorkItem parses a coverage report artifact and adds it to the build's coverage.
+func (s *CoverageService) ProcessIngestCoverageWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &IngestCoverageWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling ingest coverage work item data: %w", err)
+	}
+	artifact, err := s.artifactStore.Read(ctx, nil, workItemData.ArtifactID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping coverage for deleted artifact %q", workItemData.ArtifactID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading artifact: %w", err)
+	}
+	_, err = s.coverageReportStore.ReadByArtifactID(ctx, nil, artifact.ID)
+	if err == nil {
+		s.Infof("Ignoring coverage for artifact %q that has already been ingested", artifact.ID)
+		return false, nil
+	} else if !gerror.IsNotFound(err) {
+		return true, fmt.Errorf("error reading coverage report: %w", err)
+	}
+	if artifact.Size > maxCoverageReportSize {
+		return false, fmt.Errorf("error coverage report %q is too large (%d bytes, maximum is %d bytes)", artifact.Path, artifact.Size, maxCoverageReportSize)
+	}
+
+	reader, err := s.artifactService.GetArtifactData(ctx, artifact.ID)
+	if err != nil {
+		if gerror.IsArtifactQuarantined(err) {
+			s.Warnf("Ignoring coverage for quarantined artifact %q", artifact.ID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading artifact data: %w", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxCoverageReportSize))
+	if err != nil {
+		return true, fmt.Errorf("error reading artifact data: %w", err)
+	}
+	format, files, err := parseCoverageReport(data)
+	if err != nil {
+		// Retrying won't help if the report can't be parsed
+		return false, fmt.Errorf("error parsing coverage report %q: %w", artifact.Path, err)
+	}
+
+	var buildCoverage *models.BuildCoverage
+	err = s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		job, err := s.jobStore.Read(ctx, tx, artifact.JobID)
+		if err != nil {
+			return fmt.Errorf("error reading job for artifact: %w", err)
+		}
+		report := models.NewCoverageReport(models.NewTime(time.Now()), job, artifact.ID, format, files)
+		err = s.coverageReportStore.Create(ctx, tx, report)
+		if err != nil {
+			return fmt.Errorf("error creating coverage report: %w", err)
+		}
+		build, err := s.buildStore.Read(ctx, tx, job.BuildID)
+		if err != nil {
+			return fmt.Errorf("error reading build: %w", err)
+		}
+		buildCoverage, err = s.updateBuildCoverage(ctx, tx, build, files)
+		if err != nil {
+			return err
+		}
+		// Reports are normally ingested before the build finishes, but if this one was late (e.g. because it
+		// had to be retried) then the build's coverage must be checked again now it has changed
+		if build.Status.HasFinished() {
+			err = s.workQueueService.AddWorkItem(ctx, tx, NewCheckCoverageWorkItem(build.ID))
+			if err != nil {
+				return fmt.Errorf("error queueing check coverage work item: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return true, err
+	}
+	s.Infof("Ingested %s coverage report %q for build %q: build coverage is now %.2f%%",
+		format, artifact.Path, buildCoverage.BuildID, buildCoverage.Percent())
+	return false, nil
+}
+
+// updateBuildCoverage adds the files from a new coverage report to the build's coverage, creating the build's
+// coverage if this is its first report, and compares the result against the build's target branch.
+func (s *CoverageService) updateBuildCoverage(ctx context.Context, tx *store.Tx, build *models.Build, files models.FileCoverages) (*models.BuildCoverage, error) {
+	now := models.NewTime(time.Now())
+	buildCoverage, err := s.buildCoverageStore.ReadByBuildID(ctx, tx, build.ID)
+	create := false
+	if err != nil {
+		if !gerror.IsNotFound(err) {
+			return nil, fmt.Errorf("error reading build coverage: %w", err)
+		}
+		buildCoverage = models.NewBuildCoverage(now, build)
+		create = true
+	}
+
+	buildCoverage.Files = buildCoverage.Files.Merge(files)
+	buildCoverage.LinesCovered, buildCoverage.LinesValid = buildCoverage.Files.Totals()
+	buildCoverage.Reports++
+	buildCoverage.UpdatedAt = now
+
+	targetRef, err := s.findTargetRef(ctx, tx, build)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.buildCoverageStore.ReadLatestByRef(ctx, tx, build.RepoID, targetRef, build.ID)
+	if err != nil {
+		if !gerror.IsNotFound(err) {
+			return nil, fmt.Errorf("error reading target build coverage: %w", err)
+		}
+		target = nil
+	}
+	buildCoverage.SetTarget(targetRef, target)
+
+	if create {
+		err = s.buildCoverageStore.Create(ctx, tx, buildCoverage)
+		if err != nil {
+			return nil, fmt.Errorf("error creating build coverage: %w", err)
+		}
+		return buildCoverage, nil
+	}
+	err = s.buildCoverageStore.Update(ctx, tx, buildCoverage)
+	if err != nil {
+		return nil, fmt.Errorf("error updating build coverage: %w", err)
+	}
+	return buildCoverage, nil
+}
+
+// findTargetRef returns the ref of the branch a build's coverage should be compared against. This is the base
+// branch of an open pull request for the build's ref if there is one, otherwise the repo's default branch.
+func (s *CoverageService) findTargetRef(ctx context.Context, tx *store.Tx, build *models.Build) (string, error) {
+	pullRequest, err := s.pullRequestStore.ReadOpenByHeadRef(ctx, tx, build.RepoID, build.Ref)
+	if err == nil {
+		return pullRequest.BaseRef, nil
+	} else if !gerror.IsNotFound(err) {
+		return "", fmt.Errorf("error reading pull request: %w", err)
+	}
+	repo, err := s.repoStore.Read(ctx, tx, build.RepoID)
+	if err != nil {
+		return "", fmt.Errorf("error reading repo: %w", err)
+	}
+	if repo.DefaultBranch == "" || strings.HasPrefix(repo.DefaultBranch, "refs/") {
+		return repo.DefaultBranch, nil
+	}
+	return "refs/heads/" + repo.DefaultBranch, nil
+}
+
+// ProcessCheckCoverageWorkItem checks a finished build's coverage against the threshold configured for its repo,
+// publishing a coverage status and/or failing the build as specified in the repo's coverage settings.
+func (s *CoverageService) ProcessCheckCoverageWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &CheckCoverageWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling check coverage work item data: %w", err)
+	}
+	build, err := s.buildStore.Read(ctx, nil, workItemData.BuildID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading build: %w", err)
+	}
+	repo, err := s.repoStore.Read(ctx, nil, build.RepoID)
+	if err != nil {
+		return true, fmt.Errorf("error reading repo: %w", err)
+	}
+	settings := repo.CoverageSettings
+	if settings == nil {
+		return false, nil
+	}
+	buildCoverage, err := s.buildCoverageStore.ReadByBuildID(ctx, nil, build.ID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Build %q reported no coverage; skipping coverage check", build.ID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading build coverage: %w", err)
+	}
+
+	passed := buildCoverage.Percent() >= settings.Threshold
+	description := describeCoverage(buildCoverage, settings)
+	s.Infof("Coverage check for build %q passed=%v: %s", build.ID, passed, description)
+	if settings.PublishStatus {
+		state := models.CustomStatusStateSuccess
+		if !passed {
+			state = models.CustomStatusStateFailure
+		}
+		_, err = s.customStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+			BuildID:     build.ID,
+			Name:        models.CoverageStatusName,
+			State:       state,
+			Description: description,
+		})
+		if err != nil {
+			return true, fmt.Errorf("error publishing coverage status: %w", err)
+		}
+	}
+	if settings.FailBuild && !passed {
+		_, err = s.queueService.FailBuild(ctx, nil, build.ID, models.NewError(fmt.Errorf("coverage check failed: %s", description)))
+		if err != nil {
+			return true, fmt.Errorf("error failing build: %w", err)
+		}
+	}
+	return false, nil
+}
+
+// describeCoverage returns a short human-readable summary of a build's coverage compared to the threshold
+// and the target branch, suitable for a commit status description.
+func describeCoverage(buildCoverage *models.BuildCoverage, settings *models.CoverageSettings) string {
+	description := fmt.Sprintf("%.2f%% coverage", buildCoverage.Percent())
+	if delta := buildCoverage.Delta(); delta != nil {
+		description += fmt.Sprintf(" (%+.2f%% vs %s)", *delta, strings.TrimPrefix(buildCoverage.TargetRef, "refs/heads/"))
+	}
+	return description + fmt.Sprintf(", minimum is %.2f%%", settings.Threshold)
+}
+
+// ReadBuildCoverage reads the combined code coverage for a build, including the change in coverage
+// compared to the target branch.
+// Returns models.ErrNotFound if no coverage has been reported for the build.
+func (s *CoverageService) ReadBuildCoverage(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) (*models.BuildCoverage, error) {
+	return s.buildCoverageStore.ReadByBuildID(ctx, txOrNil, buildID)
+}
+
+// ListBuildCoverages lists the coverage for builds in a repo, newest first, to show coverage trends over time.
+// Use cursor to page through results, if any.
+func (s *CoverageService) ListBuildCoverages(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.BuildCoverage, *models.Cursor, error) {
+	return s.buildCoverageStore.ListByRepoID(ctx, txOrNil, repoID, pagination)
+}
+
+// ListReports lists the coverage reports for a build.
+// Use cursor to page through results, if any.
+func (s *CoverageService) ListReports(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CoverageReport, *models.Cursor, error) {
+	return s.coverageReportStore.ListByBuildID(ctx, txOrNil, buildID, pagination)
+}
@@ -0,0 +1,149 @@
+package coverage_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testCoverageTimeout = 30 * time.Second
+
+// goCoverProfile covers 3 of 4 statements.
+const goCoverProfile = `mode: set
+github.com/example/app/server.go:3.10,5.2 2 1
+github.com/example/app/server.go:7.10,9.2 1 0
+github.com/example/app/util.go:3.10,5.2 1 1
+`
+
+// lcovReport covers 1 of 3 lines.
+const lcovReport = `SF:src/index.js
+DA:1,1
+DA:2,0
+DA:3,0
+end_of_record
+`
+
+// coberturaReport covers 1 of 2 lines.
+const coberturaReport = `<coverage>
+  <packages><package><classes>
+    <class filename="app/server.py"><lines><line number="1" hits="3"/><line number="2" hits="0"/></lines></class>
+  </classes></package></packages>
+</coverage>`
+
+func TestCoverageService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	waitForReports := func(buildID models.BuildID, reports int) *models.BuildCoverage {
+		deadline := time.Now().Add(testCoverageTimeout)
+		for time.Now().Before(deadline) {
+			buildCoverage, err := app.CoverageService.ReadBuildCoverage(ctx, nil, buildID)
+			if err == nil && buildCoverage.Reports >= reports {
+				return buildCoverage
+			}
+			if err != nil {
+				require.True(t, gerror.IsNotFound(err))
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for %d coverage reports to be ingested", reports)
+		return nil
+	}
+
+	// Coverage on the default branch has nothing to compare against
+	mainBuild := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "refs/heads/master")
+	_, err = app.ArtifactService.Create(ctx, mainBuild.Jobs[0].ID, "reports", "reports/coverage.out", "", bytes.NewReader([]byte(goCoverProfile)), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, mainBuild.Jobs[0].ID, models.CoverageArtifactGroupName, "coverage.out", "", bytes.NewReader([]byte(goCoverProfile)), true)
+	require.NoError(t, err)
+	mainCoverage := waitForReports(mainBuild.ID, 1)
+	require.Equal(t, 3, mainCoverage.LinesCovered)
+	require.Equal(t, 4, mainCoverage.LinesValid)
+	require.Equal(t, 75.0, mainCoverage.Percent())
+	require.Equal(t, "refs/heads/master", mainCoverage.TargetRef)
+	require.Nil(t, mainCoverage.Delta())
+
+	repo, err = app.RepoService.UpdateCoverageSettings(ctx, repo.ID, dto.UpdateCoverageSettings{
+		Settings: &models.CoverageSettings{Threshold: 80, FailBuild: true, PublishStatus: true},
+	})
+	require.NoError(t, err)
+
+	// Reports from a feature branch build are combined and compared against the default branch
+	featureBuild := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "refs/heads/feature")
+	_, err = app.ArtifactService.Create(ctx, featureBuild.Jobs[0].ID, models.CoverageArtifactGroupName, "lcov.info", "", bytes.NewReader([]byte(lcovReport)), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, featureBuild.Jobs[0].ID, models.CoverageArtifactGroupName, "coverage.xml", "", bytes.NewReader([]byte(coberturaReport)), true)
+	require.NoError(t, err)
+	featureCoverage := waitForReports(featureBuild.ID, 2)
+	require.Equal(t, 2, featureCoverage.LinesCovered)
+	require.Equal(t, 5, featureCoverage.LinesValid)
+	require.Len(t, featureCoverage.Files, 2)
+	require.NotNil(t, featureCoverage.TargetBuildID)
+	require.Equal(t, mainBuild.ID, *featureCoverage.TargetBuildID)
+	require.InDelta(t, -35.0, *featureCoverage.Delta(), 0.001)
+
+	reports, _, err := app.CoverageService.ListReports(ctx, nil, featureBuild.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	// Once the build succeeds its coverage is below the threshold, so the build is failed and a failing status published
+	for _, job := range featureBuild.Jobs {
+		_, err = app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+		require.NoError(t, err)
+	}
+	deadline := time.Now().Add(testCoverageTimeout)
+	var build *models.Build
+	for time.Now().Before(deadline) {
+		build, err = app.BuildService.Read(ctx, nil, featureBuild.ID)
+		require.NoError(t, err)
+		if build.Status == models.WorkflowStatusFailed {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, models.WorkflowStatusFailed, build.Status)
+	require.NotNil(t, build.Error)
+	require.Contains(t, build.Error.Error(), "coverage check failed")
+
+	statuses, _, err := app.CustomStatusService.ListByBuildID(ctx, nil, featureBuild.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, models.CoverageStatusName, statuses[0].Name)
+	require.Equal(t, models.CustomStatusStateFailure, statuses[0].State)
+	require.Contains(t, statuses[0].Description, "40.00% coverage (-35.00% vs master)")
+
+	// Builds for an open pull request are compared against the pull request's base branch
+	externalID := models.NewExternalResourceID("github", "pr-1")
+	pullRequest := models.NewPullRequest(models.NewTime(time.Now()), nil, nil, "Add feature", "open", repo.ID, legalEntity.ID, "refs/heads/release", "refs/heads/pr-branch", &externalID)
+	require.NoError(t, app.PullRequestStore.Create(ctx, nil, pullRequest))
+	prBuild := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "refs/heads/pr-branch")
+	_, err = app.ArtifactService.Create(ctx, prBuild.Jobs[0].ID, models.CoverageArtifactGroupName, "coverage.out", "", bytes.NewReader([]byte(goCoverProfile)), true)
+	require.NoError(t, err)
+	prCoverage := waitForReports(prBuild.ID, 1)
+	require.Equal(t, "refs/heads/release", prCoverage.TargetRef)
+	require.Nil(t, prCoverage.TargetBuildID)
+
+	buildCoverages, _, err := app.CoverageService.ListBuildCoverages(ctx, nil, repo.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, buildCoverages, 3)
+}
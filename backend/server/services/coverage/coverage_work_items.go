@@ -0,0 +1,55 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// IngestCoverageWorkItem is a work item that will parse a coverage report artifact and add it to the build's coverage.
+const IngestCoverageWorkItem models.WorkItemType = "IngestCoverage"
+
+// CheckCoverageWorkItem is a work item that will check a finished build's coverage against the repo's threshold.
+const CheckCoverageWorkItem models.WorkItemType = "CheckCoverage"
+
+// IngestCoverageWorkItemData is serialized to JSON and stored in the Data field of an IngestCoverageWorkItem.
+type IngestCoverageWorkItemData struct {
+	ArtifactID models.ArtifactID
+}
+
+// CheckCoverageWorkItemData is serialized to JSON and stored in the Data field of a CheckCoverageWorkItem.
+type CheckCoverageWorkItemData struct {
+	BuildID models.BuildID
+}
+
+func NewIngestCoverageWorkItem(buildID models.BuildID, artifactID models.ArtifactID) *models.WorkItem {
+	data := &IngestCoverageWorkItemData{
+		ArtifactID: artifactID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in IngestCoverageWorkItemData definition
+		panic("Unable to marshal IngestCoverageWorkItemData object to JSON")
+	}
+	return models.NewWorkItem(IngestCoverageWorkItem, string(dataJson), makeCoverageConcurrencyKey(buildID), models.NewTime(time.Now()))
+}
+
+func NewCheckCoverageWorkItem(buildID models.BuildID) *models.WorkItem {
+	data := &CheckCoverageWorkItemData{
+		BuildID: buildID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in CheckCoverageWorkItemData definition
+		panic("Unable to marshal CheckCoverageWorkItemData object to JSON")
+	}
+	return models.NewWorkItem(CheckCoverageWorkItem, string(dataJson), makeCoverageConcurrencyKey(buildID), models.NewTime(time.Now()))
+}
+
+// makeCoverageConcurrencyKey returns a concurrency key per build, so that reports from the same build don't race
+// to update the build's coverage, and coverage is only checked once reports queued before it have been ingested.
+func makeCoverageConcurrencyKey(buildID models.BuildID) models.WorkItemConcurrencyKey {
+	return models.NewWorkItemConcurrencyKey(fmt.Sprintf("coverage/%s", buildID))
+}
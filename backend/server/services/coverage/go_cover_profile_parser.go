@@ -0,0 +1,58 @@
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// parseGoCoverProfile parses the output of 'go test -coverprofile'. Go reports coverage by statement rather
+// than by line, so the number of statements in each block is counted. Blocks that appear more than once (e.g.
+// when profiles for several packages built with -coverpkg are concatenated) are only counted once.
+func parseGoCoverProfile(data []byte) (models.FileCoverages, error) {
+	var (
+		hits   = make(lineHits)
+		lineNo int
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// <file>:<start line>.<start col>,<end line>.<end col> <number of statements> <count>
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d: invalid profile block %q", lineNo, line)
+		}
+		path := line[:colon]
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: invalid profile block %q", lineNo, line)
+		}
+		statements, err := strconv.Atoi(fields[1])
+		if err != nil || statements < 0 {
+			return nil, fmt.Errorf("line %d: invalid number of statements %q", lineNo, fields[1])
+		}
+		count, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid count %q", lineNo, fields[2])
+		}
+		hits.add(path, fields[0], statements, count > 0)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return nil, errors.New("no profile blocks found")
+	}
+	return hits.files(), nil
+}
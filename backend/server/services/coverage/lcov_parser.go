@@ -0,0 +1,65 @@
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// isLCOV returns true if data looks like an LCOV tracefile, i.e. it contains a source file record.
+func isLCOV(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("TN:")) || bytes.HasPrefix(data, []byte("SF:")) || bytes.Contains(data, []byte("\nSF:"))
+}
+
+// parseLCOV parses an LCOV tracefile. Line coverage is calculated from the DA (line data) records for each
+// source file; function and branch records are ignored.
+func parseLCOV(data []byte) (models.FileCoverages, error) {
+	var (
+		hits       = make(lineHits)
+		sourceFile string
+		lineNo     int
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			sourceFile = strings.TrimPrefix(line, "SF:")
+			if _, ok := hits[sourceFile]; !ok {
+				// Record the file even if it has no line data, so that it is included in the report
+				hits[sourceFile] = make(map[string]*lineHit)
+			}
+		case strings.HasPrefix(line, "DA:"):
+			if sourceFile == "" {
+				return nil, fmt.Errorf("line %d: line data outside of a source file record", lineNo)
+			}
+			// DA:<line number>,<execution count>[,<checksum>]
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: invalid line data %q", lineNo, line)
+			}
+			count, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid execution count %q", lineNo, fields[1])
+			}
+			hits.add(sourceFile, fields[0], 1, count > 0)
+		case line == "end_of_record":
+			sourceFile = ""
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return nil, errors.New("no source files found")
+	}
+	return hits.files(), nil
+}
@@ -0,0 +1,84 @@
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// lineHits records, for each source file in a coverage report, whether each executable line (or for Go
+// cover profiles, each statement block) was executed. A line reported more than once is covered if it was
+// executed according to any of the reports of it.
+type lineHits map[string]map[string]*lineHit
+
+type lineHit struct {
+	// weight is the number of lines or statements counted for the key; always 1 for formats that report lines.
+	weight  int
+	covered bool
+}
+
+func (h lineHits) add(path string, key string, weight int, covered bool) {
+	lines, ok := h[path]
+	if !ok {
+		lines = make(map[string]*lineHit)
+		h[path] = lines
+	}
+	hit, ok := lines[key]
+	if !ok {
+		lines[key] = &lineHit{weight: weight, covered: covered}
+		return
+	}
+	hit.covered = hit.covered || covered
+}
+
+// files summarizes the line hits as a list of file coverages sorted by path.
+func (h lineHits) files() models.FileCoverages {
+	files := make(models.FileCoverages, 0, len(h))
+	for path, lines := range h {
+		file := &models.FileCoverage{Path: path}
+		for _, hit := range lines {
+			file.LinesValid += hit.weight
+			if hit.covered {
+				file.LinesCovered += hit.weight
+			}
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// parseCoverageReport detects the format of a coverage report and parses it into the coverage of each file.
+// Returns a validation error if the report is not in a supported format or cannot be parsed.
+func parseCoverageReport(data []byte) (models.CoverageFormat, models.FileCoverages, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 byte order mark
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return "", nil, gerror.NewErrValidationFailed("Coverage report is empty")
+	}
+	var (
+		format models.CoverageFormat
+		files  models.FileCoverages
+		err    error
+	)
+	switch {
+	case trimmed[0] == '<':
+		format = models.CoverageFormatCobertura
+		files, err = parseCobertura(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("mode:")):
+		format = models.CoverageFormatGoCoverProfile
+		files, err = parseGoCoverProfile(trimmed)
+	case isLCOV(trimmed):
+		format = models.CoverageFormatLCOV
+		files, err = parseLCOV(trimmed)
+	default:
+		return "", nil, gerror.NewErrValidationFailed("Coverage report is not Cobertura XML, LCOV or a Go cover profile")
+	}
+	if err != nil {
+		return "", nil, gerror.NewErrValidationFailed(fmt.Sprintf("Error parsing %s coverage report: %s", format, err))
+	}
+	return format, files, nil
+}
@@ -0,0 +1,130 @@
+package coverage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func filesByPath(files models.FileCoverages) map[string]*models.FileCoverage {
+	byPath := make(map[string]*models.FileCoverage)
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	return byPath
+}
+
+func TestParseCoberturaReport(t *testing.T) {
+	report := `<?xml version="1.0" ?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+<coverage line-rate="0.5" lines-covered="3" lines-valid="6">
+  <sources><source>/src</source></sources>
+  <packages>
+    <package name="app">
+      <classes>
+        <class name="app.Server" filename="app/server.py">
+          <methods>
+            <method name="start"><lines><line number="2" hits="4"/></lines></method>
+          </methods>
+          <lines>
+            <line number="1" hits="1"/>
+            <line number="2" hits="4"/>
+            <line number="3" hits="0"/>
+          </lines>
+        </class>
+        <class name="app.Server$Inner" filename="app/server.py">
+          <lines><line number="3" hits="2"/><line number="4" hits="0"/></lines>
+        </class>
+        <class name="app.Util" filename="app/util.py">
+          <lines><line number="1" hits="0"/></lines>
+        </class>
+      </classes>
+    </package>
+  </packages>
+</coverage>`
+	format, files, err := parseCoverageReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, models.CoverageFormatCobertura, format)
+
+	byPath := filesByPath(files)
+	require.Len(t, byPath, 2)
+	// Lines listed under both a method and its class, or under several classes in the same file, count once
+	require.Equal(t, 3, byPath["app/server.py"].LinesCovered)
+	require.Equal(t, 4, byPath["app/server.py"].LinesValid)
+	require.Equal(t, 0, byPath["app/util.py"].LinesCovered)
+	require.Equal(t, 1, byPath["app/util.py"].LinesValid)
+}
+
+func TestParseLCOVReport(t *testing.T) {
+	report := `TN:
+SF:/src/index.js
+FN:1,main
+FNDA:1,main
+DA:1,1
+DA:2,0
+DA:3,5,abcdef
+LF:3
+LH:2
+end_of_record
+SF:/src/empty.js
+end_of_record
+SF:/src/index.js
+DA:2,1
+end_of_record
+`
+	format, files, err := parseCoverageReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, models.CoverageFormatLCOV, format)
+
+	byPath := filesByPath(files)
+	require.Len(t, byPath, 2)
+	require.Equal(t, 3, byPath["/src/index.js"].LinesCovered)
+	require.Equal(t, 3, byPath["/src/index.js"].LinesValid)
+	require.Equal(t, 0, byPath["/src/empty.js"].LinesValid)
+
+	_, _, err = parseCoverageReport([]byte("SF:/src/index.js\nDA:1,lots\nend_of_record\n"))
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+}
+
+func TestParseGoCoverProfile(t *testing.T) {
+	report := `mode: atomic
+github.com/example/app/server.go:10.20,12.2 2 5
+github.com/example/app/server.go:14.2,16.3 3 0
+github.com/example/app/util.go:5.30,7.2 1 0
+mode: atomic
+github.com/example/app/util.go:5.30,7.2 1 3
+`
+	format, files, err := parseCoverageReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, models.CoverageFormatGoCoverProfile, format)
+
+	byPath := filesByPath(files)
+	require.Len(t, byPath, 2)
+	require.Equal(t, 2, byPath["github.com/example/app/server.go"].LinesCovered)
+	require.Equal(t, 5, byPath["github.com/example/app/server.go"].LinesValid)
+	// A block reported more than once is counted once, and is covered if any report of it was covered
+	require.Equal(t, 1, byPath["github.com/example/app/util.go"].LinesCovered)
+	require.Equal(t, 1, byPath["github.com/example/app/util.go"].LinesValid)
+
+	_, _, err = parseCoverageReport([]byte("mode: set\ngithub.com/example/app/server.go:10.20,12.2 two 1\n"))
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+}
+
+func TestParseCoverageReportUnsupported(t *testing.T) {
+	for _, report := range []string{
+		"",
+		"not a coverage report",
+		`<testsuites><testsuite name="junit"/></testsuites>`,
+		`<coverage><packages/></coverage>`,
+		"mode: set\n",
+	} {
+		_, _, err := parseCoverageReport([]byte(report))
+		require.Error(t, err, report)
+		require.True(t, gerror.IsValidationFailed(err), report)
+	}
+}
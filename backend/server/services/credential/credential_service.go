@@ -10,8 +10,10 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
@@ -29,6 +31,9 @@ type JWTConfig struct {
 	AutoCreateKeyPair AutoCreateJWTSigningKeyPair
 }
 
+// MaxPersonalAccessTokenLifetime is the longest a personal access token can be valid for.
+const MaxPersonalAccessTokenLifetime = 366 * 24 * time.Hour
+
 type CredentialService struct {
 	db                    *store.DB
 	ownershipStore        store.OwnershipStore
@@ -169,6 +174,79 @@ func (s *CredentialService) CreateSharedSecretCredential(
 	return token.Public(), credential, nil
 }
 
+// CreatePersonalAccessToken creates a new personal access token for an identity, limited to the specified
+// scopes and valid until the specified expiry time.
+// The plaintext token is returned. The plaintext can never be reconstructed so do something useful with it now.
+func (s *CredentialService) CreatePersonalAccessToken(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	create dto.CreatePersonalAccessToken,
+) (string, *models.Credential, error) {
+	now := models.NewTime(time.Now().UTC())
+	if !create.ExpiresAt.After(now.Time) {
+		return "", nil, gerror.NewErrValidationFailed("error expiry time must be in the future")
+	}
+	if create.ExpiresAt.Sub(now.Time) > MaxPersonalAccessTokenLifetime {
+		return "", nil, gerror.NewErrValidationFailed(fmt.Sprintf("error personal access tokens can't be valid for longer than %d days", MaxPersonalAccessTokenLifetime/(24*time.Hour)))
+	}
+	token, err := models.NewSharedSecretToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("error making new shared secret token: %w", err)
+	}
+	credential := models.NewPersonalAccessTokenCredential(now, create.IdentityID, create.Name, create.Scopes, create.ExpiresAt, token)
+	err = credential.Validate()
+	if err != nil {
+		return "", nil, err
+	}
+	err = s.Create(ctx, txOrNil, credential)
+	if err != nil {
+		return "", nil, err
+	}
+	s.Infof("Created personal access token %q for identity %q with scopes %v", credential.ID, create.IdentityID, create.Scopes)
+	return token.Public().PersonalAccessTokenString(), credential, nil
+}
+
+// ListPersonalAccessTokens returns the personal access tokens belonging to an identity.
+// Use cursor to page through results, if any.
+func (s *CredentialService) ListPersonalAccessTokens(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	pagination models.Pagination,
+) ([]*models.Credential, *models.Cursor, error) {
+	return s.credentialStore.ListCredentialsOfTypeForIdentity(ctx, txOrNil, identityID, models.CredentialTypePersonalAccessToken, pagination)
+}
+
+// RevokePersonalAccessToken permanently deletes one of an identity's personal access tokens, so that it
+// can no longer be used. Returns a not found error if the identity has no such token.
+func (s *CredentialService) RevokePersonalAccessToken(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	id models.CredentialID,
+) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		credential, err := s.credentialStore.Read(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		// Don't reveal that other identities' credentials exist
+		if credential.IdentityID != identityID || credential.Type != models.CredentialTypePersonalAccessToken {
+			return gerror.NewErrNotFound("Not Found")
+		}
+		err = s.ownershipStore.Delete(ctx, tx, credential.GetID())
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		err = s.credentialStore.Delete(ctx, tx, credential.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting credential: %w", err)
+		}
+		s.Infof("Revoked personal access token %q for identity %q", credential.ID, identityID)
+		return nil
+	})
+}
+
 // CreateClientCertificateCredential creates a new client certificate credential for the specified identity.
 // clientCert is an X.509 certificate used to identify the client.
 func (s *CredentialService) CreateClientCertificateCredential(
@@ -2,6 +2,7 @@ package credential
 
 import (
 	"crypto"
+	"crypto/ecdsa"
 	"fmt"
 	"time"
 
@@ -23,6 +24,35 @@ type BuildTokenClaims struct {
 	jwt.RegisteredClaims
 }
 
+// JobOIDCTokenClaims are the claims in an OpenID Connect ID token issued to a running job. Third parties (such as
+// cloud providers) that trust the server's OIDC issuer can grant access based on these claims.
+type JobOIDCTokenClaims struct {
+	// LegalEntity is the name of the legal entity that owns the job's repo.
+	LegalEntity string `json:"legal_entity"`
+	// Repo is the full name of the job's repo, in the format "legal-entity/repo".
+	Repo      string `json:"repo"`
+	RepoID    string `json:"repo_id"`
+	BuildID   string `json:"build_id"`
+	BuildName string `json:"build_name"`
+	// Workflow is the workflow the job is part of, or empty for the default workflow.
+	Workflow string `json:"workflow,omitempty"`
+	// Job is the fully qualified name of the job, in the format "workflow.job" (or just "job" for jobs in the
+	// default workflow).
+	Job       string `json:"job"`
+	JobID     string `json:"job_id"`
+	Ref       string `json:"ref"`
+	CommitSHA string `json:"commit_sha"`
+	jwt.RegisteredClaims
+}
+
+// CreateJobOIDCJWT signs an OpenID Connect ID token containing the supplied claims, using the ES256 algorithm.
+// keyID identifies the signing key in the issuer's JSON Web Key Set, so that verifiers can find the public key.
+func CreateJobOIDCJWT(claims *JobOIDCTokenClaims, keyID string, privateKey *ecdsa.PrivateKey) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = keyID
+	return token.SignedString(privateKey)
+}
+
 // CreateIdentityJWT creates a new JWT (JSON Web Token) credential that can be used to authenticate as
 // the specified identity. The JWT will be signed using the supplied private key.
 func CreateIdentityJWT(
@@ -0,0 +1,113 @@
+package custom_status
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/scm"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+type CustomStatusService struct {
+	db                *store.DB
+	customStatusStore store.CustomStatusStore
+	buildStore        store.BuildStore
+	repoStore         store.RepoStore
+	scmRegistry       *scm.SCMRegistry
+	logger.Log
+}
+
+func NewCustomStatusService(
+	db *store.DB,
+	customStatusStore store.CustomStatusStore,
+	buildStore store.BuildStore,
+	repoStore store.RepoStore,
+	scmRegistry *scm.SCMRegistry,
+	logFactory logger.LogFactory,
+) *CustomStatusService {
+	return &CustomStatusService{
+		db:                db,
+		customStatusStore: customStatusStore,
+		buildStore:        buildStore,
+		repoStore:         repoStore,
+		scmRegistry:       scmRegistry,
+		Log:               logFactory("CustomStatusService"),
+	}
+}
+
+// Publish creates or replaces the custom status with the specified name for a build, and relays the status
+// to the SCM for the build's repo (if any). Publishing a status that is identical to the existing status
+// with the same name is a no-op.
+func (s *CustomStatusService) Publish(ctx context.Context, txOrNil *store.Tx, publish dto.PublishCustomStatus) (*models.CustomStatus, error) {
+	now := models.NewTime(time.Now())
+	customStatus := models.NewCustomStatus(now, publish.BuildID, publish.Name, publish.State, publish.Description, publish.TargetURL)
+	err := customStatus.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		build, err := s.buildStore.Read(ctx, tx, publish.BuildID)
+		if err != nil {
+			return fmt.Errorf("error reading build: %w", err)
+		}
+		created, updated, err := s.customStatusStore.Upsert(ctx, tx, customStatus)
+		if err != nil {
+			return fmt.Errorf("error upserting custom status: %w", err)
+		}
+		if !created && !updated {
+			return nil
+		}
+		s.Infof("Custom status %q for build %q is now %q", customStatus.Name, build.ID, customStatus.State)
+		err = s.notifySCMCustomStatusUpdated(ctx, tx, build, customStatus)
+		if err != nil {
+			// Log and ignore errors while notifying SCM of custom status change
+			s.Error(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return customStatus, nil
+}
+
+// Read an existing custom status, looking it up by ID.
+// Returns models.ErrNotFound if the custom status does not exist.
+func (s *CustomStatusService) Read(ctx context.Context, txOrNil *store.Tx, id models.CustomStatusID) (*models.CustomStatus, error) {
+	return s.customStatusStore.Read(ctx, txOrNil, id)
+}
+
+// ListByBuildID lists the custom statuses published to a build.
+// Use cursor to page through results, if any.
+func (s *CustomStatusService) ListByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CustomStatus, *models.Cursor, error) {
+	return s.customStatusStore.ListByBuildID(ctx, txOrNil, buildID, pagination)
+}
+
+// notifySCMCustomStatusUpdated allows SCM-specific code to be run when a custom status for a build changes,
+// e.g. to publish the status to GitHub.
+func (s *CustomStatusService) notifySCMCustomStatusUpdated(ctx context.Context, txOrNil *store.Tx, build *models.Build, customStatus *models.CustomStatus) error {
+	repo, err := s.repoStore.Read(ctx, txOrNil, build.RepoID)
+	if err != nil {
+		return err
+	}
+	// Only notify if the repo is associated with an external SCM
+	if repo.ExternalID != nil {
+		scmName := repo.ExternalID.ExternalSystem
+		externalSCM, err := s.scmRegistry.Get(scmName)
+		if err != nil {
+			return fmt.Errorf("error getting SCM from registry for %q: %w", scmName, err)
+		}
+		err = externalSCM.NotifyCustomStatusUpdated(ctx, txOrNil, build, repo, customStatus)
+		if err != nil {
+			return fmt.Errorf("error notifying SCM %s of custom status change: %w", scmName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package custom_status_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestCustomStatusService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	otherGraph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+
+	// Invalid statuses are rejected
+	_, err = app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID: graph.ID,
+		Name:    "performance-regression",
+		State:   "maybe",
+	})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+	_, err = app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID:   graph.ID,
+		Name:      "performance-regression",
+		State:     models.CustomStatusStatePending,
+		TargetURL: "not a url",
+	})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	perf, err := app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID:     graph.ID,
+		Name:        "performance-regression",
+		State:       models.CustomStatusStatePending,
+		Description: "Running benchmarks",
+	})
+	require.NoError(t, err)
+	coverage, err := app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID:   graph.ID,
+		Name:      "coverage",
+		State:     models.CustomStatusStateFailure,
+		TargetURL: "https://coverage.example.com/report",
+	})
+	require.NoError(t, err)
+	_, err = app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID: otherGraph.ID,
+		Name:    "performance-regression",
+		State:   models.CustomStatusStateSuccess,
+	})
+	require.NoError(t, err)
+
+	// Publishing a status with an existing name replaces the existing status
+	updated, err := app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID:     graph.ID,
+		Name:        "performance-regression",
+		State:       models.CustomStatusStateSuccess,
+		Description: "No regressions found",
+	})
+	require.NoError(t, err)
+	require.Equal(t, perf.ID, updated.ID)
+	require.NotEqual(t, perf.ETag, updated.ETag)
+
+	// Publishing an identical status is a no-op
+	unchanged, err := app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID:     graph.ID,
+		Name:        "performance-regression",
+		State:       models.CustomStatusStateSuccess,
+		Description: "No regressions found",
+	})
+	require.NoError(t, err)
+	require.Equal(t, updated.ID, unchanged.ID)
+	require.Equal(t, updated.ETag, unchanged.ETag)
+
+	statuses, _, err := app.CustomStatusService.ListByBuildID(ctx, nil, graph.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	byName := make(map[models.ResourceName]*models.CustomStatus)
+	for _, status := range statuses {
+		byName[status.Name] = status
+	}
+	require.Equal(t, models.CustomStatusStateSuccess, byName["performance-regression"].State)
+	require.Equal(t, "No regressions found", byName["performance-regression"].Description)
+	require.Equal(t, coverage.ID, byName["coverage"].ID)
+	require.Equal(t, "https://coverage.example.com/report", byName["coverage"].TargetURL)
+
+	// Statuses can't be published to builds that don't exist
+	_, err = app.CustomStatusService.Publish(ctx, nil, dto.PublishCustomStatus{
+		BuildID: models.NewBuildID(),
+		Name:    "coverage",
+		State:   models.CustomStatusStateSuccess,
+	})
+	require.Error(t, err)
+	require.True(t, gerror.IsNotFound(err))
+}
@@ -0,0 +1,588 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	DefaultSMTPPort       = 587
+	DefaultDigestInterval = 24 * time.Hour
+	emailSendTimeout      = 1 * time.Minute
+	// digestPollInterval is how often to check for digest emails that are due to be sent.
+	digestPollInterval = 5 * time.Minute
+)
+
+type EmailServiceConfig struct {
+	// SMTPHost is the host name of the SMTP server to send emails via. If empty then emails are not sent.
+	SMTPHost string
+	// SMTPPort is the port of the SMTP server. Defaults to 587 if zero.
+	SMTPPort int
+	// SMTPUsername is the username to authenticate to the SMTP server with. If empty then no authentication is used.
+	SMTPUsername string
+	// SMTPPassword is the password to authenticate to the SMTP server with.
+	SMTPPassword string
+	// FromAddress is the address that emails are sent from.
+	FromAddress string
+	// WebUIBaseURL is the base URL of the web UI, used to include links to builds in emails.
+	// If empty then no links will be included.
+	WebUIBaseURL string
+	// DigestInterval is how long notifications are batched up for before a digest email is sent, for users
+	// who have opted for digests. Defaults to 24 hours if zero.
+	DigestInterval time.Duration
+}
+
+// EmailService emails users about finished builds, according to each user's email preference. Users are only
+// emailed about builds for repos owned by themselves or by an organization they are a member of, and only
+// if they are authorized to read the build. Emails are sent in the background via the work queue, or batched
+// up into a periodic digest email for users who prefer digests.
+type EmailService struct {
+	db                   *store.DB
+	emailPreferenceStore store.EmailPreferenceStore
+	digestEntryStore     store.EmailDigestEntryStore
+	buildStore           store.BuildStore
+	commitStore          store.CommitStore
+	repoStore            store.RepoStore
+	legalEntityStore     store.LegalEntityStore
+	identityStore        store.IdentityStore
+	authorizationService services.AuthorizationService
+	workQueueService     services.WorkQueueService
+	leaderElection       services.LeaderElectionService
+	config               EmailServiceConfig
+	senderMu             sync.RWMutex
+	sender               services.EmailSender
+	startStopMutex       sync.Mutex
+	exitChan             chan bool
+	wg                   sync.WaitGroup
+	logger.Log
+}
+
+func NewEmailService(
+	db *store.DB,
+	emailPreferenceStore store.EmailPreferenceStore,
+	digestEntryStore store.EmailDigestEntryStore,
+	buildStore store.BuildStore,
+	commitStore store.CommitStore,
+	repoStore store.RepoStore,
+	legalEntityStore store.LegalEntityStore,
+	identityStore store.IdentityStore,
+	authorizationService services.AuthorizationService,
+	eventService services.EventService,
+	workQueueService services.WorkQueueService,
+	leaderElection services.LeaderElectionService,
+	config EmailServiceConfig,
+	logFactory logger.LogFactory,
+) *EmailService {
+	if config.SMTPPort == 0 {
+		config.SMTPPort = DefaultSMTPPort
+	}
+	if config.DigestInterval == 0 {
+		config.DigestInterval = DefaultDigestInterval
+	}
+	s := &EmailService{
+		db:                   db,
+		emailPreferenceStore: emailPreferenceStore,
+		digestEntryStore:     digestEntryStore,
+		buildStore:           buildStore,
+		commitStore:          commitStore,
+		repoStore:            repoStore,
+		legalEntityStore:     legalEntityStore,
+		identityStore:        identityStore,
+		authorizationService: authorizationService,
+		workQueueService:     workQueueService,
+		leaderElection:       leaderElection,
+		config:               config,
+		Log:                  logFactory("EmailService"),
+	}
+	if config.SMTPHost != "" {
+		s.sender = NewSMTPSender(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.FromAddress)
+		s.Infof("Email notifications enabled using SMTP server %s:%d", config.SMTPHost, config.SMTPPort)
+	}
+
+	// Register the code to process work items for sending emails
+	err := s.workQueueService.RegisterHandler(
+		BuildEmailWorkItem,
+		s.ProcessBuildEmailWorkItem,
+		emailSendTimeout,
+		work_queue.ExponentialBackoff(10, 5*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	eventService.Subscribe(models.BuildStatusChangedEvent, s.onBuildStatusChanged)
+
+	return s
+}
+
+// SetSender sets the sender used to send emails, replacing the sender created from the service config.
+// If sender is nil then no emails will be sent.
+func (s *EmailService) SetSender(sender services.EmailSender) {
+	s.senderMu.Lock()
+	defer s.senderMu.Unlock()
+	s.sender = sender
+}
+
+func (s *EmailService) getSender() services.EmailSender {
+	s.senderMu.RLock()
+	defer s.senderMu.RUnlock()
+	return s.sender
+}
+
+// Start periodically sending digest emails in the background, whenever this server is the leader.
+func (s *EmailService) Start() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan != nil {
+		return
+	}
+	s.Trace("Starting email digest poll loop...")
+	s.exitChan = make(chan bool)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.digestPollLoop()
+	}()
+}
+
+// Stop sending digest emails, waiting for any digests currently being sent to complete.
+func (s *EmailService) Stop() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan == nil {
+		return
+	}
+	close(s.exitChan)
+	s.wg.Wait()
+	s.exitChan = nil
+}
+
+func (s *EmailService) digestPollLoop() {
+	ticker := time.NewTicker(digestPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.exitChan:
+			s.Trace("Exiting email digest poll loop...")
+			return
+		case <-ticker.C:
+			if !s.leaderElection.IsLeader() {
+				continue
+			}
+			err := s.SendDueDigests(context.Background(), models.NewTime(time.Now()))
+			if err != nil {
+				s.Errorf("Error sending digest emails: %v", err)
+			}
+		}
+	}
+}
+
+// ReadPreference reads the email preference for a user. If the user has never set a preference then
+// a default preference is returned, with email notifications disabled.
+func (s *EmailService) ReadPreference(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) (*models.EmailPreference, error) {
+	preference, err := s.emailPreferenceStore.ReadByLegalEntityID(ctx, txOrNil, legalEntityID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return s.makeDefaultPreference(legalEntityID), nil
+		}
+		return nil, err
+	}
+	return preference, nil
+}
+
+// UpdatePreference updates a user's email preference with optimistic locking, changing only the fields
+// that are set in update. The preference is created if the user has never set one.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (s *EmailService) UpdatePreference(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, update dto.UpdateEmailPreference) (*models.EmailPreference, error) {
+	var preference *models.EmailPreference
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		var (
+			err    error
+			create bool
+		)
+		preference, err = s.emailPreferenceStore.ReadByLegalEntityID(ctx, tx, legalEntityID)
+		if err != nil {
+			if !gerror.IsNotFound(err) {
+				return fmt.Errorf("error reading email preference: %w", err)
+			}
+			preference = s.makeDefaultPreference(legalEntityID)
+			create = true
+		}
+		if update.Enabled != nil {
+			preference.Enabled = *update.Enabled
+		}
+		if update.OnlyMyBuilds != nil {
+			preference.OnlyMyBuilds = *update.OnlyMyBuilds
+		}
+		if update.OnlyFailures != nil {
+			preference.OnlyFailures = *update.OnlyFailures
+		}
+		if update.Digest != nil {
+			preference.Digest = *update.Digest
+		}
+		err = preference.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+		if create {
+			err = s.emailPreferenceStore.Create(ctx, tx, preference)
+			if err != nil {
+				return fmt.Errorf("error creating email preference: %w", err)
+			}
+			s.Infof("Created email preference %q for legal entity %q", preference.ID, legalEntityID)
+			return nil
+		}
+		preference.UpdatedAt = models.NewTime(time.Now())
+		preference.ETag = models.GetETag(preference, update.ETag)
+		err = s.emailPreferenceStore.Update(ctx, tx, preference)
+		if err != nil {
+			return fmt.Errorf("error updating email preference: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return preference, nil
+}
+
+func (s *EmailService) makeDefaultPreference(legalEntityID models.LegalEntityID) *models.EmailPreference {
+	return models.NewEmailPreference(models.NewTime(time.Now()), legalEntityID, false, false, false, false)
+}
+
+// onBuildStatusChanged is called when a build's status changes, and queues emails (or digest entries) for
+// any users whose email preferences are interested in the build's outcome.
+func (s *EmailService) onBuildStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	status := models.WorkflowStatus(event.Payload)
+	if status != models.WorkflowStatusSucceeded && status != models.WorkflowStatusFailed {
+		return nil
+	}
+	if s.getSender() == nil {
+		return nil
+	}
+	build, err := s.buildStore.Read(ctx, tx, event.BuildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	repo, err := s.repoStore.Read(ctx, tx, build.RepoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	preferences, err := s.listAllEnabledForRepoOwner(ctx, tx, repo.LegalEntityID)
+	if err != nil {
+		return err
+	}
+	if len(preferences) == 0 {
+		return nil
+	}
+	commit, err := s.commitStore.Read(ctx, tx, build.CommitID)
+	if err != nil {
+		return fmt.Errorf("error reading commit: %w", err)
+	}
+	for _, preference := range preferences {
+		isMyBuild := commit.AuthorID == preference.LegalEntityID || commit.CommitterID == preference.LegalEntityID
+		if !preference.ShouldNotify(status, isMyBuild) {
+			continue
+		}
+		if preference.Digest {
+			entry := models.NewEmailDigestEntry(models.NewTime(time.Now()), preference.LegalEntityID, build.ID)
+			err = s.digestEntryStore.Create(ctx, tx, entry)
+			if err != nil {
+				return fmt.Errorf("error creating email digest entry: %w", err)
+			}
+			s.Tracef("Added build %q to email digest for legal entity %q", build.ID, preference.LegalEntityID)
+			continue
+		}
+		err = s.workQueueService.AddWorkItem(ctx, tx, NewBuildEmailWorkItem(preference.LegalEntityID, build.ID))
+		if err != nil {
+			return fmt.Errorf("error queueing build email work item: %w", err)
+		}
+		s.Tracef("Queued email for build %q to legal entity %q", build.ID, preference.LegalEntityID)
+	}
+	return nil
+}
+
+// listAllEnabledForRepoOwner reads every page of enabled email preferences for users interested in a repo owner.
+func (s *EmailService) listAllEnabledForRepoOwner(ctx context.Context, txOrNil *store.Tx, ownerLegalEntityID models.LegalEntityID) ([]*models.EmailPreference, error) {
+	var (
+		results    []*models.EmailPreference
+		pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+	)
+	for moreResults := true; moreResults; {
+		preferences, cursor, err := s.emailPreferenceStore.ListEnabledForRepoOwner(ctx, txOrNil, ownerLegalEntityID, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing email preferences: %w", err)
+		}
+		results = append(results, preferences...)
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	return results, nil
+}
+
+// ProcessBuildEmailWorkItem is a work item handler that emails a user about a finished build.
+func (s *EmailService) ProcessBuildEmailWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &BuildEmailWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling build email work item data: %w", err)
+	}
+	sender := s.getSender()
+	if sender == nil {
+		s.Warnf("Dropping email for build %q: no email sender configured", workItemData.BuildID)
+		return false, nil
+	}
+	recipient, ok, err := s.getRecipient(ctx, workItemData.LegalEntityID)
+	if err != nil {
+		return true, err
+	}
+	if !ok {
+		return false, nil
+	}
+	build, err := s.buildStore.Read(ctx, nil, workItemData.BuildID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping email for deleted build %q", workItemData.BuildID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading build: %w", err)
+	}
+	authorized, err := s.isAuthorizedToReadBuild(ctx, recipient, build)
+	if err != nil {
+		return true, err
+	}
+	if !authorized {
+		s.Infof("Dropping email for build %q: legal entity %q is not authorized to read the build", build.ID, recipient.ID)
+		return false, nil
+	}
+	summary, err := s.makeBuildSummary(ctx, build)
+	if err != nil {
+		return true, err
+	}
+	var body strings.Builder
+	summary.writeTo(&body)
+	err = sender.Send(ctx, recipient.EmailAddress, summary.subject(), body.String())
+	if err != nil {
+		return true, err
+	}
+	s.Tracef("Sent email for build %q to legal entity %q", build.ID, recipient.ID)
+	return false, nil
+}
+
+// SendDueDigests sends a digest email to each user with notifications that have been waiting for at
+// least the configured digest interval as of now.
+func (s *EmailService) SendDueDigests(ctx context.Context, now models.Time) error {
+	sender := s.getSender()
+	if sender == nil {
+		return nil
+	}
+	legalEntityIDs, err := s.digestEntryStore.ListLegalEntitiesDue(ctx, nil, models.NewTime(now.Add(-s.config.DigestInterval)))
+	if err != nil {
+		return fmt.Errorf("error listing legal entities with due digests: %w", err)
+	}
+	var firstErr error
+	for _, legalEntityID := range legalEntityIDs {
+		err := s.sendDigest(ctx, sender, legalEntityID)
+		if err != nil {
+			// Don't let one user's digest stop others from being sent
+			s.Errorf("Error sending digest email to legal entity %q: %v", legalEntityID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// sendDigest sends a single digest email to a user, covering all of their waiting digest entries.
+// The entries are deleted once the email has been sent.
+func (s *EmailService) sendDigest(ctx context.Context, sender services.EmailSender, legalEntityID models.LegalEntityID) error {
+	var (
+		entries    []*models.EmailDigestEntry
+		pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+	)
+	for moreResults := true; moreResults; {
+		page, cursor, err := s.digestEntryStore.ListByLegalEntityID(ctx, nil, legalEntityID, pagination)
+		if err != nil {
+			return fmt.Errorf("error listing email digest entries: %w", err)
+		}
+		entries = append(entries, page...)
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	recipient, ok, err := s.getRecipient(ctx, legalEntityID)
+	if err != nil {
+		return err
+	}
+	if ok {
+		var summaries []*buildSummary
+		for i := len(entries) - 1; i >= 0; i-- { // entries are listed newest first
+			build, err := s.buildStore.Read(ctx, nil, entries[i].BuildID)
+			if err != nil {
+				if gerror.IsNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("error reading build: %w", err)
+			}
+			authorized, err := s.isAuthorizedToReadBuild(ctx, recipient, build)
+			if err != nil {
+				return err
+			}
+			if !authorized {
+				continue
+			}
+			summary, err := s.makeBuildSummary(ctx, build)
+			if err != nil {
+				return err
+			}
+			summaries = append(summaries, summary)
+		}
+		if len(summaries) > 0 {
+			var body strings.Builder
+			for i, summary := range summaries {
+				if i > 0 {
+					body.WriteString("\n")
+				}
+				summary.writeTo(&body)
+			}
+			subject := fmt.Sprintf("[BuildBeaver] Digest: %d finished build(s)", len(summaries))
+			err = sender.Send(ctx, recipient.EmailAddress, subject, body.String())
+			if err != nil {
+				return err
+			}
+			s.Tracef("Sent digest email covering %d build(s) to legal entity %q", len(summaries), legalEntityID)
+		}
+	}
+	for _, entry := range entries {
+		err = s.digestEntryStore.Delete(ctx, nil, entry.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting email digest entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// getRecipient reads the legal entity to send an email to. Returns false if the legal entity no longer
+// exists or has no email address, in which case no email should be sent.
+func (s *EmailService) getRecipient(ctx context.Context, legalEntityID models.LegalEntityID) (*models.LegalEntity, bool, error) {
+	legalEntity, err := s.legalEntityStore.Read(ctx, nil, legalEntityID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping email for deleted legal entity %q", legalEntityID)
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("error reading legal entity: %w", err)
+	}
+	if legalEntity.EmailAddress == "" {
+		s.Infof("Dropping email for legal entity %q: no email address", legalEntityID)
+		return nil, false, nil
+	}
+	return legalEntity, true, nil
+}
+
+// isAuthorizedToReadBuild returns true if the legal entity is authorized to read the build.
+func (s *EmailService) isAuthorizedToReadBuild(ctx context.Context, legalEntity *models.LegalEntity, build *models.Build) (bool, error) {
+	identity, err := s.identityStore.ReadByOwnerResource(ctx, nil, legalEntity.ID.ResourceID)
+	if err != nil {
+		return false, fmt.Errorf("error reading identity for legal entity: %w", err)
+	}
+	authorized, err := s.authorizationService.IsAuthorized(ctx, identity.ID, models.BuildReadOperation, build.ID.ResourceID)
+	if err != nil {
+		return false, fmt.Errorf("error checking authorization: %w", err)
+	}
+	return authorized, nil
+}
+
+// buildSummary contains the details of a finished build to include in an email.
+type buildSummary struct {
+	repoName  string
+	buildName string
+	ref       string
+	status    models.WorkflowStatus
+	error     string
+	buildURL  string
+}
+
+func (m *buildSummary) subject() string {
+	return fmt.Sprintf("[BuildBeaver] %s #%s (%s) %s", m.repoName, m.buildName, m.ref, m.status)
+}
+
+func (m *buildSummary) writeTo(b *strings.Builder) {
+	fmt.Fprintf(b, "Build %s #%s (%s) %s\n", m.repoName, m.buildName, m.ref, m.status)
+	if m.error != "" {
+		fmt.Fprintf(b, "Error: %s\n", m.error)
+	}
+	if m.buildURL != "" {
+		fmt.Fprintf(b, "%s\n", m.buildURL)
+	}
+}
+
+func (s *EmailService) makeBuildSummary(ctx context.Context, build *models.Build) (*buildSummary, error) {
+	repo, err := s.repoStore.Read(ctx, nil, build.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	summary := &buildSummary{
+		repoName:  repo.Name.String(),
+		buildName: build.Name.String(),
+		ref:       build.Ref,
+		status:    build.Status,
+	}
+	if build.Error != nil {
+		summary.error = build.Error.Error()
+	}
+	if s.config.WebUIBaseURL != "" {
+		repoOwner, err := s.legalEntityStore.Read(ctx, nil, repo.LegalEntityID)
+		if err != nil {
+			return nil, fmt.Errorf("error reading repo owner: %w", err)
+		}
+		summary.buildURL, err = s.makeWebUIBuildURL(repoOwner, repo, build)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return summary, nil
+}
+
+func (s *EmailService) makeWebUIBuildURL(repoOwner *models.LegalEntity, repo *models.Repo, build *models.Build) (string, error) {
+	var orgsOrUsers string
+	switch repoOwner.Type {
+	case models.LegalEntityTypeCompany:
+		orgsOrUsers = "orgs"
+	case models.LegalEntityTypePerson:
+		orgsOrUsers = "users"
+	default:
+		return "", fmt.Errorf("error unknown type of Legal Entity '%s' for repo owner, name '%s", repoOwner.Type, repoOwner.Name)
+	}
+	baseURL := strings.TrimSuffix(s.config.WebUIBaseURL, "/")
+	return fmt.Sprintf("%s/%s/%s/repos/%s/builds/%s",
+		baseURL,
+		orgsOrUsers,
+		url.QueryEscape(repoOwner.GetName().String()),
+		url.QueryEscape(repo.GetName().String()),
+		url.QueryEscape(build.GetName().String()),
+	), nil
+}
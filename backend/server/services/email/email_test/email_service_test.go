@@ -0,0 +1,181 @@
+package email_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testEmailTimeout = 30 * time.Second
+
+type sentEmail struct {
+	to      string
+	subject string
+	body    string
+}
+
+// testSender records emails instead of sending them via an SMTP server.
+type testSender struct {
+	mu     sync.Mutex
+	emails []sentEmail
+}
+
+func (s *testSender) Send(ctx context.Context, to string, subject string, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emails = append(s.emails, sentEmail{to: to, subject: subject, body: body})
+	return nil
+}
+
+// waitForEmails waits until at least n emails have been sent, and returns all emails sent so far.
+func (s *testSender) waitForEmails(t *testing.T, n int) []sentEmail {
+	deadline := time.Now().Add(testEmailTimeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		if len(s.emails) >= n {
+			emails := append([]sentEmail(nil), s.emails...)
+			s.mu.Unlock()
+			return emails
+		}
+		s.mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d emails", n)
+	return nil
+}
+
+func (s *testSender) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.emails)
+}
+
+func TestEmailService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	sender := &testSender{}
+	app.EmailService.SetSender(sender)
+
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "email-test-company", "", "")
+	server_test.CreateRunner(t, ctx, app, "", company.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, company.ID)
+
+	readOnlyUserGroup, err := app.GroupService.ReadByName(ctx, nil, company.ID, models.ReadOnlyUserStandardGroup.Name)
+	require.NoError(t, err)
+	addMember := func(name models.ResourceName, emailAddress string, canReadBuilds bool) *models.LegalEntity {
+		member, identity := server_test.CreatePersonLegalEntity(t, ctx, app, name, "", emailAddress)
+		err := app.LegalEntityService.AddCompanyMember(ctx, nil, company.ID, member.ID)
+		require.NoError(t, err)
+		if canReadBuilds {
+			_, _, err = app.GroupService.FindOrCreateMembership(ctx, nil, models.NewGroupMembershipData(
+				readOnlyUserGroup.ID, identity.ID, models.TestsSystem, company.ID))
+			require.NoError(t, err)
+		}
+		return member
+	}
+	everything := addMember("everything", "everything@example.com", true)
+	failures := addMember("failures", "failures@example.com", true)
+	mine := addMember("mine", "mine@example.com", true)
+	digest := addMember("digest", "digest@example.com", true)
+	disabled := addMember("disabled", "disabled@example.com", true)
+	noAccess := addMember("no-access", "no-access@example.com", false)
+	outsider, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "outsider", "", "outsider@example.com")
+
+	// Users without a preference have emails disabled
+	preference, err := app.EmailService.ReadPreference(ctx, nil, disabled.ID)
+	require.NoError(t, err)
+	require.False(t, preference.Enabled)
+
+	enabled := true
+	setPreference := func(legalEntityID models.LegalEntityID, update dto.UpdateEmailPreference) {
+		update.Enabled = &enabled
+		preference, err := app.EmailService.UpdatePreference(ctx, nil, legalEntityID, update)
+		require.NoError(t, err)
+		require.True(t, preference.Enabled)
+	}
+	setPreference(everything.ID, dto.UpdateEmailPreference{})
+	setPreference(failures.ID, dto.UpdateEmailPreference{OnlyFailures: &enabled})
+	setPreference(mine.ID, dto.UpdateEmailPreference{OnlyMyBuilds: &enabled})
+	setPreference(digest.ID, dto.UpdateEmailPreference{Digest: &enabled})
+	setPreference(noAccess.ID, dto.UpdateEmailPreference{})
+	setPreference(outsider.ID, dto.UpdateEmailPreference{})
+
+	// Updating an existing preference only changes the fields specified
+	setPreference(everything.ID, dto.UpdateEmailPreference{})
+	preference, err = app.EmailService.ReadPreference(ctx, nil, everything.ID)
+	require.NoError(t, err)
+	require.True(t, preference.Enabled)
+	require.False(t, preference.OnlyFailures)
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	finishBuild := func(author models.LegalEntityID, status models.WorkflowStatus) *models.Build {
+		graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, author, "")
+		build := graph.Build
+		build.Status = status
+		if status == models.WorkflowStatusFailed {
+			build.Error = models.NewError(gerror.NewErrValidationFailed("test failure"))
+		}
+		err := app.BuildService.Update(ctx, nil, build)
+		require.NoError(t, err)
+		err = app.EventService.PublishEvent(ctx, nil, models.NewBuildStatusChangedEventData(build))
+		require.NoError(t, err)
+		return build
+	}
+	recipients := func(emails []sentEmail) []string {
+		var to []string
+		for _, email := range emails {
+			to = append(to, email.to)
+		}
+		sort.Strings(to)
+		return to
+	}
+
+	// A successful build by someone else only goes to the user who wants everything
+	build1 := finishBuild(company.ID, models.WorkflowStatusSucceeded)
+	emails := sender.waitForEmails(t, 1)
+	require.Equal(t, []string{"everything@example.com"}, recipients(emails))
+	require.Contains(t, emails[0].subject, build1.Name.String())
+
+	// A failed build by the user who wants only their own builds goes to everyone interested who can read the build
+	build2 := finishBuild(mine.ID, models.WorkflowStatusFailed)
+	emails = sender.waitForEmails(t, 4)
+	require.Equal(t, []string{"everything@example.com", "failures@example.com", "mine@example.com"}, recipients(emails[1:]))
+	require.Contains(t, emails[1].body, "test failure")
+	time.Sleep(time.Second)
+	require.Equal(t, 4, sender.count())
+
+	// Digests are not sent until they are due
+	err = app.EmailService.SendDueDigests(ctx, models.NewTime(time.Now()))
+	require.NoError(t, err)
+	require.Equal(t, 4, sender.count())
+
+	// A single digest covers all builds since the last digest
+	err = app.EmailService.SendDueDigests(ctx, models.NewTime(time.Now().Add(48*time.Hour)))
+	require.NoError(t, err)
+	emails = sender.waitForEmails(t, 5)
+	require.Equal(t, "digest@example.com", emails[4].to)
+	require.Contains(t, emails[4].body, "#"+build1.Name.String())
+	require.Contains(t, emails[4].body, "#"+build2.Name.String())
+
+	// Digest entries are removed once sent
+	err = app.EmailService.SendDueDigests(ctx, models.NewTime(time.Now().Add(48*time.Hour)))
+	require.NoError(t, err)
+	require.Equal(t, 5, sender.count())
+}
@@ -0,0 +1,37 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// BuildEmailWorkItem is a work item that will email a user about a finished build.
+const BuildEmailWorkItem models.WorkItemType = "BuildEmail"
+
+// BuildEmailWorkItemData is serialized to JSON and stored in the Data field of a BuildEmailWorkItem.
+// The user's email address is deliberately not included; it is read when the work item is processed so
+// that changes to the address (or to the user's access to the build) are respected.
+type BuildEmailWorkItemData struct {
+	LegalEntityID models.LegalEntityID
+	BuildID       models.BuildID
+}
+
+func NewBuildEmailWorkItem(legalEntityID models.LegalEntityID, buildID models.BuildID) *models.WorkItem {
+	data := &BuildEmailWorkItemData{
+		LegalEntityID: legalEntityID,
+		BuildID:       buildID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in BuildEmailWorkItemData definition
+		panic("Unable to marshal BuildEmailWorkItemData object to JSON")
+	}
+
+	// Concurrency key is per user, so each user receives their emails in order
+	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("build-email/%s", legalEntityID))
+
+	return models.NewWorkItem(BuildEmailWorkItem, string(dataJson), concurrencyKey, models.NewTime(time.Now()))
+}
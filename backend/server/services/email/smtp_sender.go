@@ -0,0 +1,71 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends emails via an SMTP server. STARTTLS is used if the server supports it.
+type SMTPSender struct {
+	host        string
+	port        int
+	username    string
+	password    string
+	fromAddress string
+}
+
+func NewSMTPSender(host string, port int, username string, password string, fromAddress string) *SMTPSender {
+	return &SMTPSender{
+		host:        host,
+		port:        port,
+		username:    username,
+		password:    password,
+		fromAddress: fromAddress,
+	}
+}
+
+// Send a plain text email to the specified address.
+func (s *SMTPSender) Send(ctx context.Context, to string, subject string, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("error email address and subject must not contain line breaks")
+	}
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+	message := s.makeMessage(to, subject, body)
+
+	// smtp.SendMail doesn't accept a context, so run it in the background and give up if the context is done
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- smtp.SendMail(addr, auth, s.fromAddress, []string{to}, message)
+	}()
+	select {
+	case err := <-errChan:
+		if err != nil {
+			return fmt.Errorf("error sending email via %s: %w", addr, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error sending email via %s: %w", addr, ctx.Err())
+	}
+}
+
+func (s *SMTPSender) makeMessage(to string, subject string, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.fromAddress)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -2,17 +2,21 @@ package event
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
 	"github.com/buildbeaver/buildbeaver/server/store"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 )
 
 type EventService struct {
-	db         *store.DB
-	eventStore store.EventStore
+	db            *store.DB
+	eventStore    store.EventStore
+	subscribersMu sync.RWMutex
+	subscribers   map[models.EventType][]services.EventHandler
 	logger.Log
 }
 
@@ -22,12 +26,22 @@ func NewEventService(
 	logFactory logger.LogFactory,
 ) *EventService {
 	return &EventService{
-		db:         db,
-		eventStore: eventStore,
-		Log:        logFactory("EventService"),
+		db:          db,
+		eventStore:  eventStore,
+		subscribers: make(map[models.EventType][]services.EventHandler),
+		Log:         logFactory("EventService"),
 	}
 }
 
+// Subscribe registers a handler to be called each time an event of the specified type is published.
+// Handlers are called synchronously inside the publishing transaction so must not block for long;
+// any slow work (e.g. calling external systems) should be queued as a work item.
+func (s *EventService) Subscribe(eventType models.EventType, handler services.EventHandler) {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+	s.subscribers[eventType] = append(s.subscribers[eventType], handler)
+}
+
 // PublishEvent publishes a new event. Subscribers matching the event type and resource will be notified.
 func (s *EventService) PublishEvent(ctx context.Context, txOrNil *store.Tx, eventData *models.EventData) error {
 	err := eventData.Validate()
@@ -48,10 +62,23 @@ func (s *EventService) PublishEvent(ctx context.Context, txOrNil *store.Tx, even
 
 		// TODO: Change this to trace level logging
 		s.Infof("Created event, ID=%q, SequenceNumber=%d", event.ID, event.SequenceNumber)
+
+		for _, handler := range s.getSubscribers(event.Type) {
+			err = handler(ctx, tx, event)
+			if err != nil {
+				return fmt.Errorf("error notifying subscriber of %s event: %w", event.Type, err)
+			}
+		}
 		return nil
 	})
 }
 
+func (s *EventService) getSubscribers(eventType models.EventType) []services.EventHandler {
+	s.subscribersMu.RLock()
+	defer s.subscribersMu.RUnlock()
+	return s.subscribers[eventType]
+}
+
 // FetchEvents fetches new events for a given build, i.e. those with event numbers greater than lastEventNumber.
 // limit specifies the maximum number of events to return.
 // Events will be returned in order of event number; event numbers provide a unique ordering within a build.
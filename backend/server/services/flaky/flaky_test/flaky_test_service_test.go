@@ -0,0 +1,134 @@
+package flaky_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testIngestTimeout = 30 * time.Second
+
+// firstReport fails TestDivide, and TestRetry fails and then passes when it is retried
+const firstReport = `<testsuite name="math">
+  <testcase classname="math" name="TestAdd" time="0.1"/>
+  <testcase classname="math" name="TestDivide" time="0.2"><failure message="expected 2"/></testcase>
+  <testcase classname="math" name="TestRetry" time="0.1"><failure message="timed out"/></testcase>
+  <testcase classname="math" name="TestRetry" time="0.1"/>
+</testsuite>`
+
+// secondReport passes TestDivide against the same commit, and fails TestSubtract
+const secondReport = `<testsuite name="math">
+  <testcase classname="math" name="TestAdd" time="0.1"/>
+  <testcase classname="math" name="TestDivide" time="0.2"/>
+  <testcase classname="math" name="TestSubtract" time="0.1"><failure message="expected 0"/></testcase>
+</testsuite>`
+
+func TestFlakyTestService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	// Build the same commit twice
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	firstBuild, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	secondBuild, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, firstBuild.ID, secondBuild.ID)
+	firstJob := firstBuild.Jobs[0]
+	secondJob := secondBuild.Jobs[0]
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	waitForRun := func(buildID models.BuildID) {
+		deadline := time.Now().Add(testIngestTimeout)
+		for time.Now().Before(deadline) {
+			_, err := app.TestResultService.ReadSummary(ctx, nil, buildID)
+			if err == nil {
+				return
+			}
+			require.True(t, gerror.IsNotFound(err))
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for test results to be ingested for build %q", buildID)
+	}
+	listFlakyTests := func() map[string]*models.FlakyTest {
+		flakyTests, _, err := app.FlakyTestService.ListFlakyTests(ctx, nil, repo.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+		require.NoError(t, err)
+		byName := make(map[string]*models.FlakyTest, len(flakyTests))
+		for _, flakyTest := range flakyTests {
+			byName[flakyTest.Name] = flakyTest
+		}
+		return byName
+	}
+
+	// A test that fails and then passes within a single run is flaky
+	_, err = app.ArtifactService.Create(ctx, firstJob.ID, models.TestResultsArtifactGroupName, "junit.xml", "", bytes.NewReader([]byte(firstReport)), true)
+	require.NoError(t, err)
+	waitForRun(firstBuild.ID)
+	flakyTests := listFlakyTests()
+	require.Len(t, flakyTests, 1)
+	require.Contains(t, flakyTests, "TestRetry")
+
+	// A test that fails in one build and passes in another build of the same commit is flaky,
+	// but a test that fails consistently isn't
+	_, err = app.ArtifactService.Create(ctx, secondJob.ID, models.TestResultsArtifactGroupName, "junit.xml", "", bytes.NewReader([]byte(secondReport)), true)
+	require.NoError(t, err)
+	waitForRun(secondBuild.ID)
+	flakyTests = listFlakyTests()
+	require.Len(t, flakyTests, 2)
+	divide := flakyTests["TestDivide"]
+	require.NotNil(t, divide)
+	require.Equal(t, "math", divide.Suite)
+	require.Equal(t, 1, divide.Detections)
+	require.Equal(t, secondBuild.ID, divide.LastBuildID)
+	require.Equal(t, commit.ID, divide.LastCommitID)
+	require.NotContains(t, flakyTests, "TestSubtract")
+	require.NotContains(t, flakyTests, "TestAdd")
+
+	// The first job's only failing test is known to be flaky, but the job is only annotated once it has failed
+	jobFlakyTests, err := app.FlakyTestService.ReadJobFlakyTests(ctx, nil, firstJob.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, jobFlakyTests.Failed)
+	require.Len(t, jobFlakyTests.KnownFlaky, 1)
+	require.Equal(t, divide.ID, jobFlakyTests.KnownFlaky[0].ID)
+	require.False(t, jobFlakyTests.FailedDueToFlakyTests)
+
+	job, err := app.JobStore.Read(ctx, nil, firstJob.ID)
+	require.NoError(t, err)
+	job.Status = models.WorkflowStatusFailed
+	err = app.JobStore.Update(ctx, nil, job)
+	require.NoError(t, err)
+	jobFlakyTests, err = app.FlakyTestService.ReadJobFlakyTests(ctx, nil, firstJob.ID)
+	require.NoError(t, err)
+	require.True(t, jobFlakyTests.FailedDueToFlakyTests)
+
+	// A job with a failing test that isn't known to be flaky isn't annotated
+	job, err = app.JobStore.Read(ctx, nil, secondJob.ID)
+	require.NoError(t, err)
+	job.Status = models.WorkflowStatusFailed
+	err = app.JobStore.Update(ctx, nil, job)
+	require.NoError(t, err)
+	jobFlakyTests, err = app.FlakyTestService.ReadJobFlakyTests(ctx, nil, secondJob.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, jobFlakyTests.Failed)
+	require.Empty(t, jobFlakyTests.KnownFlaky)
+	require.False(t, jobFlakyTests.FailedDueToFlakyTests)
+}
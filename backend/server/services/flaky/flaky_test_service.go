@@ -0,0 +1,147 @@
+package flaky
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// FlakyTestService detects tests that fail intermittently. Each test run is checked as it is ingested, and
+// a test is flagged as flaky if it failed on some attempts and passed on others within the run, or if it
+// passed in some test runs and failed in others that ran against the same commit. Flagged tests are used
+// to tell whether a failed job failed only because of known flaky tests.
+type FlakyTestService struct {
+	flakyTestStore store.FlakyTestStore
+	testCaseStore  store.TestCaseStore
+	jobStore       store.JobStore
+	logger.Log
+}
+
+func NewFlakyTestService(
+	flakyTestStore store.FlakyTestStore,
+	testCaseStore store.TestCaseStore,
+	jobStore store.JobStore,
+	logFactory logger.LogFactory,
+) *FlakyTestService {
+	return &FlakyTestService{
+		flakyTestStore: flakyTestStore,
+		testCaseStore:  testCaseStore,
+		jobStore:       jobStore,
+		Log:            logFactory("FlakyTestService"),
+	}
+}
+
+// RecordTestRun checks the test cases in a test run reported by a job for flakiness, and flags any flaky
+// tests that are found. Must be called in the transaction that created the test run and its test cases.
+func (s *FlakyTestService) RecordTestRun(ctx context.Context, tx *store.Tx, job *models.Job, testRun *models.TestRun, testCases []*models.TestCase) error {
+	counts, err := s.testCaseStore.CountStatusesByCommitID(ctx, tx, testRun.RepoID, job.CommitID)
+	if err != nil {
+		return fmt.Errorf("error counting test cases for commit: %w", err)
+	}
+	type testKey struct {
+		suite string
+		name  string
+	}
+	passedAtCommit := make(map[testKey]bool)
+	failedAtCommit := make(map[testKey]bool)
+	for _, count := range counts {
+		key := testKey{suite: count.Suite, name: count.Name}
+		switch count.Status {
+		case models.TestCaseStatusPassed:
+			passedAtCommit[key] = true
+		case models.TestCaseStatusFailed:
+			failedAtCommit[key] = true
+		case models.TestCaseStatusFlaky:
+			passedAtCommit[key] = true
+			failedAtCommit[key] = true
+		}
+	}
+
+	now := models.NewTime(time.Now())
+	for _, testCase := range testCases {
+		if testCase.Status == models.TestCaseStatusSkipped {
+			continue
+		}
+		key := testKey{suite: testCase.Suite, name: testCase.Name}
+		if !passedAtCommit[key] || !failedAtCommit[key] {
+			continue
+		}
+		err = s.recordDetection(ctx, tx, now, job, testCase)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordDetection flags the test for a test case as flaky, or records another detection if it is already flagged.
+func (s *FlakyTestService) recordDetection(ctx context.Context, tx *store.Tx, now models.Time, job *models.Job, testCase *models.TestCase) error {
+	flakyTest, err := s.flakyTestStore.ReadByName(ctx, tx, testCase.RepoID, testCase.Suite, testCase.Name)
+	if err != nil {
+		if !gerror.IsNotFound(err) {
+			return fmt.Errorf("error reading flaky test: %w", err)
+		}
+		flakyTest = models.NewFlakyTest(now, testCase.RepoID, testCase.Suite, testCase.Name)
+		flakyTest.Detected(now, testCase, job.CommitID)
+		err = s.flakyTestStore.Create(ctx, tx, flakyTest)
+		if err != nil {
+			return fmt.Errorf("error creating flaky test: %w", err)
+		}
+		s.Infof("Detected flaky test %q in suite %q for repo %q", testCase.Name, testCase.Suite, testCase.RepoID)
+		return nil
+	}
+	flakyTest.Detected(now, testCase, job.CommitID)
+	err = s.flakyTestStore.Update(ctx, tx, flakyTest)
+	if err != nil {
+		return fmt.Errorf("error updating flaky test: %w", err)
+	}
+	return nil
+}
+
+// ListFlakyTests lists the tests in a repo that are known to be flaky, newest first.
+// Use cursor to page through results, if any.
+func (s *FlakyTestService) ListFlakyTests(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.FlakyTest, *models.Cursor, error) {
+	return s.flakyTestStore.ListByRepoID(ctx, txOrNil, repoID, pagination)
+}
+
+// ReadJobFlakyTests checks which of the tests that failed in a job are known to be flaky, to tell whether
+// the job failed only because of flaky tests.
+func (s *FlakyTestService) ReadJobFlakyTests(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) (*dto.JobFlakyTests, error) {
+	job, err := s.jobStore.Read(ctx, txOrNil, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading job: %w", err)
+	}
+	failed, err := s.testCaseStore.ListFailedByJobID(ctx, txOrNil, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing failed test cases: %w", err)
+	}
+	result := &dto.JobFlakyTests{
+		Job:        job,
+		Failed:     len(failed),
+		KnownFlaky: []*models.FlakyTest{},
+	}
+	unexplained := 0
+	seen := make(map[models.FlakyTestID]bool)
+	for _, testCase := range failed {
+		flakyTest, err := s.flakyTestStore.ReadByName(ctx, txOrNil, job.RepoID, testCase.Suite, testCase.Name)
+		if err != nil {
+			if !gerror.IsNotFound(err) {
+				return nil, fmt.Errorf("error reading flaky test: %w", err)
+			}
+			unexplained++
+			continue
+		}
+		if !seen[flakyTest.ID] {
+			seen[flakyTest.ID] = true
+			result.KnownFlaky = append(result.KnownFlaky, flakyTest)
+		}
+	}
+	result.FailedDueToFlakyTests = job.Status == models.WorkflowStatusFailed && len(failed) > 0 && unexplained == 0
+	return result, nil
+}
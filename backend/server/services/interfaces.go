@@ -4,12 +4,14 @@ import (
 	"context"
 	"crypto/rsa"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/common/models/search"
 	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
 	"github.com/buildbeaver/buildbeaver/server/services/scm"
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
@@ -20,20 +22,51 @@ type QueueService interface {
 	// set to failed with an error describing the problem, and no error will be returned from this function.
 	// Returns an error only if there was a transient issue that could be retried.
 	EnqueueBuildFromCommit(ctx context.Context, txOrNil *store.Tx, commit *models.Commit, ref string, opts *models.BuildOptions) (*dto.BuildGraph, error)
+	// EnqueueBuildFromRef enqueues a new build of the commit with the specified SHA, or of the commit at the head
+	// of ref if sha is empty, reading the commit from the repo's SCM if it isn't already in the database. The
+	// build is recorded as a build of ref, which defaults to the repo's default branch. Branch names are
+	// converted to refs (e.g. main becomes refs/heads/main).
+	// The caller must not have a transaction open, since the SCM may be contacted.
+	EnqueueBuildFromRef(ctx context.Context, repoID models.RepoID, ref string, sha string, opts *models.BuildOptions) (*dto.BuildGraph, error)
+	// ValidateBuildConfig parses the supplied build configuration as though it had been committed to the specified
+	// repo, without enqueuing a build. Templates in the repo itself are loaded from gitRef, or from the repo's
+	// default branch if gitRef is empty. Returns a validation failed error if the build configuration is invalid.
+	ValidateBuildConfig(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, config []byte, configType models.ConfigType, gitRef string) (*models.BuildDefinition, error)
+	// ParseBuildConfig parses the supplied build configuration, enforcing the same limits as enqueued builds.
+	// Any templates the configuration references are loaded using templateLoader, or are not supported if
+	// templateLoader is nil.
+	ParseBuildConfig(config []byte, configType models.ConfigType, templateLoader parser.TemplateLoader) (*models.BuildDefinition, error)
+	// MakeBuildGraph makes and validates the graph of jobs that a build of the specified ref in the repo would
+	// contain for buildDef, without enqueuing a build. Returns a validation failed or limit exceeded error if
+	// the jobs don't form a valid build.
+	MakeBuildGraph(repoID models.RepoID, ref string, buildDef *models.BuildDefinition) (*dto.BuildGraph, error)
 	// EnqueueBuildFromBuildDefinition enqueues a new build based on the specified build definition, which is assumed
 	// to have come from the specified commit. Unlike EnqueueBuildFromCommit this function will return an error
 	// if there is a problem with the build definition (as well as any transient errors).
 	EnqueueBuildFromBuildDefinition(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, commitID models.CommitID, buildDef *models.BuildDefinition, ref string, opts *models.BuildOptions) (*dto.BuildGraph, error)
+	// CloneBuild enqueues a new build for the same commit and ref as a finished build, containing the jobs
+	// recorded for the finished build (including any jobs that were added dynamically) with the changes
+	// specified in clone applied. Returns a validation error if the build has not finished.
+	CloneBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, clone dto.CloneBuild) (*dto.BuildGraph, error)
 	// AddConfigToBuild enqueues new jobs for an existing build, taken from the supplied build configuration.
 	// Returns the full build graph containing both existing and new jobs, as well as an array containing just the new jobs.
 	// This function will return an error if there is a problem with the jobs, as well as any transient errors.
 	AddConfigToBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, config []byte, configType models.ConfigType) (*dto.BuildGraph, []*dto.JobGraph, error)
+	// UpdateConfigInBuild updates jobs that are already part of an existing build, taken from the supplied build
+	// configuration. Each job must be queued and not yet handed to a runner; only the job's environment variables
+	// and dependencies can be changed, and new steps can be added.
+	// Returns the full build graph, as well as an array containing just the updated jobs.
+	UpdateConfigInBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, config []byte, configType models.ConfigType) (*dto.BuildGraph, []*dto.JobGraph, error)
 	// CheckBuildConfigLength returns an error if the supplied length (in bytes) is too long for a build configuration,
 	// or if the configuration is empty.
 	CheckBuildConfigLength(buildDefinitionLength int) error
 	// Dequeue returns the next queued job that is ready for execution and that the specified
 	// runner is capable of running, or a ErrCodeNotFound if no jobs are ready for execution.
 	Dequeue(ctx context.Context, runnerID models.RunnerID) (*dto.RunnableJob, error)
+	// DequeueWithWait returns the next queued job that is ready for execution and that the specified runner is
+	// capable of running. If no jobs are ready then waits for up to wait for a job to become ready, returning
+	// a ErrCodeNotFound if none did.
+	DequeueWithWait(ctx context.Context, runnerID models.RunnerID, wait time.Duration) (*dto.RunnableJob, error)
 	// UpdateJobStatus updates the status of a job that was previously dequeued. If the new status is
 	// WorkflowStatusFailed then an error should be provided to indicate what happened.
 	// This function will maintain the status of the build containing this job, to reflect the overall
@@ -46,10 +79,22 @@ type QueueService interface {
 	// UpdateStepStatus updates the status of a step that is executing under a job that was previously dequeued.
 	// If the new status is WorkflowStatusFailed then an error should be provided to indicate what happened.
 	UpdateStepStatus(ctx context.Context, txOrNil *store.Tx, stepID models.StepID, update dto.UpdateStepStatus) (*models.Step, error)
+	// FailBuild marks a build that has succeeded as failed with the specified error, for use when a check made
+	// after all the build's jobs have finished (such as a code coverage threshold) does not pass.
+	// Builds that have not succeeded are returned unchanged.
+	FailBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, buildError *models.Error) (*models.Build, error)
+	// UpdateBuildPriority changes the priority of a build that has not finished, so its remaining queued jobs
+	// are handed to runners ahead of (or behind) jobs from other builds. Returns a validation error if the
+	// build has finished.
+	UpdateBuildPriority(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, update dto.UpdateBuildPriority) (*models.Build, error)
 	// ReadQueuedBuild makes a queued build DTO including all child jobs and steps.
 	ReadQueuedBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) (*dto.QueuedBuild, error)
 	// ReadJobGraph makes and returns a JobGraph for the specified job.
 	ReadJobGraph(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) (*dto.JobGraph, error)
+	// RecoverOrphanedJobs finds jobs that were handed to runners which have since stopped sending heartbeats, and
+	// fails them (or queues them to run again if the job is configured to retry on runner loss). A diagnostic
+	// message is written to each recovered job's logs. Returns the number of jobs that were recovered.
+	RecoverOrphanedJobs(ctx context.Context) (int, error)
 }
 
 type LogService interface {
@@ -59,6 +104,15 @@ type LogService interface {
 	// Read an existing log descriptor, looking it up by ID.
 	// Returns models.ErrNotFound if the log descriptor does not exist.
 	Read(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) (*models.LogDescriptor, error)
+	// ReadWithWait reads an existing log descriptor. If the log has not been sealed then waits for up to wait
+	// for it to be sealed, and returns the descriptor as it stands when the log is sealed or the wait expires.
+	ReadWithWait(ctx context.Context, id models.LogDescriptorID, wait time.Duration) (*models.LogDescriptor, error)
+	// ListByIDs reads the log descriptors with the specified IDs, in no particular order. IDs of log descriptors
+	// that do not exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.LogDescriptorID) ([]*models.LogDescriptor, error)
+	// ReadSize returns the size in bytes of a log descriptor's data written so far. The size of a log that is
+	// still being written is calculated from its data, so this should not be called more often than necessary.
+	ReadSize(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) (int64, error)
 	// Seal a log descriptor and its data, making it immutable going forward.
 	Seal(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) error
 	// Search all log descriptors. If searcher is set, the results will be limited to log descriptors the searcher
@@ -66,8 +120,23 @@ type LogService interface {
 	Search(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, search models.LogDescriptorSearch) ([]*models.LogDescriptor, *models.Cursor, error)
 	// WriteData pipes data from reader and writes it to the log descriptor's data.
 	WriteData(ctx context.Context, logDescriptorID models.LogDescriptorID, reader io.Reader) error
-	// ReadData opens a read stream to a log descriptor's data.
+	// AppendError writes an error line to the end of a log, after any entries already written to it. This is used
+	// to record why a log is being closed when its writer (e.g. a runner) can no longer write to it.
+	AppendError(ctx context.Context, logDescriptorID models.LogDescriptorID, text string) error
+	// ReadData opens a read stream to a log descriptor's data. If search.Follow is set then the stream
+	// remains open and new entries are streamed as they are written, until the log is sealed or ctx is done.
 	ReadData(ctx context.Context, logID models.LogDescriptorID, search *models.LogSearch) (io.ReadCloser, error)
+	// Archive compresses a sealed log's data into a single blob under the archive prefix, and deletes the original
+	// chunks. Archived logs remain readable. Does nothing if the log has already been archived or expired.
+	Archive(ctx context.Context, id models.LogDescriptorID) error
+	// Expire deletes a sealed log's data, whether or not it has been archived. The log descriptor itself is kept
+	// so that it can be reported as expired. Does nothing if the log has already been expired.
+	Expire(ctx context.Context, id models.LogDescriptorID) error
+	// Delete permanently and idempotently deletes a log, including its data and any archive of its data, whether
+	// or not the log has been sealed. Any logs that have this log as their parent must be deleted first.
+	Delete(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) error
+	// UsageForRepo summarizes the storage used by the logs belonging to a repo's builds, jobs and steps.
+	UsageForRepo(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) (*models.LogUsage, error)
 }
 
 // BlobStore is an interface for storing and retrieving flat files.
@@ -97,6 +166,312 @@ type EncryptionService interface {
 	Decrypt(tx context.Context, encryptedData []byte, encryptedDataKey []byte) (plainTextData []byte, err error)
 }
 
+type NotificationService interface {
+	// Create a new notification setting for a repo.
+	Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateNotificationSetting) (*models.NotificationSetting, error)
+	// Read an existing notification setting, looking it up by ID.
+	// Returns models.ErrNotFound if the notification setting does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID) (*models.NotificationSetting, error)
+	// Update an existing notification setting with optimistic locking, changing only the fields that are set in update.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID, update dto.UpdateNotificationSetting) (*models.NotificationSetting, error)
+	// Delete permanently and idempotently deletes a notification setting, identifying it by ID.
+	Delete(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID) error
+	// ListByRepoID lists all notification settings for a repo. Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.NotificationSetting, *models.Cursor, error)
+	// RegisterChannel registers a channel that notifications can be sent to, replacing any existing channel
+	// of the same type.
+	RegisterChannel(channel NotificationChannel)
+}
+
+// NotificationChannel sends notification messages to an external system such as Slack or Discord.
+type NotificationChannel interface {
+	// Type returns the type of channel, matching the Channel field of the notification settings it serves.
+	Type() models.NotificationChannelType
+	// Send a message to the channel via the specified webhook URL.
+	Send(ctx context.Context, webhookURL string, message string) error
+}
+
+type BuildRuleSetService interface {
+	// Read reads the build rule set for a repo. If the repo has no rule set then a default rule set is
+	// returned, which builds every branch and tag.
+	Read(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) (*models.BuildRuleSet, error)
+	// Update updates a repo's build rule set with optimistic locking, changing only the fields that are set
+	// in update. The rule set is created if the repo doesn't already have one.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, update dto.UpdateBuildRuleSet) (*models.BuildRuleSet, error)
+	// Delete permanently and idempotently deletes a repo's build rule set, reverting to the default rules.
+	Delete(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) error
+	// ShouldBuild returns true if the repo's build rules allow a push of the specified ref to be built.
+	// pullRequest is true if the push is part of a pull request.
+	ShouldBuild(ctx context.Context, txOrNil *store.Tx, repo *models.Repo, ref string, pullRequest bool) (bool, error)
+	// SkipCommit returns true if the repo's build rules say that the specified commit should not be built,
+	// based on the commit message.
+	SkipCommit(ctx context.Context, txOrNil *store.Tx, commit *models.Commit) (bool, error)
+}
+
+type EmailService interface {
+	// ReadPreference reads the email preference for a user. If the user has never set a preference then
+	// a default preference is returned, with email notifications disabled.
+	ReadPreference(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) (*models.EmailPreference, error)
+	// UpdatePreference updates a user's email preference with optimistic locking, changing only the fields
+	// that are set in update. The preference is created if the user has never set one.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	UpdatePreference(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, update dto.UpdateEmailPreference) (*models.EmailPreference, error)
+	// SendDueDigests sends a digest email to each user with notifications that have been waiting for at
+	// least the configured digest interval as of now.
+	SendDueDigests(ctx context.Context, now models.Time) error
+	// SetSender sets the sender used to send emails, replacing the sender created from the service config.
+	// If sender is nil then no emails will be sent.
+	SetSender(sender EmailSender)
+}
+
+// EmailSender sends emails via an external system such as an SMTP server.
+type EmailSender interface {
+	// Send a plain text email to the specified address.
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+type MetricsExportService interface {
+	// ExportDue aggregates all samples in export windows that have ended by the specified time, and sends the
+	// aggregates to every registered sink. Samples are deleted once all sinks have accepted them; if any sink
+	// fails then the samples are kept and the same windows will be exported again next time.
+	ExportDue(ctx context.Context, now models.Time) error
+	// ExportRateLimitsDue sends the counts of requests throttled by this server's rate limiters, in export
+	// windows that have ended by the specified time, to every registered sink. Counts are kept in memory and
+	// are only discarded once all sinks have accepted them.
+	ExportRateLimitsDue(ctx context.Context, now models.Time) error
+	// RecordRateLimited counts a request throttled by the named rate limiter, by the specified type of key.
+	// Requests are only counted while at least one sink is registered.
+	RecordRateLimited(limiter string, keyType models.RateLimitKeyType)
+	// RegisterSink adds a sink that metrics will be exported to. Samples are only recorded while at least one
+	// sink is registered.
+	RegisterSink(sink MetricsSink)
+}
+
+// MetricsSink sends aggregated build and job metrics to an external time series database or data warehouse.
+type MetricsSink interface {
+	// Name returns a short name for the sink, for use in logs.
+	Name() string
+	// Export sends batches of aggregated metrics to the external system. Batches are in window order.
+	// The same batch may be exported more than once if an earlier attempt failed.
+	Export(ctx context.Context, batches []*models.MetricsBatch) error
+}
+
+type TestResultService interface {
+	// ReadSummary reads the test summary for a build.
+	// Returns models.ErrNotFound if no test results have been reported for the build.
+	ReadSummary(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) (*models.TestSummary, error)
+	// ListSummaries lists the test summaries for builds in a repo, newest first, to show test trends over time.
+	// Use cursor to page through results, if any.
+	ListSummaries(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.TestSummary, *models.Cursor, error)
+	// ListRuns lists the test runs for a build.
+	// Use cursor to page through results, if any.
+	ListRuns(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.TestRun, *models.Cursor, error)
+	// SearchCases searches for test cases, newest first. Searching by repo, suite and name returns the history
+	// of a single test across builds.
+	// Use cursor to page through results, if any.
+	SearchCases(ctx context.Context, txOrNil *store.Tx, search models.TestCaseSearch) ([]*models.TestCase, *models.Cursor, error)
+}
+
+type FlakyTestService interface {
+	// RecordTestRun checks the test cases in a test run reported by a job for flakiness, and flags any flaky
+	// tests that are found. Must be called in the transaction that created the test run and its test cases.
+	RecordTestRun(ctx context.Context, tx *store.Tx, job *models.Job, testRun *models.TestRun, testCases []*models.TestCase) error
+	// ListFlakyTests lists the tests in a repo that are known to be flaky, newest first.
+	// Use cursor to page through results, if any.
+	ListFlakyTests(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.FlakyTest, *models.Cursor, error)
+	// ReadJobFlakyTests checks which of the tests that failed in a job are known to be flaky, to tell whether
+	// the job failed only because of flaky tests.
+	ReadJobFlakyTests(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) (*dto.JobFlakyTests, error)
+}
+
+type CacheService interface {
+	// Find finds the cache entry to restore for a job. Returns the entry with the specified key if there is one,
+	// otherwise the most recently used entry for the cache in the job's repo. The entry is marked as used, so
+	// it is less likely to be evicted.
+	// Returns models.ErrNotFound if the job's repo has no entries for the cache.
+	Find(ctx context.Context, txOrNil *store.Tx, jobID models.JobID, name models.ResourceName, key string) (*models.CacheEntry, error)
+	// ReadForJob reads a cache entry on behalf of a job.
+	// Returns models.ErrNotFound if the cache entry does not exist or belongs to a different repo to the job.
+	ReadForJob(ctx context.Context, txOrNil *store.Tx, jobID models.JobID, cacheEntryID models.CacheEntryID) (*models.CacheEntry, error)
+	// GetCacheEntryData returns a reader to the tarball for a cache entry, read on behalf of a job.
+	// Returns models.ErrNotFound if the cache entry does not exist or belongs to a different repo to the job.
+	// It is the caller's responsibility to close the reader.
+	GetCacheEntryData(ctx context.Context, jobID models.JobID, cacheEntryID models.CacheEntryID) (io.ReadCloser, error)
+	// Save a cache entry for a job, with the tarball provided by reader. It is the caller's responsibility to close
+	// reader. The job must declare a cache with the specified name. If an entry with the same name and key already
+	// exists in the job's repo, the existing entry is returned and the new tarball is discarded.
+	Save(ctx context.Context, jobID models.JobID, name models.ResourceName, key string, reader io.Reader) (*models.CacheEntry, error)
+}
+
+type CoverageService interface {
+	// ReadBuildCoverage reads the combined code coverage for a build, including the change in coverage
+	// compared to the target branch.
+	// Returns models.ErrNotFound if no coverage has been reported for the build.
+	ReadBuildCoverage(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) (*models.BuildCoverage, error)
+	// ListBuildCoverages lists the coverage for builds in a repo, newest first, to show coverage trends over time.
+	// Use cursor to page through results, if any.
+	ListBuildCoverages(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.BuildCoverage, *models.Cursor, error)
+	// ListReports lists the coverage reports for a build.
+	// Use cursor to page through results, if any.
+	ListReports(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CoverageReport, *models.Cursor, error)
+}
+
+type CustomStatusService interface {
+	// Publish creates or replaces the custom status with the specified name for a build, and relays the status
+	// to the SCM for the build's repo (if any). Publishing a status that is identical to the existing status
+	// with the same name is a no-op.
+	Publish(ctx context.Context, txOrNil *store.Tx, publish dto.PublishCustomStatus) (*models.CustomStatus, error)
+	// Read an existing custom status, looking it up by ID.
+	// Returns models.ErrNotFound if the custom status does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.CustomStatusID) (*models.CustomStatus, error)
+	// ListByBuildID lists the custom statuses published to a build.
+	// Use cursor to page through results, if any.
+	ListByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CustomStatus, *models.Cursor, error)
+}
+
+type OutgoingWebhookService interface {
+	// Create a new outgoing webhook for a repo or legal entity.
+	Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateOutgoingWebhook) (*models.OutgoingWebhook, error)
+	// Read an existing outgoing webhook, looking it up by ID.
+	// Returns models.ErrNotFound if the outgoing webhook does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID) (*models.OutgoingWebhook, error)
+	// Update an existing outgoing webhook with optimistic locking, changing only the fields that are set in update.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID, update dto.UpdateOutgoingWebhook) (*models.OutgoingWebhook, error)
+	// Delete permanently and idempotently deletes an outgoing webhook and its delivery history, identifying it by ID.
+	Delete(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID) error
+	// ListByLegalEntityID lists the outgoing webhooks registered against a legal entity, excluding webhooks
+	// registered against the legal entity's individual repos. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error)
+	// ListByRepoID lists the outgoing webhooks registered against a repo. Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error)
+	// ReadDelivery reads an existing outgoing webhook delivery, looking it up by ID.
+	// Returns models.ErrNotFound if the delivery does not exist.
+	ReadDelivery(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookDeliveryID) (*models.OutgoingWebhookDelivery, error)
+	// ListDeliveries lists the deliveries for an outgoing webhook, most recent first.
+	// Use cursor to page through results, if any.
+	ListDeliveries(ctx context.Context, txOrNil *store.Tx, webhookID models.OutgoingWebhookID, pagination models.Pagination) ([]*models.OutgoingWebhookDelivery, *models.Cursor, error)
+	// Redeliver queues a delivery to be delivered again, for example after fixing a problem with the endpoint.
+	// The delivery's attempt count is reset, but attempts made so far are kept in its attempt log.
+	// Returns a validation error if the delivery is still pending, or if the webhook is disabled.
+	Redeliver(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookDeliveryID) (*models.OutgoingWebhookDelivery, error)
+}
+
+type WebhookDeliveryService interface {
+	// Receive records a webhook received from an SCM and then processes it. The delivery is recorded even if
+	// processing fails, so that it can be inspected and replayed later.
+	// Returns the recorded delivery, along with the error from processing it if any. Returns models.ErrNotFound
+	// and records nothing if the SCM does not exist.
+	Receive(ctx context.Context, scmName models.SystemName, headers http.Header, payload []byte) (*models.WebhookDelivery, error)
+	// Read an existing webhook delivery, looking it up by ID.
+	// Returns models.ErrNotFound if the delivery does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.WebhookDeliveryID) (*models.WebhookDelivery, error)
+	// Search all webhook deliveries, most recent first. Use cursor to page through results, if any.
+	Search(ctx context.Context, txOrNil *store.Tx, search *models.WebhookDeliverySearch) ([]*models.WebhookDelivery, *models.Cursor, error)
+	// Replay queues a delivery to be processed again, for example after fixing the problem that caused it to fail.
+	// Returns a validation error if the delivery is still pending.
+	Replay(ctx context.Context, txOrNil *store.Tx, id models.WebhookDeliveryID) (*models.WebhookDelivery, error)
+}
+
+type ToolchainService interface {
+	// Register registers a new version of a toolchain for a legal entity. Registering a version that already
+	// exists with the same image and digest is a no-op that returns the existing toolchain; registering it
+	// with a different image or digest fails with an already exists error, since versions can't be changed.
+	Register(ctx context.Context, txOrNil *store.Tx, register dto.RegisterToolchain) (*models.Toolchain, error)
+	// RegisterForBuild registers a new version of a toolchain for the legal entity that owns a build's repo.
+	// The build's repo is recorded as the toolchain's source repo, so that if a Dockerfile is specified a new
+	// version is automatically built whenever it changes. See Register for details.
+	RegisterForBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, register dto.RegisterToolchain) (*models.Toolchain, error)
+	// Read an existing toolchain, looking it up by ID.
+	// Returns models.ErrNotFound if the toolchain does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.ToolchainID) (*models.Toolchain, error)
+	// Resolve finds the toolchain a job refers to, looking it up by reference amongst the toolchains
+	// belonging to the specified legal entity (the owner of the job's repo).
+	// Returns a validation failed error if no such toolchain is registered.
+	Resolve(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, ref models.ToolchainReference) (*models.Toolchain, error)
+	// Delete permanently and idempotently deletes a toolchain, identifying it by ID. Jobs that refer to the
+	// toolchain and haven't yet been run will fail.
+	Delete(ctx context.Context, txOrNil *store.Tx, id models.ToolchainID) error
+	// ListByLegalEntityID lists the toolchains belonging to a legal entity. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Toolchain, *models.Cursor, error)
+	// ListRebuiltOnChangeBySourceRepoID lists the latest version of each toolchain that is built from a Dockerfile
+	// in the specified repo, and should be rebuilt when its Dockerfile changes.
+	ListRebuiltOnChangeBySourceRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) ([]*models.Toolchain, error)
+}
+
+type ToolchainRebuildService interface {
+	// RebuildChangedToolchains checks whether the commit a build ran for changed the Dockerfile of any toolchain
+	// built from the build's repo, and enqueues a build of the rebuild workflow for each toolchain that changed.
+	// A toolchain is only rebuilt once per commit, however many times this is called. Returns the builds enqueued.
+	RebuildChangedToolchains(ctx context.Context, buildID models.BuildID) ([]*dto.BuildGraph, error)
+}
+
+type OIDCService interface {
+	// IssueJobToken issues a short-lived OpenID Connect ID token identifying a running job, for the specified
+	// audience. If audience is empty the token's audience is the issuer URL.
+	// Returns a validation failed error if OIDC tokens are not enabled or the job is not running.
+	IssueJobToken(ctx context.Context, txOrNil *store.Tx, jobID models.JobID, audience string) (*dto.OIDCToken, error)
+	// IssuerURL returns the URL that identifies the server as an OpenID Connect issuer.
+	// Returns a not found error if OIDC tokens are not enabled.
+	IssuerURL() (string, error)
+	// GetSigningKeys returns the public keys that tokens issued by the server can be verified with.
+	// Returns a not found error if OIDC tokens are not enabled.
+	GetSigningKeys() ([]*dto.JSONWebKey, error)
+}
+
+type SSOService interface {
+	// Enabled returns true if users can log in via the identity provider.
+	Enabled() bool
+	// AuthCodeURL returns the URL of the identity provider's login page to redirect the user's browser to.
+	// state and nonce must be random values that are checked when the provider redirects back.
+	// Returns a not found error if single sign-on is not enabled.
+	AuthCodeURL(ctx context.Context, state string, nonce string) (string, error)
+	// Authenticate exchanges an authorization code from the identity provider for an ID token, verifies the token
+	// and ensures there is a legal entity and identity for the user it describes, with group memberships matching
+	// the user's groups in the provider. Returns the user's identity.
+	Authenticate(ctx context.Context, code string, nonce string) (*models.Identity, error)
+	// SyncUser ensures there is a legal entity and identity for a user who has logged in via the identity provider,
+	// and that the user is a member of the access control groups their provider groups are mapped to (and no other
+	// groups via the provider). Returns the user's identity.
+	SyncUser(ctx context.Context, user *dto.SSOUser) (*models.Identity, error)
+}
+
+type RunnerPoolService interface {
+	// Create a new runner pool for a legal entity. The pool is scaled to its minimum size the next time
+	// pools are reconciled.
+	Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateRunnerPool) (*models.RunnerPool, error)
+	// Read an existing runner pool, looking it up by ID.
+	// Returns models.ErrNotFound if the runner pool does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID) (*models.RunnerPool, error)
+	// Update an existing runner pool with optimistic locking, changing only the fields that are set in update.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID, update dto.UpdateRunnerPool) (*models.RunnerPool, error)
+	// Delete permanently and idempotently deletes a runner pool, identifying it by ID. Any runners the pool's
+	// provisioner has started are left running.
+	Delete(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID) error
+	// ListByLegalEntityID lists the runner pools belonging to a legal entity. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.RunnerPool, *models.Cursor, error)
+	// Reconcile compares the number of jobs each runner pool could be running with the pool's current size,
+	// and asks the pool's provisioner to start or stop runners as needed. Pools are scaled up straight away,
+	// but are only scaled down once they have had more runners than they need for their idle timeout.
+	Reconcile(ctx context.Context, now models.Time) error
+	// RegisterProvisioner adds a provisioner that can start and stop runners, replacing any existing
+	// provisioner of the same type.
+	RegisterProvisioner(provisioner RunnerProvisioner)
+}
+
+// RunnerProvisioner starts and stops the runners in runner pools, using some external system to run them.
+type RunnerProvisioner interface {
+	// Type returns the type of provisioner; each pool is scaled by the provisioner matching its provisioner type.
+	Type() models.RunnerProvisionerType
+	// Scale starts or stops runners so that the pool has the desired number of runners. The pool's
+	// DesiredRunners is the number of runners previously requested. secret is the pool's decrypted
+	// provisioner secret, or empty if the pool doesn't have one.
+	Scale(ctx context.Context, pool *models.RunnerPool, secret string, desired int) error
+}
+
 type SecretService interface {
 	// Create a new secret.
 	// Returns store.ErrAlreadyExists if a secret with matching unique properties already exists.
@@ -108,6 +483,7 @@ type SecretService interface {
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *store.Tx, secret *models.Secret) (*models.Secret, error)
 	// UpdatePlaintext updates an existing secret's plaintext key and value with optimistic locking.
+	// The previous key and value are kept as a prior version of the secret.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	UpdatePlaintext(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID, update dto.UpdateSecretPlaintext) (*models.SecretPlaintext, error)
 	// Delete permanently and idempotently deletes a secret, identifying it by ID.
@@ -116,6 +492,14 @@ type SecretService interface {
 	ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.Secret, *models.Cursor, error)
 	// ListPlaintextByRepoID gets all secrets in plaintext that are associated with the specified repo id.
 	ListPlaintextByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.SecretPlaintext, *models.Cursor, error)
+	// ListPlaintextByRepoIDAtBuildStart gets all secrets in plaintext that are associated with the specified repo id,
+	// as they were when the specified build started. Secrets created after the build started are omitted.
+	// Internal secrets are always returned as they are now, since they are managed by the system rather than rotated.
+	// Returns an error if the version of a secret that was current when the build started has since been purged.
+	ListPlaintextByRepoIDAtBuildStart(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, buildID models.BuildID, pagination models.Pagination) ([]*models.SecretPlaintext, *models.Cursor, error)
+	// ListVersions lists the prior versions of a secret, newest first. The versions returned contain metadata only.
+	// Use cursor to page through results, if any.
+	ListVersions(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID, pagination models.Pagination) ([]*models.SecretVersion, *models.Cursor, error)
 	// SecretToSecretPlaintext converts a secret to a plaintext secret.
 	SecretToSecretPlaintext(ctx context.Context, secret *models.Secret) (*models.SecretPlaintext, error)
 }
@@ -123,19 +507,37 @@ type SecretService interface {
 type RepoService interface {
 	// Read an existing repo, looking it up by ID.
 	Read(ctx context.Context, txOrNil *store.Tx, id models.RepoID) (*models.Repo, error)
+	// ListByIDs reads the repos with the specified IDs, in no particular order. IDs of repos that do not
+	// exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.RepoID) ([]*models.Repo, error)
 	// ReadByExternalID reads an existing repo, looking it up by its external id.
 	// Returns models.ErrNotFound if the repo does not exist.
 	ReadByExternalID(ctx context.Context, txOrNil *store.Tx, externalID models.ExternalResourceID) (*models.Repo, error)
 	// Upsert creates a repo if it does not exist, otherwise it updates its mutable properties
 	// if they differ from the in-memory instance. Returns true,false if the resource was created
 	// and false,true if the resource was updated. false,false if neither a create or update was necessary.
-	// Repo Metadata and selected fields will not be updated (including Enabled and SSHKeySecretID fields).
+	// Repo Metadata and selected fields will not be updated (including Enabled, SSHKeySecretID, DynamicJobRestrictions, CoverageSettings, PublicBuilds, BuildPrioritySettings and DeduplicateJobs fields).
 	Upsert(ctx context.Context, txOrNil *store.Tx, repo *models.Repo) (bool, bool, error)
 	// Search all repos. If searcher is set, the results will be limited to repos the searcher is authorized to
 	// see (via the read:repo permission). Use cursor to page through results, if any.
 	Search(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, query search.Query) ([]*models.Repo, *models.Cursor, error)
 	// UpdateRepoEnabled enables or disables builds for a repo.
 	UpdateRepoEnabled(ctx context.Context, repoID models.RepoID, update dto.UpdateRepoEnabled) (*models.Repo, error)
+	// UpdateDynamicJobRestrictions sets or clears the restrictions on jobs that can be added to builds for a repo
+	// via the Dynamic API.
+	UpdateDynamicJobRestrictions(ctx context.Context, repoID models.RepoID, update dto.UpdateDynamicJobRestrictions) (*models.Repo, error)
+	// UpdateCoverageSettings sets or clears the code coverage threshold that is checked when each build for
+	// a repo finishes.
+	UpdateCoverageSettings(ctx context.Context, repoID models.RepoID, update dto.UpdateCoverageSettings) (*models.Repo, error)
+	// UpdatePublicBuilds sets whether anyone, including people who have not logged in, can read the builds,
+	// logs and artifacts for a repo.
+	UpdatePublicBuilds(ctx context.Context, repoID models.RepoID, update dto.UpdateRepoPublicBuilds) (*models.Repo, error)
+	// UpdateBuildPrioritySettings sets or clears the settings that determine the priority given to new builds
+	// for a repo.
+	UpdateBuildPrioritySettings(ctx context.Context, repoID models.RepoID, update dto.UpdateBuildPrioritySettings) (*models.Repo, error)
+	// UpdateDeduplicateJobs sets whether queued jobs for a repo that are identical to a job already running in
+	// another build finish with the running job's result instead of being run again.
+	UpdateDeduplicateJobs(ctx context.Context, repoID models.RepoID, update dto.UpdateRepoDeduplicateJobs) (*models.Repo, error)
 	// SoftDelete soft deletes an existing repo.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch, i.e. if the repo has changed in
 	// the database since the supplied object was read.
@@ -156,6 +558,9 @@ type KeyPairService interface {
 type AuthorizationService interface {
 	// IsAuthorized returns true if the identity is authorized to perform operation on resource.
 	IsAuthorized(ctx context.Context, identityID models.IdentityID, operation *models.Operation, resourceID models.ResourceID) (bool, error)
+	// ListEffectivePermissions returns each of the operations the identity is authorized to perform on the resource,
+	// taking into account every grant that applies to the identity, including denied permissions.
+	ListEffectivePermissions(ctx context.Context, txOrNil *store.Tx, identityID models.IdentityID, resourceID models.ResourceID) ([]*models.Operation, error)
 	// CreateGrantsForIdentity grants the specified identity a set of permissions.
 	// For each operation in the supplied list, the identity will be allowed to perform the
 	// specified operation on the specified resource or on any resource it owns (directly or indirectly),
@@ -180,6 +585,16 @@ type AuthorizationService interface {
 		operations []*models.Operation,
 		resourceID models.ResourceID,
 	) error
+	// SetPublicReadAccess grants the anonymous identity permission to perform each of models.PublicReadOperations
+	// on the specified resource or on any resource it owns if public is true, or removes those grants if public
+	// is false. This allows requests that are not authenticated to read the resource.
+	SetPublicReadAccess(
+		ctx context.Context,
+		txOrNil *store.Tx,
+		grantedByLegalEntityID models.LegalEntityID,
+		resourceID models.ResourceID,
+		public bool,
+	) error
 	// DeleteGrant permanently and idempotently deletes a grant, identifying it by id.
 	DeleteGrant(ctx context.Context, txOrNil *store.Tx, id models.GrantID) error
 	// DeleteAllGrantsForIdentity permanently and idempotently deletes all grants for the specified identity.
@@ -190,6 +605,9 @@ type AuthorizationService interface {
 	FindOrCreateGrant(ctx context.Context, txOrNil *store.Tx, grantData *models.Grant) (grant *models.Grant, created bool, err error)
 	// ListGrantsForGroup finds and returns all grants that give permissions to the specified group.
 	ListGrantsForGroup(ctx context.Context, txOrNil *store.Tx, groupID models.GroupID, pagination models.Pagination) ([]*models.Grant, *models.Cursor, error)
+	// ListGrantsForIdentity finds and returns all grants that give permissions directly to the specified identity.
+	// Grants the identity has through the groups it is a member of are not included.
+	ListGrantsForIdentity(ctx context.Context, txOrNil *store.Tx, identityID models.IdentityID, pagination models.Pagination) ([]*models.Grant, *models.Cursor, error)
 }
 
 type GroupService interface {
@@ -247,9 +665,90 @@ type GroupService interface {
 	) ([]*models.GroupMembership, *models.Cursor, error)
 }
 
+type RoleService interface {
+	// ListTemplates returns the role templates that roles can be created from.
+	ListTemplates() []*models.RoleTemplate
+	// Create a new custom role for a company, either from a template or from the supplied operations.
+	Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateRole) (*models.Role, error)
+	// Read an existing role, looking it up by ID.
+	// Returns models.ErrNotFound if the role does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.RoleID) (*models.Role, error)
+	// Update an existing role with optimistic locking, changing only the fields that are set in update.
+	// If the role's operations change then the grants held by each of the role's assignees are updated to match.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *store.Tx, id models.RoleID, update dto.UpdateRole) (*models.Role, error)
+	// Delete permanently and idempotently deletes a role, identifying it by ID. The role is removed from all of
+	// its assignees, along with any grants they held only because of the role.
+	Delete(ctx context.Context, txOrNil *store.Tx, id models.RoleID) error
+	// ListByLegalEntityID lists the roles defined by a legal entity. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Role, *models.Cursor, error)
+	// AssignToGroup assigns a role to one of the access control groups of the company that owns the role,
+	// granting the group each of the role's operations on the company. This method is idempotent; if the role
+	// is already assigned to the group then the existing assignment is returned.
+	AssignToGroup(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, groupID models.GroupID) (*models.RoleAssignment, error)
+	// AssignToIdentity assigns a role directly to a member of the company that owns the role, granting the
+	// member's identity each of the role's operations on the company. This method is idempotent; if the role
+	// is already assigned to the identity then the existing assignment is returned.
+	AssignToIdentity(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, identityID models.IdentityID) (*models.RoleAssignment, error)
+	// ReadAssignment reads an existing role assignment, looking it up by ID.
+	// Returns models.ErrNotFound if the role assignment does not exist.
+	ReadAssignment(ctx context.Context, txOrNil *store.Tx, id models.RoleAssignmentID) (*models.RoleAssignment, error)
+	// Unassign permanently and idempotently removes a role assignment, along with any grants the assignee held
+	// only because of the role.
+	Unassign(ctx context.Context, txOrNil *store.Tx, id models.RoleAssignmentID) error
+	// ListAssignments lists the assignments of a role. Use cursor to page through results, if any.
+	ListAssignments(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, pagination models.Pagination) ([]*models.RoleAssignment, *models.Cursor, error)
+}
+
+type PermissionOverrideService interface {
+	// Set grants (or denies, if deny is true) an access control group permission to perform an operation on a repo.
+	// The group must belong to the legal entity that owns the repo. If the group already has an override for the
+	// operation on the repo then the existing override is updated and returned.
+	Set(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, groupID models.GroupID, operation models.Operation, deny bool) (*models.Grant, error)
+	// Read an existing permission override, looking it up by the ID of its grant.
+	// Returns models.ErrNotFound if the override does not exist.
+	Read(ctx context.Context, txOrNil *store.Tx, id models.GrantID) (*models.Grant, error)
+	// Delete permanently and idempotently deletes a permission override, identifying it by the ID of its grant.
+	// The group's permissions on the repo revert to those it holds on the repo's owner.
+	Delete(ctx context.Context, txOrNil *store.Tx, id models.GrantID) error
+	// ListByRepoID lists the permissions granted to or denied for groups directly on a repo, including those synced
+	// from an SCM. Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.Grant, *models.Cursor, error)
+}
+
+type UsageService interface {
+	// CheckQuotas returns a quota exceeded error if the legal entity has reached any of its quotas for the
+	// current month, or nil if the legal entity can use more resources.
+	CheckQuotas(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) error
+	// SetQuota limits the monthly usage of a metric by a legal entity. If the legal entity already has a quota
+	// for the metric then the existing quota is updated and returned.
+	SetQuota(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, metric models.UsageMetric, monthlyLimit int64) (*models.UsageQuota, error)
+	// DeleteQuota permanently and idempotently removes the legal entity's quota for a metric, if any.
+	DeleteQuota(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, metric models.UsageMetric) error
+	// ListQuotas lists all quotas of a legal entity.
+	ListQuotas(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) ([]*models.UsageQuota, error)
+	// Summary summarizes the usage of a legal entity for each month from 'from' to 'to' inclusive, in total and
+	// by repo, along with the legal entity's quotas.
+	Summary(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, from models.UsagePeriod, to models.UsagePeriod) (*models.UsageSummary, error)
+}
+
+type LeaderElectionService interface {
+	// IsLeader returns true if this server is currently the leader. Only one of the servers sharing a database
+	// is the leader at a time; background work that must not run on more than one server at once (e.g. periodic
+	// syncs and timeout checks) should be skipped when this returns false.
+	IsLeader() bool
+}
+
 type AuthenticationService interface {
+	// Each of the Authenticate functions returns an account disabled error if the identity belongs to a legal
+	// entity that has been disabled.
+
 	// AuthenticateSharedSecret authenticates an identity using a shared secret token.
 	AuthenticateSharedSecret(ctx context.Context, token string) (*models.Identity, error)
+	// AuthenticatePersonalAccessToken authenticates an identity using a personal access token. The token's
+	// credential is returned too, so the caller can limit the request to the token's scopes.
+	// Returns an unauthorized error if the token is invalid, disabled or expired.
+	AuthenticatePersonalAccessToken(ctx context.Context, token string) (*models.Identity, *models.Credential, error)
 	// AuthenticateSCMAuth authenticates an identity using an SCM as the authentication provider (typically OAuth).
 	// If the identity does not exist then a new legal entity and identity will automatically be created and authenticated.
 	AuthenticateSCMAuth(ctx context.Context, auth models.SCMAuth) (*models.Identity, error)
@@ -257,6 +756,30 @@ type AuthenticationService interface {
 	AuthenticateClientCertificate(ctx context.Context, certificateData certificates.CertificateData) (*models.Identity, error)
 	// AuthenticateJWT authenticates an identity using a JWT.
 	AuthenticateJWT(ctx context.Context, jwt string) (*models.Identity, error)
+	// ReadSAMLIdentityProvider reads the SAML identity provider configured for a legal entity.
+	// Returns a not found error if the legal entity has no identity provider.
+	ReadSAMLIdentityProvider(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) (*models.SAMLIdentityProvider, error)
+	// UpdateSAMLIdentityProvider updates the SAML identity provider for a company with optimistic locking, changing
+	// only the fields that are set in update. The identity provider is created if the company doesn't have one.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	UpdateSAMLIdentityProvider(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, update dto.UpdateSAMLIdentityProvider) (*models.SAMLIdentityProvider, error)
+	// DeleteSAMLIdentityProvider deletes the SAML identity provider configured for a legal entity, so that users
+	// can no longer log in with it. Users and group memberships that were provisioned via the provider are kept.
+	// Returns a not found error if the legal entity has no identity provider.
+	DeleteSAMLIdentityProvider(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) error
+	// GetSAMLServiceProviderMetadata returns the SAML metadata document describing BuildBeaver as a service provider
+	// for a legal entity's identity provider, to be uploaded to the identity provider.
+	// Returns a not found error if the legal entity has no identity provider.
+	GetSAMLServiceProviderMetadata(ctx context.Context, legalEntityID models.LegalEntityID) ([]byte, error)
+	// MakeSAMLAuthenticationRequest makes a request to authenticate a user with a legal entity's SAML identity provider,
+	// using the HTTP-Redirect binding. Returns a not found error if the legal entity has no enabled identity provider.
+	MakeSAMLAuthenticationRequest(ctx context.Context, legalEntityID models.LegalEntityID) (*dto.SAMLAuthenticationRequest, error)
+	// AuthenticateSAMLResponse validates a response posted back from a legal entity's SAML identity provider
+	// (base64 encoded, as received via the HTTP-POST binding) and ensures there is a person legal entity and
+	// identity for the user the assertion describes. The user is made a member of the legal entity, and of any
+	// standard groups their groups attribute values are mapped to. requestID must be the ID of the request
+	// the response is in response to. Returns the user's identity.
+	AuthenticateSAMLResponse(ctx context.Context, legalEntityID models.LegalEntityID, samlResponse string, requestID string) (*models.Identity, error)
 }
 
 type CredentialService interface {
@@ -271,6 +794,16 @@ type CredentialService interface {
 		identityID models.IdentityID,
 		enabled bool,
 	) (models.PublicSharedSecretToken, *models.Credential, error)
+	// CreatePersonalAccessToken creates a new personal access token for an identity, limited to the specified
+	// scopes and valid until the specified expiry time.
+	// The plaintext token is returned. The plaintext can never be reconstructed so do something useful with it now.
+	CreatePersonalAccessToken(ctx context.Context, txOrNil *store.Tx, create dto.CreatePersonalAccessToken) (string, *models.Credential, error)
+	// ListPersonalAccessTokens returns the personal access tokens belonging to an identity.
+	// Use cursor to page through results, if any.
+	ListPersonalAccessTokens(ctx context.Context, txOrNil *store.Tx, identityID models.IdentityID, pagination models.Pagination) ([]*models.Credential, *models.Cursor, error)
+	// RevokePersonalAccessToken permanently deletes one of an identity's personal access tokens, so that it
+	// can no longer be used. Returns a not found error if the identity has no such token.
+	RevokePersonalAccessToken(ctx context.Context, txOrNil *store.Tx, identityID models.IdentityID, id models.CredentialID) error
 	// CreateClientCertificateCredential creates a new client certificate credential for the specified identity.
 	// clientCert is an X.509 certificate used to identify the client.
 	CreateClientCertificateCredential(
@@ -315,12 +848,49 @@ type ArtifactService interface {
 	) (*models.Artifact, error)
 	// Read an existing artifact, looking it up by ID.
 	Read(ctx context.Context, txOrNil *store.Tx, id models.ArtifactID) (*models.Artifact, error)
+	// ListByJobIDs gets all artifacts created by any of the specified jobs.
+	ListByJobIDs(ctx context.Context, txOrNil *store.Tx, jobIDs []models.JobID) ([]*models.Artifact, error)
 	// Search all artifacts. If searcher is set, the results will be limited to artifacts the searcher is authorized to
 	// see (via the read:artifact permission). Use cursor to page through results, if any.
 	Search(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, search models.ArtifactSearch) ([]*models.Artifact, *models.Cursor, error)
+	// SearchWithWait searches artifacts in the same way as Search. If none of the matching artifacts have been
+	// sealed then waits for up to wait for an artifact in the build being searched to be sealed, searching again
+	// each time one is, and returns the results of the last search. The results may still contain no sealed
+	// artifacts if none were sealed before the wait expired.
+	SearchWithWait(ctx context.Context, searcher models.IdentityID, search models.ArtifactSearch, wait time.Duration) ([]*models.Artifact, *models.Cursor, error)
 	// GetArtifactData returns a reader to the data of an artifact.
+	// Returns an ArtifactQuarantined error if the artifact has been quarantined by the artifact scanner.
 	// It is the callers responsibility to close reader.
 	GetArtifactData(ctx context.Context, artifactID models.ArtifactID) (io.ReadCloser, error)
+	// WriteArtifactBundle writes an archive in the specified format to writer, containing the data for every artifact
+	// matching search. The archive is assembled on the fly from ranged blob store reads, so it is never held in
+	// memory or on disk. Unsealed and quarantined artifacts are skipped. If searcher is set, only artifacts the searcher
+	// is authorized to see are included. Returns a NotFound error, before anything is written, if no artifacts match.
+	WriteArtifactBundle(ctx context.Context, searcher models.IdentityID, search models.ArtifactSearch, format models.ArtifactBundleFormat, writer io.Writer) error
+	// VerifyArtifact checks that the data held for a sealed artifact still matches the SHA-256 hash recorded when
+	// it was uploaded and, if the artifact was signed, that the signature over the hash is valid.
+	VerifyArtifact(ctx context.Context, artifactID models.ArtifactID) (*models.ArtifactVerification, error)
+	// Delete permanently and idempotently deletes an artifact and its data.
+	Delete(ctx context.Context, txOrNil *store.Tx, artifactID models.ArtifactID) error
+	// RegisterUploadHandler registers a handler to be called each time the data for an artifact has been stored,
+	// just before the artifact is sealed. Handlers are called inside the transaction that seals the artifact and
+	// may modify the artifact before it is saved.
+	RegisterUploadHandler(handler ArtifactUploadHandler)
+}
+
+// ArtifactUploadHandler is called when the data for an artifact has been stored.
+type ArtifactUploadHandler func(ctx context.Context, tx *store.Tx, artifact *models.Artifact) error
+
+type ArtifactScanService interface {
+	// Enabled returns true if a scanner is configured and newly uploaded artifacts will be scanned.
+	Enabled() bool
+}
+
+// ArtifactScanner scans artifact data for viruses and other malware.
+type ArtifactScanner interface {
+	// Scan reads the data from reader and scans it. If a threat is found then infected is returned as true,
+	// along with the name of the threat.
+	Scan(ctx context.Context, reader io.Reader) (infected bool, threat string, err error)
 }
 
 type LegalEntityService interface {
@@ -348,6 +918,13 @@ type LegalEntityService interface {
 	// Update an existing legal entity with optimistic locking. Overrides all previous values using the supplied model.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *store.Tx, legalEntity *models.LegalEntity) error
+	// SetDisabled disables a legal entity so that it can no longer authenticate, or enables it again if disabled is
+	// false. Disabling a legal entity keeps all of its data, including its memberships and grants.
+	// Returns the legal entity as it is after the change.
+	SetDisabled(ctx context.Context, txOrNil *store.Tx, id models.LegalEntityID, disabled bool) (*models.LegalEntity, error)
+	// UpdateSchedulingWeight sets a legal entity's share of the runners it shares with other legal entities,
+	// when jobs from several legal entities are queued.
+	UpdateSchedulingWeight(ctx context.Context, legalEntityID models.LegalEntityID, update dto.UpdateLegalEntitySchedulingWeight) (*models.LegalEntity, error)
 	// ListParentLegalEntities lists all legal entities a legal entity is a member of. Use cursor to page through results, if any.
 	ListParentLegalEntities(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.LegalEntity, *models.Cursor, error)
 	// ListMemberLegalEntities lists all legal entities that are members of a parent legal entity. Use cursor to page through results, if any.
@@ -373,6 +950,9 @@ type BuildService interface {
 	// Read an existing build, looking it up by ID.
 	// Returns models.ErrNotFound if the build does not exist.
 	Read(ctx context.Context, txOrNil *store.Tx, id models.BuildID) (*models.Build, error)
+	// ListByIDs reads the builds with the specified IDs, in no particular order. IDs of builds that do not
+	// exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.BuildID) ([]*models.Build, error)
 	// ReadByIdentityID looks up the build that corresponds to the specified identity, or returns a not found error
 	// if the identity doesn't correspond to a build.
 	ReadByIdentityID(ctx context.Context, txOrNil *store.Tx, identityID models.IdentityID) (*models.Build, error)
@@ -397,6 +977,18 @@ type BuildService interface {
 	UniversalSearch(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, search search.Query) ([]*models.BuildSearchResult, *models.Cursor, error)
 }
 
+type BuildComparisonService interface {
+	// Compare works out what changed from the base build to the head build. Both builds must be in the same repo.
+	Compare(ctx context.Context, txOrNil *store.Tx, baseBuildID models.BuildID, headBuildID models.BuildID) (*dto.BuildComparison, error)
+}
+
+type JobAnalyticsService interface {
+	// Analyze computes statistics for the finished jobs in a repo that were created within the query's time
+	// range, for the repo as a whole and for each workflow or job, both over the whole range and in each time
+	// window. Returns a validation failed error if the query is invalid or covers too many jobs.
+	Analyze(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, query *models.JobAnalyticsQuery) (*dto.JobAnalytics, error)
+}
+
 type JobService interface {
 	// Create a new job.
 	// Returns store.ErrAlreadyExists if a job with matching unique properties already exists.
@@ -417,16 +1009,33 @@ type JobService interface {
 	// Update an existing job with optimistic locking. Overrides all previous values using the supplied model.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *store.Tx, job *models.Job) error
+	// UpdateDependencies replaces the recorded dependencies of an existing job with the job's current Depends list.
+	UpdateDependencies(ctx context.Context, txOrNil *store.Tx, job *models.Job) error
+	// FindRunningDuplicate locates a job in another build that has the same commit, workflow, name and definition
+	// as the specified job, and which has been handed to a runner but has not yet finished. Jobs that are themselves
+	// indirected to another job are ignored. Returns models.ErrNotFound if no such job exists.
+	FindRunningDuplicate(ctx context.Context, txOrNil *store.Tx, job *models.Job) (*models.Job, error)
+	// ListAttached lists the queued jobs that are indirected to the specified job, waiting to finish with
+	// the same result.
+	ListAttached(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) ([]*models.Job, error)
 	// ListDependencies lists all jobs that the specified job depends on.
 	ListDependencies(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) ([]*models.Job, error)
+	// ListRunningByRunnerID lists the jobs that have been handed to the specified runner but have not yet finished.
+	ListRunningByRunnerID(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID) ([]*models.Job, error)
 	// FindQueuedJob locates a queued job that the runner is capable of running, and which is ready for
 	// execution (e.g all dependencies are completed).
 	FindQueuedJob(ctx context.Context, txOrNil *store.Tx, runner *models.Runner) (*models.Job, error)
 	// ListByBuildID gets all jobs that are associated with the specified build id.
 	ListByBuildID(ctx context.Context, txOrNil *store.Tx, id models.BuildID) ([]*models.Job, error)
+	// ListByBuildIDs gets all jobs that are associated with any of the specified build ids.
+	ListByBuildIDs(ctx context.Context, txOrNil *store.Tx, ids []models.BuildID) ([]*models.Job, error)
 	// ListByStatus returns all jobs that have the specified status, regardless of who owns the jobs or which build
 	// they are part of. Use cursor to page through results, if any.
 	ListByStatus(ctx context.Context, txOrNil *store.Tx, status models.WorkflowStatus, pagination models.Pagination) ([]*models.Job, *models.Cursor, error)
+	// Search all jobs, regardless of who owns the jobs or which build they are part of. Use cursor to page
+	// through results, if any. A runner ID and time window can be used to find the jobs a runner executed
+	// over a period of time.
+	Search(ctx context.Context, txOrNil *store.Tx, search models.JobSearch) ([]*models.Job, *models.Cursor, error)
 }
 
 type StepService interface {
@@ -441,6 +1050,8 @@ type StepService interface {
 	Update(ctx context.Context, txOrNil *store.Tx, step *models.Step) error
 	// ListByJobID gets all steps that are associated with the specified job id.
 	ListByJobID(ctx context.Context, txOrNil *store.Tx, id models.JobID) ([]*models.Step, error)
+	// ListByJobIDs gets all steps that are associated with any of the specified job ids.
+	ListByJobIDs(ctx context.Context, txOrNil *store.Tx, ids []models.JobID) ([]*models.Step, error)
 }
 
 type RunnerService interface {
@@ -451,6 +1062,9 @@ type RunnerService interface {
 	// Read an existing runner, looking it up by ID.
 	// Returns models.ErrNotFound if the runner does not exist.
 	Read(ctx context.Context, txOrNil *store.Tx, id models.RunnerID) (*models.Runner, error)
+	// ListByIDs reads the runners with the specified IDs, in no particular order. IDs of runners that do not
+	// exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.RunnerID) ([]*models.Runner, error)
 	// ReadByName reads an existing runner, looking it up by name and the ID of the legal entity that owns the runner.
 	// Returns models.ErrNotFound if the runner is not found.
 	ReadByName(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, name models.ResourceName) (*models.Runner, error)
@@ -461,16 +1075,42 @@ type RunnerService interface {
 	// Update an existing runner.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *store.Tx, runner *models.Runner) (*models.Runner, error)
-	// RunnerCompatibleWithJob returns true if a runner exists that is capable of running job.
+	// RunnerCompatibleWithJob returns true if a runner exists that is capable of running job, or if a runner
+	// pool exists that could start one.
 	RunnerCompatibleWithJob(ctx context.Context, txOrNil *store.Tx, job *models.Job) (bool, error)
 	// SoftDelete soft deletes an existing runner.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	SoftDelete(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID, delete dto.DeleteRunner) error
+	// ShareWithLegalEntity lets a runner run jobs for another legal entity's repos as well as for its own legal
+	// entity's repos, by adding the runner to the other legal entity's 'runner' standard group.
+	// The runner gets the same access to the other legal entity's builds, including their secrets, as that legal
+	// entity's own runners, so whoever controls the runner must be trusted by the other legal entity.
+	// When jobs from several legal entities are queued, the runner is shared according to each legal entity's
+	// scheduling weight.
+	ShareWithLegalEntity(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID, legalEntityID models.LegalEntityID) error
+	// StopSharingWithLegalEntity stops a runner running jobs for another legal entity's repos, undoing
+	// ShareWithLegalEntity. A runner can't stop running jobs for its own legal entity.
+	StopSharingWithLegalEntity(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID, legalEntityID models.LegalEntityID) error
+	// ListLegalEntityIDs lists the IDs of the legal entities whose jobs a runner can run: the legal entity that owns
+	// the runner, and any legal entities the runner has been shared with.
+	ListLegalEntityIDs(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID) ([]models.LegalEntityID, error)
 	// Search all runners. If searcher is set, the results will be limited to runners the searcher is authorized to
 	// see (via the read:runner permission). Use cursor to page through results, if any.
 	Search(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, search models.RunnerSearch) ([]*models.Runner, *models.Cursor, error)
+	// UpdateHealth records a health report sent by a runner as a heartbeat. The runner is marked as online, and
+	// is marked as unhealthy and will not be given jobs to run if the report shows problems with the runner's host.
+	UpdateHealth(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID, report models.RunnerHealthReport) (*models.Runner, error)
+	// DetectOfflineRunners marks runners that have not sent a heartbeat within the configured period before now
+	// as offline. A RunnerOffline event is published for each job the runner was running when it went offline.
+	DetectOfflineRunners(ctx context.Context, now models.Time) error
+	// RegisterHealthChangedHandler registers a handler to be called each time a runner becomes unhealthy, or
+	// becomes healthy again. Handlers are called inside the transaction that updates the runner.
+	RegisterHealthChangedHandler(handler RunnerHealthChangedHandler)
 }
 
+// RunnerHealthChangedHandler is called when a runner becomes unhealthy, or becomes healthy again.
+type RunnerHealthChangedHandler func(ctx context.Context, tx *store.Tx, runner *models.Runner) error
+
 type SyncService interface {
 	// SyncAuthenticatedUser reads the details for the currently authenticated user from their SCM, and ensures
 	// there is a LegalEntity and Identity for the user in the database. Returns the Identity for the user.
@@ -573,6 +1213,12 @@ type BackoffAlgorithm func(attemptsSoFar int, lastAttemptAt time.Time, workItem
 type WorkQueueService interface {
 	// AddWorkItem adds a new Work Item to the queue to be processed.
 	AddWorkItem(ctx context.Context, txOrNil *store.Tx, workItem *models.WorkItem) error
+	// AddOrReplaceWorkItem adds a new Work Item to the queue to be processed, unless a work item of the same type
+	// and concurrency key is already queued and has not started processing yet. In that case the queued work item's
+	// data is replaced with the new work item's data, so that only the latest data is processed.
+	// If no other work item is queued for the concurrency key then processing of the new work item is delayed by
+	// at least delay, so that any further work items added during that time are coalesced into it.
+	AddOrReplaceWorkItem(ctx context.Context, txOrNil *store.Tx, workItem *models.WorkItem, delay time.Duration) error
 	// RegisterHandler registers a handler function to process work items of the specified type.
 	// Only one handler function can be registered for each type; subsequent calls to RegisterHandler for that
 	// type will return an error.
@@ -603,9 +1249,17 @@ type WorkQueueService interface {
 	) error
 }
 
+// EventHandler is called when an event is published, inside the transaction the event was published in.
+// Returning an error will cause publishing of the event to fail.
+type EventHandler func(ctx context.Context, tx *store.Tx, event *models.Event) error
+
 type EventService interface {
 	// PublishEvent publishes a new event. Subscribers matching the event type and resource will be notified.
 	PublishEvent(ctx context.Context, txOrNil *store.Tx, eventData *models.EventData) error
+	// Subscribe registers a handler to be called each time an event of the specified type is published.
+	// Handlers are called synchronously inside the publishing transaction so must not block for long;
+	// any slow work (e.g. calling external systems) should be queued as a work item.
+	Subscribe(eventType models.EventType, handler EventHandler)
 	// FetchEvents fetches new events for a given build, i.e. those with event numbers greater than lastEventNumber.
 	// limit specifies the maximum number of events to return.
 	// Events will be returned in order of event number; event numbers provide a unique ordering within a build.
@@ -54,11 +54,29 @@ func (s *JobService) ReadByFingerprint(
 	return s.jobStore.ReadByFingerprint(ctx, txOrNil, repoID, workflow, jobName, jobFingerprint, jobFingerprintHashType)
 }
 
+// FindRunningDuplicate locates a job in another build that has the same commit, workflow, name and definition
+// as the specified job, and which has been handed to a runner but has not yet finished. Jobs that are themselves
+// indirected to another job are ignored. Returns models.ErrNotFound if no such job exists.
+func (s *JobService) FindRunningDuplicate(ctx context.Context, txOrNil *store.Tx, job *models.Job) (*models.Job, error) {
+	return s.jobStore.FindRunningDuplicate(ctx, txOrNil, job)
+}
+
+// ListAttached lists the queued jobs that are indirected to the specified job, waiting to finish with
+// the same result.
+func (s *JobService) ListAttached(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) ([]*models.Job, error) {
+	return s.jobStore.ListAttached(ctx, txOrNil, jobID)
+}
+
 // ListDependencies lists all jobs that the specified job depends on.
 func (s *JobService) ListDependencies(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) ([]*models.Job, error) {
 	return s.jobStore.ListDependencies(ctx, txOrNil, jobID)
 }
 
+// ListRunningByRunnerID lists the jobs that have been handed to the specified runner but have not yet finished.
+func (s *JobService) ListRunningByRunnerID(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID) ([]*models.Job, error) {
+	return s.jobStore.ListRunningByRunnerID(ctx, txOrNil, runnerID)
+}
+
 // FindQueuedJob locates a queued job that the runner is capable of running, and which is ready for
 // execution (e.g all dependencies are completed).
 func (s *JobService) FindQueuedJob(ctx context.Context, txOrNil *store.Tx, runner *models.Runner) (*models.Job, error) {
@@ -112,6 +130,25 @@ func (s *JobService) Create(ctx context.Context, txOrNil *store.Tx, create *dto.
 	})
 }
 
+// UpdateDependencies replaces the recorded dependencies of an existing job with the job's current Depends list.
+// As when creating a job, dependencies on jobs in other workflows that don't exist yet are recorded as
+// deferred dependencies.
+func (s *JobService) UpdateDependencies(ctx context.Context, txOrNil *store.Tx, job *models.Job) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.jobStore.DeleteDependencies(ctx, tx, job.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting job dependencies: %w", err)
+		}
+		for _, depends := range job.Depends {
+			err = s.createDependency(ctx, tx, job, depends)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // createDependency adds a new dependency for a job.
 // If the (dependent) job and the dependency job are in the same workflow then the dependency job must already exist.
 // If they are in different workflows and the dependency job doesn't exist yet then a deferred dependency
@@ -188,7 +225,22 @@ func (s *JobService) ListByStatus(
 	return s.jobStore.ListByStatus(ctx, txOrNil, status, pagination)
 }
 
+// Search all jobs, regardless of who owns the jobs or which build they are part of. Use cursor to page
+// through results, if any.
+func (s *JobService) Search(ctx context.Context, txOrNil *store.Tx, search models.JobSearch) ([]*models.Job, *models.Cursor, error) {
+	err := search.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.jobStore.Search(ctx, txOrNil, search)
+}
+
 // ListByBuildID gets all jobs that are associated with the specified build id.
 func (s *JobService) ListByBuildID(ctx context.Context, txOrNil *store.Tx, id models.BuildID) ([]*models.Job, error) {
 	return s.jobStore.ListByBuildID(ctx, txOrNil, id)
 }
+
+// ListByBuildIDs gets all jobs that are associated with any of the specified build ids.
+func (s *JobService) ListByBuildIDs(ctx context.Context, txOrNil *store.Tx, ids []models.BuildID) ([]*models.Job, error) {
+	return s.jobStore.ListByBuildIDs(ctx, txOrNil, ids)
+}
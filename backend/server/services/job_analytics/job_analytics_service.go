@@ -0,0 +1,211 @@
+package job_analytics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// jobAnalyticsBatchSize is the number of jobs to read from the database at once.
+	jobAnalyticsBatchSize = 1000
+	// maxJobAnalyticsJobs is the maximum number of jobs a single query can analyze, to bound the memory used
+	// to compute percentiles.
+	maxJobAnalyticsJobs = 100000
+)
+
+// JobAnalyticsService computes statistics about how long jobs take to run, how long they wait in the queue
+// and how often they succeed, from the timings stored against each job.
+type JobAnalyticsService struct {
+	jobStore store.JobStore
+	logger.Log
+}
+
+func NewJobAnalyticsService(
+	jobStore store.JobStore,
+	logFactory logger.LogFactory,
+) *JobAnalyticsService {
+	return &JobAnalyticsService{
+		jobStore: jobStore,
+		Log:      logFactory("JobAnalyticsService"),
+	}
+}
+
+// Analyze computes statistics for the finished jobs in a repo that were created within the query's time range,
+// for the repo as a whole and for each workflow or job, both over the whole range and in each time window.
+func (s *JobAnalyticsService) Analyze(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, query *models.JobAnalyticsQuery) (*dto.JobAnalytics, error) {
+	err := query.Validate()
+	if err != nil {
+		return nil, err
+	}
+	windows := query.Windows()
+	totals := newSeriesBuilder(len(windows))
+	groups := make(map[models.NodeFQN]*seriesBuilder)
+	pagination := models.NewPagination(jobAnalyticsBatchSize, nil)
+	analyzed := 0
+	for {
+		jobs, cursor, err := s.jobStore.ListFinishedByRepoID(ctx, txOrNil, repoID, query.From, query.To, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing jobs: %w", err)
+		}
+		for _, job := range jobs {
+			analyzed++
+			if analyzed > maxJobAnalyticsJobs {
+				return nil, gerror.NewErrValidationFailed(fmt.Sprintf("More than %d jobs were created in the time range; choose a shorter range", maxJobAnalyticsJobs))
+			}
+			window := int(job.CreatedAt.Sub(query.From.Time) / query.Interval)
+			if window < 0 || window >= len(windows) {
+				continue
+			}
+			key := models.NewNodeFQNForWorkflow(job.Workflow)
+			if query.GroupBy == models.JobAnalyticsGroupByJob {
+				key = job.GetFQN()
+			}
+			group, ok := groups[key]
+			if !ok {
+				group = newSeriesBuilder(len(windows))
+				groups[key] = group
+			}
+			totals.add(window, job)
+			group.add(window, job)
+		}
+		if cursor == nil || cursor.Next == nil {
+			break
+		}
+		pagination.Cursor = cursor.Next
+	}
+
+	keys := make([]models.NodeFQN, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].WorkflowName != keys[j].WorkflowName {
+			return keys[i].WorkflowName < keys[j].WorkflowName
+		}
+		return keys[i].JobName < keys[j].JobName
+	})
+	analytics := &dto.JobAnalytics{
+		RepoID:  repoID,
+		Query:   query,
+		Windows: windows,
+		Totals:  totals.build(),
+		Groups:  make([]*dto.JobAnalyticsGroup, 0, len(keys)),
+	}
+	for _, key := range keys {
+		analytics.Groups = append(analytics.Groups, &dto.JobAnalyticsGroup{
+			Workflow:           key.WorkflowName,
+			JobName:            key.JobName,
+			JobAnalyticsSeries: groups[key].build(),
+		})
+	}
+	return analytics, nil
+}
+
+// seriesBuilder accumulates statistics over the whole range of a query and in each of its time windows.
+type seriesBuilder struct {
+	overall *statsBuilder
+	windows []*statsBuilder
+}
+
+func newSeriesBuilder(windowCount int) *seriesBuilder {
+	builder := &seriesBuilder{
+		overall: &statsBuilder{},
+		windows: make([]*statsBuilder, windowCount),
+	}
+	for i := range builder.windows {
+		builder.windows[i] = &statsBuilder{}
+	}
+	return builder
+}
+
+func (b *seriesBuilder) add(window int, job *models.Job) {
+	b.overall.add(job)
+	b.windows[window].add(job)
+}
+
+func (b *seriesBuilder) build() *dto.JobAnalyticsSeries {
+	series := &dto.JobAnalyticsSeries{
+		Overall: b.overall.build(),
+		Windows: make([]*dto.JobStats, len(b.windows)),
+	}
+	for i, window := range b.windows {
+		series.Windows[i] = window.build()
+	}
+	return series
+}
+
+// statsBuilder accumulates statistics about a set of jobs.
+type statsBuilder struct {
+	stats      dto.JobStats
+	durations  []time.Duration
+	queueWaits []time.Duration
+}
+
+func (b *statsBuilder) add(job *models.Job) {
+	b.stats.Jobs++
+	switch job.Status {
+	case models.WorkflowStatusSucceeded:
+		b.stats.Succeeded++
+	case models.WorkflowStatusFailed:
+		b.stats.Failed++
+	case models.WorkflowStatusCanceled:
+		b.stats.Canceled++
+	case models.WorkflowStatusSkipped:
+		b.stats.Skipped++
+	}
+	// Jobs that never ran (e.g. skipped, canceled while queued, or indirected to another job) have no timings
+	timings := job.Timings
+	if timings.RunningAt == nil {
+		return
+	}
+	if timings.QueuedAt != nil {
+		b.queueWaits = append(b.queueWaits, timings.RunningAt.Sub(timings.QueuedAt.Time))
+	}
+	if timings.FinishedAt != nil {
+		b.durations = append(b.durations, timings.FinishedAt.Sub(timings.RunningAt.Time))
+	}
+}
+
+func (b *statsBuilder) build() *dto.JobStats {
+	stats := b.stats
+	if completed := stats.Succeeded + stats.Failed; completed > 0 {
+		successRate := float64(stats.Succeeded) / float64(completed)
+		stats.SuccessRate = &successRate
+	}
+	stats.DurationP50, stats.DurationP95 = percentiles(b.durations)
+	stats.QueueWaitP50, stats.QueueWaitP95 = percentiles(b.queueWaits)
+	return &stats
+}
+
+// percentiles returns the 50th and 95th percentiles of durations, or nil if durations is empty.
+// The order of durations is not preserved.
+func percentiles(durations []time.Duration) (p50 *time.Duration, p95 *time.Duration) {
+	if len(durations) == 0 {
+		return nil, nil
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return percentile(durations, 50), percentile(durations, 95)
+}
+
+// percentile returns the duration that the specified percentage (0-100) of the sorted durations are no longer
+// than, using the nearest-rank method.
+func percentile(sorted []time.Duration, percent float64) *time.Duration {
+	rank := int(math.Ceil(percent / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	value := sorted[rank-1]
+	return &value
+}
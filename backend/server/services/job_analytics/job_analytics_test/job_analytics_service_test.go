@@ -0,0 +1,104 @@
+package job_analytics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+func TestJobAnalyticsService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	now := time.Now()
+
+	// The same job succeeds in one build and fails in another; the other jobs never finish so aren't counted
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	firstBuild, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	secondBuild, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	fqn := firstBuild.Jobs[0].GetFQN()
+	finish := func(buildID models.BuildID, status models.WorkflowStatus, queueWait time.Duration, duration time.Duration) {
+		job, err := app.JobStore.ReadByName(ctx, nil, buildID, fqn.WorkflowName, fqn.JobName)
+		require.NoError(t, err)
+		queuedAt := models.NewTime(now)
+		runningAt := models.NewTime(now.Add(queueWait))
+		finishedAt := models.NewTime(now.Add(queueWait + duration))
+		job.Status = status
+		job.Timings.QueuedAt = &queuedAt
+		job.Timings.RunningAt = &runningAt
+		job.Timings.FinishedAt = &finishedAt
+		err = app.JobStore.Update(ctx, nil, job)
+		require.NoError(t, err)
+	}
+	finish(firstBuild.ID, models.WorkflowStatusSucceeded, 2*time.Second, 10*time.Second)
+	finish(secondBuild.ID, models.WorkflowStatusFailed, 4*time.Second, 30*time.Second)
+
+	query := &models.JobAnalyticsQuery{
+		From:     models.NewTime(now.Add(-time.Hour)),
+		To:       models.NewTime(now.Add(time.Hour)),
+		Interval: time.Hour,
+		GroupBy:  models.JobAnalyticsGroupByJob,
+	}
+	analytics, err := app.JobAnalyticsService.Analyze(ctx, nil, repo.ID, query)
+	require.NoError(t, err)
+	require.Len(t, analytics.Windows, 2)
+	require.Len(t, analytics.Groups, 1)
+	group := analytics.Groups[0]
+	require.Equal(t, fqn.WorkflowName, group.Workflow)
+	require.Equal(t, fqn.JobName, group.JobName)
+	require.Equal(t, analytics.Totals, group.JobAnalyticsSeries)
+
+	stats := group.Overall
+	require.Equal(t, 2, stats.Jobs)
+	require.Equal(t, 1, stats.Succeeded)
+	require.Equal(t, 1, stats.Failed)
+	require.NotNil(t, stats.SuccessRate)
+	require.Equal(t, 0.5, *stats.SuccessRate)
+	require.Equal(t, 10*time.Second, *stats.DurationP50)
+	require.Equal(t, 30*time.Second, *stats.DurationP95)
+	require.Equal(t, 2*time.Second, *stats.QueueWaitP50)
+	require.Equal(t, 4*time.Second, *stats.QueueWaitP95)
+
+	// Jobs are counted in the window they were queued in
+	require.Len(t, group.Windows, 2)
+	require.Equal(t, 0, group.Windows[0].Jobs)
+	require.Nil(t, group.Windows[0].SuccessRate)
+	require.Nil(t, group.Windows[0].DurationP50)
+	require.Equal(t, 2, group.Windows[1].Jobs)
+
+	// Grouping by workflow combines the jobs in each workflow
+	query.GroupBy = models.JobAnalyticsGroupByWorkflow
+	analytics, err = app.JobAnalyticsService.Analyze(ctx, nil, repo.ID, query)
+	require.NoError(t, err)
+	require.Len(t, analytics.Groups, 1)
+	require.Equal(t, fqn.WorkflowName, analytics.Groups[0].Workflow)
+	require.Empty(t, analytics.Groups[0].JobName)
+
+	// Jobs queued outside the range are left out
+	query.To = models.NewTime(now.Add(-time.Minute))
+	analytics, err = app.JobAnalyticsService.Analyze(ctx, nil, repo.ID, query)
+	require.NoError(t, err)
+	require.Empty(t, analytics.Groups)
+	require.Equal(t, 0, analytics.Totals.Overall.Jobs)
+
+	// Queries must not be broken down into too many windows
+	query.Interval = time.Minute
+	query.To = models.NewTime(query.From.Add(time.Duration(models.MaxJobAnalyticsWindows+1) * time.Minute))
+	_, err = app.JobAnalyticsService.Analyze(ctx, nil, repo.ID, query)
+	require.True(t, gerror.IsValidationFailed(err))
+}
@@ -0,0 +1,144 @@
+package leader_election
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/google/uuid"
+	"golang.org/x/net/context"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/util"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// leaderLeaseName names the lease held by the leader in the database.
+	leaderLeaseName = "server"
+	// DefaultLeaseDuration is how long the leader's lease lasts if it isn't renewed. If the leader stops
+	// without releasing its lease, another server will take over at most this long afterwards.
+	DefaultLeaseDuration = 30 * time.Second
+	// leaseTimeout is the maximum time to spend acquiring or renewing the lease.
+	leaseTimeout = 10 * time.Second
+)
+
+type LeaderElectionConfig struct {
+	// LeaseDuration is how long the leader's lease lasts if it isn't renewed. The lease is renewed every third
+	// of this duration. Defaults to DefaultLeaseDuration if zero.
+	LeaseDuration time.Duration
+}
+
+// LeaderElectionService elects one of the servers sharing a database as the leader, using a lease stored in
+// the database. Every server periodically tries to acquire the lease; the server holding it renews it for as
+// long as it runs, and releases it when stopped so another server can take over straight away. A server
+// stops considering itself the leader as soon as its lease would have expired, even if it can't reach the
+// database to find out whether another server has taken over.
+type LeaderElectionService struct {
+	*util.StatefulService
+	leaseStore store.LeaderLeaseStore
+	clk        clock.Clock
+	config     LeaderElectionConfig
+	holderID   string
+	mu         sync.Mutex
+	isLeader   bool
+	validUntil time.Time
+	logger.Log
+}
+
+// NewLeaderElectionService creates a LeaderElectionService and makes a first attempt to acquire the lease
+// before returning, so that a server running alone is the leader as soon as it starts. Returns a function
+// to call to stop renewing the lease and release it.
+func NewLeaderElectionService(
+	leaseStore store.LeaderLeaseStore,
+	clk clock.Clock,
+	config LeaderElectionConfig,
+	logFactory logger.LogFactory,
+) (*LeaderElectionService, func()) {
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = DefaultLeaseDuration
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	s := &LeaderElectionService{
+		leaseStore: leaseStore,
+		clk:        clk,
+		config:     config,
+		holderID:   fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), uuid.New().String()),
+		Log:        logFactory("LeaderElectionService"),
+	}
+	s.StatefulService = util.NewStatefulService(context.Background(), s.Log, s.loop)
+	s.tryAcquire()
+	s.Start()
+	return s, s.Stop
+}
+
+// IsLeader returns true if this server is currently the leader.
+func (s *LeaderElectionService) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isLeader && s.clk.Now().Before(s.validUntil)
+}
+
+// Stop renewing the lease, and release it if this server is the leader.
+func (s *LeaderElectionService) Stop() {
+	s.StatefulService.Stop()
+	s.mu.Lock()
+	wasLeader := s.isLeader
+	s.isLeader = false
+	s.mu.Unlock()
+	if wasLeader {
+		ctx, cancel := context.WithTimeout(context.Background(), leaseTimeout)
+		defer cancel()
+		err := s.leaseStore.Release(ctx, nil, leaderLeaseName, s.holderID)
+		if err != nil {
+			s.Warnf("Error releasing leader lease; another server will take over when it expires: %v", err)
+			return
+		}
+		s.Infof("Released leader lease")
+	}
+}
+
+func (s *LeaderElectionService) loop() {
+	s.Tracef("Starting leader election loop...")
+	for {
+		select {
+		case <-s.StatefulService.Ctx().Done():
+			s.Tracef("Leader election service closed; exiting...")
+			return
+
+		case <-s.clk.After(s.config.LeaseDuration / 3):
+			s.tryAcquire()
+		}
+	}
+}
+
+// tryAcquire acquires the lease if it is free, or renews it if this server already holds it.
+func (s *LeaderElectionService) tryAcquire() {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseTimeout)
+	defer cancel()
+	// The database measures the lease from when it handles the request, using its own clock; measure our view
+	// of the lease from before the request is made, so it never outlasts the database's
+	validUntil := s.clk.Now().Add(s.config.LeaseDuration)
+	acquired, err := s.leaseStore.TryAcquire(ctx, nil, leaderLeaseName, s.holderID, s.config.LeaseDuration)
+	if err != nil {
+		// Stay leader until the lease we already hold expires, in case the problem is transient
+		s.Warnf("Error acquiring or renewing leader lease: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acquired && !s.isLeader {
+		s.Infof("This server is now the leader (%s)", s.holderID)
+	} else if !acquired && s.isLeader {
+		s.Warnf("This server is no longer the leader; another server holds the leader lease")
+	}
+	s.isLeader = acquired
+	if acquired {
+		s.validUntil = validUntil
+	}
+}
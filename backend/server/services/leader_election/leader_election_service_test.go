@@ -0,0 +1,95 @@
+package leader_election
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// testLeaseStore is an in-memory LeaderLeaseStore that can be made to fail.
+type testLeaseStore struct {
+	clk       clock.Clock
+	mu        sync.Mutex
+	holder    string
+	expiresAt models.Time
+	fail      bool
+}
+
+func (d *testLeaseStore) TryAcquire(ctx context.Context, txOrNil *store.Tx, name string, holder string, leaseDuration time.Duration) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fail {
+		return false, fmt.Errorf("error database unavailable")
+	}
+	now := d.clk.Now()
+	if d.holder != "" && d.holder != holder && d.expiresAt.After(now) {
+		return false, nil
+	}
+	d.holder = holder
+	d.expiresAt = models.NewTime(now.Add(leaseDuration))
+	return true, nil
+}
+
+func (d *testLeaseStore) Release(ctx context.Context, txOrNil *store.Tx, name string, holder string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.holder == holder {
+		d.holder = ""
+	}
+	return nil
+}
+
+func (d *testLeaseStore) setFail(fail bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fail = fail
+}
+
+func TestLeaderElection(t *testing.T) {
+	logRegistry, err := logger.NewLogRegistry("")
+	require.Nil(t, err)
+	logFactory := logger.MakeLogrusLogFactoryStdOut(logRegistry)
+	clk := clock.NewMock()
+	clk.Set(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	leaseStore := &testLeaseStore{clk: clk}
+	config := LeaderElectionConfig{LeaseDuration: 30 * time.Second}
+
+	first, stopFirst := NewLeaderElectionService(leaseStore, clk, config, logFactory)
+	require.True(t, first.IsLeader(), "First server should be leader as soon as it starts")
+
+	second, stopSecond := NewLeaderElectionService(leaseStore, clk, config, logFactory)
+	defer stopSecond()
+	require.False(t, second.IsLeader(), "Only one server should be leader")
+
+	// If the leader can't renew its lease it must stop being leader once the lease would have expired
+	leaseStore.setFail(true)
+	first.tryAcquire()
+	require.True(t, first.IsLeader(), "Leader should remain leader until its lease expires")
+	clk.Add(config.LeaseDuration)
+	require.False(t, first.IsLeader(), "Leader should not remain leader after its lease expires")
+	leaseStore.setFail(false)
+
+	// Another server can then take over
+	second.tryAcquire()
+	require.True(t, second.IsLeader())
+	first.tryAcquire()
+	require.False(t, first.IsLeader())
+
+	// When the leader stops it releases the lease so another server can take over straight away
+	stopFirst()
+	third, stopThird := NewLeaderElectionService(leaseStore, clk, config, logFactory)
+	defer stopThird()
+	require.False(t, third.IsLeader())
+	stopSecond()
+	third.tryAcquire()
+	require.True(t, third.IsLeader())
+}
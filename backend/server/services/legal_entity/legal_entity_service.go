@@ -7,10 +7,12 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/buildbeaver/buildbeaver/server/dto"
 	"github.com/buildbeaver/buildbeaver/server/store"
 
 	"github.com/buildbeaver/buildbeaver/server/services"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 )
@@ -203,6 +205,72 @@ func (s *LegalEntityService) Update(ctx context.Context, txOrNil *store.Tx, lega
 	return s.legalEntityStore.Update(ctx, txOrNil, legalEntity)
 }
 
+// SetDisabled disables a legal entity so that it can no longer authenticate, or enables it again if disabled is
+// false. Disabling a legal entity keeps all of its data, including its memberships and grants.
+// Returns the legal entity as it is after the change.
+func (s *LegalEntityService) SetDisabled(ctx context.Context, txOrNil *store.Tx, id models.LegalEntityID, disabled bool) (*models.LegalEntity, error) {
+	var legalEntity *models.LegalEntity
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		var err error
+		legalEntity, err = s.legalEntityStore.Read(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error reading legal entity: %w", err)
+		}
+		if legalEntity.IsDisabled() == disabled {
+			return nil
+		}
+		if disabled {
+			now := models.NewTime(time.Now())
+			legalEntity.DisabledAt = &now
+		} else {
+			legalEntity.DisabledAt = nil
+		}
+		err = s.legalEntityStore.Update(ctx, tx, legalEntity)
+		if err != nil {
+			return fmt.Errorf("error updating legal entity: %w", err)
+		}
+		if disabled {
+			s.Infof("Disabled legal entity %q", legalEntity.ID)
+		} else {
+			s.Infof("Enabled legal entity %q", legalEntity.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return legalEntity, nil
+}
+
+// UpdateSchedulingWeight sets a legal entity's share of the runners it shares with other legal entities,
+// when jobs from several legal entities are queued.
+func (s *LegalEntityService) UpdateSchedulingWeight(ctx context.Context, legalEntityID models.LegalEntityID, update dto.UpdateLegalEntitySchedulingWeight) (*models.LegalEntity, error) {
+	err := models.ValidateLegalEntitySchedulingWeight(update.SchedulingWeight)
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	var legalEntity *models.LegalEntity
+	err = s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		var err error
+		legalEntity, err = s.legalEntityStore.Read(ctx, tx, legalEntityID)
+		if err != nil {
+			return fmt.Errorf("error reading legal entity: %w", err)
+		}
+		legalEntity.ETag = models.GetETag(legalEntity, update.ETag)
+		legalEntity.SchedulingWeight = update.SchedulingWeight
+		legalEntity.UpdatedAt = models.NewTime(time.Now())
+		err = s.legalEntityStore.Update(ctx, tx, legalEntity)
+		if err != nil {
+			return fmt.Errorf("error updating legal entity: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return legalEntity, nil
+}
+
 // ListParentLegalEntities lists all legal entities a legal entity is a member of. Use cursor to page through results, if any.
 func (s *LegalEntityService) ListParentLegalEntities(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.LegalEntity, *models.Cursor, error) {
 	return s.legalEntityStore.ListParentLegalEntities(ctx, txOrNil, legalEntityID, pagination)
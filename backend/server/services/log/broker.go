@@ -0,0 +1,108 @@
+package log
+
+import (
+	"sync"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// subscriptionBufferSize is the number of live entries that can be queued for a subscriber before it
+// is considered too slow and is downgraded to reading from the blob store.
+const subscriptionBufferSize = 1000
+
+// subscription receives live log entries for a single log as they are written to this server.
+type subscription struct {
+	logID models.LogDescriptorID
+	// entries receives each entry as it is written. It is closed if the subscriber falls too far behind,
+	// after which the subscriber must rely on polling the blob store for new entries.
+	entries chan encodedEntry
+	// sealed is closed when the log is sealed.
+	sealed chan struct{}
+	// closed is true once entries has been closed; guarded by the broker's mutex.
+	closed bool
+}
+
+// broker fans out live log entries from the writers on this server to any readers tailing the same log.
+// The broker only knows about entries written to this server; readers must still poll the blob store
+// to pick up entries written via other servers.
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[models.LogDescriptorID]map[*subscription]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{
+		subscribers: make(map[models.LogDescriptorID]map[*subscription]struct{}),
+	}
+}
+
+// subscribe registers a new subscription for live entries written to the specified log.
+// Call unsubscribe once finished with the subscription.
+func (b *broker) subscribe(logID models.LogDescriptorID) *subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := &subscription{
+		logID:   logID,
+		entries: make(chan encodedEntry, subscriptionBufferSize),
+		sealed:  make(chan struct{}),
+	}
+	subs, ok := b.subscribers[logID]
+	if !ok {
+		subs = make(map[*subscription]struct{})
+		b.subscribers[logID] = subs
+	}
+	subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes a subscription from the broker. It is safe to call more than once.
+func (b *broker) unsubscribe(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs, ok := b.subscribers[sub.logID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.logID)
+	}
+	if !sub.closed {
+		close(sub.entries)
+		sub.closed = true
+	}
+}
+
+// publish sends an entry to all subscribers of the specified log. This never blocks; subscribers
+// that are not keeping up have their entries channel closed and will no longer receive live entries.
+func (b *broker) publish(logID models.LogDescriptorID, entry encodedEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers[logID] {
+		if sub.closed {
+			continue
+		}
+		select {
+		case sub.entries <- entry:
+		default:
+			close(sub.entries)
+			sub.closed = true
+		}
+	}
+}
+
+// seal notifies all subscribers of the specified log that the log has been sealed.
+func (b *broker) seal(logID models.LogDescriptorID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers[logID] {
+		select {
+		case <-sub.sealed:
+		default:
+			close(sub.sealed)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package log
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/pkg/errors"
@@ -15,8 +20,30 @@ import (
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
+const (
+	// DefaultTailPollInterval is used if LogServiceConfig does not specify a TailPollInterval.
+	DefaultTailPollInterval = 5 * time.Second
+	// DefaultArchivePrefix is used if LogServiceConfig does not specify an ArchivePrefix.
+	DefaultArchivePrefix = "archive/"
+	// DefaultRetentionInterval is used if LogServiceConfig does not specify a RetentionInterval.
+	DefaultRetentionInterval = 1 * time.Hour
+)
+
 type LogServiceConfig struct {
 	WriterConfig WriterConfig
+	// TailPollInterval is how often readers following a log check the blob store for entries written
+	// via other servers, and check whether the log has been sealed.
+	TailPollInterval time.Duration
+	// ArchiveAfter is how long after a log is created that its data is compressed and moved to the archive
+	// prefix in the blob store. Zero disables archival.
+	ArchiveAfter time.Duration
+	// DeleteAfter is how long after a log is created that its data is deleted. Zero disables deletion.
+	DeleteAfter time.Duration
+	// ArchivePrefix is the blob store key prefix archived logs are moved to. Point cold storage lifecycle
+	// rules (e.g. an S3 storage class transition) at this prefix.
+	ArchivePrefix string
+	// RetentionInterval is how often the retention policy is applied.
+	RetentionInterval time.Duration
 }
 
 type LogService struct {
@@ -28,6 +55,10 @@ type LogService struct {
 	blobStore      services.BlobStore
 	logStore       store.LogStore
 	ownershipStore store.OwnershipStore
+	broker         *broker
+	startStopMutex sync.Mutex
+	exitChan       chan bool
+	wg             sync.WaitGroup
 }
 
 func NewLogService(
@@ -39,6 +70,15 @@ func NewLogService(
 	logContainerStore store.LogStore,
 	ownershipStore store.OwnershipStore) *LogService {
 
+	if config.TailPollInterval <= 0 {
+		config.TailPollInterval = DefaultTailPollInterval
+	}
+	if config.ArchivePrefix == "" {
+		config.ArchivePrefix = DefaultArchivePrefix
+	}
+	if config.RetentionInterval <= 0 {
+		config.RetentionInterval = DefaultRetentionInterval
+	}
 	return &LogService{
 		log:            logFactory("LogService"),
 		logFactory:     logFactory,
@@ -48,6 +88,7 @@ func NewLogService(
 		blobStore:      blobStore,
 		logStore:       logContainerStore,
 		ownershipStore: ownershipStore,
+		broker:         newBroker(),
 	}
 }
 
@@ -82,6 +123,12 @@ func (l *LogService) Read(ctx context.Context, txOrNil *store.Tx, id models.LogD
 	return l.logStore.Read(ctx, txOrNil, id)
 }
 
+// ListByIDs reads the log descriptors with the specified IDs, in no particular order. IDs of log descriptors
+// that do not exist are ignored.
+func (l *LogService) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.LogDescriptorID) ([]*models.LogDescriptor, error) {
+	return l.logStore.ListByIDs(ctx, txOrNil, ids)
+}
+
 // Search all log descriptors. If searcher is set, the results will be limited to log descriptors the searcher
 // is authorized to see (via the read:build permission). Use cursor to page through results, if any.
 func (l *LogService) Search(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, search models.LogDescriptorSearch) ([]*models.LogDescriptor, *models.Cursor, error) {
@@ -97,14 +144,77 @@ func (l *LogService) WriteData(ctx context.Context, logDescriptorID models.LogDe
 	if descriptor.Sealed {
 		return gerror.NewErrLogClosed()
 	}
-	writer := newWriter(l.logFactory, l.clk, l.config.WriterConfig, l.blobStore, descriptor)
+	writer := newWriter(l.logFactory, l.clk, l.config.WriterConfig, l.blobStore, l.broker, descriptor)
 	writer.Start()
 	defer writer.Stop()
 	return writer.drain(ctx, reader)
 }
 
-// ReadData opens a read stream to a log descriptor's data.
+// AppendError writes an error line to the end of a log, after any entries already written to it. This is used
+// to record why a log is being closed when its writer (e.g. a runner) can no longer write to it.
+func (l *LogService) AppendError(ctx context.Context, logDescriptorID models.LogDescriptorID, text string) error {
+	descriptor, err := l.logStore.Read(ctx, nil, logDescriptorID)
+	if err != nil {
+		return fmt.Errorf("error reading log descriptor: %w", err)
+	}
+	chunks, err := l.listChunks(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+	// Chunk keys record the (exclusive) end sequence number of the entries in the chunk
+	nextSeqNo := 1
+	for _, chunk := range chunks {
+		match := logChunkKeyFormatRegex.FindStringSubmatch(chunk.Key)
+		if match == nil {
+			continue
+		}
+		endSeqNo, err := strconv.Atoi(match[3])
+		if err != nil {
+			continue
+		}
+		if endSeqNo > nextSeqNo {
+			nextSeqNo = endSeqNo
+		}
+	}
+	entries := []*models.LogEntry{
+		models.NewLogEntryError(nextSeqNo, models.NewTime(l.clk.Now()), text, -1, nil),
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("error marshalling log entry to JSON: %w", err)
+	}
+	return l.WriteData(ctx, logDescriptorID, bytes.NewReader(data))
+}
+
+// ReadData opens a read stream to a log descriptor's data. If search.Follow is set then the stream
+// remains open and new entries are streamed as they are written, until the log is sealed or ctx is done.
 func (l *LogService) ReadData(ctx context.Context, logID models.LogDescriptorID, search *models.LogSearch) (io.ReadCloser, error) {
+	err := search.Validate()
+	if err != nil {
+		return nil, err
+	}
+	plaintext := false
+	if search.Plaintext != nil {
+		plaintext = *search.Plaintext
+	}
+	if search.Follow != nil && *search.Follow {
+		log, err := l.Read(ctx, nil, logID)
+		if err != nil {
+			return nil, fmt.Errorf("error reading log descriptor: %w", err)
+		}
+		if log.IsExpired() {
+			return nil, gerror.NewErrLogExpired()
+		}
+		config := tailerConfig{
+			pollInterval: l.config.TailPollInterval,
+			gapWait:      2 * l.config.WriterConfig.ChunkTTL,
+		}
+		readDescriptor := func(ctx context.Context, id models.LogDescriptorID) (*models.LogDescriptor, error) {
+			return l.Read(ctx, nil, id)
+		}
+		return newTailer(ctx, l.logFactory, l.clk, config, l.blobStore, l.broker, readDescriptor, log, search.StartSeqNo, plaintext), nil
+	}
+
 	var logs []*models.LogDescriptor
 	if search.Expand != nil && *search.Expand {
 		pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
@@ -123,13 +233,12 @@ func (l *LogService) ReadData(ctx context.Context, logID models.LogDescriptorID,
 		if err != nil {
 			return nil, fmt.Errorf("error reading log descriptor")
 		}
+		if log.IsExpired() {
+			return nil, gerror.NewErrLogExpired()
+		}
 		logs = []*models.LogDescriptor{log}
 	}
 
-	plaintext := false
-	if search.Plaintext != nil {
-		plaintext = *search.Plaintext
-	}
 	reader := newReader(ctx, l.logFactory, l.blobStore, &query{
 		descriptors: logs,
 		startSeqNo:  search.StartSeqNo,
@@ -138,6 +247,61 @@ func (l *LogService) ReadData(ctx context.Context, logID models.LogDescriptorID,
 	return reader, nil
 }
 
+// ReadWithWait reads an existing log descriptor. If the log has not been sealed then waits for up to wait
+// for it to be sealed, and returns the descriptor as it stands when the log is sealed or the wait expires.
+// Logs sealed via this server are noticed straight away; logs sealed via other servers are noticed the next
+// time the descriptor is polled, every TailPollInterval.
+func (l *LogService) ReadWithWait(ctx context.Context, id models.LogDescriptorID, wait time.Duration) (*models.LogDescriptor, error) {
+	if wait <= 0 {
+		return l.Read(ctx, nil, id)
+	}
+	// Subscribe before the first read, so that the log being sealed during it isn't missed
+	sub := l.broker.subscribe(id)
+	defer l.broker.unsubscribe(sub)
+	ticker := l.clk.Ticker(l.config.TailPollInterval)
+	defer ticker.Stop()
+	timer := l.clk.Timer(wait)
+	defer timer.Stop()
+	sealedC := sub.sealed
+	for {
+		descriptor, err := l.Read(ctx, nil, id)
+		if err != nil || descriptor.Sealed {
+			return descriptor, err
+		}
+		select {
+		case <-sealedC:
+			// The broker only notifies once; if the seal's transaction was rolled back, fall back to polling
+			sealedC = nil
+		case <-ticker.C:
+		case <-timer.C:
+			return descriptor, nil
+		case <-ctx.Done():
+			return descriptor, nil
+		}
+	}
+}
+
+// ReadSize returns the size in bytes of a log descriptor's data written so far. The size of a log that is
+// still being written is calculated from its data, so this should not be called more often than necessary.
+func (l *LogService) ReadSize(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) (int64, error) {
+	descriptor, err := l.logStore.Read(ctx, txOrNil, id)
+	if err != nil {
+		return 0, fmt.Errorf("error reading log descriptor: %w", err)
+	}
+	if descriptor.Sealed {
+		return descriptor.SizeBytes, nil
+	}
+	chunks, err := l.listChunks(ctx, descriptor)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, blob := range chunks {
+		size += blob.SizeBytes
+	}
+	return size, nil
+}
+
 // Seal a log descriptor and its data, making it immutable going forward.
 func (l *LogService) Seal(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) error {
 	descriptor, err := l.logStore.Read(ctx, txOrNil, id)
@@ -149,18 +313,9 @@ func (l *LogService) Seal(ctx context.Context, txOrNil *store.Tx, id models.LogD
 	}
 	// TODO size calculation should probably be out of band in future as it can tie up a transaction
 	//  for an extended period of time, and it shouldn't be in the critical path of finalizing a build/job/step.
-	limit := 1000
-	blobs, cursor, err := l.blobStore.ListBlobs(ctx, fmt.Sprintf(logChunkKeyBaseFormat, descriptor.ResourceID, descriptor.ID), "", models.NewPagination(limit, nil))
+	all, err := l.listChunks(ctx, descriptor)
 	if err != nil {
-		return fmt.Errorf("error listing initial log parts: %w", err)
-	}
-	all := blobs
-	for cursor != nil && cursor.Next != nil {
-		blobs, cursor, err = l.blobStore.ListBlobs(ctx, fmt.Sprintf(logChunkKeyBaseFormat, descriptor.ResourceID, descriptor.ID), "", models.NewPagination(limit, cursor.Next))
-		if err != nil {
-			return fmt.Errorf("error listing log parts page: %w", err)
-		}
-		all = append(all, blobs...)
+		return err
 	}
 	for _, blob := range all {
 		descriptor.SizeBytes += blob.SizeBytes
@@ -171,5 +326,8 @@ func (l *LogService) Seal(ctx context.Context, txOrNil *store.Tx, id models.LogD
 	if err != nil {
 		return fmt.Errorf("error updating log descriptor: %w", err)
 	}
+	// Let anyone following the log know it's finished. If the transaction is rolled back then followers
+	// will notice the log is still unsealed the next time they poll it.
+	l.broker.seal(id)
 	return nil
 }
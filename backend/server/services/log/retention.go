@@ -0,0 +1,352 @@
+package log
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// logArchiveKeyFormat is the key of the compressed blob holding an archived log's data, relative to the
+	// archive prefix.
+	logArchiveKeyFormat = "%slogs/%s/%s.json.gz"
+	// retentionBatchSize is the number of logs to read from the database at a time when applying the
+	// retention policy.
+	retentionBatchSize = 100
+	// retentionTimeout is the maximum amount of time a single run of the retention policy may take.
+	retentionTimeout = 1 * time.Hour
+)
+
+// Archive compresses a sealed log's data into a single blob under the archive prefix, and deletes the original
+// chunks. Archived logs remain readable. Does nothing if the log has already been archived or expired.
+func (l *LogService) Archive(ctx context.Context, id models.LogDescriptorID) error {
+	descriptor, err := l.logStore.Read(ctx, nil, id)
+	if err != nil {
+		return fmt.Errorf("error reading log descriptor: %w", err)
+	}
+	if !descriptor.Sealed {
+		return fmt.Errorf("error log must be sealed before it can be archived")
+	}
+	if descriptor.IsArchived() || descriptor.IsExpired() {
+		return nil
+	}
+	chunks, err := l.listChunks(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf(logArchiveKeyFormat, l.config.ArchivePrefix, descriptor.ResourceID, descriptor.ID)
+	size, err := l.writeArchive(ctx, descriptor, key)
+	if err != nil {
+		return fmt.Errorf("error writing log archive: %w", err)
+	}
+	now := models.NewTime(l.clk.Now())
+	descriptor.ArchivedAt = &now
+	descriptor.ArchiveBlobKey = key
+	descriptor.ArchivedSizeBytes = size
+	descriptor.UpdatedAt = now
+	err = l.logStore.Update(ctx, nil, descriptor)
+	if err != nil {
+		deleteErr := l.blobStore.DeleteBlob(ctx, key)
+		if deleteErr != nil {
+			l.log.Warnf("Ignoring error deleting log archive %q after failed update: %v", key, deleteErr)
+		}
+		return fmt.Errorf("error updating log descriptor: %w", err)
+	}
+	// Readers now use the archive. If any chunks can't be deleted they will be picked up again when the log expires.
+	return l.deleteChunks(ctx, chunks)
+}
+
+// Expire deletes a sealed log's data, whether or not it has been archived. The log descriptor itself is kept
+// so that it can be reported as expired. Does nothing if the log has already been expired.
+func (l *LogService) Expire(ctx context.Context, id models.LogDescriptorID) error {
+	descriptor, err := l.logStore.Read(ctx, nil, id)
+	if err != nil {
+		return fmt.Errorf("error reading log descriptor: %w", err)
+	}
+	if !descriptor.Sealed {
+		return fmt.Errorf("error log must be sealed before it can be expired")
+	}
+	if descriptor.IsExpired() {
+		return nil
+	}
+	// Delete the data before marking the log as expired, so that if anything fails the log will be retried next run
+	chunks, err := l.listChunks(ctx, descriptor)
+	if err != nil {
+		return err
+	}
+	err = l.deleteChunks(ctx, chunks)
+	if err != nil {
+		return err
+	}
+	if descriptor.IsArchived() {
+		err = l.blobStore.DeleteBlob(ctx, descriptor.ArchiveBlobKey)
+		if err != nil {
+			return fmt.Errorf("error deleting log archive %q: %w", descriptor.ArchiveBlobKey, err)
+		}
+	}
+	now := models.NewTime(l.clk.Now())
+	descriptor.ExpiredAt = &now
+	descriptor.UpdatedAt = now
+	err = l.logStore.Update(ctx, nil, descriptor)
+	if err != nil {
+		return fmt.Errorf("error updating log descriptor: %w", err)
+	}
+	return nil
+}
+
+// Delete permanently and idempotently deletes a log, including its data and any archive of its data, whether or not
+// the log has been sealed. The data is deleted first, so if anything fails the log is still recorded and the delete
+// can be retried. Any logs that have this log as their parent must be deleted first.
+func (l *LogService) Delete(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) error {
+	descriptor, err := l.logStore.Read(ctx, txOrNil, id)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading log descriptor: %w", err)
+	}
+	if !descriptor.IsExpired() {
+		chunks, err := l.listChunks(ctx, descriptor)
+		if err != nil {
+			return err
+		}
+		err = l.deleteChunks(ctx, chunks)
+		if err != nil {
+			return err
+		}
+		if descriptor.IsArchived() {
+			err = l.blobStore.DeleteBlob(ctx, descriptor.ArchiveBlobKey)
+			if err != nil {
+				return fmt.Errorf("error deleting log archive %q: %w", descriptor.ArchiveBlobKey, err)
+			}
+		}
+	}
+	return l.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := l.logStore.Delete(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error deleting log descriptor: %w", err)
+		}
+		err = l.ownershipStore.Delete(ctx, tx, id.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		return nil
+	})
+}
+
+// ApplyRetention expires all logs older than the configured DeleteAfter age, and archives all remaining logs
+// older than the configured ArchiveAfter age. Either step is skipped if its age is not configured.
+// Logs that can't be archived or expired are logged and counted, and will be retried on the next run.
+func (l *LogService) ApplyRetention(ctx context.Context, now models.Time) (*models.LogRetentionResult, error) {
+	result := &models.LogRetentionResult{}
+	if l.config.DeleteAfter > 0 {
+		createdBefore := models.NewTime(now.Add(-l.config.DeleteAfter))
+		err := l.applyToBatches(ctx, createdBefore, l.logStore.ListExpirable, l.Expire, &result.Expired, &result.Failed)
+		if err != nil {
+			return result, fmt.Errorf("error expiring logs: %w", err)
+		}
+	}
+	if l.config.ArchiveAfter > 0 {
+		createdBefore := models.NewTime(now.Add(-l.config.ArchiveAfter))
+		err := l.applyToBatches(ctx, createdBefore, l.logStore.ListArchivable, l.Archive, &result.Archived, &result.Failed)
+		if err != nil {
+			return result, fmt.Errorf("error archiving logs: %w", err)
+		}
+	}
+	if result.Archived > 0 || result.Expired > 0 || result.Failed > 0 {
+		l.log.Infof("Applied log retention policy: %d archived, %d expired, %d failed", result.Archived, result.Expired, result.Failed)
+	}
+	return result, nil
+}
+
+// applyToBatches repeatedly lists a batch of logs and applies fn to each one, until there are no more logs
+// to process or an entire batch fails.
+func (l *LogService) applyToBatches(
+	ctx context.Context,
+	createdBefore models.Time,
+	list func(ctx context.Context, txOrNil *store.Tx, createdBefore models.Time, limit int) ([]*models.LogDescriptor, error),
+	fn func(ctx context.Context, id models.LogDescriptorID) error,
+	succeeded *int,
+	failed *int,
+) error {
+	for {
+		descriptors, err := list(ctx, nil, createdBefore, retentionBatchSize)
+		if err != nil {
+			return err
+		}
+		progressed := false
+		for _, descriptor := range descriptors {
+			err = fn(ctx, descriptor.ID)
+			if err != nil {
+				l.log.Warnf("Error applying retention policy to log %s: %v", descriptor.ID, err)
+				*failed++
+				continue
+			}
+			*succeeded++
+			progressed = true
+		}
+		// Failed logs are listed again in the next batch, so stop once a batch makes no progress
+		if len(descriptors) < retentionBatchSize || !progressed {
+			return nil
+		}
+	}
+}
+
+// UsageForRepo summarizes the storage used by the logs belonging to a repo's builds, jobs and steps.
+func (l *LogService) UsageForRepo(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) (*models.LogUsage, error) {
+	return l.logStore.UsageByRepoID(ctx, txOrNil, repoID)
+}
+
+// StartRetention starts periodically applying the log retention policy in the background, whenever
+// leaderElection reports that this server is the leader.
+// Does nothing if neither ArchiveAfter nor DeleteAfter are configured.
+func (l *LogService) StartRetention(leaderElection services.LeaderElectionService) {
+	l.startStopMutex.Lock()
+	defer l.startStopMutex.Unlock()
+
+	if l.exitChan != nil || (l.config.ArchiveAfter <= 0 && l.config.DeleteAfter <= 0) {
+		return
+	}
+	l.log.Infof("Applying log retention policy every %s (archive after %s, delete after %s)",
+		l.config.RetentionInterval, l.config.ArchiveAfter, l.config.DeleteAfter)
+	l.exitChan = make(chan bool)
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.retentionLoop(leaderElection)
+	}()
+}
+
+// StopRetention stops applying the log retention policy, waiting for any run in progress to complete.
+func (l *LogService) StopRetention() {
+	l.startStopMutex.Lock()
+	defer l.startStopMutex.Unlock()
+
+	if l.exitChan == nil {
+		return
+	}
+	close(l.exitChan)
+	l.wg.Wait()
+	l.exitChan = nil
+}
+
+func (l *LogService) retentionLoop(leaderElection services.LeaderElectionService) {
+	ticker := time.NewTicker(l.config.RetentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.exitChan:
+			l.log.Trace("Exiting log retention loop...")
+			return
+		case <-ticker.C:
+			if !leaderElection.IsLeader() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), retentionTimeout)
+			_, err := l.ApplyRetention(ctx, models.NewTime(l.clk.Now()))
+			cancel()
+			if err != nil {
+				l.log.Errorf("Error applying log retention policy: %v", err)
+			}
+		}
+	}
+}
+
+// writeArchive writes all entries in a log to a gzip compressed blob at key, in the same JSON array format
+// used for chunks. Returns the compressed size of the blob.
+func (l *LogService) writeArchive(ctx context.Context, descriptor *models.LogDescriptor, key string) (int64, error) {
+	pipeReader, pipeWriter := io.Pipe()
+	counter := &countingWriter{writer: pipeWriter}
+	go func() {
+		pipeWriter.CloseWithError(l.writeArchiveData(ctx, descriptor, counter))
+	}()
+	err := l.blobStore.PutBlob(ctx, key, pipeReader)
+	// Unblock the writer if the blob store stopped reading early
+	pipeReader.CloseWithError(fmt.Errorf("error blob store stopped reading archive"))
+	if err != nil {
+		return 0, err
+	}
+	return counter.count, nil
+}
+
+func (l *LogService) writeArchiveData(ctx context.Context, descriptor *models.LogDescriptor, writer io.Writer) error {
+	assembler := newWindowAssembler(ctx, l.logFactory, l.blobStore, descriptor, nil)
+	defer assembler.Close()
+	zipWriter := gzip.NewWriter(writer)
+	_, err := zipWriter.Write([]byte("["))
+	if err != nil {
+		return err
+	}
+	for first := true; ; first = false {
+		next, err := assembler.Next()
+		if err != nil {
+			return fmt.Errorf("error reading log entries: %w", err)
+		}
+		if next == nil {
+			break
+		}
+		if !first {
+			_, err = zipWriter.Write([]byte(","))
+			if err != nil {
+				return err
+			}
+		}
+		_, err = zipWriter.Write(next.raw)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = zipWriter.Write([]byte("]"))
+	if err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// listChunks lists all chunk blobs holding a log's uncompressed data.
+func (l *LogService) listChunks(ctx context.Context, descriptor *models.LogDescriptor) ([]*models.BlobDescriptor, error) {
+	prefix := fmt.Sprintf(logChunkKeyBaseFormat, descriptor.ResourceID, descriptor.ID)
+	limit := 1000
+	blobs, cursor, err := l.blobStore.ListBlobs(ctx, prefix, "", models.NewPagination(limit, nil))
+	if err != nil {
+		return nil, fmt.Errorf("error listing initial log parts: %w", err)
+	}
+	all := blobs
+	for cursor != nil && cursor.Next != nil {
+		blobs, cursor, err = l.blobStore.ListBlobs(ctx, prefix, "", models.NewPagination(limit, cursor.Next))
+		if err != nil {
+			return nil, fmt.Errorf("error listing log parts page: %w", err)
+		}
+		all = append(all, blobs...)
+	}
+	return all, nil
+}
+
+func (l *LogService) deleteChunks(ctx context.Context, chunks []*models.BlobDescriptor) error {
+	for _, chunk := range chunks {
+		err := l.blobStore.DeleteBlob(ctx, chunk.Key)
+		if err != nil {
+			return fmt.Errorf("error deleting log part %q: %w", chunk.Key, err)
+		}
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it to an underlying writer.
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
@@ -0,0 +1,155 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func TestLogRetention(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	clk.Set(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	blobStore := newTestBlobStore()
+	logStore := newTestLogStore()
+	logRegistry, err := logger.NewLogRegistry("")
+	require.Nil(t, err)
+	logFactory := logger.MakeLogrusLogFactoryStdOut(logRegistry)
+
+	service := NewLogService(logFactory, clk, nil, LogServiceConfig{
+		WriterConfig: DefaultWriterConfig,
+		ArchiveAfter: 7 * 24 * time.Hour,
+		DeleteAfter:  30 * 24 * time.Hour,
+	}, blobStore, logStore, nil)
+
+	// Write a sealed log split across two chunks
+	descriptor := models.NewLogDescriptor(models.NewTime(clk.Now()), models.LogDescriptorID{}, models.NewJobID().ResourceID)
+	descriptor.Sealed = true
+	logStore.descriptors[descriptor.ID] = descriptor
+	var expected []byte
+	for chunk := 0; chunk < 2; chunk++ {
+		var entries []*models.LogEntry
+		for i := 1; i <= 3; i++ {
+			seqNo := chunk*3 + i
+			entry := models.NewLogEntryLine(seqNo, models.NewTime(clk.Now()), fmt.Sprintf("line %d", seqNo), seqNo, nil)
+			entries = append(entries, entry)
+			expected = append(expected, entry.Derived().(models.PlainTextLogEntry).GetText()+"\n"...)
+		}
+		data, err := json.Marshal(entries)
+		require.Nil(t, err)
+		key := fmt.Sprintf(logChunkKeyFullFormat, descriptor.ResourceID, descriptor.ID, chunk*3+3, chunk*3+1, "foo")
+		err = blobStore.PutBlob(ctx, key, bytes.NewReader(data))
+		require.Nil(t, err)
+	}
+	chunkPrefix := fmt.Sprintf(logChunkKeyBaseFormat, descriptor.ResourceID, descriptor.ID)
+	plaintext := true
+
+	// Nothing is due yet
+	result, err := service.ApplyRetention(ctx, models.NewTime(clk.Now()))
+	require.Nil(t, err)
+	assert.Equal(t, models.LogRetentionResult{}, *result)
+
+	t.Run("Archive", func(t *testing.T) {
+		clk.Add(8 * 24 * time.Hour)
+		result, err := service.ApplyRetention(ctx, models.NewTime(clk.Now()))
+		require.Nil(t, err)
+		assert.Equal(t, models.LogRetentionResult{Archived: 1}, *result)
+
+		archived := logStore.descriptors[descriptor.ID]
+		require.True(t, archived.IsArchived())
+		assert.False(t, archived.IsExpired())
+		assert.True(t, strings.HasPrefix(archived.ArchiveBlobKey, DefaultArchivePrefix))
+		assert.Greater(t, archived.ArchivedSizeBytes, int64(0))
+		for key := range blobStore.blobs {
+			assert.False(t, strings.HasPrefix(key, chunkPrefix), "expected chunk %q to be deleted", key)
+		}
+
+		// Archived logs remain readable
+		reader, err := service.ReadData(ctx, descriptor.ID, &models.LogSearch{Plaintext: &plaintext})
+		require.Nil(t, err)
+		actual, err := ioutil.ReadAll(reader)
+		require.Nil(t, err)
+		assert.Equal(t, string(expected), string(actual))
+
+		// Archiving again is a no-op
+		result, err = service.ApplyRetention(ctx, models.NewTime(clk.Now()))
+		require.Nil(t, err)
+		assert.Equal(t, models.LogRetentionResult{}, *result)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		clk.Add(30 * 24 * time.Hour)
+		result, err := service.ApplyRetention(ctx, models.NewTime(clk.Now()))
+		require.Nil(t, err)
+		assert.Equal(t, models.LogRetentionResult{Expired: 1}, *result)
+
+		expired := logStore.descriptors[descriptor.ID]
+		assert.True(t, expired.IsExpired())
+		assert.Empty(t, blobStore.blobs)
+
+		_, err = service.ReadData(ctx, descriptor.ID, &models.LogSearch{Plaintext: &plaintext})
+		assert.True(t, gerror.IsLogExpired(err))
+	})
+}
+
+// testLogStore is an in-memory store.LogStore holding just enough behaviour to test log retention.
+type testLogStore struct {
+	store.LogStore
+	descriptors map[models.LogDescriptorID]*models.LogDescriptor
+}
+
+func newTestLogStore() *testLogStore {
+	return &testLogStore{descriptors: map[models.LogDescriptorID]*models.LogDescriptor{}}
+}
+
+func (s *testLogStore) Read(ctx context.Context, txOrNil *store.Tx, id models.LogDescriptorID) (*models.LogDescriptor, error) {
+	descriptor, ok := s.descriptors[id]
+	if !ok {
+		return nil, gerror.NewErrNotFound("Not Found")
+	}
+	copied := *descriptor
+	return &copied, nil
+}
+
+func (s *testLogStore) Update(ctx context.Context, txOrNil *store.Tx, descriptor *models.LogDescriptor) error {
+	copied := *descriptor
+	s.descriptors[descriptor.ID] = &copied
+	return nil
+}
+
+func (s *testLogStore) ListArchivable(ctx context.Context, txOrNil *store.Tx, createdBefore models.Time, limit int) ([]*models.LogDescriptor, error) {
+	return s.list(createdBefore, limit, func(descriptor *models.LogDescriptor) bool {
+		return !descriptor.IsArchived() && !descriptor.IsExpired()
+	})
+}
+
+func (s *testLogStore) ListExpirable(ctx context.Context, txOrNil *store.Tx, createdBefore models.Time, limit int) ([]*models.LogDescriptor, error) {
+	return s.list(createdBefore, limit, func(descriptor *models.LogDescriptor) bool {
+		return !descriptor.IsExpired()
+	})
+}
+
+func (s *testLogStore) list(createdBefore models.Time, limit int, filter func(descriptor *models.LogDescriptor) bool) ([]*models.LogDescriptor, error) {
+	var results []*models.LogDescriptor
+	for _, descriptor := range s.descriptors {
+		if descriptor.Sealed && descriptor.CreatedAt.Before(createdBefore.Time) && filter(descriptor) && len(results) < limit {
+			copied := *descriptor
+			results = append(results, &copied)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,291 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+// descriptorReader reads the latest version of a log descriptor.
+type descriptorReader func(ctx context.Context, id models.LogDescriptorID) (*models.LogDescriptor, error)
+
+type tailerConfig struct {
+	// pollInterval is how often to check the blob store for new entries and to check if the log has been sealed.
+	pollInterval time.Duration
+	// gapWait is how long to hold live entries that arrive out of sequence, while waiting for the missing
+	// entries to be persisted to the blob store, before giving up on the missing entries.
+	gapWait time.Duration
+}
+
+// tailer is an io.Reader that follows a single log that may still be being written to. It emits entries
+// already in the blob store, followed by live entries as they are written, until the log is sealed.
+// The stream uses the same format as reader, so clients can consume a followed log in the same way
+// as a completed one. If the tailer's context is done before the log is sealed then the stream is ended
+// without the log_end terminator.
+type tailer struct {
+	ctx            context.Context
+	log            logger.Log
+	logFactory     logger.LogFactory
+	clk            clock.Clock
+	config         tailerConfig
+	blobStore      services.BlobStore
+	broker         *broker
+	readDescriptor descriptorReader
+	descriptor     *models.LogDescriptor
+	plaintext      bool
+	sub            *subscription
+	ticker         *clock.Ticker
+	state          struct {
+		// lastSeqNo is the sequence number of the last entry written to the stream
+		lastSeqNo int
+		// pending is output that is waiting to be drained by successive calls to Read()
+		pending []byte
+		// held are live entries that arrived after a gap in the sequence, in the order they arrived
+		held []encodedEntry
+		// gapTimer fires when we should give up waiting for missing entries; nil if there are no held entries
+		gapTimer <-chan time.Time
+		// liveEntries is nil once the subscription has stopped delivering entries
+		liveEntries <-chan encodedEntry
+		// sealedC is nil once the seal notification has been received
+		sealedC <-chan struct{}
+		// started is true once the initial entries have been read from the blob store
+		started bool
+		// firstEntryWritten is true if we've written at least one entry
+		firstEntryWritten bool
+		// sealed is true once the descriptor has been observed to be sealed
+		sealed bool
+		// done is true once the stream terminator has been written
+		done bool
+	}
+}
+
+// newTailer creates a tailer for the specified log, subscribing to live entries from the broker.
+// If startSeqNo is non-nil then entries before it will be skipped. Call Close() once finished.
+func newTailer(
+	ctx context.Context,
+	logFactory logger.LogFactory,
+	clk clock.Clock,
+	config tailerConfig,
+	blobStore services.BlobStore,
+	broker *broker,
+	readDescriptor descriptorReader,
+	descriptor *models.LogDescriptor,
+	startSeqNo *int,
+	plaintext bool) *tailer {
+
+	t := &tailer{
+		ctx:            ctx,
+		log:            logFactory("LogTailer"),
+		logFactory:     logFactory,
+		clk:            clk,
+		config:         config,
+		blobStore:      blobStore,
+		broker:         broker,
+		readDescriptor: readDescriptor,
+		descriptor:     descriptor,
+		plaintext:      plaintext,
+		// Subscribe before we read anything from the blob store to ensure we can't miss entries in between
+		sub:    broker.subscribe(descriptor.ID),
+		ticker: clk.Ticker(config.pollInterval),
+	}
+	t.state.liveEntries = t.sub.entries
+	t.state.sealedC = t.sub.sealed
+	t.state.sealed = descriptor.Sealed
+	if startSeqNo != nil && *startSeqNo > 0 {
+		t.state.lastSeqNo = *startSeqNo - 1
+	}
+	if !plaintext {
+		t.state.pending = []byte{'['}
+	}
+	return t
+}
+
+func (t *tailer) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for len(t.state.pending) == 0 {
+		if t.state.done {
+			return 0, io.EOF
+		}
+		err := t.next()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, t.state.pending)
+	t.state.pending = t.state.pending[n:]
+	return n, nil
+}
+
+// Close the tailer, unsubscribing from live entries.
+func (t *tailer) Close() error {
+	t.ticker.Stop()
+	t.broker.unsubscribe(t.sub)
+	return nil
+}
+
+// next blocks until there is more output available, the log is sealed or the context is done.
+// Any output is appended to pending.
+func (t *tailer) next() error {
+	if !t.state.started {
+		t.state.started = true
+		return t.readStored()
+	}
+	if t.state.sealed {
+		return t.finish()
+	}
+	select {
+	case <-t.ctx.Done():
+		// Close the stream without the log_end terminator so the client knows the log isn't finished, and
+		// can reconnect using the seq no of the last entry it received
+		t.log.Debugf("Context done while following log %s: %v", t.descriptor.ID, t.ctx.Err())
+		t.state.done = true
+		if !t.plaintext {
+			t.state.pending = append(t.state.pending, ']')
+		}
+		return nil
+
+	case entry, ok := <-t.state.liveEntries:
+		if !ok {
+			t.log.Debugf("No longer receiving live entries for log %s; Falling back to polling", t.descriptor.ID)
+			t.state.liveEntries = nil
+			return nil
+		}
+		if len(t.state.held) > 0 || entry.seqNo > t.state.lastSeqNo+1 {
+			// Entries in the gap may have been written before we subscribed; give the writer a chance to
+			// persist them so we can read them from the blob store
+			t.state.held = append(t.state.held, entry)
+			if t.state.gapTimer == nil {
+				t.state.gapTimer = t.clk.After(t.config.gapWait)
+			}
+			return nil
+		}
+		return t.appendEntry(entry.seqNo, entry.data)
+
+	case <-t.state.gapTimer:
+		t.state.gapTimer = nil
+		err := t.readStored()
+		if err != nil {
+			return err
+		}
+		return t.releaseHeld(true)
+
+	case <-t.state.sealedC:
+		t.state.sealedC = nil
+		return t.poll()
+
+	case <-t.ticker.C:
+		return t.poll()
+	}
+}
+
+// poll checks whether the log has been sealed and reads any new entries from the blob store.
+func (t *tailer) poll() error {
+	descriptor, err := t.readDescriptor(t.ctx, t.descriptor.ID)
+	if err != nil {
+		return fmt.Errorf("error reading log descriptor: %w", err)
+	}
+	err = t.readStored()
+	if err != nil {
+		return err
+	}
+	if descriptor.Sealed {
+		// Nothing more will be written, so anything still missing is never going to turn up
+		t.state.sealed = true
+		return t.releaseHeld(true)
+	}
+	return t.releaseHeld(false)
+}
+
+// readStored appends all entries from the blob store that come after the last entry written to the stream.
+func (t *tailer) readStored() error {
+	startSeqNo := t.state.lastSeqNo + 1
+	assembler := newWindowAssembler(t.ctx, t.logFactory, t.blobStore, t.descriptor, &startSeqNo)
+	defer assembler.Close()
+	for {
+		next, err := assembler.Next()
+		if err != nil {
+			return fmt.Errorf("error reading stored log entries: %w", err)
+		}
+		if next == nil {
+			return nil
+		}
+		persistent := next.entry.Derived().(models.PersistentLogEntry)
+		err = t.appendEntry(persistent.GetSeqNo(), next.raw)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// releaseHeld appends held live entries that are now in sequence. If force is true then all held entries
+// are appended regardless of any remaining gaps.
+func (t *tailer) releaseHeld(force bool) error {
+	for len(t.state.held) > 0 {
+		entry := t.state.held[0]
+		if !force && entry.seqNo > t.state.lastSeqNo+1 {
+			return nil
+		}
+		t.state.held = t.state.held[1:]
+		err := t.appendEntry(entry.seqNo, entry.data)
+		if err != nil {
+			return err
+		}
+	}
+	t.state.gapTimer = nil
+	return nil
+}
+
+// appendEntry appends an encoded entry to the pending output, skipping entries that have already been written.
+func (t *tailer) appendEntry(seqNo int, raw json.RawMessage) error {
+	if seqNo <= t.state.lastSeqNo {
+		return nil
+	}
+	t.state.lastSeqNo = seqNo
+	if t.plaintext {
+		entry := &models.LogEntry{}
+		err := json.Unmarshal(raw, entry)
+		if err != nil {
+			return fmt.Errorf("error unmarshalling entry: %w", err)
+		}
+		text, ok := entry.Derived().(models.PlainTextLogEntry)
+		if ok {
+			// Skip this entry if it doesn't support plaintext
+			t.state.pending = append(t.state.pending, text.GetText()+"\n"...)
+		}
+		return nil
+	}
+	if t.state.firstEntryWritten {
+		t.state.pending = append(t.state.pending, ',')
+	}
+	t.state.pending = append(t.state.pending, raw...)
+	t.state.firstEntryWritten = true
+	return nil
+}
+
+// finish appends the stream terminator to the pending output.
+func (t *tailer) finish() error {
+	t.state.done = true
+	if t.plaintext {
+		return nil
+	}
+	raw, err := json.Marshal(models.NewLogEntryEnd())
+	if err != nil {
+		return fmt.Errorf("error marshaling end entry: %w", err)
+	}
+	if t.state.firstEntryWritten {
+		t.state.pending = append(t.state.pending, ',')
+	}
+	t.state.pending = append(t.state.pending, raw...)
+	t.state.pending = append(t.state.pending, ']')
+	return nil
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+var testTailerConfig = tailerConfig{
+	pollInterval: time.Minute,
+	gapWait:      time.Second,
+}
+
+type testDescriptorReader struct {
+	mu         sync.Mutex
+	descriptor models.LogDescriptor
+}
+
+func (r *testDescriptorReader) read(ctx context.Context, id models.LogDescriptorID) (*models.LogDescriptor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	descriptor := r.descriptor
+	return &descriptor, nil
+}
+
+func (r *testDescriptorReader) seal() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.descriptor.Sealed = true
+}
+
+func makeTestLine(clk clock.Clock, seqNo int) *models.LogEntry {
+	return models.NewLogEntryLine(seqNo, models.NewTime(clk.Now()), fmt.Sprintf("line %d", seqNo), seqNo, nil)
+}
+
+func putTestChunk(t *testing.T, blobStore *testBlobStore, descriptor *models.LogDescriptor, entries ...*models.LogEntry) {
+	buf, err := json.Marshal(entries)
+	require.NoError(t, err)
+	startSeqNo := entries[0].Derived().(models.PersistentLogEntry).GetSeqNo()
+	endSeqNo := entries[len(entries)-1].Derived().(models.PersistentLogEntry).GetSeqNo() + 1
+	key := fmt.Sprintf(logChunkKeyFullFormat, descriptor.ResourceID, descriptor.ID, endSeqNo, startSeqNo, "foo")
+	err = blobStore.PutBlob(context.Background(), key, bytes.NewReader(buf))
+	require.NoError(t, err)
+}
+
+func publishTestLine(t *testing.T, broker *broker, descriptor *models.LogDescriptor, entry *models.LogEntry) {
+	persistent := entry.Derived().(models.PersistentLogEntry)
+	data, err := json.Marshal(persistent)
+	require.NoError(t, err)
+	broker.publish(descriptor.ID, encodedEntry{seqNo: persistent.GetSeqNo(), data: data})
+}
+
+func TestLogTailer_FollowUntilSealed(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	blobStore := newTestBlobStore()
+	logFactory := logger.NoOpLogFactory
+	broker := newBroker()
+	descriptor := models.NewLogDescriptor(models.NewTime(clk.Now()), models.LogDescriptorID{}, models.NewJobID().ResourceID)
+	descriptors := &testDescriptorReader{descriptor: *descriptor}
+
+	putTestChunk(t, blobStore, descriptor, makeTestLine(clk, 1), makeTestLine(clk, 2))
+
+	tailer := newTailer(ctx, logFactory, clk, testTailerConfig, blobStore, broker, descriptors.read, descriptor, nil, true)
+	defer tailer.Close()
+	lines := bufio.NewReader(tailer)
+
+	// Stored entries are read first, followed by live entries as they are published
+	publishTestLine(t, broker, descriptor, makeTestLine(clk, 2)) // duplicate of a stored entry
+	publishTestLine(t, broker, descriptor, makeTestLine(clk, 3))
+	for i := 1; i <= 3; i++ {
+		line, err := lines.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("line %d\n", i), line)
+	}
+
+	// Once the log is sealed any remaining stored entries are read and the stream ends
+	putTestChunk(t, blobStore, descriptor, makeTestLine(clk, 3), makeTestLine(clk, 4))
+	descriptors.seal()
+	broker.seal(descriptor.ID)
+	rest, err := ioutil.ReadAll(lines)
+	require.NoError(t, err)
+	require.Equal(t, "line 4\n", string(rest))
+}
+
+func TestLogTailer_WaitsForGap(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	blobStore := newTestBlobStore()
+	logFactory := logger.NoOpLogFactory
+	broker := newBroker()
+	descriptor := models.NewLogDescriptor(models.NewTime(clk.Now()), models.LogDescriptorID{}, models.NewJobID().ResourceID)
+	descriptors := &testDescriptorReader{descriptor: *descriptor}
+
+	tailer := newTailer(ctx, logFactory, clk, testTailerConfig, blobStore, broker, descriptors.read, descriptor, nil, true)
+	defer tailer.Close()
+
+	// Entries 1 and 2 were written before we subscribed and have not been persisted yet
+	publishTestLine(t, broker, descriptor, makeTestLine(clk, 3))
+
+	doneC := make(chan string)
+	go func() {
+		lines := bufio.NewReader(tailer)
+		var read string
+		for i := 0; i < 3; i++ {
+			line, err := lines.ReadString('\n')
+			if err != nil {
+				break
+			}
+			read += line
+		}
+		doneC <- read
+	}()
+
+	putTestChunk(t, blobStore, descriptor, makeTestLine(clk, 1), makeTestLine(clk, 2))
+	for {
+		select {
+		case read := <-doneC:
+			require.Equal(t, "line 1\nline 2\nline 3\n", read)
+			return
+		case <-time.After(10 * time.Millisecond):
+			clk.Add(testTailerConfig.gapWait)
+		}
+	}
+}
+
+func TestLogTailer_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clk := clock.NewMock()
+	blobStore := newTestBlobStore()
+	logFactory := logger.NoOpLogFactory
+	broker := newBroker()
+	descriptor := models.NewLogDescriptor(models.NewTime(clk.Now()), models.LogDescriptorID{}, models.NewJobID().ResourceID)
+	descriptors := &testDescriptorReader{descriptor: *descriptor}
+
+	putTestChunk(t, blobStore, descriptor, makeTestLine(clk, 1))
+
+	tailer := newTailer(ctx, logFactory, clk, testTailerConfig, blobStore, broker, descriptors.read, descriptor, nil, false)
+	defer tailer.Close()
+	cancel()
+
+	// The stream should still be a valid JSON array, without the log_end terminator
+	data, err := ioutil.ReadAll(tailer)
+	require.NoError(t, err)
+	var entries []*models.LogEntry
+	err = json.Unmarshal(data, &entries)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, 1, entries[0].Derived().(models.PersistentLogEntry).GetSeqNo())
+}
@@ -23,6 +23,8 @@ type chunkDescriptor struct {
 	// endSeqNo is the last seqNo number covered by the chunk (exclusive)
 	endSeqNo  int
 	sessionID string
+	// compressed is true if the chunk's blob is gzip compressed
+	compressed bool
 }
 
 // chunkWindow wraps a set of logs chunks to provide a contiguous stream of log entries over a range of sequence numbers.
@@ -89,6 +91,19 @@ func (l *windowAssembler) Next() (*nextEntry, error) {
 					cursor *models.Cursor
 					err    error
 				)
+				if l.desc.IsExpired() {
+					// The log's data has been deleted so there's nothing to read
+					return nil, nil
+				}
+				if l.desc.IsArchived() {
+					if l.state.endOfStore {
+						return nil, nil
+					}
+					// All of an archived log's entries are in a single compressed blob
+					l.state.endOfStore = true
+					l.state.windows = []*chunkWindow{l.makeArchiveWindow()}
+					continue
+				}
 				if !l.state.endOfStore {
 					// Otherwise, produce more windows if we can
 					prefix := l.makeChunkListPrefix()
@@ -260,6 +275,19 @@ func (l *windowAssembler) parseChunkBlob(blob *models.BlobDescriptor) (*chunkDes
 	}, nil
 }
 
+// makeArchiveWindow produces a window over the single compressed blob holding an archived log's entries.
+func (l *windowAssembler) makeArchiveWindow() *chunkWindow {
+	chunk := &chunkDescriptor{
+		BlobDescriptor: &models.BlobDescriptor{Key: l.desc.ArchiveBlobKey, SizeBytes: l.desc.ArchivedSizeBytes},
+		resourceID:     l.desc.ResourceID,
+		compressed:     true,
+	}
+	return &chunkWindow{
+		desc:   l.desc,
+		chunks: []*chunkDescriptor{chunk},
+	}
+}
+
 // makeChunkListPrefix produces the most specific chunk blob key prefix possible given the current query.
 func (l *windowAssembler) makeChunkListPrefix() string {
 	return fmt.Sprintf(logChunkKeyBaseFormat, l.desc.ResourceID, l.desc.ID)
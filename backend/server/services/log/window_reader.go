@@ -1,6 +1,7 @@
 package log
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -59,6 +60,13 @@ func (l *windowReader) Next() (*nextEntry, error) {
 				l.state.err = fmt.Errorf("error reading data %q: %w", nextChunk.Key, err)
 				return nil, l.state.err
 			}
+			if nextChunk.compressed {
+				reader, err = newGzipReadCloser(reader)
+				if err != nil {
+					l.state.err = fmt.Errorf("error decompressing data %q: %w", nextChunk.Key, err)
+					return nil, l.state.err
+				}
+			}
 			l.state.decoder = json.NewDecoder(reader)
 			_, err = l.state.decoder.Token()
 			if err != nil {
@@ -118,3 +126,27 @@ func (l *windowReader) Close() error {
 	}
 	return nil
 }
+
+// gzipReadCloser decompresses data from an underlying reader, closing both on Close.
+type gzipReadCloser struct {
+	*gzip.Reader
+	underlying io.ReadCloser
+}
+
+func newGzipReadCloser(underlying io.ReadCloser) (*gzipReadCloser, error) {
+	reader, err := gzip.NewReader(underlying)
+	if err != nil {
+		underlying.Close()
+		return nil, err
+	}
+	return &gzipReadCloser{Reader: reader, underlying: underlying}, nil
+}
+
+func (r *gzipReadCloser) Close() error {
+	err := r.Reader.Close()
+	underlyingErr := r.underlying.Close()
+	if err != nil {
+		return err
+	}
+	return underlyingErr
+}
@@ -60,6 +60,7 @@ type writer struct {
 	log         logger.Log
 	config      WriterConfig
 	blobStore   services.BlobStore
+	broker      *broker
 	descriptor  *models.LogDescriptor
 	sessionID   string
 	entryInChan chan encodedEntry
@@ -73,14 +74,16 @@ type writer struct {
 }
 
 // newWriter creates a new writer service to buffer and write chunks of log entries to blob storage.
-// Call Start() on the writer before using, and Stop() once finished.
-func newWriter(logFactory logger.LogFactory, clk clock.Clock, config WriterConfig, blobStore services.BlobStore, descriptor *models.LogDescriptor) *writer {
+// If broker is non-nil, each entry is also published to the broker as it is read so that it can be tailed
+// by readers before it is persisted. Call Start() on the writer before using, and Stop() once finished.
+func newWriter(logFactory logger.LogFactory, clk clock.Clock, config WriterConfig, blobStore services.BlobStore, broker *broker, descriptor *models.LogDescriptor) *writer {
 	w := &writer{
 		clk:         clk,
 		log:         logFactory("LogWriter"),
 		config:      config,
 		descriptor:  descriptor,
 		blobStore:   blobStore,
+		broker:      broker,
 		sessionID:   uuid.New().String(),
 		entryInChan: make(chan encodedEntry),
 		flushChan:   make(chan *writerFlushRequest),
@@ -118,10 +121,14 @@ func (l *writer) drain(ctx context.Context, reader io.Reader) error {
 		if err != nil {
 			return fmt.Errorf("error marshalling entry to JSON: %w", err)
 		}
+		encoded := encodedEntry{data: data, seqNo: persistent.GetSeqNo()}
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case l.entryInChan <- encodedEntry{data: data, seqNo: persistent.GetSeqNo()}:
+		case l.entryInChan <- encoded:
+		}
+		if l.broker != nil {
+			l.broker.publish(l.descriptor.ID, encoded)
 		}
 	}
 	_, err = dec.Token()
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/benbjohnson/clock"
@@ -67,7 +68,7 @@ func testSuccess(
 		blobStore := newTestBlobStore()
 		blobStore.returnError = false
 
-		logWriter := newWriter(logFactory, clk, DefaultWriterConfig, blobStore, descriptor)
+		logWriter := newWriter(logFactory, clk, DefaultWriterConfig, blobStore, nil, descriptor)
 		logWriter.Start()
 		defer logWriter.Stop()
 
@@ -97,7 +98,7 @@ func testErrorHandling(
 		blobStore := newTestBlobStore()
 		blobStore.returnError = true
 
-		logWriter := newWriter(logFactory, clk, DefaultWriterConfig, blobStore, descriptor)
+		logWriter := newWriter(logFactory, clk, DefaultWriterConfig, blobStore, nil, descriptor)
 		logWriter.Start()
 		defer logWriter.Stop()
 
@@ -122,6 +123,7 @@ type blob struct {
 }
 
 type testBlobStore struct {
+	mu          sync.Mutex
 	blobs       map[string]*blob
 	returnError bool
 }
@@ -131,10 +133,13 @@ func newTestBlobStore() *testBlobStore {
 }
 
 func (s *testBlobStore) PutBlob(ctx context.Context, key string, source io.Reader) error {
+	// Read before locking, as the source may itself be streaming data from other blobs
 	data, err := ioutil.ReadAll(source)
 	if err != nil {
 		return fmt.Errorf("error reading data data: %w", err)
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.returnError {
 		return fmt.Errorf("error for testing purposes")
 	}
@@ -149,6 +154,8 @@ func (s *testBlobStore) PutBlob(ctx context.Context, key string, source io.Reade
 }
 
 func (s *testBlobStore) GetBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	blob, ok := s.blobs[key]
 	if !ok {
 		return nil, gerror.NewErrNotFound(fmt.Sprintf("error %q does not exist", key))
@@ -157,6 +164,8 @@ func (s *testBlobStore) GetBlob(ctx context.Context, key string) (io.ReadCloser,
 }
 
 func (s *testBlobStore) GetBlobRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	blob, ok := s.blobs[key]
 	if !ok {
 		return nil, gerror.NewErrNotFound(fmt.Sprintf("error %q does not exist", key))
@@ -165,11 +174,15 @@ func (s *testBlobStore) GetBlobRange(ctx context.Context, key string, offset, le
 }
 
 func (s *testBlobStore) DeleteBlob(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	delete(s.blobs, key)
 	return nil
 }
 
 func (s *testBlobStore) ListBlobs(ctx context.Context, prefix string, marker string, pagination models.Pagination) ([]*models.BlobDescriptor, *models.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	var (
 		keys   []string
 		blobs  []*models.BlobDescriptor
@@ -209,9 +222,13 @@ func (s *testBlobStore) ListBlobs(ctx context.Context, prefix string, marker str
 }
 
 func (s *testBlobStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.blobs = map[string]*blob{}
 }
 
 func (s *testBlobStore) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	delete(s.blobs, key)
 }
@@ -0,0 +1,233 @@
+package metrics_export
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+const (
+	DefaultBigQueryEndpoint = "https://bigquery.googleapis.com"
+	bigQueryRequestTimeout  = 30 * time.Second
+	// bigQueryMaxRowsPerRequest is the number of rows to send in each insertAll request; BigQuery recommends
+	// no more than 500 rows per request.
+	bigQueryMaxRowsPerRequest = 500
+	// gceMetadataTokenURL is used to obtain an access token for the default service account when running on GCP.
+	gceMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// BigQuerySink streams aggregated metrics into a BigQuery table using the tabledata.insertAll API.
+// Each row is given an insert ID derived from its window and labels, so BigQuery will de-duplicate rows
+// if a window is re-sent after a failure. The table must already exist, with the following schema:
+//
+//	window_start            TIMESTAMP
+//	window_end              TIMESTAMP
+//	kind                    STRING
+//	owner                   STRING
+//	repo                    STRING
+//	status                  STRING
+//	count                   INTEGER
+//	duration_seconds_sum    FLOAT
+//	duration_seconds_max    FLOAT
+//	queue_wait_seconds_sum  FLOAT
+//	queue_wait_seconds_max  FLOAT
+//
+// If no access token is configured then one is obtained from the GCE metadata server, for the service
+// account the server is running as. Only build and job metrics are written; rate limit counts are operational
+// rather than analytics data, and are left to time series sinks.
+type BigQuerySink struct {
+	insertAllURL string
+	accessToken  string
+	client       *http.Client
+	tokenMu      sync.Mutex
+	cachedToken  string
+	cachedExpiry time.Time
+}
+
+func NewBigQuerySink(endpoint string, projectID string, datasetID string, tableID string, accessToken string) *BigQuerySink {
+	if endpoint == "" {
+		endpoint = DefaultBigQueryEndpoint
+	}
+	return &BigQuerySink{
+		insertAllURL: fmt.Sprintf("%s/bigquery/v2/projects/%s/datasets/%s/tables/%s/insertAll",
+			strings.TrimSuffix(endpoint, "/"),
+			url.PathEscape(projectID),
+			url.PathEscape(datasetID),
+			url.PathEscape(tableID)),
+		accessToken: accessToken,
+		client:      &http.Client{Timeout: bigQueryRequestTimeout},
+	}
+}
+
+func (s *BigQuerySink) Name() string {
+	return "bigquery"
+}
+
+type bigQueryRow struct {
+	WindowStart         string  `json:"window_start"`
+	WindowEnd           string  `json:"window_end"`
+	Kind                string  `json:"kind"`
+	Owner               string  `json:"owner"`
+	Repo                string  `json:"repo"`
+	Status              string  `json:"status"`
+	Count               int64   `json:"count"`
+	DurationSecondsSum  float64 `json:"duration_seconds_sum"`
+	DurationSecondsMax  float64 `json:"duration_seconds_max"`
+	QueueWaitSecondsSum float64 `json:"queue_wait_seconds_sum"`
+	QueueWaitSecondsMax float64 `json:"queue_wait_seconds_max"`
+}
+
+type bigQueryInsertAllRow struct {
+	InsertID string       `json:"insertId"`
+	JSON     *bigQueryRow `json:"json"`
+}
+
+type bigQueryInsertAllRequest struct {
+	Kind string                  `json:"kind"`
+	Rows []*bigQueryInsertAllRow `json:"rows"`
+}
+
+type bigQueryInsertAllResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+// Export inserts one row per aggregate into the BigQuery table.
+func (s *BigQuerySink) Export(ctx context.Context, batches []*models.MetricsBatch) error {
+	var rows []*bigQueryInsertAllRow
+	for _, batch := range batches {
+		for _, aggregate := range batch.Aggregates {
+			row := &bigQueryRow{
+				WindowStart:         batch.WindowStart.UTC().Format(time.RFC3339),
+				WindowEnd:           batch.WindowEnd.UTC().Format(time.RFC3339),
+				Kind:                aggregate.Kind.String(),
+				Owner:               aggregate.OwnerName.String(),
+				Repo:                aggregate.RepoName.String(),
+				Status:              aggregate.Status.String(),
+				Count:               aggregate.Count,
+				DurationSecondsSum:  aggregate.DurationSecondsSum,
+				DurationSecondsMax:  aggregate.DurationSecondsMax,
+				QueueWaitSecondsSum: aggregate.QueueWaitSecondsSum,
+				QueueWaitSecondsMax: aggregate.QueueWaitSecondsMax,
+			}
+			rows = append(rows, &bigQueryInsertAllRow{InsertID: makeInsertID(row), JSON: row})
+		}
+	}
+	for len(rows) > 0 {
+		n := len(rows)
+		if n > bigQueryMaxRowsPerRequest {
+			n = bigQueryMaxRowsPerRequest
+		}
+		err := s.insertAll(ctx, rows[:n])
+		if err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// makeInsertID returns an ID that uniquely identifies the row's window and labels.
+func makeInsertID(row *bigQueryRow) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{row.WindowStart, row.Kind, row.Owner, row.Repo, row.Status}, "\x00")))
+	return hex.EncodeToString(hash[:16])
+}
+
+func (s *BigQuerySink) insertAll(ctx context.Context, rows []*bigQueryInsertAllRow) error {
+	token, err := s.getAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(&bigQueryInsertAllRequest{Kind: "bigquery#tableDataInsertAllRequest", Rows: rows})
+	if err != nil {
+		return fmt.Errorf("error marshalling insertAll request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.insertAllURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating insertAll request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending insertAll request: %w", err)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("error reading insertAll response: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("error insertAll request failed with status %d: %s", res.StatusCode, strings.TrimSpace(string(resBody)))
+	}
+	// Individual rows can fail even when the request as a whole succeeds
+	response := &bigQueryInsertAllResponse{}
+	err = json.Unmarshal(resBody, response)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling insertAll response: %w", err)
+	}
+	if len(response.InsertErrors) > 0 {
+		insertError := response.InsertErrors[0]
+		var reasons []string
+		for _, e := range insertError.Errors {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", e.Reason, e.Message))
+		}
+		return fmt.Errorf("error inserting %d row(s), first failure at index %d: %s",
+			len(response.InsertErrors), insertError.Index, strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+// getAccessToken returns the configured access token, or a token for the default service account
+// obtained from the GCE metadata server.
+func (s *BigQuerySink) getAccessToken(ctx context.Context) (string, error) {
+	if s.accessToken != "" {
+		return s.accessToken, nil
+	}
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+	if s.cachedToken != "" && time.Now().Before(s.cachedExpiry) {
+		return s.cachedToken, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gceMetadataTokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating metadata server token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting access token from metadata server: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error requesting access token from metadata server: status %d", res.StatusCode)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("error decoding access token from metadata server: %w", err)
+	}
+	s.cachedToken = token.AccessToken
+	// Refresh the token a minute before it expires
+	s.cachedExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.cachedToken, nil
+}
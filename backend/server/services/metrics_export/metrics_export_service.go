@@ -0,0 +1,459 @@
+package metrics_export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	DefaultExportInterval = 1 * time.Minute
+	exportTimeout         = 5 * time.Minute
+)
+
+type MetricsExportServiceConfig struct {
+	// Interval is the length of each export window; metrics are aggregated and exported once per interval.
+	// Defaults to 1 minute if zero.
+	Interval time.Duration
+	// PrometheusRemoteWriteURL is the URL of a Prometheus remote write endpoint to export metrics to.
+	// If empty then metrics are not exported to Prometheus.
+	PrometheusRemoteWriteURL string
+	// PrometheusRemoteWriteBearerToken is an optional bearer token to authenticate to the remote write endpoint with.
+	PrometheusRemoteWriteBearerToken string
+	// BigQueryProjectID is the ID of the Google Cloud project containing the BigQuery table to export metrics to.
+	// If empty then metrics are not exported to BigQuery.
+	BigQueryProjectID string
+	// BigQueryDatasetID is the ID of the dataset containing the BigQuery table.
+	BigQueryDatasetID string
+	// BigQueryTableID is the ID of the BigQuery table to insert metrics into.
+	BigQueryTableID string
+	// BigQueryAccessToken is an optional OAuth2 access token to authenticate to BigQuery with. If empty then
+	// a token for the default service account is obtained from the GCE metadata server.
+	BigQueryAccessToken string
+	// BigQueryEndpoint is the base URL of the BigQuery API. Defaults to DefaultBigQueryEndpoint if empty.
+	BigQueryEndpoint string
+}
+
+// MetricsExportService periodically exports aggregated build and job metrics to external time series
+// databases or data warehouses, for long-horizon engineering analytics. A sample is recorded whenever a build
+// or job finishes; samples are then aggregated into fixed, aligned windows (per kind, repo and status) and
+// sent to each configured sink. Samples are only deleted once every sink has accepted them, so a sink that
+// is temporarily unavailable will receive the missed windows on a later attempt.
+// Each server also counts the API requests throttled by its own rate limiters, and exports the counts for
+// itself in the same windows, regardless of which server is the leader.
+type MetricsExportService struct {
+	db                 *store.DB
+	metricsSampleStore store.MetricsSampleStore
+	buildStore         store.BuildStore
+	jobStore           store.JobStore
+	repoStore          store.RepoStore
+	legalEntityStore   store.LegalEntityStore
+	leaderElection     services.LeaderElectionService
+	config             MetricsExportServiceConfig
+	sinksMu            sync.RWMutex
+	sinks              []services.MetricsSink
+	instance           string
+	rateLimitMu        sync.Mutex
+	rateLimited        map[rateLimitCountKey]int64
+	startStopMutex     sync.Mutex
+	exitChan           chan bool
+	wg                 sync.WaitGroup
+	logger.Log
+}
+
+func NewMetricsExportService(
+	db *store.DB,
+	metricsSampleStore store.MetricsSampleStore,
+	buildStore store.BuildStore,
+	jobStore store.JobStore,
+	repoStore store.RepoStore,
+	legalEntityStore store.LegalEntityStore,
+	eventService services.EventService,
+	leaderElection services.LeaderElectionService,
+	config MetricsExportServiceConfig,
+	logFactory logger.LogFactory,
+) *MetricsExportService {
+	if config.Interval == 0 {
+		config.Interval = DefaultExportInterval
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	s := &MetricsExportService{
+		db:                 db,
+		metricsSampleStore: metricsSampleStore,
+		buildStore:         buildStore,
+		jobStore:           jobStore,
+		repoStore:          repoStore,
+		legalEntityStore:   legalEntityStore,
+		leaderElection:     leaderElection,
+		config:             config,
+		instance:           hostname,
+		rateLimited:        make(map[rateLimitCountKey]int64),
+		Log:                logFactory("MetricsExportService"),
+	}
+	if config.PrometheusRemoteWriteURL != "" {
+		s.RegisterSink(NewPrometheusSink(config.PrometheusRemoteWriteURL, config.PrometheusRemoteWriteBearerToken))
+	}
+	if config.BigQueryProjectID != "" {
+		s.RegisterSink(NewBigQuerySink(config.BigQueryEndpoint, config.BigQueryProjectID, config.BigQueryDatasetID, config.BigQueryTableID, config.BigQueryAccessToken))
+	}
+
+	eventService.Subscribe(models.BuildStatusChangedEvent, s.onBuildStatusChanged)
+	eventService.Subscribe(models.JobStatusChangedEvent, s.onJobStatusChanged)
+
+	return s
+}
+
+// RegisterSink adds a sink that metrics will be exported to. Samples are only recorded while at least one
+// sink is registered.
+func (s *MetricsExportService) RegisterSink(sink services.MetricsSink) {
+	s.sinksMu.Lock()
+	defer s.sinksMu.Unlock()
+	s.sinks = append(s.sinks, sink)
+	s.Infof("Exporting build and job metrics to %s every %s", sink.Name(), s.config.Interval)
+}
+
+func (s *MetricsExportService) getSinks() []services.MetricsSink {
+	s.sinksMu.RLock()
+	defer s.sinksMu.RUnlock()
+	return append([]services.MetricsSink(nil), s.sinks...)
+}
+
+// Start periodically exporting metrics in the background, whenever this server is the leader.
+func (s *MetricsExportService) Start() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan != nil {
+		return
+	}
+	s.Trace("Starting metrics export loop...")
+	s.exitChan = make(chan bool)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.exportLoop()
+	}()
+}
+
+// Stop exporting metrics, waiting for any export currently in progress to complete.
+func (s *MetricsExportService) Stop() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan == nil {
+		return
+	}
+	close(s.exitChan)
+	s.wg.Wait()
+	s.exitChan = nil
+}
+
+func (s *MetricsExportService) exportLoop() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.exitChan:
+			s.Trace("Exiting metrics export loop...")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			now := models.NewTime(time.Now())
+			err := s.ExportRateLimitsDue(ctx, now)
+			if err != nil {
+				s.Errorf("Error exporting rate limit metrics: %v", err)
+			}
+			if s.leaderElection.IsLeader() {
+				err = s.ExportDue(ctx, now)
+				if err != nil {
+					s.Errorf("Error exporting metrics: %v", err)
+				}
+			}
+			cancel()
+		}
+	}
+}
+
+// ExportDue aggregates all samples in export windows that have ended by the specified time, and sends the
+// aggregates to every registered sink. Samples are deleted once all sinks have accepted them; if any sink
+// fails then the samples are kept and the same windows will be exported again next time.
+func (s *MetricsExportService) ExportDue(ctx context.Context, now models.Time) error {
+	sinks := s.getSinks()
+	if len(sinks) == 0 {
+		return nil
+	}
+	windowEnd := models.NewTime(now.UTC().Truncate(s.config.Interval))
+	samples, err := s.listAllCreatedBefore(ctx, windowEnd)
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	batches := s.aggregate(samples)
+	for _, sink := range sinks {
+		err = sink.Export(ctx, batches)
+		if err != nil {
+			return fmt.Errorf("error exporting metrics to %s: %w", sink.Name(), err)
+		}
+	}
+	for _, sample := range samples {
+		err = s.metricsSampleStore.Delete(ctx, nil, sample.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting metrics sample: %w", err)
+		}
+	}
+	s.Infof("Exported %d metrics sample(s) in %d window(s)", len(samples), len(batches))
+	return nil
+}
+
+// rateLimitCountKey identifies a count of throttled requests, kept in memory until it is exported.
+type rateLimitCountKey struct {
+	windowStart time.Time
+	limiter     string
+	keyType     models.RateLimitKeyType
+}
+
+// RecordRateLimited counts a request throttled by the named rate limiter, by the specified type of key.
+// Requests are only counted while at least one sink is registered.
+func (s *MetricsExportService) RecordRateLimited(limiter string, keyType models.RateLimitKeyType) {
+	if len(s.getSinks()) == 0 {
+		return
+	}
+	key := rateLimitCountKey{
+		windowStart: time.Now().UTC().Truncate(s.config.Interval),
+		limiter:     limiter,
+		keyType:     keyType,
+	}
+	s.rateLimitMu.Lock()
+	defer s.rateLimitMu.Unlock()
+	s.rateLimited[key]++
+}
+
+// ExportRateLimitsDue sends the counts of requests throttled by this server's rate limiters, in export
+// windows that have ended by the specified time, to every registered sink. Counts are kept in memory and
+// are only discarded once all sinks have accepted them.
+func (s *MetricsExportService) ExportRateLimitsDue(ctx context.Context, now models.Time) error {
+	sinks := s.getSinks()
+	if len(sinks) == 0 {
+		return nil
+	}
+	windowEnd := now.UTC().Truncate(s.config.Interval)
+	counts := make(map[rateLimitCountKey]int64)
+	s.rateLimitMu.Lock()
+	for key, count := range s.rateLimited {
+		if key.windowStart.Before(windowEnd) {
+			counts[key] = count
+		}
+	}
+	s.rateLimitMu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+	batches := s.aggregateRateLimits(counts)
+	for _, sink := range sinks {
+		err := sink.Export(ctx, batches)
+		if err != nil {
+			return fmt.Errorf("error exporting rate limit metrics to %s: %w", sink.Name(), err)
+		}
+	}
+	s.rateLimitMu.Lock()
+	for key, count := range counts {
+		// Requests may have been counted against an unfinished window while exporting; those are kept
+		s.rateLimited[key] -= count
+		if s.rateLimited[key] <= 0 {
+			delete(s.rateLimited, key)
+		}
+	}
+	s.rateLimitMu.Unlock()
+	return nil
+}
+
+// aggregateRateLimits groups counts of throttled requests into export windows. Batches are returned in window
+// order, and counts within a batch are sorted so that the output is deterministic.
+func (s *MetricsExportService) aggregateRateLimits(counts map[rateLimitCountKey]int64) []*models.MetricsBatch {
+	var (
+		batches      []*models.MetricsBatch
+		batchByStart = make(map[time.Time]*models.MetricsBatch)
+	)
+	for key, count := range counts {
+		batch, ok := batchByStart[key.windowStart]
+		if !ok {
+			batch = &models.MetricsBatch{
+				WindowStart: models.NewTime(key.windowStart),
+				WindowEnd:   models.NewTime(key.windowStart.Add(s.config.Interval)),
+			}
+			batchByStart[key.windowStart] = batch
+			batches = append(batches, batch)
+		}
+		batch.RateLimits = append(batch.RateLimits, &models.RateLimitAggregate{
+			Instance:  s.instance,
+			Limiter:   key.limiter,
+			KeyType:   key.keyType,
+			Throttled: count,
+		})
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].WindowStart.Before(batches[j].WindowStart.Time) })
+	for _, batch := range batches {
+		sort.Slice(batch.RateLimits, func(i, j int) bool {
+			a, b := batch.RateLimits[i], batch.RateLimits[j]
+			if a.Limiter != b.Limiter {
+				return a.Limiter < b.Limiter
+			}
+			return a.KeyType < b.KeyType
+		})
+	}
+	return batches
+}
+
+// aggregate groups samples into export windows, and summarizes the samples in each window by kind, repo and
+// status. Batches are returned in window order, and aggregates within a batch are sorted so that the output
+// is deterministic.
+func (s *MetricsExportService) aggregate(samples []*models.MetricsSample) []*models.MetricsBatch {
+	type aggregateKey struct {
+		kind      models.MetricsSampleKind
+		ownerName models.ResourceName
+		repoName  models.ResourceName
+		status    models.WorkflowStatus
+	}
+	var (
+		batches      []*models.MetricsBatch
+		batchByStart = make(map[time.Time]*models.MetricsBatch)
+		aggregatesBy = make(map[time.Time]map[aggregateKey]*models.MetricsAggregate)
+	)
+	for _, sample := range samples {
+		windowStart := sample.CreatedAt.UTC().Truncate(s.config.Interval)
+		batch, ok := batchByStart[windowStart]
+		if !ok {
+			batch = &models.MetricsBatch{
+				WindowStart: models.NewTime(windowStart),
+				WindowEnd:   models.NewTime(windowStart.Add(s.config.Interval)),
+			}
+			batchByStart[windowStart] = batch
+			aggregatesBy[windowStart] = make(map[aggregateKey]*models.MetricsAggregate)
+			batches = append(batches, batch)
+		}
+		key := aggregateKey{kind: sample.Kind, ownerName: sample.OwnerName, repoName: sample.RepoName, status: sample.Status}
+		aggregate, ok := aggregatesBy[windowStart][key]
+		if !ok {
+			aggregate = &models.MetricsAggregate{
+				Kind:      sample.Kind,
+				OwnerName: sample.OwnerName,
+				RepoName:  sample.RepoName,
+				Status:    sample.Status,
+			}
+			aggregatesBy[windowStart][key] = aggregate
+			batch.Aggregates = append(batch.Aggregates, aggregate)
+		}
+		duration := float64(sample.DurationMillis) / 1000
+		queueWait := float64(sample.QueueWaitMillis) / 1000
+		aggregate.Count++
+		aggregate.DurationSecondsSum += duration
+		aggregate.QueueWaitSecondsSum += queueWait
+		if duration > aggregate.DurationSecondsMax {
+			aggregate.DurationSecondsMax = duration
+		}
+		if queueWait > aggregate.QueueWaitSecondsMax {
+			aggregate.QueueWaitSecondsMax = queueWait
+		}
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].WindowStart.Before(batches[j].WindowStart.Time) })
+	for _, batch := range batches {
+		sort.Slice(batch.Aggregates, func(i, j int) bool {
+			a, b := batch.Aggregates[i], batch.Aggregates[j]
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			if a.OwnerName != b.OwnerName {
+				return a.OwnerName < b.OwnerName
+			}
+			if a.RepoName != b.RepoName {
+				return a.RepoName < b.RepoName
+			}
+			return a.Status < b.Status
+		})
+	}
+	return batches
+}
+
+// listAllCreatedBefore reads every page of metrics samples created before the specified time.
+func (s *MetricsExportService) listAllCreatedBefore(ctx context.Context, createdBefore models.Time) ([]*models.MetricsSample, error) {
+	var (
+		results    []*models.MetricsSample
+		pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+	)
+	for moreResults := true; moreResults; {
+		samples, cursor, err := s.metricsSampleStore.ListCreatedBefore(ctx, nil, createdBefore, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing metrics samples: %w", err)
+		}
+		results = append(results, samples...)
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	return results, nil
+}
+
+// onBuildStatusChanged is called when a build's status changes, and records a metrics sample if the build has finished.
+func (s *MetricsExportService) onBuildStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	if !models.WorkflowStatus(event.Payload).HasFinished() || len(s.getSinks()) == 0 {
+		return nil
+	}
+	build, err := s.buildStore.Read(ctx, tx, event.BuildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	return s.recordSample(ctx, tx, models.MetricsSampleKindBuild, build.RepoID, build.ID.ResourceID, build.Status, build.Timings)
+}
+
+// onJobStatusChanged is called when a job's status changes, and records a metrics sample if the job has finished.
+func (s *MetricsExportService) onJobStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	if !models.WorkflowStatus(event.Payload).HasFinished() || len(s.getSinks()) == 0 {
+		return nil
+	}
+	job, err := s.jobStore.Read(ctx, tx, models.JobIDFromResourceID(event.ResourceID))
+	if err != nil {
+		return fmt.Errorf("error reading job: %w", err)
+	}
+	return s.recordSample(ctx, tx, models.MetricsSampleKindJob, job.RepoID, job.ID.ResourceID, job.Status, job.Timings)
+}
+
+func (s *MetricsExportService) recordSample(
+	ctx context.Context,
+	tx *store.Tx,
+	kind models.MetricsSampleKind,
+	repoID models.RepoID,
+	resourceID models.ResourceID,
+	status models.WorkflowStatus,
+	timings models.WorkflowTimings,
+) error {
+	repo, err := s.repoStore.Read(ctx, tx, repoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	owner, err := s.legalEntityStore.Read(ctx, tx, repo.LegalEntityID)
+	if err != nil {
+		return fmt.Errorf("error reading repo owner: %w", err)
+	}
+	sample := models.NewMetricsSample(models.NewTime(time.Now()), kind, repo.ID, owner.Name, repo.Name, resourceID, status, timings)
+	err = s.metricsSampleStore.Create(ctx, tx, sample)
+	if err != nil {
+		return fmt.Errorf("error creating metrics sample: %w", err)
+	}
+	s.Tracef("Recorded metrics sample for %s %q", kind, resourceID)
+	return nil
+}
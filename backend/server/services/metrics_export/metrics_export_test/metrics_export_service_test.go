@@ -0,0 +1,210 @@
+package metrics_export_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+// testSink records exported batches instead of sending them to an external system.
+type testSink struct {
+	mu      sync.Mutex
+	fail    bool
+	batches [][]*models.MetricsBatch
+}
+
+func (s *testSink) Name() string {
+	return "test"
+}
+
+func (s *testSink) Export(ctx context.Context, batches []*models.MetricsBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("test sink unavailable")
+	}
+	s.batches = append(s.batches, batches)
+	return nil
+}
+
+func (s *testSink) exports() [][]*models.MetricsBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]*models.MetricsBatch(nil), s.batches...)
+}
+
+func TestMetricsExportService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	sink := &testSink{}
+	app.MetricsExportService.RegisterSink(sink)
+
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "metrics-test-company", "", "")
+	server_test.CreateRunner(t, ctx, app, "", company.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, company.ID)
+
+	makeTimings := func(queueWait time.Duration, duration time.Duration) models.WorkflowTimings {
+		queuedAt := models.NewTime(time.Now().Add(-queueWait - duration))
+		runningAt := models.NewTime(queuedAt.Add(queueWait))
+		finishedAt := models.NewTime(runningAt.Add(duration))
+		return models.WorkflowTimings{QueuedAt: &queuedAt, RunningAt: &runningAt, FinishedAt: &finishedAt}
+	}
+	finishBuild := func(status models.WorkflowStatus, queueWait time.Duration, duration time.Duration) *models.Build {
+		graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, company.ID, "")
+		for _, job := range graph.Jobs {
+			job.Status = status
+			job.Timings = makeTimings(queueWait, duration)
+			err := app.JobService.Update(ctx, nil, job.Job)
+			require.NoError(t, err)
+			err = app.EventService.PublishEvent(ctx, nil, models.NewJobStatusChangedEventData(job.Job))
+			require.NoError(t, err)
+		}
+		build := graph.Build
+		build.Status = status
+		build.Timings = makeTimings(queueWait, duration)
+		err := app.BuildService.Update(ctx, nil, build)
+		require.NoError(t, err)
+		err = app.EventService.PublishEvent(ctx, nil, models.NewBuildStatusChangedEventData(build))
+		require.NoError(t, err)
+		return build
+	}
+
+	// Builds that haven't finished are not sampled
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, company.ID, "")
+	err = app.EventService.PublishEvent(ctx, nil, models.NewBuildStatusChangedEventData(graph.Build))
+	require.NoError(t, err)
+
+	finishBuild(models.WorkflowStatusSucceeded, 2*time.Second, 10*time.Second)
+	finishBuild(models.WorkflowStatusSucceeded, 4*time.Second, 30*time.Second)
+	finishBuild(models.WorkflowStatusFailed, 1*time.Second, 5*time.Second)
+
+	// Nothing is exported until the window the samples were recorded in has ended
+	err = app.MetricsExportService.ExportDue(ctx, models.NewTime(time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+	require.Empty(t, sink.exports())
+
+	// Samples are kept if a sink fails, and exported again next time
+	sink.mu.Lock()
+	sink.fail = true
+	sink.mu.Unlock()
+	err = app.MetricsExportService.ExportDue(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.Error(t, err)
+	require.Empty(t, sink.exports())
+	sink.mu.Lock()
+	sink.fail = false
+	sink.mu.Unlock()
+
+	err = app.MetricsExportService.ExportDue(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	exports := sink.exports()
+	require.Len(t, exports, 1)
+
+	// Aggregate across windows, as the builds may have straddled a window boundary
+	aggregates := make(map[string]*models.MetricsAggregate)
+	for _, batch := range exports[0] {
+		require.True(t, batch.WindowStart.Before(batch.WindowEnd.Time))
+		for _, aggregate := range batch.Aggregates {
+			require.Equal(t, company.Name, aggregate.OwnerName)
+			require.Equal(t, repo.Name, aggregate.RepoName)
+			key := aggregate.Kind.String() + "/" + aggregate.Status.String()
+			total, ok := aggregates[key]
+			if !ok {
+				total = &models.MetricsAggregate{Kind: aggregate.Kind, Status: aggregate.Status}
+				aggregates[key] = total
+			}
+			total.Count += aggregate.Count
+			total.DurationSecondsSum += aggregate.DurationSecondsSum
+			total.QueueWaitSecondsSum += aggregate.QueueWaitSecondsSum
+			if aggregate.DurationSecondsMax > total.DurationSecondsMax {
+				total.DurationSecondsMax = aggregate.DurationSecondsMax
+			}
+			if aggregate.QueueWaitSecondsMax > total.QueueWaitSecondsMax {
+				total.QueueWaitSecondsMax = aggregate.QueueWaitSecondsMax
+			}
+		}
+	}
+	succeeded := aggregates["build/succeeded"]
+	require.NotNil(t, succeeded)
+	require.Equal(t, int64(2), succeeded.Count)
+	require.InDelta(t, 40, succeeded.DurationSecondsSum, 0.01)
+	require.InDelta(t, 30, succeeded.DurationSecondsMax, 0.01)
+	require.InDelta(t, 6, succeeded.QueueWaitSecondsSum, 0.01)
+	require.InDelta(t, 4, succeeded.QueueWaitSecondsMax, 0.01)
+	failed := aggregates["build/failed"]
+	require.NotNil(t, failed)
+	require.Equal(t, int64(1), failed.Count)
+	require.InDelta(t, 5, failed.DurationSecondsSum, 0.01)
+	require.NotNil(t, aggregates["job/succeeded"])
+	require.NotNil(t, aggregates["job/failed"])
+	require.Equal(t, int64(len(graph.Jobs)), aggregates["job/failed"].Count)
+
+	// Exported samples are deleted
+	err = app.MetricsExportService.ExportDue(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	require.Len(t, sink.exports(), 1)
+}
+
+func TestMetricsExportServiceRateLimits(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	// Requests are only counted while a sink is registered
+	app.MetricsExportService.RecordRateLimited("dequeue", models.RateLimitKeyTypeIP)
+
+	sink := &testSink{}
+	app.MetricsExportService.RegisterSink(sink)
+	app.MetricsExportService.RecordRateLimited("dequeue", models.RateLimitKeyTypeIdentity)
+	app.MetricsExportService.RecordRateLimited("dequeue", models.RateLimitKeyTypeIdentity)
+	app.MetricsExportService.RecordRateLimited("dequeue", models.RateLimitKeyTypeIP)
+	app.MetricsExportService.RecordRateLimited("log_write", models.RateLimitKeyTypeIdentity)
+
+	// Nothing is exported until the window the requests were counted in has ended
+	err = app.MetricsExportService.ExportRateLimitsDue(ctx, models.NewTime(time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+	require.Empty(t, sink.exports())
+
+	// Counts are kept if a sink fails, and exported again next time
+	sink.mu.Lock()
+	sink.fail = true
+	sink.mu.Unlock()
+	err = app.MetricsExportService.ExportRateLimitsDue(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.Error(t, err)
+	sink.mu.Lock()
+	sink.fail = false
+	sink.mu.Unlock()
+
+	err = app.MetricsExportService.ExportRateLimitsDue(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	exports := sink.exports()
+	require.Len(t, exports, 1)
+
+	// Sum across windows, as the requests may have straddled a window boundary
+	throttled := make(map[string]int64)
+	for _, batch := range exports[0] {
+		require.Empty(t, batch.Aggregates)
+		for _, rateLimit := range batch.RateLimits {
+			require.NotEmpty(t, rateLimit.Instance)
+			throttled[rateLimit.Limiter+"/"+rateLimit.KeyType.String()] += rateLimit.Throttled
+		}
+	}
+	require.Equal(t, map[string]int64{"dequeue/identity": 2, "dequeue/ip": 1, "log_write/identity": 1}, throttled)
+
+	// Exported counts are discarded
+	err = app.MetricsExportService.ExportRateLimitsDue(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	require.Len(t, sink.exports(), 1)
+}
@@ -0,0 +1,177 @@
+package metrics_export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+const prometheusRemoteWriteTimeout = 30 * time.Second
+
+// PrometheusSink sends aggregated metrics to a Prometheus-compatible time series database using the
+// Prometheus remote write protocol (v1). One sample is written per metric per export window, timestamped
+// at the end of the window, so re-sending a window after a failure overwrites rather than duplicates data.
+//
+// For each kind of sample ("build" or "job") the following metrics are written, labelled by owner, repo and status:
+//
+//	buildbeaver_<kind>_finished                  the number of builds/jobs that finished in the window
+//	buildbeaver_<kind>_duration_seconds_sum      the total time spent running
+//	buildbeaver_<kind>_duration_seconds_max      the longest time any single build/job spent running
+//	buildbeaver_<kind>_queue_wait_seconds_sum    the total time spent waiting to start running
+//	buildbeaver_<kind>_queue_wait_seconds_max    the longest time any single build/job spent waiting to start running
+//
+// Requests throttled by each server's rate limiters are written as the following metric, labelled by instance
+// (the server's hostname), limiter (e.g. "dequeue") and key_type ("identity" or "ip"):
+//
+//	buildbeaver_rate_limit_throttled             the number of requests rejected in the window
+type PrometheusSink struct {
+	url         string
+	bearerToken string
+	client      *http.Client
+}
+
+func NewPrometheusSink(url string, bearerToken string) *PrometheusSink {
+	return &PrometheusSink{
+		url:         url,
+		bearerToken: bearerToken,
+		client:      &http.Client{Timeout: prometheusRemoteWriteTimeout},
+	}
+}
+
+func (s *PrometheusSink) Name() string {
+	return "prometheus"
+}
+
+// Export sends the batches to the remote write endpoint in a single request.
+func (s *PrometheusSink) Export(ctx context.Context, batches []*models.MetricsBatch) error {
+	body := snappyEncode(encodeWriteRequest(makeTimeSeries(batches)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating remote write request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending remote write request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("error remote write request failed with status %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+type promLabel struct {
+	name  string
+	value string
+}
+
+type promSample struct {
+	value       float64
+	timestampMS int64
+}
+
+type promTimeSeries struct {
+	labels  []promLabel
+	samples []promSample
+}
+
+// makeTimeSeries converts batches of aggregates into time series, with one series per metric and set of
+// labels. Batches must be in window order so that the samples in each series are in timestamp order.
+func makeTimeSeries(batches []*models.MetricsBatch) []*promTimeSeries {
+	var (
+		series   []*promTimeSeries
+		seriesBy = make(map[string]*promTimeSeries)
+	)
+	add := func(labels []promLabel, timestampMS int64, value float64) {
+		sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+		var key strings.Builder
+		for _, label := range labels {
+			key.WriteString(label.name + "\x00" + label.value + "\x00")
+		}
+		ts, ok := seriesBy[key.String()]
+		if !ok {
+			ts = &promTimeSeries{labels: labels}
+			seriesBy[key.String()] = ts
+			series = append(series, ts)
+		}
+		ts.samples = append(ts.samples, promSample{value: value, timestampMS: timestampMS})
+	}
+	for _, batch := range batches {
+		timestampMS := batch.WindowEnd.UnixMilli()
+		for _, aggregate := range batch.Aggregates {
+			labels := func(name string) []promLabel {
+				return []promLabel{
+					{name: "__name__", value: fmt.Sprintf("buildbeaver_%s_%s", aggregate.Kind, name)},
+					{name: "owner", value: aggregate.OwnerName.String()},
+					{name: "repo", value: aggregate.RepoName.String()},
+					{name: "status", value: aggregate.Status.String()},
+				}
+			}
+			add(labels("finished"), timestampMS, float64(aggregate.Count))
+			add(labels("duration_seconds_sum"), timestampMS, aggregate.DurationSecondsSum)
+			add(labels("duration_seconds_max"), timestampMS, aggregate.DurationSecondsMax)
+			add(labels("queue_wait_seconds_sum"), timestampMS, aggregate.QueueWaitSecondsSum)
+			add(labels("queue_wait_seconds_max"), timestampMS, aggregate.QueueWaitSecondsMax)
+		}
+		for _, rateLimit := range batch.RateLimits {
+			add([]promLabel{
+				{name: "__name__", value: "buildbeaver_rate_limit_throttled"},
+				{name: "instance", value: rateLimit.Instance},
+				{name: "limiter", value: rateLimit.Limiter},
+				{name: "key_type", value: rateLimit.KeyType.String()},
+			}, timestampMS, float64(rateLimit.Throttled))
+		}
+	}
+	return series
+}
+
+// encodeWriteRequest encodes time series as a Prometheus remote write WriteRequest protobuf message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []*promTimeSeries) []byte {
+	var out []byte
+	for _, ts := range series {
+		var tsBytes []byte
+		for _, label := range ts.labels {
+			var labelBytes []byte
+			labelBytes = protowire.AppendTag(labelBytes, 1, protowire.BytesType)
+			labelBytes = protowire.AppendString(labelBytes, label.name)
+			labelBytes = protowire.AppendTag(labelBytes, 2, protowire.BytesType)
+			labelBytes = protowire.AppendString(labelBytes, label.value)
+			tsBytes = protowire.AppendTag(tsBytes, 1, protowire.BytesType)
+			tsBytes = protowire.AppendBytes(tsBytes, labelBytes)
+		}
+		for _, sample := range ts.samples {
+			var sampleBytes []byte
+			sampleBytes = protowire.AppendTag(sampleBytes, 1, protowire.Fixed64Type)
+			sampleBytes = protowire.AppendFixed64(sampleBytes, math.Float64bits(sample.value))
+			sampleBytes = protowire.AppendTag(sampleBytes, 2, protowire.VarintType)
+			sampleBytes = protowire.AppendVarint(sampleBytes, uint64(sample.timestampMS))
+			tsBytes = protowire.AppendTag(tsBytes, 2, protowire.BytesType)
+			tsBytes = protowire.AppendBytes(tsBytes, sampleBytes)
+		}
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, tsBytes)
+	}
+	return out
+}
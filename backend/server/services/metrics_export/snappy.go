@@ -0,0 +1,36 @@
+package metrics_export
+
+import "encoding/binary"
+
+// maxSnappyLiteralLen is the longest literal element we emit; longer data is split across several literals.
+const maxSnappyLiteralLen = 1 << 16
+
+// snappyEncode encodes data using the Snappy block format, as required by the Prometheus remote write protocol.
+// The data is stored as a sequence of literals without compression, which is valid Snappy that any decoder
+// will accept. Remote write requests for aggregated metrics are small so compression isn't worth a dependency.
+func snappyEncode(data []byte) []byte {
+	out := make([]byte, 0, len(data)+binary.MaxVarintLen64+5*(len(data)/maxSnappyLiteralLen+1))
+	out = binary.AppendUvarint(out, uint64(len(data)))
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxSnappyLiteralLen {
+			n = maxSnappyLiteralLen
+		}
+		out = appendSnappyLiteral(out, data[:n])
+		data = data[n:]
+	}
+	return out
+}
+
+func appendSnappyLiteral(out []byte, literal []byte) []byte {
+	n := len(literal) - 1
+	switch {
+	case n < 60:
+		out = append(out, byte(n)<<2)
+	case n < 1<<8:
+		out = append(out, 60<<2, byte(n))
+	default:
+		out = append(out, 61<<2, byte(n), byte(n>>8))
+	}
+	return append(out, literal...)
+}
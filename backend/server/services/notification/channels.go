@@ -0,0 +1,91 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/util"
+)
+
+const (
+	webhookRequestTimeout = 10 * time.Second
+	// maxErrorBodyBytes is the maximum amount of a webhook's error response to include in an error message.
+	maxErrorBodyBytes = 512
+	// maxDiscordMessageChars is the maximum length of a message accepted by Discord.
+	maxDiscordMessageChars = 2000
+)
+
+// SlackChannel sends notifications to a Slack incoming webhook.
+type SlackChannel struct {
+	client *http.Client
+}
+
+func NewSlackChannel() *SlackChannel {
+	return &SlackChannel{client: &http.Client{Timeout: webhookRequestTimeout}}
+}
+
+// Type returns the type of channel, matching the Channel field of the notification settings it serves.
+func (c *SlackChannel) Type() models.NotificationChannelType {
+	return models.NotificationChannelTypeSlack
+}
+
+// Send a message to the channel via the specified webhook URL.
+func (c *SlackChannel) Send(ctx context.Context, webhookURL string, message string) error {
+	return postWebhookJSON(ctx, c.client, webhookURL, map[string]string{"text": message})
+}
+
+// DiscordChannel sends notifications to a Discord webhook.
+type DiscordChannel struct {
+	client *http.Client
+}
+
+func NewDiscordChannel() *DiscordChannel {
+	return &DiscordChannel{client: &http.Client{Timeout: webhookRequestTimeout}}
+}
+
+// Type returns the type of channel, matching the Channel field of the notification settings it serves.
+func (c *DiscordChannel) Type() models.NotificationChannelType {
+	return models.NotificationChannelTypeDiscord
+}
+
+// Send a message to the channel via the specified webhook URL.
+func (c *DiscordChannel) Send(ctx context.Context, webhookURL string, message string) error {
+	message = util.TruncateStringToMaxLength(message, maxDiscordMessageChars)
+	return postWebhookJSON(ctx, c.client, webhookURL, map[string]string{"content": message})
+}
+
+// postWebhookJSON posts body to the specified URL as JSON, returning an error if the response
+// does not have a 2xx status code.
+func postWebhookJSON(ctx context.Context, client *http.Client, webhookURL string, body interface{}) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshalling webhook body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		// Don't include the full URL in the error since it contains a credential
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("error sending webhook request to %s: %w", req.URL.Host, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		errBody, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBodyBytes))
+		return fmt.Errorf("error webhook request to %s failed with status %d: %s", req.URL.Host, res.StatusCode, errBody)
+	}
+	return nil
+}
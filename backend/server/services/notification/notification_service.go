@@ -0,0 +1,434 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	notificationSendTimeout = 30 * time.Second
+	// previousBuildSearchLimit is the number of finished builds to look back through when determining
+	// whether a successful build is a recovery.
+	previousBuildSearchLimit = 10
+)
+
+// defaultMessageTemplate is used to format messages for notification settings without a custom template.
+const defaultMessageTemplate = `{{- if eq .Event "failure" -}}
+Build {{ .RepoName }} #{{ .BuildName }} ({{ .Ref }}) failed{{ if .Error }}: {{ .Error }}{{ end }}
+{{- else if eq .Event "recovery" -}}
+Build {{ .RepoName }} #{{ .BuildName }} ({{ .Ref }}) recovered
+{{- else -}}
+Build {{ .RepoName }} #{{ .BuildName }} ({{ .Ref }}) succeeded
+{{- end }}{{ if .BuildURL }} {{ .BuildURL }}{{ end }}`
+
+type NotificationServiceConfig struct {
+	// WebUIBaseURL is the base URL of the web UI, used to include links to builds in notifications.
+	// If empty then no links will be included.
+	WebUIBaseURL string
+}
+
+type NotificationService struct {
+	db                       *store.DB
+	notificationSettingStore store.NotificationSettingStore
+	ownershipStore           store.OwnershipStore
+	repoStore                store.RepoStore
+	buildStore               store.BuildStore
+	legalEntityStore         store.LegalEntityStore
+	workQueueService         services.WorkQueueService
+	encryptionService        services.EncryptionService
+	config                   NotificationServiceConfig
+	channelsMu               sync.RWMutex
+	channels                 map[models.NotificationChannelType]services.NotificationChannel
+	logger.Log
+}
+
+func NewNotificationService(
+	db *store.DB,
+	notificationSettingStore store.NotificationSettingStore,
+	ownershipStore store.OwnershipStore,
+	repoStore store.RepoStore,
+	buildStore store.BuildStore,
+	legalEntityStore store.LegalEntityStore,
+	eventService services.EventService,
+	workQueueService services.WorkQueueService,
+	encryptionService services.EncryptionService,
+	config NotificationServiceConfig,
+	logFactory logger.LogFactory,
+) *NotificationService {
+	s := &NotificationService{
+		db:                       db,
+		notificationSettingStore: notificationSettingStore,
+		ownershipStore:           ownershipStore,
+		repoStore:                repoStore,
+		buildStore:               buildStore,
+		legalEntityStore:         legalEntityStore,
+		workQueueService:         workQueueService,
+		encryptionService:        encryptionService,
+		config:                   config,
+		channels:                 make(map[models.NotificationChannelType]services.NotificationChannel),
+		Log:                      logFactory("NotificationService"),
+	}
+	s.RegisterChannel(NewSlackChannel())
+	s.RegisterChannel(NewDiscordChannel())
+
+	// Register the code to process work items for sending notifications
+	err := s.workQueueService.RegisterHandler(
+		NotificationWorkItem,
+		s.ProcessNotificationWorkItem,
+		notificationSendTimeout,
+		work_queue.ExponentialBackoff(10, 5*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	eventService.Subscribe(models.BuildStatusChangedEvent, s.onBuildStatusChanged)
+
+	return s
+}
+
+// RegisterChannel registers a channel that notifications can be sent to, replacing any existing channel
+// of the same type.
+func (s *NotificationService) RegisterChannel(channel services.NotificationChannel) {
+	s.channelsMu.Lock()
+	defer s.channelsMu.Unlock()
+	s.channels[channel.Type()] = channel
+}
+
+// Create a new notification setting for a repo.
+func (s *NotificationService) Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateNotificationSetting) (*models.NotificationSetting, error) {
+	err := validateWebhookURL(create.WebhookURLPlaintext)
+	if err != nil {
+		return nil, err
+	}
+	webhookURLEncrypted, dataKeyEncrypted, err := s.encryptionService.Encrypt(ctx, []byte(create.WebhookURLPlaintext))
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting webhook url: %w", err)
+	}
+	now := models.NewTime(time.Now())
+	setting := models.NewNotificationSetting(
+		now,
+		create.RepoID,
+		create.Channel,
+		webhookURLEncrypted,
+		dataKeyEncrypted,
+		create.OnSuccess,
+		create.OnFailure,
+		create.OnRecovery,
+		create.MessageTemplate)
+	err = setting.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.notificationSettingStore.Create(ctx, tx, setting)
+		if err != nil {
+			return fmt.Errorf("error creating notification setting: %w", err)
+		}
+		ownership := models.NewOwnership(now, create.RepoID.ResourceID, setting.GetID())
+		err = s.ownershipStore.Create(ctx, tx, ownership)
+		if err != nil {
+			return fmt.Errorf("error creating ownership: %w", err)
+		}
+		s.Infof("Created notification setting %q for repo %q", setting.ID, setting.RepoID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// Read an existing notification setting, looking it up by ID.
+// Returns models.ErrNotFound if the notification setting does not exist.
+func (s *NotificationService) Read(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID) (*models.NotificationSetting, error) {
+	return s.notificationSettingStore.Read(ctx, txOrNil, id)
+}
+
+// Update an existing notification setting with optimistic locking, changing only the fields that are set in update.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (s *NotificationService) Update(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID, update dto.UpdateNotificationSetting) (*models.NotificationSetting, error) {
+	setting, err := s.notificationSettingStore.Read(ctx, txOrNil, id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading notification setting: %w", err)
+	}
+	if update.Channel != nil {
+		setting.Channel = *update.Channel
+	}
+	if update.WebhookURLPlaintext != nil {
+		err := validateWebhookURL(*update.WebhookURLPlaintext)
+		if err != nil {
+			return nil, err
+		}
+		setting.WebhookURLEncrypted, setting.DataKeyEncrypted, err = s.encryptionService.Encrypt(ctx, []byte(*update.WebhookURLPlaintext))
+		if err != nil {
+			return nil, fmt.Errorf("error encrypting webhook url: %w", err)
+		}
+	}
+	if update.OnSuccess != nil {
+		setting.OnSuccess = *update.OnSuccess
+	}
+	if update.OnFailure != nil {
+		setting.OnFailure = *update.OnFailure
+	}
+	if update.OnRecovery != nil {
+		setting.OnRecovery = *update.OnRecovery
+	}
+	if update.MessageTemplate != nil {
+		setting.MessageTemplate = *update.MessageTemplate
+	}
+	setting.UpdatedAt = models.NewTime(time.Now())
+	setting.ETag = models.GetETag(setting, update.ETag)
+	err = setting.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	err = s.notificationSettingStore.Update(ctx, txOrNil, setting)
+	if err != nil {
+		return nil, fmt.Errorf("error updating notification setting: %w", err)
+	}
+	return setting, nil
+}
+
+// Delete permanently and idempotently deletes a notification setting, identifying it by ID.
+func (s *NotificationService) Delete(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.notificationSettingStore.Delete(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error deleting notification setting: %w", err)
+		}
+		err = s.ownershipStore.Delete(ctx, tx, id.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListByRepoID lists all notification settings for a repo. Use cursor to page through results, if any.
+func (s *NotificationService) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.NotificationSetting, *models.Cursor, error) {
+	return s.notificationSettingStore.ListByRepoID(ctx, txOrNil, repoID, pagination)
+}
+
+// onBuildStatusChanged is called when a build's status changes, and queues notifications for any of the
+// build's repo's notification settings that are interested in the build's outcome.
+func (s *NotificationService) onBuildStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	status := models.WorkflowStatus(event.Payload)
+	if status != models.WorkflowStatusSucceeded && status != models.WorkflowStatusFailed {
+		return nil
+	}
+	build, err := s.buildStore.Read(ctx, tx, event.BuildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	settings, err := s.listAllByRepoID(ctx, tx, build.RepoID)
+	if err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	notificationEvent, err := s.getNotificationEvent(ctx, tx, build)
+	if err != nil {
+		return err
+	}
+	var data *models.NotificationMessageData
+	for _, setting := range settings {
+		if !setting.ShouldNotify(notificationEvent) {
+			continue
+		}
+		if data == nil {
+			data, err = s.makeMessageData(ctx, tx, build, notificationEvent)
+			if err != nil {
+				return err
+			}
+		}
+		message, err := renderMessage(setting, data)
+		if err != nil {
+			// A broken template shouldn't stop the build from finishing or other notifications being sent
+			s.Warnf("Ignoring notification setting %q: %v", setting.ID, err)
+			continue
+		}
+		err = s.workQueueService.AddWorkItem(ctx, tx, NewNotificationWorkItem(setting.ID, message))
+		if err != nil {
+			return fmt.Errorf("error queueing notification work item: %w", err)
+		}
+		s.Tracef("Queued %s notification for build %q to %s channel %q", notificationEvent, build.ID, setting.Channel, setting.ID)
+	}
+	return nil
+}
+
+// listAllByRepoID reads every page of notification settings for a repo.
+func (s *NotificationService) listAllByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) ([]*models.NotificationSetting, error) {
+	var (
+		results    []*models.NotificationSetting
+		pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+	)
+	for moreResults := true; moreResults; {
+		settings, cursor, err := s.notificationSettingStore.ListByRepoID(ctx, txOrNil, repoID, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing notification settings: %w", err)
+		}
+		results = append(results, settings...)
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	return results, nil
+}
+
+// getNotificationEvent determines which notification event a finished build corresponds to. A successful
+// build is a recovery if the previous finished build for the same repo and ref failed.
+func (s *NotificationService) getNotificationEvent(ctx context.Context, txOrNil *store.Tx, build *models.Build) (models.NotificationEvent, error) {
+	if build.Status == models.WorkflowStatusFailed {
+		return models.NotificationEventFailure, nil
+	}
+	search := models.NewBuildSearch()
+	search.RepoID = &build.RepoID
+	search.Ref = build.Ref
+	search.IncludeStatuses = []models.WorkflowStatus{models.WorkflowStatusSucceeded, models.WorkflowStatusFailed}
+	search.Limit = previousBuildSearchLimit
+	results, _, err := s.buildStore.Search(ctx, txOrNil, models.NoIdentity, search)
+	if err != nil {
+		return "", fmt.Errorf("error searching for previous builds: %w", err)
+	}
+	for _, result := range results {
+		// Ignore this build and any newer builds that may have finished before it
+		if result.ID == build.ID || !result.CreatedAt.Before(build.CreatedAt.Time) {
+			continue
+		}
+		if result.Status == models.WorkflowStatusFailed {
+			return models.NotificationEventRecovery, nil
+		}
+		break
+	}
+	return models.NotificationEventSuccess, nil
+}
+
+// makeMessageData makes the data available to message templates for a finished build.
+func (s *NotificationService) makeMessageData(ctx context.Context, txOrNil *store.Tx, build *models.Build, event models.NotificationEvent) (*models.NotificationMessageData, error) {
+	repo, err := s.repoStore.Read(ctx, txOrNil, build.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	data := &models.NotificationMessageData{
+		Event:     event,
+		RepoName:  repo.Name,
+		BuildName: build.Name,
+		Ref:       build.Ref,
+		Status:    build.Status,
+	}
+	if build.Error != nil {
+		data.Error = build.Error.Error()
+	}
+	if s.config.WebUIBaseURL != "" {
+		repoOwner, err := s.legalEntityStore.Read(ctx, txOrNil, repo.LegalEntityID)
+		if err != nil {
+			return nil, fmt.Errorf("error reading repo owner: %w", err)
+		}
+		data.BuildURL, err = s.makeWebUIBuildURL(repoOwner, repo, build)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (s *NotificationService) makeWebUIBuildURL(repoOwner *models.LegalEntity, repo *models.Repo, build *models.Build) (string, error) {
+	var orgsOrUsers string
+	switch repoOwner.Type {
+	case models.LegalEntityTypeCompany:
+		orgsOrUsers = "orgs"
+	case models.LegalEntityTypePerson:
+		orgsOrUsers = "users"
+	default:
+		return "", fmt.Errorf("error unknown type of Legal Entity '%s' for repo owner, name '%s", repoOwner.Type, repoOwner.Name)
+	}
+	baseURL := strings.TrimSuffix(s.config.WebUIBaseURL, "/")
+	return fmt.Sprintf("%s/%s/%s/repos/%s/builds/%s",
+		baseURL,
+		orgsOrUsers,
+		url.QueryEscape(repoOwner.GetName().String()),
+		url.QueryEscape(repo.GetName().String()),
+		url.QueryEscape(build.GetName().String()),
+	), nil
+}
+
+// ProcessNotificationWorkItem is a work item handler that sends a notification message to a channel.
+func (s *NotificationService) ProcessNotificationWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &NotificationWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling notification work item data: %w", err)
+	}
+	setting, err := s.notificationSettingStore.Read(ctx, nil, workItemData.NotificationSettingID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping notification for deleted notification setting %q", workItemData.NotificationSettingID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading notification setting: %w", err)
+	}
+	s.channelsMu.RLock()
+	channel, ok := s.channels[setting.Channel]
+	s.channelsMu.RUnlock()
+	if !ok {
+		return false, fmt.Errorf("error no channel registered for type %q", setting.Channel)
+	}
+	webhookURL, err := s.encryptionService.Decrypt(ctx, setting.WebhookURLEncrypted, setting.DataKeyEncrypted)
+	if err != nil {
+		return false, fmt.Errorf("error decrypting webhook url: %w", err)
+	}
+	err = channel.Send(ctx, string(webhookURL), workItemData.Message)
+	if err != nil {
+		return true, fmt.Errorf("error sending notification to %s channel %q: %w", setting.Channel, setting.ID, err)
+	}
+	s.Tracef("Notification sent successfully to %s channel %q", setting.Channel, setting.ID)
+	return false, nil
+}
+
+// renderMessage formats the message to send for a notification setting.
+func renderMessage(setting *models.NotificationSetting, data *models.NotificationMessageData) (string, error) {
+	text := setting.MessageTemplate
+	if text == "" {
+		text = defaultMessageTemplate
+	}
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing message template: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return "", fmt.Errorf("error executing message template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// validateWebhookURL returns a validation error if the specified webhook URL can't be used to send notifications.
+func validateWebhookURL(webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return gerror.NewErrValidationFailed("Webhook URL must be a valid http or https URL")
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package notification_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testMessageTimeout = 30 * time.Second
+
+type sentMessage struct {
+	webhookURL string
+	message    string
+}
+
+// testChannel records messages instead of sending them to an external system.
+type testChannel struct {
+	mu       sync.Mutex
+	messages []sentMessage
+}
+
+func (c *testChannel) Type() models.NotificationChannelType {
+	return models.NotificationChannelTypeSlack
+}
+
+func (c *testChannel) Send(ctx context.Context, webhookURL string, message string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, sentMessage{webhookURL: webhookURL, message: message})
+	return nil
+}
+
+// waitForMessages waits until at least n messages have been sent, and returns all messages sent so far.
+func (c *testChannel) waitForMessages(t *testing.T, n int) []sentMessage {
+	deadline := time.Now().Add(testMessageTimeout)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		if len(c.messages) >= n {
+			messages := append([]sentMessage(nil), c.messages...)
+			c.mu.Unlock()
+			return messages
+		}
+		c.mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d notification messages", n)
+	return nil
+}
+
+func TestNotificationService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	channel := &testChannel{}
+	app.NotificationService.RegisterChannel(channel)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	// Webhook URLs must be http(s)
+	_, err = app.NotificationService.Create(ctx, nil, dto.CreateNotificationSetting{
+		RepoID:              repo.ID,
+		Channel:             models.NotificationChannelTypeSlack,
+		WebhookURLPlaintext: "not a url",
+		OnFailure:           true,
+	})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	failuresURL := "https://hooks.example.com/failures"
+	failures, err := app.NotificationService.Create(ctx, nil, dto.CreateNotificationSetting{
+		RepoID:              repo.ID,
+		Channel:             models.NotificationChannelTypeSlack,
+		WebhookURLPlaintext: failuresURL,
+		OnFailure:           true,
+		OnRecovery:          true,
+		MessageTemplate:     "{{ .Event }} {{ .BuildName }}",
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, failuresURL, string(failures.WebhookURLEncrypted))
+
+	successesURL := "https://hooks.example.com/successes"
+	_, err = app.NotificationService.Create(ctx, nil, dto.CreateNotificationSetting{
+		RepoID:              repo.ID,
+		Channel:             models.NotificationChannelTypeSlack,
+		WebhookURLPlaintext: successesURL,
+		OnSuccess:           true,
+	})
+	require.NoError(t, err)
+
+	settings, _, err := app.NotificationService.ListByRepoID(ctx, nil, repo.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, settings, 2)
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	finishBuild := func(status models.WorkflowStatus) *models.Build {
+		graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+		build := graph.Build
+		build.Status = status
+		if status == models.WorkflowStatusFailed {
+			build.Error = models.NewError(gerror.NewErrValidationFailed("test failure"))
+		}
+		err := app.BuildService.Update(ctx, nil, build)
+		require.NoError(t, err)
+		err = app.EventService.PublishEvent(ctx, nil, models.NewBuildStatusChangedEventData(build))
+		require.NoError(t, err)
+		return build
+	}
+
+	// A failed build is only sent to the setting interested in failures
+	build1 := finishBuild(models.WorkflowStatusFailed)
+	messages := channel.waitForMessages(t, 1)
+	require.Equal(t, sentMessage{webhookURL: failuresURL, message: "failure " + build1.Name.String()}, messages[0])
+
+	// The next successful build is a recovery, which is also a success
+	build2 := finishBuild(models.WorkflowStatusSucceeded)
+	messages = channel.waitForMessages(t, 3)
+	require.ElementsMatch(t, []sentMessage{
+		{webhookURL: failuresURL, message: "recovery " + build2.Name.String()},
+		{webhookURL: successesURL, message: "Build " + repo.Name.String() + " #" + build2.Name.String() + " (" + build2.Ref + ") recovered"},
+	}, messages[1:])
+
+	// Subsequent successful builds are not recoveries
+	build3 := finishBuild(models.WorkflowStatusSucceeded)
+	messages = channel.waitForMessages(t, 4)
+	require.Equal(t, sentMessage{webhookURL: successesURL, message: "Build " + repo.Name.String() + " #" + build3.Name.String() + " (" + build3.Ref + ") succeeded"}, messages[3])
+
+	// Deleted settings no longer receive notifications
+	err = app.NotificationService.Delete(ctx, nil, failures.ID)
+	require.NoError(t, err)
+	_, err = app.NotificationService.Read(ctx, nil, failures.ID)
+	require.True(t, gerror.IsNotFound(err))
+}
@@ -0,0 +1,37 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// NotificationWorkItem is a work item that will send a message to a notification channel.
+const NotificationWorkItem models.WorkItemType = "Notification"
+
+// NotificationWorkItemData is serialized to JSON and stored in the Data field of a NotificationWorkItem.
+// The webhook URL is deliberately not included since it is a secret; it is read from the notification
+// setting when the work item is processed.
+type NotificationWorkItemData struct {
+	NotificationSettingID models.NotificationSettingID
+	Message               string
+}
+
+func NewNotificationWorkItem(settingID models.NotificationSettingID, message string) *models.WorkItem {
+	data := &NotificationWorkItemData{
+		NotificationSettingID: settingID,
+		Message:               message,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in NotificationWorkItemData definition
+		panic("Unable to marshal NotificationWorkItemData object to JSON")
+	}
+
+	// Concurrency key is per notification setting, so messages are sent to each channel in order
+	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("notification/%s", settingID))
+
+	return models.NewWorkItem(NotificationWorkItem, string(dataJson), concurrencyKey, models.NewTime(time.Now()))
+}
@@ -0,0 +1,245 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/credential"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	DefaultTokenExpiry = 10 * time.Minute
+	MaxTokenExpiry     = 1 * time.Hour
+	// JWKSPath is the path of the JSON Web Key Set, relative to the issuer URL.
+	JWKSPath = "/jwks"
+	// DiscoveryPath is the path of the OpenID Connect discovery document, relative to the issuer URL.
+	DiscoveryPath = "/.well-known/openid-configuration"
+)
+
+type OIDCConfig struct {
+	// IssuerURL is the externally reachable URL that identifies the server as an OpenID Connect issuer. Verifiers
+	// fetch the discovery document and signing keys from this URL, so it must be the public URL of the
+	// API server's OIDC routes (e.g. "https://buildbeaver.example.com/api/v1/oidc").
+	IssuerURL       string
+	CertificateFile certificates.CertificateFile
+	PrivateKeyFile  certificates.PrivateKeyFile
+	// AutoCreateKeyPair will create a new key pair and certificate if no files exist at the configured locations.
+	AutoCreateKeyPair bool
+	// TokenExpiry is how long tokens issued to jobs are valid for.
+	TokenExpiry time.Duration
+}
+
+// Enabled returns true if OIDC tokens can be issued to jobs.
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
+}
+
+// OIDCService issues short-lived OpenID Connect ID tokens to running jobs, so that jobs can authenticate to
+// cloud providers and other services that trust the server as an identity provider, without storing long-lived
+// credentials as secrets. Tokens are signed with an ecdsa key using ES256, and the public key is published as
+// a JSON Web Key Set alongside an OIDC discovery document at the issuer URL.
+type OIDCService struct {
+	config           OIDCConfig
+	privateKey       *ecdsa.PrivateKey
+	keyID            string
+	jobStore         store.JobStore
+	buildStore       store.BuildStore
+	repoStore        store.RepoStore
+	commitStore      store.CommitStore
+	legalEntityStore store.LegalEntityStore
+	logger.Log
+}
+
+func NewOIDCService(
+	config OIDCConfig,
+	jobStore store.JobStore,
+	buildStore store.BuildStore,
+	repoStore store.RepoStore,
+	commitStore store.CommitStore,
+	legalEntityStore store.LegalEntityStore,
+	logFactory logger.LogFactory,
+) (*OIDCService, error) {
+	s := &OIDCService{
+		config:           config,
+		jobStore:         jobStore,
+		buildStore:       buildStore,
+		repoStore:        repoStore,
+		commitStore:      commitStore,
+		legalEntityStore: legalEntityStore,
+		Log:              logFactory("OIDCService"),
+	}
+	if !config.Enabled() {
+		return s, nil
+	}
+	if s.config.TokenExpiry <= 0 {
+		s.config.TokenExpiry = DefaultTokenExpiry
+	}
+	if s.config.TokenExpiry > MaxTokenExpiry {
+		return nil, fmt.Errorf("error OIDC token expiry must not be longer than %s", MaxTokenExpiry)
+	}
+	s.config.IssuerURL = strings.TrimSuffix(config.IssuerURL, "/")
+
+	if config.AutoCreateKeyPair {
+		created, err := certificates.GenerateECDSASigningKeyAndCertificate(
+			config.CertificateFile,
+			config.PrivateKeyFile,
+			"BuildBeaver Limited",
+		)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			s.Infof("Created private/public key pair for OIDC token signing and verification")
+		}
+	}
+	privateKeyPEMBlock, err := os.ReadFile(config.PrivateKeyFile.String())
+	if err != nil {
+		return nil, fmt.Errorf("error loading OIDC token signing private key: %w", err)
+	}
+	privateKey, err := certificates.GetECDSAPrivateKeyFromPEM(string(privateKeyPEMBlock))
+	if err != nil {
+		return nil, fmt.Errorf("error reading OIDC token signing private key from PEM file data: %w", err)
+	}
+	if privateKey.Curve.Params().Name != "P-256" {
+		return nil, fmt.Errorf("error OIDC token signing private key must use the P-256 curve, found %s", privateKey.Curve.Params().Name)
+	}
+	s.privateKey = privateKey
+	s.keyID = makeKeyID(&privateKey.PublicKey)
+	return s, nil
+}
+
+// IssuerURL returns the URL that identifies the server as an OpenID Connect issuer.
+// Returns a not found error if OIDC tokens are not enabled.
+func (s *OIDCService) IssuerURL() (string, error) {
+	if !s.config.Enabled() {
+		return "", gerror.NewErrNotFound("OIDC tokens are not enabled on this server")
+	}
+	return s.config.IssuerURL, nil
+}
+
+// GetSigningKeys returns the public keys that tokens issued by the server can be verified with.
+// Returns a not found error if OIDC tokens are not enabled.
+func (s *OIDCService) GetSigningKeys() ([]*dto.JSONWebKey, error) {
+	if !s.config.Enabled() {
+		return nil, gerror.NewErrNotFound("OIDC tokens are not enabled on this server")
+	}
+	publicKey := s.privateKey.PublicKey
+	return []*dto.JSONWebKey{
+		{
+			KeyType:   "EC",
+			Use:       "sig",
+			Algorithm: jwt.SigningMethodES256.Alg(),
+			KeyID:     s.keyID,
+			Curve:     "P-256",
+			X:         base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, 32))),
+			Y:         base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, 32))),
+		},
+	}, nil
+}
+
+// IssueJobToken issues an OpenID Connect ID token identifying a running job, for the specified audience.
+// If audience is empty the token's audience is the issuer URL.
+// Returns a validation failed error if OIDC tokens are not enabled or the job is not running.
+func (s *OIDCService) IssueJobToken(ctx context.Context, txOrNil *store.Tx, jobID models.JobID, audience string) (*dto.OIDCToken, error) {
+	if !s.config.Enabled() {
+		return nil, gerror.NewErrValidationFailed("OIDC tokens are not enabled on this server")
+	}
+	job, err := s.jobStore.Read(ctx, txOrNil, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading job: %w", err)
+	}
+	if job.Status != models.WorkflowStatusSubmitted && job.Status != models.WorkflowStatusRunning {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("OIDC tokens can only be issued to running jobs; job is %s", job.Status))
+	}
+	build, err := s.buildStore.Read(ctx, txOrNil, job.BuildID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading build: %w", err)
+	}
+	repo, err := s.repoStore.Read(ctx, txOrNil, job.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	legalEntity, err := s.legalEntityStore.Read(ctx, txOrNil, repo.LegalEntityID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading legal entity: %w", err)
+	}
+	commit, err := s.commitStore.Read(ctx, txOrNil, build.CommitID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit: %w", err)
+	}
+	if audience == "" {
+		audience = s.config.IssuerURL
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.config.TokenExpiry)
+	repoFullName := fmt.Sprintf("%s/%s", legalEntity.Name, repo.Name)
+	jobName := job.Name.String()
+	if job.Workflow != "" {
+		jobName = fmt.Sprintf("%s.%s", job.Workflow, job.Name)
+	}
+	claims := &credential.JobOIDCTokenClaims{
+		LegalEntity: legalEntity.Name.String(),
+		Repo:        repoFullName,
+		RepoID:      repo.ID.String(),
+		BuildID:     build.ID.String(),
+		BuildName:   build.Name.String(),
+		Workflow:    job.Workflow.String(),
+		Job:         jobName,
+		JobID:       job.ID.String(),
+		Ref:         build.Ref,
+		CommitSHA:   commit.SHA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: s.config.IssuerURL,
+			// The subject identifies what the token was issued to in a form trust policies can easily match on
+			Subject:   fmt.Sprintf("repo:%s:ref:%s", repoFullName, build.Ref),
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        models.NewResourceID("oidc-token").String(),
+		},
+	}
+	token, err := credential.CreateJobOIDCJWT(claims, s.keyID, s.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error signing OIDC token: %w", err)
+	}
+	s.Infof("Issued OIDC token for job %q with audience %q", job.ID, audience)
+	return &dto.OIDCToken{
+		Token:     token,
+		ExpiresAt: models.NewTime(expiresAt),
+	}, nil
+}
+
+// makeKeyID returns the RFC 7638 thumbprint of a public key, to identify it in the JSON Web Key Set.
+func makeKeyID(publicKey *ecdsa.PublicKey) string {
+	// The thumbprint is the hash of the required members of the key, in lexicographic order with no whitespace
+	thumbprintJSON, _ := json.Marshal(struct {
+		Curve   string `json:"crv"`
+		KeyType string `json:"kty"`
+		X       string `json:"x"`
+		Y       string `json:"y"`
+	}{
+		Curve:   "P-256",
+		KeyType: "EC",
+		X:       base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, 32))),
+		Y:       base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, 32))),
+	})
+	hash := sha256.Sum256(thumbprintJSON)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,101 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/credential"
+)
+
+func TestIssueJobToken(t *testing.T) {
+	ctx := context.Background()
+
+	config := server_test.TestConfig(t)
+	app, cleanup, err := server_test.New(config)
+	require.NoError(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "refs/heads/main")
+	job := graph.Jobs[0]
+
+	// Tokens are only issued to jobs that are running
+	_, err = app.OIDCService.IssueJobToken(ctx, nil, job.ID, "sts.amazonaws.com")
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	running, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, job.ID, running.ID)
+
+	issuerURL, err := app.OIDCService.IssuerURL()
+	require.NoError(t, err)
+	require.Equal(t, config.OIDCConfig.IssuerURL, issuerURL)
+	keys, err := app.OIDCService.GetSigningKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	key := keys[0]
+	require.Equal(t, "ES256", key.Algorithm)
+	require.NotEmpty(t, key.KeyID)
+
+	// The token must verify against the published key, and identify the job
+	token, err := app.OIDCService.IssueJobToken(ctx, nil, job.ID, "sts.amazonaws.com")
+	require.NoError(t, err)
+	claims := &credential.JobOIDCTokenClaims{}
+	parsed, err := jwt.ParseWithClaims(token.Token, claims, func(token *jwt.Token) (interface{}, error) {
+		require.Equal(t, key.KeyID, token.Header["kid"])
+		return publicKeyFromJWK(t, key), nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	require.NoError(t, err)
+	require.True(t, parsed.Valid)
+	require.Equal(t, issuerURL, claims.Issuer)
+	require.True(t, claims.VerifyAudience("sts.amazonaws.com", true))
+	require.Equal(t, "repo:"+legalEntity.Name.String()+"/"+repo.Name.String()+":ref:refs/heads/main", claims.Subject)
+	require.Equal(t, repo.ID.String(), claims.RepoID)
+	require.Equal(t, graph.ID.String(), claims.BuildID)
+	require.Equal(t, job.ID.String(), claims.JobID)
+	require.Equal(t, "refs/heads/main", claims.Ref)
+	require.NotEmpty(t, claims.CommitSHA)
+	require.Equal(t, token.ExpiresAt.Unix(), claims.ExpiresAt.Unix())
+
+	// Each token is unique, and the audience defaults to the issuer
+	other, err := app.OIDCService.IssueJobToken(ctx, nil, job.ID, "")
+	require.NoError(t, err)
+	otherClaims := &credential.JobOIDCTokenClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(other.Token, otherClaims)
+	require.NoError(t, err)
+	require.NotEqual(t, claims.ID, otherClaims.ID)
+	require.True(t, otherClaims.VerifyAudience(issuerURL, true))
+
+	// Once the job finishes it can no longer get tokens
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+	_, err = app.OIDCService.IssueJobToken(ctx, nil, job.ID, "sts.amazonaws.com")
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+}
+
+func publicKeyFromJWK(t *testing.T, key *dto.JSONWebKey) *ecdsa.PublicKey {
+	x, err := base64.RawURLEncoding.DecodeString(key.X)
+	require.NoError(t, err)
+	y, err := base64.RawURLEncoding.DecodeString(key.Y)
+	require.NoError(t, err)
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+}
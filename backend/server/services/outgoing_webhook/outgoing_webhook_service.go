@@ -0,0 +1,642 @@
+package outgoing_webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	deliveryRequestTimeout  = 10 * time.Second
+	deliveryWorkItemTimeout = 30 * time.Second
+	// maxDeliveryAttempts is the number of times delivery of a payload is attempted before giving up.
+	maxDeliveryAttempts = 8
+	// maxResponseBodyBytes is the maximum amount of an endpoint's response body to record against a delivery.
+	maxResponseBodyBytes = 1024
+	// DefaultMaxConsecutiveFailedDeliveries is the default number of deliveries in a row that can fail before
+	// a webhook is disabled.
+	DefaultMaxConsecutiveFailedDeliveries = 5
+)
+
+const (
+	// SignatureHeader is the header containing the hex-encoded HMAC-SHA256 signature of the payload,
+	// computed using the webhook's secret and prefixed by "sha256=".
+	SignatureHeader = "X-BuildBeaver-Signature-256"
+	// EventHeader is the header containing the type of event the payload describes.
+	EventHeader = "X-BuildBeaver-Event"
+	// DeliveryHeader is the header containing the ID of the delivery.
+	DeliveryHeader = "X-BuildBeaver-Delivery"
+)
+
+type OutgoingWebhookServiceConfig struct {
+	// AllowHTTP permits webhooks with plain http URLs to be registered. This should only be used for development.
+	AllowHTTP bool
+	// MaxConsecutiveFailedDeliveries is the number of deliveries in a row that can fail after every attempt to
+	// deliver them before a webhook is automatically disabled. Zero means webhooks are never disabled.
+	MaxConsecutiveFailedDeliveries int
+}
+
+type OutgoingWebhookService struct {
+	db                *store.DB
+	webhookStore      store.OutgoingWebhookStore
+	deliveryStore     store.OutgoingWebhookDeliveryStore
+	ownershipStore    store.OwnershipStore
+	repoStore         store.RepoStore
+	buildStore        store.BuildStore
+	jobStore          store.JobStore
+	workQueueService  services.WorkQueueService
+	encryptionService services.EncryptionService
+	config            OutgoingWebhookServiceConfig
+	client            *http.Client
+	logger.Log
+}
+
+func NewOutgoingWebhookService(
+	db *store.DB,
+	webhookStore store.OutgoingWebhookStore,
+	deliveryStore store.OutgoingWebhookDeliveryStore,
+	ownershipStore store.OwnershipStore,
+	repoStore store.RepoStore,
+	buildStore store.BuildStore,
+	jobStore store.JobStore,
+	eventService services.EventService,
+	runnerService services.RunnerService,
+	workQueueService services.WorkQueueService,
+	encryptionService services.EncryptionService,
+	config OutgoingWebhookServiceConfig,
+	logFactory logger.LogFactory,
+) *OutgoingWebhookService {
+	s := &OutgoingWebhookService{
+		db:                db,
+		webhookStore:      webhookStore,
+		deliveryStore:     deliveryStore,
+		ownershipStore:    ownershipStore,
+		repoStore:         repoStore,
+		buildStore:        buildStore,
+		jobStore:          jobStore,
+		workQueueService:  workQueueService,
+		encryptionService: encryptionService,
+		config:            config,
+		client:            &http.Client{Timeout: deliveryRequestTimeout},
+		Log:               logFactory("OutgoingWebhookService"),
+	}
+
+	// Register the code to process work items for delivering payloads
+	err := s.workQueueService.RegisterHandler(
+		OutgoingWebhookDeliveryWorkItem,
+		s.ProcessDeliveryWorkItem,
+		deliveryWorkItemTimeout,
+		work_queue.ExponentialBackoff(maxDeliveryAttempts, 10*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	eventService.Subscribe(models.BuildStatusChangedEvent, s.onStatusChanged)
+	eventService.Subscribe(models.JobStatusChangedEvent, s.onStatusChanged)
+	runnerService.RegisterHealthChangedHandler(s.onRunnerHealthChanged)
+
+	return s
+}
+
+// Create a new outgoing webhook for a repo or legal entity.
+func (s *OutgoingWebhookService) Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateOutgoingWebhook) (*models.OutgoingWebhook, error) {
+	err := s.validateURL(create.URL)
+	if err != nil {
+		return nil, err
+	}
+	if create.SecretPlaintext == "" {
+		return nil, gerror.NewErrValidationFailed("Secret must not be empty")
+	}
+	secretEncrypted, dataKeyEncrypted, err := s.encryptionService.Encrypt(ctx, []byte(create.SecretPlaintext))
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting secret: %w", err)
+	}
+	var webhook *models.OutgoingWebhook
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		legalEntityID := create.LegalEntityID
+		ownerID := create.LegalEntityID.ResourceID
+		if create.RepoID.Valid() {
+			repo, err := s.repoStore.Read(ctx, tx, create.RepoID)
+			if err != nil {
+				return fmt.Errorf("error reading repo: %w", err)
+			}
+			legalEntityID = repo.LegalEntityID
+			ownerID = repo.ID.ResourceID
+		}
+		now := models.NewTime(time.Now())
+		webhook = models.NewOutgoingWebhook(
+			now,
+			legalEntityID,
+			create.RepoID,
+			create.URL,
+			secretEncrypted,
+			dataKeyEncrypted,
+			create.OnBuildStatusChanged,
+			create.OnJobStatusChanged,
+			create.OnRunnerHealthChanged)
+		err := webhook.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+		err = s.webhookStore.Create(ctx, tx, webhook)
+		if err != nil {
+			return fmt.Errorf("error creating outgoing webhook: %w", err)
+		}
+		ownership := models.NewOwnership(now, ownerID, webhook.GetID())
+		err = s.ownershipStore.Create(ctx, tx, ownership)
+		if err != nil {
+			return fmt.Errorf("error creating ownership: %w", err)
+		}
+		s.Infof("Created outgoing webhook %q for %q", webhook.ID, ownerID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Read an existing outgoing webhook, looking it up by ID.
+// Returns models.ErrNotFound if the outgoing webhook does not exist.
+func (s *OutgoingWebhookService) Read(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID) (*models.OutgoingWebhook, error) {
+	return s.webhookStore.Read(ctx, txOrNil, id)
+}
+
+// Update an existing outgoing webhook with optimistic locking, changing only the fields that are set in update.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (s *OutgoingWebhookService) Update(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID, update dto.UpdateOutgoingWebhook) (*models.OutgoingWebhook, error) {
+	webhook, err := s.webhookStore.Read(ctx, txOrNil, id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading outgoing webhook: %w", err)
+	}
+	if update.URL != nil {
+		err := s.validateURL(*update.URL)
+		if err != nil {
+			return nil, err
+		}
+		webhook.URL = *update.URL
+	}
+	if update.SecretPlaintext != nil {
+		if *update.SecretPlaintext == "" {
+			return nil, gerror.NewErrValidationFailed("Secret must not be empty")
+		}
+		webhook.SecretEncrypted, webhook.DataKeyEncrypted, err = s.encryptionService.Encrypt(ctx, []byte(*update.SecretPlaintext))
+		if err != nil {
+			return nil, fmt.Errorf("error encrypting secret: %w", err)
+		}
+	}
+	if update.OnBuildStatusChanged != nil {
+		webhook.OnBuildStatusChanged = *update.OnBuildStatusChanged
+	}
+	if update.OnJobStatusChanged != nil {
+		webhook.OnJobStatusChanged = *update.OnJobStatusChanged
+	}
+	if update.OnRunnerHealthChanged != nil {
+		webhook.OnRunnerHealthChanged = *update.OnRunnerHealthChanged
+	}
+	if update.Enabled != nil {
+		if *update.Enabled {
+			webhook.Enable()
+		} else if !webhook.IsDisabled() {
+			webhook.Disable(models.NewTime(time.Now()), "Disabled by user")
+		}
+	}
+	webhook.UpdatedAt = models.NewTime(time.Now())
+	webhook.ETag = models.GetETag(webhook, update.ETag)
+	err = webhook.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	err = s.webhookStore.Update(ctx, txOrNil, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("error updating outgoing webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// Delete permanently and idempotently deletes an outgoing webhook and its delivery history, identifying it by ID.
+func (s *OutgoingWebhookService) Delete(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.deliveryStore.DeleteByWebhookID(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error deleting outgoing webhook deliveries: %w", err)
+		}
+		err = s.webhookStore.Delete(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error deleting outgoing webhook: %w", err)
+		}
+		err = s.ownershipStore.Delete(ctx, tx, id.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListByLegalEntityID lists the outgoing webhooks registered against a legal entity, excluding webhooks
+// registered against the legal entity's individual repos. Use cursor to page through results, if any.
+func (s *OutgoingWebhookService) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error) {
+	return s.webhookStore.ListByLegalEntityID(ctx, txOrNil, legalEntityID, pagination)
+}
+
+// ListByRepoID lists the outgoing webhooks registered against a repo. Use cursor to page through results, if any.
+func (s *OutgoingWebhookService) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error) {
+	return s.webhookStore.ListByRepoID(ctx, txOrNil, repoID, pagination)
+}
+
+// ReadDelivery reads an existing outgoing webhook delivery, looking it up by ID.
+// Returns models.ErrNotFound if the delivery does not exist.
+func (s *OutgoingWebhookService) ReadDelivery(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookDeliveryID) (*models.OutgoingWebhookDelivery, error) {
+	return s.deliveryStore.Read(ctx, txOrNil, id)
+}
+
+// ListDeliveries lists the deliveries for an outgoing webhook, most recent first.
+// Use cursor to page through results, if any.
+func (s *OutgoingWebhookService) ListDeliveries(ctx context.Context, txOrNil *store.Tx, webhookID models.OutgoingWebhookID, pagination models.Pagination) ([]*models.OutgoingWebhookDelivery, *models.Cursor, error) {
+	return s.deliveryStore.ListByWebhookID(ctx, txOrNil, webhookID, pagination)
+}
+
+// Redeliver queues a delivery to be delivered again, for example after fixing a problem with the endpoint.
+// The delivery's attempt count is reset, but attempts made so far are kept in its attempt log.
+// Returns a validation error if the delivery is still pending, or if the webhook is disabled.
+func (s *OutgoingWebhookService) Redeliver(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookDeliveryID) (*models.OutgoingWebhookDelivery, error) {
+	var delivery *models.OutgoingWebhookDelivery
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		var err error
+		delivery, err = s.deliveryStore.Read(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error reading outgoing webhook delivery: %w", err)
+		}
+		if delivery.Status == models.OutgoingWebhookDeliveryStatusPending {
+			return gerror.NewErrValidationFailed("Delivery is already pending")
+		}
+		webhook, err := s.webhookStore.Read(ctx, tx, delivery.WebhookID)
+		if err != nil {
+			return fmt.Errorf("error reading outgoing webhook: %w", err)
+		}
+		if webhook.IsDisabled() {
+			return gerror.NewErrValidationFailed("Webhook is disabled; enable it before redelivering")
+		}
+		delivery.Status = models.OutgoingWebhookDeliveryStatusPending
+		delivery.Attempts = 0
+		delivery.UpdatedAt = models.NewTime(time.Now())
+		err = s.deliveryStore.Update(ctx, tx, delivery)
+		if err != nil {
+			return fmt.Errorf("error updating outgoing webhook delivery: %w", err)
+		}
+		err = s.workQueueService.AddWorkItem(ctx, tx, NewOutgoingWebhookDeliveryWorkItem(delivery.ID))
+		if err != nil {
+			return fmt.Errorf("error queueing outgoing webhook delivery work item: %w", err)
+		}
+		s.Infof("Queued redelivery of %q to outgoing webhook %q", delivery.ID, webhook.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// onStatusChanged is called when the status of a build or job changes, and queues deliveries to any
+// webhooks for the build's repo that are subscribed to the event.
+func (s *OutgoingWebhookService) onStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	build, err := s.buildStore.Read(ctx, tx, event.BuildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	repo, err := s.repoStore.Read(ctx, tx, build.RepoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	webhooks, err := s.listAllForRepo(ctx, tx, repo)
+	if err != nil {
+		return err
+	}
+	var payload *models.OutgoingWebhookPayload
+	for _, webhook := range webhooks {
+		if !webhook.IsSubscribed(event.Type) {
+			continue
+		}
+		if payload == nil {
+			payload, err = s.makePayload(ctx, tx, event, repo, build)
+			if err != nil {
+				return err
+			}
+		}
+		err = s.queueDelivery(ctx, tx, webhook, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onRunnerHealthChanged is called when a runner becomes unhealthy or healthy again, and queues deliveries to
+// any webhooks for the runner's legal entity that are subscribed to the event.
+func (s *OutgoingWebhookService) onRunnerHealthChanged(ctx context.Context, tx *store.Tx, runner *models.Runner) error {
+	webhooks, err := s.listAllForLegalEntity(ctx, tx, runner.LegalEntityID)
+	if err != nil {
+		return err
+	}
+	payload := &models.OutgoingWebhookPayload{
+		Event:     models.RunnerHealthChangedEvent,
+		Timestamp: models.NewTime(time.Now()),
+		Runner: &models.OutgoingWebhookPayloadRunner{
+			ID:      runner.ID,
+			Name:    runner.Name,
+			Healthy: runner.IsHealthy(),
+		},
+	}
+	if runner.Health != nil {
+		payload.Runner.Problems = runner.Health.Problems
+	}
+	for _, webhook := range webhooks {
+		if !webhook.IsSubscribed(payload.Event) {
+			continue
+		}
+		err = s.queueDelivery(ctx, tx, webhook, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueDelivery records a delivery of payload to a webhook and queues a work item to deliver it.
+// The payload's DeliveryID is filled out with the ID of the new delivery.
+func (s *OutgoingWebhookService) queueDelivery(ctx context.Context, tx *store.Tx, webhook *models.OutgoingWebhook, payload *models.OutgoingWebhookPayload) error {
+	delivery := models.NewOutgoingWebhookDelivery(models.NewTime(time.Now()), webhook.ID, payload.Event)
+	payload.DeliveryID = delivery.ID
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling outgoing webhook payload: %w", err)
+	}
+	delivery.Payload = string(payloadJSON)
+	err = s.deliveryStore.Create(ctx, tx, delivery)
+	if err != nil {
+		return fmt.Errorf("error creating outgoing webhook delivery: %w", err)
+	}
+	err = s.workQueueService.AddWorkItem(ctx, tx, NewOutgoingWebhookDeliveryWorkItem(delivery.ID))
+	if err != nil {
+		return fmt.Errorf("error queueing outgoing webhook delivery work item: %w", err)
+	}
+	s.Tracef("Queued %s delivery %q to outgoing webhook %q", payload.Event, delivery.ID, webhook.ID)
+	return nil
+}
+
+// listAllForRepo reads every page of outgoing webhooks that receive events for a repo.
+func (s *OutgoingWebhookService) listAllForRepo(ctx context.Context, txOrNil *store.Tx, repo *models.Repo) ([]*models.OutgoingWebhook, error) {
+	var (
+		results    []*models.OutgoingWebhook
+		pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+	)
+	for moreResults := true; moreResults; {
+		webhooks, cursor, err := s.webhookStore.ListForRepo(ctx, txOrNil, repo, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing outgoing webhooks: %w", err)
+		}
+		results = append(results, webhooks...)
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	return results, nil
+}
+
+// listAllForLegalEntity reads every page of outgoing webhooks registered against a legal entity, excluding
+// webhooks registered against the legal entity's individual repos.
+func (s *OutgoingWebhookService) listAllForLegalEntity(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) ([]*models.OutgoingWebhook, error) {
+	var (
+		results    []*models.OutgoingWebhook
+		pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+	)
+	for moreResults := true; moreResults; {
+		webhooks, cursor, err := s.webhookStore.ListByLegalEntityID(ctx, txOrNil, legalEntityID, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing outgoing webhooks: %w", err)
+		}
+		results = append(results, webhooks...)
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	return results, nil
+}
+
+// makePayload makes the payload describing a build or job status changed event. The DeliveryID
+// must be filled out separately for each delivery.
+func (s *OutgoingWebhookService) makePayload(ctx context.Context, txOrNil *store.Tx, event *models.Event, repo *models.Repo, build *models.Build) (*models.OutgoingWebhookPayload, error) {
+	payload := &models.OutgoingWebhookPayload{
+		Event:     event.Type,
+		Timestamp: event.CreatedAt,
+		Repo: &models.OutgoingWebhookPayloadRepo{
+			ID:   repo.ID,
+			Name: repo.Name,
+		},
+		Build: &models.OutgoingWebhookPayloadItem{
+			ID:     build.ID.ResourceID,
+			Name:   build.Name,
+			Ref:    build.Ref,
+			Status: build.Status,
+		},
+	}
+	if build.Error != nil {
+		payload.Build.Error = build.Error.Error()
+	}
+	if event.Type == models.JobStatusChangedEvent {
+		job, err := s.jobStore.Read(ctx, txOrNil, models.JobIDFromResourceID(event.ResourceID))
+		if err != nil {
+			return nil, fmt.Errorf("error reading job: %w", err)
+		}
+		payload.Job = &models.OutgoingWebhookPayloadItem{
+			ID:       job.ID.ResourceID,
+			Name:     job.Name,
+			Workflow: job.Workflow,
+			Status:   job.Status,
+		}
+		if job.Error != nil {
+			payload.Job.Error = job.Error.Error()
+		}
+	}
+	return payload, nil
+}
+
+// ProcessDeliveryWorkItem is a work item handler that delivers a payload to an outgoing webhook, and
+// records the outcome against the delivery.
+func (s *OutgoingWebhookService) ProcessDeliveryWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &OutgoingWebhookDeliveryWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling outgoing webhook delivery work item data: %w", err)
+	}
+	delivery, err := s.deliveryStore.Read(ctx, nil, workItemData.DeliveryID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping deleted outgoing webhook delivery %q", workItemData.DeliveryID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading outgoing webhook delivery: %w", err)
+	}
+	if delivery.Status != models.OutgoingWebhookDeliveryStatusPending {
+		return false, nil
+	}
+	webhook, err := s.webhookStore.Read(ctx, nil, delivery.WebhookID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping delivery %q for deleted outgoing webhook %q", delivery.ID, delivery.WebhookID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading outgoing webhook: %w", err)
+	}
+	if webhook.IsDisabled() {
+		// Payloads queued before the webhook was disabled are never delivered, but can be redelivered later
+		delivery.Status = models.OutgoingWebhookDeliveryStatusFailed
+		delivery.Error = "webhook is disabled"
+		delivery.UpdatedAt = models.NewTime(time.Now())
+		err = s.deliveryStore.Update(ctx, nil, delivery)
+		if err != nil {
+			return true, fmt.Errorf("error updating outgoing webhook delivery: %w", err)
+		}
+		return false, nil
+	}
+	secret, err := s.encryptionService.Decrypt(ctx, webhook.SecretEncrypted, webhook.DataKeyEncrypted)
+	if err != nil {
+		return false, fmt.Errorf("error decrypting outgoing webhook secret: %w", err)
+	}
+
+	attemptedAt := time.Now()
+	statusCode, responseBody, sendErr := s.send(ctx, webhook.URL, secret, delivery)
+	attempt := &models.OutgoingWebhookDeliveryAttempt{
+		AttemptedAt:        models.NewTime(attemptedAt),
+		DurationMs:         time.Since(attemptedAt).Milliseconds(),
+		ResponseStatusCode: statusCode,
+	}
+	delivery.Attempts++
+	delivery.ResponseStatusCode = statusCode
+	delivery.ResponseBody = responseBody
+	delivery.Error = ""
+	if sendErr == nil {
+		delivery.Status = models.OutgoingWebhookDeliveryStatusSucceeded
+	} else {
+		delivery.Error = sendErr.Error()
+		attempt.Error = sendErr.Error()
+		if delivery.Attempts >= maxDeliveryAttempts {
+			delivery.Status = models.OutgoingWebhookDeliveryStatusFailed
+		}
+	}
+	delivery.LogAttempt(attempt)
+	delivery.UpdatedAt = models.NewTime(time.Now())
+	err = s.deliveryStore.Update(ctx, nil, delivery)
+	if err != nil {
+		return true, fmt.Errorf("error updating outgoing webhook delivery: %w", err)
+	}
+	if delivery.Status != models.OutgoingWebhookDeliveryStatusPending {
+		s.recordDeliveryOutcome(ctx, webhook.ID, delivery.Status == models.OutgoingWebhookDeliveryStatusSucceeded)
+	}
+	if sendErr != nil {
+		return delivery.Status == models.OutgoingWebhookDeliveryStatusPending,
+			fmt.Errorf("error delivering payload to outgoing webhook %q: %w", webhook.ID, sendErr)
+	}
+	s.Tracef("Delivered %q to outgoing webhook %q", delivery.ID, webhook.ID)
+	return false, nil
+}
+
+// recordDeliveryOutcome counts the deliveries to a webhook that failed after every attempt to deliver them,
+// and disables the webhook once too many deliveries in a row have failed. A successful delivery resets the count.
+// Errors are logged rather than returned since the outcome of the delivery itself has already been recorded.
+func (s *OutgoingWebhookService) recordDeliveryOutcome(ctx context.Context, webhookID models.OutgoingWebhookID, succeeded bool) {
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		webhook, err := s.webhookStore.Read(ctx, tx, webhookID)
+		if err != nil {
+			return fmt.Errorf("error reading outgoing webhook: %w", err)
+		}
+		now := models.NewTime(time.Now())
+		if succeeded {
+			if webhook.ConsecutiveFailedDeliveries == 0 {
+				return nil
+			}
+			webhook.ConsecutiveFailedDeliveries = 0
+		} else {
+			webhook.ConsecutiveFailedDeliveries++
+			if s.config.MaxConsecutiveFailedDeliveries > 0 &&
+				webhook.ConsecutiveFailedDeliveries >= s.config.MaxConsecutiveFailedDeliveries &&
+				!webhook.IsDisabled() {
+				webhook.Disable(now, fmt.Sprintf("%d deliveries in a row failed", webhook.ConsecutiveFailedDeliveries))
+				s.Warnf("Disabled outgoing webhook %q after %d failed deliveries in a row", webhook.ID, webhook.ConsecutiveFailedDeliveries)
+			}
+		}
+		webhook.UpdatedAt = now
+		return s.webhookStore.Update(ctx, tx, webhook)
+	})
+	if err != nil {
+		s.Warnf("Unable to record outcome of delivery to outgoing webhook %q: %v", webhookID, err)
+	}
+}
+
+// send posts the delivery's payload to the specified URL, signed using secret. Returns the status code
+// and the start of the body of the response, if a response was received.
+func (s *OutgoingWebhookService) send(ctx context.Context, webhookURL string, secret []byte, delivery *models.OutgoingWebhookDelivery) (statusCode int, responseBody string, err error) {
+	body := []byte(delivery.Payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "BuildBeaver-Webhook")
+	req.Header.Set(SignatureHeader, signature)
+	req.Header.Set(EventHeader, delivery.EventType.String())
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("error sending request: %w", err)
+	}
+	defer res.Body.Close()
+	resBody, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseBodyBytes))
+	// The response is stored in the database so it must be valid text
+	responseBody = strings.ReplaceAll(strings.ToValidUTF8(string(resBody), ""), "\x00", "")
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, responseBody, fmt.Errorf("error endpoint responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, responseBody, nil
+}
+
+// validateURL returns a validation error if the specified URL can't be used as an outgoing webhook.
+func (s *OutgoingWebhookService) validateURL(webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || parsed.Host == "" {
+		return gerror.NewErrValidationFailed("URL must be a valid https URL")
+	}
+	if parsed.Scheme != "https" && !(s.config.AllowHTTP && parsed.Scheme == "http") {
+		return gerror.NewErrValidationFailed("URL must be a valid https URL")
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package outgoing_webhook_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/outgoing_webhook"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testDeliveryTimeout = 30 * time.Second
+
+type receivedRequest struct {
+	event     models.EventType
+	signature string
+	payload   *models.OutgoingWebhookPayload
+	body      []byte
+}
+
+// testEndpoint records payloads delivered to it, responding with the specified status code.
+type testEndpoint struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []receivedRequest
+}
+
+func newTestEndpoint(t *testing.T, statusCode int) *testEndpoint {
+	endpoint := &testEndpoint{}
+	endpoint.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		payload := &models.OutgoingWebhookPayload{}
+		err = json.Unmarshal(body, payload)
+		require.NoError(t, err)
+		endpoint.mu.Lock()
+		endpoint.requests = append(endpoint.requests, receivedRequest{
+			event:     models.EventType(r.Header.Get(outgoing_webhook.EventHeader)),
+			signature: r.Header.Get(outgoing_webhook.SignatureHeader),
+			payload:   payload,
+			body:      body,
+		})
+		endpoint.mu.Unlock()
+		w.WriteHeader(statusCode)
+		w.Write([]byte("received"))
+	}))
+	return endpoint
+}
+
+// waitForRequests waits until at least n payloads have been delivered, and returns all payloads delivered so far.
+func (e *testEndpoint) waitForRequests(t *testing.T, n int) []receivedRequest {
+	deadline := time.Now().Add(testDeliveryTimeout)
+	for time.Now().Before(deadline) {
+		e.mu.Lock()
+		if len(e.requests) >= n {
+			requests := append([]receivedRequest(nil), e.requests...)
+			e.mu.Unlock()
+			return requests
+		}
+		e.mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d outgoing webhook deliveries", n)
+	return nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestOutgoingWebhookService(t *testing.T) {
+	ctx := context.Background()
+
+	config := server_test.TestConfig(t)
+	config.OutgoingWebhookConfig.AllowHTTP = true
+	app, cleanup, err := server_test.New(config)
+	require.Nil(t, err)
+	defer cleanup()
+
+	orgEndpoint := newTestEndpoint(t, http.StatusOK)
+	defer orgEndpoint.Close()
+	repoEndpoint := newTestEndpoint(t, http.StatusOK)
+	defer repoEndpoint.Close()
+	brokenEndpoint := newTestEndpoint(t, http.StatusInternalServerError)
+	defer brokenEndpoint.Close()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	require.NotEmpty(t, graph.Jobs)
+
+	// URLs must be valid
+	_, err = app.OutgoingWebhookService.Create(ctx, nil, dto.CreateOutgoingWebhook{
+		LegalEntityID:        legalEntity.ID,
+		URL:                  "not a url",
+		SecretPlaintext:      "secret",
+		OnBuildStatusChanged: true,
+	})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	// The org webhook receives build events for all repos, and the repo webhook receives job events for the repo
+	orgSecret := "org-secret"
+	orgWebhook, err := app.OutgoingWebhookService.Create(ctx, nil, dto.CreateOutgoingWebhook{
+		LegalEntityID:        legalEntity.ID,
+		URL:                  orgEndpoint.URL,
+		SecretPlaintext:      orgSecret,
+		OnBuildStatusChanged: true,
+	})
+	require.NoError(t, err)
+	require.False(t, orgWebhook.RepoID.Valid())
+	require.NotEqual(t, orgSecret, string(orgWebhook.SecretEncrypted))
+
+	repoSecret := "repo-secret"
+	repoWebhook, err := app.OutgoingWebhookService.Create(ctx, nil, dto.CreateOutgoingWebhook{
+		RepoID:             repo.ID,
+		URL:                repoEndpoint.URL,
+		SecretPlaintext:    repoSecret,
+		OnJobStatusChanged: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, repo.ID, repoWebhook.RepoID)
+	require.Equal(t, legalEntity.ID, repoWebhook.LegalEntityID)
+
+	brokenWebhook, err := app.OutgoingWebhookService.Create(ctx, nil, dto.CreateOutgoingWebhook{
+		RepoID:               repo.ID,
+		URL:                  brokenEndpoint.URL,
+		SecretPlaintext:      "broken-secret",
+		OnBuildStatusChanged: true,
+	})
+	require.NoError(t, err)
+
+	orgWebhooks, _, err := app.OutgoingWebhookService.ListByLegalEntityID(ctx, nil, legalEntity.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, orgWebhooks, 1)
+	repoWebhooks, _, err := app.OutgoingWebhookService.ListByRepoID(ctx, nil, repo.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, repoWebhooks, 2)
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	err = app.EventService.PublishEvent(ctx, nil, models.NewBuildStatusChangedEventData(graph.Build))
+	require.NoError(t, err)
+	job := graph.Jobs[0]
+	err = app.EventService.PublishEvent(ctx, nil, models.NewJobStatusChangedEventData(job.Job))
+	require.NoError(t, err)
+
+	orgRequests := orgEndpoint.waitForRequests(t, 1)
+	require.Equal(t, models.BuildStatusChangedEvent, orgRequests[0].event)
+	require.Equal(t, sign(orgSecret, orgRequests[0].body), orgRequests[0].signature)
+	require.Equal(t, graph.Build.ID.ResourceID, orgRequests[0].payload.Build.ID)
+	require.Equal(t, repo.ID, orgRequests[0].payload.Repo.ID)
+	require.Nil(t, orgRequests[0].payload.Job)
+
+	repoRequests := repoEndpoint.waitForRequests(t, 1)
+	require.Equal(t, models.JobStatusChangedEvent, repoRequests[0].event)
+	require.Equal(t, sign(repoSecret, repoRequests[0].body), repoRequests[0].signature)
+	require.NotNil(t, repoRequests[0].payload.Job)
+	require.Equal(t, job.ID.ResourceID, repoRequests[0].payload.Job.ID)
+
+	// Successful deliveries are recorded in the webhook's delivery history
+	deliveries, _, err := app.OutgoingWebhookService.ListDeliveries(ctx, nil, orgWebhook.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, orgRequests[0].payload.DeliveryID, deliveries[0].ID)
+	waitForDelivery := func(id models.OutgoingWebhookDeliveryID) *models.OutgoingWebhookDelivery {
+		deadline := time.Now().Add(testDeliveryTimeout)
+		for time.Now().Before(deadline) {
+			delivery, err := app.OutgoingWebhookService.ReadDelivery(ctx, nil, id)
+			require.NoError(t, err)
+			if delivery.Attempts > 0 {
+				return delivery
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for delivery %q to be attempted", id)
+		return nil
+	}
+	delivery := waitForDelivery(deliveries[0].ID)
+	require.Equal(t, models.OutgoingWebhookDeliveryStatusSucceeded, delivery.Status)
+	require.Equal(t, http.StatusOK, delivery.ResponseStatusCode)
+	require.Equal(t, "received", delivery.ResponseBody)
+
+	// Failed deliveries remain pending so they will be retried
+	brokenEndpoint.waitForRequests(t, 1)
+	deliveries, _, err = app.OutgoingWebhookService.ListDeliveries(ctx, nil, brokenWebhook.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery = waitForDelivery(deliveries[0].ID)
+	require.Equal(t, models.OutgoingWebhookDeliveryStatusPending, delivery.Status)
+	require.Equal(t, http.StatusInternalServerError, delivery.ResponseStatusCode)
+	require.NotEmpty(t, delivery.Error)
+
+	// Deleting a webhook deletes its delivery history
+	err = app.OutgoingWebhookService.Delete(ctx, nil, brokenWebhook.ID)
+	require.NoError(t, err)
+	_, err = app.OutgoingWebhookService.Read(ctx, nil, brokenWebhook.ID)
+	require.True(t, gerror.IsNotFound(err))
+	_, err = app.OutgoingWebhookService.ReadDelivery(ctx, nil, delivery.ID)
+	require.True(t, gerror.IsNotFound(err))
+}
+
+func TestOutgoingWebhookRedeliveryAndDisabling(t *testing.T) {
+	ctx := context.Background()
+
+	config := server_test.TestConfig(t)
+	config.OutgoingWebhookConfig.AllowHTTP = true
+	config.OutgoingWebhookConfig.MaxConsecutiveFailedDeliveries = 2
+	app, cleanup, err := server_test.New(config)
+	require.Nil(t, err)
+	defer cleanup()
+
+	brokenEndpoint := newTestEndpoint(t, http.StatusInternalServerError)
+	defer brokenEndpoint.Close()
+	fixedEndpoint := newTestEndpoint(t, http.StatusOK)
+	defer fixedEndpoint.Close()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+
+	webhook, err := app.OutgoingWebhookService.Create(ctx, nil, dto.CreateOutgoingWebhook{
+		RepoID:               repo.ID,
+		URL:                  brokenEndpoint.URL,
+		SecretPlaintext:      "secret",
+		OnBuildStatusChanged: true,
+	})
+	require.NoError(t, err)
+
+	// Deliveries are processed directly rather than via the work queue, to avoid waiting for retries
+	service := app.OutgoingWebhookService.(*outgoing_webhook.OutgoingWebhookService)
+	publishAndDeliver := func() *models.OutgoingWebhookDelivery {
+		err := app.EventService.PublishEvent(ctx, nil, models.NewBuildStatusChangedEventData(graph.Build))
+		require.NoError(t, err)
+		deliveries, _, err := app.OutgoingWebhookService.ListDeliveries(ctx, nil, webhook.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+		require.NoError(t, err)
+		require.NotEmpty(t, deliveries)
+		for i := 0; i < 10; i++ {
+			_, _ = service.ProcessDeliveryWorkItem(ctx, outgoing_webhook.NewOutgoingWebhookDeliveryWorkItem(deliveries[0].ID))
+		}
+		delivery, err := app.OutgoingWebhookService.ReadDelivery(ctx, nil, deliveries[0].ID)
+		require.NoError(t, err)
+		return delivery
+	}
+
+	// Every attempt is recorded in the delivery's attempt log
+	first := publishAndDeliver()
+	require.Equal(t, models.OutgoingWebhookDeliveryStatusFailed, first.Status)
+	require.Len(t, first.AttemptLog, first.Attempts)
+	for _, attempt := range first.AttemptLog {
+		require.Equal(t, http.StatusInternalServerError, attempt.ResponseStatusCode)
+		require.NotEmpty(t, attempt.Error)
+	}
+	webhook, err = app.OutgoingWebhookService.Read(ctx, nil, webhook.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, webhook.ConsecutiveFailedDeliveries)
+	require.False(t, webhook.IsDisabled())
+
+	// The webhook is disabled once too many deliveries in a row fail
+	second := publishAndDeliver()
+	require.Equal(t, models.OutgoingWebhookDeliveryStatusFailed, second.Status)
+	webhook, err = app.OutgoingWebhookService.Read(ctx, nil, webhook.ID)
+	require.NoError(t, err)
+	require.True(t, webhook.IsDisabled())
+	require.NotEmpty(t, webhook.DisabledReason)
+
+	// Nothing is delivered to a disabled webhook, and its deliveries can't be redelivered
+	err = app.EventService.PublishEvent(ctx, nil, models.NewBuildStatusChangedEventData(graph.Build))
+	require.NoError(t, err)
+	deliveries, _, err := app.OutgoingWebhookService.ListDeliveries(ctx, nil, webhook.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	_, err = app.OutgoingWebhookService.Redeliver(ctx, nil, first.ID)
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	// Once the endpoint is fixed and the webhook re-enabled, failed deliveries can be redelivered
+	enabled := true
+	webhook, err = app.OutgoingWebhookService.Update(ctx, nil, webhook.ID, dto.UpdateOutgoingWebhook{
+		URL:     &fixedEndpoint.URL,
+		Enabled: &enabled,
+	})
+	require.NoError(t, err)
+	require.False(t, webhook.IsDisabled())
+	require.Equal(t, 0, webhook.ConsecutiveFailedDeliveries)
+	redelivery, err := app.OutgoingWebhookService.Redeliver(ctx, nil, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.OutgoingWebhookDeliveryStatusPending, redelivery.Status)
+	require.Equal(t, 0, redelivery.Attempts)
+	_, err = app.OutgoingWebhookService.Redeliver(ctx, nil, first.ID)
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+	canRetry, err := service.ProcessDeliveryWorkItem(ctx, outgoing_webhook.NewOutgoingWebhookDeliveryWorkItem(first.ID))
+	require.NoError(t, err)
+	require.False(t, canRetry)
+	redelivery, err = app.OutgoingWebhookService.ReadDelivery(ctx, nil, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.OutgoingWebhookDeliveryStatusSucceeded, redelivery.Status)
+	require.Equal(t, 1, redelivery.Attempts)
+	require.Len(t, redelivery.AttemptLog, len(first.AttemptLog)+1)
+	require.Empty(t, redelivery.AttemptLog[len(redelivery.AttemptLog)-1].Error)
+	requests := fixedEndpoint.waitForRequests(t, 1)
+	require.Equal(t, first.ID, requests[0].payload.DeliveryID)
+}
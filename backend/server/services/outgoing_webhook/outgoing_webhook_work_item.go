@@ -0,0 +1,35 @@
+package outgoing_webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// OutgoingWebhookDeliveryWorkItem is a work item that will deliver a payload to an outgoing webhook.
+const OutgoingWebhookDeliveryWorkItem models.WorkItemType = "OutgoingWebhookDelivery"
+
+// OutgoingWebhookDeliveryWorkItemData is serialized to JSON and stored in the Data field of an
+// OutgoingWebhookDeliveryWorkItem. The payload itself is read from the delivery when the work item is processed.
+type OutgoingWebhookDeliveryWorkItemData struct {
+	DeliveryID models.OutgoingWebhookDeliveryID
+}
+
+func NewOutgoingWebhookDeliveryWorkItem(deliveryID models.OutgoingWebhookDeliveryID) *models.WorkItem {
+	data := &OutgoingWebhookDeliveryWorkItemData{
+		DeliveryID: deliveryID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in OutgoingWebhookDeliveryWorkItemData definition
+		panic("Unable to marshal OutgoingWebhookDeliveryWorkItemData object to JSON")
+	}
+
+	// Concurrency key is per delivery, so an endpoint that is down doesn't hold up deliveries to other endpoints.
+	// Receivers can use the timestamp in the payload to order events.
+	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("outgoing-webhook-delivery/%s", deliveryID))
+
+	return models.NewWorkItem(OutgoingWebhookDeliveryWorkItem, string(dataJson), concurrencyKey, models.NewTime(time.Now()))
+}
@@ -0,0 +1,163 @@
+package permission_override
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// PermissionOverrideService manages permission overrides, which grant or deny an access control group
+// specific operations on a single repo regardless of the permissions the group holds on the repo's owner.
+// Overrides are stored as group grants that target the repo.
+type PermissionOverrideService struct {
+	db             *store.DB
+	repoStore      store.RepoStore
+	groupStore     store.GroupStore
+	grantStore     store.GrantStore
+	ownershipStore store.OwnershipStore
+	logger.Log
+}
+
+func NewPermissionOverrideService(
+	db *store.DB,
+	repoStore store.RepoStore,
+	groupStore store.GroupStore,
+	grantStore store.GrantStore,
+	ownershipStore store.OwnershipStore,
+	logFactory logger.LogFactory,
+) *PermissionOverrideService {
+	return &PermissionOverrideService{
+		db:             db,
+		repoStore:      repoStore,
+		groupStore:     groupStore,
+		grantStore:     grantStore,
+		ownershipStore: ownershipStore,
+		Log:            logFactory("PermissionOverrideService"),
+	}
+}
+
+// Set grants (or denies, if deny is true) an access control group permission to perform an operation on a repo.
+// The group must belong to the legal entity that owns the repo. If the group already has an override for the
+// operation on the repo then the existing override is updated and returned.
+func (s *PermissionOverrideService) Set(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	repoID models.RepoID,
+	groupID models.GroupID,
+	operation models.Operation,
+	deny bool,
+) (*models.Grant, error) {
+	if !models.IsRepoOverridableOperation(operation) {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Operation %s can not be overridden on a repo", operation))
+	}
+	var grant *models.Grant
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		repo, err := s.repoStore.Read(ctx, tx, repoID)
+		if err != nil {
+			return fmt.Errorf("error reading repo: %w", err)
+		}
+		group, err := s.groupStore.Read(ctx, tx, groupID)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return gerror.NewErrValidationFailed("Group not found")
+			}
+			return fmt.Errorf("error reading group: %w", err)
+		}
+		if group.LegalEntityID != repo.LegalEntityID {
+			return gerror.NewErrValidationFailed("Permissions can only be overridden for groups owned by the same legal entity as the repo")
+		}
+		if group.ExternalID != nil {
+			// Grants for groups synced from an SCM are replaced with the SCM's permissions on every sync
+			return gerror.NewErrValidationFailed("Permissions can not be overridden for groups whose permissions are managed by an SCM")
+		}
+		now := models.NewTime(time.Now().UTC())
+		grantData := models.NewGroupGrant(now, repo.LegalEntityID, group.ID, operation, repo.ID.ResourceID)
+		grantData.Deny = deny
+		var created bool
+		grant, created, err = s.grantStore.FindOrCreate(ctx, tx, grantData)
+		if err != nil {
+			return fmt.Errorf("error creating grant: %w", err)
+		}
+		if created {
+			// Grant is owned by the target resource, consistent with grants made by the authorization service
+			ownership := models.NewOwnership(now, grant.TargetResourceID, grant.GetID())
+			_, _, err = s.ownershipStore.Upsert(ctx, tx, ownership)
+			if err != nil {
+				return fmt.Errorf("error creating ownership: %w", err)
+			}
+		} else if grant.Deny != deny {
+			grant.Deny = deny
+			grant.UpdatedAt = now
+			err = s.grantStore.Update(ctx, tx, grant)
+			if err != nil {
+				return fmt.Errorf("error updating grant: %w", err)
+			}
+		}
+		s.Infof("Set permission override for group %s to %s %s on repo %s", group.ID, overrideEffect(grant), operation, repo.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// Read an existing permission override, looking it up by the ID of its grant.
+// Returns models.ErrNotFound if the override does not exist.
+func (s *PermissionOverrideService) Read(ctx context.Context, txOrNil *store.Tx, id models.GrantID) (*models.Grant, error) {
+	return s.grantStore.Read(ctx, txOrNil, id)
+}
+
+// Delete permanently and idempotently deletes a permission override, identifying it by the ID of its grant.
+// The group's permissions on the repo revert to those it holds on the repo's owner.
+func (s *PermissionOverrideService) Delete(ctx context.Context, txOrNil *store.Tx, id models.GrantID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		grant, err := s.grantStore.Read(ctx, tx, id)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("error reading grant: %w", err)
+		}
+		if !grant.AuthorizedGroupID.Valid() {
+			return gerror.NewErrValidationFailed("Grant is not a permission override")
+		}
+		group, err := s.groupStore.Read(ctx, tx, grant.AuthorizedGroupID)
+		if err != nil {
+			return fmt.Errorf("error reading group: %w", err)
+		}
+		if group.ExternalID != nil {
+			return gerror.NewErrValidationFailed("Permissions can not be overridden for groups whose permissions are managed by an SCM")
+		}
+		err = s.ownershipStore.Delete(ctx, tx, grant.ID.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		err = s.grantStore.Delete(ctx, tx, grant.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting grant: %w", err)
+		}
+		s.Infof("Deleted permission override for group %s to %s %s on %s",
+			group.ID, overrideEffect(grant), grant.GetOperation(), grant.TargetResourceID)
+		return nil
+	})
+}
+
+// ListByRepoID lists the permissions granted to or denied for groups directly on a repo, including those synced
+// from an SCM. Use cursor to page through results, if any.
+func (s *PermissionOverrideService) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.Grant, *models.Cursor, error) {
+	return s.grantStore.ListGroupGrantsForTarget(ctx, txOrNil, repoID.ResourceID, pagination)
+}
+
+// overrideEffect describes whether a grant allows or denies its operation, for logging.
+func overrideEffect(grant *models.Grant) string {
+	if grant.Deny {
+		return "deny"
+	}
+	return "allow"
+}
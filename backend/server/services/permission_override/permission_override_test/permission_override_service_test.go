@@ -0,0 +1,156 @@
+package permission_override_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/models/search"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestPermissionOverrideService(t *testing.T) {
+	ctx := context.Background()
+	now := models.NewTime(time.Now())
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "override-co", "Override Co", "overrides@not-a-real-domain.com")
+	repo1 := server_test.CreateNamedRepo(t, ctx, app, "repo-1", company.ID)
+	repo2 := server_test.CreateNamedRepo(t, ctx, app, "repo-2", company.ID)
+	bob, bobIdentity := server_test.CreatePersonLegalEntity(t, ctx, app, "bob", "Bob Second", "bob@not-a-real-domain.com")
+	err = app.LegalEntityService.AddCompanyMember(ctx, nil, company.ID, bob.ID)
+	require.NoError(t, err)
+
+	// Bob is a regular user of the company, and also a member of a contractors group
+	userGroup, err := app.GroupService.ReadByName(ctx, nil, company.ID, models.UserStandardGroup.Name)
+	require.NoError(t, err)
+	_, _, err = app.GroupService.FindOrCreateMembership(ctx, nil, models.NewGroupMembershipData(userGroup.ID, bobIdentity.ID, models.TestsSystem, company.ID))
+	require.NoError(t, err)
+	contractors, _, err := app.GroupService.FindOrCreateByName(ctx, nil, models.NewGroup(now, company.ID, "contractors", "Contractors", false, nil))
+	require.NoError(t, err)
+	_, _, err = app.GroupService.FindOrCreateMembership(ctx, nil, models.NewGroupMembershipData(contractors.ID, bobIdentity.ID, models.TestsSystem, company.ID))
+	require.NoError(t, err)
+
+	checkAuthorized := func(repo *models.Repo, operation *models.Operation, expected bool) {
+		t.Helper()
+		authorized, err := app.AuthorizationService.IsAuthorized(ctx, bobIdentity.ID, operation, repo.ID.ResourceID)
+		require.NoError(t, err)
+		require.Equal(t, expected, authorized, "expected authorized=%v for %s on %s", expected, operation, repo.Name)
+	}
+	visibleRepos := func() []models.RepoID {
+		t.Helper()
+		repos, _, err := app.RepoStore.Search(ctx, nil, bobIdentity.ID, search.NewRepoQueryBuilder().Compile())
+		require.NoError(t, err)
+		var ids []models.RepoID
+		for _, repo := range repos {
+			ids = append(ids, repo.ID)
+		}
+		return ids
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := app.PermissionOverrideService.Set(ctx, nil, repo1.ID, contractors.ID, *models.SecretReadPlaintextOperation, false)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "reading secret plaintext can not be overridden")
+
+		_, err = app.PermissionOverrideService.Set(ctx, nil, repo1.ID, contractors.ID, *models.GroupCreateOperation, false)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "only repo operations can be overridden")
+
+		otherCompany := server_test.CreateCompanyLegalEntity(t, ctx, app, "other-co", "Other Co", "other@not-a-real-domain.com")
+		otherGroup, err := app.GroupService.ReadByName(ctx, nil, otherCompany.ID, models.UserStandardGroup.Name)
+		require.NoError(t, err)
+		_, err = app.PermissionOverrideService.Set(ctx, nil, repo1.ID, otherGroup.ID, *models.SecretReadOperation, false)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "groups must belong to the repo's owner")
+
+		externalID := models.NewExternalResourceID(models.TestsSystem, "team-synced")
+		syncedGroup, _, err := app.GroupService.FindOrCreateByName(ctx, nil, models.NewGroup(now, company.ID, "team-synced", "Synced team", false, &externalID))
+		require.NoError(t, err)
+		_, err = app.PermissionOverrideService.Set(ctx, nil, repo1.ID, syncedGroup.ID, *models.SecretReadOperation, false)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "permissions of groups managed by an SCM can not be overridden")
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		checkAuthorized(repo1, models.BuildCreateOperation, true)
+		checkAuthorized(repo1, models.RepoReadOperation, true)
+		require.ElementsMatch(t, []models.RepoID{repo1.ID, repo2.ID}, visibleRepos())
+
+		buildDeny, err := app.PermissionOverrideService.Set(ctx, nil, repo1.ID, contractors.ID, *models.BuildCreateOperation, true)
+		require.NoError(t, err)
+		require.True(t, buildDeny.Deny)
+		readDeny, err := app.PermissionOverrideService.Set(ctx, nil, repo2.ID, contractors.ID, *models.RepoReadOperation, true)
+		require.NoError(t, err)
+
+		// A deny on the repo wins over the allow the user group holds on the company
+		checkAuthorized(repo1, models.BuildCreateOperation, false)
+		checkAuthorized(repo2, models.BuildCreateOperation, true)
+		checkAuthorized(repo2, models.RepoReadOperation, false)
+		require.ElementsMatch(t, []models.RepoID{repo1.ID}, visibleRepos())
+
+		operations, err := app.AuthorizationService.ListEffectivePermissions(ctx, nil, bobIdentity.ID, repo1.ID.ResourceID)
+		require.NoError(t, err)
+		require.Contains(t, operations, models.RepoReadOperation)
+		require.NotContains(t, operations, models.BuildCreateOperation)
+
+		overrides, _, err := app.PermissionOverrideService.ListByRepoID(ctx, nil, repo1.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+		require.NoError(t, err)
+		require.Len(t, overrides, 1)
+		require.Equal(t, buildDeny.ID, overrides[0].ID)
+
+		err = app.PermissionOverrideService.Delete(ctx, nil, buildDeny.ID)
+		require.NoError(t, err)
+		err = app.PermissionOverrideService.Delete(ctx, nil, readDeny.ID)
+		require.NoError(t, err)
+		checkAuthorized(repo1, models.BuildCreateOperation, true)
+		require.ElementsMatch(t, []models.RepoID{repo1.ID, repo2.ID}, visibleRepos())
+	})
+
+	t.Run("Allow", func(t *testing.T) {
+		checkAuthorized(repo1, models.ArtifactDeleteOperation, false)
+
+		allow, err := app.PermissionOverrideService.Set(ctx, nil, repo1.ID, contractors.ID, *models.ArtifactDeleteOperation, false)
+		require.NoError(t, err)
+		checkAuthorized(repo1, models.ArtifactDeleteOperation, true)
+		checkAuthorized(repo2, models.ArtifactDeleteOperation, false)
+
+		// Changing an override to a deny updates the existing override
+		deny, err := app.PermissionOverrideService.Set(ctx, nil, repo1.ID, contractors.ID, *models.ArtifactDeleteOperation, true)
+		require.NoError(t, err)
+		require.Equal(t, allow.ID, deny.ID)
+		require.True(t, deny.Deny)
+		checkAuthorized(repo1, models.ArtifactDeleteOperation, false)
+
+		err = app.PermissionOverrideService.Delete(ctx, nil, deny.ID)
+		require.NoError(t, err)
+		checkAuthorized(repo1, models.ArtifactDeleteOperation, false)
+
+		// Deleting is idempotent
+		err = app.PermissionOverrideService.Delete(ctx, nil, deny.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("DenyOnSameResourceWins", func(t *testing.T) {
+		// Bob's direct grant and his group's deny are both on repo1, so the deny takes precedence
+		err := app.AuthorizationService.CreateGrantsForIdentity(ctx, nil, company.ID, bobIdentity.ID,
+			[]*models.Operation{models.BuildPrioritizeOperation}, repo1.ID.ResourceID)
+		require.NoError(t, err)
+		checkAuthorized(repo1, models.BuildPrioritizeOperation, true)
+
+		deny, err := app.PermissionOverrideService.Set(ctx, nil, repo1.ID, contractors.ID, *models.BuildPrioritizeOperation, true)
+		require.NoError(t, err)
+		checkAuthorized(repo1, models.BuildPrioritizeOperation, false)
+
+		err = app.PermissionOverrideService.Delete(ctx, nil, deny.ID)
+		require.NoError(t, err)
+		checkAuthorized(repo1, models.BuildPrioritizeOperation, true)
+	})
+}
@@ -0,0 +1,201 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// conditionEvaluator decides whether the jobs and steps being enqueued for a build should be skipped, by
+// evaluating their conditions. Information about the build that conditions refer to is only loaded if a
+// condition needs it, and is loaded at most once per build.
+type conditionEvaluator struct {
+	ctx   context.Context
+	tx    *store.Tx
+	queue *QueueService
+	build *models.Build
+	// conditionCtx is the context conditions are evaluated in, or nil if it has not been loaded yet.
+	conditionCtx *parser.ConditionContext
+	changedFiles []string
+	// changedFilesErr is the error encountered while loading changed files, if any.
+	changedFilesErr    error
+	changedFilesLoaded bool
+}
+
+func (s *QueueService) newConditionEvaluator(ctx context.Context, tx *store.Tx, build *models.Build) *conditionEvaluator {
+	return &conditionEvaluator{
+		ctx:   ctx,
+		tx:    tx,
+		queue: s,
+		build: build,
+	}
+}
+
+// applyJobCondition sets the status of a new job and all its steps to skipped if the job's condition is not met,
+// if none of the commit's changed files pass the job's path filters, or if the job depends on a job that was skipped. Otherwise, applies the conditions of each of the job's steps.
+// skippedJobs records the jobs in the build that have been skipped, and must include all of the job's dependencies
+// that were skipped. If a condition can't be evaluated the job is marked as failed. Changes are made *in-memory*;
+// the caller is responsible for subsequently persisting the job and its steps.
+func (e *conditionEvaluator) applyJobCondition(job *dto.JobGraph, skippedJobs map[models.NodeFQN]bool) error {
+	skip := false
+	for _, dependency := range job.Depends {
+		if skippedJobs[dependency.GetFQN()] {
+			skip = true
+		}
+	}
+	if !skip && job.Condition != "" {
+		met, err := e.evaluate(job.Condition, job.Job)
+		if err != nil {
+			e.failJob(job, err)
+			return nil
+		}
+		skip = !met
+	}
+	if !skip && (len(job.OnlyPaths) > 0 || len(job.IgnorePaths) > 0) {
+		skip = !e.pathFiltersMatch(job.Job)
+	}
+	if skip {
+		job.Status = models.WorkflowStatusSkipped
+		for _, step := range job.Steps {
+			step.Status = models.WorkflowStatusSkipped
+		}
+		return nil
+	}
+
+	skippedSteps := make(map[models.ResourceName]bool)
+	return job.Walk(false, func(step *models.Step) error {
+		skip := false
+		for _, dependency := range step.Depends {
+			if skippedSteps[dependency.StepName] {
+				skip = true
+			}
+		}
+		if !skip && step.Condition != "" {
+			met, err := e.evaluate(step.Condition, job.Job)
+			if err != nil {
+				e.failJob(job, fmt.Errorf("error in step %q: %w", step.Name, err))
+				return nil
+			}
+			skip = !met
+		}
+		if skip {
+			step.Status = models.WorkflowStatusSkipped
+			skippedSteps[step.Name] = true
+		}
+		return nil
+	})
+}
+
+// pathFiltersMatch returns true if the files changed by the commit being built pass the job's path filters.
+// If the changed files can't be determined the job is run, since skipping it could hide a broken build.
+func (e *conditionEvaluator) pathFiltersMatch(job *models.Job) bool {
+	changedFiles, err := e.getChangedFiles()
+	if err != nil {
+		e.queue.Warnf("Ignoring path filters for job %q in build %q; unable to determine changed files: %v", job.Name, e.build.ID, err)
+		return true
+	}
+	matched, err := parser.PathFiltersMatch(job.OnlyPaths, job.IgnorePaths, changedFiles)
+	if err != nil {
+		e.queue.Warnf("Ignoring path filters for job %q in build %q: %v", job.Name, e.build.ID, err)
+		return true
+	}
+	return matched
+}
+
+// failJob marks a job and any of its steps that have not been skipped as failed with the specified error.
+func (e *conditionEvaluator) failJob(job *dto.JobGraph, err error) {
+	job.Status = models.WorkflowStatusFailed
+	job.Error = models.NewError(err)
+	for _, step := range job.Steps {
+		if step.Status != models.WorkflowStatusSkipped {
+			step.Status = models.WorkflowStatusFailed
+			// NOTE intentionally do not set step error here, as it just duplicates the job error
+		}
+	}
+}
+
+// evaluate returns true if the specified condition is met for a job in the build.
+func (e *conditionEvaluator) evaluate(expr string, job *models.Job) (bool, error) {
+	condition, err := parser.ParseCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	conditionCtx, err := e.getConditionContext()
+	if err != nil {
+		return false, err
+	}
+	jobCtx := *conditionCtx
+	jobCtx.Env = make(map[string]string, len(job.Environment))
+	for _, envVar := range job.Environment {
+		// Secrets are not available when jobs are enqueued, so only variables with explicit values can be tested
+		if envVar.ValueFromSecret == "" {
+			jobCtx.Env[envVar.Name] = envVar.Value
+		}
+	}
+	return condition.Evaluate(&jobCtx)
+}
+
+// getConditionContext returns the context that conditions for jobs in the build are evaluated in.
+func (e *conditionEvaluator) getConditionContext() (*parser.ConditionContext, error) {
+	if e.conditionCtx != nil {
+		return e.conditionCtx, nil
+	}
+	ref := e.build.Ref
+	conditionCtx := &parser.ConditionContext{
+		Ref:          ref,
+		ChangedFiles: e.getChangedFiles,
+	}
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		conditionCtx.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	case strings.HasPrefix(ref, "refs/tags/"):
+		conditionCtx.Tag = strings.TrimPrefix(ref, "refs/tags/")
+	}
+	if strings.HasPrefix(ref, "refs/pull/") {
+		conditionCtx.PullRequest = true
+	} else if ref != "" {
+		_, err := e.queue.pullRequestStore.ReadOpenByHeadRef(e.ctx, e.tx, e.build.RepoID, ref)
+		if err != nil && !gerror.IsNotFound(err) {
+			return nil, fmt.Errorf("error reading pull request for ref %q: %w", ref, err)
+		}
+		conditionCtx.PullRequest = err == nil
+	}
+	e.conditionCtx = conditionCtx
+	return conditionCtx, nil
+}
+
+// getChangedFiles returns the paths of the files changed by the commit being built, reading them from
+// the SCM hosting the build's repo.
+func (e *conditionEvaluator) getChangedFiles() ([]string, error) {
+	if !e.changedFilesLoaded {
+		e.changedFiles, e.changedFilesErr = e.loadChangedFiles()
+		e.changedFilesLoaded = true
+	}
+	return e.changedFiles, e.changedFilesErr
+}
+
+func (e *conditionEvaluator) loadChangedFiles() ([]string, error) {
+	commit, err := e.queue.commitStore.Read(e.ctx, e.tx, e.build.CommitID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit: %w", err)
+	}
+	repo, err := e.queue.repoService.Read(e.ctx, e.tx, e.build.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	if repo.ExternalID == nil {
+		return nil, fmt.Errorf("error repo %q is not hosted by an SCM", repo.Name)
+	}
+	scmName := repo.ExternalID.ExternalSystem
+	externalSCM, err := e.queue.scmRegistry.Get(scmName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SCM from registry for %q: %w", scmName, err)
+	}
+	return externalSCM.GetChangedFiles(e.ctx, repo, commit.SHA)
+}
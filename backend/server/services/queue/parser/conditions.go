@@ -0,0 +1,423 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/pkg/errors"
+)
+
+// ConditionContext provides the values that variables in a condition expression are evaluated against.
+type ConditionContext struct {
+	// Branch is the name of the branch being built, or empty if the build is not for a branch.
+	Branch string
+	// Tag is the name of the tag being built, or empty if the build is not for a tag.
+	Tag string
+	// Ref is the full git ref being built, e.g. 'refs/heads/main'.
+	Ref string
+	// PullRequest is true if the build is for a pull request.
+	PullRequest bool
+	// Env contains the (non-secret) environment variables of the job the condition applies to.
+	Env map[string]string
+	// ChangedFiles returns the paths of the files changed by the commit being built. It is only called if
+	// the condition refers to 'changed_files'.
+	ChangedFiles func() ([]string, error)
+}
+
+type conditionValueType string
+
+const (
+	conditionTypeString conditionValueType = "string"
+	conditionTypeBool   conditionValueType = "boolean"
+	conditionTypeList   conditionValueType = "list"
+)
+
+// conditionVariables lists the type of each variable that can be referred to in a condition expression.
+// Environment variables are referred to as 'env.NAME' and are always strings.
+var conditionVariables = map[string]conditionValueType{
+	"branch":        conditionTypeString,
+	"tag":           conditionTypeString,
+	"ref":           conditionTypeString,
+	"pull_request":  conditionTypeBool,
+	"changed_files": conditionTypeList,
+}
+
+// Condition is a parsed 'if' expression that determines whether a job or step should run. Expressions can
+// compare variables using '==', '!=' and 'matches' (a glob match, true for a list if any element matches),
+// and can be combined using '&&', '||', '!' and parentheses, e.g.
+// branch == "main" && (pull_request || changed_files matches "docs/**")
+type Condition struct {
+	expr string
+	root conditionNode
+}
+
+// ParseCondition parses and type-checks a condition expression.
+func ParseCondition(expr string) (*Condition, error) {
+	tokens, err := lexCondition(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing condition %q", expr)
+	}
+	p := &conditionParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != conditionTokenEnd {
+		err = errors.Errorf("unexpected %s", p.peek())
+	}
+	if err == nil && root.valueType() != conditionTypeBool {
+		err = errors.Errorf("condition must be a boolean expression but found a %s", root.valueType())
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing condition %q", expr)
+	}
+	return &Condition{expr: expr, root: root}, nil
+}
+
+// String returns the source of the condition expression.
+func (c *Condition) String() string {
+	return c.expr
+}
+
+// Evaluate returns true if the condition is met in the specified context.
+func (c *Condition) Evaluate(ctx *ConditionContext) (bool, error) {
+	value, err := c.root.evaluate(ctx)
+	if err != nil {
+		return false, errors.Wrapf(err, "error evaluating condition %q", c.expr)
+	}
+	return value.boolean, nil
+}
+
+type conditionValue struct {
+	str     string
+	boolean bool
+	list    []string
+}
+
+// equal returns true if two string or boolean values are equal. Lists can't be compared.
+func (v conditionValue) equal(other conditionValue) bool {
+	return v.str == other.str && v.boolean == other.boolean
+}
+
+type conditionNode interface {
+	valueType() conditionValueType
+	evaluate(ctx *ConditionContext) (conditionValue, error)
+}
+
+type conditionLiteral struct {
+	typ   conditionValueType
+	value conditionValue
+}
+
+func (n *conditionLiteral) valueType() conditionValueType {
+	return n.typ
+}
+
+func (n *conditionLiteral) evaluate(ctx *ConditionContext) (conditionValue, error) {
+	return n.value, nil
+}
+
+type conditionVariable struct {
+	name string
+}
+
+func (n *conditionVariable) valueType() conditionValueType {
+	if strings.HasPrefix(n.name, "env.") {
+		return conditionTypeString
+	}
+	return conditionVariables[n.name]
+}
+
+func (n *conditionVariable) evaluate(ctx *ConditionContext) (conditionValue, error) {
+	if strings.HasPrefix(n.name, "env.") {
+		return conditionValue{str: ctx.Env[strings.TrimPrefix(n.name, "env.")]}, nil
+	}
+	switch n.name {
+	case "branch":
+		return conditionValue{str: ctx.Branch}, nil
+	case "tag":
+		return conditionValue{str: ctx.Tag}, nil
+	case "ref":
+		return conditionValue{str: ctx.Ref}, nil
+	case "pull_request":
+		return conditionValue{boolean: ctx.PullRequest}, nil
+	case "changed_files":
+		if ctx.ChangedFiles == nil {
+			return conditionValue{}, errors.New("changed files are not available for this build")
+		}
+		files, err := ctx.ChangedFiles()
+		if err != nil {
+			return conditionValue{}, errors.Wrap(err, "error getting changed files")
+		}
+		return conditionValue{list: files}, nil
+	default:
+		return conditionValue{}, errors.Errorf("unknown variable %q", n.name)
+	}
+}
+
+type conditionNot struct {
+	operand conditionNode
+}
+
+func (n *conditionNot) valueType() conditionValueType {
+	return conditionTypeBool
+}
+
+func (n *conditionNot) evaluate(ctx *ConditionContext) (conditionValue, error) {
+	value, err := n.operand.evaluate(ctx)
+	if err != nil {
+		return conditionValue{}, err
+	}
+	return conditionValue{boolean: !value.boolean}, nil
+}
+
+type conditionBinary struct {
+	op          string
+	left, right conditionNode
+}
+
+func (n *conditionBinary) valueType() conditionValueType {
+	return conditionTypeBool
+}
+
+func (n *conditionBinary) evaluate(ctx *ConditionContext) (conditionValue, error) {
+	left, err := n.left.evaluate(ctx)
+	if err != nil {
+		return conditionValue{}, err
+	}
+	// Short-circuit logical operators so that e.g. changed files are only fetched if needed
+	switch {
+	case n.op == "&&" && !left.boolean:
+		return conditionValue{boolean: false}, nil
+	case n.op == "||" && left.boolean:
+		return conditionValue{boolean: true}, nil
+	}
+	right, err := n.right.evaluate(ctx)
+	if err != nil {
+		return conditionValue{}, err
+	}
+	switch n.op {
+	case "&&", "||":
+		return conditionValue{boolean: right.boolean}, nil
+	case "==":
+		return conditionValue{boolean: left.equal(right)}, nil
+	case "!=":
+		return conditionValue{boolean: !left.equal(right)}, nil
+	case "matches":
+		candidates := left.list
+		if n.left.valueType() == conditionTypeString {
+			candidates = []string{left.str}
+		}
+		for _, candidate := range candidates {
+			matched, err := doublestar.Match(right.str, candidate)
+			if err != nil {
+				return conditionValue{}, errors.Wrapf(err, "invalid glob %q", right.str)
+			}
+			if matched {
+				return conditionValue{boolean: true}, nil
+			}
+		}
+		return conditionValue{boolean: false}, nil
+	default:
+		return conditionValue{}, errors.Errorf("unknown operator %q", n.op)
+	}
+}
+
+type conditionTokenKind string
+
+const (
+	conditionTokenEnd        conditionTokenKind = "end of expression"
+	conditionTokenIdentifier conditionTokenKind = "identifier"
+	conditionTokenString     conditionTokenKind = "string"
+	conditionTokenOperator   conditionTokenKind = "operator"
+)
+
+type conditionToken struct {
+	kind  conditionTokenKind
+	value string
+}
+
+func (t conditionToken) String() string {
+	if t.kind == conditionTokenEnd {
+		return string(t.kind)
+	}
+	return fmt.Sprintf("%s %q", t.kind, t.value)
+}
+
+// lexCondition splits a condition expression into tokens.
+func lexCondition(expr string) ([]conditionToken, error) {
+	var tokens []conditionToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, conditionToken{kind: conditionTokenString, value: string(runes[i+1 : end])})
+			i = end + 1
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || strings.ContainsRune("_.-", runes[end])) {
+				end++
+			}
+			word := string(runes[i:end])
+			kind := conditionTokenIdentifier
+			if word == "matches" {
+				kind = conditionTokenOperator
+			}
+			tokens = append(tokens, conditionToken{kind: kind, value: word})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "&&", "||", "!", "(", ")"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, conditionToken{kind: conditionTokenOperator, value: op})
+			i += len(op)
+		}
+	}
+	return append(tokens, conditionToken{kind: conditionTokenEnd}), nil
+}
+
+// conditionParser is a recursive descent parser for condition expressions.
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+func (p *conditionParser) peek() conditionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() conditionToken {
+	token := p.tokens[p.pos]
+	if token.kind != conditionTokenEnd {
+		p.pos++
+	}
+	return token
+}
+
+func (p *conditionParser) acceptOperator(op string) bool {
+	if token := p.peek(); token.kind == conditionTokenOperator && token.value == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	return p.parseLogical("&&", p.parseUnary)
+}
+
+func (p *conditionParser) parseLogical(op string, parseOperand func() (conditionNode, error)) (conditionNode, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOperator(op) {
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		for _, operand := range []conditionNode{left, right} {
+			if operand.valueType() != conditionTypeBool {
+				return nil, errors.Errorf("operands of '%s' must be boolean but found a %s", op, operand.valueType())
+			}
+		}
+		left = &conditionBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseUnary() (conditionNode, error) {
+	if p.acceptOperator("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.valueType() != conditionTypeBool {
+			return nil, errors.Errorf("operand of '!' must be boolean but found a %s", operand.valueType())
+		}
+		return &conditionNot{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (conditionNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	token := p.peek()
+	if token.kind != conditionTokenOperator || (token.value != "==" && token.value != "!=" && token.value != "matches") {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if token.value == "matches" {
+		if left.valueType() == conditionTypeBool {
+			return nil, errors.Errorf("left operand of 'matches' must be a string or list but found a %s", left.valueType())
+		}
+		glob, ok := right.(*conditionLiteral)
+		if !ok || right.valueType() != conditionTypeString {
+			return nil, errors.New("right operand of 'matches' must be a quoted glob pattern")
+		}
+		// Matching the pattern against itself makes the matcher read the whole pattern, so syntax errors are found
+		if _, err := doublestar.Match(glob.value.str, glob.value.str); err != nil {
+			return nil, errors.Wrapf(err, "invalid glob %q", glob.value.str)
+		}
+	} else if left.valueType() == conditionTypeList || left.valueType() != right.valueType() {
+		return nil, errors.Errorf("cannot compare a %s with a %s using '%s'", left.valueType(), right.valueType(), token.value)
+	}
+	return &conditionBinary{op: token.value, left: left, right: right}, nil
+}
+
+func (p *conditionParser) parsePrimary() (conditionNode, error) {
+	token := p.next()
+	switch token.kind {
+	case conditionTokenString:
+		return &conditionLiteral{typ: conditionTypeString, value: conditionValue{str: token.value}}, nil
+	case conditionTokenIdentifier:
+		switch token.value {
+		case "true", "false":
+			return &conditionLiteral{typ: conditionTypeBool, value: conditionValue{boolean: token.value == "true"}}, nil
+		}
+		variable := &conditionVariable{name: token.value}
+		if variable.valueType() == "" || token.value == "env." {
+			return nil, errors.Errorf("unknown variable %q", token.value)
+		}
+		return variable, nil
+	case conditionTokenOperator:
+		if token.value == "(" {
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.acceptOperator(")") {
+				return nil, errors.Errorf("expected ')' but found %s", p.peek())
+			}
+			return node, nil
+		}
+	}
+	return nil, errors.Errorf("unexpected %s", token)
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError describes a problem with a build definition, including the location of the element that caused it.
+type ParseError struct {
+	// File is the path of the template containing the problem, or empty if the problem is in the build
+	// definition itself.
+	File string
+	// Line is the 1-based line number of the element that caused the problem, or 0 if unknown.
+	Line int
+	// Column is the 1-based column number of the element that caused the problem, or 0 if unknown.
+	Column int
+	// Path is the path to the element that caused the problem, e.g. 'jobs[1].steps[0].commands'.
+	Path string
+	// Err is the underlying error.
+	Err error
+}
+
+// Location returns a human-readable description of where the problem is, e.g. 'ci/go.yml line 12, column 7'.
+func (e *ParseError) Location() string {
+	var parts []string
+	if e.File != "" {
+		parts = append(parts, e.File)
+	}
+	if e.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d, column %d", e.Line, e.Column))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e *ParseError) Error() string {
+	var parts []string
+	if location := e.Location(); location != "" {
+		parts = append(parts, location)
+	}
+	if e.Path != "" {
+		parts = append(parts, e.Path)
+	}
+	parts = append(parts, e.Err.Error())
+	return strings.Join(parts, ": ")
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// pathError records the path to the element of a build definition that caused an error. Each segment is either
+// a string (an object field name) or an int (an array index). Wrapping errors in pathErrors at each level of
+// parsing builds up the full path, which can be read back using errorPath.
+type pathError struct {
+	segments []interface{}
+	err      error
+}
+
+// atPath records that err was caused by the element at the specified path, relative to the element being parsed.
+// Returns nil if err is nil.
+func atPath(err error, segments ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &pathError{segments: segments, err: err}
+}
+
+func (e *pathError) Error() string {
+	return e.err.Error()
+}
+
+func (e *pathError) Unwrap() error {
+	return e.err
+}
+
+// errorPath returns the full path to the element that caused err, or nil if err doesn't record a path.
+// Paths recorded within a ParseError have already been located, so are ignored.
+func errorPath(err error) []interface{} {
+	var path []interface{}
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch e := err.(type) {
+		case *pathError:
+			path = append(path, e.segments...)
+		case *ParseError:
+			return path
+		}
+	}
+	return path
+}
+
+// AsParseError returns the most specific ParseError describing err, or nil if the location of the problem
+// is not known.
+func AsParseError(err error) *ParseError {
+	var parseErr *ParseError
+	for ; err != nil; err = errors.Unwrap(err) {
+		if e, ok := err.(*ParseError); ok {
+			parseErr = e
+		}
+	}
+	return parseErr
+}
+
+// formatPath formats a path to an element, e.g. 'jobs[1].steps[0].commands'.
+func formatPath(path []interface{}) string {
+	var sb strings.Builder
+	for _, segment := range path {
+		switch v := segment.(type) {
+		case int:
+			sb.WriteString("[" + strconv.Itoa(v) + "]")
+		default:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(fmt.Sprintf("%v", v))
+		}
+	}
+	return sb.String()
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+// positionIndex maps the formatted path of each element in a build definition to the element's position
+// in the source file.
+type positionIndex map[string]position
+
+// newPositionIndex returns an index of the positions of elements in a YAML or JSON build definition,
+// or nil if the source can't be parsed.
+func newPositionIndex(config []byte) positionIndex {
+	var root yaml.Node
+	err := yaml.Unmarshal(config, &root)
+	if err != nil || len(root.Content) == 0 {
+		return nil
+	}
+	index := make(positionIndex)
+	index.add(nil, root.Content[0])
+	return index
+}
+
+func (i positionIndex) add(path []interface{}, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			key, value := node.Content[j], node.Content[j+1]
+			childPath := append(append([]interface{}{}, path...), key.Value)
+			// Use the position of the key rather than the value, since values can start on a following line
+			i[formatPath(childPath)] = position{line: key.Line, column: key.Column}
+			i.add(childPath, value)
+		}
+	case yaml.SequenceNode:
+		for j, item := range node.Content {
+			childPath := append(append([]interface{}{}, path...), j)
+			i[formatPath(childPath)] = position{line: item.Line, column: item.Column}
+			i.add(childPath, item)
+		}
+	}
+}
+
+// locate returns the position of the element at the specified path, or of its closest ancestor that can be
+// found if the element itself can't be (e.g. because it was missing). Returns a zero position if nothing
+// on the path can be found.
+func (i positionIndex) locate(path []interface{}) position {
+	for n := len(path); n > 0; n-- {
+		if pos, ok := i[formatPath(path[:n])]; ok {
+			return pos
+		}
+	}
+	return position{}
+}
@@ -3,12 +3,16 @@ package parser
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/google/go-jsonnet"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 )
 
@@ -53,8 +57,51 @@ var (
 	jobDependsOnJobRegex                          = regexp.MustCompile(`(?im)^(?:workflow\.([a-zA-Z0-9_-]+)\.)?jobs\.([a-zA-Z0-9_*-]+)$`)
 	jobDependsOnAllArtifactsFromJobShorthandRegex = regexp.MustCompile(`(?im)^(?:([a-zA-Z0-9_-]+)\.)?([a-zA-Z0-9_*-]+)\.artifacts$`)
 	jobDependsOnJobShorthandRegex                 = regexp.MustCompile(`(?im)^(?:([a-zA-Z0-9_-]+)\.)?([a-zA-Z0-9_*-]+)$`)
+
+	// Artifacts from previous builds are referenced by an optional repo name, followed by a mandatory build name
+	// (the build number, optionally prefixed with 'build-'), then optionally an artifact group name.
+	// Examples: 'build-123', 'my-repo@build-123', 'my-repo@123:go-binaries'
+	artifactFromBuildRegex = regexp.MustCompile(`(?i)^(?:([a-zA-Z0-9_-]+)@)?(?:build-)?([0-9]+)(?::([a-zA-Z0-9_-]+))?$`)
 )
 
+// ConfigTypeForFileName returns the type of build config that a file with the specified name contains, based on
+// the file's extension, or ConfigTypeInvalid if the extension is not recognised.
+func ConfigTypeForFileName(name string) models.ConfigType {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return models.ConfigTypeYAML
+	case ".json":
+		return models.ConfigTypeJSON
+	case ".jsonnet":
+		return models.ConfigTypeJSONNET
+	default:
+		return models.ConfigTypeInvalid
+	}
+}
+
+// FindBuildConfigFile looks for a build config file in dir, which should be the root of a git repo. Returns the
+// name of the first file found (in directory order) with one of the recognised build config file names, and the
+// type of config it contains, or a not found error if there is no build config file in dir.
+func FindBuildConfigFile(dir string) (string, models.ConfigType, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", models.ConfigTypeNoConfig, errors.Wrap(err, "error listing files")
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		for _, names := range [][]string{YAMLBuildConfigFileNames, JSONBuildConfigFileNames, JSONNETBuildConfigFileNames} {
+			for _, name := range names {
+				if file.Name() == name {
+					return name, ConfigTypeForFileName(name), nil
+				}
+			}
+		}
+	}
+	return "", models.ConfigTypeNoConfig, gerror.NewErrNotFound("Unable to locate buildbeaver config file in root of repo")
+}
+
 // buildDefinitionVersionedParser is an object capable of parsing a specific version of a build definition.
 type buildDefinitionVersionedParser interface {
 	Parse(topLevelElement map[string]interface{}) (*models.BuildDefinition, error)
@@ -63,13 +110,31 @@ type buildDefinitionVersionedParser interface {
 // ParserLimits provides a parser with information on limits to check while parsing. If the data goes beyond
 // any limit then parsing should fail.
 type ParserLimits struct {
-	// MaxJobsPerBuild is the maximum number of steps allowed in any single job. Any build definition containing
+	// MaxStepsPerJob is the maximum number of steps allowed in any single job. Any build definition containing
 	// a job with more than this number of steps will be rejected.
 	MaxStepsPerJob int
 }
 
+// checkStepCount returns a limit exceeded error identifying the job if stepCount is more than the maximum
+// number of steps allowed in a job.
+func (l ParserLimits) checkStepCount(job *models.JobDefinition, stepCount int) error {
+	if l.MaxStepsPerJob <= 0 || stepCount <= l.MaxStepsPerJob {
+		return nil
+	}
+	fqn := models.NewNodeFQNForJob(job.Workflow, job.Name)
+	return gerror.NewErrLimitExceeded(
+		fmt.Sprintf("Too many steps in job '%s'; the job has %d steps but a maximum of %d steps are allowed in each job, so split it into smaller jobs",
+			fqn.String(), stepCount, l.MaxStepsPerJob),
+		"max_steps_per_job",
+		l.MaxStepsPerJob,
+		stepCount).
+		EDetail(gerror.WorkflowDetailKey, job.Workflow.String()).
+		EDetail(gerror.JobDetailKey, job.Name.String())
+}
+
 type BuildDefinitionParser struct {
-	limits ParserLimits
+	limits         ParserLimits
+	templateLoader TemplateLoader
 }
 
 func NewBuildDefinitionParser(limits ParserLimits) *BuildDefinitionParser {
@@ -78,6 +143,15 @@ func NewBuildDefinitionParser(limits ParserLimits) *BuildDefinitionParser {
 	}
 }
 
+// NewBuildDefinitionParserWithTemplates returns a parser that supports build definitions referencing templates
+// via 'include' and 'extends' elements, using templateLoader to load the templates.
+func NewBuildDefinitionParserWithTemplates(limits ParserLimits, templateLoader TemplateLoader) *BuildDefinitionParser {
+	return &BuildDefinitionParser{
+		limits:         limits,
+		templateLoader: templateLoader,
+	}
+}
+
 // Parse parses a raw build config.
 func (s *BuildDefinitionParser) Parse(config []byte, configType models.ConfigType) (*models.BuildDefinition, error) {
 	var (
@@ -109,39 +183,57 @@ func (s *BuildDefinitionParser) Parse(config []byte, configType models.ConfigTyp
 		return nil, errors.Errorf("error parsing build definition: must contain a top-level object: %T", topLevelElement)
 	}
 
-	const defaultVersion = "DEFAULT_VERSION"
-	version := defaultVersion
+	// Expand any templates before parsing, so that the versioned parsers only ever see a complete set of jobs
+	source := jobSource{config: config, configType: configType}
+	topLevelElement, jobSources, err := s.resolveTemplates(topLevelElement, source)
+	if err != nil {
+		return nil, source.locate(err)
+	}
+
+	var requestedVersion string
 	rVersion, ok := topLevelElement["version"]
 	if ok {
 		// normalizeMapValues() turns all scalar data types into strings, including float/integer version numbers
-		version, ok = rVersion.(string)
+		requestedVersion, ok = rVersion.(string)
 		if !ok {
 			return nil, errors.Errorf("error parsing build definition: expected 'version' field to be a string but found: %T", rVersion)
 		}
 	}
 
-	// Create a parser specific to the version to parse the rest of the data
-	var parser buildDefinitionVersionedParser
-	switch version {
-	case "0.2", defaultVersion:
-		parser = newBuildDefinitionParserV02(s.limits)
-	case "0.3":
-		parser = newBuildDefinitionParserV03(s.limits)
-	case "1.0", "1":
-		// TODO: Before release, define version 1.0 as the latest and make this the default by moving defaultVersion to this line
-		return nil, errors.Errorf("error parsing build definition: version %s has not been defined in pre-release version", version)
-	default:
-		return nil, errors.Errorf("error parsing build definition: version %s not supported", version)
+	// Choose a parser specific to the version to parse the rest of the data
+	versionName, version, warnings, err := negotiateVersion(requestedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing build definition: %w", source.locate(atPath(err, "version")))
 	}
-
-	build, err = parser.Parse(topLevelElement)
+	build, err = version.newParser(s.limits).Parse(topLevelElement)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing build definition: %w", err)
+		return nil, fmt.Errorf("error parsing build definition: %w", s.locateError(err, source, jobSources))
+	}
+
+	build.Version = versionName
+	build.Warnings = warnings
+	for _, deprecated := range findDeprecatedFields(topLevelElement, version) {
+		build.Warnings = append(build.Warnings, s.locateError(deprecated, source, jobSources).Error())
 	}
 
 	return build, nil
 }
 
+// locateError returns a ParseError reporting the file and position of the element that caused err, if err
+// records the path to the element. jobSources contains the source of each job if the build definition
+// included jobs from templates, or nil otherwise.
+func (s *BuildDefinitionParser) locateError(err error, source jobSource, jobSources []jobSource) error {
+	path := errorPath(err)
+	if len(path) >= 2 && path[0] == "jobs" {
+		// Report errors in jobs against the file the job came from
+		if i, ok := path[1].(int); ok && i < len(jobSources) {
+			source = jobSources[i]
+			path = append([]interface{}{"jobs", source.index}, path[2:]...)
+		}
+	}
+	return source.locatePath(err, path)
+}
+
 func (s *BuildDefinitionParser) parseFromYAML(config []byte) (interface{}, error) {
 	var raw interface{}
 	err := yaml.Unmarshal(config, &raw)
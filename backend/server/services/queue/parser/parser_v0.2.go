@@ -5,7 +5,6 @@ import (
 	"regexp"
 	"strconv"
 
-	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/pkg/errors"
 
 	"github.com/buildbeaver/buildbeaver/common/models"
@@ -154,6 +153,18 @@ func (s *buildDefinitionParserV02) parseJob(raw map[string]interface{}) (*models
 			}
 		}
 
+		rToolchain, ok := docker["toolchain"]
+		if ok {
+			toolchain, ok := rToolchain.(string)
+			if !ok {
+				return nil, errors.Errorf("Expected job 'docker.toolchain' field to be a string but found: %T", rToolchain)
+			}
+			if _, err := models.ParseToolchainReference(toolchain); err != nil {
+				return nil, err
+			}
+			job.DockerToolchain = toolchain
+		}
+
 		rPull := docker["pull"]
 		err := job.DockerImagePullStrategy.Scan(rPull) // handles the default case if pull is not set
 		if err != nil {
@@ -248,6 +259,10 @@ func (s *buildDefinitionParserV02) parseJob(raw map[string]interface{}) (*models
 		if !ok {
 			return nil, errors.Errorf("Expected steps to be an array of step objects but found %T", rSteps)
 		}
+		err := s.limits.checkStepCount(job, len(value))
+		if err != nil {
+			return nil, err
+		}
 		for i, obj := range value {
 			element, ok := obj.(map[string]interface{})
 			if !ok {
@@ -258,11 +273,6 @@ func (s *buildDefinitionParserV02) parseJob(raw map[string]interface{}) (*models
 				return nil, errors.Wrapf(err, "Error parsing step at index %d", i)
 			}
 			job.Steps = append(job.Steps, *step)
-			if s.limits.MaxStepsPerJob > 0 && len(job.Steps) > s.limits.MaxStepsPerJob {
-				return nil, gerror.NewErrValidationFailed(
-					fmt.Sprintf("too many steps in job '%s'; a maximum of %d steps are allowed in each job",
-						job.Name, s.limits.MaxStepsPerJob))
-			}
 		}
 	}
 
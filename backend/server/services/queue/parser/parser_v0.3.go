@@ -3,8 +3,8 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"time"
 
-	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/pkg/errors"
 )
@@ -35,31 +35,311 @@ func (s *buildDefinitionParserV03) Parse(topLevelElement map[string]interface{})
 	if err != nil {
 		return nil, err
 	}
-	build := &models.BuildDefinition{Jobs: jobs}
+	var stages []models.ResourceName
+	rStages, ok := topLevelElement["stages"]
+	if ok {
+		stages, err = s.parseStages(rStages)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing stages")
+		}
+	}
+	err = s.compileStages(stages, jobs)
+	if err != nil {
+		return nil, err
+	}
+	rWorkflows, ok := topLevelElement["workflows"]
+	if ok {
+		err = s.applyWorkflows(rWorkflows, jobs)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "error parsing workflows"), "workflows")
+		}
+	}
+	rEnvironment, ok := topLevelElement["environment"]
+	if ok {
+		environment, err := s.parseEnvironment(rEnvironment)
+		if err != nil {
+			return nil, atPath(err, "environment")
+		}
+		s.applyBuildEnvironment(environment, jobs)
+	}
+	var parameters []*models.BuildParameterDefinition
+	rParameters, ok := topLevelElement["parameters"]
+	if ok {
+		parameters, err = s.parseParameters(rParameters)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "error parsing parameters"), "parameters")
+		}
+	}
+	build := &models.BuildDefinition{Jobs: jobs, Parameters: parameters}
 	return build, nil
 }
 
+// applyBuildEnvironment adds the environment variables set in the top-level 'environment' element to every job.
+// Environment variables set on a job take precedence over those set for the build.
+func (s *buildDefinitionParserV03) applyBuildEnvironment(environment []*models.EnvVar, jobs []models.JobDefinition) {
+	for j := range jobs {
+		job := &jobs[j]
+		jobEnvNames := make(map[string]bool, len(job.Environment))
+		for _, envVar := range job.Environment {
+			jobEnvNames[envVar.Name] = true
+		}
+		var merged []*models.EnvVar
+		for _, envVar := range environment {
+			if !jobEnvNames[envVar.Name] {
+				copied := *envVar
+				merged = append(merged, &copied)
+			}
+		}
+		job.Environment = append(merged, job.Environment...)
+	}
+}
+
+// parseParameters parses the 'parameters' element, which declares the parameters that can be supplied when
+// triggering the build.
+func (s *buildDefinitionParserV03) parseParameters(raw interface{}) ([]*models.BuildParameterDefinition, error) {
+	rParametersArray, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("Expected 'parameters' element to be a list but found: %T", raw)
+	}
+	var parameters []*models.BuildParameterDefinition
+	seen := make(map[string]bool, len(rParametersArray))
+	for i, rParameter := range rParametersArray {
+		element, ok := rParameter.(map[string]interface{})
+		if !ok {
+			return nil, atPath(errors.Errorf("Expected parameter to be an object but found: %T", rParameter), i)
+		}
+		parameter := &models.BuildParameterDefinition{Type: models.BuildParameterTypeString}
+		for key, value := range element {
+			var err error
+			switch key {
+			case "name":
+				parameter.Name, err = s.parseParameterValue(value)
+			case "type":
+				var rType string
+				rType, err = s.parseParameterValue(value)
+				parameter.Type = models.BuildParameterType(rType)
+			case "description":
+				parameter.Description, err = s.parseParameterValue(value)
+			case "default":
+				var def string
+				def, err = s.parseParameterValue(value)
+				parameter.Default = &def
+			case "choices":
+				rChoices, ok := value.([]interface{})
+				if !ok {
+					return nil, atPath(errors.Errorf("Expected parameter 'choices' field to be a list but found: %T", value), i, key)
+				}
+				parameter.Choices, err = s.parseStringArray(rChoices)
+			default:
+				return nil, atPath(errors.Errorf("Unknown parameter field %q; expected name, type, description, default or choices", key), i, key)
+			}
+			if err != nil {
+				return nil, atPath(errors.Wrapf(err, "error parsing parameter '%s' field", key), i, key)
+			}
+		}
+		err := parameter.Validate()
+		if err != nil {
+			return nil, atPath(err, i)
+		}
+		if seen[parameter.Name] {
+			return nil, atPath(errors.Errorf("Found duplicate parameter %q", parameter.Name), i, "name")
+		}
+		seen[parameter.Name] = true
+		parameters = append(parameters, parameter)
+	}
+	return parameters, nil
+}
+
+// parseParameterValue converts the raw value of a parameter field to a string. YAML configs provide strings,
+// whereas JSON configs can also provide numbers and booleans.
+func (s *buildDefinitionParserV03) parseParameterValue(raw interface{}) (string, error) {
+	switch value := raw.(type) {
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		return "", errors.Errorf("Expected a string, number or boolean but found: %T", raw)
+	}
+}
+
+// applyWorkflows parses the 'workflows' element, which configures path filters for every job in a workflow,
+// and applies them to the jobs in each workflow. Path filters set on a job take precedence over those of its workflow.
+func (s *buildDefinitionParserV03) applyWorkflows(raw interface{}, jobs []models.JobDefinition) error {
+	rWorkflowsArray, ok := raw.([]interface{})
+	if !ok {
+		return errors.Errorf("Expected 'workflows' element to be a list but found: %T", raw)
+	}
+	seen := make(map[models.ResourceName]bool, len(rWorkflowsArray))
+	for i, rWorkflow := range rWorkflowsArray {
+		element, ok := rWorkflow.(map[string]interface{})
+		if !ok {
+			return atPath(errors.Errorf("Expected workflow to be an object but found: %T", rWorkflow), i)
+		}
+		rName, ok := element["name"].(string)
+		if !ok {
+			return atPath(errors.Errorf("Expected workflow 'name' field to be a string but found: %T", element["name"]), i, "name")
+		}
+		name := models.ResourceName(rName)
+		err := name.Validate()
+		if err != nil {
+			return atPath(errors.Wrapf(err, "error validating workflow name %q", rName), i, "name")
+		}
+		if seen[name] {
+			return atPath(errors.Errorf("Found duplicate workflow %q", rName), i, "name")
+		}
+		seen[name] = true
+		var onlyPaths, ignorePaths models.PathPatterns
+		if rOnlyPaths, ok := element["only_paths"]; ok {
+			onlyPaths, err = s.parsePathPatterns(rOnlyPaths)
+			if err != nil {
+				return atPath(errors.Wrap(err, "Unable to parse workflow 'only_paths' field"), i, "only_paths")
+			}
+		}
+		if rIgnorePaths, ok := element["ignore_paths"]; ok {
+			ignorePaths, err = s.parsePathPatterns(rIgnorePaths)
+			if err != nil {
+				return atPath(errors.Wrap(err, "Unable to parse workflow 'ignore_paths' field"), i, "ignore_paths")
+			}
+		}
+		for j := range jobs {
+			job := &jobs[j]
+			if job.Workflow != name {
+				continue
+			}
+			if job.OnlyPaths == nil {
+				job.OnlyPaths = onlyPaths
+			}
+			if job.IgnorePaths == nil {
+				job.IgnorePaths = ignorePaths
+			}
+		}
+	}
+	return nil
+}
+
 func (s *buildDefinitionParserV03) parseJobs(raw []interface{}) ([]models.JobDefinition, error) {
 	jobs := make([]models.JobDefinition, len(raw))
 	for i, obj := range raw {
 		element, ok := obj.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("Top-level element is not a job object: %T", obj)
+			return nil, atPath(errors.Errorf("Top-level element is not a job object: %T", obj), "jobs", i)
 		}
 		kind, ok := element["kind"]
 		if !ok || kind == "pipeline" || kind == "job" {
 			job, err := s.parseJob(element)
 			if err != nil {
-				return nil, errors.Wrapf(err, "error parsing pipeline job at index %d", i)
+				return nil, atPath(errors.Wrapf(err, "error parsing pipeline job at index %d", i), "jobs", i)
 			}
 			jobs[i] = *job
 		} else {
-			return nil, errors.Errorf("Unsupported kind: %s", kind)
+			return nil, atPath(errors.Errorf("Unsupported kind: %s", kind), "jobs", i, "kind")
 		}
 	}
 	return jobs, nil
 }
 
+func (s *buildDefinitionParserV03) parseStages(raw interface{}) ([]models.ResourceName, error) {
+	rStagesArray, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("Expected 'stages' element to be a list but found: %T", raw)
+	}
+	stages := make([]models.ResourceName, len(rStagesArray))
+	seen := make(map[models.ResourceName]bool, len(rStagesArray))
+	for i, rStage := range rStagesArray {
+		stage, ok := rStage.(string)
+		if !ok {
+			return nil, errors.Errorf("Expected stage at index %d to be a string but found: %T", i, rStage)
+		}
+		name := models.ResourceName(stage)
+		err := name.Validate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error validating stage %q", stage)
+		}
+		if seen[name] {
+			return nil, errors.Errorf("Found duplicate stage %q", stage)
+		}
+		seen[name] = true
+		stages[i] = name
+	}
+	return stages, nil
+}
+
+// compileStages adds job dependencies implied by the stages the jobs belong to. Each job in a stage depends on
+// every job in the closest earlier stage of the same workflow that contains at least one job.
+// Stages are ordered as listed in the top-level 'stages' element if present, or otherwise in the order they
+// first appear in the jobs list. Jobs without a stage are unaffected.
+func (s *buildDefinitionParserV03) compileStages(stages []models.ResourceName, jobs []models.JobDefinition) error {
+	stageOrder := make(map[models.ResourceName]int, len(stages))
+	for i, stage := range stages {
+		stageOrder[stage] = i
+	}
+	declared := len(stages) > 0
+	for _, job := range jobs {
+		if job.Stage == "" {
+			continue
+		}
+		if _, ok := stageOrder[job.Stage]; !ok {
+			if declared {
+				return errors.Errorf("Job %q belongs to stage %q which is not listed in 'stages'", job.Name, job.Stage)
+			}
+			stageOrder[job.Stage] = len(stageOrder)
+		}
+	}
+	if len(stageOrder) == 0 {
+		return nil
+	}
+
+	// Group job indexes by workflow and then by stage position
+	jobsByWorkflowAndStage := make(map[models.ResourceName]map[int][]int)
+	for i, job := range jobs {
+		if job.Stage == "" {
+			continue
+		}
+		byStage, ok := jobsByWorkflowAndStage[job.Workflow]
+		if !ok {
+			byStage = make(map[int][]int)
+			jobsByWorkflowAndStage[job.Workflow] = byStage
+		}
+		position := stageOrder[job.Stage]
+		byStage[position] = append(byStage[position], i)
+	}
+
+	for _, byStage := range jobsByWorkflowAndStage {
+		var previous []int
+		for position := 0; position < len(stageOrder); position++ {
+			current, ok := byStage[position]
+			if !ok {
+				continue
+			}
+			for _, jobIndex := range current {
+				job := &jobs[jobIndex]
+				for _, previousIndex := range previous {
+					dependency := models.NewJobDependency(jobs[previousIndex].Workflow, jobs[previousIndex].Name)
+					if !s.hasJobDependency(job.Depends, dependency) {
+						job.Depends = append(job.Depends, dependency)
+					}
+				}
+			}
+			previous = current
+		}
+	}
+	return nil
+}
+
+func (s *buildDefinitionParserV03) hasJobDependency(dependencies []*models.JobDependency, dependency *models.JobDependency) bool {
+	for _, existing := range dependencies {
+		if existing.Equal(dependency) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models.JobDefinition, error) {
 
 	job := &models.JobDefinition{}
@@ -68,7 +348,7 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 	if ok {
 		workflow, name, err := s.parseJobName(rName)
 		if err != nil {
-			return nil, errors.Wrap(err, "Unable to parse job 'name' field")
+			return nil, atPath(errors.Wrap(err, "Unable to parse job 'name' field"), "name")
 		}
 		job.Workflow = workflow
 		job.Name = name
@@ -78,10 +358,10 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 	if ok {
 		workflow, ok := rWorkflow.(string)
 		if !ok {
-			return nil, errors.Errorf("Expected job 'workflow' field to be a string but found: %T", rWorkflow)
+			return nil, atPath(errors.Errorf("Expected job 'workflow' field to be a string but found: %T", rWorkflow), "workflow")
 		}
 		if job.Workflow != "" && workflow != "" {
-			return nil, errors.Errorf("Job workflow is specified in both 'workflow' and 'name' fields")
+			return nil, atPath(errors.Errorf("Job workflow is specified in both 'workflow' and 'name' fields"), "workflow")
 		}
 		job.Workflow = models.ResourceName(workflow)
 	}
@@ -90,10 +370,19 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 	if ok {
 		job.Description, ok = rDescription.(string)
 		if !ok {
-			return nil, errors.Errorf("Expected job 'description' field to be a string but found: %T", rDescription)
+			return nil, atPath(errors.Errorf("Expected job 'description' field to be a string but found: %T", rDescription), "description")
 		}
 	}
 
+	rStage, ok := raw["stage"]
+	if ok {
+		stage, ok := rStage.(string)
+		if !ok {
+			return nil, atPath(errors.Errorf("Expected job 'stage' field to be a string but found: %T", rStage), "stage")
+		}
+		job.Stage = models.ResourceName(stage)
+	}
+
 	rRunsOn, ok := raw["runs_on"]
 	if ok {
 		switch value := rRunsOn.(type) {
@@ -102,11 +391,11 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 		case []interface{}:
 			labels, err := s.parseLabels(value)
 			if err != nil {
-				return nil, errors.Wrap(err, "Unable to parse job 'runs-on' field")
+				return nil, atPath(errors.Wrap(err, "Unable to parse job 'runs-on' field"), "runs_on")
 			}
 			job.RunsOn = labels
 		default:
-			return nil, errors.Errorf("Unable to parse %q to list of labels", rRunsOn)
+			return nil, atPath(errors.Errorf("Unable to parse %q to list of labels", rRunsOn), "runs_on")
 		}
 	}
 
@@ -114,30 +403,39 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 	if ok {
 		jobDependencies, err := s.parseJobDependencies(rDepends)
 		if err != nil {
-			return nil, errors.Wrap(err, "error parsing job dependencies")
+			return nil, atPath(errors.Wrap(err, "error parsing job dependencies"), "depends")
 		}
 		job.Depends = jobDependencies
 	}
 
+	rArtifactFrom, ok := raw["artifact_from"]
+	if ok {
+		artifactFrom, err := s.parseExternalArtifactDependencies(rArtifactFrom)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "error parsing job 'artifact_from' field"), "artifact_from")
+		}
+		job.ArtifactFrom = artifactFrom
+	}
+
 	// If type is not set explicitly then we will try and infer it below
 	rType, ok := raw["type"]
 	if ok {
 		err := job.Type.Scan(rType)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing job 'type' property: %w", err)
+			return nil, atPath(fmt.Errorf("error parsing job 'type' property: %w", err), "type")
 		}
 	}
 
 	rDocker, ok := raw["docker"]
 	if ok {
 		if job.Type.Valid() && job.Type != models.JobTypeDocker {
-			return nil, fmt.Errorf("%s jobs do not support a 'docker' configuration option", job.Type)
+			return nil, atPath(fmt.Errorf("%s jobs do not support a 'docker' configuration option", job.Type), "docker")
 		}
 		job.Type = models.JobTypeDocker
 
 		docker, ok := rDocker.(map[string]interface{})
 		if !ok {
-			return nil, errors.Errorf("Expected job 'docker' field to be an object but found: %T", rDocker)
+			return nil, atPath(errors.Errorf("Expected job 'docker' field to be an object but found: %T", rDocker), "docker")
 		}
 
 		rShell, ok := docker["shell"]
@@ -145,7 +443,7 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 			if shell, ok := rShell.(string); ok {
 				job.DockerShell = &shell
 			} else {
-				return nil, errors.Errorf("Expected job 'docker.shell' field to be a string but found: %T", rShell)
+				return nil, atPath(errors.Errorf("Expected job 'docker.shell' field to be a string but found: %T", rShell), "docker", "shell")
 			}
 		}
 
@@ -153,43 +451,160 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 		if ok {
 			job.DockerImage, ok = rImage.(string)
 			if !ok {
-				return nil, errors.Errorf("Expected job 'docker.image' field to be a string but found: %T", rImage)
+				return nil, atPath(errors.Errorf("Expected job 'docker.image' field to be a string but found: %T", rImage), "docker", "image")
+			}
+		}
+
+		rToolchain, ok := docker["toolchain"]
+		if ok {
+			toolchain, ok := rToolchain.(string)
+			if !ok {
+				return nil, atPath(errors.Errorf("Expected job 'docker.toolchain' field to be a string but found: %T", rToolchain), "docker", "toolchain")
+			}
+			if _, err := models.ParseToolchainReference(toolchain); err != nil {
+				return nil, atPath(err, "docker", "toolchain")
 			}
+			job.DockerToolchain = toolchain
 		}
 
 		rPull := docker["pull"]
 		err := job.DockerImagePullStrategy.Scan(rPull) // handles the default case if pull is not set
 		if err != nil {
-			return nil, fmt.Errorf("error parsing job 'docker.pull' property: %w", err)
+			return nil, atPath(fmt.Errorf("error parsing job 'docker.pull' property: %w", err), "docker", "pull")
 		}
 
 		auth, err := s.parseDockerAuthOrNil(docker)
 		if err != nil {
-			return nil, err
+			return nil, atPath(err, "docker")
 		}
 		job.DockerAuth = auth
 	}
 
+	rShell, ok := raw["shell"]
+	if ok {
+		if shell, ok := rShell.(string); ok && shell != "" {
+			job.Shell = &shell
+		} else {
+			return nil, atPath(errors.Errorf("Expected job 'shell' field to be a non-empty string but found: %v", rShell), "shell")
+		}
+	}
+
+	// Jobs that can only run on one operating system must only be given to runners on that operating system
+	shell := job.Shell
+	if job.DockerShell != nil {
+		shell = job.DockerShell
+	}
+	var shellName string
+	if shell != nil {
+		shellName = *shell
+	}
+	job.OperatingSystem = models.DeriveOperatingSystem(shellName, job.DockerImage)
+	rOperatingSystem, ok := raw["operating_system"]
+	if ok {
+		operatingSystem, ok := rOperatingSystem.(string)
+		if !ok || !models.OperatingSystem(operatingSystem).Valid() {
+			return nil, atPath(errors.Errorf("Expected job 'operating_system' field to be one of %q, %q or %q but found: %v",
+				models.OperatingSystemLinux, models.OperatingSystemWindows, models.OperatingSystemMacOS, rOperatingSystem), "operating_system")
+		}
+		if job.OperatingSystem != "" && job.OperatingSystem != models.OperatingSystem(operatingSystem) {
+			return nil, atPath(errors.Errorf("Job 'operating_system' %q conflicts with the job's shell or Docker image, which need %q",
+				operatingSystem, job.OperatingSystem), "operating_system")
+		}
+		job.OperatingSystem = models.OperatingSystem(operatingSystem)
+	}
+
+	rTimeout, ok := raw["timeout"]
+	if ok {
+		timeout, err := s.parseTimeout(rTimeout)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse job 'timeout' field"), "timeout")
+		}
+		job.TimeoutSeconds = int64(timeout / time.Second)
+	}
+
+	rResources, ok := raw["resources"]
+	if ok {
+		resources, err := s.parseResources(rResources)
+		if err != nil {
+			return nil, atPath(err, "resources")
+		}
+		job.SetResources(resources)
+	}
+
+	rCheckout, ok := raw["checkout"]
+	if ok {
+		checkout, err := s.parseBool(rCheckout)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse job 'checkout' field"), "checkout")
+		}
+		job.SkipCheckout = !checkout
+	}
+
+	rRetryOnRunnerLoss, ok := raw["retry_on_runner_loss"]
+	if ok {
+		retry, err := s.parseBool(rRetryOnRunnerLoss)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to parse job 'retry_on_runner_loss' field")
+		}
+		job.RetryOnRunnerLoss = retry
+	}
+
+	rPinSecrets, ok := raw["pin_secrets"]
+	if ok {
+		pin, err := s.parseBool(rPinSecrets)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to parse job 'pin_secrets' field")
+		}
+		job.PinSecrets = pin
+	}
+
+	rCondition, ok := raw["if"]
+	if ok {
+		condition, err := s.parseCondition(rCondition)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse job 'if' field"), "if")
+		}
+		job.Condition = condition
+	}
+
+	rOnlyPaths, ok := raw["only_paths"]
+	if ok {
+		onlyPaths, err := s.parsePathPatterns(rOnlyPaths)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse job 'only_paths' field"), "only_paths")
+		}
+		job.OnlyPaths = onlyPaths
+	}
+
+	rIgnorePaths, ok := raw["ignore_paths"]
+	if ok {
+		ignorePaths, err := s.parsePathPatterns(rIgnorePaths)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse job 'ignore_paths' field"), "ignore_paths")
+		}
+		job.IgnorePaths = ignorePaths
+	}
+
 	rStepExecution := raw["step_execution"]
 	err := job.StepExecution.Scan(rStepExecution)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing job 'step_execution' property: %w", err)
+		return nil, atPath(fmt.Errorf("error parsing job 'step_execution' property: %w", err), "step_execution")
 	}
 
 	rServices, ok := raw["services"]
 	if ok {
 		value, ok := rServices.([]interface{})
 		if !ok {
-			return nil, errors.Errorf("Expected services to be an array of service objects but found %T", rServices)
+			return nil, atPath(errors.Errorf("Expected services to be an array of service objects but found %T", rServices), "services")
 		}
 		for i, obj := range value {
 			element, ok := obj.(map[string]interface{})
 			if !ok {
-				return nil, errors.Errorf("Expected services to be an array of service objects but found %T", obj)
+				return nil, atPath(errors.Errorf("Expected services to be an array of service objects but found %T", obj), "services", i)
 			}
 			service, err := s.parseService(element)
 			if err != nil {
-				return nil, errors.Wrapf(err, "Error parsing service at index %d", i)
+				return nil, atPath(errors.Wrapf(err, "Error parsing service at index %d", i), "services", i)
 			}
 			job.Services = append(job.Services, service)
 		}
@@ -203,53 +618,41 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 		case []interface{}:
 			fingerprintCommands, err := s.parseCommands(value)
 			if err != nil {
-				return nil, errors.Wrap(err, "Unable to parse job 'fingerprint' field")
+				return nil, atPath(errors.Wrap(err, "Unable to parse job 'fingerprint' field"), "fingerprint")
 			}
 			job.FingerprintCommands = fingerprintCommands
 		default:
-			return nil, errors.Errorf("Unable to parse %q to list of fingerprint commands", rFingerprintCommands)
+			return nil, atPath(errors.Errorf("Unable to parse %q to list of fingerprint commands", rFingerprintCommands), "fingerprint")
 		}
 	}
 
 	rArtifacts, ok := raw["artifacts"]
 	if ok {
+		artifacts, err := s.parseArtifactDefinitions(rArtifacts, "default")
+		if err != nil {
+			return nil, atPath(err, "artifacts")
+		}
+		job.ArtifactDefinitions = artifacts
+	}
 
-		rValues, ok := rArtifacts.([]interface{})
+	rCaches, ok := raw["caches"]
+	if ok {
+		rValues, ok := rCaches.([]interface{})
 		if !ok {
-			return nil, errors.Errorf("Unable to parse %q to list of artifacts", rArtifacts)
+			return nil, atPath(errors.Errorf("Expected caches to be an array of cache objects but found %T", rCaches), "caches")
 		}
-
-		// Artifact definitions can be an array of objects or an array of strings. Objects are used when
-		// additional information is attached to the artifact (like a name) and strings are used when the
-		// default name is used.
-		if len(rValues) > 0 {
-			switch rValues[0].(type) {
-			case string:
-				artifacts, err := s.parseArtifactDefinitionStrings(rValues)
-				if err != nil {
-					return nil, errors.Wrap(err, "error parsing artifact dependencies")
-				}
-				job.ArtifactDefinitions = artifacts
-
-			case interface{}:
-				artifacts, err := s.parseArtifactDefinitionObjects(rValues)
-				if err != nil {
-					return nil, errors.Wrap(err, "error parsing artifact dependencies")
-				}
-				job.ArtifactDefinitions = artifacts
-
-			default:
-				return nil, errors.Errorf("Expected 'artifacts' to contain an array of strings referencing other " +
-					"step's artifacts as dependencies, or a map of objects describing the artifacts this step will produce")
-			}
+		caches, err := s.parseCacheDefinitions(rValues)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "error parsing cache definitions"), "caches")
 		}
+		job.CacheDefinitions = caches
 	}
 
 	rEnvironment, ok := raw["environment"]
 	if ok {
 		environment, err := s.parseEnvironment(rEnvironment)
 		if err != nil {
-			return nil, err
+			return nil, atPath(err, "environment")
 		}
 		job.Environment = environment
 	}
@@ -258,23 +661,22 @@ func (s *buildDefinitionParserV03) parseJob(raw map[string]interface{}) (*models
 	if ok {
 		value, ok := rSteps.([]interface{})
 		if !ok {
-			return nil, errors.Errorf("Expected steps to be an array of step objects but found %T", rSteps)
+			return nil, atPath(errors.Errorf("Expected steps to be an array of step objects but found %T", rSteps), "steps")
+		}
+		err := s.limits.checkStepCount(job, len(value))
+		if err != nil {
+			return nil, atPath(err, "steps")
 		}
 		for i, obj := range value {
 			element, ok := obj.(map[string]interface{})
 			if !ok {
-				return nil, errors.Errorf("Expected steps to be an array of step objects but found %T", obj)
+				return nil, atPath(errors.Errorf("Expected steps to be an array of step objects but found %T", obj), "steps", i)
 			}
 			step, err := s.parseStep(job, element)
 			if err != nil {
-				return nil, errors.Wrapf(err, "Error parsing step at index %d", i)
+				return nil, atPath(errors.Wrapf(err, "Error parsing step at index %d", i), "steps", i)
 			}
 			job.Steps = append(job.Steps, *step)
-			if s.limits.MaxStepsPerJob > 0 && len(job.Steps) > s.limits.MaxStepsPerJob {
-				return nil, gerror.NewErrValidationFailed(
-					fmt.Sprintf("Too many steps in job '%s'; a maximum of %d steps are allowed in each job",
-						job.Name, s.limits.MaxStepsPerJob))
-			}
 		}
 	}
 
@@ -289,7 +691,7 @@ func (s *buildDefinitionParserV03) parseStep(job *models.JobDefinition, raw map[
 	if ok {
 		name, ok := rName.(string)
 		if !ok {
-			return nil, errors.Errorf("Expected step 'name' field to be a string but found: %T", rName)
+			return nil, atPath(errors.Errorf("Expected step 'name' field to be a string but found: %T", rName), "name")
 		}
 		step.Name = models.ResourceName(name)
 	}
@@ -298,7 +700,7 @@ func (s *buildDefinitionParserV03) parseStep(job *models.JobDefinition, raw map[
 	if ok {
 		step.Description, ok = rDescription.(string)
 		if !ok {
-			return nil, errors.Errorf("Expected step 'description' field to be a string but found: %T", rDescription)
+			return nil, atPath(errors.Errorf("Expected step 'description' field to be a string but found: %T", rDescription), "description")
 		}
 	}
 
@@ -310,23 +712,135 @@ func (s *buildDefinitionParserV03) parseStep(job *models.JobDefinition, raw map[
 		case []interface{}:
 			commands, err := s.parseCommands(value)
 			if err != nil {
-				return nil, errors.Wrap(err, "Unable to parse step 'commands' field")
+				return nil, atPath(errors.Wrap(err, "Unable to parse step 'commands' field"), "commands")
 			}
 			step.Commands = commands
 		default:
-			return nil, errors.Errorf("Unable to parse %q to list of commands", rCommands)
+			return nil, atPath(errors.Errorf("Unable to parse %q to list of commands", rCommands), "commands")
 		}
 	}
 
+	rArtifacts, ok := raw["artifacts"]
+	if ok {
+		// Artifacts declared as a list of paths are named after the step, so they don't clash with the job's artifacts
+		artifacts, err := s.parseArtifactDefinitions(rArtifacts, step.Name)
+		if err != nil {
+			return nil, atPath(err, "artifacts")
+		}
+		step.ArtifactDefinitions = artifacts
+	}
+
+	rTimeout, ok := raw["timeout"]
+	if ok {
+		timeout, err := s.parseTimeout(rTimeout)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse step 'timeout' field"), "timeout")
+		}
+		step.TimeoutSeconds = int64(timeout / time.Second)
+	}
+
+	rEcho, ok := raw["echo"]
+	if ok {
+		echo, err := s.parseBool(rEcho)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse step 'echo' field"), "echo")
+		}
+		step.EchoCommands = &echo
+	}
+
+	rCondition, ok := raw["if"]
+	if ok {
+		condition, err := s.parseCondition(rCondition)
+		if err != nil {
+			return nil, atPath(errors.Wrap(err, "Unable to parse step 'if' field"), "if")
+		}
+		step.Condition = condition
+	}
+
 	depends, err := s.parseStepDependencies(job, raw)
 	if err != nil {
-		return nil, errors.Wrap(err, "error parsing step dependencies")
+		return nil, atPath(errors.Wrap(err, "error parsing step dependencies"), "depends")
 	}
 	step.Depends = depends
 
 	return step, nil
 }
 
+// parseCondition parses and validates a condition expression, returning the expression to store against the
+// job or step so that it can be evaluated when the job or step is enqueued.
+func (s *buildDefinitionParserV03) parseCondition(raw interface{}) (string, error) {
+	var expr string
+	switch value := raw.(type) {
+	case string:
+		expr = value
+	case bool:
+		expr = strconv.FormatBool(value)
+	default:
+		return "", errors.Errorf("Expected a condition expression string but found: %T", raw)
+	}
+	_, err := ParseCondition(expr)
+	if err != nil {
+		return "", err
+	}
+	return expr, nil
+}
+
+// parsePathPatterns parses a list of path patterns, or a single pattern specified as a string.
+func (s *buildDefinitionParserV03) parsePathPatterns(raw interface{}) (models.PathPatterns, error) {
+	var patterns models.PathPatterns
+	switch value := raw.(type) {
+	case string:
+		patterns = models.PathPatterns{value}
+	case []interface{}:
+		strs, err := s.parseStringArray(value)
+		if err != nil {
+			return nil, err
+		}
+		patterns = strs
+	default:
+		return nil, errors.Errorf("Expected a path pattern or list of path patterns but found: %T", raw)
+	}
+	err := ValidatePathPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// parseTimeout parses a timeout specified either as a duration string (e.g. "90s", "10m", "1h30m") or as a
+// whole number of seconds.
+func (s *buildDefinitionParserV03) parseTimeout(raw interface{}) (time.Duration, error) {
+	var timeout time.Duration
+	switch value := raw.(type) {
+	case string:
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			timeout = time.Duration(seconds) * time.Second
+			break
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		timeout = duration
+	case int:
+		timeout = time.Duration(value) * time.Second
+	case int64:
+		timeout = time.Duration(value) * time.Second
+	case float64:
+		if value != float64(int64(value)) {
+			return 0, errors.Errorf("Expected a whole number of seconds but found: %v", value)
+		}
+		timeout = time.Duration(value) * time.Second
+	default:
+		return 0, errors.Errorf("Expected a duration string or number of seconds but found: %T", raw)
+	}
+	if timeout < time.Second {
+		return 0, errors.Errorf("Timeout must be at least one second but found: %v", raw)
+	}
+	return timeout, nil
+}
+
 // parseJobName parses a job's name field, to extract an optional workflow name as well as the job name.
 func (s *buildDefinitionParserV03) parseJobName(raw interface{}) (workflow models.ResourceName, jobName models.ResourceName, err error) {
 	str, ok := raw.(string)
@@ -358,14 +872,19 @@ func (s *buildDefinitionParserV03) parseJobDependencies(raw interface{}) ([]*mod
 		return nil, errors.Errorf("unable to parse %q to a list of job dependencies", value)
 	}
 
-	jobDependenciesByJobName := map[models.ResourceName]*models.JobDependency{}
+	// Jobs with the same name in different workflows are different jobs, so dependencies are keyed by FQN
+	var jobDependencies []*models.JobDependency
+	jobDependenciesByFQN := map[models.NodeFQN]*models.JobDependency{}
 	recordJobDependency := func(workflow models.ResourceName, jobName models.ResourceName, artifactDeps ...*models.ArtifactDependency) {
-		if existing, ok := jobDependenciesByJobName[jobName]; ok {
+		fqn := models.NewNodeFQNForJob(workflow, jobName)
+		if existing, ok := jobDependenciesByFQN[fqn]; ok {
 			if len(artifactDeps) > 0 {
 				existing.ArtifactDependencies = append(existing.ArtifactDependencies, artifactDeps...)
 			}
 		} else {
-			jobDependenciesByJobName[jobName] = models.NewJobDependency(workflow, jobName, artifactDeps...)
+			dependency := models.NewJobDependency(workflow, jobName, artifactDeps...)
+			jobDependenciesByFQN[fqn] = dependency
+			jobDependencies = append(jobDependencies, dependency)
 		}
 	}
 
@@ -422,13 +941,40 @@ func (s *buildDefinitionParserV03) parseJobDependencies(raw interface{}) ([]*mod
 		return nil, errors.Errorf("Unable to parse %q to a step dependency", rValue)
 	}
 
-	jobDependencies := make([]*models.JobDependency, 0, len(jobDependenciesByJobName))
-	for _, dep := range jobDependenciesByJobName {
-		jobDependencies = append(jobDependencies, dep)
-	}
 	return jobDependencies, nil
 }
 
+// parseExternalArtifactDependencies parses a single reference or a list of references to artifacts
+// produced by previous builds, e.g. 'my-repo@build-123:go-binaries'.
+func (s *buildDefinitionParserV03) parseExternalArtifactDependencies(raw interface{}) ([]*models.ExternalArtifactDependency, error) {
+	var rawArr []interface{}
+	switch value := raw.(type) {
+	case string:
+		rawArr = []interface{}{value}
+	case []interface{}:
+		rawArr = value
+	default:
+		return nil, errors.Errorf("unable to parse %q to a list of artifact references", value)
+	}
+
+	var dependencies []*models.ExternalArtifactDependency
+	for _, rValue := range rawArr {
+		value, ok := rValue.(string)
+		if !ok {
+			return nil, errors.Errorf("unable to parse %q type %T to an artifact reference", rValue, rValue)
+		}
+		match := artifactFromBuildRegex.FindStringSubmatch(value)
+		if match == nil {
+			return nil, errors.Errorf("Unable to parse %q to an artifact reference; expected format is [repo@]build-<number>[:group]", value)
+		}
+		repoName := models.ResourceName(match[1])
+		buildName := models.ResourceName(match[2])
+		groupName := models.ResourceName(match[3])
+		dependencies = append(dependencies, models.NewExternalArtifactDependency(repoName, buildName, groupName))
+	}
+	return dependencies, nil
+}
+
 func (s *buildDefinitionParserV03) parseStepDependencies(job *models.JobDefinition, raw map[string]interface{}) ([]*models.StepDependency, error) {
 	var rawArr []interface{}
 	rDepends, ok := raw["depends"]
@@ -474,11 +1020,46 @@ func (s *buildDefinitionParserV03) parseStepDependencies(job *models.JobDefiniti
 	}
 }
 
-func (s *buildDefinitionParserV03) parseArtifactDefinitionStrings(raw []interface{}) ([]*models.ArtifactDefinition, error) {
+// parseArtifactDefinitions parses the 'artifacts' field of a job or step. Artifacts declared as a list of
+// paths are given the default group name.
+func (s *buildDefinitionParserV03) parseArtifactDefinitions(rArtifacts interface{}, defaultGroupName models.ResourceName) ([]*models.ArtifactDefinition, error) {
+	rValues, ok := rArtifacts.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("Unable to parse %q to list of artifacts", rArtifacts)
+	}
+
+	// Artifact definitions can be an array of objects or an array of strings. Objects are used when
+	// additional information is attached to the artifact (like a name) and strings are used when the
+	// default name is used.
+	if len(rValues) == 0 {
+		return nil, nil
+	}
+	switch rValues[0].(type) {
+	case string:
+		artifacts, err := s.parseArtifactDefinitionStrings(rValues, defaultGroupName)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing artifact dependencies")
+		}
+		return artifacts, nil
+
+	case interface{}:
+		artifacts, err := s.parseArtifactDefinitionObjects(rValues)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing artifact dependencies")
+		}
+		return artifacts, nil
+
+	default:
+		return nil, errors.Errorf("Expected 'artifacts' to contain an array of strings referencing other " +
+			"step's artifacts as dependencies, or a map of objects describing the artifacts this step will produce")
+	}
+}
+
+func (s *buildDefinitionParserV03) parseArtifactDefinitionStrings(raw []interface{}, defaultGroupName models.ResourceName) ([]*models.ArtifactDefinition, error) {
 	// If artifacts are defined as an array of strings then those strings represent
 	// the paths and the artifact(s) take on a default name.
 	artifact := &models.ArtifactDefinition{
-		GroupName: "default",
+		GroupName: defaultGroupName,
 	}
 	for _, rValue := range raw {
 		switch value := rValue.(type) {
@@ -528,6 +1109,80 @@ func (s *buildDefinitionParserV03) parseArtifactDefinitionObjects(raw []interfac
 	return artifacts, nil
 }
 
+func (s *buildDefinitionParserV03) parseCacheDefinitions(raw []interface{}) ([]*models.CacheDefinition, error) {
+	var caches []*models.CacheDefinition
+	for i, rValue := range raw {
+		// A cache can be given as just the name of a preset
+		if preset, ok := rValue.(string); ok {
+			definition := &models.CacheDefinition{Preset: models.CachePreset(preset)}
+			err := definition.ApplyPreset()
+			if err != nil {
+				return nil, errors.Wrapf(err, "Unable to parse cache at index %d", i)
+			}
+			caches = append(caches, definition)
+			continue
+		}
+		value, ok := rValue.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("Expected caches to be an array of cache objects or preset names but found %T", rValue)
+		}
+		definition := &models.CacheDefinition{}
+		rPreset, ok := value["preset"]
+		if ok {
+			preset, ok := rPreset.(string)
+			if !ok {
+				return nil, errors.Errorf("Expected cache definition 'preset' field to be a string but found: %T", rPreset)
+			}
+			definition.Preset = models.CachePreset(preset)
+		}
+		rName, ok := value["name"]
+		if ok {
+			name, ok := rName.(string)
+			if !ok {
+				return nil, errors.Errorf("Expected cache definition 'name' field to be a string but found: %T", rName)
+			}
+			definition.Name = models.ResourceName(name)
+		}
+		rPath, ok := value["paths"]
+		if ok {
+			switch value := rPath.(type) {
+			case string:
+				definition.Paths = []string{value}
+			case []interface{}:
+				paths, err := s.parseStringArray(value)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Unable to parse 'paths' field of cache at index %d", i)
+				}
+				definition.Paths = paths
+			default:
+				return nil, errors.Errorf("Unable to parse %q to list of cache paths", rPath)
+			}
+		}
+		rKey, ok := value["key"]
+		if ok {
+			switch value := rKey.(type) {
+			case string:
+				definition.KeyCommands = []models.Command{models.Command(value)}
+			case []interface{}:
+				keyCommands, err := s.parseCommands(value)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Unable to parse 'key' field of cache at index %d", i)
+				}
+				definition.KeyCommands = keyCommands
+			default:
+				return nil, errors.Errorf("Unable to parse %q to list of cache key commands", rKey)
+			}
+		}
+		// Anything set explicitly overrides the preset
+		err := definition.ApplyPreset()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to parse cache at index %d", i)
+		}
+		caches = append(caches, definition)
+	}
+	return caches, nil
+}
+
 func (s *buildDefinitionParserV03) parseService(raw map[string]interface{}) (*models.Service, error) {
 	service := &models.Service{}
 	rName, ok := raw["name"]
@@ -622,6 +1277,52 @@ func (s *buildDefinitionParserV03) parseStringArray(raw []interface{}) ([]string
 	return strs, nil
 }
 
+// parseResources parses a job's 'resources' field, which declares the CPU, memory, disk space and GPUs the
+// job requires from the runner it runs on.
+func (s *buildDefinitionParserV03) parseResources(raw interface{}) (models.Resources, error) {
+	var resources models.Resources
+	rMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return resources, errors.Errorf("Expected job 'resources' field to be an object but found: %T", raw)
+	}
+	for key, value := range rMap {
+		var err error
+		switch key {
+		case "cpu":
+			resources.CPUMillis, err = models.ParseCPUMillis(value)
+		case "memory":
+			resources.MemoryBytes, err = models.ParseBytes(value)
+		case "disk":
+			resources.DiskBytes, err = models.ParseBytes(value)
+		case "gpu", "gpus":
+			resources.GPUs, err = models.ParseGPUs(value)
+		default:
+			return resources, errors.Errorf("Unknown job 'resources' field %q; expected cpu, memory, disk or gpus", key)
+		}
+		if err != nil {
+			return resources, atPath(errors.Wrapf(err, "error parsing job 'resources.%s' field", key), key)
+		}
+	}
+	return resources, nil
+}
+
+// parseBool attempts to convert the raw value of a field to a bool. JSON configs provide a bool, whereas
+// YAML configs provide a string since the YAML parser's output is normalized to strings.
+func (s *buildDefinitionParserV03) parseBool(raw interface{}) (bool, error) {
+	switch value := raw.(type) {
+	case bool:
+		return value, nil
+	case string:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, errors.Errorf("Expected a boolean but found %q", value)
+		}
+		return parsed, nil
+	default:
+		return false, errors.Errorf("Expected a boolean but found: %T", raw)
+	}
+}
+
 // parseSecretString attempts to convert the raw value of a field into a SecretString. The raw value can contain
 // either a string (a literal value) or an object. If an object is provided then it can contain either a
 // literal value or the name of a secret that will contain the value. An empty string is a valid literal value.
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// ValidatePathPatterns returns an error if any of the specified path patterns is not a valid glob.
+func ValidatePathPatterns(patterns models.PathPatterns) error {
+	for i, pattern := range patterns {
+		if pattern == "" {
+			return errors.Errorf("path pattern at index %d must not be empty", i)
+		}
+		// Matching a pattern against itself is enough to surface any syntax errors in it
+		if _, err := doublestar.Match(pattern, pattern); err != nil {
+			return errors.Wrapf(err, "error parsing path pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// PathFiltersMatch returns true if a job with the specified path filters should run for a commit that changed
+// the specified files. If onlyPaths is set, at least one changed file must match one of its patterns. If ignorePaths
+// is set, the job is skipped if every changed file matches one of its patterns.
+func PathFiltersMatch(onlyPaths models.PathPatterns, ignorePaths models.PathPatterns, changedFiles []string) (bool, error) {
+	if len(onlyPaths) > 0 {
+		matched := false
+		for _, file := range changedFiles {
+			ok, err := matchAnyPathPattern(onlyPaths, file)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if len(ignorePaths) > 0 && len(changedFiles) > 0 {
+		for _, file := range changedFiles {
+			ignored, err := matchAnyPathPattern(ignorePaths, file)
+			if err != nil {
+				return false, err
+			}
+			if !ignored {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func matchAnyPathPattern(patterns models.PathPatterns, path string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := doublestar.Match(pattern, path)
+		if err != nil {
+			return false, errors.Wrapf(err, "error matching path pattern %q", pattern)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
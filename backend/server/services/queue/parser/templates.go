@@ -0,0 +1,503 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// maxTemplateDepth is the maximum depth of nested 'include' and 'extends' elements allowed in a build definition.
+const maxTemplateDepth = 10
+
+var (
+	// Templates are referenced by an optional repo name and/or git ref, followed by a mandatory path to the
+	// template file within the repo. Examples: 'ci/go.yml', 'shared-ci:templates/go.yml', 'shared-ci@v1:go.yml'
+	templateReferenceRegex = regexp.MustCompile(`^(?:([a-zA-Z0-9_-]+)?(?:@([^:\s]+))?:)?([^:\s]+)$`)
+
+	// Parameters are substituted into templates wherever '${{ params.<name> }}' appears in a string value.
+	templateParamRegex = regexp.MustCompile(`\$\{\{\s*params\.([a-zA-Z0-9_-]+)\s*}}`)
+)
+
+// TemplateReference identifies a build definition template referenced by an 'include' or 'extends' element.
+type TemplateReference struct {
+	// Repo is the name of the repo containing the template, or empty for the repo the build definition came from.
+	// Repos are looked up within the legal entity that owns the repo being built.
+	Repo models.ResourceName
+	// Ref is the git ref (branch, tag or commit SHA) to read the template from, or empty for the commit the
+	// build definition came from.
+	Ref string
+	// Path is the path of the template file, relative to the root of the repo.
+	Path string
+}
+
+func (r TemplateReference) String() string {
+	if r.Repo == "" && r.Ref == "" {
+		return r.Path
+	}
+	str := string(r.Repo)
+	if r.Ref != "" {
+		str = fmt.Sprintf("%s@%s", str, r.Ref)
+	}
+	return fmt.Sprintf("%s:%s", str, r.Path)
+}
+
+// relativeTo returns the reference resolved relative to the template it appeared in. References that don't
+// specify a repo refer to the same repo (and ref, unless one is specified) as the template they appear in.
+func (r TemplateReference) relativeTo(parent TemplateReference) TemplateReference {
+	if r.Repo == "" {
+		r.Repo = parent.Repo
+		if r.Ref == "" {
+			r.Ref = parent.Ref
+		}
+	}
+	return r
+}
+
+// TemplateLoader loads the contents of templates referenced from build definitions.
+type TemplateLoader interface {
+	// LoadTemplate returns the contents of the template file identified by ref. Returns a not found error
+	// if the repo, ref or file does not exist.
+	LoadTemplate(ref TemplateReference) ([]byte, error)
+}
+
+// templateUsage is a reference to a template together with the parameter values to substitute into it.
+type templateUsage struct {
+	ref    TemplateReference
+	params map[string]string
+}
+
+// jobSource identifies the file a job in a build definition came from, so that errors can be reported against
+// the correct file when jobs are included from templates.
+type jobSource struct {
+	// file is the path of the template the job was included from, or empty for the build definition itself.
+	file       string
+	config     []byte
+	configType models.ConfigType
+	// index is the index of the job within the file's 'jobs' list.
+	index int
+}
+
+// locate converts an error that records the path to the element that caused it into a ParseError reporting
+// the position of the element within the source file. Errors that don't record a path are returned unchanged.
+func (s jobSource) locate(err error) error {
+	return s.locatePath(err, errorPath(err))
+}
+
+// locatePath converts an error into a ParseError reporting the position of the element at the specified path
+// within the source file. Errors are returned unchanged if path is empty.
+func (s jobSource) locatePath(err error, path []interface{}) error {
+	if len(path) == 0 {
+		return err
+	}
+	var pos position
+	if s.configType == models.ConfigTypeYAML || s.configType == models.ConfigTypeJSON {
+		pos = newPositionIndex(s.config).locate(path)
+	}
+	return &ParseError{File: s.file, Line: pos.line, Column: pos.column, Path: formatPath(path), Err: err}
+}
+
+// templateResolver expands the 'include' and 'extends' elements in a build definition, loading the referenced
+// templates and substituting parameters. It tracks the chain of templates being expanded to detect cycles.
+type templateResolver struct {
+	parser *BuildDefinitionParser
+	loader TemplateLoader
+	stack  []TemplateReference
+}
+
+// resolveTemplates returns a copy of the top-level element of a build definition, with jobs from any included
+// templates added to the 'jobs' list and any jobs that extend a template merged with the template.
+// Also returns the source of each job in the returned 'jobs' list. The top-level element is returned unchanged,
+// with nil sources, if it doesn't use templates.
+func (s *BuildDefinitionParser) resolveTemplates(topLevelElement map[string]interface{}, source jobSource) (map[string]interface{}, []jobSource, error) {
+	if !usesTemplates(topLevelElement) {
+		return topLevelElement, nil, nil
+	}
+	if s.templateLoader == nil {
+		return nil, nil, errors.Errorf("error parsing build definition: 'include' and 'extends' are not supported here")
+	}
+	resolver := &templateResolver{parser: s, loader: s.templateLoader}
+	jobs, sources, err := resolver.resolveJobs(topLevelElement, TemplateReference{}, source)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error resolving templates")
+	}
+	resolved := make(map[string]interface{}, len(topLevelElement))
+	for key, value := range topLevelElement {
+		if key != "include" {
+			resolved[key] = value
+		}
+	}
+	resolved["jobs"] = jobs
+	return resolved, sources, nil
+}
+
+// usesTemplates returns true if the build definition includes templates, or contains any jobs that extend one.
+func usesTemplates(topLevelElement map[string]interface{}) bool {
+	if _, ok := topLevelElement["include"]; ok {
+		return true
+	}
+	rJobs, _ := topLevelElement["jobs"].([]interface{})
+	for _, rJob := range rJobs {
+		job, ok := rJob.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := job["extends"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveJobs returns the jobs from all templates included by definition (recursively), followed by the jobs
+// in definition itself with any templates they extend merged in. base is the template definition came from,
+// and source is the file it came from. Also returns the source of each returned job.
+func (r *templateResolver) resolveJobs(definition map[string]interface{}, base TemplateReference, source jobSource) ([]interface{}, []jobSource, error) {
+	var (
+		jobs    []interface{}
+		sources []jobSource
+	)
+	rInclude, ok := definition["include"]
+	if ok {
+		usages, err := parseTemplateUsages(rInclude)
+		if err != nil {
+			return nil, nil, atPath(errors.Wrap(err, "error parsing 'include' element"), "include")
+		}
+		for _, usage := range usages {
+			ref := usage.ref.relativeTo(base)
+			included, includedSources, err := r.resolveInclude(ref, usage.params)
+			if err != nil {
+				return nil, nil, atPath(errors.Wrapf(err, "error including template %s", ref), "include")
+			}
+			jobs = append(jobs, included...)
+			sources = append(sources, includedSources...)
+		}
+	}
+	rJobs, ok := definition["jobs"]
+	if ok {
+		rJobsArray, ok := rJobs.([]interface{})
+		if !ok {
+			return nil, nil, atPath(errors.Errorf("job element must contain an array but found %T", rJobs), "jobs")
+		}
+		for i, rJob := range rJobsArray {
+			job, ok := rJob.(map[string]interface{})
+			if ok {
+				if _, ok := job["extends"]; ok {
+					extended, err := r.resolveExtends(job, base)
+					if err != nil {
+						err = errors.Wrapf(err, "error extending job %v at index %d", job["name"], i)
+						return nil, nil, atPath(err, "jobs", i, "extends")
+					}
+					rJob = extended
+				}
+			}
+			jobs = append(jobs, rJob)
+			jobSource := source
+			jobSource.index = i
+			sources = append(sources, jobSource)
+		}
+	}
+	return jobs, sources, nil
+}
+
+// resolveInclude loads a template containing a list of jobs and returns the resolved jobs and their sources.
+func (r *templateResolver) resolveInclude(ref TemplateReference, params map[string]string) ([]interface{}, []jobSource, error) {
+	err := r.enter(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.exit()
+	template, source, err := r.loadTemplate(ref, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key := range template {
+		if key != "version" && key != "include" && key != "jobs" {
+			err = errors.Errorf("unsupported element %q; included templates may only contain 'params', 'include' and 'jobs'", key)
+			return nil, nil, source.locate(atPath(err, key))
+		}
+	}
+	jobs, sources, err := r.resolveJobs(template, ref, source)
+	if err != nil {
+		return nil, nil, source.locate(err)
+	}
+	return jobs, sources, nil
+}
+
+// resolveExtends loads the job template referenced by the job's 'extends' element (recursively resolving any
+// template it extends in turn) and returns the template merged with the job. Elements set on the job replace
+// the same elements in the template.
+func (r *templateResolver) resolveExtends(job map[string]interface{}, base TemplateReference) (map[string]interface{}, error) {
+	usage, err := parseTemplateUsage(job["extends"])
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing 'extends' element")
+	}
+	ref := usage.ref.relativeTo(base)
+	err = r.enter(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer r.exit()
+	template, source, err := r.loadTemplate(ref, usage.params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading template %s", ref)
+	}
+	if _, ok := template["extends"]; ok {
+		template, err = r.resolveExtends(template, ref)
+		if err != nil {
+			return nil, errors.Wrapf(source.locate(atPath(err, "extends")), "error extending template %s", ref)
+		}
+	}
+	merged := make(map[string]interface{}, len(template)+len(job))
+	for key, value := range template {
+		merged[key] = value
+	}
+	for key, value := range job {
+		if key != "extends" {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// enter records that the referenced template is being expanded, returning an error if this would create a cycle
+// or exceed the maximum nesting depth.
+func (r *templateResolver) enter(ref TemplateReference) error {
+	for i, parent := range r.stack {
+		if parent == ref {
+			var chain []string
+			for _, link := range r.stack[i:] {
+				chain = append(chain, link.String())
+			}
+			chain = append(chain, ref.String())
+			return errors.Errorf("template cycle detected: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if len(r.stack) >= maxTemplateDepth {
+		return errors.Errorf("templates are nested more than %d levels deep", maxTemplateDepth)
+	}
+	r.stack = append(r.stack, ref)
+	return nil
+}
+
+// exit records that expansion of the most recently entered template has finished.
+func (r *templateResolver) exit() {
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+// loadTemplate loads and unmarshals the referenced template, then substitutes the supplied parameter values
+// (or the defaults declared by the template) into it. The template's 'params' element is removed.
+// Also returns the source of the template, for reporting errors against the template file.
+func (r *templateResolver) loadTemplate(ref TemplateReference, params map[string]string) (map[string]interface{}, jobSource, error) {
+	config, err := r.loader.LoadTemplate(ref)
+	if err != nil {
+		return nil, jobSource{}, err
+	}
+	source := jobSource{file: ref.String(), config: config}
+	var raw interface{}
+	switch ext := strings.ToLower(path.Ext(ref.Path)); ext {
+	case ".yml", ".yaml":
+		source.configType = models.ConfigTypeYAML
+		raw, err = r.parser.parseFromYAML(config)
+	case ".json":
+		source.configType = models.ConfigTypeJSON
+		raw, err = r.parser.parseFromJSON(config)
+	case ".jsonnet":
+		source.configType = models.ConfigTypeJSONNET
+		raw, err = r.parser.parseFromJSONNET(config)
+	default:
+		return nil, jobSource{}, errors.Errorf("unsupported template file extension %q; expected .yml, .yaml, .json or .jsonnet", ext)
+	}
+	if err != nil {
+		return nil, jobSource{}, errors.Wrap(err, "error unmarshalling template")
+	}
+	template, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, jobSource{}, errors.Errorf("template must contain a top-level object but found %T", raw)
+	}
+	values, err := parseTemplateParams(template["params"], params)
+	if err != nil {
+		return nil, jobSource{}, source.locate(atPath(err, "params"))
+	}
+	delete(template, "params")
+	substituted, err := substituteTemplateParams(template, values, nil)
+	if err != nil {
+		return nil, jobSource{}, source.locate(err)
+	}
+	return substituted.(map[string]interface{}), source, nil
+}
+
+// parseTemplateParams returns the value of each parameter declared by a template. raw is the template's
+// 'params' element, which is either a list of required parameter names or an object mapping each parameter
+// name to its default value. supplied contains the values supplied via the 'with' element referencing the
+// template; each must correspond to a declared parameter.
+func parseTemplateParams(raw interface{}, supplied map[string]string) (map[string]string, error) {
+	values := make(map[string]string)
+	declared := make(map[string]bool)
+	switch rParams := raw.(type) {
+	case nil:
+	case []interface{}:
+		for _, rName := range rParams {
+			name, ok := rName.(string)
+			if !ok {
+				return nil, errors.Errorf("expected template parameter name to be a string but found %T", rName)
+			}
+			declared[name] = true
+		}
+	case map[string]interface{}:
+		for name, rDefault := range rParams {
+			value, err := templateParamValue(rDefault)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error parsing default value for template parameter %q", name)
+			}
+			declared[name] = true
+			values[name] = value
+		}
+	default:
+		return nil, errors.Errorf("expected template 'params' element to be a list or an object but found %T", raw)
+	}
+	for name, value := range supplied {
+		if !declared[name] {
+			return nil, errors.Errorf("parameter %q is not declared by the template", name)
+		}
+		values[name] = value
+	}
+	for name := range declared {
+		if _, ok := values[name]; !ok {
+			return nil, errors.Errorf("no value supplied for required template parameter %q", name)
+		}
+	}
+	return values, nil
+}
+
+// substituteTemplateParams returns a copy of raw with parameter values substituted into each string value.
+// path is the path to raw within the template, used to report the location of any errors.
+func substituteTemplateParams(raw interface{}, values map[string]string, path []interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case string:
+		var err error
+		substituted := templateParamRegex.ReplaceAllStringFunc(v, func(match string) string {
+			name := templateParamRegex.FindStringSubmatch(match)[1]
+			value, ok := values[name]
+			if !ok && err == nil {
+				err = atPath(errors.Errorf("template refers to undeclared parameter %q", name), path...)
+			}
+			return value
+		})
+		return substituted, err
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, element := range v {
+			substituted, err := substituteTemplateParams(element, values, append(path[:len(path):len(path)], i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = substituted
+		}
+		return res, nil
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			substituted, err := substituteTemplateParams(element, values, append(path[:len(path):len(path)], key))
+			if err != nil {
+				return nil, err
+			}
+			res[key] = substituted
+		}
+		return res, nil
+	default:
+		return v, nil
+	}
+}
+
+// parseTemplateUsages parses an 'include' element, containing either a single template usage or a list of them.
+func parseTemplateUsages(raw interface{}) ([]*templateUsage, error) {
+	rArr, ok := raw.([]interface{})
+	if !ok {
+		rArr = []interface{}{raw}
+	}
+	usages := make([]*templateUsage, len(rArr))
+	for i, rUsage := range rArr {
+		usage, err := parseTemplateUsage(rUsage)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing template reference at index %d", i)
+		}
+		usages[i] = usage
+	}
+	return usages, nil
+}
+
+// parseTemplateUsage parses a reference to a template. This is either a string in the format
+// '[repo][@ref]:path' (or just a path), or an object with 'repo', 'ref', 'path' and 'with' elements where
+// 'with' supplies values for the template's parameters.
+func parseTemplateUsage(raw interface{}) (*templateUsage, error) {
+	switch v := raw.(type) {
+	case string:
+		match := templateReferenceRegex.FindStringSubmatch(v)
+		if match == nil {
+			return nil, errors.Errorf("unable to parse %q to a template reference; expected format is [repo][@ref]:path", v)
+		}
+		return &templateUsage{ref: TemplateReference{Repo: models.ResourceName(match[1]), Ref: match[2], Path: match[3]}}, nil
+	case map[string]interface{}:
+		usage := &templateUsage{params: make(map[string]string)}
+		for key, rValue := range v {
+			switch key {
+			case "repo", "ref", "path":
+				value, ok := rValue.(string)
+				if !ok {
+					return nil, errors.Errorf("expected template reference %q element to be a string but found %T", key, rValue)
+				}
+				switch key {
+				case "repo":
+					usage.ref.Repo = models.ResourceName(value)
+				case "ref":
+					usage.ref.Ref = value
+				case "path":
+					usage.ref.Path = value
+				}
+			case "with":
+				rWith, ok := rValue.(map[string]interface{})
+				if !ok {
+					return nil, errors.Errorf("expected template reference 'with' element to be an object but found %T", rValue)
+				}
+				for name, rParam := range rWith {
+					value, err := templateParamValue(rParam)
+					if err != nil {
+						return nil, errors.Wrapf(err, "error parsing value for template parameter %q", name)
+					}
+					usage.params[name] = value
+				}
+			default:
+				return nil, errors.Errorf("unsupported template reference element %q", key)
+			}
+		}
+		if usage.ref.Path == "" {
+			return nil, errors.Errorf("template reference must specify a 'path'")
+		}
+		if usage.ref.Repo != "" {
+			err := usage.ref.Repo.Validate()
+			if err != nil {
+				return nil, errors.Wrap(err, "error validating template repo name")
+			}
+		}
+		return usage, nil
+	default:
+		return nil, errors.Errorf("expected template reference to be a string or an object but found %T", raw)
+	}
+}
+
+// templateParamValue converts a scalar value supplied for a template parameter to a string.
+func templateParamValue(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case []interface{}, map[string]interface{}:
+		return "", errors.Errorf("expected a scalar value but found %T", v)
+	case string:
+		return v, nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
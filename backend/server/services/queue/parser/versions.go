@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// LatestVersion is the latest build definition version supported by this server. Build definitions should
+	// pin the version they are written for using the top-level 'version' element, so that changes to the
+	// default version can't change how they are parsed.
+	LatestVersion = "0.3"
+	// defaultVersion is the version used to parse build definitions that don't specify a version.
+	defaultVersion = "0.2"
+)
+
+// buildDefinitionVersion describes a version of the build definition schema supported by this server.
+type buildDefinitionVersion struct {
+	// deprecated explains what to do instead of using this version, or is empty if the version is not deprecated.
+	deprecated string
+	// deprecatedFields lists fields that can still be specified in this version but should no longer be used.
+	deprecatedFields []deprecatedField
+	newParser        func(limits ParserLimits) buildDefinitionVersionedParser
+}
+
+// deprecatedField describes a field that should no longer be used in a build definition.
+type deprecatedField struct {
+	// pattern is the path to the field, with '*' matching each element of an array,
+	// e.g. 'jobs.*.steps.*.environment'
+	pattern string
+	// message explains what to do instead of using the field.
+	message string
+}
+
+var buildDefinitionVersions = map[string]*buildDefinitionVersion{
+	"0.2": {
+		deprecated: fmt.Sprintf("build definition version 0.2 is deprecated; please upgrade to version %s", LatestVersion),
+		newParser: func(limits ParserLimits) buildDefinitionVersionedParser {
+			return newBuildDefinitionParserV02(limits)
+		},
+	},
+	"0.3": {
+		deprecatedFields: []deprecatedField{
+			{pattern: "jobs.*.kind", message: "'kind' is deprecated and can be removed; all jobs are pipeline jobs"},
+			{pattern: "jobs.*.steps.*.environment", message: "step 'environment' is deprecated and is ignored; set 'environment' on the job instead"},
+		},
+		newParser: func(limits ParserLimits) buildDefinitionVersionedParser {
+			return newBuildDefinitionParserV03(limits)
+		},
+	},
+}
+
+// negotiateVersion returns the supported version to use to parse a build definition that requests the specified
+// version, along with any warnings about the choice. An empty requested version selects the default version.
+// Versions are compared numerically so that e.g. '0.3.0' selects version 0.3.
+func negotiateVersion(requested string) (string, *buildDefinitionVersion, []string, error) {
+	if requested == "" {
+		version := buildDefinitionVersions[defaultVersion]
+		warnings := []string{fmt.Sprintf("no 'version' specified so defaulting to version %s; "+
+			"pin the version with 'version: %s' to avoid unexpected changes", defaultVersion, LatestVersion)}
+		return defaultVersion, version, warnings, nil
+	}
+	requestedNumber, ok := parseVersionNumber(requested)
+	if !ok {
+		return "", nil, nil, fmt.Errorf("version %s is not a valid version number", requested)
+	}
+	for name, version := range buildDefinitionVersions {
+		if number, _ := parseVersionNumber(name); number == requestedNumber {
+			var warnings []string
+			if version.deprecated != "" {
+				warnings = append(warnings, version.deprecated)
+			}
+			return name, version, warnings, nil
+		}
+	}
+	if latest, _ := parseVersionNumber(LatestVersion); requestedNumber.newerThan(latest) {
+		return "", nil, nil, fmt.Errorf("version %s is newer than the latest version supported by this server (%s)",
+			requested, LatestVersion)
+	}
+	return "", nil, nil, fmt.Errorf("version %s not supported; supported versions are %s",
+		requested, strings.Join(supportedVersions(), ", "))
+}
+
+// supportedVersions returns the names of all supported build definition versions, in ascending order.
+func supportedVersions() []string {
+	var names []string
+	for name := range buildDefinitionVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type versionNumber struct {
+	major int
+	minor int
+}
+
+func (v versionNumber) newerThan(other versionNumber) bool {
+	return v.major > other.major || (v.major == other.major && v.minor > other.minor)
+}
+
+// parseVersionNumber parses a 'major', 'major.minor' or 'major.minor.0' version string.
+func parseVersionNumber(str string) (versionNumber, bool) {
+	parts := strings.Split(strings.TrimSpace(str), ".")
+	if len(parts) > 3 || (len(parts) == 3 && parts[2] != "0") {
+		return versionNumber{}, false
+	}
+	var (
+		number versionNumber
+		err    error
+	)
+	number.major, err = strconv.Atoi(parts[0])
+	if err != nil || number.major < 0 {
+		return versionNumber{}, false
+	}
+	if len(parts) > 1 {
+		number.minor, err = strconv.Atoi(parts[1])
+		if err != nil || number.minor < 0 {
+			return versionNumber{}, false
+		}
+	}
+	return number, true
+}
+
+// findDeprecatedFields returns an error recording the path to each field in the top-level element of a build
+// definition that is deprecated in the specified version.
+func findDeprecatedFields(topLevelElement map[string]interface{}, version *buildDefinitionVersion) []error {
+	var found []error
+	for _, field := range version.deprecatedFields {
+		for _, path := range matchPattern(topLevelElement, strings.Split(field.pattern, "."), nil) {
+			found = append(found, atPath(errors.New(field.message), path...))
+		}
+	}
+	return found
+}
+
+// matchPattern returns the path to each element within raw that matches the pattern, relative to path.
+func matchPattern(raw interface{}, pattern []string, path []interface{}) [][]interface{} {
+	if len(pattern) == 0 {
+		return [][]interface{}{path}
+	}
+	var matches [][]interface{}
+	switch value := raw.(type) {
+	case map[string]interface{}:
+		if child, ok := value[pattern[0]]; ok && pattern[0] != "*" {
+			childPath := append(append([]interface{}{}, path...), pattern[0])
+			matches = append(matches, matchPattern(child, pattern[1:], childPath)...)
+		}
+	case []interface{}:
+		if pattern[0] == "*" {
+			for i, child := range value {
+				childPath := append(append([]interface{}{}, path...), i)
+				matches = append(matches, matchPattern(child, pattern[1:], childPath)...)
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,60 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestArtifactDependencies(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	// Jobs named 'build' in two workflows, each producing differently named artifacts
+	makeBuildJob := func(workflow models.ResourceName, artifact models.ResourceName) models.JobDefinition {
+		job := makeConditionalJobDefinition("build", "", nil, makeConditionalStepDefinition("run", ""))
+		job.Workflow = workflow
+		job.ArtifactDefinitions = models.ArtifactDefinitions{{GroupName: artifact, Paths: []string{"dist/*"}}}
+		return job
+	}
+	makeDeployJob := func(dependencies ...*models.ArtifactDependency) models.JobDefinition {
+		job := makeConditionalJobDefinition("deploy", "", nil, makeConditionalStepDefinition("run", ""))
+		for _, dependency := range dependencies {
+			job.Depends = append(job.Depends, models.NewJobDependency(dependency.Workflow, dependency.JobName, dependency))
+		}
+		return job
+	}
+	enqueue := func(jobs ...models.JobDefinition) error {
+		_, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, &models.BuildDefinition{Jobs: jobs}, "refs/heads/main", nil)
+		return err
+	}
+
+	// Depending on the same job name in both workflows is fine, as long as each job defines the artifact
+	err = enqueue(
+		makeBuildJob("linux", "tarball"),
+		makeBuildJob("windows", "installer"),
+		makeDeployJob(
+			models.NewArtifactDependency("linux", "build", "tarball"),
+			models.NewArtifactDependency("windows", "build", "installer")))
+	require.NoError(t, err)
+
+	// The artifact must come from the job in the workflow named by the dependency
+	err = enqueue(
+		makeBuildJob("linux", "tarball"),
+		makeBuildJob("windows", "installer"),
+		makeDeployJob(models.NewArtifactDependency("linux", "build", "installer")))
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err), "expected validation error, found: %v", err)
+	require.Contains(t, err.Error(), `depends on artifact "installer" from job "linux.build"`)
+}
@@ -0,0 +1,108 @@
+package queue_server_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestArtifactFrom(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	// Run a release build that produces two groups of artifacts
+	releaseBuild, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, makeArtifactFromBuildDefinition("release"), "refs/heads/master", nil)
+	require.NoError(t, err)
+	releaseJob, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	binary, err := app.ArtifactService.Create(ctx, releaseJob.ID, "go-binaries", "bin/app", "", bytes.NewReader([]byte("binary")), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, releaseJob.ID, "docs", "docs/index.html", "", bytes.NewReader([]byte("docs")), true)
+	require.NoError(t, err)
+
+	// Artifacts can't be used from the release build until it has succeeded
+	deployDef := makeArtifactFromBuildDefinition("deploy", models.NewExternalArtifactDependency("", releaseBuild.Name, "go-binaries"))
+	deployBuild, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, deployDef, "refs/heads/master", nil)
+	require.NoError(t, err)
+	job, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NotNil(t, gerror.ToNotFound(err))
+	require.Nil(t, job)
+	checkBuildStatus(t, app, deployBuild.ID, models.WorkflowStatusFailed)
+
+	for _, step := range releaseJob.Steps {
+		_, err = app.QueueService.UpdateStepStatus(ctx, nil, step.ID, dto.UpdateStepStatus{Status: models.WorkflowStatusSucceeded})
+		require.NoError(t, err)
+	}
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, releaseJob.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+	checkBuildStatus(t, app, releaseBuild.ID, models.WorkflowStatusSucceeded)
+
+	t.Run("Group", func(t *testing.T) {
+		_, err = app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, deployDef, "refs/heads/master", nil)
+		require.NoError(t, err)
+		job, err := app.QueueService.Dequeue(ctx, runner.ID)
+		require.NoError(t, err)
+		require.Len(t, job.ExternalArtifacts, 1)
+		require.Equal(t, binary.ID, job.ExternalArtifacts[0].ID)
+	})
+
+	t.Run("AllArtifactsFromNamedRepo", func(t *testing.T) {
+		def := makeArtifactFromBuildDefinition("deploy", models.NewExternalArtifactDependency(repo.Name, releaseBuild.Name, ""))
+		_, err = app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, def, "refs/heads/master", nil)
+		require.NoError(t, err)
+		job, err := app.QueueService.Dequeue(ctx, runner.ID)
+		require.NoError(t, err)
+		require.Len(t, job.ExternalArtifacts, 2)
+	})
+
+	t.Run("Unresolvable", func(t *testing.T) {
+		for _, dependency := range []*models.ExternalArtifactDependency{
+			models.NewExternalArtifactDependency("", "999", ""),
+			models.NewExternalArtifactDependency("no-such-repo", releaseBuild.Name, ""),
+			models.NewExternalArtifactDependency("", releaseBuild.Name, "no-such-group"),
+		} {
+			build, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, makeArtifactFromBuildDefinition("deploy", dependency), "refs/heads/master", nil)
+			require.NoError(t, err)
+			job, err := app.QueueService.Dequeue(ctx, runner.ID)
+			require.NotNil(t, gerror.ToNotFound(err), "expected %q not to resolve", dependency)
+			require.Nil(t, job)
+			checkBuildStatus(t, app, build.ID, models.WorkflowStatusFailed)
+		}
+	})
+}
+
+func makeArtifactFromBuildDefinition(jobName models.ResourceName, artifactFrom ...*models.ExternalArtifactDependency) *models.BuildDefinition {
+	return &models.BuildDefinition{
+		Jobs: []models.JobDefinition{
+			{
+				JobDefinitionData: models.JobDefinitionData{
+					Name:                    jobName,
+					Type:                    models.JobTypeDocker,
+					DockerImage:             "alpine",
+					DockerImagePullStrategy: models.DockerPullStrategyDefault,
+					StepExecution:           models.StepExecutionSequential,
+					ArtifactFrom:            artifactFrom,
+				},
+				Steps: []models.StepDefinition{{
+					StepDefinitionData: models.StepDefinitionData{
+						Name:     "run",
+						Commands: models.Commands{"echo 'hello world'"},
+					},
+				}},
+			},
+		}}
+}
@@ -0,0 +1,82 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+const buildParametersYAML = `
+version: 0.3
+parameters:
+  - name: target
+    type: choice
+    choices: [staging, production]
+    default: staging
+  - name: build_number
+    type: number
+jobs:
+  - name: deploy
+    docker:
+      image: docker:20.10
+    steps:
+      - name: deploy
+        commands:
+          - echo deploy
+`
+
+func TestBuildParameters(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := referencedata.GenerateCommit(repo.ID, legalEntity.ID)
+	commit.Config = []byte(buildParametersYAML)
+	commit.ConfigType = models.ConfigTypeYAML
+	require.NoError(t, app.CommitStore.Create(ctx, nil, commit))
+
+	// A build fails if a required parameter isn't supplied, or a value is invalid
+	build, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusFailed, build.Status)
+	build, err = app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, &models.BuildOptions{
+		Parameters: models.BuildParameters{"build_number": "42", "target": "dev"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusFailed, build.Status)
+
+	// Supplied and default values are recorded in the build options and passed to each job
+	build, err = app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, &models.BuildOptions{
+		Parameters: models.BuildParameters{"build_number": "42"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusQueued, build.Status)
+	expected := models.BuildParameters{"build_number": "42", "target": "staging"}
+	require.Equal(t, expected, build.Opts.Parameters)
+	job, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, build.ID, job.BuildID)
+	require.Equal(t, expected, job.BuildParameters)
+
+	// Clones reuse the parameter values of the original build unless others are supplied
+	from, err := app.BuildService.Read(ctx, nil, build.ID)
+	require.NoError(t, err)
+	from.Status = models.WorkflowStatusSucceeded
+	err = app.BuildService.Update(ctx, nil, from)
+	require.NoError(t, err)
+	clone, err := app.QueueService.CloneBuild(ctx, nil, build.ID, dto.CloneBuild{
+		Opts: &models.BuildOptions{Parameters: models.BuildParameters{"target": "production"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.BuildParameters{"build_number": "42", "target": "production"}, clone.Opts.Parameters)
+}
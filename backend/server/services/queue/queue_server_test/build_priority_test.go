@@ -0,0 +1,78 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+func TestBuildPriority(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	enqueue := func(ref string, opts *models.BuildOptions) *dto.BuildGraph {
+		commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+		build, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, ref, opts)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusQueued, build.Status)
+		return build
+	}
+	requireNextJobFrom := func(build *dto.BuildGraph) {
+		job, err := app.QueueService.Dequeue(ctx, runner.ID)
+		require.NoError(t, err)
+		require.Equal(t, build.ID, job.BuildID)
+	}
+
+	// Jobs from a higher priority build are dequeued ahead of older jobs
+	routine := enqueue(referencedata.TestRef, nil)
+	require.Equal(t, 0, routine.Priority)
+	priority := 10
+	urgent := enqueue(referencedata.TestRef, &models.BuildOptions{Priority: &priority})
+	require.Equal(t, 10, urgent.Priority)
+	requireNextJobFrom(urgent)
+
+	// Builds can't be given a priority outside the allowed range
+	priority = models.MaxBuildPriority + 1
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	invalid, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, &models.BuildOptions{Priority: &priority})
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusFailed, invalid.Status)
+
+	// Repo settings give builds a priority based on their ref
+	_, err = app.RepoService.UpdateBuildPrioritySettings(ctx, repo.ID, dto.UpdateBuildPrioritySettings{
+		Settings: &models.BuildPrioritySettings{
+			Default: -5,
+			Refs:    []*models.RefBuildPriority{{Pattern: "^refs/tags/v.*$", Priority: 50}},
+		},
+	})
+	require.NoError(t, err)
+	branch := enqueue(referencedata.TestRef, nil)
+	require.Equal(t, -5, branch.Priority)
+	release := enqueue("refs/tags/v1.0.0", nil)
+	require.Equal(t, 50, release.Priority)
+	requireNextJobFrom(release)
+
+	// A queued build can be bumped ahead of other builds
+	bumped, err := app.QueueService.UpdateBuildPriority(ctx, nil, branch.ID, dto.UpdateBuildPriority{Priority: 100})
+	require.NoError(t, err)
+	require.Equal(t, 100, bumped.Priority)
+	requireNextJobFrom(branch)
+
+	// Finished builds can't be reprioritized
+	_, err = app.QueueService.UpdateBuildPriority(ctx, nil, invalid.ID, dto.UpdateBuildPriority{Priority: 100})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+}
@@ -0,0 +1,80 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestCloneBuild(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	_ = server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	build := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	_, _, err = app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeDynamicJobYAML("dynamic", "deploy", "linux", "staging-key"), models.ConfigTypeYAML)
+	require.NoError(t, err)
+
+	// Builds can't be cloned until they have finished
+	_, err = app.QueueService.CloneBuild(ctx, nil, build.ID, dto.CloneBuild{})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	from, err := app.BuildService.Read(ctx, nil, build.ID)
+	require.NoError(t, err)
+	from.Status = models.WorkflowStatusSucceeded
+	err = app.BuildService.Update(ctx, nil, from)
+	require.NoError(t, err)
+	fromGraph, err := app.QueueService.ReadQueuedBuild(ctx, nil, build.ID)
+	require.NoError(t, err)
+
+	// The clone should contain the dynamic job, with the environment overrides applied to every job
+	environment := []*models.EnvVar{
+		{Name: "TOKEN", SecretString: models.SecretString{ValueFromSecret: "prod-key"}},
+		{Name: "TARGET", SecretString: models.SecretString{Value: "production"}},
+	}
+	clone, err := app.QueueService.CloneBuild(ctx, nil, build.ID, dto.CloneBuild{Environment: environment})
+	require.NoError(t, err)
+	require.Equal(t, build.ID, clone.ClonedFromBuildID)
+	require.Equal(t, build.CommitID, clone.CommitID)
+	require.Equal(t, build.Ref, clone.Ref)
+	require.NotEqual(t, build.Name, clone.Name)
+
+	cloneGraph, err := app.QueueService.ReadQueuedBuild(ctx, nil, clone.ID)
+	require.NoError(t, err)
+	require.Len(t, cloneGraph.Jobs, len(fromGraph.Jobs))
+	for _, fromJob := range fromGraph.Jobs {
+		var cloneJob *dto.JobGraph
+		for _, jGraph := range cloneGraph.Jobs {
+			if jGraph.GetFQN() == fromJob.GetFQN() {
+				cloneJob = jGraph
+			}
+		}
+		require.NotNil(t, cloneJob, "Expected job %s in cloned build", fromJob.GetFQN())
+		require.Len(t, cloneJob.Steps, len(fromJob.Steps))
+		env := map[string]models.SecretString{}
+		for _, envVar := range cloneJob.Environment {
+			env[envVar.Name] = envVar.SecretString
+		}
+		require.Equal(t, "prod-key", env["TOKEN"].ValueFromSecret)
+		require.Equal(t, "production", env["TARGET"].Value)
+	}
+
+	// When the jobs that added dynamic jobs run again, the jobs that are already in the clone are ignored
+	_, newJobs, err := app.QueueService.AddConfigToBuild(ctx, nil, clone.ID, makeDynamicJobYAML("dynamic", "deploy", "linux", "staging-key"), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, newJobs, 0)
+	_, newJobs, err = app.QueueService.AddConfigToBuild(ctx, nil, clone.ID, makeDynamicJobYAML("extra", "deploy", "linux", "staging-key"), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, newJobs, 1)
+}
@@ -0,0 +1,128 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestConditions(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	buildDef := &models.BuildDefinition{
+		Jobs: []models.JobDefinition{
+			makeConditionalJobDefinition("test", "", nil,
+				makeConditionalStepDefinition("unit", ""),
+				makeConditionalStepDefinition("integration", `env.INTEGRATION == "true"`),
+				makeConditionalStepDefinition("report", "", "integration"),
+			),
+			makeConditionalJobDefinition("deploy", `branch == "main" && !pull_request`, []models.ResourceName{"test"},
+				makeConditionalStepDefinition("deploy", "")),
+			makeConditionalJobDefinition("announce", "", []models.ResourceName{"deploy"},
+				makeConditionalStepDefinition("announce", "")),
+		},
+	}
+	bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, buildDef, "refs/heads/feature", nil)
+	require.NoError(t, err)
+
+	// The deploy job's condition isn't met, so it is skipped along with the job that depends on it
+	jobs, err := app.JobService.ListByBuildID(ctx, nil, bGraph.ID)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+	for _, job := range jobs {
+		expected := models.WorkflowStatusSkipped
+		if job.Name == "test" {
+			expected = models.WorkflowStatusQueued
+		}
+		require.Equal(t, expected, job.Status, "job %s", job.Name)
+		if job.Status == models.WorkflowStatusSkipped {
+			require.NotNil(t, job.Timings.FinishedAt)
+		}
+	}
+
+	// Steps whose condition isn't met are skipped, along with the steps that depend on them
+	testJob, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.ResourceName("test"), testJob.Name)
+	stepStatuses := make(map[models.ResourceName]models.WorkflowStatus)
+	for _, step := range testJob.Steps {
+		stepStatuses[step.Name] = step.Status
+	}
+	require.Equal(t, models.WorkflowStatusSubmitted, stepStatuses["unit"])
+	require.Equal(t, models.WorkflowStatusSkipped, stepStatuses["integration"])
+	require.Equal(t, models.WorkflowStatusSkipped, stepStatuses["report"])
+
+	// Skipped jobs are never handed to a runner
+	_, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.True(t, gerror.IsNotFound(err))
+
+	// The build succeeds once the jobs that weren't skipped succeed
+	for _, step := range testJob.Steps {
+		if step.Status == models.WorkflowStatusSkipped {
+			continue
+		}
+		_, err = app.QueueService.UpdateStepStatus(ctx, nil, step.ID, dto.UpdateStepStatus{Status: models.WorkflowStatusSucceeded})
+		require.NoError(t, err)
+	}
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, testJob.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+	checkBuildStatus(t, app, bGraph.ID, models.WorkflowStatusSucceeded)
+
+	// The deploy job runs for builds of the main branch
+	bGraph, err = app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, buildDef, "refs/heads/main", nil)
+	require.NoError(t, err)
+	jobs, err = app.JobService.ListByBuildID(ctx, nil, bGraph.ID)
+	require.NoError(t, err)
+	for _, job := range jobs {
+		require.Equal(t, models.WorkflowStatusQueued, job.Status, "job %s", job.Name)
+	}
+}
+
+func makeConditionalJobDefinition(name models.ResourceName, condition string, depends []models.ResourceName, steps ...models.StepDefinition) models.JobDefinition {
+	job := models.JobDefinition{
+		JobDefinitionData: models.JobDefinitionData{
+			Name:                    name,
+			Type:                    models.JobTypeDocker,
+			DockerImage:             "alpine",
+			DockerImagePullStrategy: models.DockerPullStrategyDefault,
+			StepExecution:           models.StepExecutionSequential,
+			Condition:               condition,
+			Environment: []*models.EnvVar{
+				{Name: "INTEGRATION", SecretString: models.SecretString{Value: "false"}},
+			},
+		},
+		Steps: steps,
+	}
+	for _, dependency := range depends {
+		job.Depends = append(job.Depends, models.NewJobDependency("", dependency))
+	}
+	return job
+}
+
+func makeConditionalStepDefinition(name models.ResourceName, condition string, depends ...models.ResourceName) models.StepDefinition {
+	step := models.StepDefinition{
+		StepDefinitionData: models.StepDefinitionData{
+			Name:      name,
+			Commands:  models.Commands{"echo 'hello world'"},
+			Condition: condition,
+		},
+	}
+	for _, dependency := range depends {
+		step.Depends = append(step.Depends, models.NewStepDependency(dependency))
+	}
+	return step
+}
@@ -0,0 +1,96 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func makeDynamicJobYAML(name string, workflow string, runsOn string, secret string) []byte {
+	config := `
+version: 0.3
+jobs:
+  - name: ` + name + `
+    workflow: ` + workflow + `
+    type: exec
+    runs_on:
+      - ` + runsOn + `
+    environment:
+      TOKEN:
+        from_secret: ` + secret + `
+    steps:
+      - name: build
+        commands:
+          - echo hello
+`
+	return []byte(config)
+}
+
+func TestDynamicJobRestrictions(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	_ = server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	build := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+
+	// Without restrictions any dynamic job can be added
+	_, _, err = app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeDynamicJobYAML("unrestricted", "deploy", "privileged", "prod-key"), models.ConfigTypeYAML)
+	require.NoError(t, err)
+
+	restrictions := &models.DynamicJobRestrictions{
+		MaxJobs:          len(build.Jobs) + 2,
+		AllowedWorkflows: []models.ResourceName{"test"},
+		ForbiddenLabels:  models.Labels{"privileged"},
+		ForbiddenSecrets: []string{"prod-key"},
+	}
+	repo, err = app.RepoService.UpdateDynamicJobRestrictions(ctx, repo.ID, dto.UpdateDynamicJobRestrictions{Restrictions: restrictions})
+	require.NoError(t, err)
+	require.Equal(t, restrictions, repo.DynamicJobRestrictions)
+
+	// Restrictions must survive an upsert from the SCM
+	_, _, err = app.RepoService.Upsert(ctx, nil, repo)
+	require.NoError(t, err)
+	repo, err = app.RepoService.Read(ctx, nil, repo.ID)
+	require.NoError(t, err)
+	require.Equal(t, restrictions, repo.DynamicJobRestrictions)
+
+	t.Run("ForbiddenWorkflow", func(t *testing.T) {
+		_, _, err := app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeDynamicJobYAML("a", "deploy", "linux", "other"), models.ConfigTypeYAML)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "expected validation error, found: %v", err)
+	})
+
+	t.Run("ForbiddenLabel", func(t *testing.T) {
+		_, _, err := app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeDynamicJobYAML("b", "test", "privileged", "other"), models.ConfigTypeYAML)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "expected validation error, found: %v", err)
+	})
+
+	t.Run("ForbiddenSecret", func(t *testing.T) {
+		_, _, err := app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeDynamicJobYAML("c", "test", "linux", "prod-key"), models.ConfigTypeYAML)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "expected validation error, found: %v", err)
+	})
+
+	t.Run("Allowed", func(t *testing.T) {
+		_, newJobs, err := app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeDynamicJobYAML("d", "test", "linux", "other"), models.ConfigTypeYAML)
+		require.NoError(t, err)
+		require.Len(t, newJobs, 1)
+	})
+
+	t.Run("MaxJobs", func(t *testing.T) {
+		_, _, err := app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeDynamicJobYAML("e", "test", "linux", "other"), models.ConfigTypeYAML)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "expected validation error, found: %v", err)
+	})
+}
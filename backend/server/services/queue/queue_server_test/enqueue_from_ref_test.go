@@ -0,0 +1,89 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+	"github.com/buildbeaver/buildbeaver/server/services/scm/fake_scm"
+)
+
+const enqueueFromRefYAML = `
+version: 0.3
+parameters:
+  - name: target
+    default: staging
+jobs:
+  - name: build
+    docker:
+      image: docker:20.10
+    steps:
+      - name: build
+        commands:
+          - echo build
+`
+
+func TestEnqueueBuildFromRef(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	scmInterface, err := app.SCMRegistry.Get(fake_scm.FakeSCMName)
+	require.NoError(t, err)
+	fakeSCM := scmInterface.(*fake_scm.FakeSCMService)
+	scmUserID, _ := fakeSCM.CreateUser("enqueue-from-ref-user", true)
+	scmRepoID, repoExternalID, err := fakeSCM.CreateRepoForUser(scmUserID, "enqueue-from-ref")
+	require.NoError(t, err)
+	require.NoError(t, fakeSCM.SetRepoFile(scmRepoID, ".buildbeaver.yaml", []byte(enqueueFromRefYAML)))
+	require.NoError(t, fakeSCM.SetRef(scmRepoID, "refs/heads/main", "1111111111111111111111111111111111111111"))
+	require.NoError(t, fakeSCM.SetRef(scmRepoID, "refs/heads/feature", "2222222222222222222222222222222222222222"))
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := referencedata.GenerateRepo("enqueue-from-ref", legalEntity.ID)
+	repo.ExternalID = &repoExternalID
+	repo.DefaultBranch = "main"
+	_, _, err = app.RepoService.Upsert(ctx, nil, repo)
+	require.NoError(t, err)
+
+	// The default branch is built if no ref is specified
+	build, err := app.QueueService.EnqueueBuildFromRef(ctx, repo.ID, "", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusQueued, build.Status)
+	require.Equal(t, "refs/heads/main", build.Ref)
+	require.Equal(t, models.BuildParameters{"target": "staging"}, build.Opts.Parameters)
+	commit, err := app.CommitStore.Read(ctx, nil, build.CommitID)
+	require.NoError(t, err)
+	require.Equal(t, "1111111111111111111111111111111111111111", commit.SHA)
+
+	// Branch names are expanded to refs, and options are passed through to the build
+	build, err = app.QueueService.EnqueueBuildFromRef(ctx, repo.ID, "feature", "", &models.BuildOptions{
+		Parameters: models.BuildParameters{"target": "production"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "refs/heads/feature", build.Ref)
+	require.Equal(t, models.BuildParameters{"target": "production"}, build.Opts.Parameters)
+	commit, err = app.CommitStore.Read(ctx, nil, build.CommitID)
+	require.NoError(t, err)
+	require.Equal(t, "2222222222222222222222222222222222222222", commit.SHA)
+
+	// A specific commit can be built, and commits already known to the server are reused
+	build, err = app.QueueService.EnqueueBuildFromRef(ctx, repo.ID, "main", "2222222222222222222222222222222222222222", nil)
+	require.NoError(t, err)
+	require.Equal(t, "refs/heads/main", build.Ref)
+	require.Equal(t, commit.ID, build.CommitID)
+
+	// Refs and commits the SCM doesn't know about are rejected
+	_, err = app.QueueService.EnqueueBuildFromRef(ctx, repo.ID, "missing", "", nil)
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+	_, err = app.QueueService.EnqueueBuildFromRef(ctx, repo.ID, "", "3333333333333333333333333333333333333333", nil)
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+}
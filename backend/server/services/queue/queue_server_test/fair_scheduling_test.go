@@ -0,0 +1,62 @@
+package queue_server_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestFairScheduling(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	busyRepo := server_test.CreateNamedRepo(t, ctx, app, "busy-repo", legalEntity.ID)
+	quietRepo := server_test.CreateNamedRepo(t, ctx, app, "quiet-repo", legalEntity.ID)
+	require.Equal(t, models.DefaultLegalEntitySchedulingWeight, legalEntity.SchedulingWeight)
+
+	enqueue := func(repo *models.Repo, jobCount int) *dto.BuildGraph {
+		commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+		var jobs []models.JobDefinition
+		for i := 0; i < jobCount; i++ {
+			name := models.ResourceName(fmt.Sprintf("job-%d", i))
+			jobs = append(jobs, makeConditionalJobDefinition(name, "", nil, makeConditionalStepDefinition("run", "")))
+		}
+		bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, &models.BuildDefinition{Jobs: jobs}, "refs/heads/main", nil)
+		require.NoError(t, err)
+		return bGraph
+	}
+	requireNextJobsFrom := func(builds ...*dto.BuildGraph) {
+		for _, build := range builds {
+			job, err := app.QueueService.Dequeue(ctx, runner.ID)
+			require.NoError(t, err)
+			require.Equal(t, build.ID, job.BuildID)
+		}
+	}
+
+	// A large build queued first doesn't stop jobs from another repo's later build being run
+	big := enqueue(busyRepo, 10)
+	small := enqueue(quietRepo, 10)
+	requireNextJobsFrom(big, small, big, small)
+
+	// Jobs keep alternating between the repos while both have jobs queued
+	requireNextJobsFrom(big, small, big, small)
+
+	// Weights must be within the allowed range
+	_, err = app.LegalEntityService.UpdateSchedulingWeight(ctx, legalEntity.ID, dto.UpdateLegalEntitySchedulingWeight{SchedulingWeight: 0})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+	updated, err := app.LegalEntityService.UpdateSchedulingWeight(ctx, legalEntity.ID, dto.UpdateLegalEntitySchedulingWeight{SchedulingWeight: 3})
+	require.NoError(t, err)
+	require.Equal(t, 3, updated.SchedulingWeight)
+}
@@ -0,0 +1,46 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestDockerRegistryRewrites(t *testing.T) {
+	config := server_test.TestConfig(t)
+	config.ImageConfig.RegistryRewrites = map[string]string{"docker.io": "mirror.example.com:5000"}
+	app, cleanup, err := server_test.New(config)
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	job := makeConditionalJobDefinition("test", "", nil, makeConditionalStepDefinition("run", ""))
+	job.Services = models.JobServices{
+		{Name: "postgres", DockerImage: "postgres:14"},
+		{Name: "private", DockerImage: "ghcr.io/org/image:1"},
+	}
+	_, err = app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, &models.BuildDefinition{Jobs: []models.JobDefinition{job}}, "refs/heads/main", nil)
+	require.NoError(t, err)
+
+	// The runner is given the rewritten images...
+	runnable, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, "mirror.example.com:5000/library/alpine", runnable.DockerImage)
+	require.Equal(t, "mirror.example.com:5000/library/postgres:14", runnable.Services[0].DockerImage)
+	require.Equal(t, "ghcr.io/org/image:1", runnable.Services[1].DockerImage, "images from other registries are not rewritten")
+
+	// ...but the stored job keeps the images from the build definition
+	stored, err := app.JobService.Read(ctx, nil, runnable.ID)
+	require.NoError(t, err)
+	require.Equal(t, "alpine", stored.DockerImage)
+	require.Equal(t, "postgres:14", stored.Services[0].DockerImage)
+}
@@ -0,0 +1,106 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestJobDeduplication(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	_, err = app.RepoService.UpdateDeduplicateJobs(ctx, repo.ID, dto.UpdateRepoDeduplicateJobs{DeduplicateJobs: true})
+	require.NoError(t, err)
+
+	buildDef := &models.BuildDefinition{
+		Jobs: []models.JobDefinition{
+			makeConditionalJobDefinition("test", "", nil, makeConditionalStepDefinition("unit", "")),
+		},
+	}
+	enqueue := func() *dto.BuildGraph {
+		bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, buildDef, "refs/heads/main", nil)
+		require.NoError(t, err)
+		return bGraph
+	}
+	readOnlyJob := func(bGraph *dto.BuildGraph) *models.Job {
+		jobs, err := app.JobService.ListByBuildID(ctx, nil, bGraph.ID)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		return jobs[0]
+	}
+	requireNothingToDequeue := func() {
+		_, err := app.QueueService.Dequeue(ctx, runner.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsNotFound(err))
+	}
+	setJobStatus := func(jobID models.JobID, status models.WorkflowStatus) {
+		_, err := app.QueueService.UpdateJobStatus(ctx, nil, jobID, dto.UpdateJobStatus{Status: status})
+		require.NoError(t, err)
+	}
+
+	// An identical job in a newer build attaches to the running job instead of being run
+	first := enqueue()
+	second := enqueue()
+	running, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, first.ID, running.BuildID)
+	setJobStatus(running.ID, models.WorkflowStatusRunning)
+	requireNothingToDequeue()
+	attached := readOnlyJob(second)
+	require.Equal(t, models.WorkflowStatusQueued, attached.Status)
+	require.Equal(t, running.ID, attached.IndirectToJobID)
+	requireNothingToDequeue()
+
+	// The attached job finishes with the running job's result
+	setJobStatus(running.ID, models.WorkflowStatusSucceeded)
+	attached = readOnlyJob(second)
+	require.Equal(t, models.WorkflowStatusSucceeded, attached.Status)
+	steps, err := app.StepService.ListByJobID(ctx, nil, attached.ID)
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	require.Equal(t, models.WorkflowStatusSucceeded, steps[0].Status)
+	build, err := app.BuildService.Read(ctx, nil, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusSucceeded, build.Status)
+
+	// Jobs attached to a job that is canceled are detached and run themselves
+	third := enqueue()
+	fourth := enqueue()
+	running, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, third.ID, running.BuildID)
+	requireNothingToDequeue()
+	setJobStatus(running.ID, models.WorkflowStatusCanceled)
+	detached := readOnlyJob(fourth)
+	require.Equal(t, models.WorkflowStatusQueued, detached.Status)
+	require.False(t, detached.IndirectToJobID.Valid())
+	dequeued, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, detached.ID, dequeued.ID)
+	setJobStatus(dequeued.ID, models.WorkflowStatusSucceeded)
+
+	// Builds with the force option always run their jobs
+	fifth := enqueue()
+	sixth, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, buildDef, "refs/heads/main", &models.BuildOptions{Force: true})
+	require.NoError(t, err)
+	running, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, fifth.ID, running.BuildID)
+	dequeued, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, sixth.ID, dequeued.BuildID)
+}
@@ -0,0 +1,142 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestJobResources(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	// The runner has 2 CPUs and 4GiB of memory, and doesn't limit disk space
+	runner.SetCapacity(models.Resources{CPUMillis: 2000, MemoryBytes: 4 << 30})
+	runner.ETag = models.ETagAny
+	runner, err = app.RunnerService.Update(ctx, nil, runner)
+	require.NoError(t, err)
+
+	makeJob := func(name models.ResourceName, resources models.Resources) models.JobDefinition {
+		job := makeConditionalJobDefinition(name, "", nil, makeConditionalStepDefinition("run", ""))
+		job.SetResources(resources)
+		return job
+	}
+	enqueue := func(jobs ...models.JobDefinition) *dto.BuildGraph {
+		bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, &models.BuildDefinition{Jobs: jobs}, "refs/heads/main", nil)
+		require.NoError(t, err)
+		return bGraph
+	}
+	dequeue := func() *dto.RunnableJob {
+		job, err := app.QueueService.Dequeue(ctx, runner.ID)
+		require.NoError(t, err)
+		return job
+	}
+	requireNothingToDequeue := func() {
+		_, err := app.QueueService.Dequeue(ctx, runner.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsNotFound(err))
+	}
+	finish := func(jobID models.JobID) {
+		_, err := app.QueueService.UpdateJobStatus(ctx, nil, jobID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+		require.NoError(t, err)
+	}
+
+	// A job requiring more than the runner's total capacity fails, since no runner could ever run it
+	tooBig := enqueue(makeJob("too-big", models.Resources{MemoryBytes: 8 << 30}))
+	require.Equal(t, models.WorkflowStatusFailed, tooBig.Status)
+
+	// Jobs that fit in the capacity left by the runner's other jobs are dequeued; others wait
+	enqueue(
+		makeJob("big", models.Resources{CPUMillis: 1500, MemoryBytes: 1 << 30}),
+		makeJob("small", models.Resources{CPUMillis: 1000}),
+		makeJob("unconstrained", models.Resources{}),
+	)
+	running := map[models.ResourceName]*dto.RunnableJob{}
+	for i := 0; i < 2; i++ {
+		job := dequeue()
+		running[job.Name] = job
+	}
+	requireNothingToDequeue()
+	require.Contains(t, running, models.ResourceName("unconstrained"), "jobs without requirements always fit")
+	require.False(t, running["big"] != nil && running["small"] != nil, "big and small jobs don't fit together")
+
+	// Once the job holding the CPU finishes, the other one can run
+	for name, job := range running {
+		if name != "unconstrained" {
+			finish(job.ID)
+		}
+	}
+	next := dequeue()
+	require.Contains(t, []models.ResourceName{"big", "small"}, next.Name)
+	require.NotContains(t, running, next.Name)
+	requireNothingToDequeue()
+}
+
+func TestJobGPUs(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	cpuRunner := server_test.CreateRunner(t, ctx, app, "cpu-runner", legalEntity.ID, nil)
+	gpuRunner := server_test.CreateRunner(t, ctx, app, "gpu-runner", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	// The GPU runner has 2 GPUs; the other runner doesn't advertise any capacity so has no GPUs
+	gpuRunner.SetCapacity(models.Resources{GPUs: 2})
+	gpuRunner.ETag = models.ETagAny
+	gpuRunner, err = app.RunnerService.Update(ctx, nil, gpuRunner)
+	require.NoError(t, err)
+
+	makeJob := func(name models.ResourceName, gpus int64) models.JobDefinition {
+		job := makeConditionalJobDefinition(name, "", nil, makeConditionalStepDefinition("run", ""))
+		job.SetResources(models.Resources{GPUs: gpus})
+		return job
+	}
+	enqueue := func(jobs ...models.JobDefinition) *dto.BuildGraph {
+		bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, &models.BuildDefinition{Jobs: jobs}, "refs/heads/main", nil)
+		require.NoError(t, err)
+		return bGraph
+	}
+	requireNothingToDequeue := func(runnerID models.RunnerID) {
+		_, err := app.QueueService.Dequeue(ctx, runnerID)
+		require.Error(t, err)
+		require.True(t, gerror.IsNotFound(err))
+	}
+
+	// A job requiring more GPUs than any runner has fails
+	tooMany := enqueue(makeJob("too-many", 4))
+	require.Equal(t, models.WorkflowStatusFailed, tooMany.Status)
+
+	enqueue(makeJob("train", 2), makeJob("infer", 1))
+
+	// The runner without GPUs never gets GPU jobs
+	requireNothingToDequeue(cpuRunner.ID)
+
+	// The GPU runner runs one job at a time, since together they need more GPUs than it has
+	first, err := app.QueueService.Dequeue(ctx, gpuRunner.ID)
+	require.NoError(t, err)
+	require.Contains(t, []models.ResourceName{"train", "infer"}, first.Name)
+	requireNothingToDequeue(gpuRunner.ID)
+
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, first.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+	require.NoError(t, err)
+	second, err := app.QueueService.Dequeue(ctx, gpuRunner.ID)
+	require.NoError(t, err)
+	require.NotEqual(t, first.Name, second.Name)
+}
@@ -0,0 +1,46 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestJobTimeout(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	short := makeConditionalJobDefinition("short", "", nil, makeConditionalStepDefinition("run", ""))
+	short.TimeoutSeconds = 1
+	long := makeConditionalJobDefinition("long", "", nil, makeConditionalStepDefinition("run", ""))
+	bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, &models.BuildDefinition{Jobs: []models.JobDefinition{short, long}}, "refs/heads/main", nil)
+	require.NoError(t, err)
+
+	// Only the job with its own short timeout is failed; the other job uses the (much longer) default
+	time.Sleep(1100 * time.Millisecond)
+	nrJobsTimedOut := checkForTimeouts(t, app, time.Hour)
+	require.Equal(t, 1, nrJobsTimedOut)
+
+	for _, jGraph := range bGraph.Jobs {
+		job, err := app.JobService.Read(ctx, nil, jGraph.ID)
+		require.NoError(t, err)
+		if job.Name == "short" {
+			require.Equal(t, models.WorkflowStatusFailed, job.Status)
+			require.Equal(t, int64(1), job.TimeoutSeconds)
+		} else {
+			require.Equal(t, models.WorkflowStatusQueued, job.Status)
+		}
+	}
+}
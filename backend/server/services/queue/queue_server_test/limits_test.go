@@ -0,0 +1,115 @@
+package queue_server_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+// makeLimitsYAML returns a build config containing the specified number of jobs in a workflow, each job
+// having the specified number of steps.
+func makeLimitsYAML(workflow string, jobs int, steps int) []byte {
+	var sb strings.Builder
+	sb.WriteString("version: 0.3\njobs:\n")
+	for i := 0; i < jobs; i++ {
+		sb.WriteString(fmt.Sprintf("  - name: job-%d\n    workflow: %s\n    type: exec\n    steps:\n", i, workflow))
+		for j := 0; j < steps; j++ {
+			sb.WriteString(fmt.Sprintf("      - name: step-%d\n        commands:\n          - echo hello\n", j))
+		}
+	}
+	return []byte(sb.String())
+}
+
+func TestLimitsExceeded(t *testing.T) {
+	config := server_test.TestConfig(t)
+	config.LimitsConfig.MaxJobsPerBuild = 3
+	config.LimitsConfig.MaxStepsPerJob = 2
+	app, cleanup, err := server_test.New(config)
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	enqueue := func(config []byte) models.BuildID {
+		commit := referencedata.GenerateCommit(repo.ID, legalEntity.ID)
+		commit.Config = config
+		err := app.CommitStore.Create(ctx, nil, commit)
+		require.NoError(t, err)
+		bGraph, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+		require.NoError(t, err)
+		return bGraph.ID
+	}
+	requireLimitExceededEvent := func(buildID models.BuildID, workflow models.ResourceName, jobName models.ResourceName) *models.Event {
+		events, err := app.EventService.FetchEvents(ctx, nil, buildID, 0, 100)
+		require.NoError(t, err)
+		for _, event := range events {
+			if event.Type == models.LimitExceededEvent {
+				require.Equal(t, workflow, event.Workflow)
+				require.Equal(t, jobName, event.JobName)
+				return event
+			}
+		}
+		require.Fail(t, "expected a LimitExceeded event for the build")
+		return nil
+	}
+
+	t.Run("StepsInCommittedBuild", func(t *testing.T) {
+		buildID := enqueue(makeLimitsYAML("test", 1, 3))
+		build, err := app.BuildService.Read(ctx, nil, buildID)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusFailed, build.Status)
+		require.Contains(t, build.Error.Error(), "Too many steps in job 'test.job-0'; the job has 3 steps but a maximum of 2")
+		event := requireLimitExceededEvent(buildID, "test", "job-0")
+		require.Contains(t, event.Payload, "Too many steps in job 'test.job-0'")
+	})
+
+	t.Run("JobsInCommittedBuild", func(t *testing.T) {
+		buildID := enqueue(makeLimitsYAML("test", 4, 1))
+		build, err := app.BuildService.Read(ctx, nil, buildID)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusFailed, build.Status)
+		require.Contains(t, build.Error.Error(), "the build would have 4 jobs but a maximum of 3 jobs are allowed in a build (jobs per workflow: workflow 'test': 4)")
+		requireLimitExceededEvent(buildID, "test", "")
+	})
+
+	t.Run("DynamicJobs", func(t *testing.T) {
+		buildID := enqueue(makeLimitsYAML("generate", 1, 1))
+
+		_, _, err := app.QueueService.AddConfigToBuild(ctx, nil, buildID, makeLimitsYAML("deploy", 1, 5), models.ConfigTypeYAML)
+		require.Error(t, err)
+		limitErr := gerror.ToLimitExceeded(err)
+		require.NotNil(t, limitErr, "expected limit exceeded error, found: %v", err)
+		details := limitErr.Details()
+		require.Equal(t, "max_steps_per_job", details[gerror.LimitDetailKey].Value())
+		require.Equal(t, 2, details[gerror.MaxDetailKey].Value())
+		require.Equal(t, 5, details[gerror.CountDetailKey].Value())
+		require.Equal(t, "deploy", details[gerror.WorkflowDetailKey].Value())
+		require.Equal(t, "job-0", details[gerror.JobDetailKey].Value())
+		requireLimitExceededEvent(buildID, "deploy", "job-0")
+
+		_, _, err = app.QueueService.AddConfigToBuild(ctx, nil, buildID, makeLimitsYAML("deploy", 3, 1), models.ConfigTypeYAML)
+		require.Error(t, err)
+		limitErr = gerror.ToLimitExceeded(err)
+		require.NotNil(t, limitErr, "expected limit exceeded error, found: %v", err)
+		require.Equal(t, "max_jobs_per_build", limitErr.Details()[gerror.LimitDetailKey].Value())
+		require.Equal(t, 4, limitErr.Details()[gerror.CountDetailKey].Value())
+		require.Equal(t, "deploy", limitErr.Details()[gerror.WorkflowDetailKey].Value())
+		require.Contains(t, limitErr.Message(), "workflow 'deploy': 3, workflow 'generate': 1")
+
+		// Jobs within the limits can still be added
+		_, newJobs, err := app.QueueService.AddConfigToBuild(ctx, nil, buildID, makeLimitsYAML("deploy", 2, 2), models.ConfigTypeYAML)
+		require.NoError(t, err)
+		require.Len(t, newJobs, 2)
+	})
+}
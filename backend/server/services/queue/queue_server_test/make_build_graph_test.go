@@ -0,0 +1,119 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
+)
+
+// mapTemplateLoader loads templates from a map of template path to contents.
+type mapTemplateLoader map[string]string
+
+func (l mapTemplateLoader) LoadTemplate(ref parser.TemplateReference) ([]byte, error) {
+	contents, ok := l[ref.Path]
+	if !ok {
+		return nil, gerror.NewErrNotFound("Template not found")
+	}
+	return []byte(contents), nil
+}
+
+func TestParseBuildConfigAndMakeBuildGraph(t *testing.T) {
+	config := server_test.TestConfig(t)
+	config.LimitsConfig.MaxStepsPerJob = 2
+	app, cleanup, err := server_test.New(config)
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	const buildConfig = `
+version: "0.3"
+include:
+  - ci/lint.yml
+jobs:
+  - name: build
+    type: exec
+    steps:
+      - name: go
+        commands: go build ./...
+  - name: test
+    workflow: unit
+    depends: [ build, deploy.publish ]
+    type: exec
+    steps:
+      - name: go
+        commands: go test ./...
+`
+	loader := mapTemplateLoader{"ci/lint.yml": `
+jobs:
+  - name: lint
+    depends: [ build ]
+    type: exec
+    steps:
+      - name: vet
+        commands: go vet ./...
+`}
+
+	// Templates are only supported if a loader is provided
+	_, err = app.QueueService.ParseBuildConfig([]byte(buildConfig), models.ConfigTypeYAML, nil)
+	require.Error(t, err)
+	buildDef, err := app.QueueService.ParseBuildConfig([]byte(buildConfig), models.ConfigTypeYAML, loader)
+	require.NoError(t, err)
+	require.Len(t, buildDef.Jobs, 3)
+
+	// The graph is validated but not persisted; dependencies on jobs in other workflows are allowed
+	graph, err := app.QueueService.MakeBuildGraph(models.NewRepoID(), "HEAD", buildDef)
+	require.NoError(t, err)
+	require.Len(t, graph.Jobs, 3)
+	_, err = app.BuildService.Read(ctx, nil, graph.ID)
+	require.True(t, gerror.IsNotFound(err))
+
+	// Parse errors report where the problem is, including within templates
+	loader["ci/lint.yml"] = `
+jobs:
+  - name: lint
+    type: exec
+    steps:
+      - name: vet
+        commands: go vet ./...
+      - name: fmt
+        commands: go fmt ./...
+      - name: mod
+        commands: go mod tidy
+`
+	_, err = app.QueueService.ParseBuildConfig([]byte(buildConfig), models.ConfigTypeYAML, loader)
+	require.Error(t, err)
+	require.True(t, gerror.IsLimitExceeded(err))
+	parseErr := parser.AsParseError(err)
+	require.NotNil(t, parseErr)
+	require.Equal(t, "ci/lint.yml", parseErr.File)
+	require.Greater(t, parseErr.Line, 0)
+
+	// Cycles between jobs are reported when making the graph
+	buildDef, err = app.QueueService.ParseBuildConfig([]byte(`
+version: "0.3"
+jobs:
+  - name: a
+    depends: [ b ]
+    type: exec
+    steps:
+      - name: go
+        commands: go build ./...
+  - name: b
+    depends: [ a ]
+    type: exec
+    steps:
+      - name: go
+        commands: go build ./...
+`), models.ConfigTypeYAML, nil)
+	require.NoError(t, err)
+	_, err = app.QueueService.MakeBuildGraph(models.NewRepoID(), "HEAD", buildDef)
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+	require.Contains(t, err.Error(), "Cycle: ")
+}
@@ -0,0 +1,76 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+	"github.com/buildbeaver/buildbeaver/server/services/scm/fake_scm"
+)
+
+func TestPathFilters(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	scmInterface, err := app.SCMRegistry.Get(fake_scm.FakeSCMName)
+	require.NoError(t, err)
+	fakeSCM := scmInterface.(*fake_scm.FakeSCMService)
+	scmUserID, _ := fakeSCM.CreateUser("path-filters-user", true)
+	scmRepoID, repoExternalID, err := fakeSCM.CreateRepoForUser(scmUserID, "path-filters")
+	require.NoError(t, err)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := referencedata.GenerateRepo("path-filters", legalEntity.ID)
+	repo.ExternalID = &repoExternalID
+	_, _, err = app.RepoService.Upsert(ctx, nil, repo)
+	require.NoError(t, err)
+	docsCommit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	require.NoError(t, fakeSCM.SetChangedFiles(scmRepoID, docsCommit.SHA, []string{"docs/index.md", "README.md"}))
+
+	backendJob := makeConditionalJobDefinition("backend", "", nil, makeConditionalStepDefinition("test", ""))
+	backendJob.OnlyPaths = models.PathPatterns{"backend/**"}
+	docsJob := makeConditionalJobDefinition("docs", "", nil, makeConditionalStepDefinition("build", ""))
+	docsJob.OnlyPaths = models.PathPatterns{"docs/**"}
+	codeJob := makeConditionalJobDefinition("code", "", nil, makeConditionalStepDefinition("lint", ""))
+	codeJob.IgnorePaths = models.PathPatterns{"docs/**", "**/*.md"}
+	deployJob := makeConditionalJobDefinition("deploy", "", []models.ResourceName{"backend"}, makeConditionalStepDefinition("deploy", ""))
+	buildDef := &models.BuildDefinition{Jobs: []models.JobDefinition{backendJob, docsJob, codeJob, deployJob}}
+
+	// Documentation-only changes skip the jobs that filter them out, along with any jobs depending on them
+	bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, docsCommit.ID, buildDef, "refs/heads/main", nil)
+	require.NoError(t, err)
+	checkJobStatuses(t, app, bGraph.ID, map[models.ResourceName]models.WorkflowStatus{
+		"backend": models.WorkflowStatusSkipped,
+		"docs":    models.WorkflowStatusQueued,
+		"code":    models.WorkflowStatusSkipped,
+		"deploy":  models.WorkflowStatusSkipped,
+	})
+
+	// Backend changes run the backend jobs
+	backendCommit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	require.NoError(t, fakeSCM.SetChangedFiles(scmRepoID, backendCommit.SHA, []string{"backend/main.go", "docs/index.md"}))
+	bGraph, err = app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, backendCommit.ID, buildDef, "refs/heads/main", nil)
+	require.NoError(t, err)
+	checkJobStatuses(t, app, bGraph.ID, map[models.ResourceName]models.WorkflowStatus{
+		"backend": models.WorkflowStatusQueued,
+		"docs":    models.WorkflowStatusQueued,
+		"code":    models.WorkflowStatusQueued,
+		"deploy":  models.WorkflowStatusQueued,
+	})
+}
+
+func checkJobStatuses(t *testing.T, app *server_test.TestServer, buildID models.BuildID, expected map[models.ResourceName]models.WorkflowStatus) {
+	jobs, err := app.JobService.ListByBuildID(context.Background(), nil, buildID)
+	require.NoError(t, err)
+	require.Len(t, jobs, len(expected))
+	for _, job := range jobs {
+		require.Equal(t, expected[job.Name], job.Status, "job %s", job.Name)
+	}
+}
@@ -106,6 +106,63 @@ func TestDequeueWithLabels(t *testing.T) {
 	require.Equal(t, buildDef.Jobs[0].Name, job.Name)
 }
 
+func TestDequeueCustomJobType(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	runnerWithoutPlugin := server_test.CreateRunner(t, ctx, app, "without-plugin", legalEntity.ID, nil)
+
+	buildDef := &models.BuildDefinition{
+		Jobs: []models.JobDefinition{
+			{
+				JobDefinitionData: models.JobDefinitionData{
+					Name:          "plan",
+					Type:          "terraform",
+					StepExecution: models.StepExecutionSequential,
+				},
+				Steps: []models.StepDefinition{{
+					StepDefinitionData: models.StepDefinitionData{
+						Name: "plan",
+						Commands: models.Commands{
+							"terraform plan",
+						},
+					},
+				}},
+			},
+		}}
+
+	// No runner has a plugin for the job type, so the job fails straight away
+	build, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, buildDef, "refs/heads/master", nil)
+	require.NoError(t, err)
+	require.NotNil(t, build.Error)
+	require.Equal(t, models.WorkflowStatusFailed, build.Status)
+
+	runnerWithPlugin := server_test.CreateRunner(t, ctx, app, "with-plugin", legalEntity.ID, nil)
+	runnerWithPlugin.SupportedJobTypes = append(runnerWithPlugin.SupportedJobTypes, "terraform")
+	_, err = app.RunnerService.Update(ctx, nil, runnerWithPlugin)
+	require.NoError(t, err)
+
+	build, err = app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, buildDef, "refs/heads/master", nil)
+	require.NoError(t, err)
+	require.Nil(t, build.Error)
+	require.Equal(t, models.WorkflowStatusQueued, build.Status)
+
+	// Only the runner that advertises the job type can dequeue the job
+	job, err := app.QueueService.Dequeue(ctx, runnerWithoutPlugin.ID)
+	require.Error(t, err)
+	require.Nil(t, job)
+	job, err = app.QueueService.Dequeue(ctx, runnerWithPlugin.ID)
+	require.NoError(t, err)
+	require.Equal(t, buildDef.Jobs[0].Name, job.Name)
+	require.Equal(t, models.JobType("terraform"), job.Type)
+}
+
 func TestNoCompatibleRunners(t *testing.T) {
 
 	app, cleanup, err := server_test.New(server_test.TestConfig(t))
@@ -0,0 +1,114 @@
+package queue_server_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestRunnerLossRecovery(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	lostRunner := server_test.CreateRunner(t, ctx, app, "lost-runner", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	heartbeat := func(runnerID models.RunnerID) {
+		_, err := app.RunnerService.UpdateHealth(ctx, nil, runnerID, models.RunnerHealthReport{
+			Time:           models.NewTime(time.Now()),
+			DiskPath:       "/tmp",
+			DiskFreeBytes:  10 * 1024 * 1024 * 1024,
+			DiskTotalBytes: 100 * 1024 * 1024 * 1024,
+		})
+		require.NoError(t, err)
+	}
+	readLog := func(logID models.LogDescriptorID) string {
+		plaintext := true
+		reader, err := app.LogService.ReadData(ctx, logID, &models.LogSearch{Plaintext: &plaintext})
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	flaky := makeConditionalJobDefinition("flaky", "", nil, makeConditionalStepDefinition("unit", ""))
+	retried := makeConditionalJobDefinition("retried", "", nil, makeConditionalStepDefinition("unit", ""))
+	retried.RetryOnRunnerLoss = true
+	bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID,
+		&models.BuildDefinition{Jobs: []models.JobDefinition{flaky, retried}}, "refs/heads/main", nil)
+	require.NoError(t, err)
+
+	// Jobs held by a runner that is still sending heartbeats are left alone
+	heartbeat(lostRunner.ID)
+	var running []*models.Job
+	for i := 0; i < 2; i++ {
+		job, err := app.QueueService.Dequeue(ctx, lostRunner.ID)
+		require.NoError(t, err)
+		updated, err := app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusRunning})
+		require.NoError(t, err)
+		running = append(running, updated)
+	}
+	recovered, err := app.QueueService.RecoverOrphanedJobs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, recovered)
+
+	// Once the runner stops sending heartbeats its jobs are failed, or requeued if they retry on runner loss
+	err = app.RunnerService.DetectOfflineRunners(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	recovered, err = app.QueueService.RecoverOrphanedJobs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, recovered)
+
+	jobs, err := app.JobService.ListByBuildID(ctx, nil, bGraph.ID)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	for _, job := range jobs {
+		steps, err := app.StepService.ListByJobID(ctx, nil, job.ID)
+		require.NoError(t, err)
+		require.Len(t, steps, 1)
+		switch job.Name {
+		case "flaky":
+			require.Equal(t, models.WorkflowStatusFailed, job.Status)
+			require.NotNil(t, job.Error)
+			require.Contains(t, job.Error.Error(), "stopped sending heartbeats")
+			require.Equal(t, models.WorkflowStatusFailed, steps[0].Status)
+			require.Contains(t, readLog(steps[0].LogDescriptorID), "stopped sending heartbeats")
+		case "retried":
+			require.Equal(t, models.WorkflowStatusQueued, job.Status)
+			require.Equal(t, 1, job.RunnerLossRetries)
+			require.False(t, job.RunnerID.Valid())
+			require.Equal(t, models.WorkflowStatusQueued, steps[0].Status)
+			for _, previous := range running {
+				if previous.ID == job.ID {
+					require.NotEqual(t, previous.LogDescriptorID, job.LogDescriptorID, "requeued jobs get a new log")
+					require.Contains(t, readLog(previous.LogDescriptorID), "queued to run again")
+				}
+			}
+		default:
+			t.Fatalf("unexpected job %q", job.Name)
+		}
+	}
+
+	// The requeued job is run from scratch by another runner
+	healthyRunner := server_test.CreateRunner(t, ctx, app, "healthy-runner", legalEntity.ID, nil)
+	heartbeat(healthyRunner.ID)
+	job, err := app.QueueService.Dequeue(ctx, healthyRunner.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.ResourceName("retried"), job.Name)
+	require.Equal(t, models.WorkflowStatusSubmitted, job.Steps[0].Status)
+	recovered, err = app.QueueService.RecoverOrphanedJobs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, recovered)
+}
@@ -0,0 +1,63 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+func TestSkipCI(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	createCommit := func(message string) *models.Commit {
+		commit := referencedata.GenerateCommit(repo.ID, legalEntity.ID)
+		commit.Message = message
+		err := app.CommitStore.Create(ctx, nil, commit)
+		require.NoError(t, err)
+		return commit
+	}
+	enqueue := func(commit *models.Commit, opts *models.BuildOptions) *dto.BuildGraph {
+		build, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, opts)
+		require.NoError(t, err)
+		return build
+	}
+	requireSkipped := func(build *dto.BuildGraph) {
+		require.Equal(t, models.WorkflowStatusSkipped, build.Status)
+		require.Nil(t, build.Error)
+		require.Empty(t, build.Jobs)
+		require.NotNil(t, build.Timings.FinishedAt)
+	}
+
+	// Commits with a skip marker in their message are recorded as a skipped build
+	for _, message := range []string{"Fix typo [skip ci]", "[CI SKIP] Update readme"} {
+		requireSkipped(enqueue(createCommit(message), nil))
+	}
+	build, err := app.BuildService.Read(ctx, nil, enqueue(createCommit("Fix typo [skip ci]"), nil).ID)
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusSkipped, build.Status)
+
+	// Builds that are explicitly requested ignore skip markers
+	commit := createCommit("Fix typo [skip ci]")
+	build2 := enqueue(commit, &models.BuildOptions{IgnoreSkipMarkers: true})
+	require.Equal(t, models.WorkflowStatusQueued, build2.Status)
+
+	// Repos can configure their own pattern for commits to skip
+	skipPattern := `^docs(\(.*\))?:`
+	_, err = app.BuildRuleSetService.Update(ctx, nil, repo.ID, dto.UpdateBuildRuleSet{SkipPattern: &skipPattern})
+	require.NoError(t, err)
+	requireSkipped(enqueue(createCommit("docs(readme): add install steps"), nil))
+	require.Equal(t, models.WorkflowStatusQueued, enqueue(createCommit("fix: docs: link"), nil).Status)
+}
@@ -0,0 +1,219 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+	"github.com/buildbeaver/buildbeaver/server/services/scm/fake_scm"
+)
+
+func TestToolchains(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	otherLegalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "other", "Other", "other@example.com")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	register := dto.RegisterToolchain{
+		LegalEntityID: legalEntity.ID,
+		Name:          "go-builder",
+		Version:       "1.4",
+		Image:         "registry.example.com:5000/go-builder:1.4",
+		Digest:        digest,
+	}
+	toolchain, err := app.ToolchainService.Register(ctx, nil, register)
+	require.NoError(t, err)
+
+	// Registering the same version again is a no-op, but a version can't be changed once registered
+	again, err := app.ToolchainService.Register(ctx, nil, register)
+	require.NoError(t, err)
+	require.Equal(t, toolchain.ID, again.ID)
+	changed := register
+	changed.Digest = ""
+	_, err = app.ToolchainService.Register(ctx, nil, changed)
+	require.Error(t, err)
+	require.True(t, gerror.IsAlreadyExists(err))
+
+	// A toolchain registered by another legal entity isn't visible to this legal entity's builds
+	_, err = app.ToolchainService.Register(ctx, nil, dto.RegisterToolchain{
+		LegalEntityID: otherLegalEntity.ID,
+		Name:          "other-builder",
+		Version:       "1.0",
+		Image:         "other-builder:1.0",
+	})
+	require.NoError(t, err)
+
+	enqueue := func(toolchain string) *dto.BuildGraph {
+		job := makeConditionalJobDefinition("build", "", nil, makeConditionalStepDefinition("run", ""))
+		job.DockerImage = ""
+		job.DockerToolchain = toolchain
+		bGraph, err := app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID, &models.BuildDefinition{Jobs: []models.JobDefinition{job}}, "refs/heads/main", nil)
+		require.NoError(t, err)
+		return bGraph
+	}
+
+	// The job runs in the toolchain's image, pinned to its digest
+	enqueue("go-builder@1.4")
+	job, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, "registry.example.com:5000/go-builder@"+digest, job.DockerImage)
+	stored, err := app.JobService.Read(ctx, nil, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "go-builder@1.4", stored.DockerToolchain)
+	require.Equal(t, job.DockerImage, stored.DockerImage)
+
+	// Jobs referring to toolchains that aren't registered for the repo's owner fail rather than run
+	for _, ref := range []string{"go-builder@9.9", "other-builder@1.0"} {
+		bGraph := enqueue(ref)
+		_, err = app.QueueService.Dequeue(ctx, runner.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsNotFound(err))
+		failed, err := app.JobService.Read(ctx, nil, bGraph.Jobs[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusFailed, failed.Status)
+		require.Contains(t, failed.Error.Error(), ref)
+	}
+}
+
+const toolchainRebuildYAML = `
+version: 0.3
+jobs:
+  - name: build
+    workflow: app
+    type: docker
+    docker:
+      toolchain: go-builder@1.0
+    steps:
+      - name: run
+        commands:
+          - go build ./...
+  - name: build
+    workflow: go-builder-image
+    type: exec
+    steps:
+      - name: build
+        commands:
+          - docker build -f docker/go-builder/Dockerfile .
+`
+
+func TestToolchainRebuilds(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	scmInterface, err := app.SCMRegistry.Get(fake_scm.FakeSCMName)
+	require.NoError(t, err)
+	fakeSCM := scmInterface.(*fake_scm.FakeSCMService)
+	scmUserID, _ := fakeSCM.CreateUser("toolchain-rebuilds-user", true)
+	scmRepoID, repoExternalID, err := fakeSCM.CreateRepoForUser(scmUserID, "toolchain-rebuilds")
+	require.NoError(t, err)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := referencedata.GenerateRepo("toolchain-rebuilds", legalEntity.ID)
+	repo.ExternalID = &repoExternalID
+	_, _, err = app.RepoService.Upsert(ctx, nil, repo)
+	require.NoError(t, err)
+	createCommit := func(changedFiles ...string) *models.Commit {
+		commit := referencedata.GenerateCommit(repo.ID, legalEntity.ID)
+		commit.Config = []byte(toolchainRebuildYAML)
+		require.NoError(t, app.CommitStore.Create(ctx, nil, commit))
+		require.NoError(t, fakeSCM.SetChangedFiles(scmRepoID, commit.SHA, changedFiles))
+		return commit
+	}
+	enqueue := func(commit *models.Commit) *dto.BuildGraph {
+		bGraph, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, "refs/heads/main", nil)
+		require.NoError(t, err)
+		require.Equal(t, models.WorkflowStatusQueued, bGraph.Status)
+		return bGraph
+	}
+
+	// A Dockerfile can only be recorded for toolchains registered by a build
+	register := dto.RegisterToolchain{
+		Name:            "go-builder",
+		Version:         "1.0",
+		Image:           "go-builder:1.0",
+		Dockerfile:      "docker/go-builder/Dockerfile",
+		RebuildWorkflow: "go-builder-image",
+	}
+	apiRegister := register
+	apiRegister.LegalEntityID = legalEntity.ID
+	_, err = app.ToolchainService.Register(ctx, nil, apiRegister)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	firstBuild := enqueue(createCommit("docker/go-builder/Dockerfile"))
+	toolchain, err := app.ToolchainService.RegisterForBuild(ctx, nil, firstBuild.ID, register)
+	require.NoError(t, err)
+	require.Equal(t, repo.ID, *toolchain.SourceRepoID)
+	rebuilt, err := app.ToolchainService.ListRebuiltOnChangeBySourceRepoID(ctx, nil, repo.ID)
+	require.NoError(t, err)
+	require.Len(t, rebuilt, 1)
+
+	// Commits that don't touch the Dockerfile don't rebuild the toolchain
+	unchanged := enqueue(createCommit("main.go", "docker/other/Dockerfile"))
+	rebuilds, err := app.ToolchainRebuildService.RebuildChangedToolchains(ctx, unchanged.ID)
+	require.NoError(t, err)
+	require.Empty(t, rebuilds)
+
+	// Changing the Dockerfile runs just the rebuild workflow, once per commit
+	changed := enqueue(createCommit("./docker/go-builder/Dockerfile", "main.go"))
+	rebuilds, err = app.ToolchainRebuildService.RebuildChangedToolchains(ctx, changed.ID)
+	require.NoError(t, err)
+	require.Len(t, rebuilds, 1)
+	require.Equal(t, changed.CommitID, rebuilds[0].CommitID)
+	require.Equal(t, []models.NodeFQN{models.NewNodeFQNForWorkflow("go-builder-image")}, rebuilds[0].Opts.NodesToRun)
+	rebuild := rebuilds[0]
+	rebuilds, err = app.ToolchainRebuildService.RebuildChangedToolchains(ctx, changed.ID)
+	require.NoError(t, err)
+	require.Empty(t, rebuilds)
+
+	// Registering the rebuilt image as a new version notifies the repos using the toolchain, on their latest build
+	_, err = app.ToolchainService.RegisterForBuild(ctx, nil, rebuild.ID, dto.RegisterToolchain{
+		Name:            "go-builder",
+		Version:         "1.1",
+		Image:           "go-builder:1.1",
+		Dockerfile:      "docker/go-builder/Dockerfile",
+		RebuildWorkflow: "go-builder-image",
+	})
+	require.NoError(t, err)
+	events, err := app.EventService.FetchEvents(ctx, nil, rebuild.ID, 0, 1000)
+	require.NoError(t, err)
+	var available []*models.Event
+	for _, event := range events {
+		if event.Type == models.ToolchainVersionAvailableEvent {
+			available = append(available, event)
+		}
+	}
+	require.Len(t, available, 1)
+	require.Equal(t, "go-builder@1.1", available[0].Payload)
+	events, err = app.EventService.FetchEvents(ctx, nil, changed.ID, 0, 1000)
+	require.NoError(t, err)
+	for _, event := range events {
+		require.NotEqual(t, models.ToolchainVersionAvailableEvent, event.Type)
+	}
+
+	// Once the latest version stops recording a Dockerfile the toolchain is no longer rebuilt
+	_, err = app.ToolchainService.RegisterForBuild(ctx, nil, rebuild.ID, dto.RegisterToolchain{
+		Name:    "go-builder",
+		Version: "1.2",
+		Image:   "go-builder:1.2",
+	})
+	require.NoError(t, err)
+	rebuilt, err = app.ToolchainService.ListRebuiltOnChangeBySourceRepoID(ctx, nil, repo.ID)
+	require.NoError(t, err)
+	require.Empty(t, rebuilt)
+}
@@ -0,0 +1,135 @@
+package queue_server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func makeUpdatableJobYAML(name string, description string, extra string) []byte {
+	config := `
+version: 0.3
+jobs:
+  - name: ` + name + `
+    workflow: dynamic
+    description: ` + description + `
+    type: exec
+    step_execution: parallel
+` + extra
+	return []byte(config)
+}
+
+const updatableJobSteps = `    steps:
+      - name: build
+        commands:
+          - echo build
+`
+
+func TestUpdateConfigInBuild(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	_ = server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	build := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+
+	_, newJobs, err := app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeUpdatableJobYAML("first", "first job", updatableJobSteps), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, newJobs, 1)
+	first := newJobs[0]
+	_, newJobs, err = app.QueueService.AddConfigToBuild(ctx, nil, build.ID, makeUpdatableJobYAML("second", "second job", updatableJobSteps), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, newJobs, 1)
+	second := newJobs[0]
+
+	requireNotUpdated := func(t *testing.T, config []byte) {
+		_, _, err := app.QueueService.UpdateConfigInBuild(ctx, nil, build.ID, config, models.ConfigTypeYAML)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "expected validation error, found: %v", err)
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		_, updated, err := app.QueueService.UpdateConfigInBuild(ctx, nil, build.ID, makeUpdatableJobYAML("first", "first job", updatableJobSteps), models.ConfigTypeYAML)
+		require.NoError(t, err)
+		require.Len(t, updated, 1)
+		jGraph, err := app.QueueService.ReadJobGraph(ctx, nil, first.ID)
+		require.NoError(t, err)
+		require.Len(t, jGraph.Steps, 1)
+	})
+
+	t.Run("EnvStepsAndDepends", func(t *testing.T) {
+		extra := `    depends: dynamic.second
+    environment:
+      MODE: release
+` + updatableJobSteps + `      - name: test
+        depends: build
+        commands:
+          - echo test
+`
+		_, updated, err := app.QueueService.UpdateConfigInBuild(ctx, nil, build.ID, makeUpdatableJobYAML("first", "first job", extra), models.ConfigTypeYAML)
+		require.NoError(t, err)
+		require.Len(t, updated, 1)
+
+		jGraph, err := app.QueueService.ReadJobGraph(ctx, nil, first.ID)
+		require.NoError(t, err)
+		require.Len(t, jGraph.Steps, 2)
+		require.Len(t, jGraph.Environment, 1)
+		require.Equal(t, "MODE", jGraph.Environment[0].Name)
+		require.NotEqual(t, first.DefinitionDataHash, jGraph.DefinitionDataHash)
+
+		dependencies, err := app.JobService.ListDependencies(ctx, nil, first.ID)
+		require.NoError(t, err)
+		require.Len(t, dependencies, 1)
+		require.Equal(t, second.ID, dependencies[0].ID)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		requireNotUpdated(t, makeUpdatableJobYAML("second", "second job", "    depends: dynamic.first\n"+updatableJobSteps))
+	})
+
+	t.Run("JobSettingChanged", func(t *testing.T) {
+		requireNotUpdated(t, makeUpdatableJobYAML("second", "changed description", updatableJobSteps))
+	})
+
+	t.Run("StepChanged", func(t *testing.T) {
+		steps := `    steps:
+      - name: build
+        commands:
+          - echo changed
+`
+		requireNotUpdated(t, makeUpdatableJobYAML("second", "second job", steps))
+	})
+
+	t.Run("StepRemoved", func(t *testing.T) {
+		steps := `    steps:
+      - name: other
+        commands:
+          - echo other
+`
+		requireNotUpdated(t, makeUpdatableJobYAML("second", "second job", steps))
+	})
+
+	t.Run("UnknownJob", func(t *testing.T) {
+		requireNotUpdated(t, makeUpdatableJobYAML("third", "third job", updatableJobSteps))
+	})
+
+	t.Run("NotQueued", func(t *testing.T) {
+		job, err := app.JobService.Read(ctx, nil, second.ID)
+		require.NoError(t, err)
+		_, err = app.QueueService.UpdateJobStatus(ctx, nil, second.ID, dto.UpdateJobStatus{
+			Status: models.WorkflowStatusCanceled,
+			ETag:   job.ETag,
+		})
+		require.NoError(t, err)
+		requireNotUpdated(t, makeUpdatableJobYAML("second", "second job", "    environment:\n      MODE: release\n"+updatableJobSteps))
+	})
+}
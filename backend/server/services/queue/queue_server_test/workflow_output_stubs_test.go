@@ -0,0 +1,48 @@
+package queue_server_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+func TestWorkflowOutputStubs(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	stubs := models.WorkflowOutputStubs{
+		"base": {"go-docker-config": json.RawMessage(`{"image":"go-builder:latest","pull":"never"}`)},
+	}
+
+	// A workflow can't be both stubbed and requested to run
+	build, err := app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, &models.BuildOptions{
+		NodesToRun:          []models.NodeFQN{models.NewNodeFQNForWorkflow("base")},
+		WorkflowOutputStubs: stubs,
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusFailed, build.Status)
+
+	// Stubbed outputs are passed to each job so they can be provided to dynamic builds
+	build, err = app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, &models.BuildOptions{
+		WorkflowOutputStubs: stubs,
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.WorkflowStatusQueued, build.Status)
+	job, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	require.Equal(t, build.ID, job.BuildID)
+	require.JSONEq(t, `{"image":"go-builder:latest","pull":"never"}`, string(job.WorkflowOutputStubs["base"]["go-docker-config"]))
+}
@@ -3,14 +3,18 @@ package queue
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 	"github.com/buildbeaver/buildbeaver/server/dto"
 	"github.com/buildbeaver/buildbeaver/server/services"
 	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
@@ -22,6 +26,8 @@ const (
 	DefaultMaxBuildConfigLength int = 2 * 1024 * 1024 // 2 megabytes
 	DefaultMaxJobsPerBuild      int = 256
 	DefaultMaxStepsPerJob       int = 20
+	// maxRunnerLossRetries is the number of times a job that retries on runner loss will be queued to run again.
+	maxRunnerLossRetries = 2
 )
 
 type LimitsConfig struct {
@@ -37,20 +43,37 @@ type LimitsConfig struct {
 	MaxStepsPerJob int
 }
 
+type ImageConfig struct {
+	// RegistryRewrites maps the host of a Docker registry (e.g. docker.io) to a registry to pull images from
+	// instead, such as a pull-through mirror. The docker images of jobs and services handed to runners are
+	// rewritten accordingly; the build definition and stored jobs keep the original image references.
+	RegistryRewrites map[string]string
+}
+
 type QueueService struct {
-	db                *store.DB
-	runnerService     services.RunnerService
-	buildService      services.BuildService
-	jobService        services.JobService
-	stepService       services.StepService
-	repoService       services.RepoService
-	credentialService services.CredentialService
-	logService        services.LogService
-	eventService      services.EventService
-	commitStore       store.CommitStore
-	timeoutChecker    *TimeoutChecker
-	scmRegistry       *scm.SCMRegistry
-	limits            LimitsConfig
+	db                  *store.DB
+	runnerService       services.RunnerService
+	buildService        services.BuildService
+	jobService          services.JobService
+	stepService         services.StepService
+	repoService         services.RepoService
+	credentialService   services.CredentialService
+	logService          services.LogService
+	eventService        services.EventService
+	commitStore         store.CommitStore
+	artifactStore       store.ArtifactStore
+	resourceLinkStore   store.ResourceLinkStore
+	pullRequestStore    store.PullRequestStore
+	legalEntityService  services.LegalEntityService
+	buildRuleSetService services.BuildRuleSetService
+	toolchainService    services.ToolchainService
+	usageService        services.UsageService
+	notifier            store.Notifier
+	timeoutChecker      *TimeoutChecker
+	runnerLossReaper    *RunnerLossReaper
+	scmRegistry         *scm.SCMRegistry
+	limits              LimitsConfig
+	images              ImageConfig
 	logger.Log
 }
 
@@ -65,39 +88,64 @@ func NewQueueService(
 	logService services.LogService,
 	eventService services.EventService,
 	commitStore store.CommitStore,
+	artifactStore store.ArtifactStore,
+	resourceLinkStore store.ResourceLinkStore,
+	pullRequestStore store.PullRequestStore,
+	legalEntityService services.LegalEntityService,
+	buildRuleSetService services.BuildRuleSetService,
+	toolchainService services.ToolchainService,
+	usageService services.UsageService,
+	notifier store.Notifier,
+	leaderElectionService services.LeaderElectionService,
 	scmRegistry *scm.SCMRegistry,
 	logFactory logger.LogFactory,
 	limits LimitsConfig,
+	images ImageConfig,
 ) *QueueService {
 
 	s := &QueueService{
-		db:                db,
-		buildService:      buildService,
-		runnerService:     runnerService,
-		jobService:        jobService,
-		stepService:       stepService,
-		repoService:       repoService,
-		credentialService: credentialService,
-		logService:        logService,
-		eventService:      eventService,
-		commitStore:       commitStore,
-		scmRegistry:       scmRegistry,
-		limits:            limits,
-		Log:               logFactory("QueueService"),
-	}
-
-	s.timeoutChecker = NewTimeoutChecker(db, s, jobService, stepService, logFactory)
+		db:                  db,
+		buildService:        buildService,
+		runnerService:       runnerService,
+		jobService:          jobService,
+		stepService:         stepService,
+		repoService:         repoService,
+		credentialService:   credentialService,
+		logService:          logService,
+		eventService:        eventService,
+		commitStore:         commitStore,
+		artifactStore:       artifactStore,
+		resourceLinkStore:   resourceLinkStore,
+		pullRequestStore:    pullRequestStore,
+		legalEntityService:  legalEntityService,
+		buildRuleSetService: buildRuleSetService,
+		toolchainService:    toolchainService,
+		usageService:        usageService,
+		notifier:            notifier,
+		scmRegistry:         scmRegistry,
+		limits:              limits,
+		images:              images,
+		Log:                 logFactory("QueueService"),
+	}
+
+	s.timeoutChecker = NewTimeoutChecker(db, s, jobService, stepService, leaderElectionService, logFactory)
 	s.timeoutChecker.Start()
+	s.runnerLossReaper = NewRunnerLossReaper(s, leaderElectionService, logFactory)
+	s.runnerLossReaper.Start()
 	return s
 }
 
 func (s *QueueService) Stop() {
 	s.timeoutChecker.Stop()
+	s.runnerLossReaper.Stop()
 }
 
 // EnqueueBuildFromCommit parses the build definition from the specified commit, and enqueues a new build from it.
 // If there is a problem with the build definition then a skeleton build is enqueued that is immediately
 // set to failed with an error describing the problem, and no error will be returned from this function.
+// If the commit message contains a skip marker such as [skip ci] then a skeleton build is enqueued that is
+// immediately set to skipped, unless opts.IgnoreSkipMarkers is set.
+// Any templates the build definition references are loaded from the SCM hosting the template's repo.
 // Returns an error only if there was a transient issue that could be retried.
 func (s *QueueService) EnqueueBuildFromCommit(
 	ctx context.Context,
@@ -106,13 +154,35 @@ func (s *QueueService) EnqueueBuildFromCommit(
 	ref string,
 	opts *models.BuildOptions,
 ) (*dto.BuildGraph, error) {
-	parser := parser.NewBuildDefinitionParser(s.getParserLimits())
+	ctx, span := tracing.StartSpan(ctx, "QueueService.EnqueueBuildFromCommit")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("repo_id", commit.RepoID), attribute.Stringer("commit_id", commit.ID))
+	if opts == nil || !opts.IgnoreSkipMarkers {
+		skip, err := s.buildRuleSetService.SkipCommit(ctx, txOrNil, commit)
+		if err != nil {
+			return nil, fmt.Errorf("error checking whether to skip commit: %w", err)
+		}
+		if skip {
+			return s.createSkippedBuild(ctx, txOrNil, commit, ref, opts)
+		}
+	}
+	templateLoader := s.newSCMTemplateLoader(ctx, txOrNil, commit.RepoID, commit.SHA)
+	parser := parser.NewBuildDefinitionParserWithTemplates(s.getParserLimits(), templateLoader)
 	buildDef, err := parser.Parse(commit.Config, commit.ConfigType)
 	if err != nil {
+		// Don't fail the build if a template couldn't be loaded due to a transient issue
+		if templateLoader.transientErr != nil {
+			return nil, fmt.Errorf("error loading build definition templates: %w", templateLoader.transientErr)
+		}
 		return s.createFailedBuild(ctx, txOrNil, commit, ref, opts, err)
 	}
 
-	graph, err := s.makeNewBuildGraph(commit.RepoID, commit.ID, buildDef, ref, opts)
+	resolvedOpts, err := resolveBuildParameters(buildDef, opts)
+	if err != nil {
+		return s.createFailedBuild(ctx, txOrNil, commit, ref, opts, err)
+	}
+
+	graph, err := s.makeNewBuildGraph(commit.RepoID, commit.ID, buildDef, ref, resolvedOpts)
 	if err != nil {
 		err = fmt.Errorf("error parsing build configuration: %w", err)
 		return s.createFailedBuild(ctx, txOrNil, commit, ref, opts, err)
@@ -121,6 +191,118 @@ func (s *QueueService) EnqueueBuildFromCommit(
 	return s.enqueueBuild(ctx, txOrNil, graph)
 }
 
+// EnqueueBuildFromRef enqueues a new build of the commit with the specified SHA, or of the commit at the head
+// of ref if sha is empty, reading the commit from the repo's SCM if it isn't already in the database. The
+// build is recorded as a build of ref, which defaults to the repo's default branch. Branch names are
+// converted to refs (e.g. main becomes refs/heads/main). If there is a problem with the commit's build
+// definition then a failed build is enqueued, as for EnqueueBuildFromCommit.
+// The caller must not have a transaction open, since the SCM may be contacted.
+func (s *QueueService) EnqueueBuildFromRef(
+	ctx context.Context,
+	repoID models.RepoID,
+	ref string,
+	sha string,
+	opts *models.BuildOptions,
+) (*dto.BuildGraph, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.EnqueueBuildFromRef")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("repo_id", repoID))
+	repo, err := s.repoService.Read(ctx, nil, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	if ref == "" {
+		ref = repo.DefaultBranch
+	}
+	if ref == "" {
+		return nil, gerror.NewErrValidationFailed("A ref must be specified since the repo has no default branch")
+	}
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	var commit *models.Commit
+	if sha != "" {
+		commit, err = s.commitStore.ReadBySHA(ctx, nil, repoID, sha)
+		if err != nil && !gerror.IsNotFound(err) {
+			return nil, fmt.Errorf("error reading commit: %w", err)
+		}
+	}
+	// Commits recorded without reading their config (e.g. because they were never built) must be fetched again
+	if commit == nil || commit.Config == nil {
+		if repo.ExternalID == nil {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Repo %q is not hosted by an SCM so commits can't be read from it", repo.Name))
+		}
+		scmName := repo.ExternalID.ExternalSystem
+		externalSCM, err := s.scmRegistry.Get(scmName)
+		if err != nil {
+			return nil, fmt.Errorf("error getting SCM from registry for %q: %w", scmName, err)
+		}
+		fetchRef := sha
+		if fetchRef == "" {
+			fetchRef = ref
+		}
+		commit, err = externalSCM.FetchCommit(ctx, repo, fetchRef)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil, gerror.NewErrValidationFailed(err.Error())
+			}
+			return nil, fmt.Errorf("error fetching commit from SCM: %w", err)
+		}
+	}
+
+	return s.EnqueueBuildFromCommit(ctx, nil, commit, ref, opts)
+}
+
+// ValidateBuildConfig parses the supplied build configuration as though it had been committed to the specified repo,
+// without enqueuing a build. Templates in the repo itself are loaded from gitRef, or from the repo's default branch
+// if gitRef is empty. Returns a validation failed error describing the problem if the build configuration is
+// invalid; the location of the problem can be found using parser.AsParseError. Returns any other error only if
+// there was a transient issue that could be retried.
+func (s *QueueService) ValidateBuildConfig(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	repoID models.RepoID,
+	config []byte,
+	configType models.ConfigType,
+	gitRef string,
+) (*models.BuildDefinition, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.ValidateBuildConfig")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("repo_id", repoID))
+	err := s.CheckBuildConfigLength(len(config))
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	templateLoader := s.newSCMTemplateLoader(ctx, txOrNil, repoID, gitRef)
+	buildDef, err := s.ParseBuildConfig(config, configType, templateLoader)
+	if err != nil {
+		if templateLoader.transientErr != nil {
+			return nil, fmt.Errorf("error loading build definition templates: %w", templateLoader.transientErr)
+		}
+		return nil, gerror.NewErrValidationFailed("Invalid build definition").Wrap(err)
+	}
+	return buildDef, nil
+}
+
+// ParseBuildConfig parses the supplied build configuration, enforcing the same limits as builds enqueued by this
+// service. Any templates the configuration references are loaded using templateLoader; if templateLoader is nil
+// then templates are not supported. The location of any problem found can be read using parser.AsParseError.
+func (s *QueueService) ParseBuildConfig(config []byte, configType models.ConfigType, templateLoader parser.TemplateLoader) (*models.BuildDefinition, error) {
+	if templateLoader == nil {
+		return parser.NewBuildDefinitionParser(s.getParserLimits()).Parse(config, configType)
+	}
+	return parser.NewBuildDefinitionParserWithTemplates(s.getParserLimits(), templateLoader).Parse(config, configType)
+}
+
+// MakeBuildGraph makes the graph of jobs that a build of the specified ref in the repo would contain for buildDef,
+// and validates it, without enqueuing a build. The graph is not persisted and its build does not refer to a real
+// commit, so it is only useful for checking or displaying the jobs in a build definition.
+// Returns a validation failed or limit exceeded error if the jobs don't form a valid build.
+func (s *QueueService) MakeBuildGraph(repoID models.RepoID, ref string, buildDef *models.BuildDefinition) (*dto.BuildGraph, error) {
+	return s.makeNewBuildGraph(repoID, models.NewCommitID(), buildDef, ref, nil)
+}
+
 // EnqueueBuildFromBuildDefinition enqueues a new build based on the specified build definition, which is assumed
 // to have come from the specified commit. Unlike EnqueueBuildFromCommit this function will return an error
 // if there is a problem with the build definition (as well as any transient errors).
@@ -133,6 +315,13 @@ func (s *QueueService) EnqueueBuildFromBuildDefinition(
 	ref string,
 	opts *models.BuildOptions,
 ) (*dto.BuildGraph, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.EnqueueBuildFromBuildDefinition")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("repo_id", repoID), attribute.Stringer("commit_id", commitID))
+	opts, err := resolveBuildParameters(buildDef, opts)
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
 	graph, err := s.makeNewBuildGraph(repoID, commitID, buildDef, ref, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error creating build graph: %w", err)
@@ -141,6 +330,117 @@ func (s *QueueService) EnqueueBuildFromBuildDefinition(
 	return s.enqueueBuild(ctx, txOrNil, graph)
 }
 
+// CloneBuild enqueues a new build for the same commit and ref as a finished build, containing the jobs
+// recorded for the finished build (including any jobs that were added dynamically) with the changes
+// specified in clone applied. Returns a validation error if the build has not finished.
+func (s *QueueService) CloneBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, clone dto.CloneBuild) (*dto.BuildGraph, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.CloneBuild")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("build_id", buildID))
+	for _, envVar := range clone.Environment {
+		err := envVar.Validate()
+		if err != nil {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid environment variable: %s", err))
+		}
+	}
+	var graph *dto.BuildGraph
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		from, err := s.ReadBuildGraph(ctx, tx, buildID)
+		if err != nil {
+			return fmt.Errorf("error reading build graph: %w", err)
+		}
+		if !from.Status.HasFinished() {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Only finished builds can be cloned; build has status '%s'", from.Status))
+		}
+		buildDef := makeBuildDefinitionFromGraph(from, clone.Environment)
+		opts, err := resolveBuildParameters(buildDef, clone.Opts)
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Error cloning build: %s", err))
+		}
+		graph, err = s.makeNewBuildGraph(from.RepoID, from.CommitID, buildDef, from.Ref, opts)
+		if err != nil {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Error cloning build: %s", err))
+		}
+		graph.ClonedFromBuildID = from.ID
+		graph, err = s.enqueueBuild(ctx, tx, graph)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Infof("Cloned build %s to new build %s", buildID, graph.ID)
+	return graph, nil
+}
+
+// makeBuildDefinitionFromGraph makes a build definition containing the jobs and steps recorded in an
+// existing build graph. The supplied environment variables are set on every job, replacing any existing
+// variables with the same name. Each parameter recorded for the existing build is declared as a string
+// parameter defaulting to the value it had in that build.
+func makeBuildDefinitionFromGraph(bGraph *dto.BuildGraph, environment []*models.EnvVar) *models.BuildDefinition {
+	buildDef := &models.BuildDefinition{}
+	for _, name := range bGraph.Opts.Parameters.Names() {
+		value := bGraph.Opts.Parameters[name]
+		buildDef.Parameters = append(buildDef.Parameters, &models.BuildParameterDefinition{
+			Name:    name,
+			Type:    models.BuildParameterTypeString,
+			Default: &value,
+		})
+	}
+	for _, jGraph := range bGraph.Jobs {
+		jobDef := models.JobDefinition{JobDefinitionData: jGraph.JobDefinitionData}
+		jobDef.Environment = mergeEnvVars(jGraph.Environment, environment)
+		for _, step := range jGraph.Steps {
+			jobDef.Steps = append(jobDef.Steps, models.StepDefinition{StepDefinitionData: step.StepDefinitionData})
+		}
+		buildDef.Jobs = append(buildDef.Jobs, jobDef)
+	}
+	return buildDef
+}
+
+// resolveBuildParameters returns the options to queue a build of buildDef with, including a value for every
+// parameter the build definition declares. opts is not modified. Returns an error if the parameter values
+// supplied in opts are not valid for the build definition.
+func resolveBuildParameters(buildDef *models.BuildDefinition, opts *models.BuildOptions) (*models.BuildOptions, error) {
+	var supplied models.BuildParameters
+	if opts != nil {
+		supplied = opts.Parameters
+	}
+	parameters, err := models.ResolveBuildParameters(buildDef.Parameters, supplied)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving build parameters: %w", err)
+	}
+	if parameters == nil {
+		return opts, nil
+	}
+	resolved := models.BuildOptions{}
+	if opts != nil {
+		resolved = *opts
+	}
+	resolved.Parameters = parameters
+	return &resolved, nil
+}
+
+// mergeEnvVars returns a new list of environment variables containing existing, with each variable in
+// overrides either replacing the existing variable with the same name or being appended to the list.
+func mergeEnvVars(existing models.JobEnvVars, overrides []*models.EnvVar) models.JobEnvVars {
+	merged := make(models.JobEnvVars, 0, len(existing)+len(overrides))
+	indexByName := make(map[string]int, len(existing))
+	for _, envVar := range existing {
+		indexByName[envVar.Name] = len(merged)
+		merged = append(merged, envVar)
+	}
+	for _, envVar := range overrides {
+		i, ok := indexByName[envVar.Name]
+		if ok {
+			merged[i] = envVar
+		} else {
+			indexByName[envVar.Name] = len(merged)
+			merged = append(merged, envVar)
+		}
+	}
+	return merged
+}
+
 // AddConfigToBuild enqueues new jobs for an existing build, taken from the supplied build configuration.
 // Returns the full build graph containing both existing and new jobs, as well as an array containing just the new jobs.
 // This function will return an error if there is a problem with the jobs, as well as any transient errors.
@@ -151,26 +451,89 @@ func (s *QueueService) AddConfigToBuild(
 	config []byte,
 	configType models.ConfigType,
 ) (*dto.BuildGraph, []*dto.JobGraph, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.AddConfigToBuild")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("build_id", buildID))
+
+	buildDef, err := s.parseDynamicConfig(ctx, txOrNil, buildID, config, configType, "Error dynamically creating jobs")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bGraph, newJGraphs, err := s.addJobsToBuild(ctx, txOrNil, buildID, buildDef.Jobs, false)
+	if err != nil {
+		s.recordLimitExceeded(ctx, txOrNil, buildID, err)
+		return nil, nil, err
+	}
+	return bGraph, newJGraphs, nil
+}
+
+// UpdateConfigInBuild updates jobs that are already part of an existing build, taken from the supplied build
+// configuration. Each job in the configuration must have the same workflow and name as a job in the build that
+// is queued and has not yet been handed to a runner, and must give the job's full definition. Only the job's
+// environment variables and dependencies can be changed, and new steps can be added to the job.
+// Returns the full build graph, as well as an array containing just the updated jobs.
+// This function will return an error if there is a problem with the jobs, as well as any transient errors.
+func (s *QueueService) UpdateConfigInBuild(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	buildID models.BuildID,
+	config []byte,
+	configType models.ConfigType,
+) (*dto.BuildGraph, []*dto.JobGraph, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.UpdateConfigInBuild")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("build_id", buildID))
+
+	buildDef, err := s.parseDynamicConfig(ctx, txOrNil, buildID, config, configType, "Error dynamically updating jobs")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bGraph, updatedJGraphs, err := s.addJobsToBuild(ctx, txOrNil, buildID, buildDef.Jobs, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bGraph, updatedJGraphs, nil
+}
+
+// parseDynamicConfig parses a set of jobs submitted to an existing build. errorPrefix describes what the jobs
+// were submitted for, and is used in errors for configurations that are too long.
+func (s *QueueService) parseDynamicConfig(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	buildID models.BuildID,
+	config []byte,
+	configType models.ConfigType,
+	errorPrefix string,
+) (*models.BuildDefinition, error) {
 	// Check maximum length for build config
 	err := s.CheckBuildConfigLength(len(config))
 	if err != nil {
-		return nil, nil, gerror.NewErrValidationFailed(fmt.Sprintf("Error dynamically creating jobs: %s", err.Error()))
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("%s: %s", errorPrefix, err.Error()))
 	}
 
 	// Parse the jobs into job definitions
 	parser := parser.NewBuildDefinitionParser(s.getParserLimits())
 	buildDef, err := parser.Parse(config, configType)
 	if err != nil {
-		return nil, nil, gerror.NewErrValidationFailed(err.Error())
+		if gerror.IsLimitExceeded(err) {
+			// Return the limit exceeded error as-is so the caller can see which limit was exceeded and where
+			s.recordLimitExceeded(ctx, txOrNil, buildID, err)
+			return nil, err
+		}
+		return nil, gerror.NewErrValidationFailed(err.Error())
 	}
-
-	return s.addJobsToBuild(ctx, txOrNil, buildID, buildDef.Jobs)
+	return buildDef, nil
 }
 
-// addJobsToBuild enqueues new jobs for an existing build.
-// Returns the full build graph containing both existing and new jobs, as well as an array containing just the new jobs.
+// addJobsToBuild enqueues new jobs for an existing build. If update is true then instead of adding new jobs,
+// each of the jobs must already be part of the build and the existing job is updated to match; see
+// updateJobGraphs for the changes that are allowed.
+// Returns the full build graph containing both existing and new jobs, as well as an array containing just the new
+// (or updated) jobs.
 // This function will return an error if there is a problem with the jobs, as well as any transient errors.
-func (s *QueueService) addJobsToBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, jobs []models.JobDefinition) (*dto.BuildGraph, []*dto.JobGraph, error) {
+func (s *QueueService) addJobsToBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, jobs []models.JobDefinition, update bool) (*dto.BuildGraph, []*dto.JobGraph, error) {
 	var (
 		bGraph     *dto.BuildGraph
 		newJGraphs []*dto.JobGraph
@@ -193,10 +556,37 @@ func (s *QueueService) addJobsToBuild(ctx context.Context, txOrNil *store.Tx, bu
 			return gerror.NewErrValidationFailed(fmt.Sprintf("error build has already finished with status '%s'",
 				bGraph.Build.Status))
 		}
-		// Append the new jobs to the existing graph
-		err = s.makeJobGraphsAndAppendToBuildGraph(bGraph, jobs)
+		if bGraph.ClonedFromBuildID.Valid() && !update {
+			// A cloned build already contains the jobs that were added dynamically to the original build, so
+			// when the jobs that added them run again, ignore any jobs that are already part of the build
+			jobs = s.removeExistingJobs(bGraph, jobs)
+			if len(jobs) == 0 {
+				return nil
+			}
+		}
+		// Enforce any restrictions configured for the repo, to limit what a compromised dynamic job can do
+		newJobCount := len(jobs)
+		if update {
+			newJobCount = 0
+		}
+		err = s.checkDynamicJobRestrictions(ctx, tx, bGraph, jobs, newJobCount)
 		if err != nil {
-			return fmt.Errorf("error making new job graphs: %w", err)
+			return err
+		}
+		var updates []*jobUpdate
+		if update {
+			// Apply the changes to the existing jobs in the graph, refusing to change jobs that have been
+			// handed to a runner
+			updates, err = s.updateJobGraphs(bGraph, jobs)
+			if err != nil {
+				return err
+			}
+		} else {
+			// Append the new jobs to the existing graph
+			err = s.makeJobGraphsAndAppendToBuildGraph(bGraph, jobs)
+			if err != nil {
+				return fmt.Errorf("error making new job graphs: %w", err)
+			}
 		}
 		bGraph.PopulateDefaults()
 		// Validate the full graph containing all existing and new jobs; this will pick up any new cycles in the
@@ -205,6 +595,10 @@ func (s *QueueService) addJobsToBuild(ctx context.Context, txOrNil *store.Tx, bu
 		if err != nil {
 			return fmt.Errorf("error validating updated build graph: %w", err)
 		}
+		if update {
+			newJGraphs, err = s.saveJobUpdates(ctx, tx, bGraph, updates)
+			return err
+		}
 		// Enqueue the new jobs
 		newJGraphs, err = s.enqueueJobs(ctx, tx, bGraph)
 		return err
@@ -215,6 +609,201 @@ func (s *QueueService) addJobsToBuild(ctx context.Context, txOrNil *store.Tx, bu
 	return bGraph, newJGraphs, nil
 }
 
+// jobUpdate records the changes made to an existing job's graph by updateJobGraphs, so they can be saved.
+type jobUpdate struct {
+	jGraph   *dto.JobGraph
+	newSteps []*models.Step
+}
+
+// updateJobGraphs changes the existing jobs in the build graph to match the supplied job definitions, which
+// must each have the same workflow and name as a job in the graph. The changes are not persisted.
+// Only jobs that are queued and have not yet been handed to a runner can be updated, and only the job's
+// environment variables and dependencies can be changed. Steps can be added to a job, but the job's existing
+// steps must be included in the definition unchanged.
+func (s *QueueService) updateJobGraphs(bGraph *dto.BuildGraph, jobs []models.JobDefinition) ([]*jobUpdate, error) {
+	jGraphsByFQN := make(map[models.NodeFQN]*dto.JobGraph, len(bGraph.Jobs))
+	for _, jGraph := range bGraph.Jobs {
+		jGraphsByFQN[jGraph.GetFQN()] = jGraph
+	}
+	now := models.NewTime(time.Now())
+	var updates []*jobUpdate
+	for _, job := range jobs {
+		fqn := models.NewNodeFQNForJob(job.Workflow, job.Name)
+		jGraph, ok := jGraphsByFQN[fqn]
+		if !ok {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("error job %s is not part of the build", fqn.String()))
+		}
+		err := s.checkJobCanBeUpdated(jGraph, job)
+		if err != nil {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("error job %s cannot be updated: %s", fqn.String(), err))
+		}
+		// NOTE: Very important that we use JobDefinition here as it includes the job's steps
+		hash, err := hashstructure.Hash(job, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+		if err != nil {
+			return nil, fmt.Errorf("error hashing job definiton data: %w", err)
+		}
+		jGraph.Environment = job.Environment
+		jGraph.Depends = job.Depends
+		jGraph.DefinitionDataHash = fmt.Sprintf("%x", hash)
+
+		update := &jobUpdate{jGraph: jGraph}
+		existingSteps := make(map[models.ResourceName]bool, len(jGraph.Steps))
+		for _, step := range jGraph.Steps {
+			existingSteps[step.Name] = true
+		}
+		for _, stepDef := range job.Steps {
+			if existingSteps[stepDef.Name] {
+				continue
+			}
+			step := s.makeStep(bGraph.Build, stepDef, now)
+			jGraph.Steps = append(jGraph.Steps, step)
+			update.newSteps = append(update.newSteps, step)
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+// checkJobCanBeUpdated returns an error if the existing job can't be changed to match the supplied definition.
+func (s *QueueService) checkJobCanBeUpdated(jGraph *dto.JobGraph, job models.JobDefinition) error {
+	if jGraph.Status != models.WorkflowStatusQueued {
+		return fmt.Errorf("job has status '%s'; only queued jobs that have not been picked up by a runner can be updated", jGraph.Status)
+	}
+	if jGraph.IndirectToJobID.Valid() {
+		return fmt.Errorf("job is waiting for the result of identical job %s", jGraph.IndirectToJobID)
+	}
+
+	existingData := jGraph.JobDefinitionData
+	updatedData := job.JobDefinitionData
+	existingData.Environment, updatedData.Environment = nil, nil
+	existingData.Depends, updatedData.Depends = nil, nil
+	// Some settings are normalized when the existing job is read from the database, so normalize the
+	// updated settings in the same way before comparing
+	err := updatedData.DockerImagePullStrategy.Scan(string(updatedData.DockerImagePullStrategy))
+	if err != nil {
+		return err
+	}
+	err = updatedData.StepExecution.Scan(string(updatedData.StepExecution))
+	if err != nil {
+		return err
+	}
+	same, err := sameDefinition(existingData, updatedData)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("only environment variables and dependencies can be changed, and steps added")
+	}
+
+	stepDefs := make(map[models.ResourceName]models.StepDefinition, len(job.Steps))
+	for _, stepDef := range job.Steps {
+		stepDefs[stepDef.Name] = stepDef
+	}
+	for _, step := range jGraph.Steps {
+		stepDef, ok := stepDefs[step.Name]
+		if !ok {
+			return fmt.Errorf("step %q is missing; steps cannot be removed", step.Name)
+		}
+		same, err = sameDefinition(step.StepDefinitionData, stepDef.StepDefinitionData)
+		if err != nil {
+			return err
+		}
+		if !same {
+			return fmt.Errorf("step %q has changed; existing steps cannot be changed", step.Name)
+		}
+	}
+	return nil
+}
+
+// sameDefinition returns true if two job or step definitions are the same, comparing them in the same way as
+// the definition data hash recorded for each job.
+func sameDefinition(a interface{}, b interface{}) (bool, error) {
+	opts := &hashstructure.HashOptions{SlicesAsSets: true}
+	hashA, err := hashstructure.Hash(a, hashstructure.FormatV2, opts)
+	if err != nil {
+		return false, fmt.Errorf("error hashing definition: %w", err)
+	}
+	hashB, err := hashstructure.Hash(b, hashstructure.FormatV2, opts)
+	if err != nil {
+		return false, fmt.Errorf("error hashing definition: %w", err)
+	}
+	return hashA == hashB, nil
+}
+
+// saveJobUpdates persists the changes made to jobs by updateJobGraphs, creating any new steps.
+// Returns the updated job graphs.
+func (s *QueueService) saveJobUpdates(ctx context.Context, tx *store.Tx, bGraph *dto.BuildGraph, updates []*jobUpdate) ([]*dto.JobGraph, error) {
+	var jGraphs []*dto.JobGraph
+	for _, update := range updates {
+		job := update.jGraph.Job
+		job.UpdatedAt = models.NewTime(time.Now())
+		// The update is made with optimistic locking, so this will fail if a runner has dequeued the job
+		// since the build graph was read
+		err := s.jobService.Update(ctx, tx, job)
+		if err != nil {
+			return nil, fmt.Errorf("error updating job: %w", err)
+		}
+		err = s.jobService.UpdateDependencies(ctx, tx, job)
+		if err != nil {
+			return nil, fmt.Errorf("error updating job dependencies: %w", err)
+		}
+		for _, step := range update.newSteps {
+			err = s.createStep(ctx, tx, job, step)
+			if err != nil {
+				return nil, fmt.Errorf("error creating step: %w", err)
+			}
+		}
+		s.Infof("Updated job %s in build %s, adding %d steps", job.ID, bGraph.Build.ID, len(update.newSteps))
+		jGraphs = append(jGraphs, update.jGraph)
+	}
+	if len(jGraphs) > 0 {
+		// Removing a dependency may have made a job ready to run
+		s.notifyJobsReady(ctx, tx, bGraph.Build.RepoID)
+	}
+	return jGraphs, nil
+}
+
+// removeExistingJobs returns the subset of jobs that are not already part of the build graph.
+func (s *QueueService) removeExistingJobs(bGraph *dto.BuildGraph, jobs []models.JobDefinition) []models.JobDefinition {
+	existing := make(map[models.NodeFQN]bool, len(bGraph.Jobs))
+	for _, jGraph := range bGraph.Jobs {
+		existing[jGraph.GetFQN()] = true
+	}
+	var newJobs []models.JobDefinition
+	for _, job := range jobs {
+		fqn := models.NewNodeFQNForJob(job.Workflow, job.Name)
+		if existing[fqn] {
+			s.Tracef("Ignoring job %s for cloned build %s; job is already part of the build", fqn, bGraph.ID)
+			continue
+		}
+		newJobs = append(newJobs, job)
+	}
+	return newJobs
+}
+
+// checkDynamicJobRestrictions returns a validation error if the repo that owns the build has restrictions
+// configured on dynamic jobs, and the supplied jobs do not satisfy those restrictions. newJobCount is the
+// number of the supplied jobs that will be added to the build, rather than updating existing jobs.
+func (s *QueueService) checkDynamicJobRestrictions(ctx context.Context, tx *store.Tx, bGraph *dto.BuildGraph, jobs []models.JobDefinition, newJobCount int) error {
+	repo, err := s.repoService.Read(ctx, tx, bGraph.Build.RepoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	restrictions := repo.DynamicJobRestrictions
+	if restrictions == nil {
+		return nil
+	}
+	err = restrictions.CheckJobCount(len(bGraph.Jobs) + newJobCount)
+	if err != nil {
+		return gerror.NewErrValidationFailed(err.Error())
+	}
+	err = restrictions.CheckJobs(jobs)
+	if err != nil {
+		return gerror.NewErrValidationFailed(fmt.Sprintf("Jobs violate dynamic job restrictions for repo: %s", err))
+	}
+	return nil
+}
+
 func (s *QueueService) getParserLimits() parser.ParserLimits {
 	return parser.ParserLimits{
 		MaxStepsPerJob: s.limits.MaxStepsPerJob,
@@ -236,9 +825,102 @@ func (s *QueueService) CheckBuildConfigLength(length int) error {
 	return nil
 }
 
+// DequeueWithWait returns the next queued job that is ready for execution and that the specified runner is
+// capable of running. If no jobs are ready then waits for up to wait for a job to become ready, returning
+// a ErrCodeNotFound if none did. Waiting relies on notifications that jobs may be ready; if the notifier
+// doesn't deliver notifications then this doesn't wait, and behaves the same as Dequeue.
+func (s *QueueService) DequeueWithWait(ctx context.Context, runnerID models.RunnerID, wait time.Duration) (*dto.RunnableJob, error) {
+	if wait <= 0 {
+		return s.Dequeue(ctx, runnerID)
+	}
+	legalEntityIDs, err := s.runnerService.ListLegalEntityIDs(ctx, nil, runnerID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing legal entities for runner: %w", err)
+	}
+	// Start listening before the first attempt, so that jobs becoming ready during it aren't missed
+	notifications, stopListening := s.listenForQueuedJobs(legalEntityIDs)
+	defer stopListening()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		job, err := s.Dequeue(ctx, runnerID)
+		if err == nil || !gerror.IsNotFound(err) || notifications == nil {
+			return job, err
+		}
+		select {
+		case <-notifications:
+		case <-timer.C:
+			return nil, err
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// listenForQueuedJobs returns a Go channel that receives a value after jobs may have become ready for any of the
+// specified legal entities, along with a function to call to stop listening. Returns a nil Go channel if the
+// notifier doesn't deliver notifications.
+func (s *QueueService) listenForQueuedJobs(legalEntityIDs []models.LegalEntityID) (<-chan struct{}, func()) {
+	if len(legalEntityIDs) == 1 {
+		return s.notifier.Listen(store.QueuedJobsNotificationChannel(legalEntityIDs[0]))
+	}
+	merged := make(chan struct{}, 1)
+	done := make(chan struct{})
+	var stops []func()
+	listening := false
+	for _, legalEntityID := range legalEntityIDs {
+		notifications, stop := s.notifier.Listen(store.QueuedJobsNotificationChannel(legalEntityID))
+		stops = append(stops, stop)
+		if notifications == nil {
+			continue
+		}
+		listening = true
+		go func() {
+			for {
+				select {
+				case <-notifications:
+					select {
+					case merged <- struct{}{}:
+					default:
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	stop := func() {
+		close(done)
+		for _, stop := range stops {
+			stop()
+		}
+	}
+	if !listening {
+		return nil, stop
+	}
+	return merged, stop
+}
+
+// notifyJobsReady notifies runners waiting for jobs for the repo's legal entity that jobs may be ready.
+// Errors are logged rather than returned, since runners will find the jobs when they next poll.
+func (s *QueueService) notifyJobsReady(ctx context.Context, tx *store.Tx, repoID models.RepoID) {
+	repo, err := s.repoService.Read(ctx, tx, repoID)
+	if err != nil {
+		s.Warnf("Ignoring error reading repo to notify runners of ready jobs: %v", err)
+		return
+	}
+	err = s.notifier.Notify(ctx, tx, store.QueuedJobsNotificationChannel(repo.LegalEntityID))
+	if err != nil {
+		s.Warnf("Ignoring error notifying runners of ready jobs: %v", err)
+	}
+}
+
 // Dequeue returns the next queued job that is ready for execution and that the specified
 // runner is capable of running, or a ErrCodeNotFound if no jobs are ready for execution.
 func (s *QueueService) Dequeue(ctx context.Context, runnerID models.RunnerID) (*dto.RunnableJob, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.Dequeue")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("runner_id", runnerID))
 	var dequeued *dto.RunnableJob
 	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
 		runner, err := s.runnerService.Read(ctx, tx, runnerID)
@@ -249,6 +931,10 @@ func (s *QueueService) Dequeue(ctx context.Context, runnerID models.RunnerID) (*
 		if !runner.Enabled {
 			return gerror.NewErrCodeRunnerDisabled()
 		}
+		// Don't return any jobs if the runner's host is unhealthy, or the job would likely fail
+		if !runner.IsHealthy() {
+			return gerror.NewErrCodeRunnerUnhealthy()
+		}
 		stg, err := s.jobService.FindQueuedJob(ctx, tx, runner)
 		if err != nil {
 			return err
@@ -266,6 +952,10 @@ func (s *QueueService) Dequeue(ctx context.Context, runnerID models.RunnerID) (*
 		if err != nil {
 			return fmt.Errorf("error reading repo: %w", err)
 		}
+		repoOwner, err := s.legalEntityService.Read(ctx, tx, repo.LegalEntityID)
+		if err != nil {
+			return fmt.Errorf("error reading repo owner: %w", err)
+		}
 		commit, err := s.commitStore.Read(ctx, tx, build.CommitID)
 		if err != nil {
 			return fmt.Errorf("error reading commit: %w", err)
@@ -282,47 +972,315 @@ func (s *QueueService) Dequeue(ctx context.Context, runnerID models.RunnerID) (*
 		job.Jobs = dependencyJobs
 		job.Steps = steps
 		job.Repo = repo
+		job.RepoOwnerName = repoOwner.Name
 		job.Commit = commit
 
-		// Create an identity and a JWT token for use by dynamic build steps during the build
-		identity, err := s.buildService.FindOrCreateIdentity(ctx, tx, build.ID)
-		if err != nil {
-			return err
+		// If the repo deduplicates jobs and an identical job is already running in another build, wait for
+		// that job's result rather than handing this job to the runner
+		if repo.DeduplicateJobs && !build.Opts.Force {
+			attached, err := s.attachToRunningDuplicate(ctx, tx, job.Job)
+			if err != nil {
+				return err
+			}
+			if attached {
+				return nil
+			}
+		}
+
+		// Don't run jobs for legal entities that have used up their monthly quota
+		err = s.usageService.CheckQuotas(ctx, tx, repo.LegalEntityID)
+		if err != nil {
+			if !gerror.IsQuotaExceeded(err) {
+				return fmt.Errorf("error checking quotas: %w", err)
+			}
+			s.Warnf("Failing job %s: %v", job.ID, err)
+			return s.failUnrunnableJob(ctx, tx, job.JobGraph, err)
+		}
+
+		// Resolve any artifacts the job needs from previous builds. If these can't be found then the job can
+		// never succeed, so fail it now rather than handing it to the runner.
+		externalArtifacts, err := s.resolveExternalArtifacts(ctx, tx, job.Job, repo)
+		if err != nil {
+			if !gerror.IsValidationFailed(err) {
+				return fmt.Errorf("error resolving artifacts from previous builds: %w", err)
+			}
+			s.Warnf("Failing job %s: %v", job.ID, err)
+			return s.failUnrunnableJob(ctx, tx, job.JobGraph, err)
+		}
+		job.ExternalArtifacts = externalArtifacts
+
+		// Resolve the toolchain the job runs in, if any, to the image registered for it. If the toolchain
+		// isn't registered then the job can never run, so fail it now.
+		err = s.resolveToolchain(ctx, tx, job.Job, repo)
+		if err != nil {
+			if !gerror.IsValidationFailed(err) {
+				return fmt.Errorf("error resolving toolchain: %w", err)
+			}
+			s.Warnf("Failing job %s: %v", job.ID, err)
+			return s.failUnrunnableJob(ctx, tx, job.JobGraph, err)
+		}
+
+		// Create an identity and a JWT token for use by dynamic build steps during the build
+		identity, err := s.buildService.FindOrCreateIdentity(ctx, tx, build.ID)
+		if err != nil {
+			return err
+		}
+		jwtToken, err := s.credentialService.CreateIdentityJWT(identity.ID)
+		job.JWT = jwtToken
+
+		job.WorkflowsToRun = s.getInitialWorkflowsToRun(build)
+		job.WorkflowOutputStubs = build.Opts.WorkflowOutputStubs
+		job.BuildParameters = build.Opts.Parameters
+
+		jobStatusChanged := job.Status != models.WorkflowStatusSubmitted
+		job.Status = models.WorkflowStatusSubmitted
+		job.RunnerID = runner.ID
+		_, err = s.updateJob(ctx, tx, job.JobGraph.Job, jobStatusChanged)
+		if err != nil {
+			return fmt.Errorf("error updating job: %w", err)
+		}
+		for _, step := range steps {
+			if step.Status == models.WorkflowStatusSkipped {
+				continue // skipped steps have already finished and won't be run
+			}
+			stepStatusChanged := step.Status != models.WorkflowStatusSubmitted
+			step.Status = models.WorkflowStatusSubmitted
+			step.RunnerID = runner.ID
+			_, err = s.updateStep(ctx, tx, job.Job, step, stepStatusChanged)
+			if err != nil {
+				return fmt.Errorf("error updating step: %w", err)
+			}
+		}
+		_, err = s.maintainBuildStatus(ctx, tx, job.BuildID)
+		if err != nil {
+			return fmt.Errorf("error maintaining build status: %w", err)
+		}
+		dequeued = job
+		s.Infof("Dequeued job %s", dequeued.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error dequeuing job: %w", err)
+	}
+	if dequeued == nil {
+		return nil, gerror.NewErrNotFound("No queued jobs are ready for execution")
+	}
+	s.rewriteDockerImages(dequeued)
+
+	return dequeued, nil
+}
+
+// rewriteDockerImages rewrites the registry of the docker images of a job and its services, as configured.
+// Only the job handed to the runner is changed; the stored job keeps its original image references.
+func (s *QueueService) rewriteDockerImages(job *dto.RunnableJob) {
+	if len(s.images.RegistryRewrites) == 0 {
+		return
+	}
+	job.DockerImage = models.RewriteDockerImageRegistry(job.DockerImage, s.images.RegistryRewrites)
+	jobServices := make(models.JobServices, 0, len(job.Services))
+	for _, service := range job.Services {
+		rewritten := *service
+		rewritten.DockerImage = models.RewriteDockerImageRegistry(service.DockerImage, s.images.RegistryRewrites)
+		jobServices = append(jobServices, &rewritten)
+	}
+	job.Services = jobServices
+}
+
+// resolveToolchain sets the job's docker image to the image registered for the toolchain the job refers to, if any.
+// The image is recorded against the job so it's clear afterwards exactly which image the job ran in.
+// Returns a validation failed error if the toolchain isn't registered for the legal entity that owns the repo.
+func (s *QueueService) resolveToolchain(ctx context.Context, tx *store.Tx, job *models.Job, repo *models.Repo) error {
+	if job.DockerToolchain == "" {
+		return nil
+	}
+	ref, err := models.ParseToolchainReference(job.DockerToolchain)
+	if err != nil {
+		return gerror.NewErrValidationFailed(err.Error())
+	}
+	toolchain, err := s.toolchainService.Resolve(ctx, tx, repo.LegalEntityID, ref)
+	if err != nil {
+		return err
+	}
+	job.DockerImage = toolchain.ImageReference()
+	return nil
+}
+
+// resolveExternalArtifacts finds the artifacts from previous builds that the job depends on via its ArtifactFrom
+// dependencies. Builds are looked up by name within the job's repo, or within another repo owned by the same
+// legal entity. Returns a validation failed error if a referenced build does not exist, did not succeed, or did
+// not produce any artifacts matching the reference.
+func (s *QueueService) resolveExternalArtifacts(ctx context.Context, tx *store.Tx, job *models.Job, repo *models.Repo) ([]*models.Artifact, error) {
+	if len(job.ArtifactFrom) == 0 {
+		return nil, nil
+	}
+	legalEntity, err := s.legalEntityService.Read(ctx, tx, repo.LegalEntityID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading legal entity: %w", err)
+	}
+	var artifacts []*models.Artifact
+	for _, dependency := range job.ArtifactFrom {
+		repoName := dependency.RepoName
+		if repoName == "" {
+			repoName = repo.Name
+		}
+		leaf, err := s.resourceLinkStore.Resolve(ctx, tx, models.ResourceLink{
+			{Kind: models.LegalEntityResourceKind, Name: legalEntity.Name},
+			{Kind: models.RepoResourceKind, Name: repoName},
+			{Kind: models.BuildResourceKind, Name: dependency.BuildName},
+		})
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Build %q referenced in artifact_from was not found", dependency))
+			}
+			return nil, fmt.Errorf("error resolving build for %q: %w", dependency, err)
+		}
+		build, err := s.buildService.Read(ctx, tx, models.BuildIDFromResourceID(leaf.ID))
+		if err != nil {
+			return nil, fmt.Errorf("error reading build for %q: %w", dependency, err)
+		}
+		if build.Status != models.WorkflowStatusSucceeded {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Build %q referenced in artifact_from has status %q; artifacts can only be used from builds that succeeded", dependency, build.Status))
+		}
+
+		search := models.NewArtifactSearch()
+		search.BuildID = build.ID
+		if dependency.GroupName != "" {
+			search.GroupName = &dependency.GroupName
+		}
+		found := 0
+		moreResults := true
+		for moreResults {
+			results, cursor, err := s.artifactStore.Search(ctx, tx, models.NoIdentity, *search)
+			if err != nil {
+				return nil, fmt.Errorf("error searching artifacts for %q: %w", dependency, err)
+			}
+			for _, artifact := range results {
+				if artifact.Sealed {
+					artifacts = append(artifacts, artifact)
+					found++
+				}
+			}
+			if cursor != nil && cursor.Next != nil {
+				search.Pagination.Cursor = cursor.Next // move on to next page of results
+			} else {
+				moreResults = false
+			}
+		}
+		if found == 0 {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("No artifacts found for %q referenced in artifact_from", dependency))
+		}
+	}
+	return artifacts, nil
+}
+
+// failUnrunnableJob marks a job that can never run, along with its steps, as failed with the specified error.
+func (s *QueueService) failUnrunnableJob(ctx context.Context, tx *store.Tx, job *dto.JobGraph, jobErr error) error {
+	job.Status = models.WorkflowStatusFailed
+	job.Error = models.NewError(jobErr)
+	_, err := s.updateJob(ctx, tx, job.Job, true)
+	if err != nil {
+		return fmt.Errorf("error updating job: %w", err)
+	}
+	for _, step := range job.Steps {
+		step.Status = models.WorkflowStatusFailed
+		// NOTE intentionally do not set step error here, as it just duplicates the job error
+		_, err = s.updateStep(ctx, tx, job.Job, step, true)
+		if err != nil {
+			return fmt.Errorf("error updating step: %w", err)
+		}
+	}
+	_, err = s.maintainBuildStatus(ctx, tx, job.BuildID)
+	if err != nil {
+		return fmt.Errorf("error maintaining build status: %w", err)
+	}
+	return nil
+}
+
+// attachToRunningDuplicate looks for a job in another build that is identical to the specified queued job and
+// has already been handed to a runner. If one is found the queued job is indirected to it, so that instead of
+// running the queued job finishes with the same result as the running job. Returns true if the job was attached.
+func (s *QueueService) attachToRunningDuplicate(ctx context.Context, tx *store.Tx, job *models.Job) (bool, error) {
+	duplicate, err := s.jobService.FindRunningDuplicate(ctx, tx, job)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return false, nil
 		}
-		jwtToken, err := s.credentialService.CreateIdentityJWT(identity.ID)
-		job.JWT = jwtToken
-
-		job.WorkflowsToRun = s.getInitialWorkflowsToRun(build)
+		return false, fmt.Errorf("error finding running duplicate job: %w", err)
+	}
+	job.IndirectToJobID = duplicate.ID
+	job.UpdatedAt = models.NewTime(time.Now())
+	err = s.jobService.Update(ctx, tx, job)
+	if err != nil {
+		return false, fmt.Errorf("error updating job: %w", err)
+	}
+	s.Infof("Job %s attached to running duplicate job %s", job.ID, duplicate.ID)
+	return true, nil
+}
 
-		jobStatusChanged := job.Status != models.WorkflowStatusSubmitted
-		job.Status = models.WorkflowStatusSubmitted
-		job.RunnerID = runner.ID
-		_, err = s.updateJob(ctx, tx, job.JobGraph.Job, jobStatusChanged)
+// finishAttachedJobs gives each queued job that is attached to the specified job the same result, now that
+// the job has finished. If the job was canceled then the attached jobs are detached and left queued so that
+// they will run themselves.
+func (s *QueueService) finishAttachedJobs(ctx context.Context, tx *store.Tx, job *models.Job) error {
+	attachedJobs, err := s.jobService.ListAttached(ctx, tx, job.ID)
+	if err != nil {
+		return fmt.Errorf("error listing attached jobs: %w", err)
+	}
+	if len(attachedJobs) == 0 {
+		return nil
+	}
+	steps, err := s.stepService.ListByJobID(ctx, tx, job.ID)
+	if err != nil {
+		return fmt.Errorf("error listing job steps: %w", err)
+	}
+	stepsByName := make(map[models.ResourceName]*models.Step, len(steps))
+	for _, step := range steps {
+		stepsByName[step.Name] = step
+	}
+	for _, attachedJob := range attachedJobs {
+		if job.Status == models.WorkflowStatusCanceled {
+			attachedJob.IndirectToJobID = models.JobID{}
+			attachedJob.UpdatedAt = models.NewTime(time.Now())
+			err = s.jobService.Update(ctx, tx, attachedJob)
+			if err != nil {
+				return fmt.Errorf("error updating job: %w", err)
+			}
+			s.Infof("Job %s detached from canceled duplicate job %s", attachedJob.ID, job.ID)
+			continue
+		}
+		attachedJob.Status = job.Status
+		attachedJob.Error = job.Error
+		attachedJob.Fingerprint = job.Fingerprint
+		attachedJob.FingerprintHashType = job.FingerprintHashType
+		_, err = s.updateJob(ctx, tx, attachedJob, true)
 		if err != nil {
 			return fmt.Errorf("error updating job: %w", err)
 		}
-		for _, step := range steps {
-			stepStatusChanged := step.Status != models.WorkflowStatusSubmitted
-			step.Status = models.WorkflowStatusSubmitted
-			step.RunnerID = runner.ID
-			_, err = s.updateStep(ctx, tx, job.Job, step, stepStatusChanged)
+		attachedSteps, err := s.stepService.ListByJobID(ctx, tx, attachedJob.ID)
+		if err != nil {
+			return fmt.Errorf("error listing job steps: %w", err)
+		}
+		for _, step := range attachedSteps {
+			if step.Status.HasFinished() {
+				continue
+			}
+			step.Status = job.Status
+			duplicateStep, ok := stepsByName[step.Name]
+			if ok && duplicateStep.Status.HasFinished() {
+				step.Status = duplicateStep.Status
+				step.Error = duplicateStep.Error
+			}
+			_, err = s.updateStep(ctx, tx, attachedJob, step, true)
 			if err != nil {
 				return fmt.Errorf("error updating step: %w", err)
 			}
 		}
-		_, err = s.maintainBuildStatus(ctx, tx, job.BuildID)
+		_, err = s.maintainBuildStatus(ctx, tx, attachedJob.BuildID)
 		if err != nil {
 			return fmt.Errorf("error maintaining build status: %w", err)
 		}
-		dequeued = job
-		s.Infof("Dequeued job %s", dequeued.ID)
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error dequeuing job: %w", err)
+		s.Infof("Job %s finished with the result of duplicate job %s", attachedJob.ID, job.ID)
 	}
-
-	return dequeued, nil
+	return nil
 }
 
 // getInitialWorkflowsToRun returns the set of workflows that are explicitly requested in the build options
@@ -413,11 +1371,227 @@ func (s *QueueService) CheckForTimeouts(timeout time.Duration) int {
 	return s.timeoutChecker.CheckForTimeouts(timeout)
 }
 
+// RecoverOrphanedJobs finds jobs that were handed to runners which have since stopped sending heartbeats, and
+// fails them (or queues them to run again if the job is configured to retry on runner loss). A diagnostic
+// message is written to each recovered job's logs. Returns the number of jobs that were recovered.
+func (s *QueueService) RecoverOrphanedJobs(ctx context.Context) (int, error) {
+	var orphanedJobs []*models.Job
+	runners := make(map[models.RunnerID]*models.Runner)
+	for _, status := range []models.WorkflowStatus{models.WorkflowStatusSubmitted, models.WorkflowStatusRunning} {
+		pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+		for moreResults := true; moreResults; {
+			jobs, cursor, err := s.jobService.ListByStatus(ctx, nil, status, pagination)
+			if err != nil {
+				return 0, fmt.Errorf("error listing %s jobs: %w", status, err)
+			}
+			for _, job := range jobs {
+				if !job.RunnerID.Valid() {
+					continue
+				}
+				runner, ok := runners[job.RunnerID]
+				if !ok {
+					runner, err = s.runnerService.Read(ctx, nil, job.RunnerID)
+					if err != nil && !gerror.IsNotFound(err) {
+						return 0, fmt.Errorf("error reading runner: %w", err)
+					}
+					runners[job.RunnerID] = runner // nil if the runner has been deleted
+				}
+				if runner != nil && isRunnerLost(runner) {
+					orphanedJobs = append(orphanedJobs, job)
+				}
+			}
+			if cursor != nil && cursor.Next != nil {
+				pagination.Cursor = cursor.Next // move on to next page of results
+			} else {
+				moreResults = false
+			}
+		}
+	}
+
+	// Recover each orphaned job in a separate transaction, so failure to recover one does not impact the others
+	errorCount := 0
+	recoveredCount := 0
+	for _, job := range orphanedJobs {
+		runner := runners[job.RunnerID]
+		// Log data isn't stored in the database, so write to the logs before starting the transaction
+		s.writeRunnerLossMessage(ctx, job, runner)
+		var recovered bool
+		err := s.db.WithTx(ctx, nil, func(tx *store.Tx) (err error) {
+			recovered, err = s.recoverOrphanedJob(ctx, tx, job.ID, runner)
+			return err
+		})
+		if err != nil {
+			// Log error and continue
+			s.Errorf("error recovering orphaned job with ID %s: %v", job.ID, err)
+			errorCount++
+		} else if recovered {
+			recoveredCount++
+		}
+	}
+	if errorCount > 0 {
+		return recoveredCount, fmt.Errorf("error recovering jobs: failed to recover %d out of %d orphaned jobs", errorCount, len(orphanedJobs))
+	}
+	return recoveredCount, nil
+}
+
+// isRunnerLost returns true if the runner has stopped sending heartbeats. Runners that have never sent a
+// heartbeat (e.g. older runners) are never treated as lost, since we can't tell whether they are still running.
+func isRunnerLost(runner *models.Runner) bool {
+	return !runner.Online && runner.LastSeenAt != nil
+}
+
+// retryOnRunnerLoss returns true if the job should be queued to run again after its runner was lost.
+func retryOnRunnerLoss(job *models.Job) bool {
+	return job.RetryOnRunnerLoss && job.RunnerLossRetries < maxRunnerLossRetries
+}
+
+// makeRunnerLossMessage returns a message explaining that a job's runner was lost.
+func makeRunnerLossMessage(runner *models.Runner, retry bool) string {
+	lastSeen := "never"
+	if runner.LastSeenAt != nil {
+		lastSeen = runner.LastSeenAt.Format(time.RFC3339)
+	}
+	message := fmt.Sprintf("Runner %q stopped sending heartbeats (last seen %s) while running this job", runner.Name, lastSeen)
+	if retry {
+		message += "; the job has been queued to run again"
+	}
+	return message
+}
+
+// writeRunnerLossMessage explains what happened at the end of the job and step logs the lost runner will never
+// finish writing. This is best effort, since the job must be recovered even if the logs can't be written to.
+func (s *QueueService) writeRunnerLossMessage(ctx context.Context, job *models.Job, runner *models.Runner) {
+	message := makeRunnerLossMessage(runner, retryOnRunnerLoss(job))
+	logIDs := []models.LogDescriptorID{job.LogDescriptorID}
+	steps, err := s.stepService.ListByJobID(ctx, nil, job.ID)
+	if err != nil {
+		s.Warnf("Ignoring error listing steps to write runner loss message for job %s: %v", job.ID, err)
+	}
+	for _, step := range steps {
+		if !step.Status.HasFinished() {
+			logIDs = append(logIDs, step.LogDescriptorID)
+		}
+	}
+	for _, logID := range logIDs {
+		err = s.logService.AppendError(ctx, logID, message)
+		if err != nil {
+			s.Warnf("Ignoring error writing runner loss message to log %s: %v", logID, err)
+		}
+	}
+}
+
+// recoverOrphanedJob fails or requeues a job that was handed to a runner which has stopped sending heartbeats.
+// Returns false if the job no longer needs to be recovered (e.g. it has finished in the meantime).
+func (s *QueueService) recoverOrphanedJob(ctx context.Context, tx *store.Tx, jobID models.JobID, runner *models.Runner) (bool, error) {
+	job, err := s.jobService.Read(ctx, tx, jobID)
+	if err != nil {
+		return false, fmt.Errorf("error reading job: %w", err)
+	}
+	if job.Status.HasFinished() || job.Status == models.WorkflowStatusQueued || job.RunnerID != runner.ID {
+		return false, nil
+	}
+	steps, err := s.stepService.ListByJobID(ctx, tx, job.ID)
+	if err != nil {
+		return false, fmt.Errorf("error listing job steps: %w", err)
+	}
+	retry := retryOnRunnerLoss(job)
+	if retry {
+		err = s.requeueOrphanedJob(ctx, tx, job, steps)
+		if err != nil {
+			return false, err
+		}
+		s.Infof("Job %s was queued to run again after runner %s was lost", job.ID, runner.ID)
+		return true, nil
+	}
+
+	_, err = s.UpdateJobStatus(ctx, tx, job.ID, dto.UpdateJobStatus{
+		Status: models.WorkflowStatusFailed,
+		Error:  models.NewError(fmt.Errorf("error: %s", makeRunnerLossMessage(runner, false))),
+		ETag:   "", // fail the job regardless of whether it has been updated in the meantime
+	})
+	if err != nil {
+		return false, fmt.Errorf("error updating job status: %w", err)
+	}
+	for _, step := range steps {
+		if !step.Status.HasFinished() {
+			_, err = s.UpdateStepStatus(ctx, tx, step.ID, dto.UpdateStepStatus{
+				Status: models.WorkflowStatusFailed,
+				Error:  models.NewError(fmt.Errorf("error: step failed because the runner running its job was lost")),
+				ETag:   "", // fail the step regardless of whether it has been updated in the meantime
+			})
+			if err != nil {
+				return false, fmt.Errorf("error updating step status: %w", err)
+			}
+		}
+	}
+	s.Infof("Job %s was failed after runner %s was lost", job.ID, runner.ID)
+	return true, nil
+}
+
+// requeueOrphanedJob puts a job and its steps back in the queue so the job can be run again from scratch by
+// another runner. The logs from the lost run are sealed and kept, and the job and steps are given new logs.
+func (s *QueueService) requeueOrphanedJob(ctx context.Context, tx *store.Tx, job *models.Job, steps []*models.Step) error {
+	build, err := s.buildService.Read(ctx, tx, job.BuildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	err = s.logService.Seal(ctx, tx, job.LogDescriptorID)
+	if err != nil {
+		return fmt.Errorf("error sealing job log: %w", err)
+	}
+	jobLog, err := s.logService.Create(ctx, tx, models.NewLogDescriptor(models.NewTime(time.Now()), build.LogDescriptorID, job.ID.ResourceID))
+	if err != nil {
+		return fmt.Errorf("error creating log descriptor: %w", err)
+	}
+	job.LogDescriptorID = jobLog.ID
+	job.Status = models.WorkflowStatusQueued
+	job.RunnerID = models.RunnerID{}
+	job.Error = nil
+	job.Timings = models.WorkflowTimings{}
+	job.RunnerLossRetries++
+	_, err = s.updateJob(ctx, tx, job, true)
+	if err != nil {
+		return fmt.Errorf("error updating job: %w", err)
+	}
+	for _, step := range steps {
+		if step.Status == models.WorkflowStatusSkipped {
+			continue // skipped steps won't be run
+		}
+		if !step.Status.HasFinished() {
+			err = s.logService.Seal(ctx, tx, step.LogDescriptorID)
+			if err != nil {
+				return fmt.Errorf("error sealing step log: %w", err)
+			}
+		}
+		stepLog, err := s.logService.Create(ctx, tx, models.NewLogDescriptor(models.NewTime(time.Now()), job.LogDescriptorID, step.ID.ResourceID))
+		if err != nil {
+			return fmt.Errorf("error creating log descriptor: %w", err)
+		}
+		step.LogDescriptorID = stepLog.ID
+		step.Status = models.WorkflowStatusQueued
+		step.RunnerID = models.RunnerID{}
+		step.Error = nil
+		step.Timings = models.WorkflowTimings{}
+		_, err = s.updateStep(ctx, tx, job, step, true)
+		if err != nil {
+			return fmt.Errorf("error updating step: %w", err)
+		}
+	}
+	_, err = s.maintainBuildStatus(ctx, tx, job.BuildID)
+	if err != nil {
+		return fmt.Errorf("error maintaining build status: %w", err)
+	}
+	return nil
+}
+
 // UpdateJobStatus updates the status of a job.
 // If the new status is WorkflowStatusFailed then an error can be provided to indicate what happened.
 // This function will maintain the status of the build containing this job, to reflect the overall
 // status of the build each time the status of a job is changed, and publish build events for status changes.
 func (s *QueueService) UpdateJobStatus(ctx context.Context, txOrNil *store.Tx, jobID models.JobID, update dto.UpdateJobStatus) (*models.Job, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.UpdateJobStatus")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("job_id", jobID), attribute.Stringer("status", update.Status))
 	var (
 		err error
 		job *models.Job
@@ -435,6 +1609,12 @@ func (s *QueueService) UpdateJobStatus(ctx context.Context, txOrNil *store.Tx, j
 		if err != nil {
 			return fmt.Errorf("error maintaining job status: %w", err)
 		}
+		if jobStatusChanged && job.Status.HasFinished() {
+			err = s.finishAttachedJobs(ctx, tx, job)
+			if err != nil {
+				return fmt.Errorf("error finishing attached jobs: %w", err)
+			}
+		}
 		_, err = s.maintainBuildStatus(ctx, tx, job.BuildID)
 		if err != nil {
 			return fmt.Errorf("error maintaining build status: %w", err)
@@ -448,6 +1628,9 @@ func (s *QueueService) UpdateJobStatus(ctx context.Context, txOrNil *store.Tx, j
 // with the force option (e.g. force=false), the server will attempt to locate previously a successful job with a
 // matching fingerprint and indirect this job to it. If an indirection has been set, the agent must skip the job.
 func (s *QueueService) UpdateJobFingerprint(ctx context.Context, jobID models.JobID, update dto.UpdateJobFingerprint) (*models.Job, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.UpdateJobFingerprint")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("job_id", jobID))
 	var (
 		job *models.Job
 		err error
@@ -505,6 +1688,9 @@ func (s *QueueService) UpdateJobFingerprint(ctx context.Context, jobID models.Jo
 // UpdateStepStatus updates the status of a step that is executing under a job that was previously dequeued.
 // If the new status is WorkflowStatusFailed then an error can be provided to indicate what happened.
 func (s *QueueService) UpdateStepStatus(ctx context.Context, txOrNil *store.Tx, stepID models.StepID, update dto.UpdateStepStatus) (*models.Step, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueueService.UpdateStepStatus")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("step_id", stepID), attribute.Stringer("status", update.Status))
 	var (
 		step *models.Step
 		err  error
@@ -535,6 +1721,90 @@ func (s *QueueService) UpdateStepStatus(ctx context.Context, txOrNil *store.Tx,
 	return step, nil
 }
 
+// FailBuild marks a build that has succeeded as failed with the specified error, for use when a check made
+// after all the build's jobs have finished (such as a code coverage threshold) does not pass.
+// Builds that have not succeeded are returned unchanged.
+func (s *QueueService) FailBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, buildError *models.Error) (*models.Build, error) {
+	var (
+		build *models.Build
+		err   error
+	)
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err = s.buildService.LockRowForUpdate(ctx, tx, buildID)
+		if err != nil {
+			return fmt.Errorf("error locking build: %w", err)
+		}
+		build, err = s.buildService.Read(ctx, tx, buildID)
+		if err != nil {
+			return fmt.Errorf("error reading build: %w", err)
+		}
+		if build.Status != models.WorkflowStatusSucceeded {
+			return nil
+		}
+		// The build has already finished, so its timings and logs are left as they are; updateBuild
+		// isn't used since it would try to seal the build log a second time.
+		build.Status = models.WorkflowStatusFailed
+		build.Error = buildError
+		build.UpdatedAt = models.NewTime(time.Now())
+		err = s.buildService.Update(ctx, tx, build)
+		if err != nil {
+			return fmt.Errorf("error updating build: %w", err)
+		}
+		err = s.notifySCMBuildUpdated(ctx, tx, build)
+		if err != nil {
+			// Log and ignore errors while notifying SCM of build status change
+			s.Error(err)
+		}
+		err = s.eventService.PublishEvent(ctx, tx, models.NewBuildStatusChangedEventData(build))
+		if err != nil {
+			return fmt.Errorf("error publishing build status changed event: %w", err)
+		}
+		s.Infof("Build %s failed after finishing: %s", build.ID, buildError)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+// UpdateBuildPriority changes the priority of a build that has not finished, so its remaining queued jobs
+// are handed to runners ahead of (or behind) jobs from other builds. Returns a validation error if the
+// build has finished.
+func (s *QueueService) UpdateBuildPriority(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, update dto.UpdateBuildPriority) (*models.Build, error) {
+	err := models.ValidateBuildPriority(update.Priority)
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid build priority: %s", err))
+	}
+	var build *models.Build
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.buildService.LockRowForUpdate(ctx, tx, buildID)
+		if err != nil {
+			return fmt.Errorf("error locking build: %w", err)
+		}
+		build, err = s.buildService.Read(ctx, tx, buildID)
+		if err != nil {
+			return fmt.Errorf("error reading build: %w", err)
+		}
+		if build.Status.HasFinished() {
+			return gerror.NewErrValidationFailed(fmt.Sprintf("Only builds that have not finished can be reprioritized; build has status '%s'", build.Status))
+		}
+		build.ETag = models.GetETag(build, update.ETag)
+		build.Priority = update.Priority
+		build.UpdatedAt = models.NewTime(time.Now())
+		err = s.buildService.Update(ctx, tx, build)
+		if err != nil {
+			return fmt.Errorf("error updating build: %w", err)
+		}
+		s.Infof("Build %s priority changed to %d", build.ID, build.Priority)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
 func (s *QueueService) updateBuild(ctx context.Context, tx *store.Tx, build *models.Build, statusChanged bool) (*models.Build, error) {
 	now := models.NewTime(time.Now())
 	build.UpdatedAt = now
@@ -545,7 +1815,7 @@ func (s *QueueService) updateBuild(ctx context.Context, tx *store.Tx, build *mod
 		build.Timings.SubmittedAt = &now
 	case models.WorkflowStatusRunning:
 		build.Timings.RunningAt = &now
-	case models.WorkflowStatusSucceeded, models.WorkflowStatusFailed:
+	case models.WorkflowStatusSucceeded, models.WorkflowStatusFailed, models.WorkflowStatusSkipped:
 		build.Timings.FinishedAt = &now
 		err := s.logService.Seal(ctx, tx, build.LogDescriptorID)
 		if err != nil {
@@ -593,7 +1863,7 @@ func (s *QueueService) updateJob(ctx context.Context, tx *store.Tx, job *models.
 		job.Timings.SubmittedAt = &now
 	case models.WorkflowStatusRunning:
 		job.Timings.RunningAt = &now
-	case models.WorkflowStatusSucceeded, models.WorkflowStatusFailed:
+	case models.WorkflowStatusSucceeded, models.WorkflowStatusFailed, models.WorkflowStatusSkipped:
 		job.Timings.FinishedAt = &now
 		err := s.logService.Seal(ctx, tx, job.LogDescriptorID)
 		if err != nil {
@@ -618,6 +1888,11 @@ func (s *QueueService) updateJob(ctx context.Context, tx *store.Tx, job *models.
 		if err != nil {
 			return nil, fmt.Errorf("error publishing step status changed event: %w", err)
 		}
+		// Jobs that depended on a finished job may now be ready, and the runner has capacity for another
+		// job; a job that has been requeued is ready to run again
+		if job.Status == models.WorkflowStatusQueued || job.Status.HasFinished() {
+			s.notifyJobsReady(ctx, tx, job.RepoID)
+		}
 		s.Infof("Job %s transitioned to: %s", job.ID, job.Status)
 	} else {
 		s.Infof("Job %s updated (no change to status)", job.ID)
@@ -642,7 +1917,7 @@ func (s *QueueService) updateStep(
 		step.Timings.SubmittedAt = &now
 	case models.WorkflowStatusRunning:
 		step.Timings.RunningAt = &now
-	case models.WorkflowStatusSucceeded, models.WorkflowStatusFailed:
+	case models.WorkflowStatusSucceeded, models.WorkflowStatusFailed, models.WorkflowStatusSkipped:
 		step.Timings.FinishedAt = &now
 		err := s.logService.Seal(ctx, tx, step.LogDescriptorID)
 		if err != nil {
@@ -784,7 +2059,20 @@ func (s *QueueService) notifySCMBuildUpdated(ctx context.Context, txOrNil *store
 // Returns an error if there is a problem with the build graph (as well as any transient errors).
 func (s *QueueService) enqueueBuild(ctx context.Context, txOrNil *store.Tx, graph *dto.BuildGraph) (*dto.BuildGraph, error) {
 	return graph, s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
-		err := s.createBuild(ctx, tx, graph.Build)
+		repo, err := s.repoService.Read(ctx, tx, graph.Build.RepoID)
+		if err != nil {
+			return fmt.Errorf("error reading repo: %w", err)
+		}
+		// Reject new builds for legal entities that have used up their monthly quota
+		err = s.usageService.CheckQuotas(ctx, tx, repo.LegalEntityID)
+		if err != nil {
+			return err
+		}
+		err = s.setBuildPriority(ctx, tx, graph.Build)
+		if err != nil {
+			return fmt.Errorf("error setting build priority: %w", err)
+		}
+		err = s.createBuild(ctx, tx, graph.Build)
 		if err != nil {
 			return fmt.Errorf("error creating build: %w", err)
 		}
@@ -793,6 +2081,23 @@ func (s *QueueService) enqueueBuild(ctx context.Context, txOrNil *store.Tx, grap
 	})
 }
 
+// setBuildPriority sets the priority of a new build from the build's options if a priority was requested,
+// or otherwise from the repo's build priority settings.
+func (s *QueueService) setBuildPriority(ctx context.Context, tx *store.Tx, build *models.Build) error {
+	if build.Opts.Priority != nil {
+		build.Priority = *build.Opts.Priority
+		return nil
+	}
+	repo, err := s.repoService.Read(ctx, tx, build.RepoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	if repo.BuildPrioritySettings != nil {
+		build.Priority = repo.BuildPrioritySettings.PriorityForRef(build.Ref)
+	}
+	return nil
+}
+
 // EnqueueJobs enqueues jobs for an existing build idempotently. Assumes if a job by the same name already exists
 // within the build then it must be identical to the job in the specified in the build graph (so make sure you've
 // validated the graph before calling this function).
@@ -801,36 +2106,64 @@ func (s *QueueService) enqueueBuild(ctx context.Context, txOrNil *store.Tx, grap
 func (s *QueueService) enqueueJobs(ctx context.Context, txOrNil *store.Tx, bGraph *dto.BuildGraph) ([]*dto.JobGraph, error) {
 	var jGraphs []*dto.JobGraph
 	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
-		err := s.failJobsWithNoCompatibleRunner(ctx, tx, bGraph.Jobs)
-		if err != nil {
-			return fmt.Errorf("error checking for compatible runners: %w", err)
-		}
-		err = bGraph.Walk(false, func(job *dto.JobGraph) error {
-			_, err := s.jobService.Read(ctx, tx, job.ID)
+		// Find the new jobs and decide which of them should be skipped, visiting jobs in dependency order
+		// so that jobs depending on a skipped job are also skipped
+		var (
+			conditions  = s.newConditionEvaluator(ctx, tx, bGraph.Build)
+			skippedJobs = make(map[models.NodeFQN]bool)
+			runnable    []*dto.JobGraph
+		)
+		err := bGraph.Walk(false, func(job *dto.JobGraph) error {
+			existing, err := s.jobService.Read(ctx, tx, job.ID)
 			if err != nil && !gerror.IsNotFound(err) {
 				return fmt.Errorf("error reading existing job: %w", err)
 			}
 			if err == nil { // job already exists, nothing to do
+				skippedJobs[job.GetFQN()] = existing.Status == models.WorkflowStatusSkipped
 				return nil
 			}
+			err = conditions.applyJobCondition(job, skippedJobs)
+			if err != nil {
+				return fmt.Errorf("error applying job conditions: %w", err)
+			}
+			skippedJobs[job.GetFQN()] = job.Status == models.WorkflowStatusSkipped
+			jGraphs = append(jGraphs, job)
+			if !job.Status.HasFinished() {
+				runnable = append(runnable, job)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = s.failJobsWithNoCompatibleRunner(ctx, tx, runnable)
+		if err != nil {
+			return fmt.Errorf("error checking for compatible runners: %w", err)
+		}
+		for _, job := range jGraphs {
 			err = s.createJob(ctx, tx, bGraph.Build, job.Job)
 			if err != nil {
 				return fmt.Errorf("error creating job: %w", err)
 			}
-			jGraphs = append(jGraphs, job) // we created it, track it
-			return job.Walk(false, func(step *models.Step) error {
+			err = job.Walk(false, func(step *models.Step) error {
 				err := s.createStep(ctx, tx, job.Job, step)
 				if err != nil {
 					return fmt.Errorf("error creating step: %w", err)
 				}
 				return nil
 			})
-		})
+			if err != nil {
+				return err
+			}
+		}
+		bGraph.Build, err = s.maintainBuildStatus(ctx, tx, bGraph.Build.ID)
 		if err != nil {
 			return err
 		}
-		bGraph.Build, err = s.maintainBuildStatus(ctx, tx, bGraph.Build.ID)
-		return err
+		if len(runnable) > 0 {
+			s.notifyJobsReady(ctx, tx, bGraph.Build.RepoID)
+		}
+		return nil
 	})
 	if err != nil {
 		return nil, err
@@ -858,7 +2191,8 @@ func (s *QueueService) makeNewBuildGraph(
 		Timings: models.WorkflowTimings{
 			QueuedAt: &now,
 		},
-		Opts: models.BuildOptions{},
+		Opts:     models.BuildOptions{},
+		Warnings: buildDefinition.Warnings,
 	}}
 	err := s.makeJobGraphsAndAppendToBuildGraph(bGraph, buildDefinition.Jobs)
 	if err != nil {
@@ -882,6 +2216,9 @@ func (s *QueueService) makeNewBuildGraph(
 				if err != nil {
 					return nil, errors.Wrap(err, "error trimming build")
 				}
+				if opts.SkipDependencies {
+					bGraph.TrimDependencies(opts.NodesToRun)
+				}
 			}
 		}
 	}
@@ -909,20 +2246,7 @@ func (s *QueueService) makeJobGraphs(build *models.Build, jobs []models.JobDefin
 		}
 		var steps []*models.Step
 		for _, stepDef := range job.Steps {
-			steps = append(steps, &models.Step{
-				StepMetadata: models.StepMetadata{
-					ID:        models.NewStepID(),
-					CreatedAt: now,
-				},
-				StepData: models.StepData{
-					StepDefinitionData: stepDef.StepDefinitionData,
-					RepoID:             build.RepoID,
-					Status:             models.WorkflowStatusQueued,
-					Timings: models.WorkflowTimings{
-						QueuedAt: &now,
-					},
-				},
-			})
+			steps = append(steps, s.makeStep(build, stepDef, now))
 		}
 		jGraphs = append(jGraphs, &dto.JobGraph{
 			Job: &models.Job{
@@ -951,14 +2275,32 @@ func (s *QueueService) makeJobGraphs(build *models.Build, jobs []models.JobDefin
 	return jGraphs, nil
 }
 
+// makeStep creates (but does not persist) a queued step for a Step Definition, in the context of a build.
+func (s *QueueService) makeStep(build *models.Build, stepDef models.StepDefinition, now models.Time) *models.Step {
+	return &models.Step{
+		StepMetadata: models.StepMetadata{
+			ID:        models.NewStepID(),
+			CreatedAt: now,
+		},
+		StepData: models.StepData{
+			StepDefinitionData: stepDef.StepDefinitionData,
+			RepoID:             build.RepoID,
+			Status:             models.WorkflowStatusQueued,
+			Timings: models.WorkflowTimings{
+				QueuedAt: &now,
+			},
+		},
+	}
+}
+
 // makeJobGraphsAndAppendToBuildGraph creates (but does not persist) Job Graphs for a set of Job Definitions,
 // in the context of a build, and appends them to the build graph.
 // It does not validate the new job graphs, or the updated build graph.
 func (s *QueueService) makeJobGraphsAndAppendToBuildGraph(bGraph *dto.BuildGraph, jobs []models.JobDefinition) error {
 	// Validate that we won't exceed the maximum number of jobs for the build
-	if len(bGraph.Jobs)+len(jobs) > s.limits.MaxJobsPerBuild {
-		return gerror.NewErrValidationFailed(
-			fmt.Sprintf("Too many jobs in build; a maximum of %d jobs are allowed in a build", s.limits.MaxJobsPerBuild))
+	err := s.checkJobCount(bGraph, jobs)
+	if err != nil {
+		return err
 	}
 
 	jGraphs, err := s.makeJobGraphs(bGraph.Build, jobs)
@@ -971,10 +2313,100 @@ func (s *QueueService) makeJobGraphsAndAppendToBuildGraph(bGraph *dto.BuildGraph
 	return nil
 }
 
+// checkJobCount returns a limit exceeded error if adding jobs to the build graph would take the build over the
+// maximum number of jobs allowed in a build. The error reports how many jobs each workflow has, and identifies
+// the workflow contributing the most new jobs as the one at fault.
+func (s *QueueService) checkJobCount(bGraph *dto.BuildGraph, jobs []models.JobDefinition) error {
+	count := len(bGraph.Jobs) + len(jobs)
+	if count <= s.limits.MaxJobsPerBuild {
+		return nil
+	}
+	countsByWorkflow := make(map[models.ResourceName]int)
+	for _, job := range bGraph.Jobs {
+		countsByWorkflow[job.Workflow]++
+	}
+	newCountsByWorkflow := make(map[models.ResourceName]int)
+	for _, job := range jobs {
+		countsByWorkflow[job.Workflow]++
+		newCountsByWorkflow[job.Workflow]++
+	}
+	workflows := make([]models.ResourceName, 0, len(countsByWorkflow))
+	for workflow := range countsByWorkflow {
+		workflows = append(workflows, workflow)
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		if countsByWorkflow[workflows[i]] != countsByWorkflow[workflows[j]] {
+			return countsByWorkflow[workflows[i]] > countsByWorkflow[workflows[j]]
+		}
+		return workflows[i] < workflows[j]
+	})
+	counts := make([]string, 0, len(workflows))
+	var offender models.ResourceName
+	for _, workflow := range workflows {
+		name := fmt.Sprintf("workflow '%s'", workflow)
+		if workflow == "" {
+			name = "default workflow"
+		}
+		counts = append(counts, fmt.Sprintf("%s: %d", name, countsByWorkflow[workflow]))
+		if newCountsByWorkflow[workflow] > newCountsByWorkflow[offender] {
+			offender = workflow
+		}
+	}
+	return gerror.NewErrLimitExceeded(
+		fmt.Sprintf("Too many jobs in build; the build would have %d jobs but a maximum of %d jobs are allowed in a build (jobs per workflow: %s)",
+			count, s.limits.MaxJobsPerBuild, strings.Join(counts, ", ")),
+		"max_jobs_per_build",
+		s.limits.MaxJobsPerBuild,
+		count).
+		EDetail(gerror.WorkflowDetailKey, offender.String())
+}
+
+// recordLimitExceeded publishes a LimitExceeded event for the build if err reports that a limit was exceeded,
+// so the build's author can see which workflow or job needs to be split up. Errors publishing the event are
+// logged rather than returned, so as not to hide the original problem.
+func (s *QueueService) recordLimitExceeded(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, err error) {
+	limitErr := gerror.ToLimitExceeded(err)
+	if limitErr == nil {
+		return
+	}
+	details := limitErr.Details()
+	detailName := func(key gerror.DetailKey) models.ResourceName {
+		if detail, ok := details[key]; ok {
+			return models.ResourceName(fmt.Sprintf("%v", detail.Value()))
+		}
+		return ""
+	}
+	eventData := models.NewLimitExceededEventData(
+		buildID,
+		detailName(gerror.WorkflowDetailKey),
+		detailName(gerror.JobDetailKey),
+		limitErr.Message())
+	err = s.eventService.PublishEvent(ctx, txOrNil, eventData)
+	if err != nil {
+		s.Warnf("Ignoring error publishing limit exceeded event for build %s: %v", buildID, err)
+	}
+}
+
 // createFailedBuild creates a failed build with the minimal information available at the time of creation.
 // We use this in case we are unable to create a build during the normal Enqueuing process where we need a build to
-// represent a commit that is in a failed state.
+// represent a commit that is in a failed state. If the build failed because it exceeded a limit, the problem
+// is also recorded as a LimitExceeded event against the build.
 func (s *QueueService) createFailedBuild(ctx context.Context, txOrNil *store.Tx, commit *models.Commit, ref string, opts *models.BuildOptions, err error) (*dto.BuildGraph, error) {
+	graph, createErr := s.createFinishedBuild(ctx, txOrNil, commit, ref, opts, models.WorkflowStatusFailed, models.NewError(err))
+	if createErr == nil {
+		s.recordLimitExceeded(ctx, txOrNil, graph.ID, err)
+	}
+	return graph, createErr
+}
+
+// createSkippedBuild creates a build with no jobs that is immediately set to skipped, to record that a
+// commit was not built.
+func (s *QueueService) createSkippedBuild(ctx context.Context, txOrNil *store.Tx, commit *models.Commit, ref string, opts *models.BuildOptions) (*dto.BuildGraph, error) {
+	return s.createFinishedBuild(ctx, txOrNil, commit, ref, opts, models.WorkflowStatusSkipped, nil)
+}
+
+// createFinishedBuild creates a build with no jobs that has already finished with the specified status.
+func (s *QueueService) createFinishedBuild(ctx context.Context, txOrNil *store.Tx, commit *models.Commit, ref string, opts *models.BuildOptions, status models.WorkflowStatus, errOrNil *models.Error) (*dto.BuildGraph, error) {
 	now := models.NewTime(time.Now())
 	graph := &dto.BuildGraph{
 		Build: &models.Build{
@@ -983,14 +2415,14 @@ func (s *QueueService) createFailedBuild(ctx context.Context, txOrNil *store.Tx,
 			CreatedAt: now,
 			CommitID:  commit.ID,
 			Ref:       ref,
-			Status:    models.WorkflowStatusFailed,
+			Status:    status,
 			Timings: models.WorkflowTimings{
 				QueuedAt:    &now,
 				SubmittedAt: &now,
 				RunningAt:   &now,
 				FinishedAt:  &now,
 			},
-			Error: models.NewError(err),
+			Error: errOrNil,
 		},
 	}
 	graph.PopulateDefaults()
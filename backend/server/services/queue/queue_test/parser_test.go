@@ -1,12 +1,17 @@
 package queue_test
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
 	"github.com/stretchr/testify/require"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
 )
@@ -81,6 +86,904 @@ func TestParseStepExecution(t *testing.T) {
 	require.Equal(t, build.Jobs[3].Steps[2].Depends[0].StepName, build.Jobs[3].Steps[1].Name)
 }
 
+func TestParseStepArtifacts(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: test
+    docker:
+      image: golang:1.19
+    steps:
+      - name: lint
+        commands:
+          - make lint
+        artifacts:
+          - reports/lint.xml
+      - name: unit
+        commands:
+          - make test
+        artifacts:
+          - name: test-reports
+            paths:
+              - reports/junit/*.xml
+              - reports/coverage.out
+    artifacts:
+      - bin/app
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 1)
+	job := build.Jobs[0]
+
+	// Job level artifacts declared as a list of paths keep the default name
+	require.Len(t, job.ArtifactDefinitions, 1)
+	require.Equal(t, models.ResourceName("default"), job.ArtifactDefinitions[0].GroupName)
+
+	// Step level artifacts declared as a list of paths are named after the step
+	require.Len(t, job.Steps, 2)
+	require.Len(t, job.Steps[0].ArtifactDefinitions, 1)
+	require.Equal(t, models.ResourceName("lint"), job.Steps[0].ArtifactDefinitions[0].GroupName)
+	require.Equal(t, []string{"reports/lint.xml"}, job.Steps[0].ArtifactDefinitions[0].Paths)
+	require.Len(t, job.Steps[1].ArtifactDefinitions, 1)
+	require.Equal(t, models.ResourceName("test-reports"), job.Steps[1].ArtifactDefinitions[0].GroupName)
+	require.Equal(t, []string{"reports/junit/*.xml", "reports/coverage.out"}, job.Steps[1].ArtifactDefinitions[0].Paths)
+}
+
+func TestParseStages(t *testing.T) {
+	config := `
+version: 0.3
+stages:
+  - build
+  - test
+  - deploy
+jobs:
+  - name: deploy
+    stage: deploy
+    docker:
+      image: golang:1.19
+    steps:
+      - name: deploy
+        commands:
+          - make deploy
+  - name: compile
+    stage: build
+    docker:
+      image: golang:1.19
+    steps:
+      - name: compile
+        commands:
+          - make
+  - name: unit
+    stage: test
+    depends: compile
+    docker:
+      image: golang:1.19
+    steps:
+      - name: unit
+        commands:
+          - make test
+  - name: integration
+    stage: test
+    docker:
+      image: golang:1.19
+    steps:
+      - name: integration
+        commands:
+          - make integration
+  - name: lint
+    docker:
+      image: golang:1.19
+    steps:
+      - name: lint
+        commands:
+          - make lint
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 5)
+	jobsByName := make(map[models.ResourceName]models.JobDefinition)
+	for _, job := range build.Jobs {
+		jobsByName[job.Name] = job
+	}
+	dependencyNames := func(job models.JobDefinition) []models.ResourceName {
+		var names []models.ResourceName
+		for _, dependency := range job.Depends {
+			names = append(names, dependency.JobName)
+		}
+		return names
+	}
+
+	// Jobs in the first stage, and jobs without a stage, gain no dependencies
+	require.Empty(t, jobsByName["compile"].Depends)
+	require.Empty(t, jobsByName["lint"].Depends)
+
+	// Explicit dependencies on jobs in the previous stage aren't duplicated
+	require.Equal(t, []models.ResourceName{"compile"}, dependencyNames(jobsByName["unit"]))
+	require.Equal(t, []models.ResourceName{"compile"}, dependencyNames(jobsByName["integration"]))
+
+	// Stages are ordered by the stages list rather than the order jobs appear in
+	require.Equal(t, models.ResourceName("deploy"), jobsByName["deploy"].Stage)
+	require.ElementsMatch(t, []models.ResourceName{"unit", "integration"}, dependencyNames(jobsByName["deploy"]))
+
+	// Jobs must belong to a listed stage when a stages list is given
+	_, err = parser.Parse([]byte(strings.Replace(config, "stage: deploy", "stage: release", 1)), models.ConfigTypeYAML)
+	require.Error(t, err)
+}
+
+func TestParseCheckout(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: build
+    docker:
+      image: golang:1.19
+    steps:
+      - name: build
+        commands:
+          - make
+  - name: notify
+    checkout: false
+    depends: build
+    docker:
+      image: alpine
+    steps:
+      - name: notify
+        commands:
+          - ./notify.sh
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 2)
+	require.False(t, build.Jobs[0].SkipCheckout, "jobs are checked out by default")
+	require.True(t, build.Jobs[1].SkipCheckout)
+
+	_, err = parser.Parse([]byte(strings.Replace(config, "checkout: false", "checkout: nope", 1)), models.ConfigTypeYAML)
+	require.Error(t, err)
+
+	// Dynamic builds submit jobs as JSON, where checkout is a real boolean
+	jsonConfig := `{"version": "0.3", "jobs": [{"name": "notify", "checkout": false, "docker": {"image": "alpine"}, "steps": [{"name": "notify", "commands": ["./notify.sh"]}]}]}`
+	build, err = parser.Parse([]byte(jsonConfig), models.ConfigTypeJSON)
+	require.NoError(t, err)
+	require.True(t, build.Jobs[0].SkipCheckout)
+}
+
+func TestParseArtifactFrom(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: deploy
+    artifact_from:
+      - build-123:go-binaries
+      - release-repo@456
+      - Release-Repo@build-789:docs
+    docker:
+      image: alpine
+    steps:
+      - name: deploy
+        commands:
+          - ./deploy.sh
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 1)
+	require.Equal(t, models.ExternalArtifactDependencies{
+		models.NewExternalArtifactDependency("", "123", "go-binaries"),
+		models.NewExternalArtifactDependency("release-repo", "456", ""),
+		models.NewExternalArtifactDependency("Release-Repo", "789", "docs"),
+	}, build.Jobs[0].ArtifactFrom)
+
+	for _, invalid := range []string{"build-abc", "repo@", "repo@build-1:", "repo@@build-1", "repo.name@build-1"} {
+		_, err = parser.Parse([]byte(strings.Replace(config, "build-123:go-binaries", invalid, 1)), models.ConfigTypeYAML)
+		require.Error(t, err, "expected error parsing %q", invalid)
+	}
+
+	// A single reference can be given as a string
+	jsonConfig := `{"version": "0.3", "jobs": [{"name": "deploy", "artifact_from": "app@build-7:bin", "docker": {"image": "alpine"}, "steps": [{"name": "deploy", "commands": ["./deploy.sh"]}]}]}`
+	build, err = parser.Parse([]byte(jsonConfig), models.ConfigTypeJSON)
+	require.NoError(t, err)
+	require.Equal(t, models.ExternalArtifactDependencies{
+		models.NewExternalArtifactDependency("app", "7", "bin"),
+	}, build.Jobs[0].ArtifactFrom)
+}
+
+// mapTemplateLoader loads templates from a map of template reference strings to contents.
+type mapTemplateLoader map[string]string
+
+func (l mapTemplateLoader) LoadTemplate(ref parser.TemplateReference) ([]byte, error) {
+	contents, ok := l[ref.String()]
+	if !ok {
+		return nil, gerror.NewErrNotFound(fmt.Sprintf("template %s not found", ref))
+	}
+	return []byte(contents), nil
+}
+
+func TestParseTemplates(t *testing.T) {
+	loader := mapTemplateLoader{
+		"ci/lint.yml": `
+params:
+  - image
+jobs:
+  - name: lint
+    docker:
+      image: ${{ params.image }}
+    steps:
+      - name: lint
+        commands:
+          - make lint
+`,
+		"shared-ci@v1:templates/go.yml": `
+params:
+  go_version: "1.21"
+extends: base.yml
+docker:
+  image: golang:${{ params.go_version }}
+`,
+		"shared-ci@v1:base.yml": `
+description: Shared Go job
+steps:
+  - name: build
+    commands:
+      - go build ./...
+`,
+	}
+	config := `
+version: 0.3
+include:
+  - path: ci/lint.yml
+    with:
+      image: golangci/golangci-lint
+jobs:
+  - name: build
+    extends:
+      repo: shared-ci
+      ref: v1
+      path: templates/go.yml
+      with:
+        go_version: "1.22"
+  - name: test
+    extends: shared-ci@v1:templates/go.yml
+    description: Run the tests
+    steps:
+      - name: test
+        commands:
+          - go test ./...
+`
+	build, err := parser.NewBuildDefinitionParserWithTemplates(parser.ParserLimits{}, loader).Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 3)
+
+	lint := build.Jobs[0]
+	require.Equal(t, models.ResourceName("lint"), lint.Name)
+	require.Equal(t, "golangci/golangci-lint", lint.DockerImage)
+
+	// Templates extended by other templates are resolved relative to the repo and ref of the extending template
+	goBuild := build.Jobs[1]
+	require.Equal(t, models.ResourceName("build"), goBuild.Name)
+	require.Equal(t, "golang:1.22", goBuild.DockerImage)
+	require.Equal(t, "Shared Go job", goBuild.Description)
+	require.Len(t, goBuild.Steps, 1)
+	require.Equal(t, models.Commands{"go build ./..."}, goBuild.Steps[0].Commands)
+
+	// Elements set on the job replace those in the template, and default parameter values are used
+	goTest := build.Jobs[2]
+	require.Equal(t, "golang:1.21", goTest.DockerImage)
+	require.Equal(t, "Run the tests", goTest.Description)
+	require.Len(t, goTest.Steps, 1)
+	require.Equal(t, models.ResourceName("test"), goTest.Steps[0].Name)
+
+	// Templates can't be used without a loader
+	_, err = parser.NewBuildDefinitionParser(parser.ParserLimits{}).Parse([]byte(config), models.ConfigTypeYAML)
+	require.Error(t, err)
+
+	t.Run("Errors", func(t *testing.T) {
+		loader := mapTemplateLoader{
+			"a.yml": "include: b.yml\njobs: []",
+			"b.yml": "include: a.yml\njobs: []",
+			"c.yml": "params: [image]\njobs: []",
+			"d.yml": "docker:\n  image: ${{ params.image }}",
+			"e.yml": "stages: [build]\njobs: []",
+		}
+		for config, expected := range map[string]string{
+			"version: 0.3\ninclude: a.yml":                                         "template cycle detected: a.yml -> b.yml -> a.yml",
+			"version: 0.3\ninclude: missing.yml":                                   "template missing.yml not found",
+			"version: 0.3\ninclude: c.yml":                                         `no value supplied for required template parameter "image"`,
+			"version: 0.3\ninclude: {path: c.yml, with: {image: x, tag: y}}":       `parameter "tag" is not declared by the template`,
+			"version: 0.3\njobs:\n  - name: build\n    extends: d.yml":             `template refers to undeclared parameter "image"`,
+			"version: 0.3\ninclude: e.yml":                                         `unsupported element "stages"`,
+			"version: 0.3\njobs:\n  - name: build\n    extends: {repo: x, ref: y}": "template reference must specify a 'path'",
+		} {
+			_, err := parser.NewBuildDefinitionParserWithTemplates(parser.ParserLimits{}, loader).Parse([]byte(config), models.ConfigTypeYAML)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), expected)
+		}
+	})
+}
+
+func TestParseErrorLocations(t *testing.T) {
+	config := `version: 0.3
+jobs:
+  - name: build
+    docker:
+      image: golang:1.19
+    steps:
+      - name: build
+        commands:
+          - go build ./...
+  - name: test
+    docker:
+      image: golang:1.19
+    steps:
+      - name: test
+        timeout: soon
+        commands:
+          - go test ./...
+`
+	_, err := parser.NewBuildDefinitionParser(parser.ParserLimits{}).Parse([]byte(config), models.ConfigTypeYAML)
+	require.Error(t, err)
+	parseErr := parser.AsParseError(err)
+	require.NotNil(t, parseErr)
+	require.Equal(t, "", parseErr.File)
+	require.Equal(t, 15, parseErr.Line)
+	require.Equal(t, 9, parseErr.Column)
+	require.Equal(t, "jobs[1].steps[0].timeout", parseErr.Path)
+	require.Contains(t, err.Error(), "line 15, column 9: jobs[1].steps[0].timeout")
+
+	// Errors in jobs from templates are reported against the template file
+	loader := mapTemplateLoader{
+		"ci/lint.yml": `jobs:
+  - name: lint
+    docker:
+      image: golangci/golangci-lint
+      pull: sometimes
+`,
+	}
+	config = `version: 0.3
+include: ci/lint.yml
+jobs:
+  - name: build
+    docker:
+      image: golang:1.19
+    steps:
+      - name: build
+        commands:
+          - go build ./...
+`
+	_, err = parser.NewBuildDefinitionParserWithTemplates(parser.ParserLimits{}, loader).Parse([]byte(config), models.ConfigTypeYAML)
+	require.Error(t, err)
+	parseErr = parser.AsParseError(err)
+	require.NotNil(t, parseErr)
+	require.Equal(t, "ci/lint.yml", parseErr.File)
+	require.Equal(t, 5, parseErr.Line)
+	require.Equal(t, "jobs[0].docker.pull", parseErr.Path)
+
+	// Errors within templates are reported against the template, with the referencing element in the message
+	config = "version: 0.3\njobs:\n  - name: build\n    extends: ci/go.yml\n"
+	loader = mapTemplateLoader{"ci/go.yml": "docker:\n  image: ${{ params.image }}"}
+	_, err = parser.NewBuildDefinitionParserWithTemplates(parser.ParserLimits{}, loader).Parse([]byte(config), models.ConfigTypeYAML)
+	require.Error(t, err)
+	parseErr = parser.AsParseError(err)
+	require.NotNil(t, parseErr)
+	require.Equal(t, "ci/go.yml", parseErr.File)
+	require.Equal(t, 2, parseErr.Line)
+	require.Equal(t, "docker.image", parseErr.Path)
+	require.Contains(t, err.Error(), "line 4, column 5: jobs[0].extends")
+}
+
+func TestParseVersions(t *testing.T) {
+	jobs := `
+jobs:
+  - name: test
+    docker:
+      image: golang:1.19
+    steps:
+      - name: test
+        commands:
+          - go test ./...
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+
+	// Build definitions without a version use the default version, with a warning to pin the version
+	build, err := parser.Parse([]byte(jobs), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Equal(t, "0.2", build.Version)
+	require.Len(t, build.Warnings, 1)
+	require.Contains(t, build.Warnings[0], "no 'version' specified")
+
+	build, err = parser.Parse([]byte("version: 0.2"+jobs), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Equal(t, "0.2", build.Version)
+	require.Len(t, build.Warnings, 1)
+	require.Contains(t, build.Warnings[0], "version 0.2 is deprecated")
+
+	build, err = parser.Parse([]byte("version: 0.3.0"+jobs), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Equal(t, "0.3", build.Version)
+	require.Empty(t, build.Warnings)
+
+	_, err = parser.Parse([]byte("version: 1.0"+jobs), models.ConfigTypeYAML)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "newer than the latest version supported by this server")
+
+	_, err = parser.Parse([]byte("version: 0.1"+jobs), models.ConfigTypeYAML)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "version 0.1 not supported")
+
+	// Deprecated fields are reported as warnings, with their location
+	config := `version: 0.3
+jobs:
+  - name: test
+    kind: job
+    docker:
+      image: golang:1.19
+    steps:
+      - name: test
+        environment:
+          GOFLAGS: -mod=vendor
+        commands:
+          - go test ./...
+`
+	build, err = parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"line 4, column 5: jobs[0].kind: 'kind' is deprecated and can be removed; all jobs are pipeline jobs",
+		"line 9, column 9: jobs[0].steps[0].environment: step 'environment' is deprecated and is ignored; set 'environment' on the job instead",
+	}, []string(build.Warnings))
+}
+
+func TestParseRetryOnRunnerLoss(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: build
+    docker:
+      image: golang:1.19
+    steps:
+      - name: build
+        commands:
+          - make
+  - name: test
+    retry_on_runner_loss: true
+    docker:
+      image: golang:1.19
+    steps:
+      - name: test
+        commands:
+          - make test
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 2)
+	require.False(t, build.Jobs[0].RetryOnRunnerLoss, "jobs are not retried by default")
+	require.True(t, build.Jobs[1].RetryOnRunnerLoss)
+
+	_, err = parser.Parse([]byte(strings.Replace(config, "retry_on_runner_loss: true", "retry_on_runner_loss: maybe", 1)), models.ConfigTypeYAML)
+	require.Error(t, err)
+}
+
+func TestParsePinSecrets(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: build
+    docker:
+      image: golang:1.19
+    steps:
+      - name: build
+        commands:
+          - make
+  - name: deploy
+    pin_secrets: true
+    docker:
+      image: golang:1.19
+    steps:
+      - name: deploy
+        commands:
+          - make deploy
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 2)
+	require.False(t, build.Jobs[0].PinSecrets, "secrets are not pinned by default")
+	require.True(t, build.Jobs[1].PinSecrets)
+
+	_, err = parser.Parse([]byte(strings.Replace(config, "pin_secrets: true", "pin_secrets: maybe", 1)), models.ConfigTypeYAML)
+	require.Error(t, err)
+}
+
+func TestParseStepTimeout(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: test
+    docker:
+      image: golang:1.19
+    steps:
+      - name: duration
+        timeout: 1h30m
+        commands:
+          - make test
+      - name: seconds
+        timeout: 90
+        commands:
+          - make lint
+      - name: none
+        commands:
+          - make vet
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 1)
+	steps := build.Jobs[0].Steps
+	require.Len(t, steps, 3)
+	require.Equal(t, int64(5400), steps[0].TimeoutSeconds)
+	require.Equal(t, int64(90), steps[1].TimeoutSeconds)
+	require.Equal(t, int64(0), steps[2].TimeoutSeconds)
+
+	for _, invalid := range []string{"timeout: soon", "timeout: 500ms", "timeout: -5"} {
+		_, err = parser.Parse([]byte(strings.Replace(config, "timeout: 90", invalid, 1)), models.ConfigTypeYAML)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestParseStepEcho(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: test
+    docker:
+      image: golang:1.19
+    steps:
+      - name: loud
+        echo: true
+        commands:
+          - make test
+      - name: quiet
+        echo: false
+        commands:
+          - make lint
+      - name: default
+        commands:
+          - make vet
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 1)
+	steps := build.Jobs[0].Steps
+	require.Len(t, steps, 3)
+	require.NotNil(t, steps[0].EchoCommands)
+	require.True(t, *steps[0].EchoCommands)
+	require.NotNil(t, steps[1].EchoCommands)
+	require.False(t, *steps[1].EchoCommands)
+	require.Nil(t, steps[2].EchoCommands)
+
+	_, err = parser.Parse([]byte(strings.Replace(config, "echo: false", "echo: loudly", 1)), models.ConfigTypeYAML)
+	require.Error(t, err)
+}
+
+func TestParseDependenciesAcrossWorkflows(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: deploy
+    docker:
+      image: golang:1.19
+    depends:
+      - linux.build.artifacts
+      - workflow.windows.jobs.build.artifacts.installer
+      - build
+    steps:
+      - name: deploy
+        commands:
+          - make deploy
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 1)
+
+	// Jobs named 'build' in different workflows are separate dependencies, in the order they're declared
+	depends := build.Jobs[0].Depends
+	require.Len(t, depends, 3)
+	require.Equal(t, models.NewNodeFQNForJob("linux", "build"), depends[0].GetFQN())
+	require.Equal(t, []*models.ArtifactDependency{models.NewArtifactDependency("linux", "build", "")}, depends[0].ArtifactDependencies)
+	require.Equal(t, models.NewNodeFQNForJob("windows", "build"), depends[1].GetFQN())
+	require.Equal(t, []*models.ArtifactDependency{models.NewArtifactDependency("windows", "build", "installer")}, depends[1].ArtifactDependencies)
+	require.Equal(t, models.NewNodeFQNForJob("", "build"), depends[2].GetFQN())
+	require.Empty(t, depends[2].ArtifactDependencies)
+}
+
+func TestParseCachePresets(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: test
+    docker:
+      image: golang:1.19
+    caches:
+      - go-modules
+      - preset: node-modules
+        paths: web/node_modules
+      - name: custom
+        paths: build
+        key: cat Makefile
+    steps:
+      - name: test
+        commands:
+          - make test
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 1)
+	caches := build.Jobs[0].CacheDefinitions
+	require.Len(t, caches, 3)
+
+	require.Equal(t, models.ResourceName("go-modules"), caches[0].Name)
+	require.Equal(t, models.CachePresetGoModules, caches[0].Preset)
+	require.NotEmpty(t, caches[0].Paths)
+	require.NotEmpty(t, caches[0].KeyCommands)
+	require.Equal(t, "/workspace/.cache/go-mod", caches[0].Preset.Environment("/workspace")["GOMODCACHE"])
+
+	// Fields given explicitly override the preset's
+	require.Equal(t, models.ResourceName("node-modules"), caches[1].Name)
+	require.Equal(t, []string{"web/node_modules"}, caches[1].Paths)
+	require.NotEmpty(t, caches[1].KeyCommands)
+
+	require.Equal(t, models.ResourceName("custom"), caches[2].Name)
+	require.Equal(t, models.CachePreset(""), caches[2].Preset)
+	require.Empty(t, caches[2].Preset.Environment("/workspace"))
+
+	for _, invalid := range []string{"- rust-crates", "- preset: rust-crates"} {
+		_, err = parser.Parse([]byte(strings.Replace(config, "- go-modules", invalid, 1)), models.ConfigTypeYAML)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	config := `
+version: 0.3
+jobs:
+  - name: deploy
+    if: branch == "main" && !pull_request
+    docker:
+      image: golang:1.19
+    steps:
+      - name: docs
+        if: changed_files matches "docs/**"
+        commands:
+          - make docs
+      - name: always
+        if: true
+        commands:
+          - make deploy
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 1)
+	require.Equal(t, `branch == "main" && !pull_request`, build.Jobs[0].Condition)
+	require.Equal(t, `changed_files matches "docs/**"`, build.Jobs[0].Steps[0].Condition)
+	require.Equal(t, "true", build.Jobs[0].Steps[1].Condition)
+
+	for _, invalid := range []string{
+		`if: branch = "main"`,        // unknown operator
+		`if: branch == pull_request`, // mismatched types
+		`if: branch`,                 // not a boolean
+		`if: commit == "abc"`,        // unknown variable
+		`if: branch matches "[a"`,    // invalid glob
+	} {
+		_, err = parser.Parse([]byte(strings.Replace(config, `if: branch == "main" && !pull_request`, invalid, 1)), models.ConfigTypeYAML)
+		require.Error(t, err, invalid)
+		require.Contains(t, err.Error(), "jobs[0].if", invalid)
+	}
+}
+
+func TestEvaluateConditions(t *testing.T) {
+	changedFilesRead := 0
+	ctx := &parser.ConditionContext{
+		Branch: "main",
+		Ref:    "refs/heads/main",
+		Env:    map[string]string{"DEPLOY": "yes"},
+		ChangedFiles: func() ([]string, error) {
+			changedFilesRead++
+			return []string{"docs/index.md", "backend/main.go"}, nil
+		},
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`branch == "main"`, true},
+		{`branch != "main"`, false},
+		{`tag == ""`, true},
+		{`pull_request`, false},
+		{`!pull_request && ref matches "refs/heads/*"`, true},
+		{`env.DEPLOY == "yes"`, true},
+		{`env.MISSING == ""`, true},
+		{`changed_files matches "docs/**"`, true},
+		{`changed_files matches "frontend/**"`, false},
+		{`branch == "release" || (pull_request || changed_files matches "**/*.go")`, true},
+	}
+	for _, test := range tests {
+		condition, err := parser.ParseCondition(test.expr)
+		require.NoError(t, err, test.expr)
+		met, err := condition.Evaluate(ctx)
+		require.NoError(t, err, test.expr)
+		require.Equal(t, test.expected, met, test.expr)
+	}
+
+	// Changed files are only read if the result depends on them
+	changedFilesRead = 0
+	condition, err := parser.ParseCondition(`branch == "main" || changed_files matches "docs/**"`)
+	require.NoError(t, err)
+	met, err := condition.Evaluate(ctx)
+	require.NoError(t, err)
+	require.True(t, met)
+	require.Equal(t, 0, changedFilesRead)
+}
+
+func TestParsePathFilters(t *testing.T) {
+	config := `
+version: 0.3
+workflows:
+  - name: backend
+    only_paths:
+      - backend/**
+    ignore_paths: "**/*.md"
+jobs:
+  - name: test
+    workflow: backend
+    docker:
+      image: golang:1.19
+    steps:
+      - name: test
+        commands:
+          - make test
+  - name: lint
+    workflow: backend
+    only_paths: [backend/**, go.mod]
+    docker:
+      image: golang:1.19
+    steps:
+      - name: lint
+        commands:
+          - make lint
+  - name: docs
+    docker:
+      image: golang:1.19
+    steps:
+      - name: docs
+        commands:
+          - make docs
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Jobs, 3)
+	require.Equal(t, models.PathPatterns{"backend/**"}, build.Jobs[0].OnlyPaths)
+	require.Equal(t, models.PathPatterns{"**/*.md"}, build.Jobs[0].IgnorePaths)
+	// Path filters set on a job override those of its workflow
+	require.Equal(t, models.PathPatterns{"backend/**", "go.mod"}, build.Jobs[1].OnlyPaths)
+	require.Equal(t, models.PathPatterns{"**/*.md"}, build.Jobs[1].IgnorePaths)
+	require.Nil(t, build.Jobs[2].OnlyPaths)
+	require.Nil(t, build.Jobs[2].IgnorePaths)
+
+	for _, invalid := range []string{
+		`only_paths: ["[a"]`, // invalid glob
+		`only_paths: [""]`,   // empty pattern
+		`only_paths: {a: b}`, // not a list of patterns
+	} {
+		_, err = parser.Parse([]byte(strings.Replace(config, `only_paths: [backend/**, go.mod]`, invalid, 1)), models.ConfigTypeYAML)
+		require.Error(t, err, invalid)
+		require.Contains(t, err.Error(), "jobs[1].only_paths", invalid)
+	}
+	_, err = parser.Parse([]byte(strings.Replace(config, `ignore_paths: "**/*.md"`, `ignore_paths: "[a"`, 1)), models.ConfigTypeYAML)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "workflows[0].ignore_paths")
+}
+
+func TestParseParametersAndBuildEnvironment(t *testing.T) {
+	config := `
+version: 0.3
+environment:
+  MODE: release
+  REGION: eu
+parameters:
+  - name: target
+    description: Where to deploy to
+    type: choice
+    choices: [staging, production]
+    default: staging
+  - name: dry_run
+    type: boolean
+    default: yes
+  - name: build_number
+    type: number
+jobs:
+  - name: build
+    docker:
+      image: golang:1.19
+    environment:
+      REGION: us
+    steps:
+      - name: build
+        commands:
+          - make
+`
+	parser := parser.NewBuildDefinitionParser(parser.ParserLimits{})
+	build, err := parser.Parse([]byte(config), models.ConfigTypeYAML)
+	require.NoError(t, err)
+	require.Len(t, build.Parameters, 3)
+	require.Equal(t, "target", build.Parameters[0].Name)
+	require.Equal(t, models.BuildParameterTypeChoice, build.Parameters[0].Type)
+	require.Equal(t, []string{"staging", "production"}, build.Parameters[0].Choices)
+	require.Nil(t, build.Parameters[2].Default)
+
+	// Environment variables set on a job override those set for the build
+	require.Len(t, build.Jobs, 1)
+	env := make(map[string]string)
+	for _, envVar := range build.Jobs[0].Environment {
+		env[envVar.Name] = envVar.Value
+	}
+	require.Equal(t, map[string]string{"MODE": "release", "REGION": "us"}, env)
+
+	resolved, err := models.ResolveBuildParameters(build.Parameters, models.BuildParameters{"build_number": "42"})
+	require.NoError(t, err)
+	require.Equal(t, models.BuildParameters{"target": "staging", "dry_run": "true", "build_number": "42"}, resolved)
+	for _, invalid := range []models.BuildParameters{
+		{},                                      // missing required parameter
+		{"build_number": "forty-two"},           // not a number
+		{"build_number": "42", "target": "dev"}, // not one of the choices
+		{"build_number": "42", "unknown": "value"}, // not declared
+	} {
+		_, err = models.ResolveBuildParameters(build.Parameters, invalid)
+		require.Error(t, err, invalid)
+	}
+
+	for _, invalid := range []string{
+		"default: nope",      // default is not a valid choice
+		"type: colour",       // unknown type
+		"name: dry-run",      // not usable in an environment variable name
+		"name: build_number", // duplicate
+	} {
+		_, err = parser.Parse([]byte(strings.Replace(config, "default: staging", invalid, 1)), models.ConfigTypeYAML)
+		require.Error(t, err, invalid)
+		require.Contains(t, err.Error(), "parameters[", invalid)
+	}
+}
+
+func TestPathFiltersMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		onlyPaths    models.PathPatterns
+		ignorePaths  models.PathPatterns
+		changedFiles []string
+		expected     bool
+	}{
+		{"no filters", nil, nil, []string{"docs/index.md"}, true},
+		{"only paths matched", models.PathPatterns{"backend/**"}, nil, []string{"docs/index.md", "backend/main.go"}, true},
+		{"only paths not matched", models.PathPatterns{"backend/**"}, nil, []string{"docs/index.md"}, false},
+		{"only paths with no changes", models.PathPatterns{"backend/**"}, nil, nil, false},
+		{"ignore paths all ignored", nil, models.PathPatterns{"docs/**", "**/*.md"}, []string{"docs/index.md", "README.md"}, false},
+		{"ignore paths some not ignored", nil, models.PathPatterns{"docs/**"}, []string{"docs/index.md", "backend/main.go"}, true},
+		{"ignore paths with no changes", nil, models.PathPatterns{"docs/**"}, nil, true},
+		{"only and ignore paths", models.PathPatterns{"backend/**"}, models.PathPatterns{"**/*.md"}, []string{"backend/README.md"}, false},
+	}
+	for _, test := range tests {
+		matched, err := parser.PathFiltersMatch(test.onlyPaths, test.ignorePaths, test.changedFiles)
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.expected, matched, test.name)
+	}
+}
+
 func testPipelineAgainstReference(build *models.BuildDefinition) func(t *testing.T) {
 	return func(t *testing.T) {
 		if len(build.Jobs) != len(referencedata.ReferenceBuild.Jobs) {
@@ -172,3 +1075,26 @@ func testPipelineAgainstReference(build *models.BuildDefinition) func(t *testing
 		}
 	}
 }
+
+func TestFindBuildConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	_, _, err := parser.FindBuildConfigFile(dir)
+	require.Error(t, err)
+	require.True(t, gerror.IsNotFound(err))
+
+	// Directories and other files with config file extensions are ignored
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".buildbeaver.yml"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yml"), []byte("jobs: []"), 0644))
+	_, _, err = parser.FindBuildConfigFile(dir)
+	require.True(t, gerror.IsNotFound(err))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "buildbeaver.jsonnet"), []byte("{}"), 0644))
+	name, configType, err := parser.FindBuildConfigFile(dir)
+	require.NoError(t, err)
+	require.Equal(t, "buildbeaver.jsonnet", name)
+	require.Equal(t, models.ConfigTypeJSONNET, configType)
+
+	require.Equal(t, models.ConfigTypeYAML, parser.ConfigTypeForFileName("ci/go.YML"))
+	require.Equal(t, models.ConfigTypeJSON, parser.ConfigTypeForFileName("jobs.json"))
+	require.Equal(t, models.ConfigTypeInvalid, parser.ConfigTypeForFileName("Makefile"))
+}
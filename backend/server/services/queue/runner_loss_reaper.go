@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/util"
+	"github.com/buildbeaver/buildbeaver/server/services"
+)
+
+const defaultRunnerLossPollInterval = 1 * time.Minute
+
+// RunnerLossReaper implements a Service to periodically recover jobs that were handed to runners which
+// have since stopped sending heartbeats, so the jobs don't stay submitted or running until they time out.
+type RunnerLossReaper struct {
+	*util.StatefulService
+	queueService          services.QueueService
+	leaderElectionService services.LeaderElectionService
+	pollInterval          time.Duration
+	logger.Log
+}
+
+func NewRunnerLossReaper(
+	queueService services.QueueService,
+	leaderElectionService services.LeaderElectionService,
+	logFactory logger.LogFactory,
+) *RunnerLossReaper {
+	s := &RunnerLossReaper{
+		queueService:          queueService,
+		leaderElectionService: leaderElectionService,
+		pollInterval:          defaultRunnerLossPollInterval,
+		Log:                   logFactory("RunnerLossReaper"),
+	}
+	s.StatefulService = util.NewStatefulService(context.Background(), s.Log, s.loop)
+	return s
+}
+
+func (s *RunnerLossReaper) loop() {
+	s.Tracef("Starting runner loss polling loop...")
+	for {
+		select {
+		case <-s.StatefulService.Ctx().Done():
+			s.Tracef("Runner loss reaper closed; exiting...")
+			return
+
+		case <-time.After(s.pollInterval):
+			if !s.leaderElectionService.IsLeader() {
+				s.Tracef("Skipping recovery of jobs from lost runners; this server is not the leader")
+				continue
+			}
+			nrRecovered, err := s.queueService.RecoverOrphanedJobs(s.Ctx())
+			if err != nil {
+				s.Errorf("Error recovering jobs from lost runners: %s", err.Error())
+			}
+			if nrRecovered > 0 {
+				s.Infof("Recovered %d jobs from lost runners", nrRecovered)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// LoadHistory reads the jobs that were active at or after the specified time and have since finished, for
+// replaying through the simulated queue. Jobs that never ran on a runner (e.g. because they were indirected
+// to a job from a previous build) are skipped. A job is treated as ready to run once it was queued and each
+// job it depends on in the same build had finished, since the queue can't hand it to a runner before then.
+func LoadHistory(ctx context.Context, jobStore store.JobStore, since time.Time) ([]Job, error) {
+	after := models.NewTime(since)
+	search := models.NewJobSearch()
+	search.After = &after
+	search.Statuses = []models.WorkflowStatus{models.WorkflowStatusSucceeded, models.WorkflowStatusFailed}
+
+	var ran []*models.Job
+	finishedAt := make(map[models.BuildID]map[string]time.Time)
+	for moreResults := true; moreResults; {
+		jobs, cursor, err := jobStore.Search(ctx, nil, *search)
+		if err != nil {
+			return nil, fmt.Errorf("error searching jobs: %w", err)
+		}
+		for _, job := range jobs {
+			if job.Timings.FinishedAt == nil {
+				continue
+			}
+			finishedInBuild, ok := finishedAt[job.BuildID]
+			if !ok {
+				finishedInBuild = make(map[string]time.Time)
+				finishedAt[job.BuildID] = finishedInBuild
+			}
+			fqn := job.GetFQN()
+			finishedInBuild[fqn.String()] = job.Timings.FinishedAt.Time
+			if job.IndirectToJobID.Valid() || job.Timings.RunningAt == nil {
+				continue
+			}
+			ran = append(ran, job)
+		}
+		if cursor != nil && cursor.Next != nil {
+			search.Cursor = cursor.Next // move on to next page of results
+		} else {
+			moreResults = false
+		}
+	}
+
+	history := make([]Job, 0, len(ran))
+	for _, job := range ran {
+		readyAt := job.CreatedAt.Time
+		if job.Timings.QueuedAt != nil {
+			readyAt = job.Timings.QueuedAt.Time
+		}
+		for _, dependency := range job.Depends {
+			fqn := dependency.GetFQN()
+			dependencyFinishedAt, ok := finishedAt[job.BuildID][fqn.String()]
+			if ok && dependencyFinishedAt.After(readyAt) {
+				readyAt = dependencyFinishedAt
+			}
+		}
+		duration := job.Timings.FinishedAt.Sub(job.Timings.RunningAt.Time)
+		if duration < 0 {
+			duration = 0
+		}
+		history = append(history, Job{
+			ReadyAt:  readyAt,
+			Duration: duration,
+			RunsOn:   job.RunsOn,
+		})
+	}
+	return history, nil
+}
@@ -0,0 +1,268 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// Job is a job from the build history to replay through the simulated queue.
+type Job struct {
+	// ReadyAt is the time the job was ready to run: queued, and with all the jobs it depends on finished.
+	ReadyAt time.Time
+	// Duration is how long the job ran for once a runner picked it up.
+	Duration time.Duration
+	// RunsOn is the set of labels a runner must have to run the job.
+	RunsOn models.Labels
+}
+
+// RunnerGroup is a number of identical runners in a simulated scenario.
+type RunnerGroup struct {
+	// Labels are the labels each runner in the group has.
+	Labels models.Labels
+	// Count is the number of runners in the group.
+	Count int
+	// ParallelJobs is the number of jobs each runner runs at once. Defaults to 1 if zero.
+	ParallelJobs int
+}
+
+func (m RunnerGroup) String() string {
+	labels := make([]string, 0, len(m.Labels))
+	for _, label := range m.Labels {
+		labels = append(labels, label.String())
+	}
+	return fmt.Sprintf("%d x [%s]", m.Count, strings.Join(labels, ","))
+}
+
+// Stats summarizes the time jobs spent waiting in the simulated queue for a runner.
+type Stats struct {
+	waits []time.Duration // sorted
+}
+
+// Jobs returns the number of jobs the stats cover.
+func (s *Stats) Jobs() int {
+	return len(s.waits)
+}
+
+// Percentile returns the wait time that the specified percentage (0-100) of jobs waited no longer than,
+// using the nearest-rank method.
+func (s *Stats) Percentile(percent float64) time.Duration {
+	if len(s.waits) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(percent / 100 * float64(len(s.waits))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(s.waits) {
+		rank = len(s.waits)
+	}
+	return s.waits[rank-1]
+}
+
+// Max returns the longest time any job waited.
+func (s *Stats) Max() time.Duration {
+	return s.Percentile(100)
+}
+
+func (s *Stats) add(wait time.Duration) {
+	s.waits = append(s.waits, wait)
+}
+
+func (s *Stats) sort() {
+	sort.Slice(s.waits, func(i, j int) bool { return s.waits[i] < s.waits[j] })
+}
+
+// Result is the outcome of simulating the queue.
+type Result struct {
+	// Overall covers all jobs that a runner in the scenario could run.
+	Overall *Stats
+	// ByRunsOn breaks the wait times down by the labels jobs require, keyed by the comma separated,
+	// sorted list of labels (empty for jobs that don't require any labels).
+	ByRunsOn map[string]*Stats
+	// Unschedulable is the number of jobs that no runner in the scenario could run.
+	Unschedulable int
+}
+
+// LabelsKey returns the key used in Result.ByRunsOn for jobs requiring the specified labels.
+func LabelsKey(labels models.Labels) string {
+	strs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		strs = append(strs, label.String())
+	}
+	sort.Strings(strs)
+	return strings.Join(strs, ",")
+}
+
+// slot is one job's worth of a simulated runner's capacity.
+type slot struct {
+	labels    map[models.Label]bool
+	busyUntil time.Time
+}
+
+func (s *slot) canRun(job *Job) bool {
+	for _, label := range job.RunsOn {
+		if !s.labels[label] {
+			return false
+		}
+	}
+	return true
+}
+
+// Simulate replays the jobs through a queue served by the specified runners, and returns how long jobs
+// would have waited for a runner. Jobs are handed out oldest first, each to the first free runner with
+// all the labels the job requires, in the same way the server hands out jobs to runners polling the queue.
+func Simulate(jobs []Job, runners []RunnerGroup) *Result {
+	var slots []*slot
+	for _, group := range runners {
+		labels := make(map[models.Label]bool, len(group.Labels))
+		for _, label := range group.Labels {
+			labels[label] = true
+		}
+		parallel := group.ParallelJobs
+		if parallel <= 0 {
+			parallel = 1
+		}
+		for i := 0; i < group.Count*parallel; i++ {
+			slots = append(slots, &slot{labels: labels})
+		}
+	}
+
+	sorted := make([]*Job, 0, len(jobs))
+	for i := range jobs {
+		sorted = append(sorted, &jobs[i])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ReadyAt.Before(sorted[j].ReadyAt) })
+
+	result := &Result{Overall: &Stats{}, ByRunsOn: make(map[string]*Stats)}
+	var (
+		queue []*Job
+		now   time.Time
+		next  int
+	)
+	for {
+		for next < len(sorted) && !sorted[next].ReadyAt.After(now) {
+			job := sorted[next]
+			next++
+			if !anyCanRun(slots, job) {
+				result.Unschedulable++
+				continue
+			}
+			queue = append(queue, job)
+		}
+
+		var waiting []*Job
+		for _, job := range queue {
+			s := findFreeSlot(slots, job, now)
+			if s == nil {
+				waiting = append(waiting, job)
+				continue
+			}
+			s.busyUntil = now.Add(job.Duration)
+			wait := now.Sub(job.ReadyAt)
+			result.Overall.add(wait)
+			key := LabelsKey(job.RunsOn)
+			stats, ok := result.ByRunsOn[key]
+			if !ok {
+				stats = &Stats{}
+				result.ByRunsOn[key] = stats
+			}
+			stats.add(wait)
+		}
+		queue = waiting
+
+		// Move time on to the next job arriving or, if jobs are waiting, the next runner becoming free
+		var nextEvent time.Time
+		if next < len(sorted) {
+			nextEvent = sorted[next].ReadyAt
+		}
+		if len(queue) > 0 {
+			for _, s := range slots {
+				if s.busyUntil.After(now) && (nextEvent.IsZero() || s.busyUntil.Before(nextEvent)) {
+					nextEvent = s.busyUntil
+				}
+			}
+		}
+		if nextEvent.IsZero() {
+			break
+		}
+		now = nextEvent
+	}
+
+	result.Overall.sort()
+	for _, stats := range result.ByRunsOn {
+		stats.sort()
+	}
+	return result
+}
+
+// RunnersNeeded finds the smallest number of runners in the specified group (an index into runners) that
+// keeps the given percentile of wait times for the jobs the group can run within target, trying counts from
+// the group's current count up to maxCount. Returns the count and the result of simulating with that count,
+// or an error if even maxCount runners aren't enough.
+func RunnersNeeded(
+	jobs []Job,
+	runners []RunnerGroup,
+	group int,
+	percentile float64,
+	target time.Duration,
+	maxCount int,
+) (int, *Result, error) {
+	if group < 0 || group >= len(runners) {
+		return 0, nil, fmt.Errorf("error runner group %d does not exist", group)
+	}
+	scenario := append([]RunnerGroup(nil), runners...)
+	var result *Result
+	for count := scenario[group].Count; count <= maxCount; count++ {
+		scenario[group].Count = count
+		result = Simulate(jobs, scenario)
+		if meetsTarget(result, scenario[group], percentile, target) {
+			return count, result, nil
+		}
+	}
+	return 0, result, fmt.Errorf("error %d runners in group %s are not enough to keep p%v wait times within %s",
+		maxCount, scenario[group], percentile, target)
+}
+
+// meetsTarget returns true if every kind of job the group can run was scheduled, and waited no longer
+// than target at the specified percentile.
+func meetsTarget(result *Result, group RunnerGroup, percentile float64, target time.Duration) bool {
+	groupSlot := &slot{labels: make(map[models.Label]bool, len(group.Labels))}
+	for _, label := range group.Labels {
+		groupSlot.labels[label] = true
+	}
+	for key, stats := range result.ByRunsOn {
+		var runsOn models.Labels
+		if key != "" {
+			for _, label := range strings.Split(key, ",") {
+				runsOn = append(runsOn, models.Label(label))
+			}
+		}
+		if groupSlot.canRun(&Job{RunsOn: runsOn}) && stats.Percentile(percentile) > target {
+			return false
+		}
+	}
+	return true
+}
+
+func anyCanRun(slots []*slot, job *Job) bool {
+	for _, s := range slots {
+		if s.canRun(job) {
+			return true
+		}
+	}
+	return false
+}
+
+func findFreeSlot(slots []*slot, job *Job, now time.Time) *slot {
+	for _, s := range slots {
+		if !s.busyUntil.After(now) && s.canRun(job) {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+var (
+	start = time.Date(2023, 1, 1, 9, 0, 0, 0, time.UTC)
+	linux = models.Labels{"linux", "amd64"}
+	mac   = models.Labels{"macos", "arm64"}
+)
+
+func job(readyAfter time.Duration, duration time.Duration, runsOn models.Labels) Job {
+	return Job{ReadyAt: start.Add(readyAfter), Duration: duration, RunsOn: runsOn}
+}
+
+func TestSimulateWaitTimes(t *testing.T) {
+	// Three 10 minute linux jobs arrive together; with one runner they run one after the other
+	jobs := []Job{
+		job(0, 10*time.Minute, linux),
+		job(0, 10*time.Minute, linux),
+		job(0, 10*time.Minute, linux),
+		job(5*time.Minute, time.Minute, mac),
+	}
+	result := Simulate(jobs, []RunnerGroup{
+		{Labels: linux, Count: 1},
+		{Labels: mac, Count: 1},
+	})
+	require.Equal(t, 0, result.Unschedulable)
+	require.Equal(t, 4, result.Overall.Jobs())
+	require.Equal(t, 20*time.Minute, result.Overall.Max())
+
+	linuxStats := result.ByRunsOn[LabelsKey(linux)]
+	require.NotNil(t, linuxStats)
+	require.Equal(t, 3, linuxStats.Jobs())
+	require.Equal(t, time.Duration(0), linuxStats.Percentile(33))
+	require.Equal(t, 10*time.Minute, linuxStats.Percentile(50))
+	require.Equal(t, 20*time.Minute, linuxStats.Percentile(95))
+
+	// The mac job has its own runner so never waits behind the linux jobs
+	require.Equal(t, time.Duration(0), result.ByRunsOn[LabelsKey(mac)].Max())
+
+	// Running two jobs at once on the linux runner halves the worst wait
+	result = Simulate(jobs, []RunnerGroup{
+		{Labels: linux, Count: 1, ParallelJobs: 2},
+		{Labels: mac, Count: 1},
+	})
+	require.Equal(t, 10*time.Minute, result.ByRunsOn[LabelsKey(linux)].Max())
+}
+
+func TestSimulateUnschedulable(t *testing.T) {
+	jobs := []Job{
+		job(0, time.Minute, linux),
+		job(0, time.Minute, mac),
+		job(0, time.Minute, nil),
+	}
+	result := Simulate(jobs, []RunnerGroup{{Labels: linux, Count: 1}})
+	require.Equal(t, 1, result.Unschedulable, "no runner can run the mac job")
+	require.Equal(t, 2, result.Overall.Jobs())
+	require.Nil(t, result.ByRunsOn[LabelsKey(mac)])
+	require.Equal(t, time.Minute, result.ByRunsOn[""].Max(), "a job without labels waits for the linux runner")
+}
+
+func TestRunnersNeeded(t *testing.T) {
+	// Ten 5 minute linux jobs arrive at once, every hour for a day
+	var jobs []Job
+	for hour := 0; hour < 24; hour++ {
+		for i := 0; i < 10; i++ {
+			jobs = append(jobs, job(time.Duration(hour)*time.Hour, 5*time.Minute, linux))
+		}
+	}
+	runners := []RunnerGroup{{Labels: linux, Count: 2}}
+
+	// A job is only delayed by a runner being busy with earlier jobs, so a p95 wait of under 10 minutes
+	// needs enough runners to get through the burst in three rounds
+	count, result, err := RunnersNeeded(jobs, runners, 0, 95, 10*time.Minute, 100)
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+	require.LessOrEqual(t, result.Overall.Percentile(95), 10*time.Minute)
+	require.Equal(t, 2, runners[0].Count, "the scenario passed in is not modified")
+
+	// Nothing waits once every job in a burst gets its own runner
+	count, _, err = RunnersNeeded(jobs, runners, 0, 100, 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+
+	_, _, err = RunnersNeeded(jobs, runners, 0, 100, 0, 5)
+	require.Error(t, err)
+	_, _, err = RunnersNeeded(jobs, runners, 1, 95, time.Minute, 5)
+	require.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// scmTemplateLoader loads the templates referenced from a repo's build definition from the SCM hosting each
+// template's repo. Templates can be loaded from the build definition's own repo, or from another repo owned by
+// the same legal entity.
+type scmTemplateLoader struct {
+	ctx     context.Context
+	txOrNil *store.Tx
+	queue   *QueueService
+	repoID  models.RepoID
+	// gitRef is the git ref (e.g. commit SHA) to load templates from when they are in the build definition's own repo.
+	gitRef string
+	// transientErr is the first error encountered while loading templates that is not caused by a problem
+	// with the build definition, and so could succeed if retried.
+	transientErr error
+}
+
+func (s *QueueService) newSCMTemplateLoader(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, gitRef string) *scmTemplateLoader {
+	return &scmTemplateLoader{
+		ctx:     ctx,
+		txOrNil: txOrNil,
+		queue:   s,
+		repoID:  repoID,
+		gitRef:  gitRef,
+	}
+}
+
+// LoadTemplate returns the contents of the template file identified by ref. Returns a not found or validation
+// failed error if the template can't be loaded due to a problem with the reference.
+func (l *scmTemplateLoader) LoadTemplate(ref parser.TemplateReference) ([]byte, error) {
+	contents, err := l.loadTemplate(ref)
+	if err != nil && !gerror.IsNotFound(err) && !gerror.IsValidationFailed(err) && l.transientErr == nil {
+		l.transientErr = err
+	}
+	return contents, err
+}
+
+func (l *scmTemplateLoader) loadTemplate(ref parser.TemplateReference) ([]byte, error) {
+	repo, err := l.queue.repoService.Read(l.ctx, l.txOrNil, l.repoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	gitRef := ref.Ref
+	if ref.Repo != "" && ref.Repo != repo.Name {
+		repo, err = l.findRepo(repo.LegalEntityID, ref.Repo)
+		if err != nil {
+			return nil, err
+		}
+		if gitRef == "" {
+			gitRef = repo.DefaultBranch
+		}
+	} else if gitRef == "" {
+		gitRef = l.gitRef
+		if gitRef == "" {
+			gitRef = repo.DefaultBranch
+		}
+	}
+	if repo.ExternalID == nil {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Repo %q is not hosted by an SCM so templates can't be loaded from it", repo.Name))
+	}
+	scmName := repo.ExternalID.ExternalSystem
+	externalSCM, err := l.queue.scmRegistry.Get(scmName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SCM from registry for %q: %w", scmName, err)
+	}
+	contents, err := externalSCM.GetFileContents(l.ctx, repo, gitRef, ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	err = l.queue.CheckBuildConfigLength(len(contents))
+	if err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// findRepo finds the repo with the specified name owned by the specified legal entity.
+func (l *scmTemplateLoader) findRepo(legalEntityID models.LegalEntityID, repoName models.ResourceName) (*models.Repo, error) {
+	legalEntity, err := l.queue.legalEntityService.Read(l.ctx, l.txOrNil, legalEntityID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading legal entity: %w", err)
+	}
+	leaf, err := l.queue.resourceLinkStore.Resolve(l.ctx, l.txOrNil, models.ResourceLink{
+		{Kind: models.LegalEntityResourceKind, Name: legalEntity.Name},
+		{Kind: models.RepoResourceKind, Name: repoName},
+	})
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return nil, gerror.NewErrNotFound(fmt.Sprintf("Repo %q not found", repoName))
+		}
+		return nil, fmt.Errorf("error resolving repo %q: %w", repoName, err)
+	}
+	return l.queue.repoService.Read(l.ctx, l.txOrNil, models.RepoIDFromResourceID(leaf.ID))
+}
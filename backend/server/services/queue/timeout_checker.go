@@ -37,12 +37,13 @@ func newTimeoutCheck(timeout time.Duration) *timeoutCheck {
 // for a timeout period.
 type TimeoutChecker struct {
 	*util.StatefulService
-	db                  *store.DB
-	queueService        services.QueueService
-	jobService          services.JobService
-	stepService         services.StepService
-	timeoutPollInterval time.Duration
-	timeoutCheckChan    chan *timeoutCheck
+	db                    *store.DB
+	queueService          services.QueueService
+	jobService            services.JobService
+	stepService           services.StepService
+	leaderElectionService services.LeaderElectionService
+	timeoutPollInterval   time.Duration
+	timeoutCheckChan      chan *timeoutCheck
 	logger.Log
 }
 
@@ -51,16 +52,18 @@ func NewTimeoutChecker(
 	queueService services.QueueService,
 	jobService services.JobService,
 	stepService services.StepService,
+	leaderElectionService services.LeaderElectionService,
 	logFactory logger.LogFactory,
 ) *TimeoutChecker {
 	s := &TimeoutChecker{
-		db:                  db,
-		queueService:        queueService,
-		jobService:          jobService,
-		stepService:         stepService,
-		timeoutPollInterval: defaultTimeoutPollInterval,
-		timeoutCheckChan:    make(chan *timeoutCheck),
-		Log:                 logFactory("TimeoutChecker"),
+		db:                    db,
+		queueService:          queueService,
+		jobService:            jobService,
+		stepService:           stepService,
+		leaderElectionService: leaderElectionService,
+		timeoutPollInterval:   defaultTimeoutPollInterval,
+		timeoutCheckChan:      make(chan *timeoutCheck),
+		Log:                   logFactory("TimeoutChecker"),
 	}
 	s.StatefulService = util.NewStatefulService(context.Background(), s.Log, s.loop)
 	return s
@@ -83,6 +86,10 @@ func (s *TimeoutChecker) loop() {
 			timeoutReq.completedChan <- nrTimedOutJobs
 
 		case <-time.After(s.timeoutPollInterval):
+			if !s.leaderElectionService.IsLeader() {
+				s.Tracef("Skipping job timeout check; this server is not the leader")
+				continue
+			}
 			nrTimedOutJobs, err := s.checkForTimeouts(defaultJobTimeout)
 			if err != nil {
 				s.Errorf("Error checking jobs for timeouts: %s", err.Error())
@@ -94,7 +101,8 @@ func (s *TimeoutChecker) loop() {
 	}
 }
 
-// Checks all currently running jobs to see if they have timed out, using the specified default timeout duration.
+// Checks all currently running jobs to see if they have timed out, using the job's own timeout if it has one
+// and otherwise the specified default timeout duration.
 // Returns the number of jobs that timed out.
 func (s *TimeoutChecker) checkForTimeouts(defaultTimeout time.Duration) (nrTimedOutJobs int, err error) {
 	var (
@@ -119,8 +127,10 @@ func (s *TimeoutChecker) checkForTimeouts(defaultTimeout time.Duration) (nrTimed
 				}
 				s.Tracef("checkForTimeouts: Got a page of %d jobs in search", len(runningJobs))
 				for _, job := range runningJobs {
-					// TODO: Support non-default timeouts on a per-job basis
 					timeout := defaultTimeout
+					if job.TimeoutSeconds > 0 {
+						timeout = time.Duration(job.TimeoutSeconds) * time.Second
+					}
 					if s.hasJobTimedOut(job, timeout) {
 						results = append(results, job)
 					}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/common/models/search"
@@ -24,6 +25,8 @@ type RepoService struct {
 	scmRegistry       *scm.SCMRegistry
 	keyPairService    services.KeyPairService
 	secretService     services.SecretService
+	// authorizationService is used to grant public access to repos with public builds
+	authorizationService services.AuthorizationService
 	logger.Log
 }
 
@@ -35,17 +38,19 @@ func NewRepoService(
 	scmRegistry *scm.SCMRegistry,
 	keyPairService services.KeyPairService,
 	secretService services.SecretService,
+	authorizationService services.AuthorizationService,
 	logFactory logger.LogFactory) *RepoService {
 
 	return &RepoService{
-		db:                db,
-		ownershipStore:    ownershipStore,
-		repoStore:         repoStore,
-		resourceLinkStore: resourceLinkStore,
-		scmRegistry:       scmRegistry,
-		keyPairService:    keyPairService,
-		secretService:     secretService,
-		Log:               logFactory("RepoService"),
+		db:                   db,
+		ownershipStore:       ownershipStore,
+		repoStore:            repoStore,
+		resourceLinkStore:    resourceLinkStore,
+		scmRegistry:          scmRegistry,
+		keyPairService:       keyPairService,
+		secretService:        secretService,
+		authorizationService: authorizationService,
+		Log:                  logFactory("RepoService"),
 	}
 }
 
@@ -55,6 +60,12 @@ func (s *RepoService) Read(ctx context.Context, txOrNil *store.Tx, id models.Rep
 	return s.repoStore.Read(ctx, txOrNil, id)
 }
 
+// ListByIDs reads the repos with the specified IDs, in no particular order. IDs of repos that do not
+// exist are ignored.
+func (s *RepoService) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.RepoID) ([]*models.Repo, error) {
+	return s.repoStore.ListByIDs(ctx, txOrNil, ids)
+}
+
 // ReadByExternalID reads an existing repo, looking it up by its external id.
 // Returns models.ErrNotFound if the repo does not exist.
 func (s *RepoService) ReadByExternalID(ctx context.Context, txOrNil *store.Tx, externalID models.ExternalResourceID) (*models.Repo, error) {
@@ -64,7 +75,7 @@ func (s *RepoService) ReadByExternalID(ctx context.Context, txOrNil *store.Tx, e
 // Upsert creates a repo if it does not exist, otherwise it updates its mutable properties
 // if they differ from the in-memory instance. Returns true,false if the resource was created
 // and false,true if the resource was updated. false,false if neither a create or update was necessary.
-// Repo Metadata and selected fields will not be updated (including Enabled and SSHKeySecretID fields).
+// Repo Metadata and selected fields will not be updated (including Enabled, SSHKeySecretID, DynamicJobRestrictions, CoverageSettings, PublicBuilds, BuildPrioritySettings and DeduplicateJobs fields).
 func (s *RepoService) Upsert(ctx context.Context, txOrNil *store.Tx, repo *models.Repo) (created bool, updated bool, err error) {
 	err = repo.Validate()
 	if err != nil {
@@ -112,6 +123,153 @@ func (s *RepoService) UpdateRepoEnabled(ctx context.Context, repoID models.RepoI
 	}
 }
 
+// UpdateDynamicJobRestrictions sets or clears the restrictions on jobs that can be added to builds for a repo
+// via the Dynamic API.
+func (s *RepoService) UpdateDynamicJobRestrictions(ctx context.Context, repoID models.RepoID, update dto.UpdateDynamicJobRestrictions) (*models.Repo, error) {
+	if update.Restrictions != nil {
+		err := update.Restrictions.Validate()
+		if err != nil {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid dynamic job restrictions: %s", err))
+		}
+	}
+	var repo *models.Repo
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		var err error
+		repo, err = s.repoStore.Read(ctx, tx, repoID)
+		if err != nil {
+			return fmt.Errorf("error reading repo: %w", err)
+		}
+		repo.ETag = models.GetETag(repo, update.ETag)
+		repo.DynamicJobRestrictions = update.Restrictions
+		repo.UpdatedAt = models.NewTime(time.Now())
+		err = s.repoStore.Update(ctx, tx, repo)
+		if err != nil {
+			return fmt.Errorf("error updating repo: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// UpdateCoverageSettings sets or clears the code coverage threshold that is checked when each build for
+// a repo finishes.
+func (s *RepoService) UpdateCoverageSettings(ctx context.Context, repoID models.RepoID, update dto.UpdateCoverageSettings) (*models.Repo, error) {
+	if update.Settings != nil {
+		err := update.Settings.Validate()
+		if err != nil {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid coverage settings: %s", err))
+		}
+	}
+	var repo *models.Repo
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		var err error
+		repo, err = s.repoStore.Read(ctx, tx, repoID)
+		if err != nil {
+			return fmt.Errorf("error reading repo: %w", err)
+		}
+		repo.ETag = models.GetETag(repo, update.ETag)
+		repo.CoverageSettings = update.Settings
+		repo.UpdatedAt = models.NewTime(time.Now())
+		err = s.repoStore.Update(ctx, tx, repo)
+		if err != nil {
+			return fmt.Errorf("error updating repo: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// UpdateBuildPrioritySettings sets or clears the settings that determine the priority given to new builds
+// for a repo. Builds that have already been queued keep their existing priority.
+func (s *RepoService) UpdateBuildPrioritySettings(ctx context.Context, repoID models.RepoID, update dto.UpdateBuildPrioritySettings) (*models.Repo, error) {
+	if update.Settings != nil {
+		err := update.Settings.Validate()
+		if err != nil {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid build priority settings: %s", err))
+		}
+	}
+	var repo *models.Repo
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		var err error
+		repo, err = s.repoStore.Read(ctx, tx, repoID)
+		if err != nil {
+			return fmt.Errorf("error reading repo: %w", err)
+		}
+		repo.ETag = models.GetETag(repo, update.ETag)
+		repo.BuildPrioritySettings = update.Settings
+		repo.UpdatedAt = models.NewTime(time.Now())
+		err = s.repoStore.Update(ctx, tx, repo)
+		if err != nil {
+			return fmt.Errorf("error updating repo: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// UpdateDeduplicateJobs sets whether queued jobs for a repo that are identical to a job already running in
+// another build finish with the running job's result instead of being run again.
+func (s *RepoService) UpdateDeduplicateJobs(ctx context.Context, repoID models.RepoID, update dto.UpdateRepoDeduplicateJobs) (*models.Repo, error) {
+	var repo *models.Repo
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		var err error
+		repo, err = s.repoStore.Read(ctx, tx, repoID)
+		if err != nil {
+			return fmt.Errorf("error reading repo: %w", err)
+		}
+		repo.ETag = models.GetETag(repo, update.ETag)
+		repo.DeduplicateJobs = update.DeduplicateJobs
+		repo.UpdatedAt = models.NewTime(time.Now())
+		err = s.repoStore.Update(ctx, tx, repo)
+		if err != nil {
+			return fmt.Errorf("error updating repo: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// UpdatePublicBuilds sets whether anyone, including people who have not logged in, can read the builds,
+// logs and artifacts for a repo.
+func (s *RepoService) UpdatePublicBuilds(ctx context.Context, repoID models.RepoID, update dto.UpdateRepoPublicBuilds) (*models.Repo, error) {
+	var repo *models.Repo
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		var err error
+		repo, err = s.repoStore.Read(ctx, tx, repoID)
+		if err != nil {
+			return fmt.Errorf("error reading repo: %w", err)
+		}
+		repo.ETag = models.GetETag(repo, update.ETag)
+		repo.PublicBuilds = update.PublicBuilds
+		repo.UpdatedAt = models.NewTime(time.Now())
+		err = s.repoStore.Update(ctx, tx, repo)
+		if err != nil {
+			return fmt.Errorf("error updating repo: %w", err)
+		}
+		err = s.authorizationService.SetPublicReadAccess(ctx, tx, repo.LegalEntityID, repo.ID.ResourceID, repo.PublicBuilds)
+		if err != nil {
+			return fmt.Errorf("error updating public read access: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
 // enableRepo enables builds for a repo.
 func (s *RepoService) enableRepo(ctx context.Context, repo *models.Repo) (*models.Repo, error) {
 	scm, err := s.scmRegistry.Get(repo.ExternalID.ExternalSystem)
@@ -0,0 +1,471 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+type RoleService struct {
+	db                         *store.DB
+	roleStore                  store.RoleStore
+	roleAssignmentStore        store.RoleAssignmentStore
+	ownershipStore             store.OwnershipStore
+	groupStore                 store.GroupStore
+	grantStore                 store.GrantStore
+	legalEntityStore           store.LegalEntityStore
+	legalEntityMembershipStore store.LegalEntityMembershipStore
+	legalEntityService         services.LegalEntityService
+	authorizationService       services.AuthorizationService
+	logger.Log
+}
+
+func NewRoleService(
+	db *store.DB,
+	roleStore store.RoleStore,
+	roleAssignmentStore store.RoleAssignmentStore,
+	ownershipStore store.OwnershipStore,
+	groupStore store.GroupStore,
+	grantStore store.GrantStore,
+	legalEntityStore store.LegalEntityStore,
+	legalEntityMembershipStore store.LegalEntityMembershipStore,
+	legalEntityService services.LegalEntityService,
+	authorizationService services.AuthorizationService,
+	logFactory logger.LogFactory,
+) *RoleService {
+	return &RoleService{
+		db:                         db,
+		roleStore:                  roleStore,
+		roleAssignmentStore:        roleAssignmentStore,
+		ownershipStore:             ownershipStore,
+		groupStore:                 groupStore,
+		grantStore:                 grantStore,
+		legalEntityStore:           legalEntityStore,
+		legalEntityMembershipStore: legalEntityMembershipStore,
+		legalEntityService:         legalEntityService,
+		authorizationService:       authorizationService,
+		Log:                        logFactory("RoleService"),
+	}
+}
+
+// ListTemplates returns the role templates that roles can be created from.
+func (s *RoleService) ListTemplates() []*models.RoleTemplate {
+	return models.AllRoleTemplates
+}
+
+// Create a new custom role for a company, either from a template or from the supplied operations.
+func (s *RoleService) Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateRole) (*models.Role, error) {
+	now := models.NewTime(time.Now())
+	var role *models.Role
+	if create.TemplateName != "" {
+		template := models.LookupRoleTemplate(create.TemplateName)
+		if template == nil {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Unknown role template: %s", create.TemplateName))
+		}
+		role = models.NewRoleFromTemplate(now, create.LegalEntityID, template)
+		if create.Name != "" {
+			role.Name = create.Name
+		}
+		if create.Description != "" {
+			role.Description = create.Description
+		}
+	} else {
+		role = models.NewRole(now, create.LegalEntityID, create.Name, create.Description, create.Operations)
+	}
+	err := role.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		legalEntity, err := s.legalEntityStore.Read(ctx, tx, create.LegalEntityID)
+		if err != nil {
+			return fmt.Errorf("error reading legal entity: %w", err)
+		}
+		if legalEntity.Type != models.LegalEntityTypeCompany {
+			return gerror.NewErrValidationFailed("Roles can only be defined for companies")
+		}
+		err = s.roleStore.Create(ctx, tx, role)
+		if err != nil {
+			return fmt.Errorf("error creating role: %w", err)
+		}
+		ownership := models.NewOwnership(now, role.LegalEntityID.ResourceID, role.GetID())
+		err = s.ownershipStore.Create(ctx, tx, ownership)
+		if err != nil {
+			return fmt.Errorf("error creating ownership: %w", err)
+		}
+		s.Infof("Created role %q (%s) for %q", role.Name, role.ID, role.LegalEntityID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Read an existing role, looking it up by ID.
+// Returns models.ErrNotFound if the role does not exist.
+func (s *RoleService) Read(ctx context.Context, txOrNil *store.Tx, id models.RoleID) (*models.Role, error) {
+	return s.roleStore.Read(ctx, txOrNil, id)
+}
+
+// Update an existing role with optimistic locking, changing only the fields that are set in update.
+// If the role's operations change then the grants held by each of the role's assignees are updated to match.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (s *RoleService) Update(ctx context.Context, txOrNil *store.Tx, id models.RoleID, update dto.UpdateRole) (*models.Role, error) {
+	var role *models.Role
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		var err error
+		role, err = s.roleStore.Read(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error reading role: %w", err)
+		}
+		previousOperations := role.Operations
+		if update.Name != nil {
+			role.Name = *update.Name
+		}
+		if update.Description != nil {
+			role.Description = *update.Description
+		}
+		if update.Operations != nil {
+			role.Operations = *update.Operations
+		}
+		role.UpdatedAt = models.NewTime(time.Now())
+		role.ETag = models.GetETag(role, update.ETag)
+		err = role.Validate()
+		if err != nil {
+			return gerror.NewErrValidationFailed(err.Error())
+		}
+		err = s.roleStore.Update(ctx, tx, role)
+		if err != nil {
+			return fmt.Errorf("error updating role: %w", err)
+		}
+		if update.Operations == nil {
+			return nil
+		}
+		removedOperations := operationsNotIn(previousOperations, role.Operations)
+		return s.forEachAssignment(ctx, tx, role.ID, func(assignment *models.RoleAssignment) error {
+			err := s.grantOperations(ctx, tx, role, assignment)
+			if err != nil {
+				return err
+			}
+			return s.revokeOperations(ctx, tx, role.LegalEntityID, assignment, removedOperations)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Delete permanently and idempotently deletes a role, identifying it by ID. The role is removed from all of
+// its assignees, along with any grants they held only because of the role.
+func (s *RoleService) Delete(ctx context.Context, txOrNil *store.Tx, id models.RoleID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		role, err := s.roleStore.Read(ctx, tx, id)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("error reading role: %w", err)
+		}
+		var assignments []*models.RoleAssignment
+		err = s.forEachAssignment(ctx, tx, role.ID, func(assignment *models.RoleAssignment) error {
+			assignments = append(assignments, assignment)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Deleting the role also deletes its assignments
+		err = s.roleStore.Delete(ctx, tx, role.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting role: %w", err)
+		}
+		err = s.ownershipStore.Delete(ctx, tx, role.ID.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		for _, assignment := range assignments {
+			err = s.revokeOperations(ctx, tx, role.LegalEntityID, assignment, role.Operations)
+			if err != nil {
+				return err
+			}
+		}
+		s.Infof("Deleted role %q (%s) for %q", role.Name, role.ID, role.LegalEntityID)
+		return nil
+	})
+}
+
+// ListByLegalEntityID lists the roles defined by a legal entity. Use cursor to page through results, if any.
+func (s *RoleService) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Role, *models.Cursor, error) {
+	return s.roleStore.ListByLegalEntityID(ctx, txOrNil, legalEntityID, pagination)
+}
+
+// AssignToGroup assigns a role to one of the access control groups of the company that owns the role,
+// granting the group each of the role's operations on the company. This method is idempotent; if the role
+// is already assigned to the group then the existing assignment is returned.
+func (s *RoleService) AssignToGroup(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, groupID models.GroupID) (*models.RoleAssignment, error) {
+	var assignment *models.RoleAssignment
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		role, err := s.roleStore.Read(ctx, tx, roleID)
+		if err != nil {
+			return fmt.Errorf("error reading role: %w", err)
+		}
+		group, err := s.groupStore.Read(ctx, tx, groupID)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return gerror.NewErrValidationFailed("Group not found")
+			}
+			return fmt.Errorf("error reading group: %w", err)
+		}
+		if group.LegalEntityID != role.LegalEntityID {
+			return gerror.NewErrValidationFailed("Roles can only be assigned to groups owned by the same company")
+		}
+		if group.ExternalID != nil {
+			// Grants for groups synced from an SCM are replaced with the SCM's permissions on every sync
+			return gerror.NewErrValidationFailed("Roles can not be assigned to groups whose permissions are managed by an SCM")
+		}
+		assignment, err = s.assign(ctx, tx, role, models.NewGroupRoleAssignment(models.NewTime(time.Now()), role.ID, group.ID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// AssignToIdentity assigns a role directly to a member of the company that owns the role, granting the
+// member's identity each of the role's operations on the company. This method is idempotent; if the role
+// is already assigned to the identity then the existing assignment is returned.
+func (s *RoleService) AssignToIdentity(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, identityID models.IdentityID) (*models.RoleAssignment, error) {
+	var assignment *models.RoleAssignment
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		role, err := s.roleStore.Read(ctx, tx, roleID)
+		if err != nil {
+			return fmt.Errorf("error reading role: %w", err)
+		}
+		member, err := s.legalEntityService.ReadByIdentityID(ctx, tx, identityID)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return gerror.NewErrValidationFailed("Identity not found")
+			}
+			return fmt.Errorf("error reading legal entity for identity: %w", err)
+		}
+		if member.Type != models.LegalEntityTypePerson {
+			return gerror.NewErrValidationFailed("Roles can only be assigned to the identities of people")
+		}
+		_, err = s.legalEntityMembershipStore.ReadByMember(ctx, tx, role.LegalEntityID, member.ID)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return gerror.NewErrValidationFailed("Roles can only be assigned to members of the company that owns the role")
+			}
+			return fmt.Errorf("error reading company membership: %w", err)
+		}
+		assignment, err = s.assign(ctx, tx, role, models.NewIdentityRoleAssignment(models.NewTime(time.Now()), role.ID, identityID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// ReadAssignment reads an existing role assignment, looking it up by ID.
+// Returns models.ErrNotFound if the role assignment does not exist.
+func (s *RoleService) ReadAssignment(ctx context.Context, txOrNil *store.Tx, id models.RoleAssignmentID) (*models.RoleAssignment, error) {
+	return s.roleAssignmentStore.Read(ctx, txOrNil, id)
+}
+
+// Unassign permanently and idempotently removes a role assignment, along with any grants the assignee held
+// only because of the role. Grants the assignee also holds via another role or because it is a standard
+// group are left in place.
+func (s *RoleService) Unassign(ctx context.Context, txOrNil *store.Tx, id models.RoleAssignmentID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		assignment, err := s.roleAssignmentStore.Read(ctx, tx, id)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("error reading role assignment: %w", err)
+		}
+		role, err := s.roleStore.Read(ctx, tx, assignment.RoleID)
+		if err != nil {
+			return fmt.Errorf("error reading role: %w", err)
+		}
+		err = s.roleAssignmentStore.Delete(ctx, tx, assignment.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting role assignment: %w", err)
+		}
+		return s.revokeOperations(ctx, tx, role.LegalEntityID, assignment, role.Operations)
+	})
+}
+
+// ListAssignments lists the assignments of a role. Use cursor to page through results, if any.
+func (s *RoleService) ListAssignments(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, pagination models.Pagination) ([]*models.RoleAssignment, *models.Cursor, error) {
+	return s.roleAssignmentStore.ListByRoleID(ctx, txOrNil, roleID, pagination)
+}
+
+// assign creates the supplied role assignment and grants the assignee the role's operations, or returns
+// the existing assignment if the role is already assigned to the same group or identity.
+func (s *RoleService) assign(ctx context.Context, tx *store.Tx, role *models.Role, assignmentData *models.RoleAssignment) (*models.RoleAssignment, error) {
+	existing, err := s.roleAssignmentStore.ReadByAssignee(ctx, tx, role.ID, assignmentData.GetAuthorizedResourceID())
+	if err == nil {
+		return existing, nil
+	}
+	if !gerror.IsNotFound(err) {
+		return nil, fmt.Errorf("error reading role assignment: %w", err)
+	}
+	err = assignmentData.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	err = s.roleAssignmentStore.Create(ctx, tx, assignmentData)
+	if err != nil {
+		return nil, fmt.Errorf("error creating role assignment: %w", err)
+	}
+	err = s.grantOperations(ctx, tx, role, assignmentData)
+	if err != nil {
+		return nil, err
+	}
+	return assignmentData, nil
+}
+
+// grantOperations grants the assignee of a role assignment each of the role's operations on the company
+// that owns the role. Grants the assignee already holds are left in place.
+func (s *RoleService) grantOperations(ctx context.Context, tx *store.Tx, role *models.Role, assignment *models.RoleAssignment) error {
+	var err error
+	if assignment.AuthorizedIdentityID.Valid() {
+		err = s.authorizationService.CreateGrantsForIdentity(ctx, tx, role.LegalEntityID, assignment.AuthorizedIdentityID,
+			role.Operations.Pointers(), role.LegalEntityID.ResourceID)
+	} else {
+		err = s.authorizationService.CreateGrantsForGroup(ctx, tx, role.LegalEntityID, assignment.AuthorizedGroupID,
+			role.Operations.Pointers(), role.LegalEntityID.ResourceID)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating grants for role assignment %q: %w", assignment.ID, err)
+	}
+	return nil
+}
+
+// revokeOperations deletes the assignee's grants on a company for the specified operations, except for
+// operations the assignee still needs because of another role assigned to it or because it is one of the
+// company's standard groups. The role assignment must already have been updated or deleted.
+func (s *RoleService) revokeOperations(
+	ctx context.Context,
+	tx *store.Tx,
+	legalEntityID models.LegalEntityID,
+	assignment *models.RoleAssignment,
+	operations models.RoleOperations,
+) error {
+	retained, err := s.retainedOperations(ctx, tx, legalEntityID, assignment)
+	if err != nil {
+		return err
+	}
+	now := models.NewTime(time.Now())
+	for _, operation := range operations {
+		if retained[operation] {
+			continue
+		}
+		var grantData *models.Grant
+		if assignment.AuthorizedIdentityID.Valid() {
+			grantData = models.NewIdentityGrant(now, legalEntityID, assignment.AuthorizedIdentityID, operation, legalEntityID.ResourceID)
+		} else {
+			grantData = models.NewGroupGrant(now, legalEntityID, assignment.AuthorizedGroupID, operation, legalEntityID.ResourceID)
+		}
+		grant, err := s.grantStore.ReadByAuthorizedOperation(ctx, tx, grantData)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("error reading grant: %w", err)
+		}
+		err = s.authorizationService.DeleteGrant(ctx, tx, grant.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting grant: %w", err)
+		}
+		s.Infof("Revoked grant for %s to perform %s on %s", assignment.GetAuthorizedResourceID(), operation, legalEntityID)
+	}
+	return nil
+}
+
+// retainedOperations returns the set of operations on a company the assignee of a role assignment must keep
+// holding, regardless of the role assignment.
+func (s *RoleService) retainedOperations(ctx context.Context, tx *store.Tx, legalEntityID models.LegalEntityID, assignment *models.RoleAssignment) (map[models.Operation]bool, error) {
+	retained := make(map[models.Operation]bool)
+	if assignment.AuthorizedGroupID.Valid() {
+		group, err := s.groupStore.Read(ctx, tx, assignment.AuthorizedGroupID)
+		if err != nil && !gerror.IsNotFound(err) {
+			return nil, fmt.Errorf("error reading group: %w", err)
+		}
+		if err == nil && group.IsInternal {
+			if definition := models.LookupStandardGroupDefinition(group.Name); definition != nil {
+				for _, operation := range definition.Operations {
+					retained[*operation] = true
+				}
+			}
+		}
+	}
+	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+	for moreResults := true; moreResults; {
+		roles, cursor, err := s.roleStore.ListByAssignee(ctx, tx, legalEntityID, assignment.GetAuthorizedResourceID(), pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing roles assigned to %s: %w", assignment.GetAuthorizedResourceID(), err)
+		}
+		for _, role := range roles {
+			for _, operation := range role.Operations {
+				retained[operation] = true
+			}
+		}
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next // move on to next page of results
+		} else {
+			moreResults = false
+		}
+	}
+	return retained, nil
+}
+
+// forEachAssignment calls fn for every assignment of a role.
+func (s *RoleService) forEachAssignment(ctx context.Context, tx *store.Tx, roleID models.RoleID, fn func(assignment *models.RoleAssignment) error) error {
+	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+	for moreResults := true; moreResults; {
+		assignments, cursor, err := s.roleAssignmentStore.ListByRoleID(ctx, tx, roleID, pagination)
+		if err != nil {
+			return fmt.Errorf("error listing role assignments: %w", err)
+		}
+		for _, assignment := range assignments {
+			err = fn(assignment)
+			if err != nil {
+				return err
+			}
+		}
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next // move on to next page of results
+		} else {
+			moreResults = false
+		}
+	}
+	return nil
+}
+
+// operationsNotIn returns the operations in a that are not in b.
+func operationsNotIn(a models.RoleOperations, b models.RoleOperations) models.RoleOperations {
+	inB := make(map[models.Operation]bool, len(b))
+	for _, operation := range b {
+		inB[operation] = true
+	}
+	var result models.RoleOperations
+	for _, operation := range a {
+		if !inB[operation] {
+			result = append(result, operation)
+		}
+	}
+	return result
+}
@@ -0,0 +1,198 @@
+package role_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+func TestRoleService(t *testing.T) {
+	ctx := context.Background()
+	now := models.NewTime(time.Now())
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "role-co", "Role Co", "roles@not-a-real-domain.com")
+	repo := server_test.CreateNamedRepo(t, ctx, app, "repo-1", company.ID)
+	alice, aliceIdentity := server_test.CreatePersonLegalEntity(t, ctx, app, "alice", "Alice First", "alice@not-a-real-domain.com")
+	bob, bobIdentity := server_test.CreatePersonLegalEntity(t, ctx, app, "bob", "Bob Second", "bob@not-a-real-domain.com")
+	_, daveIdentity := server_test.CreatePersonLegalEntity(t, ctx, app, "dave", "Dave Fourth", "dave@not-a-real-domain.com")
+	err = app.LegalEntityService.AddCompanyMember(ctx, nil, company.ID, alice.ID)
+	require.NoError(t, err)
+	err = app.LegalEntityService.AddCompanyMember(ctx, nil, company.ID, bob.ID)
+	require.NoError(t, err)
+
+	checkAuthorized := func(identityID models.IdentityID, operation *models.Operation, expected bool) {
+		t.Helper()
+		authorized, err := app.AuthorizationService.IsAuthorized(ctx, identityID, operation, repo.ID.ResourceID)
+		require.NoError(t, err)
+		require.Equal(t, expected, authorized, "expected authorized=%v for %s", expected, operation)
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := app.RoleService.Create(ctx, nil, dto.CreateRole{
+			LegalEntityID: company.ID,
+			Name:          "plaintext-reader",
+			Operations:    models.RoleOperations{*models.SecretReadPlaintextOperation},
+		})
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "roles must not grant operations reserved for build agents")
+
+		_, err = app.RoleService.Create(ctx, nil, dto.CreateRole{LegalEntityID: company.ID, Name: "empty"})
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err))
+
+		_, err = app.RoleService.Create(ctx, nil, dto.CreateRole{LegalEntityID: company.ID, TemplateName: "no-such-template"})
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err))
+
+		_, err = app.RoleService.Create(ctx, nil, dto.CreateRole{LegalEntityID: alice.ID, TemplateName: models.SecretsAdminRoleTemplate.Name})
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "roles can only be defined for companies")
+	})
+
+	secretsAdmin, err := app.RoleService.Create(ctx, nil, dto.CreateRole{
+		LegalEntityID: company.ID,
+		TemplateName:  models.SecretsAdminRoleTemplate.Name,
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.SecretsAdminRoleTemplate.Name, secretsAdmin.Name)
+	require.Len(t, secretsAdmin.Operations, len(models.SecretsAdminRoleTemplate.Operations))
+
+	releaseManager, err := app.RoleService.Create(ctx, nil, dto.CreateRole{
+		LegalEntityID: company.ID,
+		TemplateName:  models.ReleaseManagerRoleTemplate.Name,
+		Name:          "releases",
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.ResourceName("releases"), releaseManager.Name)
+
+	roles, _, err := app.RoleService.ListByLegalEntityID(ctx, nil, company.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, roles, 2)
+
+	t.Run("AssignToIdentity", func(t *testing.T) {
+		checkAuthorized(aliceIdentity.ID, models.SecretUpdateOperation, false)
+
+		assignment, err := app.RoleService.AssignToIdentity(ctx, nil, secretsAdmin.ID, aliceIdentity.ID)
+		require.NoError(t, err)
+		checkAuthorized(aliceIdentity.ID, models.SecretUpdateOperation, true)
+		checkAuthorized(aliceIdentity.ID, models.SecretReadPlaintextOperation, false)
+		checkAuthorized(aliceIdentity.ID, models.BuildCreateOperation, false)
+
+		again, err := app.RoleService.AssignToIdentity(ctx, nil, secretsAdmin.ID, aliceIdentity.ID)
+		require.NoError(t, err)
+		require.Equal(t, assignment.ID, again.ID, "assigning a role twice should return the existing assignment")
+
+		_, err = app.RoleService.AssignToIdentity(ctx, nil, secretsAdmin.ID, daveIdentity.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "roles can only be assigned to company members")
+		checkAuthorized(daveIdentity.ID, models.SecretUpdateOperation, false)
+	})
+
+	t.Run("AssignToGroup", func(t *testing.T) {
+		team, _, err := app.GroupService.FindOrCreateByName(ctx, nil, models.NewGroup(now, company.ID, "team-1", "Team 1", false, nil))
+		require.NoError(t, err)
+		_, _, err = app.GroupService.FindOrCreateMembership(ctx, nil, models.NewGroupMembershipData(team.ID, bobIdentity.ID, models.TestsSystem, company.ID))
+		require.NoError(t, err)
+		checkAuthorized(bobIdentity.ID, models.BuildPrioritizeOperation, false)
+
+		assignment, err := app.RoleService.AssignToGroup(ctx, nil, releaseManager.ID, team.ID)
+		require.NoError(t, err)
+		checkAuthorized(bobIdentity.ID, models.BuildPrioritizeOperation, true)
+		checkAuthorized(bobIdentity.ID, models.SecretUpdateOperation, false)
+
+		assignments, _, err := app.RoleService.ListAssignments(ctx, nil, releaseManager.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+		require.NoError(t, err)
+		require.Len(t, assignments, 1)
+		require.Equal(t, team.ID, assignments[0].AuthorizedGroupID)
+
+		err = app.RoleService.Unassign(ctx, nil, assignment.ID)
+		require.NoError(t, err)
+		checkAuthorized(bobIdentity.ID, models.BuildPrioritizeOperation, false)
+
+		otherCompany := server_test.CreateCompanyLegalEntity(t, ctx, app, "other-co", "Other Co", "other@not-a-real-domain.com")
+		otherGroup, err := app.GroupService.ReadByName(ctx, nil, otherCompany.ID, models.UserStandardGroup.Name)
+		require.NoError(t, err)
+		_, err = app.RoleService.AssignToGroup(ctx, nil, releaseManager.ID, otherGroup.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "roles can only be assigned to groups owned by the same company")
+
+		externalID := models.NewExternalResourceID(models.TestsSystem, "team-synced")
+		syncedGroup, _, err := app.GroupService.FindOrCreateByName(ctx, nil, models.NewGroup(now, company.ID, "team-synced", "Synced team", false, &externalID))
+		require.NoError(t, err)
+		_, err = app.RoleService.AssignToGroup(ctx, nil, releaseManager.ID, syncedGroup.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "roles can not be assigned to groups managed by an SCM")
+	})
+
+	t.Run("UnassignKeepsStandardGroupGrants", func(t *testing.T) {
+		userGroup, err := app.GroupService.ReadByName(ctx, nil, company.ID, models.UserStandardGroup.Name)
+		require.NoError(t, err)
+		_, _, err = app.GroupService.FindOrCreateMembership(ctx, nil, models.NewGroupMembershipData(userGroup.ID, bobIdentity.ID, models.TestsSystem, company.ID))
+		require.NoError(t, err)
+		checkAuthorized(bobIdentity.ID, models.BuildCreateOperation, true)
+
+		assignment, err := app.RoleService.AssignToGroup(ctx, nil, releaseManager.ID, userGroup.ID)
+		require.NoError(t, err)
+		checkAuthorized(bobIdentity.ID, models.BuildPrioritizeOperation, true)
+
+		err = app.RoleService.Unassign(ctx, nil, assignment.ID)
+		require.NoError(t, err)
+		checkAuthorized(bobIdentity.ID, models.BuildPrioritizeOperation, false)
+		checkAuthorized(bobIdentity.ID, models.BuildCreateOperation, true)
+	})
+
+	t.Run("UnassignKeepsOtherRoleGrants", func(t *testing.T) {
+		assignment, err := app.RoleService.AssignToIdentity(ctx, nil, releaseManager.ID, aliceIdentity.ID)
+		require.NoError(t, err)
+		checkAuthorized(aliceIdentity.ID, models.BuildCreateOperation, true)
+
+		err = app.RoleService.Unassign(ctx, nil, assignment.ID)
+		require.NoError(t, err)
+		checkAuthorized(aliceIdentity.ID, models.BuildCreateOperation, false)
+		// Repo read is also granted by the secrets admin role
+		checkAuthorized(aliceIdentity.ID, models.RepoReadOperation, true)
+	})
+
+	t.Run("UpdateOperations", func(t *testing.T) {
+		operations := models.RoleOperations{
+			*models.LegalEntityReadOperation,
+			*models.RepoReadOperation,
+			*models.SecretReadOperation,
+			*models.SecretCreateOperation,
+		}
+		updated, err := app.RoleService.Update(ctx, nil, secretsAdmin.ID, dto.UpdateRole{Operations: &operations})
+		require.NoError(t, err)
+		require.Equal(t, operations, updated.Operations)
+		checkAuthorized(aliceIdentity.ID, models.SecretCreateOperation, true)
+		checkAuthorized(aliceIdentity.ID, models.SecretUpdateOperation, false)
+		checkAuthorized(aliceIdentity.ID, models.SecretDeleteOperation, false)
+
+		operations = append(operations, *models.SecretDeleteOperation)
+		_, err = app.RoleService.Update(ctx, nil, secretsAdmin.ID, dto.UpdateRole{Operations: &operations})
+		require.NoError(t, err)
+		checkAuthorized(aliceIdentity.ID, models.SecretDeleteOperation, true)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := app.RoleService.Delete(ctx, nil, secretsAdmin.ID)
+		require.NoError(t, err)
+		checkAuthorized(aliceIdentity.ID, models.SecretCreateOperation, false)
+		checkAuthorized(aliceIdentity.ID, models.RepoReadOperation, false)
+
+		_, err = app.RoleService.Read(ctx, nil, secretsAdmin.ID)
+		require.True(t, gerror.IsNotFound(err))
+		err = app.RoleService.Delete(ctx, nil, secretsAdmin.ID)
+		require.NoError(t, err, "delete should be idempotent")
+	})
+}
@@ -3,10 +3,14 @@ package runner
 import (
 	"context"
 	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/dto"
@@ -14,15 +18,54 @@ import (
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
+const (
+	// DefaultMinDiskFreeBytes is the default minimum free disk space a runner must report to be healthy.
+	DefaultMinDiskFreeBytes = 2 * 1024 * 1024 * 1024
+	// DefaultMaxClockSkew is the default maximum difference allowed between a runner's clock and the server's
+	// clock for the runner to be healthy. This must allow for the time taken to deliver the health report.
+	DefaultMaxClockSkew = 2 * time.Minute
+	// DefaultOfflineAfter is the default time after the last heartbeat from a runner at which the runner is
+	// considered to be offline. This should be several times the interval at which runners send heartbeats.
+	DefaultOfflineAfter = 5 * time.Minute
+	// offlineDetectionInterval is how often to check for runners that have stopped sending heartbeats.
+	offlineDetectionInterval = 30 * time.Second
+	// offlineDetectionTimeout is the maximum time to spend on a single check for offline runners.
+	offlineDetectionTimeout = time.Minute
+)
+
+type RunnerHealthConfig struct {
+	// MinDiskFreeBytes is the minimum free disk space a runner must report to be healthy.
+	// Zero disables the check.
+	MinDiskFreeBytes uint64
+	// MaxClockSkew is the maximum difference allowed between a runner's clock and the server's clock for the
+	// runner to be healthy. Zero disables the check.
+	MaxClockSkew time.Duration
+	// OfflineAfter is the time after the last heartbeat from a runner at which the runner is marked as offline.
+	// Zero disables offline detection.
+	OfflineAfter time.Duration
+}
+
 type RunnerService struct {
-	db                *store.DB
-	credentialService services.CredentialService
-	groupService      services.GroupService
-	runnerStore       store.RunnerStore
-	ownershipStore    store.OwnershipStore
-	resourceLinkStore store.ResourceLinkStore
-	identityStore     store.IdentityStore
+	db                    *store.DB
+	credentialService     services.CredentialService
+	groupService          services.GroupService
+	runnerStore           store.RunnerStore
+	runnerPoolStore       store.RunnerPoolStore
+	ownershipStore        store.OwnershipStore
+	resourceLinkStore     store.ResourceLinkStore
+	identityStore         store.IdentityStore
+	jobStore              store.JobStore
+	eventService          services.EventService
+	leaderElectionService services.LeaderElectionService
+	healthConfig          RunnerHealthConfig
 	logger.Log
+
+	healthChangedHandlersMu sync.RWMutex
+	healthChangedHandlers   []services.RunnerHealthChangedHandler
+
+	startStopMutex sync.Mutex
+	exitChan       chan bool
+	wg             sync.WaitGroup
 }
 
 func NewRunnerService(
@@ -30,20 +73,30 @@ func NewRunnerService(
 	credentialService services.CredentialService,
 	groupService services.GroupService,
 	runnerStore store.RunnerStore,
+	runnerPoolStore store.RunnerPoolStore,
 	ownershipStore store.OwnershipStore,
 	resourceLinkStore store.ResourceLinkStore,
 	identityStore store.IdentityStore,
+	jobStore store.JobStore,
+	eventService services.EventService,
+	leaderElectionService services.LeaderElectionService,
+	healthConfig RunnerHealthConfig,
 	logFactory logger.LogFactory) *RunnerService {
 
 	return &RunnerService{
-		db:                db,
-		credentialService: credentialService,
-		groupService:      groupService,
-		runnerStore:       runnerStore,
-		ownershipStore:    ownershipStore,
-		resourceLinkStore: resourceLinkStore,
-		identityStore:     identityStore,
-		Log:               logFactory("RunnerService"),
+		db:                    db,
+		credentialService:     credentialService,
+		groupService:          groupService,
+		runnerStore:           runnerStore,
+		runnerPoolStore:       runnerPoolStore,
+		ownershipStore:        ownershipStore,
+		resourceLinkStore:     resourceLinkStore,
+		identityStore:         identityStore,
+		jobStore:              jobStore,
+		eventService:          eventService,
+		leaderElectionService: leaderElectionService,
+		healthConfig:          healthConfig,
+		Log:                   logFactory("RunnerService"),
 	}
 }
 
@@ -53,6 +106,12 @@ func (s *RunnerService) Read(ctx context.Context, txOrNil *store.Tx, id models.R
 	return s.runnerStore.Read(ctx, txOrNil, id)
 }
 
+// ListByIDs reads the runners with the specified IDs, in no particular order. IDs of runners that do not
+// exist are ignored.
+func (s *RunnerService) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.RunnerID) ([]*models.Runner, error) {
+	return s.runnerStore.ListByIDs(ctx, txOrNil, ids)
+}
+
 // ReadByName reads an existing runner, looking it up by name and the ID of the legal entity that owns the runner.
 // Returns models.ErrNotFound if the runner is not found.
 func (s *RunnerService) ReadByName(
@@ -135,7 +194,7 @@ func (s *RunnerService) Create(ctx context.Context, txOrNil *store.Tx, runner *m
 			return fmt.Errorf("error creating identity ownership for new runner: %w", err)
 		}
 		// Add the runner to the 'runner' standard group, so it picks up suitable permissions
-		err = s.addRunnerToRunnerStandardGroup(ctx, tx, runner, identity)
+		err = s.addRunnerToRunnerStandardGroup(ctx, tx, runner, identity, runner.LegalEntityID)
 		if err != nil {
 			return err
 		}
@@ -151,29 +210,30 @@ func (s *RunnerService) Create(ctx context.Context, txOrNil *store.Tx, runner *m
 	})
 }
 
-// addRunnerToRunnerStandardGroup will add the specified runner to the runners access control group for its parent
-// legal entity, to give it access rights required to dequeue and run builds for that legal entity.
+// addRunnerToRunnerStandardGroup will add the specified runner to the runners access control group for a legal
+// entity (normally its parent legal entity), to give it access rights required to dequeue and run builds for that
+// legal entity.
 func (s *RunnerService) addRunnerToRunnerStandardGroup(
 	ctx context.Context,
 	txOrNil *store.Tx,
 	runner *models.Runner,
 	runnerIdentity *models.Identity,
+	legalEntityID models.LegalEntityID,
 ) error {
-	// Find the 'runner' standard group for the legal entity that owns this runner
-	ownerLegalEntityID := runner.LegalEntityID
-	group, err := s.groupService.ReadByName(ctx, txOrNil, ownerLegalEntityID, models.RunnerStandardGroup.Name)
+	// Find the 'runner' standard group for the legal entity
+	group, err := s.groupService.ReadByName(ctx, txOrNil, legalEntityID, models.RunnerStandardGroup.Name)
 	if err != nil {
 		return fmt.Errorf("error reading standard group for runner: %w", err)
 	}
 
 	s.Infof("Attempting to add runner %s to group '%s' for legal entity %s (GroupID %s)",
-		runner.ID, group.Name, ownerLegalEntityID, group.ID)
-	addedBy := runner.LegalEntityID // record that the runner was added to this group by the owning legal entity
+		runner.ID, group.Name, legalEntityID, group.ID)
+	addedBy := legalEntityID // record that the runner was added to this group by the legal entity that owns the group
 	_, _, err = s.groupService.FindOrCreateMembership(ctx, txOrNil, models.NewGroupMembershipData(
 		group.ID, runnerIdentity.ID, models.BuildBeaverSystem, addedBy))
 	if err != nil {
 		return fmt.Errorf("error adding %s to group '%s' for legal entity %s: %w",
-			runner.ID, group.Name, ownerLegalEntityID, err)
+			runner.ID, group.Name, legalEntityID, err)
 	}
 
 	return nil
@@ -240,9 +300,14 @@ func (s *RunnerService) Update(ctx context.Context, txOrNil *store.Tx, runner *m
 	return runner, nil
 }
 
-// RunnerCompatibleWithJob returns true if a runner exists that is capable of running job.
+// RunnerCompatibleWithJob returns true if a runner exists that is capable of running job, or if a runner
+// pool exists that could start one.
 func (s *RunnerService) RunnerCompatibleWithJob(ctx context.Context, txOrNil *store.Tx, job *models.Job) (bool, error) {
-	return s.runnerStore.RunnerCompatibleWithJob(ctx, txOrNil, job)
+	compatible, err := s.runnerStore.RunnerCompatibleWithJob(ctx, txOrNil, job)
+	if err != nil || compatible {
+		return compatible, err
+	}
+	return s.runnerPoolStore.PoolCompatibleWithJob(ctx, txOrNil, job)
 }
 
 // SoftDelete an existing runner.
@@ -265,7 +330,7 @@ func (s *RunnerService) SoftDelete(ctx context.Context, txOrNil *store.Tx, runne
 		if err != nil {
 			return fmt.Errorf("error deleting resource link: %w", err)
 		}
-		err = s.removeRunnerFromRunnerStandardGroup(ctx, tx, runner, identity)
+		err = s.removeRunnerFromRunnerStandardGroup(ctx, tx, runner, identity, runner.LegalEntityID)
 		if err != nil {
 			return err
 		}
@@ -280,33 +345,101 @@ func (s *RunnerService) SoftDelete(ctx context.Context, txOrNil *store.Tx, runne
 }
 
 // removeRunnerFromRunnerStandardGroup will remove the specified runner from the runners access control group for
-// its parent legal entity, to remove its access rights.
+// a legal entity (normally its parent legal entity), to remove its access rights.
 func (s *RunnerService) removeRunnerFromRunnerStandardGroup(
 	ctx context.Context,
 	txOrNil *store.Tx,
 	runner *models.Runner,
 	runnerIdentity *models.Identity,
+	legalEntityID models.LegalEntityID,
 ) error {
-	// Find the 'runner' standard group for the legal entity that owns this runner
-	ownerLegalEntityID := runner.LegalEntityID
-	group, err := s.groupService.ReadByName(ctx, txOrNil, ownerLegalEntityID, models.RunnerStandardGroup.Name)
+	// Find the 'runner' standard group for the legal entity
+	group, err := s.groupService.ReadByName(ctx, txOrNil, legalEntityID, models.RunnerStandardGroup.Name)
 	if err != nil {
 		return fmt.Errorf("error reading standard group for runner: %w", err)
 	}
 
 	s.Tracef("Removing runner %s from group '%s' for legal entity %s (GroupID %s)",
-		runner.ID, group.Name, ownerLegalEntityID, group.ID)
+		runner.ID, group.Name, legalEntityID, group.ID)
 
 	bbSystem := models.BuildBeaverSystem
 	err = s.groupService.RemoveMembership(ctx, txOrNil, group.ID, runnerIdentity.ID, &bbSystem)
 	if err != nil {
 		return fmt.Errorf("error removing %s from group '%s' for legal entity %s: %w",
-			runner.ID, group.Name, ownerLegalEntityID, err)
+			runner.ID, group.Name, legalEntityID, err)
 	}
 
 	return nil
 }
 
+// ShareWithLegalEntity lets a runner run jobs for another legal entity's repos as well as for its own legal
+// entity's repos, by adding the runner to the other legal entity's 'runner' standard group.
+// The runner gets the same access to the other legal entity's builds, including their secrets, as that legal
+// entity's own runners, so whoever controls the runner must be trusted by the other legal entity.
+// When jobs from several legal entities are queued, the runner is shared according to each legal entity's
+// scheduling weight.
+func (s *RunnerService) ShareWithLegalEntity(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID, legalEntityID models.LegalEntityID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		runner, err := s.runnerStore.Read(ctx, tx, runnerID)
+		if err != nil {
+			return fmt.Errorf("error reading runner: %w", err)
+		}
+		if runner.LegalEntityID == legalEntityID {
+			return gerror.NewErrValidationFailed("A runner always runs jobs for the legal entity that owns it")
+		}
+		identity, err := s.ReadIdentity(ctx, tx, runner.ID)
+		if err != nil {
+			return err
+		}
+		return s.addRunnerToRunnerStandardGroup(ctx, tx, runner, identity, legalEntityID)
+	})
+}
+
+// StopSharingWithLegalEntity stops a runner running jobs for another legal entity's repos, undoing
+// ShareWithLegalEntity. A runner can't stop running jobs for its own legal entity.
+func (s *RunnerService) StopSharingWithLegalEntity(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID, legalEntityID models.LegalEntityID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		runner, err := s.runnerStore.Read(ctx, tx, runnerID)
+		if err != nil {
+			return fmt.Errorf("error reading runner: %w", err)
+		}
+		if runner.LegalEntityID == legalEntityID {
+			return gerror.NewErrValidationFailed("A runner can't stop running jobs for the legal entity that owns it")
+		}
+		identity, err := s.ReadIdentity(ctx, tx, runner.ID)
+		if err != nil {
+			return err
+		}
+		return s.removeRunnerFromRunnerStandardGroup(ctx, tx, runner, identity, legalEntityID)
+	})
+}
+
+// ListLegalEntityIDs lists the IDs of the legal entities whose jobs a runner can run: the legal entity that owns
+// the runner, and any legal entities the runner has been shared with.
+func (s *RunnerService) ListLegalEntityIDs(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID) ([]models.LegalEntityID, error) {
+	identity, err := s.ReadIdentity(ctx, txOrNil, runnerID)
+	if err != nil {
+		return nil, err
+	}
+	var legalEntityIDs []models.LegalEntityID
+	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+	for {
+		groups, cursor, err := s.groupService.ListGroups(ctx, txOrNil, nil, &identity.ID, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing groups for runner: %w", err)
+		}
+		for _, group := range groups {
+			if group.Name == models.RunnerStandardGroup.Name {
+				legalEntityIDs = append(legalEntityIDs, group.LegalEntityID)
+			}
+		}
+		if cursor == nil || cursor.Next == nil {
+			return legalEntityIDs, nil
+		}
+		pagination.Cursor = cursor.Next
+	}
+}
+
 // deleteRunnerCredentials will hard delete any credentials the runner was using to authenticate.
 func (s *RunnerService) deleteRunnerCredentials(
 	ctx context.Context,
@@ -351,6 +484,207 @@ func (s *RunnerService) Search(ctx context.Context, txOrNil *store.Tx, searcher
 	return s.runnerStore.Search(ctx, txOrNil, searcher, search)
 }
 
+// UpdateHealth records a health report sent by a runner as a heartbeat. The runner is marked as online, and
+// is marked as unhealthy and will not be given jobs to run if the report shows problems with the runner's host.
+func (s *RunnerService) UpdateHealth(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID, report models.RunnerHealthReport) (*models.Runner, error) {
+	err := report.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	health := s.assessHealth(models.NewTime(time.Now()), report)
+	var runner *models.Runner
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.runnerStore.LockRowForUpdate(ctx, tx, runnerID)
+		if err != nil {
+			return fmt.Errorf("error locking runner: %w", err)
+		}
+		runner, err = s.runnerStore.Read(ctx, tx, runnerID)
+		if err != nil {
+			return fmt.Errorf("error reading runner: %w", err)
+		}
+		wasHealthy := runner.IsHealthy()
+		if !runner.Online && runner.LastSeenAt != nil {
+			s.Infof("Runner %q is online again", runner.ID)
+		}
+		runner.Health = health
+		runner.LastSeenAt = &health.ReceivedAt
+		runner.Online = true
+		if report.SoftwareVersion != "" {
+			runner.SoftwareVersion = report.SoftwareVersion
+		}
+		runner.UpdatedAt = health.ReceivedAt
+		err = s.runnerStore.Update(ctx, tx, runner)
+		if err != nil {
+			return fmt.Errorf("error updating runner: %w", err)
+		}
+		if runner.IsHealthy() == wasHealthy {
+			return nil
+		}
+		if runner.IsHealthy() {
+			s.Infof("Runner %q is healthy again", runner.ID)
+		} else {
+			s.Warnf("Runner %q is unhealthy and will not be given jobs to run: %s", runner.ID, strings.Join(health.Problems, "; "))
+		}
+		s.healthChangedHandlersMu.RLock()
+		handlers := s.healthChangedHandlers
+		s.healthChangedHandlersMu.RUnlock()
+		for _, handler := range handlers {
+			err := handler(ctx, tx, runner)
+			if err != nil {
+				return fmt.Errorf("error calling runner health changed handler: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runner, nil
+}
+
+// RegisterHealthChangedHandler registers a handler to be called each time a runner becomes unhealthy, or
+// becomes healthy again. Handlers are called inside the transaction that updates the runner.
+func (s *RunnerService) RegisterHealthChangedHandler(handler services.RunnerHealthChangedHandler) {
+	s.healthChangedHandlersMu.Lock()
+	defer s.healthChangedHandlersMu.Unlock()
+	s.healthChangedHandlers = append(s.healthChangedHandlers, handler)
+}
+
+// StartOfflineDetection starts periodically checking for runners that have stopped sending heartbeats,
+// marking them as offline, whenever this server is the leader. Does nothing if offline detection is disabled.
+func (s *RunnerService) StartOfflineDetection() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan != nil || s.healthConfig.OfflineAfter <= 0 {
+		return
+	}
+	s.Trace("Starting runner offline detection loop...")
+	s.exitChan = make(chan bool)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.offlineDetectionLoop()
+	}()
+}
+
+// StopOfflineDetection stops checking for runners that have stopped sending heartbeats, waiting for any
+// check currently in progress to complete.
+func (s *RunnerService) StopOfflineDetection() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan == nil {
+		return
+	}
+	close(s.exitChan)
+	s.wg.Wait()
+	s.exitChan = nil
+}
+
+func (s *RunnerService) offlineDetectionLoop() {
+	ticker := time.NewTicker(offlineDetectionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.exitChan:
+			s.Trace("Exiting runner offline detection loop...")
+			return
+		case <-ticker.C:
+			if !s.leaderElectionService.IsLeader() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), offlineDetectionTimeout)
+			err := s.DetectOfflineRunners(ctx, models.NewTime(time.Now()))
+			cancel()
+			if err != nil {
+				s.Errorf("Error detecting offline runners: %v", err)
+			}
+		}
+	}
+}
+
+// DetectOfflineRunners marks runners that have not sent a heartbeat within the configured period before now
+// as offline. A RunnerOffline event is published for each job the runner was running when it went offline.
+func (s *RunnerService) DetectOfflineRunners(ctx context.Context, now models.Time) error {
+	if s.healthConfig.OfflineAfter <= 0 {
+		return nil
+	}
+	since := models.NewTime(now.Add(-s.healthConfig.OfflineAfter))
+	runners, err := s.runnerStore.ListOnlineNotSeenSince(ctx, nil, since)
+	if err != nil {
+		return fmt.Errorf("error listing runners not seen since %s: %w", since, err)
+	}
+	for _, candidate := range runners {
+		err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+			err := s.runnerStore.LockRowForUpdate(ctx, tx, candidate.ID)
+			if err != nil {
+				return fmt.Errorf("error locking runner: %w", err)
+			}
+			runner, err := s.runnerStore.Read(ctx, tx, candidate.ID)
+			if err != nil {
+				return fmt.Errorf("error reading runner: %w", err)
+			}
+			// The runner may have sent a heartbeat since it was listed
+			if !runner.Online || runner.LastSeenAt == nil || !runner.LastSeenAt.Before(since.Time) {
+				return nil
+			}
+			runner.Online = false
+			runner.UpdatedAt = now
+			err = s.runnerStore.Update(ctx, tx, runner)
+			if err != nil {
+				return fmt.Errorf("error updating runner: %w", err)
+			}
+			jobs, err := s.jobStore.ListRunningByRunnerID(ctx, tx, runner.ID)
+			if err != nil {
+				return fmt.Errorf("error listing jobs running on runner: %w", err)
+			}
+			s.Warnf("Runner %q is offline; no heartbeat received since %s; %d job(s) in progress", runner.ID, runner.LastSeenAt, len(jobs))
+			for _, job := range jobs {
+				err = s.eventService.PublishEvent(ctx, tx, models.NewRunnerOfflineEventData(job, runner))
+				if err != nil {
+					return fmt.Errorf("error publishing runner offline event: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error marking runner %q offline: %w", candidate.ID, err)
+		}
+	}
+	return nil
+}
+
+// assessHealth checks a health report received from a runner at the specified time against the configured
+// limits, and returns the runner's health including any problems found.
+func (s *RunnerService) assessHealth(receivedAt models.Time, report models.RunnerHealthReport) *models.RunnerHealth {
+	skew := report.Time.Sub(receivedAt.Time)
+	health := &models.RunnerHealth{
+		RunnerHealthReport: report,
+		ReceivedAt:         receivedAt,
+		ClockSkewSeconds:   skew.Seconds(),
+		Problems:           []string{},
+	}
+	if report.DiskError != "" {
+		health.Problems = append(health.Problems, fmt.Sprintf("unable to measure disk space at %q: %s", report.DiskPath, report.DiskError))
+	} else if s.healthConfig.MinDiskFreeBytes > 0 && report.DiskFreeBytes < s.healthConfig.MinDiskFreeBytes {
+		health.Problems = append(health.Problems, fmt.Sprintf("only %d MiB of disk space is free at %q, minimum is %d MiB",
+			report.DiskFreeBytes/(1024*1024), report.DiskPath, s.healthConfig.MinDiskFreeBytes/(1024*1024)))
+	}
+	if report.DockerReachable != nil && !*report.DockerReachable {
+		problem := "docker daemon is not reachable"
+		if report.DockerError != "" {
+			problem = fmt.Sprintf("%s: %s", problem, report.DockerError)
+		}
+		health.Problems = append(health.Problems, problem)
+	}
+	if s.healthConfig.MaxClockSkew > 0 && time.Duration(math.Abs(float64(skew))) > s.healthConfig.MaxClockSkew {
+		health.Problems = append(health.Problems, fmt.Sprintf("clock is out by %s, maximum is %s",
+			skew.Round(time.Second), s.healthConfig.MaxClockSkew))
+	}
+	return health
+}
+
 // configureDefaultLabels ensures the runner's labels are populated with suitable defaults.
 func (s *RunnerService) configureDefaultLabels(runner *models.Runner) {
 	if runner.OperatingSystem != "" {
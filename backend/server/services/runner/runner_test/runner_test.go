@@ -1,13 +1,16 @@
 package runner_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/runner/app/runner_test"
 	"github.com/buildbeaver/buildbeaver/server/app/server_test"
@@ -129,3 +132,241 @@ func TestRunnerLabelUpdate(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, labels, runner.Labels)
 }
+
+func TestRunnerHealth(t *testing.T) {
+	ctx := context.Background()
+	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	testCompany := server_test.CreateCompanyLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", testCompany.ID, nil)
+	require.True(t, runner.IsHealthy(), "Runner should be healthy before it has reported its health")
+
+	webhook, err := app.OutgoingWebhookService.Create(ctx, nil, dto.CreateOutgoingWebhook{
+		LegalEntityID:         testCompany.ID,
+		URL:                   "https://example.com/hook",
+		SecretPlaintext:       "secret",
+		OnRunnerHealthChanged: true,
+	})
+	require.NoError(t, err)
+
+	dockerReachable := true
+	healthyReport := models.RunnerHealthReport{
+		Time:            models.NewTime(time.Now()),
+		DiskPath:        "/tmp",
+		DiskFreeBytes:   10 * 1024 * 1024 * 1024,
+		DiskTotalBytes:  100 * 1024 * 1024 * 1024,
+		DockerReachable: &dockerReachable,
+	}
+	runner, err = app.RunnerService.UpdateHealth(ctx, nil, runner.ID, healthyReport)
+	require.NoError(t, err)
+	require.True(t, runner.IsHealthy())
+	require.NotNil(t, runner.Health)
+	require.Empty(t, runner.Health.Problems)
+
+	// A runner that is low on disk space and can't reach docker is unhealthy and isn't given jobs
+	dockerUnreachable := false
+	unhealthyReport := healthyReport
+	unhealthyReport.Time = models.NewTime(time.Now())
+	unhealthyReport.DiskFreeBytes = 100 * 1024 * 1024
+	unhealthyReport.DockerReachable = &dockerUnreachable
+	unhealthyReport.DockerError = "connection refused"
+	runner, err = app.RunnerService.UpdateHealth(ctx, nil, runner.ID, unhealthyReport)
+	require.NoError(t, err)
+	require.False(t, runner.IsHealthy())
+	require.Len(t, runner.Health.Problems, 2)
+	runner, err = app.RunnerService.Read(ctx, nil, runner.ID)
+	require.NoError(t, err)
+	require.False(t, runner.IsHealthy(), "Health should be persisted")
+	_, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.True(t, gerror.IsRunnerUnhealthy(err))
+
+	// A runner whose clock is wrong is unhealthy
+	skewedReport := healthyReport
+	skewedReport.Time = models.NewTime(time.Now().Add(time.Hour))
+	runner, err = app.RunnerService.UpdateHealth(ctx, nil, runner.ID, skewedReport)
+	require.NoError(t, err)
+	require.False(t, runner.IsHealthy())
+	require.Len(t, runner.Health.Problems, 1)
+	require.Contains(t, runner.Health.Problems[0], "clock")
+	require.InDelta(t, time.Hour.Seconds(), runner.Health.ClockSkewSeconds, 60)
+
+	// Once the runner reports it is healthy again it can dequeue jobs
+	healthyReport.Time = models.NewTime(time.Now())
+	runner, err = app.RunnerService.UpdateHealth(ctx, nil, runner.ID, healthyReport)
+	require.NoError(t, err)
+	require.True(t, runner.IsHealthy())
+	_, err = app.QueueService.Dequeue(ctx, runner.ID)
+	require.True(t, gerror.IsNotFound(err))
+
+	// A delivery is queued each time the runner becomes unhealthy or healthy again, but not while it stays unhealthy
+	deliveries, _, err := app.OutgoingWebhookService.ListDeliveries(ctx, nil, webhook.ID, pagination)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	for _, delivery := range deliveries {
+		require.Equal(t, models.RunnerHealthChangedEvent, delivery.EventType)
+	}
+
+	// Runner health events can't be delivered to repo webhooks
+	repo := server_test.CreateRepo(t, ctx, app, testCompany.ID)
+	_, err = app.OutgoingWebhookService.Create(ctx, nil, dto.CreateOutgoingWebhook{
+		RepoID:                repo.ID,
+		URL:                   "https://example.com/hook",
+		SecretPlaintext:       "secret",
+		OnRunnerHealthChanged: true,
+	})
+	require.True(t, gerror.IsValidationFailed(err))
+}
+
+func TestRunnerHeartbeats(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	testCompany := server_test.CreateCompanyLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "busy-runner", testCompany.ID, nil)
+	require.False(t, runner.Online, "Runner should be offline before it has sent a heartbeat")
+	require.Nil(t, runner.LastSeenAt)
+	idleRunner := server_test.CreateRunner(t, ctx, app, "idle-runner", testCompany.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, testCompany.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, testCompany.ID, "")
+
+	searchOnline := func(online bool) []models.RunnerID {
+		search := models.RunnerSearch{
+			Pagination:    models.NewPagination(models.DefaultPaginationLimit, nil),
+			LegalEntityID: &testCompany.ID,
+			Online:        &online,
+		}
+		runners, _, err := app.RunnerService.Search(ctx, nil, models.IdentityID{}, search)
+		require.NoError(t, err)
+		var ids []models.RunnerID
+		for _, runner := range runners {
+			ids = append(ids, runner.ID)
+		}
+		return ids
+	}
+	heartbeat := func(runnerID models.RunnerID, jobIDs ...models.JobID) *models.Runner {
+		runner, err := app.RunnerService.UpdateHealth(ctx, nil, runnerID, models.RunnerHealthReport{
+			Time:            models.NewTime(time.Now()),
+			DiskPath:        "/tmp",
+			DiskFreeBytes:   10 * 1024 * 1024 * 1024,
+			DiskTotalBytes:  100 * 1024 * 1024 * 1024,
+			SoftwareVersion: "test-heartbeat-version",
+			CurrentJobIDs:   jobIDs,
+			Resources: &models.RunnerResourceStats{
+				CPUCount:             4,
+				LoadAverage:          1.5,
+				MemoryTotalBytes:     8 * 1024 * 1024 * 1024,
+				MemoryAvailableBytes: 2 * 1024 * 1024 * 1024,
+			},
+		})
+		require.NoError(t, err)
+		return runner
+	}
+
+	// Heartbeats mark runners as online and record the runner's version, jobs and resource usage
+	heartbeat(idleRunner.ID)
+	runner = heartbeat(runner.ID)
+	require.True(t, runner.Online)
+	require.NotNil(t, runner.LastSeenAt)
+	require.Equal(t, "test-heartbeat-version", runner.SoftwareVersion)
+	require.ElementsMatch(t, []models.RunnerID{runner.ID, idleRunner.ID}, searchOnline(true))
+	require.Empty(t, searchOnline(false))
+
+	job, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	runner = heartbeat(runner.ID, job.ID)
+	require.Equal(t, []models.JobID{job.ID}, runner.Health.CurrentJobIDs)
+	require.Equal(t, 4, runner.Health.Resources.CPUCount)
+
+	// Runners that have sent a heartbeat recently stay online
+	err = app.RunnerService.DetectOfflineRunners(ctx, models.NewTime(time.Now()))
+	require.NoError(t, err)
+	require.Len(t, searchOnline(true), 2)
+
+	// Runners that stop sending heartbeats go offline, with an event for each job they were running
+	err = app.RunnerService.DetectOfflineRunners(ctx, models.NewTime(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	require.Empty(t, searchOnline(true))
+	require.ElementsMatch(t, []models.RunnerID{runner.ID, idleRunner.ID}, searchOnline(false))
+	events, err := app.EventService.FetchEvents(ctx, nil, graph.ID, 0, 1000)
+	require.NoError(t, err)
+	var offlineEvents []*models.Event
+	for _, event := range events {
+		if event.Type == models.RunnerOfflineEvent {
+			offlineEvents = append(offlineEvents, event)
+		}
+	}
+	require.Len(t, offlineEvents, 1)
+	require.Equal(t, job.ID.ResourceID, offlineEvents[0].ResourceID)
+	require.Equal(t, runner.ID.String(), offlineEvents[0].Payload)
+
+	// The next heartbeat brings the runner back online
+	runner = heartbeat(runner.ID, job.ID)
+	require.True(t, runner.Online)
+	require.Equal(t, []models.RunnerID{runner.ID}, searchOnline(true))
+}
+
+func TestRunnerJobHistory(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	testCompany := server_test.CreateCompanyLegalEntity(t, ctx, app, "", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "flaky-runner", testCompany.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, testCompany.ID)
+	start := models.NewTime(time.Now())
+	server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, testCompany.ID, "")
+
+	search := func(search models.JobSearch) []models.JobID {
+		search.Pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+		search.RunnerID = &runner.ID
+		jobs, _, err := app.JobService.Search(ctx, nil, search)
+		require.NoError(t, err)
+		var ids []models.JobID
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+
+	// Only jobs that have been handed to the runner are included
+	require.Empty(t, search(models.JobSearch{}))
+	failed, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, failed.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusRunning})
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, failed.ID, dto.UpdateJobStatus{
+		Status: models.WorkflowStatusFailed,
+		Error:  models.NewError(errors.New("broken host")),
+	})
+	require.NoError(t, err)
+	server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, testCompany.ID, "")
+	running, err := app.QueueService.Dequeue(ctx, runner.ID)
+	require.NoError(t, err)
+	_, err = app.QueueService.UpdateJobStatus(ctx, nil, running.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusRunning})
+	require.NoError(t, err)
+	finished := models.NewTime(time.Now())
+	require.ElementsMatch(t, []models.JobID{failed.ID, running.ID}, search(models.JobSearch{}))
+
+	// Jobs can be filtered by status
+	require.Equal(t, []models.JobID{failed.ID}, search(models.JobSearch{Statuses: []models.WorkflowStatus{models.WorkflowStatusFailed}}))
+
+	// Jobs are included if they were active during the time window
+	require.ElementsMatch(t, []models.JobID{failed.ID, running.ID}, search(models.JobSearch{After: &start, Before: &finished}))
+	later := models.NewTime(time.Now().Add(time.Hour))
+	require.Equal(t, []models.JobID{running.ID}, search(models.JobSearch{After: &later}))
+	require.Empty(t, search(models.JobSearch{Before: &start}))
+
+	// Time windows must end after they start
+	_, _, err = app.JobService.Search(ctx, nil, models.JobSearch{After: &later, Before: &start})
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+}
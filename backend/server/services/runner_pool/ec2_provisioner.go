@@ -0,0 +1,222 @@
+package runner_pool
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+const (
+	ec2ProvisionerTimeout = 30 * time.Second
+	ec2APIVersion         = "2016-11-15"
+	// EC2PoolTagKey is the tag added to every instance launched for a runner pool, with the pool's ID as the value.
+	EC2PoolTagKey = "buildbeaver:runner-pool"
+)
+
+// EC2Provisioner runs a pool's runners on EC2 instances launched from a launch template. Instances are tagged
+// with the pool's ID so the provisioner can count them; when scaling down, the most recently launched instances
+// are terminated first. Requests to EC2 are authenticated using the server's AWS credentials, which need
+// permission to run, describe and terminate instances and to use the launch template.
+type EC2Provisioner struct {
+	client      *http.Client
+	credsOnce   sync.Once
+	credentials *credentials.Credentials
+	credsErr    error
+}
+
+func NewEC2Provisioner() *EC2Provisioner {
+	return &EC2Provisioner{
+		client: &http.Client{Timeout: ec2ProvisionerTimeout},
+	}
+}
+
+func (p *EC2Provisioner) Type() models.RunnerProvisionerType {
+	return models.RunnerProvisionerTypeEC2
+}
+
+// Scale launches or terminates instances until the pool has the desired number of pending or running instances.
+func (p *EC2Provisioner) Scale(ctx context.Context, pool *models.RunnerPool, secret string, desired int) error {
+	config := pool.ProvisionerConfig.EC2
+	if config == nil {
+		return fmt.Errorf("error ec2 provisioner config is missing")
+	}
+	instances, err := p.describePoolInstances(ctx, config.Region, pool.ID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case desired > len(instances):
+		return p.runInstances(ctx, config, pool.ID, desired-len(instances))
+	case desired < len(instances):
+		sort.Slice(instances, func(i, j int) bool {
+			return instances[i].LaunchTime.After(instances[j].LaunchTime)
+		})
+		var instanceIDs []string
+		for _, instance := range instances[:len(instances)-desired] {
+			instanceIDs = append(instanceIDs, instance.InstanceID)
+		}
+		return p.terminateInstances(ctx, config.Region, instanceIDs)
+	default:
+		return nil
+	}
+}
+
+type ec2Instance struct {
+	InstanceID string    `xml:"instanceId"`
+	LaunchTime time.Time `xml:"launchTime"`
+}
+
+type ec2DescribeInstancesResponse struct {
+	Reservations []struct {
+		Instances []ec2Instance `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+type ec2ErrorResponse struct {
+	Errors []struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Errors>Error"`
+}
+
+// describePoolInstances lists the pending and running instances tagged as belonging to the pool.
+func (p *EC2Provisioner) describePoolInstances(ctx context.Context, region string, poolID models.RunnerPoolID) ([]ec2Instance, error) {
+	var (
+		instances []ec2Instance
+		nextToken string
+	)
+	for {
+		params := url.Values{}
+		params.Set("Action", "DescribeInstances")
+		params.Set("Filter.1.Name", "tag:"+EC2PoolTagKey)
+		params.Set("Filter.1.Value.1", poolID.String())
+		params.Set("Filter.2.Name", "instance-state-name")
+		params.Set("Filter.2.Value.1", "pending")
+		params.Set("Filter.2.Value.2", "running")
+		if nextToken != "" {
+			params.Set("NextToken", nextToken)
+		}
+		res := &ec2DescribeInstancesResponse{}
+		err := p.call(ctx, region, params, res)
+		if err != nil {
+			return nil, fmt.Errorf("error describing instances: %w", err)
+		}
+		for _, reservation := range res.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+		if res.NextToken == "" {
+			return instances, nil
+		}
+		nextToken = res.NextToken
+	}
+}
+
+// runInstances launches up to count instances from the pool's launch template, tagged with the pool's ID.
+// EC2 may launch fewer instances if there is insufficient capacity; the shortfall is made up next time the
+// pool is scaled up.
+func (p *EC2Provisioner) runInstances(ctx context.Context, config *models.EC2ProvisionerConfig, poolID models.RunnerPoolID, count int) error {
+	params := url.Values{}
+	params.Set("Action", "RunInstances")
+	params.Set("LaunchTemplate.LaunchTemplateId", config.LaunchTemplateID)
+	if config.LaunchTemplateVersion != "" {
+		params.Set("LaunchTemplate.Version", config.LaunchTemplateVersion)
+	}
+	params.Set("MinCount", "1")
+	params.Set("MaxCount", strconv.Itoa(count))
+	params.Set("TagSpecification.1.ResourceType", "instance")
+	params.Set("TagSpecification.1.Tag.1.Key", EC2PoolTagKey)
+	params.Set("TagSpecification.1.Tag.1.Value", poolID.String())
+	err := p.call(ctx, config.Region, params, nil)
+	if err != nil {
+		return fmt.Errorf("error running instances: %w", err)
+	}
+	return nil
+}
+
+// terminateInstances terminates the specified instances.
+func (p *EC2Provisioner) terminateInstances(ctx context.Context, region string, instanceIDs []string) error {
+	params := url.Values{}
+	params.Set("Action", "TerminateInstances")
+	for i, instanceID := range instanceIDs {
+		params.Set(fmt.Sprintf("InstanceId.%d", i+1), instanceID)
+	}
+	err := p.call(ctx, region, params, nil)
+	if err != nil {
+		return fmt.Errorf("error terminating instances: %w", err)
+	}
+	return nil
+}
+
+// call makes a signed request to the EC2 Query API in the specified region, and decodes the XML response
+// into result if result is not nil.
+func (p *EC2Provisioner) call(ctx context.Context, region string, params url.Values, result interface{}) error {
+	creds, err := p.getCredentials()
+	if err != nil {
+		return err
+	}
+	params.Set("Version", ec2APIVersion)
+	body := []byte(params.Encode())
+	endpoint := fmt.Sprintf("https://ec2.%s.amazonaws.com/", region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	_, err = v4.NewSigner(creds).Sign(req, bytes.NewReader(body), "ec2", region, time.Now())
+	if err != nil {
+		return fmt.Errorf("error signing request: %w", err)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		errRes := &ec2ErrorResponse{}
+		if xml.Unmarshal(resBody, errRes) == nil && len(errRes.Errors) > 0 {
+			return fmt.Errorf("error EC2 responded with status %d: %s: %s", res.StatusCode, errRes.Errors[0].Code, errRes.Errors[0].Message)
+		}
+		return fmt.Errorf("error EC2 responded with status %d: %s", res.StatusCode, strings.TrimSpace(string(resBody)))
+	}
+	if result != nil {
+		err = xml.Unmarshal(resBody, result)
+		if err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+	return nil
+}
+
+// getCredentials returns the server's AWS credentials, loading them from the environment the first time
+// they are needed.
+func (p *EC2Provisioner) getCredentials() (*credentials.Credentials, error) {
+	p.credsOnce.Do(func() {
+		sess, err := session.NewSession()
+		if err != nil {
+			p.credsErr = fmt.Errorf("error creating AWS session: %w", err)
+			return
+		}
+		p.credentials = sess.Config.Credentials
+	})
+	return p.credentials, p.credsErr
+}
@@ -0,0 +1,87 @@
+package runner_pool
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+const kubernetesProvisionerTimeout = 30 * time.Second
+
+// KubernetesProvisioner runs a pool's runners as the pods of a Deployment, and scales the Deployment to the
+// number of runners required via the scale subresource. If the pool has a secret then it is used as a bearer
+// token to authenticate to the API server; the token needs permission to patch deployments/scale.
+type KubernetesProvisioner struct{}
+
+func NewKubernetesProvisioner() *KubernetesProvisioner {
+	return &KubernetesProvisioner{}
+}
+
+func (p *KubernetesProvisioner) Type() models.RunnerProvisionerType {
+	return models.RunnerProvisionerTypeKubernetes
+}
+
+// Scale sets the number of replicas of the pool's Deployment to the desired number of runners.
+func (p *KubernetesProvisioner) Scale(ctx context.Context, pool *models.RunnerPool, secret string, desired int) error {
+	config := pool.ProvisionerConfig.Kubernetes
+	if config == nil {
+		return fmt.Errorf("error kubernetes provisioner config is missing")
+	}
+	client, err := p.makeClient(config)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": desired},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling scale patch: %w", err)
+	}
+	scaleURL := fmt.Sprintf("%s/apis/apps/v1/namespaces/%s/deployments/%s/scale",
+		strings.TrimSuffix(config.APIServerURL, "/"), url.PathEscape(config.Namespace), url.PathEscape(config.Deployment))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, scaleURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("Accept", "application/json")
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("error API server responded with status %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// makeClient returns an HTTP client for the API server, trusting the configured CA certificate if any.
+func (p *KubernetesProvisioner) makeClient(config *models.KubernetesProvisionerConfig) (*http.Client, error) {
+	client := &http.Client{Timeout: kubernetesProvisionerTimeout}
+	if config.CACertificate != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(config.CACertificate)) {
+			return nil, fmt.Errorf("error parsing CA certificate: no certificates found")
+		}
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,399 @@
+package runner_pool
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	DefaultReconcileInterval = 30 * time.Second
+	reconcileTimeout         = 5 * time.Minute
+)
+
+type RunnerPoolServiceConfig struct {
+	// ReconcileInterval is how often runner pools are scaled to match the number of jobs they could be running.
+	// Defaults to 30 seconds if zero.
+	ReconcileInterval time.Duration
+	// AllowHTTP permits webhook provisioners and Kubernetes API servers with plain http URLs to be configured.
+	// This should only be used for development.
+	AllowHTTP bool
+}
+
+// RunnerPoolService automatically starts and stops runners for each legal entity's runner pools. The number
+// of jobs each pool's runners could be running is periodically compared with the size of the pool, and the
+// pool's provisioner is asked to start or stop runners to match, within the pool's minimum and maximum size.
+// Provisioned runners are expected to register themselves with the server using the pool's labels.
+type RunnerPoolService struct {
+	db                *store.DB
+	poolStore         store.RunnerPoolStore
+	ownershipStore    store.OwnershipStore
+	jobStore          store.JobStore
+	encryptionService services.EncryptionService
+	leaderElection    services.LeaderElectionService
+	config            RunnerPoolServiceConfig
+	provisionersMu    sync.RWMutex
+	provisioners      map[models.RunnerProvisionerType]services.RunnerProvisioner
+	startStopMutex    sync.Mutex
+	exitChan          chan bool
+	wg                sync.WaitGroup
+	logger.Log
+}
+
+func NewRunnerPoolService(
+	db *store.DB,
+	poolStore store.RunnerPoolStore,
+	ownershipStore store.OwnershipStore,
+	jobStore store.JobStore,
+	encryptionService services.EncryptionService,
+	leaderElection services.LeaderElectionService,
+	config RunnerPoolServiceConfig,
+	logFactory logger.LogFactory,
+) *RunnerPoolService {
+	if config.ReconcileInterval == 0 {
+		config.ReconcileInterval = DefaultReconcileInterval
+	}
+	s := &RunnerPoolService{
+		db:                db,
+		poolStore:         poolStore,
+		ownershipStore:    ownershipStore,
+		jobStore:          jobStore,
+		encryptionService: encryptionService,
+		leaderElection:    leaderElection,
+		config:            config,
+		provisioners:      make(map[models.RunnerProvisionerType]services.RunnerProvisioner),
+		Log:               logFactory("RunnerPoolService"),
+	}
+	s.RegisterProvisioner(NewEC2Provisioner())
+	s.RegisterProvisioner(NewKubernetesProvisioner())
+	s.RegisterProvisioner(NewWebhookProvisioner())
+	return s
+}
+
+// RegisterProvisioner adds a provisioner that can start and stop runners, replacing any existing
+// provisioner of the same type.
+func (s *RunnerPoolService) RegisterProvisioner(provisioner services.RunnerProvisioner) {
+	s.provisionersMu.Lock()
+	defer s.provisionersMu.Unlock()
+	s.provisioners[provisioner.Type()] = provisioner
+}
+
+func (s *RunnerPoolService) getProvisioner(provisionerType models.RunnerProvisionerType) (services.RunnerProvisioner, bool) {
+	s.provisionersMu.RLock()
+	defer s.provisionersMu.RUnlock()
+	provisioner, ok := s.provisioners[provisionerType]
+	return provisioner, ok
+}
+
+// Create a new runner pool for a legal entity. The pool is scaled to its minimum size the next time
+// pools are reconciled.
+func (s *RunnerPoolService) Create(ctx context.Context, txOrNil *store.Tx, create dto.CreateRunnerPool) (*models.RunnerPool, error) {
+	var (
+		secretEncrypted  []byte
+		dataKeyEncrypted []byte
+		err              error
+	)
+	if create.SecretPlaintext != "" {
+		secretEncrypted, dataKeyEncrypted, err = s.encryptionService.Encrypt(ctx, []byte(create.SecretPlaintext))
+		if err != nil {
+			return nil, fmt.Errorf("error encrypting secret: %w", err)
+		}
+	}
+	now := models.NewTime(time.Now())
+	pool := models.NewRunnerPool(
+		now,
+		create.LegalEntityID,
+		create.Name,
+		create.Labels,
+		create.MinRunners,
+		create.MaxRunners,
+		create.ScaleDownIdleTimeout,
+		create.ProvisionerType,
+		create.ProvisionerConfig,
+		secretEncrypted,
+		dataKeyEncrypted)
+	err = s.validate(pool)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.poolStore.Create(ctx, tx, pool)
+		if err != nil {
+			return fmt.Errorf("error creating runner pool: %w", err)
+		}
+		ownership := models.NewOwnership(now, pool.LegalEntityID.ResourceID, pool.GetID())
+		err = s.ownershipStore.Create(ctx, tx, ownership)
+		if err != nil {
+			return fmt.Errorf("error creating ownership: %w", err)
+		}
+		s.Infof("Created runner pool %q for %q", pool.ID, pool.LegalEntityID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Read an existing runner pool, looking it up by ID.
+// Returns models.ErrNotFound if the runner pool does not exist.
+func (s *RunnerPoolService) Read(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID) (*models.RunnerPool, error) {
+	return s.poolStore.Read(ctx, txOrNil, id)
+}
+
+// Update an existing runner pool with optimistic locking, changing only the fields that are set in update.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (s *RunnerPoolService) Update(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID, update dto.UpdateRunnerPool) (*models.RunnerPool, error) {
+	pool, err := s.poolStore.Read(ctx, txOrNil, id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading runner pool: %w", err)
+	}
+	if update.Name != nil {
+		pool.Name = *update.Name
+	}
+	if update.Labels != nil {
+		pool.Labels = *update.Labels
+	}
+	if update.MinRunners != nil {
+		pool.MinRunners = *update.MinRunners
+	}
+	if update.MaxRunners != nil {
+		pool.MaxRunners = *update.MaxRunners
+	}
+	if update.ScaleDownIdleTimeout != nil {
+		pool.ScaleDownIdleTimeoutSeconds = int(update.ScaleDownIdleTimeout.Seconds())
+	}
+	if update.ProvisionerType != nil {
+		pool.ProvisionerType = *update.ProvisionerType
+	}
+	if update.ProvisionerConfig != nil {
+		pool.ProvisionerConfig = *update.ProvisionerConfig
+	}
+	if update.SecretPlaintext != nil {
+		if *update.SecretPlaintext == "" {
+			pool.SecretEncrypted = nil
+			pool.DataKeyEncrypted = nil
+		} else {
+			pool.SecretEncrypted, pool.DataKeyEncrypted, err = s.encryptionService.Encrypt(ctx, []byte(*update.SecretPlaintext))
+			if err != nil {
+				return nil, fmt.Errorf("error encrypting secret: %w", err)
+			}
+		}
+	}
+	pool.UpdatedAt = models.NewTime(time.Now())
+	pool.ETag = models.GetETag(pool, update.ETag)
+	err = s.validate(pool)
+	if err != nil {
+		return nil, err
+	}
+	err = s.poolStore.Update(ctx, txOrNil, pool)
+	if err != nil {
+		return nil, fmt.Errorf("error updating runner pool: %w", err)
+	}
+	return pool, nil
+}
+
+// Delete permanently and idempotently deletes a runner pool, identifying it by ID. Any runners the pool's
+// provisioner has started are left running.
+func (s *RunnerPoolService) Delete(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.poolStore.Delete(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error deleting runner pool: %w", err)
+		}
+		err = s.ownershipStore.Delete(ctx, tx, id.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListByLegalEntityID lists the runner pools belonging to a legal entity. Use cursor to page through results, if any.
+func (s *RunnerPoolService) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.RunnerPool, *models.Cursor, error) {
+	return s.poolStore.ListByLegalEntityID(ctx, txOrNil, legalEntityID, pagination)
+}
+
+// Start periodically reconciling runner pools in the background, whenever this server is the leader.
+func (s *RunnerPoolService) Start() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan != nil {
+		return
+	}
+	s.Trace("Starting runner pool reconcile loop...")
+	s.exitChan = make(chan bool)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.reconcileLoop()
+	}()
+}
+
+// Stop reconciling runner pools, waiting for any reconcile currently in progress to complete.
+func (s *RunnerPoolService) Stop() {
+	s.startStopMutex.Lock()
+	defer s.startStopMutex.Unlock()
+
+	if s.exitChan == nil {
+		return
+	}
+	close(s.exitChan)
+	s.wg.Wait()
+	s.exitChan = nil
+}
+
+func (s *RunnerPoolService) reconcileLoop() {
+	ticker := time.NewTicker(s.config.ReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.exitChan:
+			s.Trace("Exiting runner pool reconcile loop...")
+			return
+		case <-ticker.C:
+			if !s.leaderElection.IsLeader() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+			err := s.Reconcile(ctx, models.NewTime(time.Now()))
+			cancel()
+			if err != nil {
+				s.Errorf("Error reconciling runner pools: %v", err)
+			}
+		}
+	}
+}
+
+// Reconcile compares the number of jobs each runner pool could be running with the pool's current size,
+// and asks the pool's provisioner to start or stop runners as needed. Pools are scaled up straight away,
+// but are only scaled down once they have had more runners than they need for their idle timeout.
+func (s *RunnerPoolService) Reconcile(ctx context.Context, now models.Time) error {
+	var (
+		result     *multierror.Error
+		pagination = models.NewPagination(models.DefaultPaginationLimit, nil)
+	)
+	for moreResults := true; moreResults; {
+		pools, cursor, err := s.poolStore.ListAll(ctx, nil, pagination)
+		if err != nil {
+			return fmt.Errorf("error listing runner pools: %w", err)
+		}
+		for _, pool := range pools {
+			err := s.reconcilePool(ctx, pool, now)
+			if err != nil {
+				result = multierror.Append(result, fmt.Errorf("error reconciling runner pool %q: %w", pool.ID, err))
+			}
+		}
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	return result.ErrorOrNil()
+}
+
+// reconcilePool scales a single pool to match the number of jobs it could be running, and records the outcome
+// against the pool.
+func (s *RunnerPoolService) reconcilePool(ctx context.Context, pool *models.RunnerPool, now models.Time) error {
+	demand, err := s.jobStore.CountRunnableJobs(ctx, nil, pool.LegalEntityID, pool.Labels)
+	if err != nil {
+		return fmt.Errorf("error counting runnable jobs: %w", err)
+	}
+	target := pool.TargetRunners(demand)
+
+	scale := false
+	switch {
+	case target > pool.DesiredRunners:
+		scale = true
+	case target == pool.DesiredRunners:
+		pool.LastBusyAt = &now
+	default:
+		idleSince := pool.CreatedAt
+		if pool.LastBusyAt != nil {
+			idleSince = *pool.LastBusyAt
+		}
+		scale = now.Sub(idleSince.Time) >= pool.ScaleDownIdleTimeout()
+	}
+
+	if scale {
+		err = s.scale(ctx, pool, target)
+		if err != nil {
+			pool.LastError = err.Error()
+		} else {
+			s.Infof("Scaled runner pool %q from %d to %d runner(s) for %d job(s)", pool.ID, pool.DesiredRunners, target, demand)
+			pool.DesiredRunners = target
+			pool.LastBusyAt = &now
+			pool.LastScaledAt = &now
+			pool.LastError = ""
+		}
+	}
+
+	pool.UpdatedAt = models.NewTime(time.Now())
+	updateErr := s.poolStore.Update(ctx, nil, pool)
+	if updateErr != nil {
+		return fmt.Errorf("error updating runner pool: %w", updateErr)
+	}
+	return err
+}
+
+// scale asks the pool's provisioner to start or stop runners so the pool has the desired number of runners.
+func (s *RunnerPoolService) scale(ctx context.Context, pool *models.RunnerPool, desired int) error {
+	provisioner, ok := s.getProvisioner(pool.ProvisionerType)
+	if !ok {
+		return fmt.Errorf("error no provisioner registered for type %q", pool.ProvisionerType)
+	}
+	var secret string
+	if pool.SecretEncrypted != nil {
+		plaintext, err := s.encryptionService.Decrypt(ctx, pool.SecretEncrypted, pool.DataKeyEncrypted)
+		if err != nil {
+			return fmt.Errorf("error decrypting runner pool secret: %w", err)
+		}
+		secret = string(plaintext)
+	}
+	err := provisioner.Scale(ctx, pool, secret, desired)
+	if err != nil {
+		return fmt.Errorf("error scaling with %s provisioner: %w", pool.ProvisionerType, err)
+	}
+	return nil
+}
+
+// validate returns a validation error if the pool can't be saved, or if any URLs in the pool's provisioner
+// config can't be used.
+func (s *RunnerPoolService) validate(pool *models.RunnerPool) error {
+	err := pool.Validate()
+	if err != nil {
+		return gerror.NewErrValidationFailed(err.Error())
+	}
+	switch pool.ProvisionerType {
+	case models.RunnerProvisionerTypeKubernetes:
+		return s.validateURL(pool.ProvisionerConfig.Kubernetes.APIServerURL)
+	case models.RunnerProvisionerTypeWebhook:
+		return s.validateURL(pool.ProvisionerConfig.Webhook.URL)
+	}
+	return nil
+}
+
+// validateURL returns a validation error if the specified URL can't be used by a provisioner.
+func (s *RunnerPoolService) validateURL(provisionerURL string) error {
+	parsed, err := url.Parse(provisionerURL)
+	if err != nil || parsed.Host == "" {
+		return gerror.NewErrValidationFailed("Provisioner URL must be a valid https URL")
+	}
+	if parsed.Scheme != "https" && !(s.config.AllowHTTP && parsed.Scheme == "http") {
+		return gerror.NewErrValidationFailed("Provisioner URL must be a valid https URL")
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package runner_pool_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+type scaleCall struct {
+	poolName models.ResourceName
+	previous int
+	desired  int
+	secret   string
+}
+
+// testProvisioner records the scale requests it receives instead of starting or stopping any runners.
+type testProvisioner struct {
+	mu    sync.Mutex
+	calls []scaleCall
+	err   error
+}
+
+func (p *testProvisioner) Type() models.RunnerProvisionerType {
+	return models.RunnerProvisionerTypeWebhook
+}
+
+func (p *testProvisioner) Scale(ctx context.Context, pool *models.RunnerPool, secret string, desired int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.calls = append(p.calls, scaleCall{poolName: pool.Name, previous: pool.DesiredRunners, desired: desired, secret: secret})
+	return nil
+}
+
+// takeCalls returns the scale requests received since the last call to takeCalls.
+func (p *testProvisioner) takeCalls() []scaleCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := p.calls
+	p.calls = nil
+	return calls
+}
+
+func makeJobDefinition(name models.ResourceName, runsOn models.Labels, depends ...models.ResourceName) models.JobDefinition {
+	job := models.JobDefinition{
+		JobDefinitionData: models.JobDefinitionData{
+			Name:                    name,
+			Type:                    models.JobTypeDocker,
+			DockerImage:             "alpine",
+			DockerImagePullStrategy: models.DockerPullStrategyDefault,
+			StepExecution:           models.StepExecutionSequential,
+			RunsOn:                  runsOn,
+		},
+		Steps: []models.StepDefinition{{
+			StepDefinitionData: models.StepDefinitionData{
+				Name:     "run",
+				Commands: models.Commands{"echo 'hello world'"},
+			},
+		}},
+	}
+	for _, dependency := range depends {
+		job.Depends = append(job.Depends, models.NewJobDependency("", dependency))
+	}
+	return job
+}
+
+func TestRunnerPoolScaling(t *testing.T) {
+	ctx := context.Background()
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	provisioner := &testProvisioner{}
+	app.RunnerPoolService.RegisterProvisioner(provisioner)
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	webhookConfig := models.RunnerProvisionerConfig{Webhook: &models.WebhookProvisionerConfig{URL: "https://provisioner.example.com/scale"}}
+	newPool := func(name models.ResourceName, labels models.Labels, min int, max int) dto.CreateRunnerPool {
+		return dto.CreateRunnerPool{
+			LegalEntityID:        legalEntity.ID,
+			Name:                 name,
+			Labels:               labels,
+			MinRunners:           min,
+			MaxRunners:           max,
+			ScaleDownIdleTimeout: 10 * time.Minute,
+			ProvisionerType:      models.RunnerProvisionerTypeWebhook,
+			ProvisionerConfig:    webhookConfig,
+		}
+	}
+
+	// Pools must have a valid size and provisioner config
+	_, err = app.RunnerPoolService.Create(ctx, nil, newPool("backwards", nil, 3, 1))
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+	insecure := newPool("insecure", nil, 0, 1)
+	insecure.ProvisionerConfig = models.RunnerProvisionerConfig{Webhook: &models.WebhookProvisionerConfig{URL: "http://provisioner.example.com"}}
+	_, err = app.RunnerPoolService.Create(ctx, nil, insecure)
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+	unconfigured := newPool("unconfigured", nil, 0, 1)
+	unconfigured.ProvisionerType = models.RunnerProvisionerTypeKubernetes
+	_, err = app.RunnerPoolService.Create(ctx, nil, unconfigured)
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	gpuCreate := newPool("gpu", models.Labels{"gpu"}, 0, 3)
+	gpuCreate.SecretPlaintext = "provisioner-secret"
+	gpuPool, err := app.RunnerPoolService.Create(ctx, nil, gpuCreate)
+	require.NoError(t, err)
+	require.NotEqual(t, "provisioner-secret", string(gpuPool.SecretEncrypted))
+	_, err = app.RunnerPoolService.Create(ctx, nil, newPool("general", nil, 1, 2))
+	require.NoError(t, err)
+
+	// Only ready jobs whose labels the pool's runners have count towards a pool; jobs without labels
+	// only count towards pools without labels. The lint job is an exec job so that the GPU runner
+	// below (which only runs docker jobs) can't dequeue it.
+	lint := makeJobDefinition("lint", nil)
+	lint.Type = models.JobTypeExec
+	_, err = app.QueueService.EnqueueBuildFromBuildDefinition(ctx, nil, repo.ID, commit.ID,
+		&models.BuildDefinition{Jobs: []models.JobDefinition{
+			lint,
+			makeJobDefinition("train", models.Labels{"gpu"}),
+			makeJobDefinition("render", models.Labels{"gpu"}),
+			makeJobDefinition("deploy", models.Labels{"gpu"}, "lint"),
+			makeJobDefinition("huge", models.Labels{"gpu", "large"}),
+		}}, "refs/heads/main", nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []scaleCall{
+		{poolName: "gpu", previous: 0, desired: 2, secret: "provisioner-secret"},
+		{poolName: "general", previous: 0, desired: 1},
+	}, provisioner.takeCalls())
+
+	// Nothing changes while demand stays the same
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start.Add(time.Minute)))
+	require.NoError(t, err)
+	require.Empty(t, provisioner.takeCalls())
+
+	runJob := func(runner *models.Runner) *dto.RunnableJob {
+		job, err := app.QueueService.Dequeue(ctx, runner.ID)
+		require.NoError(t, err)
+		_, err = app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusRunning})
+		require.NoError(t, err)
+		_, err = app.QueueService.UpdateJobStatus(ctx, nil, job.ID, dto.UpdateJobStatus{Status: models.WorkflowStatusSucceeded})
+		require.NoError(t, err)
+		return job
+	}
+	gpuRunner := models.NewRunner(models.NewTime(time.Now()), "gpu-runner", legalEntity.ID, "1.2.3", "", "",
+		models.JobTypes{models.JobTypeDocker}, models.Labels{"gpu"}, true)
+	err = app.RunnerService.Create(ctx, nil, gpuRunner, nil)
+	require.NoError(t, err)
+	job := runJob(gpuRunner)
+	require.Contains(t, []models.ResourceName{"train", "render"}, job.Name)
+
+	// Pools are only scaled down once they have had spare runners for the idle timeout
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start.Add(2*time.Minute)))
+	require.NoError(t, err)
+	require.Empty(t, provisioner.takeCalls())
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start.Add(12*time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, []scaleCall{{poolName: "gpu", previous: 2, desired: 1, secret: "provisioner-secret"}}, provisioner.takeCalls())
+
+	// Pools are scaled up straight away when more jobs become ready, but never below their minimum size
+	generalRunner := server_test.CreateRunner(t, ctx, app, "general-runner", legalEntity.ID, nil)
+	job = runJob(generalRunner)
+	require.Equal(t, models.ResourceName("lint"), job.Name)
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start.Add(13*time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, []scaleCall{{poolName: "gpu", previous: 1, desired: 2, secret: "provisioner-secret"}}, provisioner.takeCalls())
+
+	// Failures are recorded against the pool and retried next time
+	minRunners := 3
+	_, err = app.RunnerPoolService.Update(ctx, nil, gpuPool.ID, dto.UpdateRunnerPool{MinRunners: &minRunners})
+	require.NoError(t, err)
+	provisioner.err = errors.New("out of capacity")
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start.Add(14*time.Minute)))
+	require.Error(t, err)
+	gpuPool, err = app.RunnerPoolService.Read(ctx, nil, gpuPool.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, gpuPool.DesiredRunners)
+	require.Contains(t, gpuPool.LastError, "out of capacity")
+	provisioner.err = nil
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start.Add(15*time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, []scaleCall{{poolName: "gpu", previous: 2, desired: 3, secret: "provisioner-secret"}}, provisioner.takeCalls())
+	gpuPool, err = app.RunnerPoolService.Read(ctx, nil, gpuPool.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, gpuPool.DesiredRunners)
+	require.Empty(t, gpuPool.LastError)
+	require.NotNil(t, gpuPool.LastScaledAt)
+
+	// Deleted pools are no longer scaled
+	err = app.RunnerPoolService.Delete(ctx, nil, gpuPool.ID)
+	require.NoError(t, err)
+	err = app.RunnerPoolService.Reconcile(ctx, models.NewTime(start.Add(time.Hour)))
+	require.NoError(t, err)
+	require.Empty(t, provisioner.takeCalls())
+	pools, _, err := app.RunnerPoolService.ListByLegalEntityID(ctx, nil, legalEntity.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, pools, 1)
+	require.Equal(t, models.ResourceName("general"), pools[0].Name)
+}
@@ -0,0 +1,92 @@
+package runner_pool
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services/outgoing_webhook"
+)
+
+const webhookProvisionerTimeout = 30 * time.Second
+
+// WebhookScaleRequest is the JSON document posted to a webhook provisioner's URL whenever the number of
+// runners a pool needs changes. The receiver is responsible for starting or stopping runners to match.
+type WebhookScaleRequest struct {
+	PoolID        models.RunnerPoolID  `json:"pool_id"`
+	PoolName      models.ResourceName  `json:"pool_name"`
+	LegalEntityID models.LegalEntityID `json:"legal_entity_id"`
+	Labels        models.Labels        `json:"labels"`
+	Timestamp     models.Time          `json:"timestamp"`
+	// PreviousRunners is the number of runners the pool was previously asked to run.
+	PreviousRunners int `json:"previous_runners"`
+	// DesiredRunners is the number of runners the pool should now be running.
+	DesiredRunners int `json:"desired_runners"`
+}
+
+// WebhookProvisioner asks an external system to start or stop runners by posting a WebhookScaleRequest
+// to the pool's configured URL. If the pool has a secret then requests are signed in the same way as
+// outgoing webhook payloads.
+type WebhookProvisioner struct {
+	client *http.Client
+}
+
+func NewWebhookProvisioner() *WebhookProvisioner {
+	return &WebhookProvisioner{
+		client: &http.Client{Timeout: webhookProvisionerTimeout},
+	}
+}
+
+func (p *WebhookProvisioner) Type() models.RunnerProvisionerType {
+	return models.RunnerProvisionerTypeWebhook
+}
+
+// Scale posts the desired number of runners to the pool's URL.
+func (p *WebhookProvisioner) Scale(ctx context.Context, pool *models.RunnerPool, secret string, desired int) error {
+	config := pool.ProvisionerConfig.Webhook
+	if config == nil {
+		return fmt.Errorf("error webhook provisioner config is missing")
+	}
+	body, err := json.Marshal(&WebhookScaleRequest{
+		PoolID:          pool.ID,
+		PoolName:        pool.Name,
+		LegalEntityID:   pool.LegalEntityID,
+		Labels:          pool.Labels,
+		Timestamp:       models.NewTime(time.Now()),
+		PreviousRunners: pool.DesiredRunners,
+		DesiredRunners:  desired,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling scale request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "BuildBeaver-RunnerPool")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set(outgoing_webhook.SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("error endpoint responded with status %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
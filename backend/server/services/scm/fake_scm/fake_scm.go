@@ -3,7 +3,6 @@ package fake_scm
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +13,7 @@ import (
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/queue/parser"
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
@@ -80,6 +80,9 @@ type fakeSCMRepo struct {
 	id           RepoID
 	name         models.ResourceName
 	sshPublicKey []byte
+	files        map[string][]byte   // file contents by path; the fake SCM doesn't track history so ref is ignored
+	changedFiles map[string][]string // paths of files changed by each commit, by commit SHA
+	refs         map[string]string   // commit SHA each git ref points to, by ref
 }
 
 // FakeSCMService is an implementation of the SCM interface designed for testing. It is loosely based on GitHub,
@@ -90,6 +93,7 @@ type FakeSCMService struct {
 	commitStore        store.CommitStore
 	legalEntityService services.LegalEntityService
 	state              *fakeSCMState
+	webhookHandler     func(ctx context.Context, delivery *models.WebhookDelivery) error
 	logger.Log
 }
 
@@ -223,6 +227,46 @@ func (s *FakeSCMService) CreateRepoForCompany(companyID CompanyID, repoName stri
 	return repoID, repoIDToExternalResourceID(repoID), nil
 }
 
+// SetRepoFile sets the contents of the file at the specified path within a repo. The same contents will be
+// returned for every ref.
+func (s *FakeSCMService) SetRepoFile(repoID RepoID, path string, contents []byte) error {
+	repo, err := s.findRepo(repoID)
+	if err != nil {
+		return err
+	}
+	if repo.files == nil {
+		repo.files = make(map[string][]byte)
+	}
+	repo.files[path] = contents
+	return nil
+}
+
+// SetChangedFiles sets the paths of the files changed by the commit with the specified SHA within a repo.
+func (s *FakeSCMService) SetChangedFiles(repoID RepoID, sha string, paths []string) error {
+	repo, err := s.findRepo(repoID)
+	if err != nil {
+		return err
+	}
+	if repo.changedFiles == nil {
+		repo.changedFiles = make(map[string][]string)
+	}
+	repo.changedFiles[sha] = paths
+	return nil
+}
+
+// SetRef sets the commit SHA that the specified git ref points to within a repo.
+func (s *FakeSCMService) SetRef(repoID RepoID, ref string, sha string) error {
+	repo, err := s.findRepo(repoID)
+	if err != nil {
+		return err
+	}
+	if repo.refs == nil {
+		repo.refs = make(map[string]string)
+	}
+	repo.refs[ref] = sha
+	return nil
+}
+
 // DeleteRepo delete the repo with the specified ID, from whichever user or company it was created under.
 // This method is idempotent so it doesn't need to return an error.
 func (s *FakeSCMService) DeleteRepo(repoID RepoID) {
@@ -522,11 +566,18 @@ func (s *FakeSCMService) Name() models.SystemName {
 	return FakeSCMName
 }
 
-// WebhookHandler returns the http handler func that should be invoked when
-// the fake SCM service receives a webhook, or an error if the service does not
-// support webhooks.
-func (s *FakeSCMService) WebhookHandler() (http.HandlerFunc, error) {
-	return nil, fmt.Errorf("FakeSCMService does not support Webhooks")
+// HandleWebhook processes a webhook received by the fake SCM service, by passing it to the handler set
+// by SetWebhookHandler. Returns an error if no handler has been set.
+func (s *FakeSCMService) HandleWebhook(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if s.webhookHandler == nil {
+		return fmt.Errorf("FakeSCMService does not support Webhooks")
+	}
+	return s.webhookHandler(ctx, delivery)
+}
+
+// SetWebhookHandler sets a function to process webhooks received by the fake SCM service, for testing.
+func (s *FakeSCMService) SetWebhookHandler(handler func(ctx context.Context, delivery *models.WebhookDelivery) error) {
+	s.webhookHandler = handler
 }
 
 // NotifyBuildUpdated is called when the status of a build is updated.
@@ -543,6 +594,20 @@ func (s *FakeSCMService) NotifyBuildUpdated(ctx context.Context, txOrNil *store.
 	return nil // This is a no-op
 }
 
+// NotifyCustomStatusUpdated is called when a custom status is published to a build.
+// Allows the SCM to publish the status alongside (but separately from) the overall status of the build.
+func (s *FakeSCMService) NotifyCustomStatusUpdated(ctx context.Context, txOrNil *store.Tx, build *models.Build, repo *models.Repo, customStatus *models.CustomStatus) error {
+	// Verify the repo is actually on the fake SCM
+	fakeSCMRepo, err := s.findRepoByExternalID(repo.ExternalID)
+	if err != nil {
+		return err
+	}
+
+	s.Tracef("Received notification that custom status %q is now %q for build %q for repo %d, name %q (database repo %q ID %d)",
+		customStatus.Name, customStatus.State, build.Name, fakeSCMRepo.id, fakeSCMRepo.name, repo.Name, repo.ID)
+	return nil // This is a no-op
+}
+
 // EnableRepo is called when a repo is enabled within BuildBeaver - this is the SCM's opportunity
 // to do any setup required to close the loop and make this work. Public key identifies the key that
 // BuildBeaver will use when cloning the repo.
@@ -588,6 +653,78 @@ func (s *FakeSCMService) BuildRepoLatestCommit(ctx context.Context, repo *models
 	return nil // This is a no-op
 }
 
+// GetFileContents returns the contents of the file at the specified path within a repo, as set by SetRepoFile.
+// The ref is ignored. Returns a not found error if the file does not exist.
+func (s *FakeSCMService) GetFileContents(ctx context.Context, repo *models.Repo, ref string, path string) ([]byte, error) {
+	fakeSCMRepo, err := s.findRepoByExternalID(repo.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+	contents, ok := fakeSCMRepo.files[path]
+	if !ok {
+		return nil, gerror.NewErrNotFound(fmt.Sprintf("File %q not found in repo %q", path, fakeSCMRepo.name))
+	}
+	return contents, nil
+}
+
+// GetChangedFiles returns the paths of the files changed by the commit with the specified SHA. Commits that have
+// not had their changed files set are treated as changing no files.
+func (s *FakeSCMService) GetChangedFiles(ctx context.Context, repo *models.Repo, sha string) ([]string, error) {
+	fakeSCMRepo, err := s.findRepoByExternalID(repo.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), fakeSCMRepo.changedFiles[sha]...), nil
+}
+
+// FetchCommit returns the commit that the specified git ref points to, as set by SetRef, or the commit with
+// the specified SHA if a ref points to it. The commit is recorded in the database if it isn't already there,
+// with its build config taken from the build config file set by SetRepoFile (if any).
+// Returns a not found error if the ref or commit does not exist.
+func (s *FakeSCMService) FetchCommit(ctx context.Context, repo *models.Repo, ref string) (*models.Commit, error) {
+	fakeSCMRepo, err := s.findRepoByExternalID(repo.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+	sha, ok := fakeSCMRepo.refs[ref]
+	if !ok {
+		for _, refSHA := range fakeSCMRepo.refs {
+			if refSHA == ref {
+				sha, ok = refSHA, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, gerror.NewErrNotFound(fmt.Sprintf("Commit for ref %q not found in repo %q", ref, fakeSCMRepo.name))
+	}
+	commit, err := s.commitStore.ReadBySHA(ctx, nil, repo.ID, sha)
+	if err == nil {
+		return commit, nil
+	}
+	if !gerror.IsNotFound(err) {
+		return nil, fmt.Errorf("error reading commit from database: %w", err)
+	}
+	var (
+		config     []byte
+		configType = models.ConfigTypeNoConfig
+	)
+	for _, names := range [][]string{parser.YAMLBuildConfigFileNames, parser.JSONBuildConfigFileNames, parser.JSONNETBuildConfigFileNames} {
+		for _, name := range names {
+			if contents, ok := fakeSCMRepo.files[name]; ok && config == nil {
+				config, configType = contents, parser.ConfigTypeForFileName(name)
+			}
+		}
+	}
+	commit = models.NewCommit(models.NewTime(time.Now()), repo.ID, config, configType, sha,
+		"Commit from fake SCM", models.LegalEntityID{}, "", "", models.LegalEntityID{}, "", "", "")
+	_, _, err = s.commitStore.Upsert(ctx, nil, commit)
+	if err != nil {
+		return nil, fmt.Errorf("error upserting commit: %w", err)
+	}
+	return commit, nil
+}
+
 // GetUserLegalEntityData returns an SCM legal entity representing the user currently authenticated with auth.
 func (s *FakeSCMService) GetUserLegalEntityData(ctx context.Context, auth models.SCMAuth) (*models.LegalEntityData, error) {
 	user, err := s.authenticateUser(auth)
@@ -862,6 +999,7 @@ func findOperationsForFakeSCMRepoPermission(permission fakeSCMRepoPermission) []
 			models.SecretCreateOperation,
 			models.BuildReadOperation,
 			models.BuildUpdateOperation,
+			models.BuildPrioritizeOperation,
 			models.ArtifactCreateOperation,
 			models.ArtifactReadOperation,
 			models.ArtifactUpdateOperation,
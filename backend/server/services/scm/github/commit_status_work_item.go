@@ -51,8 +51,9 @@ func NewCommitStatusWorkItem(
 		panic("Unable to marshal SendCommitStatusWorkItemData object to JSON")
 	}
 
-	// Concurrency key is the combination of 'github-commit', repo and SHA
-	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("github-commit/%s/%s", repo, sha))
+	// Concurrency key is the combination of 'github-commit', repo, SHA and status context, so that updates
+	// to the same status are sent in order and can be coalesced, while different statuses are independent
+	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("github-commit/%s/%s/%s", repo, sha, contextText))
 
 	return models.NewWorkItem(CommitStatusWorkItem, string(dataJson), concurrencyKey, models.NewTime(time.Now()))
 }
@@ -30,6 +30,13 @@ const (
 	groupNamePrefixForGitHubTeam = "github-team-"
 	maxCharsInCommitStatus       = 140
 	commitStatusUpdateTimeout    = 30 * time.Second
+	// commitStatusCoalesceDelay is how long to wait before sending a commit status to GitHub, so that further
+	// updates to the same status made in the meantime are sent as a single update.
+	commitStatusCoalesceDelay = 5 * time.Second
+	// rateLimitResetMargin is added to the time GitHub says a rate limit will reset, to allow for clock skew.
+	rateLimitResetMargin = 5 * time.Second
+	// defaultAbuseRateLimitWait is how long to wait after hitting a secondary rate limit if GitHub doesn't say.
+	defaultAbuseRateLimitWait    = time.Minute
 	DefaultCommitStatusTargetURL = "https://app.changeme.com"
 )
 
@@ -42,6 +49,9 @@ type AppConfig struct {
 	// CommitStatusTargetURL is a string that can be passed to GitHub as the 'target URL' when updating
 	// the status of a commit.
 	CommitStatusTargetURL string
+	// WebhookSecret is the secret configured for webhooks in the GitHub App settings, used to verify the
+	// signature of incoming webhooks. If empty then signatures are not verified.
+	WebhookSecret string
 }
 
 type GitHubSCMAuthentication struct {
@@ -142,17 +152,19 @@ func GetLegalEntityMetadata(legalEntity *models.LegalEntity) (*LegalEntityMetada
 }
 
 type GitHubService struct {
-	db                 *store.DB
-	repoStore          store.RepoStore
-	commitStore        store.CommitStore
-	buildStore         store.BuildStore
-	pullRequestService services.PullRequestService
-	legalEntityService services.LegalEntityService
-	queueService       services.QueueService
-	workQueueService   services.WorkQueueService
-	groupService       services.GroupService
-	syncService        services.SyncService
-	config             AppConfig
+	db                   *store.DB
+	repoStore            store.RepoStore
+	commitStore          store.CommitStore
+	buildStore           store.BuildStore
+	pullRequestService   services.PullRequestService
+	buildRuleSetService  services.BuildRuleSetService
+	legalEntityService   services.LegalEntityService
+	authorizationService services.AuthorizationService
+	queueService         services.QueueService
+	workQueueService     services.WorkQueueService
+	groupService         services.GroupService
+	syncService          services.SyncService
+	config               AppConfig
 	logger.Log
 }
 
@@ -162,7 +174,9 @@ func NewGitHubService(
 	commitStore store.CommitStore,
 	buildStore store.BuildStore,
 	pullRequestService services.PullRequestService,
+	buildRuleSetService services.BuildRuleSetService,
 	legalEntityService services.LegalEntityService,
+	authorizationService services.AuthorizationService,
 	queueService services.QueueService,
 	workQueueService services.WorkQueueService,
 	groupService services.GroupService,
@@ -171,18 +185,20 @@ func NewGitHubService(
 	logFactory logger.LogFactory,
 ) *GitHubService {
 	s := &GitHubService{
-		db:                 db,
-		repoStore:          repoStore,
-		commitStore:        commitStore,
-		buildStore:         buildStore,
-		pullRequestService: pullRequestService,
-		legalEntityService: legalEntityService,
-		queueService:       queueService,
-		workQueueService:   workQueueService,
-		groupService:       groupService,
-		syncService:        syncService,
-		config:             config,
-		Log:                logFactory("GitHubService"),
+		db:                   db,
+		repoStore:            repoStore,
+		commitStore:          commitStore,
+		buildStore:           buildStore,
+		pullRequestService:   pullRequestService,
+		buildRuleSetService:  buildRuleSetService,
+		legalEntityService:   legalEntityService,
+		authorizationService: authorizationService,
+		queueService:         queueService,
+		workQueueService:     workQueueService,
+		groupService:         groupService,
+		syncService:          syncService,
+		config:               config,
+		Log:                  logFactory("GitHubService"),
 	}
 
 	// Register the code to process work items for sending Commit Status updates to GitHub
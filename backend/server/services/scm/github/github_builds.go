@@ -3,6 +3,8 @@ package github
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v28/github"
@@ -54,7 +56,7 @@ func (s *GitHubService) BuildRepoLatestCommit(
 	}
 
 	// Find the commit at the head of this ref, and build it if necessary
-	err = s.buildLatestCommit(ctx, ghClient, repo, ghRepoName, ghOwner, ref)
+	err = s.buildLatestCommit(ctx, ghClient, repo, ghRepoName, ghOwner, ref, nil)
 	if err != nil {
 		return err
 	}
@@ -68,6 +70,7 @@ func (s *GitHubService) BuildRepoLatestCommit(
 // If all completed builds for this commit failed then a new build will be queued.
 // Older builds for previous commits for this ref may be cancelled or elided from the queue, since they
 // are out of date.
+// If opts is not nil then the build was explicitly requested with those options, so a new build is always queued.
 // The caller should not already have a DB transaction open since this function makes calls to GitHub,
 // and also uses transactions and row locking to ensure only one build will be queued for a commit.
 func (s *GitHubService) buildLatestCommit(
@@ -77,6 +80,7 @@ func (s *GitHubService) buildLatestCommit(
 	ghRepoName string,
 	ghOwner string,
 	ref string,
+	opts *models.BuildOptions,
 ) error {
 	// Ask GitHub which commit is the head of the ref
 	ghReference, _, err := ghClient.Git.GetRef(ctx, ghOwner, ghRepoName, ref)
@@ -131,7 +135,7 @@ func (s *GitHubService) buildLatestCommit(
 	if err != nil {
 		return errors.Wrap(err, "error searching database for existing builds for a commit")
 	}
-	if len(existingBuilds) > 0 {
+	if len(existingBuilds) > 0 && opts == nil {
 		s.Tracef("buildLatestCommit commit %q already has a build with status %q - will not queue another build",
 			existingBuilds[0].Build.CommitID, existingBuilds[0].Build.Status)
 		return nil
@@ -159,13 +163,13 @@ func (s *GitHubService) buildLatestCommit(
 		if err != nil {
 			return errors.Wrap(err, "error searching database for existing builds for a commit")
 		}
-		if len(existingBuilds) > 0 {
+		if len(existingBuilds) > 0 && opts == nil {
 			s.Tracef("Found another build for commit %q before we could add it; not queuing a second build", headCommit.ID)
 			return nil
 		}
 
 		// Queue the build inside the same transaction
-		_, err := s.queueService.EnqueueBuildFromCommit(ctx, tx, headCommit, ref, nil)
+		_, err := s.queueService.EnqueueBuildFromCommit(ctx, tx, headCommit, ref, opts)
 		if err != nil {
 			// Config is valid but some other error happened; return error so the caller can potentially retry
 			return errors.Wrap(err, "error queueing build for PR commit")
@@ -381,6 +385,83 @@ loop:
 	return config, configType, nil
 }
 
+// GetFileContents returns the contents of the file at the specified path within a repo, as of the specified
+// git ref (branch, tag or commit SHA). Returns a not found error if the ref or file does not exist.
+func (s *GitHubService) GetFileContents(ctx context.Context, repo *models.Repo, ref string, path string) ([]byte, error) {
+	repoMetadata, err := GetRepoMetadata(repo)
+	if err != nil {
+		return nil, err
+	}
+	ghClient, err := s.makeGitHubAppInstallationClient(repoMetadata.InstallationID)
+	if err != nil {
+		return nil, fmt.Errorf("error making github client: %w", err)
+	}
+	opts := &github.RepositoryContentGetOptions{Ref: ref}
+	fileContent, _, res, err := ghClient.Repositories.GetContents(ctx, repoMetadata.RepoOwner, repoMetadata.RepoName, path, opts)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil, gerror.NewErrNotFound(fmt.Sprintf("File %q not found in repo %q at ref %q", path, repo.Name, ref))
+		}
+		return nil, fmt.Errorf("error getting contents of file %q: %w", path, err)
+	}
+	if fileContent == nil {
+		return nil, gerror.NewErrNotFound(fmt.Sprintf("Path %q in repo %q at ref %q is not a file", path, repo.Name, ref))
+	}
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding contents of file %q: %w", path, err)
+	}
+	return []byte(content), nil
+}
+
+// GetChangedFiles returns the paths of the files changed by the commit with the specified SHA, relative to
+// the commit's first parent. Returns a not found error if the commit does not exist.
+func (s *GitHubService) GetChangedFiles(ctx context.Context, repo *models.Repo, sha string) ([]string, error) {
+	repoMetadata, err := GetRepoMetadata(repo)
+	if err != nil {
+		return nil, err
+	}
+	ghClient, err := s.makeGitHubAppInstallationClient(repoMetadata.InstallationID)
+	if err != nil {
+		return nil, fmt.Errorf("error making github client: %w", err)
+	}
+	ghCommit, res, err := ghClient.Repositories.GetCommit(ctx, repoMetadata.RepoOwner, repoMetadata.RepoName, sha)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil, gerror.NewErrNotFound(fmt.Sprintf("Commit %q not found in repo %q", sha, repo.Name))
+		}
+		return nil, fmt.Errorf("error getting commit %q: %w", sha, err)
+	}
+	paths := make([]string, 0, len(ghCommit.Files))
+	for _, file := range ghCommit.Files {
+		paths = append(paths, file.GetFilename())
+	}
+	return paths, nil
+}
+
+// FetchCommit reads the commit that the specified git ref (a ref such as refs/heads/main, or a commit SHA)
+// points to from GitHub, and records it in the database along with its build config if it isn't already there.
+// Returns a not found error if the ref or commit does not exist.
+func (s *GitHubService) FetchCommit(ctx context.Context, repo *models.Repo, ref string) (*models.Commit, error) {
+	repoMetadata, err := GetRepoMetadata(repo)
+	if err != nil {
+		return nil, err
+	}
+	ghClient, err := s.makeGitHubAppInstallationClient(repoMetadata.InstallationID)
+	if err != nil {
+		return nil, fmt.Errorf("error making github client: %w", err)
+	}
+	// GitHub accepts SHAs, and refs without the leading "refs/" (e.g. heads/main)
+	ghCommit, res, err := ghClient.Repositories.GetCommit(ctx, repoMetadata.RepoOwner, repoMetadata.RepoName, strings.TrimPrefix(ref, "refs/"))
+	if err != nil {
+		if res != nil && (res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusUnprocessableEntity) {
+			return nil, gerror.NewErrNotFound(fmt.Sprintf("Commit for ref %q not found in repo %q", ref, repo.Name))
+		}
+		return nil, fmt.Errorf("error getting commit for ref %q: %w", ref, err)
+	}
+	return s.upsertCommit(ctx, ghClient, ghCommit, repo, repoMetadata.RepoName, repoMetadata.RepoOwner, true)
+}
+
 // findOrCreateGithubUser ensures that we have a Legal Entity in our database for the supplied GitHub user.
 // If the user already exists, no action will be taken and no details will be updated.
 // In particular any existing GitHub external metadata, including the installation ID, will not be overwritten.
@@ -3,14 +3,17 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v28/github"
 
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/common/util"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
 	"github.com/buildbeaver/buildbeaver/server/store"
 )
 
@@ -56,7 +59,7 @@ func (s *GitHubService) setGitHubCommitStatusForBuild(ctx context.Context, txOrN
 		return err
 	}
 
-	err = s.setGitHubCommitStatus(ctx, txOrNil, installationID, ghOwner, ghRepoName, commit.SHA, gitHubState, targetURL, description)
+	err = s.setGitHubCommitStatus(ctx, txOrNil, installationID, ghOwner, ghRepoName, commit.SHA, gitHubState, targetURL, description, gitHubStatusContextText)
 	if err != nil {
 		return err
 	}
@@ -64,6 +67,60 @@ func (s *GitHubService) setGitHubCommitStatusForBuild(ctx context.Context, txOrN
 	return nil
 }
 
+// NotifyCustomStatusUpdated is called when a custom status is published to a build.
+// Allows the SCM to publish the status alongside (but separately from) the overall status of the build.
+func (s *GitHubService) NotifyCustomStatusUpdated(ctx context.Context, txOrNil *store.Tx, build *models.Build, repo *models.Repo, customStatus *models.CustomStatus) error {
+	s.Tracef("Received notification that custom status %q has been updated for build %q for repo %q", customStatus.Name, build.Name, repo.Name)
+	return s.setGitHubCommitStatusForCustomStatus(ctx, txOrNil, build, repo, customStatus)
+}
+
+// setGitHubCommitStatusForCustomStatus queues a work item to update GitHub with a status for the commit for the
+// specified build, to reflect a custom status published to the build. Each custom status is given its own
+// context on GitHub so that it can be used independently in branch protection rules.
+// It's OK to call this function inside a DB transaction since GitHub will not actually be contacted directly.
+func (s *GitHubService) setGitHubCommitStatusForCustomStatus(ctx context.Context, txOrNil *store.Tx, build *models.Build, repo *models.Repo, customStatus *models.CustomStatus) error {
+	repoMetadata, err := GetRepoMetadata(repo)
+	if err != nil {
+		return err
+	}
+
+	commit, err := s.commitStore.Read(ctx, txOrNil, build.CommitID)
+	if err != nil {
+		return fmt.Errorf("error reading commit for build: %w", err)
+	}
+
+	// Link to the build unless the status supplies its own URL
+	targetURL := customStatus.TargetURL
+	if targetURL == "" {
+		repoOwner, err := s.legalEntityService.Read(ctx, txOrNil, repo.LegalEntityID)
+		if err != nil {
+			return fmt.Errorf("error repo owner legal entity for build: %w", err)
+		}
+		targetURL, err = s.makeWebUIBuildURL(repoOwner, repo, build)
+		if err != nil {
+			return err
+		}
+	}
+	description := customStatus.Description
+	if description == "" {
+		description = fmt.Sprintf("%s: %s", customStatus.Name, customStatus.State)
+	}
+	contextText := fmt.Sprintf("%s/%s", gitHubStatusContextText, customStatus.Name)
+
+	return s.setGitHubCommitStatus(
+		ctx,
+		txOrNil,
+		repoMetadata.InstallationID,
+		repoMetadata.RepoOwner,
+		repoMetadata.RepoName,
+		commit.SHA,
+		customStatus.State.ToGitHubState(),
+		targetURL,
+		description,
+		contextText,
+	)
+}
+
 func (s *GitHubService) makeWebUIBuildURL(repoOwner *models.LegalEntity, repo *models.Repo, build *models.Build) (string, error) {
 	var orgsOrUsers string
 	switch repoOwner.Type {
@@ -92,6 +149,7 @@ func (s *GitHubService) makeWebUIBuildURL(repoOwner *models.LegalEntity, repo *m
 // setGitHubCommitStatus queues a Work Item to update the GitHub Status for a commit.
 // installationID is the GitHub installation ID for the BuildBeaver GitHub app.
 // owner, repo and sha are the GitHub repo owner name, GitHub repo name and GitHub SHA for the build.
+// contextText identifies the status on GitHub; a commit has one status per distinct context.
 func (s *GitHubService) setGitHubCommitStatus(
 	ctx context.Context,
 	txOrNil *store.Tx,
@@ -100,19 +158,22 @@ func (s *GitHubService) setGitHubCommitStatus(
 	gitHubState string,
 	targetURL string,
 	statusDescription string,
+	contextText string,
 ) error {
 	// Ensure description is short enough, or it will be rejected by GitHub
 	shortDescription := util.TruncateStringToMaxLength(statusDescription, maxCharsInCommitStatus)
-	s.Tracef("Queuing work item to set GitHub Status for repo %s, commit %s to state %q, description %q",
-		repo, sha, gitHubState, shortDescription)
+	s.Tracef("Queuing work item to set GitHub Status %q for repo %s, commit %s to state %q, description %q",
+		contextText, repo, sha, gitHubState, shortDescription)
 
 	// Add a work item to the queue to send the status to GitHub
 	workItem := NewCommitStatusWorkItem(
 		installationID,
 		owner, repo, sha,
-		gitHubState, targetURL, shortDescription, gitHubStatusContextText,
+		gitHubState, targetURL, shortDescription, contextText,
 	)
-	err := s.workQueueService.AddWorkItem(ctx, txOrNil, workItem)
+	// Statuses for the same commit and context supersede each other, so coalesce updates made in quick succession
+	// (e.g. as the jobs of a large build progress) to avoid hitting GitHub's rate limits
+	err := s.workQueueService.AddOrReplaceWorkItem(ctx, txOrNil, workItem, commitStatusCoalesceDelay)
 	if err != nil {
 		return fmt.Errorf("error queueing work item to set Commit Status on GitHub: %w", err)
 	}
@@ -150,23 +211,58 @@ func (s *GitHubService) ProcessCommitStatusWorkItem(ctx context.Context, workIte
 		status,
 	)
 	if err != nil {
+		err = fmt.Errorf("error setting GitHub Commit Status: %w", err)
+		// we hit a rate limit, which returns a 403. Retry once the rate limit resets rather than using
+		// up attempts in the meantime. See:
+		// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
+		if retryAfter := rateLimitRetryAfter(response, err); retryAfter != nil {
+			s.Infof("GitHub API rate limit hit for app installation (ID %d) when setting a commit status; retrying after %s",
+				workItemData.InstallationID, retryAfter)
+			return true, work_queue.NewRetryAfterError(*retryAfter, err)
+		}
 		canRetry := true
-		if response.StatusCode == 404 {
+		if response != nil && response.StatusCode == 404 {
 			canRetry = false // no point trying again if the commit isn't there
 		}
-		if response.StatusCode == 403 {
-			if response.Rate.Remaining == 0 {
-				// we hit the rate limit, which returns a 403. Retry again later. See:
-				// https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
-				s.Infof("GitHub API rate limit hit for app installation (ID %d) when setting a commit status", workItemData.InstallationID)
-				canRetry = true
-			} else {
-				canRetry = false // for a more general access denied error there's no point retrying
-			}
+		if response != nil && response.StatusCode == 403 {
+			canRetry = false // for a more general access denied error there's no point retrying
 		}
-		return canRetry, fmt.Errorf("error setting GitHub Commit Status: %w", err)
+		return canRetry, err
 	}
 
 	s.Tracef("GitHub Status set successfully by CommitStatusWorkItem")
 	return false, nil
 }
+
+// rateLimitRetryAfter returns the time after which a request that failed because of a GitHub rate limit
+// can be retried, or nil if the request did not fail because of a rate limit.
+func rateLimitRetryAfter(response *github.Response, err error) *time.Time {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitResetTime(rateLimitErr.Rate)
+	}
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		// The secondary rate limit says how long to wait, if anything
+		wait := defaultAbuseRateLimitWait
+		if abuseErr.RetryAfter != nil {
+			wait = *abuseErr.RetryAfter
+		}
+		retryAfter := time.Now().Add(wait)
+		return &retryAfter
+	}
+	if response != nil && response.StatusCode == 403 && response.Rate.Limit > 0 && response.Rate.Remaining == 0 {
+		return rateLimitResetTime(response.Rate)
+	}
+	return nil
+}
+
+// rateLimitResetTime returns the time at which a GitHub rate limit resets, allowing for clock skew
+// between ourselves and GitHub.
+func rateLimitResetTime(rate github.Rate) *time.Time {
+	retryAfter := rate.Reset.Time.Add(rateLimitResetMargin)
+	if retryAfter.Before(time.Now()) {
+		retryAfter = time.Now().Add(rateLimitResetMargin)
+	}
+	return &retryAfter
+}
@@ -443,6 +443,7 @@ func findOperationsForGitHubRepoPermissions(ghPermissions []string) []*models.Op
 				models.SecretCreateOperation,
 				models.BuildReadOperation,
 				models.BuildUpdateOperation,
+				models.BuildPrioritizeOperation,
 				models.ArtifactCreateOperation,
 				models.ArtifactReadOperation,
 				models.ArtifactUpdateOperation,
@@ -458,6 +459,7 @@ func findOperationsForGitHubRepoPermissions(ghPermissions []string) []*models.Op
 				models.SecretCreateOperation,
 				models.BuildReadOperation,
 				models.BuildUpdateOperation,
+				models.BuildPrioritizeOperation,
 				models.ArtifactReadOperation,
 				models.ArtifactDeleteOperation,
 			})
@@ -2,10 +2,12 @@ package github
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"strings"
 	"time"
 
@@ -16,51 +18,66 @@ import (
 	"github.com/buildbeaver/buildbeaver/common/models"
 )
 
-// WebhookHandler returns the http handler func that should be invoked when
-// the SCM service receives a webhook, or an error if the service does not
-// support webhooks.
-func (s *GitHubService) WebhookHandler() (http.HandlerFunc, error) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		eventType := r.Header.Get("X-GitHub-Event")
-		if eventType == "" {
-			s.Error("No event type header present")
-			w.WriteHeader(400)
-			return
-		}
-
-		// Require a signature; verification will happen inside handleWebhookEvent()
-		signature256 := r.Header.Get("X-Hub-Signature-256")
-		if eventType == "" {
-			s.Error("No SHA-256 signature header present")
-			w.WriteHeader(400)
-			return
-		}
+const (
+	// webhookEventHeader is the header containing the type of event a webhook payload describes.
+	webhookEventHeader = "X-GitHub-Event"
+	// webhookSignatureHeader is the header containing the hex-encoded HMAC-SHA256 signature of a webhook
+	// payload, computed using the GitHub App's webhook secret and prefixed by "sha256=".
+	webhookSignatureHeader = "X-Hub-Signature-256"
+)
 
-		event := &WebhookEvent{
-			EventType:    eventType,
-			Signature256: signature256,
-			Payload:      r.Body,
-		}
-		err := s.HandleWebhookEvent(r.Context(), event)
-		if err != nil {
-			s.Errorf("Error processing %s event: %s", eventType, err)
-			w.WriteHeader(500)
-			return
-		}
+// HandleWebhook processes a webhook received from GitHub. If a webhook secret is configured then the
+// signature of the delivery is verified first, and deliveries with an invalid signature are rejected.
+func (s *GitHubService) HandleWebhook(ctx context.Context, delivery *models.WebhookDelivery) error {
+	eventType := delivery.Headers.Get(webhookEventHeader)
+	if eventType == "" {
+		return gerror.NewErrValidationFailed("No event type header present")
+	}
+	delivery.EventType = eventType
+	signature256 := delivery.Headers.Get(webhookSignatureHeader)
+	delivery.SignatureStatus = s.verifyWebhookSignature(signature256, []byte(delivery.Payload))
+	switch delivery.SignatureStatus {
+	case models.WebhookSignatureStatusInvalid:
+		return gerror.NewErrValidationFailed("Webhook signature is missing or invalid")
+	case models.WebhookSignatureStatusUnverified:
+		s.Warnf("Received GitHub Webhook. WARNING: SIGNATURE WAS NOT VERIFIED as no webhook secret is configured: %s", eventType)
+	}
 
-		w.WriteHeader(200)
+	event := &WebhookEvent{
+		EventType:    eventType,
+		Signature256: signature256,
+		Payload:      strings.NewReader(delivery.Payload),
+	}
+	return s.HandleWebhookEvent(ctx, event)
+}
 
-	}, nil
+// verifyWebhookSignature checks signature256, from the 'X-Hub-Signature-256' header, is the signature of
+// payload computed using the configured webhook secret.
+func (s *GitHubService) verifyWebhookSignature(signature256 string, payload []byte) models.WebhookSignatureStatus {
+	if s.config.WebhookSecret == "" {
+		return models.WebhookSignatureStatusUnverified
+	}
+	if !strings.HasPrefix(signature256, "sha256=") {
+		return models.WebhookSignatureStatusInvalid
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(signature256, "sha256="))
+	if err != nil {
+		return models.WebhookSignatureStatusInvalid
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.WebhookSecret))
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return models.WebhookSignatureStatusInvalid
+	}
+	return models.WebhookSignatureStatusValid
 }
 
-// HandleWebhookEvent process an incoming GitHub Webhook event.
+// HandleWebhookEvent process an incoming GitHub Webhook event. The signature of the event must already have
+// been verified, if required.
 // eventType is the GitHub event name, from the 'X-GitHub-Event' header
 // hubSignature256 is the SHA-256 signature for the event, from the 'X-Hub-Signature-256' header
 // payload is a reader for the payload data of the event, which is the body of the HTTP request
 func (s *GitHubService) HandleWebhookEvent(ctx context.Context, event *WebhookEvent) error {
-	// TODO validate the signature on the event by using the configured webhook secret
-	s.Warnf("Received GitHub Webhook. WARNING: SIGNATURE WAS NOT VERIFIED: %s", event.EventType)
-
 	// Read the event payload
 	payload, err := ioutil.ReadAll(event.Payload)
 	if err != nil {
@@ -72,6 +89,8 @@ func (s *GitHubService) HandleWebhookEvent(ctx context.Context, event *WebhookEv
 		err = s.handlePushEvent(ctx, payload)
 	case "pull_request":
 		err = s.handlePullRequestEvent(ctx, payload)
+	case "issue_comment":
+		err = s.handleIssueCommentEvent(ctx, payload)
 	case "installation":
 		err = s.handleInstallationEvent(ctx, payload)
 	case "installation_target":
@@ -126,8 +145,16 @@ func (s *GitHubService) handlePushEvent(ctx context.Context, payload []byte) err
 	repoOwner := event.GetRepo().GetOwner().GetLogin()
 	ref := event.GetRef()
 
+	shouldBuild, err := s.buildRuleSetService.ShouldBuild(ctx, nil, repo, ref, false)
+	if err != nil {
+		return err
+	}
+	if !shouldBuild {
+		return nil
+	}
+
 	// Find the commit at the head of this ref, and build it if necessary
-	err = s.buildLatestCommit(ctx, ghClient, repo, repoName, repoOwner, ref)
+	err = s.buildLatestCommit(ctx, ghClient, repo, repoName, repoOwner, ref, nil)
 	if err != nil {
 		return err
 	}
@@ -174,26 +201,9 @@ func (s *GitHubService) handlePullRequestEvent(ctx context.Context, payload []by
 	}
 	baseRef := fixGithubBranchRef(event.GetPullRequest().GetBase().GetRef())
 
-	// Find the repo the head of this PR is from (often the same as the base repo)
-	ghHeadRepo := event.GetPullRequest().GetHead().GetRepo()
-	ghHeadRepoID := ghHeadRepo.GetID()
-
-	var refToBuild string
-	if ghHeadRepoID == ghBaseRepoID {
-		// PR is from another branch within the same repo - do a build of the branch head.
-		// This will often already be underway in response to the push Webhook notification.
-		refToBuild = fixGithubBranchRef(event.GetPullRequest().GetHead().GetRef())
-	} else {
-		// PR is from a different repo. Both base and head repo MUST be private.
-		// TODO: Re-evaluate this once we have security settings to allow public repo PRs in certain circumstances
-		s.Tracef("Cross-repo PR detected - checking both repos are private")
-		if !ghBaseRepo.GetPrivate() || !ghHeadRepo.GetPrivate() {
-			s.Infof("Ignoring Pull Request notification for cross-repo PR with public repo: base repo '%s' private=%v, head repo '%s' private=%v",
-				ghBaseRepo.GetFullName(), ghBaseRepo.GetPrivate(), ghHeadRepo.GetFullName(), ghHeadRepo.GetPrivate())
-			return nil
-		}
-		// use the special GitHub PR ref for the build, in the context of the base repo
-		refToBuild = makeGithubPullRequestRef(prNumber)
+	refToBuild, ok := s.getPullRequestRefToBuild(ghBaseRepo, event.GetPullRequest())
+	if !ok {
+		return nil
 	}
 
 	ghClient, err := s.makeGitHubAppInstallationClient(event.GetInstallation().GetID())
@@ -238,7 +248,14 @@ func (s *GitHubService) handlePullRequestEvent(ctx context.Context, payload []by
 
 	// Only attempt a build if the action indicates there has been a new commit
 	if event.GetAction() == "opened" || event.GetAction() == "synchronize" {
-		err = s.buildLatestCommit(ctx, ghClient, baseRepo, baseRepoName, baseRepoOwner, refToBuild)
+		shouldBuild, err := s.buildRuleSetService.ShouldBuild(ctx, nil, baseRepo, refToBuild, true)
+		if err != nil {
+			return err
+		}
+		if !shouldBuild {
+			return nil
+		}
+		err = s.buildLatestCommit(ctx, ghClient, baseRepo, baseRepoName, baseRepoOwner, refToBuild, nil)
 		if err != nil {
 			return err
 		}
@@ -247,6 +264,135 @@ func (s *GitHubService) handlePullRequestEvent(ctx context.Context, payload []by
 	return nil
 }
 
+// handleIssueCommentEvent queues a build of a pull request when someone comments on it with one of the comment
+// commands configured in the repo's build rule set, in the form:
+//
+//	/<command> [name=value ...]
+//
+// or '/bb <command> [name=value ...]'. Each name=value pair supplies the value of a parameter declared in the
+// build definition. The commenter must be authorized to perform the command's operation on the repo. A new build
+// is queued even if the head of the pull request has already been built, so that builds can be re-run with
+// other parameters.
+func (s *GitHubService) handleIssueCommentEvent(ctx context.Context, payload []byte) error {
+	event := &github.IssueCommentEvent{}
+	err := json.Unmarshal(payload, event)
+	if err != nil {
+		return errors.Wrap(err, "error unmarshalling event")
+	}
+	if event.GetAction() != "created" || !event.GetIssue().IsPullRequest() {
+		return nil
+	}
+	commandName, args, isCommand := models.ParseCommentCommand(event.GetComment().GetBody())
+	if !isCommand {
+		return nil
+	}
+	sender := event.GetSender().GetLogin()
+	s.Tracef("Received comment command %q, sender %q", commandName, sender)
+
+	ghRepo := event.GetRepo()
+	repoName := ghRepo.GetName()
+	repoOwner := ghRepo.GetOwner().GetLogin()
+	repo, repoEnabled, err := s.checkRepoEnabled(ctx, ghRepo.GetID())
+	if err != nil {
+		return err
+	}
+	if !repoEnabled {
+		s.Infof("Ignoring comment command for repo that is not enabled")
+		return nil
+	}
+
+	ruleSet, err := s.buildRuleSetService.Read(ctx, nil, repo.ID)
+	if err != nil {
+		return fmt.Errorf("error reading build rule set: %w", err)
+	}
+	command, ok := ruleSet.GetCommentCommands().Find(commandName)
+	if !ok {
+		s.Infof("Ignoring unknown comment command %q from %q", commandName, sender)
+		return nil
+	}
+	opts, err := command.MakeBuildOptions(args)
+	if err != nil {
+		s.Infof("Ignoring comment command %q with invalid arguments from %q: %v", commandName, sender, err)
+		return nil
+	}
+	authorized, err := s.isAuthorizedForCommentCommand(ctx, event.GetSender(), repo, command)
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		s.Infof("Ignoring comment command %q from user %q who is not authorized to run it", commandName, sender)
+		return nil
+	}
+
+	ghClient, err := s.makeGitHubAppInstallationClient(event.GetInstallation().GetID())
+	if err != nil {
+		return fmt.Errorf("error making github client: %w", err)
+	}
+	ghPullRequest, _, err := ghClient.PullRequests.Get(ctx, repoOwner, repoName, event.GetIssue().GetNumber())
+	if err != nil {
+		return fmt.Errorf("error reading pull request %d from GitHub: %w", event.GetIssue().GetNumber(), err)
+	}
+	refToBuild, ok := s.getPullRequestRefToBuild(ghRepo, ghPullRequest)
+	if !ok {
+		return nil
+	}
+
+	s.Infof("Queuing build of %s for repo %s requested by %q with comment command %q", refToBuild, repo.GetName(), sender, commandName)
+	return s.buildLatestCommit(ctx, ghClient, repo, repoName, repoOwner, refToBuild, opts)
+}
+
+// isAuthorizedForCommentCommand returns true if the GitHub user is authorized to run the comment command on
+// the repo. Users who don't have a legal entity in BuildBeaver are never authorized.
+func (s *GitHubService) isAuthorizedForCommentCommand(
+	ctx context.Context,
+	ghUser *github.User,
+	repo *models.Repo,
+	command *models.CommentCommand,
+) (bool, error) {
+	operation, err := command.GetOperation()
+	if err != nil {
+		return false, err
+	}
+	legalEntity, err := s.legalEntityService.ReadByExternalID(ctx, nil, GitHubIDToExternalResourceID(ghUser.GetID()))
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reading legal entity for user %q: %w", ghUser.GetLogin(), err)
+	}
+	identity, err := s.legalEntityService.ReadIdentity(ctx, nil, legalEntity.ID)
+	if err != nil {
+		return false, fmt.Errorf("error reading identity for legal entity %q: %w", legalEntity.ID, err)
+	}
+	authorized, err := s.authorizationService.IsAuthorized(ctx, identity.ID, operation, repo.ID.ResourceID)
+	if err != nil {
+		return false, fmt.Errorf("error checking authorization for user %q: %w", ghUser.GetLogin(), err)
+	}
+	return authorized, nil
+}
+
+// getPullRequestRefToBuild returns the ref to build for a pull request into the specified base repo.
+// Returns false if the pull request should not be built.
+func (s *GitHubService) getPullRequestRefToBuild(ghBaseRepo *github.Repository, ghPullRequest *github.PullRequest) (string, bool) {
+	// Find the repo the head of this PR is from (often the same as the base repo)
+	ghHeadRepo := ghPullRequest.GetHead().GetRepo()
+	if ghHeadRepo.GetID() == ghBaseRepo.GetID() {
+		// PR is from another branch within the same repo - do a build of the branch head.
+		// This will often already be underway in response to the push Webhook notification.
+		return fixGithubBranchRef(ghPullRequest.GetHead().GetRef()), true
+	}
+	// PR is from a different repo. Both base and head repo MUST be private.
+	// TODO: Re-evaluate this once we have security settings to allow public repo PRs in certain circumstances
+	s.Tracef("Cross-repo PR detected - checking both repos are private")
+	if !ghBaseRepo.GetPrivate() || !ghHeadRepo.GetPrivate() {
+		s.Infof("Ignoring Pull Request for cross-repo PR with public repo: base repo '%s' private=%v, head repo '%s' private=%v",
+			ghBaseRepo.GetFullName(), ghBaseRepo.GetPrivate(), ghHeadRepo.GetFullName(), ghHeadRepo.GetPrivate())
+		return "", false
+	}
+	// use the special GitHub PR ref for the build, in the context of the base repo
+	return makeGithubPullRequestRef(ghPullRequest.GetNumber()), true
+}
+
 // fixGithubBranchRef will attempt to fix an issue with Ref strings supplied by the GitHub API that
 // refer to a branch.
 // In particular, inside PR notifications a Ref is given as "branchname" rather than "refs/heads/branchname".
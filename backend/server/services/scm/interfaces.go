@@ -2,7 +2,6 @@ package scm
 
 import (
 	"context"
-	"net/http"
 
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/store"
@@ -17,10 +16,11 @@ type OrgToGroupsMap map[models.ExternalResourceID][]models.ResourceName
 type SCM interface {
 	// Name returns the unique name of the SCM.
 	Name() models.SystemName
-	// WebhookHandler returns the http handler func that should be invoked when
-	// the SCM service receives a webhook, or an error if the service does not
-	// support webhooks.
-	WebhookHandler() (http.HandlerFunc, error)
+	// HandleWebhook processes a webhook received from the SCM. Implementations should check the signature
+	// of the delivery, if the SCM signs webhooks, and record the result in delivery.SignatureStatus.
+	// The delivery may be one that was received earlier and is being replayed.
+	// Returns an error if the delivery could not be processed, or if the SCM does not support webhooks.
+	HandleWebhook(ctx context.Context, delivery *models.WebhookDelivery) error
 	// EnableRepo is called when a repo is enabled within the system - this is the SCM's opportunity to
 	// to do any setup required to close the loop and make this work. Public key identifies the key that
 	// the system will use when cloning the repo.
@@ -39,6 +39,19 @@ type SCM interface {
 	// NotifyBuildUpdated is called when the status of a build is updated.
 	// Allows the SCM to notify users or take other actions when a build has progressed or finished.
 	NotifyBuildUpdated(ctx context.Context, txOrNil *store.Tx, build *models.Build, repo *models.Repo) error
+	// NotifyCustomStatusUpdated is called when a custom status is published to a build.
+	// Allows the SCM to publish the status alongside (but separately from) the overall status of the build.
+	NotifyCustomStatusUpdated(ctx context.Context, txOrNil *store.Tx, build *models.Build, repo *models.Repo, customStatus *models.CustomStatus) error
+	// GetFileContents returns the contents of the file at the specified path within a repo, as of the specified
+	// git ref (branch, tag or commit SHA). Returns a not found error if the ref or file does not exist.
+	GetFileContents(ctx context.Context, repo *models.Repo, ref string, path string) ([]byte, error)
+	// GetChangedFiles returns the paths of the files changed by the commit with the specified SHA, relative to
+	// the commit's first parent. Returns a not found error if the commit does not exist.
+	GetChangedFiles(ctx context.Context, repo *models.Repo, sha string) ([]string, error)
+	// FetchCommit reads the commit that the specified git ref (a ref such as refs/heads/main, or a commit SHA)
+	// points to from the SCM, and records it in the database along with its build config if it isn't already
+	// there. Returns a not found error if the ref or commit does not exist.
+	FetchCommit(ctx context.Context, repo *models.Repo, ref string) (*models.Commit, error)
 	// GetUserLegalEntityData returns legal entity data representing the user currently authenticated with auth.
 	GetUserLegalEntityData(ctx context.Context, auth models.SCMAuth) (*models.LegalEntityData, error)
 	// IsLegalEntityRegisteredAsUser returns true if the specified Legal Entity is registered as a user of this
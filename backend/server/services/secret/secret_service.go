@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/dto"
@@ -17,29 +18,35 @@ import (
 )
 
 type SecretService struct {
-	db                *store.DB
-	secretStore       store.SecretStore
-	ownershipStore    store.OwnershipStore
-	resourceLinkStore store.ResourceLinkStore
-	encryptionService services.EncryptionService
+	db                 *store.DB
+	secretStore        store.SecretStore
+	secretVersionStore store.SecretVersionStore
+	buildStore         store.BuildStore
+	ownershipStore     store.OwnershipStore
+	resourceLinkStore  store.ResourceLinkStore
+	encryptionService  services.EncryptionService
 	logger.Log
 }
 
 func NewSecretService(
 	db *store.DB,
 	secretStore store.SecretStore,
+	secretVersionStore store.SecretVersionStore,
+	buildStore store.BuildStore,
 	ownershipStore store.OwnershipStore,
 	resourceLinkStore store.ResourceLinkStore,
 	encryptionService services.EncryptionService,
 	logFactory logger.LogFactory) *SecretService {
 
 	return &SecretService{
-		db:                db,
-		secretStore:       secretStore,
-		ownershipStore:    ownershipStore,
-		resourceLinkStore: resourceLinkStore,
-		encryptionService: encryptionService,
-		Log:               logFactory("SecretService"),
+		db:                 db,
+		secretStore:        secretStore,
+		secretVersionStore: secretVersionStore,
+		buildStore:         buildStore,
+		ownershipStore:     ownershipStore,
+		resourceLinkStore:  resourceLinkStore,
+		encryptionService:  encryptionService,
+		Log:                logFactory("SecretService"),
 	}
 }
 
@@ -126,6 +133,7 @@ func (s *SecretService) Update(ctx context.Context, txOrNil *store.Tx, secret *m
 }
 
 // UpdatePlaintext updates an existing secret's plaintext key and value with optimistic locking.
+// The previous key and value are kept as a prior version of the secret.
 // Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 func (s *SecretService) UpdatePlaintext(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID, update dto.UpdateSecretPlaintext) (*models.SecretPlaintext, error) {
 	secret, err := s.secretStore.Read(ctx, txOrNil, secretID)
@@ -150,7 +158,11 @@ func (s *SecretService) UpdatePlaintext(ctx context.Context, txOrNil *store.Tx,
 	if err != nil {
 		return nil, fmt.Errorf("error making secret name: %w", err)
 	}
-	secret.UpdatedAt = models.NewTime(time.Now())
+	now := models.NewTime(time.Now())
+	priorVersion := models.NewSecretVersion(now, secret)
+	secret.UpdatedAt = now
+	secret.Version++
+	secret.VersionCreatedAt = now
 	secret.Name = name
 	secret.KeyEncrypted = partsEncrypted[0]
 	secret.ValueEncrypted = partsEncrypted[1]
@@ -165,10 +177,15 @@ func (s *SecretService) UpdatePlaintext(ctx context.Context, txOrNil *store.Tx,
 		if err != nil {
 			return fmt.Errorf("error updating secret: %w", err)
 		}
+		err = s.secretVersionStore.Create(ctx, tx, priorVersion)
+		if err != nil {
+			return fmt.Errorf("error creating secret version: %w", err)
+		}
 		_, _, err = s.resourceLinkStore.Upsert(ctx, tx, secret)
 		if err != nil {
 			return fmt.Errorf("error upserting resource link: %w", err)
 		}
+		s.Infof("Updated secret %q to version %d", secret.ID, secret.Version)
 		return nil
 	})
 	if err != nil {
@@ -184,7 +201,11 @@ func (s *SecretService) UpdatePlaintext(ctx context.Context, txOrNil *store.Tx,
 // Delete permanently and idempotently deletes a secret, identifying it by ID.
 func (s *SecretService) Delete(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID) error {
 	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
-		err := s.secretStore.Delete(ctx, tx, secretID)
+		err := s.secretVersionStore.DeleteBySecretID(ctx, tx, secretID)
+		if err != nil {
+			return fmt.Errorf("error deleting secret versions: %w", err)
+		}
+		err = s.secretStore.Delete(ctx, tx, secretID)
 		if err != nil {
 			return fmt.Errorf("error deleting secret: %w", err)
 		}
@@ -219,6 +240,60 @@ func (s *SecretService) ListPlaintextByRepoID(ctx context.Context, txOrNil *stor
 	return plaintext, cursor, nil
 }
 
+// ListPlaintextByRepoIDAtBuildStart gets all secrets in plaintext that are associated with the specified repo id,
+// as they were when the specified build started. Secrets created after the build started are omitted.
+// Internal secrets are always returned as they are now, since they are managed by the system rather than rotated.
+// Returns an error if the version of a secret that was current when the build started has since been purged.
+func (s *SecretService) ListPlaintextByRepoIDAtBuildStart(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	repoID models.RepoID,
+	buildID models.BuildID,
+	pagination models.Pagination) ([]*models.SecretPlaintext, *models.Cursor, error) {
+
+	build, err := s.buildStore.Read(ctx, txOrNil, buildID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading build: %w", err)
+	}
+	if build.RepoID != repoID {
+		return nil, nil, gerror.NewErrNotFound("Build not found in repo")
+	}
+	secrets, cursor, err := s.ListByRepoID(ctx, txOrNil, repoID, pagination)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing secrets: %w", err)
+	}
+	var pinned []*models.Secret
+	for _, secret := range secrets {
+		if secret.IsInternal || !secret.VersionCreatedAt.After(build.CreatedAt.Time) {
+			pinned = append(pinned, secret)
+			continue
+		}
+		if secret.CreatedAt.After(build.CreatedAt.Time) {
+			continue // the secret didn't exist when the build started
+		}
+		version, err := s.secretVersionStore.ReadCurrentAt(ctx, txOrNil, secret.ID, build.CreatedAt)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil, nil, gerror.NewErrValidationFailed(fmt.Sprintf(
+					"The version of secret %s that was current when build %s started has been purged", secret.ID, build.ID))
+			}
+			return nil, nil, fmt.Errorf("error reading secret version: %w", err)
+		}
+		pinned = append(pinned, version.ApplyTo(secret))
+	}
+	plaintext, err := s.secretsToSecretsPlaintext(ctx, pinned)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error converting secrets to plaintext: %w", err)
+	}
+	return plaintext, cursor, nil
+}
+
+// ListVersions lists the prior versions of a secret, newest first. The versions returned contain metadata only.
+// Use cursor to page through results, if any.
+func (s *SecretService) ListVersions(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID, pagination models.Pagination) ([]*models.SecretVersion, *models.Cursor, error) {
+	return s.secretVersionStore.ListBySecretID(ctx, txOrNil, secretID, pagination)
+}
+
 // makeSecretName makes a name for a secret based on its key.
 // This must be recalculated whenever the key changes.
 func (s *SecretService) makeSecretName(keyPlaintext string) (models.ResourceName, error) {
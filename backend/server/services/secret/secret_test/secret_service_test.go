@@ -0,0 +1,122 @@
+package secret_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/store/secret_versions"
+)
+
+func TestSecretVersions(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	// tick makes sure each step below happens at a distinct time
+	tick := func() { time.Sleep(5 * time.Millisecond) }
+
+	// valuesAtBuildStart returns the plaintext values of the repo's user secrets as they were when the build started
+	valuesAtBuildStart := func(buildID models.BuildID) (map[string]string, error) {
+		secrets, _, err := app.SecretService.ListPlaintextByRepoIDAtBuildStart(ctx, nil, repo.ID, buildID, models.NewPagination(models.DefaultPaginationLimit, nil))
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]string)
+		for _, secret := range secrets {
+			if !secret.IsInternal {
+				values[secret.Key] = secret.Value
+			}
+		}
+		return values, nil
+	}
+	update := func(secretID models.SecretID, value string) *models.SecretPlaintext {
+		tick()
+		updated, err := app.SecretService.UpdatePlaintext(ctx, nil, secretID, dto.UpdateSecretPlaintext{ValuePlaintext: &value})
+		require.NoError(t, err)
+		return updated
+	}
+
+	token, err := app.SecretService.Create(ctx, nil, repo.ID, "TOKEN", "token-v1", false)
+	require.NoError(t, err)
+	require.Equal(t, 1, token.Version)
+	tick()
+	build1 := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+
+	// Rotating the secret keeps the prior version, and a secret created afterwards didn't exist when build 1 started
+	updated := update(token.ID, "token-v2")
+	require.Equal(t, 2, updated.Version)
+	require.Equal(t, "token-v2", updated.Value)
+	tick()
+	_, err = app.SecretService.Create(ctx, nil, repo.ID, "PASSWORD", "password-v1", false)
+	require.NoError(t, err)
+	tick()
+	build2 := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	update(token.ID, "token-v3")
+
+	values, err := valuesAtBuildStart(build1.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"TOKEN": "token-v1"}, values)
+	values, err = valuesAtBuildStart(build2.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"TOKEN": "token-v2", "PASSWORD": "password-v1"}, values)
+
+	// Unpinned secrets are always the latest versions
+	latest, _, err := app.SecretService.ListPlaintextByRepoID(ctx, nil, repo.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	for _, secret := range latest {
+		if secret.Key == "TOKEN" {
+			require.Equal(t, "token-v3", secret.Value)
+			require.Equal(t, 3, secret.Version)
+		}
+	}
+
+	// Version metadata is listed newest first
+	versions, _, err := app.SecretService.ListVersions(ctx, nil, token.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, 2, versions[0].Version)
+	require.Equal(t, 1, versions[1].Version)
+	require.True(t, versions[1].SupersededAt.Equal(versions[0].CreatedAt.Time))
+
+	// Builds from another repo can't be used to read this repo's secrets
+	otherRepo := server_test.CreateNamedRepo(t, ctx, app, "other-repo", legalEntity.ID)
+	otherBuild := server_test.CreateAndQueueBuild(t, ctx, app, otherRepo.ID, legalEntity.ID, "")
+	_, err = valuesAtBuildStart(otherBuild.ID)
+	require.True(t, gerror.IsNotFound(err))
+
+	// Purging versions keeps the newest prior versions, and nothing superseded after the cutoff
+	versionStore := secret_versions.NewStore(app.DB, app.LogFactory)
+	purged, err := versionStore.DeleteSuperseded(ctx, nil, 1, models.NewTime(time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+	require.Equal(t, 0, purged)
+	purged, err = versionStore.DeleteSuperseded(ctx, nil, 1, models.NewTime(time.Now()))
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+
+	// Build 1 needed the purged version, so its secrets can no longer be resolved; build 2's still can
+	_, err = valuesAtBuildStart(build1.ID)
+	require.True(t, gerror.IsValidationFailed(err))
+	values, err = valuesAtBuildStart(build2.ID)
+	require.NoError(t, err)
+	require.Equal(t, "token-v2", values["TOKEN"])
+
+	// Deleting a secret deletes its versions
+	err = app.SecretService.Delete(ctx, nil, token.ID)
+	require.NoError(t, err)
+	versions, _, err = app.SecretService.ListVersions(ctx, nil, token.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
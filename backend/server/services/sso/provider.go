@@ -0,0 +1,241 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+)
+
+const (
+	// discoveryPath is the path of the OpenID Connect discovery document, relative to the issuer URL.
+	discoveryPath = "/.well-known/openid-configuration"
+	// keyRefreshInterval is the minimum time between fetches of the provider's signing keys, to prevent
+	// tokens with unknown key IDs causing a fetch on every request.
+	keyRefreshInterval = 5 * time.Minute
+	// maxProviderResponseBytes limits the size of documents read from the provider.
+	maxProviderResponseBytes = 1 << 20
+)
+
+// validSigningMethods are the algorithms ID tokens can be signed with. The "none" algorithm and HMAC
+// algorithms are deliberately excluded.
+var validSigningMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// providerMetadata is the subset of the OpenID Connect discovery document needed to log users in.
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// provider is a client for an OpenID Connect identity provider. Provider metadata is discovered on first use
+// and cached, as are the provider's signing keys, which are refreshed when a token signed with an unknown
+// key is seen, so that providers can rotate their keys.
+type provider struct {
+	issuerURL  string
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	metadata      *providerMetadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func newProvider(issuerURL string, httpClient *http.Client) *provider {
+	return &provider{
+		issuerURL:  strings.TrimSuffix(issuerURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// getMetadata returns the provider's discovery document, fetching it if it has not been fetched before.
+func (p *provider) getMetadata(ctx context.Context) (*providerMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	metadata := &providerMetadata{}
+	err := p.getJSON(ctx, p.issuerURL+discoveryPath, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching OIDC provider discovery document: %w", err)
+	}
+	// The issuer in the discovery document must exactly match the issuer we were configured with (OIDC Discovery 4.3)
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.issuerURL {
+		return nil, fmt.Errorf("error OIDC provider issuer %q does not match configured issuer %q", metadata.Issuer, p.issuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("error OIDC provider discovery document is missing required endpoints")
+	}
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// getKey returns the provider's public key with the specified key ID, refreshing the provider's keys
+// if the key is not known.
+func (p *provider) getKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	metadata, err := p.getMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, ok := p.keys[keyID]
+	if ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("error OIDC provider signing key %q not found", keyID)
+	}
+	keySet := &struct {
+		Keys []*dto.JSONWebKey `json:"keys"`
+	}{}
+	err = p.getJSON(ctx, metadata.JWKSURI, keySet)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching OIDC provider signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		publicKey, err := parseJSONWebKey(jwk)
+		if err != nil {
+			// Providers may publish key types we don't support alongside ones we do
+			continue
+		}
+		keys[jwk.KeyID] = publicKey
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	key, ok = p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("error OIDC provider signing key %q not found", keyID)
+	}
+	return key, nil
+}
+
+// verifyIDToken checks that an ID token was signed by the provider, was issued to clientID and has not
+// expired, and that its nonce matches the nonce sent with the authentication request. Returns the
+// token's claims.
+func (p *provider) verifyIDToken(ctx context.Context, idToken string, clientID string, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(validSigningMethods))
+	_, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, keyID)
+	})
+	if err != nil {
+		return nil, gerror.NewErrUnauthorized("Invalid ID token").Wrap(err)
+	}
+	if !claims.VerifyIssuer(p.issuerURL, true) && !claims.VerifyIssuer(p.issuerURL+"/", true) {
+		return nil, gerror.NewErrUnauthorized("ID token was issued by an unexpected issuer")
+	}
+	if !claims.VerifyAudience(clientID, true) {
+		return nil, gerror.NewErrUnauthorized("ID token was issued to an unexpected audience")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, gerror.NewErrUnauthorized("ID token has expired")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, gerror.NewErrUnauthorized("ID token nonce does not match")
+	}
+	return claims, nil
+}
+
+func (p *provider) getJSON(ctx context.Context, url string, doc interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxProviderResponseBytes))
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("error unexpected status code %d from %s", res.StatusCode, url)
+	}
+	err = json.Unmarshal(body, doc)
+	if err != nil {
+		return fmt.Errorf("error parsing response from %s: %w", url, err)
+	}
+	return nil
+}
+
+// parseJSONWebKey converts an RSA or EC JSON Web Key to a public key that can verify signatures.
+func parseJSONWebKey(jwk *dto.JSONWebKey) (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decodeKeyParam(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeKeyParam(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, fmt.Errorf("error RSA exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("error unsupported curve %q", jwk.Curve)
+		}
+		x, err := decodeKeyParam(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeKeyParam(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("error EC key is not on curve %s", jwk.Curve)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("error unsupported key type %q", jwk.KeyType)
+	}
+}
+
+func decodeKeyParam(param string) (*big.Int, error) {
+	if param == "" {
+		return nil, fmt.Errorf("error key parameter must be set")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(param, "="))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding key parameter: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,392 @@
+package sso
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// SSOSystemName is the external system name for legal entities and group memberships that are sourced
+// from the single sign-on identity provider.
+const SSOSystemName = models.SystemName("oidc")
+
+// DefaultGroupsClaim is the ID token claim that lists the groups a user is a member of, unless configured otherwise.
+const DefaultGroupsClaim = "groups"
+
+// providerTimeout is the maximum time allowed for each request to the identity provider.
+const providerTimeout = 30 * time.Second
+
+var invalidNameCharsRegex = regexp.MustCompile("[^a-zA-Z0-9_-]+")
+
+// GroupMapping makes members of a group in the identity provider members of an access control group
+// owned by a company in BuildBeaver.
+type GroupMapping struct {
+	// ProviderGroup is the name of the group as it appears in the groups claim of the provider's ID tokens.
+	ProviderGroup string
+	// LegalEntityName is the name of the company that owns the access control group.
+	LegalEntityName models.ResourceName
+	// GroupName is the name of the access control group within the company.
+	GroupName models.ResourceName
+}
+
+func (m GroupMapping) String() string {
+	return fmt.Sprintf("%s=%s/%s", m.ProviderGroup, m.LegalEntityName, m.GroupName)
+}
+
+// ParseGroupMappings parses a comma separated list of group mappings, each in the form
+// provider-group=company/group, e.g. "okta-admins=acme/admin,okta-devs=acme/write".
+func ParseGroupMappings(str string) ([]GroupMapping, error) {
+	var mappings []GroupMapping
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("error invalid group mapping %q; expected provider-group=company/group", pair)
+		}
+		target := strings.SplitN(strings.TrimSpace(parts[1]), "/", 2)
+		if len(target) != 2 {
+			return nil, fmt.Errorf("error invalid group mapping %q; expected provider-group=company/group", pair)
+		}
+		mapping := GroupMapping{
+			ProviderGroup:   strings.TrimSpace(parts[0]),
+			LegalEntityName: models.ResourceName(target[0]),
+			GroupName:       models.ResourceName(target[1]),
+		}
+		if err := mapping.LegalEntityName.Validate(); err != nil {
+			return nil, fmt.Errorf("error invalid company name in group mapping %q: %w", pair, err)
+		}
+		if err := mapping.GroupName.Validate(); err != nil {
+			return nil, fmt.Errorf("error invalid group name in group mapping %q: %w", pair, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+type SSOConfig struct {
+	// IssuerURL identifies the OpenID Connect identity provider (e.g. "https://example.okta.com"). The
+	// provider's endpoints and signing keys are discovered from this URL.
+	IssuerURL string
+	// ClientID is the ID of the client registered with the identity provider for BuildBeaver.
+	ClientID string
+	// ClientSecret is the secret of the client registered with the identity provider.
+	ClientSecret string
+	// RedirectURL is the URL the provider will redirect to after authenticating users.
+	RedirectURL string
+	// Scopes are the scopes to request in addition to "openid"; these must cause the provider to include
+	// the user's profile, email and groups in ID tokens.
+	Scopes []string
+	// GroupsClaim is the name of the ID token claim that lists the groups the user is a member of.
+	GroupsClaim string
+	// GroupMappings map groups in the identity provider to BuildBeaver access control groups.
+	GroupMappings []GroupMapping
+}
+
+// Enabled returns true if users can log in via the identity provider.
+func (c SSOConfig) Enabled() bool {
+	return c.IssuerURL != ""
+}
+
+// SSOService logs users in via a generic OpenID Connect identity provider such as Okta, Azure AD or Keycloak,
+// as an alternative to logging in via GitHub. Each user who logs in is given a person legal entity identified
+// by their subject within the provider, and their group memberships in the provider are mapped to access control
+// groups within companies. Memberships added this way are recorded against SSOSystemName, so they are managed
+// independently of memberships synced from SCMs or added within BuildBeaver.
+type SSOService struct {
+	config             SSOConfig
+	provider           *provider
+	db                 *store.DB
+	legalEntityStore   store.LegalEntityStore
+	legalEntityService services.LegalEntityService
+	groupService       services.GroupService
+	logger.Log
+}
+
+func NewSSOService(
+	config SSOConfig,
+	db *store.DB,
+	legalEntityStore store.LegalEntityStore,
+	legalEntityService services.LegalEntityService,
+	groupService services.GroupService,
+	logFactory logger.LogFactory,
+) *SSOService {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DefaultGroupsClaim
+	}
+	return &SSOService{
+		config:             config,
+		provider:           newProvider(config.IssuerURL, &http.Client{Timeout: providerTimeout}),
+		db:                 db,
+		legalEntityStore:   legalEntityStore,
+		legalEntityService: legalEntityService,
+		groupService:       groupService,
+		Log:                logFactory("SSOService"),
+	}
+}
+
+// Enabled returns true if users can log in via the identity provider.
+func (s *SSOService) Enabled() bool {
+	return s.config.Enabled()
+}
+
+// AuthCodeURL returns the URL of the identity provider's login page to redirect the user's browser to.
+// state and nonce must be random values that are checked when the provider redirects back.
+// Returns a not found error if single sign-on is not enabled.
+func (s *SSOService) AuthCodeURL(ctx context.Context, state string, nonce string) (string, error) {
+	oauthConfig, err := s.getOAuth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauthConfig.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+// Authenticate exchanges an authorization code from the identity provider for an ID token, verifies the token
+// and ensures there is a legal entity and identity for the user it describes, with group memberships matching
+// the user's groups in the provider. Returns the user's identity.
+func (s *SSOService) Authenticate(ctx context.Context, code string, nonce string) (*models.Identity, error) {
+	oauthConfig, err := s.getOAuth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := oauthConfig.Exchange(context.WithValue(ctx, oauth2.HTTPClient, s.provider.httpClient), code)
+	if err != nil {
+		return nil, gerror.NewErrUnauthorized("Error exchanging authorization code").Wrap(err)
+	}
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return nil, gerror.NewErrUnauthorized("Identity provider did not return an ID token")
+	}
+	claims, err := s.provider.verifyIDToken(ctx, idToken, s.config.ClientID, nonce)
+	if err != nil {
+		return nil, err
+	}
+	user := &dto.SSOUser{
+		Subject:  stringClaim(claims, "sub"),
+		Username: stringClaim(claims, "preferred_username"),
+		Name:     stringClaim(claims, "name"),
+		Email:    stringClaim(claims, "email"),
+		Groups:   stringListClaim(claims, s.config.GroupsClaim),
+	}
+	if user.Subject == "" {
+		return nil, gerror.NewErrUnauthorized("ID token has no subject")
+	}
+	return s.SyncUser(ctx, user)
+}
+
+// SyncUser ensures there is a legal entity and identity for a user who has logged in via the identity provider,
+// and that the user is a member of the access control groups their provider groups are mapped to (and no other
+// groups via the provider). Returns the user's identity.
+func (s *SSOService) SyncUser(ctx context.Context, user *dto.SSOUser) (*models.Identity, error) {
+	var identity *models.Identity
+	err := s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		legalEntity, err := s.upsertUserLegalEntity(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+		identity, err = s.legalEntityService.ReadIdentity(ctx, tx, legalEntity.ID)
+		if err != nil {
+			return fmt.Errorf("error finding identity for legal entity: %w", err)
+		}
+		return s.syncUserGroups(ctx, tx, legalEntity, identity, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Infof("Synced SSO user %q (identity %s) with %d provider groups", user.Subject, identity.ID, len(user.Groups))
+	return identity, nil
+}
+
+// upsertUserLegalEntity creates or updates the person legal entity for an SSO user. New legal entities are named
+// after the user's username or email address, falling back to a unique name if that name is already taken.
+// Existing legal entities keep their name so that links to them remain stable.
+func (s *SSOService) upsertUserLegalEntity(ctx context.Context, tx *store.Tx, user *dto.SSOUser) (*models.LegalEntity, error) {
+	externalID := models.NewExternalResourceID(SSOSystemName, user.Subject)
+	var name models.ResourceName
+	existing, err := s.legalEntityStore.ReadByExternalID(ctx, tx, externalID)
+	if err == nil {
+		name = existing.Name
+	} else if gerror.IsNotFound(err) {
+		name, err = s.chooseUserName(ctx, tx, user)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("error reading legal entity for SSO user: %w", err)
+	}
+	legalName := user.Name
+	if legalName == "" {
+		legalName = name.String()
+	}
+	legalEntityData := models.NewPersonLegalEntityData(name, legalName, user.Email, &externalID, "")
+	legalEntity, _, _, err := s.legalEntityService.Upsert(ctx, tx, legalEntityData)
+	if err != nil {
+		return nil, fmt.Errorf("error upserting legal entity for SSO user: %w", err)
+	}
+	return legalEntity, nil
+}
+
+// chooseUserName returns a name for a new legal entity for an SSO user that is not already in use.
+func (s *SSOService) chooseUserName(ctx context.Context, tx *store.Tx, user *dto.SSOUser) (models.ResourceName, error) {
+	base := user.Username
+	if base == "" && user.Email != "" {
+		base = strings.SplitN(user.Email, "@", 2)[0]
+	}
+	base = strings.Trim(invalidNameCharsRegex.ReplaceAllString(base, "-"), "-")
+	hash := sha256.Sum256([]byte(user.Subject))
+	suffix := hex.EncodeToString(hash[:])[:8]
+	if base == "" {
+		return models.ResourceName("user-" + suffix), nil
+	}
+	// Leave room for the suffix, so the same base name is used whether or not it's needed
+	if len(base) > 90 {
+		base = base[:90]
+	}
+	_, err := s.legalEntityStore.ReadByName(ctx, tx, models.ResourceName(base))
+	if gerror.IsNotFound(err) {
+		return models.ResourceName(base), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error checking whether legal entity name is in use: %w", err)
+	}
+	return models.ResourceName(base + "-" + suffix), nil
+}
+
+// syncUserGroups adds the user to the access control groups their provider groups are mapped to, and
+// removes any memberships previously added via the provider for groups they are no longer mapped to.
+// Mappings that refer to companies or groups that don't exist are logged and ignored.
+func (s *SSOService) syncUserGroups(
+	ctx context.Context,
+	tx *store.Tx,
+	user *models.LegalEntity,
+	identity *models.Identity,
+	ssoUser *dto.SSOUser,
+) error {
+	providerGroups := make(map[string]bool, len(ssoUser.Groups))
+	for _, group := range ssoUser.Groups {
+		providerGroups[group] = true
+	}
+
+	// Work out the desired state of each mapped group first, since several provider groups can map to
+	// the same access control group
+	var (
+		mappedGroups = make(map[models.GroupID]*models.Group)
+		companies    = make(map[models.GroupID]*models.LegalEntity)
+		isMember     = make(map[models.GroupID]bool)
+	)
+	for _, mapping := range s.config.GroupMappings {
+		company, err := s.legalEntityStore.ReadByName(ctx, tx, mapping.LegalEntityName)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				s.Warnf("Ignoring SSO group mapping %s: company %q not found", mapping, mapping.LegalEntityName)
+				continue
+			}
+			return fmt.Errorf("error reading company for group mapping %s: %w", mapping, err)
+		}
+		group, err := s.groupService.ReadByName(ctx, tx, company.ID, mapping.GroupName)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				s.Warnf("Ignoring SSO group mapping %s: group %q not found", mapping, mapping.GroupName)
+				continue
+			}
+			return fmt.Errorf("error reading group for group mapping %s: %w", mapping, err)
+		}
+		mappedGroups[group.ID] = group
+		companies[group.ID] = company
+		if providerGroups[mapping.ProviderGroup] {
+			isMember[group.ID] = true
+		}
+	}
+
+	systemName := SSOSystemName
+	for groupID, group := range mappedGroups {
+		company := companies[groupID]
+		if isMember[groupID] {
+			err := s.legalEntityService.AddCompanyMember(ctx, tx, company.ID, user.ID)
+			if err != nil {
+				return fmt.Errorf("error adding user %q to company %q: %w", user.Name, company.Name, err)
+			}
+			_, created, err := s.groupService.FindOrCreateMembership(ctx, tx, models.NewGroupMembershipData(
+				group.ID, identity.ID, SSOSystemName, company.ID))
+			if err != nil {
+				return fmt.Errorf("error adding user %q to group %q for company %q: %w", user.Name, group.Name, company.Name, err)
+			}
+			if created {
+				s.Infof("Added SSO user %q to group %q for company %q", user.Name, group.Name, company.Name)
+			}
+		} else {
+			err := s.groupService.RemoveMembership(ctx, tx, group.ID, identity.ID, &systemName)
+			if err != nil {
+				return fmt.Errorf("error removing user %q from group %q for company %q: %w", user.Name, group.Name, company.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getOAuth2Config returns the OAuth2 configuration for the identity provider, discovering the provider's
+// endpoints if required.
+func (s *SSOService) getOAuth2Config(ctx context.Context) (*oauth2.Config, error) {
+	if !s.config.Enabled() {
+		return nil, gerror.NewErrNotFound("Single sign-on is not enabled on this server")
+	}
+	metadata, err := s.provider.getMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []string{"openid"}
+	for _, scope := range s.config.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return &oauth2.Config{
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+		RedirectURL:  s.config.RedirectURL,
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}, nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	str, _ := claims[name].(string)
+	return str
+}
+
+// stringListClaim returns a claim that is a list of strings; a single string is treated as a list of one.
+func stringListClaim(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var list []string
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				list = append(list, str)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
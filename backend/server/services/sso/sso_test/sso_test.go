@@ -0,0 +1,237 @@
+package sso_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services/sso"
+)
+
+const testClientID = "buildbeaver-test-client"
+
+// fakeProvider is a minimal OpenID Connect identity provider that issues an ID token with the next claims
+// it has been given in response to any authorization code.
+type fakeProvider struct {
+	server     *httptest.Server
+	signingKey *rsa.PrivateKey
+	nextClaims jwt.MapClaims
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &fakeProvider{signingKey: signingKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []*dto.JSONWebKey{{
+				KeyType:   "RSA",
+				Use:       "sig",
+				Algorithm: "RS256",
+				KeyID:     "test-key",
+				N:         base64.RawURLEncoding.EncodeToString(signingKey.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(signingKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, p.nextClaims)
+		token.Header["kid"] = "test-key"
+		idToken, err := token.SignedString(p.signingKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+// claims returns valid claims for a user, issued by the provider to the test client.
+func (p *fakeProvider) claims(subject string, username string, nonce string, groups ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                p.server.URL,
+		"aud":                testClientID,
+		"sub":                subject,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"preferred_username": username,
+		"name":               "Test User " + username,
+		"email":              username + "@example.com",
+		"groups":             groups,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, doc interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func TestSSOAuthenticate(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	provider := newFakeProvider(t)
+	defer provider.server.Close()
+
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "acme", "Acme Corp", "acme@example.com")
+	adminGroup, err := app.GroupService.ReadByName(ctx, nil, company.ID, models.AdminStandardGroup.Name)
+	require.NoError(t, err)
+
+	mappings, err := sso.ParseGroupMappings("eng-admins=acme/admin, missing=nobody/admin")
+	require.NoError(t, err)
+	ssoService := sso.NewSSOService(sso.SSOConfig{
+		IssuerURL:     provider.server.URL,
+		ClientID:      testClientID,
+		ClientSecret:  "secret",
+		RedirectURL:   "https://buildbeaver.example.com/api/v1/authentication/sso/callback",
+		Scopes:        []string{"profile", "email", "groups"},
+		GroupMappings: mappings,
+	}, app.DB, app.LegalEntityStore, app.LegalEntityService, app.GroupService, app.LogFactory)
+
+	t.Run("AuthCodeURL", func(t *testing.T) {
+		authURL, err := ssoService.AuthCodeURL(ctx, "test-state", "test-nonce")
+		require.NoError(t, err)
+		parsed, err := url.Parse(authURL)
+		require.NoError(t, err)
+		require.Equal(t, provider.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+		require.Equal(t, testClientID, parsed.Query().Get("client_id"))
+		require.Equal(t, "test-state", parsed.Query().Get("state"))
+		require.Equal(t, "test-nonce", parsed.Query().Get("nonce"))
+		require.Equal(t, "openid profile email groups", parsed.Query().Get("scope"))
+	})
+
+	var aliceIdentity *models.Identity
+	systemName := sso.SSOSystemName
+
+	t.Run("GroupMembershipAdded", func(t *testing.T) {
+		provider.nextClaims = provider.claims("alice-subject", "alice", "nonce-1", "eng-admins", "unmapped")
+		aliceIdentity, err = ssoService.Authenticate(ctx, "code", "nonce-1")
+		require.NoError(t, err)
+
+		alice, err := app.LegalEntityService.ReadByIdentityID(ctx, nil, aliceIdentity.ID)
+		require.NoError(t, err)
+		require.Equal(t, models.ResourceName("alice"), alice.Name)
+		require.Equal(t, models.LegalEntityTypePerson, alice.Type)
+		require.Equal(t, "alice@example.com", alice.EmailAddress)
+		require.Equal(t, models.NewExternalResourceID(sso.SSOSystemName, "alice-subject"), *alice.ExternalID)
+
+		_, err = app.GroupService.ReadMembership(ctx, nil, adminGroup.ID, aliceIdentity.ID, sso.SSOSystemName)
+		require.NoError(t, err)
+	})
+
+	t.Run("GroupMembershipRemoved", func(t *testing.T) {
+		provider.nextClaims = provider.claims("alice-subject", "alice", "nonce-2")
+		identity, err := ssoService.Authenticate(ctx, "code", "nonce-2")
+		require.NoError(t, err)
+		require.Equal(t, aliceIdentity.ID, identity.ID)
+
+		_, err = app.GroupService.ReadMembership(ctx, nil, adminGroup.ID, aliceIdentity.ID, sso.SSOSystemName)
+		require.True(t, gerror.IsNotFound(err))
+		memberships, _, err := app.GroupService.ListGroupMemberships(ctx, nil, nil, &aliceIdentity.ID, &systemName, models.NewPagination(models.DefaultPaginationLimit, nil))
+		require.NoError(t, err)
+		require.Empty(t, memberships)
+	})
+
+	t.Run("NameCollision", func(t *testing.T) {
+		existing, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "bob", "Bob Existing", "bob@example.org")
+		identity, err := ssoService.SyncUser(ctx, &dto.SSOUser{Subject: "bob-subject", Username: "bob", Email: "bob@example.com"})
+		require.NoError(t, err)
+		bob, err := app.LegalEntityService.ReadByIdentityID(ctx, nil, identity.ID)
+		require.NoError(t, err)
+		require.NotEqual(t, existing.ID, bob.ID)
+		require.NotEqual(t, models.ResourceName("bob"), bob.Name)
+		require.Regexp(t, "^bob-[0-9a-f]{8}$", bob.Name.String())
+	})
+
+	t.Run("InvalidTokens", func(t *testing.T) {
+		// Nonce must match the nonce sent with the authentication request
+		provider.nextClaims = provider.claims("alice-subject", "alice", "nonce-3")
+		_, err := ssoService.Authenticate(ctx, "code", "other-nonce")
+		require.True(t, gerror.IsUnauthorized(err))
+
+		// Token must be issued to our client
+		provider.nextClaims = provider.claims("alice-subject", "alice", "nonce-4")
+		provider.nextClaims["aud"] = "some-other-client"
+		_, err = ssoService.Authenticate(ctx, "code", "nonce-4")
+		require.True(t, gerror.IsUnauthorized(err))
+
+		// Token must not have expired
+		provider.nextClaims = provider.claims("alice-subject", "alice", "nonce-5")
+		provider.nextClaims["exp"] = time.Now().Add(-time.Minute).Unix()
+		_, err = ssoService.Authenticate(ctx, "code", "nonce-5")
+		require.True(t, gerror.IsUnauthorized(err))
+
+		// Token must be issued by the provider
+		provider.nextClaims = provider.claims("alice-subject", "alice", "nonce-6")
+		provider.nextClaims["iss"] = "https://attacker.example.com"
+		_, err = ssoService.Authenticate(ctx, "code", "nonce-6")
+		require.True(t, gerror.IsUnauthorized(err))
+	})
+}
+
+func TestSSODisabled(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	ssoService := sso.NewSSOService(sso.SSOConfig{}, app.DB, app.LegalEntityStore, app.LegalEntityService, app.GroupService, app.LogFactory)
+	require.False(t, ssoService.Enabled())
+	_, err = ssoService.AuthCodeURL(ctx, "state", "nonce")
+	require.True(t, gerror.IsNotFound(err))
+}
+
+func TestParseGroupMappings(t *testing.T) {
+	mappings, err := sso.ParseGroupMappings("")
+	require.NoError(t, err)
+	require.Empty(t, mappings)
+
+	mappings, err = sso.ParseGroupMappings("Engineering Admins=acme/admin,devs=acme/user")
+	require.NoError(t, err)
+	require.Equal(t, []sso.GroupMapping{
+		{ProviderGroup: "Engineering Admins", LegalEntityName: "acme", GroupName: "admin"},
+		{ProviderGroup: "devs", LegalEntityName: "acme", GroupName: "user"},
+	}, mappings)
+
+	for _, invalid := range []string{"devs", "devs=acme", "=acme/admin", "devs=acme corp/admin", "devs=acme/"} {
+		_, err = sso.ParseGroupMappings(invalid)
+		require.Error(t, err, invalid)
+	}
+}
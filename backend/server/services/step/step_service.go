@@ -45,6 +45,11 @@ func (s *StepService) ListByJobID(ctx context.Context, txOrNil *store.Tx, id mod
 	return s.stepStore.ListByJobID(ctx, txOrNil, id)
 }
 
+// ListByJobIDs gets all steps that are associated with any of the specified job ids.
+func (s *StepService) ListByJobIDs(ctx context.Context, txOrNil *store.Tx, ids []models.JobID) ([]*models.Step, error) {
+	return s.stepStore.ListByJobIDs(ctx, txOrNil, ids)
+}
+
 // Create a new step.
 // Returns store.ErrAlreadyExists if a job with matching unique properties already exists.
 func (s *StepService) Create(ctx context.Context, txOrNil *store.Tx, create *dto.CreateStep) error {
@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/logger"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/common/models/search"
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 	"github.com/buildbeaver/buildbeaver/server/services"
 	"github.com/buildbeaver/buildbeaver/server/services/scm"
 	"github.com/buildbeaver/buildbeaver/server/store"
@@ -43,6 +46,7 @@ func NewSyncService(
 	credentialService services.CredentialService,
 	groupService services.GroupService,
 	authorizationService services.AuthorizationService,
+	leaderElectionService services.LeaderElectionService,
 	logFactory logger.LogFactory,
 ) *SyncService {
 	s := &SyncService{
@@ -56,7 +60,7 @@ func NewSyncService(
 		Log:                  logFactory("SyncService"),
 	}
 
-	s.syncTimer = NewSyncTimer(db, s, logFactory)
+	s.syncTimer = NewSyncTimer(db, s, leaderElectionService, logFactory)
 	s.syncTimer.Start()
 	return s
 }
@@ -68,6 +72,8 @@ func (s *SyncService) Stop() {
 // SyncAuthenticatedUser reads the details for the currently authenticated user from their SCM, and ensures
 // there is a LegalEntity and Identity for the user in the database. Returns the Identity for the user.
 func (s *SyncService) SyncAuthenticatedUser(ctx context.Context, auth models.SCMAuth) (*models.Identity, error) {
+	ctx, span := tracing.StartSpan(ctx, "SyncService.SyncAuthenticatedUser")
+	defer span.End()
 	s.Info("Performing SCM Sync of Legal Entity for authenticated user")
 	var (
 		userLegalEntityData *models.LegalEntityData
@@ -132,6 +138,9 @@ func (s *SyncService) GlobalSync(
 	fullSyncAfter time.Duration,
 	perLegalEntityTimeout time.Duration,
 ) error {
+	ctx, span := tracing.StartSpan(ctx, "SyncService.GlobalSync")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("scm", scmName))
 	var (
 		fullSyncCount   int
 		quickSyncCount  int
@@ -195,6 +204,10 @@ func (s *SyncService) SyncLegalEntity(
 	legalEntityData *models.LegalEntityData,
 	fullSyncAfter time.Duration,
 ) (fullSync bool, repoCount int, err error) {
+	ctx, span := tracing.StartSpan(ctx, "SyncService.SyncLegalEntity")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("legal_entity_name", legalEntityData.Name))
+	defer func() { tracing.RecordError(span, err) }()
 	// Sync with the SCM that the legal entity came from, determined via its ExternalID
 	if legalEntityData.ExternalID == nil {
 		return false, 0, fmt.Errorf("error Legal Entity name '%s' has no external ID; can't identify external SCM to sync with", legalEntityData.Name)
@@ -287,6 +300,10 @@ func (s *SyncService) SyncReposForLegalEntity(
 	scmService scm.SCM,
 	legalEntity *models.LegalEntity,
 ) (repoCount int, err error) {
+	ctx, span := tracing.StartSpan(ctx, "SyncService.SyncReposForLegalEntity")
+	defer span.End()
+	span.SetAttributes(attribute.Stringer("legal_entity_id", legalEntity.ID))
+	defer func() { tracing.RecordError(span, err) }()
 	s.Tracef(" Beginning Sync repos operation for Legal entity ID %s, name %q, SCM '%s'",
 		legalEntity.ID, legalEntity.Name, scmService.Name())
 
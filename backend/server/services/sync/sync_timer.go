@@ -23,22 +23,25 @@ const initialSyncDelay = 1 * time.Minute
 // SyncTimer implements a Service to periodically sync with external systems.
 type SyncTimer struct {
 	*util.StatefulService
-	db                *store.DB
-	syncService       services.SyncService
-	syncTimerInterval time.Duration
+	db                    *store.DB
+	syncService           services.SyncService
+	leaderElectionService services.LeaderElectionService
+	syncTimerInterval     time.Duration
 	logger.Log
 }
 
 func NewSyncTimer(
 	db *store.DB,
 	syncService services.SyncService,
+	leaderElectionService services.LeaderElectionService,
 	logFactory logger.LogFactory,
 ) *SyncTimer {
 	s := &SyncTimer{
-		db:                db,
-		syncService:       syncService,
-		syncTimerInterval: defaultSyncTimerInterval,
-		Log:               logFactory("SyncTimer"),
+		db:                    db,
+		syncService:           syncService,
+		leaderElectionService: leaderElectionService,
+		syncTimerInterval:     defaultSyncTimerInterval,
+		Log:                   logFactory("SyncTimer"),
 	}
 	s.StatefulService = util.NewStatefulService(context.Background(), s.Log, s.loop)
 	return s
@@ -62,8 +65,13 @@ func (s *SyncTimer) loop() {
 	}
 }
 
-// Performs all periodic sync operations.
+// Performs all periodic sync operations, if this server is the leader.
 func (s *SyncTimer) doSync() {
+	if !s.leaderElectionService.IsLeader() {
+		s.Tracef("Skipping global sync; this server is not the leader")
+		return
+	}
+
 	// Set an overall timeout on the global sync
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultGlobalSyncTimeout)
 	defer cancel()
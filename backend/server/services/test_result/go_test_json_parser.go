@@ -0,0 +1,89 @@
+package test_result
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// goTestEvent is a single line of output from 'go test -json'; see 'go doc test2json'.
+type goTestEvent struct {
+	Action  string   `json:"Action"`
+	Package string   `json:"Package"`
+	Test    string   `json:"Test"`
+	Elapsed *float64 `json:"Elapsed"`
+	Output  string   `json:"Output"`
+}
+
+// parseGoTestJSON parses the output of 'go test -json'. Each test result is recorded as an attempt, so tests
+// run more than once (e.g. with -count or by a retrying wrapper) are reported as flaky if their results differ.
+// Lines that are not JSON (such as compiler errors) are ignored.
+func parseGoTestJSON(data []byte) ([]*testAttempt, error) {
+	var (
+		attempts []*testAttempt
+		events   int
+		outputs  = make(map[string]*strings.Builder)
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		event := &goTestEvent{}
+		if json.Unmarshal(line, event) != nil || event.Action == "" {
+			continue
+		}
+		events++
+		if event.Test == "" {
+			continue // package-level event
+		}
+		key := event.Package + "\x00" + event.Test
+		switch event.Action {
+		case "output":
+			output, ok := outputs[key]
+			if !ok {
+				output = &strings.Builder{}
+				outputs[key] = output
+			}
+			// Keep output bounded for tests that log heavily; messages are truncated when stored anyway
+			if output.Len() < 4*models.MaxTestCaseMessageLength {
+				output.WriteString(event.Output)
+			}
+		case "pass", "fail", "skip":
+			attempt := &testAttempt{
+				suite: event.Package,
+				name:  event.Test,
+			}
+			if event.Elapsed != nil {
+				attempt.durationMillis = int64(*event.Elapsed * 1000)
+			}
+			switch event.Action {
+			case "pass":
+				attempt.status = models.TestCaseStatusPassed
+			case "fail":
+				attempt.status = models.TestCaseStatusFailed
+				if output, ok := outputs[key]; ok {
+					attempt.message = strings.TrimSpace(output.String())
+				}
+			default:
+				attempt.status = models.TestCaseStatusSkipped
+			}
+			delete(outputs, key)
+			attempts = append(attempts, attempt)
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	if events == 0 {
+		return nil, errors.New("no test events found")
+	}
+	return attempts, nil
+}
@@ -0,0 +1,109 @@
+package test_result
+
+import (
+	"encoding/xml"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+type junitTestSuites struct {
+	Suites []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string            `xml:"name,attr"`
+	Suites    []*junitTestSuite `xml:"testsuite"`
+	TestCases []*junitTestCase  `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string          `xml:"name,attr"`
+	ClassName string          `xml:"classname,attr"`
+	Time      string          `xml:"time,attr"`
+	Failures  []*junitProblem `xml:"failure"`
+	Errors    []*junitProblem `xml:"error"`
+	Skipped   *junitProblem   `xml:"skipped"`
+	// FlakyFailures and FlakyErrors are failed attempts at a test that eventually passed (Maven Surefire).
+	FlakyFailures []*junitProblem `xml:"flakyFailure"`
+	FlakyErrors   []*junitProblem `xml:"flakyError"`
+	// RerunFailures and RerunErrors are failed attempts at a test that failed every time (Maven Surefire).
+	RerunFailures []*junitProblem `xml:"rerunFailure"`
+	RerunErrors   []*junitProblem `xml:"rerunError"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (p *junitProblem) String() string {
+	return joinMessage(p.Message, p.Text)
+}
+
+// parseJUnit parses a JUnit XML test report. The root element may be either <testsuites> or a single
+// <testsuite>, and test suites may be nested.
+func parseJUnit(data []byte, rootElement string) ([]*testAttempt, error) {
+	root := &junitTestSuites{}
+	if rootElement == "testsuite" {
+		suite := &junitTestSuite{}
+		err := xml.Unmarshal(data, suite)
+		if err != nil {
+			return nil, err
+		}
+		root.Suites = []*junitTestSuite{suite}
+	} else {
+		err := xml.Unmarshal(data, root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var attempts []*testAttempt
+	var walk func(suite *junitTestSuite)
+	walk = func(suite *junitTestSuite) {
+		for _, testCase := range suite.TestCases {
+			attempts = append(attempts, junitTestCaseAttempts(suite, testCase)...)
+		}
+		for _, child := range suite.Suites {
+			walk(child)
+		}
+	}
+	for _, suite := range root.Suites {
+		walk(suite)
+	}
+	return attempts, nil
+}
+
+func junitTestCaseAttempts(suite *junitTestSuite, testCase *junitTestCase) []*testAttempt {
+	suiteName := testCase.ClassName
+	if suiteName == "" {
+		suiteName = suite.Name
+	}
+	makeAttempt := func(status models.TestCaseStatus, durationMillis int64, message string) *testAttempt {
+		return &testAttempt{
+			suite:          suiteName,
+			name:           testCase.Name,
+			status:         status,
+			durationMillis: durationMillis,
+			message:        message,
+		}
+	}
+	// The recorded time covers the final attempt; earlier (rerun or flaky) attempts have no time recorded
+	var attempts []*testAttempt
+	for _, problems := range [][]*junitProblem{testCase.FlakyFailures, testCase.FlakyErrors, testCase.RerunFailures, testCase.RerunErrors} {
+		for _, problem := range problems {
+			attempts = append(attempts, makeAttempt(models.TestCaseStatusFailed, 0, problem.String()))
+		}
+	}
+	durationMillis := parseSeconds(testCase.Time)
+	switch {
+	case len(testCase.Failures) > 0:
+		attempts = append(attempts, makeAttempt(models.TestCaseStatusFailed, durationMillis, testCase.Failures[0].String()))
+	case len(testCase.Errors) > 0:
+		attempts = append(attempts, makeAttempt(models.TestCaseStatusFailed, durationMillis, testCase.Errors[0].String()))
+	case testCase.Skipped != nil:
+		attempts = append(attempts, makeAttempt(models.TestCaseStatusSkipped, durationMillis, ""))
+	default:
+		attempts = append(attempts, makeAttempt(models.TestCaseStatusPassed, durationMillis, ""))
+	}
+	return attempts
+}
@@ -0,0 +1,159 @@
+package test_result
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// testAttempt is the outcome of a single attempt at running a test, as parsed from a test report.
+type testAttempt struct {
+	suite          string
+	name           string
+	status         models.TestCaseStatus
+	durationMillis int64
+	message        string
+}
+
+// testResult is the combined outcome of every attempt at running a test within a single test report.
+type testResult struct {
+	suite          string
+	name           string
+	passed         int
+	failed         int
+	skipped        int
+	durationMillis int64
+	message        string
+}
+
+func (r *testResult) status() models.TestCaseStatus {
+	return models.CombineTestCaseStatuses(r.passed, r.failed, r.skipped)
+}
+
+func (r *testResult) attempts() int {
+	return r.passed + r.failed + r.skipped
+}
+
+// parseTestReport detects the format of a test report and parses it into a list of test attempts.
+// Returns a validation error if the report is not in a supported format or cannot be parsed.
+func parseTestReport(data []byte) (models.TestReportFormat, []*testAttempt, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 byte order mark
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return "", nil, gerror.NewErrValidationFailed("Test report is empty")
+	}
+	var (
+		format   models.TestReportFormat
+		attempts []*testAttempt
+		err      error
+	)
+	switch trimmed[0] {
+	case '<':
+		var root string
+		format, root, err = detectXMLFormat(trimmed)
+		if err != nil {
+			return "", nil, err
+		}
+		if format == models.TestReportFormatXUnit {
+			attempts, err = parseXUnit(trimmed, root)
+		} else {
+			attempts, err = parseJUnit(trimmed, root)
+		}
+	case '{':
+		format = models.TestReportFormatGoTestJSON
+		attempts, err = parseGoTestJSON(trimmed)
+	default:
+		return "", nil, gerror.NewErrValidationFailed("Test report is not JUnit/xUnit XML or Go test JSON")
+	}
+	if err != nil {
+		return "", nil, gerror.NewErrValidationFailed(fmt.Sprintf("Error parsing %s test report: %s", format, err))
+	}
+	return format, attempts, nil
+}
+
+// detectXMLFormat determines which XML test report format data is in from the name of its root element,
+// and returns the format along with the name of the root element.
+func detectXMLFormat(data []byte) (models.TestReportFormat, string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", "", gerror.NewErrValidationFailed(fmt.Sprintf("Error parsing XML test report: %s", err))
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "testsuites", "testsuite":
+			return models.TestReportFormatJUnit, start.Name.Local, nil
+		case "assemblies", "assembly":
+			return models.TestReportFormatXUnit, start.Name.Local, nil
+		default:
+			return "", "", gerror.NewErrValidationFailed(fmt.Sprintf("Unsupported XML test report root element <%s>", start.Name.Local))
+		}
+	}
+}
+
+// combineAttempts combines the attempts at each test into a single result per test, preserving the order
+// in which tests first appear in the report.
+func combineAttempts(attempts []*testAttempt) []*testResult {
+	var (
+		results  []*testResult
+		resultBy = make(map[string]*testResult)
+	)
+	for _, attempt := range attempts {
+		if attempt.name == "" {
+			continue
+		}
+		key := attempt.suite + "\x00" + attempt.name
+		result, ok := resultBy[key]
+		if !ok {
+			result = &testResult{suite: attempt.suite, name: attempt.name}
+			resultBy[key] = result
+			results = append(results, result)
+		}
+		switch attempt.status {
+		case models.TestCaseStatusPassed:
+			result.passed++
+		case models.TestCaseStatusFailed:
+			result.failed++
+			result.message = attempt.message
+		default:
+			result.skipped++
+		}
+		result.durationMillis += attempt.durationMillis
+	}
+	return results
+}
+
+// parseSeconds parses a duration in (possibly fractional) seconds, returning it in milliseconds.
+// Returns zero if the duration is missing or invalid, since many tools omit or mangle durations.
+func parseSeconds(str string) int64 {
+	seconds, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(str), ",", ""), 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return int64(seconds * 1000)
+}
+
+// joinMessage combines a short failure message and a longer failure detail (e.g. a stack trace).
+func joinMessage(message string, detail string) string {
+	message = strings.TrimSpace(message)
+	detail = strings.TrimSpace(detail)
+	switch {
+	case message == "":
+		return detail
+	case detail == "":
+		return message
+	case strings.HasPrefix(detail, message):
+		return detail
+	default:
+		return message + "\n\n" + detail
+	}
+}
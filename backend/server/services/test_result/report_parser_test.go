@@ -0,0 +1,112 @@
+package test_result
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+func TestParseJUnitReport(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="com.example.Suite" tests="4">
+    <testcase classname="com.example.MathTest" name="adds" time="0.5"/>
+    <testcase classname="com.example.MathTest" name="divides" time="0.25">
+      <failure message="expected 2">stack trace</failure>
+    </testcase>
+    <testcase classname="com.example.MathTest" name="multiplies" time="1">
+      <flakyFailure message="timed out"/>
+    </testcase>
+    <testcase classname="com.example.MathTest" name="subtracts">
+      <skipped/>
+    </testcase>
+  </testsuite>
+</testsuites>`
+	format, attempts, err := parseTestReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, models.TestReportFormatJUnit, format)
+
+	results := resultsByName(combineAttempts(attempts))
+	require.Len(t, results, 4)
+	require.Equal(t, "com.example.MathTest", results["adds"].suite)
+	require.Equal(t, models.TestCaseStatusPassed, results["adds"].status())
+	require.Equal(t, int64(500), results["adds"].durationMillis)
+	require.Equal(t, models.TestCaseStatusFailed, results["divides"].status())
+	require.Contains(t, results["divides"].message, "expected 2")
+	require.Equal(t, models.TestCaseStatusFlaky, results["multiplies"].status())
+	require.Equal(t, 2, results["multiplies"].attempts())
+	require.Equal(t, models.TestCaseStatusSkipped, results["subtracts"].status())
+}
+
+func TestParseXUnitReport(t *testing.T) {
+	report := `<assemblies>
+  <assembly name="Example.Tests.dll">
+    <collection name="Math">
+      <test name="Example.MathTests.Adds" type="Example.MathTests" method="Adds" time="0.1" result="Pass"/>
+      <test name="Example.MathTests.Divides" type="Example.MathTests" method="Divides" time="0.2" result="Fail">
+        <failure><message>expected 2</message></failure>
+      </test>
+      <test name="Example.MathTests.Subtracts" type="Example.MathTests" method="Subtracts" time="0" result="Skip"/>
+    </collection>
+  </assembly>
+</assemblies>`
+	format, attempts, err := parseTestReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, models.TestReportFormatXUnit, format)
+
+	results := resultsByName(combineAttempts(attempts))
+	require.Len(t, results, 3)
+	require.Equal(t, "Example.MathTests", results["Adds"].suite)
+	require.Equal(t, models.TestCaseStatusPassed, results["Adds"].status())
+	require.Equal(t, models.TestCaseStatusFailed, results["Divides"].status())
+	require.Contains(t, results["Divides"].message, "expected 2")
+	require.Equal(t, models.TestCaseStatusSkipped, results["Subtracts"].status())
+}
+
+func TestParseGoTestJSONReport(t *testing.T) {
+	report := `{"Action":"run","Package":"example.com/math","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/math","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"pass","Package":"example.com/math","Test":"TestAdd","Elapsed":0.01}
+{"Action":"run","Package":"example.com/math","Test":"TestDivide"}
+{"Action":"output","Package":"example.com/math","Test":"TestDivide","Output":"    math_test.go:12: expected 2\n"}
+{"Action":"fail","Package":"example.com/math","Test":"TestDivide","Elapsed":0.02}
+{"Action":"run","Package":"example.com/math","Test":"TestDivide"}
+{"Action":"pass","Package":"example.com/math","Test":"TestDivide","Elapsed":0.02}
+{"Action":"fail","Package":"example.com/math","Elapsed":0.05}
+`
+	format, attempts, err := parseTestReport([]byte(report))
+	require.NoError(t, err)
+	require.Equal(t, models.TestReportFormatGoTestJSON, format)
+
+	results := resultsByName(combineAttempts(attempts))
+	require.Len(t, results, 2)
+	require.Equal(t, "example.com/math", results["TestAdd"].suite)
+	require.Equal(t, models.TestCaseStatusPassed, results["TestAdd"].status())
+	require.Equal(t, models.TestCaseStatusFlaky, results["TestDivide"].status())
+	require.Equal(t, 2, results["TestDivide"].attempts())
+	require.Contains(t, results["TestDivide"].message, "expected 2")
+}
+
+func TestParseInvalidReport(t *testing.T) {
+	for _, report := range []string{
+		"",
+		"not a test report",
+		"<html><body>Not a test report</body></html>",
+		`{"not":"a go test event"}`,
+	} {
+		_, _, err := parseTestReport([]byte(report))
+		require.Error(t, err, "report: %q", report)
+		require.True(t, gerror.IsValidationFailed(err))
+	}
+}
+
+func resultsByName(results []*testResult) map[string]*testResult {
+	byName := make(map[string]*testResult)
+	for _, result := range results {
+		byName[result.name] = result
+	}
+	return byName
+}
@@ -0,0 +1,273 @@
+package test_result
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const (
+	// maxTestReportSize is the largest test report that will be parsed; larger reports are rejected.
+	maxTestReportSize = 64 * 1024 * 1024
+	ingestTimeout     = 5 * time.Minute
+)
+
+// TestResultService ingests test reports uploaded by jobs and summarizes the results. Any artifact uploaded
+// to the models.TestResultsArtifactGroupName artifact group is parsed in the background via the work queue,
+// as a JUnit/xUnit XML or Go test JSON report. Each report is recorded as a test run containing one test case
+// per test, the build's test summary is recalculated to include the new run, and the run is checked for
+// flaky tests.
+type TestResultService struct {
+	db               *store.DB
+	testRunStore     store.TestRunStore
+	testCaseStore    store.TestCaseStore
+	testSummaryStore store.TestSummaryStore
+	artifactStore    store.ArtifactStore
+	jobStore         store.JobStore
+	artifactService  services.ArtifactService
+	flakyTestService services.FlakyTestService
+	logger.Log
+}
+
+func NewTestResultService(
+	db *store.DB,
+	testRunStore store.TestRunStore,
+	testCaseStore store.TestCaseStore,
+	testSummaryStore store.TestSummaryStore,
+	artifactStore store.ArtifactStore,
+	jobStore store.JobStore,
+	artifactService services.ArtifactService,
+	flakyTestService services.FlakyTestService,
+	workQueueService services.WorkQueueService,
+	logFactory logger.LogFactory,
+) *TestResultService {
+	s := &TestResultService{
+		db:               db,
+		testRunStore:     testRunStore,
+		testCaseStore:    testCaseStore,
+		testSummaryStore: testSummaryStore,
+		artifactStore:    artifactStore,
+		jobStore:         jobStore,
+		artifactService:  artifactService,
+		flakyTestService: flakyTestService,
+		Log:              logFactory("TestResultService"),
+	}
+
+	// Register the code to process work items for ingesting test reports
+	err := workQueueService.RegisterHandler(
+		IngestTestResultsWorkItem,
+		s.ProcessIngestTestResultsWorkItem,
+		ingestTimeout,
+		work_queue.ExponentialBackoff(10, 5*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	artifactService.RegisterUploadHandler(func(ctx context.Context, tx *store.Tx, artifact *models.Artifact) error {
+		if artifact.GroupName != models.TestResultsArtifactGroupName {
+			return nil
+		}
+		job, err := s.jobStore.Read(ctx, tx, artifact.JobID)
+		if err != nil {
+			return fmt.Errorf("error reading job for artifact: %w", err)
+		}
+		return workQueueService.AddWorkItem(ctx, tx, NewIngestTestResultsWorkItem(job.BuildID, artifact.ID))
+	})
+
+	return s
+}
+
+// ProcessIngestTestResultsWorkItem parses a test report artifact and records its results.
+func (s *TestResultService) ProcessIngestTestResultsWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &IngestTestResultsWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling ingest test results work item data: %w", err)
+	}
+	artifact, err := s.artifactStore.Read(ctx, nil, workItemData.ArtifactID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping test results for deleted artifact %q", workItemData.ArtifactID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading artifact: %w", err)
+	}
+	_, err = s.testRunStore.ReadByArtifactID(ctx, nil, artifact.ID)
+	if err == nil {
+		s.Infof("Ignoring test results for artifact %q that have already been ingested", artifact.ID)
+		return false, nil
+	} else if !gerror.IsNotFound(err) {
+		return true, fmt.Errorf("error reading test run: %w", err)
+	}
+	if artifact.Size > maxTestReportSize {
+		return false, fmt.Errorf("error test report %q is too large (%d bytes, maximum is %d bytes)", artifact.Path, artifact.Size, maxTestReportSize)
+	}
+
+	reader, err := s.artifactService.GetArtifactData(ctx, artifact.ID)
+	if err != nil {
+		if gerror.IsArtifactQuarantined(err) {
+			s.Warnf("Ignoring test results for quarantined artifact %q", artifact.ID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading artifact data: %w", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxTestReportSize))
+	if err != nil {
+		return true, fmt.Errorf("error reading artifact data: %w", err)
+	}
+	format, attempts, err := parseTestReport(data)
+	if err != nil {
+		// Retrying won't help if the report can't be parsed
+		return false, fmt.Errorf("error parsing test report %q: %w", artifact.Path, err)
+	}
+
+	var testRun *models.TestRun
+	err = s.db.WithTx(ctx, nil, func(tx *store.Tx) error {
+		job, err := s.jobStore.Read(ctx, tx, artifact.JobID)
+		if err != nil {
+			return fmt.Errorf("error reading job for artifact: %w", err)
+		}
+		now := models.NewTime(time.Now())
+		testRun = models.NewTestRun(now, job, artifact.ID, format)
+		results := combineAttempts(attempts)
+		testCases := make([]*models.TestCase, 0, len(results))
+		for _, result := range results {
+			testCase := models.NewTestCase(now, testRun, result.suite, result.name, result.status(), result.attempts(), result.durationMillis, result.message)
+			testCases = append(testCases, testCase)
+			testRun.Add(testCase.Status, testCase.DurationMillis)
+		}
+		err = s.testRunStore.Create(ctx, tx, testRun)
+		if err != nil {
+			return fmt.Errorf("error creating test run: %w", err)
+		}
+		for _, testCase := range testCases {
+			err = s.testCaseStore.Create(ctx, tx, testCase)
+			if err != nil {
+				return fmt.Errorf("error creating test case: %w", err)
+			}
+		}
+		err = s.flakyTestService.RecordTestRun(ctx, tx, job, testRun, testCases)
+		if err != nil {
+			return fmt.Errorf("error checking for flaky tests: %w", err)
+		}
+		return s.updateSummary(ctx, tx, testRun)
+	})
+	if err != nil {
+		return true, err
+	}
+	s.Infof("Ingested %s test report %q for build %q: %d tests, %d failed, %d flaky",
+		format, artifact.Path, testRun.BuildID, testRun.Total, testRun.Failed, testRun.Flaky)
+	return false, nil
+}
+
+// updateSummary recalculates the test summary for the build a new test run was added to, creating the
+// summary if this is the build's first test run.
+func (s *TestResultService) updateSummary(ctx context.Context, tx *store.Tx, testRun *models.TestRun) error {
+	now := models.NewTime(time.Now())
+	summary, err := s.testSummaryStore.ReadByBuildID(ctx, tx, testRun.BuildID)
+	create := false
+	if err != nil {
+		if !gerror.IsNotFound(err) {
+			return fmt.Errorf("error reading test summary: %w", err)
+		}
+		summary = models.NewTestSummary(now, testRun.BuildID, testRun.RepoID)
+		create = true
+	}
+
+	// A test reported by more than one run is counted once, combining its results across all runs
+	counts, err := s.testCaseStore.CountStatusesByBuildID(ctx, tx, testRun.BuildID)
+	if err != nil {
+		return fmt.Errorf("error counting test cases: %w", err)
+	}
+	type testKey struct {
+		suite string
+		name  string
+	}
+	type testOutcomes struct {
+		passed  int
+		failed  int
+		skipped int
+	}
+	outcomesBy := make(map[testKey]*testOutcomes)
+	for _, count := range counts {
+		key := testKey{suite: count.Suite, name: count.Name}
+		outcomes, ok := outcomesBy[key]
+		if !ok {
+			outcomes = &testOutcomes{}
+			outcomesBy[key] = outcomes
+		}
+		switch count.Status {
+		case models.TestCaseStatusPassed:
+			outcomes.passed += count.Count
+		case models.TestCaseStatusFailed:
+			outcomes.failed += count.Count
+		case models.TestCaseStatusSkipped:
+			outcomes.skipped += count.Count
+		case models.TestCaseStatusFlaky:
+			outcomes.passed += count.Count
+			outcomes.failed += count.Count
+		}
+	}
+	summary.Total, summary.Passed, summary.Failed, summary.Skipped, summary.Flaky = 0, 0, 0, 0, 0
+	for _, outcomes := range outcomesBy {
+		summary.Add(models.CombineTestCaseStatuses(outcomes.passed, outcomes.failed, outcomes.skipped))
+	}
+	summary.Runs++
+	summary.DurationMillis += testRun.DurationMillis
+	summary.UpdatedAt = now
+
+	if create {
+		err = s.testSummaryStore.Create(ctx, tx, summary)
+		if err != nil {
+			return fmt.Errorf("error creating test summary: %w", err)
+		}
+		return nil
+	}
+	err = s.testSummaryStore.Update(ctx, tx, summary)
+	if err != nil {
+		return fmt.Errorf("error updating test summary: %w", err)
+	}
+	return nil
+}
+
+// ReadSummary reads the test summary for a build.
+// Returns models.ErrNotFound if no test results have been reported for the build.
+func (s *TestResultService) ReadSummary(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) (*models.TestSummary, error) {
+	return s.testSummaryStore.ReadByBuildID(ctx, txOrNil, buildID)
+}
+
+// ListSummaries lists the test summaries for builds in a repo, newest first, to show test trends over time.
+// Use cursor to page through results, if any.
+func (s *TestResultService) ListSummaries(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.TestSummary, *models.Cursor, error) {
+	return s.testSummaryStore.ListByRepoID(ctx, txOrNil, repoID, pagination)
+}
+
+// ListRuns lists the test runs for a build.
+// Use cursor to page through results, if any.
+func (s *TestResultService) ListRuns(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.TestRun, *models.Cursor, error) {
+	return s.testRunStore.ListByBuildID(ctx, txOrNil, buildID, pagination)
+}
+
+// SearchCases searches for test cases, newest first. Searching by repo, suite and name returns the history
+// of a single test across builds.
+// Use cursor to page through results, if any.
+func (s *TestResultService) SearchCases(ctx context.Context, txOrNil *store.Tx, search models.TestCaseSearch) ([]*models.TestCase, *models.Cursor, error) {
+	err := search.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.testCaseStore.Search(ctx, txOrNil, search)
+}
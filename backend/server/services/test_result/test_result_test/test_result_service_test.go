@@ -0,0 +1,142 @@
+package test_result_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testIngestTimeout = 30 * time.Second
+
+const junitReport = `<testsuite name="math">
+  <testcase classname="math" name="TestAdd" time="0.1"/>
+  <testcase classname="math" name="TestDivide" time="0.2"><failure message="expected 2"/></testcase>
+  <testcase classname="math" name="TestSubtract" time="0"><skipped/></testcase>
+</testsuite>`
+
+const goTestJSONReport = `{"Action":"run","Package":"math","Test":"TestDivide"}
+{"Action":"pass","Package":"math","Test":"TestDivide","Elapsed":0.3}
+{"Action":"run","Package":"math","Test":"TestMultiply"}
+{"Action":"pass","Package":"math","Test":"TestMultiply","Elapsed":0.4}
+`
+
+func TestTestResultService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, "")
+	require.NotEmpty(t, graph.Jobs)
+	job := graph.Jobs[0]
+
+	// No summary exists until test results are reported
+	_, err = app.TestResultService.ReadSummary(ctx, nil, graph.ID)
+	require.Error(t, err)
+	require.True(t, gerror.IsNotFound(err))
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	waitForRuns := func(runs int) *models.TestSummary {
+		deadline := time.Now().Add(testIngestTimeout)
+		for time.Now().Before(deadline) {
+			summary, err := app.TestResultService.ReadSummary(ctx, nil, graph.ID)
+			if err == nil && summary.Runs >= runs {
+				return summary
+			}
+			if err != nil {
+				require.True(t, gerror.IsNotFound(err))
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for %d test runs to be ingested", runs)
+		return nil
+	}
+
+	// Artifacts outside the test results group are not ingested, nor are unparseable reports
+	_, err = app.ArtifactService.Create(ctx, job.ID, "reports", "reports/junit.xml", "", bytes.NewReader([]byte(junitReport)), true)
+	require.NoError(t, err)
+	_, err = app.ArtifactService.Create(ctx, job.ID, models.TestResultsArtifactGroupName, "garbage.txt", "", bytes.NewReader([]byte("not a report")), true)
+	require.NoError(t, err)
+
+	junit, err := app.ArtifactService.Create(ctx, job.ID, models.TestResultsArtifactGroupName, "junit.xml", "", bytes.NewReader([]byte(junitReport)), true)
+	require.NoError(t, err)
+	summary := waitForRuns(1)
+	require.Equal(t, repo.ID, summary.RepoID)
+	require.Equal(t, 3, summary.Total)
+	require.Equal(t, 1, summary.Passed)
+	require.Equal(t, 1, summary.Failed)
+	require.Equal(t, 1, summary.Skipped)
+	require.Equal(t, 0, summary.Flaky)
+	require.Equal(t, int64(300), summary.DurationMillis)
+
+	// A test that failed in one run and passed in another is flaky across the build
+	_, err = app.ArtifactService.Create(ctx, job.ID, models.TestResultsArtifactGroupName, "go-test.json", "", bytes.NewReader([]byte(goTestJSONReport)), true)
+	require.NoError(t, err)
+	summary = waitForRuns(2)
+	require.Equal(t, 4, summary.Total)
+	require.Equal(t, 2, summary.Passed)
+	require.Equal(t, 0, summary.Failed)
+	require.Equal(t, 1, summary.Skipped)
+	require.Equal(t, 1, summary.Flaky)
+	require.Equal(t, int64(1000), summary.DurationMillis)
+
+	runs, _, err := app.TestResultService.ListRuns(ctx, nil, graph.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	var junitRun *models.TestRun
+	for _, run := range runs {
+		if run.ArtifactID == junit.ID {
+			junitRun = run
+		}
+	}
+	require.NotNil(t, junitRun)
+	require.Equal(t, models.TestReportFormatJUnit, junitRun.Format)
+	require.Equal(t, job.ID, junitRun.JobID)
+	require.Equal(t, 1, junitRun.Failed)
+
+	// Search test cases within a build
+	failed := models.TestCaseStatusFailed
+	search := models.NewTestCaseSearch()
+	search.BuildID = &graph.ID
+	search.Status = &failed
+	testCases, _, err := app.TestResultService.SearchCases(ctx, nil, *search)
+	require.NoError(t, err)
+	require.Len(t, testCases, 1)
+	require.Equal(t, "TestDivide", testCases[0].Name)
+	require.Equal(t, "expected 2", testCases[0].Message)
+
+	// Search the history of a single test across the repo
+	suite, name := "math", "TestDivide"
+	search = models.NewTestCaseSearch()
+	search.RepoID = &repo.ID
+	search.Suite = &suite
+	search.Name = &name
+	testCases, _, err = app.TestResultService.SearchCases(ctx, nil, *search)
+	require.NoError(t, err)
+	require.Len(t, testCases, 2)
+
+	// Searches must be scoped to a build or repo
+	_, _, err = app.TestResultService.SearchCases(ctx, nil, *models.NewTestCaseSearch())
+	require.Error(t, err)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	summaries, _, err := app.TestResultService.ListSummaries(ctx, nil, repo.ID, models.NewPagination(models.DefaultPaginationLimit, nil))
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.Equal(t, summary.ID, summaries[0].ID)
+}
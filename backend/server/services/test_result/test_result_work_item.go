@@ -0,0 +1,33 @@
+package test_result
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// IngestTestResultsWorkItem is a work item that will parse a test report artifact and record its results.
+const IngestTestResultsWorkItem models.WorkItemType = "IngestTestResults"
+
+// IngestTestResultsWorkItemData is serialized to JSON and stored in the Data field of an IngestTestResultsWorkItem.
+type IngestTestResultsWorkItemData struct {
+	ArtifactID models.ArtifactID
+}
+
+func NewIngestTestResultsWorkItem(buildID models.BuildID, artifactID models.ArtifactID) *models.WorkItem {
+	data := &IngestTestResultsWorkItemData{
+		ArtifactID: artifactID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in IngestTestResultsWorkItemData definition
+		panic("Unable to marshal IngestTestResultsWorkItemData object to JSON")
+	}
+
+	// Concurrency key is per build, so that reports from the same build don't race to update the build's summary
+	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("test-results/%s", buildID))
+
+	return models.NewWorkItem(IngestTestResultsWorkItem, string(dataJson), concurrencyKey, models.NewTime(time.Now()))
+}
@@ -0,0 +1,81 @@
+package test_result
+
+import (
+	"encoding/xml"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+type xunitAssemblies struct {
+	Assemblies []*xunitAssembly `xml:"assembly"`
+}
+
+type xunitAssembly struct {
+	Collections []*xunitCollection `xml:"collection"`
+}
+
+type xunitCollection struct {
+	Tests []*xunitTest `xml:"test"`
+}
+
+type xunitTest struct {
+	Name    string        `xml:"name,attr"`
+	Type    string        `xml:"type,attr"`
+	Method  string        `xml:"method,attr"`
+	Time    string        `xml:"time,attr"`
+	Result  string        `xml:"result,attr"`
+	Failure *xunitFailure `xml:"failure"`
+}
+
+type xunitFailure struct {
+	Message    string `xml:"message"`
+	StackTrace string `xml:"stack-trace"`
+}
+
+// parseXUnit parses an xUnit.net v2 XML test report. The root element may be either <assemblies> or a
+// single <assembly>.
+func parseXUnit(data []byte, rootElement string) ([]*testAttempt, error) {
+	root := &xunitAssemblies{}
+	if rootElement == "assembly" {
+		assembly := &xunitAssembly{}
+		err := xml.Unmarshal(data, assembly)
+		if err != nil {
+			return nil, err
+		}
+		root.Assemblies = []*xunitAssembly{assembly}
+	} else {
+		err := xml.Unmarshal(data, root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var attempts []*testAttempt
+	for _, assembly := range root.Assemblies {
+		for _, collection := range assembly.Collections {
+			for _, test := range collection.Tests {
+				name := test.Method
+				if name == "" {
+					name = test.Name
+				}
+				attempt := &testAttempt{
+					suite:          test.Type,
+					name:           name,
+					durationMillis: parseSeconds(test.Time),
+				}
+				switch test.Result {
+				case "Pass":
+					attempt.status = models.TestCaseStatusPassed
+				case "Fail":
+					attempt.status = models.TestCaseStatusFailed
+					if test.Failure != nil {
+						attempt.message = joinMessage(test.Failure.Message, test.Failure.StackTrace)
+					}
+				default:
+					attempt.status = models.TestCaseStatusSkipped
+				}
+				attempts = append(attempts, attempt)
+			}
+		}
+	}
+	return attempts, nil
+}
@@ -0,0 +1,248 @@
+package toolchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/scm"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const rebuildTimeout = 2 * time.Minute
+
+// ToolchainRebuildService watches the Dockerfiles toolchains are built from, and automatically builds a new
+// version of a toolchain when its Dockerfile changes. Each time a full build of a repo's default branch succeeds,
+// the files changed by the build's commit are checked (in the background via the work queue) against the
+// Dockerfile of the latest version of each toolchain registered by the repo's builds. If a toolchain's Dockerfile
+// changed, a build of the toolchain's rebuild workflow is enqueued for the same commit; this workflow is expected
+// to build and publish the image, and register it as a new version of the toolchain, at which point repos using
+// the toolchain are notified via a models.ToolchainVersionAvailableEvent.
+type ToolchainRebuildService struct {
+	db               *store.DB
+	buildStore       store.BuildStore
+	commitStore      store.CommitStore
+	repoStore        store.RepoStore
+	toolchainService services.ToolchainService
+	queueService     services.QueueService
+	workQueueService services.WorkQueueService
+	scmRegistry      *scm.SCMRegistry
+	logger.Log
+}
+
+func NewToolchainRebuildService(
+	db *store.DB,
+	buildStore store.BuildStore,
+	commitStore store.CommitStore,
+	repoStore store.RepoStore,
+	toolchainService services.ToolchainService,
+	queueService services.QueueService,
+	eventService services.EventService,
+	workQueueService services.WorkQueueService,
+	scmRegistry *scm.SCMRegistry,
+	logFactory logger.LogFactory,
+) *ToolchainRebuildService {
+	s := &ToolchainRebuildService{
+		db:               db,
+		buildStore:       buildStore,
+		commitStore:      commitStore,
+		repoStore:        repoStore,
+		toolchainService: toolchainService,
+		queueService:     queueService,
+		workQueueService: workQueueService,
+		scmRegistry:      scmRegistry,
+		Log:              logFactory("ToolchainRebuildService"),
+	}
+
+	// Register the code to process work items for rebuilding toolchains
+	err := workQueueService.RegisterHandler(
+		RebuildToolchainsWorkItem,
+		s.ProcessRebuildToolchainsWorkItem,
+		rebuildTimeout,
+		work_queue.ExponentialBackoff(10, 5*time.Second, 1*time.Hour),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	eventService.Subscribe(models.BuildStatusChangedEvent, s.onBuildStatusChanged)
+
+	return s
+}
+
+// onBuildStatusChanged queues a check for changed toolchain Dockerfiles when a full build of a repo's default
+// branch succeeds, if any toolchains are built from the repo.
+func (s *ToolchainRebuildService) onBuildStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	if models.WorkflowStatus(event.Payload) != models.WorkflowStatusSucceeded {
+		return nil
+	}
+	build, err := s.buildStore.Read(ctx, tx, event.BuildID)
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	// Builds of only some workflows (including the rebuilds themselves) never trigger a rebuild
+	if len(build.Opts.NodesToRun) > 0 {
+		return nil
+	}
+	repo, err := s.repoStore.Read(ctx, tx, build.RepoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	if build.Ref != defaultBranchRef(repo) {
+		return nil
+	}
+	toolchains, err := s.toolchainService.ListRebuiltOnChangeBySourceRepoID(ctx, tx, repo.ID)
+	if err != nil {
+		return fmt.Errorf("error listing toolchains built from repo: %w", err)
+	}
+	if len(toolchains) == 0 {
+		return nil
+	}
+	return s.workQueueService.AddWorkItem(ctx, tx, NewRebuildToolchainsWorkItem(build))
+}
+
+// ProcessRebuildToolchainsWorkItem enqueues a rebuild of each toolchain whose Dockerfile was changed by a build.
+func (s *ToolchainRebuildService) ProcessRebuildToolchainsWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &RebuildToolchainsWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling rebuild toolchains work item data: %w", err)
+	}
+	_, err = s.RebuildChangedToolchains(ctx, workItemData.BuildID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping toolchain rebuild check for deleted build %q", workItemData.BuildID)
+			return false, nil
+		}
+		return true, err
+	}
+	return false, nil
+}
+
+// RebuildChangedToolchains checks whether the commit a build ran for changed the Dockerfile of any toolchain
+// built from the build's repo, and enqueues a build of the rebuild workflow for each toolchain that changed.
+// A toolchain is only rebuilt once per commit, however many times this is called. Returns the builds enqueued.
+func (s *ToolchainRebuildService) RebuildChangedToolchains(ctx context.Context, buildID models.BuildID) ([]*dto.BuildGraph, error) {
+	build, err := s.buildStore.Read(ctx, nil, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading build: %w", err)
+	}
+	toolchains, err := s.toolchainService.ListRebuiltOnChangeBySourceRepoID(ctx, nil, build.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing toolchains built from repo: %w", err)
+	}
+	if len(toolchains) == 0 {
+		return nil, nil
+	}
+	commit, err := s.commitStore.Read(ctx, nil, build.CommitID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit: %w", err)
+	}
+	changedFiles, err := s.getChangedFiles(ctx, build.RepoID, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var rebuilds []*dto.BuildGraph
+	for _, workflow := range findChangedToolchainWorkflows(toolchains, changedFiles) {
+		alreadyRebuilt, err := s.isRebuildEnqueued(ctx, commit, build.Ref, workflow)
+		if err != nil {
+			return nil, err
+		}
+		if alreadyRebuilt {
+			continue
+		}
+		rebuild, err := s.queueService.EnqueueBuildFromCommit(ctx, nil, commit, build.Ref, &models.BuildOptions{
+			NodesToRun:        []models.NodeFQN{models.NewNodeFQNForWorkflow(workflow)},
+			IgnoreSkipMarkers: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error enqueuing rebuild workflow %q: %w", workflow, err)
+		}
+		s.Infof("Enqueued build %q of workflow %q to rebuild toolchains changed by commit %q", rebuild.ID, workflow, commit.SHA)
+		rebuilds = append(rebuilds, rebuild)
+	}
+	return rebuilds, nil
+}
+
+// getChangedFiles returns the paths of the files changed by a commit, fetched from the SCM hosting the repo.
+func (s *ToolchainRebuildService) getChangedFiles(ctx context.Context, repoID models.RepoID, commit *models.Commit) ([]string, error) {
+	repo, err := s.repoStore.Read(ctx, nil, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	if repo.ExternalID == nil {
+		return nil, fmt.Errorf("error repo %q is not hosted by an SCM", repo.Name)
+	}
+	scmName := repo.ExternalID.ExternalSystem
+	externalSCM, err := s.scmRegistry.Get(scmName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SCM from registry for %q: %w", scmName, err)
+	}
+	changedFiles, err := externalSCM.GetChangedFiles(ctx, repo, commit.SHA)
+	if err != nil {
+		return nil, fmt.Errorf("error getting files changed by commit: %w", err)
+	}
+	return changedFiles, nil
+}
+
+// isRebuildEnqueued returns true if a build of just the specified workflow has already been enqueued for a commit.
+func (s *ToolchainRebuildService) isRebuildEnqueued(ctx context.Context, commit *models.Commit, ref string, workflow models.ResourceName) (bool, error) {
+	search := models.NewBuildSearchForCommit(commit.ID, ref, false, nil, 100)
+	for moreResults := true; moreResults; {
+		results, cursor, err := s.buildStore.Search(ctx, nil, models.NoIdentity, search)
+		if err != nil {
+			return false, fmt.Errorf("error searching for builds of commit: %w", err)
+		}
+		for _, result := range results {
+			nodes := result.Opts.NodesToRun
+			if len(nodes) == 1 && nodes[0] == models.NewNodeFQNForWorkflow(workflow) {
+				return true, nil
+			}
+		}
+		if cursor != nil && cursor.Next != nil {
+			search.Pagination.Cursor = cursor.Next
+		} else {
+			moreResults = false
+		}
+	}
+	return false, nil
+}
+
+// findChangedToolchainWorkflows returns the rebuild workflows for toolchains whose Dockerfile is one of the
+// changed files, without duplicates.
+func findChangedToolchainWorkflows(toolchains []*models.Toolchain, changedFiles []string) []models.ResourceName {
+	changed := make(map[string]bool, len(changedFiles))
+	for _, file := range changedFiles {
+		changed[path.Clean(file)] = true
+	}
+	var workflows []models.ResourceName
+	found := make(map[models.ResourceName]bool)
+	for _, toolchain := range toolchains {
+		if !changed[path.Clean(toolchain.Dockerfile)] || found[toolchain.RebuildWorkflow] {
+			continue
+		}
+		workflows = append(workflows, toolchain.RebuildWorkflow)
+		found[toolchain.RebuildWorkflow] = true
+	}
+	return workflows
+}
+
+// defaultBranchRef returns the ref for a repo's default branch.
+func defaultBranchRef(repo *models.Repo) string {
+	if repo.DefaultBranch == "" || strings.HasPrefix(repo.DefaultBranch, "refs/") {
+		return repo.DefaultBranch
+	}
+	return "refs/heads/" + repo.DefaultBranch
+}
@@ -0,0 +1,222 @@
+package toolchain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/dto"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// ToolchainService manages the toolchains registered by each legal entity, and resolves the toolchain
+// references in jobs to the images the jobs should run in. When a new version of a toolchain is registered
+// a models.ToolchainVersionAvailableEvent is published for each repo whose jobs use the toolchain.
+type ToolchainService struct {
+	db             *store.DB
+	toolchainStore store.ToolchainStore
+	ownershipStore store.OwnershipStore
+	buildStore     store.BuildStore
+	repoStore      store.RepoStore
+	jobStore       store.JobStore
+	eventService   services.EventService
+	logger.Log
+}
+
+func NewToolchainService(
+	db *store.DB,
+	toolchainStore store.ToolchainStore,
+	ownershipStore store.OwnershipStore,
+	buildStore store.BuildStore,
+	repoStore store.RepoStore,
+	jobStore store.JobStore,
+	eventService services.EventService,
+	logFactory logger.LogFactory,
+) *ToolchainService {
+	return &ToolchainService{
+		db:             db,
+		toolchainStore: toolchainStore,
+		ownershipStore: ownershipStore,
+		buildStore:     buildStore,
+		repoStore:      repoStore,
+		jobStore:       jobStore,
+		eventService:   eventService,
+		Log:            logFactory("ToolchainService"),
+	}
+}
+
+// Register registers a new version of a toolchain for a legal entity. Registering a version that already
+// exists with the same image and digest is a no-op that returns the existing toolchain; registering it
+// with a different image or digest fails with an already exists error, since versions can't be changed.
+func (s *ToolchainService) Register(ctx context.Context, txOrNil *store.Tx, register dto.RegisterToolchain) (*models.Toolchain, error) {
+	return s.register(ctx, txOrNil, register, nil, nil)
+}
+
+// RegisterForBuild registers a new version of a toolchain for the legal entity that owns a build's repo.
+// The build's repo is recorded as the toolchain's source repo, so that if a Dockerfile is specified a new
+// version is automatically built whenever it changes. See Register for details.
+func (s *ToolchainService) RegisterForBuild(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, register dto.RegisterToolchain) (*models.Toolchain, error) {
+	build, err := s.buildStore.Read(ctx, txOrNil, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading build: %w", err)
+	}
+	repo, err := s.repoStore.Read(ctx, txOrNil, build.RepoID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repo: %w", err)
+	}
+	register.LegalEntityID = repo.LegalEntityID
+	return s.register(ctx, txOrNil, register, &buildID, &repo.ID)
+}
+
+func (s *ToolchainService) register(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	register dto.RegisterToolchain,
+	buildID *models.BuildID,
+	sourceRepoID *models.RepoID,
+) (*models.Toolchain, error) {
+	now := models.NewTime(time.Now())
+	toolchain := models.NewToolchain(
+		now,
+		register.LegalEntityID,
+		register.Name,
+		register.Version,
+		register.Image,
+		register.Digest,
+		buildID,
+		sourceRepoID,
+		register.Dockerfile,
+		register.RebuildWorkflow)
+	err := toolchain.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		existing, err := s.toolchainStore.ReadByReference(ctx, tx, toolchain.LegalEntityID, toolchain.Reference())
+		if err == nil {
+			if existing.Image != toolchain.Image || existing.Digest != toolchain.Digest {
+				return gerror.NewErrAlreadyExists(fmt.Sprintf(
+					"Toolchain %s is already registered with a different image; register a new version instead", toolchain.Reference()))
+			}
+			*toolchain = *existing
+			return nil
+		}
+		if !gerror.IsNotFound(err) {
+			return fmt.Errorf("error reading toolchain: %w", err)
+		}
+		err = s.toolchainStore.Create(ctx, tx, toolchain)
+		if err != nil {
+			return fmt.Errorf("error creating toolchain: %w", err)
+		}
+		ownership := models.NewOwnership(now, toolchain.LegalEntityID.ResourceID, toolchain.GetID())
+		err = s.ownershipStore.Create(ctx, tx, ownership)
+		if err != nil {
+			return fmt.Errorf("error creating ownership: %w", err)
+		}
+		s.Infof("Registered toolchain %s (%q) for %q with image %q", toolchain.Reference(), toolchain.ID, toolchain.LegalEntityID, toolchain.ImageReference())
+		return s.notifyDependentRepos(ctx, tx, toolchain)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toolchain, nil
+}
+
+// notifyDependentRepos publishes a models.ToolchainVersionAvailableEvent for each repo whose jobs have used
+// another version of a newly registered toolchain. Each event is published against the latest job in the repo
+// that used the toolchain.
+func (s *ToolchainService) notifyDependentRepos(ctx context.Context, tx *store.Tx, toolchain *models.Toolchain) error {
+	jobs, err := s.jobStore.ListLatestByToolchainName(ctx, tx, toolchain.LegalEntityID, toolchain.Name)
+	if err != nil {
+		return fmt.Errorf("error listing jobs using toolchain: %w", err)
+	}
+	notified := make(map[models.RepoID]bool)
+	for _, job := range jobs {
+		// LIKE treats underscores in the name as wildcards, so double check the job really uses this toolchain
+		name, _, _ := strings.Cut(job.DockerToolchain, "@")
+		if name != toolchain.Name.String() || job.DockerToolchain == toolchain.Reference().String() || notified[job.RepoID] {
+			continue
+		}
+		err = s.eventService.PublishEvent(ctx, tx, models.NewToolchainVersionAvailableEventData(job, toolchain))
+		if err != nil {
+			return fmt.Errorf("error publishing toolchain version available event: %w", err)
+		}
+		notified[job.RepoID] = true
+	}
+	return nil
+}
+
+// ListRebuiltOnChangeBySourceRepoID lists the latest version of each toolchain that is built from a Dockerfile
+// in the specified repo, and should be rebuilt when its Dockerfile changes.
+func (s *ToolchainService) ListRebuiltOnChangeBySourceRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) ([]*models.Toolchain, error) {
+	toolchains, err := s.toolchainStore.ListBySourceRepoID(ctx, txOrNil, repoID)
+	if err != nil {
+		return nil, err
+	}
+	// Only the latest version of each toolchain is relevant; earlier versions may have been built from
+	// a Dockerfile that has since moved, or the toolchain may no longer be rebuilt automatically
+	latest := make(map[models.ResourceName]*models.Toolchain)
+	var names []models.ResourceName
+	for _, toolchain := range toolchains {
+		current, ok := latest[toolchain.Name]
+		if !ok {
+			names = append(names, toolchain.Name)
+		}
+		if !ok || toolchain.CreatedAt.After(current.CreatedAt.Time) {
+			latest[toolchain.Name] = toolchain
+		}
+	}
+	var results []*models.Toolchain
+	for _, name := range names {
+		if latest[name].IsRebuiltOnChange() {
+			results = append(results, latest[name])
+		}
+	}
+	return results, nil
+}
+
+// Read an existing toolchain, looking it up by ID.
+// Returns models.ErrNotFound if the toolchain does not exist.
+func (s *ToolchainService) Read(ctx context.Context, txOrNil *store.Tx, id models.ToolchainID) (*models.Toolchain, error) {
+	return s.toolchainStore.Read(ctx, txOrNil, id)
+}
+
+// Resolve finds the toolchain a job refers to, looking it up by reference amongst the toolchains
+// belonging to the specified legal entity (the owner of the job's repo).
+// Returns a validation failed error if no such toolchain is registered.
+func (s *ToolchainService) Resolve(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, ref models.ToolchainReference) (*models.Toolchain, error) {
+	toolchain, err := s.toolchainStore.ReadByReference(ctx, txOrNil, legalEntityID, ref)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Toolchain %s is not registered for the repo's owner", ref))
+		}
+		return nil, fmt.Errorf("error reading toolchain: %w", err)
+	}
+	return toolchain, nil
+}
+
+// Delete permanently and idempotently deletes a toolchain, identifying it by ID. Jobs that refer to the
+// toolchain and haven't yet been run will fail.
+func (s *ToolchainService) Delete(ctx context.Context, txOrNil *store.Tx, id models.ToolchainID) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := s.toolchainStore.Delete(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error deleting toolchain: %w", err)
+		}
+		err = s.ownershipStore.Delete(ctx, tx, id.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error deleting ownership: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListByLegalEntityID lists the toolchains belonging to a legal entity. Use cursor to page through results, if any.
+func (s *ToolchainService) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Toolchain, *models.Cursor, error) {
+	return s.toolchainStore.ListByLegalEntityID(ctx, txOrNil, legalEntityID, pagination)
+}
@@ -0,0 +1,36 @@
+package toolchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// RebuildToolchainsWorkItem is a work item that will check whether a build changed the Dockerfile of any
+// toolchain built from the build's repo, and enqueue a build of a new version of each toolchain that changed.
+const RebuildToolchainsWorkItem models.WorkItemType = "RebuildToolchains"
+
+// RebuildToolchainsWorkItemData is serialized to JSON and stored in the Data field of a RebuildToolchainsWorkItem.
+type RebuildToolchainsWorkItemData struct {
+	BuildID models.BuildID
+}
+
+func NewRebuildToolchainsWorkItem(build *models.Build) *models.WorkItem {
+	data := &RebuildToolchainsWorkItemData{
+		BuildID: build.ID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in RebuildToolchainsWorkItemData definition
+		panic("Unable to marshal RebuildToolchainsWorkItemData object to JSON")
+	}
+	return models.NewWorkItem(RebuildToolchainsWorkItem, string(dataJson), makeRebuildConcurrencyKey(build.RepoID), models.NewTime(time.Now()))
+}
+
+// makeRebuildConcurrencyKey returns a concurrency key per repo, so that two builds of the same commit can't
+// race to enqueue duplicate rebuilds of a toolchain.
+func makeRebuildConcurrencyKey(repoID models.RepoID) models.WorkItemConcurrencyKey {
+	return models.NewWorkItemConcurrencyKey(fmt.Sprintf("toolchain-rebuild/%s", repoID))
+}
@@ -0,0 +1,337 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+// maxSummaryMonths is the largest number of months that can be included in a usage summary.
+const maxSummaryMonths = 36
+
+// UsageService accounts for the resources each legal entity consumes, and enforces monthly quotas on them.
+// Build seconds are recorded when a job finishes, log bytes when a build, job or step finishes and its log
+// has been sealed, and artifact bytes when an artifact's data has been stored. Usage is recorded at most once
+// for each source resource and metric, so a resource is never double-counted.
+type UsageService struct {
+	db               *store.DB
+	usageRecordStore store.UsageRecordStore
+	usageQuotaStore  store.UsageQuotaStore
+	repoStore        store.RepoStore
+	buildStore       store.BuildStore
+	jobStore         store.JobStore
+	stepStore        store.StepStore
+	logStore         store.LogStore
+	logger.Log
+}
+
+func NewUsageService(
+	db *store.DB,
+	usageRecordStore store.UsageRecordStore,
+	usageQuotaStore store.UsageQuotaStore,
+	repoStore store.RepoStore,
+	buildStore store.BuildStore,
+	jobStore store.JobStore,
+	stepStore store.StepStore,
+	logStore store.LogStore,
+	artifactService services.ArtifactService,
+	eventService services.EventService,
+	logFactory logger.LogFactory,
+) *UsageService {
+	s := &UsageService{
+		db:               db,
+		usageRecordStore: usageRecordStore,
+		usageQuotaStore:  usageQuotaStore,
+		repoStore:        repoStore,
+		buildStore:       buildStore,
+		jobStore:         jobStore,
+		stepStore:        stepStore,
+		logStore:         logStore,
+		Log:              logFactory("UsageService"),
+	}
+
+	artifactService.RegisterUploadHandler(s.onArtifactUploaded)
+	eventService.Subscribe(models.BuildStatusChangedEvent, s.onBuildStatusChanged)
+	eventService.Subscribe(models.JobStatusChangedEvent, s.onJobStatusChanged)
+	eventService.Subscribe(models.StepStatusChangedEvent, s.onStepStatusChanged)
+
+	return s
+}
+
+// CheckQuotas returns a quota exceeded error if the legal entity has reached any of its quotas for the
+// current month, or nil if the legal entity can use more resources.
+func (s *UsageService) CheckQuotas(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) error {
+	quotas, err := s.ListQuotas(ctx, txOrNil, legalEntityID)
+	if err != nil {
+		return err
+	}
+	if len(quotas) == 0 {
+		return nil
+	}
+	period := models.UsagePeriodOf(time.Now())
+	rollups, err := s.usageRecordStore.Rollup(ctx, txOrNil, legalEntityID, period, period)
+	if err != nil {
+		return fmt.Errorf("error totalling usage: %w", err)
+	}
+	totals := make(models.UsageTotals)
+	for _, rollup := range rollups {
+		totals[rollup.Metric] += rollup.Quantity
+	}
+	for _, quota := range quotas {
+		if totals[quota.Metric] >= quota.MonthlyLimit {
+			return gerror.NewErrQuotaExceeded(fmt.Sprintf("Monthly %s quota of %d exceeded for %s",
+				quota.Metric, quota.MonthlyLimit, period))
+		}
+	}
+	return nil
+}
+
+// SetQuota limits the monthly usage of a metric by a legal entity. If the legal entity already has a quota
+// for the metric then the existing quota is updated and returned.
+func (s *UsageService) SetQuota(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, metric models.UsageMetric, monthlyLimit int64) (*models.UsageQuota, error) {
+	now := models.NewTime(time.Now())
+	newQuota := models.NewUsageQuota(now, legalEntityID, metric, monthlyLimit)
+	err := newQuota.Validate()
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(err.Error())
+	}
+	var quota *models.UsageQuota
+	err = s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		existing, err := s.usageQuotaStore.ReadByMetric(ctx, tx, legalEntityID, metric)
+		if gerror.IsNotFound(err) {
+			err = s.usageQuotaStore.Create(ctx, tx, newQuota)
+			if err != nil {
+				return fmt.Errorf("error creating quota: %w", err)
+			}
+			quota = newQuota
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading quota: %w", err)
+		}
+		existing.MonthlyLimit = monthlyLimit
+		existing.UpdatedAt = now
+		err = s.usageQuotaStore.Update(ctx, tx, existing)
+		if err != nil {
+			return fmt.Errorf("error updating quota: %w", err)
+		}
+		quota = existing
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Infof("Set monthly %s quota for legal entity %s to %d", metric, legalEntityID, monthlyLimit)
+	return quota, nil
+}
+
+// DeleteQuota permanently and idempotently removes the legal entity's quota for a metric, if any.
+func (s *UsageService) DeleteQuota(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, metric models.UsageMetric) error {
+	return s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		quota, err := s.usageQuotaStore.ReadByMetric(ctx, tx, legalEntityID, metric)
+		if err != nil {
+			if gerror.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("error reading quota: %w", err)
+		}
+		err = s.usageQuotaStore.Delete(ctx, tx, quota.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting quota: %w", err)
+		}
+		s.Infof("Removed monthly %s quota for legal entity %s", metric, legalEntityID)
+		return nil
+	})
+}
+
+// ListQuotas lists all quotas of a legal entity.
+func (s *UsageService) ListQuotas(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) ([]*models.UsageQuota, error) {
+	var quotas []*models.UsageQuota
+	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+	for moreResults := true; moreResults; {
+		quotasPage, cursor, err := s.usageQuotaStore.ListByLegalEntityID(ctx, txOrNil, legalEntityID, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("error listing quotas: %w", err)
+		}
+		quotas = append(quotas, quotasPage...)
+		if cursor != nil && cursor.Next != nil {
+			pagination.Cursor = cursor.Next // move on to next page of results
+		} else {
+			moreResults = false
+		}
+	}
+	return quotas, nil
+}
+
+// Summary summarizes the usage of a legal entity for each month from 'from' to 'to' inclusive, in total and
+// by repo, along with the legal entity's quotas.
+func (s *UsageService) Summary(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, from models.UsagePeriod, to models.UsagePeriod) (*models.UsageSummary, error) {
+	periods, err := periodsBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := s.usageRecordStore.Rollup(ctx, txOrNil, legalEntityID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error totalling usage: %w", err)
+	}
+	quotas, err := s.ListQuotas(ctx, txOrNil, legalEntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	months := make(map[models.UsagePeriod]*models.MonthlyUsage, len(periods))
+	summary := &models.UsageSummary{
+		LegalEntityID: legalEntityID,
+		Months:        make([]*models.MonthlyUsage, 0, len(periods)),
+		Quotas:        quotas,
+	}
+	for _, period := range periods {
+		month := &models.MonthlyUsage{
+			Period: period,
+			Totals: make(models.UsageTotals),
+			Repos:  []*models.RepoUsage{},
+		}
+		months[period] = month
+		summary.Months = append(summary.Months, month)
+	}
+	// Rollups are ordered by period and then by repo, so each repo's rollups are adjacent
+	for _, rollup := range rollups {
+		month, ok := months[rollup.Period]
+		if !ok {
+			continue
+		}
+		month.Totals[rollup.Metric] += rollup.Quantity
+		if len(month.Repos) == 0 || month.Repos[len(month.Repos)-1].RepoID != rollup.RepoID {
+			month.Repos = append(month.Repos, &models.RepoUsage{RepoID: rollup.RepoID, Totals: make(models.UsageTotals)})
+		}
+		month.Repos[len(month.Repos)-1].Totals[rollup.Metric] += rollup.Quantity
+	}
+	return summary, nil
+}
+
+// periodsBetween returns each usage period from 'from' to 'to' inclusive, in order.
+func periodsBetween(from models.UsagePeriod, to models.UsagePeriod) ([]models.UsagePeriod, error) {
+	start, err := time.Parse("2006-01", from.String())
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid start month %q", from))
+	}
+	end, err := time.Parse("2006-01", to.String())
+	if err != nil {
+		return nil, gerror.NewErrValidationFailed(fmt.Sprintf("Invalid end month %q", to))
+	}
+	if end.Before(start) {
+		return nil, gerror.NewErrValidationFailed("The end month must not be before the start month")
+	}
+	var periods []models.UsagePeriod
+	for t := start; !t.After(end); t = t.AddDate(0, 1, 0) {
+		if len(periods) == maxSummaryMonths {
+			return nil, gerror.NewErrValidationFailed(fmt.Sprintf("At most %d months can be summarized at once", maxSummaryMonths))
+		}
+		periods = append(periods, models.UsagePeriodOf(t))
+	}
+	return periods, nil
+}
+
+// onArtifactUploaded records the size of an artifact once its data has been stored.
+func (s *UsageService) onArtifactUploaded(ctx context.Context, tx *store.Tx, artifact *models.Artifact) error {
+	job, err := s.jobStore.Read(ctx, tx, artifact.JobID)
+	if err != nil {
+		return fmt.Errorf("error reading job for artifact: %w", err)
+	}
+	return s.record(ctx, tx, job.RepoID, artifact.ID.ResourceID, models.UsageMetricArtifactBytes, int64(artifact.Size))
+}
+
+// onBuildStatusChanged records the size of a build's log once the build has finished.
+func (s *UsageService) onBuildStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	if !models.WorkflowStatus(event.Payload).HasFinished() {
+		return nil
+	}
+	build, err := s.buildStore.Read(ctx, tx, models.BuildIDFromResourceID(event.ResourceID))
+	if err != nil {
+		return fmt.Errorf("error reading build: %w", err)
+	}
+	return s.recordLog(ctx, tx, build.RepoID, build.LogDescriptorID)
+}
+
+// onJobStatusChanged records the time a job spent running, and the size of its log, once the job has finished.
+func (s *UsageService) onJobStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	if !models.WorkflowStatus(event.Payload).HasFinished() {
+		return nil
+	}
+	job, err := s.jobStore.Read(ctx, tx, models.JobIDFromResourceID(event.ResourceID))
+	if err != nil {
+		return fmt.Errorf("error reading job: %w", err)
+	}
+	// Jobs that never started running (e.g. skipped or canceled while queued) used no build time
+	finishedAt := job.Timings.FinishedAt
+	if finishedAt == nil {
+		finishedAt = job.Timings.CanceledAt
+	}
+	if job.Timings.RunningAt != nil && finishedAt != nil {
+		seconds := int64(finishedAt.Sub(job.Timings.RunningAt.Time).Round(time.Second) / time.Second)
+		if seconds < 0 {
+			seconds = 0
+		}
+		err = s.record(ctx, tx, job.RepoID, job.ID.ResourceID, models.UsageMetricBuildSeconds, seconds)
+		if err != nil {
+			return err
+		}
+	}
+	return s.recordLog(ctx, tx, job.RepoID, job.LogDescriptorID)
+}
+
+// onStepStatusChanged records the size of a step's log once the step has finished.
+func (s *UsageService) onStepStatusChanged(ctx context.Context, tx *store.Tx, event *models.Event) error {
+	if !models.WorkflowStatus(event.Payload).HasFinished() {
+		return nil
+	}
+	step, err := s.stepStore.Read(ctx, tx, models.StepIDFromResourceID(event.ResourceID))
+	if err != nil {
+		return fmt.Errorf("error reading step: %w", err)
+	}
+	return s.recordLog(ctx, tx, step.RepoID, step.LogDescriptorID)
+}
+
+// recordLog records the size of a log, if the log has been sealed. The size of a log is only known once
+// it has been sealed.
+func (s *UsageService) recordLog(ctx context.Context, tx *store.Tx, repoID models.RepoID, logDescriptorID models.LogDescriptorID) error {
+	if !logDescriptorID.Valid() {
+		return nil
+	}
+	descriptor, err := s.logStore.Read(ctx, tx, logDescriptorID)
+	if err != nil {
+		return fmt.Errorf("error reading log descriptor: %w", err)
+	}
+	if !descriptor.Sealed {
+		return nil
+	}
+	return s.record(ctx, tx, repoID, descriptor.ID.ResourceID, models.UsageMetricLogBytes, descriptor.SizeBytes)
+}
+
+// record records usage of a metric by a repo's owner, measured from the specified source resource.
+// Usage that has already been recorded for the source resource and metric is not recorded again.
+func (s *UsageService) record(ctx context.Context, tx *store.Tx, repoID models.RepoID, sourceResourceID models.ResourceID, metric models.UsageMetric, quantity int64) error {
+	repo, err := s.repoStore.Read(ctx, tx, repoID)
+	if err != nil {
+		return fmt.Errorf("error reading repo: %w", err)
+	}
+	record := models.NewUsageRecord(models.NewTime(time.Now()), repo.LegalEntityID, repoID, sourceResourceID, metric, quantity)
+	err = record.Validate()
+	if err != nil {
+		return fmt.Errorf("error validating usage record: %w", err)
+	}
+	_, created, err := s.usageRecordStore.FindOrCreate(ctx, tx, record)
+	if err != nil {
+		return fmt.Errorf("error recording %s usage: %w", metric, err)
+	}
+	if created {
+		s.Tracef("Recorded %d %s for %s against legal entity %s", quantity, metric, sourceResourceID, repo.LegalEntityID)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package usage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+func TestUsageService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	company := server_test.CreateCompanyLegalEntity(t, ctx, app, "usage-test-company", "", "")
+	runner := server_test.CreateRunner(t, ctx, app, "", company.ID, nil)
+	repo1 := server_test.CreateNamedRepo(t, ctx, app, "repo-1", company.ID)
+	repo2 := server_test.CreateNamedRepo(t, ctx, app, "repo-2", company.ID)
+	period := models.UsagePeriodOf(time.Now())
+
+	// finishJobs marks each job in a new build as having run for the specified duration
+	finishJobs := func(repo *models.Repo, duration time.Duration) []*models.Job {
+		graph := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, company.ID, "")
+		var jobs []*models.Job
+		for _, job := range graph.Jobs {
+			runningAt := models.NewTime(time.Now().Add(-duration))
+			finishedAt := models.NewTime(runningAt.Add(duration))
+			job.Status = models.WorkflowStatusSucceeded
+			job.Timings.RunningAt = &runningAt
+			job.Timings.FinishedAt = &finishedAt
+			err := app.JobService.Update(ctx, nil, job.Job)
+			require.NoError(t, err)
+			err = app.EventService.PublishEvent(ctx, nil, models.NewJobStatusChangedEventData(job.Job))
+			require.NoError(t, err)
+			jobs = append(jobs, job.Job)
+		}
+		return jobs
+	}
+	currentMonth := func() *models.MonthlyUsage {
+		summary, err := app.UsageService.Summary(ctx, nil, company.ID, period, period)
+		require.NoError(t, err)
+		require.Len(t, summary.Months, 1)
+		require.Equal(t, period, summary.Months[0].Period)
+		return summary.Months[0]
+	}
+
+	t.Run("Record", func(t *testing.T) {
+		jobs1 := finishJobs(repo1, 30*time.Second)
+		jobs2 := finishJobs(repo2, 10*time.Second)
+		require.NotEmpty(t, jobs1)
+		require.NotEmpty(t, jobs2)
+
+		month := currentMonth()
+		require.Equal(t, int64(30*len(jobs1)+10*len(jobs2)), month.Totals[models.UsageMetricBuildSeconds])
+		require.Len(t, month.Repos, 2)
+		for _, repoUsage := range month.Repos {
+			switch {
+			case repoUsage.RepoID.Equal(repo1.ID.ResourceID):
+				require.Equal(t, int64(30*len(jobs1)), repoUsage.Totals[models.UsageMetricBuildSeconds])
+			case repoUsage.RepoID.Equal(repo2.ID.ResourceID):
+				require.Equal(t, int64(10*len(jobs2)), repoUsage.Totals[models.UsageMetricBuildSeconds])
+			default:
+				t.Fatalf("unexpected repo in usage summary: %s", repoUsage.RepoID)
+			}
+		}
+
+		// Usage is only recorded once per job, however many times it finishes
+		for _, job := range jobs1 {
+			err := app.EventService.PublishEvent(ctx, nil, models.NewJobStatusChangedEventData(job))
+			require.NoError(t, err)
+		}
+		require.Equal(t, month.Totals, currentMonth().Totals)
+	})
+
+	t.Run("Summary", func(t *testing.T) {
+		from := models.UsagePeriodOf(time.Now().AddDate(0, -2, 0))
+		summary, err := app.UsageService.Summary(ctx, nil, company.ID, from, period)
+		require.NoError(t, err)
+		require.Len(t, summary.Months, 3)
+		require.Equal(t, from, summary.Months[0].Period)
+		require.Empty(t, summary.Months[0].Totals)
+		require.Empty(t, summary.Months[0].Repos)
+		require.Equal(t, period, summary.Months[2].Period)
+
+		_, err = app.UsageService.Summary(ctx, nil, company.ID, period, from)
+		require.Error(t, err)
+		require.True(t, gerror.IsValidationFailed(err), "the end month must not be before the start month")
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		used := currentMonth().Totals[models.UsageMetricBuildSeconds]
+
+		// Queue a build while the company is under its quota
+		quota, err := app.UsageService.SetQuota(ctx, nil, company.ID, models.UsageMetricBuildSeconds, used+1)
+		require.NoError(t, err)
+		require.NoError(t, app.UsageService.CheckQuotas(ctx, nil, company.ID))
+		graph := server_test.CreateAndQueueBuild(t, ctx, app, repo1.ID, company.ID, "")
+
+		// Setting the quota again updates the existing quota
+		updated, err := app.UsageService.SetQuota(ctx, nil, company.ID, models.UsageMetricBuildSeconds, used)
+		require.NoError(t, err)
+		require.Equal(t, quota.ID, updated.ID)
+		quotas, err := app.UsageService.ListQuotas(ctx, nil, company.ID)
+		require.NoError(t, err)
+		require.Len(t, quotas, 1)
+		require.Equal(t, used, quotas[0].MonthlyLimit)
+
+		err = app.UsageService.CheckQuotas(ctx, nil, company.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsQuotaExceeded(err))
+
+		// New builds are rejected
+		commit := server_test.CreateCommit(t, ctx, app, repo1.ID, company.ID)
+		_, err = app.QueueService.EnqueueBuildFromCommit(ctx, nil, commit, referencedata.TestRef, nil)
+		require.Error(t, err)
+		require.True(t, gerror.IsQuotaExceeded(err))
+
+		// Queued jobs are failed rather than handed to a runner
+		_, err = app.QueueService.Dequeue(ctx, runner.ID)
+		require.Error(t, err)
+		require.True(t, gerror.IsNotFound(err))
+		failed := 0
+		for _, job := range graph.Jobs {
+			job, err := app.JobService.Read(ctx, nil, job.ID)
+			require.NoError(t, err)
+			if job.Status == models.WorkflowStatusFailed {
+				failed++
+				require.NotNil(t, job.Error)
+				require.Contains(t, job.Error.Error(), "quota")
+			}
+		}
+		require.Equal(t, 1, failed)
+
+		// Other legal entities are unaffected
+		otherCompany := server_test.CreateCompanyLegalEntity(t, ctx, app, "usage-test-other", "", "")
+		require.NoError(t, app.UsageService.CheckQuotas(ctx, nil, otherCompany.ID))
+
+		// Removing the quota allows builds to be queued again
+		err = app.UsageService.DeleteQuota(ctx, nil, company.ID, models.UsageMetricBuildSeconds)
+		require.NoError(t, err)
+		err = app.UsageService.DeleteQuota(ctx, nil, company.ID, models.UsageMetricBuildSeconds)
+		require.NoError(t, err, "deleting a quota is idempotent")
+		require.NoError(t, app.UsageService.CheckQuotas(ctx, nil, company.ID))
+		server_test.CreateAndQueueBuild(t, ctx, app, repo1.ID, company.ID, "")
+	})
+}
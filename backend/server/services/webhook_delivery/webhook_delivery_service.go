@@ -0,0 +1,187 @@
+package webhook_delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/services"
+	"github.com/buildbeaver/buildbeaver/server/services/scm"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const replayWorkItemTimeout = 5 * time.Minute
+
+// unrecordedHeaders are request headers that are not recorded against deliveries, since they may contain
+// credentials added by a proxy in front of the server.
+var unrecordedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+type WebhookDeliveryService struct {
+	db               *store.DB
+	deliveryStore    store.WebhookDeliveryStore
+	scmRegistry      *scm.SCMRegistry
+	workQueueService services.WorkQueueService
+	logger.Log
+}
+
+func NewWebhookDeliveryService(
+	db *store.DB,
+	deliveryStore store.WebhookDeliveryStore,
+	scmRegistry *scm.SCMRegistry,
+	workQueueService services.WorkQueueService,
+	logFactory logger.LogFactory,
+) *WebhookDeliveryService {
+	s := &WebhookDeliveryService{
+		db:               db,
+		deliveryStore:    deliveryStore,
+		scmRegistry:      scmRegistry,
+		workQueueService: workQueueService,
+		Log:              logFactory("WebhookDeliveryService"),
+	}
+
+	// Register the code to process work items for replaying deliveries
+	err := s.workQueueService.RegisterHandler(
+		WebhookDeliveryReplayWorkItem,
+		s.ProcessReplayWorkItem,
+		replayWorkItemTimeout,
+		work_queue.ExponentialBackoff(5, 10*time.Second, 5*time.Minute),
+		true,  // keep failed work items
+		false, // don't keep successful work items
+	)
+	if err != nil {
+		panic(fmt.Sprintf("error registering work item handler: %s", err.Error()))
+	}
+
+	return s
+}
+
+// Receive records a webhook received from an SCM and then processes it. The delivery is recorded even if
+// processing fails, so that it can be inspected and replayed later.
+// Returns the recorded delivery, along with the error from processing it if any. Returns models.ErrNotFound
+// and records nothing if the SCM does not exist.
+func (s *WebhookDeliveryService) Receive(ctx context.Context, scmName models.SystemName, headers http.Header, payload []byte) (*models.WebhookDelivery, error) {
+	scm, err := s.scmRegistry.Get(scmName)
+	if err != nil {
+		return nil, err
+	}
+	headers = headers.Clone()
+	for _, header := range unrecordedHeaders {
+		headers.Del(header)
+	}
+	delivery := models.NewWebhookDelivery(models.NewTime(time.Now()), scmName, headers, payload)
+	err = s.deliveryStore.Create(ctx, nil, delivery)
+	if err != nil {
+		return nil, fmt.Errorf("error recording webhook delivery: %w", err)
+	}
+	return delivery, s.process(ctx, scm, delivery)
+}
+
+// Read an existing webhook delivery, looking it up by ID.
+// Returns models.ErrNotFound if the delivery does not exist.
+func (s *WebhookDeliveryService) Read(ctx context.Context, txOrNil *store.Tx, id models.WebhookDeliveryID) (*models.WebhookDelivery, error) {
+	return s.deliveryStore.Read(ctx, txOrNil, id)
+}
+
+// Search all webhook deliveries, most recent first. Use cursor to page through results, if any.
+func (s *WebhookDeliveryService) Search(ctx context.Context, txOrNil *store.Tx, search *models.WebhookDeliverySearch) ([]*models.WebhookDelivery, *models.Cursor, error) {
+	err := search.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.deliveryStore.Search(ctx, txOrNil, search)
+}
+
+// Replay queues a delivery to be processed again, for example after fixing the problem that caused it to fail.
+// Returns a validation error if the delivery is still pending.
+func (s *WebhookDeliveryService) Replay(ctx context.Context, txOrNil *store.Tx, id models.WebhookDeliveryID) (*models.WebhookDelivery, error) {
+	var delivery *models.WebhookDelivery
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		var err error
+		delivery, err = s.deliveryStore.Read(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("error reading webhook delivery: %w", err)
+		}
+		if delivery.Status == models.WebhookDeliveryStatusPending {
+			return gerror.NewErrValidationFailed("Delivery is already pending")
+		}
+		delivery.Status = models.WebhookDeliveryStatusPending
+		delivery.UpdatedAt = models.NewTime(time.Now())
+		err = s.deliveryStore.Update(ctx, tx, delivery)
+		if err != nil {
+			return fmt.Errorf("error updating webhook delivery: %w", err)
+		}
+		err = s.workQueueService.AddWorkItem(ctx, tx, NewWebhookDeliveryReplayWorkItem(delivery.ID))
+		if err != nil {
+			return fmt.Errorf("error queueing webhook delivery replay work item: %w", err)
+		}
+		s.Infof("Queued replay of webhook delivery %q", delivery.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ProcessReplayWorkItem is a work item handler that processes a previously received webhook delivery again,
+// and records the outcome against the delivery.
+func (s *WebhookDeliveryService) ProcessReplayWorkItem(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+	workItemData := &WebhookDeliveryReplayWorkItemData{}
+	err = json.Unmarshal([]byte(workItem.Data), workItemData)
+	if err != nil {
+		return false, fmt.Errorf("error unmarshaling webhook delivery replay work item data: %w", err)
+	}
+	delivery, err := s.deliveryStore.Read(ctx, nil, workItemData.DeliveryID)
+	if err != nil {
+		if gerror.IsNotFound(err) {
+			s.Infof("Dropping replay of deleted webhook delivery %q", workItemData.DeliveryID)
+			return false, nil
+		}
+		return true, fmt.Errorf("error reading webhook delivery: %w", err)
+	}
+	if delivery.Status != models.WebhookDeliveryStatusPending {
+		return false, nil
+	}
+	scm, err := s.scmRegistry.Get(delivery.SCMName)
+	if err != nil {
+		return false, s.recordOutcome(ctx, delivery, err)
+	}
+	// Failures are recorded against the delivery rather than retried, so it can be inspected and replayed again
+	return false, s.process(ctx, scm, delivery)
+}
+
+// process passes a delivery to the SCM it was received from, and records the outcome against the delivery.
+// Returns the error from processing the delivery, if any.
+func (s *WebhookDeliveryService) process(ctx context.Context, scm scm.SCM, delivery *models.WebhookDelivery) error {
+	err := scm.HandleWebhook(ctx, delivery)
+	return s.recordOutcome(ctx, delivery, err)
+}
+
+// recordOutcome records the outcome of processing a delivery, where processErr is the error from processing it
+// or nil if it was processed successfully. Returns processErr.
+// Errors recording the outcome are logged rather than returned, so that they don't mask the outcome itself.
+func (s *WebhookDeliveryService) recordOutcome(ctx context.Context, delivery *models.WebhookDelivery, processErr error) error {
+	now := models.NewTime(time.Now())
+	delivery.Attempts++
+	delivery.ProcessedAt = &now
+	delivery.UpdatedAt = now
+	if processErr != nil {
+		delivery.Status = models.WebhookDeliveryStatusFailed
+		delivery.Error = processErr.Error()
+		s.Warnf("Error processing webhook delivery %q from %s: %v", delivery.ID, delivery.SCMName, processErr)
+	} else {
+		delivery.Status = models.WebhookDeliveryStatusSucceeded
+		delivery.Error = ""
+	}
+	err := s.deliveryStore.Update(ctx, nil, delivery)
+	if err != nil {
+		s.Errorf("Error recording outcome of webhook delivery %q: %v", delivery.ID, err)
+	}
+	return processErr
+}
@@ -0,0 +1,115 @@
+package webhook_delivery_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/gerror"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/services/scm/fake_scm"
+	"github.com/buildbeaver/buildbeaver/server/services/work_queue"
+)
+
+const testReplayTimeout = 30 * time.Second
+
+func TestWebhookDeliveryService(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	fakeSCM, err := app.SCMRegistry.Get(fake_scm.FakeSCMName)
+	require.NoError(t, err)
+	var broken atomic.Bool
+	broken.Store(true)
+	var handled int32
+	fakeSCM.(*fake_scm.FakeSCMService).SetWebhookHandler(func(ctx context.Context, delivery *models.WebhookDelivery) error {
+		atomic.AddInt32(&handled, 1)
+		delivery.EventType = delivery.Headers.Get("X-Event")
+		if broken.Load() {
+			return errors.New("webhook handler is broken")
+		}
+		return nil
+	})
+
+	headers := http.Header{}
+	headers.Set("X-Event", "push")
+	headers.Set("Authorization", "Bearer secret")
+
+	// Deliveries for unknown SCMs are not recorded
+	_, err = app.WebhookDeliveryService.Receive(ctx, "unknown-scm", headers, []byte(`{}`))
+	require.True(t, gerror.IsNotFound(err))
+
+	// Deliveries that fail to process are recorded as failed, without credentials
+	delivery, err := app.WebhookDeliveryService.Receive(ctx, fake_scm.FakeSCMName, headers, []byte(`{"ref":"main"}`))
+	require.Error(t, err)
+	require.NotNil(t, delivery)
+	delivery, err = app.WebhookDeliveryService.Read(ctx, nil, delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.WebhookDeliveryStatusFailed, delivery.Status)
+	require.Equal(t, "push", delivery.EventType)
+	require.Equal(t, `{"ref":"main"}`, delivery.Payload)
+	require.Equal(t, "push", delivery.Headers.Get("X-Event"))
+	require.Empty(t, delivery.Headers.Get("Authorization"))
+	require.Contains(t, delivery.Error, "webhook handler is broken")
+	require.Equal(t, 1, delivery.Attempts)
+	require.NotNil(t, delivery.ProcessedAt)
+
+	_, err = app.WebhookDeliveryService.Receive(ctx, fake_scm.FakeSCMName, headers, []byte(`{}`))
+	require.Error(t, err)
+
+	// Successful deliveries are recorded as succeeded
+	broken.Store(false)
+	succeeded, err := app.WebhookDeliveryService.Receive(ctx, fake_scm.FakeSCMName, headers, []byte(`{}`))
+	require.NoError(t, err)
+	succeeded, err = app.WebhookDeliveryService.Read(ctx, nil, succeeded.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.WebhookDeliveryStatusSucceeded, succeeded.Status)
+	require.Empty(t, succeeded.Error)
+
+	// Deliveries can be searched by status
+	search := models.NewWebhookDeliverySearch()
+	status := models.WebhookDeliveryStatusFailed
+	search.Status = &status
+	failedDeliveries, _, err := app.WebhookDeliveryService.Search(ctx, nil, search)
+	require.NoError(t, err)
+	require.Len(t, failedDeliveries, 2)
+	for _, failed := range failedDeliveries {
+		require.Equal(t, models.WebhookDeliveryStatusFailed, failed.Status)
+	}
+
+	// Replaying a failed delivery processes it again in the background
+	replayed, err := app.WebhookDeliveryService.Replay(ctx, nil, delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.WebhookDeliveryStatusPending, replayed.Status)
+
+	// A delivery can't be replayed again until the replay has finished
+	_, err = app.WebhookDeliveryService.Replay(ctx, nil, delivery.ID)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	workQueue.Start()
+	defer workQueue.Shutdown()
+
+	deadline := time.Now().Add(testReplayTimeout)
+	for time.Now().Before(deadline) {
+		replayed, err = app.WebhookDeliveryService.Read(ctx, nil, delivery.ID)
+		require.NoError(t, err)
+		if replayed.Status != models.WebhookDeliveryStatusPending {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, models.WebhookDeliveryStatusSucceeded, replayed.Status)
+	require.Empty(t, replayed.Error)
+	require.Equal(t, 2, replayed.Attempts)
+	require.Equal(t, int32(4), atomic.LoadInt32(&handled))
+}
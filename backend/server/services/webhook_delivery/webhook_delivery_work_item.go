@@ -0,0 +1,33 @@
+package webhook_delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// WebhookDeliveryReplayWorkItem is a work item that will process a previously received webhook delivery again.
+const WebhookDeliveryReplayWorkItem models.WorkItemType = "WebhookDeliveryReplay"
+
+// WebhookDeliveryReplayWorkItemData is serialized to JSON and stored in the Data field of a
+// WebhookDeliveryReplayWorkItem. The payload itself is read from the delivery when the work item is processed.
+type WebhookDeliveryReplayWorkItemData struct {
+	DeliveryID models.WebhookDeliveryID
+}
+
+func NewWebhookDeliveryReplayWorkItem(deliveryID models.WebhookDeliveryID) *models.WorkItem {
+	data := &WebhookDeliveryReplayWorkItemData{
+		DeliveryID: deliveryID,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		// If this happens we have a bug in WebhookDeliveryReplayWorkItemData definition
+		panic("Unable to marshal WebhookDeliveryReplayWorkItemData object to JSON")
+	}
+
+	concurrencyKey := models.NewWorkItemConcurrencyKey(fmt.Sprintf("webhook-delivery/%s", deliveryID))
+
+	return models.NewWorkItem(WebhookDeliveryReplayWorkItem, string(dataJson), concurrencyKey, models.NewTime(time.Now()))
+}
@@ -0,0 +1,26 @@
+package work_queue
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryAfterError can be returned by a work item handler (along with canRetry true) to retry the work item
+// no earlier than a specific time, for example when an external API's rate limit will reset. The handler's
+// backoff algorithm is not consulted, and the attempt is not counted towards the attempts it allows.
+type RetryAfterError struct {
+	RetryAfter time.Time
+	Err        error
+}
+
+func NewRetryAfterError(retryAfter time.Time, err error) *RetryAfterError {
+	return &RetryAfterError{RetryAfter: retryAfter, Err: err}
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s; retry after %s", e.Err, e.RetryAfter.Format(time.RFC3339))
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
@@ -2,6 +2,7 @@ package work_queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -17,7 +18,8 @@ var (
 	// NrWorkItemProcessors is the number of goroutines to start for processing work items.
 	NrWorkItemProcessors = 10
 
-	// PollInterval determines how often each processor will poll the database for new work items
+	// PollInterval determines how often each processor will poll the database for new work items. Processors
+	// are woken sooner when notified that work items have been added, if the notifier delivers notifications.
 	PollInterval = 2 * time.Second
 
 	// workItemUpdateRetryAttempts is the number of times we will retry when attempting to update
@@ -39,6 +41,7 @@ type WorkQueueService struct {
 	db                 *store.DB
 	workItemStore      store.WorkItemStore
 	workItemStateStore store.WorkItemStateStore
+	notifier           store.Notifier
 
 	// The ID that work queue processor Goroutines will use when allocating work items
 	processorID models.WorkItemProcessorID
@@ -48,12 +51,17 @@ type WorkQueueService struct {
 
 	started, shutdown bool
 	startStopMutex    sync.Mutex
+	// stopListening is called on shutdown to stop listening for notifications of new work items
+	stopListening func()
 
 	// Channel closed when processor goroutines should shut down
 	requestShutdownChan chan bool
 	// Channel that individual processor Goroutines can use to report that they have shut down
 	// by sending their processorNr.
 	shutdownCompleteChan chan int
+	// Channel used by a processor that has found a work item to wake another idle processor, in case
+	// more work items are ready
+	wakeChan chan struct{}
 
 	logger.Log
 }
@@ -62,16 +70,19 @@ func NewWorkQueueService(
 	db *store.DB,
 	workItemStore store.WorkItemStore,
 	workItemStateStore store.WorkItemStateStore,
+	notifier store.Notifier,
 	logFactory logger.LogFactory,
 ) *WorkQueueService {
 	s := &WorkQueueService{
 		db:                   db,
 		workItemStore:        workItemStore,
 		workItemStateStore:   workItemStateStore,
+		notifier:             notifier,
 		processorID:          models.NewWorkItemProcessorID(),
 		handlerRegistrations: make(map[models.WorkItemType]*handlerRegistration),
 		requestShutdownChan:  make(chan bool),
 		shutdownCompleteChan: make(chan int),
+		wakeChan:             make(chan struct{}, 1),
 		Log:                  logFactory("WorkQueueService"),
 	}
 	return s
@@ -91,8 +102,10 @@ func (s *WorkQueueService) Start() {
 	}
 
 	s.Infof("Starting %d work item processor(s) for work queue", NrWorkItemProcessors)
+	notifications, stopListening := s.notifier.Listen(store.WorkItemsNotificationChannel)
+	s.stopListening = stopListening
 	for processorNr := 1; processorNr <= NrWorkItemProcessors; processorNr++ {
-		go s.processorLoop(processorNr)
+		go s.processorLoop(processorNr, notifications)
 	}
 	s.started = true
 }
@@ -115,6 +128,9 @@ func (s *WorkQueueService) Shutdown() {
 		<-s.shutdownCompleteChan
 	}
 
+	if s.stopListening != nil {
+		s.stopListening()
+	}
 	s.Infof("All work item processors shut down successfully")
 	s.shutdown = true
 }
@@ -134,7 +150,12 @@ func (s *WorkQueueService) AddWorkItem(ctx context.Context, txOrNil *store.Tx, w
 
 		// Create the work item itself
 		workItem.StateID = state.ID
-		return s.workItemStore.Create(ctx, tx, workItem)
+		err = s.workItemStore.Create(ctx, tx, workItem)
+		if err != nil {
+			return err
+		}
+		s.notifyWorkItemsReady(ctx, tx)
+		return nil
 	})
 	if err != nil {
 		return err
@@ -144,6 +165,74 @@ func (s *WorkQueueService) AddWorkItem(ctx context.Context, txOrNil *store.Tx, w
 	return nil
 }
 
+// AddOrReplaceWorkItem adds a new Work Item to the queue to be processed, unless a work item of the same type
+// and concurrency key is already queued and has not started processing yet. In that case the queued work item's
+// data is replaced with the new work item's data, so that only the latest data is processed. This is intended
+// for work items that each supersede all earlier ones, such as sending the latest status of something.
+//
+// If no other work item is queued for the concurrency key then processing of the new work item is delayed by
+// at least delay, so that any further work items added during that time are coalesced into it.
+// The work item must have a concurrency key.
+func (s *WorkQueueService) AddOrReplaceWorkItem(ctx context.Context, txOrNil *store.Tx, workItem *models.WorkItem, delay time.Duration) error {
+	if workItem.ConcurrencyKey == "" {
+		return fmt.Errorf("error concurrency key must be supplied to replace work items")
+	}
+	now := models.NewTime(time.Now())
+	replaced := false
+	err := s.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		state, err := s.workItemStateStore.FindOrCreateAndLockRow(ctx, tx,
+			models.NewWorkItemState(now, workItem.ConcurrencyKey))
+		if err != nil {
+			return fmt.Errorf("error finding or creating state record for concurrency key %s: %w", workItem.ConcurrencyKey, err)
+		}
+		queued, err := s.workItemStore.ListUncompleted(ctx, tx, state.ID)
+		if err != nil {
+			return fmt.Errorf("error listing queued work items for concurrency key %s: %w", workItem.ConcurrencyKey, err)
+		}
+
+		// The oldest uncompleted work item is the one being processed if the state is currently allocated,
+		// and can't be replaced; any later work item can be.
+		isProcessing := state.AllocatedTo != nil && state.AllocatedUntil != nil && state.AllocatedUntil.After(now.Time)
+		if len(queued) > 0 {
+			latest := queued[len(queued)-1]
+			if latest.Type == workItem.Type && !(isProcessing && len(queued) == 1) {
+				latest.Data = workItem.Data
+				err = s.workItemStore.Update(ctx, tx, latest)
+				if err != nil {
+					return fmt.Errorf("error replacing data for queued work item: %w", err)
+				}
+				*workItem = *latest
+				replaced = true
+				return nil
+			}
+		} else if delay > 0 {
+			state.NotBefore = models.NewTimePtr(now.Add(delay))
+			err = s.workItemStateStore.Update(ctx, tx, state)
+			if err != nil {
+				return fmt.Errorf("error updating work item state record to delay processing: %w", err)
+			}
+		}
+
+		workItem.StateID = state.ID
+		err = s.workItemStore.Create(ctx, tx, workItem)
+		if err != nil {
+			return err
+		}
+		s.notifyWorkItemsReady(ctx, tx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if replaced {
+		s.Tracef("Replaced data for queued work item %q of type %q", workItem.ID, workItem.Type)
+	} else {
+		s.Tracef("Queued work item %q of type %q", workItem.ID, workItem.Type)
+	}
+	return nil
+}
+
 // RegisterHandler registers a handler function to process work items of the specified type.
 // Only one handler function can be registered for each type; subsequent calls to RegisterHandler for that
 // type will return an error.
@@ -228,10 +317,28 @@ func (s *WorkQueueService) listAllRegisteredTypes() []models.WorkItemType {
 	return results
 }
 
+// notifyWorkItemsReady notifies processors, in this and any other server, that work items may be ready to process.
+// Errors are logged rather than returned, since processors will find the work items when they next poll.
+func (s *WorkQueueService) notifyWorkItemsReady(ctx context.Context, txOrNil *store.Tx) {
+	err := s.notifier.Notify(ctx, txOrNil, store.WorkItemsNotificationChannel)
+	if err != nil {
+		s.Warnf("Ignoring error notifying work item processors: %v", err)
+	}
+}
+
+// wakeIdleProcessor wakes one idle processor in this server, if any, to look for more work items.
+func (s *WorkQueueService) wakeIdleProcessor() {
+	select {
+	case s.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
 // processorLoop sits in a loop looking for work items to process. Each work item is processed by calling the
-// handler registered for its type.
+// handler registered for its type. When there are no work items ready the processor waits until notified
+// that work items have been added, or until the poll interval has elapsed.
 // The loop terminates and the function returns when requestShutdownChan is closed.
-func (s *WorkQueueService) processorLoop(processorNr int) {
+func (s *WorkQueueService) processorLoop(processorNr int, notifications <-chan struct{}) {
 	for {
 		// Check for shutdown event - continue on immediately unless told to shut down
 		select {
@@ -249,10 +356,17 @@ func (s *WorkQueueService) processorLoop(processorNr int) {
 			workItem = nil
 		}
 		if workItem != nil {
+			// A single notification can announce several work items, so get another processor looking too
+			s.wakeIdleProcessor()
 			s.processWorkItem(workItem)
 		} else {
-			// No work item to process, so poll again after poll interval has elapsed
-			time.Sleep(PollInterval)
+			// No work item to process, so poll again when notified or after poll interval has elapsed
+			select {
+			case <-s.requestShutdownChan:
+			case <-notifications:
+			case <-s.wakeChan:
+			case <-time.After(PollInterval):
+			}
 		}
 	}
 }
@@ -407,10 +521,19 @@ func (s *WorkQueueService) workItemFailed(
 	// This ensures a time gap between attempts, giving other work items a chance to be processed.
 	lastAttemptAt := models.NewTime(time.Now())
 
-	// Run backoff algorithm to decide whether and when to retry.
-	// Use the deep copy of the work item in case the algorithm decides to change it.
-	s.Tracef("Calling backoff algorithm, attemptsSoFar=%d", item.State.AttemptsSoFar)
-	notBeforeTime := backoffAlgorithm(item.State.AttemptsSoFar, lastAttemptAt.Time, workItemCopy)
+	// The handler can ask to retry no earlier than a specific time; this overrides the backoff algorithm,
+	// and the attempt isn't counted towards the number of attempts the backoff algorithm allows
+	var notBeforeTime *time.Time
+	var retryAfterErr *RetryAfterError
+	if errors.As(processingError, &retryAfterErr) {
+		item.State.AttemptsSoFar--
+		notBeforeTime = &retryAfterErr.RetryAfter
+	} else {
+		// Run backoff algorithm to decide whether and when to retry.
+		// Use the deep copy of the work item in case the algorithm decides to change it.
+		s.Tracef("Calling backoff algorithm, attemptsSoFar=%d", item.State.AttemptsSoFar)
+		notBeforeTime = backoffAlgorithm(item.State.AttemptsSoFar, lastAttemptAt.Time, workItemCopy)
+	}
 	if notBeforeTime == nil {
 		// nil means don't retry because there have been too many errors
 		wrappedErr := fmt.Errorf("giving up after %d errors; last error: %w", item.State.AttemptsSoFar, processingError)
@@ -565,6 +688,8 @@ func (s *WorkQueueService) deleteWorkItemAndUpdateOrDeleteState(item *models.Wor
 					}
 					return true, fmt.Errorf("error updating work item state record: %w", err)
 				}
+				// The next work item for the state is now ready to process
+				s.notifyWorkItemsReady(ctx, tx)
 			}
 			return false, nil // success
 		},
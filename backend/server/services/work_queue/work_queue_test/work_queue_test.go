@@ -321,6 +321,86 @@ func TestWorkItemDeleteMixedStateIntegration(t *testing.T) {
 	checkWorkItemResults(env, keepFailedWorkItems, keepSuccessfulWorkItems)
 }
 
+func TestWorkItemCoalescingIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	const workItemTimeout = 10 * time.Second
+	ctx := context.Background()
+	app, cleanUpServer, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanUpServer()
+
+	// The handler records the item number of each work item processed; items asking to fail temporarily
+	// request a retry after a specific time, which must not count towards the backoff algorithm's attempts
+	var (
+		processed      []int
+		processedMutex sync.Mutex
+		retried        = make(map[int]bool)
+	)
+	workQueue := app.WorkQueueService.(*work_queue.WorkQueueService)
+	work_queue.NrWorkItemProcessors = 10 // test concurrency
+	err = workQueue.RegisterHandler(
+		testWorkItem,
+		func(ctx context.Context, workItem *models.WorkItem) (canRetry bool, err error) {
+			workItemData := &testWorkItemData{}
+			err = json.Unmarshal([]byte(workItem.Data), workItemData)
+			if err != nil {
+				return false, err
+			}
+			processedMutex.Lock()
+			defer processedMutex.Unlock()
+			if workItemData.NrTimesToFailTemporarily > 0 && !retried[workItemData.ItemNr] {
+				retried[workItemData.ItemNr] = true
+				return true, work_queue.NewRetryAfterError(time.Now().Add(time.Second), fmt.Errorf("error: rate limited"))
+			}
+			processed = append(processed, workItemData.ItemNr)
+			return false, nil
+		},
+		workItemTimeout,
+		work_queue.NoRetry(),
+		true,
+		true,
+	)
+	require.NoError(t, err)
+
+	// Work items added in quick succession for the same key are coalesced into a single work item with
+	// the latest data, while work items for other keys are independent
+	var lastData *testWorkItemData
+	for i := 0; i < 5; i++ {
+		lastData = &testWorkItemData{}
+		err = workQueue.AddOrReplaceWorkItem(ctx, nil, newTestWorkItem(lastData, "coalesce-key-1"), 3*time.Second)
+		require.NoError(t, err)
+	}
+	rateLimitedData := &testWorkItemData{NrTimesToFailTemporarily: 1}
+	err = workQueue.AddOrReplaceWorkItem(ctx, nil, newTestWorkItem(rateLimitedData, "coalesce-key-2"), 3*time.Second)
+	require.NoError(t, err)
+
+	// Work items without a concurrency key can't be coalesced
+	err = workQueue.AddOrReplaceWorkItem(ctx, nil, newTestWorkItem(&testWorkItemData{}, ""), 0)
+	require.Error(t, err)
+
+	workQueue.Start()
+	deadline := time.Now().Add(time.Minute)
+	for {
+		processedMutex.Lock()
+		nrProcessed := len(processed)
+		processedMutex.Unlock()
+		if nrProcessed >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	// Give any work items that weren't coalesced a chance to be (wrongly) processed
+	time.Sleep(2 * work_queue.PollInterval)
+	workQueue.Shutdown()
+
+	processedMutex.Lock()
+	defer processedMutex.Unlock()
+	require.ElementsMatch(t, []int{lastData.ItemNr, rateLimitedData.ItemNr}, processed)
+}
+
 // makeTestWorkItemHandler returns a work item handler function that can exhibit various test behaviours
 // when processing test work items.
 func makeTestWorkItemHandler(env *testEnvironment) services.WorkItemHandler {
@@ -31,6 +31,7 @@ func (d *ArtifactStore) Create(ctx context.Context, txOrNil *store.Tx, artifactD
 	artifact := &models.Artifact{
 		ArtifactData: *artifactData,
 		ID:           models.NewArtifactID(),
+		ScanStatus:   models.ArtifactScanStatusNotScanned,
 	}
 
 	err := d.table.Create(ctx, txOrNil, artifact)
@@ -66,6 +67,20 @@ func (d *ArtifactStore) Read(ctx context.Context, txOrNil *store.Tx, id models.A
 	return artifact, d.table.ReadByID(ctx, txOrNil, id.ResourceID, artifact)
 }
 
+// ListByJobIDs gets all artifacts created by any of the specified jobs.
+func (d *ArtifactStore) ListByJobIDs(ctx context.Context, txOrNil *store.Tx, jobIDs []models.JobID) ([]*models.Artifact, error) {
+	artifactSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Artifact{}).
+		Where(goqu.Ex{"artifact_job_id": jobIDs})
+	var artifacts []*models.Artifact
+	err := d.table.ListAllIn(ctx, txOrNil, &artifacts, artifactSelect)
+	if err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
 // FindByUniqueFields returns a matching artifact from the fields that are unique within our store.
 func (d *ArtifactStore) FindByUniqueFields(ctx context.Context, txOrNil *store.Tx, artifact *models.ArtifactData) (*models.Artifact, error) {
 	foundArtifact := &models.Artifact{}
@@ -83,6 +98,12 @@ func (d *ArtifactStore) Update(ctx context.Context, txOrNil *store.Tx, artifact
 	return d.table.UpdateByID(ctx, txOrNil, artifact)
 }
 
+// Delete permanently and idempotently deletes an artifact. The artifact's data must be deleted from the
+// blob store separately.
+func (d *ArtifactStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.ArtifactID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
 // Search all artifacts. If searcher is set, the results will be limited to artifacts the searcher is authorized to
 // see (via the read:artifact permission). Use cursor to page through results, if any.
 func (d *ArtifactStore) Search(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, search models.ArtifactSearch) ([]*models.Artifact, *models.Cursor, error) {
@@ -95,6 +116,9 @@ func (d *ArtifactStore) Search(ctx context.Context, txOrNil *store.Tx, searcher
 	if !search.BuildID.IsZero() {
 		jobsJoin = jobsJoin.Where(goqu.Ex{"job_build_id": search.BuildID})
 	}
+	if search.Workflow != nil {
+		jobsJoin = jobsJoin.Where(goqu.Ex{"job_workflow": search.Workflow})
+	}
 	if search.JobName != nil {
 		jobsJoin = jobsJoin.Where(goqu.Ex{"job_name": search.JobName})
 	}
@@ -230,3 +230,59 @@ func TestArtifact(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, artifacts, 0)
 }
+
+func TestArtifactSearchByWorkflow(t *testing.T) {
+	ctx := context.Background()
+
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err)
+	defer cleanup()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+
+	logDescriptor := models.NewLogDescriptor(models.NewTime(time.Now()), models.LogDescriptorID{}, referencedata.ReferenceBuild.ID.ResourceID)
+	err = app.LogStore.Create(context.Background(), nil, logDescriptor)
+	require.NoError(t, err)
+
+	// Two jobs with the same name, one in the default workflow and one in another workflow
+	build := referencedata.GenerateBuild(repo.ID, commit.ID, logDescriptor.ID, "refs/heads/master", 2)
+	build.Jobs[1].Name = build.Jobs[0].Name
+	build.Jobs[1].Workflow = "other"
+	err = app.BuildService.Create(ctx, nil, build.Build)
+	require.NoError(t, err)
+	for _, jGraph := range build.Jobs {
+		err = app.JobService.Create(ctx, nil, &dto.CreateJob{
+			Job:   jGraph.Job,
+			Build: build.Build,
+		})
+		require.NoError(t, err)
+		_, err = app.ArtifactStore.Create(ctx, nil, models.NewArtifactData(
+			models.NewTime(time.Now()),
+			"binary",
+			jGraph.ID,
+			"dist",
+			"dist/binary"))
+		require.NoError(t, err)
+	}
+
+	search := func(path string) []*models.Artifact {
+		search := models.NewArtifactSearch()
+		search.BuildID = build.ID
+		require.NoError(t, search.SetPath(path))
+		artifacts, _, err := app.ArtifactStore.Search(ctx, nil, models.NoIdentity, *search)
+		require.NoError(t, err)
+		return artifacts
+	}
+
+	defaultArtifacts := search(build.Jobs[0].Name.String() + ".dist")
+	require.Len(t, defaultArtifacts, 1)
+	require.Equal(t, build.Jobs[0].ID, defaultArtifacts[0].JobID)
+
+	otherArtifacts := search("other." + build.Jobs[1].Name.String() + ".*")
+	require.Len(t, otherArtifacts, 1)
+	require.Equal(t, build.Jobs[1].ID, otherArtifacts[0].JobID)
+
+	require.Len(t, search("other."+build.Jobs[1].Name.String()+".missing"), 0)
+}
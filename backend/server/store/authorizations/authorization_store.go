@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/pkg/errors"
 
 	"github.com/buildbeaver/buildbeaver/common/models"
@@ -15,6 +16,7 @@ const queryCountGrantsForOperation = `
 WITH RECURSIVE ownership_hierarchy AS (
 	SELECT
 		access_control_ownership_owned_resource_id AS access_control_anchor_id,
+		0 AS access_control_depth,
 		access_control_ownership_id,
 		access_control_ownership_owner_resource_id,
 		access_control_ownership_owned_resource_id
@@ -25,6 +27,7 @@ WITH RECURSIVE ownership_hierarchy AS (
 	UNION ALL
 		SELECT
 			child.access_control_anchor_id,
+			child.access_control_depth + 1,
 			parent.access_control_ownership_id,
 			parent.access_control_ownership_owner_resource_id,
 			parent.access_control_ownership_owned_resource_id
@@ -38,39 +41,65 @@ WITH RECURSIVE ownership_hierarchy AS (
 				child.access_control_ownership_owner_resource_id = parent.access_control_ownership_owned_resource_id
 			AND
 				child.access_control_ownership_id != parent.access_control_ownership_id
+),
+
+matching_grants AS (
+	SELECT
+		access_control_grant_deny,
+		owned_resource.access_control_depth
+	FROM
+		access_control_grants
+	INNER JOIN
+		ownership_hierarchy
+		AS
+			owned_resource
+		ON
+			owned_resource.access_control_ownership_owned_resource_id = access_control_grant_target_resource_id
+	WHERE
+		access_control_grant_operation_name = :access_control_operation_name
+	AND
+		access_control_grant_operation_resource_kind = :access_control_operation_resource_kind
+	AND (
+		-- The identity was granted permission directly
+		access_control_grant_authorized_identity_id = :access_control_authorized_identity_id
+
+		-- Or the legal entity is a member of a group that was granted permission
+		OR (
+			SELECT
+				access_control_group_membership_id
+			FROM
+				access_control_group_memberships
+			WHERE
+				access_control_group_membership_group_id = access_control_grant_authorized_group_id
+			AND
+				access_control_group_membership_member_identity_id = :access_control_authorized_identity_id
+			LIMIT
+				1
+		) IS NOT NULL
+	)
 )
 
 SELECT
 	COUNT(*)
 FROM
-	access_control_grants
-INNER JOIN
-	ownership_hierarchy
+	matching_grants
 	AS
-		owned_resource
-	ON
-		owned_resource.access_control_ownership_owned_resource_id = access_control_grant_target_resource_id
+		allowed
 WHERE
-	access_control_grant_operation_name = :access_control_operation_name
-AND
-	access_control_grant_operation_resource_kind = :access_control_operation_resource_kind
-AND (
-	-- The identity was granted permission directly
-	access_control_grant_authorized_identity_id = :access_control_authorized_identity_id
-
-	-- Or the legal entity is a member of a group that was granted permission
-	OR (
-		SELECT
-			access_control_group_membership_id
-		FROM
-			access_control_group_memberships
-		WHERE
-			access_control_group_membership_group_id = access_control_grant_authorized_group_id
-		AND
-			access_control_group_membership_member_identity_id = :access_control_authorized_identity_id
-		LIMIT
-			1
-	) IS NOT NULL
+	NOT allowed.access_control_grant_deny
+
+-- Grants closer to the target resource take precedence, and a deny wins over an allow at the same level
+AND NOT EXISTS (
+	SELECT
+		1
+	FROM
+		matching_grants
+		AS
+			denied
+	WHERE
+		denied.access_control_grant_deny
+	AND
+		denied.access_control_depth <= allowed.access_control_depth
 )
 `
 
@@ -89,11 +118,43 @@ func WithIsAuthorizedListFilter(
 	operation models.Operation,
 	resourceIDColumnName string) *goqu.SelectDataset {
 
+	// matchingGrants selects the grants for the operation that apply to the identity, either directly or via
+	// group membership, on each resource in the ownership hierarchy anchored at resourceIDColumnName.
+	matchingGrants := func(alias string) *goqu.SelectDataset {
+		grant := func(column string) exp.IdentifierExpression {
+			return goqu.I(alias + "." + column)
+		}
+		memberships := alias + "_memberships"
+		return goqu.From(goqu.T("access_control_grants").As(alias)).
+			InnerJoin(goqu.T("ownership_hierarchy").As(alias+"_resource"),
+				goqu.On(
+					grant("access_control_grant_target_resource_id").
+						Eq(goqu.I(alias+"_resource.access_control_ownership_owned_resource_id")),
+				),
+			).
+			LeftJoin(goqu.T("access_control_group_memberships").As(memberships),
+				goqu.On(
+					grant("access_control_grant_authorized_group_id").
+						Eq(goqu.I(memberships+".access_control_group_membership_group_id")),
+				),
+			).
+			Where(grant("access_control_grant_operation_name").Eq(operation.Name)).
+			Where(grant("access_control_grant_operation_resource_kind").Eq(operation.ResourceKind)).
+			Where(
+				goqu.Or(
+					// The legal entity was granted permission directly
+					grant("access_control_grant_authorized_identity_id").Eq(identityID),
+					// Or the legal entity is a member of a group that was granted permission
+					goqu.I(memberships+".access_control_group_membership_member_identity_id").Eq(identityID),
+				))
+	}
+
 	return dataset.WithRecursive(
 		"ownership_hierarchy",
 		dataset.From("access_control_ownerships").
 			Select(
 				goqu.I("access_control_ownership_owned_resource_id").As("access_control_anchor_id"),
+				goqu.L("0").As("access_control_depth"),
 				goqu.I("access_control_ownership_id"),
 				goqu.I("access_control_ownership_owner_resource_id"),
 				goqu.I("access_control_ownership_owned_resource_id"),
@@ -102,6 +163,7 @@ func WithIsAuthorizedListFilter(
 				dataset.From(goqu.T("access_control_ownerships").As("parent")).
 					Select(
 						goqu.I("child.access_control_anchor_id"),
+						goqu.L("? + 1", goqu.I("child.access_control_depth")),
 						goqu.I("parent.access_control_ownership_id"),
 						goqu.I("parent.access_control_ownership_owner_resource_id"),
 						goqu.I("parent.access_control_ownership_owned_resource_id")).
@@ -113,30 +175,17 @@ func WithIsAuthorizedListFilter(
 					),
 			),
 	).InnerJoin(
-		goqu.Select(
-			goqu.I("owned_resource.access_control_anchor_id").As("access_control_anchor_id")).
-			From(goqu.T("access_control_grants")).
-			InnerJoin(goqu.T("ownership_hierarchy").As("owned_resource"),
-				goqu.On(
-					goqu.I("access_control_grant_target_resource_id").
-						Eq(goqu.I("owned_resource.access_control_ownership_owned_resource_id")),
-				),
-			).
-			LeftJoin(goqu.T("access_control_group_memberships"),
-				goqu.On(
-					goqu.I("access_control_grant_authorized_group_id").
-						Eq(goqu.I("access_control_group_memberships.access_control_group_membership_group_id")),
-				),
-			).
-			Where(goqu.I("access_control_grant_operation_name").Eq(operation.Name)).
-			Where(goqu.I("access_control_grant_operation_resource_kind").Eq(operation.ResourceKind)).
-			Where(
-				goqu.Or(
-					// The legal entity was granted permission directly
-					goqu.I("access_control_grant_authorized_identity_id").Eq(identityID),
-					// Or the legal entity is a member of a group that was granted permission
-					goqu.I("access_control_group_memberships.access_control_group_membership_member_identity_id").Eq(identityID),
-				)).As("access_control"),
+		matchingGrants("allowed").
+			Select(goqu.I("allowed_resource.access_control_anchor_id").As("access_control_anchor_id")).
+			Where(goqu.I("allowed.access_control_grant_deny").Eq(false)).
+			// Grants closer to the resource take precedence, and a deny wins over an allow at the same level
+			Where(goqu.L("NOT EXISTS ?",
+				matchingGrants("denied").
+					Select(goqu.L("1")).
+					Where(goqu.I("denied.access_control_grant_deny").Eq(true)).
+					Where(goqu.I("denied_resource.access_control_anchor_id").Eq(goqu.I("allowed_resource.access_control_anchor_id"))).
+					Where(goqu.I("denied_resource.access_control_depth").Lte(goqu.I("allowed_resource.access_control_depth"))),
+			)).As("access_control"),
 		goqu.On(goqu.I(resourceIDColumnName).Eq(goqu.I("access_control.access_control_anchor_id"))),
 	).Distinct() // do not produce duplicate results if there are multiple ways to gain access to a resource
 }
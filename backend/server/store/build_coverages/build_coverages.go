@@ -0,0 +1,78 @@
+package build_coverages
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.BuildCoverage{})
+}
+
+type BuildCoverageStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *BuildCoverageStore {
+	return &BuildCoverageStore{
+		table: store.NewResourceTable(db, logFactory, &models.BuildCoverage{}),
+	}
+}
+
+// Create a new build coverage.
+// Returns store.ErrAlreadyExists if a build coverage with matching unique properties already exists.
+func (d *BuildCoverageStore) Create(ctx context.Context, txOrNil *store.Tx, buildCoverage *models.BuildCoverage) error {
+	return d.table.Create(ctx, txOrNil, buildCoverage)
+}
+
+// ReadByBuildID reads the coverage for a build.
+// Returns models.ErrNotFound if the build has no coverage.
+func (d *BuildCoverageStore) ReadByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) (*models.BuildCoverage, error) {
+	buildCoverage := &models.BuildCoverage{}
+	return buildCoverage, d.table.ReadWhere(ctx, txOrNil, buildCoverage,
+		goqu.Ex{"build_coverage_build_id": buildID})
+}
+
+// ReadLatestByRef reads the most recently created coverage for a build of the specified ref in a repo,
+// ignoring the coverage for excludeBuildID.
+// Returns models.ErrNotFound if no other build of the ref has coverage.
+func (d *BuildCoverageStore) ReadLatestByRef(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, ref string, excludeBuildID models.BuildID) (*models.BuildCoverage, error) {
+	buildCoverage := &models.BuildCoverage{}
+	ds := goqu.
+		Select(buildCoverage).
+		From(d.table.TableName()).
+		Where(
+			goqu.Ex{
+				"build_coverage_repo_id": repoID,
+				"build_coverage_ref":     ref,
+			},
+			goqu.C("build_coverage_build_id").Neq(excludeBuildID)).
+		Order(goqu.I("build_coverage_created_at").Desc())
+	return buildCoverage, d.table.ReadIn(ctx, txOrNil, buildCoverage, ds)
+}
+
+// Update an existing build coverage. Overrides all previous values using the supplied model.
+func (d *BuildCoverageStore) Update(ctx context.Context, txOrNil *store.Tx, buildCoverage *models.BuildCoverage) error {
+	return d.table.UpdateByID(ctx, txOrNil, buildCoverage)
+}
+
+// ListByRepoID lists the coverage for builds in a repo, newest first.
+// Use cursor to page through results, if any.
+func (d *BuildCoverageStore) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.BuildCoverage, *models.Cursor, error) {
+	buildCoveragesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.BuildCoverage{}).
+		Where(goqu.Ex{"build_coverage_repo_id": repoID})
+
+	var buildCoverages []*models.BuildCoverage
+	cursor, err := d.table.ListIn(ctx, txOrNil, &buildCoverages, pagination, buildCoveragesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buildCoverages, cursor, nil
+}
@@ -0,0 +1,58 @@
+package build_rule_sets
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.BuildRuleSet{})
+	store.MustDBModel(&models.BuildRuleSet{})
+}
+
+type BuildRuleSetStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *BuildRuleSetStore {
+	return &BuildRuleSetStore{
+		table: store.NewResourceTable(db, logFactory, &models.BuildRuleSet{}),
+	}
+}
+
+// Create a new build rule set.
+// Returns store.ErrAlreadyExists if a build rule set with matching unique properties already exists.
+func (d *BuildRuleSetStore) Create(ctx context.Context, txOrNil *store.Tx, ruleSet *models.BuildRuleSet) error {
+	return d.table.Create(ctx, txOrNil, ruleSet)
+}
+
+// Read an existing build rule set, looking it up by ResourceID.
+// Returns models.ErrNotFound if the build rule set does not exist.
+func (d *BuildRuleSetStore) Read(ctx context.Context, txOrNil *store.Tx, id models.BuildRuleSetID) (*models.BuildRuleSet, error) {
+	ruleSet := &models.BuildRuleSet{}
+	return ruleSet, d.table.ReadByID(ctx, txOrNil, id.ResourceID, ruleSet)
+}
+
+// ReadByRepoID reads the build rule set for a repo.
+// Returns models.ErrNotFound if the repo has no build rule set.
+func (d *BuildRuleSetStore) ReadByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) (*models.BuildRuleSet, error) {
+	ruleSet := &models.BuildRuleSet{}
+	return ruleSet, d.table.ReadWhere(ctx, txOrNil, ruleSet,
+		goqu.Ex{"build_rule_set_repo_id": repoID})
+}
+
+// Update an existing build rule set with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *BuildRuleSetStore) Update(ctx context.Context, txOrNil *store.Tx, ruleSet *models.BuildRuleSet) error {
+	return d.table.UpdateByID(ctx, txOrNil, ruleSet)
+}
+
+// Delete permanently and idempotently deletes a build rule set, identifying it by id.
+func (d *BuildRuleSetStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.BuildRuleSetID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
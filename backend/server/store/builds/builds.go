@@ -20,11 +20,13 @@ func init() {
 }
 
 type BuildStore struct {
+	db    *store.DB
 	table *store.ResourceTable
 }
 
 func NewStore(db *store.DB, logFactory logger.LogFactory) *BuildStore {
 	return &BuildStore{
+		db:    db,
 		table: store.NewResourceTable(db, logFactory, &models.Build{}),
 	}
 }
@@ -42,12 +44,106 @@ func (d *BuildStore) Read(ctx context.Context, txOrNil *store.Tx, id models.Buil
 	return build, d.table.ReadByID(ctx, txOrNil, id.ResourceID, build)
 }
 
+// ListByIDs reads the builds with the specified IDs, in no particular order. IDs of builds that do not
+// exist are ignored.
+func (d *BuildStore) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.BuildID) ([]*models.Build, error) {
+	var builds []*models.Build
+	return builds, d.table.ReadByIDs(ctx, txOrNil, ids, &builds)
+}
+
 // Update an existing build with optimistic locking. Overrides all previous values using the supplied model.
 // Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 func (d *BuildStore) Update(ctx context.Context, txOrNil *store.Tx, build *models.Build) error {
 	return d.table.UpdateByID(ctx, txOrNil, build)
 }
 
+// SoftDelete soft deletes an existing build. Soft-deleted builds can't be read or searched for.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *BuildStore) SoftDelete(ctx context.Context, txOrNil *store.Tx, build *models.Build) error {
+	return d.table.SoftDelete(ctx, txOrNil, build)
+}
+
+// Delete permanently and idempotently deletes a build, whether or not it has been soft-deleted. The build's jobs
+// must be deleted first. Any builds cloned from the build have their reference to it cleared; rows in other tables
+// that reference the build with ON DELETE CASCADE (e.g. test results and coverage) are deleted by the database.
+func (d *BuildStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.BuildID) error {
+	return d.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := d.db.Write2(tx, func(writer store.Writer) error {
+			_, err := d.table.LogUpdate(writer.Update(d.table.TableName()).
+				Set(goqu.Record{"build_cloned_from_build_id": nil}).
+				Where(goqu.Ex{"build_cloned_from_build_id": id})).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("error clearing references from cloned builds: %w", store.MakeStandardDBError(err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return d.table.DeleteByID(ctx, tx, id.ResourceID)
+	})
+}
+
+// ListPrunable lists up to limit finished builds that match the specified prune criteria, in order of ID.
+// If after is valid then only builds with IDs after it are listed, to page through the results.
+// Builds with jobs that jobs in other builds were deduplicated to are never listed, since the other builds
+// read results from those jobs.
+func (d *BuildStore) ListPrunable(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	criteria models.BuildPruneCriteria,
+	after models.BuildID,
+	limit int,
+) ([]*models.Build, error) {
+	buildsSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.Build{}).
+		Where(
+			goqu.C("build_created_at").Lt(criteria.CreatedBefore),
+			goqu.Ex{"build_status": []models.WorkflowStatus{
+				models.WorkflowStatusSucceeded,
+				models.WorkflowStatusFailed,
+				models.WorkflowStatusCanceled,
+				models.WorkflowStatusSkipped,
+			}},
+			goqu.L("NOT EXISTS (SELECT 1 FROM jobs AS target_jobs"+
+				" JOIN jobs AS indirect_jobs ON indirect_jobs.job_indirect_to_job_id = target_jobs.job_id"+
+				" WHERE target_jobs.job_build_id = builds.build_id AND indirect_jobs.job_build_id <> builds.build_id)"),
+		).
+		Order(goqu.I("build_id").Asc()).
+		Limit(uint(limit))
+	if !criteria.IncludeDeleted {
+		buildsSelect = buildsSelect.Where(goqu.C("build_deleted_at").IsNull())
+	}
+	if criteria.KeepLast > 0 {
+		buildsSelect = buildsSelect.Where(goqu.L("(SELECT COUNT(*) FROM builds AS newer_builds"+
+			" WHERE newer_builds.build_repo_id = builds.build_repo_id AND newer_builds.build_deleted_at IS NULL"+
+			" AND (newer_builds.build_created_at > builds.build_created_at"+
+			" OR (newer_builds.build_created_at = builds.build_created_at AND newer_builds.build_id > builds.build_id))) >= ?",
+			criteria.KeepLast))
+	}
+	if after.Valid() {
+		buildsSelect = buildsSelect.Where(goqu.C("build_id").Gt(after))
+	}
+
+	// Perform the read directly on the database; ResourceTable.ListIn() is not suitable because it forces
+	// newest-first ordering by creation time and leaves out soft-deleted builds
+	var builds []*models.Build
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := buildsSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &builds, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return builds, nil
+}
+
 // LockRowForUpdate takes out an exclusive row lock on the build table row for the specified build.
 // This function must be called within a transaction, and will block other transactions from locking, updating
 // or deleting the row until this transaction ends.
@@ -0,0 +1,92 @@
+package builds_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/api/rest/client/clienttest"
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+	"github.com/buildbeaver/buildbeaver/server/dto/dto_test/referencedata"
+)
+
+func TestListPrunable(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.NoError(t, err, "Error initializing app")
+	defer cleanup()
+	ctx := context.Background()
+
+	legalEntity := server_test.CreateCompanyLegalEntity(t, ctx, app, "", "", "")
+	_, clientCert := clienttest.MakeClientCertificateAPIClient(t, app)
+	server_test.CreateRunner(t, ctx, app, "test", legalEntity.ID, clientCert)
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+
+	makeBuild := func(status models.WorkflowStatus) *models.Build {
+		build := server_test.CreateAndQueueBuild(t, ctx, app, repo.ID, legalEntity.ID, referencedata.TestRef2).Build
+		build.Status = status
+		err := app.BuildStore.Update(ctx, nil, build)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond) // ensure builds have distinct creation times
+		return build
+	}
+	oldest := makeBuild(models.WorkflowStatusSucceeded)
+	older := makeBuild(models.WorkflowStatusFailed)
+	makeBuild(models.WorkflowStatusRunning) // unfinished builds are never pruned
+	old := makeBuild(models.WorkflowStatusCanceled)
+	createdBefore := models.NewTime(time.Now())
+	makeBuild(models.WorkflowStatusSucceeded) // too recent
+
+	listPrunable := func(criteria models.BuildPruneCriteria, after models.BuildID, limit int) []models.BuildID {
+		builds, err := app.BuildStore.ListPrunable(ctx, nil, criteria, after, limit)
+		require.NoError(t, err)
+		var ids []models.BuildID
+		for _, build := range builds {
+			ids = append(ids, build.ID)
+		}
+		return ids
+	}
+
+	t.Run("Age", func(t *testing.T) {
+		ids := listPrunable(models.BuildPruneCriteria{CreatedBefore: createdBefore}, models.BuildID{}, 10)
+		require.ElementsMatch(t, []models.BuildID{oldest.ID, older.ID, old.ID}, ids)
+	})
+
+	t.Run("KeepLast", func(t *testing.T) {
+		// The three newest builds are kept, including the running build
+		ids := listPrunable(models.BuildPruneCriteria{CreatedBefore: createdBefore, KeepLast: 3}, models.BuildID{}, 10)
+		require.ElementsMatch(t, []models.BuildID{oldest.ID, older.ID}, ids)
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		criteria := models.BuildPruneCriteria{CreatedBefore: createdBefore}
+		first := listPrunable(criteria, models.BuildID{}, 2)
+		require.Len(t, first, 2)
+		rest := listPrunable(criteria, first[1], 2)
+		require.ElementsMatch(t, []models.BuildID{oldest.ID, older.ID, old.ID}, append(first, rest...))
+	})
+
+	t.Run("SoftDeleted", func(t *testing.T) {
+		err := app.BuildStore.SoftDelete(ctx, nil, oldest)
+		require.NoError(t, err)
+		ids := listPrunable(models.BuildPruneCriteria{CreatedBefore: createdBefore}, models.BuildID{}, 10)
+		require.ElementsMatch(t, []models.BuildID{older.ID, old.ID}, ids)
+		ids = listPrunable(models.BuildPruneCriteria{CreatedBefore: createdBefore, IncludeDeleted: true}, models.BuildID{}, 10)
+		require.ElementsMatch(t, []models.BuildID{oldest.ID, older.ID, old.ID}, ids)
+
+		jobs, err := app.JobStore.ListByBuildIDIncludingDeleted(ctx, nil, oldest.ID)
+		require.NoError(t, err)
+		var jobIDs []models.JobID
+		for _, job := range jobs {
+			jobIDs = append(jobIDs, job.ID)
+		}
+		require.NoError(t, app.StepStore.DeleteByJobIDs(ctx, nil, jobIDs))
+		require.NoError(t, app.JobStore.DeleteByBuildID(ctx, nil, oldest.ID))
+		require.NoError(t, app.EventStore.DeleteEventCounter(ctx, nil, oldest.ID))
+		require.NoError(t, app.BuildStore.Delete(ctx, nil, oldest.ID))
+		ids = listPrunable(models.BuildPruneCriteria{CreatedBefore: createdBefore, IncludeDeleted: true}, models.BuildID{}, 10)
+		require.ElementsMatch(t, []models.BuildID{older.ID, old.ID}, ids)
+	})
+}
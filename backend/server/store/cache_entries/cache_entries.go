@@ -0,0 +1,128 @@
+package cache_entries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.CacheEntry{})
+}
+
+type CacheEntryStore struct {
+	db    *store.DB
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *CacheEntryStore {
+	return &CacheEntryStore{
+		db:    db,
+		table: store.NewResourceTableWithTableName(db, logFactory, "cache_entries", &models.CacheEntry{}),
+	}
+}
+
+// Create a new cache entry.
+// Returns store.ErrAlreadyExists if a cache entry with matching unique properties already exists.
+func (d *CacheEntryStore) Create(ctx context.Context, txOrNil *store.Tx, cacheEntry *models.CacheEntry) error {
+	return d.table.Create(ctx, txOrNil, cacheEntry)
+}
+
+// Read an existing cache entry, looking it up by ID.
+// Returns models.ErrNotFound if the cache entry does not exist.
+func (d *CacheEntryStore) Read(ctx context.Context, txOrNil *store.Tx, id models.CacheEntryID) (*models.CacheEntry, error) {
+	cacheEntry := &models.CacheEntry{}
+	return cacheEntry, d.table.ReadByID(ctx, txOrNil, id.ResourceID, cacheEntry)
+}
+
+// ReadByKey reads an existing cache entry, looking it up by repo, cache name and cache key.
+// Returns models.ErrNotFound if the cache entry does not exist.
+func (d *CacheEntryStore) ReadByKey(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, name models.ResourceName, key string) (*models.CacheEntry, error) {
+	cacheEntry := &models.CacheEntry{}
+	return cacheEntry, d.table.ReadWhere(ctx, txOrNil, cacheEntry,
+		goqu.Ex{
+			"cache_entry_repo_id": repoID,
+			"cache_entry_name":    name,
+			"cache_entry_key":     key,
+		})
+}
+
+// ReadMostRecentlyUsed reads the most recently used cache entry with the specified name in a repo, regardless of key.
+// Returns models.ErrNotFound if the repo has no entries for the cache.
+func (d *CacheEntryStore) ReadMostRecentlyUsed(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, name models.ResourceName) (*models.CacheEntry, error) {
+	cacheEntry := &models.CacheEntry{}
+	ds := goqu.
+		Select(cacheEntry).
+		From(d.table.TableName()).
+		Where(goqu.Ex{
+			"cache_entry_repo_id": repoID,
+			"cache_entry_name":    name,
+		}).
+		Order(goqu.I("cache_entry_last_used_at").Desc(), goqu.I("cache_entry_id").Desc())
+	return cacheEntry, d.table.ReadIn(ctx, txOrNil, cacheEntry, ds)
+}
+
+// Update an existing cache entry. Overrides all previous values using the supplied model.
+func (d *CacheEntryStore) Update(ctx context.Context, txOrNil *store.Tx, cacheEntry *models.CacheEntry) error {
+	return d.table.UpdateByID(ctx, txOrNil, cacheEntry)
+}
+
+// Delete permanently and idempotently deletes a cache entry.
+func (d *CacheEntryStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.CacheEntryID) error {
+	return d.table.DeleteWhere(ctx, txOrNil, goqu.Ex{"cache_entry_id": id})
+}
+
+// TotalSizeByRepoID returns the total size in bytes of all cache entries in a repo.
+func (d *CacheEntryStore) TotalSizeByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) (uint64, error) {
+	sizeSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(goqu.COALESCE(goqu.SUM(goqu.C("cache_entry_size")), 0)).
+		Where(goqu.Ex{"cache_entry_repo_id": repoID})
+
+	var total uint64
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := sizeSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		_, err = db.ScanValContext(ctx, &total, query, args...)
+		return err
+	})
+	if err != nil {
+		return 0, store.MakeStandardDBError(err)
+	}
+	return total, nil
+}
+
+// ListLeastRecentlyUsed lists up to limit cache entries in a repo, least recently used first.
+func (d *CacheEntryStore) ListLeastRecentlyUsed(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, limit int) ([]*models.CacheEntry, error) {
+	var cacheEntries []*models.CacheEntry
+
+	cacheEntrySelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.CacheEntry{}).
+		Where(goqu.Ex{"cache_entry_repo_id": repoID}).
+		Order(goqu.I("cache_entry_last_used_at").Asc(), goqu.I("cache_entry_id").Asc()).
+		Limit(uint(limit))
+
+	// Perform the read directly on the database; ResourceTable.ListIn() is not suitable because it forces
+	// newest-first ordering by creation time
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := cacheEntrySelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &cacheEntries, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return cacheEntries, nil
+}
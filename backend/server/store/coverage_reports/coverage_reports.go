@@ -0,0 +1,55 @@
+package coverage_reports
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.CoverageReport{})
+}
+
+type CoverageReportStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *CoverageReportStore {
+	return &CoverageReportStore{
+		table: store.NewResourceTable(db, logFactory, &models.CoverageReport{}),
+	}
+}
+
+// Create a new coverage report.
+// Returns store.ErrAlreadyExists if a coverage report with matching unique properties already exists.
+func (d *CoverageReportStore) Create(ctx context.Context, txOrNil *store.Tx, coverageReport *models.CoverageReport) error {
+	return d.table.Create(ctx, txOrNil, coverageReport)
+}
+
+// ReadByArtifactID reads an existing coverage report, looking it up by the ID of the artifact it was parsed from.
+// Returns models.ErrNotFound if the coverage report does not exist.
+func (d *CoverageReportStore) ReadByArtifactID(ctx context.Context, txOrNil *store.Tx, artifactID models.ArtifactID) (*models.CoverageReport, error) {
+	coverageReport := &models.CoverageReport{}
+	return coverageReport, d.table.ReadWhere(ctx, txOrNil, coverageReport,
+		goqu.Ex{"coverage_report_artifact_id": artifactID})
+}
+
+// ListByBuildID lists the coverage reports for a build.
+// Use cursor to page through results, if any.
+func (d *CoverageReportStore) ListByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CoverageReport, *models.Cursor, error) {
+	coverageReportsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.CoverageReport{}).
+		Where(goqu.Ex{"coverage_report_build_id": buildID})
+
+	var coverageReports []*models.CoverageReport
+	cursor, err := d.table.ListIn(ctx, txOrNil, &coverageReports, pagination, coverageReportsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return coverageReports, cursor, nil
+}
@@ -54,6 +54,21 @@ func (d *CredentialStore) ReadBySharedSecretID(ctx context.Context, txOrNil *sto
 	return credential, nil
 }
 
+// ReadByPersonalAccessTokenID reads an existing personal access token credential, looking it up by the
+// shared secret ID embedded in the token. Returns models.ErrNotFound if the credential does not exist.
+func (d *CredentialStore) ReadByPersonalAccessTokenID(ctx context.Context, txOrNil *store.Tx, sharedSecretID string) (*models.Credential, error) {
+	credential := &models.Credential{}
+	err := d.table.ReadWhere(ctx, txOrNil, credential,
+		goqu.Ex{
+			"credential_type":             models.CredentialTypePersonalAccessToken,
+			"credential_shared_secret_id": sharedSecretID,
+		})
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
 // ReadByGitHubUserID reads an existing GitHub credential, looking it up by the GitHub user id ResourceID.
 // Returns models.ErrNotFound if the credential does not exist.
 func (d *CredentialStore) ReadByGitHubUserID(ctx context.Context, txOrNil *store.Tx, gitHubUserID int64) (*models.Credential, error) {
@@ -83,6 +98,12 @@ func (d *CredentialStore) ReadByPublicKey(ctx context.Context, txOrNil *store.Tx
 	return credential, nil
 }
 
+// Update an existing credential with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *CredentialStore) Update(ctx context.Context, txOrNil *store.Tx, credential *models.Credential) error {
+	return d.table.UpdateByID(ctx, txOrNil, credential)
+}
+
 // Delete permanently and idempotently deletes a credential.
 func (d *CredentialStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.CredentialID) error {
 	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
@@ -108,3 +129,28 @@ func (d *CredentialStore) ListCredentialsForIdentity(
 	}
 	return credentials, cursor, nil
 }
+
+// ListCredentialsOfTypeForIdentity returns a list of the credentials of the specified type for an identity.
+// Use cursor to page through results, if any.
+func (d *CredentialStore) ListCredentialsOfTypeForIdentity(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	credentialType models.CredentialType,
+	pagination models.Pagination,
+) ([]*models.Credential, *models.Cursor, error) {
+	credentialSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Credential{}).
+		Where(goqu.Ex{
+			"credential_identity_id": identityID,
+			"credential_type":        credentialType,
+		})
+
+	var credentials []*models.Credential
+	cursor, err := d.table.ListIn(ctx, txOrNil, &credentials, pagination, credentialSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return credentials, cursor, nil
+}
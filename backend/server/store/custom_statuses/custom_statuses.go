@@ -0,0 +1,96 @@
+package custom_statuses
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.CustomStatus{})
+	store.MustDBModel(&models.CustomStatus{})
+}
+
+type CustomStatusStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *CustomStatusStore {
+	return &CustomStatusStore{
+		table: store.NewResourceTableWithTableName(db, logFactory, "custom_statuses", &models.CustomStatus{}),
+	}
+}
+
+// Create a new custom status.
+// Returns store.ErrAlreadyExists if a custom status with matching unique properties already exists.
+func (d *CustomStatusStore) Create(ctx context.Context, txOrNil *store.Tx, customStatus *models.CustomStatus) error {
+	return d.table.Create(ctx, txOrNil, customStatus)
+}
+
+// Read an existing custom status, looking it up by ResourceID.
+// Returns models.ErrNotFound if the custom status does not exist.
+func (d *CustomStatusStore) Read(ctx context.Context, txOrNil *store.Tx, id models.CustomStatusID) (*models.CustomStatus, error) {
+	customStatus := &models.CustomStatus{}
+	return customStatus, d.table.ReadByID(ctx, txOrNil, id.ResourceID, customStatus)
+}
+
+// ReadByName reads an existing custom status, looking it up by build and name.
+// Returns models.ErrNotFound if the custom status does not exist.
+func (d *CustomStatusStore) ReadByName(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, name models.ResourceName) (*models.CustomStatus, error) {
+	customStatus := &models.CustomStatus{}
+	return customStatus, d.table.ReadWhere(ctx, txOrNil, customStatus,
+		goqu.Ex{"custom_status_build_id": buildID},
+		goqu.Ex{"custom_status_name": name})
+}
+
+// Update an existing custom status with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *CustomStatusStore) Update(ctx context.Context, txOrNil *store.Tx, customStatus *models.CustomStatus) error {
+	return d.table.UpdateByID(ctx, txOrNil, customStatus)
+}
+
+// Upsert creates a custom status if no status with the same name exists for the build, otherwise it updates
+// the existing status's mutable properties if they differ from the in-memory instance. On update the ID and
+// creation time of the in-memory instance are replaced with those of the existing status.
+// Returns true,false if the resource was created and false,true if the resource was updated.
+// false,false if neither a create or update was necessary.
+func (d *CustomStatusStore) Upsert(ctx context.Context, txOrNil *store.Tx, customStatus *models.CustomStatus) (bool, bool, error) {
+	return d.table.Upsert(ctx, txOrNil,
+		func(tx *store.Tx) (models.Resource, error) {
+			return d.ReadByName(ctx, tx, customStatus.BuildID, customStatus.Name)
+		}, func(tx *store.Tx) error {
+			return d.Create(ctx, tx, customStatus)
+		}, func(tx *store.Tx, obj models.Resource) (bool, error) {
+			existing := obj.(*models.CustomStatus)
+			customStatus.ID = existing.ID
+			customStatus.CreatedAt = existing.CreatedAt
+			customStatus.ETag = existing.ETag
+			if existing.State == customStatus.State &&
+				existing.Description == customStatus.Description &&
+				existing.TargetURL == customStatus.TargetURL {
+				*customStatus = *existing
+				return false, nil
+			}
+			return true, d.Update(ctx, tx, customStatus)
+		})
+}
+
+// ListByBuildID lists the custom statuses published to a build.
+// Use cursor to page through results, if any.
+func (d *CustomStatusStore) ListByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CustomStatus, *models.Cursor, error) {
+	customStatusesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.CustomStatus{}).
+		Where(goqu.Ex{"custom_status_build_id": buildID})
+
+	var customStatuses []*models.CustomStatus
+	cursor, err := d.table.ListIn(ctx, txOrNil, &customStatuses, pagination, customStatusesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return customStatuses, cursor, nil
+}
@@ -15,6 +15,9 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/buildbeaver/buildbeaver/common/tracing"
 )
 
 type DatabaseConfig struct {
@@ -204,6 +207,16 @@ func (d *DB) WithTx(ctx context.Context, txOrNil *Tx, fn func(tx *Tx) error) err
 		return fn(txOrNil)
 	}
 
+	ctx, span := tracing.StartSpan(ctx, "db.transaction")
+	defer span.End()
+	span.SetAttributes(attribute.String("db.system", string(d.Driver)))
+
+	err := d.withNewTx(ctx, fn)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (d *DB) withNewTx(ctx context.Context, fn func(tx *Tx) error) error {
 	if d.Driver == Sqlite {
 		d.lock.Lock()
 		defer d.lock.Unlock()
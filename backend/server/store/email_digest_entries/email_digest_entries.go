@@ -0,0 +1,85 @@
+package email_digest_entries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.EmailDigestEntry{})
+}
+
+type EmailDigestEntryStore struct {
+	db    *store.DB
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *EmailDigestEntryStore {
+	return &EmailDigestEntryStore{
+		db:    db,
+		table: store.NewResourceTableWithTableName(db, logFactory, "email_digest_entries", &models.EmailDigestEntry{}),
+	}
+}
+
+// Create a new email digest entry.
+// Returns store.ErrAlreadyExists if an entry with matching unique properties already exists.
+func (d *EmailDigestEntryStore) Create(ctx context.Context, txOrNil *store.Tx, entry *models.EmailDigestEntry) error {
+	return d.table.Create(ctx, txOrNil, entry)
+}
+
+// Delete permanently and idempotently deletes an email digest entry, identifying it by ID.
+func (d *EmailDigestEntryStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.EmailDigestEntryID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByLegalEntityID lists the entries waiting to be included in a user's next digest email.
+// Use cursor to page through results, if any.
+func (d *EmailDigestEntryStore) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.EmailDigestEntry, *models.Cursor, error) {
+	entriesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.EmailDigestEntry{}).
+		Where(goqu.Ex{"email_digest_entry_legal_entity_id": legalEntityID})
+
+	var entries []*models.EmailDigestEntry
+	cursor, err := d.table.ListIn(ctx, txOrNil, &entries, pagination, entriesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, cursor, nil
+}
+
+// ListLegalEntitiesDue returns the IDs of the users with at least one entry created before the specified time,
+// i.e. the users whose digest emails are due to be sent.
+func (d *EmailDigestEntryStore) ListLegalEntitiesDue(ctx context.Context, txOrNil *store.Tx, createdBefore models.Time) ([]models.LegalEntityID, error) {
+	// Format the time in a form usable in SQL queries
+	createdBeforeValue, err := createdBefore.Value()
+	if err != nil {
+		return nil, fmt.Errorf("error converting time to value: %w", err)
+	}
+
+	dueSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(goqu.C("email_digest_entry_legal_entity_id")).
+		Distinct().
+		Where(goqu.C("email_digest_entry_created_at").Lt(createdBeforeValue))
+
+	var legalEntityIDs []models.LegalEntityID
+	err = d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := dueSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanValsContext(ctx, &legalEntityIDs, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return legalEntityIDs, nil
+}
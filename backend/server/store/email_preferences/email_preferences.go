@@ -0,0 +1,80 @@
+package email_preferences
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.EmailPreference{})
+	store.MustDBModel(&models.EmailPreference{})
+}
+
+type EmailPreferenceStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *EmailPreferenceStore {
+	return &EmailPreferenceStore{
+		table: store.NewResourceTable(db, logFactory, &models.EmailPreference{}),
+	}
+}
+
+// Create a new email preference.
+// Returns store.ErrAlreadyExists if an email preference with matching unique properties already exists.
+func (d *EmailPreferenceStore) Create(ctx context.Context, txOrNil *store.Tx, preference *models.EmailPreference) error {
+	return d.table.Create(ctx, txOrNil, preference)
+}
+
+// Read an existing email preference, looking it up by ResourceID.
+// Returns models.ErrNotFound if the email preference does not exist.
+func (d *EmailPreferenceStore) Read(ctx context.Context, txOrNil *store.Tx, id models.EmailPreferenceID) (*models.EmailPreference, error) {
+	preference := &models.EmailPreference{}
+	return preference, d.table.ReadByID(ctx, txOrNil, id.ResourceID, preference)
+}
+
+// ReadByLegalEntityID reads the email preference for a user, looking it up by the user's legal entity ID.
+// Returns models.ErrNotFound if the user has no email preference.
+func (d *EmailPreferenceStore) ReadByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) (*models.EmailPreference, error) {
+	preference := &models.EmailPreference{}
+	return preference, d.table.ReadWhere(ctx, txOrNil, preference,
+		goqu.Ex{"email_preference_legal_entity_id": legalEntityID})
+}
+
+// Update an existing email preference with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *EmailPreferenceStore) Update(ctx context.Context, txOrNil *store.Tx, preference *models.EmailPreference) error {
+	return d.table.UpdateByID(ctx, txOrNil, preference)
+}
+
+// ListEnabledForRepoOwner lists the enabled email preferences of users who may be interested in builds
+// for repos owned by the specified legal entity, i.e. the legal entity itself and any of its members.
+// Use cursor to page through results, if any.
+func (d *EmailPreferenceStore) ListEnabledForRepoOwner(ctx context.Context, txOrNil *store.Tx, ownerLegalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.EmailPreference, *models.Cursor, error) {
+	// Join on the owner's memberships; the unique index on memberships ensures each preference is included at most once
+	preferencesSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		LeftJoin(goqu.T("legal_entities_memberships"), goqu.On(goqu.Ex{
+			"legal_entities_membership_member_legal_entity_id": goqu.I("email_preference_legal_entity_id"),
+			"legal_entities_membership_legal_entity_id":        ownerLegalEntityID,
+		})).
+		Select(&models.EmailPreference{}).
+		Where(
+			goqu.Ex{"email_preference_enabled": true},
+			goqu.Or(
+				goqu.Ex{"email_preference_legal_entity_id": ownerLegalEntityID},
+				goqu.C("legal_entities_membership_id").IsNotNull(),
+			))
+
+	var preferences []*models.EmailPreference
+	cursor, err := d.table.ListIn(ctx, txOrNil, &preferences, pagination, preferencesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return preferences, cursor, nil
+}
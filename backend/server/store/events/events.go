@@ -65,6 +65,20 @@ func (d *EventStore) DeleteEventsForBuild(ctx context.Context, txOrNil *store.Tx
 	return d.table.DeleteWhere(ctx, txOrNil, goqu.Ex{"event_build_id": buildID.ResourceID})
 }
 
+// DeleteEventCounter permanently and idempotently deletes the event counter for the specified build.
+// This must only be done when the build itself is deleted, since event numbers must never be reused.
+func (d *EventStore) DeleteEventCounter(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) error {
+	return d.db.Write2(txOrNil, func(writer store.Writer) error {
+		_, err := writer.Delete(goqu.T("build_event_counters")).
+			Where(goqu.Ex{"build_event_counter_build_id": buildID}).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("error deleting event counter: %w", store.MakeStandardDBError(err))
+		}
+		return nil
+	})
+}
+
 // FindEvents reads the next events for a build.
 // If no matching events are present then an empty list is returned immediately.
 func (d *EventStore) FindEvents(
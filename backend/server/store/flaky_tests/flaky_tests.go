@@ -0,0 +1,64 @@
+package flaky_tests
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.FlakyTest{})
+}
+
+type FlakyTestStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *FlakyTestStore {
+	return &FlakyTestStore{
+		table: store.NewResourceTable(db, logFactory, &models.FlakyTest{}),
+	}
+}
+
+// Create a new flaky test.
+// Returns store.ErrAlreadyExists if a flaky test with matching unique properties already exists.
+func (d *FlakyTestStore) Create(ctx context.Context, txOrNil *store.Tx, flakyTest *models.FlakyTest) error {
+	return d.table.Create(ctx, txOrNil, flakyTest)
+}
+
+// ReadByName reads the flaky test with the specified suite and name in a repo.
+// Returns models.ErrNotFound if the test is not known to be flaky.
+func (d *FlakyTestStore) ReadByName(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, suite string, name string) (*models.FlakyTest, error) {
+	flakyTest := &models.FlakyTest{}
+	return flakyTest, d.table.ReadWhere(ctx, txOrNil, flakyTest,
+		goqu.Ex{
+			"flaky_test_repo_id": repoID,
+			"flaky_test_suite":   suite,
+			"flaky_test_name":    name,
+		})
+}
+
+// Update an existing flaky test. Overrides all previous values using the supplied model.
+func (d *FlakyTestStore) Update(ctx context.Context, txOrNil *store.Tx, flakyTest *models.FlakyTest) error {
+	return d.table.UpdateByID(ctx, txOrNil, flakyTest)
+}
+
+// ListByRepoID lists the flaky tests in a repo, newest first.
+// Use cursor to page through results, if any.
+func (d *FlakyTestStore) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.FlakyTest, *models.Cursor, error) {
+	flakyTestsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.FlakyTest{}).
+		Where(goqu.Ex{"flaky_test_repo_id": repoID})
+
+	var flakyTests []*models.FlakyTest
+	cursor, err := d.table.ListIn(ctx, txOrNil, &flakyTests, pagination, flakyTestsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return flakyTests, cursor, nil
+}
@@ -120,6 +120,49 @@ func (d *GrantStore) ListGrantsForGroup(
 	return grants, cursor, nil
 }
 
+// ListGrantsForIdentity finds and returns all grants that give permissions directly to the specified identity.
+// Grants the identity has through the groups it is a member of are not included.
+func (d *GrantStore) ListGrantsForIdentity(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	identityID models.IdentityID,
+	pagination models.Pagination,
+) ([]*models.Grant, *models.Cursor, error) {
+	grantSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Grant{}).
+		Where(goqu.Ex{"access_control_grant_authorized_identity_id": identityID})
+
+	var grants []*models.Grant
+	cursor, err := d.table.ListIn(ctx, txOrNil, &grants, pagination, grantSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return grants, cursor, nil
+}
+
+// ListGroupGrantsForTarget finds and returns all grants that give access control groups permissions directly
+// on the specified target resource, including grants that deny permissions.
+func (d *GrantStore) ListGroupGrantsForTarget(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	targetResourceID models.ResourceID,
+	pagination models.Pagination,
+) ([]*models.Grant, *models.Cursor, error) {
+	grantSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Grant{}).
+		Where(goqu.Ex{"access_control_grant_target_resource_id": targetResourceID}).
+		Where(goqu.C("access_control_grant_authorized_group_id").IsNotNull())
+
+	var grants []*models.Grant
+	cursor, err := d.table.ListIn(ctx, txOrNil, &grants, pagination, grantSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return grants, cursor, nil
+}
+
 // DeleteAllGrantsForGroup permanently and idempotently deletes all grants for the specified group.
 func (d *GrantStore) DeleteAllGrantsForGroup(ctx context.Context, txOrNil *store.Tx, groupID models.GroupID) error {
 	return d.table.DeleteWhere(ctx, txOrNil, goqu.Ex{"access_control_grant_authorized_group_id": groupID})
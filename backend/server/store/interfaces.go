@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/buildbeaver/buildbeaver/common/certificates"
 	"github.com/buildbeaver/buildbeaver/common/models"
@@ -86,6 +87,9 @@ type RepoStore interface {
 	// Read an existing repo, looking it up by ID.
 	// Returns models.ErrNotFound if the repo does not exist.
 	Read(ctx context.Context, txOrNil *Tx, id models.RepoID) (*models.Repo, error)
+	// ListByIDs reads the repos with the specified IDs, in no particular order. IDs of repos that do not
+	// exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *Tx, ids []models.RepoID) ([]*models.Repo, error)
 	// ReadByExternalID reads an existing repo, looking it up by its external id.
 	// Returns models.ErrNotFound if the repo does not exist.
 	ReadByExternalID(ctx context.Context, txOrNil *Tx, externalID models.ExternalResourceID) (*models.Repo, error)
@@ -95,7 +99,7 @@ type RepoStore interface {
 	// Upsert creates a repo if it does not exist, otherwise it updates its mutable properties
 	// if they differ from the in-memory instance. Returns true,false if the resource was created
 	// and false,true if the resource was updated. false,false if neither a create or update was necessary.
-	// Repo Metadata and selected fields will not be updated (including Enabled and SSHKeySecretID fields).
+	// Repo Metadata and selected fields will not be updated (including Enabled, SSHKeySecretID, DynamicJobRestrictions and CoverageSettings fields).
 	Upsert(ctx context.Context, txOrNil *Tx, model *models.Repo) (bool, bool, error)
 	// SoftDelete soft deletes an existing repo.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
@@ -138,6 +142,9 @@ type BuildStore interface {
 	// Read an existing build, looking it up by ID.
 	// Returns models.ErrNotFound if the build does not exist.
 	Read(ctx context.Context, txOrNil *Tx, id models.BuildID) (*models.Build, error)
+	// ListByIDs reads the builds with the specified IDs, in no particular order. IDs of builds that do not
+	// exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *Tx, ids []models.BuildID) ([]*models.Build, error)
 	// Update an existing build with optimistic locking. Overrides all previous values using the supplied model.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *Tx, build *models.Build) error
@@ -151,6 +158,15 @@ type BuildStore interface {
 	// UniversalSearch searches all builds. If searcher is set, the results will be limited to builds the searcher is authorized to
 	// see (via the read:build permission). Use cursor to page through results, if any.
 	UniversalSearch(ctx context.Context, txOrNil *Tx, searcher models.IdentityID, search search.Query) ([]*models.BuildSearchResult, *models.Cursor, error)
+	// SoftDelete soft deletes an existing build. Soft-deleted builds can't be read or searched for.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	SoftDelete(ctx context.Context, txOrNil *Tx, build *models.Build) error
+	// Delete permanently and idempotently deletes a build, whether or not it has been soft-deleted. The build's jobs
+	// must be deleted first. Any builds cloned from the build have their reference to it cleared.
+	Delete(ctx context.Context, txOrNil *Tx, id models.BuildID) error
+	// ListPrunable lists up to limit finished builds that match the specified prune criteria, in order of ID.
+	// If after is valid then only builds with IDs after it are listed, to page through the results.
+	ListPrunable(ctx context.Context, txOrNil *Tx, criteria models.BuildPruneCriteria, after models.BuildID, limit int) ([]*models.Build, error)
 }
 
 type JobStore interface {
@@ -173,19 +189,52 @@ type JobStore interface {
 		jobName models.ResourceName,
 		jobFingerprint string,
 		jobFingerprintHashType *models.HashType) (*models.Job, error)
+	// FindRunningDuplicate locates a job in another build that has the same commit, workflow, name and definition
+	// as the specified job, and which has been handed to a runner but has not yet finished. Jobs that are themselves
+	// indirected to another job are ignored. Returns models.ErrNotFound if no such job exists.
+	FindRunningDuplicate(ctx context.Context, txOrNil *Tx, job *models.Job) (*models.Job, error)
+	// ListAttached lists the queued jobs that are indirected to the specified job, waiting to finish with
+	// the same result.
+	ListAttached(ctx context.Context, txOrNil *Tx, jobID models.JobID) ([]*models.Job, error)
+	// ListRunningByRunnerID lists the jobs that have been handed to the specified runner but have not yet finished.
+	ListRunningByRunnerID(ctx context.Context, txOrNil *Tx, runnerID models.RunnerID) ([]*models.Job, error)
 	// Update an existing job with optimistic locking. Overrides all previous values using the supplied model.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *Tx, job *models.Job) error
 	// ListByBuildID gets all jobs that are associated with the specified build id.
 	ListByBuildID(ctx context.Context, txOrNil *Tx, id models.BuildID) ([]*models.Job, error)
+	// ListByBuildIDs gets all jobs that are associated with any of the specified build ids.
+	ListByBuildIDs(ctx context.Context, txOrNil *Tx, ids []models.BuildID) ([]*models.Job, error)
+	// ListByBuildIDIncludingDeleted gets all jobs that are associated with the specified build id, including jobs
+	// that have been soft-deleted.
+	ListByBuildIDIncludingDeleted(ctx context.Context, txOrNil *Tx, buildID models.BuildID) ([]*models.Job, error)
+	// SoftDelete soft deletes an existing job. Soft-deleted jobs can't be read or listed.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	SoftDelete(ctx context.Context, txOrNil *Tx, job *models.Job) error
+	// DeleteByBuildID permanently and idempotently deletes all jobs in the specified build, whether or not they
+	// have been soft-deleted, along with the dependencies between them. The jobs' steps and artifacts must be
+	// deleted first.
+	DeleteByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID) error
+	// ListLatestByToolchainName lists the most recently created jobs that ran in any version of the named toolchain,
+	// for each of the legal entity's repos.
+	ListLatestByToolchainName(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, name models.ResourceName) ([]*models.Job, error)
 	// ListByStatus returns all jobs that have the specified status, regardless of who owns the jobs or which build
 	// they are part of. Use cursor to page through results, if any.
 	ListByStatus(ctx context.Context, txOrNil *Tx, status models.WorkflowStatus, pagination models.Pagination) ([]*models.Job, *models.Cursor, error)
+	// ListFinishedByRepoID returns the jobs in a repo that were created from 'from' (inclusive) until 'to'
+	// (exclusive) and have since finished. Use cursor to page through results, if any.
+	ListFinishedByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, from models.Time, to models.Time, pagination models.Pagination) ([]*models.Job, *models.Cursor, error)
+	// Search all jobs, regardless of who owns the jobs or which build they are part of. Use cursor to page
+	// through results, if any.
+	Search(ctx context.Context, txOrNil *Tx, search models.JobSearch) ([]*models.Job, *models.Cursor, error)
 	// ListDependencies lists all jobs that the specified job depends on.
 	// Deferred dependencies (on jobs in other workflows that don't yet exist) will not be listed.
 	ListDependencies(ctx context.Context, txOrNil *Tx, jobID models.JobID) ([]*models.Job, error)
 	// CreateDependency records a dependency between jobs where source depends on target.
 	CreateDependency(ctx context.Context, txOrNil *Tx, buildID models.BuildID, sourceJobID models.JobID, targetJobID models.JobID) error
+	// DeleteDependencies permanently deletes all dependencies the specified job has on other jobs, including
+	// deferred dependencies. Dependencies other jobs have on the specified job are not affected.
+	DeleteDependencies(ctx context.Context, txOrNil *Tx, jobID models.JobID) error
 	// CreateDeferredDependency records a dependency between a job and another job in another workflow
 	// which does not yet exist.
 	CreateDeferredDependency(ctx context.Context, txOrNil *Tx, buildID models.BuildID, sourceJobID models.JobID, targetWorkflow models.ResourceName, targetJobName models.ResourceName) error
@@ -198,6 +247,10 @@ type JobStore interface {
 	// FindQueuedJob locates a queued job that the runner is capable of running, and which is ready for
 	// execution (e.g all dependencies are completed).
 	FindQueuedJob(ctx context.Context, txOrNil *Tx, runner *models.Runner) (*models.Job, error)
+	// CountRunnableJobs counts the jobs under repos owned by the legal entity that a runner with the specified
+	// labels could run, and that are either ready for execution or already submitted to or running on a runner.
+	// Jobs that don't require any labels are only counted if no labels are specified.
+	CountRunnableJobs(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, labels models.Labels) (int, error)
 }
 
 type StepStore interface {
@@ -212,6 +265,17 @@ type StepStore interface {
 	Update(ctx context.Context, txOrNil *Tx, step *models.Step) error
 	// ListByJobID gets all steps that are associated with the specified job id.
 	ListByJobID(ctx context.Context, txOrNil *Tx, id models.JobID) ([]*models.Step, error)
+	// ListByJobIDs gets all steps that are associated with any of the specified job ids.
+	ListByJobIDs(ctx context.Context, txOrNil *Tx, ids []models.JobID) ([]*models.Step, error)
+	// ListByJobIDsIncludingDeleted gets all steps that are associated with any of the specified job ids, including
+	// steps that have been soft-deleted.
+	ListByJobIDsIncludingDeleted(ctx context.Context, txOrNil *Tx, jobIDs []models.JobID) ([]*models.Step, error)
+	// SoftDelete soft deletes an existing step. Soft-deleted steps can't be read or listed.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	SoftDelete(ctx context.Context, txOrNil *Tx, step *models.Step) error
+	// DeleteByJobIDs permanently and idempotently deletes all steps in any of the specified jobs, whether or not
+	// they have been soft-deleted.
+	DeleteByJobIDs(ctx context.Context, txOrNil *Tx, jobIDs []models.JobID) error
 }
 
 type SecretStore interface {
@@ -230,6 +294,346 @@ type SecretStore interface {
 	ListByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.Secret, *models.Cursor, error)
 }
 
+type SecretVersionStore interface {
+	// Create a new secret version.
+	// Returns store.ErrAlreadyExists if a secret version with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, version *models.SecretVersion) error
+	// ReadCurrentAt reads the version of a secret that was current at the specified time, i.e. the latest
+	// version created at or before that time.
+	// Returns models.ErrNotFound if there is no such version (or it has been purged).
+	ReadCurrentAt(ctx context.Context, txOrNil *Tx, secretID models.SecretID, at models.Time) (*models.SecretVersion, error)
+	// DeleteBySecretID permanently and idempotently deletes all versions of a secret.
+	DeleteBySecretID(ctx context.Context, txOrNil *Tx, secretID models.SecretID) error
+	// DeleteSuperseded permanently deletes prior versions of secrets that were superseded before the specified
+	// time, always keeping the newest keep prior versions of each secret. Returns the number of versions deleted.
+	DeleteSuperseded(ctx context.Context, txOrNil *Tx, keep int, supersededBefore models.Time) (int, error)
+	// ListBySecretID lists the prior versions of a secret, newest first. Use cursor to page through results, if any.
+	ListBySecretID(ctx context.Context, txOrNil *Tx, secretID models.SecretID, pagination models.Pagination) ([]*models.SecretVersion, *models.Cursor, error)
+}
+
+type NotificationSettingStore interface {
+	// Create a new notification setting.
+	// Returns store.ErrAlreadyExists if a notification setting with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, setting *models.NotificationSetting) error
+	// Read an existing notification setting, looking it up by ID.
+	// Returns models.ErrNotFound if the notification setting does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.NotificationSettingID) (*models.NotificationSetting, error)
+	// Update an existing notification setting with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, setting *models.NotificationSetting) error
+	// Delete permanently and idempotently deletes a notification setting, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.NotificationSettingID) error
+	// ListByRepoID lists all notification settings for a repo. Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.NotificationSetting, *models.Cursor, error)
+}
+
+type OutgoingWebhookStore interface {
+	// Create a new outgoing webhook.
+	// Returns store.ErrAlreadyExists if an outgoing webhook with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, webhook *models.OutgoingWebhook) error
+	// Read an existing outgoing webhook, looking it up by ID.
+	// Returns models.ErrNotFound if the outgoing webhook does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.OutgoingWebhookID) (*models.OutgoingWebhook, error)
+	// Update an existing outgoing webhook with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, webhook *models.OutgoingWebhook) error
+	// Delete permanently and idempotently deletes an outgoing webhook, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.OutgoingWebhookID) error
+	// ListByLegalEntityID lists the outgoing webhooks registered against a legal entity, excluding webhooks
+	// registered against the legal entity's individual repos. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error)
+	// ListByRepoID lists the outgoing webhooks registered against a repo. Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error)
+	// ListForRepo lists all outgoing webhooks that receive events for a repo; this includes webhooks registered
+	// against the repo and webhooks registered against the legal entity that owns the repo.
+	// Use cursor to page through results, if any.
+	ListForRepo(ctx context.Context, txOrNil *Tx, repo *models.Repo, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error)
+}
+
+type OutgoingWebhookDeliveryStore interface {
+	// Create a new outgoing webhook delivery.
+	// Returns store.ErrAlreadyExists if a delivery with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, delivery *models.OutgoingWebhookDelivery) error
+	// Read an existing outgoing webhook delivery, looking it up by ID.
+	// Returns models.ErrNotFound if the delivery does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.OutgoingWebhookDeliveryID) (*models.OutgoingWebhookDelivery, error)
+	// Update an existing outgoing webhook delivery with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, delivery *models.OutgoingWebhookDelivery) error
+	// ListByWebhookID lists the deliveries for an outgoing webhook, most recent first.
+	// Use cursor to page through results, if any.
+	ListByWebhookID(ctx context.Context, txOrNil *Tx, webhookID models.OutgoingWebhookID, pagination models.Pagination) ([]*models.OutgoingWebhookDelivery, *models.Cursor, error)
+	// DeleteByWebhookID permanently and idempotently deletes all deliveries for an outgoing webhook.
+	DeleteByWebhookID(ctx context.Context, txOrNil *Tx, webhookID models.OutgoingWebhookID) error
+}
+
+type WebhookDeliveryStore interface {
+	// Create a new webhook delivery.
+	// Returns store.ErrAlreadyExists if a delivery with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, delivery *models.WebhookDelivery) error
+	// Read an existing webhook delivery, looking it up by ID.
+	// Returns models.ErrNotFound if the delivery does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.WebhookDeliveryID) (*models.WebhookDelivery, error)
+	// Update an existing webhook delivery with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, delivery *models.WebhookDelivery) error
+	// Search all webhook deliveries, most recent first. Use cursor to page through results, if any.
+	Search(ctx context.Context, txOrNil *Tx, search *models.WebhookDeliverySearch) ([]*models.WebhookDelivery, *models.Cursor, error)
+}
+
+type RunnerPoolStore interface {
+	// Create a new runner pool.
+	// Returns store.ErrAlreadyExists if a runner pool with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, pool *models.RunnerPool) error
+	// Read an existing runner pool, looking it up by ID.
+	// Returns models.ErrNotFound if the runner pool does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.RunnerPoolID) (*models.RunnerPool, error)
+	// Update an existing runner pool with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, pool *models.RunnerPool) error
+	// Delete permanently and idempotently deletes a runner pool, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.RunnerPoolID) error
+	// ListByLegalEntityID lists the runner pools belonging to a legal entity. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.RunnerPool, *models.Cursor, error)
+	// ListAll lists the runner pools belonging to all legal entities. Use cursor to page through results, if any.
+	ListAll(ctx context.Context, txOrNil *Tx, pagination models.Pagination) ([]*models.RunnerPool, *models.Cursor, error)
+	// PoolCompatibleWithJob returns true if a runner pool exists that could start a runner capable of running job.
+	PoolCompatibleWithJob(ctx context.Context, txOrNil *Tx, job *models.Job) (bool, error)
+}
+
+type ToolchainStore interface {
+	// Create a new toolchain.
+	// Returns store.ErrAlreadyExists if a toolchain with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, toolchain *models.Toolchain) error
+	// Read an existing toolchain, looking it up by ID.
+	// Returns models.ErrNotFound if the toolchain does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.ToolchainID) (*models.Toolchain, error)
+	// ReadByReference reads an existing toolchain, looking it up by legal entity, name and version.
+	// Returns models.ErrNotFound if the toolchain does not exist.
+	ReadByReference(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, ref models.ToolchainReference) (*models.Toolchain, error)
+	// Delete permanently and idempotently deletes a toolchain, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.ToolchainID) error
+	// ListByLegalEntityID lists the toolchains belonging to a legal entity. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Toolchain, *models.Cursor, error)
+	// ListBySourceRepoID lists all versions of the toolchains registered by builds of the specified repo.
+	ListBySourceRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID) ([]*models.Toolchain, error)
+}
+
+type CustomStatusStore interface {
+	// Create a new custom status.
+	// Returns store.ErrAlreadyExists if a custom status with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, customStatus *models.CustomStatus) error
+	// Read an existing custom status, looking it up by ID.
+	// Returns models.ErrNotFound if the custom status does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.CustomStatusID) (*models.CustomStatus, error)
+	// ReadByName reads an existing custom status, looking it up by build and name.
+	// Returns models.ErrNotFound if the custom status does not exist.
+	ReadByName(ctx context.Context, txOrNil *Tx, buildID models.BuildID, name models.ResourceName) (*models.CustomStatus, error)
+	// Update an existing custom status with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, customStatus *models.CustomStatus) error
+	// Upsert creates a custom status if no status with the same name exists for the build, otherwise it updates
+	// the existing status's mutable properties if they differ from the in-memory instance.
+	// Returns true,false if the resource was created and false,true if the resource was updated.
+	// false,false if neither a create or update was necessary.
+	Upsert(ctx context.Context, txOrNil *Tx, customStatus *models.CustomStatus) (bool, bool, error)
+	// ListByBuildID lists the custom statuses published to a build.
+	// Use cursor to page through results, if any.
+	ListByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CustomStatus, *models.Cursor, error)
+}
+
+type BuildRuleSetStore interface {
+	// Create a new build rule set.
+	// Returns store.ErrAlreadyExists if a build rule set with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, ruleSet *models.BuildRuleSet) error
+	// Read an existing build rule set, looking it up by ID.
+	// Returns models.ErrNotFound if the build rule set does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.BuildRuleSetID) (*models.BuildRuleSet, error)
+	// ReadByRepoID reads the build rule set for a repo.
+	// Returns models.ErrNotFound if the repo has no build rule set.
+	ReadByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID) (*models.BuildRuleSet, error)
+	// Update an existing build rule set with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, ruleSet *models.BuildRuleSet) error
+	// Delete permanently and idempotently deletes a build rule set, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.BuildRuleSetID) error
+}
+
+type EmailPreferenceStore interface {
+	// Create a new email preference.
+	// Returns store.ErrAlreadyExists if an email preference with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, preference *models.EmailPreference) error
+	// Read an existing email preference, looking it up by ID.
+	// Returns models.ErrNotFound if the email preference does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.EmailPreferenceID) (*models.EmailPreference, error)
+	// ReadByLegalEntityID reads the email preference for a user, looking it up by the user's legal entity ID.
+	// Returns models.ErrNotFound if the user has no email preference.
+	ReadByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID) (*models.EmailPreference, error)
+	// Update an existing email preference with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, preference *models.EmailPreference) error
+	// ListEnabledForRepoOwner lists the enabled email preferences of users who may be interested in builds
+	// for repos owned by the specified legal entity, i.e. the legal entity itself and any of its members.
+	// Use cursor to page through results, if any.
+	ListEnabledForRepoOwner(ctx context.Context, txOrNil *Tx, ownerLegalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.EmailPreference, *models.Cursor, error)
+}
+
+type SAMLIdentityProviderStore interface {
+	// Create a new SAML identity provider.
+	// Returns store.ErrAlreadyExists if an identity provider with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, provider *models.SAMLIdentityProvider) error
+	// Read an existing SAML identity provider, looking it up by ID.
+	// Returns models.ErrNotFound if the identity provider does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.SAMLIdentityProviderID) (*models.SAMLIdentityProvider, error)
+	// ReadByLegalEntityID reads the SAML identity provider configured for a legal entity.
+	// Returns models.ErrNotFound if the legal entity has no identity provider.
+	ReadByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID) (*models.SAMLIdentityProvider, error)
+	// Update an existing SAML identity provider with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, provider *models.SAMLIdentityProvider) error
+	// Delete permanently and idempotently deletes a SAML identity provider, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.SAMLIdentityProviderID) error
+}
+
+type EmailDigestEntryStore interface {
+	// Create a new email digest entry.
+	// Returns store.ErrAlreadyExists if an entry with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, entry *models.EmailDigestEntry) error
+	// Delete permanently and idempotently deletes an email digest entry, identifying it by ID.
+	Delete(ctx context.Context, txOrNil *Tx, id models.EmailDigestEntryID) error
+	// ListByLegalEntityID lists the entries waiting to be included in a user's next digest email.
+	// Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.EmailDigestEntry, *models.Cursor, error)
+	// ListLegalEntitiesDue returns the IDs of the users with at least one entry created before the specified time,
+	// i.e. the users whose digest emails are due to be sent.
+	ListLegalEntitiesDue(ctx context.Context, txOrNil *Tx, createdBefore models.Time) ([]models.LegalEntityID, error)
+}
+
+type MetricsSampleStore interface {
+	// Create a new metrics sample.
+	// Returns store.ErrAlreadyExists if a metrics sample with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, sample *models.MetricsSample) error
+	// Delete permanently and idempotently deletes a metrics sample, identifying it by ID.
+	Delete(ctx context.Context, txOrNil *Tx, id models.MetricsSampleID) error
+	// ListCreatedBefore lists the metrics samples created before the specified time.
+	// Use cursor to page through results, if any.
+	ListCreatedBefore(ctx context.Context, txOrNil *Tx, createdBefore models.Time, pagination models.Pagination) ([]*models.MetricsSample, *models.Cursor, error)
+}
+
+type TestRunStore interface {
+	// Create a new test run.
+	// Returns store.ErrAlreadyExists if a test run with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, testRun *models.TestRun) error
+	// Read an existing test run, looking it up by ResourceID.
+	// Returns models.ErrNotFound if the test run does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.TestRunID) (*models.TestRun, error)
+	// ReadByArtifactID reads an existing test run, looking it up by the ID of the artifact it was parsed from.
+	// Returns models.ErrNotFound if the test run does not exist.
+	ReadByArtifactID(ctx context.Context, txOrNil *Tx, artifactID models.ArtifactID) (*models.TestRun, error)
+	// ListByBuildID lists the test runs for a build.
+	// Use cursor to page through results, if any.
+	ListByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.TestRun, *models.Cursor, error)
+}
+
+type TestCaseStore interface {
+	// Create a new test case.
+	// Returns store.ErrAlreadyExists if a test case with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, testCase *models.TestCase) error
+	// Search all test cases matching the search criteria.
+	// Use cursor to page through results, if any.
+	Search(ctx context.Context, txOrNil *Tx, search models.TestCaseSearch) ([]*models.TestCase, *models.Cursor, error)
+	// CountStatusesByBuildID counts the test cases reported by a build, grouped by suite, name and status.
+	CountStatusesByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID) ([]*models.TestCaseStatusCount, error)
+	// CountStatusesByCommitID counts the test cases reported by jobs in a repo that ran against a commit,
+	// grouped by suite, name and status.
+	CountStatusesByCommitID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, commitID models.CommitID) ([]*models.TestCaseStatusCount, error)
+	// ListFailedByJobID lists the test cases that failed on every attempt in the test runs reported by a job.
+	ListFailedByJobID(ctx context.Context, txOrNil *Tx, jobID models.JobID) ([]*models.TestCase, error)
+	// ListFailedByBuildID lists the test cases that failed on every attempt in the test runs reported by a build.
+	ListFailedByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID) ([]*models.TestCase, error)
+}
+
+type TestSummaryStore interface {
+	// Create a new test summary.
+	// Returns store.ErrAlreadyExists if a test summary with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, testSummary *models.TestSummary) error
+	// ReadByBuildID reads the test summary for a build.
+	// Returns models.ErrNotFound if the build has no test summary.
+	ReadByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID) (*models.TestSummary, error)
+	// Update an existing test summary. Overrides all previous values using the supplied model.
+	Update(ctx context.Context, txOrNil *Tx, testSummary *models.TestSummary) error
+	// ListByRepoID lists the test summaries for builds in a repo, newest first.
+	// Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.TestSummary, *models.Cursor, error)
+}
+
+type FlakyTestStore interface {
+	// Create a new flaky test.
+	// Returns store.ErrAlreadyExists if a flaky test with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, flakyTest *models.FlakyTest) error
+	// ReadByName reads the flaky test with the specified suite and name in a repo.
+	// Returns models.ErrNotFound if the test is not known to be flaky.
+	ReadByName(ctx context.Context, txOrNil *Tx, repoID models.RepoID, suite string, name string) (*models.FlakyTest, error)
+	// Update an existing flaky test. Overrides all previous values using the supplied model.
+	Update(ctx context.Context, txOrNil *Tx, flakyTest *models.FlakyTest) error
+	// ListByRepoID lists the flaky tests in a repo, newest first.
+	// Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.FlakyTest, *models.Cursor, error)
+}
+
+type CoverageReportStore interface {
+	// Create a new coverage report.
+	// Returns store.ErrAlreadyExists if a coverage report with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, coverageReport *models.CoverageReport) error
+	// ReadByArtifactID reads an existing coverage report, looking it up by the ID of the artifact it was parsed from.
+	// Returns models.ErrNotFound if the coverage report does not exist.
+	ReadByArtifactID(ctx context.Context, txOrNil *Tx, artifactID models.ArtifactID) (*models.CoverageReport, error)
+	// ListByBuildID lists the coverage reports for a build.
+	// Use cursor to page through results, if any.
+	ListByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.CoverageReport, *models.Cursor, error)
+}
+
+type BuildCoverageStore interface {
+	// Create a new build coverage.
+	// Returns store.ErrAlreadyExists if a build coverage with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, buildCoverage *models.BuildCoverage) error
+	// ReadByBuildID reads the coverage for a build.
+	// Returns models.ErrNotFound if the build has no coverage.
+	ReadByBuildID(ctx context.Context, txOrNil *Tx, buildID models.BuildID) (*models.BuildCoverage, error)
+	// ReadLatestByRef reads the most recently created coverage for a build of the specified ref in a repo,
+	// ignoring the coverage for excludeBuildID.
+	// Returns models.ErrNotFound if no other build of the ref has coverage.
+	ReadLatestByRef(ctx context.Context, txOrNil *Tx, repoID models.RepoID, ref string, excludeBuildID models.BuildID) (*models.BuildCoverage, error)
+	// Update an existing build coverage. Overrides all previous values using the supplied model.
+	Update(ctx context.Context, txOrNil *Tx, buildCoverage *models.BuildCoverage) error
+	// ListByRepoID lists the coverage for builds in a repo, newest first.
+	// Use cursor to page through results, if any.
+	ListByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.BuildCoverage, *models.Cursor, error)
+}
+
+type CacheEntryStore interface {
+	// Create a new cache entry.
+	// Returns store.ErrAlreadyExists if a cache entry with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, cacheEntry *models.CacheEntry) error
+	// Read an existing cache entry, looking it up by ID.
+	// Returns models.ErrNotFound if the cache entry does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.CacheEntryID) (*models.CacheEntry, error)
+	// ReadByKey reads an existing cache entry, looking it up by repo, cache name and cache key.
+	// Returns models.ErrNotFound if the cache entry does not exist.
+	ReadByKey(ctx context.Context, txOrNil *Tx, repoID models.RepoID, name models.ResourceName, key string) (*models.CacheEntry, error)
+	// ReadMostRecentlyUsed reads the most recently used cache entry with the specified name in a repo, regardless of key.
+	// Returns models.ErrNotFound if the repo has no entries for the cache.
+	ReadMostRecentlyUsed(ctx context.Context, txOrNil *Tx, repoID models.RepoID, name models.ResourceName) (*models.CacheEntry, error)
+	// Update an existing cache entry. Overrides all previous values using the supplied model.
+	Update(ctx context.Context, txOrNil *Tx, cacheEntry *models.CacheEntry) error
+	// Delete permanently and idempotently deletes a cache entry.
+	Delete(ctx context.Context, txOrNil *Tx, id models.CacheEntryID) error
+	// TotalSizeByRepoID returns the total size in bytes of all cache entries in a repo.
+	TotalSizeByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID) (uint64, error)
+	// ListLeastRecentlyUsed lists up to limit cache entries in a repo, least recently used first.
+	ListLeastRecentlyUsed(ctx context.Context, txOrNil *Tx, repoID models.RepoID, limit int) ([]*models.CacheEntry, error)
+}
+
 type GroupStore interface {
 	// Create a new access control Group.
 	// Returns store.ErrAlreadyExists if a group with matching unique properties already exists.
@@ -327,12 +731,57 @@ type GrantStore interface {
 	FindOrCreate(ctx context.Context, txOrNil *Tx, grantData *models.Grant) (grant *models.Grant, created bool, err error)
 	// ListGrantsForGroup finds and returns all grants that give permissions to the specified group.
 	ListGrantsForGroup(ctx context.Context, txOrNil *Tx, groupID models.GroupID, pagination models.Pagination) ([]*models.Grant, *models.Cursor, error)
+	// ListGrantsForIdentity finds and returns all grants that give permissions directly to the specified identity.
+	// Grants the identity has through the groups it is a member of are not included.
+	ListGrantsForIdentity(ctx context.Context, txOrNil *Tx, identityID models.IdentityID, pagination models.Pagination) ([]*models.Grant, *models.Cursor, error)
+	// ListGroupGrantsForTarget finds and returns all grants that give access control groups permissions directly
+	// on the specified target resource, including grants that deny permissions.
+	ListGroupGrantsForTarget(ctx context.Context, txOrNil *Tx, targetResourceID models.ResourceID, pagination models.Pagination) ([]*models.Grant, *models.Cursor, error)
 	// DeleteAllGrantsForGroup permanently and idempotently deletes all grants for the specified group.
 	DeleteAllGrantsForGroup(ctx context.Context, txOrNil *Tx, groupID models.GroupID) error
 	// DeleteAllGrantsForIdentity permanently and idempotently deletes all grants for the specified identity.
 	DeleteAllGrantsForIdentity(ctx context.Context, txOrNil *Tx, identityID models.IdentityID) error
 }
 
+type RoleStore interface {
+	// Create a new role.
+	// Returns store.ErrAlreadyExists if a role with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, role *models.Role) error
+	// Read an existing role, looking it up by ID.
+	// Returns models.ErrNotFound if the role does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.RoleID) (*models.Role, error)
+	// ReadByName reads an existing role, looking it up by role name and the ID of the legal entity
+	// that owns the role. Returns models.ErrNotFound if the role does not exist.
+	ReadByName(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, name models.ResourceName) (*models.Role, error)
+	// Update an existing role with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, role *models.Role) error
+	// Delete permanently and idempotently deletes a role, identifying it by id.
+	// Any assignments of the role are deleted along with it.
+	Delete(ctx context.Context, txOrNil *Tx, id models.RoleID) error
+	// ListByLegalEntityID lists the roles defined by a legal entity. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Role, *models.Cursor, error)
+	// ListByAssignee lists the roles defined by a legal entity that are assigned to the specified group or identity.
+	// Use cursor to page through results, if any.
+	ListByAssignee(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, assigneeID models.ResourceID, pagination models.Pagination) ([]*models.Role, *models.Cursor, error)
+}
+
+type RoleAssignmentStore interface {
+	// Create a new role assignment.
+	// Returns store.ErrAlreadyExists if the role is already assigned to the group or identity.
+	Create(ctx context.Context, txOrNil *Tx, assignment *models.RoleAssignment) error
+	// Read an existing role assignment, looking it up by ID.
+	// Returns models.ErrNotFound if the role assignment does not exist.
+	Read(ctx context.Context, txOrNil *Tx, id models.RoleAssignmentID) (*models.RoleAssignment, error)
+	// ReadByAssignee reads the assignment of a role to the specified group or identity.
+	// Returns models.ErrNotFound if the role is not assigned to the group or identity.
+	ReadByAssignee(ctx context.Context, txOrNil *Tx, roleID models.RoleID, assigneeID models.ResourceID) (*models.RoleAssignment, error)
+	// Delete permanently and idempotently deletes a role assignment, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.RoleAssignmentID) error
+	// ListByRoleID lists the assignments of a role. Use cursor to page through results, if any.
+	ListByRoleID(ctx context.Context, txOrNil *Tx, roleID models.RoleID, pagination models.Pagination) ([]*models.RoleAssignment, *models.Cursor, error)
+}
+
 type OwnershipStore interface {
 	// Create a new ownership.
 	// Returns store.ErrAlreadyExists if an ownership with matching unique properties already exists.
@@ -368,12 +817,18 @@ type CredentialStore interface {
 	// ReadBySharedSecretID reads an existing shared secret credential, looking it up by shared secret ID.
 	// Returns models.ErrNotFound if the credential does not exist.
 	ReadBySharedSecretID(ctx context.Context, txOrNil *Tx, sharedSecretID string) (*models.Credential, error)
+	// ReadByPersonalAccessTokenID reads an existing personal access token credential, looking it up by the
+	// shared secret ID embedded in the token. Returns models.ErrNotFound if the credential does not exist.
+	ReadByPersonalAccessTokenID(ctx context.Context, txOrNil *Tx, sharedSecretID string) (*models.Credential, error)
 	// ReadByGitHubUserID reads an existing GitHub credential, looking it up by the GitHub user ID.
 	// Returns models.ErrNotFound if the credential does not exist.
 	ReadByGitHubUserID(ctx context.Context, txOrNil *Tx, gitHubUserID int64) (*models.Credential, error)
 	// ReadByPublicKey reads an existing client certificate credential, looking it up by from the supplied public key.
 	// Returns models.ErrNotFound if the credential does not exist.
 	ReadByPublicKey(ctx context.Context, txOrNil *Tx, publicKey certificates.PublicKeyData) (*models.Credential, error)
+	// Update an existing credential with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, credential *models.Credential) error
 	// Delete permanently and idempotently deletes a credential.
 	Delete(ctx context.Context, txOrNil *Tx, id models.CredentialID) error
 	// ListCredentialsForIdentity returns a list of all credentials for the specified identity ID.
@@ -384,6 +839,15 @@ type CredentialStore interface {
 		identityID models.IdentityID,
 		pagination models.Pagination,
 	) ([]*models.Credential, *models.Cursor, error)
+	// ListCredentialsOfTypeForIdentity returns a list of the credentials of the specified type for an identity.
+	// Use cursor to page through results, if any.
+	ListCredentialsOfTypeForIdentity(
+		ctx context.Context,
+		txOrNil *Tx,
+		identityID models.IdentityID,
+		credentialType models.CredentialType,
+		pagination models.Pagination,
+	) ([]*models.Credential, *models.Cursor, error)
 }
 
 type ArtifactStore interface {
@@ -397,11 +861,16 @@ type ArtifactStore interface {
 	// Read an existing artifact, looking it up by ID.
 	// Returns models.ErrNotFound if the artifact does not exist.
 	Read(ctx context.Context, txOrNil *Tx, id models.ArtifactID) (*models.Artifact, error)
+	// ListByJobIDs gets all artifacts created by any of the specified jobs.
+	ListByJobIDs(ctx context.Context, txOrNil *Tx, jobIDs []models.JobID) ([]*models.Artifact, error)
 	// FindByUniqueFields returns a matching artifact from the fields that are unique within our store.
 	FindByUniqueFields(ctx context.Context, txOrNil *Tx, artifact *models.ArtifactData) (*models.Artifact, error)
 	// Update an existing artifact with optimistic locking. Overrides all previous values using the supplied model.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *Tx, artifact *models.Artifact) error
+	// Delete permanently and idempotently deletes an artifact. The artifact's data must be deleted from the
+	// blob store separately.
+	Delete(ctx context.Context, txOrNil *Tx, id models.ArtifactID) error
 	// Search all artifacts. If searcher is set, the results will be limited to artifacts the searcher is authorized to
 	// see (via the read:artifact permission). Use cursor to page through results, if any.
 	Search(ctx context.Context, txOrNil *Tx, searcher models.IdentityID, search models.ArtifactSearch) ([]*models.Artifact, *models.Cursor, error)
@@ -414,6 +883,9 @@ type RunnerStore interface {
 	// Read an existing runner, looking it up by ID.
 	// Returns models.ErrNotFound if the runner does not exist.
 	Read(ctx context.Context, txOrNil *Tx, id models.RunnerID) (*models.Runner, error)
+	// ListByIDs reads the runners with the specified IDs, in no particular order. IDs of runners that do not
+	// exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *Tx, ids []models.RunnerID) ([]*models.Runner, error)
 	// ReadByName reads an existing runner, looking it up by name and the ID of the legal entity that owns the runner.
 	// Returns models.ErrNotFound if the runner is not found.
 	ReadByName(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, name models.ResourceName) (*models.Runner, error)
@@ -440,6 +912,9 @@ type RunnerStore interface {
 	// Search all runners. If searcher is set, the results will be limited to runners the searcher is authorized to
 	// see (via the read:runner permission). Use cursor to page through results, if any.
 	Search(ctx context.Context, txOrNil *Tx, searcher models.IdentityID, search models.RunnerSearch) ([]*models.Runner, *models.Cursor, error)
+	// ListOnlineNotSeenSince lists the runners that are marked as online but have not sent a heartbeat since the
+	// specified time. Soft-deleted runners are excluded.
+	ListOnlineNotSeenSince(ctx context.Context, txOrNil *Tx, since models.Time) ([]*models.Runner, error)
 }
 
 type ResourceLinkStore interface {
@@ -461,6 +936,12 @@ type LogStore interface {
 	// Read an existing logs, looking it up by ID.
 	// Returns models.ErrNotFound if the logs does not exist.
 	Read(ctx context.Context, txOrNil *Tx, id models.LogDescriptorID) (*models.LogDescriptor, error)
+	// ListByIDs reads the log descriptors with the specified IDs, in no particular order. IDs of log descriptors
+	// that do not exist are ignored.
+	ListByIDs(ctx context.Context, txOrNil *Tx, ids []models.LogDescriptorID) ([]*models.LogDescriptor, error)
+	// ListByResourceIDs reads the log descriptors for any of the specified builds, jobs or steps, including logs
+	// that are no longer referenced by their resource (e.g. logs from previous attempts at a job), in no particular order.
+	ListByResourceIDs(ctx context.Context, txOrNil *Tx, resourceIDs []models.ResourceID) ([]*models.LogDescriptor, error)
 	// Update an existing logs.
 	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 	Update(ctx context.Context, txOrNil *Tx, container *models.LogDescriptor) error
@@ -469,6 +950,14 @@ type LogStore interface {
 	// Search all log descriptors. If searcher is set, the results will be limited to log descriptors the searcher
 	// is authorized to see (via the read:build permission). Use cursor to page through results, if any.
 	Search(ctx context.Context, txOrNil *Tx, searcher models.IdentityID, search models.LogDescriptorSearch) ([]*models.LogDescriptor, *models.Cursor, error)
+	// ListArchivable lists up to limit sealed logs created before the specified time whose data has not yet been
+	// archived or expired, oldest first.
+	ListArchivable(ctx context.Context, txOrNil *Tx, createdBefore models.Time, limit int) ([]*models.LogDescriptor, error)
+	// ListExpirable lists up to limit sealed logs created before the specified time whose data has not yet been
+	// expired, oldest first. Both archived and unarchived logs are included.
+	ListExpirable(ctx context.Context, txOrNil *Tx, createdBefore models.Time, limit int) ([]*models.LogDescriptor, error)
+	// UsageByRepoID summarizes the storage used by the logs belonging to a repo's builds, jobs and steps.
+	UsageByRepoID(ctx context.Context, txOrNil *Tx, repoID models.RepoID) (*models.LogUsage, error)
 }
 
 type PullRequestStore interface {
@@ -485,6 +974,9 @@ type PullRequestStore interface {
 	// mutable properties if they differ from the in-memory instance. Returns true,false if the resource was
 	// created and false,true if the resource was updated. false,false if neither a create nor update was necessary.
 	Upsert(ctx context.Context, txOrNil *Tx, pullRequest *models.PullRequest) (bool, bool, error)
+	// ReadOpenByHeadRef reads the most recently updated open pull request in a repo whose head is the specified ref.
+	// Returns models.ErrNotFound if there is no such pull request.
+	ReadOpenByHeadRef(ctx context.Context, txOrNil *Tx, repoID models.RepoID, headRef string) (*models.PullRequest, error)
 }
 
 type WorkItemStore interface {
@@ -499,6 +991,9 @@ type WorkItemStore interface {
 	Update(ctx context.Context, txOrNil *Tx, workItem *models.WorkItem) error
 	// Delete permanently and idempotently deletes a work item.
 	Delete(ctx context.Context, txOrNil *Tx, id models.WorkItemID) error
+	// ListUncompleted lists the work items sharing the specified work item state record that have not yet been
+	// completed, in the order they will be processed.
+	ListUncompleted(ctx context.Context, txOrNil *Tx, workItemStateID models.WorkItemStateID) ([]*models.WorkItem, error)
 }
 
 type WorkItemStateStore interface {
@@ -546,6 +1041,9 @@ type EventStore interface {
 	Read(ctx context.Context, txOrNil *Tx, id models.EventID) (*models.Event, error)
 	// DeleteEventsForBuild permanently and idempotently deletes all events for the specified build.
 	DeleteEventsForBuild(ctx context.Context, txOrNil *Tx, buildID models.BuildID) error
+	// DeleteEventCounter permanently and idempotently deletes the event counter for the specified build.
+	// This must only be done when the build itself is deleted, since event numbers must never be reused.
+	DeleteEventCounter(ctx context.Context, txOrNil *Tx, buildID models.BuildID) error
 	// FindEvents reads the next events for a build.
 	// If no matching events are present then an empty list is returned immediately.
 	FindEvents(ctx context.Context, txOrNil *Tx, buildID models.BuildID, lastEventNumber models.EventNumber, limit int) ([]*models.Event, error)
@@ -553,3 +1051,45 @@ type EventStore interface {
 	// a sequence number for a new event.
 	IncrementEventCounter(ctx context.Context, txOrNil *Tx, buildID models.BuildID) (models.EventNumber, error)
 }
+
+type UsageRecordStore interface {
+	// Create a new usage record.
+	// Returns store.ErrAlreadyExists if a usage record with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, record *models.UsageRecord) error
+	// ReadBySource reads an existing usage record, looking it up by the resource the usage was measured from
+	// and the metric. Returns models.ErrNotFound if the usage record does not exist.
+	ReadBySource(ctx context.Context, txOrNil *Tx, sourceResourceID models.ResourceID, metric models.UsageMetric) (*models.UsageRecord, error)
+	// FindOrCreate finds and returns the usage record for the source resource and metric in recordData.
+	// If no such usage record exists then recordData is created and returned, and true is returned for 'created'.
+	FindOrCreate(ctx context.Context, txOrNil *Tx, recordData *models.UsageRecord) (record *models.UsageRecord, created bool, err error)
+	// Rollup totals the usage recorded for a legal entity for each usage period from 'from' to 'to' inclusive,
+	// by repo and metric. Rollups are returned in period order.
+	Rollup(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, from models.UsagePeriod, to models.UsagePeriod) ([]*models.UsageRollup, error)
+}
+
+type UsageQuotaStore interface {
+	// Create a new usage quota.
+	// Returns store.ErrAlreadyExists if a usage quota with matching unique properties already exists.
+	Create(ctx context.Context, txOrNil *Tx, quota *models.UsageQuota) error
+	// ReadByMetric reads the quota a legal entity has for a metric.
+	// Returns models.ErrNotFound if the legal entity has no quota for the metric.
+	ReadByMetric(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, metric models.UsageMetric) (*models.UsageQuota, error)
+	// Update an existing usage quota with optimistic locking. Overrides all previous values using the supplied model.
+	// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+	Update(ctx context.Context, txOrNil *Tx, quota *models.UsageQuota) error
+	// Delete permanently and idempotently deletes a usage quota, identifying it by id.
+	Delete(ctx context.Context, txOrNil *Tx, id models.UsageQuotaID) error
+	// ListByLegalEntityID lists the usage quotas of a legal entity. Use cursor to page through results, if any.
+	ListByLegalEntityID(ctx context.Context, txOrNil *Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.UsageQuota, *models.Cursor, error)
+}
+
+type LeaderLeaseStore interface {
+	// TryAcquire acquires the named lease for holder for leaseDuration, or extends it for leaseDuration if holder
+	// already holds it. The lease can only be acquired if nobody holds it, or if the previous holder's lease has
+	// expired. Lease times are measured using the database's clock, so servers with skewed clocks agree on when
+	// a lease expires. Returns true if holder holds the lease on return.
+	TryAcquire(ctx context.Context, txOrNil *Tx, name string, holder string, leaseDuration time.Duration) (bool, error)
+	// Release idempotently gives up the named lease if it is held by holder, so another holder can acquire
+	// it without waiting for it to expire.
+	Release(ctx context.Context, txOrNil *Tx, name string, holder string) error
+}
@@ -3,6 +3,7 @@ package jobs
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/doug-martin/goqu/v9"
 
@@ -90,6 +91,65 @@ func (d *JobStore) ReadByFingerprint(
 	return job, d.table.ReadIn(ctx, txOrNil, job, ds)
 }
 
+// FindRunningDuplicate locates a job in another build that has the same commit, workflow, name and definition
+// as the specified job, and which has been handed to a runner but has not yet finished. Jobs that are themselves
+// indirected to another job are ignored. Returns models.ErrNotFound if no such job exists.
+func (d *JobStore) FindRunningDuplicate(ctx context.Context, txOrNil *store.Tx, job *models.Job) (*models.Job, error) {
+	duplicate := &models.Job{}
+	ds := goqu.
+		Select(duplicate).
+		From(d.table.TableName()).
+		Where(goqu.Ex{
+			"job_repo_id":                   job.RepoID,
+			"job_commit_id":                 job.CommitID,
+			"job_workflow":                  job.Workflow,
+			"job_name":                      job.Name,
+			"job_definition_data_hash_type": job.DefinitionDataHashType,
+			"job_definition_data_hash":      job.DefinitionDataHash,
+			"job_status":                    goqu.Op{"in": []models.WorkflowStatus{models.WorkflowStatusSubmitted, models.WorkflowStatusRunning}},
+			"job_build_id":                  goqu.Op{"neq": job.BuildID},
+			"job_indirect_to_job_id":        nil,
+		}).
+		Order(goqu.I("job_created_at").Asc()).
+		Limit(1)
+	return duplicate, d.table.ReadIn(ctx, txOrNil, duplicate, ds)
+}
+
+// ListAttached lists the queued jobs that are indirected to the specified job, waiting to finish with
+// the same result.
+func (d *JobStore) ListAttached(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) ([]*models.Job, error) {
+	jobSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Job{}).
+		Where(goqu.Ex{
+			"job_indirect_to_job_id": jobID,
+			"job_status":             models.WorkflowStatusQueued,
+		})
+	var jobs []*models.Job
+	err := d.table.ListAllIn(ctx, txOrNil, &jobs, jobSelect)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ListRunningByRunnerID lists the jobs that have been handed to the specified runner but have not yet finished.
+func (d *JobStore) ListRunningByRunnerID(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID) ([]*models.Job, error) {
+	jobSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Job{}).
+		Where(goqu.Ex{
+			"job_runner_id": runnerID,
+			"job_status":    []models.WorkflowStatus{models.WorkflowStatusSubmitted, models.WorkflowStatusRunning},
+		})
+	var jobs []*models.Job
+	err := d.table.ListAllIn(ctx, txOrNil, &jobs, jobSelect)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
 // Update an existing job with optimistic locking. Overrides all previous values using the supplied model.
 // Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 func (d *JobStore) Update(ctx context.Context, txOrNil *store.Tx, job *models.Job) error {
@@ -111,6 +171,71 @@ func (d *JobStore) ListByBuildID(ctx context.Context, txOrNil *store.Tx, buildID
 	return jobs, nil
 }
 
+// ListByBuildIDIncludingDeleted gets all jobs that are associated with the specified build id, including jobs
+// that have been soft-deleted.
+func (d *JobStore) ListByBuildIDIncludingDeleted(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) ([]*models.Job, error) {
+	jobSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.Job{}).
+		Where(goqu.Ex{"job_build_id": buildID}).
+		Order(goqu.I("job_created_at").Asc(), goqu.I("job_id").Asc())
+
+	// Perform the read directly on the database; ResourceTable.ListIn() is not suitable because it
+	// leaves out soft-deleted jobs
+	var jobs []*models.Job
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := jobSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &jobs, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return jobs, nil
+}
+
+// ListByBuildIDs gets all jobs that are associated with any of the specified build ids.
+func (d *JobStore) ListByBuildIDs(ctx context.Context, txOrNil *store.Tx, buildIDs []models.BuildID) ([]*models.Job, error) {
+	jobSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Job{}).
+		Where(goqu.Ex{"job_build_id": buildIDs})
+	var jobs []*models.Job
+	err := d.table.ListAllIn(ctx, txOrNil, &jobs, jobSelect)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ListLatestByToolchainName lists the most recently created jobs that ran in any version of the named toolchain,
+// for each of the legal entity's repos. Where several jobs in a repo's latest build used the toolchain, all of
+// them are returned.
+func (d *JobStore) ListLatestByToolchainName(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, name models.ResourceName) ([]*models.Job, error) {
+	toolchainPattern := string(name) + "@%"
+	latestCreatedAt := goqu.From(goqu.T("jobs").As("latest_jobs")).
+		Select(goqu.MAX("latest_jobs.job_created_at")).
+		Where(
+			goqu.I("latest_jobs.job_repo_id").Eq(goqu.I("toolchain_jobs.job_repo_id")),
+			goqu.I("latest_jobs.job_docker_toolchain").Like(toolchainPattern))
+	jobSelect := goqu.From(goqu.T("jobs").As("toolchain_jobs")).
+		Select(&models.Job{}).
+		Join(goqu.T("repos"), goqu.On(goqu.Ex{"toolchain_jobs.job_repo_id": goqu.I("repos.repo_id")})).
+		Where(
+			goqu.Ex{"repos.repo_legal_entity_id": legalEntityID},
+			goqu.I("toolchain_jobs.job_docker_toolchain").Like(toolchainPattern),
+			goqu.I("toolchain_jobs.job_created_at").Eq(latestCreatedAt))
+	var jobs []*models.Job
+	err := d.table.ListAllIn(ctx, txOrNil, &jobs, jobSelect)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
 // ListByStatus returns all jobs that have the specified status, regardless of who owns the jobs or which build
 // they are part of. Use cursor to page through results, if any.
 func (d *JobStore) ListByStatus(ctx context.Context, txOrNil *store.Tx, status models.WorkflowStatus, pagination models.Pagination) ([]*models.Job, *models.Cursor, error) {
@@ -126,6 +251,73 @@ func (d *JobStore) ListByStatus(ctx context.Context, txOrNil *store.Tx, status m
 	return jobs, cursor, nil
 }
 
+// ListFinishedByRepoID returns the jobs in a repo that were created from 'from' (inclusive) until 'to' (exclusive)
+// and have since finished. Use cursor to page through results, if any.
+func (d *JobStore) ListFinishedByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, from models.Time, to models.Time, pagination models.Pagination) ([]*models.Job, *models.Cursor, error) {
+	finishedStatuses := []models.WorkflowStatus{
+		models.WorkflowStatusSucceeded,
+		models.WorkflowStatusFailed,
+		models.WorkflowStatusCanceled,
+		models.WorkflowStatusSkipped,
+	}
+	jobSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Job{}).
+		Where(
+			goqu.Ex{"job_repo_id": repoID, "job_status": finishedStatuses},
+			goqu.C("job_created_at").Gte(from),
+			goqu.C("job_created_at").Lt(to))
+	var jobs []*models.Job
+	cursor, err := d.table.ListIn(ctx, txOrNil, &jobs, pagination, jobSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jobs, cursor, nil
+}
+
+// Search all jobs, regardless of who owns the jobs or which build they are part of. Use cursor to page
+// through results, if any.
+// A job is treated as active from when it was created until it was last updated, or until now if the job has not
+// yet finished, and is included if this overlaps the search's time window.
+func (d *JobStore) Search(ctx context.Context, txOrNil *store.Tx, search models.JobSearch) ([]*models.Job, *models.Cursor, error) {
+	jobSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Job{})
+	if search.RunnerID != nil {
+		jobSelect = jobSelect.Where(goqu.Ex{"job_runner_id": search.RunnerID})
+	}
+	if search.Before != nil {
+		beforeValue, err := search.Before.Value()
+		if err != nil {
+			return nil, nil, err
+		}
+		jobSelect = jobSelect.Where(goqu.C("job_created_at").Lt(beforeValue))
+	}
+	if search.After != nil {
+		afterValue, err := search.After.Value()
+		if err != nil {
+			return nil, nil, err
+		}
+		jobSelect = jobSelect.Where(goqu.Or(
+			goqu.C("job_updated_at").Gte(afterValue),
+			goqu.Ex{"job_status": []models.WorkflowStatus{
+				models.WorkflowStatusQueued,
+				models.WorkflowStatusSubmitted,
+				models.WorkflowStatusRunning,
+			}},
+		))
+	}
+	if len(search.Statuses) > 0 {
+		jobSelect = jobSelect.Where(goqu.Ex{"job_status": search.Statuses})
+	}
+	var jobs []*models.Job
+	cursor, err := d.table.ListIn(ctx, txOrNil, &jobs, search.Pagination, jobSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jobs, cursor, nil
+}
+
 // ListDependencies lists all jobs that the specified job depends on.
 // Deferred dependencies (on jobs in other workflows that don't yet exist) will not be listed.
 func (d *JobStore) ListDependencies(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) ([]*models.Job, error) {
@@ -143,6 +335,34 @@ func (d *JobStore) ListDependencies(ctx context.Context, txOrNil *store.Tx, jobI
 	return jobs, nil
 }
 
+// SoftDelete soft deletes an existing job. Soft-deleted jobs can't be read or listed.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *JobStore) SoftDelete(ctx context.Context, txOrNil *store.Tx, job *models.Job) error {
+	return d.table.SoftDelete(ctx, txOrNil, job)
+}
+
+// DeleteByBuildID permanently and idempotently deletes all jobs in the specified build, whether or not they
+// have been soft-deleted, along with the dependencies between them. The jobs' steps and artifacts must be
+// deleted first.
+func (d *JobStore) DeleteByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) error {
+	return d.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := d.db.Write2(tx, func(db store.Writer) error {
+			_, err := db.Delete(goqu.T("jobs_depend_on_jobs")).
+				Where(goqu.Ex{"jobs_depend_on_jobs_build_id": buildID}).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("error executing delete query for job dependencies: %w", store.MakeStandardDBError(err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Jobs can be deduplicated to other jobs in the same build, so delete them all in a single statement
+		return d.table.DeleteWhere(ctx, tx, goqu.Ex{"job_build_id": buildID})
+	})
+}
+
 // CreateDependency records a dependency between jobs where source depends on target.
 func (d *JobStore) CreateDependency(
 	ctx context.Context,
@@ -169,6 +389,20 @@ func (d *JobStore) CreateDependency(
 	})
 }
 
+// DeleteDependencies permanently deletes all dependencies the specified job has on other jobs, including
+// deferred dependencies. Dependencies other jobs have on the specified job are not affected.
+func (d *JobStore) DeleteDependencies(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) error {
+	return d.db.Write2(txOrNil, func(db store.Writer) error {
+		_, err := db.Delete(goqu.T("jobs_depend_on_jobs")).
+			Where(goqu.Ex{"jobs_depend_on_jobs_source_job_id": jobID}).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("error executing delete query for job dependencies: %w", store.MakeStandardDBError(err))
+		}
+		return nil
+	})
+}
+
 // CreateDeferredDependency records a dependency between a job and another job in another workflow
 // which does not yet exist.
 func (d *JobStore) CreateDeferredDependency(
@@ -244,33 +478,29 @@ func (d *JobStore) CreateLabel(ctx context.Context, txOrNil *store.Tx, jobID mod
 }
 
 // FindQueuedJob locates a queued job that the runner is capable of running, and which is ready for
-// execution (e.g all dependencies are completed).
+// execution (e.g all dependencies are completed). If the runner has advertised its capacity then only jobs
+// whose resource requirements fit within the capacity not used by the runner's other jobs are considered.
+// Jobs that require GPUs are only considered if the runner has enough GPUs left, whatever its capacity.
+// Jobs that require an operating system are only considered if the runner reported running on it, and exec
+// jobs are only considered if they are from a repo in the runner's exec job allowlist.
+// Jobs are considered from each legal entity the runner can run jobs for (its own, and any it has been shared
+// with), and are shared fairly between them: the job is chosen from the legal entity with the fewest jobs already
+// submitted or running relative to its scheduling weight, however many repos each legal entity has. Build priority
+// applies within that legal entity's share, so a high priority build can't take runners from other legal entities.
+// Within the legal entity, jobs from the highest priority build are preferred, then jobs from the repo with the fewest jobs
+// already submitted or running, so one repo's large build can't starve the legal entity's other repos of runners.
+// The oldest such job is chosen.
 // Returns models.ErrNotFound if the job does not exist.
 func (d *JobStore) FindQueuedJob(ctx context.Context, txOrNil *store.Tx, runner *models.Runner) (*models.Job, error) {
-	// Find other jobs that queued_jobs.job_id depends on that are not yet done, if any, which would stop it from
-	// being eligible to run
-	dependencySubQuery := goqu.From(goqu.T("jobs").As("candidate_jobs")).
-		Select(goqu.I("job_dependency.job_id")).
-		Join(goqu.T("jobs_depend_on_jobs"), goqu.On(goqu.Ex{"candidate_jobs.job_id": goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_source_job_id")})).
-		Join(goqu.T("jobs").As("job_dependency"), goqu.On(goqu.Ex{"job_dependency.job_id": goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_target_job_id")})).
-		Where(goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_target_job_id").IsNotNull()).
-		Where(goqu.Ex{
-			"jobs_depend_on_jobs_source_job_id": goqu.I("queued_jobs.job_id"),
-			"job_dependency.job_status":         goqu.Op{"notIn": []models.WorkflowStatus{models.WorkflowStatusCanceled, models.WorkflowStatusFailed, models.WorkflowStatusSucceeded}},
-		}).
-		Limit(1)
-
-	// Find deferred cross-workflow dependencies for queued_jobs.job_id, if any, which would stop it from being
-	// eligible to run
-	deferredDependencySubQuery := goqu.From(goqu.T("jobs").As("candidate_deferred_jobs")).
-		Select(goqu.I("jobs_depend_on_jobs_target_job_name")).
-		Join(goqu.T("jobs_depend_on_jobs"), goqu.On(goqu.Ex{"candidate_deferred_jobs.job_id": goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_source_job_id")})).
-		Where(
-			goqu.Ex{"jobs_depend_on_jobs_source_job_id": goqu.I("queued_jobs.job_id")},
-			goqu.C("jobs_depend_on_jobs_target_workflow").IsNotNull(),
-			goqu.C("jobs_depend_on_jobs_target_job_name").IsNotNull(),
-		).
-		Limit(1)
+	capacity := runner.GetCapacity()
+	var inUse models.Resources
+	if !capacity.IsZero() {
+		var err error
+		inUse, err = d.sumResourcesInUse(ctx, txOrNil, runner.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var runnerSupportedJobTypes []string
 	for _, kind := range runner.SupportedJobTypes {
@@ -280,38 +510,239 @@ func (d *JobStore) FindQueuedJob(ctx context.Context, txOrNil *store.Tx, runner
 	jobSelect := goqu.From(goqu.T("jobs").As("queued_jobs")).
 		Select(&models.Job{}). // TODO: use SELECT FOR UPDATE SKIP LOCKED for Postgres/MySQL
 		Join(goqu.T("repos"), goqu.On(goqu.Ex{"queued_jobs.job_repo_id": goqu.I("repos.repo_id")})).
-		Where(goqu.Ex{"repos.repo_legal_entity_id": runner.LegalEntityID}). // only jobs under repos owned by correct legal entity
+		Join(goqu.T("builds"), goqu.On(goqu.Ex{"queued_jobs.job_build_id": goqu.I("builds.build_id")})).
+		Join(goqu.T("legal_entities"), goqu.On(goqu.Ex{"repos.repo_legal_entity_id": goqu.I("legal_entities.legal_entity_id")})).
+		Where(goqu.L("? IN ?", goqu.I("repos.repo_legal_entity_id"), store.MakeRunnerLegalEntityIDsSubQuery(runner.ID))). // only jobs under repos owned by legal entities the runner serves
 		Where(goqu.Ex{"job_status": models.WorkflowStatusQueued}).
-		Where(goqu.V(dependencySubQuery).IsNull()).         // where all jobs this one depends on are done
-		Where(goqu.V(deferredDependencySubQuery).IsNull()). // where this job has no deferred cross-workflow dependencies
+		Where(goqu.Ex{"queued_jobs.job_indirect_to_job_id": nil}).                    // jobs attached to a running duplicate never run
+		Where(goqu.V(makeDependencySubQuery("queued_jobs.job_id")).IsNull()).         // where all jobs this one depends on are done
+		Where(goqu.V(makeDeferredDependencySubQuery("queued_jobs.job_id")).IsNull()). // where this job has no deferred cross-workflow dependencies
 		Where(goqu.Ex{"job_type": goqu.Op{"in": runnerSupportedJobTypes}})
 
+	// Runners that only run exec jobs from some repos must only be given exec jobs from those repos
+	if runner.ExecJobAllowlist != nil && !runner.ExecJobAllowlist.AllowsAll() {
+		jobSelect = jobSelect.Where(goqu.Or(
+			goqu.I("queued_jobs.job_type").Neq(models.JobTypeExec),
+			makeExecJobAllowlistCondition(runner.ExecJobAllowlist)))
+	}
+
 	// All runners can run jobs that don't require any labels
 	labelOrs := []goqu.Expression{goqu.I("job_runs_on").IsNull()}
 
 	// Some runners may additionally be able to run jobs that require labels, if the runner also has labels
 	// (and they match of course...)
 	if len(runner.Labels) > 0 {
-		var runnerLabels []string
-		for _, label := range runner.Labels {
-			runnerLabels = append(runnerLabels, string(label))
-		}
 		// Locate a job that the runner has all the required labels for.
-		labelSubQuery := goqu.From(goqu.T("job_labels")).
-			Select(goqu.I("job_labels.job_label_job_id")).
-			Where(goqu.Ex{
-				"job_labels.job_label_job_id": goqu.I("queued_jobs.job_id"),
-				"job_labels.job_label_label":  goqu.Op{"notIn": runnerLabels},
-			}).
-			Limit(1)
+		labelSubQuery := makeMissingLabelSubQuery("queued_jobs.job_id", runner.Labels)
 		labelOrs = append(labelOrs, goqu.V(labelSubQuery).IsNull()) // where the runner has all labels this job needs
 	}
 	jobSelect = jobSelect.Where(goqu.Or(labelOrs...))
 
+	// Jobs that need a particular operating system only run on runners that reported running on it
+	jobSelect = jobSelect.Where(goqu.Or(
+		goqu.Ex{"queued_jobs.job_operating_system": ""},
+		goqu.Ex{"queued_jobs.job_operating_system": runner.OperatingSystem}))
+
+	// Jobs that don't require a resource can always run; jobs that do must fit in what the runner has left
+	resourceLimits := []struct {
+		column    string
+		capacity  int64
+		remaining int64
+	}{
+		{"queued_jobs.job_cpu_millis", capacity.CPUMillis, capacity.CPUMillis - inUse.CPUMillis},
+		{"queued_jobs.job_memory_bytes", capacity.MemoryBytes, capacity.MemoryBytes - inUse.MemoryBytes},
+		{"queued_jobs.job_disk_bytes", capacity.DiskBytes, capacity.DiskBytes - inUse.DiskBytes},
+	}
+	for _, limit := range resourceLimits {
+		if limit.capacity == 0 {
+			continue // the runner doesn't limit this resource
+		}
+		jobSelect = jobSelect.Where(goqu.Or(
+			goqu.I(limit.column).Eq(0),
+			goqu.I(limit.column).Lte(limit.remaining)))
+	}
+	// A runner without GPUs has none to give, so GPUs are limited even when the capacity is zero
+	jobSelect = jobSelect.Where(goqu.Or(
+		goqu.I("queued_jobs.job_gpus").Eq(0),
+		goqu.I("queued_jobs.job_gpus").Lte(capacity.GPUs-inUse.GPUs)))
+
+	// Jobs from the legal entity with the smallest weighted share of the runners run first, then jobs from
+	// higher priority builds, then jobs from the repo with the fewest active jobs, then the oldest jobs
 	jobSelect = jobSelect.
-		Order(goqu.I("job_created_at").Asc()).
+		Order(
+			goqu.L("CAST((?) AS REAL) / ?",
+				makeLegalEntityActiveJobCountSubQuery("repos.repo_legal_entity_id"),
+				goqu.I("legal_entities.legal_entity_scheduling_weight")).Asc(),
+			goqu.I("builds.build_priority").Desc(),
+			goqu.L("(?)", makeActiveJobCountSubQuery("queued_jobs.job_repo_id")).Asc(),
+			goqu.I("job_created_at").Asc()).
 		Limit(1)
 
 	job := &models.Job{}
 	return job, d.table.ReadIn(ctx, txOrNil, job, jobSelect)
 }
+
+// sumResourcesInUse returns the total resources required by the jobs that have been submitted to or are
+// running on the specified runner.
+func (d *JobStore) sumResourcesInUse(ctx context.Context, txOrNil *store.Tx, runnerID models.RunnerID) (models.Resources, error) {
+	sumSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(
+			goqu.L("COALESCE(SUM(job_cpu_millis), 0)").As("cpu_millis"),
+			goqu.L("COALESCE(SUM(job_memory_bytes), 0)").As("memory_bytes"),
+			goqu.L("COALESCE(SUM(job_disk_bytes), 0)").As("disk_bytes"),
+			goqu.L("COALESCE(SUM(job_gpus), 0)").As("gpus")).
+		Where(goqu.Ex{
+			"job_runner_id": runnerID,
+			"job_status":    []models.WorkflowStatus{models.WorkflowStatusSubmitted, models.WorkflowStatusRunning},
+		})
+
+	var sums []*struct {
+		CPUMillis   int64 `db:"cpu_millis"`
+		MemoryBytes int64 `db:"memory_bytes"`
+		DiskBytes   int64 `db:"disk_bytes"`
+		GPUs        int64 `db:"gpus"`
+	}
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := sumSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &sums, query, args...)
+	})
+	if err != nil {
+		return models.Resources{}, store.MakeStandardDBError(err)
+	}
+	if len(sums) == 0 {
+		return models.Resources{}, nil
+	}
+	return models.Resources{CPUMillis: sums[0].CPUMillis, MemoryBytes: sums[0].MemoryBytes, DiskBytes: sums[0].DiskBytes, GPUs: sums[0].GPUs}, nil
+}
+
+// CountRunnableJobs counts the jobs under repos owned by the legal entity that a runner with the specified
+// labels could run, and that are either ready for execution or already submitted to or running on a runner.
+// Jobs that don't require any labels are only counted if no labels are specified.
+func (d *JobStore) CountRunnableJobs(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, labels models.Labels) (int, error) {
+	jobSelect := goqu.From(goqu.T("jobs").As("runnable_jobs")).
+		Select(goqu.COUNT("*")).
+		Join(goqu.T("repos"), goqu.On(goqu.Ex{"runnable_jobs.job_repo_id": goqu.I("repos.repo_id")})).
+		Where(goqu.Ex{"repos.repo_legal_entity_id": legalEntityID}).
+		Where(goqu.Ex{"runnable_jobs.job_indirect_to_job_id": nil}).
+		Where(goqu.Or(
+			goqu.Ex{"runnable_jobs.job_status": []models.WorkflowStatus{models.WorkflowStatusSubmitted, models.WorkflowStatusRunning}},
+			goqu.And(
+				goqu.Ex{"runnable_jobs.job_status": models.WorkflowStatusQueued},
+				goqu.V(makeDependencySubQuery("runnable_jobs.job_id")).IsNull(),
+				goqu.V(makeDeferredDependencySubQuery("runnable_jobs.job_id")).IsNull(),
+			),
+		))
+	if len(labels) > 0 {
+		jobSelect = jobSelect.
+			Where(goqu.I("runnable_jobs.job_runs_on").IsNotNull()).
+			Where(goqu.V(makeMissingLabelSubQuery("runnable_jobs.job_id", labels)).IsNull())
+	} else {
+		jobSelect = jobSelect.Where(goqu.I("runnable_jobs.job_runs_on").IsNull())
+	}
+
+	var count int
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := jobSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		_, err = db.ScanValContext(ctx, &count, query, args...)
+		return store.MakeStandardDBError(err)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// makeDependencySubQuery returns a query that finds other jobs that the job identified by jobIDColumn depends
+// on that are not yet done, if any, which would stop it from being eligible to run.
+func makeDependencySubQuery(jobIDColumn string) *goqu.SelectDataset {
+	return goqu.From(goqu.T("jobs").As("candidate_jobs")).
+		Select(goqu.I("job_dependency.job_id")).
+		Join(goqu.T("jobs_depend_on_jobs"), goqu.On(goqu.Ex{"candidate_jobs.job_id": goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_source_job_id")})).
+		Join(goqu.T("jobs").As("job_dependency"), goqu.On(goqu.Ex{"job_dependency.job_id": goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_target_job_id")})).
+		Where(goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_target_job_id").IsNotNull()).
+		Where(goqu.Ex{
+			"jobs_depend_on_jobs_source_job_id": goqu.I(jobIDColumn),
+			"job_dependency.job_status":         goqu.Op{"notIn": []models.WorkflowStatus{models.WorkflowStatusCanceled, models.WorkflowStatusFailed, models.WorkflowStatusSucceeded, models.WorkflowStatusSkipped}},
+		}).
+		Limit(1)
+}
+
+// makeActiveJobCountSubQuery returns a query that counts the jobs for the repo identified by repoIDColumn that
+// have been submitted to or are running on a runner.
+func makeActiveJobCountSubQuery(repoIDColumn string) *goqu.SelectDataset {
+	return goqu.From(goqu.T("jobs").As("active_jobs")).
+		Select(goqu.COUNT("*")).
+		Where(goqu.Ex{
+			"active_jobs.job_repo_id": goqu.I(repoIDColumn),
+			"active_jobs.job_status":  []models.WorkflowStatus{models.WorkflowStatusSubmitted, models.WorkflowStatusRunning},
+		})
+}
+
+// makeLegalEntityActiveJobCountSubQuery returns a query that counts the jobs for all repos owned by the legal
+// entity identified by legalEntityIDColumn that have been submitted to or are running on a runner.
+func makeLegalEntityActiveJobCountSubQuery(legalEntityIDColumn string) *goqu.SelectDataset {
+	return goqu.From(goqu.T("jobs").As("legal_entity_active_jobs")).
+		Select(goqu.COUNT("*")).
+		Join(goqu.T("repos").As("legal_entity_repos"),
+			goqu.On(goqu.Ex{"legal_entity_active_jobs.job_repo_id": goqu.I("legal_entity_repos.repo_id")})).
+		Where(goqu.Ex{
+			"legal_entity_repos.repo_legal_entity_id": goqu.I(legalEntityIDColumn),
+			"legal_entity_active_jobs.job_status":     []models.WorkflowStatus{models.WorkflowStatusSubmitted, models.WorkflowStatusRunning},
+		})
+}
+
+// makeExecJobAllowlistCondition returns a condition that is true for jobs from repos allowed by the specified
+// exec job allowlist. The query must join the repos and legal_entities tables.
+func makeExecJobAllowlistCondition(allowlist models.ExecJobAllowlist) goqu.Expression {
+	ownerName := goqu.Func("LOWER", goqu.I("legal_entities.legal_entity_name"))
+	repoName := goqu.Func("LOWER", goqu.I("repos.repo_name"))
+	allowed := []goqu.Expression{goqu.L("1 = 0")} // an empty allowlist allows nothing
+	for _, entry := range allowlist {
+		owner, repo, isRepo := strings.Cut(entry, "/")
+		if isRepo {
+			allowed = append(allowed, goqu.And(ownerName.Eq(owner), repoName.Eq(repo)))
+		} else {
+			allowed = append(allowed, ownerName.Eq(owner))
+		}
+	}
+	return goqu.Or(allowed...)
+}
+
+// makeDeferredDependencySubQuery returns a query that finds deferred cross-workflow dependencies for the job
+// identified by jobIDColumn, if any, which would stop it from being eligible to run.
+func makeDeferredDependencySubQuery(jobIDColumn string) *goqu.SelectDataset {
+	return goqu.From(goqu.T("jobs").As("candidate_deferred_jobs")).
+		Select(goqu.I("jobs_depend_on_jobs_target_job_name")).
+		Join(goqu.T("jobs_depend_on_jobs"), goqu.On(goqu.Ex{"candidate_deferred_jobs.job_id": goqu.I("jobs_depend_on_jobs.jobs_depend_on_jobs_source_job_id")})).
+		Where(
+			goqu.Ex{"jobs_depend_on_jobs_source_job_id": goqu.I(jobIDColumn)},
+			goqu.C("jobs_depend_on_jobs_target_workflow").IsNotNull(),
+			goqu.C("jobs_depend_on_jobs_target_job_name").IsNotNull(),
+		).
+		Limit(1)
+}
+
+// makeMissingLabelSubQuery returns a query that finds a label required by the job identified by jobIDColumn
+// that is not in the specified set of labels, if any, which would stop a runner with those labels running it.
+func makeMissingLabelSubQuery(jobIDColumn string, labels models.Labels) *goqu.SelectDataset {
+	var labelStrs []string
+	for _, label := range labels {
+		labelStrs = append(labelStrs, string(label))
+	}
+	return goqu.From(goqu.T("job_labels")).
+		Select(goqu.I("job_labels.job_label_job_id")).
+		Where(goqu.Ex{
+			"job_labels.job_label_job_id": goqu.I(jobIDColumn),
+			"job_labels.job_label_label":  goqu.Op{"notIn": labelStrs},
+		}).
+		Limit(1)
+}
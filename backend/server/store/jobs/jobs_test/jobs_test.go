@@ -2,11 +2,13 @@ package jobs_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/buildbeaver/buildbeaver/common/gerror"
 	"github.com/buildbeaver/buildbeaver/common/models"
 	"github.com/buildbeaver/buildbeaver/server/app/server_test"
 	"github.com/buildbeaver/buildbeaver/server/dto"
@@ -37,6 +39,231 @@ func TestJob(t *testing.T) {
 	t.Run("CreateRunner", testJobCreate(app.JobStore, build))
 }
 
+func TestFindQueuedJobSharesRunnerByLegalEntityWeight(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// One legal entity has more repos, the other has a higher scheduling weight
+	manyRepos := server_test.CreateCompanyLegalEntity(t, ctx, app, "many-repos", "", "")
+	weighted := server_test.CreateCompanyLegalEntity(t, ctx, app, "weighted", "", "")
+	require.Equal(t, models.DefaultLegalEntitySchedulingWeight, weighted.SchedulingWeight)
+	_, err = app.LegalEntityService.UpdateSchedulingWeight(ctx, weighted.ID, dto.UpdateLegalEntitySchedulingWeight{SchedulingWeight: 3})
+	require.Nil(t, err)
+	_, err = app.LegalEntityService.UpdateSchedulingWeight(ctx, weighted.ID, dto.UpdateLegalEntitySchedulingWeight{SchedulingWeight: 0})
+	require.True(t, gerror.IsValidationFailed(err))
+
+	enqueue := func(legalEntityID models.LegalEntityID, repoName string) {
+		repo := server_test.CreateNamedRepo(t, ctx, app, repoName, legalEntityID)
+		commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntityID)
+		logDescriptor := models.NewLogDescriptor(models.NewTime(time.Now()), models.LogDescriptorID{}, referencedata.ReferenceBuild.ID.ResourceID)
+		err := app.LogStore.Create(ctx, nil, logDescriptor)
+		require.Nil(t, err)
+		build := referencedata.GenerateBuild(repo.ID, commit.ID, logDescriptor.ID, "refs/heads/master", 8)
+		err = app.BuildService.Create(ctx, nil, build.Build)
+		require.Nil(t, err)
+		for _, job := range build.Jobs {
+			err = app.JobStore.Create(ctx, nil, job.Job)
+			require.Nil(t, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		enqueue(manyRepos.ID, fmt.Sprintf("many-repos-%d", i))
+	}
+	enqueue(weighted.ID, "weighted")
+
+	// A runner owned by one legal entity and shared with the other
+	runner := server_test.CreateRunner(t, ctx, app, "", manyRepos.ID, nil)
+	err = app.RunnerService.ShareWithLegalEntity(ctx, nil, runner.ID, weighted.ID)
+	require.Nil(t, err)
+	err = app.RunnerService.ShareWithLegalEntity(ctx, nil, runner.ID, manyRepos.ID)
+	require.True(t, gerror.IsValidationFailed(err))
+
+	// dequeue finds the next job for the runner and submits it, returning the legal entity that owns the job's repo
+	dequeue := func() models.LegalEntityID {
+		job, err := app.JobStore.FindQueuedJob(ctx, nil, runner)
+		require.Nil(t, err)
+		job.Status = models.WorkflowStatusSubmitted
+		job.RunnerID = runner.ID
+		err = app.JobStore.Update(ctx, nil, job)
+		require.Nil(t, err)
+		repo, err := app.RepoStore.Read(ctx, nil, job.RepoID)
+		require.Nil(t, err)
+		return repo.LegalEntityID
+	}
+
+	// The runner is shared according to the legal entities' weights, not their number of repos
+	counts := make(map[models.LegalEntityID]int)
+	for i := 0; i < 8; i++ {
+		counts[dequeue()]++
+	}
+	require.Equal(t, 2, counts[manyRepos.ID])
+	require.Equal(t, 6, counts[weighted.ID])
+
+	// Once the runner is no longer shared it only runs jobs for its own legal entity
+	err = app.RunnerService.StopSharingWithLegalEntity(ctx, nil, runner.ID, weighted.ID)
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, manyRepos.ID, dequeue())
+	}
+}
+
+func TestFindQueuedJobMatchesOperatingSystem(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	logDescriptor := models.NewLogDescriptor(models.NewTime(time.Now()), models.LogDescriptorID{}, referencedata.ReferenceBuild.ID.ResourceID)
+	err = app.LogStore.Create(ctx, nil, logDescriptor)
+	require.Nil(t, err)
+	build := referencedata.GenerateBuild(repo.ID, commit.ID, logDescriptor.ID, "refs/heads/master", 0)
+	err = app.BuildService.Create(ctx, nil, build.Build)
+	require.Nil(t, err)
+	windowsJob := referencedata.GenerateJob(repo.ID, commit.ID, build.ID, logDescriptor.ID, "refs/heads/master", 0).Job
+	windowsJob.OperatingSystem = models.OperatingSystemWindows
+	err = app.JobStore.Create(ctx, nil, windowsJob)
+	require.Nil(t, err)
+
+	// A runner that hasn't reported its operating system, or reported a different one, can't run the job
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	_, err = app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.True(t, gerror.IsNotFound(err))
+	runner.OperatingSystem = string(models.OperatingSystemLinux)
+	runner, err = app.RunnerService.Update(ctx, nil, runner)
+	require.Nil(t, err)
+	_, err = app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.True(t, gerror.IsNotFound(err))
+	compatible, err := app.RunnerService.RunnerCompatibleWithJob(ctx, nil, windowsJob)
+	require.Nil(t, err)
+	require.False(t, compatible)
+
+	// A runner on the job's operating system can run it
+	runner.OperatingSystem = string(models.OperatingSystemWindows)
+	runner, err = app.RunnerService.Update(ctx, nil, runner)
+	require.Nil(t, err)
+	job, err := app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.Nil(t, err)
+	require.Equal(t, windowsJob.ID, job.ID)
+	compatible, err = app.RunnerService.RunnerCompatibleWithJob(ctx, nil, windowsJob)
+	require.Nil(t, err)
+	require.True(t, compatible)
+
+	// Jobs that don't need an operating system run on any runner
+	anyJob := referencedata.GenerateJob(repo.ID, commit.ID, build.ID, logDescriptor.ID, "refs/heads/master", 0).Job
+	err = app.JobStore.Create(ctx, nil, anyJob)
+	require.Nil(t, err)
+	windowsJob.Status = models.WorkflowStatusSucceeded
+	err = app.JobStore.Update(ctx, nil, windowsJob)
+	require.Nil(t, err)
+	runner.OperatingSystem = string(models.OperatingSystemLinux)
+	job, err = app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.Nil(t, err)
+	require.Equal(t, anyJob.ID, job.ID)
+}
+
+func TestFindQueuedJobMatchesExecJobAllowlist(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "Acme", "", "")
+	enqueue := func(repoName string, jobType models.JobType) *models.Job {
+		repo := server_test.CreateNamedRepo(t, ctx, app, repoName, legalEntity.ID)
+		commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+		logDescriptor := models.NewLogDescriptor(models.NewTime(time.Now()), models.LogDescriptorID{}, referencedata.ReferenceBuild.ID.ResourceID)
+		err := app.LogStore.Create(ctx, nil, logDescriptor)
+		require.Nil(t, err)
+		build := referencedata.GenerateBuild(repo.ID, commit.ID, logDescriptor.ID, "refs/heads/master", 0)
+		err = app.BuildService.Create(ctx, nil, build.Build)
+		require.Nil(t, err)
+		job := referencedata.GenerateJob(repo.ID, commit.ID, build.ID, logDescriptor.ID, "refs/heads/master", 0).Job
+		job.Type = jobType
+		err = app.JobStore.Create(ctx, nil, job)
+		require.Nil(t, err)
+		return job
+	}
+	otherExecJob := enqueue("other-repo", models.JobTypeExec)
+
+	// A runner that only allows exec jobs from another repo isn't given the exec job
+	runner := server_test.CreateRunner(t, ctx, app, "", legalEntity.ID, nil)
+	runner.ExecJobAllowlist, err = models.ParseExecJobAllowlist([]string{"acme/Allowed-Repo"})
+	require.Nil(t, err)
+	runner, err = app.RunnerService.Update(ctx, nil, runner)
+	require.Nil(t, err)
+	_, err = app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.True(t, gerror.IsNotFound(err))
+
+	// Exec jobs from allowed repos, and jobs of other types from any repo, can be run
+	allowedExecJob := enqueue("allowed-repo", models.JobTypeExec)
+	job, err := app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.Nil(t, err)
+	require.Equal(t, allowedExecJob.ID, job.ID)
+	allowedExecJob.Status = models.WorkflowStatusSucceeded
+	err = app.JobStore.Update(ctx, nil, allowedExecJob)
+	require.Nil(t, err)
+	dockerJob := enqueue("docker-repo", models.JobTypeDocker)
+	job, err = app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.Nil(t, err)
+	require.Equal(t, dockerJob.ID, job.ID)
+	dockerJob.Status = models.WorkflowStatusSucceeded
+	err = app.JobStore.Update(ctx, nil, dockerJob)
+	require.Nil(t, err)
+
+	// Allowing the owner allows all of its repos
+	runner.ExecJobAllowlist = models.ExecJobAllowlist{"acme"}
+	runner, err = app.RunnerService.Update(ctx, nil, runner)
+	require.Nil(t, err)
+	job, err = app.JobStore.FindQueuedJob(ctx, nil, runner)
+	require.Nil(t, err)
+	require.Equal(t, otherExecJob.ID, job.ID)
+}
+
+func TestListByBuildIDsReadsEveryPage(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	legalEntity, _ := server_test.CreatePersonLegalEntity(t, ctx, app, "", "", "")
+	repo := server_test.CreateRepo(t, ctx, app, legalEntity.ID)
+	commit := server_test.CreateCommit(t, ctx, app, repo.ID, legalEntity.ID)
+	logDescriptor := models.NewLogDescriptor(models.NewTime(time.Now()), models.LogDescriptorID{}, referencedata.ReferenceBuild.ID.ResourceID)
+	err = app.LogStore.Create(ctx, nil, logDescriptor)
+	require.Nil(t, err)
+	build := referencedata.GenerateBuild(repo.ID, commit.ID, logDescriptor.ID, "refs/heads/master", 0)
+	build.Jobs = nil
+	err = app.BuildService.Create(ctx, nil, build.Build)
+	require.Nil(t, err)
+
+	// More jobs than are read in a single page
+	const jobCount = 1201
+	created := make(map[models.JobID]bool)
+	for i := 0; i < jobCount; i++ {
+		job := referencedata.GenerateJob(repo.ID, commit.ID, build.ID, logDescriptor.ID, "refs/heads/master", 0)
+		err = app.JobStore.Create(ctx, nil, job.Job)
+		require.Nil(t, err)
+		created[job.ID] = true
+	}
+
+	jobs, err := app.JobStore.ListByBuildIDs(ctx, nil, []models.BuildID{build.ID})
+	require.Nil(t, err)
+	require.Len(t, jobs, jobCount)
+	for _, job := range jobs {
+		require.True(t, created[job.ID])
+		delete(created, job.ID)
+	}
+}
+
 func testJobCreate(store store.JobStore, build *dto.BuildGraph) func(t *testing.T) {
 	return func(t *testing.T) {
 		err := store.Create(context.Background(), nil, build.Jobs[0].Job)
@@ -0,0 +1,166 @@
+package leader_leases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+const leaderLeasesTableName = "leader_leases"
+
+type LeaderLeaseStore struct {
+	db *store.DB
+	logger.Log
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *LeaderLeaseStore {
+	return &LeaderLeaseStore{
+		db:  db,
+		Log: logFactory("LeaderLeaseStore"),
+	}
+}
+
+// TryAcquire acquires the named lease for holder for leaseDuration, or extends it for leaseDuration if holder
+// already holds it. The lease can only be acquired if nobody holds it, or if the previous holder's lease has
+// expired. Lease times are measured using the database's clock, so servers with skewed clocks agree on when
+// a lease expires. Returns true if holder holds the lease on return.
+func (d *LeaderLeaseStore) TryAcquire(
+	ctx context.Context,
+	txOrNil *store.Tx,
+	name string,
+	holder string,
+	leaseDuration time.Duration,
+) (bool, error) {
+	now := d.databaseTime(0)
+	expiresAt := d.databaseTime(leaseDuration)
+	var acquired bool
+	err := d.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		// Extend the lease if we already hold it
+		renewed, err := d.update(ctx, tx,
+			goqu.Record{"leader_lease_expires_at": expiresAt},
+			goqu.Ex{"leader_lease_name": name, "leader_lease_holder": holder})
+		if err != nil {
+			return fmt.Errorf("error renewing lease: %w", err)
+		}
+		if renewed {
+			acquired = true
+			return nil
+		}
+		// Take over the lease if the previous holder let it expire
+		takenOver, err := d.update(ctx, tx,
+			goqu.Record{
+				"leader_lease_holder":      holder,
+				"leader_lease_acquired_at": now,
+				"leader_lease_expires_at":  expiresAt,
+			},
+			goqu.Ex{"leader_lease_name": name, "leader_lease_expires_at": goqu.Op{"lte": now}})
+		if err != nil {
+			return fmt.Errorf("error taking over expired lease: %w", err)
+		}
+		if takenOver {
+			acquired = true
+			return nil
+		}
+		// Create the lease if nobody has ever held it; if someone else creates it first then they hold it
+		acquired, err = d.insert(ctx, tx, goqu.Record{
+			"leader_lease_name":        name,
+			"leader_lease_holder":      holder,
+			"leader_lease_acquired_at": now,
+			"leader_lease_expires_at":  expiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating lease: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+// Release idempotently gives up the named lease if it is held by holder, so another holder can acquire
+// it without waiting for it to expire.
+func (d *LeaderLeaseStore) Release(ctx context.Context, txOrNil *store.Tx, name string, holder string) error {
+	return d.db.Write2(txOrNil, func(writer store.Writer) error {
+		query, args, err := writer.Delete(goqu.T(leaderLeasesTableName)).
+			Where(goqu.Ex{"leader_lease_name": name, "leader_lease_holder": holder}).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.Tracef("Query: %s, args: %v", query, args)
+		_, err = writer.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("error releasing lease %q: %w", name, store.MakeStandardDBError(err))
+		}
+		return nil
+	})
+}
+
+// update sets the specified columns on the lease matching where, and returns true if a lease was updated.
+func (d *LeaderLeaseStore) update(ctx context.Context, tx *store.Tx, record goqu.Record, where goqu.Ex) (bool, error) {
+	var updated bool
+	err := d.db.Write2(tx, func(writer store.Writer) error {
+		query, args, err := writer.Update(goqu.T(leaderLeasesTableName)).Set(record).Where(where).ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.Tracef("Query: %s, args: %v", query, args)
+		res, err := writer.ExecContext(ctx, query, args...)
+		if err != nil {
+			return store.MakeStandardDBError(err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error determining number of rows updated: %w", err)
+		}
+		updated = rowsAffected == 1
+		return nil
+	})
+	return updated, err
+}
+
+// insert creates a lease from record unless one with the same name already exists, and returns true if
+// the lease was created.
+func (d *LeaderLeaseStore) insert(ctx context.Context, tx *store.Tx, record goqu.Record) (bool, error) {
+	var inserted bool
+	err := d.db.Write2(tx, func(writer store.Writer) error {
+		query, args, err := writer.Insert(goqu.T(leaderLeasesTableName)).
+			Rows(record).
+			OnConflict(goqu.DoNothing()).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.Tracef("Query: %s, args: %v", query, args)
+		res, err := writer.ExecContext(ctx, query, args...)
+		if err != nil {
+			return store.MakeStandardDBError(err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error determining number of rows inserted: %w", err)
+		}
+		inserted = rowsAffected == 1
+		return nil
+	})
+	return inserted, err
+}
+
+// databaseTime returns an expression for the database's current time (in UTC) plus offset, in the same form
+// as lease times are stored in.
+func (d *LeaderLeaseStore) databaseTime(offset time.Duration) exp.LiteralExpression {
+	if d.db.Driver == store.Postgres {
+		return goqu.L("(CURRENT_TIMESTAMP AT TIME ZONE 'UTC') + ? * INTERVAL '1 microsecond'", offset.Microseconds())
+	}
+	// SQLite stores times as text, so format the time the same way as models.Time; 'now' is the same as
+	// CURRENT_TIMESTAMP but with millisecond precision
+	return goqu.L("strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', ?)", fmt.Sprintf("%+.3f seconds", offset.Seconds()))
+}
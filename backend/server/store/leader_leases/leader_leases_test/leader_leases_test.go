@@ -0,0 +1,71 @@
+package leader_leases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buildbeaver/buildbeaver/server/app/server_test"
+)
+
+func TestLeaderLease(t *testing.T) {
+	app, cleanup, err := server_test.New(server_test.TestConfig(t))
+	require.Nil(t, err)
+	defer cleanup()
+
+	ctx := context.Background()
+	// Use a different lease to the one the test server's own leader election uses
+	const name = "leader-leases-test"
+	const leaseDuration = 30 * time.Second
+	// Renewing a lease for a negative duration makes it expire straight away, without waiting for it to expire
+	const expired = -time.Second
+
+	// The first holder to ask gets the lease
+	acquired, err := app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "a", leaseDuration)
+	require.Nil(t, err)
+	require.True(t, acquired)
+
+	// Nobody else can get it until it expires
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "b", leaseDuration)
+	require.Nil(t, err)
+	require.False(t, acquired)
+
+	// The holder can renew it
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "a", expired)
+	require.Nil(t, err)
+	require.True(t, acquired)
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "a", leaseDuration)
+	require.Nil(t, err)
+	require.True(t, acquired)
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "b", leaseDuration)
+	require.Nil(t, err)
+	require.False(t, acquired, "Lease should have been extended by renewal")
+
+	// Once it expires someone else can take it over, after which the previous holder can't renew it
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "a", expired)
+	require.Nil(t, err)
+	require.True(t, acquired)
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "b", leaseDuration)
+	require.Nil(t, err)
+	require.True(t, acquired)
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "a", leaseDuration)
+	require.Nil(t, err)
+	require.False(t, acquired)
+
+	// Only the holder can release it, after which anyone can get it straight away
+	err = app.LeaderLeaseStore.Release(ctx, nil, name, "a")
+	require.Nil(t, err)
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "c", leaseDuration)
+	require.Nil(t, err)
+	require.False(t, acquired)
+	err = app.LeaderLeaseStore.Release(ctx, nil, name, "b")
+	require.Nil(t, err)
+	acquired, err = app.LeaderLeaseStore.TryAcquire(ctx, nil, name, "c", leaseDuration)
+	require.Nil(t, err)
+	require.True(t, acquired)
+
+	// The test server is the only server using its database, so it should be the leader
+	require.True(t, app.LeaderElectionService.IsLeader())
+}
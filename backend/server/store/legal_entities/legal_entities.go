@@ -103,7 +103,7 @@ func (d *LegalEntityStore) Update(ctx context.Context, txOrNil *store.Tx, legalE
 }
 
 // Upsert creates a legal entity if no legal entity with the same External ID already exists, otherwise it updates
-// the existing legal entity's data if it differs from the supplied data.
+// the existing legal entity's data if it differs from the supplied data. The SchedulingWeight field will not be updated.
 // Returns the LegalEntity as it exists in the database after the create or update, and
 // true,false if the resource was created, false,true if the resource was updated, or false,false if
 // neither create nor update was necessary.
@@ -123,6 +123,8 @@ func (d *LegalEntityStore) Upsert(ctx context.Context, txOrNil *store.Tx, legalE
 			return err
 		}, func(tx *store.Tx, obj models.Resource) (bool, error) {
 			existing := obj.(*models.LegalEntity)
+			// The scheduling weight is set by an administrator, not by the data being upserted
+			legalEntityData.SchedulingWeight = existing.SchedulingWeight
 			if reflect.DeepEqual(existing.LegalEntityData, legalEntityData) {
 				return false, nil
 			}
@@ -2,6 +2,7 @@ package logs
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/doug-martin/goqu/v9"
 
@@ -17,11 +18,13 @@ func init() {
 }
 
 type LogStore struct {
+	db    *store.DB
 	table *store.ResourceTable
 }
 
 func NewStore(db *store.DB, logFactory logger.LogFactory) *LogStore {
 	return &LogStore{
+		db:    db,
 		table: store.NewResourceTable(db, logFactory, &models.LogDescriptor{}),
 	}
 }
@@ -39,6 +42,40 @@ func (d *LogStore) Read(ctx context.Context, txOrNil *store.Tx, id models.LogDes
 	return log, d.table.ReadByID(ctx, txOrNil, id.ResourceID, log)
 }
 
+// ListByIDs reads the log descriptors with the specified IDs, in no particular order. IDs of log descriptors that do not
+// exist are ignored.
+func (d *LogStore) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.LogDescriptorID) ([]*models.LogDescriptor, error) {
+	var logs []*models.LogDescriptor
+	return logs, d.table.ReadByIDs(ctx, txOrNil, ids, &logs)
+}
+
+// ListByResourceIDs reads the log descriptors for any of the specified builds, jobs or steps, including logs
+// that are no longer referenced by their resource (e.g. logs from previous attempts at a job), in no particular order.
+func (d *LogStore) ListByResourceIDs(ctx context.Context, txOrNil *store.Tx, resourceIDs []models.ResourceID) ([]*models.LogDescriptor, error) {
+	var logs []*models.LogDescriptor
+	if len(resourceIDs) == 0 {
+		return logs, nil
+	}
+
+	logSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.LogDescriptor{}).
+		Where(goqu.C("log_descriptor_resource_id").In(resourceIDs))
+
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := logSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &logs, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return logs, nil
+}
+
 // Update an existing logs.
 // Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 func (d *LogStore) Update(ctx context.Context, txOrNil *store.Tx, log *models.LogDescriptor) error {
@@ -68,8 +105,12 @@ func (d *LogStore) Search(ctx context.Context, txOrNil *store.Tx, searcher model
 				Where(goqu.Ex{"log_descriptor_id": *search.ParentLogID}).
 				UnionAll(d.table.Dialect().From(goqu.T(d.table.TableName()).As("parent")).
 					Select(
+						goqu.C("log_descriptor_archive_blob_key").Table("parent"),
+						goqu.C("log_descriptor_archived_at").Table("parent"),
+						goqu.C("log_descriptor_archived_size_bytes").Table("parent"),
 						goqu.C("log_descriptor_created_at").Table("parent"),
 						goqu.C("log_descriptor_etag").Table("parent"),
+						goqu.C("log_descriptor_expired_at").Table("parent"),
 						goqu.C("log_descriptor_id").Table("parent"),
 						goqu.C("log_descriptor_parent_log_id").Table("parent"),
 						goqu.C("log_descriptor_resource_id").Table("parent"),
@@ -87,3 +128,89 @@ func (d *LogStore) Search(ctx context.Context, txOrNil *store.Tx, searcher model
 	}
 	return logs, cursor, nil
 }
+
+// ListArchivable lists up to limit sealed logs created before the specified time whose data has not yet been
+// archived or expired, oldest first.
+func (d *LogStore) ListArchivable(ctx context.Context, txOrNil *store.Tx, createdBefore models.Time, limit int) ([]*models.LogDescriptor, error) {
+	return d.listOldest(ctx, txOrNil, limit,
+		goqu.C("log_descriptor_created_at").Lt(createdBefore),
+		goqu.Ex{
+			"log_descriptor_sealed":      true,
+			"log_descriptor_archived_at": nil,
+			"log_descriptor_expired_at":  nil,
+		})
+}
+
+// ListExpirable lists up to limit sealed logs created before the specified time whose data has not yet been
+// expired, oldest first. Both archived and unarchived logs are included.
+func (d *LogStore) ListExpirable(ctx context.Context, txOrNil *store.Tx, createdBefore models.Time, limit int) ([]*models.LogDescriptor, error) {
+	return d.listOldest(ctx, txOrNil, limit,
+		goqu.C("log_descriptor_created_at").Lt(createdBefore),
+		goqu.Ex{
+			"log_descriptor_sealed":     true,
+			"log_descriptor_expired_at": nil,
+		})
+}
+
+func (d *LogStore) listOldest(ctx context.Context, txOrNil *store.Tx, limit int, where ...goqu.Expression) ([]*models.LogDescriptor, error) {
+	var logs []*models.LogDescriptor
+
+	logSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.LogDescriptor{}).
+		Where(where...).
+		Order(goqu.I("log_descriptor_created_at").Asc(), goqu.I("log_descriptor_id").Asc()).
+		Limit(uint(limit))
+
+	// Perform the read directly on the database; ResourceTable.ListIn() is not suitable because it forces
+	// newest-first ordering by creation time
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := logSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &logs, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return logs, nil
+}
+
+// UsageByRepoID summarizes the storage used by the logs belonging to a repo's builds, jobs and steps.
+func (d *LogStore) UsageByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) (*models.LogUsage, error) {
+	resourceIDs := d.table.Dialect().
+		From("builds").Select(goqu.C("build_id")).Where(goqu.Ex{"build_repo_id": repoID}).
+		UnionAll(d.table.Dialect().From("jobs").Select(goqu.C("job_id")).Where(goqu.Ex{"job_repo_id": repoID})).
+		UnionAll(d.table.Dialect().From("steps").Select(goqu.C("step_id")).Where(goqu.Ex{"step_repo_id": repoID}))
+
+	usageSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(
+			goqu.L("COALESCE(SUM(CASE WHEN log_descriptor_archived_at IS NULL AND log_descriptor_expired_at IS NULL THEN 1 ELSE 0 END), 0)").As("live_count"),
+			goqu.L("COALESCE(SUM(CASE WHEN log_descriptor_archived_at IS NULL AND log_descriptor_expired_at IS NULL THEN log_descriptor_size_bytes ELSE 0 END), 0)").As("live_size_bytes"),
+			goqu.L("COALESCE(SUM(CASE WHEN log_descriptor_archived_at IS NOT NULL AND log_descriptor_expired_at IS NULL THEN 1 ELSE 0 END), 0)").As("archived_count"),
+			goqu.L("COALESCE(SUM(CASE WHEN log_descriptor_archived_at IS NOT NULL AND log_descriptor_expired_at IS NULL THEN log_descriptor_archived_size_bytes ELSE 0 END), 0)").As("archived_size_bytes"),
+			goqu.L("COALESCE(SUM(CASE WHEN log_descriptor_expired_at IS NOT NULL THEN 1 ELSE 0 END), 0)").As("expired_count")).
+		Where(goqu.C("log_descriptor_resource_id").In(resourceIDs))
+
+	var usages []*models.LogUsage
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := usageSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &usages, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	usage := &models.LogUsage{RepoID: repoID}
+	if len(usages) > 0 {
+		usage = usages[0]
+		usage.RepoID = repoID
+	}
+	return usage, nil
+}
@@ -0,0 +1,59 @@
+package metrics_samples
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.MetricsSample{})
+}
+
+type MetricsSampleStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *MetricsSampleStore {
+	return &MetricsSampleStore{
+		table: store.NewResourceTable(db, logFactory, &models.MetricsSample{}),
+	}
+}
+
+// Create a new metrics sample.
+// Returns store.ErrAlreadyExists if a metrics sample with matching unique properties already exists.
+func (d *MetricsSampleStore) Create(ctx context.Context, txOrNil *store.Tx, sample *models.MetricsSample) error {
+	return d.table.Create(ctx, txOrNil, sample)
+}
+
+// Delete permanently and idempotently deletes a metrics sample, identifying it by ID.
+func (d *MetricsSampleStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.MetricsSampleID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListCreatedBefore lists the metrics samples created before the specified time.
+// Use cursor to page through results, if any.
+func (d *MetricsSampleStore) ListCreatedBefore(ctx context.Context, txOrNil *store.Tx, createdBefore models.Time, pagination models.Pagination) ([]*models.MetricsSample, *models.Cursor, error) {
+	// Format the time in a form usable in SQL queries
+	createdBeforeValue, err := createdBefore.Value()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error converting time to value: %w", err)
+	}
+
+	samplesSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.MetricsSample{}).
+		Where(goqu.C("metrics_sample_created_at").Lt(createdBeforeValue))
+
+	var samples []*models.MetricsSample
+	cursor, err := d.table.ListIn(ctx, txOrNil, &samples, pagination, samplesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return samples, cursor, nil
+}
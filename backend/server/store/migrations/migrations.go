@@ -995,4 +995,865 @@ var BuildBeaverServerMigrations = MigrationSet{
 					jobs_depend_on_jobs_target_job_id);`,
 		DownSQL: `DROP INDEX jobs_depend_on_jobs_target_job_name_index; `,
 	},
+	{
+		SequenceNumber: 68,
+		Name:           "repo_dynamic_job_restrictions",
+		UpSQL:          `ALTER TABLE repos ADD COLUMN repo_dynamic_job_restrictions text;`,
+		DownSQL:        `ALTER TABLE repos DROP COLUMN repo_dynamic_job_restrictions;`,
+	},
+	{
+		SequenceNumber: 69,
+		Name:           "create_notification_settings",
+		UpSQL: `CREATE TABLE IF NOT EXISTS notification_settings
+				(
+					notification_setting_id text NOT NULL PRIMARY KEY,
+					notification_setting_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					notification_setting_created_at timestamp without time zone NOT NULL,
+					notification_setting_updated_at timestamp without time zone NOT NULL,
+					notification_setting_etag text NOT NULL,
+					notification_setting_channel text NOT NULL,
+					notification_setting_webhook_url_encrypted {{ .Binary}} NOT NULL,
+					notification_setting_data_key_encrypted {{ .Binary}} NOT NULL,
+					notification_setting_on_success BOOL NOT NULL,
+					notification_setting_on_failure BOOL NOT NULL,
+					notification_setting_on_recovery BOOL NOT NULL,
+					notification_setting_message_template text NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS notification_settings_repo_id_index ON notification_settings(
+					notification_setting_repo_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS notification_settings_created_at_id_desc_unique_index ON notification_settings(
+					notification_setting_created_at DESC,
+					notification_setting_id DESC);`,
+		DownSQL: `DROP TABLE notification_settings;`,
+	},
+	{
+		SequenceNumber: 70,
+		Name:           "build_cloned_from_build_id",
+		UpSQL:          `ALTER TABLE builds ADD COLUMN build_cloned_from_build_id text REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE NO ACTION;`,
+		DownSQL:        `ALTER TABLE builds DROP COLUMN build_cloned_from_build_id;`,
+	},
+	{
+		SequenceNumber: 71,
+		Name:           "artifact_scan_status",
+		UpSQL: `ALTER TABLE artifacts ADD COLUMN artifact_scan_status text NOT NULL DEFAULT 'not-scanned';
+				ALTER TABLE artifacts ADD COLUMN artifact_scan_result text NOT NULL DEFAULT '';`,
+		DownSQL: `ALTER TABLE artifacts DROP COLUMN artifact_scan_result;
+				  ALTER TABLE artifacts DROP COLUMN artifact_scan_status;`,
+	},
+	{
+		SequenceNumber: 72,
+		Name:           "create_outgoing_webhooks",
+		UpSQL: `CREATE TABLE IF NOT EXISTS outgoing_webhooks
+				(
+					outgoing_webhook_id text NOT NULL PRIMARY KEY,
+					outgoing_webhook_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					outgoing_webhook_repo_id text REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					outgoing_webhook_created_at timestamp without time zone NOT NULL,
+					outgoing_webhook_updated_at timestamp without time zone NOT NULL,
+					outgoing_webhook_etag text NOT NULL,
+					outgoing_webhook_url text NOT NULL,
+					outgoing_webhook_secret_encrypted {{ .Binary}} NOT NULL,
+					outgoing_webhook_data_key_encrypted {{ .Binary}} NOT NULL,
+					outgoing_webhook_on_build_status_changed BOOL NOT NULL,
+					outgoing_webhook_on_job_status_changed BOOL NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS outgoing_webhooks_legal_entity_id_index ON outgoing_webhooks(
+					outgoing_webhook_legal_entity_id);
+				CREATE INDEX IF NOT EXISTS outgoing_webhooks_repo_id_index ON outgoing_webhooks(
+					outgoing_webhook_repo_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS outgoing_webhooks_created_at_id_desc_unique_index ON outgoing_webhooks(
+					outgoing_webhook_created_at DESC,
+					outgoing_webhook_id DESC);
+				CREATE TABLE IF NOT EXISTS outgoing_webhook_deliveries
+				(
+					outgoing_webhook_delivery_id text NOT NULL PRIMARY KEY,
+					outgoing_webhook_delivery_webhook_id text NOT NULL REFERENCES outgoing_webhooks (outgoing_webhook_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					outgoing_webhook_delivery_created_at timestamp without time zone NOT NULL,
+					outgoing_webhook_delivery_updated_at timestamp without time zone NOT NULL,
+					outgoing_webhook_delivery_etag text NOT NULL,
+					outgoing_webhook_delivery_event_type text NOT NULL,
+					outgoing_webhook_delivery_payload text NOT NULL,
+					outgoing_webhook_delivery_status text NOT NULL,
+					outgoing_webhook_delivery_attempts integer NOT NULL,
+					outgoing_webhook_delivery_response_status_code integer NOT NULL,
+					outgoing_webhook_delivery_response_body text NOT NULL,
+					outgoing_webhook_delivery_error text NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS outgoing_webhook_deliveries_webhook_id_index ON outgoing_webhook_deliveries(
+					outgoing_webhook_delivery_webhook_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS outgoing_webhook_deliveries_created_at_id_desc_unique_index ON outgoing_webhook_deliveries(
+					outgoing_webhook_delivery_created_at DESC,
+					outgoing_webhook_delivery_id DESC);`,
+		DownSQL: `DROP TABLE outgoing_webhook_deliveries;
+				  DROP TABLE outgoing_webhooks;`,
+	},
+	{
+		SequenceNumber: 73,
+		Name:           "create_custom_statuses",
+		UpSQL: `CREATE TABLE IF NOT EXISTS custom_statuses
+				(
+					custom_status_id text NOT NULL PRIMARY KEY,
+					custom_status_build_id text NOT NULL REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					custom_status_created_at timestamp without time zone NOT NULL,
+					custom_status_updated_at timestamp without time zone NOT NULL,
+					custom_status_etag text NOT NULL,
+					custom_status_name text NOT NULL,
+					custom_status_state text NOT NULL,
+					custom_status_description text NOT NULL,
+					custom_status_target_url text NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS custom_statuses_build_id_name_unique_index ON custom_statuses(
+					custom_status_build_id,
+					custom_status_name);
+				CREATE UNIQUE INDEX IF NOT EXISTS custom_statuses_created_at_id_desc_unique_index ON custom_statuses(
+					custom_status_created_at DESC,
+					custom_status_id DESC);`,
+		DownSQL: `DROP TABLE custom_statuses;`,
+	},
+	{
+		SequenceNumber: 74,
+		Name:           "create_email_preferences",
+		UpSQL: `CREATE TABLE IF NOT EXISTS email_preferences
+				(
+					email_preference_id text NOT NULL PRIMARY KEY,
+					email_preference_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					email_preference_created_at timestamp without time zone NOT NULL,
+					email_preference_updated_at timestamp without time zone NOT NULL,
+					email_preference_etag text NOT NULL,
+					email_preference_enabled BOOL NOT NULL,
+					email_preference_only_my_builds BOOL NOT NULL,
+					email_preference_only_failures BOOL NOT NULL,
+					email_preference_digest BOOL NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS email_preferences_legal_entity_id_unique_index ON email_preferences(
+					email_preference_legal_entity_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS email_preferences_created_at_id_desc_unique_index ON email_preferences(
+					email_preference_created_at DESC,
+					email_preference_id DESC);
+				CREATE TABLE IF NOT EXISTS email_digest_entries
+				(
+					email_digest_entry_id text NOT NULL PRIMARY KEY,
+					email_digest_entry_created_at timestamp without time zone NOT NULL,
+					email_digest_entry_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					email_digest_entry_build_id text NOT NULL REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS email_digest_entries_legal_entity_id_index ON email_digest_entries(
+					email_digest_entry_legal_entity_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS email_digest_entries_created_at_id_desc_unique_index ON email_digest_entries(
+					email_digest_entry_created_at DESC,
+					email_digest_entry_id DESC);`,
+		DownSQL: `DROP TABLE email_digest_entries;
+				  DROP TABLE email_preferences;`,
+	},
+	{
+		SequenceNumber: 75,
+		Name:           "create_metrics_samples",
+		UpSQL: `CREATE TABLE IF NOT EXISTS metrics_samples
+				(
+					metrics_sample_id text NOT NULL PRIMARY KEY,
+					metrics_sample_created_at timestamp without time zone NOT NULL,
+					metrics_sample_kind text NOT NULL,
+					metrics_sample_repo_id text NOT NULL,
+					metrics_sample_owner_name text NOT NULL,
+					metrics_sample_repo_name text NOT NULL,
+					metrics_sample_resource_id text NOT NULL,
+					metrics_sample_status text NOT NULL,
+					metrics_sample_queue_wait_millis bigint NOT NULL,
+					metrics_sample_duration_millis bigint NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS metrics_samples_created_at_id_desc_unique_index ON metrics_samples(
+					metrics_sample_created_at DESC,
+					metrics_sample_id DESC);`,
+		DownSQL: `DROP TABLE metrics_samples;`,
+	},
+	{
+		SequenceNumber: 76,
+		Name:           "create_test_results",
+		UpSQL: `CREATE TABLE IF NOT EXISTS test_runs
+				(
+					test_run_id text NOT NULL PRIMARY KEY,
+					test_run_created_at timestamp without time zone NOT NULL,
+					test_run_build_id text NOT NULL REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					test_run_job_id text NOT NULL REFERENCES jobs (job_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					test_run_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					test_run_artifact_id text NOT NULL,
+					test_run_format text NOT NULL,
+					test_run_total integer NOT NULL,
+					test_run_passed integer NOT NULL,
+					test_run_failed integer NOT NULL,
+					test_run_skipped integer NOT NULL,
+					test_run_flaky integer NOT NULL,
+					test_run_duration_millis bigint NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS test_runs_artifact_id_unique_index ON test_runs(
+					test_run_artifact_id);
+				CREATE INDEX IF NOT EXISTS test_runs_build_id_index ON test_runs(
+					test_run_build_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS test_runs_created_at_id_desc_unique_index ON test_runs(
+					test_run_created_at DESC,
+					test_run_id DESC);
+				CREATE TABLE IF NOT EXISTS test_cases
+				(
+					test_case_id text NOT NULL PRIMARY KEY,
+					test_case_created_at timestamp without time zone NOT NULL,
+					test_case_test_run_id text NOT NULL REFERENCES test_runs (test_run_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					test_case_build_id text NOT NULL REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					test_case_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					test_case_suite text NOT NULL,
+					test_case_name text NOT NULL,
+					test_case_status text NOT NULL,
+					test_case_attempts integer NOT NULL,
+					test_case_duration_millis bigint NOT NULL,
+					test_case_message text NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS test_cases_test_run_id_index ON test_cases(
+					test_case_test_run_id);
+				CREATE INDEX IF NOT EXISTS test_cases_build_id_index ON test_cases(
+					test_case_build_id);
+				CREATE INDEX IF NOT EXISTS test_cases_repo_id_suite_name_index ON test_cases(
+					test_case_repo_id,
+					test_case_suite,
+					test_case_name);
+				CREATE UNIQUE INDEX IF NOT EXISTS test_cases_created_at_id_desc_unique_index ON test_cases(
+					test_case_created_at DESC,
+					test_case_id DESC);
+				CREATE TABLE IF NOT EXISTS test_summaries
+				(
+					test_summary_id text NOT NULL PRIMARY KEY,
+					test_summary_created_at timestamp without time zone NOT NULL,
+					test_summary_updated_at timestamp without time zone NOT NULL,
+					test_summary_build_id text NOT NULL REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					test_summary_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					test_summary_runs integer NOT NULL,
+					test_summary_total integer NOT NULL,
+					test_summary_passed integer NOT NULL,
+					test_summary_failed integer NOT NULL,
+					test_summary_skipped integer NOT NULL,
+					test_summary_flaky integer NOT NULL,
+					test_summary_duration_millis bigint NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS test_summaries_build_id_unique_index ON test_summaries(
+					test_summary_build_id);
+				CREATE INDEX IF NOT EXISTS test_summaries_repo_id_index ON test_summaries(
+					test_summary_repo_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS test_summaries_created_at_id_desc_unique_index ON test_summaries(
+					test_summary_created_at DESC,
+					test_summary_id DESC);`,
+		DownSQL: `DROP TABLE test_summaries;
+				  DROP TABLE test_cases;
+				  DROP TABLE test_runs;`,
+	},
+	{
+		SequenceNumber: 77,
+		Name:           "create_coverage",
+		UpSQL: `ALTER TABLE repos ADD COLUMN repo_coverage_settings text;
+				CREATE TABLE IF NOT EXISTS coverage_reports
+				(
+					coverage_report_id text NOT NULL PRIMARY KEY,
+					coverage_report_created_at timestamp without time zone NOT NULL,
+					coverage_report_build_id text NOT NULL REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					coverage_report_job_id text NOT NULL REFERENCES jobs (job_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					coverage_report_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					coverage_report_artifact_id text NOT NULL,
+					coverage_report_format text NOT NULL,
+					coverage_report_files integer NOT NULL,
+					coverage_report_lines_covered integer NOT NULL,
+					coverage_report_lines_valid integer NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS coverage_reports_artifact_id_unique_index ON coverage_reports(
+					coverage_report_artifact_id);
+				CREATE INDEX IF NOT EXISTS coverage_reports_build_id_index ON coverage_reports(
+					coverage_report_build_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS coverage_reports_created_at_id_desc_unique_index ON coverage_reports(
+					coverage_report_created_at DESC,
+					coverage_report_id DESC);
+				CREATE TABLE IF NOT EXISTS build_coverages
+				(
+					build_coverage_id text NOT NULL PRIMARY KEY,
+					build_coverage_created_at timestamp without time zone NOT NULL,
+					build_coverage_updated_at timestamp without time zone NOT NULL,
+					build_coverage_build_id text NOT NULL REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					build_coverage_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					build_coverage_ref text NOT NULL,
+					build_coverage_reports integer NOT NULL,
+					build_coverage_lines_covered integer NOT NULL,
+					build_coverage_lines_valid integer NOT NULL,
+					build_coverage_files text NOT NULL,
+					build_coverage_target_ref text NOT NULL,
+					build_coverage_target_build_id text,
+					build_coverage_target_lines_covered integer NOT NULL,
+					build_coverage_target_lines_valid integer NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS build_coverages_build_id_unique_index ON build_coverages(
+					build_coverage_build_id);
+				CREATE INDEX IF NOT EXISTS build_coverages_repo_id_ref_index ON build_coverages(
+					build_coverage_repo_id,
+					build_coverage_ref);
+				CREATE UNIQUE INDEX IF NOT EXISTS build_coverages_created_at_id_desc_unique_index ON build_coverages(
+					build_coverage_created_at DESC,
+					build_coverage_id DESC);`,
+		DownSQL: `DROP TABLE build_coverages;
+				  DROP TABLE coverage_reports;
+				  ALTER TABLE repos DROP COLUMN repo_coverage_settings;`,
+	},
+	{
+		SequenceNumber: 78,
+		Name:           "add_runner_health",
+		UpSQL: `ALTER TABLE runners ADD COLUMN runner_health text;
+				ALTER TABLE outgoing_webhooks ADD COLUMN outgoing_webhook_on_runner_health_changed bool NOT NULL default FALSE;`,
+		DownSQL: `ALTER TABLE outgoing_webhooks DROP COLUMN outgoing_webhook_on_runner_health_changed;
+				  ALTER TABLE runners DROP COLUMN runner_health;`,
+	},
+	{
+		SequenceNumber: 79,
+		Name:           "create_cache_entries",
+		UpSQL: `ALTER TABLE jobs ADD COLUMN job_cache_definitions text;
+				CREATE TABLE IF NOT EXISTS cache_entries
+				(
+					cache_entry_id text NOT NULL PRIMARY KEY,
+					cache_entry_created_at timestamp without time zone NOT NULL,
+					cache_entry_last_used_at timestamp without time zone NOT NULL,
+					cache_entry_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					cache_entry_job_id text NOT NULL,
+					cache_entry_name text NOT NULL,
+					cache_entry_key text NOT NULL,
+					cache_entry_size bigint NOT NULL,
+					cache_entry_hash_type text NOT NULL,
+					cache_entry_hash text NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS cache_entries_repo_id_name_key_unique_index ON cache_entries(
+					cache_entry_repo_id,
+					cache_entry_name,
+					cache_entry_key);
+				CREATE INDEX IF NOT EXISTS cache_entries_repo_id_last_used_at_index ON cache_entries(
+					cache_entry_repo_id,
+					cache_entry_last_used_at);
+				CREATE UNIQUE INDEX IF NOT EXISTS cache_entries_created_at_id_desc_unique_index ON cache_entries(
+					cache_entry_created_at DESC,
+					cache_entry_id DESC);`,
+		DownSQL: `DROP TABLE cache_entries;
+				  ALTER TABLE jobs DROP COLUMN job_cache_definitions;`,
+	},
+	{
+		SequenceNumber: 80,
+		Name:           "add_step_artifact_definitions",
+		UpSQL:          `ALTER TABLE steps ADD COLUMN step_artifact_definitions text;`,
+		DownSQL:        `ALTER TABLE steps DROP COLUMN step_artifact_definitions;`,
+	},
+	{
+		SequenceNumber: 81,
+		Name:           "add_job_stage",
+		UpSQL:          `ALTER TABLE jobs ADD COLUMN job_stage text NOT NULL DEFAULT '';`,
+		DownSQL:        `ALTER TABLE jobs DROP COLUMN job_stage;`,
+	},
+	{
+		SequenceNumber: 82,
+		Name:           "add_step_timeout_seconds",
+		UpSQL:          `ALTER TABLE steps ADD COLUMN step_timeout_seconds integer NOT NULL DEFAULT 0;`,
+		DownSQL:        `ALTER TABLE steps DROP COLUMN step_timeout_seconds;`,
+	},
+	{
+		SequenceNumber: 83,
+		Name:           "add_log_descriptor_retention_fields",
+		UpSQL: `ALTER TABLE log_descriptors ADD COLUMN log_descriptor_archived_at timestamp without time zone;
+				ALTER TABLE log_descriptors ADD COLUMN log_descriptor_archive_blob_key text NOT NULL DEFAULT '';
+				ALTER TABLE log_descriptors ADD COLUMN log_descriptor_archived_size_bytes bigint NOT NULL DEFAULT 0;
+				ALTER TABLE log_descriptors ADD COLUMN log_descriptor_expired_at timestamp without time zone;
+				CREATE INDEX IF NOT EXISTS log_descriptors_created_at_index ON log_descriptors(log_descriptor_created_at);`,
+		DownSQL: `DROP INDEX log_descriptors_created_at_index;
+				  ALTER TABLE log_descriptors DROP COLUMN log_descriptor_archived_at;
+				  ALTER TABLE log_descriptors DROP COLUMN log_descriptor_archive_blob_key;
+				  ALTER TABLE log_descriptors DROP COLUMN log_descriptor_archived_size_bytes;
+				  ALTER TABLE log_descriptors DROP COLUMN log_descriptor_expired_at;`,
+	},
+	{
+		SequenceNumber: 84,
+		Name:           "add_artifact_sha256_and_signature",
+		UpSQL: `ALTER TABLE artifacts ADD COLUMN artifact_sha256 text NOT NULL DEFAULT '';
+				ALTER TABLE artifacts ADD COLUMN artifact_signature text NOT NULL DEFAULT '';`,
+		DownSQL: `ALTER TABLE artifacts DROP COLUMN artifact_sha256;
+				  ALTER TABLE artifacts DROP COLUMN artifact_signature;`,
+	},
+	{
+		SequenceNumber: 85,
+		Name:           "add_job_skip_checkout",
+		UpSQL:          `ALTER TABLE jobs ADD COLUMN job_skip_checkout bool NOT NULL DEFAULT FALSE;`,
+		DownSQL:        `ALTER TABLE jobs DROP COLUMN job_skip_checkout;`,
+	},
+	{
+		SequenceNumber: 86,
+		Name:           "add_job_artifact_from",
+		UpSQL:          `ALTER TABLE jobs ADD COLUMN job_artifact_from text;`,
+		DownSQL:        `ALTER TABLE jobs DROP COLUMN job_artifact_from;`,
+	},
+	{
+		SequenceNumber: 87,
+		Name:           "add_build_warnings",
+		UpSQL:          `ALTER TABLE builds ADD COLUMN build_warnings text;`,
+		DownSQL:        `ALTER TABLE builds DROP COLUMN build_warnings;`,
+	},
+	{
+		SequenceNumber: 88,
+		Name:           "add_job_and_step_condition",
+		UpSQL: `ALTER TABLE jobs ADD COLUMN job_condition text NOT NULL DEFAULT '';
+				ALTER TABLE steps ADD COLUMN step_condition text NOT NULL DEFAULT '';`,
+		DownSQL: `ALTER TABLE steps DROP COLUMN step_condition;
+				  ALTER TABLE jobs DROP COLUMN job_condition;`,
+	},
+	{
+		SequenceNumber: 89,
+		Name:           "add_repo_public_builds",
+		UpSQL: `ALTER TABLE repos ADD COLUMN repo_public_builds bool NOT NULL DEFAULT FALSE;
+				INSERT INTO identities (identity_id, identity_created_at, identity_owner_resource_id)
+					VALUES ('identity:00000000-0000-0000-0000-000000000000', CURRENT_TIMESTAMP, 'identity:00000000-0000-0000-0000-000000000000');`,
+		DownSQL: `DELETE FROM access_control_grants WHERE access_control_grant_authorized_identity_id = 'identity:00000000-0000-0000-0000-000000000000';
+				  DELETE FROM identities WHERE identity_id = 'identity:00000000-0000-0000-0000-000000000000';
+				  ALTER TABLE repos DROP COLUMN repo_public_builds;`,
+	},
+	{
+		SequenceNumber: 90,
+		Name:           "add_job_path_filters",
+		UpSQL: `ALTER TABLE jobs ADD COLUMN job_only_paths text;
+				ALTER TABLE jobs ADD COLUMN job_ignore_paths text;`,
+		DownSQL: `ALTER TABLE jobs DROP COLUMN job_ignore_paths;
+				  ALTER TABLE jobs DROP COLUMN job_only_paths;`,
+	},
+	{
+		SequenceNumber: 91,
+		Name:           "create_build_rule_sets",
+		UpSQL: `CREATE TABLE IF NOT EXISTS build_rule_sets
+				(
+					build_rule_set_id text NOT NULL PRIMARY KEY,
+					build_rule_set_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					build_rule_set_created_at timestamp without time zone NOT NULL,
+					build_rule_set_updated_at timestamp without time zone NOT NULL,
+					build_rule_set_etag text NOT NULL,
+					build_rule_set_allow_refs text,
+					build_rule_set_deny_refs text,
+					build_rule_set_build_tags BOOL NOT NULL,
+					build_rule_set_pull_requests_only BOOL NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS build_rule_sets_repo_id_unique_index ON build_rule_sets(
+					build_rule_set_repo_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS build_rule_sets_created_at_id_desc_unique_index ON build_rule_sets(
+					build_rule_set_created_at DESC,
+					build_rule_set_id DESC);`,
+		DownSQL: `DROP TABLE build_rule_sets;`,
+	},
+	{
+		SequenceNumber: 92,
+		Name:           "add_build_rule_set_skip_pattern",
+		UpSQL:          `ALTER TABLE build_rule_sets ADD COLUMN build_rule_set_skip_pattern text NOT NULL DEFAULT '';`,
+		DownSQL:        `ALTER TABLE build_rule_sets DROP COLUMN build_rule_set_skip_pattern;`,
+	},
+	{
+		SequenceNumber: 93,
+		Name:           "add_build_priority",
+		UpSQL: `ALTER TABLE builds ADD COLUMN build_priority integer NOT NULL DEFAULT 0;
+				ALTER TABLE repos ADD COLUMN repo_build_priority_settings text;`,
+		DownSQL: `ALTER TABLE repos DROP COLUMN repo_build_priority_settings;
+				  ALTER TABLE builds DROP COLUMN build_priority;`,
+	},
+	{
+		SequenceNumber: 94,
+		Name:           "add_repo_deduplicate_jobs",
+		UpSQL:          `ALTER TABLE repos ADD COLUMN repo_deduplicate_jobs bool NOT NULL DEFAULT FALSE;`,
+		DownSQL:        `ALTER TABLE repos DROP COLUMN repo_deduplicate_jobs;`,
+	},
+	{
+		SequenceNumber: 95,
+		Name:           "add_outgoing_webhook_failure_tracking",
+		UpSQL: `ALTER TABLE outgoing_webhooks ADD COLUMN outgoing_webhook_consecutive_failed_deliveries integer NOT NULL DEFAULT 0;
+				ALTER TABLE outgoing_webhooks ADD COLUMN outgoing_webhook_disabled_at timestamp without time zone;
+				ALTER TABLE outgoing_webhooks ADD COLUMN outgoing_webhook_disabled_reason text NOT NULL DEFAULT '';
+				ALTER TABLE outgoing_webhook_deliveries ADD COLUMN outgoing_webhook_delivery_attempt_log text;`,
+		DownSQL: `ALTER TABLE outgoing_webhook_deliveries DROP COLUMN outgoing_webhook_delivery_attempt_log;
+				  ALTER TABLE outgoing_webhooks DROP COLUMN outgoing_webhook_disabled_reason;
+				  ALTER TABLE outgoing_webhooks DROP COLUMN outgoing_webhook_disabled_at;
+				  ALTER TABLE outgoing_webhooks DROP COLUMN outgoing_webhook_consecutive_failed_deliveries;`,
+	},
+	{
+		SequenceNumber: 96,
+		Name:           "add_runner_heartbeats",
+		UpSQL: `ALTER TABLE runners ADD COLUMN runner_last_seen_at timestamp without time zone;
+				ALTER TABLE runners ADD COLUMN runner_online bool NOT NULL DEFAULT FALSE;`,
+		DownSQL: `ALTER TABLE runners DROP COLUMN runner_online;
+				  ALTER TABLE runners DROP COLUMN runner_last_seen_at;`,
+	},
+	{
+		SequenceNumber: 97,
+		Name:           "add_job_runner_loss_retries",
+		UpSQL: `ALTER TABLE jobs ADD COLUMN job_retry_on_runner_loss bool NOT NULL DEFAULT FALSE;
+				ALTER TABLE jobs ADD COLUMN job_runner_loss_retries integer NOT NULL DEFAULT 0;`,
+		DownSQL: `ALTER TABLE jobs DROP COLUMN job_runner_loss_retries;
+				  ALTER TABLE jobs DROP COLUMN job_retry_on_runner_loss;`,
+	},
+	{
+		SequenceNumber: 98,
+		Name:           "create_runner_pools",
+		UpSQL: `CREATE TABLE IF NOT EXISTS runner_pools
+				(
+					runner_pool_id text NOT NULL PRIMARY KEY,
+					runner_pool_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					runner_pool_name text NOT NULL,
+					runner_pool_created_at timestamp without time zone NOT NULL,
+					runner_pool_updated_at timestamp without time zone NOT NULL,
+					runner_pool_etag text NOT NULL,
+					runner_pool_labels text,
+					runner_pool_min_runners integer NOT NULL,
+					runner_pool_max_runners integer NOT NULL,
+					runner_pool_scale_down_idle_timeout_seconds integer NOT NULL,
+					runner_pool_provisioner_type text NOT NULL,
+					runner_pool_provisioner_config text NOT NULL,
+					runner_pool_secret_encrypted {{ .Binary}},
+					runner_pool_data_key_encrypted {{ .Binary}},
+					runner_pool_desired_runners integer NOT NULL,
+					runner_pool_last_busy_at timestamp without time zone,
+					runner_pool_last_scaled_at timestamp without time zone,
+					runner_pool_last_error text NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS runner_pools_legal_entity_id_name_unique_index ON runner_pools(
+					runner_pool_legal_entity_id,
+					runner_pool_name);
+				CREATE UNIQUE INDEX IF NOT EXISTS runner_pools_created_at_id_desc_unique_index ON runner_pools(
+					runner_pool_created_at DESC,
+					runner_pool_id DESC);`,
+		DownSQL: `DROP TABLE runner_pools;`,
+	},
+	{
+		SequenceNumber: 99,
+		Name:           "add_job_shell",
+		UpSQL:          `ALTER TABLE jobs ADD COLUMN job_shell text;`,
+		DownSQL:        `ALTER TABLE jobs DROP COLUMN job_shell;`,
+	},
+	{
+		SequenceNumber: 100,
+		Name:           "add_job_resources_and_runner_capacity",
+		UpSQL: `ALTER TABLE jobs ADD COLUMN job_cpu_millis bigint NOT NULL DEFAULT 0;
+				ALTER TABLE jobs ADD COLUMN job_memory_bytes bigint NOT NULL DEFAULT 0;
+				ALTER TABLE jobs ADD COLUMN job_disk_bytes bigint NOT NULL DEFAULT 0;
+				ALTER TABLE runners ADD COLUMN runner_capacity_cpu_millis bigint NOT NULL DEFAULT 0;
+				ALTER TABLE runners ADD COLUMN runner_capacity_memory_bytes bigint NOT NULL DEFAULT 0;
+				ALTER TABLE runners ADD COLUMN runner_capacity_disk_bytes bigint NOT NULL DEFAULT 0;`,
+		DownSQL: `ALTER TABLE runners DROP COLUMN runner_capacity_disk_bytes;
+				  ALTER TABLE runners DROP COLUMN runner_capacity_memory_bytes;
+				  ALTER TABLE runners DROP COLUMN runner_capacity_cpu_millis;
+				  ALTER TABLE jobs DROP COLUMN job_disk_bytes;
+				  ALTER TABLE jobs DROP COLUMN job_memory_bytes;
+				  ALTER TABLE jobs DROP COLUMN job_cpu_millis;`,
+	},
+	{
+		SequenceNumber: 101,
+		Name:           "add_job_timeout_seconds",
+		UpSQL:          `ALTER TABLE jobs ADD COLUMN job_timeout_seconds integer NOT NULL DEFAULT 0;`,
+		DownSQL:        `ALTER TABLE jobs DROP COLUMN job_timeout_seconds;`,
+	},
+	{
+		SequenceNumber: 102,
+		Name:           "add_step_echo_commands",
+		UpSQL:          `ALTER TABLE steps ADD COLUMN step_echo_commands boolean;`,
+		DownSQL:        `ALTER TABLE steps DROP COLUMN step_echo_commands;`,
+	},
+	{
+		SequenceNumber: 103,
+		Name:           "add_job_gpus_and_runner_capacity_gpus",
+		UpSQL: `ALTER TABLE jobs ADD COLUMN job_gpus bigint NOT NULL DEFAULT 0;
+				ALTER TABLE runners ADD COLUMN runner_capacity_gpus bigint NOT NULL DEFAULT 0;`,
+		DownSQL: `ALTER TABLE runners DROP COLUMN runner_capacity_gpus;
+				  ALTER TABLE jobs DROP COLUMN job_gpus;`,
+	},
+	{
+		SequenceNumber: 104,
+		Name:           "create_toolchains",
+		UpSQL: `CREATE TABLE IF NOT EXISTS toolchains
+				(
+					toolchain_id text NOT NULL PRIMARY KEY,
+					toolchain_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					toolchain_created_at timestamp without time zone NOT NULL,
+					toolchain_updated_at timestamp without time zone NOT NULL,
+					toolchain_etag text NOT NULL,
+					toolchain_name text NOT NULL,
+					toolchain_version text NOT NULL,
+					toolchain_image text NOT NULL,
+					toolchain_digest text NOT NULL,
+					toolchain_created_by_build_id text REFERENCES builds (build_id) ON UPDATE NO ACTION ON DELETE SET NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS toolchains_legal_entity_id_name_version_unique_index ON toolchains(
+					toolchain_legal_entity_id,
+					toolchain_name,
+					toolchain_version);
+				CREATE UNIQUE INDEX IF NOT EXISTS toolchains_created_at_id_desc_unique_index ON toolchains(
+					toolchain_created_at DESC,
+					toolchain_id DESC);
+				ALTER TABLE jobs ADD COLUMN job_docker_toolchain text NOT NULL DEFAULT '';`,
+		DownSQL: `ALTER TABLE jobs DROP COLUMN job_docker_toolchain;
+				  DROP TABLE toolchains;`,
+	},
+	{
+		SequenceNumber: 105,
+		Name:           "create_secret_versions",
+		UpSQL: `ALTER TABLE secrets ADD COLUMN secret_version integer NOT NULL DEFAULT 1;
+				ALTER TABLE secrets ADD COLUMN secret_version_created_at timestamp without time zone;
+				UPDATE secrets SET secret_version_created_at = secret_updated_at;
+				CREATE TABLE IF NOT EXISTS secret_versions
+				(
+					secret_version_id text NOT NULL PRIMARY KEY,
+					secret_version_secret_id text NOT NULL REFERENCES secrets (secret_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					secret_version_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					secret_version_number integer NOT NULL,
+					secret_version_created_at timestamp without time zone NOT NULL,
+					secret_version_superseded_at timestamp without time zone NOT NULL,
+					secret_version_key_encrypted {{ .Binary}} NOT NULL,
+					secret_version_value_encrypted {{ .Binary}} NOT NULL,
+					secret_version_data_key_encrypted {{ .Binary}} NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS secret_versions_secret_id_number_unique_index ON secret_versions(
+					secret_version_secret_id,
+					secret_version_number);
+				CREATE UNIQUE INDEX IF NOT EXISTS secret_versions_created_at_id_desc_unique_index ON secret_versions(
+					secret_version_created_at DESC,
+					secret_version_id DESC);
+				ALTER TABLE jobs ADD COLUMN job_pin_secrets bool NOT NULL DEFAULT FALSE;`,
+		DownSQL: `ALTER TABLE jobs DROP COLUMN job_pin_secrets;
+				  DROP TABLE secret_versions;
+				  ALTER TABLE secrets DROP COLUMN secret_version_created_at;
+				  ALTER TABLE secrets DROP COLUMN secret_version;`,
+	},
+	{
+		SequenceNumber: 106,
+		Name:           "add_toolchain_sources",
+		UpSQL: `ALTER TABLE toolchains ADD COLUMN toolchain_source_repo_id text REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE SET NULL;
+				ALTER TABLE toolchains ADD COLUMN toolchain_dockerfile text NOT NULL DEFAULT '';
+				ALTER TABLE toolchains ADD COLUMN toolchain_rebuild_workflow text NOT NULL DEFAULT '';
+				CREATE INDEX IF NOT EXISTS toolchains_source_repo_id_index ON toolchains(toolchain_source_repo_id);`,
+		DownSQL: `DROP INDEX toolchains_source_repo_id_index;
+				  ALTER TABLE toolchains DROP COLUMN toolchain_rebuild_workflow;
+				  ALTER TABLE toolchains DROP COLUMN toolchain_dockerfile;
+				  ALTER TABLE toolchains DROP COLUMN toolchain_source_repo_id;`,
+	},
+	{
+		SequenceNumber: 107,
+		Name:           "add_personal_access_tokens",
+		UpSQL: `ALTER TABLE credentials ADD COLUMN credential_name text NOT NULL DEFAULT '';
+				ALTER TABLE credentials ADD COLUMN credential_scopes text;
+				ALTER TABLE credentials ADD COLUMN credential_expires_at timestamp without time zone;
+				ALTER TABLE credentials ADD COLUMN credential_last_used_at timestamp without time zone;
+				CREATE INDEX IF NOT EXISTS credentials_identity_id_type_index ON credentials(credential_identity_id, credential_type);`,
+		DownSQL: `DROP INDEX credentials_identity_id_type_index;
+				  ALTER TABLE credentials DROP COLUMN credential_last_used_at;
+				  ALTER TABLE credentials DROP COLUMN credential_expires_at;
+				  ALTER TABLE credentials DROP COLUMN credential_scopes;
+				  ALTER TABLE credentials DROP COLUMN credential_name;`,
+	},
+	{
+		SequenceNumber: 108,
+		Name:           "create_saml_identity_providers",
+		UpSQL: `CREATE TABLE IF NOT EXISTS saml_identity_providers
+				(
+					saml_identity_provider_id text NOT NULL PRIMARY KEY,
+					saml_identity_provider_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					saml_identity_provider_created_at timestamp without time zone NOT NULL,
+					saml_identity_provider_updated_at timestamp without time zone NOT NULL,
+					saml_identity_provider_etag text NOT NULL,
+					saml_identity_provider_enabled BOOL NOT NULL,
+					saml_identity_provider_metadata_xml text NOT NULL,
+					saml_identity_provider_email_attribute text NOT NULL,
+					saml_identity_provider_name_attribute text NOT NULL,
+					saml_identity_provider_username_attribute text NOT NULL,
+					saml_identity_provider_groups_attribute text NOT NULL,
+					saml_identity_provider_group_mappings text NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS saml_identity_providers_legal_entity_id_unique_index ON saml_identity_providers(
+					saml_identity_provider_legal_entity_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS saml_identity_providers_created_at_id_desc_unique_index ON saml_identity_providers(
+					saml_identity_provider_created_at DESC,
+					saml_identity_provider_id DESC);`,
+		DownSQL: `DROP TABLE saml_identity_providers;`,
+	},
+	{
+		SequenceNumber: 109,
+		Name:           "create_access_control_roles",
+		UpSQL: `CREATE TABLE IF NOT EXISTS access_control_roles
+				(
+					access_control_role_id text NOT NULL PRIMARY KEY,
+					access_control_role_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					access_control_role_created_at timestamp without time zone NOT NULL,
+					access_control_role_updated_at timestamp without time zone NOT NULL,
+					access_control_role_etag text NOT NULL,
+					access_control_role_name text NOT NULL,
+					access_control_role_description text NOT NULL,
+					access_control_role_operations text NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS access_control_roles_legal_entity_id_name_unique_index ON access_control_roles(
+					access_control_role_legal_entity_id,
+					access_control_role_name);
+				CREATE UNIQUE INDEX IF NOT EXISTS access_control_roles_created_at_id_desc_unique_index ON access_control_roles(
+					access_control_role_created_at DESC,
+					access_control_role_id DESC);
+				CREATE TABLE IF NOT EXISTS access_control_role_assignments
+				(
+					access_control_role_assignment_id text NOT NULL PRIMARY KEY,
+					access_control_role_assignment_role_id text NOT NULL REFERENCES access_control_roles (access_control_role_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					access_control_role_assignment_created_at timestamp without time zone NOT NULL,
+					access_control_role_assignment_authorized_identity_id text REFERENCES identities (identity_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					access_control_role_assignment_authorized_group_id text REFERENCES access_control_groups (access_control_group_id) ON UPDATE NO ACTION ON DELETE CASCADE
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS access_control_role_assignments_unique_index ON access_control_role_assignments(
+					access_control_role_assignment_role_id,
+					coalesce(access_control_role_assignment_authorized_identity_id, ''),
+					coalesce(access_control_role_assignment_authorized_group_id, ''));
+				CREATE INDEX IF NOT EXISTS access_control_role_assignments_authorized_identity_id_index ON access_control_role_assignments(
+					access_control_role_assignment_authorized_identity_id);
+				CREATE INDEX IF NOT EXISTS access_control_role_assignments_authorized_group_id_index ON access_control_role_assignments(
+					access_control_role_assignment_authorized_group_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS access_control_role_assignments_created_at_id_desc_unique_index ON access_control_role_assignments(
+					access_control_role_assignment_created_at DESC,
+					access_control_role_assignment_id DESC);`,
+		DownSQL: `DROP TABLE access_control_role_assignments;
+				  DROP TABLE access_control_roles;`,
+	},
+	{
+		SequenceNumber: 110,
+		Name:           "add_access_control_grant_deny",
+		UpSQL:          `ALTER TABLE access_control_grants ADD COLUMN access_control_grant_deny bool NOT NULL default FALSE;`,
+		DownSQL:        `ALTER TABLE access_control_grants DROP COLUMN access_control_grant_deny;`,
+	},
+	{
+		SequenceNumber: 111,
+		Name:           "create_usage_records_and_quotas",
+		UpSQL: `CREATE TABLE IF NOT EXISTS usage_records
+				(
+					usage_record_id text NOT NULL PRIMARY KEY,
+					usage_record_created_at timestamp without time zone NOT NULL,
+					usage_record_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					usage_record_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					usage_record_source_resource_id text NOT NULL,
+					usage_record_metric text NOT NULL,
+					usage_record_period text NOT NULL,
+					usage_record_quantity bigint NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS usage_records_source_resource_id_metric_unique_index ON usage_records(
+					usage_record_source_resource_id,
+					usage_record_metric);
+				CREATE INDEX IF NOT EXISTS usage_records_legal_entity_id_period_index ON usage_records(
+					usage_record_legal_entity_id,
+					usage_record_period);
+				CREATE UNIQUE INDEX IF NOT EXISTS usage_records_created_at_id_desc_unique_index ON usage_records(
+					usage_record_created_at DESC,
+					usage_record_id DESC);
+				CREATE TABLE IF NOT EXISTS usage_quotas
+				(
+					usage_quota_id text NOT NULL PRIMARY KEY,
+					usage_quota_legal_entity_id text NOT NULL REFERENCES legal_entities (legal_entity_id) ON UPDATE NO ACTION ON DELETE NO ACTION,
+					usage_quota_created_at timestamp without time zone NOT NULL,
+					usage_quota_updated_at timestamp without time zone NOT NULL,
+					usage_quota_etag text NOT NULL,
+					usage_quota_metric text NOT NULL,
+					usage_quota_monthly_limit bigint NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS usage_quotas_legal_entity_id_metric_unique_index ON usage_quotas(
+					usage_quota_legal_entity_id,
+					usage_quota_metric);
+				CREATE UNIQUE INDEX IF NOT EXISTS usage_quotas_created_at_id_desc_unique_index ON usage_quotas(
+					usage_quota_created_at DESC,
+					usage_quota_id DESC);`,
+		DownSQL: `DROP TABLE usage_quotas;
+				  DROP TABLE usage_records;`,
+	},
+	{
+		SequenceNumber: 112,
+		Name:           "add_legal_entity_scheduling_weight",
+		UpSQL:          `ALTER TABLE legal_entities ADD COLUMN legal_entity_scheduling_weight integer NOT NULL DEFAULT 1;`,
+		DownSQL:        `ALTER TABLE legal_entities DROP COLUMN legal_entity_scheduling_weight;`,
+	},
+	{
+		SequenceNumber: 113,
+		Name:           "create_leader_leases",
+		UpSQL: `CREATE TABLE IF NOT EXISTS leader_leases
+				(
+					leader_lease_name text NOT NULL PRIMARY KEY,
+					leader_lease_holder text NOT NULL,
+					leader_lease_acquired_at timestamp without time zone NOT NULL,
+					leader_lease_expires_at timestamp without time zone NOT NULL
+				);`,
+		DownSQL: `DROP TABLE leader_leases;`,
+	},
+	{
+		SequenceNumber: 114,
+		Name:           "create_webhook_deliveries",
+		UpSQL: `CREATE TABLE IF NOT EXISTS webhook_deliveries
+				(
+					webhook_delivery_id text NOT NULL PRIMARY KEY,
+					webhook_delivery_created_at timestamp without time zone NOT NULL,
+					webhook_delivery_updated_at timestamp without time zone NOT NULL,
+					webhook_delivery_etag text NOT NULL,
+					webhook_delivery_scm_name text NOT NULL,
+					webhook_delivery_event_type text NOT NULL,
+					webhook_delivery_headers text NOT NULL,
+					webhook_delivery_payload text NOT NULL,
+					webhook_delivery_signature_status text NOT NULL,
+					webhook_delivery_status text NOT NULL,
+					webhook_delivery_error text NOT NULL,
+					webhook_delivery_attempts integer NOT NULL,
+					webhook_delivery_processed_at timestamp without time zone
+				);
+				CREATE INDEX IF NOT EXISTS webhook_deliveries_status_index ON webhook_deliveries(
+					webhook_delivery_status);
+				CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_created_at_id_desc_unique_index ON webhook_deliveries(
+					webhook_delivery_created_at DESC,
+					webhook_delivery_id DESC);`,
+		DownSQL: `DROP TABLE webhook_deliveries;`,
+	},
+	{
+		SequenceNumber: 115,
+		Name:           "add_legal_entity_disabled_at",
+		UpSQL:          `ALTER TABLE legal_entities ADD COLUMN legal_entity_disabled_at timestamp without time zone;`,
+		DownSQL:        `ALTER TABLE legal_entities DROP COLUMN legal_entity_disabled_at;`,
+	},
+	{
+		SequenceNumber: 116,
+		Name:           "add_build_rule_set_comment_commands",
+		UpSQL:          `ALTER TABLE build_rule_sets ADD COLUMN build_rule_set_comment_commands text;`,
+		DownSQL:        `ALTER TABLE build_rule_sets DROP COLUMN build_rule_set_comment_commands;`,
+	},
+	{
+		SequenceNumber: 117,
+		Name:           "create_flaky_tests",
+		UpSQL: `CREATE TABLE IF NOT EXISTS flaky_tests
+				(
+					flaky_test_id text NOT NULL PRIMARY KEY,
+					flaky_test_created_at timestamp without time zone NOT NULL,
+					flaky_test_updated_at timestamp without time zone NOT NULL,
+					flaky_test_repo_id text NOT NULL REFERENCES repos (repo_id) ON UPDATE NO ACTION ON DELETE CASCADE,
+					flaky_test_suite text NOT NULL,
+					flaky_test_name text NOT NULL,
+					flaky_test_detections integer NOT NULL,
+					flaky_test_last_build_id text NOT NULL,
+					flaky_test_last_commit_id text,
+					flaky_test_message text NOT NULL
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS flaky_tests_repo_id_suite_name_unique_index ON flaky_tests(
+					flaky_test_repo_id,
+					flaky_test_suite,
+					flaky_test_name);
+				CREATE UNIQUE INDEX IF NOT EXISTS flaky_tests_created_at_id_desc_unique_index ON flaky_tests(
+					flaky_test_created_at DESC,
+					flaky_test_id DESC);
+				CREATE INDEX IF NOT EXISTS test_runs_job_id_index ON test_runs(
+					test_run_job_id);`,
+		DownSQL: `DROP INDEX test_runs_job_id_index;
+				  DROP TABLE flaky_tests;`,
+	},
+	{
+		SequenceNumber: 118,
+		Name:           "add_job_operating_system",
+		UpSQL:          `ALTER TABLE jobs ADD COLUMN job_operating_system text NOT NULL DEFAULT '';`,
+		DownSQL:        `ALTER TABLE jobs DROP COLUMN job_operating_system;`,
+	},
+	{
+		SequenceNumber: 119,
+		Name:           "add_runner_exec_job_allowlist",
+		UpSQL:          `ALTER TABLE runners ADD COLUMN runner_exec_job_allowlist text;`,
+		DownSQL:        `ALTER TABLE runners DROP COLUMN runner_exec_job_allowlist;`,
+	},
 }
@@ -0,0 +1,65 @@
+package notification_settings
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.NotificationSetting{})
+	store.MustDBModel(&models.NotificationSetting{})
+}
+
+type NotificationSettingStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *NotificationSettingStore {
+	return &NotificationSettingStore{
+		table: store.NewResourceTable(db, logFactory, &models.NotificationSetting{}),
+	}
+}
+
+// Create a new notification setting.
+// Returns store.ErrAlreadyExists if a notification setting with matching unique properties already exists.
+func (d *NotificationSettingStore) Create(ctx context.Context, txOrNil *store.Tx, setting *models.NotificationSetting) error {
+	return d.table.Create(ctx, txOrNil, setting)
+}
+
+// Read an existing notification setting, looking it up by ResourceID.
+// Returns models.ErrNotFound if the notification setting does not exist.
+func (d *NotificationSettingStore) Read(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID) (*models.NotificationSetting, error) {
+	setting := &models.NotificationSetting{}
+	return setting, d.table.ReadByID(ctx, txOrNil, id.ResourceID, setting)
+}
+
+// Update an existing notification setting with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *NotificationSettingStore) Update(ctx context.Context, txOrNil *store.Tx, setting *models.NotificationSetting) error {
+	return d.table.UpdateByID(ctx, txOrNil, setting)
+}
+
+// Delete permanently and idempotently deletes a notification setting, identifying it by id.
+func (d *NotificationSettingStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.NotificationSettingID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByRepoID lists all notification settings for a repo. Use cursor to page through results, if any.
+func (d *NotificationSettingStore) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.NotificationSetting, *models.Cursor, error) {
+	settingsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.NotificationSetting{}).
+		Where(goqu.Ex{"notification_setting_repo_id": repoID})
+
+	var settings []*models.NotificationSetting
+	cursor, err := d.table.ListIn(ctx, txOrNil, &settings, pagination, settingsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return settings, cursor, nil
+}
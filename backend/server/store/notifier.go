@@ -0,0 +1,305 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+const (
+	// WorkItemsNotificationChannel is notified when work items are added to the work queue, or become ready
+	// to be processed.
+	WorkItemsNotificationChannel NotificationChannel = "bb_work_items"
+
+	postgresListenerMinReconnectInterval = 1 * time.Second
+	postgresListenerMaxReconnectInterval = 1 * time.Minute
+	// postgresListenerPingInterval is how often the listener's connection is checked when no notifications
+	// are being received.
+	postgresListenerPingInterval = 90 * time.Second
+)
+
+// NotificationChannel names a channel that notifications can be sent on.
+type NotificationChannel string
+
+func (c NotificationChannel) String() string {
+	return string(c)
+}
+
+// QueuedJobsNotificationChannel returns the channel that is notified when jobs under repos owned by the
+// specified legal entity may have become ready to hand to a runner.
+func QueuedJobsNotificationChannel(legalEntityID models.LegalEntityID) NotificationChannel {
+	return NotificationChannel("bb_jobs_" + legalEntityID.String())
+}
+
+// BuildArtifactsNotificationChannel returns the channel that is notified when an artifact produced by a job
+// in the specified build is sealed.
+func BuildArtifactsNotificationChannel(buildID models.BuildID) NotificationChannel {
+	return NotificationChannel("bb_artifacts_" + buildID.String())
+}
+
+type NotifierBackend string
+
+func (b NotifierBackend) String() string {
+	return string(b)
+}
+
+const (
+	// NotifierBackendAuto uses Postgres LISTEN/NOTIFY when the database is Postgres, and otherwise delivers
+	// notifications within this process.
+	NotifierBackendAuto NotifierBackend = "auto"
+	// NotifierBackendPostgres uses Postgres LISTEN/NOTIFY, so notifications reach every server using the database.
+	NotifierBackendPostgres NotifierBackend = "postgres"
+	// NotifierBackendLocal delivers notifications within this process only. This is only suitable when a
+	// single server uses the database.
+	NotifierBackendLocal NotifierBackend = "local"
+	// NotifierBackendNone doesn't deliver notifications, so everything waiting for them falls back to polling
+	// the database.
+	NotifierBackendNone NotifierBackend = "none"
+)
+
+type NotifierConfig struct {
+	// Backend determines how notifications are delivered. Defaults to NotifierBackendAuto if empty.
+	Backend NotifierBackend
+}
+
+// Notifier lets one part of the server tell others that something has changed in the database, so they
+// don't have to poll the database to find out.
+type Notifier interface {
+	// Notify sends a notification on the specified channel. If a transaction is supplied then the notification
+	// should be sent as part of the transaction, so that listeners aren't told about changes before they are
+	// committed (although backends may deliver it early, or not at all, so listeners must still poll occasionally).
+	Notify(ctx context.Context, txOrNil *Tx, channel NotificationChannel) error
+	// Listen returns a Go channel that receives a value after one or more notifications have been sent on the
+	// specified notification channel, along with a function to call to stop listening. Several notifications
+	// sent close together may be delivered as a single value. Returns a nil Go channel if this notifier doesn't
+	// deliver notifications, in which case the caller must poll instead.
+	Listen(channel NotificationChannel) (<-chan struct{}, func())
+}
+
+// NewNotifier makes a Notifier using the backend specified in config, along with a function to call to
+// stop delivering notifications.
+func NewNotifier(config NotifierConfig, db *DB, logFactory logger.LogFactory) (Notifier, func(), error) {
+	backend := config.Backend
+	if backend == "" || backend == NotifierBackendAuto {
+		if db.Driver == Postgres {
+			backend = NotifierBackendPostgres
+		} else {
+			backend = NotifierBackendLocal
+		}
+	}
+	switch backend {
+	case NotifierBackendPostgres:
+		if db.Driver != Postgres {
+			return nil, nil, fmt.Errorf("error the %s notifier backend requires a Postgres database, not %s", backend, db.Driver)
+		}
+		notifier := NewPostgresNotifier(db, logFactory)
+		return notifier, notifier.Close, nil
+	case NotifierBackendLocal:
+		return NewLocalNotifier(), func() {}, nil
+	case NotifierBackendNone:
+		return &noOpNotifier{}, func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("error unknown notifier backend %q", backend)
+	}
+}
+
+// listeners keeps track of the Go channels listening for notifications on each notification channel.
+type listeners struct {
+	mu       sync.Mutex
+	channels map[NotificationChannel]map[chan struct{}]bool
+}
+
+func newListeners() *listeners {
+	return &listeners{channels: make(map[NotificationChannel]map[chan struct{}]bool)}
+}
+
+// add registers a new listener on channel, and returns true if it is the first listener on the channel.
+func (l *listeners) add(channel NotificationChannel) (chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := make(chan struct{}, 1)
+	first := len(l.channels[channel]) == 0
+	if first {
+		l.channels[channel] = make(map[chan struct{}]bool)
+	}
+	l.channels[channel][c] = true
+	return c, first
+}
+
+// remove unregisters a listener on channel, and returns true if it was the last listener on the channel.
+func (l *listeners) remove(channel NotificationChannel, c chan struct{}) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.channels[channel][c] {
+		return false
+	}
+	delete(l.channels[channel], c)
+	if len(l.channels[channel]) == 0 {
+		delete(l.channels, channel)
+		return true
+	}
+	return false
+}
+
+// notify wakes every listener on channel, without blocking on listeners that haven't received an earlier notification.
+func (l *listeners) notify(channel NotificationChannel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for c := range l.channels[channel] {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// notifyAll wakes every listener on every channel.
+func (l *listeners) notifyAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, cs := range l.channels {
+		for c := range cs {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// LocalNotifier delivers notifications to listeners within the same process. Notifications are delivered
+// straight away rather than when the supplied transaction commits; with SQLite, listeners can't read the
+// database until the transaction finishes anyway.
+type LocalNotifier struct {
+	listeners *listeners
+}
+
+func NewLocalNotifier() *LocalNotifier {
+	return &LocalNotifier{listeners: newListeners()}
+}
+
+func (n *LocalNotifier) Notify(ctx context.Context, txOrNil *Tx, channel NotificationChannel) error {
+	n.listeners.notify(channel)
+	return nil
+}
+
+func (n *LocalNotifier) Listen(channel NotificationChannel) (<-chan struct{}, func()) {
+	c, _ := n.listeners.add(channel)
+	return c, func() { n.listeners.remove(channel, c) }
+}
+
+// PostgresNotifier delivers notifications to listeners in every process using the database, via Postgres
+// LISTEN/NOTIFY. Notifications sent as part of a transaction are delivered when the transaction commits.
+// A single database connection is used to listen on all channels that have listeners.
+type PostgresNotifier struct {
+	db        *DB
+	listeners *listeners
+	listener  *pq.Listener
+	doneChan  chan bool
+	logger.Log
+}
+
+func NewPostgresNotifier(db *DB, logFactory logger.LogFactory) *PostgresNotifier {
+	n := &PostgresNotifier{
+		db:        db,
+		listeners: newListeners(),
+		doneChan:  make(chan bool),
+		Log:       logFactory("PostgresNotifier"),
+	}
+	n.listener = pq.NewListener(db.ConnectionString.String(),
+		postgresListenerMinReconnectInterval,
+		postgresListenerMaxReconnectInterval,
+		n.onListenerEvent)
+	go n.loop()
+	return n
+}
+
+func (n *PostgresNotifier) Notify(ctx context.Context, txOrNil *Tx, channel NotificationChannel) error {
+	return n.db.Write(txOrNil, func(db Execer, _ Binder) error {
+		_, err := db.ExecContext(ctx, "SELECT pg_notify($1, '')", channel.String())
+		if err != nil {
+			return fmt.Errorf("error sending notification on channel %s: %w", channel, MakeStandardDBError(err))
+		}
+		return nil
+	})
+}
+
+func (n *PostgresNotifier) Listen(channel NotificationChannel) (<-chan struct{}, func()) {
+	c, first := n.listeners.add(channel)
+	if first {
+		err := n.listener.Listen(channel.String())
+		if err != nil && err != pq.ErrChannelAlreadyOpen {
+			// The listener re-listens on all channels when it reconnects, and listeners wake up
+			// then in case they missed notifications; until then they must rely on polling
+			n.Warnf("Ignoring error listening on notification channel %s: %v", channel, err)
+		}
+	}
+	return c, func() {
+		if n.listeners.remove(channel, c) {
+			err := n.listener.Unlisten(channel.String())
+			if err != nil && err != pq.ErrChannelNotOpen {
+				n.Warnf("Ignoring error unlistening on notification channel %s: %v", channel, err)
+			}
+		}
+	}
+}
+
+// Close stops listening for notifications and closes the listener's database connection.
+func (n *PostgresNotifier) Close() {
+	close(n.doneChan)
+	err := n.listener.Close()
+	if err != nil {
+		n.Warnf("Ignoring error closing notification listener: %v", err)
+	}
+}
+
+func (n *PostgresNotifier) loop() {
+	for {
+		select {
+		case <-n.doneChan:
+			return
+		case notification := <-n.listener.NotificationChannel():
+			if notification == nil {
+				// The connection was lost and re-established, so notifications may have been missed
+				n.listeners.notifyAll()
+				continue
+			}
+			n.listeners.notify(NotificationChannel(notification.Channel))
+		case <-time.After(postgresListenerPingInterval):
+			go func() {
+				err := n.listener.Ping()
+				if err != nil {
+					n.Warnf("Error checking notification listener connection: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+func (n *PostgresNotifier) onListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		n.Warnf("Notification listener disconnected from database, will reconnect: %v", err)
+	case pq.ListenerEventConnectionAttemptFailed:
+		n.Warnf("Notification listener failed to connect to database, will retry: %v", err)
+	case pq.ListenerEventReconnected:
+		n.Infof("Notification listener reconnected to database")
+	}
+}
+
+// noOpNotifier doesn't deliver notifications, leaving listeners to poll the database.
+type noOpNotifier struct{}
+
+func (n *noOpNotifier) Notify(ctx context.Context, txOrNil *Tx, channel NotificationChannel) error {
+	return nil
+}
+
+func (n *noOpNotifier) Listen(channel NotificationChannel) (<-chan struct{}, func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,66 @@
+package outgoing_webhook_deliveries
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.OutgoingWebhookDelivery{})
+	store.MustDBModel(&models.OutgoingWebhookDelivery{})
+}
+
+type OutgoingWebhookDeliveryStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *OutgoingWebhookDeliveryStore {
+	return &OutgoingWebhookDeliveryStore{
+		table: store.NewResourceTableWithTableName(db, logFactory, "outgoing_webhook_deliveries", &models.OutgoingWebhookDelivery{}),
+	}
+}
+
+// Create a new outgoing webhook delivery.
+// Returns store.ErrAlreadyExists if a delivery with matching unique properties already exists.
+func (d *OutgoingWebhookDeliveryStore) Create(ctx context.Context, txOrNil *store.Tx, delivery *models.OutgoingWebhookDelivery) error {
+	return d.table.Create(ctx, txOrNil, delivery)
+}
+
+// Read an existing outgoing webhook delivery, looking it up by ResourceID.
+// Returns models.ErrNotFound if the delivery does not exist.
+func (d *OutgoingWebhookDeliveryStore) Read(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookDeliveryID) (*models.OutgoingWebhookDelivery, error) {
+	delivery := &models.OutgoingWebhookDelivery{}
+	return delivery, d.table.ReadByID(ctx, txOrNil, id.ResourceID, delivery)
+}
+
+// Update an existing outgoing webhook delivery with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *OutgoingWebhookDeliveryStore) Update(ctx context.Context, txOrNil *store.Tx, delivery *models.OutgoingWebhookDelivery) error {
+	return d.table.UpdateByID(ctx, txOrNil, delivery)
+}
+
+// ListByWebhookID lists the deliveries for an outgoing webhook, most recent first.
+// Use cursor to page through results, if any.
+func (d *OutgoingWebhookDeliveryStore) ListByWebhookID(ctx context.Context, txOrNil *store.Tx, webhookID models.OutgoingWebhookID, pagination models.Pagination) ([]*models.OutgoingWebhookDelivery, *models.Cursor, error) {
+	deliveriesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.OutgoingWebhookDelivery{}).
+		Where(goqu.Ex{"outgoing_webhook_delivery_webhook_id": webhookID})
+
+	var deliveries []*models.OutgoingWebhookDelivery
+	cursor, err := d.table.ListIn(ctx, txOrNil, &deliveries, pagination, deliveriesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return deliveries, cursor, nil
+}
+
+// DeleteByWebhookID permanently and idempotently deletes all deliveries for an outgoing webhook.
+func (d *OutgoingWebhookDeliveryStore) DeleteByWebhookID(ctx context.Context, txOrNil *store.Tx, webhookID models.OutgoingWebhookID) error {
+	return d.table.DeleteWhere(ctx, txOrNil, goqu.Ex{"outgoing_webhook_delivery_webhook_id": webhookID})
+}
@@ -0,0 +1,96 @@
+package outgoing_webhooks
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.OutgoingWebhook{})
+	store.MustDBModel(&models.OutgoingWebhook{})
+}
+
+type OutgoingWebhookStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *OutgoingWebhookStore {
+	return &OutgoingWebhookStore{
+		table: store.NewResourceTable(db, logFactory, &models.OutgoingWebhook{}),
+	}
+}
+
+// Create a new outgoing webhook.
+// Returns store.ErrAlreadyExists if an outgoing webhook with matching unique properties already exists.
+func (d *OutgoingWebhookStore) Create(ctx context.Context, txOrNil *store.Tx, webhook *models.OutgoingWebhook) error {
+	return d.table.Create(ctx, txOrNil, webhook)
+}
+
+// Read an existing outgoing webhook, looking it up by ResourceID.
+// Returns models.ErrNotFound if the outgoing webhook does not exist.
+func (d *OutgoingWebhookStore) Read(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID) (*models.OutgoingWebhook, error) {
+	webhook := &models.OutgoingWebhook{}
+	return webhook, d.table.ReadByID(ctx, txOrNil, id.ResourceID, webhook)
+}
+
+// Update an existing outgoing webhook with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *OutgoingWebhookStore) Update(ctx context.Context, txOrNil *store.Tx, webhook *models.OutgoingWebhook) error {
+	return d.table.UpdateByID(ctx, txOrNil, webhook)
+}
+
+// Delete permanently and idempotently deletes an outgoing webhook, identifying it by id.
+func (d *OutgoingWebhookStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.OutgoingWebhookID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByLegalEntityID lists the outgoing webhooks registered against a legal entity, excluding webhooks
+// registered against the legal entity's individual repos. Use cursor to page through results, if any.
+func (d *OutgoingWebhookStore) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error) {
+	webhooksSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.OutgoingWebhook{}).
+		Where(goqu.Ex{"outgoing_webhook_legal_entity_id": legalEntityID}).
+		Where(goqu.C("outgoing_webhook_repo_id").IsNull())
+	return d.list(ctx, txOrNil, pagination, webhooksSelect)
+}
+
+// ListByRepoID lists the outgoing webhooks registered against a repo. Use cursor to page through results, if any.
+func (d *OutgoingWebhookStore) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error) {
+	webhooksSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.OutgoingWebhook{}).
+		Where(goqu.Ex{"outgoing_webhook_repo_id": repoID})
+	return d.list(ctx, txOrNil, pagination, webhooksSelect)
+}
+
+// ListForRepo lists all outgoing webhooks that receive events for a repo; this includes webhooks registered
+// against the repo and webhooks registered against the legal entity that owns the repo.
+// Use cursor to page through results, if any.
+func (d *OutgoingWebhookStore) ListForRepo(ctx context.Context, txOrNil *store.Tx, repo *models.Repo, pagination models.Pagination) ([]*models.OutgoingWebhook, *models.Cursor, error) {
+	webhooksSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.OutgoingWebhook{}).
+		Where(goqu.Or(
+			goqu.Ex{"outgoing_webhook_repo_id": repo.ID},
+			goqu.And(
+				goqu.Ex{"outgoing_webhook_legal_entity_id": repo.LegalEntityID},
+				goqu.C("outgoing_webhook_repo_id").IsNull(),
+			),
+		))
+	return d.list(ctx, txOrNil, pagination, webhooksSelect)
+}
+
+func (d *OutgoingWebhookStore) list(ctx context.Context, txOrNil *store.Tx, pagination models.Pagination, webhooksSelect *goqu.SelectDataset) ([]*models.OutgoingWebhook, *models.Cursor, error) {
+	var webhooks []*models.OutgoingWebhook
+	cursor, err := d.table.ListIn(ctx, txOrNil, &webhooks, pagination, webhooksSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return webhooks, cursor, nil
+}
@@ -50,6 +50,22 @@ func (d *PullRequestStore) ReadByExternalID(ctx context.Context, txOrNil *store.
 	return pullRequest, d.table.ReadWhere(ctx, txOrNil, pullRequest, goqu.Ex{"pull_request_external_id": externalID})
 }
 
+// ReadOpenByHeadRef reads the most recently updated open pull request in a repo whose head is the specified ref.
+// Returns models.ErrNotFound if there is no such pull request.
+func (d *PullRequestStore) ReadOpenByHeadRef(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, headRef string) (*models.PullRequest, error) {
+	pullRequest := &models.PullRequest{}
+	ds := goqu.
+		Select(pullRequest).
+		From(d.table.TableName()).
+		Where(goqu.Ex{
+			"pull_request_repo_id":   repoID,
+			"pull_request_head_ref":  headRef,
+			"pull_request_closed_at": nil,
+		}).
+		Order(goqu.I("pull_request_updated_at").Desc())
+	return pullRequest, d.table.ReadIn(ctx, txOrNil, pullRequest, ds)
+}
+
 // Update an existing pull request with optimistic locking. Overrides all previous values using the supplied model.
 // Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
 func (d *PullRequestStore) Update(ctx context.Context, txOrNil *store.Tx, pullRequest *models.PullRequest) error {
@@ -45,6 +45,13 @@ func (d *RepoStore) Read(ctx context.Context, txOrNil *store.Tx, id models.RepoI
 	return repo, d.table.ReadByID(ctx, txOrNil, id.ResourceID, repo)
 }
 
+// ListByIDs reads the repos with the specified IDs, in no particular order. IDs of repos that do not
+// exist are ignored.
+func (d *RepoStore) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.RepoID) ([]*models.Repo, error) {
+	var repos []*models.Repo
+	return repos, d.table.ReadByIDs(ctx, txOrNil, ids, &repos)
+}
+
 // ReadByExternalID reads an existing repo, looking it up by its external id.
 // Returns models.ErrNotFound if the repo does not exist.
 func (d *RepoStore) ReadByExternalID(ctx context.Context, txOrNil *store.Tx, externalID models.ExternalResourceID) (*models.Repo, error) {
@@ -62,7 +69,7 @@ func (d *RepoStore) Update(ctx context.Context, txOrNil *store.Tx, repo *models.
 // Upsert creates a repo if it does not exist, otherwise it updates its mutable properties
 // if they differ from the in-memory instance. Returns true,false if the resource was created
 // and false,true if the resource was updated. false,false if neither a create or update was necessary.
-// Repo Metadata and selected fields will not be updated (including Enabled and SSHKeySecretID fields).
+// Repo Metadata and selected fields will not be updated (including Enabled, SSHKeySecretID, DynamicJobRestrictions, CoverageSettings, PublicBuilds, BuildPrioritySettings and DeduplicateJobs fields).
 func (d *RepoStore) Upsert(ctx context.Context, txOrNil *store.Tx, repo *models.Repo) (bool, bool, error) {
 	if repo.ExternalID == nil {
 		return false, false, fmt.Errorf("error external id must be set to upsert")
@@ -77,6 +84,11 @@ func (d *RepoStore) Upsert(ctx context.Context, txOrNil *store.Tx, repo *models.
 			repo.RepoMetadata = existing.RepoMetadata
 			repo.Enabled = existing.Enabled
 			repo.SSHKeySecretID = existing.SSHKeySecretID
+			repo.DynamicJobRestrictions = existing.DynamicJobRestrictions
+			repo.CoverageSettings = existing.CoverageSettings
+			repo.PublicBuilds = existing.PublicBuilds
+			repo.BuildPrioritySettings = existing.BuildPrioritySettings
+			repo.DeduplicateJobs = existing.DeduplicateJobs
 			if reflect.DeepEqual(existing, repo) {
 				return false, nil
 			}
@@ -111,6 +111,62 @@ func (d *ResourceTable) ReadByID(ctx context.Context, txOrNil *Tx, id models.Res
 	return nil // success
 }
 
+// ReadByIDs reads the resources with the specified IDs into resources, which must be a pointer to a slice of
+// resources. ids must be a slice of IDs of the resource's type, e.g. []models.BuildID.
+// IDs of resources that do not exist are ignored rather than returning an error, and resources are read in
+// no particular order. Soft-deleted resources are returned or ignored following the same rules as ReadByID.
+func (d *ResourceTable) ReadByIDs(ctx context.Context, txOrNil *Tx, ids interface{}, resources interface{}) error {
+	slicePtr := reflect.TypeOf(resources)
+	if slicePtr.Kind() != reflect.Ptr || slicePtr.Elem().Kind() != reflect.Slice {
+		d.Panicf("expected pointer to slice, found: %T", resources)
+	}
+	sliceT := slicePtr.Elem()
+	sliceV := reflect.ValueOf(resources).Elem()
+	if !sliceT.Elem().Implements(resourceInterface) || sliceT.Elem().Kind() != reflect.Ptr {
+		d.Panicf("expected slice of pointer to resource, found: %s", sliceT.Elem())
+	}
+	idsV := reflect.ValueOf(ids)
+	if idsV.Kind() != reflect.Slice {
+		d.Panicf("expected slice of IDs, found: %T", ids)
+	}
+	if idsV.Len() == 0 {
+		return nil
+	}
+
+	// Read the resources, regardless of whether they are soft-deleted
+	prototype := reflect.New(sliceT.Elem().Elem()).Interface()
+	ds := d.Dialect().From(d.tableName).Select(prototype).Where(goqu.Ex{d.idColName: ids})
+	err := d.db.Read2(txOrNil, func(db Reader) error {
+		query, args, err := ds.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.LogQuery(query, args)
+		err = db.ScanStructsContext(ctx, resources, query, args...)
+		if err != nil {
+			return MakeStandardDBError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Leave out any soft-deleted resources that are unreachable
+	if sliceT.Elem().Implements(softDeletableResourceInterface) {
+		reachable := reflect.MakeSlice(sliceT, 0, sliceV.Len())
+		for i := 0; i < sliceV.Len(); i++ {
+			softDeletableResource := sliceV.Index(i).Interface().(models.SoftDeletableResource)
+			if softDeletableResource.GetDeletedAt() == nil || !softDeletableResource.IsUnreachable() {
+				reachable = reflect.Append(reachable, sliceV.Index(i))
+			}
+		}
+		sliceV.Set(reachable)
+	}
+
+	return nil
+}
+
 // ReadWhere reads an existing resource, looking it up using the supplied where clauses.
 // If the resource is a models.SoftDeletableResource and it is deleted it will be treated as not existing, regardless
 // of what the resource's IsUnreachable() method returns.
@@ -603,6 +659,36 @@ func (d *ResourceTable) ListIn(ctx context.Context, txOrNil *Tx, resources inter
 	return cursor, nil
 }
 
+// listAllPageSize is the number of resources read per query by ListAllIn.
+const listAllPageSize = 500
+
+// ListAllIn lists all resources in the specified select dataset, reading them a page at a time.
+// Resources are listed in order of the newest creation date first (with ID being the tie-breaker; any ordering
+// specified in the supplied Dataset is ignored.
+// Resources must be a pointer to a slice of the resource type e.g. &[]*models.Artifact
+func (d *ResourceTable) ListAllIn(ctx context.Context, txOrNil *Tx, resources interface{}, ds *goqu.SelectDataset) error {
+	sliceV := reflect.ValueOf(resources)
+	if sliceV.Kind() != reflect.Ptr || sliceV.Elem().Kind() != reflect.Slice {
+		d.Panicf("expected pointer to slice, found: %T", resources)
+	}
+	all := reflect.MakeSlice(sliceV.Elem().Type(), 0, 0)
+	pagination := models.NewPagination(listAllPageSize, nil)
+	for {
+		page := reflect.New(sliceV.Elem().Type())
+		cursor, err := d.ListIn(ctx, txOrNil, page.Interface(), pagination, ds)
+		if err != nil {
+			return err
+		}
+		all = reflect.AppendSlice(all, page.Elem())
+		if cursor == nil || cursor.Next == nil {
+			break
+		}
+		pagination.Cursor = cursor.Next
+	}
+	sliceV.Elem().Set(all)
+	return nil
+}
+
 func MakeStandardDBError(err error) error {
 	// TODO support other databases
 	var sqliteErr sqlite3.Error
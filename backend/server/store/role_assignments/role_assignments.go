@@ -0,0 +1,72 @@
+package role_assignments
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.RoleAssignment{})
+}
+
+type RoleAssignmentStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *RoleAssignmentStore {
+	return &RoleAssignmentStore{
+		table: store.NewResourceTableWithTableName(db, logFactory, "access_control_role_assignments", &models.RoleAssignment{}),
+	}
+}
+
+// Create a new role assignment.
+// Returns store.ErrAlreadyExists if the role is already assigned to the group or identity.
+func (d *RoleAssignmentStore) Create(ctx context.Context, txOrNil *store.Tx, assignment *models.RoleAssignment) error {
+	d.table.Infof("Assigning role %s to %s", assignment.RoleID, assignment.GetAuthorizedResourceID())
+	return d.table.Create(ctx, txOrNil, assignment)
+}
+
+// Read an existing role assignment, looking it up by ResourceID.
+// Returns models.ErrNotFound if the role assignment does not exist.
+func (d *RoleAssignmentStore) Read(ctx context.Context, txOrNil *store.Tx, id models.RoleAssignmentID) (*models.RoleAssignment, error) {
+	assignment := &models.RoleAssignment{}
+	return assignment, d.table.ReadByID(ctx, txOrNil, id.ResourceID, assignment)
+}
+
+// ReadByAssignee reads the assignment of a role to the specified group or identity.
+// Returns models.ErrNotFound if the role is not assigned to the group or identity.
+func (d *RoleAssignmentStore) ReadByAssignee(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, assigneeID models.ResourceID) (*models.RoleAssignment, error) {
+	assignment := &models.RoleAssignment{}
+	return assignment, d.table.ReadWhere(ctx, txOrNil, assignment,
+		goqu.Ex{"access_control_role_assignment_role_id": roleID},
+		goqu.Or(
+			goqu.Ex{"access_control_role_assignment_authorized_identity_id": assigneeID},
+			goqu.Ex{"access_control_role_assignment_authorized_group_id": assigneeID},
+		))
+}
+
+// Delete permanently and idempotently deletes a role assignment, identifying it by id.
+func (d *RoleAssignmentStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.RoleAssignmentID) error {
+	d.table.Infof("Removing role assignment with ID %s", id)
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByRoleID lists the assignments of a role. Use cursor to page through results, if any.
+func (d *RoleAssignmentStore) ListByRoleID(ctx context.Context, txOrNil *store.Tx, roleID models.RoleID, pagination models.Pagination) ([]*models.RoleAssignment, *models.Cursor, error) {
+	assignmentsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.RoleAssignment{}).
+		Where(goqu.Ex{"access_control_role_assignment_role_id": roleID})
+
+	var assignments []*models.RoleAssignment
+	cursor, err := d.table.ListIn(ctx, txOrNil, &assignments, pagination, assignmentsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return assignments, cursor, nil
+}
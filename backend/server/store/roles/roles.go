@@ -0,0 +1,95 @@
+package roles
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.Role{})
+	store.MustDBModel(&models.Role{})
+}
+
+type RoleStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *RoleStore {
+	return &RoleStore{
+		table: store.NewResourceTableWithTableName(db, logFactory, "access_control_roles", &models.Role{}),
+	}
+}
+
+// Create a new role.
+// Returns store.ErrAlreadyExists if a role with matching unique properties already exists.
+func (d *RoleStore) Create(ctx context.Context, txOrNil *store.Tx, role *models.Role) error {
+	return d.table.Create(ctx, txOrNil, role)
+}
+
+// Read an existing role, looking it up by ResourceID.
+// Returns models.ErrNotFound if the role does not exist.
+func (d *RoleStore) Read(ctx context.Context, txOrNil *store.Tx, id models.RoleID) (*models.Role, error) {
+	role := &models.Role{}
+	return role, d.table.ReadByID(ctx, txOrNil, id.ResourceID, role)
+}
+
+// ReadByName reads an existing role, looking it up by role name and the ID of the legal entity
+// that owns the role. Returns models.ErrNotFound if the role does not exist.
+func (d *RoleStore) ReadByName(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, name models.ResourceName) (*models.Role, error) {
+	role := &models.Role{}
+	return role, d.table.ReadWhere(ctx, txOrNil, role, goqu.Ex{
+		"access_control_role_legal_entity_id": legalEntityID,
+		"access_control_role_name":            name,
+	})
+}
+
+// Update an existing role with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *RoleStore) Update(ctx context.Context, txOrNil *store.Tx, role *models.Role) error {
+	return d.table.UpdateByID(ctx, txOrNil, role)
+}
+
+// Delete permanently and idempotently deletes a role, identifying it by id.
+// Any assignments of the role are deleted along with it.
+func (d *RoleStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.RoleID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByLegalEntityID lists the roles defined by a legal entity. Use cursor to page through results, if any.
+func (d *RoleStore) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Role, *models.Cursor, error) {
+	rolesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Role{}).
+		Where(goqu.Ex{"access_control_role_legal_entity_id": legalEntityID})
+	return d.list(ctx, txOrNil, pagination, rolesSelect)
+}
+
+// ListByAssignee lists the roles defined by a legal entity that are assigned to the specified group or identity.
+// Use cursor to page through results, if any.
+func (d *RoleStore) ListByAssignee(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, assigneeID models.ResourceID, pagination models.Pagination) ([]*models.Role, *models.Cursor, error) {
+	rolesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Role{}).
+		Join(goqu.T("access_control_role_assignments"),
+			goqu.On(goqu.Ex{"access_control_roles.access_control_role_id": goqu.I("access_control_role_assignments.access_control_role_assignment_role_id")})).
+		Where(goqu.Ex{"access_control_role_legal_entity_id": legalEntityID}).
+		Where(goqu.Or(
+			goqu.Ex{"access_control_role_assignment_authorized_identity_id": assigneeID},
+			goqu.Ex{"access_control_role_assignment_authorized_group_id": assigneeID},
+		))
+	return d.list(ctx, txOrNil, pagination, rolesSelect)
+}
+
+func (d *RoleStore) list(ctx context.Context, txOrNil *store.Tx, pagination models.Pagination, rolesSelect *goqu.SelectDataset) ([]*models.Role, *models.Cursor, error) {
+	var roles []*models.Role
+	cursor, err := d.table.ListIn(ctx, txOrNil, &roles, pagination, rolesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, cursor, nil
+}
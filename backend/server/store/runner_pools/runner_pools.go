@@ -0,0 +1,101 @@
+package runner_pools
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.RunnerPool{})
+	store.MustDBModel(&models.RunnerPool{})
+}
+
+type RunnerPoolStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *RunnerPoolStore {
+	return &RunnerPoolStore{
+		table: store.NewResourceTable(db, logFactory, &models.RunnerPool{}),
+	}
+}
+
+// Create a new runner pool.
+// Returns store.ErrAlreadyExists if a runner pool with matching unique properties already exists.
+func (d *RunnerPoolStore) Create(ctx context.Context, txOrNil *store.Tx, pool *models.RunnerPool) error {
+	return d.table.Create(ctx, txOrNil, pool)
+}
+
+// Read an existing runner pool, looking it up by ResourceID.
+// Returns models.ErrNotFound if the runner pool does not exist.
+func (d *RunnerPoolStore) Read(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID) (*models.RunnerPool, error) {
+	pool := &models.RunnerPool{}
+	return pool, d.table.ReadByID(ctx, txOrNil, id.ResourceID, pool)
+}
+
+// Update an existing runner pool with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *RunnerPoolStore) Update(ctx context.Context, txOrNil *store.Tx, pool *models.RunnerPool) error {
+	return d.table.UpdateByID(ctx, txOrNil, pool)
+}
+
+// Delete permanently and idempotently deletes a runner pool, identifying it by id.
+func (d *RunnerPoolStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.RunnerPoolID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByLegalEntityID lists the runner pools belonging to a legal entity. Use cursor to page through results, if any.
+func (d *RunnerPoolStore) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.RunnerPool, *models.Cursor, error) {
+	poolsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.RunnerPool{}).
+		Where(goqu.Ex{"runner_pool_legal_entity_id": legalEntityID})
+	return d.list(ctx, txOrNil, pagination, poolsSelect)
+}
+
+// ListAll lists the runner pools belonging to all legal entities. Use cursor to page through results, if any.
+func (d *RunnerPoolStore) ListAll(ctx context.Context, txOrNil *store.Tx, pagination models.Pagination) ([]*models.RunnerPool, *models.Cursor, error) {
+	poolsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.RunnerPool{})
+	return d.list(ctx, txOrNil, pagination, poolsSelect)
+}
+
+// PoolCompatibleWithJob returns true if a runner pool exists that could start a runner capable of running job.
+func (d *RunnerPoolStore) PoolCompatibleWithJob(ctx context.Context, txOrNil *store.Tx, job *models.Job) (bool, error) {
+	poolsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.RunnerPool{}).
+		Join(goqu.T("repos"), goqu.On(goqu.Ex{"runner_pools.runner_pool_legal_entity_id": goqu.I("repos.repo_legal_entity_id")})).
+		Where(goqu.Ex{"repos.repo_id": job.RepoID})
+	pagination := models.NewPagination(models.DefaultPaginationLimit, nil)
+	for {
+		pools, cursor, err := d.list(ctx, txOrNil, pagination, poolsSelect)
+		if err != nil {
+			return false, err
+		}
+		for _, pool := range pools {
+			if pool.CanRun(job.RunsOn) {
+				return true, nil
+			}
+		}
+		if cursor == nil || cursor.Next == nil {
+			return false, nil
+		}
+		pagination.Cursor = cursor.Next
+	}
+}
+
+func (d *RunnerPoolStore) list(ctx context.Context, txOrNil *store.Tx, pagination models.Pagination, poolsSelect *goqu.SelectDataset) ([]*models.RunnerPool, *models.Cursor, error) {
+	var pools []*models.RunnerPool
+	cursor, err := d.table.ListIn(ctx, txOrNil, &pools, pagination, poolsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pools, cursor, nil
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/models"
+)
+
+// MakeRunnerLegalEntityIDsSubQuery returns a query that selects the IDs of the legal entities whose jobs the
+// runner identified by runnerID can run. runnerID can be a runner ID or a column identifier.
+// A runner can run jobs for each legal entity whose 'runner' standard group it is a member of; this is always
+// the legal entity that owns the runner, plus any legal entities the runner has been shared with.
+func MakeRunnerLegalEntityIDsSubQuery(runnerID interface{}) *goqu.SelectDataset {
+	return goqu.From(goqu.T("access_control_groups").As("runner_groups")).
+		Select(goqu.I("runner_groups.access_control_group_legal_entity_id")).
+		Join(goqu.T("access_control_group_memberships").As("runner_group_memberships"),
+			goqu.On(goqu.Ex{"runner_groups.access_control_group_id": goqu.I("runner_group_memberships.access_control_group_membership_group_id")})).
+		Join(goqu.T("identities").As("runner_identities"),
+			goqu.On(goqu.Ex{"runner_group_memberships.access_control_group_membership_member_identity_id": goqu.I("runner_identities.identity_id")})).
+		Where(goqu.Ex{
+			"runner_groups.access_control_group_name":       models.RunnerStandardGroup.Name,
+			"runner_groups.access_control_group_deleted_at": nil,
+			"runner_identities.identity_owner_resource_id":  runnerID,
+		})
+}
@@ -44,6 +44,13 @@ func (d *RunnerStore) Read(ctx context.Context, txOrNil *store.Tx, id models.Run
 	return runner, d.table.ReadByID(ctx, txOrNil, id.ResourceID, runner)
 }
 
+// ListByIDs reads the runners with the specified IDs, in no particular order. IDs of runners that do not
+// exist are ignored.
+func (d *RunnerStore) ListByIDs(ctx context.Context, txOrNil *store.Tx, ids []models.RunnerID) ([]*models.Runner, error) {
+	var runners []*models.Runner
+	return runners, d.table.ReadByIDs(ctx, txOrNil, ids, &runners)
+}
+
 // ReadByName reads an existing runner, looking it up by name and the ID of the legal entity that owns the runner.
 // Returns models.ErrNotFound if the runner is not found.
 func (d *RunnerStore) ReadByName(
@@ -136,15 +143,21 @@ func (d *RunnerStore) DeleteSupportedJobType(ctx context.Context, txOrNil *store
 	})
 }
 
-// RunnerCompatibleWithJob returns true if a runner exists that is capable of running job.
+// RunnerCompatibleWithJob returns true if a runner exists that is capable of running job, including having
+// enough total capacity for the job's resource requirements. The runner must be owned by or shared with the
+// legal entity that owns the job's repo.
 func (d *RunnerStore) RunnerCompatibleWithJob(ctx context.Context, txOrNil *store.Tx, job *models.Job) (bool, error) {
 	query := d.table.Dialect().
 		From(d.table.TableName()).
 		Select(&models.Runner{}).
-		Join(goqu.T("repos"), goqu.On(goqu.Ex{"runners.runner_legal_entity_id": goqu.I("repos.repo_legal_entity_id")})).
+		Join(goqu.T("repos"), goqu.On(goqu.L("? IN ?", goqu.I("repos.repo_legal_entity_id"), store.MakeRunnerLegalEntityIDsSubQuery(goqu.I("runners.runner_id"))))).
 		Where(goqu.Ex{"repos.repo_id": job.RepoID}).
 		Where(goqu.I("runners.runner_deleted_at").IsNull())
 
+	if job.OperatingSystem != "" {
+		query = query.Where(goqu.Ex{"runners.runner_operating_system": job.OperatingSystem})
+	}
+
 	if len(job.RunsOn) > 0 {
 		var jobLabels []string
 		for _, label := range job.RunsOn {
@@ -158,6 +171,36 @@ func (d *RunnerStore) RunnerCompatibleWithJob(ctx context.Context, txOrNil *stor
 		query = query.Where(goqu.V(labelSubQuery).Eq(len(jobLabels)))
 	}
 
+	if !job.Type.IsBuiltIn() {
+		// Custom job types are only supported by runners with a plugin for the type.
+		jobTypeSubQuery := d.table.Dialect().From(goqu.T("runner_supported_job_types")).
+			Select(goqu.COUNT("*")).
+			Where(goqu.Ex{"runner_supported_job_types.runner_supported_job_types_runner_id": goqu.I("runners.runner_id")}).
+			Where(goqu.Ex{"runner_supported_job_types.runner_supported_job_types_job_type": job.Type})
+		query = query.Where(goqu.V(jobTypeSubQuery).Gt(0))
+	}
+
+	// The runner's total capacity must be enough for the job, for each resource the runner limits
+	resourceRequirements := []struct {
+		column   string
+		required int64
+	}{
+		{"runners.runner_capacity_cpu_millis", job.CPUMillis},
+		{"runners.runner_capacity_memory_bytes", job.MemoryBytes},
+		{"runners.runner_capacity_disk_bytes", job.DiskBytes},
+	}
+	for _, requirement := range resourceRequirements {
+		if requirement.required > 0 {
+			query = query.Where(goqu.Or(
+				goqu.I(requirement.column).Eq(0),
+				goqu.I(requirement.column).Gte(requirement.required)))
+		}
+	}
+	// Runners only have GPUs if they say so
+	if job.GPUs > 0 {
+		query = query.Where(goqu.I("runners.runner_capacity_gpus").Gte(job.GPUs))
+	}
+
 	query = query.Limit(1)
 
 	runner := &models.Runner{}
@@ -171,6 +214,29 @@ func (d *RunnerStore) RunnerCompatibleWithJob(ctx context.Context, txOrNil *stor
 	return true, nil
 }
 
+// ListOnlineNotSeenSince lists the runners that are marked as online but have not sent a heartbeat since the
+// specified time. Soft-deleted runners are excluded.
+func (d *RunnerStore) ListOnlineNotSeenSince(ctx context.Context, txOrNil *store.Tx, since models.Time) ([]*models.Runner, error) {
+	// Format the time in a form usable in SQL queries
+	sinceValue, err := since.Value()
+	if err != nil {
+		return nil, fmt.Errorf("error converting time to value: %w", err)
+	}
+	runnersSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Runner{}).
+		Where(
+			goqu.Ex{"runner_online": true},
+			goqu.C("runner_last_seen_at").Lt(sinceValue),
+			goqu.C("runner_deleted_at").IsNull())
+	var runners []*models.Runner
+	err = d.table.ListAllIn(ctx, txOrNil, &runners, runnersSelect)
+	if err != nil {
+		return nil, err
+	}
+	return runners, nil
+}
+
 // Search all runners. If searcher is set, the results will be limited to runners the searcher is authorized to
 // see (via the read:runner permission). Use cursor to page through results, if any.
 func (d *RunnerStore) Search(ctx context.Context, txOrNil *store.Tx, searcher models.IdentityID, search models.RunnerSearch) ([]*models.Runner, *models.Cursor, error) {
@@ -186,6 +252,11 @@ func (d *RunnerStore) Search(ctx context.Context, txOrNil *store.Tx, searcher mo
 			Where(goqu.Ex{"runner_legal_entity_id": search.LegalEntityID})
 	}
 
+	if search.Online != nil {
+		runnersSelect = runnersSelect.
+			Where(goqu.Ex{"runner_online": *search.Online})
+	}
+
 	var runners []*models.Runner
 	cursor, err := d.table.ListIn(ctx, txOrNil, &runners, search.Pagination, runnersSelect)
 	if err != nil {
@@ -0,0 +1,58 @@
+package saml_identity_providers
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.SAMLIdentityProvider{})
+	store.MustDBModel(&models.SAMLIdentityProvider{})
+}
+
+type SAMLIdentityProviderStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *SAMLIdentityProviderStore {
+	return &SAMLIdentityProviderStore{
+		table: store.NewResourceTable(db, logFactory, &models.SAMLIdentityProvider{}),
+	}
+}
+
+// Create a new SAML identity provider.
+// Returns store.ErrAlreadyExists if an identity provider with matching unique properties already exists.
+func (d *SAMLIdentityProviderStore) Create(ctx context.Context, txOrNil *store.Tx, provider *models.SAMLIdentityProvider) error {
+	return d.table.Create(ctx, txOrNil, provider)
+}
+
+// Read an existing SAML identity provider, looking it up by ResourceID.
+// Returns models.ErrNotFound if the identity provider does not exist.
+func (d *SAMLIdentityProviderStore) Read(ctx context.Context, txOrNil *store.Tx, id models.SAMLIdentityProviderID) (*models.SAMLIdentityProvider, error) {
+	provider := &models.SAMLIdentityProvider{}
+	return provider, d.table.ReadByID(ctx, txOrNil, id.ResourceID, provider)
+}
+
+// ReadByLegalEntityID reads the SAML identity provider configured for a legal entity.
+// Returns models.ErrNotFound if the legal entity has no identity provider.
+func (d *SAMLIdentityProviderStore) ReadByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID) (*models.SAMLIdentityProvider, error) {
+	provider := &models.SAMLIdentityProvider{}
+	return provider, d.table.ReadWhere(ctx, txOrNil, provider,
+		goqu.Ex{"saml_identity_provider_legal_entity_id": legalEntityID})
+}
+
+// Update an existing SAML identity provider with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *SAMLIdentityProviderStore) Update(ctx context.Context, txOrNil *store.Tx, provider *models.SAMLIdentityProvider) error {
+	return d.table.UpdateByID(ctx, txOrNil, provider)
+}
+
+// Delete permanently and idempotently deletes a SAML identity provider, identifying it by id.
+func (d *SAMLIdentityProviderStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.SAMLIdentityProviderID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
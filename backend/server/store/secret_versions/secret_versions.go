@@ -0,0 +1,115 @@
+package secret_versions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.SecretVersion{})
+}
+
+type SecretVersionStore struct {
+	db    *store.DB
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *SecretVersionStore {
+	return &SecretVersionStore{
+		db:    db,
+		table: store.NewResourceTable(db, logFactory, &models.SecretVersion{}),
+	}
+}
+
+// Create a new secret version.
+// Returns store.ErrAlreadyExists if a secret version with matching unique properties already exists.
+func (d *SecretVersionStore) Create(ctx context.Context, txOrNil *store.Tx, version *models.SecretVersion) error {
+	return d.table.Create(ctx, txOrNil, version)
+}
+
+// ReadCurrentAt reads the version of a secret that was current at the specified time, i.e. the latest
+// version created at or before that time.
+// Returns models.ErrNotFound if there is no such version (or it has been purged).
+func (d *SecretVersionStore) ReadCurrentAt(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID, at models.Time) (*models.SecretVersion, error) {
+	atValue, err := at.Value()
+	if err != nil {
+		return nil, fmt.Errorf("error converting time to value: %w", err)
+	}
+	version := &models.SecretVersion{}
+	ds := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(version).
+		Where(
+			goqu.Ex{"secret_version_secret_id": secretID},
+			goqu.C("secret_version_created_at").Lte(atValue),
+			goqu.C("secret_version_superseded_at").Gt(atValue)).
+		Order(goqu.I("secret_version_number").Desc())
+	return version, d.table.ReadIn(ctx, txOrNil, version, ds)
+}
+
+// DeleteBySecretID permanently and idempotently deletes all versions of a secret.
+func (d *SecretVersionStore) DeleteBySecretID(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID) error {
+	return d.table.DeleteWhere(ctx, txOrNil, goqu.Ex{"secret_version_secret_id": secretID})
+}
+
+// DeleteSuperseded permanently deletes prior versions of secrets that were superseded before the specified
+// time, always keeping the newest keep prior versions of each secret. Returns the number of versions deleted.
+func (d *SecretVersionStore) DeleteSuperseded(ctx context.Context, txOrNil *store.Tx, keep int, supersededBefore models.Time) (int, error) {
+	supersededBeforeValue, err := supersededBefore.Value()
+	if err != nil {
+		return 0, fmt.Errorf("error converting time to value: %w", err)
+	}
+	// The current version of each secret is held in the secrets table, so the newest prior version
+	// is always one less than the secret's version.
+	purgeSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Join(goqu.T("secrets"), goqu.On(goqu.Ex{"secret_id": goqu.I("secret_version_secret_id")})).
+		Select(goqu.C("secret_version_id")).
+		Where(
+			goqu.C("secret_version_superseded_at").Lt(supersededBeforeValue),
+			goqu.L("secret_version_number < secret_version - ?", keep))
+
+	var ids []models.SecretVersionID
+	err = d.db.WithTx(ctx, txOrNil, func(tx *store.Tx) error {
+		err := d.db.Read2(tx, func(db store.Reader) error {
+			query, args, err := purgeSelect.ToSQL()
+			if err != nil {
+				return fmt.Errorf("error generating query: %w", err)
+			}
+			d.table.LogQuery(query, args)
+			return db.ScanValsContext(ctx, &ids, query, args...)
+		})
+		if err != nil {
+			return store.MakeStandardDBError(err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return d.table.DeleteWhere(ctx, tx, goqu.C("secret_version_id").In(ids))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// ListBySecretID lists the prior versions of a secret, newest first. Use cursor to page through results, if any.
+func (d *SecretVersionStore) ListBySecretID(ctx context.Context, txOrNil *store.Tx, secretID models.SecretID, pagination models.Pagination) ([]*models.SecretVersion, *models.Cursor, error) {
+	versionsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.SecretVersion{}).
+		Where(goqu.Ex{"secret_version_secret_id": secretID})
+
+	var versions []*models.SecretVersion
+	cursor, err := d.table.ListIn(ctx, txOrNil, &versions, pagination, versionsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return versions, cursor, nil
+}
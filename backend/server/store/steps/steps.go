@@ -2,6 +2,7 @@ package steps
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/doug-martin/goqu/v9"
 
@@ -17,11 +18,13 @@ func init() {
 }
 
 type StepStore struct {
+	db    *store.DB
 	table *store.ResourceTable
 }
 
 func NewStore(db *store.DB, logFactory logger.LogFactory) *StepStore {
 	return &StepStore{
+		db:    db,
 		table: store.NewResourceTable(db, logFactory, &models.Step{}),
 	}
 }
@@ -59,3 +62,55 @@ func (d *StepStore) ListByJobID(ctx context.Context, txOrNil *store.Tx, jobID mo
 	}
 	return steps, nil
 }
+
+// ListByJobIDs gets all steps that are associated with any of the specified job ids.
+func (d *StepStore) ListByJobIDs(ctx context.Context, txOrNil *store.Tx, jobIDs []models.JobID) ([]*models.Step, error) {
+	stepsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Step{}).
+		Where(goqu.Ex{"step_job_id": jobIDs})
+	var steps []*models.Step
+	err := d.table.ListAllIn(ctx, txOrNil, &steps, stepsSelect)
+	if err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// ListByJobIDsIncludingDeleted gets all steps that are associated with any of the specified job ids, including
+// steps that have been soft-deleted.
+func (d *StepStore) ListByJobIDsIncludingDeleted(ctx context.Context, txOrNil *store.Tx, jobIDs []models.JobID) ([]*models.Step, error) {
+	stepsSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.Step{}).
+		Where(goqu.Ex{"step_job_id": jobIDs}).
+		Order(goqu.I("step_created_at").Asc(), goqu.I("step_id").Asc())
+
+	// Perform the read directly on the database; ResourceTable.ListIn() is not suitable because it
+	// leaves out soft-deleted steps
+	var steps []*models.Step
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := stepsSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &steps, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return steps, nil
+}
+
+// SoftDelete soft deletes an existing step. Soft-deleted steps can't be read or listed.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *StepStore) SoftDelete(ctx context.Context, txOrNil *store.Tx, step *models.Step) error {
+	return d.table.SoftDelete(ctx, txOrNil, step)
+}
+
+// DeleteByJobIDs permanently and idempotently deletes all steps in any of the specified jobs, whether or not
+// they have been soft-deleted.
+func (d *StepStore) DeleteByJobIDs(ctx context.Context, txOrNil *store.Tx, jobIDs []models.JobID) error {
+	return d.table.DeleteWhere(ctx, txOrNil, goqu.Ex{"step_job_id": jobIDs})
+}
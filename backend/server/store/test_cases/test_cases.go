@@ -0,0 +1,186 @@
+package test_cases
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.TestCase{})
+}
+
+type TestCaseStore struct {
+	db    *store.DB
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *TestCaseStore {
+	return &TestCaseStore{
+		db:    db,
+		table: store.NewResourceTable(db, logFactory, &models.TestCase{}),
+	}
+}
+
+// Create a new test case.
+// Returns store.ErrAlreadyExists if a test case with matching unique properties already exists.
+func (d *TestCaseStore) Create(ctx context.Context, txOrNil *store.Tx, testCase *models.TestCase) error {
+	return d.table.Create(ctx, txOrNil, testCase)
+}
+
+// Search all test cases matching the search criteria.
+// Use cursor to page through results, if any.
+func (d *TestCaseStore) Search(ctx context.Context, txOrNil *store.Tx, search models.TestCaseSearch) ([]*models.TestCase, *models.Cursor, error) {
+	testCasesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.TestCase{})
+	if search.BuildID != nil {
+		testCasesSelect = testCasesSelect.Where(goqu.Ex{"test_case_build_id": *search.BuildID})
+	}
+	if search.RepoID != nil {
+		testCasesSelect = testCasesSelect.Where(goqu.Ex{"test_case_repo_id": *search.RepoID})
+	}
+	if search.TestRunID != nil {
+		testCasesSelect = testCasesSelect.Where(goqu.Ex{"test_case_test_run_id": *search.TestRunID})
+	}
+	if search.Suite != nil {
+		testCasesSelect = testCasesSelect.Where(goqu.Ex{"test_case_suite": *search.Suite})
+	}
+	if search.Name != nil {
+		testCasesSelect = testCasesSelect.Where(goqu.Ex{"test_case_name": *search.Name})
+	}
+	if search.Status != nil {
+		testCasesSelect = testCasesSelect.Where(goqu.Ex{"test_case_status": *search.Status})
+	}
+
+	var testCases []*models.TestCase
+	cursor, err := d.table.ListIn(ctx, txOrNil, &testCases, search.Pagination, testCasesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return testCases, cursor, nil
+}
+
+// CountStatusesByBuildID counts the test cases reported by a build, grouped by suite, name and status.
+func (d *TestCaseStore) CountStatusesByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) ([]*models.TestCaseStatusCount, error) {
+	countsSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(
+			goqu.C("test_case_suite"),
+			goqu.C("test_case_name"),
+			goqu.C("test_case_status"),
+			goqu.COUNT("*").As("count")).
+		Where(goqu.Ex{"test_case_build_id": buildID}).
+		GroupBy(
+			goqu.C("test_case_suite"),
+			goqu.C("test_case_name"),
+			goqu.C("test_case_status"))
+
+	var counts []*models.TestCaseStatusCount
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := countsSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &counts, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return counts, nil
+}
+
+// CountStatusesByCommitID counts the test cases reported by jobs in a repo that ran against a commit,
+// grouped by suite, name and status.
+func (d *TestCaseStore) CountStatusesByCommitID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, commitID models.CommitID) ([]*models.TestCaseStatusCount, error) {
+	countsSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Join(goqu.T("test_runs"), goqu.On(goqu.Ex{"test_cases.test_case_test_run_id": goqu.I("test_runs.test_run_id")})).
+		Join(goqu.T("jobs"), goqu.On(goqu.Ex{"test_runs.test_run_job_id": goqu.I("jobs.job_id")})).
+		Select(
+			goqu.C("test_case_suite"),
+			goqu.C("test_case_name"),
+			goqu.C("test_case_status"),
+			goqu.COUNT("*").As("count")).
+		Where(goqu.Ex{
+			"test_cases.test_case_repo_id": repoID,
+			"jobs.job_commit_id":           commitID,
+		}).
+		GroupBy(
+			goqu.C("test_case_suite"),
+			goqu.C("test_case_name"),
+			goqu.C("test_case_status"))
+
+	var counts []*models.TestCaseStatusCount
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := countsSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &counts, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return counts, nil
+}
+
+// ListFailedByJobID lists the test cases that failed on every attempt in the test runs reported by a job.
+func (d *TestCaseStore) ListFailedByJobID(ctx context.Context, txOrNil *store.Tx, jobID models.JobID) ([]*models.TestCase, error) {
+	testCasesSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Join(goqu.T("test_runs"), goqu.On(goqu.Ex{"test_cases.test_case_test_run_id": goqu.I("test_runs.test_run_id")})).
+		Select(&models.TestCase{}).
+		Where(goqu.Ex{
+			"test_runs.test_run_job_id":   jobID,
+			"test_cases.test_case_status": models.TestCaseStatusFailed,
+		}).
+		Order(goqu.I("test_cases.test_case_suite").Asc(), goqu.I("test_cases.test_case_name").Asc())
+
+	var testCases []*models.TestCase
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := testCasesSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &testCases, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return testCases, nil
+}
+
+// ListFailedByBuildID lists the test cases that failed on every attempt in the test runs reported by a build.
+func (d *TestCaseStore) ListFailedByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) ([]*models.TestCase, error) {
+	testCasesSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(&models.TestCase{}).
+		Where(goqu.Ex{
+			"test_case_build_id": buildID,
+			"test_case_status":   models.TestCaseStatusFailed,
+		}).
+		Order(goqu.I("test_case_suite").Asc(), goqu.I("test_case_name").Asc())
+
+	var testCases []*models.TestCase
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := testCasesSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &testCases, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return testCases, nil
+}
@@ -0,0 +1,62 @@
+package test_runs
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.TestRun{})
+}
+
+type TestRunStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *TestRunStore {
+	return &TestRunStore{
+		table: store.NewResourceTable(db, logFactory, &models.TestRun{}),
+	}
+}
+
+// Create a new test run.
+// Returns store.ErrAlreadyExists if a test run with matching unique properties already exists.
+func (d *TestRunStore) Create(ctx context.Context, txOrNil *store.Tx, testRun *models.TestRun) error {
+	return d.table.Create(ctx, txOrNil, testRun)
+}
+
+// Read an existing test run, looking it up by ResourceID.
+// Returns models.ErrNotFound if the test run does not exist.
+func (d *TestRunStore) Read(ctx context.Context, txOrNil *store.Tx, id models.TestRunID) (*models.TestRun, error) {
+	testRun := &models.TestRun{}
+	return testRun, d.table.ReadByID(ctx, txOrNil, id.ResourceID, testRun)
+}
+
+// ReadByArtifactID reads an existing test run, looking it up by the ID of the artifact it was parsed from.
+// Returns models.ErrNotFound if the test run does not exist.
+func (d *TestRunStore) ReadByArtifactID(ctx context.Context, txOrNil *store.Tx, artifactID models.ArtifactID) (*models.TestRun, error) {
+	testRun := &models.TestRun{}
+	return testRun, d.table.ReadWhere(ctx, txOrNil, testRun,
+		goqu.Ex{"test_run_artifact_id": artifactID})
+}
+
+// ListByBuildID lists the test runs for a build.
+// Use cursor to page through results, if any.
+func (d *TestRunStore) ListByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID, pagination models.Pagination) ([]*models.TestRun, *models.Cursor, error) {
+	testRunsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.TestRun{}).
+		Where(goqu.Ex{"test_run_build_id": buildID})
+
+	var testRuns []*models.TestRun
+	cursor, err := d.table.ListIn(ctx, txOrNil, &testRuns, pagination, testRunsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return testRuns, cursor, nil
+}
@@ -0,0 +1,60 @@
+package test_summaries
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.TestSummary{})
+}
+
+type TestSummaryStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *TestSummaryStore {
+	return &TestSummaryStore{
+		table: store.NewResourceTableWithTableName(db, logFactory, "test_summaries", &models.TestSummary{}),
+	}
+}
+
+// Create a new test summary.
+// Returns store.ErrAlreadyExists if a test summary with matching unique properties already exists.
+func (d *TestSummaryStore) Create(ctx context.Context, txOrNil *store.Tx, testSummary *models.TestSummary) error {
+	return d.table.Create(ctx, txOrNil, testSummary)
+}
+
+// ReadByBuildID reads the test summary for a build.
+// Returns models.ErrNotFound if the build has no test summary.
+func (d *TestSummaryStore) ReadByBuildID(ctx context.Context, txOrNil *store.Tx, buildID models.BuildID) (*models.TestSummary, error) {
+	testSummary := &models.TestSummary{}
+	return testSummary, d.table.ReadWhere(ctx, txOrNil, testSummary,
+		goqu.Ex{"test_summary_build_id": buildID})
+}
+
+// Update an existing test summary. Overrides all previous values using the supplied model.
+func (d *TestSummaryStore) Update(ctx context.Context, txOrNil *store.Tx, testSummary *models.TestSummary) error {
+	return d.table.UpdateByID(ctx, txOrNil, testSummary)
+}
+
+// ListByRepoID lists the test summaries for builds in a repo, newest first.
+// Use cursor to page through results, if any.
+func (d *TestSummaryStore) ListByRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID, pagination models.Pagination) ([]*models.TestSummary, *models.Cursor, error) {
+	testSummariesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.TestSummary{}).
+		Where(goqu.Ex{"test_summary_repo_id": repoID})
+
+	var testSummaries []*models.TestSummary
+	cursor, err := d.table.ListIn(ctx, txOrNil, &testSummaries, pagination, testSummariesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return testSummaries, cursor, nil
+}
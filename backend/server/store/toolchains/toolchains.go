@@ -0,0 +1,83 @@
+package toolchains
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.Toolchain{})
+	store.MustDBModel(&models.Toolchain{})
+}
+
+type ToolchainStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *ToolchainStore {
+	return &ToolchainStore{
+		table: store.NewResourceTable(db, logFactory, &models.Toolchain{}),
+	}
+}
+
+// Create a new toolchain.
+// Returns store.ErrAlreadyExists if a toolchain with matching unique properties already exists.
+func (d *ToolchainStore) Create(ctx context.Context, txOrNil *store.Tx, toolchain *models.Toolchain) error {
+	return d.table.Create(ctx, txOrNil, toolchain)
+}
+
+// Read an existing toolchain, looking it up by ResourceID.
+// Returns models.ErrNotFound if the toolchain does not exist.
+func (d *ToolchainStore) Read(ctx context.Context, txOrNil *store.Tx, id models.ToolchainID) (*models.Toolchain, error) {
+	toolchain := &models.Toolchain{}
+	return toolchain, d.table.ReadByID(ctx, txOrNil, id.ResourceID, toolchain)
+}
+
+// ReadByReference reads an existing toolchain, looking it up by legal entity, name and version.
+// Returns models.ErrNotFound if the toolchain does not exist.
+func (d *ToolchainStore) ReadByReference(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, ref models.ToolchainReference) (*models.Toolchain, error) {
+	toolchain := &models.Toolchain{}
+	return toolchain, d.table.ReadWhere(ctx, txOrNil, toolchain,
+		goqu.Ex{"toolchain_legal_entity_id": legalEntityID},
+		goqu.Ex{"toolchain_name": ref.Name},
+		goqu.Ex{"toolchain_version": ref.Version})
+}
+
+// Delete permanently and idempotently deletes a toolchain, identifying it by id.
+func (d *ToolchainStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.ToolchainID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByLegalEntityID lists the toolchains belonging to a legal entity. Use cursor to page through results, if any.
+func (d *ToolchainStore) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.Toolchain, *models.Cursor, error) {
+	toolchainsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Toolchain{}).
+		Where(goqu.Ex{"toolchain_legal_entity_id": legalEntityID})
+
+	var toolchains []*models.Toolchain
+	cursor, err := d.table.ListIn(ctx, txOrNil, &toolchains, pagination, toolchainsSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return toolchains, cursor, nil
+}
+
+// ListBySourceRepoID lists all versions of the toolchains registered by builds of the specified repo.
+func (d *ToolchainStore) ListBySourceRepoID(ctx context.Context, txOrNil *store.Tx, repoID models.RepoID) ([]*models.Toolchain, error) {
+	toolchainsSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.Toolchain{}).
+		Where(goqu.Ex{"toolchain_source_repo_id": repoID})
+	var toolchains []*models.Toolchain
+	err := d.table.ListAllIn(ctx, txOrNil, &toolchains, toolchainsSelect)
+	if err != nil {
+		return nil, err
+	}
+	return toolchains, nil
+}
@@ -0,0 +1,68 @@
+package usage_quotas
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.UsageQuota{})
+	store.MustDBModel(&models.UsageQuota{})
+}
+
+type UsageQuotaStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *UsageQuotaStore {
+	return &UsageQuotaStore{
+		table: store.NewResourceTable(db, logFactory, &models.UsageQuota{}),
+	}
+}
+
+// Create a new usage quota.
+// Returns store.ErrAlreadyExists if a usage quota with matching unique properties already exists.
+func (d *UsageQuotaStore) Create(ctx context.Context, txOrNil *store.Tx, quota *models.UsageQuota) error {
+	return d.table.Create(ctx, txOrNil, quota)
+}
+
+// ReadByMetric reads the quota a legal entity has for a metric.
+// Returns models.ErrNotFound if the legal entity has no quota for the metric.
+func (d *UsageQuotaStore) ReadByMetric(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, metric models.UsageMetric) (*models.UsageQuota, error) {
+	quota := &models.UsageQuota{}
+	return quota, d.table.ReadWhere(ctx, txOrNil, quota, goqu.Ex{
+		"usage_quota_legal_entity_id": legalEntityID,
+		"usage_quota_metric":          metric,
+	})
+}
+
+// Update an existing usage quota with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *UsageQuotaStore) Update(ctx context.Context, txOrNil *store.Tx, quota *models.UsageQuota) error {
+	return d.table.UpdateByID(ctx, txOrNil, quota)
+}
+
+// Delete permanently and idempotently deletes a usage quota, identifying it by id.
+func (d *UsageQuotaStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.UsageQuotaID) error {
+	return d.table.DeleteByID(ctx, txOrNil, id.ResourceID)
+}
+
+// ListByLegalEntityID lists the usage quotas of a legal entity. Use cursor to page through results, if any.
+func (d *UsageQuotaStore) ListByLegalEntityID(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, pagination models.Pagination) ([]*models.UsageQuota, *models.Cursor, error) {
+	quotasSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.UsageQuota{}).
+		Where(goqu.Ex{"usage_quota_legal_entity_id": legalEntityID})
+
+	var quotas []*models.UsageQuota
+	cursor, err := d.table.ListIn(ctx, txOrNil, &quotas, pagination, quotasSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return quotas, cursor, nil
+}
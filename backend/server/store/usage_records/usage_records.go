@@ -0,0 +1,92 @@
+package usage_records
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	store.MustDBModel(&models.UsageRecord{})
+}
+
+type UsageRecordStore struct {
+	db    *store.DB
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *UsageRecordStore {
+	return &UsageRecordStore{
+		db:    db,
+		table: store.NewResourceTable(db, logFactory, &models.UsageRecord{}),
+	}
+}
+
+// Create a new usage record.
+// Returns store.ErrAlreadyExists if a usage record with matching unique properties already exists.
+func (d *UsageRecordStore) Create(ctx context.Context, txOrNil *store.Tx, record *models.UsageRecord) error {
+	return d.table.Create(ctx, txOrNil, record)
+}
+
+// ReadBySource reads an existing usage record, looking it up by the resource the usage was measured from
+// and the metric. Returns models.ErrNotFound if the usage record does not exist.
+func (d *UsageRecordStore) ReadBySource(ctx context.Context, txOrNil *store.Tx, sourceResourceID models.ResourceID, metric models.UsageMetric) (*models.UsageRecord, error) {
+	record := &models.UsageRecord{}
+	return record, d.table.ReadWhere(ctx, txOrNil, record, goqu.Ex{
+		"usage_record_source_resource_id": sourceResourceID,
+		"usage_record_metric":             metric,
+	})
+}
+
+// FindOrCreate finds and returns the usage record for the source resource and metric in recordData.
+// If no such usage record exists then recordData is created and returned, and true is returned for 'created'.
+func (d *UsageRecordStore) FindOrCreate(ctx context.Context, txOrNil *store.Tx, recordData *models.UsageRecord) (record *models.UsageRecord, created bool, err error) {
+	resource, created, err := d.table.FindOrCreate(ctx, txOrNil,
+		func(ctx context.Context, tx *store.Tx) (models.Resource, error) {
+			return d.ReadBySource(ctx, tx, recordData.SourceResourceID, recordData.Metric)
+		},
+		func(ctx context.Context, tx *store.Tx) (models.Resource, error) {
+			return recordData, d.Create(ctx, tx, recordData)
+		},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	return resource.(*models.UsageRecord), created, nil
+}
+
+// Rollup totals the usage recorded for a legal entity for each usage period from 'from' to 'to' inclusive,
+// by repo and metric. Rollups are returned in period order.
+func (d *UsageRecordStore) Rollup(ctx context.Context, txOrNil *store.Tx, legalEntityID models.LegalEntityID, from models.UsagePeriod, to models.UsagePeriod) ([]*models.UsageRollup, error) {
+	rollupSelect := d.table.Dialect().
+		From(d.table.TableName()).
+		Select(
+			goqu.C("usage_record_period"),
+			goqu.C("usage_record_repo_id"),
+			goqu.C("usage_record_metric"),
+			goqu.SUM(goqu.C("usage_record_quantity")).As("quantity")).
+		Where(goqu.Ex{"usage_record_legal_entity_id": legalEntityID}).
+		Where(goqu.C("usage_record_period").Gte(from)).
+		Where(goqu.C("usage_record_period").Lte(to)).
+		GroupBy(goqu.C("usage_record_period"), goqu.C("usage_record_repo_id"), goqu.C("usage_record_metric")).
+		Order(goqu.C("usage_record_period").Asc(), goqu.C("usage_record_repo_id").Asc(), goqu.C("usage_record_metric").Asc())
+
+	var rollups []*models.UsageRollup
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := rollupSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &rollups, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+	return rollups, nil
+}
@@ -0,0 +1,65 @@
+package webhook_deliveries
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/buildbeaver/buildbeaver/common/logger"
+	"github.com/buildbeaver/buildbeaver/common/models"
+	"github.com/buildbeaver/buildbeaver/server/store"
+)
+
+func init() {
+	_ = models.MutableResource(&models.WebhookDelivery{})
+	store.MustDBModel(&models.WebhookDelivery{})
+}
+
+type WebhookDeliveryStore struct {
+	table *store.ResourceTable
+}
+
+func NewStore(db *store.DB, logFactory logger.LogFactory) *WebhookDeliveryStore {
+	return &WebhookDeliveryStore{
+		table: store.NewResourceTableWithTableName(db, logFactory, "webhook_deliveries", &models.WebhookDelivery{}),
+	}
+}
+
+// Create a new webhook delivery.
+// Returns store.ErrAlreadyExists if a delivery with matching unique properties already exists.
+func (d *WebhookDeliveryStore) Create(ctx context.Context, txOrNil *store.Tx, delivery *models.WebhookDelivery) error {
+	return d.table.Create(ctx, txOrNil, delivery)
+}
+
+// Read an existing webhook delivery, looking it up by ResourceID.
+// Returns models.ErrNotFound if the delivery does not exist.
+func (d *WebhookDeliveryStore) Read(ctx context.Context, txOrNil *store.Tx, id models.WebhookDeliveryID) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	return delivery, d.table.ReadByID(ctx, txOrNil, id.ResourceID, delivery)
+}
+
+// Update an existing webhook delivery with optimistic locking. Overrides all previous values using the supplied model.
+// Returns store.ErrOptimisticLockFailed if there is an optimistic lock mismatch.
+func (d *WebhookDeliveryStore) Update(ctx context.Context, txOrNil *store.Tx, delivery *models.WebhookDelivery) error {
+	return d.table.UpdateByID(ctx, txOrNil, delivery)
+}
+
+// Search all webhook deliveries, most recent first. Use cursor to page through results, if any.
+func (d *WebhookDeliveryStore) Search(ctx context.Context, txOrNil *store.Tx, search *models.WebhookDeliverySearch) ([]*models.WebhookDelivery, *models.Cursor, error) {
+	deliveriesSelect := goqu.
+		From(d.table.TableName()).
+		Select(&models.WebhookDelivery{})
+	if search.SCMName != nil {
+		deliveriesSelect = deliveriesSelect.Where(goqu.Ex{"webhook_delivery_scm_name": *search.SCMName})
+	}
+	if search.Status != nil {
+		deliveriesSelect = deliveriesSelect.Where(goqu.Ex{"webhook_delivery_status": *search.Status})
+	}
+
+	var deliveries []*models.WebhookDelivery
+	cursor, err := d.table.ListIn(ctx, txOrNil, &deliveries, search.Pagination, deliveriesSelect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return deliveries, cursor, nil
+}
@@ -2,6 +2,7 @@ package work_items
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/doug-martin/goqu/v9"
 
@@ -49,3 +50,28 @@ func (d *WorkItemStore) Update(ctx context.Context, txOrNil *store.Tx, workItem
 func (d *WorkItemStore) Delete(ctx context.Context, txOrNil *store.Tx, id models.WorkItemID) error {
 	return d.table.DeleteWhere(ctx, txOrNil, goqu.Ex{"work_item_id": id.ResourceID})
 }
+
+// ListUncompleted lists the work items sharing the specified work item state record that have not yet been
+// completed, in the order they will be processed.
+func (d *WorkItemStore) ListUncompleted(ctx context.Context, txOrNil *store.Tx, workItemStateID models.WorkItemStateID) ([]*models.WorkItem, error) {
+	var workItems []*models.WorkItem
+
+	workItemSelect := goqu.From(d.table.TableName()).Select(&models.WorkItem{}).
+		Where(goqu.Ex{"work_item_state": workItemStateID.ResourceID}).
+		Where(goqu.C("work_item_completed_at").IsNull()).
+		Order(goqu.C("work_item_created_at").Asc())
+
+	err := d.db.Read2(txOrNil, func(db store.Reader) error {
+		query, args, err := workItemSelect.ToSQL()
+		if err != nil {
+			return fmt.Errorf("error generating query: %w", err)
+		}
+		d.table.LogQuery(query, args)
+		return db.ScanStructsContext(ctx, &workItems, query, args...)
+	})
+	if err != nil {
+		return nil, store.MakeStandardDBError(err)
+	}
+
+	return workItems, nil
+}
@@ -78,15 +78,21 @@ export function ArtifactViewer(props: Props): JSX.Element {
               <td>{artifact.path}</td>
               <td className="whitespace-nowrap">{prettyBytes(artifact.size)}</td>
               <td>
-                <a
-                  className="flex items-center gap-x-2 text-blue-500 hover:text-gray-400"
-                  title="Download artifact"
-                  href={artifact.data_url}
-                  download={artifact.name}
-                >
-                  <IoMdDownload size={18} />
-                  Download
-                </a>
+                {artifact.scan_status === 'infected' ? (
+                  <span className="text-red-500" title={`Quarantined: ${artifact.scan_result}`}>
+                    Quarantined
+                  </span>
+                ) : (
+                  <a
+                    className="flex items-center gap-x-2 text-blue-500 hover:text-gray-400"
+                    title="Download artifact"
+                    href={artifact.data_url}
+                    download={artifact.name}
+                  >
+                    <IoMdDownload size={18} />
+                    Download
+                  </a>
+                )}
               </td>
             </tr>
           ))}
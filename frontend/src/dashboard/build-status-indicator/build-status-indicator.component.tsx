@@ -41,6 +41,7 @@ export function BuildStatusIndicator(props: Props): JSX.Element {
         </div>
       );
       break;
+    case Status.Skipped:
     case Status.SkippedStep:
       icon = <FaCheckCircle className="text-curiousBlue" size={size} title="Skipped" />;
       break;
@@ -3,6 +3,7 @@ export enum Status {
   Canceled = 'canceled',
   Failed = 'failed',
   Queued = 'queued',
+  Skipped = 'skipped',
   SkippedJob = 'skipped-job',
   SkippedStep = 'skipped-step',
   Submitted = 'submitted',
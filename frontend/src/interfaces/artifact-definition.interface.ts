@@ -5,4 +5,6 @@ export interface IArtifactDefinition {
   group_name: string;
   size: number;
   data_url: string;
+  scan_status: string;
+  scan_result?: string;
 }
@@ -0,0 +1,57 @@
+import { IBuild } from './build.interface';
+import { ITimings } from './timings.interface';
+import { Status } from '../enums/status.enum';
+
+export interface IBuildDagStep {
+  error?: string;
+  log_descriptor_id: string;
+  name: string;
+  status: Status;
+  step_id: string;
+  timings: ITimings;
+}
+
+export interface IBuildDagIndirectJob {
+  build_id: string;
+  job_id: string;
+  job_url: string;
+  status: Status;
+  steps: IBuildDagStep[];
+  timings: ITimings;
+}
+
+export interface IBuildDagNode {
+  deferred: boolean;
+  description?: string;
+  error?: string;
+  id: string;
+  indirect_to?: IBuildDagIndirectJob;
+  job_id?: string;
+  job_url?: string;
+  name: string;
+  stage?: string;
+  status: Status;
+  steps: IBuildDagStep[];
+  timings: ITimings;
+  workflow: string;
+}
+
+export interface IBuildDagEdge {
+  artifacts?: string[];
+  deferred: boolean;
+  from: string;
+  to: string;
+}
+
+export interface IBuildDagWorkflow {
+  name: string;
+  nodes: string[];
+}
+
+export interface IBuildDag {
+  build: IBuild;
+  edges?: IBuildDagEdge[];
+  nodes: IBuildDagNode[];
+  url: string;
+  workflows?: IBuildDagWorkflow[];
+}
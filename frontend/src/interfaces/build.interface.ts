@@ -3,8 +3,10 @@ import { Status } from '../enums/status.enum';
 
 export interface IBuild {
   artifact_search_url: string;
+  cloned_from_build_id?: string;
   commit_id: string;
   created_at: string;
+  dag_url: string;
   error?: string;
   etag: string;
   id: string;
@@ -15,6 +17,7 @@ export interface IBuild {
     force?: boolean;
     nodes_to_run?: string;
   };
+  priority?: number;
   ref: string;
   repo_id?: string;
   status: Status;
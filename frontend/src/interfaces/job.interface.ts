@@ -17,6 +17,7 @@ export interface IJob {
   depends?: IJobDependency[];
   description: string;
   docker?: IDocker;
+  duration_millis?: number;
   environment?: IEnvironment[];
   error?: string;
   etag: string;
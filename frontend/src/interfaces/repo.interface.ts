@@ -16,6 +16,7 @@ export interface IRepo {
   link: string;
   name: string;
   private: boolean;
+  public_builds: boolean;
   secrets_url: string;
   ssh_key_secret_id: string;
   ssh_url: string;
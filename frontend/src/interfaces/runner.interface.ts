@@ -5,9 +5,11 @@ export interface IRunner {
   etag: string;
   id: string;
   labels: string[];
+  last_seen_at?: string;
   legal_entity_id: string;
   name: string;
   enabled: boolean;
+  online: boolean;
   operating_system: string;
   software_version: string;
   supported_job_types: string[];
@@ -1,4 +1,5 @@
 import { apiGet, apiPost } from './api.service';
+import { IBuildDag } from '../interfaces/build-dag.interface';
 import { IBuildGraph } from '../interfaces/build-graph.interface';
 import { ICreateBuildRequest } from './requests/create-build-request.interface';
 
@@ -9,3 +10,7 @@ export async function createBuild(url: string, request: ICreateBuildRequest): Pr
 export async function fetchBuild(url: string): Promise<IBuildGraph> {
   return apiGet<IBuildGraph>(url);
 }
+
+export async function fetchBuildDag(url: string): Promise<IBuildDag> {
+  return apiGet<IBuildDag>(url);
+}
@@ -1,8 +1,11 @@
 export interface ICreateBuildRequest {
-  from_build_id: string;
+  from_build_id?: string;
+  ref?: string;
+  sha?: string;
   opts: IBuildOpts;
 }
 
 export interface IBuildOpts {
   force: boolean;
+  parameters?: Record<string, string>;
 }
@@ -1,3 +1,4 @@
 export interface IUpdateRepoRequest {
-  enabled: boolean;
+  enabled?: boolean;
+  public_builds?: boolean;
 }
@@ -10,6 +10,7 @@ export function getColourForStatus(status: Status): string {
     case Status.Failed:
       colour = 'amaranth';
       break;
+    case Status.Skipped:
     case Status.SkippedJob:
     case Status.SkippedStep:
       colour = 'curiousBlue';
@@ -22,5 +23,5 @@ export function getColourForStatus(status: Status): string {
 }
 
 export function isFinished(status: Status): boolean {
-  return [Status.Canceled, Status.Failed, Status.Succeeded, Status.SkippedJob, Status.SkippedStep].includes(status);
+  return [Status.Canceled, Status.Failed, Status.Succeeded, Status.Skipped, Status.SkippedJob, Status.SkippedStep].includes(status);
 }
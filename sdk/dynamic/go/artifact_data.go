@@ -0,0 +1,221 @@
+package bb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// ListAllArtifacts reads information about selected artifacts from the current build, fetching every page of
+// results from the server. An empty workflow matches jobs in any workflow, and an empty job name or group name
+// matches any job or group.
+func (b *Build) ListAllArtifacts(workflow string, jobName string, groupName string) ([]client.Artifact, error) {
+	page, err := b.ListArtifactsN(workflow, jobName, groupName, 100)
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []client.Artifact
+	for {
+		artifacts = append(artifacts, page.Artifacts...)
+		if !page.HasNext() {
+			return artifacts, nil
+		}
+		page, err = page.Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// MustListAllArtifacts reads information about selected artifacts from the current build, fetching every page
+// of results from the server.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustListAllArtifacts(workflow string, jobName string, groupName string) []client.Artifact {
+	artifacts, err := b.ListAllArtifacts(workflow, jobName, groupName)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return artifacts
+}
+
+// ReadArtifactData returns a reader for fetching the binary data for an artifact. The caller must close
+// the reader when finished with it.
+func (b *Build) ReadArtifactData(artifactID string) (io.ReadCloser, error) {
+	Log(LogLevelInfo, fmt.Sprintf("Fetching artifact from server for ID %s", artifactID))
+	buildAPI := b.apiClient.BuildApi
+
+	artifactFile, response, err := buildAPI.GetArtifactData(b.GetAuthorizedContext(), artifactID).Execute()
+	var statusCode int
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	if err != nil {
+		openAPIErr, ok := err.(*client.GenericOpenAPIError)
+		if ok {
+			return nil, fmt.Errorf("error fetching artifact data from server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
+		}
+		return nil, fmt.Errorf("error fetching artifact data from server (response status code %d): %w", statusCode, err)
+	}
+
+	// An empty body means an empty artifact, but is returned from the generated client as nil
+	if artifactFile == nil {
+		// Return a ReadCloser that has no data, so the caller doesn't have to deal with nil
+		return io.NopCloser(bytes.NewReader([]byte{})), nil
+	}
+
+	// The generated client downloads the data to a temporary file; remove the file once the caller has finished
+	return &tempFileReader{File: artifactFile}, nil
+}
+
+// MustReadArtifactData returns a reader for fetching the binary data for an artifact. The caller must close
+// the reader when finished with it.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustReadArtifactData(artifactID string) io.ReadCloser {
+	reader, err := b.ReadArtifactData(artifactID)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return reader
+}
+
+// DownloadArtifact downloads the binary data for an artifact to a file at the specified path, creating any
+// parent directories that don't already exist. An existing file at the path is replaced.
+func (b *Build) DownloadArtifact(artifactID string, filePath string) error {
+	reader, err := b.ReadArtifactData(artifactID)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating directory to download artifact %s: %w", artifactID, err)
+	}
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating file to download artifact %s: %w", artifactID, err)
+	}
+	n, err := io.Copy(file, reader)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error writing data for artifact %s to file '%s': %w", artifactID, filePath, err)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("error writing data for artifact %s to file '%s': %w", artifactID, filePath, err)
+	}
+	Log(LogLevelInfo, fmt.Sprintf("Downloaded artifact with ID %s to '%s' (%d bytes)", artifactID, filePath, n))
+
+	return nil
+}
+
+// MustDownloadArtifact downloads the binary data for an artifact to a file at the specified path.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustDownloadArtifact(artifactID string, filePath string) {
+	err := b.DownloadArtifact(artifactID, filePath)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+}
+
+// ListArtifacts reads information about the artifacts produced by jobs in this workflow, fetching every page of
+// results from the server. An empty job name matches every job in the workflow, and an empty group name matches
+// every artifact group.
+func (w *Workflow) ListArtifacts(jobName string, groupName string) ([]client.Artifact, error) {
+	return w.build.ListAllArtifacts(w.GetName().String(), jobName, groupName)
+}
+
+// MustListArtifacts reads information about the artifacts produced by jobs in this workflow.
+// Terminates this program if a persistent error occurs.
+func (w *Workflow) MustListArtifacts(jobName string, groupName string) []client.Artifact {
+	artifacts, err := w.ListArtifacts(jobName, groupName)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return artifacts
+}
+
+// ReadArtifactData returns a reader for fetching the binary data for an artifact. The caller must close
+// the reader when finished with it.
+func (w *Workflow) ReadArtifactData(artifact client.Artifact) (io.ReadCloser, error) {
+	return w.build.ReadArtifactData(artifact.Id)
+}
+
+// MustReadArtifactData returns a reader for fetching the binary data for an artifact.
+// Terminates this program if a persistent error occurs.
+func (w *Workflow) MustReadArtifactData(artifact client.Artifact) io.ReadCloser {
+	return w.build.MustReadArtifactData(artifact.Id)
+}
+
+// DownloadArtifacts downloads the artifacts produced by jobs in this workflow to files under the specified
+// directory. Each artifact is written to the path it was found at relative to its job's workspace, so
+// dir takes the place of the workspace. An empty job name matches every job in the workflow, and an empty
+// group name matches every artifact group.
+// Returns the paths of the downloaded files. Returns an error if any artifact has not finished uploading, or
+// if two of the artifacts have the same path.
+func (w *Workflow) DownloadArtifacts(jobName string, groupName string, dir string) ([]string, error) {
+	artifacts, err := w.ListArtifacts(jobName, groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths := make([]string, 0, len(artifacts))
+	artifactsByPath := make(map[string]client.Artifact, len(artifacts))
+	for _, artifact := range artifacts {
+		if !artifact.Sealed {
+			return nil, fmt.Errorf("error artifact '%s' (ID %s) has not finished uploading", artifact.Name, artifact.Id)
+		}
+		// Clean the path as if rooted at the workspace, so an artifact can't be written outside dir
+		filePath := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+artifact.Path)))
+		if existing, ok := artifactsByPath[filePath]; ok {
+			return nil, fmt.Errorf("error artifacts with IDs %s and %s would both be downloaded to '%s'",
+				existing.Id, artifact.Id, filePath)
+		}
+		artifactsByPath[filePath] = artifact
+		filePaths = append(filePaths, filePath)
+	}
+
+	for i, artifact := range artifacts {
+		err = w.build.DownloadArtifact(artifact.Id, filePaths[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filePaths, nil
+}
+
+// MustDownloadArtifacts downloads the artifacts produced by jobs in this workflow to files under the specified
+// directory. See DownloadArtifacts for details.
+// Terminates this program if a persistent error occurs.
+func (w *Workflow) MustDownloadArtifacts(jobName string, groupName string, dir string) []string {
+	filePaths, err := w.DownloadArtifacts(jobName, groupName, dir)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return filePaths
+}
+
+// tempFileReader reads from a temporary file, and removes the file when closed.
+type tempFileReader struct {
+	*os.File
+}
+
+func (r *tempFileReader) Close() error {
+	err := r.File.Close()
+	removeErr := os.Remove(r.File.Name())
+	if err != nil {
+		return err
+	}
+	return removeErr
+}
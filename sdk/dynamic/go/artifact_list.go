@@ -71,6 +71,18 @@ func NewBuildApiListArtifactsRequest(b *Build, workflow string, jobName string,
 	return &request
 }
 
+func NewBuildApiListArtifactsAtRequest(b *Build, path string, pageSize int) *client.ApiListArtifactsRequest {
+	buildAPI := b.apiClient.BuildApi
+
+	Log(LogLevelInfo, fmt.Sprintf("Fetching artifact information, path '%s' (page size %d)", path, pageSize))
+
+	request := buildAPI.ListArtifacts(b.GetAuthorizedContext(), b.ID.String()).
+		Path(path).
+		Limit(int32(pageSize))
+
+	return &request
+}
+
 func ListArtifacts(b *Build, request *client.ApiListArtifactsRequest) (*ArtifactPage, error) {
 
 	artifactsResult, response, err := request.Execute()
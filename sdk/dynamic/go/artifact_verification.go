@@ -0,0 +1,124 @@
+package bb
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// VerifyArtifact asks the server to check that the data it holds for an artifact still matches the SHA-256 hash
+// recorded when the artifact was uploaded, and that the server's signature over the hash is valid if the artifact
+// was signed. Returns an error if the artifact fails verification.
+func (b *Build) VerifyArtifact(artifactID string) (*client.ArtifactVerification, error) {
+	Log(LogLevelInfo, fmt.Sprintf("Verifying artifact with ID %s", artifactID))
+	buildAPI := b.apiClient.BuildApi
+
+	verification, response, err := buildAPI.GetArtifactVerification(b.GetAuthorizedContext(), artifactID).Execute()
+	var statusCode int
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	if err != nil {
+		openAPIErr, ok := err.(*client.GenericOpenAPIError)
+		if ok {
+			return nil, fmt.Errorf("error verifying artifact on server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
+		}
+		return nil, fmt.Errorf("error verifying artifact on server (response status code %d): %w", statusCode, err)
+	}
+	if !verification.GetDataValid() {
+		return nil, fmt.Errorf("error artifact %s failed verification: data has SHA-256 %s but %s was recorded on upload",
+			artifactID, verification.GetCalculatedSha256(), verification.GetSha256())
+	}
+	if verification.GetSigned() {
+		if !verification.GetSignatureValid() {
+			return nil, fmt.Errorf("error artifact %s failed verification: signature is not valid", artifactID)
+		}
+		// Check the signature locally as well, rather than relying solely on the server's result
+		if verification.GetSigningCertificate() != "" {
+			err = checkArtifactSignature(artifactID, verification.GetSha256(), verification.GetSignature(), verification.GetSigningCertificate())
+			if err != nil {
+				return nil, fmt.Errorf("error artifact %s failed verification: %w", artifactID, err)
+			}
+		}
+	}
+	Log(LogLevelInfo, fmt.Sprintf("Verified artifact with ID %s (SHA-256 %s, signed: %t)", artifactID, verification.GetSha256(), verification.GetSigned()))
+
+	return verification, nil
+}
+
+// MustVerifyArtifact asks the server to check that an artifact has not been tampered with.
+// See VerifyArtifact for details.
+// Terminates this program if the artifact fails verification or a persistent error occurs.
+func (b *Build) MustVerifyArtifact(artifactID string) *client.ArtifactVerification {
+	verification, err := b.VerifyArtifact(artifactID)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return verification
+}
+
+// GetVerifiedArtifactData returns the binary data for an artifact, after verifying the artifact with the server
+// and checking that the downloaded data matches the artifact's verified SHA-256 hash.
+func (b *Build) GetVerifiedArtifactData(artifactID string) ([]byte, error) {
+	verification, err := b.VerifyArtifact(artifactID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.GetArtifactData(artifactID)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	calculated := hex.EncodeToString(hash[:])
+	if calculated != verification.GetSha256() {
+		return nil, fmt.Errorf("error downloaded data for artifact %s has SHA-256 %s but %s was expected",
+			artifactID, calculated, verification.GetSha256())
+	}
+	return data, nil
+}
+
+// MustGetVerifiedArtifactData returns the binary data for an artifact, after checking it has not been tampered with.
+// See GetVerifiedArtifactData for details.
+// Terminates this program if the artifact fails verification or a persistent error occurs.
+func (b *Build) MustGetVerifiedArtifactData(artifactID string) []byte {
+	data, err := b.GetVerifiedArtifactData(artifactID)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return data
+}
+
+// checkArtifactSignature checks a base64-encoded Ed25519 signature over an artifact's SHA-256 hash, using the
+// public key in the supplied PEM-encoded certificate.
+func checkArtifactSignature(artifactID string, sha256Hex string, signature string, certificatePEM string) error {
+	block, _ := pem.Decode([]byte(certificatePEM))
+	if block == nil {
+		return fmt.Errorf("signing certificate is not valid PEM data")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing signing certificate: %w", err)
+	}
+	publicKey, ok := certificate.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("signing certificate does not hold an Ed25519 public key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("error decoding signature: %w", err)
+	}
+	payload := []byte(fmt.Sprintf("buildbeaver-artifact:%s:sha256:%s", artifactID, sha256Hex))
+	if !ed25519.Verify(publicKey, payload, decoded) {
+		return fmt.Errorf("signature is not valid")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package bb
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -48,6 +49,12 @@ type Build struct {
 	// workflowsToRun is a static list of workflows that were requested to be run when the build was queued;
 	// an empty list means run all workflows
 	WorkflowsToRun []ResourceName
+	// WorkflowOutputStubs contains stubbed values for the outputs of workflows that should not be run, keyed
+	// by workflow name and then output name. Each value is the JSON encoding of the output.
+	WorkflowOutputStubs map[ResourceName]map[string]json.RawMessage
+	// Parameters contains the value of each parameter declared in the build definition, keyed by parameter name.
+	// Values were either supplied when the build was triggered or are the parameter's default.
+	Parameters map[string]string
 
 	// internal fields
 	eventManager  *EventManager
@@ -268,6 +275,7 @@ func (b *Build) MustGetJob(jobID JobID) *client.Job {
 }
 
 // ListArtifacts reads information about selected artifacts from the current build.
+// An empty workflow matches jobs in any workflow; use ListArtifactsAt to only match jobs in the default workflow.
 // The first page of results will be returned in an ArtifactPage object.
 // Call Next() on the returned object to get the next page of results, or Prev() to get the previous page.
 func (b *Build) ListArtifacts(workflow string, jobName string, groupName string) (*ArtifactPage, error) {
@@ -309,26 +317,38 @@ func (b *Build) MustListArtifactsN(workflow string, jobName string, groupName st
 	return res
 }
 
-// GetArtifactData returns the binary data for an artifact.
-func (b *Build) GetArtifactData(artifactID string) ([]byte, error) {
-	Log(LogLevelInfo, fmt.Sprintf("Fetching artifact from server for ID %s", artifactID))
-	buildAPI := b.apiClient.BuildApi
+// ListArtifactsAt reads information about the artifacts at the specified path from the current build. The path
+// has the format 'workflow.job.artifact', or 'job.artifact' for a job in the default workflow; the artifact
+// name can be '*' to read all artifacts produced by the job.
+// The first page of results will be returned in an ArtifactPage object.
+// Call Next() on the returned object to get the next page of results, or Prev() to get the previous page.
+func (b *Build) ListArtifactsAt(path string) (*ArtifactPage, error) {
+	request := NewBuildApiListArtifactsAtRequest(b, path, 30)
+	return ListArtifacts(b, request)
+}
 
-	artifactFile, response, err := buildAPI.GetArtifactData(b.GetAuthorizedContext(), artifactID).Execute()
-	var statusCode int
-	if response != nil {
-		statusCode = response.StatusCode
+// MustListArtifactsAt reads information about the artifacts at the specified path from the current build.
+// See ListArtifactsAt for the format of the path.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustListArtifactsAt(path string) *ArtifactPage {
+	res, err := b.ListArtifactsAt(path)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
 	}
+	return res
+}
+
+// GetArtifactData returns the binary data for an artifact.
+func (b *Build) GetArtifactData(artifactID string) ([]byte, error) {
+	reader, err := b.ReadArtifactData(artifactID)
 	if err != nil {
-		openAPIErr, ok := err.(*client.GenericOpenAPIError)
-		if ok {
-			return nil, fmt.Errorf("error fetching artifact data from server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
-		}
-		return nil, fmt.Errorf("error fetching artifact data from server (response status code %d): %w", statusCode, err)
+		return nil, err
 	}
+	defer reader.Close()
 	Log(LogLevelInfo, fmt.Sprintf("Received artifact data back from server"))
 
-	data, err := io.ReadAll(artifactFile)
+	data, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file to fetch artifact data: %w", err)
 	}
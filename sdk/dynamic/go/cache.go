@@ -0,0 +1,65 @@
+package bb
+
+import (
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// Cache declares that one or more paths in the job's workspace should be restored from the build cache
+// before the job's steps run, and saved to the build cache once the job has succeeded.
+type Cache struct {
+	definition client.CacheDefinition
+}
+
+func NewCache() *Cache {
+	return &Cache{definition: client.CacheDefinition{}}
+}
+
+// CacheGoModules returns a cache for the Go module cache, keyed by the contents of every go.sum file in
+// the repo. Go is pointed at the cache automatically.
+func CacheGoModules() *Cache {
+	return NewCache().Preset("go-modules")
+}
+
+// CacheNodeModules returns a cache for the node_modules directory at the root of the repo, keyed by the
+// contents of the repo's lock files (yarn.lock, package-lock.json or pnpm-lock.yaml).
+func CacheNodeModules() *Cache {
+	return NewCache().Preset("node-modules")
+}
+
+func (c *Cache) GetData() client.CacheDefinition {
+	return c.definition
+}
+
+func (c *Cache) GetName() ResourceName {
+	if c.definition.Name != nil {
+		return ResourceName(*c.definition.Name)
+	}
+	if c.definition.Preset != nil {
+		return ResourceName(*c.definition.Preset)
+	}
+	return ""
+}
+
+// Name sets the name of the cache; caches with the same name are shared by all jobs in the repo.
+func (c *Cache) Name(name string) *Cache {
+	c.definition.Name = &name
+	return c
+}
+
+// Paths sets the paths relative to the checkout directory to save and restore.
+func (c *Cache) Paths(paths ...string) *Cache {
+	c.definition.Paths = paths
+	return c
+}
+
+// Key sets the shell commands whose output is hashed to produce the cache key.
+func (c *Cache) Key(commands ...string) *Cache {
+	c.definition.Key = commands
+	return c
+}
+
+// Preset creates the cache from a predefined cache; any other fields set on the cache override the preset's.
+func (c *Cache) Preset(preset string) *Cache {
+	c.definition.Preset = &preset
+	return c
+}
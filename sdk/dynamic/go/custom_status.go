@@ -0,0 +1,73 @@
+package bb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// CustomStatusState is the outcome reported by a custom status.
+type CustomStatusState string
+
+func (s CustomStatusState) String() string {
+	return string(s)
+}
+
+const (
+	// CustomStatusPending indicates the check the status describes has not yet reached a conclusion.
+	CustomStatusPending CustomStatusState = "pending"
+	// CustomStatusSuccess indicates the check the status describes passed.
+	CustomStatusSuccess CustomStatusState = "success"
+	// CustomStatusFailure indicates the check the status describes did not pass.
+	CustomStatusFailure CustomStatusState = "failure"
+	// CustomStatusError indicates the check the status describes could not be performed.
+	CustomStatusError CustomStatusState = "error"
+)
+
+// PublishCustomStatus publishes an additional named status to the build, e.g. "performance-regression".
+// The status is relayed to the SCM separately from the overall build status, so it can be used in branch
+// protection rules. Publishing a status with the same name again replaces the previous status.
+// description and targetURL are optional and can be empty.
+func (b *Build) PublishCustomStatus(name ResourceName, state CustomStatusState, description string, targetURL string) (*client.CustomStatus, error) {
+	Log(LogLevelInfo, fmt.Sprintf("Publishing custom status %s with state %s for build %s", name, state, b.ID))
+	buildAPI := b.apiClient.BuildApi
+
+	definition := client.NewCustomStatusDefinition(name.String(), state.String())
+	if description != "" {
+		definition.SetDescription(description)
+	}
+	if targetURL != "" {
+		definition.SetTargetUrl(targetURL)
+	}
+
+	customStatus, response, err := buildAPI.PublishCustomStatus(b.GetAuthorizedContext(), b.ID.String()).
+		CustomStatusDefinition(*definition).
+		Execute()
+	var statusCode int
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	if err != nil {
+		openAPIErr, ok := err.(*client.GenericOpenAPIError)
+		if ok {
+			return nil, fmt.Errorf("error publishing custom status to server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
+		}
+		return nil, fmt.Errorf("error publishing custom status to server (response status code %d): %w", statusCode, err)
+	}
+	Log(LogLevelInfo, fmt.Sprintf("Published custom status %s", name))
+
+	return customStatus, nil
+}
+
+// MustPublishCustomStatus publishes an additional named status to the build, e.g. "performance-regression".
+// See PublishCustomStatus for details.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustPublishCustomStatus(name ResourceName, state CustomStatusState, description string, targetURL string) *client.CustomStatus {
+	customStatus, err := b.PublishCustomStatus(name, state, description, targetURL)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return customStatus
+}
@@ -1,6 +1,8 @@
 package bb
 
 import (
+	"encoding/json"
+
 	"github.com/buildbeaver/sdk/dynamic/bb/client"
 )
 
@@ -16,11 +18,31 @@ func (config *DockerConfig) GetData() client.DockerConfigDefinition {
 	return config.definition
 }
 
+// MarshalJSON encodes the Docker config as its definition, so that it can be passed between builds
+// (e.g. as a stubbed workflow output).
+func (config *DockerConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(config.definition)
+}
+
+// UnmarshalJSON decodes a Docker config from the JSON encoding of its definition.
+func (config *DockerConfig) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &config.definition)
+}
+
 func (config *DockerConfig) Image(image string) *DockerConfig {
 	config.definition.Image = image
 	return config
 }
 
+// Toolchain runs the job in a toolchain registered with the server (e.g. by an earlier job via
+// Build.RegisterToolchain), referring to it by name and version in the format "name@version", e.g.
+// "go-builder@1.4". The server resolves the reference to the toolchain's image when the job is run, so
+// Image does not need to be set.
+func (config *DockerConfig) Toolchain(ref string) *DockerConfig {
+	config.definition.Toolchain = &ref
+	return config
+}
+
 func (config *DockerConfig) Pull(pullStrategy DockerPullStrategy) *DockerConfig {
 	config.definition.Pull = pullStrategy.String()
 	return config
@@ -170,6 +170,27 @@ func (m *EventManager) GetStatsForWorkflow(workflowName ResourceName) WorkflowSt
 	}
 }
 
+// waitForWorkflowStats waits until done returns true for the statistics for the specified workflow, and returns
+// those statistics. If the event manager is stopped while waiting then the latest statistics are returned.
+func (m *EventManager) waitForWorkflowStats(workflowName ResourceName, done func(stats WorkflowStats) bool) WorkflowStats {
+	// Subscribe before checking the stats so that no updates are missed; the stats are always updated
+	// from new events before subscribers are notified of them
+	subscriberID, newEventsCh := m.subscribeForNewEvents()
+	defer m.unsubscribeFromNewEvents(subscriberID)
+
+	for {
+		stats := m.GetStatsForWorkflow(workflowName)
+		if done(stats) {
+			return stats
+		}
+		_, ok := <-newEventsCh
+		if !ok {
+			Log(LogLevelDebug, fmt.Sprintf("Event manager stopped while waiting for stats for workflow '%s'", workflowName))
+			return m.GetStatsForWorkflow(workflowName)
+		}
+	}
+}
+
 // GetJobRefOrNilFromEvent finds and returns a job reference from the specified event, or nil if the event has
 // no job reference.
 func GetJobRefOrNilFromEvent(event *client.Event) *JobReference {
@@ -25,7 +25,10 @@ var frontendJobFingerprint = []string{
 
 func main() {
 	bb.Workflows(
-		bb.NewWorkflow().Name("base").Handler(submitBaseJobs),
+		bb.NewWorkflow().
+			Name("base").
+			Handler(submitBaseJobs).
+			Output("go-docker-config", bb.NewDocker()),
 		bb.NewWorkflow().
 			Name("generate").
 			Handler(submitGenerateJobs).
@@ -69,11 +72,11 @@ func submitBaseJobs(w *bb.Workflow) error {
 					Region("us-west-2").
 					AccessKeyIDFromSecret("AWS_ACCESS_KEY_ID").
 					SecretAccessKeyFromSecret("AWS_SECRET_ACCESS_KEY"))
-			w.SetOutput("go-docker-config", goDockerConfig)
+			bb.MustSetOutputAs(w, "go-docker-config", goDockerConfig)
 		}))
 	w.MustSubmit()
 
-	goDockerConfig := w.MustWaitForOutput("base", "go-docker-config").(*bb.DockerConfig)
+	goDockerConfig := bb.MustWaitForOutputAs[*bb.DockerConfig](w, "base", "go-docker-config")
 
 	w.Job(bb.NewJob().
 		Name("backend-preflight").
@@ -89,7 +92,7 @@ func submitBaseJobs(w *bb.Workflow) error {
 }
 
 func submitGenerateJobs(w *bb.Workflow) error {
-	goDockerConfig := w.MustWaitForOutput("base", "go-docker-config").(*bb.DockerConfig)
+	goDockerConfig := bb.MustWaitForOutputAs[*bb.DockerConfig](w, "base", "go-docker-config")
 
 	w.Job(bb.NewJob().
 		Name("backend-generate").
@@ -111,7 +114,7 @@ func submitGenerateJobs(w *bb.Workflow) error {
 }
 
 func submitUnitTestJobs(w *bb.Workflow) error {
-	goDockerConfig := w.MustWaitForOutput("base", "go-docker-config").(*bb.DockerConfig)
+	goDockerConfig := bb.MustWaitForOutputAs[*bb.DockerConfig](w, "base", "go-docker-config")
 
 	w.Job(bb.NewJob().
 		Name("backend-sqlite").
@@ -162,7 +165,7 @@ func submitUnitTestJobs(w *bb.Workflow) error {
 }
 
 func submitIntegrationTestJobs(w *bb.Workflow) error {
-	goDockerConfig := w.MustWaitForOutput("base", "go-docker-config").(*bb.DockerConfig)
+	goDockerConfig := bb.MustWaitForOutputAs[*bb.DockerConfig](w, "base", "go-docker-config")
 
 	w.Job(bb.NewJob().
 		Name("backend-sqlite").
@@ -213,7 +216,7 @@ func submitIntegrationTestJobs(w *bb.Workflow) error {
 }
 
 func submitBuildJobs(w *bb.Workflow) error {
-	goDockerConfig := w.MustWaitForOutput("base", "go-docker-config").(*bb.DockerConfig)
+	goDockerConfig := bb.MustWaitForOutputAs[*bb.DockerConfig](w, "base", "go-docker-config")
 
 	w.Job(bb.NewJob().
 		Name("backend-build").
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/buildbeaver/sdk/dynamic/bb/client"
 )
@@ -69,6 +70,8 @@ type Job struct {
 	definition client.JobDefinition
 	// Workflow this job was added to, if any
 	workflow *Workflow
+	// Platform this job was created for by ForEachPlatform, if any
+	platform *Platform
 	// completionCallbacksToRegister is a list of callback functions to register once this job is part of a build
 	completionCallbacksToRegister []JobCallback
 	// successCallbacksToRegister is a list of callback functions to register once this job is part of a build
@@ -92,6 +95,19 @@ const (
 	StepExecutionParallel   StepExecutionType = "parallel"
 )
 
+// OperatingSystem is an operating system that a job can require the runner it runs on to be running.
+type OperatingSystem string
+
+func (os OperatingSystem) String() string {
+	return string(os)
+}
+
+const (
+	OperatingSystemLinux   OperatingSystem = "linux"
+	OperatingSystemWindows OperatingSystem = "windows"
+	OperatingSystemMacOS   OperatingSystem = "macos"
+)
+
 type JobType string
 
 func (t JobType) String() string {
@@ -183,11 +199,87 @@ func (job *Job) Docker(dockerConfig *DockerConfig) *Job {
 	return job
 }
 
+// Shell sets the shell to run the job's steps with: one of sh, bash, cmd, powershell or pwsh, or the path to
+// a shell. Applies to exec jobs, and to docker jobs whose Docker config doesn't set a shell.
+func (job *Job) Shell(shell string) *Job {
+	job.definition.Shell = &shell
+	return job
+}
+
+// OperatingSystem requires the job to run on a runner running the specified operating system. Jobs whose shell
+// is cmd or powershell, or whose Docker image is an official Windows image, only run on Windows runners even if
+// no operating system is set.
+func (job *Job) OperatingSystem(os OperatingSystem) *Job {
+	str := os.String()
+	job.definition.OperatingSystem = &str
+	return job
+}
+
+// Resources sets the CPU, memory, disk space and GPUs the job requires from the runner it runs on.
+func (job *Job) Resources(resources *Resources) *Job {
+	data := resources.GetData()
+	job.definition.Resources = &data
+	return job
+}
+
+// Timeout sets the maximum time the job may take, from when it is queued until it finishes, before it is
+// failed. Overrides the server's default job timeout.
+func (job *Job) Timeout(timeout time.Duration) *Job {
+	str := timeout.String()
+	job.definition.Timeout = &str
+	return job
+}
+
 func (job *Job) StepExecution(executionType StepExecutionType) *Job {
 	job.definition.StepExecution = executionType.String()
 	return job
 }
 
+// Checkout determines whether the repo is cloned into the job's workspace before its steps run. Defaults to true.
+// Pass false for jobs that don't need the source code (e.g. notification or artifact promotion jobs), so the
+// runner skips the clone and doesn't set up an SSH agent with the repo's SSH key.
+func (job *Job) Checkout(checkout bool) *Job {
+	job.definition.Checkout = &checkout
+	return job
+}
+
+// RetryOnRunnerLoss determines whether the job is queued to run again, rather than failed, if the runner running
+// the job stops sending heartbeats (e.g. because its host crashed). Defaults to false. Only use this for jobs
+// that are safe to run more than once.
+func (job *Job) RetryOnRunnerLoss(retry bool) *Job {
+	job.definition.RetryOnRunnerLoss = &retry
+	return job
+}
+
+// PinSecrets determines whether the job is given the versions of the repo's secrets that were current when the
+// build started, rather than the latest versions when the job runs. Defaults to false. Use this to make sure
+// every job in a build sees the same secrets even if a secret is rotated while the build is running.
+func (job *Job) PinSecrets(pin bool) *Job {
+	job.definition.PinSecrets = &pin
+	return job
+}
+
+// If sets a condition that must be true for the job to run, e.g. 'branch == "main"'. If the condition is false
+// when the job is enqueued then the job, and any jobs that depend on it, are skipped.
+func (job *Job) If(condition string) *Job {
+	job.definition.If = &condition
+	return job
+}
+
+// OnlyPaths sets path patterns (supporting '**') that restrict when the job runs. The job is skipped unless at
+// least one of the files changed by the commit being built matches one of the patterns.
+func (job *Job) OnlyPaths(patterns ...string) *Job {
+	job.definition.OnlyPaths = append(job.definition.OnlyPaths, patterns...)
+	return job
+}
+
+// IgnorePaths sets path patterns (supporting '**') for files that should not cause the job to run. The job is
+// skipped if every file changed by the commit being built matches one of the patterns.
+func (job *Job) IgnorePaths(patterns ...string) *Job {
+	job.definition.IgnorePaths = append(job.definition.IgnorePaths, patterns...)
+	return job
+}
+
 func (job *Job) Depends(dependencies ...string) *Job {
 	job.definition.Depends = append(job.definition.Depends, dependencies...)
 	return job
@@ -208,6 +300,14 @@ func (job *Job) DependsOnJobArtifacts(jobs ...*Job) *Job {
 	return job
 }
 
+// ArtifactFrom declares that the job depends on artifacts produced by previous builds. Each reference has the
+// format '[repo@]build-<number>[:group]', e.g. 'my-repo@build-123:go-binaries'. The artifacts are downloaded
+// into the job's workspace before its steps run.
+func (job *Job) ArtifactFrom(references ...string) *Job {
+	job.definition.ArtifactFrom = append(job.definition.ArtifactFrom, references...)
+	return job
+}
+
 func (job *Job) Env(env *Env) *Job {
 	def := client.SecretStringDefinition{Value: &env.value}
 	if env.secretName != "" {
@@ -241,6 +341,14 @@ func (job *Job) Artifact(artifact *Artifact) *Job {
 	return job
 }
 
+func (job *Job) Cache(cache *Cache) *Job {
+	job.definition.Caches = append(job.definition.Caches, cache.GetData())
+	Log(LogLevelInfo, fmt.Sprintf("Cache with name '%s' added for job '%s'", cache.GetName(), job.GetReference()))
+	return job
+}
+
+// OnCompletion will call a callback function when the job has finished, whatever its final status.
+// This is the place to submit teardown jobs that must run regardless of whether the job succeeded.
 func (job *Job) OnCompletion(fn JobCallback) *Job {
 	if job.workflow != nil {
 		job.workflow.OnJobCompletion(job.GetReference(), fn)
@@ -250,6 +358,7 @@ func (job *Job) OnCompletion(fn JobCallback) *Job {
 	return job
 }
 
+// OnSuccess will call a callback function if the job finishes successfully.
 func (job *Job) OnSuccess(fn JobCallback) *Job {
 	if job.workflow != nil {
 		job.workflow.OnJobSuccess(job.GetReference(), fn)
@@ -259,6 +368,7 @@ func (job *Job) OnSuccess(fn JobCallback) *Job {
 	return job
 }
 
+// OnFailure will call a callback function if the job fails.
 func (job *Job) OnFailure(fn JobCallback) *Job {
 	if job.workflow != nil {
 		job.workflow.OnJobFailure(job.GetReference(), fn)
@@ -268,15 +378,23 @@ func (job *Job) OnFailure(fn JobCallback) *Job {
 	return job
 }
 
-func (job *Job) OnCancelled(fn JobCallback) *Job {
+// OnCanceled will call a callback function if the job is canceled.
+func (job *Job) OnCanceled(fn JobCallback) *Job {
 	if job.workflow != nil {
-		job.workflow.OnJobCancelled(job.GetReference(), fn)
+		job.workflow.OnJobCanceled(job.GetReference(), fn)
 	} else {
 		job.cancelledCallbacksToRegister = append(job.cancelledCallbacksToRegister, fn)
 	}
 	return job
 }
 
+// OnCancelled will call a callback function if the job is canceled.
+//
+// Deprecated: use OnCanceled, which matches the spelling of StatusCanceled.
+func (job *Job) OnCancelled(fn JobCallback) *Job {
+	return job.OnCanceled(fn)
+}
+
 // OnStatusChanged will call a callback function each time the status of the job changes.
 func (job *Job) OnStatusChanged(fn JobCallback) *Job {
 	if job.workflow != nil {
@@ -300,6 +418,22 @@ func (job *Job) getWorkflowDependencies() []ResourceName {
 	return workflows
 }
 
+// removeDependenciesOnStubbedWorkflows removes any dependencies the job has on jobs in stubbed workflows,
+// since stubbed workflows are never run and so their jobs will never be created.
+func (job *Job) removeDependenciesOnStubbedWorkflows() {
+	var depends []string
+	for _, dependencyStr := range job.definition.Depends {
+		workflow, _ := workflowDependencyFromString(dependencyStr)
+		if workflow != "" && globalWorkflowManager.isWorkflowStubbed(workflow) {
+			Log(LogLevelInfo, fmt.Sprintf("Ignoring dependency '%s' of job '%s' on stubbed workflow '%s'",
+				dependencyStr, job.GetReference(), workflow))
+			continue
+		}
+		depends = append(depends, dependencyStr)
+	}
+	job.definition.Depends = depends
+}
+
 // TODO: Find a way to not need to include this parsing detail in every dynamic SDK. Simplify it?
 var (
 	jobDependsOnOneArtifactFromJobRegex03           = regexp.MustCompile(`(?im)^(?:workflow\.([a-zA-Z0-9_-]+)\.)?jobs\.([a-zA-Z0-9_*-]+)\.artifacts\.([a-zA-Z0-9_-]+)$`)
@@ -0,0 +1,188 @@
+package bb
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// JobTemplate captures settings that are common to a number of jobs, such as the Docker config, fingerprint
+// commands and environment variables, so that they don't need to be repeated for every job.
+// A template records each setting and applies it to every job created from the template via NewJobFrom, or to
+// every job in a workflow that uses the template as its defaults via WorkflowDefinition.WithDefaults.
+// Settings are applied in the order they were made, so a template can include steps as well as job settings.
+type JobTemplate struct {
+	setters []func(job *Job)
+}
+
+func NewJobTemplate() *JobTemplate {
+	return &JobTemplate{}
+}
+
+// NewJobFrom creates a new job with all the settings from the specified template applied. Further settings
+// can be made on the returned job in the usual way, and will be combined with or override the template's
+// settings in the same way as calling the job's methods a second time.
+func NewJobFrom(template *JobTemplate) *Job {
+	job := NewJob()
+	template.applyTo(job)
+	return job
+}
+
+// Copy returns a new template with the same settings as this template, that further settings can be added to
+// without affecting this template.
+func (t *JobTemplate) Copy() *JobTemplate {
+	setters := make([]func(job *Job), len(t.setters))
+	copy(setters, t.setters)
+	return &JobTemplate{setters: setters}
+}
+
+func (t *JobTemplate) applyTo(job *Job) {
+	for _, setter := range t.setters {
+		setter(job)
+	}
+}
+
+func (t *JobTemplate) add(setter func(job *Job)) *JobTemplate {
+	t.setters = append(t.setters, setter)
+	return t
+}
+
+func (t *JobTemplate) Desc(description string) *JobTemplate {
+	return t.add(func(job *Job) { job.Desc(description) })
+}
+
+func (t *JobTemplate) Type(jobType JobType) *JobTemplate {
+	return t.add(func(job *Job) { job.Type(jobType) })
+}
+
+func (t *JobTemplate) RunsOn(labels ...string) *JobTemplate {
+	return t.add(func(job *Job) { job.RunsOn(labels...) })
+}
+
+func (t *JobTemplate) Docker(dockerConfig *DockerConfig) *JobTemplate {
+	return t.add(func(job *Job) { job.Docker(dockerConfig) })
+}
+
+func (t *JobTemplate) Shell(shell string) *JobTemplate {
+	return t.add(func(job *Job) { job.Shell(shell) })
+}
+
+func (t *JobTemplate) Resources(resources *Resources) *JobTemplate {
+	return t.add(func(job *Job) { job.Resources(resources) })
+}
+
+func (t *JobTemplate) Timeout(timeout time.Duration) *JobTemplate {
+	return t.add(func(job *Job) { job.Timeout(timeout) })
+}
+
+func (t *JobTemplate) StepExecution(executionType StepExecutionType) *JobTemplate {
+	return t.add(func(job *Job) { job.StepExecution(executionType) })
+}
+
+func (t *JobTemplate) Checkout(checkout bool) *JobTemplate {
+	return t.add(func(job *Job) { job.Checkout(checkout) })
+}
+
+func (t *JobTemplate) RetryOnRunnerLoss(retry bool) *JobTemplate {
+	return t.add(func(job *Job) { job.RetryOnRunnerLoss(retry) })
+}
+
+func (t *JobTemplate) PinSecrets(pin bool) *JobTemplate {
+	return t.add(func(job *Job) { job.PinSecrets(pin) })
+}
+
+func (t *JobTemplate) If(condition string) *JobTemplate {
+	return t.add(func(job *Job) { job.If(condition) })
+}
+
+func (t *JobTemplate) OnlyPaths(patterns ...string) *JobTemplate {
+	return t.add(func(job *Job) { job.OnlyPaths(patterns...) })
+}
+
+func (t *JobTemplate) IgnorePaths(patterns ...string) *JobTemplate {
+	return t.add(func(job *Job) { job.IgnorePaths(patterns...) })
+}
+
+func (t *JobTemplate) Depends(dependencies ...string) *JobTemplate {
+	return t.add(func(job *Job) { job.Depends(dependencies...) })
+}
+
+func (t *JobTemplate) ArtifactFrom(references ...string) *JobTemplate {
+	return t.add(func(job *Job) { job.ArtifactFrom(references...) })
+}
+
+func (t *JobTemplate) Env(env *Env) *JobTemplate {
+	return t.add(func(job *Job) { job.Env(env) })
+}
+
+func (t *JobTemplate) Fingerprint(commands ...string) *JobTemplate {
+	return t.add(func(job *Job) { job.Fingerprint(commands...) })
+}
+
+func (t *JobTemplate) Step(step *Step) *JobTemplate {
+	return t.add(func(job *Job) { job.Step(step) })
+}
+
+func (t *JobTemplate) Service(service *Service) *JobTemplate {
+	return t.add(func(job *Job) { job.Service(service) })
+}
+
+func (t *JobTemplate) Artifact(artifact *Artifact) *JobTemplate {
+	return t.add(func(job *Job) { job.Artifact(artifact) })
+}
+
+func (t *JobTemplate) Cache(cache *Cache) *JobTemplate {
+	return t.add(func(job *Job) { job.Cache(cache) })
+}
+
+// applyDefaults fills in each setting the job has not set with the corresponding setting from defaults, which
+// is the definition of a job created from a template. Settings the job has made, even to an empty value such as
+// Checkout(false), are kept. Environment variables are merged, with the job's own value kept for any variable
+// both set.
+func (job *Job) applyDefaults(defaults client.JobDefinition) {
+	if job.definition.Type != nil && *job.definition.Type != JobTypeDocker.String() {
+		defaults.Docker = nil // a Docker config only applies to docker jobs
+	}
+	jobValue := reflect.ValueOf(&job.definition).Elem()
+	defaultsValue := reflect.ValueOf(defaults)
+	for i := 0; i < jobValue.NumField(); i++ {
+		switch jobValue.Type().Field(i).Name {
+		case "Name", "Workflow", "AdditionalProperties":
+			continue // these identify the job rather than configure it
+		}
+		field := jobValue.Field(i)
+		defaultField := defaultsValue.Field(i)
+		switch {
+		case field.Kind() == reflect.Map:
+			if field.IsNil() && defaultField.Len() > 0 {
+				field.Set(reflect.MakeMap(field.Type()))
+			}
+			iter := defaultField.MapRange()
+			for iter.Next() {
+				if !field.MapIndex(iter.Key()).IsValid() {
+					field.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+		case !isUnset(field):
+			// keep the job's own setting
+		case field.Kind() == reflect.Slice:
+			// copy the list so that jobs sharing the defaults can't modify each other's lists
+			field.Set(reflect.AppendSlice(reflect.MakeSlice(field.Type(), 0, defaultField.Len()), defaultField))
+		default:
+			field.Set(defaultField)
+		}
+	}
+}
+
+// isUnset returns true if a job definition field has not been set: a nil pointer, an empty list or an empty string.
+func isUnset(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return field.IsNil()
+	case reflect.Slice:
+		return field.Len() == 0
+	default:
+		return field.IsZero()
+	}
+}
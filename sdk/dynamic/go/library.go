@@ -1,6 +1,7 @@
 package bb
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"golang.org/x/net/context"
@@ -74,6 +75,22 @@ func parseEnv(env envGetter) (*Build, error) {
 
 	build := newBuild(buildID, buildName, buildOwnerName, buildRefStr, dynamicJobID, dynamicJobName, dynamicAPIURL, accessToken, workflowsToRun)
 
+	workflowOutputStubsStr := env("BB_WORKFLOW_OUTPUT_STUBS")
+	if workflowOutputStubsStr != "" {
+		err = json.Unmarshal([]byte(workflowOutputStubsStr), &build.WorkflowOutputStubs)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing stubbed workflow outputs: %w", err)
+		}
+	}
+
+	buildParametersStr := env("BB_BUILD_PARAMETERS")
+	if buildParametersStr != "" {
+		err = json.Unmarshal([]byte(buildParametersStr), &build.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing build parameters: %w", err)
+		}
+	}
+
 	commitSHAStr := env("BB_COMMIT_SHA")
 	if commitSHAStr == "" {
 		return nil, fmt.Errorf("error: Commit SHA must be provided")
@@ -0,0 +1,50 @@
+package bb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// GetOIDCToken asks the server to issue a short-lived OpenID Connect ID token identifying this job, for the
+// specified audience (e.g. "sts.amazonaws.com"; if empty the server's issuer URL is used). The token can be
+// exchanged for credentials with a cloud provider or other service configured to trust the server as an
+// identity provider, so that the build doesn't need long-lived secrets. Tokens expire after a few minutes,
+// so request a new one each time credentials are needed.
+func (b *Build) GetOIDCToken(audience string) (*client.OIDCToken, error) {
+	request := client.NewOIDCTokenRequest()
+	if audience != "" {
+		request.SetAudience(audience)
+	}
+	buildAPI := b.apiClient.BuildApi
+
+	token, response, err := buildAPI.CreateOIDCToken(b.GetAuthorizedContext(), b.DynamicJobID.String()).
+		OIDCTokenRequest(*request).
+		Execute()
+	var statusCode int
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	if err != nil {
+		openAPIErr, ok := err.(*client.GenericOpenAPIError)
+		if ok {
+			return nil, fmt.Errorf("error getting OIDC token from server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
+		}
+		return nil, fmt.Errorf("error getting OIDC token from server (response status code %d): %w", statusCode, err)
+	}
+
+	return token, nil
+}
+
+// MustGetOIDCToken asks the server to issue a short-lived OpenID Connect ID token identifying this job.
+// See GetOIDCToken for details.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustGetOIDCToken(audience string) *client.OIDCToken {
+	token, err := b.GetOIDCToken(audience)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return token
+}
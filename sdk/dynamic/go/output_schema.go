@@ -0,0 +1,218 @@
+package bb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// outputSchema is a JSON schema that the value of a workflow output must conform to. The commonly used subset
+// of JSON Schema is supported: type, enum, properties, required, additionalProperties (as a boolean), items,
+// minimum, maximum, minLength, maxLength, minItems and maxItems. Other keywords are ignored.
+type outputSchema struct {
+	Type                 schemaTypes              `json:"type"`
+	Enum                 []interface{}            `json:"enum"`
+	Properties           map[string]*outputSchema `json:"properties"`
+	Required             []string                 `json:"required"`
+	AdditionalProperties *bool                    `json:"additionalProperties"`
+	Items                *outputSchema            `json:"items"`
+	Minimum              *float64                 `json:"minimum"`
+	Maximum              *float64                 `json:"maximum"`
+	MinLength            *int                     `json:"minLength"`
+	MaxLength            *int                     `json:"maxLength"`
+	MinItems             *int                     `json:"minItems"`
+	MaxItems             *int                     `json:"maxItems"`
+}
+
+// schemaTypes is the value of a schema's 'type' keyword, which can be either a single type name or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("'type' must be a string or an array of strings")
+	}
+	*t = list
+	return nil
+}
+
+var schemaTypeNames = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// parseOutputSchema parses and checks a JSON schema for a workflow output.
+func parseOutputSchema(schema string) (*outputSchema, error) {
+	parsed := &outputSchema{}
+	err := json.Unmarshal([]byte(schema), parsed)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON schema: %w", err)
+	}
+	err = parsed.check("$")
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// check returns an error if the schema uses an unknown type name, at this level or any nested level.
+func (s *outputSchema) check(path string) error {
+	for _, typeName := range s.Type {
+		if !schemaTypeNames[typeName] {
+			return fmt.Errorf("error in JSON schema at %s: unknown type '%s'", path, typeName)
+		}
+	}
+	for name, property := range s.Properties {
+		if err := property.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// validateValue checks that the JSON encoding of value conforms to the schema.
+func (s *outputSchema) validateValue(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error encoding value as JSON: %w", err)
+	}
+	return s.validateJSON(data)
+}
+
+// validateJSON checks that the supplied JSON conforms to the schema.
+func (s *outputSchema) validateJSON(data []byte) error {
+	var decoded interface{}
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return fmt.Errorf("error decoding JSON: %w", err)
+	}
+	return s.validate(decoded, "$")
+}
+
+func (s *outputSchema) validate(value interface{}, path string) error {
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		return fmt.Errorf("%s: expected %s but got %s", path, strings.Join(s.Type, " or "), jsonTypeName(value))
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %s is not one of the allowed values", path, jsonString(value))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property '%s'", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names) // report errors in a consistent order
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property '%s'", path, name)
+				}
+				continue
+			}
+			if err := property.validate(v[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s: expected at least %d items but got %d", path, *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fmt.Errorf("%s: expected at most %d items but got %d", path, *s.MaxItems, len(v))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Errorf("%s: expected at least %d characters but got %d", path, *s.MinLength, length)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Errorf("%s: expected at most %d characters but got %d", path, *s.MaxLength, length)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%s: expected a value of at least %v but got %v", path, *s.Minimum, v)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fmt.Errorf("%s: expected a value of at most %v but got %v", path, *s.Maximum, v)
+		}
+	}
+	return nil
+}
+
+func (s *outputSchema) matchesType(value interface{}) bool {
+	actual := jsonTypeName(value)
+	for _, typeName := range s.Type {
+		if typeName == actual {
+			return true
+		}
+		if typeName == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON schema type name for a value decoded from JSON.
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,76 @@
+package bb
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// GetParameter returns the value of the build parameter with the specified name. Returns an error if the
+// build definition does not declare the parameter.
+func (b *Build) GetParameter(name string) (string, error) {
+	value, ok := b.Parameters[name]
+	if !ok {
+		return "", fmt.Errorf("error build parameter '%s' not found; parameters must be declared in the build definition", name)
+	}
+	return value, nil
+}
+
+// MustGetParameter returns the value of the build parameter with the specified name.
+// Terminates this program if the build definition does not declare the parameter.
+func (b *Build) MustGetParameter(name string) string {
+	value, err := b.GetParameter(name)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return value
+}
+
+// GetBoolParameter returns the value of the boolean build parameter with the specified name.
+func (b *Build) GetBoolParameter(name string) (bool, error) {
+	value, err := b.GetParameter(name)
+	if err != nil {
+		return false, err
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("error build parameter '%s' must be true or false but found '%s'", name, value)
+	}
+	return parsed, nil
+}
+
+// MustGetBoolParameter returns the value of the boolean build parameter with the specified name.
+// Terminates this program if the parameter is not declared or is not a boolean.
+func (b *Build) MustGetBoolParameter(name string) bool {
+	value, err := b.GetBoolParameter(name)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return value
+}
+
+// GetNumberParameter returns the value of the number build parameter with the specified name.
+func (b *Build) GetNumberParameter(name string) (float64, error) {
+	value, err := b.GetParameter(name)
+	if err != nil {
+		return 0, err
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error build parameter '%s' must be a number but found '%s'", name, value)
+	}
+	return parsed, nil
+}
+
+// MustGetNumberParameter returns the value of the number build parameter with the specified name.
+// Terminates this program if the parameter is not declared or is not a number.
+func (b *Build) MustGetNumberParameter(name string) float64 {
+	value, err := b.GetNumberParameter(name)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return value
+}
@@ -0,0 +1,141 @@
+package bb
+
+import (
+	"fmt"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+const (
+	// PlatformOSEnvVar is set on each job created by ForEachPlatform to the operating system of the target platform.
+	PlatformOSEnvVar = "BB_PLATFORM_OS"
+	// PlatformArchEnvVar is set on each job created by ForEachPlatform to the architecture of the target platform.
+	PlatformArchEnvVar = "BB_PLATFORM_ARCH"
+)
+
+// Platform is an OS/architecture target for a cross-platform build matrix, along with the Docker or exec
+// configuration used to run jobs for that target.
+type Platform struct {
+	os     string
+	arch   string
+	docker *DockerConfig
+	runsOn []string
+}
+
+// NewPlatform returns a new platform for the specified operating system (e.g. 'linux', 'windows', 'darwin')
+// and architecture (e.g. 'amd64', 'arm64').
+func NewPlatform(os string, arch string) *Platform {
+	return &Platform{os: os, arch: arch}
+}
+
+// GetOS returns the operating system of the platform.
+func (p *Platform) GetOS() string {
+	return p.os
+}
+
+// GetArch returns the architecture of the platform.
+func (p *Platform) GetArch() string {
+	return p.arch
+}
+
+// GetName returns a name for the platform made from its operating system and architecture, e.g. 'linux-amd64'.
+func (p *Platform) GetName() ResourceName {
+	if p.arch == "" {
+		return ResourceName(p.os)
+	}
+	return ResourceName(fmt.Sprintf("%s-%s", p.os, p.arch))
+}
+
+// Docker configures jobs for this platform to run in a Docker container using the specified configuration.
+func (p *Platform) Docker(dockerConfig *DockerConfig) *Platform {
+	p.docker = dockerConfig
+	return p
+}
+
+// Exec configures jobs for this platform to run directly on the runner host, using a runner with all
+// the specified labels (e.g. 'windows', 'amd64').
+func (p *Platform) Exec(runsOn ...string) *Platform {
+	p.docker = nil
+	p.runsOn = append(p.runsOn, runsOn...)
+	return p
+}
+
+// RunsOn adds labels that runners must have in order to run jobs for this platform. Labels are added to any
+// specified by the job being cloned.
+func (p *Platform) RunsOn(labels ...string) *Platform {
+	p.runsOn = append(p.runsOn, labels...)
+	return p
+}
+
+// PlatformJobs is the set of jobs created by ForEachPlatform, one per platform. A job can fan in on all
+// platforms by passing the set to DependsOnJobs or DependsOnJobArtifacts, e.g. job.DependsOnJobs(jobs...)
+type PlatformJobs []*Job
+
+// AddTo adds each of the jobs to the specified workflow.
+func (jobs PlatformJobs) AddTo(workflow *Workflow) PlatformJobs {
+	for _, job := range jobs {
+		workflow.Job(job)
+	}
+	return jobs
+}
+
+// ForPlatform returns the job created for the platform with the specified name (e.g. 'linux-amd64'),
+// or nil if there is no such job.
+func (jobs PlatformJobs) ForPlatform(platformName ResourceName) *Job {
+	for _, job := range jobs {
+		if job.platform != nil && job.platform.GetName() == platformName {
+			return job
+		}
+	}
+	return nil
+}
+
+// ForEachPlatform clones the specified job once for each platform. Each clone is named after the original job
+// with the platform name appended (e.g. 'build-linux-amd64'), runs using the platform's Docker or exec
+// configuration, and has the BB_PLATFORM_OS and BB_PLATFORM_ARCH environment variables set.
+// The original job is used as a template only and should not itself be added to a workflow.
+func ForEachPlatform(job *Job, platforms ...*Platform) PlatformJobs {
+	jobs := make(PlatformJobs, 0, len(platforms))
+	for _, platform := range platforms {
+		clone := job.clone()
+		clone.platform = platform
+		clone.Name(ResourceName(fmt.Sprintf("%s-%s", job.GetName(), platform.GetName())))
+		if platform.docker != nil {
+			clone.Docker(platform.docker)
+		} else {
+			clone.Type(JobTypeExec)
+			clone.definition.Docker = nil
+		}
+		clone.RunsOn(platform.runsOn...)
+		clone.Env(NewEnv().Name(PlatformOSEnvVar).Value(platform.os))
+		clone.Env(NewEnv().Name(PlatformArchEnvVar).Value(platform.arch))
+		jobs = append(jobs, clone)
+	}
+	return jobs
+}
+
+// clone returns a copy of the job that can be modified without affecting the original.
+func (job *Job) clone() *Job {
+	clone := &Job{
+		definition:                       job.definition,
+		platform:                         job.platform,
+		completionCallbacksToRegister:    append([]JobCallback(nil), job.completionCallbacksToRegister...),
+		successCallbacksToRegister:       append([]JobCallback(nil), job.successCallbacksToRegister...),
+		failureCallbacksToRegister:       append([]JobCallback(nil), job.failureCallbacksToRegister...),
+		cancelledCallbacksToRegister:     append([]JobCallback(nil), job.cancelledCallbacksToRegister...),
+		statusChangedCallbacksToRegister: append([]JobCallback(nil), job.statusChangedCallbacksToRegister...),
+	}
+	clone.definition.Workflow = nil
+	clone.definition.RunsOn = append([]string(nil), job.definition.RunsOn...)
+	clone.definition.Depends = append([]string(nil), job.definition.Depends...)
+	clone.definition.ArtifactFrom = append([]string(nil), job.definition.ArtifactFrom...)
+	clone.definition.Fingerprint = append([]string(nil), job.definition.Fingerprint...)
+	clone.definition.Services = append([]client.ServiceDefinition(nil), job.definition.Services...)
+	clone.definition.Artifacts = append([]client.ArtifactDefinition(nil), job.definition.Artifacts...)
+	clone.definition.Steps = append([]client.StepDefinition(nil), job.definition.Steps...)
+	clone.definition.Environment = make(map[string]client.SecretStringDefinition, len(job.definition.Environment))
+	for name, value := range job.definition.Environment {
+		clone.definition.Environment[name] = value
+	}
+	return clone
+}
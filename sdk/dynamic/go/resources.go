@@ -0,0 +1,42 @@
+package bb
+
+import "github.com/buildbeaver/sdk/dynamic/bb/client"
+
+// Resources declares the CPU, memory, disk space and GPUs a job requires. The job only runs on a runner with
+// enough spare capacity, and docker jobs are limited to the CPU and memory they request.
+type Resources struct {
+	definition client.ResourcesDefinition
+}
+
+func NewResources() *Resources {
+	return &Resources{definition: client.ResourcesDefinition{}}
+}
+
+func (resources *Resources) GetData() client.ResourcesDefinition {
+	return resources.definition
+}
+
+// CPU sets the CPU required, as a number of cores (e.g. "2" or "1.5") or as thousandths of a core
+// with an 'm' suffix (e.g. "500m").
+func (resources *Resources) CPU(cpu string) *Resources {
+	resources.definition.Cpu = &cpu
+	return resources
+}
+
+// Memory sets the memory required, as a number of bytes or with a unit suffix (e.g. "512MiB" or "4GB").
+func (resources *Resources) Memory(memory string) *Resources {
+	resources.definition.Memory = &memory
+	return resources
+}
+
+// Disk sets the disk space required, as a number of bytes or with a unit suffix (e.g. "20GB").
+func (resources *Resources) Disk(disk string) *Resources {
+	resources.definition.Disk = &disk
+	return resources
+}
+
+// GPUs sets the number of GPUs required. The job only runs on a runner with enough free GPUs.
+func (resources *Resources) GPUs(gpus int64) *Resources {
+	resources.definition.Gpus = &gpus
+	return resources
+}
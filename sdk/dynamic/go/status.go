@@ -19,13 +19,16 @@ const (
 	StatusSucceeded Status = "succeeded"
 	// StatusCanceled indicates the item was canceled before it was ever processed.
 	StatusCanceled Status = "canceled"
+	// StatusSkipped indicates the item was skipped because its condition was not met.
+	StatusSkipped Status = "skipped"
 	// StatusUnknown indicates the item is in an unknown state.
 	StatusUnknown Status = "unknown"
 )
 
-// HasFinished returns true if the status indicates the workflow has finished, either succeeded, failed, or canceled.
+// HasFinished returns true if the status indicates the workflow has finished, either succeeded, failed, canceled
+// or skipped.
 func (s Status) HasFinished() bool {
-	return s == StatusFailed || s == StatusSucceeded || s == StatusCanceled
+	return s == StatusFailed || s == StatusSucceeded || s == StatusCanceled || s == StatusSkipped
 }
 
 // HasFailed returns true if the status indicates the workflow has either failed or been canceled.
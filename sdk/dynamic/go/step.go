@@ -1,6 +1,8 @@
 package bb
 
 import (
+	"time"
+
 	"github.com/buildbeaver/sdk/dynamic/bb/client"
 )
 
@@ -35,6 +37,26 @@ func (step *Step) Commands(commands ...string) *Step {
 	return step
 }
 
+// If sets a condition that must be true for the step to run, e.g. 'changed_files matches "docs/**"'. If the
+// condition is false when the job is enqueued then the step, and any steps that depend on it, are skipped.
+func (step *Step) If(condition string) *Step {
+	step.definition.If = &condition
+	return step
+}
+
+// Timeout sets the maximum time the step's commands may run for before they are killed and the step fails.
+func (step *Step) Timeout(timeout time.Duration) *Step {
+	str := timeout.String()
+	step.definition.Timeout = &str
+	return step
+}
+
+// Echo sets whether each of the step's commands is printed to the step's log as it runs (like sh's 'set -x').
+func (step *Step) Echo(echo bool) *Step {
+	step.definition.Echo = &echo
+	return step
+}
+
 func (step *Step) Depends(stepNames ...string) *Step {
 	step.definition.Depends = append(step.definition.Depends, stepNames...)
 	return step
@@ -0,0 +1,97 @@
+package bb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// RegisterToolchain registers a Docker image (typically one built earlier in this build) as a version of a
+// named toolchain for the owner of the build's repo, so that jobs in this and later builds can run in it via
+// DockerConfig.Toolchain("name@version"). digest is the optional content digest of the image (e.g.
+// "sha256:..."); if set, jobs run in exactly this image even if the image's tag is later moved.
+// Registering a version that already exists with the same image and digest has no effect, but a registered
+// version can't be changed to a different image.
+func (b *Build) RegisterToolchain(name ResourceName, version string, image string, digest string) (*client.Toolchain, error) {
+	definition := client.NewToolchainDefinition(name.String(), version, image)
+	if digest != "" {
+		definition.SetDigest(digest)
+	}
+	return b.registerToolchain(definition)
+}
+
+// RegisterRebuiltToolchain registers a Docker image as a version of a named toolchain, as for RegisterToolchain,
+// and records that the image is built from the specified Dockerfile in this build's repo (a path relative to
+// the root of the repo). Whenever the Dockerfile changes on the repo's default branch the server runs
+// rebuildWorkflow, which should build the image again and register it as a new version of the toolchain.
+func (b *Build) RegisterRebuiltToolchain(
+	name ResourceName,
+	version string,
+	image string,
+	digest string,
+	dockerfile string,
+	rebuildWorkflow ResourceName,
+) (*client.Toolchain, error) {
+	definition := client.NewToolchainDefinition(name.String(), version, image)
+	if digest != "" {
+		definition.SetDigest(digest)
+	}
+	definition.SetDockerfile(dockerfile)
+	definition.SetRebuildWorkflow(rebuildWorkflow.String())
+	return b.registerToolchain(definition)
+}
+
+func (b *Build) registerToolchain(definition *client.ToolchainDefinition) (*client.Toolchain, error) {
+	Log(LogLevelInfo, fmt.Sprintf("Registering toolchain %s@%s with image %s for build %s", definition.Name, definition.Version, definition.Image, b.ID))
+	buildAPI := b.apiClient.BuildApi
+
+	toolchain, response, err := buildAPI.RegisterToolchain(b.GetAuthorizedContext(), b.ID.String()).
+		ToolchainDefinition(*definition).
+		Execute()
+	var statusCode int
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	if err != nil {
+		openAPIErr, ok := err.(*client.GenericOpenAPIError)
+		if ok {
+			return nil, fmt.Errorf("error registering toolchain with server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
+		}
+		return nil, fmt.Errorf("error registering toolchain with server (response status code %d): %w", statusCode, err)
+	}
+	Log(LogLevelInfo, fmt.Sprintf("Registered toolchain %s", toolchain.Reference))
+
+	return toolchain, nil
+}
+
+// MustRegisterToolchain registers a Docker image as a version of a named toolchain for the owner of the
+// build's repo. See RegisterToolchain for details.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustRegisterToolchain(name ResourceName, version string, image string, digest string) *client.Toolchain {
+	toolchain, err := b.RegisterToolchain(name, version, image, digest)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return toolchain
+}
+
+// MustRegisterRebuiltToolchain registers a Docker image as a version of a named toolchain that is rebuilt when
+// its Dockerfile changes. See RegisterRebuiltToolchain for details.
+// Terminates this program if a persistent error occurs.
+func (b *Build) MustRegisterRebuiltToolchain(
+	name ResourceName,
+	version string,
+	image string,
+	digest string,
+	dockerfile string,
+	rebuildWorkflow ResourceName,
+) *client.Toolchain {
+	toolchain, err := b.RegisterRebuiltToolchain(name, version, image, digest, dockerfile, rebuildWorkflow)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return toolchain
+}
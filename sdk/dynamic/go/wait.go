@@ -0,0 +1,193 @@
+package bb
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+const (
+	// maxServerWait is the longest the server will wait before responding to a request that asks it to wait
+	// for an artifact or log to be sealed. Longer waits are made up of several requests.
+	maxServerWait = 25 * time.Second
+	// waitPollInterval is how often to check again if the server responds before it was asked to, which
+	// happens if the server is unable to wait for notifications.
+	waitPollInterval = 5 * time.Second
+)
+
+// WaitForArtifacts waits until at least one of the selected artifacts from the current build has been sealed
+// (i.e. has finished uploading), then returns all of the selected artifacts that have been sealed. An empty
+// workflow matches jobs in any workflow, and an empty job name or group name matches any job or group.
+// The server notifies waiting requests as soon as an artifact is sealed, so this does not busy-poll.
+// A timeout of zero waits indefinitely; otherwise an error is returned if no selected artifact has been sealed
+// before the timeout expires.
+func (b *Build) WaitForArtifacts(workflow string, jobName string, groupName string, timeout time.Duration) ([]client.Artifact, error) {
+	Log(LogLevelInfo, fmt.Sprintf("Waiting for artifacts, workflow '%s', job '%s', group name '%s'", workflow, jobName, groupName))
+	err := waitUntil(timeout, func(wait time.Duration) (bool, error) {
+		request := NewBuildApiListArtifactsRequest(b, workflow, jobName, groupName, 100).Wait(int32(wait.Seconds()))
+		page, err := ListArtifacts(b, &request)
+		if err != nil {
+			return false, err
+		}
+		return len(sealedArtifacts(page.Artifacts)) > 0, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for artifacts, workflow '%s', job '%s', group name '%s': %w", workflow, jobName, groupName, err)
+	}
+	// The first sealed artifact may not be on the first page, so read every page to find the rest
+	artifacts, err := b.ListAllArtifacts(workflow, jobName, groupName)
+	if err != nil {
+		return nil, err
+	}
+	return sealedArtifacts(artifacts), nil
+}
+
+// MustWaitForArtifacts waits until at least one of the selected artifacts from the current build has been
+// sealed, then returns all of the selected artifacts that have been sealed. See WaitForArtifacts for details.
+// Terminates this program if the timeout expires or a persistent error occurs.
+func (b *Build) MustWaitForArtifacts(workflow string, jobName string, groupName string, timeout time.Duration) []client.Artifact {
+	artifacts, err := b.WaitForArtifacts(workflow, jobName, groupName, timeout)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return artifacts
+}
+
+// WaitForLogSealed waits until the log with the specified log descriptor ID has been sealed (i.e. is complete
+// and will not be written to again), then returns the log descriptor.
+// A timeout of zero waits indefinitely; otherwise an error is returned if the log has not been sealed before
+// the timeout expires.
+func (b *Build) WaitForLogSealed(logDescriptorID string, timeout time.Duration) (*client.LogDescriptor, error) {
+	Log(LogLevelInfo, fmt.Sprintf("Waiting for log to be sealed for log descriptor ID %s", logDescriptorID))
+	var logDescriptor *client.LogDescriptor
+	err := waitUntil(timeout, func(wait time.Duration) (bool, error) {
+		buildAPI := b.apiClient.BuildApi
+		result, response, err := buildAPI.GetLogDescriptor(b.GetAuthorizedContext(), logDescriptorID).
+			Wait(int32(wait.Seconds())).
+			Execute()
+		var statusCode int
+		if response != nil {
+			statusCode = response.StatusCode
+		}
+		if err != nil {
+			openAPIErr, ok := err.(*client.GenericOpenAPIError)
+			if ok {
+				return false, fmt.Errorf("error fetching log descriptor from server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
+			}
+			return false, fmt.Errorf("error fetching log descriptor from server (response status code %d): %w", statusCode, err)
+		}
+		logDescriptor = result
+		return logDescriptor.Sealed, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for log with descriptor ID %s to be sealed: %w", logDescriptorID, err)
+	}
+	return logDescriptor, nil
+}
+
+// MustWaitForLogSealed waits until the log with the specified log descriptor ID has been sealed, then returns
+// the log descriptor.
+// Terminates this program if the timeout expires or a persistent error occurs.
+func (b *Build) MustWaitForLogSealed(logDescriptorID string, timeout time.Duration) *client.LogDescriptor {
+	logDescriptor, err := b.WaitForLogSealed(logDescriptorID, timeout)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return logDescriptor
+}
+
+// WaitForJobLog waits until the log for the job with the specified ID has been sealed, which happens once the
+// job has finished, then returns the log descriptor. The log's contents can then be read in full using
+// ReadLogText or ReadLogData.
+// A timeout of zero waits indefinitely; otherwise an error is returned if the log has not been sealed before
+// the timeout expires.
+func (b *Build) WaitForJobLog(jobID JobID, timeout time.Duration) (*client.LogDescriptor, error) {
+	job, err := b.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return b.WaitForLogSealed(job.LogDescriptorId, timeout)
+}
+
+// MustWaitForJobLog waits until the log for the job with the specified ID has been sealed, then returns the
+// log descriptor.
+// Terminates this program if the timeout expires or a persistent error occurs.
+func (b *Build) MustWaitForJobLog(jobID JobID, timeout time.Duration) *client.LogDescriptor {
+	logDescriptor, err := b.WaitForJobLog(jobID, timeout)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return logDescriptor
+}
+
+// WaitForArtifacts waits until at least one of the selected artifacts produced by jobs in this workflow has been
+// sealed, then returns all of the selected artifacts that have been sealed. An empty job name matches every job
+// in the workflow, and an empty group name matches every artifact group.
+// A timeout of zero waits indefinitely; otherwise an error is returned if no selected artifact has been sealed
+// before the timeout expires.
+func (w *Workflow) WaitForArtifacts(jobName string, groupName string, timeout time.Duration) ([]client.Artifact, error) {
+	return w.build.WaitForArtifacts(w.GetName().String(), jobName, groupName, timeout)
+}
+
+// MustWaitForArtifacts waits until at least one of the selected artifacts produced by jobs in this workflow has
+// been sealed, then returns all of the selected artifacts that have been sealed.
+// Terminates this program if the timeout expires or a persistent error occurs.
+func (w *Workflow) MustWaitForArtifacts(jobName string, groupName string, timeout time.Duration) []client.Artifact {
+	return w.build.MustWaitForArtifacts(w.GetName().String(), jobName, groupName, timeout)
+}
+
+// waitUntil calls check until it returns true, passing the time the server should wait before responding.
+// Returns an error if check returns an error, or if timeout is non-zero and expires before check returns true.
+func waitUntil(timeout time.Duration, check func(wait time.Duration) (bool, error)) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		wait := maxServerWait
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining < wait {
+				wait = remaining
+			}
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		start := time.Now()
+		done, err := check(wait)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		// The server waits in whole seconds, and may not be able to wait at all; avoid busy-polling it
+		// if it responded early
+		if early := wait - time.Since(start); early > 0 {
+			if early > waitPollInterval {
+				early = waitPollInterval
+			}
+			time.Sleep(early)
+		}
+	}
+}
+
+// sealedArtifacts returns the artifacts that have been sealed.
+func sealedArtifacts(artifacts []client.Artifact) []client.Artifact {
+	var sealed []client.Artifact
+	for _, artifact := range artifacts {
+		if artifact.Sealed {
+			sealed = append(sealed, artifact)
+		}
+	}
+	return sealed
+}
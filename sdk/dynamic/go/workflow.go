@@ -1,8 +1,10 @@
 package bb
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
 	"sync"
 	"time"
 
@@ -20,11 +22,13 @@ type Workflow struct {
 	// internal state
 	startMutex         sync.Mutex // covers isStarted and the startup process
 	isStarted          bool
+	isStubbed          bool // set before any workflows are started, so no need to lock
 	jobCallbackManager *JobCallbackManager
 
-	isHandlerFinished  bool // no need to lock; this variable is monotonic and boolean
-	isWorkflowFinished bool // no need to lock; this variable is monotonic and boolean
-	hasWorkflowFailed  bool // no need to lock; this variable is monotonic and boolean
+	isHandlerFinished  bool  // no need to lock; this variable is monotonic and boolean
+	isWorkflowFinished bool  // no need to lock; this variable is monotonic and boolean
+	hasWorkflowFailed  bool  // no need to lock; this variable is monotonic and boolean
+	handlerErr         error // first error returned by a handler; only accessed by the handler-running goroutine until it is done
 
 	jobMutex     sync.Mutex            // covers newJobs, newJobErrors
 	newJobs      map[ResourceName]*Job // maps job name to job
@@ -66,7 +70,7 @@ func (w *Workflow) start(wg *sync.WaitGroup) {
 		Log(LogLevelFatal, fmt.Sprintf("Attempt to start a workflow with no build (workflow name '%s')", w.GetName()))
 		os.Exit(1)
 	}
-	if w.isStarted {
+	if w.isStarted || w.isStubbed {
 		return // no-op
 	}
 
@@ -87,31 +91,109 @@ func (w *Workflow) start(wg *sync.WaitGroup) {
 		// Wait for any dependency workflows to finish
 		w.waitForDependencyWorkflows()
 
-		// Run the workflow handler
+		// Run the workflow handler. If it fails, the jobs it already created are still submitted and the
+		// finally handler is still run (e.g. to tear down resources created by those jobs); the error is
+		// reported once the workflow's handlers have all finished.
 		err := w.definition.handler(w)
 		if err != nil {
-			// TODO: Don't necessarily quit the process here; this is a bad thing to do in tests
-			Log(LogLevelFatal, fmt.Sprintf("Error submitting new jobs to build for workflow '%s': %s", w.GetName(), err.Error()))
-			os.Exit(1)
+			w.recordHandlerError(fmt.Errorf("error submitting new jobs to build for workflow '%s': %w", w.GetName(), err))
 		}
 
 		// Submit any jobs that haven't already been submitted, and wait for callbacks to be run.
 		// This also updates the stats with the new jobs, via the event manager.
-		_, err = w.Submit(true)
-		if err != nil {
-			msg := fmt.Sprintf("Error submitting new job to build at end of workflow: %s", err.Error())
-			if w.definition.submitFailureIsFatal {
-				Log(LogLevelFatal, msg)
-				os.Exit(1)
-			} else {
-				Log(LogLevelError, msg)
+		w.submitAtEndOfHandler()
+
+		// Run the finally handler once all jobs so far have finished, then submit any jobs it created
+		if w.definition.finallyHandler != nil {
+			w.waitForJobsToFinish()
+			Log(LogLevelInfo, fmt.Sprintf("Running finally handler for workflow '%s'", w.GetName()))
+			err := w.definition.finallyHandler(w)
+			if err != nil {
+				w.recordHandlerError(fmt.Errorf("error submitting new jobs to build in finally handler for workflow '%s': %w", w.GetName(), err))
 			}
+			w.submitAtEndOfHandler()
 		}
 		w.isHandlerFinished = true // no need for lock
 		w.updateWorkflowStatus()
 	}()
 }
 
+// submitAtEndOfHandler submits any jobs that haven't already been submitted and waits for callbacks to be run,
+// after a handler function has returned.
+func (w *Workflow) submitAtEndOfHandler() {
+	_, err := w.Submit(true)
+	if err != nil {
+		msg := fmt.Sprintf("Error submitting new job to build at end of workflow: %s", err.Error())
+		if w.definition.submitFailureIsFatal {
+			Log(LogLevelFatal, msg)
+			os.Exit(1)
+		} else {
+			Log(LogLevelError, msg)
+		}
+	}
+}
+
+// recordHandlerError logs an error returned by one of the workflow's handlers and marks the workflow as failed.
+// The first error recorded is returned from getHandlerError.
+func (w *Workflow) recordHandlerError(err error) {
+	Log(LogLevelError, err.Error())
+	Log(LogLevelInfo, fmt.Sprintf("Workflow '%s' set to failed", w.GetName()))
+	w.hasWorkflowFailed = true // No need for lock. Never set this back to false.
+	if w.handlerErr == nil {
+		w.handlerErr = err
+	}
+}
+
+// getHandlerError returns the first error returned by one of the workflow's handlers, or nil if none failed.
+// This must only be called after the handler-running goroutine has finished.
+func (w *Workflow) getHandlerError() error {
+	return w.handlerErr
+}
+
+// waitForJobsToFinish waits until there are no unfinished jobs in the workflow, and updates the workflow's
+// failed status from the final job statuses.
+func (w *Workflow) waitForJobsToFinish() {
+	w.build.eventManager.waitForWorkflowStats(w.GetName(), func(stats WorkflowStats) bool {
+		return stats.UnfinishedJobCount == 0
+	})
+	w.updateWorkflowStatus()
+}
+
+// stub marks the workflow as finished without running it, and sets its outputs from the supplied JSON values.
+// Each stubbed output must have been declared in the workflow definition so that its value can be decoded.
+func (w *Workflow) stub(values map[string]json.RawMessage) error {
+	w.startMutex.Lock()
+	defer w.startMutex.Unlock()
+
+	if w.isStarted {
+		return fmt.Errorf("error: workflow '%s' can not be stubbed because it has already been started", w.GetName())
+	}
+	for outputName, value := range values {
+		outputType, ok := w.definition.outputTypes[outputName]
+		if !ok || outputType.Kind() != reflect.Pointer {
+			return fmt.Errorf("error: output '%s' of workflow '%s' is stubbed but has not been declared", outputName, w.GetName())
+		}
+		schema, ok := w.definition.outputSchemas[outputName]
+		if ok {
+			err := schema.validateJSON(value)
+			if err != nil {
+				return fmt.Errorf("error: stubbed output '%s' of workflow '%s' does not match JSON schema: %w", outputName, w.GetName(), err)
+			}
+		}
+		output := reflect.New(outputType.Elem()).Interface()
+		err := json.Unmarshal(value, output)
+		if err != nil {
+			return fmt.Errorf("error decoding stubbed output '%s' of workflow '%s': %w", outputName, w.GetName(), err)
+		}
+		w.SetOutput(outputName, output)
+	}
+
+	w.isStubbed = true
+	w.isHandlerFinished = true
+	w.isWorkflowFinished = true
+	return nil
+}
+
 func (w *Workflow) statsUpdated() {
 	Log(LogLevelDebug, fmt.Sprintf("Stats updated notification received for workflow '%s'", w.GetName()))
 
@@ -214,9 +296,18 @@ func (w *Workflow) sendNewJobsToServer() ([]client.JobGraph, error) {
 		return nil, fmt.Errorf("error: %d error(s) found during Job creation", len(w.newJobErrors))
 	}
 
+	// Fill in any settings the new jobs haven't made from the workflow's defaults
+	if w.definition.jobDefaults != nil {
+		defaults := NewJobFrom(w.definition.jobDefaults).definition
+		for _, job := range w.newJobs {
+			job.applyDefaults(defaults)
+		}
+	}
+
 	// Ensure that all job dependencies specify a workflow, and that any dependent workflows are started.
 	// Do this while holding the jobMutex.
 	for _, job := range w.newJobs {
+		job.removeDependenciesOnStubbedWorkflows()
 		err := validateJobDependencies(job.definition.Depends)
 		if err != nil {
 			return nil, fmt.Errorf("error: job '%s' has an indvalid job dependency: %w", job.GetReference(), err)
@@ -278,6 +369,74 @@ func (w *Workflow) processCreateJobResults(results []client.JobGraph) error {
 	return nil
 }
 
+// UpdateJob sends the current definition of a job that has already been submitted to the server, updating
+// the job in the build. This allows environment variables, steps or dependencies to be added to a job after it
+// was submitted, for example based on the results of other jobs, as long as the job has not yet been picked up
+// by a runner.
+// Only the job's environment variables and dependencies can be changed, and new steps added; an error is
+// returned for any other change, or if the job has already been picked up by a runner.
+func (w *Workflow) UpdateJob(job *Job) (*client.JobGraph, error) {
+	w.jobMutex.Lock()
+	defer w.jobMutex.Unlock()
+
+	if job.workflow != w {
+		return nil, fmt.Errorf("error: job '%s' is not part of workflow '%s'", job.GetName(), w.GetName())
+	}
+	if _, found := w.newJobs[job.GetName()]; found {
+		return nil, fmt.Errorf("error: job '%s' has not been submitted to the server yet; call Submit() to submit new jobs", job.GetReference())
+	}
+
+	// Ensure that all job dependencies specify a workflow, and that any dependent workflows are started
+	job.removeDependenciesOnStubbedWorkflows()
+	err := validateJobDependencies(job.definition.Depends)
+	if err != nil {
+		return nil, fmt.Errorf("error: job '%s' has an indvalid job dependency: %w", job.GetReference(), err)
+	}
+	for _, workflow := range job.getWorkflowDependencies() {
+		err = globalWorkflowManager.ensureWorkflowStarted(workflow)
+		if err != nil {
+			return nil, fmt.Errorf("error validating updated job: %w", err)
+		}
+	}
+
+	jobsAPI := w.build.GetAPIClient().JobsApi
+	buildDefinition := client.NewBuildDefinition(BuildDefinitionSyntaxVersion, []client.JobDefinition{job.definition})
+
+	Log(LogLevelInfo, fmt.Sprintf("Sending updated job '%s' to server", job.GetReference()))
+	jGraphs, response, err := jobsAPI.UpdateJobs(w.build.GetAuthorizedContext(), w.build.ID.String()).
+		BuildDefinition(*buildDefinition).
+		Execute()
+	statusCode := int(0)
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	if err != nil {
+		openAPIErr, ok := err.(*client.GenericOpenAPIError)
+		if ok {
+			return nil, fmt.Errorf("error sending updated job to server (response status code %d): %s - %s", statusCode, openAPIErr.Error(), openAPIErr.Body())
+		}
+		return nil, fmt.Errorf("error sending updated job to server (response status code %d): %w", statusCode, err)
+	}
+	if len(jGraphs) != 1 {
+		return nil, fmt.Errorf("error updating job '%s': expected 1 job back from server but got %d", job.GetReference(), len(jGraphs))
+	}
+	Log(LogLevelInfo, fmt.Sprintf("Updated job '%s', received back status code %d", job.GetReference(), statusCode))
+
+	return &jGraphs[0], nil
+}
+
+// MustUpdateJob sends the current definition of a job that has already been submitted to the server, updating
+// the job in the build. See UpdateJob for details.
+// Terminates this program if the job can't be updated or a persistent error occurs.
+func (w *Workflow) MustUpdateJob(job *Job) *client.JobGraph {
+	jGraph, err := w.UpdateJob(job)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return jGraph
+}
+
 func (w *Workflow) Job(job *Job) *Workflow {
 	w.jobMutex.Lock()
 	defer w.jobMutex.Unlock()
@@ -313,7 +472,7 @@ func (w *Workflow) Job(job *Job) *Workflow {
 	job.failureCallbacksToRegister = nil
 
 	for _, callback := range job.cancelledCallbacksToRegister {
-		w.OnJobCancelled(job.GetReference(), callback)
+		w.OnJobCanceled(job.GetReference(), callback)
 	}
 	job.cancelledCallbacksToRegister = nil
 
@@ -356,7 +515,7 @@ func (w *Workflow) OnJobFailure(jobRef JobReference, callback JobCallback) {
 	))
 }
 
-func (w *Workflow) OnJobCancelled(jobRef JobReference, callback JobCallback) {
+func (w *Workflow) OnJobCanceled(jobRef JobReference, callback JobCallback) {
 	status := StatusCanceled
 	w.jobCallbackManager.AddSubscription(newJobSubscription(
 		jobRef,
@@ -366,6 +525,13 @@ func (w *Workflow) OnJobCancelled(jobRef JobReference, callback JobCallback) {
 	))
 }
 
+// OnJobCancelled calls a callback function if the specified job is canceled.
+//
+// Deprecated: use OnJobCanceled, which matches the spelling of StatusCanceled.
+func (w *Workflow) OnJobCancelled(jobRef JobReference, callback JobCallback) {
+	w.OnJobCanceled(jobRef, callback)
+}
+
 // OnJobStatusChanged will call a callback function each time the status of a job changes.
 func (w *Workflow) OnJobStatusChanged(jobRef JobReference, callback JobCallback) {
 	w.jobCallbackManager.AddSubscription(newJobSubscription(
@@ -1,17 +1,30 @@
 package bb
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+)
 
 type WorkflowDefinition struct {
 	// Name is the workflow name, in URL format
 	name ResourceName `json:"name"`
 	// Handler is a function that can submit jobs for the workflow
 	handler WorkflowHandler
+	// jobDefaults is an optional template whose settings are used for any settings not made by the workflow's jobs
+	jobDefaults *JobTemplate
+	// finallyHandler is an optional function that is run once all the jobs submitted by handler have finished
+	finallyHandler WorkflowHandler
 	// True if we should terminate the entire process if we can't submit a job
 	submitFailureIsFatal bool
 	// dependencies is a list of workflow dependencies for this workflow. The meaning of each dependency is
 	// determined by the options specified in the dependency.
 	dependencies []*workflowDependency
+	// outputTypes maps the name of each declared output to the type of the output's value
+	outputTypes map[string]reflect.Type
+	// outputSchemas maps the name of an output to the JSON schema its value must conform to, if any
+	outputSchemas map[string]*outputSchema
+	// outputSchemaErrors maps the name of an output to the error from parsing its JSON schema, if any
+	outputSchemaErrors map[string]error
 }
 
 func NewWorkflow() *WorkflowDefinition {
@@ -36,6 +49,25 @@ func (w *WorkflowDefinition) Handler(handler WorkflowHandler) *WorkflowDefinitio
 	return w
 }
 
+// WithDefaults sets a template of default settings for the workflow's jobs. When jobs are submitted, each setting
+// a job has not made itself is taken from the template; e.g. a job with no Docker config set uses the template's
+// Docker config, and a job's environment variables are added to the template's. Use NewJobFrom instead to
+// have a template's settings combined with, rather than replaced by, the job's own settings.
+func (w *WorkflowDefinition) WithDefaults(template *JobTemplate) *WorkflowDefinition {
+	w.jobDefaults = template
+	return w
+}
+
+// Finally sets a handler to be run once the workflow's main handler has returned and all of the jobs submitted
+// so far have finished, whether they succeeded, failed or were canceled. The handler is run even if the main
+// handler returned an error, in which case the workflow is failed. Call IsFailed() on the workflow to find
+// out whether any jobs failed. The handler can submit further jobs (e.g. to tear down resources created by
+// earlier jobs); the workflow is not finished until these jobs have also finished.
+func (w *WorkflowDefinition) Finally(handler WorkflowHandler) *WorkflowDefinition {
+	w.finallyHandler = handler
+	return w
+}
+
 func (w *WorkflowDefinition) SubmitFailureIsFatal(isFatal bool) *WorkflowDefinition {
 	w.submitFailureIsFatal = isFatal
 	return w
@@ -49,6 +81,55 @@ func (w *WorkflowDefinition) Depends(workflowName ResourceName, options ...Workf
 	return w
 }
 
+// Output declares an output that the workflow will set, along with a prototype value showing the output's
+// type (e.g. bb.NewDocker() for a *DockerConfig output). The prototype must be a pointer.
+// Declared outputs can be stubbed with a JSON value so that other workflows can be run without running
+// this workflow, e.g. using 'bb run --stub-output'.
+func (w *WorkflowDefinition) Output(outputName string, prototype interface{}) *WorkflowDefinition {
+	if w.outputTypes == nil {
+		w.outputTypes = make(map[string]reflect.Type)
+	}
+	w.outputTypes[outputName] = reflect.TypeOf(prototype)
+	return w
+}
+
+// OutputSchema declares a JSON schema that the named output's value must conform to. The schema is checked
+// when the output is set via SetOutputAs and when the output is stubbed, so that a workflow producing or
+// being given the wrong shape of value fails with a clear error rather than later on in another workflow.
+// The commonly used subset of JSON Schema is supported: type, enum, properties, required, additionalProperties
+// (as a boolean), items, minimum, maximum, minLength, maxLength, minItems and maxItems.
+func (w *WorkflowDefinition) OutputSchema(outputName string, schema string) *WorkflowDefinition {
+	if w.outputSchemas == nil {
+		w.outputSchemas = make(map[string]*outputSchema)
+		w.outputSchemaErrors = make(map[string]error)
+	}
+	parsed, err := parseOutputSchema(schema)
+	if err != nil {
+		w.outputSchemaErrors[outputName] = err
+		return w
+	}
+	w.outputSchemas[outputName] = parsed
+	delete(w.outputSchemaErrors, outputName)
+	return w
+}
+
+// checkOutputValue returns an error if value is not of the declared type for the named output, or does not
+// conform to the output's JSON schema. Outputs that have not been declared are not checked.
+func (w *WorkflowDefinition) checkOutputValue(outputName string, value interface{}) error {
+	outputType, ok := w.outputTypes[outputName]
+	if ok && reflect.TypeOf(value) != outputType {
+		return fmt.Errorf("value has type %T but output is declared as %s", value, outputType)
+	}
+	schema, ok := w.outputSchemas[outputName]
+	if ok {
+		err := schema.validateValue(value)
+		if err != nil {
+			return fmt.Errorf("value does not match JSON schema: %w", err)
+		}
+	}
+	return nil
+}
+
 func (w *WorkflowDefinition) validate() error {
 	if w.GetName() == "" {
 		return fmt.Errorf("error validating workflow: name must not be an empty string")
@@ -56,5 +137,13 @@ func (w *WorkflowDefinition) validate() error {
 	if w.handler == nil {
 		return fmt.Errorf("error validating workflow definition '%s': a handler function must be specified", w.GetName())
 	}
+	for outputName, outputType := range w.outputTypes {
+		if outputType == nil || outputType.Kind() != reflect.Pointer {
+			return fmt.Errorf("error validating workflow definition '%s': prototype for output '%s' must be a pointer", w.GetName(), outputName)
+		}
+	}
+	for outputName, err := range w.outputSchemaErrors {
+		return fmt.Errorf("error validating workflow definition '%s': invalid schema for output '%s': %w", w.GetName(), outputName, err)
+	}
 	return nil
 }
@@ -3,6 +3,8 @@ package bb
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -18,7 +20,8 @@ type WorkflowStats struct {
 
 // Workflows defines a set of workflows for the current build, and begins processing workflows to submit jobs
 // to the build.
-// Returns when the workflow handler functions for all required workflows have completed.
+// Returns when the workflow handler functions for all required workflows have completed. If any workflow
+// handler function returned an error then the error is logged and this program exits with error code 1.
 func Workflows(workflows ...*WorkflowDefinition) {
 	// Add all workflows to global registry
 	for _, workflow := range workflows {
@@ -46,8 +49,9 @@ func Workflows(workflows ...*WorkflowDefinition) {
 // If createNewWorkflowManager is true then the global workflow manager is replaced with a new workflow manager,
 // effectively removing all existing registered workflows in order to start a new test.
 // Returns when the workflow handler functions for all required workflows have completed.
-// If an error occurs while registering or starting workflows then the error is returned rather than the process
-// being terminated.
+// If an error occurs while registering or starting workflows, or a workflow handler function returns an error,
+// then the error is returned rather than the process being terminated. Once a build has been created it is
+// returned even if an error is returned, so that it can be shut down.
 func WorkflowsWithEnv(
 	envVars map[string]string,
 	createNewWorkflowManager bool,
@@ -71,9 +75,10 @@ func WorkflowsWithEnv(
 		return nil, err
 	}
 
+	// Return the build along with any error from running workflows, so the caller can still shut it down
 	err = globalWorkflowManager.runWorkflows(build)
 	if err != nil {
-		return nil, err
+		return build, err
 	}
 
 	return build, nil
@@ -156,7 +161,8 @@ func (m *workflowManager) mustGetWorkflow(workflowName ResourceName) *Workflow {
 
 // runWorkflows calls workflow functions for a subset of the currently registered set of workflows,
 // as defined in the build. Returns an error if no workflows can be started, otherwise waits until
-// all workflows have finished running before returning.
+// all workflows have finished running before returning. Returns an error if any workflow's handler
+// functions returned an error.
 func (m *workflowManager) runWorkflows(build *Build) error {
 	err := func() error {
 		m.workflowsMutex.Lock()
@@ -175,6 +181,19 @@ func (m *workflowManager) runWorkflows(build *Build) error {
 			m.workflows[workflow.GetName()] = workflow
 		}
 
+		// Stubbed workflows are never run; their outputs are provided by the build instead
+		for workflowName, values := range m.build.WorkflowOutputStubs {
+			workflow := m.workflows[workflowName]
+			if workflow == nil {
+				return fmt.Errorf("error: stubbed workflow '%s' not found", workflowName)
+			}
+			err := workflow.stub(values)
+			if err != nil {
+				return err
+			}
+			Log(LogLevelInfo, fmt.Sprintf("Using stubbed outputs instead of running workflow '%s'", workflowName))
+		}
+
 		// Start the workflows requested in the build
 		workflowsToRun := m.build.WorkflowsToRun
 		startedCount := 0
@@ -222,6 +241,22 @@ func (m *workflowManager) runWorkflows(build *Build) error {
 	// TODO: in the AddWorkflows() so it can only be called before the workflows are started.
 
 	m.wg.Wait() // wait for all workflow functions to finish
+
+	// Report any workflow handlers that failed, now that their finally handlers have run
+	m.workflowsMutex.RLock()
+	defer m.workflowsMutex.RUnlock()
+	var handlerErrors []string
+	for _, workflow := range m.workflows {
+		if workflow.isStarted {
+			if err := workflow.getHandlerError(); err != nil {
+				handlerErrors = append(handlerErrors, err.Error())
+			}
+		}
+	}
+	if len(handlerErrors) > 0 {
+		sort.Strings(handlerErrors)
+		return fmt.Errorf("error: %d workflow(s) failed: %s", len(handlerErrors), strings.Join(handlerErrors, "; "))
+	}
 	return nil
 }
 
@@ -229,7 +264,7 @@ func (m *workflowManager) runWorkflows(build *Build) error {
 // in the dependency list of the workflow, recursively. Returns the number of new workflows started.
 // The caller must have already obtained the workflowMutex lock.
 func (m *workflowManager) startWorkflowAndDependencies(workflow *Workflow) (startedCount int, err error) {
-	if workflow == nil || workflow.isStarted {
+	if workflow == nil || workflow.isStarted || workflow.isStubbed {
 		return 0, nil
 	}
 
@@ -268,7 +303,7 @@ func (m *workflowManager) ensureWorkflowStarted(workflowName ResourceName) error
 		return fmt.Errorf("error: dependency on workflow which is not registered: '%s'", workflowName)
 	}
 
-	if !workflow.isStarted {
+	if !workflow.isStarted && !workflow.isStubbed {
 		Log(LogLevelInfo, fmt.Sprintf("Starting depencency workflow '%s'...", workflow.GetName()))
 		_, err := m.startWorkflowAndDependencies(workflow)
 		if err != nil {
@@ -278,6 +313,15 @@ func (m *workflowManager) ensureWorkflowStarted(workflowName ResourceName) error
 	return nil
 }
 
+// isWorkflowStubbed returns true if the specified workflow is registered and has been stubbed rather than run.
+func (m *workflowManager) isWorkflowStubbed(workflowName ResourceName) bool {
+	m.workflowsMutex.RLock()
+	defer m.workflowsMutex.RUnlock()
+
+	workflow := m.workflows[workflowName]
+	return workflow != nil && workflow.isStubbed
+}
+
 func (m *workflowManager) statsUpdated() {
 	// Get a list of workflows to notify, while holding the workflowsMutex lock for a minimal amount of time
 	m.workflowsMutex.RLock()
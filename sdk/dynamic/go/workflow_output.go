@@ -0,0 +1,102 @@
+package bb
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// SetOutputAs sets an output value for the workflow, in the same way as SetOutput, after checking that the
+// value matches the output's declaration. If the output was declared via WorkflowDefinition.Output then the
+// value must have the same type as the prototype, and if a JSON schema was declared via
+// WorkflowDefinition.OutputSchema then the value's JSON encoding must conform to the schema.
+// Returns an error and leaves the output unset if the value does not match.
+func SetOutputAs[T any](w *Workflow, outputName string, value T) error {
+	err := w.definition.checkOutputValue(outputName, value)
+	if err != nil {
+		return fmt.Errorf("error setting output '%s' of workflow '%s': %w", outputName, w.GetName(), err)
+	}
+	w.SetOutput(outputName, value)
+	return nil
+}
+
+// MustSetOutputAs sets an output value for the workflow by calling SetOutputAs.
+// Terminates this program if the value does not match the output's declaration.
+func MustSetOutputAs[T any](w *Workflow, outputName string, value T) {
+	err := SetOutputAs(w, outputName, value)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+}
+
+// GetOutputAs gets a previously set output value for the workflow, by name, as a value of type T.
+// Returns an error if no output value with the specified name has been set, or if the value is not of type T.
+func GetOutputAs[T any](w *Workflow, outputName string) (T, error) {
+	output := w.GetOutputOrNil(outputName)
+	if output == nil {
+		var zero T
+		return zero, fmt.Errorf("error: output '%s' of workflow '%s' has not been set", outputName, w.GetName())
+	}
+	return outputAs[T](w.GetName(), outputName, output)
+}
+
+// WaitForOutputAs waits until the workflow with the specified name has an output with the specified output name
+// available, then returns the output value as a value of type T.
+// Returns an error if the workflow has finished without providing the output, or if the output is not of type T.
+// If the output was declared via WorkflowDefinition.Output with a prototype that is not of type T then an error
+// is returned immediately, without waiting.
+func WaitForOutputAs[T any](w *Workflow, workflowName ResourceName, outputName string) (T, error) {
+	var zero T
+	workflow := globalWorkflowManager.getWorkflowOrNil(workflowName)
+	if workflow != nil {
+		outputType, ok := workflow.definition.outputTypes[outputName]
+		if ok && !isAssignableTo[T](outputType) {
+			return zero, fmt.Errorf("error: output '%s' of workflow '%s' is declared as %s, not %s",
+				outputName, workflowName, outputType, typeOf[T]())
+		}
+	}
+	output, err := w.WaitForOutput(workflowName, outputName)
+	if err != nil {
+		return zero, err
+	}
+	return outputAs[T](workflowName, outputName, output)
+}
+
+// MustWaitForOutputAs waits until the workflow with the specified name has an output with the specified output
+// name available, then returns the output value as a value of type T.
+// Terminates this program if the workflow has finished without providing the output, or if the output is not
+// of type T.
+func MustWaitForOutputAs[T any](w *Workflow, workflowName ResourceName, outputName string) T {
+	result, err := WaitForOutputAs[T](w, workflowName, outputName)
+	if err != nil {
+		Log(LogLevelFatal, err.Error())
+		os.Exit(1)
+	}
+	return result
+}
+
+// outputAs converts an output value to type T, returning an error naming the output if it is of another type.
+func outputAs[T any](workflowName ResourceName, outputName string, output interface{}) (T, error) {
+	typed, ok := output.(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("error: output '%s' of workflow '%s' has type %T, not %s",
+			outputName, workflowName, output, typeOf[T]())
+	}
+	return typed, nil
+}
+
+// typeOf returns the type T, including when T is an interface type.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// isAssignableTo returns true if values of type t can be returned as type T.
+func isAssignableTo[T any](t reflect.Type) bool {
+	target := typeOf[T]()
+	if target.Kind() == reflect.Interface {
+		return t.Implements(target)
+	}
+	return t == target
+}
@@ -0,0 +1,134 @@
+package bb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/buildbeaver/sdk/dynamic/bb/client"
+)
+
+// fakeDynamicAPI is a minimal dynamic build API server. Every job submitted to it succeeds straight away.
+type fakeDynamicAPI struct {
+	buildID BuildID
+	mu      sync.Mutex // covers jobNames and events
+	// jobNames lists the names of all jobs submitted, in the order they were submitted
+	jobNames []string
+	events   []client.Event
+}
+
+func (f *fakeDynamicAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := fmt.Sprintf("/api/v1/dynamic/builds/%s", f.buildID)
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == path+"/jobs":
+		f.createJobs(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == path+"/events":
+		f.getEvents(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDynamicAPI) createJobs(w http.ResponseWriter, r *http.Request) {
+	var definition client.BuildDefinition
+	err := json.NewDecoder(r.Body).Decode(&definition)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var jGraphs []client.JobGraph
+	for _, jobDefinition := range definition.Jobs {
+		jobID := fmt.Sprintf("%s:%d", JobResourceKind, len(f.jobNames)+1)
+		workflow := jobDefinition.GetWorkflow()
+		jobName := jobDefinition.Name
+		f.jobNames = append(f.jobNames, jobName)
+		jGraphs = append(jGraphs, client.JobGraph{Job: client.Job{Id: jobID, Name: jobName, Workflow: workflow}})
+		f.events = append(f.events, client.Event{
+			SequenceNumber: int64(len(f.events) + 1),
+			BuildId:        f.buildID.String(),
+			Type:           EventTypeJobStatusChanged.String(),
+			ResourceId:     jobID,
+			Workflow:       &workflow,
+			JobName:        &jobName,
+			ResourceName:   jobName,
+			Payload:        StatusSucceeded.String(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(jGraphs)
+}
+
+func (f *fakeDynamicAPI) getEvents(w http.ResponseWriter, r *http.Request) {
+	last, _ := strconv.ParseInt(r.URL.Query().Get("last"), 10, 64)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	newEvents := []client.Event{}
+	for _, event := range f.events {
+		if event.SequenceNumber > last {
+			newEvents = append(newEvents, event)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newEvents)
+}
+
+func (f *fakeDynamicAPI) getJobNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.jobNames...)
+}
+
+func TestWorkflowHandlerErrorStillRunsFinally(t *testing.T) {
+	api := &fakeDynamicAPI{buildID: BuildID{ResourceID: ResourceID(BuildResourceKind + ":1")}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	env := map[string]string{
+		"BB_DYNAMIC_BUILD_API":   server.URL,
+		"BB_BUILD_ID":            api.buildID.String(),
+		"BB_BUILD_ACCESS_TOKEN":  "test-token",
+		"BB_CONTROLLER_JOB_ID":   JobResourceKind + ":0",
+		"BB_CONTROLLER_JOB_NAME": "controller",
+		"BB_COMMIT_SHA":          "0000000000000000000000000000000000000000",
+		"BB_REPO_NAME":           "test-repo",
+	}
+
+	handlerErr := errors.New("handler failed")
+	workflowFailedInFinally := false
+	build, err := WorkflowsWithEnv(env, true,
+		NewWorkflow().
+			Name("deploy").
+			Handler(func(w *Workflow) error {
+				w.Job(NewJob().Name("create-resources"))
+				return handlerErr
+			}).
+			Finally(func(w *Workflow) error {
+				workflowFailedInFinally = w.IsFailed()
+				w.Job(NewJob().Name("delete-resources"))
+				return nil
+			}),
+	)
+	if build != nil {
+		defer build.Shutdown()
+	}
+
+	// The handler's error is reported once the finally handler has run
+	if err == nil || !strings.Contains(err.Error(), handlerErr.Error()) {
+		t.Fatalf("Expected handler error to be returned, got: %v", err)
+	}
+	if !workflowFailedInFinally {
+		t.Errorf("Expected workflow to be failed when finally handler runs")
+	}
+	// Jobs created by the handler before it failed are still submitted, followed by the finally handler's jobs
+	jobNames := api.getJobNames()
+	if strings.Join(jobNames, ",") != "create-resources,delete-resources" {
+		t.Errorf("Expected jobs create-resources and delete-resources to be submitted in order, got: %v", jobNames)
+	}
+}